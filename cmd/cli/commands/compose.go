@@ -63,7 +63,7 @@ func newUpCommand() *cobra.Command {
 				return errors.New("unable to determine standalone runner endpoint")
 			}
 
-			if err := downloadModelsOnlyIfNotFound(desktopClient, models); err != nil {
+			if err := downloadModelsOnlyIfNotFound(desktopClient, models, draftModel); err != nil {
 				return err
 			}
 
@@ -158,13 +158,19 @@ func newMetadataCommand(upCmd, downCmd *cobra.Command) *cobra.Command {
 	return c
 }
 
-func downloadModelsOnlyIfNotFound(desktopClient *desktop.Client, models []string) error {
+// downloadModelsOnlyIfNotFound pulls the given models, and the speculative
+// decoding draft model if any, unless they're already in the local store.
+func downloadModelsOnlyIfNotFound(desktopClient *desktop.Client, models []string, draftModel string) error {
 	modelsDownloaded, err := desktopClient.List()
 	if err != nil {
 		_ = sendErrorf("Failed to get models list: %v", err)
 		return err
 	}
-	for _, model := range models {
+	toDownload := models
+	if draftModel != "" && !slices.Contains(models, draftModel) {
+		toDownload = append(slices.Clone(models), draftModel)
+	}
+	for _, model := range toDownload {
 		// Download the model if not already present in the local model store
 		if !slices.ContainsFunc(modelsDownloaded, func(m dmrm.Model) bool {
 			if model == m.ID {
@@ -180,7 +186,7 @@ func downloadModelsOnlyIfNotFound(desktopClient *desktop.Client, models []string
 			printer := desktop.NewSimplePrinter(func(s string) {
 				_ = sendInfo(s)
 			})
-			_, _, err = desktopClient.Pull(model, false, printer)
+			_, _, err = desktopClient.PullWithAuth(model, false, registryAuth(model), printer)
 			if err != nil {
 				_ = sendErrorf("Failed to pull model: %v", err)
 				return fmt.Errorf("Failed to pull model: %w\n", err)
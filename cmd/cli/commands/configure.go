@@ -15,6 +15,15 @@ func newConfigureCmd() *cobra.Command {
 	var draftModel string
 	var numTokens int
 	var minAcceptanceRate float64
+	var kvCacheType string
+	var ropeScaling string
+	var ropeScale float64
+	var yarnOrigCtx uint64
+	var embeddingPooling string
+	var embeddingNormalize bool
+	var keepAlive string
+	var replicaRouting string
+	var replicas int
 
 	c := &cobra.Command{
 		Use:    "configure [--context-size=<n>] [--speculative-draft-model=<model>] MODEL [-- <runtime-flags...>]",
@@ -52,6 +61,47 @@ func newConfigureCmd() *cobra.Command {
 					MinAcceptanceRate: minAcceptanceRate,
 				}
 			}
+			cacheType, err := inference.ParseKVCacheType(kvCacheType)
+			if err != nil {
+				return err
+			}
+			opts.KVCacheType = cacheType
+			if ropeScaling != "" {
+				opts.RopeScaling = &inference.RopeScalingConfig{
+					Type:                inference.RopeScalingType(ropeScaling),
+					Factor:              ropeScale,
+					OriginalContextSize: yarnOrigCtx,
+				}
+				if err := opts.RopeScaling.Validate(); err != nil {
+					return err
+				}
+			} else if ropeScale != 0 || yarnOrigCtx != 0 {
+				return fmt.Errorf("--rope-scale and --yarn-orig-ctx require --rope-scaling")
+			}
+			pooling, err := inference.ParseEmbeddingPooling(embeddingPooling)
+			if err != nil {
+				return err
+			}
+			if pooling != "" || cmd.Flags().Changed("embedding-normalize") {
+				opts.Embeddings = &inference.EmbeddingConfig{Pooling: pooling}
+				if cmd.Flags().Changed("embedding-normalize") {
+					opts.Embeddings.Normalize = &embeddingNormalize
+				}
+			}
+			if keepAlive != "" {
+				k, err := scheduling.ParseKeepAlive(keepAlive)
+				if err != nil {
+					return err
+				}
+				opts.KeepAlive = &k
+			}
+			if replicas > 0 || replicaRouting != "" {
+				routing, err := inference.ParseReplicaRouting(replicaRouting)
+				if err != nil {
+					return err
+				}
+				opts.Replicas = &inference.ReplicaConfig{Count: replicas, Routing: routing}
+			}
 			return desktopClient.ConfigureBackend(opts)
 		},
 		ValidArgsFunction: completion.ModelNames(getDesktopClient, -1),
@@ -61,5 +111,18 @@ func newConfigureCmd() *cobra.Command {
 	c.Flags().StringVar(&draftModel, "speculative-draft-model", "", "draft model for speculative decoding")
 	c.Flags().IntVar(&numTokens, "speculative-num-tokens", 0, "number of tokens to predict speculatively")
 	c.Flags().Float64Var(&minAcceptanceRate, "speculative-min-acceptance-rate", 0, "minimum acceptance rate for speculative decoding")
+	c.Flags().StringVar(&kvCacheType, "kv-cache-type", "", "KV cache type (f16, q8_0, or q4_0)")
+	c.Flags().StringVar(&embeddingPooling, "embedding-pooling", "", "embedding pooling method (mean, cls, or last)")
+	c.Flags().BoolVar(&embeddingNormalize, "embedding-normalize", true, "L2-normalize embeddings")
+	c.Flags().StringVar(&ropeScaling, "rope-scaling", "", "RoPE scaling method for extending the context (linear or yarn)")
+	c.Flags().Float64Var(&ropeScale, "rope-scale", 0, "RoPE context extension factor")
+	c.Flags().Uint64Var(&yarnOrigCtx, "yarn-orig-ctx", 0, "context size the model was trained with, for YaRN scaling")
+	c.Flags().IntSliceVar(&opts.Devices, "devices", nil, "indices of the GPUs to pin the model to")
+	c.Flags().IntVar(&opts.MaxConcurrentRequests, "max-concurrent-requests", 0, "maximum number of requests served concurrently for the model (0 for the default)")
+	c.Flags().IntVar(&replicas, "replicas", 0, "maximum number of runners serving the model, started as load requires")
+	c.Flags().StringVar(&replicaRouting, "replica-routing", "", "how requests are distributed across replicas (least-loaded or round-robin)")
+	c.Flags().StringVar(&opts.FirstTokenTimeout, "first-token-timeout", "", "maximum time until the model starts responding to a request (e.g. 30s)")
+	c.Flags().StringVar(&opts.GenerationTimeout, "generation-timeout", "", "maximum time until the model finishes responding to a request (e.g. 10m)")
+	c.Flags().StringVar(&keepAlive, "keep-alive", "", "how long the model stays loaded when idle (e.g. 10m, or -1 to keep it loaded)")
 	return c
 }
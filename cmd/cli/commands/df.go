@@ -46,6 +46,12 @@ func diskUsageTable(df desktop.DiskUsage) string {
 	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
 
 	table.Append([]string{"Models", units.CustomSize("%.2f%s", float64(df.ModelsDiskUsage), 1000.0, []string{"B", "kB", "MB", "GB", "TB", "PB", "EB", "ZB", "YB"})})
+	if df.DerivedModelsDiskUsage != 0 {
+		table.Append([]string{"Derived models", units.CustomSize("%.2f%s", float64(df.DerivedModelsDiskUsage), 1000.0, []string{"B", "kB", "MB", "GB", "TB", "PB", "EB", "ZB", "YB"})})
+	}
+	if df.ReclaimableDiskUsage != 0 {
+		table.Append([]string{"Reclaimable", units.CustomSize("%.2f%s", float64(df.ReclaimableDiskUsage), 1000.0, []string{"B", "kB", "MB", "GB", "TB", "PB", "EB", "ZB", "YB"})})
+	}
 	if df.DefaultBackendDiskUsage != 0 {
 		table.Append([]string{"Inference engine", units.CustomSize("%.2f%s", float64(df.DefaultBackendDiskUsage), 1000.0, []string{"B", "kB", "MB", "GB", "TB", "PB", "EB", "ZB", "YB"})})
 	}
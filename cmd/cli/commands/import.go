@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/docker/model-runner/cmd/cli/desktop"
+	dmrm "github.com/docker/model-runner/pkg/inference/models"
+
+	"github.com/spf13/cobra"
+)
+
+func newImportCmd() *cobra.Command {
+	var contextSize uint64
+	c := &cobra.Command{
+		Use:   "import PATH MODEL",
+		Short: "Import a GGUF file or a GGUF or Safetensors directory from the model runner's filesystem",
+		Long: "Import a GGUF file or a GGUF or Safetensors directory from the model runner's filesystem into its model store, " +
+			"without downloading or uploading it. PATH must be absolute, and is read by the model runner, so it must be within " +
+			"one of the directories that the model runner allows models to be imported from (MODEL_IMPORT_PATHS).\n" +
+			"When importing a sharded GGUF model, PATH should point to the first shard. A multimodal projector next to a GGUF file is imported along with it.\n" +
+			"The context size is read from the model's metadata unless --context-size is specified, and a chat template (*.jinja) next to the weights is imported with them.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := requireExactArgs(2, "import", "PATH MODEL")(cmd, args); err != nil {
+				return err
+			}
+			if !filepath.IsAbs(args[0]) {
+				return fmt.Errorf(
+					"Model path must be absolute.\n\n" +
+						"See 'docker model import --help' for more information",
+				)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := ensureStandaloneRunnerAvailable(cmd.Context(), asPrinter(cmd), false); err != nil {
+				return fmt.Errorf("unable to initialize standalone model runner: %w", err)
+			}
+			return importModel(cmd, desktopClient, dmrm.ModelImportRequest{
+				Path:        filepath.Clean(args[0]),
+				Tag:         args[1],
+				ContextSize: contextSize,
+			})
+		},
+	}
+	c.Flags().Uint64Var(&contextSize, "context-size", 0, "context size in tokens")
+	return c
+}
+
+func importModel(cmd *cobra.Command, desktopClient *desktop.Client, request dmrm.ModelImportRequest) error {
+	response, progressShown, err := desktopClient.Import(request, asPrinter(cmd))
+
+	// Add a newline before any output (success or error) if progress was shown.
+	if progressShown {
+		cmd.Println()
+	}
+
+	if err != nil {
+		return handleClientError(err, "Failed to import model")
+	}
+
+	cmd.Println(response)
+	return nil
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/docker/model-runner/cmd/cli/commands/completion"
+	"github.com/docker/model-runner/cmd/cli/desktop"
+	"github.com/spf13/cobra"
+)
+
+func newLoadCmd() *cobra.Command {
+	var input string
+	c := &cobra.Command{
+		Use:   "load [OPTIONS]",
+		Short: "Load a model from a TAR archive",
+		Long: "Load a model from a TAR archive created by 'docker model save', which is read from STDIN by default. " +
+			"The model is tagged with the tags recorded in the archive.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := ensureStandaloneRunnerAvailable(cmd.Context(), asPrinter(cmd), false); err != nil {
+				return fmt.Errorf("unable to initialize standalone model runner: %w", err)
+			}
+			return loadModel(cmd, desktopClient, input)
+		},
+		ValidArgsFunction: completion.NoComplete,
+	}
+	c.Flags().StringVarP(&input, "input", "i", "", "read from a file, instead of STDIN")
+	return c
+}
+
+func loadModel(cmd *cobra.Command, desktopClient *desktop.Client, input string) error {
+	var r io.Reader = cmd.InOrStdin()
+	if input != "" {
+		f, err := os.Open(input)
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	if err := desktopClient.LoadModel(cmd.Context(), r); err != nil {
+		return handleClientError(err, "Failed to load model")
+	}
+	cmd.Println("Model loaded successfully")
+	return nil
+}
@@ -28,18 +28,19 @@ func newPackagedCmd() *cobra.Command {
 	var opts packageOptions
 
 	c := &cobra.Command{
-		Use:   "package (--gguf <path> | --safetensors-dir <path> | --from <model>) [--license <path>...] [--context-size <tokens>] [--push] MODEL",
-		Short: "Package a GGUF file, Safetensors directory, or existing model into a Docker model OCI artifact.",
+		Use:   "package (--gguf <path> | --safetensors-dir <path> | --from <model> | --lora <path>) [--license <path>...] [--context-size <tokens>] [--push] MODEL",
+		Short: "Package a GGUF file, Safetensors directory, existing model, or LoRA adapter into a Docker model OCI artifact.",
 		Long: "Package a GGUF file, Safetensors directory, or existing model into a Docker model OCI artifact, with optional licenses. The package is sent to the model-runner, unless --push is specified.\n" +
 			"When packaging a sharded GGUF model, --gguf should point to the first shard. All shard files should be siblings and should include the index in the file name (e.g. model-00001-of-00015.gguf).\n" +
 			"When packaging a Safetensors model, --safetensors-dir should point to a directory containing .safetensors files and config files (*.json, merges.txt). All files will be auto-discovered and config files will be packaged into a tar archive.\n" +
-			"When packaging from an existing model using --from, you can modify properties like context size to create a variant of the original model.",
+			"When packaging from an existing model using --from, you can modify properties like context size to create a variant of the original model.\n" +
+			"When packaging a LoRA adapter in GGUF format using --lora, the adapter is distributed on its own and applied to the base model it was trained for by requesting the model BASE+ADAPTER, such as ai/llama3.2+myorg/llama3.2-pirate.",
 		Args: func(cmd *cobra.Command, args []string) error {
 			if err := requireExactArgs(1, "package", "MODEL")(cmd, args); err != nil {
 				return err
 			}
 
-			// Validate that exactly one of --gguf, --safetensors-dir, or --from is provided (mutually exclusive)
+			// Validate that exactly one of --gguf, --safetensors-dir, --from, or --lora is provided (mutually exclusive)
 			sourcesProvided := 0
 			if opts.ggufPath != "" {
 				sourcesProvided++
@@ -50,16 +51,19 @@ func newPackagedCmd() *cobra.Command {
 			if opts.fromModel != "" {
 				sourcesProvided++
 			}
+			if opts.loraPath != "" {
+				sourcesProvided++
+			}
 
 			if sourcesProvided == 0 {
 				return fmt.Errorf(
-					"One of --gguf, --safetensors-dir, or --from is required.\n\n" +
+					"One of --gguf, --safetensors-dir, --from, or --lora is required.\n\n" +
 						"See 'docker model package --help' for more information",
 				)
 			}
 			if sourcesProvided > 1 {
 				return fmt.Errorf(
-					"Cannot specify more than one of --gguf, --safetensors-dir, or --from. Please use only one source.\n\n" +
+					"Cannot specify more than one of --gguf, --safetensors-dir, --from, or --lora. Please use only one source.\n\n" +
 						"See 'docker model package --help' for more information",
 				)
 			}
@@ -75,6 +79,24 @@ func newPackagedCmd() *cobra.Command {
 				opts.ggufPath = filepath.Clean(opts.ggufPath)
 			}
 
+			// Validate LoRA adapter path if provided. Adapters only have
+			// licenses besides the adapter itself.
+			if opts.loraPath != "" {
+				if !filepath.IsAbs(opts.loraPath) {
+					return fmt.Errorf(
+						"LoRA adapter path must be absolute.\n\n" +
+							"See 'docker model package --help' for more information",
+					)
+				}
+				opts.loraPath = filepath.Clean(opts.loraPath)
+				if opts.chatTemplatePath != "" || opts.contextSize > 0 || len(opts.dirTarPaths) > 0 {
+					return fmt.Errorf(
+						"--lora can't be combined with --chat-template, --context-size, or --dir-tar.\n\n" +
+							"See 'docker model package --help' for more information",
+					)
+				}
+			}
+
 			// Validate safetensors directory if provided
 			if opts.safetensorsDir != "" {
 				if !filepath.IsAbs(opts.safetensorsDir) {
@@ -143,6 +165,7 @@ func newPackagedCmd() *cobra.Command {
 	c.Flags().StringVar(&opts.ggufPath, "gguf", "", "absolute path to gguf file")
 	c.Flags().StringVar(&opts.safetensorsDir, "safetensors-dir", "", "absolute path to directory containing safetensors files and config")
 	c.Flags().StringVar(&opts.fromModel, "from", "", "reference to an existing model to repackage")
+	c.Flags().StringVar(&opts.loraPath, "lora", "", "absolute path to a LoRA adapter GGUF file")
 	c.Flags().StringVar(&opts.chatTemplatePath, "chat-template", "", "absolute path to chat template file (must be Jinja format)")
 	c.Flags().StringArrayVarP(&opts.licensePaths, "license", "l", nil, "absolute path to a license file")
 	c.Flags().StringArrayVar(&opts.dirTarPaths, "dir-tar", nil, "relative path to directory to package as tar (can be specified multiple times)")
@@ -155,6 +178,7 @@ type packageOptions struct {
 	chatTemplatePath string
 	contextSize      uint64
 	ggufPath         string
+	loraPath         string
 	safetensorsDir   string
 	fromModel        string
 	licensePaths     []string
@@ -170,7 +194,7 @@ type builderInitResult struct {
 	cleanupFunc func()               // Optional cleanup function for temporary files
 }
 
-// initializeBuilder creates a package builder from GGUF, Safetensors, or existing model
+// initializeBuilder creates a package builder from GGUF, Safetensors, existing model, or LoRA adapter
 func initializeBuilder(cmd *cobra.Command, opts packageOptions) (*builderInitResult, error) {
 	result := &builderInitResult{}
 
@@ -212,6 +236,13 @@ func initializeBuilder(cmd *cobra.Command, opts packageOptions) (*builderInitRes
 		if err != nil {
 			return nil, fmt.Errorf("create builder from model: %w", err)
 		}
+	} else if opts.loraPath != "" {
+		cmd.PrintErrf("Adding LoRA adapter from %q\n", opts.loraPath)
+		pkg, err := builder.FromAdapter(opts.loraPath)
+		if err != nil {
+			return nil, fmt.Errorf("add lora adapter: %w", err)
+		}
+		result.builder = pkg
 	} else if opts.ggufPath != "" {
 		cmd.PrintErrf("Adding GGUF file from %q\n", opts.ggufPath)
 		pkg, err := builder.FromGGUF(opts.ggufPath)
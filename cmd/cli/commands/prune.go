@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"github.com/docker/go-units"
+	"github.com/docker/model-runner/cmd/cli/commands/completion"
+	"github.com/spf13/cobra"
+)
+
+func newPruneCmd() *cobra.Command {
+	var dryRun bool
+
+	c := &cobra.Command{
+		Use:   "prune [OPTIONS]",
+		Short: "Remove unreferenced blobs from the model store",
+		Long: "Remove the blobs in the model store that no model references, such as those left behind by interrupted deletes. " +
+			"Blobs of models that are being pulled are kept until the pull finishes.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			garbage, err := desktopClient.Prune(dryRun)
+			if err != nil {
+				return handleClientError(err, "Failed to prune model store")
+			}
+			if len(garbage.Blobs) > 0 {
+				if dryRun {
+					cmd.Println("Would remove blobs:")
+				} else {
+					cmd.Println("Removed blobs:")
+				}
+				for _, blob := range garbage.Blobs {
+					cmd.Println(blob)
+				}
+				cmd.Println()
+			}
+			size := units.CustomSize("%.2f%s", float64(garbage.Size), 1000.0, []string{"B", "kB", "MB", "GB", "TB", "PB", "EB", "ZB", "YB"})
+			if dryRun {
+				cmd.Printf("Total reclaimable space: %s\n", size)
+			} else {
+				cmd.Printf("Total reclaimed space: %s\n", size)
+			}
+			return nil
+		},
+		ValidArgsFunction: completion.NoComplete,
+	}
+
+	c.Flags().BoolVar(&dryRun, "dry-run", false, "Only report the blobs that would be removed")
+	return c
+}
@@ -32,7 +32,7 @@ func newPullCmd() *cobra.Command {
 
 func pullModel(cmd *cobra.Command, desktopClient *desktop.Client, model string, ignoreRuntimeMemoryCheck bool) error {
 	printer := asPrinter(cmd)
-	response, _, err := desktopClient.Pull(model, ignoreRuntimeMemoryCheck, printer)
+	response, _, err := desktopClient.PullWithAuth(model, ignoreRuntimeMemoryCheck, registryAuth(model), printer)
 
 	if err != nil {
 		return handleClientError(err, "Failed to pull model")
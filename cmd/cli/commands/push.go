@@ -5,29 +5,45 @@ import (
 
 	"github.com/docker/model-runner/cmd/cli/commands/completion"
 	"github.com/docker/model-runner/cmd/cli/desktop"
+	dmrm "github.com/docker/model-runner/pkg/inference/models"
 
 	"github.com/spf13/cobra"
 )
 
 func newPushCmd() *cobra.Command {
 	c := &cobra.Command{
-		Use:   "push MODEL",
-		Short: "Push a model to Docker Hub",
-		Args:  requireExactArgs(1, "push", "MODEL"),
+		Use:   "push MODEL [DESTINATION]",
+		Short: "Push a model to Docker Hub or another OCI registry",
+		Long: "Push a model to Docker Hub or another OCI registry. The model is pushed to its own reference unless " +
+			"a destination reference is specified, which allows models to be pushed by ID or under another name. " +
+			"The credentials for the registry are read from the Docker configuration, including credential helpers.",
+		Args: requireRangeArgs(1, 2, "push", "MODEL [DESTINATION]"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if _, err := ensureStandaloneRunnerAvailable(cmd.Context(), asPrinter(cmd), false); err != nil {
 				return fmt.Errorf("unable to initialize standalone model runner: %w", err)
 			}
-			return pushModel(cmd, desktopClient, args[0])
+			var destination string
+			if len(args) > 1 {
+				destination = args[1]
+			}
+			return pushModel(cmd, desktopClient, args[0], destination)
 		},
-		ValidArgsFunction: completion.NoComplete,
+		ValidArgsFunction: completion.ModelNames(getDesktopClient, 1),
 	}
 	return c
 }
 
-func pushModel(cmd *cobra.Command, desktopClient *desktop.Client, model string) error {
+func pushModel(cmd *cobra.Command, desktopClient *desktop.Client, model, destination string) error {
 	printer := asPrinter(cmd)
-	response, progressShown, err := desktopClient.Push(model, printer)
+	target := model
+	if destination != "" {
+		target = destination
+	}
+	request := dmrm.ModelPushRequest{
+		Destination: destination,
+		Auth:        registryAuth(target),
+	}
+	response, progressShown, err := desktopClient.PushTo(model, request, printer)
 
 	// Add a newline before any output (success or error) if progress was shown.
 	if progressShown {
@@ -91,6 +91,9 @@ func NewRootCmd(cli *command.DockerCli) *cobra.Command {
 		newPullCmd(),
 		newPushCmd(),
 		newPackagedCmd(),
+		newImportCmd(),
+		newSaveCmd(),
+		newLoadCmd(),
 		newListCmd(),
 		newLogsCmd(),
 		newRunCmd(),
@@ -98,6 +101,7 @@ func NewRootCmd(cli *command.DockerCli) *cobra.Command {
 		newInspectCmd(),
 		newComposeCmd(),
 		newTagCmd(),
+		newUntagCmd(),
 		newInstallRunner(),
 		newUninstallRunner(),
 		newStartRunner(),
@@ -110,6 +114,8 @@ func NewRootCmd(cli *command.DockerCli) *cobra.Command {
 		newUnloadCmd(),
 		newRequestsCmd(),
 		newPurgeCmd(),
+		newPruneCmd(),
+		newVerifyCmd(),
 	)
 	return rootCmd
 }
@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/model-runner/cmd/cli/commands/completion"
+	"github.com/docker/model-runner/cmd/cli/desktop"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func newSaveCmd() *cobra.Command {
+	var output string
+	c := &cobra.Command{
+		Use:   "save [OPTIONS] MODEL",
+		Short: "Save a model to a TAR archive",
+		Long: "Save a model, with all of its blobs and its manifest, to a TAR archive, which is written to STDOUT by default. " +
+			"The archive records the model's tags, and can be loaded with 'docker model load', such as on a machine without network access.",
+		Args: requireExactArgs(1, "save", "MODEL"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output == "" && term.IsTerminal(int(os.Stdout.Fd())) {
+				return fmt.Errorf("refusing to write the archive to a terminal, use --output to write it to a file or redirect STDOUT")
+			}
+			if _, err := ensureStandaloneRunnerAvailable(cmd.Context(), asPrinter(cmd), false); err != nil {
+				return fmt.Errorf("unable to initialize standalone model runner: %w", err)
+			}
+			return saveModel(cmd, desktopClient, args[0], output)
+		},
+		ValidArgsFunction: completion.ModelNames(getDesktopClient, 1),
+	}
+	c.Flags().StringVarP(&output, "output", "o", "", "write to a file, instead of STDOUT")
+	return c
+}
+
+func saveModel(cmd *cobra.Command, desktopClient *desktop.Client, model, output string) error {
+	if output == "" {
+		if err := desktopClient.ExportModel(cmd.Context(), model, cmd.OutOrStdout()); err != nil {
+			return handleClientError(err, "Failed to save model")
+		}
+		return nil
+	}
+
+	// The archive is written to a temporary file first, so that an existing
+	// file isn't replaced by an incomplete archive.
+	f, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := desktopClient.ExportModel(cmd.Context(), model, f); err != nil {
+		return handleClientError(err, "Failed to save model")
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := os.Rename(f.Name(), output); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	cmd.Printf("Model %q saved to %s\n", model, output)
+	return nil
+}
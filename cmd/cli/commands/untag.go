@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/docker/model-runner/cmd/cli/commands/completion"
+	"github.com/spf13/cobra"
+)
+
+func newUntagCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "untag TAG [TAG...]",
+		Short: "Remove tags from models",
+		Long: "Remove tags from the models that they point to, without removing the models. " +
+			"The last tag of a model can't be removed: remove the model instead.",
+		Args: requireMinArgs(1, "untag", "TAG [TAG...]"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := ensureStandaloneRunnerAvailable(cmd.Context(), asPrinter(cmd), false); err != nil {
+				return fmt.Errorf("unable to initialize standalone model runner: %w", err)
+			}
+			for _, tag := range args {
+				removed, err := desktopClient.Untag(tag)
+				if err != nil {
+					return handleClientError(err, "Failed to untag model")
+				}
+				cmd.Printf("Untagged: %s\n", removed.Tag)
+			}
+			return nil
+		},
+		ValidArgsFunction: completion.ModelNames(getDesktopClient, -1),
+	}
+	return c
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/docker/model-runner/cmd/cli/pkg/standalone"
 	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/name"
 	"github.com/docker/model-runner/pkg/inference/backends/vllm"
+	dmrm "github.com/docker/model-runner/pkg/inference/models"
 	"github.com/moby/term"
 	"github.com/spf13/cobra"
 )
@@ -21,6 +22,10 @@ const (
 	defaultTag = "latest"
 )
 
+// dockerHubAuthKey is the key under which Docker Hub credentials are stored in
+// the Docker configuration.
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
 const (
 	enableViaCLI = "Enable Docker Model Runner via the CLI → docker desktop enable model-runner"
 	enableViaGUI = "Enable Docker Model Runner via the GUI → Go to Settings->AI->Enable Docker Model Runner"
@@ -182,6 +187,21 @@ func requireMinArgs(n int, cmdName string, usageArgs string) cobra.PositionalArg
 	}
 }
 
+// requireRangeArgs returns a cobra.PositionalArgs validator that ensures between min and max arguments are provided
+func requireRangeArgs(min, max int, cmdName string, usageArgs string) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) < min || len(args) > max {
+			return fmt.Errorf(
+				"'docker model %s' requires between %d and %d arguments.\n\n"+
+					"Usage:  docker model %s %s\n\n"+
+					"See 'docker model %s --help' for more information",
+				cmdName, min, max, cmdName, usageArgs, cmdName,
+			)
+		}
+		return nil
+	}
+}
+
 // runnerOptions holds common runner configuration options
 type runnerFlagOptions struct {
 	Port       *uint16
@@ -214,3 +234,34 @@ func addRunnerFlags(cmd *cobra.Command, opts runnerFlagOptions) {
 		cmd.Flags().BoolVar(opts.Debug, "debug", false, "Enable debug logging")
 	}
 }
+
+// registryAuth returns the credentials stored in the Docker configuration, or
+// by its credential helpers, for the registry of a reference. It returns nil if
+// there are none, in which case the model runner uses its own.
+func registryAuth(reference string) *dmrm.RegistryAuth {
+	if dockerCLI == nil {
+		return nil
+	}
+	ref, err := name.ParseReference(reference, name.WithDefaultRegistry(getDefaultRegistry()))
+	if err != nil {
+		return nil
+	}
+	host := ref.Context().RegistryStr()
+	if host == name.DefaultRegistry {
+		host = dockerHubAuthKey
+	}
+	authConfig, err := dockerCLI.ConfigFile().GetAuthConfig(host)
+	if err != nil {
+		return nil
+	}
+	auth := &dmrm.RegistryAuth{
+		Username:      authConfig.Username,
+		Password:      authConfig.Password,
+		IdentityToken: authConfig.IdentityToken,
+		RegistryToken: authConfig.RegistryToken,
+	}
+	if *auth == (dmrm.RegistryAuth{}) {
+		return nil
+	}
+	return auth
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/docker/model-runner/cmd/cli/commands/completion"
+	"github.com/docker/model-runner/pkg/distribution/distribution"
+	"github.com/spf13/cobra"
+)
+
+func newVerifyCmd() *cobra.Command {
+	var repair bool
+
+	c := &cobra.Command{
+		Use:   "verify [OPTIONS]",
+		Short: "Check the integrity of the model store",
+		Long: "Re-hash the manifests and blobs of all the models in the model store against their digests, " +
+			"and report the models whose files are missing, truncated, or corrupt, such as after disk failures or interrupted writes. " +
+			"With --repair, damaged models are pulled again from the registry, which only fetches their damaged layers.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			verification, err := desktopClient.VerifyStore(repair)
+			if err != nil {
+				return handleClientError(err, "Failed to verify model store")
+			}
+			size := units.CustomSize("%.2f%s", float64(verification.Size), 1000.0, []string{"B", "kB", "MB", "GB", "TB", "PB", "EB", "ZB", "YB"})
+			cmd.Printf("Verified %d model(s) and %d blob(s) (%s)\n", verification.Models, verification.Blobs, size)
+			if len(verification.Damaged) == 0 {
+				cmd.Println("No damaged models found")
+				return nil
+			}
+
+			unrepaired := 0
+			for _, model := range verification.Damaged {
+				cmd.Println()
+				cmd.Println(formatDamagedModel(model))
+				for _, blob := range model.Blobs {
+					cmd.Printf("  %s: %s\n", blob.Digest, blob.Problem)
+				}
+				switch {
+				case model.Repaired:
+					cmd.Println("  Repaired")
+				case model.Error != "":
+					cmd.Printf("  Failed to repair: %s\n", model.Error)
+					unrepaired++
+				default:
+					unrepaired++
+				}
+			}
+			if unrepaired == 0 {
+				return nil
+			}
+			if !repair {
+				return fmt.Errorf("found %d damaged model(s), run with --repair to pull them again", unrepaired)
+			}
+			return fmt.Errorf("failed to repair %d damaged model(s)", unrepaired)
+		},
+		ValidArgsFunction: completion.NoComplete,
+	}
+
+	c.Flags().BoolVar(&repair, "repair", false, "Pull the damaged layers of damaged models again")
+	return c
+}
+
+// formatDamagedModel returns the heading of a damaged model in the output of
+// the verify command.
+func formatDamagedModel(model distribution.DamagedModel) string {
+	if len(model.Tags) == 0 {
+		return fmt.Sprintf("Damaged model %s", model.ID)
+	}
+	return fmt.Sprintf("Damaged model %s (%s)", model.ID, strings.Join(model.Tags, ", "))
+}
@@ -13,6 +13,7 @@ type Layer struct {
 	ID      string // Layer ID
 	Size    uint64 // Layer size
 	Current uint64 // Current bytes transferred
+	Cached  bool   // Layer is already in the store, so it isn't transferred
 }
 
 type OpenAIChatMessage struct {
@@ -106,12 +106,19 @@ func (c *Client) Status() Status {
 }
 
 func (c *Client) Pull(model string, ignoreRuntimeMemoryCheck bool, printer standalone.StatusPrinter) (string, bool, error) {
+	return c.PullWithAuth(model, ignoreRuntimeMemoryCheck, nil, printer)
+}
+
+// PullWithAuth pulls a model with the specified registry credentials, if any.
+// Hugging Face models are authenticated with HF_TOKEN instead.
+func (c *Client) PullWithAuth(model string, ignoreRuntimeMemoryCheck bool, auth *dmrm.RegistryAuth, printer standalone.StatusPrinter) (string, bool, error) {
 	model = normalizeHuggingFaceModelName(model)
 
 	// Check if this is a Hugging Face model and if HF_TOKEN is set
 	var hfToken string
 	if strings.HasPrefix(strings.ToLower(model), "hf.co/") {
 		hfToken = os.Getenv("HF_TOKEN")
+		auth = nil
 	}
 
 	return c.withRetries("download", 3, printer, func(attempt int) (string, bool, error, bool) {
@@ -119,6 +126,7 @@ func (c *Client) Pull(model string, ignoreRuntimeMemoryCheck bool, printer stand
 			From:                     model,
 			IgnoreRuntimeMemoryCheck: ignoreRuntimeMemoryCheck,
 			BearerToken:              hfToken,
+			Auth:                     auth,
 		})
 		if err != nil {
 			// Marshaling errors are not retryable
@@ -234,14 +242,26 @@ func (c *Client) withRetries(
 }
 
 func (c *Client) Push(model string, printer standalone.StatusPrinter) (string, bool, error) {
+	return c.PushTo(model, dmrm.ModelPushRequest{}, printer)
+}
+
+// PushTo pushes a model to the destination and with the credentials specified
+// by the request, if any.
+func (c *Client) PushTo(model string, request dmrm.ModelPushRequest, printer standalone.StatusPrinter) (string, bool, error) {
 	model = normalizeHuggingFaceModelName(model)
 
 	return c.withRetries("push", 3, printer, func(attempt int) (string, bool, error, bool) {
+		jsonData, err := json.Marshal(request)
+		if err != nil {
+			// Marshaling errors are not retryable
+			return "", false, fmt.Errorf("error marshaling request: %w", err), false
+		}
+
 		pushPath := inference.ModelsPrefix + "/" + model + "/push"
 		resp, err := c.doRequest(
 			http.MethodPost,
 			pushPath,
-			nil, // Assuming no body is needed for the push request
+			bytes.NewReader(jsonData),
 		)
 		if err != nil {
 			// Only retry on network errors, not on client errors
@@ -273,6 +293,29 @@ func (c *Client) Push(model string, printer standalone.StatusPrinter) (string, b
 	})
 }
 
+// Import imports a model from the model runner's filesystem, as specified by
+// the request. Since the files are read by the model runner, the path must be
+// accessible to it, rather than to the client.
+func (c *Client) Import(request dmrm.ModelImportRequest, printer standalone.StatusPrinter) (string, bool, error) {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return "", false, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	importPath := inference.ModelsPrefix + "/import"
+	resp, err := c.doRequest(http.MethodPost, importPath, bytes.NewReader(jsonData))
+	if err != nil {
+		return "", false, c.handleQueryError(err, importPath)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", false, fmt.Errorf("importing %s failed with status %s: %s", request.Path, resp.Status, string(body))
+	}
+	return DisplayProgress(resp.Body, printer)
+}
+
 func (c *Client) List() ([]dmrm.Model, error) {
 	modelsRoute := inference.ModelsPrefix
 	body, err := c.listRaw(modelsRoute, "")
@@ -708,6 +751,8 @@ func (c *Client) PS() ([]BackendStatus, error) {
 type DiskUsage struct {
 	ModelsDiskUsage         int64 `json:"models_disk_usage"`
 	DefaultBackendDiskUsage int64 `json:"default_backend_disk_usage"`
+	DerivedModelsDiskUsage  int64 `json:"derived_models_disk_usage"`
+	ReclaimableDiskUsage    int64 `json:"reclaimable_disk_usage"`
 }
 
 func (c *Client) DF() (DiskUsage, error) {
@@ -871,6 +916,56 @@ func (c *Client) Purge() error {
 	return nil
 }
 
+// Prune removes the blobs in the model store that no model references, or
+// only reports them if dryRun is set.
+func (c *Client) Prune(dryRun bool) (distribution.Garbage, error) {
+	prunePath := inference.ModelsPrefix + "/prune"
+	if dryRun {
+		prunePath += "?dry-run=true"
+	}
+	resp, err := c.doRequest(http.MethodPost, prunePath, nil)
+	if err != nil {
+		return distribution.Garbage{}, c.handleQueryError(err, prunePath)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return distribution.Garbage{}, fmt.Errorf("pruning failed with status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var garbage distribution.Garbage
+	if err := json.NewDecoder(resp.Body).Decode(&garbage); err != nil {
+		return distribution.Garbage{}, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	return garbage, nil
+}
+
+// VerifyStore re-hashes the content of the model store and reports the damaged
+// models, pulling their damaged blobs again if repair is set.
+func (c *Client) VerifyStore(repair bool) (distribution.Verification, error) {
+	verifyPath := inference.ModelsPrefix + "/verify"
+	if repair {
+		verifyPath += "?repair=true"
+	}
+	resp, err := c.doRequest(http.MethodPost, verifyPath, nil)
+	if err != nil {
+		return distribution.Verification{}, c.handleQueryError(err, verifyPath)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return distribution.Verification{}, fmt.Errorf("verifying failed with status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var verification distribution.Verification
+	if err := json.NewDecoder(resp.Body).Decode(&verification); err != nil {
+		return distribution.Verification{}, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	return verification, nil
+}
+
 // doRequest is a helper function that performs HTTP requests and handles 503 responses
 func (c *Client) doRequest(method, path string, body io.Reader) (*http.Response, error) {
 	return c.doRequestWithAuth(method, path, body)
@@ -943,6 +1038,50 @@ func (c *Client) Tag(source, targetRepo, targetTag string) error {
 	return nil
 }
 
+// Untag removes a tag from the model that it points to, and returns the ID of
+// that model. The last tag of a model can't be removed.
+func (c *Client) Untag(tag string) (dmrm.ModelTag, error) {
+	tag = normalizeHuggingFaceModelName(tag)
+	untagPath := inference.ModelsPrefix + "/tags/" + tag
+	resp, err := c.doRequest(http.MethodDelete, untagPath, nil)
+	if err != nil {
+		return dmrm.ModelTag{}, c.handleQueryError(err, untagPath)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return dmrm.ModelTag{}, fmt.Errorf("untagging failed with status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var removed dmrm.ModelTag
+	if err := json.NewDecoder(resp.Body).Decode(&removed); err != nil {
+		return dmrm.ModelTag{}, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	return removed, nil
+}
+
+// ExportModel writes a model, with all of its blobs and its manifest, to w as
+// an archive that LoadModel loads, such as on another machine.
+func (c *Client) ExportModel(ctx context.Context, model string, w io.Writer) error {
+	model = normalizeHuggingFaceModelName(model)
+	exportPath := inference.ModelsPrefix + "/export?model=" + url.QueryEscape(model)
+	resp, err := c.doRequestWithAuthContext(ctx, http.MethodGet, exportPath, nil)
+	if err != nil {
+		return c.handleQueryError(err, exportPath)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("exporting %s failed with status %s: %s", model, resp.Status, string(body))
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to write model archive: %w", err)
+	}
+	return nil
+}
+
 func (c *Client) LoadModel(ctx context.Context, r io.Reader) error {
 	loadPath := fmt.Sprintf("%s/load", inference.ModelsPrefix)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.modelRunner.URL(loadPath), r)
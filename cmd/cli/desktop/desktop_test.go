@@ -43,6 +43,36 @@ func TestPullHuggingFaceModel(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestPullWithAuth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	auth := &models.RegistryAuth{Username: "user", Password: "secret"}
+	mockClient := mockdesktop.NewMockDockerHttpClient(ctrl)
+	mockContext := NewContextForMock(mockClient)
+	client := New(mockContext)
+
+	// Registry credentials are forwarded, except for Hugging Face models
+	var forwarded []*models.RegistryAuth
+	mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+		var reqBody models.ModelCreateRequest
+		err := json.NewDecoder(req.Body).Decode(&reqBody)
+		require.NoError(t, err)
+		forwarded = append(forwarded, reqBody.Auth)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"type":"success","message":"Model pulled successfully"}`)),
+		}, nil
+	}).Times(2)
+
+	printer := NewSimplePrinter(func(s string) {})
+	_, _, err := client.PullWithAuth("registry.example.com/private/model", false, auth, printer)
+	require.NoError(t, err)
+	_, _, err = client.PullWithAuth("hf.co/org/repo", false, auth, printer)
+	require.NoError(t, err)
+	assert.Equal(t, []*models.RegistryAuth{auth, nil}, forwarded)
+}
+
 func TestChatHuggingFaceModel(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -160,7 +160,9 @@ func writeDockerProgress(w io.Writer, msg *ProgressMessage, layerStatus map[stri
 	var status string
 	var progressDetail *jsonmessage.JSONProgress
 
-	if msg.Layer.Current == 0 {
+	if msg.Layer.Cached {
+		status = "Already exists"
+	} else if msg.Layer.Current == 0 {
 		status = "Waiting"
 	} else if msg.Layer.Current < msg.Layer.Size {
 		status = "Downloading"
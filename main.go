@@ -2,29 +2,63 @@ package main
 
 import (
 	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"maps"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/docker/model-runner/pkg/accesslog"
+	"github.com/docker/model-runner/pkg/admin"
+	"github.com/docker/model-runner/pkg/apikeys"
+	"github.com/docker/model-runner/pkg/apps"
+	"github.com/docker/model-runner/pkg/batches"
+	"github.com/docker/model-runner/pkg/distribution/distribution"
+	"github.com/docker/model-runner/pkg/distribution/registry"
 	"github.com/docker/model-runner/pkg/gpuinfo"
+	"github.com/docker/model-runner/pkg/gputelemetry"
+	"github.com/docker/model-runner/pkg/grpcapi"
 	"github.com/docker/model-runner/pkg/inference"
 	"github.com/docker/model-runner/pkg/inference/backends/llamacpp"
 	"github.com/docker/model-runner/pkg/inference/backends/mlx"
+	"github.com/docker/model-runner/pkg/inference/backends/onnx"
+	"github.com/docker/model-runner/pkg/inference/backends/plugin"
+	"github.com/docker/model-runner/pkg/inference/backends/remote"
+	"github.com/docker/model-runner/pkg/inference/backends/sdcpp"
+	"github.com/docker/model-runner/pkg/inference/backends/sglang"
+	"github.com/docker/model-runner/pkg/inference/backends/tei"
+	"github.com/docker/model-runner/pkg/inference/backends/trtllm"
 	"github.com/docker/model-runner/pkg/inference/backends/vllm"
+	"github.com/docker/model-runner/pkg/inference/backends/whisper"
 	"github.com/docker/model-runner/pkg/inference/config"
 	"github.com/docker/model-runner/pkg/inference/memory"
 	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/inference/modelslock"
+	"github.com/docker/model-runner/pkg/inference/offline"
+	"github.com/docker/model-runner/pkg/inference/runnerconfig"
 	"github.com/docker/model-runner/pkg/inference/scheduling"
+	"github.com/docker/model-runner/pkg/logging"
 	"github.com/docker/model-runner/pkg/metrics"
 	"github.com/docker/model-runner/pkg/middleware"
+	"github.com/docker/model-runner/pkg/moderations"
 	"github.com/docker/model-runner/pkg/ollama"
+	"github.com/docker/model-runner/pkg/responses"
 	"github.com/docker/model-runner/pkg/routing"
+	"github.com/docker/model-runner/pkg/sessions"
+	"github.com/docker/model-runner/pkg/tracing"
+	"github.com/docker/model-runner/pkg/usage"
+	"github.com/docker/model-runner/pkg/wschat"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 )
 
 var log = logrus.New()
@@ -60,6 +94,18 @@ func main() {
 		llamacpp.SetDesiredServerVersion(desiredServerVersion)
 	}
 
+	// Backend runtimes and models are preferably installed from the offline
+	// bundle, if any, for use in air-gapped environments.
+	var offlineBundle *offline.Bundle
+	if dir := os.Getenv("MODEL_RUNNER_OFFLINE_BUNDLE"); dir != "" {
+		offlineBundle, err = offline.Open(dir)
+		if err != nil {
+			log.Fatalf("unable to open MODEL_RUNNER_OFFLINE_BUNDLE: %v", err)
+		}
+		log.Infof("Using offline bundle: %s", dir)
+		llamacpp.SetOfflineBundle(offlineBundle)
+	}
+
 	llamaServerPath := os.Getenv("LLAMA_SERVER_PATH")
 	if llamaServerPath == "" {
 		llamaServerPath = "/Applications/Docker.app/Contents/Resources/model-runner/bin"
@@ -67,10 +113,12 @@ func main() {
 
 	gpuInfo := gpuinfo.New(llamaServerPath)
 
-	sysMemInfo, err := memory.NewSystemMemoryInfo(log, gpuInfo)
+	systemMemInfo, err := memory.NewSystemMemoryInfo(log, gpuInfo)
 	if err != nil {
 		log.Fatalf("unable to initialize system memory info: %v", err)
 	}
+	memoryBudget := createMemoryBudgetFromEnv()
+	sysMemInfo := memory.WithBudget(systemMemInfo, memoryBudget)
 
 	memEstimator := memory.NewEstimator(sysMemInfo)
 
@@ -84,10 +132,125 @@ func main() {
 	}
 	baseTransport.Proxy = http.ProxyFromEnvironment
 
+	digestPinning := distribution.DigestPinningWarn
+	if s := os.Getenv("MODEL_DIGEST_PINNING"); s != "" {
+		digestPinning, err = distribution.ParseDigestPinningMode(s)
+		if err != nil {
+			log.Fatalf("unable to parse MODEL_DIGEST_PINNING: %v", err)
+		}
+	}
+
+	var minFreeSpace *uint64
+	if s := os.Getenv("MODEL_STORE_MIN_FREE_SPACE_MB"); s != "" {
+		mb, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			log.Fatalf("unable to parse MODEL_STORE_MIN_FREE_SPACE_MB: %v", err)
+		}
+		bytes := mb * 1024 * 1024
+		minFreeSpace = &bytes
+	}
+
+	var storeQuota uint64
+	if s := os.Getenv("MODEL_STORE_QUOTA_MB"); s != "" {
+		mb, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			log.Fatalf("unable to parse MODEL_STORE_QUOTA_MB: %v", err)
+		}
+		storeQuota = mb * 1024 * 1024
+	}
+
+	var downloadChunks int
+	if s := os.Getenv("MODEL_STORE_DOWNLOAD_CHUNKS"); s != "" {
+		downloadChunks, err = strconv.Atoi(s)
+		if err != nil || downloadChunks < 1 {
+			log.Fatalf("invalid MODEL_STORE_DOWNLOAD_CHUNKS: %q", s)
+		}
+	}
+
+	signatureVerification := distribution.SignatureVerificationOff
+	if s := os.Getenv("MODEL_SIGNATURE_VERIFICATION"); s != "" {
+		signatureVerification, err = distribution.ParseSignatureVerificationMode(s)
+		if err != nil {
+			log.Fatalf("unable to parse MODEL_SIGNATURE_VERIFICATION: %v", err)
+		}
+	}
+	var signatureKeys []crypto.PublicKey
+	for _, path := range filepath.SplitList(os.Getenv("MODEL_SIGNATURE_PUBLIC_KEYS")) {
+		keys, err := distribution.LoadPublicKeys(path)
+		if err != nil {
+			log.Fatalf("unable to load MODEL_SIGNATURE_PUBLIC_KEYS: %v", err)
+		}
+		signatureKeys = append(signatureKeys, keys...)
+	}
+	if signatureVerification != distribution.SignatureVerificationOff && len(signatureKeys) == 0 {
+		log.Fatalf("MODEL_SIGNATURE_VERIFICATION requires MODEL_SIGNATURE_PUBLIC_KEYS to be set")
+	}
+
+	// Downloads share a single transport, so that the bandwidth limit applies
+	// to all of them in aggregate.
+	var storeTransport http.RoundTripper = baseTransport
+	if s := os.Getenv("MODEL_STORE_DOWNLOAD_LIMIT_MB"); s != "" {
+		mb, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || mb == 0 {
+			log.Fatalf("invalid MODEL_STORE_DOWNLOAD_LIMIT_MB: %q", s)
+		}
+		storeTransport = distribution.NewRateLimitedTransport(baseTransport, mb*1024*1024)
+	}
+
+	var registryMirrors registry.Mirrors
+	if s := os.Getenv("MODEL_REGISTRY_MIRRORS"); s != "" {
+		registryMirrors, err = registry.ParseMirrors(s)
+		if err != nil {
+			log.Fatalf("unable to parse MODEL_REGISTRY_MIRRORS: %v", err)
+		}
+	}
+
+	// The model runners of a cluster authenticate their requests to each
+	// other with a shared token, which is accepted instead of an API key.
+	clusterToken := os.Getenv("MODEL_RUNNER_CLUSTER_TOKEN")
+	var blobPeers []string
+	if s := os.Getenv("MODEL_RUNNER_BLOB_PEERS"); s != "" {
+		blobPeers, err = scheduling.ParsePeers(s)
+		if err != nil {
+			log.Fatalf("unable to parse MODEL_RUNNER_BLOB_PEERS: %v", err)
+		}
+	}
+
+	var acceptedLicenses []string
+	for _, license := range strings.Split(os.Getenv("MODEL_RUNNER_ACCEPTED_LICENSES"), ",") {
+		if license = strings.TrimSpace(license); license != "" {
+			acceptedLicenses = append(acceptedLicenses, license)
+		}
+	}
+
+	var scheduler *scheduling.Scheduler
 	clientConfig := models.ClientConfig{
-		StoreRootPath: modelPath,
-		Logger:        log.WithFields(logrus.Fields{"component": "model-manager"}),
-		Transport:     baseTransport,
+		StoreRootPath:  modelPath,
+		Logger:         log.WithFields(logrus.Fields{"component": "model-manager"}),
+		Transport:      storeTransport,
+		DigestPinning:  digestPinning,
+		MinFreeSpace:   minFreeSpace,
+		DownloadChunks: downloadChunks,
+		GGUFConverter:  os.Getenv("MODEL_RUNNER_GGUF_CONVERTER"),
+		GGUFQuantizer:  os.Getenv("MODEL_RUNNER_GGUF_QUANTIZER"),
+
+		SignatureVerification: signatureVerification,
+		SignatureKeys:         signatureKeys,
+		StoreQuota:            storeQuota,
+		// Models loaded by the scheduler, which is created below, aren't
+		// evicted to stay within the store quota.
+		ModelInUse: func(id string) bool {
+			return scheduler != nil && scheduler.ModelLoaded(id)
+		},
+		ImportPaths:     filepath.SplitList(os.Getenv("MODEL_IMPORT_PATHS")),
+		RegistryMirrors: registryMirrors,
+		Peers:           blobPeers,
+		PeerToken:       clusterToken,
+		// Models packaged with a license are only served once it's
+		// accepted, unless it's one of the licenses accepted by
+		// configuration.
+		RequireLicenseAcceptance: os.Getenv("MODEL_RUNNER_REQUIRE_LICENSE_ACCEPTANCE") == "1",
+		AcceptedLicenses:         acceptedLicenses,
 	}
 	modelHandler := models.NewHTTPHandler(
 		log,
@@ -96,6 +259,11 @@ func main() {
 		memEstimator,
 	)
 	modelManager := models.NewManager(log.WithFields(logrus.Fields{"component": "model-manager"}), clientConfig)
+	if offlineBundle != nil {
+		if err := modelManager.LoadOfflineBundle(offlineBundle); err != nil {
+			log.Warnf("Failed to load models from offline bundle: %v", err)
+		}
+	}
 	log.Infof("LLAMA_SERVER_PATH: %s", llamaServerPath)
 
 	// Create llama.cpp configuration from environment variables
@@ -113,6 +281,7 @@ func main() {
 			return d
 		}(),
 		llamaCppConfig,
+		sysMemInfo.GetTotalMemory().VRAM,
 	)
 	if err != nil {
 		log.Fatalf("unable to initialize %s backend: %v", llamacpp.Name, err)
@@ -128,7 +297,7 @@ func main() {
 		log,
 		modelManager,
 		log.WithFields(logrus.Fields{"component": vllm.Name}),
-		nil,
+		createVLLMConfigFromEnv(),
 	)
 	if err != nil {
 		log.Fatalf("unable to initialize %s backend: %v", vllm.Name, err)
@@ -144,13 +313,104 @@ func main() {
 		log.Fatalf("unable to initialize %s backend: %v", mlx.Name, err)
 	}
 
-	scheduler := scheduling.NewScheduler(
+	sglangBackend, err := sglang.New(
 		log,
-		map[string]inference.Backend{
-			llamacpp.Name: llamaCppBackend,
-			vllm.Name:     vllmBackend,
-			mlx.Name:      mlxBackend,
-		},
+		modelManager,
+		log.WithFields(logrus.Fields{"component": sglang.Name}),
+		createSGLangConfigFromEnv(offlineBundle),
+	)
+	if err != nil {
+		log.Fatalf("unable to initialize %s backend: %v", sglang.Name, err)
+	}
+
+	onnxBackend, err := onnx.New(
+		log,
+		modelManager,
+		log.WithFields(logrus.Fields{"component": onnx.Name}),
+		createONNXConfigFromEnv(),
+	)
+	if err != nil {
+		log.Fatalf("unable to initialize %s backend: %v", onnx.Name, err)
+	}
+
+	whisperBackend, err := whisper.New(
+		log,
+		modelManager,
+		log.WithFields(logrus.Fields{"component": whisper.Name}),
+		createWhisperConfigFromEnv(),
+	)
+	if err != nil {
+		log.Fatalf("unable to initialize %s backend: %v", whisper.Name, err)
+	}
+
+	sdBackend, err := sdcpp.New(
+		log,
+		modelManager,
+		log.WithFields(logrus.Fields{"component": sdcpp.Name}),
+		createSDConfigFromEnv(),
+	)
+	if err != nil {
+		log.Fatalf("unable to initialize %s backend: %v", sdcpp.Name, err)
+	}
+
+	trtllmBackend, err := trtllm.New(
+		log,
+		modelManager,
+		log.WithFields(logrus.Fields{"component": trtllm.Name}),
+		createTRTLLMConfigFromEnv(),
+	)
+	if err != nil {
+		log.Fatalf("unable to initialize %s backend: %v", trtllm.Name, err)
+	}
+
+	teiBackend, err := tei.New(
+		log,
+		modelManager,
+		log.WithFields(logrus.Fields{"component": tei.Name}),
+		createTEIConfigFromEnv(),
+	)
+	if err != nil {
+		log.Fatalf("unable to initialize %s backend: %v", tei.Name, err)
+	}
+
+	remoteBackend, err := remote.New(log.WithFields(logrus.Fields{"component": remote.Name}))
+	if err != nil {
+		log.Fatalf("unable to initialize %s backend: %v", remote.Name, err)
+	}
+
+	backends := map[string]inference.Backend{
+		llamacpp.Name: llamaCppBackend,
+		vllm.Name:     vllmBackend,
+		mlx.Name:      mlxBackend,
+		sglang.Name:   sglangBackend,
+		onnx.Name:     onnxBackend,
+		whisper.Name:  whisperBackend,
+		sdcpp.Name:    sdBackend,
+		trtllm.Name:   trtllmBackend,
+		tei.Name:      teiBackend,
+		remote.Name:   remoteBackend,
+	}
+
+	// Load third-party backends from plugins, which can't replace built-in
+	// backends.
+	if pluginsDir := os.Getenv("MODEL_RUNNER_PLUGINS_DIR"); pluginsDir != "" {
+		pluginLog := log.WithFields(logrus.Fields{"component": "plugins"})
+		for _, backend := range plugin.Discover(ctx, pluginLog, modelManager, pluginsDir) {
+			if _, ok := backends[backend.Name()]; ok {
+				log.Warnf("Ignoring plugin for %s backend, which is already registered", backend.Name())
+				continue
+			}
+			backends[backend.Name()] = backend
+		}
+	}
+
+	for name, backend := range backends {
+		memEstimator.AddBackend(name, backend)
+	}
+
+	scheduler = scheduling.NewScheduler(
+		log,
+		backends,
 		llamaCppBackend,
 		modelManager,
 		http.DefaultClient,
@@ -163,6 +423,63 @@ func main() {
 		sysMemInfo,
 	)
 
+	if s := os.Getenv("MODEL_RUNNER_KEEP_ALIVE"); s != "" {
+		keepAlive, err := scheduling.ParseKeepAlive(s)
+		if err != nil {
+			log.Fatalf("unable to parse MODEL_RUNNER_KEEP_ALIVE: %v", err)
+		}
+		if err := scheduler.SetKeepAlive(ctx, "", keepAlive); err != nil {
+			log.Fatalf("unable to set keep-alive: %v", err)
+		}
+	}
+
+	if s := os.Getenv("MODEL_RUNNER_GPU_PLACEMENT"); s != "" {
+		policy, err := scheduling.ParsePlacementPolicy(s)
+		if err != nil {
+			log.Fatalf("unable to parse MODEL_RUNNER_GPU_PLACEMENT: %v", err)
+		}
+		if err := scheduler.SetPlacementPolicy(ctx, policy); err != nil {
+			log.Fatalf("unable to set GPU placement policy: %v", err)
+		}
+	}
+
+	if limits := createQueueLimitsFromEnv(); limits != (scheduling.QueueLimits{}) {
+		scheduler.SetQueueLimits(limits)
+	}
+	if timeouts := createGenerationTimeoutsFromEnv(); timeouts != (scheduling.GenerationTimeouts{}) {
+		scheduler.SetGenerationTimeouts(timeouts)
+	}
+	if s := os.Getenv("MODEL_RUNNER_EMBEDDING_BATCH_SIZE"); s != "" {
+		size, err := strconv.Atoi(s)
+		if err != nil || size < 0 {
+			log.Fatalf("invalid MODEL_RUNNER_EMBEDDING_BATCH_SIZE: %q", s)
+		}
+		scheduler.SetEmbeddingBatchSize(size)
+	}
+	if interval := createGPUTelemetryIntervalFromEnv(); interval > 0 {
+		scheduler.EnableGPUTelemetry(gpuInfo, interval)
+	}
+
+	if s := os.Getenv("MODEL_RUNNER_PEERS"); s != "" {
+		peers, err := scheduling.ParsePeers(s)
+		if err != nil {
+			log.Fatalf("unable to parse MODEL_RUNNER_PEERS: %v", err)
+		}
+		scheduler.SetClusterToken(clusterToken)
+		if err := scheduler.SetPeers(peers); err != nil {
+			log.Fatalf("unable to set cluster peers: %v", err)
+		}
+	}
+
+	preloadEntries := createPreloadListFromEnv()
+
+	runnerConfigReloader := createRunnerConfigReloaderFromEnv(scheduler, systemMemInfo, memoryBudget)
+	if runnerConfigReloader != nil {
+		if err := runnerConfigReloader.Reload(ctx); err != nil {
+			log.Warnf("Failed to apply MODEL_RUNNER_CONFIG: %v", err)
+		}
+	}
+
 	// Create the HTTP handler for the scheduler
 	schedulerHTTP := scheduling.NewHTTPHandler(scheduler, modelHandler, nil)
 
@@ -173,13 +490,85 @@ func main() {
 	// Register both with and without trailing slash to avoid redirects
 	router.Handle(inference.ModelsPrefix, modelHandler)
 	router.Handle(inference.ModelsPrefix+"/", modelHandler)
-	router.Handle(inference.InferencePrefix+"/", schedulerHTTP)
+	// Reconstruct the conversations of chat completions in sessions, if
+	// enabled.
+	var inferenceHandler http.Handler = schedulerHTTP
+	if sessionStore := createSessionStoreFromEnv(); sessionStore != nil {
+		inferenceHandler = sessions.NewHTTPHandler(log.WithField("component", "sessions"), sessionStore, schedulerHTTP)
+	}
+	router.Handle(inference.InferencePrefix+"/", inferenceHandler)
+	if runnerConfigReloader != nil {
+		router.Handle("POST "+inference.InferencePrefix+"/_reload", runnerConfigReloader)
+	}
 	// Add path aliases: /v1 -> /engines/v1, /rerank -> /engines/rerank, /score -> /engines/score.
-	aliasHandler := &middleware.AliasHandler{Handler: schedulerHTTP}
+	aliasHandler := &middleware.AliasHandler{Handler: inferenceHandler}
 	router.Handle("/v1/", aliasHandler)
 	router.Handle("/rerank", aliasHandler)
 	router.Handle("/score", aliasHandler)
 
+	// Expose models under per-application route prefixes if configured.
+	if appsPath := os.Getenv("MODEL_APPS"); appsPath != "" {
+		appsHandler, err := apps.NewHandler(log.WithField("component", "apps"), appsPath, schedulerHTTP)
+		if err != nil {
+			log.Fatalf("unable to load MODEL_APPS: %v", err)
+		}
+		router.Handle(apps.Prefix+"/", appsHandler)
+		log.Infof("Serving applications from %s under %s", appsPath, apps.Prefix)
+	}
+
+	// Serve the admin API, which controls backend processes and manages the
+	// API keys, if an admin token is configured.
+	keyStore := createAPIKeyStoreFromEnv()
+	if token := os.Getenv("MODEL_RUNNER_ADMIN_TOKEN"); token != "" {
+		var keys admin.Keys
+		if keyStore != nil {
+			keys = keyStore
+		}
+		router.Handle(admin.Prefix+"/", admin.NewHTTPHandler(log.WithField("component", "admin"), scheduler, keys, token))
+	}
+
+	// Serve the OpenAI files and batches APIs, processing batches in the
+	// background with a low priority.
+	batchManager := createBatchManagerFromEnv(userHomeDir, schedulerHTTP)
+	batchHandler := batches.NewHTTPHandler(log.WithField("component", "batches"), batchManager)
+	for _, prefix := range []string{batches.FilesPrefix, batches.BatchesPrefix} {
+		router.Handle(prefix, batchHandler)
+		router.Handle(prefix+"/", batchHandler)
+	}
+
+	// Inference requests served over other transports than HTTP, such as
+	// the generations of WebSocket connections, are metered one by one.
+	meter, usageStore := createUsageMeterFromEnv()
+	if usageStore != nil {
+		defer usageStore.Close()
+	}
+	var meteredInference http.Handler = schedulerHTTP
+	if keyStore != nil {
+		meteredInference = apikeys.NewHandler(keyStore, meteredInference)
+	}
+	if meter != nil {
+		meteredInference = meter.Handler(meteredInference)
+	}
+
+	// Serve the OpenAI Responses API on top of chat completions.
+	responsesHandler := responses.NewHTTPHandler(log.WithField("component", "responses"), schedulerHTTP)
+	for _, prefix := range responses.Prefixes() {
+		router.Handle(prefix+responses.Path, responsesHandler)
+		router.Handle(prefix+responses.Path+"/", responsesHandler)
+	}
+
+	// Serve the OpenAI moderations API with a local safety classifier model.
+	moderationsHandler := moderations.NewHTTPHandler(log.WithField("component", "moderations"), schedulerHTTP, os.Getenv("MODEL_RUNNER_MODERATION_MODEL"))
+	for _, prefix := range moderations.Prefixes() {
+		router.Handle(prefix+moderations.Path, moderationsHandler)
+	}
+
+	// Serve chat completions over WebSocket connections.
+	wschatHandler := wschat.NewHTTPHandler(log.WithField("component", "wschat"), meteredInference)
+	for _, prefix := range wschat.Prefixes() {
+		router.Handle(prefix+wschat.Path, wschatHandler)
+	}
+
 	// Add Ollama API compatibility layer (only register with trailing slash to catch sub-paths)
 	ollamaHandler := ollama.NewHTTPHandler(log, scheduler, schedulerHTTP, nil, modelManager)
 	router.Handle(ollama.APIPrefix+"/", ollamaHandler)
@@ -195,6 +584,23 @@ func main() {
 		w.Write([]byte("Docker Model Runner is running"))
 	})
 
+	// Serve the store as a pull-through cache for other model runners if
+	// enabled
+	if os.Getenv("MODEL_REGISTRY_CACHE") == "1" {
+		router.Handle(models.RegistryCachePrefix, models.NewRegistryCache(
+			log.WithField("component", "registry-cache"),
+			modelManager,
+		))
+		log.Info("Registry cache enabled at " + models.RegistryCachePrefix)
+	}
+
+	// Serve the blobs in the store to the peers that fetch layers from each
+	// other, if any
+	if len(blobPeers) > 0 {
+		router.Handle(models.PeerBlobsPrefix, models.NewPeerBlobServer(modelManager))
+		log.Infof("Peer-to-peer model distribution enabled with peers %v", blobPeers)
+	}
+
 	// Add metrics endpoint if enabled
 	if os.Getenv("DISABLE_METRICS") != "1" {
 		metricsHandler := metrics.NewAggregatedMetricsHandler(
@@ -207,8 +613,46 @@ func main() {
 		log.Info("Metrics endpoint disabled")
 	}
 
+	// Apply the CORS configuration to every route, answering the preflight
+	// requests of browser-based applications before they reach routes that
+	// require authorization.
+	var handler http.Handler = router
+	if keyStore != nil {
+		// The health check and the admin API, which has its own token,
+		// don't require an API key.
+		keyHandler := apikeys.NewHandler(keyStore, handler, "GET /{$}", admin.Prefix+"/")
+		// Peers poll the capacity of the node and fetch the blobs in its
+		// store with the cluster token.
+		keyHandler.AllowToken(clusterToken, "GET "+inference.InferencePrefix+"/node", "GET "+models.PeerBlobsPrefix)
+		if clusterToken == "" && (os.Getenv("MODEL_RUNNER_PEERS") != "" || len(blobPeers) > 0) {
+			log.Warn("MODEL_RUNNER_CLUSTER_TOKEN isn't set, so peers can't poll this node or fetch its blobs while API keys are required")
+		}
+		handler = keyHandler
+	}
+	corsConfig := middleware.CORSConfigFromEnv()
+	if corsConfig.AllowCredentials && !corsConfig.CredentialsAllowed() {
+		log.Warn("Ignoring DMR_CORS_CREDENTIALS, since credentials can't be allowed from every origin")
+	}
+	handler = middleware.CORSHandler(corsConfig, handler)
+	if meter != nil {
+		router.Handle(usage.Prefix+"/", usage.NewHTTPHandler(log.WithField("component", "usage"), usageStore))
+		handler = meter.Handler(handler)
+	}
+	if payloadLogger, closePayloadLog := createPayloadLoggerFromEnv(); payloadLogger != nil {
+		defer closePayloadLog()
+		handler = payloadLogger.Handler(handler)
+	}
+	if accessLogger, closeAccessLog := createAccessLoggerFromEnv(); accessLogger != nil {
+		defer closeAccessLog()
+		handler = accessLogger.Handler(handler)
+	}
+	shutdownTracing := setupTracingFromEnv(ctx)
+	defer shutdownTracing()
+	handler = tracing.Handler(handler)
+
+	shutdownGracePeriod := createShutdownGracePeriodFromEnv()
 	server := &http.Server{
-		Handler:           router,
+		Handler:           handler,
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 	serverErrors := make(chan error, 1)
@@ -239,23 +683,47 @@ func main() {
 		}()
 	}
 
+	grpcServer := createGRPCServerFromEnv(meteredInference, serverErrors)
+
+	// The scheduler outlives the signal context, so that its runners can
+	// finish serving in-flight requests during shutdown.
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
 	schedulerErrors := make(chan error, 1)
 	go func() {
-		schedulerErrors <- scheduler.Run(ctx)
+		schedulerErrors <- scheduler.Run(schedulerCtx)
 	}()
 
+	if reconciler := createModelsLockReconcilerFromEnv(modelManager, scheduler); reconciler != nil {
+		go reconciler.Run(ctx)
+	}
+
+	if len(preloadEntries) > 0 {
+		go scheduler.Preload(ctx, preloadEntries)
+	}
+
+	if runnerConfigReloader != nil {
+		go reloadOnHangup(ctx, runnerConfigReloader)
+	}
+
+	go batchManager.Run(ctx)
+
 	select {
 	case err := <-serverErrors:
 		if err != nil {
 			log.Errorf("Server error: %v", err)
 		}
 	case <-ctx.Done():
+		// Restore the default signal behavior, so that a second signal
+		// terminates immediately.
+		cancel()
 		log.Infoln("Shutdown signal received")
-		log.Infoln("Shutting down the server")
-		if err := server.Close(); err != nil {
-			log.Errorf("Server shutdown error: %v", err)
+		if grpcServer != nil {
+			go shutdownGRPCServer(grpcServer, shutdownGracePeriod)
 		}
+		shutdownServer(server, scheduler, shutdownGracePeriod)
 		log.Infoln("Waiting for the scheduler to stop")
+		stopScheduler()
 		if err := <-schedulerErrors; err != nil {
 			log.Errorf("Scheduler error: %v", err)
 		}
@@ -263,33 +731,693 @@ func main() {
 	log.Infoln("Docker Model Runner stopped")
 }
 
+// createBatchManagerFromEnv creates the batch manager, which stores files and
+// batches in MODEL_RUNNER_BATCHES_PATH (by default ~/.docker/model-batches)
+// and processes MODEL_RUNNER_BATCH_CONCURRENCY requests of a batch at once.
+func createBatchManagerFromEnv(userHomeDir string, next http.Handler) *batches.Manager {
+	path := os.Getenv("MODEL_RUNNER_BATCHES_PATH")
+	if path == "" {
+		path = filepath.Join(userHomeDir, ".docker", "model-batches")
+	}
+	manager, err := batches.NewManager(log.WithField("component", "batches"), path, next)
+	if err != nil {
+		log.Fatalf("unable to load batches from %s: %v", path, err)
+	}
+	if s := os.Getenv("MODEL_RUNNER_BATCH_CONCURRENCY"); s != "" {
+		concurrency, err := strconv.Atoi(s)
+		if err != nil || concurrency < 1 {
+			log.Fatalf("invalid MODEL_RUNNER_BATCH_CONCURRENCY: %q", s)
+		}
+		manager.SetConcurrency(concurrency)
+	}
+	return manager
+}
+
+// createGRPCServerFromEnv serves the gRPC inference API with next on the TCP
+// port set by MODEL_RUNNER_GRPC_PORT, reporting errors serving it on
+// serverErrors. It returns nil if no port is set.
+func createGRPCServerFromEnv(next http.Handler, serverErrors chan<- error) *grpc.Server {
+	port := os.Getenv("MODEL_RUNNER_GRPC_PORT")
+	if port == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatalf("Failed to listen on gRPC port %s: %v", port, err)
+	}
+	server := grpcapi.NewServer(log.WithField("component", "grpc"), next)
+	log.Infof("Serving the gRPC inference API on TCP port %s", port)
+	go func() {
+		if err := server.Serve(ln); err != nil {
+			serverErrors <- fmt.Errorf("gRPC server: %w", err)
+		}
+	}()
+	return server
+}
+
+// shutdownGRPCServer stops the gRPC server from accepting new calls and waits
+// up to the grace period for in-flight calls to complete, after which they're
+// cut off.
+func shutdownGRPCServer(server *grpc.Server, gracePeriod time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(gracePeriod):
+		server.Stop()
+	}
+}
+
+// drainStatusInterval is the interval at which the requests still in flight
+// are logged during shutdown.
+const drainStatusInterval = 5 * time.Second
+
+// createShutdownGracePeriodFromEnv returns how long in-flight requests may
+// take to complete during shutdown, which is set by
+// MODEL_RUNNER_SHUTDOWN_GRACE_PERIOD.
+func createShutdownGracePeriodFromEnv() time.Duration {
+	gracePeriod := 30 * time.Second
+	if s := os.Getenv("MODEL_RUNNER_SHUTDOWN_GRACE_PERIOD"); s != "" {
+		var err error
+		if gracePeriod, err = time.ParseDuration(s); err != nil || gracePeriod < 0 {
+			log.Fatalf("invalid MODEL_RUNNER_SHUTDOWN_GRACE_PERIOD: %q", s)
+		}
+	}
+	return gracePeriod
+}
+
+// createGPUTelemetryIntervalFromEnv returns the interval at which the
+// telemetry of the GPUs is sampled, which is set by
+// MODEL_RUNNER_GPU_TELEMETRY_INTERVAL. Zero disables GPU telemetry.
+func createGPUTelemetryIntervalFromEnv() time.Duration {
+	interval := gputelemetry.DefaultInterval
+	if s := os.Getenv("MODEL_RUNNER_GPU_TELEMETRY_INTERVAL"); s != "" {
+		var err error
+		if interval, err = time.ParseDuration(s); err != nil || interval < 0 {
+			log.Fatalf("invalid MODEL_RUNNER_GPU_TELEMETRY_INTERVAL: %q", s)
+		}
+	}
+	return interval
+}
+
+// shutdownServer stops the server from accepting new requests and waits up to
+// the grace period for in-flight requests to complete, periodically logging
+// the requests still in flight for each backend. Requests still in flight
+// after the grace period are cut off.
+func shutdownServer(server *http.Server, scheduler *scheduling.Scheduler, gracePeriod time.Duration) {
+	log.Infof("Shutting down the server, allowing %s for in-flight requests to complete", gracePeriod)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	shutdownErrors := make(chan error, 1)
+	go func() {
+		shutdownErrors <- server.Shutdown(shutdownCtx)
+	}()
+	logDrainStatus(scheduler)
+
+	ticker := time.NewTicker(drainStatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-shutdownErrors:
+			if errors.Is(err, context.DeadlineExceeded) {
+				logDrainStatus(scheduler)
+				log.Warnf("Grace period of %s expired, cutting off in-flight requests", gracePeriod)
+				err = server.Close()
+			}
+			if err != nil {
+				log.Errorf("Server shutdown error: %v", err)
+			}
+			return
+		case <-ticker.C:
+			logDrainStatus(scheduler)
+		}
+	}
+}
+
+// logDrainStatus logs the number of requests still in flight for each
+// backend.
+func logDrainStatus(scheduler *scheduling.Scheduler) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	inFlight := scheduler.InFlightRequests(ctx)
+	if len(inFlight) == 0 {
+		return
+	}
+	backendNames := slices.Sorted(maps.Keys(inFlight))
+	status := make([]string, len(backendNames))
+	for i, name := range backendNames {
+		status[i] = fmt.Sprintf("%s: %d", name, inFlight[name])
+	}
+	log.Infof("Draining in-flight requests (%s)", strings.Join(status, ", "))
+}
+
+// createModelsLockReconcilerFromEnv creates a reconciler for the models.lock
+// file named by MODELS_LOCK. It returns nil if no file is configured.
+func createModelsLockReconcilerFromEnv(modelManager *models.Manager, scheduler *scheduling.Scheduler) *modelslock.Reconciler {
+	path := os.Getenv("MODELS_LOCK")
+	if path == "" {
+		return nil
+	}
+
+	interval := time.Minute
+	if s := os.Getenv("MODELS_LOCK_INTERVAL"); s != "" {
+		var err error
+		interval, err = time.ParseDuration(s)
+		if err != nil || interval <= 0 {
+			log.Fatalf("invalid MODELS_LOCK_INTERVAL: %q", s)
+		}
+	}
+
+	log.Infof("Reconciling models with %s every %s", path, interval)
+	return modelslock.NewReconciler(
+		log.WithFields(logrus.Fields{"component": "models-lock"}),
+		path,
+		interval,
+		modelManager,
+		scheduler,
+	)
+}
+
+// createRunnerConfigReloaderFromEnv creates a reloader for the runner
+// configuration file named by MODEL_RUNNER_CONFIG. It returns nil if no file
+// is configured. The file must be valid at startup.
+func createRunnerConfigReloaderFromEnv(scheduler *scheduling.Scheduler, sysMemInfo memory.SystemMemoryInfo, memoryBudget inference.RequiredMemory) *runnerconfig.Reloader {
+	path := os.Getenv("MODEL_RUNNER_CONFIG")
+	if path == "" {
+		return nil
+	}
+	if _, err := runnerconfig.Load(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("unable to load MODEL_RUNNER_CONFIG: %v", err)
+	}
+	log.Infof("Applying runner configuration from %s, reloaded on SIGHUP", path)
+	return runnerconfig.NewReloader(
+		log.WithFields(logrus.Fields{"component": "runner-config"}),
+		path,
+		scheduler,
+		sysMemInfo,
+		memoryBudget,
+	)
+}
+
+// reloadOnHangup reloads the runner configuration file whenever the process
+// receives SIGHUP, until ctx is cancelled.
+func reloadOnHangup(ctx context.Context, reloader *runnerconfig.Reloader) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			log.Infoln("SIGHUP received, reloading the runner configuration")
+			if err := reloader.Reload(ctx); err != nil {
+				log.Warnf("Failed to reload the runner configuration: %v", err)
+			}
+		}
+	}
+}
+
+// createQueueLimitsFromEnv creates the limits on the requests queued for each
+// model from environment variables. Unset variables leave the corresponding
+// limit disabled.
+func createQueueLimitsFromEnv() scheduling.QueueLimits {
+	var limits scheduling.QueueLimits
+	var err error
+	if s := os.Getenv("MODEL_RUNNER_MAX_CONCURRENT_REQUESTS"); s != "" {
+		if limits.MaxConcurrent, err = strconv.Atoi(s); err != nil || limits.MaxConcurrent < 0 {
+			log.Fatalf("invalid MODEL_RUNNER_MAX_CONCURRENT_REQUESTS: %q", s)
+		}
+	}
+	if s := os.Getenv("MODEL_RUNNER_MAX_QUEUE_DEPTH"); s != "" {
+		if limits.MaxDepth, err = strconv.Atoi(s); err != nil || limits.MaxDepth < 0 {
+			log.Fatalf("invalid MODEL_RUNNER_MAX_QUEUE_DEPTH: %q", s)
+		}
+	}
+	if s := os.Getenv("MODEL_RUNNER_MAX_QUEUE_WAIT"); s != "" {
+		if limits.MaxWait, err = time.ParseDuration(s); err != nil || limits.MaxWait < 0 {
+			log.Fatalf("invalid MODEL_RUNNER_MAX_QUEUE_WAIT: %q", s)
+		}
+	}
+	if s := os.Getenv("MODEL_RUNNER_FAIR_SHARE"); s != "" {
+		if limits.FairShare, err = strconv.ParseBool(s); err != nil {
+			log.Fatalf("invalid MODEL_RUNNER_FAIR_SHARE: %q", s)
+		}
+	}
+	if s := os.Getenv("MODEL_RUNNER_PREEMPTION_THRESHOLD"); s != "" {
+		if limits.PreemptionThreshold, err = strconv.Atoi(s); err != nil || limits.PreemptionThreshold < 0 {
+			log.Fatalf("invalid MODEL_RUNNER_PREEMPTION_THRESHOLD: %q", s)
+		}
+	}
+	return limits
+}
+
+// createGenerationTimeoutsFromEnv returns the default limits on the time that
+// backends spend serving requests, which are set by
+// MODEL_RUNNER_FIRST_TOKEN_TIMEOUT and MODEL_RUNNER_GENERATION_TIMEOUT.
+func createGenerationTimeoutsFromEnv() scheduling.GenerationTimeouts {
+	var timeouts scheduling.GenerationTimeouts
+	var err error
+	if s := os.Getenv("MODEL_RUNNER_FIRST_TOKEN_TIMEOUT"); s != "" {
+		if timeouts.FirstToken, err = time.ParseDuration(s); err != nil || timeouts.FirstToken < 0 {
+			log.Fatalf("invalid MODEL_RUNNER_FIRST_TOKEN_TIMEOUT: %q", s)
+		}
+	}
+	if s := os.Getenv("MODEL_RUNNER_GENERATION_TIMEOUT"); s != "" {
+		if timeouts.Total, err = time.ParseDuration(s); err != nil || timeouts.Total < 0 {
+			log.Fatalf("invalid MODEL_RUNNER_GENERATION_TIMEOUT: %q", s)
+		}
+	}
+	return timeouts
+}
+
+// createPreloadListFromEnv parses the models named by MODEL_RUNNER_PRELOAD,
+// which are loaded and warmed up at startup.
+func createPreloadListFromEnv() []scheduling.PreloadEntry {
+	s := os.Getenv("MODEL_RUNNER_PRELOAD")
+	if s == "" {
+		return nil
+	}
+	entries, err := scheduling.ParsePreloadList(s)
+	if err != nil {
+		log.Fatalf("unable to parse MODEL_RUNNER_PRELOAD: %v", err)
+	}
+	return entries
+}
+
+// createUsageMeterFromEnv creates a meter recording the usage of inference
+// requests in MODEL_RUNNER_USAGE_PATH, for MODEL_RUNNER_USAGE_RETENTION if
+// set, along with its store. It returns nil if usage metering is disabled.
+func createUsageMeterFromEnv() (*usage.Meter, *usage.Store) {
+	path := os.Getenv("MODEL_RUNNER_USAGE_PATH")
+	if path == "" {
+		return nil, nil
+	}
+	var retention time.Duration
+	if s := os.Getenv("MODEL_RUNNER_USAGE_RETENTION"); s != "" {
+		var err error
+		if retention, err = time.ParseDuration(s); err != nil || retention < 0 {
+			log.Fatalf("invalid MODEL_RUNNER_USAGE_RETENTION: %q", s)
+		}
+	}
+	store, err := usage.NewStore(path, retention)
+	if err != nil {
+		log.Fatalf("unable to open usage store: %v", err)
+	}
+	log.Infof("Recording usage in %s", path)
+	return usage.NewMeter(log.WithField("component", "usage"), store), store
+}
+
+// createSessionStoreFromEnv creates the store of the conversations of
+// sessions, which expire once they're unused for MODEL_RUNNER_SESSION_TTL and
+// keep MODEL_RUNNER_SESSION_MAX_MESSAGES messages each. It returns nil if
+// sessions aren't stored.
+func createSessionStoreFromEnv() *sessions.Store {
+	s := os.Getenv("MODEL_RUNNER_SESSION_TTL")
+	if s == "" {
+		return nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl <= 0 {
+		log.Fatalf("invalid MODEL_RUNNER_SESSION_TTL: %q", s)
+	}
+	maxMessages := sessions.DefaultMaxMessages
+	if s := os.Getenv("MODEL_RUNNER_SESSION_MAX_MESSAGES"); s != "" {
+		if maxMessages, err = strconv.Atoi(s); err != nil || maxMessages <= 0 {
+			log.Fatalf("invalid MODEL_RUNNER_SESSION_MAX_MESSAGES: %q", s)
+		}
+	}
+	log.Infof("Storing the conversations of sessions for %s", ttl)
+	return sessions.NewStore(ttl, maxMessages)
+}
+
+// createAPIKeyStoreFromEnv creates the store of the API keys that requests
+// are authenticated with from the keys file named by MODEL_RUNNER_API_KEYS.
+// It returns nil if API keys aren't required.
+func createAPIKeyStoreFromEnv() *apikeys.Store {
+	path := os.Getenv("MODEL_RUNNER_API_KEYS")
+	if path == "" {
+		return nil
+	}
+	store, err := apikeys.NewStore(log.WithField("component", "apikeys"), path)
+	if err != nil {
+		log.Fatalf("unable to load MODEL_RUNNER_API_KEYS: %v", err)
+	}
+	log.Infof("Requiring API keys from %s", path)
+	return store
+}
+
+// setupTracingFromEnv sets up the tracing of requests, which continue the
+// traces of their clients and propagate them to backends. Spans are exported
+// via OTLP over HTTP if OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set, unless OTEL_TRACES_EXPORTER is
+// none. It returns a function that flushes the spans not yet exported.
+func setupTracingFromEnv(ctx context.Context) func() {
+	export := (os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "") &&
+		os.Getenv("OTEL_TRACES_EXPORTER") != "none"
+	shutdown, err := tracing.Setup(ctx, export)
+	if err != nil {
+		log.Fatalf("unable to set up tracing: %v", err)
+	}
+	if export {
+		log.Info("Exporting traces via OTLP")
+	}
+	return func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(shutdownCtx); err != nil {
+			log.Warnf("Failed to flush traces: %v", err)
+		}
+	}
+}
+
+// createAccessLoggerFromEnv creates an access logger from environment
+// variables. It returns nil if access logging is disabled.
+func createAccessLoggerFromEnv() (*accesslog.Logger, func()) {
+	target := os.Getenv("ACCESS_LOG")
+	if target == "" {
+		return nil, nil
+	}
+
+	format, err := accesslog.ParseFormat(os.Getenv("ACCESS_LOG_FORMAT"))
+	if err != nil {
+		log.Fatalf("unable to parse ACCESS_LOG_FORMAT: %v", err)
+	}
+
+	maxSize, maxBackups := accessLogRotationFromEnv()
+	w, err := accesslog.Open(target, maxSize, maxBackups)
+	if err != nil {
+		log.Fatalf("unable to open access log: %v", err)
+	}
+	log.Infof("Writing %s access logs to %s", format, target)
+	w = logging.NewDiskFullWriter(w, func(err error) {
+		log.Warnf("Dropping access log entries while the disk is full: %v", err)
+	})
+	return accesslog.NewLogger(w, format), func() {
+		if err := w.Close(); err != nil {
+			log.Warnf("Failed to close access log: %v", err)
+		}
+	}
+}
+
+// createPayloadLoggerFromEnv creates a payload logger from environment
+// variables. It returns nil if payload logging is disabled.
+func createPayloadLoggerFromEnv() (*accesslog.PayloadLogger, func()) {
+	target := os.Getenv("PAYLOAD_LOG")
+	if target == "" {
+		return nil, nil
+	}
+
+	defaultRate := 0.01
+	if s := os.Getenv("PAYLOAD_LOG_SAMPLE_RATE"); s != "" {
+		var err error
+		if defaultRate, err = accesslog.ParseSampleRate(s); err != nil {
+			log.Fatalf("unable to parse PAYLOAD_LOG_SAMPLE_RATE: %v", err)
+		}
+	}
+	rates, err := accesslog.ParseSampleRates(os.Getenv("PAYLOAD_LOG_MODEL_SAMPLE_RATES"))
+	if err != nil {
+		log.Fatalf("unable to parse PAYLOAD_LOG_MODEL_SAMPLE_RATES: %v", err)
+	}
+	maxBytes := 4096
+	if s := os.Getenv("PAYLOAD_LOG_MAX_BYTES"); s != "" {
+		if maxBytes, err = strconv.Atoi(s); err != nil || maxBytes < 0 {
+			log.Fatalf("unable to parse PAYLOAD_LOG_MAX_BYTES: %q", s)
+		}
+	}
+
+	maxSize, maxBackups := accessLogRotationFromEnv()
+	w, err := accesslog.Open(target, maxSize, maxBackups)
+	if err != nil {
+		log.Fatalf("unable to open payload log: %v", err)
+	}
+	log.Infof("Writing payload logs to %s (sample rate %v, %d model overrides, %d byte cap)",
+		target, defaultRate, len(rates), maxBytes)
+	w = logging.NewDiskFullWriter(w, func(err error) {
+		log.Warnf("Dropping payload log entries while the disk is full: %v", err)
+	})
+	return accesslog.NewPayloadLogger(w, defaultRate, rates, maxBytes), func() {
+		if err := w.Close(); err != nil {
+			log.Warnf("Failed to close payload log: %v", err)
+		}
+	}
+}
+
+// accessLogRotationFromEnv returns the maximum size, in bytes, and the number
+// of retained backups for rotated access and payload log files.
+func accessLogRotationFromEnv() (int64, int) {
+	var err error
+	maxSizeMB := int64(100)
+	if s := os.Getenv("ACCESS_LOG_MAX_SIZE_MB"); s != "" {
+		if maxSizeMB, err = strconv.ParseInt(s, 10, 64); err != nil {
+			log.Fatalf("unable to parse ACCESS_LOG_MAX_SIZE_MB: %v", err)
+		}
+	}
+	maxBackups := 5
+	if s := os.Getenv("ACCESS_LOG_MAX_BACKUPS"); s != "" {
+		if maxBackups, err = strconv.Atoi(s); err != nil {
+			log.Fatalf("unable to parse ACCESS_LOG_MAX_BACKUPS: %v", err)
+		}
+	}
+	return maxSizeMB * 1024 * 1024, maxBackups
+}
+
 // createLlamaCppConfigFromEnv creates a LlamaCppConfig from environment variables
 func createLlamaCppConfigFromEnv() config.BackendConfig {
-	// Check if any configuration environment variables are set
-	argsStr := os.Getenv("LLAMA_ARGS")
-
 	// If no environment variables are set, use default configuration
-	if argsStr == "" {
+	if !slices.ContainsFunc([]string{
+		"LLAMA_ARGS", "LLAMA_KV_CACHE_TYPE", "LLAMA_THREADS", "LLAMA_THREADS_BATCH", "LLAMA_NUMA",
+	}, func(name string) bool {
+		_, ok := os.LookupEnv(name)
+		return ok
+	}) {
 		return nil // nil will cause the backend to use its default configuration
 	}
 
-	// Split the string by spaces, respecting quoted arguments
-	args := splitArgs(argsStr)
+	conf := llamacpp.NewDefaultLlamaCppConfig()
+
+	if argsStr := os.Getenv("LLAMA_ARGS"); argsStr != "" {
+		// Split the string by spaces, respecting quoted arguments
+		args := splitArgs(argsStr)
 
-	// Check for disallowed arguments
-	disallowedArgs := []string{"--model", "--host", "--embeddings", "--mmproj"}
-	for _, arg := range args {
-		for _, disallowed := range disallowedArgs {
-			if arg == disallowed {
-				log.Fatalf("LLAMA_ARGS cannot override the %s argument as it is controlled by the model runner", disallowed)
+		// Check for disallowed arguments
+		disallowedArgs := []string{"--model", "--host", "--embeddings", "--mmproj"}
+		for _, arg := range args {
+			for _, disallowed := range disallowedArgs {
+				if arg == disallowed {
+					log.Fatalf("LLAMA_ARGS cannot override the %s argument as it is controlled by the model runner", disallowed)
+				}
 			}
 		}
+
+		log.Infof("Using custom arguments: %v", args)
+		conf.Args = args
+	}
+
+	var err error
+	if conf.KVCacheType, err = inference.ParseKVCacheType(os.Getenv("LLAMA_KV_CACHE_TYPE")); err != nil {
+		log.Fatalf("unable to parse LLAMA_KV_CACHE_TYPE: %v", err)
+	}
+	if s := os.Getenv("LLAMA_THREADS"); s != "" {
+		if conf.Threads, err = strconv.Atoi(s); err != nil || conf.Threads < 1 {
+			log.Fatalf("invalid LLAMA_THREADS: %q", s)
+		}
+	}
+	if s := os.Getenv("LLAMA_THREADS_BATCH"); s != "" {
+		if conf.BatchThreads, err = strconv.Atoi(s); err != nil || conf.BatchThreads < 1 {
+			log.Fatalf("invalid LLAMA_THREADS_BATCH: %q", s)
+		}
+	}
+	if s, ok := os.LookupEnv("LLAMA_NUMA"); ok {
+		// An empty value disables NUMA optimizations.
+		if conf.NUMA, err = llamacpp.ParseNUMAStrategy(s); err != nil {
+			log.Fatalf("unable to parse LLAMA_NUMA: %v", err)
+		}
+	}
+
+	log.Infof("llama.cpp threads: %d, batch threads: %d, NUMA strategy: %q, KV cache type: %q",
+		conf.Threads, conf.BatchThreads, conf.NUMA, conf.KVCacheType)
+	return conf
+}
+
+func createVLLMConfigFromEnv() *vllm.Config {
+	guidedDecodingBackend := os.Getenv("VLLM_GUIDED_DECODING_BACKEND")
+	installDir := os.Getenv("VLLM_INSTALL_DIR")
+	version := os.Getenv("VLLM_VERSION")
+
+	// If no environment variables are set, use default configuration
+	if guidedDecodingBackend == "" && installDir == "" && version == "" {
+		return nil // nil will cause the backend to use its default configuration
+	}
+
+	cfg := vllm.NewDefaultVLLMConfig()
+	if guidedDecodingBackend != "" {
+		if !vllm.ValidGuidedDecodingBackend(guidedDecodingBackend) {
+			log.Fatalf("VLLM_GUIDED_DECODING_BACKEND has unsupported value %q", guidedDecodingBackend)
+		}
+		log.Infof("Using vLLM guided decoding backend: %s", guidedDecodingBackend)
+		cfg.GuidedDecodingBackend = guidedDecodingBackend
+	}
+	if installDir != "" {
+		log.Infof("Using managed vLLM installation directory: %s", installDir)
+		cfg.ManagedInstallDir = installDir
 	}
+	cfg.Version = version
+	return cfg
+}
 
-	log.Infof("Using custom arguments: %v", args)
-	return &llamacpp.Config{
-		Args: args,
+// createONNXConfigFromEnv creates an ONNX configuration from environment
+// variables
+func createONNXConfigFromEnv() *onnx.Config {
+	installDir := os.Getenv("ONNX_INSTALL_DIR")
+	version := os.Getenv("ONNX_VERSION")
+
+	// If no environment variables are set, use default configuration
+	if installDir == "" && version == "" {
+		return nil // nil will cause the backend to use its default configuration
+	}
+
+	cfg := onnx.NewDefaultONNXConfig()
+	if installDir != "" {
+		log.Infof("Using managed ONNX Runtime GenAI server installation directory: %s", installDir)
+		cfg.ManagedInstallDir = installDir
+	}
+	cfg.Version = version
+	return cfg
+}
+
+// createWhisperConfigFromEnv creates a whisper.cpp configuration from
+// environment variables
+func createWhisperConfigFromEnv() *whisper.Config {
+	binaryPath := os.Getenv("WHISPER_SERVER_PATH")
+	if binaryPath == "" {
+		return nil // nil will cause the backend to use its default configuration
+	}
+
+	log.Infof("Using whisper-server binary: %s", binaryPath)
+	cfg := whisper.NewDefaultWhisperConfig()
+	cfg.BinaryPath = binaryPath
+	return cfg
+}
+
+// createSDConfigFromEnv creates a stable-diffusion.cpp configuration from
+// environment variables
+func createSDConfigFromEnv() *sdcpp.Config {
+	binaryPath := os.Getenv("SD_SERVER_PATH")
+	maxImageSize := os.Getenv("SD_MAX_IMAGE_SIZE")
+	if binaryPath == "" && maxImageSize == "" {
+		return nil // nil will cause the backend to use its default configuration
+	}
+
+	cfg := sdcpp.NewDefaultSDConfig()
+	if binaryPath != "" {
+		log.Infof("Using sd-server binary: %s", binaryPath)
+		cfg.BinaryPath = binaryPath
+	}
+	if maxImageSize != "" {
+		size, err := sdcpp.ParseImageSize(maxImageSize)
+		if err != nil {
+			log.Fatalf("invalid SD_MAX_IMAGE_SIZE: %v", err)
+		}
+		cfg.MaxImageSize = size
+	}
+	return cfg
+}
+
+// createTRTLLMConfigFromEnv creates a TensorRT-LLM configuration from
+// environment variables
+func createTRTLLMConfigFromEnv() *trtllm.Config {
+	binaryPath := os.Getenv("TRTLLM_SERVE_PATH")
+	if binaryPath == "" {
+		return nil // nil will cause the backend to use its default configuration
+	}
+
+	log.Infof("Using trtllm-serve binary: %s", binaryPath)
+	cfg := trtllm.NewDefaultTRTLLMConfig()
+	cfg.BinaryPath = binaryPath
+	return cfg
+}
+
+// createMemoryBudgetFromEnv creates the memory budget for loaded models from
+// environment variables. Zero values leave the corresponding memory unlimited.
+func createMemoryBudgetFromEnv() inference.RequiredMemory {
+	var budget inference.RequiredMemory
+	if s := os.Getenv("MODEL_RUNNER_RAM_BUDGET_MB"); s != "" {
+		mb, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			log.Fatalf("unable to parse MODEL_RUNNER_RAM_BUDGET_MB: %v", err)
+		}
+		budget.RAM = mb * 1024 * 1024
+		log.Infof("Limiting models to %d MB RAM", mb)
+	}
+	if s := os.Getenv("MODEL_RUNNER_VRAM_BUDGET_MB"); s != "" {
+		mb, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			log.Fatalf("unable to parse MODEL_RUNNER_VRAM_BUDGET_MB: %v", err)
+		}
+		budget.VRAM = mb * 1024 * 1024
+		log.Infof("Limiting models to %d MB VRAM", mb)
+	}
+	return budget
+}
+
+// createTEIConfigFromEnv creates a TEI configuration from environment
+// variables
+func createTEIConfigFromEnv() *tei.Config {
+	binaryPath := os.Getenv("TEI_ROUTER_PATH")
+	maxBatchTokens := os.Getenv("TEI_MAX_BATCH_TOKENS")
+	maxConcurrentRequests := os.Getenv("TEI_MAX_CONCURRENT_REQUESTS")
+	if binaryPath == "" && maxBatchTokens == "" && maxConcurrentRequests == "" {
+		return nil // nil will cause the backend to use its default configuration
+	}
+
+	cfg := tei.NewDefaultTEIConfig()
+	if binaryPath != "" {
+		log.Infof("Using text-embeddings-router binary: %s", binaryPath)
+		cfg.BinaryPath = binaryPath
+	}
+	if maxBatchTokens != "" {
+		n, err := strconv.Atoi(maxBatchTokens)
+		if err != nil || n <= 0 {
+			log.Fatalf("TEI_MAX_BATCH_TOKENS must be a positive integer, got %q", maxBatchTokens)
+		}
+		cfg.MaxBatchTokens = n
+	}
+	if maxConcurrentRequests != "" {
+		n, err := strconv.Atoi(maxConcurrentRequests)
+		if err != nil || n <= 0 {
+			log.Fatalf("TEI_MAX_CONCURRENT_REQUESTS must be a positive integer, got %q", maxConcurrentRequests)
+		}
+		cfg.MaxConcurrentRequests = n
+	}
+	return cfg
+}
+
+// createSGLangConfigFromEnv creates an SGLang configuration from environment
+// variables and the offline bundle, if any
+func createSGLangConfigFromEnv(offlineBundle *offline.Bundle) *sglang.Config {
+	dpSize := os.Getenv("SGLANG_DP_SIZE")
+	if dpSize == "" && offlineBundle == nil {
+		return nil // nil will cause the backend to use its default configuration
+	}
+
+	cfg := sglang.NewDefaultSGLangConfig()
+	cfg.OfflineBundle = offlineBundle
+	if dpSize == "" {
+		return cfg
+	}
+	n, err := strconv.Atoi(dpSize)
+	if err != nil || n < 1 {
+		log.Fatalf("SGLANG_DP_SIZE must be a positive integer, got %q", dpSize)
+	}
+	if n > 1 {
+		log.Infof("Using SGLang data-parallel router with %d workers", n)
 	}
+	cfg.DataParallelSize = n
+	return cfg
 }
 
 // splitArgs splits a string into arguments, respecting quoted arguments
@@ -0,0 +1,156 @@
+// Package accesslog implements access logging for inference requests in
+// Common Log Format or JSON, independent of the debug logs.
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/middleware"
+)
+
+// Format is an access log format.
+type Format string
+
+const (
+	// FormatCommon is the Common Log Format, extended with the model,
+	// duration, and token counts.
+	FormatCommon Format = "common"
+	// FormatJSON writes one JSON object per request.
+	FormatJSON Format = "json"
+)
+
+// ParseFormat parses an access log format.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCommon, FormatJSON:
+		return f, nil
+	case "":
+		return FormatCommon, nil
+	default:
+		return "", fmt.Errorf("invalid access log format %q (expected common or json)", s)
+	}
+}
+
+// Entry is a single access log entry.
+type Entry struct {
+	Time             time.Time `json:"time"`
+	RemoteAddr       string    `json:"remote_addr,omitempty"`
+	Method           string    `json:"method"`
+	Route            string    `json:"route"`
+	Protocol         string    `json:"protocol"`
+	Model            string    `json:"model,omitempty"`
+	Status           int       `json:"status"`
+	Bytes            int64     `json:"bytes"`
+	DurationMS       float64   `json:"duration_ms"`
+	PromptTokens     *int64    `json:"prompt_tokens,omitempty"`
+	CompletionTokens *int64    `json:"completion_tokens,omitempty"`
+}
+
+// Logger writes access log entries.
+type Logger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format Format
+}
+
+// NewLogger creates a new access logger writing entries to w.
+func NewLogger(w io.Writer, format Format) *Logger {
+	return &Logger{w: w, format: format}
+}
+
+// Handler wraps next so that every request it serves is logged.
+func (l *Logger) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r = middleware.Annotate(r)
+		rw := middleware.NewUsageRecorder(w)
+		next.ServeHTTP(rw, r)
+
+		entry := Entry{
+			Time:       start,
+			RemoteAddr: remoteHost(r.RemoteAddr),
+			Method:     r.Method,
+			Route:      r.URL.RequestURI(),
+			Protocol:   r.Proto,
+			Model:      middleware.Model(r.Context()),
+			Status:     rw.Status(),
+			Bytes:      rw.Bytes(),
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		entry.PromptTokens, entry.CompletionTokens = rw.Usage()
+		l.Log(entry)
+	})
+}
+
+// Log writes a single entry. Write errors are ignored since access logging
+// must never interfere with serving requests.
+func (l *Logger) Log(entry Entry) {
+	var line []byte
+	if l.format == FormatJSON {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		line = append(data, '\n')
+	} else {
+		line = []byte(formatCommon(entry))
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(line)
+}
+
+// formatCommon formats an entry in Common Log Format followed by the model,
+// the duration in milliseconds, and the prompt and completion token counts.
+func formatCommon(e Entry) string {
+	return fmt.Sprintf("%s - - [%s] %q %d %s %q %.3f %s %s\n",
+		dashIfEmpty(e.RemoteAddr),
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method+" "+e.Route+" "+e.Protocol,
+		e.Status,
+		dashIfZero(e.Bytes),
+		dashIfEmpty(e.Model),
+		e.DurationMS,
+		dashIfNil(e.PromptTokens),
+		dashIfNil(e.CompletionTokens),
+	)
+}
+
+func remoteHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	if addr == "@" {
+		// Unix socket peers have no address.
+		return ""
+	}
+	return addr
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func dashIfZero(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func dashIfNil(n *int64) string {
+	if n == nil {
+		return "-"
+	}
+	return strconv.FormatInt(*n, 10)
+}
@@ -0,0 +1,135 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/middleware"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   Format
+		body     string
+		status   int
+		contains []string
+	}{
+		{
+			name:     "common with usage",
+			format:   FormatCommon,
+			body:     `{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":34,"total_tokens":46}}`,
+			status:   http.StatusOK,
+			contains: []string{`"POST /engines/v1/chat/completions HTTP/1.1" 200`, `"ai/smollm2"`, ` 12 34`},
+		},
+		{
+			name:     "common streaming usage",
+			format:   FormatCommon,
+			body:     "data: {\"choices\":[]}\n\ndata: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":6}}\n\ndata: [DONE]\n\n",
+			status:   http.StatusOK,
+			contains: []string{` 5 6`},
+		},
+		{
+			name:     "common error without usage",
+			format:   FormatCommon,
+			body:     "not found\n",
+			status:   http.StatusNotFound,
+			contains: []string{`HTTP/1.1" 404 10 "ai/smollm2"`, ` - -`},
+		},
+		{
+			name:     "json",
+			format:   FormatJSON,
+			body:     `{"usage":{"prompt_tokens":1,"completion_tokens":2}}`,
+			status:   http.StatusOK,
+			contains: []string{`"model":"ai/smollm2"`, `"status":200`, `"prompt_tokens":1`, `"completion_tokens":2`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := NewLogger(&out, tt.format)
+			handler := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				middleware.SetModel(r.Context(), "ai/smollm2")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/engines/v1/chat/completions", nil)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			line := out.String()
+			if !strings.HasSuffix(line, "\n") {
+				t.Fatalf("expected a newline-terminated entry, got %q", line)
+			}
+			if tt.format == FormatJSON && !json.Valid([]byte(line)) {
+				t.Fatalf("expected valid JSON, got %q", line)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(line, s) {
+					t.Errorf("expected %q in %q", s, line)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatCommon(t *testing.T) {
+	prompt, completion := int64(3), int64(4)
+	entry := Entry{
+		Time:             time.Date(2024, time.March, 5, 10, 11, 12, 0, time.UTC),
+		RemoteAddr:       "127.0.0.1",
+		Method:           http.MethodGet,
+		Route:            "/models",
+		Protocol:         "HTTP/1.1",
+		Status:           http.StatusOK,
+		Bytes:            42,
+		DurationMS:       1.5,
+		PromptTokens:     &prompt,
+		CompletionTokens: &completion,
+	}
+	expected := `127.0.0.1 - - [05/Mar/2024:10:11:12 +0000] "GET /models HTTP/1.1" 200 42 "-" 1.500 3 4` + "\n"
+	if got := formatCommon(entry); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("failed to open rotating file: %v", err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+	}
+
+	expected := map[string]string{
+		path:        "dddddddd\n",
+		path + ".1": "cccccccc\n",
+		path + ".2": "bbbbbbbb\n",
+	}
+	for p, content := range expected {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", p, err)
+		}
+		if string(data) != content {
+			t.Errorf("expected %q in %s, got %q", content, p, data)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups to be retained")
+	}
+}
@@ -0,0 +1,39 @@
+package accesslog
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// syslogScheme prefixes access log targets that ship entries to syslog.
+const syslogScheme = "syslog"
+
+// Open opens the destination for access log entries. The target is one of:
+//   - "-" or "stdout" for standard output,
+//   - "syslog" for the local syslog daemon,
+//   - "syslog+udp://host:port" or "syslog+tcp://host:port" for a remote
+//     syslog daemon,
+//   - otherwise a file path, which is rotated once it exceeds maxSize bytes
+//     with up to maxBackups rotated files retained.
+func Open(target string, maxSize int64, maxBackups int) (io.WriteCloser, error) {
+	switch {
+	case target == "-" || target == "stdout":
+		return nopCloser{os.Stdout}, nil
+	case target == syslogScheme:
+		return NewSyslogWriter("", "")
+	case strings.HasPrefix(target, syslogScheme+"+"):
+		network, address, _ := strings.Cut(strings.TrimPrefix(target, syslogScheme+"+"), "://")
+		return NewSyslogWriter(network, address)
+	default:
+		return NewRotatingFile(target, maxSize, maxBackups)
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,222 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/middleware"
+)
+
+// PayloadEntry is a sampled request and response payload.
+type PayloadEntry struct {
+	Time              time.Time `json:"time"`
+	Method            string    `json:"method"`
+	Route             string    `json:"route"`
+	Model             string    `json:"model"`
+	Status            int       `json:"status"`
+	Request           string    `json:"request"`
+	RequestTruncated  bool      `json:"request_truncated,omitempty"`
+	Response          string    `json:"response"`
+	ResponseTruncated bool      `json:"response_truncated,omitempty"`
+}
+
+// ParseSampleRates parses per-model payload sampling rates in the form
+// "model=rate,model=rate", where each rate is between 0 and 1.
+func ParseSampleRates(s string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		model, value, ok := strings.Cut(pair, "=")
+		if !ok || model == "" {
+			return nil, fmt.Errorf("invalid sample rate %q (expected model=rate)", pair)
+		}
+		rate, err := ParseSampleRate(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sample rate for model %q: %w", model, err)
+		}
+		rates[strings.TrimSpace(model)] = rate
+	}
+	return rates, nil
+}
+
+// ParseSampleRate parses a sampling rate between 0 and 1.
+func ParseSampleRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	if rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("sample rate %v is not between 0 and 1", rate)
+	}
+	return rate, nil
+}
+
+// PayloadLogger logs the request and response payloads of a sample of
+// inference requests. Payloads are truncated to a maximum size so that
+// sampling can be left enabled in production.
+type PayloadLogger struct {
+	mu          sync.Mutex
+	w           io.Writer
+	defaultRate float64
+	rates       map[string]float64
+	maxSize     int
+	random      func() float64
+}
+
+// NewPayloadLogger creates a new payload logger writing entries to w as JSON.
+// Requests for a model listed in rates are sampled at that rate, and all
+// other requests at defaultRate. Request and response payloads are each
+// truncated to maxSize bytes.
+func NewPayloadLogger(w io.Writer, defaultRate float64, rates map[string]float64, maxSize int) *PayloadLogger {
+	return &PayloadLogger{
+		w:           w,
+		defaultRate: defaultRate,
+		rates:       rates,
+		maxSize:     maxSize,
+		random:      rand.Float64,
+	}
+}
+
+// rate returns the sampling rate for model. Models can be configured with or
+// without the default "latest" tag.
+func (p *PayloadLogger) rate(model string) float64 {
+	if rate, ok := p.rates[model]; ok {
+		return rate
+	}
+	if rate, ok := p.rates[strings.TrimSuffix(model, ":latest")]; ok {
+		return rate
+	}
+	return p.defaultRate
+}
+
+// sample returns true if the payloads of a request for model should be
+// logged.
+func (p *PayloadLogger) sample(model string) bool {
+	rate := p.rate(model)
+	if rate <= 0 {
+		return false
+	}
+	return rate >= 1 || p.random() < rate
+}
+
+// Handler wraps next so that the payloads of a sample of the inference
+// requests it serves are logged. Requests that don't target a model, as
+// recorded by middleware.SetModel, are never logged.
+func (p *PayloadLogger) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r = middleware.Annotate(r)
+		request := &cappedBuffer{max: p.maxSize}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &teeReadCloser{ReadCloser: r.Body, w: request}
+		}
+		rw := &payloadWriter{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+			body:           cappedBuffer{max: p.maxSize},
+		}
+		next.ServeHTTP(rw, r)
+
+		model := middleware.Model(r.Context())
+		if model == "" || !p.sample(model) {
+			return
+		}
+		p.Log(PayloadEntry{
+			Time:              start,
+			Method:            r.Method,
+			Route:             r.URL.RequestURI(),
+			Model:             model,
+			Status:            rw.status,
+			Request:           string(request.data),
+			RequestTruncated:  request.truncated,
+			Response:          string(rw.body.data),
+			ResponseTruncated: rw.body.truncated,
+		})
+	})
+}
+
+// Log writes a single entry. Write errors are ignored since payload logging
+// must never interfere with serving requests.
+func (p *PayloadLogger) Log(entry PayloadEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, _ = p.w.Write(append(data, '\n'))
+}
+
+// cappedBuffer retains up to max bytes of the data written to it.
+type cappedBuffer struct {
+	max       int
+	data      []byte
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.max - len(b.data); remaining < len(p) {
+		b.data = append(b.data, p[:max(remaining, 0)]...)
+		b.truncated = true
+	} else {
+		b.data = append(b.data, p...)
+	}
+	return len(p), nil
+}
+
+// teeReadCloser copies the data read from a request body to w.
+type teeReadCloser struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		_, _ = t.w.Write(p[:n])
+	}
+	return n, err
+}
+
+// payloadWriter records the status and the head of a response.
+type payloadWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        cappedBuffer
+}
+
+func (pw *payloadWriter) WriteHeader(statusCode int) {
+	if !pw.wroteHeader {
+		pw.status = statusCode
+		pw.wroteHeader = true
+	}
+	pw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (pw *payloadWriter) Write(b []byte) (int, error) {
+	pw.wroteHeader = true
+	n, err := pw.ResponseWriter.Write(b)
+	_, _ = pw.body.Write(b[:n])
+	return n, err
+}
+
+func (pw *payloadWriter) Flush() {
+	if flusher, ok := pw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (pw *payloadWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
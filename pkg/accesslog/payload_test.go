@@ -0,0 +1,145 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/middleware"
+)
+
+func TestParseSampleRates(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]float64
+		wantErr  bool
+	}{
+		{
+			name:     "empty",
+			input:    "",
+			expected: map[string]float64{},
+		},
+		{
+			name:     "multiple models",
+			input:    "ai/smollm2=0.01, ai/qwen3:8B=1",
+			expected: map[string]float64{"ai/smollm2": 0.01, "ai/qwen3:8B": 1},
+		},
+		{
+			name:    "missing rate",
+			input:   "ai/smollm2",
+			wantErr: true,
+		},
+		{
+			name:    "rate out of range",
+			input:   "ai/smollm2=2",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates, err := ParseSampleRates(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", rates)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(rates) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, rates)
+			}
+			for model, rate := range tt.expected {
+				if rates[model] != rate {
+					t.Errorf("expected rate %v for %s, got %v", rate, model, rates[model])
+				}
+			}
+		})
+	}
+}
+
+func TestPayloadLoggerHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		model       string
+		defaultRate float64
+		rates       map[string]float64
+		random      float64
+		logged      bool
+	}{
+		{
+			name:   "sampled by model rate",
+			model:  "ai/smollm2",
+			rates:  map[string]float64{"ai/smollm2": 0.01},
+			random: 0.005,
+			logged: true,
+		},
+		{
+			name:   "not sampled by model rate",
+			model:  "ai/smollm2",
+			rates:  map[string]float64{"ai/smollm2": 0.01},
+			random: 0.5,
+		},
+		{
+			name:   "model rate without latest tag",
+			model:  "ai/smollm2:latest",
+			rates:  map[string]float64{"ai/smollm2": 1},
+			logged: true,
+		},
+		{
+			name:        "default rate",
+			model:       "ai/qwen3",
+			defaultRate: 0.1,
+			rates:       map[string]float64{"ai/smollm2": 1},
+			random:      0.05,
+			logged:      true,
+		},
+		{
+			name:        "no model",
+			defaultRate: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := NewPayloadLogger(&out, tt.defaultRate, tt.rates, 16)
+			logger.random = func() float64 { return tt.random }
+			handler := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.ReadAll(r.Body)
+				middleware.SetModel(r.Context(), tt.model)
+				w.Write([]byte(`{"choices":[{"text":"hello world"}]}`))
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/engines/v1/completions", strings.NewReader(`{"prompt":"hi"}`))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !tt.logged {
+				if out.Len() != 0 {
+					t.Fatalf("expected no entry, got %q", out.String())
+				}
+				return
+			}
+			var entry PayloadEntry
+			if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+				t.Fatalf("expected a JSON entry, got %q: %v", out.String(), err)
+			}
+			if entry.Model != tt.model || entry.Status != http.StatusOK {
+				t.Errorf("unexpected entry %+v", entry)
+			}
+			if entry.Request != `{"prompt":"hi"}` || entry.RequestTruncated {
+				t.Errorf("expected the full request, got %q (truncated=%v)", entry.Request, entry.RequestTruncated)
+			}
+			if entry.Response != `{"choices":[{"te` || !entry.ResponseTruncated {
+				t.Errorf("expected a truncated response, got %q (truncated=%v)", entry.Response, entry.ResponseTruncated)
+			}
+		})
+	}
+}
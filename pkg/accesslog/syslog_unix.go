@@ -0,0 +1,19 @@
+//go:build !windows
+
+package accesslog
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+)
+
+// NewSyslogWriter connects to the syslog daemon at address over network
+// (e.g. udp or tcp). If network is empty, the local daemon is used.
+func NewSyslogWriter(network, address string) (io.WriteCloser, error) {
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, "model-runner")
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog: %w", err)
+	}
+	return w, nil
+}
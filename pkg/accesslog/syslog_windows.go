@@ -0,0 +1,13 @@
+//go:build windows
+
+package accesslog
+
+import (
+	"errors"
+	"io"
+)
+
+// NewSyslogWriter is not supported on Windows.
+func NewSyslogWriter(network, address string) (io.WriteCloser, error) {
+	return nil, errors.New("syslog is not supported on Windows")
+}
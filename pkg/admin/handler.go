@@ -0,0 +1,315 @@
+// Package admin serves the admin API, which controls the lifecycle of the
+// backend processes serving models without restarting the model runner:
+// listing them, loading and unloading models, restarting wedged processes,
+// and viewing their recent error output. It also manages the API keys that
+// requests are authenticated with, if they're configured. Its routes require
+// the admin token.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/apikeys"
+	"github.com/docker/model-runner/pkg/distribution/distribution"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/scheduling"
+	"github.com/docker/model-runner/pkg/internal/utils"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// Prefix is the prefix of the admin routes.
+const Prefix = "/admin"
+
+// maximumRequestSize is the maximum size of a request body.
+const maximumRequestSize = 1024 * 1024
+
+// Scheduler controls the backend processes serving models.
+type Scheduler interface {
+	// Instances returns the backend processes of the loaded runners.
+	Instances(ctx context.Context) []scheduling.Instance
+	// LoadInstance loads a model and returns the instance serving it.
+	LoadInstance(ctx context.Context, backendName, model string, mode *inference.BackendMode) (scheduling.Instance, error)
+	// Unload unloads runners and returns the number of unloaded runners.
+	Unload(ctx context.Context, unload scheduling.UnloadRequest) int
+	// RestartInstance restarts the backend process of an instance and
+	// returns the instance that replaced it.
+	RestartInstance(ctx context.Context, id int) (scheduling.Instance, error)
+	// InstanceOutput returns the recent error output of an instance.
+	InstanceOutput(ctx context.Context, id int) ([]byte, error)
+}
+
+// Keys manages the API keys that requests are authenticated with.
+type Keys interface {
+	// List returns the keys, without their secrets.
+	List() []apikeys.Key
+	// Create creates a key and returns it with its secret.
+	Create(request apikeys.CreateRequest) (apikeys.Key, error)
+	// Revoke revokes the key with the specified ID.
+	Revoke(id string) error
+}
+
+// LoadRequest is the request to load a model.
+type LoadRequest struct {
+	// Backend is the backend to load the model with. If empty, the backend
+	// is selected for the model.
+	Backend string `json:"backend,omitempty"`
+	// Model is the model reference.
+	Model string `json:"model"`
+	// Mode is the mode to load the model in. If empty, it's the mode implied
+	// by the model.
+	Mode string `json:"mode,omitempty"`
+}
+
+// UnloadRequest is the request to unload a model.
+type UnloadRequest struct {
+	// Backend is the backend to unload the model from. If empty, the model is
+	// unloaded from every backend.
+	Backend string `json:"backend,omitempty"`
+	// Model is the model reference.
+	Model string `json:"model"`
+}
+
+// HTTPHandler serves the admin API.
+type HTTPHandler struct {
+	// log is the associated logger.
+	log logging.Logger
+	// router is the HTTP request router.
+	router *http.ServeMux
+	// scheduler is the scheduler controlling the backend processes.
+	scheduler Scheduler
+	// keys manages the API keys, if they're configured.
+	keys Keys
+	// token is the bearer token that requests must be authorized with.
+	token string
+}
+
+// NewHTTPHandler creates a new admin API handler that controls the backend
+// processes of scheduler and manages keys, which may be nil if API keys
+// aren't configured, serving requests authorized with token.
+func NewHTTPHandler(log logging.Logger, scheduler Scheduler, keys Keys, token string) *HTTPHandler {
+	h := &HTTPHandler{
+		log:       log,
+		router:    http.NewServeMux(),
+		scheduler: scheduler,
+		keys:      keys,
+		token:     token,
+	}
+	h.router.HandleFunc("GET "+Prefix+"/instances", h.handleList)
+	h.router.HandleFunc("POST "+Prefix+"/load", h.handleLoad)
+	h.router.HandleFunc("POST "+Prefix+"/unload", h.handleUnload)
+	h.router.HandleFunc("POST "+Prefix+"/instances/{id}/restart", h.handleRestart)
+	h.router.HandleFunc("GET "+Prefix+"/instances/{id}/logs", h.handleLogs)
+	if keys != nil {
+		h.router.HandleFunc("GET "+Prefix+"/keys", h.handleListKeys)
+		h.router.HandleFunc("POST "+Prefix+"/keys", h.handleCreateKey)
+		h.router.HandleFunc("DELETE "+Prefix+"/keys/{id}", h.handleRevokeKey)
+	}
+	return h
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.router.ServeHTTP(w, r)
+}
+
+// authorized returns whether a request is authorized with the admin token.
+func (h *HTTPHandler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && h.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// handleList handles GET /admin/instances requests, which list the backend
+// processes of the loaded runners.
+func (h *HTTPHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.scheduler.Instances(r.Context()))
+}
+
+// handleLoad handles POST /admin/load requests, which load a model and
+// respond with the instance serving it.
+func (h *HTTPHandler) handleLoad(w http.ResponseWriter, r *http.Request) {
+	var request LoadRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	if request.Model == "" {
+		http.Error(w, "model is required", http.StatusBadRequest)
+		return
+	}
+	var mode *inference.BackendMode
+	if request.Mode != "" {
+		parsed, err := inference.ParseBackendMode(request.Mode)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mode = &parsed
+	}
+
+	instance, err := h.scheduler.LoadInstance(r.Context(), request.Backend, request.Model, mode)
+	if err != nil {
+		h.log.Warnf("Failed to load %s: %v", utils.SanitizeForLog(request.Model, -1), err)
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, instance)
+}
+
+// handleUnload handles POST /admin/unload requests, which unload a model
+// from a backend unless it's serving requests.
+func (h *HTTPHandler) handleUnload(w http.ResponseWriter, r *http.Request) {
+	var request UnloadRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	if request.Model == "" {
+		http.Error(w, "model is required", http.StatusBadRequest)
+		return
+	}
+	unloaded := h.scheduler.Unload(r.Context(), scheduling.UnloadRequest{
+		Backend: request.Backend,
+		Models:  []string{request.Model},
+	})
+	writeJSON(w, http.StatusOK, scheduling.UnloadResponse{UnloadedRunners: unloaded})
+}
+
+// handleRestart handles POST /admin/instances/{id}/restart requests, which
+// restart the backend process of an instance and respond with the instance
+// that replaced it.
+func (h *HTTPHandler) handleRestart(w http.ResponseWriter, r *http.Request) {
+	id, ok := instanceID(w, r)
+	if !ok {
+		return
+	}
+	instance, err := h.scheduler.RestartInstance(r.Context(), id)
+	if err != nil {
+		h.log.Warnf("Failed to restart instance %d: %v", id, err)
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, instance)
+}
+
+// handleLogs handles GET /admin/instances/{id}/logs requests, which respond
+// with the recent error output of an instance's backend process.
+func (h *HTTPHandler) handleLogs(w http.ResponseWriter, r *http.Request) {
+	id, ok := instanceID(w, r)
+	if !ok {
+		return
+	}
+	output, err := h.scheduler.InstanceOutput(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(output)
+}
+
+// handleListKeys handles GET /admin/keys requests, which list the API keys
+// without their secrets.
+func (h *HTTPHandler) handleListKeys(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.keys.List())
+}
+
+// handleCreateKey handles POST /admin/keys requests, which create an API key
+// and respond with it and its secret, which can't be retrieved later.
+func (h *HTTPHandler) handleCreateKey(w http.ResponseWriter, r *http.Request) {
+	var request apikeys.CreateRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	if request.RequestsPerMinute < 0 {
+		http.Error(w, "requests_per_minute can't be negative", http.StatusBadRequest)
+		return
+	}
+	key, err := h.keys.Create(request)
+	if err != nil {
+		h.log.Warnf("Failed to create API key: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.log.Infof("Created API key %s (%s)", key.ID, utils.SanitizeForLog(key.Name, -1))
+	writeJSON(w, http.StatusCreated, key)
+}
+
+// handleRevokeKey handles DELETE /admin/keys/{id} requests, which revoke an
+// API key.
+func (h *HTTPHandler) handleRevokeKey(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := h.keys.Revoke(id); err != nil {
+		if errors.Is(err, apikeys.ErrKeyNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		h.log.Warnf("Failed to revoke API key %s: %v", utils.SanitizeForLog(id, -1), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.log.Infof("Revoked API key %s", utils.SanitizeForLog(id, -1))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// instanceID returns the instance ID of a request's path, or responds with an
+// error if it's invalid.
+func instanceID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 0 {
+		http.Error(w, "invalid instance ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeRequest decodes the JSON body of a request, or responds with an error
+// if it's invalid.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maximumRequestSize))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(w, "request too large", http.StatusBadRequest)
+		} else {
+			http.Error(w, "failed to read request body", http.StatusInternalServerError)
+		}
+		return false
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeError responds with an error of the scheduler.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, scheduling.ErrInstanceNotFound),
+		errors.Is(err, scheduling.ErrBackendNotFound),
+		errors.Is(err, distribution.ErrModelNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// writeJSON responds with a value encoded as JSON.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
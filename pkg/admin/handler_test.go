@@ -0,0 +1,181 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/apikeys"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/scheduling"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeScheduler serves a single instance of a model, recording the requests
+// it receives.
+type fakeScheduler struct {
+	instance scheduling.Instance
+	mode     *inference.BackendMode
+	unload   scheduling.UnloadRequest
+	restarts int
+}
+
+func (f *fakeScheduler) Instances(context.Context) []scheduling.Instance {
+	return []scheduling.Instance{f.instance}
+}
+
+func (f *fakeScheduler) LoadInstance(_ context.Context, backendName, model string, mode *inference.BackendMode) (scheduling.Instance, error) {
+	if backendName != "" && backendName != f.instance.BackendName {
+		return scheduling.Instance{}, scheduling.ErrBackendNotFound
+	}
+	f.mode = mode
+	return f.instance, nil
+}
+
+func (f *fakeScheduler) Unload(_ context.Context, unload scheduling.UnloadRequest) int {
+	f.unload = unload
+	return 1
+}
+
+func (f *fakeScheduler) RestartInstance(_ context.Context, id int) (scheduling.Instance, error) {
+	if id != f.instance.ID {
+		return scheduling.Instance{}, scheduling.ErrInstanceNotFound
+	}
+	f.restarts++
+	return f.instance, nil
+}
+
+func (f *fakeScheduler) InstanceOutput(_ context.Context, id int) ([]byte, error) {
+	if id != f.instance.ID {
+		return nil, scheduling.ErrInstanceNotFound
+	}
+	return []byte("loading model\n"), nil
+}
+
+// serve serves a request with the admin token.
+func serve(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func newFakeScheduler() *fakeScheduler {
+	return &fakeScheduler{instance: scheduling.Instance{ID: 2, BackendName: "llama.cpp", ModelName: "ai/smollm2", Mode: "completion", Running: true}}
+}
+
+func TestAuthorization(t *testing.T) {
+	h := NewHTTPHandler(logrus.New(), newFakeScheduler(), nil, "secret")
+	for _, authorization := range []string{"", "Bearer wrong", "secret", "Bearer "} {
+		r := httptest.NewRequest(http.MethodGet, Prefix+"/instances", nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected %q to be unauthorized, got %d", authorization, w.Code)
+		}
+	}
+	if w := serve(h, http.MethodGet, Prefix+"/instances", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected the token to be authorized, got %d", w.Code)
+	}
+}
+
+func TestInstances(t *testing.T) {
+	scheduler := newFakeScheduler()
+	h := NewHTTPHandler(logrus.New(), scheduler, nil, "secret")
+
+	w := serve(h, http.MethodGet, Prefix+"/instances", "")
+	var instances []scheduling.Instance
+	if err := json.Unmarshal(w.Body.Bytes(), &instances); err != nil || len(instances) != 1 || instances[0].ID != 2 {
+		t.Errorf("Unexpected instances %s", w.Body)
+	}
+
+	if w := serve(h, http.MethodPost, Prefix+"/instances/2/restart", ""); w.Code != http.StatusOK || scheduler.restarts != 1 {
+		t.Errorf("Expected the instance to be restarted, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve(h, http.MethodPost, Prefix+"/instances/3/restart", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown instances not to be found, got %d", w.Code)
+	}
+	if w := serve(h, http.MethodPost, Prefix+"/instances/x/restart", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid IDs to be rejected, got %d", w.Code)
+	}
+
+	w = serve(h, http.MethodGet, Prefix+"/instances/2/logs", "")
+	if w.Body.String() != "loading model\n" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Expected the instance's output, got %q", w.Body)
+	}
+}
+
+func TestLoadAndUnload(t *testing.T) {
+	scheduler := newFakeScheduler()
+	h := NewHTTPHandler(logrus.New(), scheduler, nil, "secret")
+
+	w := serve(h, http.MethodPost, Prefix+"/load", `{"backend":"llama.cpp","model":"ai/smollm2","mode":"embedding"}`)
+	if w.Code != http.StatusOK || scheduler.mode == nil || *scheduler.mode != inference.BackendModeEmbedding {
+		t.Errorf("Expected the model to be loaded in embedding mode, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve(h, http.MethodPost, Prefix+"/load", `{"model":"ai/smollm2"}`); w.Code != http.StatusOK || scheduler.mode != nil {
+		t.Errorf("Expected the model to be loaded in its default mode, got %d: %s", w.Code, w.Body)
+	}
+	for body, status := range map[string]int{
+		`{"model":"ai/smollm2","mode":"chat"}`:    http.StatusBadRequest,
+		`{"backend":"vllm","model":"ai/smollm2"}`: http.StatusNotFound,
+		`{}`:       http.StatusBadRequest,
+		`not json`: http.StatusBadRequest,
+	} {
+		if w := serve(h, http.MethodPost, Prefix+"/load", body); w.Code != status {
+			t.Errorf("Expected status %d for %s, got %d", status, body, w.Code)
+		}
+	}
+
+	w = serve(h, http.MethodPost, Prefix+"/unload", `{"backend":"llama.cpp","model":"ai/smollm2"}`)
+	var response scheduling.UnloadResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.UnloadedRunners != 1 {
+		t.Errorf("Unexpected unload response %s", w.Body)
+	}
+	if scheduler.unload.Backend != "llama.cpp" || len(scheduler.unload.Models) != 1 || scheduler.unload.Models[0] != "ai/smollm2" {
+		t.Errorf("Unexpected unload request %+v", scheduler.unload)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	store, err := apikeys.NewStore(logrus.New(), filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	h := NewHTTPHandler(logrus.New(), newFakeScheduler(), store, "secret")
+
+	w := serve(h, http.MethodPost, Prefix+"/keys", `{"name":"alice","models":["ai/smollm2"],"requests_per_minute":60}`)
+	var key apikeys.Key
+	if err := json.Unmarshal(w.Body.Bytes(), &key); w.Code != http.StatusCreated || err != nil || key.Key == "" || key.ID == "" {
+		t.Fatalf("Expected the key to be created with its secret, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve(h, http.MethodPost, Prefix+"/keys", `{"requests_per_minute":-1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected negative rate limits to be rejected, got %d", w.Code)
+	}
+
+	w = serve(h, http.MethodGet, Prefix+"/keys", "")
+	var keys []apikeys.Key
+	if err := json.Unmarshal(w.Body.Bytes(), &keys); err != nil || len(keys) != 1 || keys[0].ID != key.ID || keys[0].Key != "" {
+		t.Errorf("Expected the key to be listed without its secret, got %s", w.Body)
+	}
+
+	if w := serve(h, http.MethodDelete, Prefix+"/keys/"+key.ID, ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected the key to be revoked, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve(h, http.MethodDelete, Prefix+"/keys/"+key.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the revoked key not to be found, got %d", w.Code)
+	}
+
+	// Key routes aren't served unless keys are configured.
+	if w := serve(NewHTTPHandler(logrus.New(), newFakeScheduler(), nil, "secret"), http.MethodGet, Prefix+"/keys", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected key routes not to be served, got %d", w.Code)
+	}
+}
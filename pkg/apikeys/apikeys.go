@@ -0,0 +1,347 @@
+// Package apikeys manages the API keys that requests to the model runner are
+// authenticated with, so that it can be shared by a team: each key may be
+// restricted to a list of models and to a number of requests per minute, and
+// only keys allowed to manage the model runner can use routes other than
+// inference. Keys are stored in a JSON file, which is reloaded when it
+// changes, and can be created and revoked at runtime.
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// ErrKeyNotFound indicates that an unknown key was requested. If returned in
+// conjunction with an HTTP request, it should be paired with a 404 response
+// status.
+var ErrKeyNotFound = errors.New("API key not found")
+
+// Key is an API key. Keys are identified by the first 16 hexadecimal digits of
+// the SHA-256 hash of their secret, which is how usage metering identifies
+// them too.
+type Key struct {
+	// ID identifies the key. It's derived from the key's secret.
+	ID string `json:"id,omitempty"`
+	// Name describes the key, such as the person or service using it.
+	Name string `json:"name,omitempty"`
+	// Key is the key's secret. Keys created at runtime only store its hash.
+	Key string `json:"key,omitempty"`
+	// Hash is the hexadecimal SHA-256 hash of the key's secret, used if the
+	// secret isn't stored.
+	Hash string `json:"hash,omitempty"`
+	// Models are the models that the key can use for inference. If empty,
+	// all models can be used.
+	Models []string `json:"models,omitempty"`
+	// RequestsPerMinute limits the rate of the key's requests. If zero, the
+	// rate isn't limited.
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	// Manage allows the key to use the routes that manage the model runner,
+	// such as pulling models or creating batches, besides inference.
+	Manage bool `json:"manage,omitempty"`
+	// Created is the time at which the key was created at runtime.
+	Created *time.Time `json:"created,omitempty"`
+}
+
+// Config is the content of a keys file.
+type Config struct {
+	// Keys are the configured keys.
+	Keys []Key `json:"keys"`
+}
+
+// hashSecret returns the hexadecimal SHA-256 hash of a key's secret.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Parse parses and validates the content of a keys file, deriving the hash
+// and ID of each key.
+func Parse(data []byte) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid keys file: %w", err)
+	}
+	seen := make(map[string]bool, len(config.Keys))
+	for i := range config.Keys {
+		key := &config.Keys[i]
+		switch {
+		case key.Key != "":
+			key.Hash = hashSecret(key.Key)
+		case len(key.Hash) == sha256.Size*2:
+			if _, err := hex.DecodeString(key.Hash); err != nil {
+				return nil, fmt.Errorf("key %d: invalid hash", i)
+			}
+		default:
+			return nil, fmt.Errorf("key %d: key or hash is required", i)
+		}
+		key.ID = key.Hash[:16]
+		if seen[key.ID] {
+			return nil, fmt.Errorf("key %d: duplicate key", i)
+		}
+		seen[key.ID] = true
+		if key.RequestsPerMinute < 0 {
+			return nil, fmt.Errorf("key %d: invalid requests per minute %d", i, key.RequestsPerMinute)
+		}
+	}
+	return &config, nil
+}
+
+// CreateRequest is the request to create a key.
+type CreateRequest struct {
+	// Name describes the key.
+	Name string `json:"name,omitempty"`
+	// Models are the models that the key can use. If empty, all models can
+	// be used.
+	Models []string `json:"models,omitempty"`
+	// RequestsPerMinute limits the rate of the key's requests.
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	// Manage allows the key to manage the model runner.
+	Manage bool `json:"manage,omitempty"`
+}
+
+// Store holds the API keys of a keys file. The file is reloaded when it
+// changes, so that keys can be added, reconfigured, or revoked by editing it.
+type Store struct {
+	// log is the associated logger.
+	log logging.Logger
+	// path is the path to the keys file.
+	path string
+	// lock guards the fields below.
+	lock sync.Mutex
+	// modTime is the modification time of the loaded keys file.
+	modTime time.Time
+	// keys are the loaded keys, in file order.
+	keys []Key
+	// byHash indexes keys by hash.
+	byHash map[string]int
+	// limiters are the rate limiters of keys, indexed by ID.
+	limiters map[string]*limiter
+}
+
+// NewStore creates a store for the keys file at path. The file is created
+// when a key is first created if it doesn't exist, and until then there are
+// no keys.
+func NewStore(log logging.Logger, path string) (*Store, error) {
+	s := &Store{log: log, path: path, limiters: make(map[string]*limiter)}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload loads the keys file if it changed since it was last loaded. The
+// caller must hold the lock, unless the store isn't shared yet.
+func (s *Store) reload() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.set(nil, time.Time{})
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if s.byHash != nil && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	config, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	s.set(config.Keys, info.ModTime())
+	return nil
+}
+
+// set replaces the loaded keys, keeping the rate limiters of those that
+// remain. The caller must hold the lock.
+func (s *Store) set(keys []Key, modTime time.Time) {
+	s.keys = keys
+	s.modTime = modTime
+	s.byHash = make(map[string]int, len(keys))
+	ids := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		s.byHash[key.Hash] = i
+		ids[key.ID] = true
+	}
+	for id := range s.limiters {
+		if !ids[id] {
+			delete(s.limiters, id)
+		}
+	}
+}
+
+// refresh reloads the keys file if it changed, keeping the loaded keys if it
+// can't be loaded. The caller must hold the lock.
+func (s *Store) refresh() {
+	if err := s.reload(); err != nil {
+		s.log.Warnf("Failed to reload API keys from %s: %v", s.path, err)
+	}
+}
+
+// save writes the keys to the keys file. The caller must hold the lock.
+func (s *Store) save(keys []Key) error {
+	stored := make([]Key, len(keys))
+	for i, key := range keys {
+		// IDs are derived from hashes, which are derived from secrets if
+		// they're stored.
+		key.ID = ""
+		if key.Key != "" {
+			key.Hash = ""
+		}
+		stored[i] = key
+	}
+	data, err := json.MarshalIndent(Config{Keys: stored}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tempPath := s.path + ".tmp"
+	if err := os.WriteFile(tempPath, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, s.path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.set(keys, info.ModTime())
+	return nil
+}
+
+// redact returns a key without its secret.
+func redact(key Key) Key {
+	key.Key = ""
+	key.Hash = ""
+	return key
+}
+
+// List returns the keys, without their secrets.
+func (s *Store) List() []Key {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.refresh()
+	keys := make([]Key, len(s.keys))
+	for i, key := range s.keys {
+		keys[i] = redact(key)
+	}
+	return keys
+}
+
+// Create creates a key and returns it with its secret, which isn't stored and
+// can't be retrieved later.
+func (s *Store) Create(request CreateRequest) (Key, error) {
+	if request.RequestsPerMinute < 0 {
+		return Key{}, fmt.Errorf("invalid requests per minute %d", request.RequestsPerMinute)
+	}
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	created := time.Now().UTC().Truncate(time.Second)
+	key := Key{
+		Name:              request.Name,
+		Key:               "dmr-" + hex.EncodeToString(secret),
+		Models:            request.Models,
+		RequestsPerMinute: request.RequestsPerMinute,
+		Manage:            request.Manage,
+		Created:           &created,
+	}
+	key.Hash = hashSecret(key.Key)
+	key.ID = key.Hash[:16]
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.refresh()
+	stored := key
+	stored.Key = ""
+	if err := s.save(append(slices.Clone(s.keys), stored)); err != nil {
+		return Key{}, fmt.Errorf("failed to save API keys: %w", err)
+	}
+	key.Hash = ""
+	return key, nil
+}
+
+// Revoke revokes the key with the specified ID.
+func (s *Store) Revoke(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.refresh()
+	i := slices.IndexFunc(s.keys, func(key Key) bool { return key.ID == id })
+	if i < 0 {
+		return ErrKeyNotFound
+	}
+	if err := s.save(slices.Delete(slices.Clone(s.keys), i, i+1)); err != nil {
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+	return nil
+}
+
+// authenticate returns the key whose secret is specified, and the rate
+// limiter of keys whose rate is limited.
+func (s *Store) authenticate(secret string) (Key, *limiter, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.refresh()
+	i, ok := s.byHash[hashSecret(secret)]
+	if !ok {
+		return Key{}, nil, false
+	}
+	key := s.keys[i]
+	if key.RequestsPerMinute == 0 {
+		return key, nil, true
+	}
+	l := s.limiters[key.ID]
+	if l == nil || l.perMinute != key.RequestsPerMinute {
+		l = &limiter{perMinute: key.RequestsPerMinute}
+		s.limiters[key.ID] = l
+	}
+	return key, l, true
+}
+
+// limiter is a token bucket that limits the rate of a key's requests,
+// allowing bursts of up to a minute's worth of requests.
+type limiter struct {
+	// perMinute is the rate limit.
+	perMinute int
+	// lock guards all subsequent fields.
+	lock sync.Mutex
+	// tokens are the requests that can be made without waiting.
+	tokens float64
+	// updated is the time at which tokens was last updated.
+	updated time.Time
+}
+
+// allow accounts for a request, if it's within the rate limit, and otherwise
+// returns how long until it would be.
+func (l *limiter) allow(now time.Time) (time.Duration, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	perMinute := float64(l.perMinute)
+	if l.updated.IsZero() {
+		l.tokens = perMinute
+	} else {
+		l.tokens = min(l.tokens+now.Sub(l.updated).Minutes()*perMinute, perMinute)
+	}
+	l.updated = now
+	if l.tokens < 1 {
+		return time.Duration((1 - l.tokens) / perMinute * float64(time.Minute)), false
+	}
+	l.tokens--
+	return 0, true
+}
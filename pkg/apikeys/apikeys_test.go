@@ -0,0 +1,104 @@
+package apikeys
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParse(t *testing.T) {
+	config, err := Parse([]byte(`{"keys":[
+		{"name":"alice","key":"secret","models":["ai/smollm2"],"requests_per_minute":60},
+		{"name":"ci","hash":"` + hashSecret("other") + `","manage":true}
+	]}`))
+	if err != nil {
+		t.Fatalf("Failed to parse keys: %v", err)
+	}
+	if config.Keys[0].ID != hashSecret("secret")[:16] || config.Keys[1].ID != hashSecret("other")[:16] || !config.Keys[1].Manage {
+		t.Errorf("Unexpected keys %+v", config.Keys)
+	}
+
+	for _, data := range []string{
+		`not json`,
+		`{"keys":[{"name":"alice"}]}`,
+		`{"keys":[{"hash":"abc"}]}`,
+		`{"keys":[{"key":"secret"},{"key":"secret"}]}`,
+		`{"keys":[{"key":"secret","requests_per_minute":-1}]}`,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Expected %s to be invalid", data)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store, err := NewStore(logrus.New(), path)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if keys := store.List(); len(keys) != 0 {
+		t.Errorf("Expected no keys without a keys file, got %+v", keys)
+	}
+
+	key, err := store.Create(CreateRequest{Name: "alice", Models: []string{"ai/smollm2"}})
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if !strings.HasPrefix(key.Key, "dmr-") || key.ID != hashSecret(key.Key)[:16] || key.Created == nil {
+		t.Errorf("Unexpected key %+v", key)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), key.Key) || !strings.Contains(string(data), hashSecret(key.Key)) {
+		t.Errorf("Expected only the key's hash to be stored, got %s", data)
+	}
+	if authenticated, _, ok := store.authenticate(key.Key); !ok || authenticated.Name != "alice" {
+		t.Errorf("Expected the key to authenticate, got %+v", authenticated)
+	}
+	if keys := store.List(); len(keys) != 1 || keys[0].Key != "" || keys[0].Hash != "" || keys[0].ID != key.ID {
+		t.Errorf("Expected the key to be listed without its secret, got %+v", keys)
+	}
+
+	// Keys added to the file are picked up.
+	os.WriteFile(path, append(data[:len(data)-len("]\n}\n")], []byte(`,{"name":"bob","key":"bob-secret"}]}`)...), 0o600)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if authenticated, _, ok := store.authenticate("bob-secret"); !ok || authenticated.Name != "bob" {
+		t.Errorf("Expected the edited file to be reloaded, got %+v", authenticated)
+	}
+
+	if err := store.Revoke(key.ID); err != nil {
+		t.Fatalf("Failed to revoke key: %v", err)
+	}
+	if _, _, ok := store.authenticate(key.Key); ok {
+		t.Error("Expected the revoked key not to authenticate")
+	}
+	if err := store.Revoke(key.ID); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected the revoked key not to be found, got %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if !strings.Contains(string(data), `"key": "bob-secret"`) || strings.Contains(string(data), `"id"`) {
+		t.Errorf("Expected configured keys to be kept as they were, got %s", data)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := &limiter{perMinute: 2}
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, ok := l.allow(now); !ok {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+	}
+	if wait, ok := l.allow(now); ok || wait != 30*time.Second {
+		t.Errorf("Expected the request to wait 30s, got %v, %v", wait, ok)
+	}
+	if _, ok := l.allow(now.Add(30 * time.Second)); !ok {
+		t.Error("Expected the request to be allowed once the bucket refilled")
+	}
+}
@@ -0,0 +1,170 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/middleware"
+)
+
+// inferencePrefixes are the prefixes under which the inference endpoints are
+// served: the /v1 aliases, the inference prefix, and the backend prefixes.
+var inferencePrefixes = []string{"", inference.InferencePrefix, inference.InferencePrefix + "/{backend}"}
+
+// inferenceEndpoints are the endpoints, under inferencePrefixes, that serve
+// inference.
+var inferenceEndpoints = []string{
+	"/v1/chat/completions",
+	"/v1/completions",
+	"/v1/embeddings",
+	"/v1/audio/transcriptions",
+	"/v1/images/generations",
+	"/rerank",
+	"/v1/rerank",
+	"/score",
+	"/v1/tokenize",
+	"/v1/detokenize",
+	"/v1/responses",
+	"/v1/moderations",
+}
+
+// appEndpoints are the endpoints served under the prefix of each application.
+var appEndpoints = []string{
+	"/v1/chat/completions",
+	"/v1/completions",
+	"/v1/embeddings",
+}
+
+// inferenceRoutes returns the routes, in net/http.ServeMux syntax, that keys
+// that can't manage the model runner can use: inference, model listings,
+// WebSocket chat, and the key's own sessions, which are scoped to the key that
+// created them.
+func inferenceRoutes() []string {
+	routes := []string{
+		"GET " + inference.ModelsPrefix,
+		"POST /api/chat",
+		"POST /api/generate",
+		"GET /api/tags",
+	}
+	for _, prefix := range inferencePrefixes {
+		for _, endpoint := range inferenceEndpoints {
+			routes = append(routes, "POST "+prefix+endpoint)
+		}
+		routes = append(routes,
+			"GET "+prefix+"/v1/models",
+			"GET "+prefix+"/v1/models/{name...}",
+			"GET "+prefix+"/v1/chat/ws",
+			"GET "+prefix+"/v1/sessions/{id}",
+			"DELETE "+prefix+"/v1/sessions/{id}",
+		)
+	}
+	for _, endpoint := range appEndpoints {
+		routes = append(routes, "POST /apps/{app}"+endpoint)
+	}
+	return routes
+}
+
+// keyKey is the context key of the key that authenticated a request.
+type keyKey struct{}
+
+// FromContext returns the key that authenticated the request associated with
+// ctx, if any.
+func FromContext(ctx context.Context) (Key, bool) {
+	key, ok := ctx.Value(keyKey{}).(Key)
+	return key, ok
+}
+
+// Handler requires requests to be authenticated with one of the keys of a
+// store, as bearer tokens, and enforces the routes and rate limits of keys.
+type Handler struct {
+	// store is the key store.
+	store *Store
+	// next is the handler serving authenticated requests.
+	next http.Handler
+	// public matches the routes that don't require a key.
+	public *http.ServeMux
+	// inference matches the routes available to keys that can't manage the
+	// model runner.
+	inference *http.ServeMux
+	// token is the token accepted instead of a key on the routes matched by
+	// tokenRoutes, if any.
+	token string
+	// tokenRoutes matches the routes that accept token.
+	tokenRoutes *http.ServeMux
+}
+
+// NewHandler creates a new handler that serves requests authenticated with
+// the keys of store with next. Requests matching the public patterns, which
+// follow net/http.ServeMux syntax, don't require a key, as well as CORS
+// preflight requests.
+func NewHandler(store *Store, next http.Handler, public ...string) *Handler {
+	h := &Handler{
+		store:       store,
+		next:        next,
+		public:      http.NewServeMux(),
+		inference:   http.NewServeMux(),
+		tokenRoutes: http.NewServeMux(),
+	}
+	for _, pattern := range public {
+		h.public.Handle(pattern, next)
+	}
+	for _, pattern := range inferenceRoutes() {
+		h.inference.Handle(pattern, next)
+	}
+	return h
+}
+
+// AllowToken accepts a token, such as one shared by the model runners of a
+// cluster, instead of a key on the routes matching the patterns, which follow
+// net/http.ServeMux syntax. Requests authenticated with the token aren't
+// associated with a key. An empty token isn't accepted.
+func (h *Handler) AllowToken(token string, patterns ...string) {
+	h.token = token
+	for _, pattern := range patterns {
+		h.tokenRoutes.Handle(pattern, h.next)
+	}
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := h.public.Handler(r); middleware.Preflight(r) || pattern != "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok && h.token != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(h.token)) == 1 {
+		if _, pattern := h.tokenRoutes.Handler(r); pattern != "" {
+			h.next.ServeHTTP(w, r)
+			return
+		}
+	}
+	var key Key
+	var l *limiter
+	if ok && secret != "" {
+		key, l, ok = h.store.authenticate(secret)
+	}
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="model-runner"`)
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+		return
+	}
+	if _, pattern := h.inference.Handler(r); !key.Manage && pattern == "" {
+		http.Error(w, "API key isn't allowed to manage the model runner", http.StatusForbidden)
+		return
+	}
+	if l != nil {
+		if wait, ok := l.allow(time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, fmt.Sprintf("rate limit of %d requests per minute exceeded", key.RequestsPerMinute), http.StatusTooManyRequests)
+			return
+		}
+	}
+	h.next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), keyKey{}, key)))
+}
@@ -0,0 +1,82 @@
+package apikeys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(path, []byte(`{"keys":[
+		{"name":"alice","key":"alice-secret","models":["ai/smollm2"],"requests_per_minute":2},
+		{"name":"ci","key":"ci-secret","manage":true},
+		{"name":"bob","key":"bob-secret"}
+	]}`), 0o600)
+	store, err := NewStore(logrus.New(), path)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	var served Key
+	h := NewHandler(store, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served, _ = FromContext(r.Context())
+	}), "GET /{$}", "/admin/")
+	serve := func(method, path, secret string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, http.NoBody)
+		if secret != "" {
+			r.Header.Set("Authorization", "Bearer "+secret)
+		}
+		if method == http.MethodOptions && secret == "" {
+			r.Header.Set("Origin", "http://localhost:3000")
+			r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, test := range []struct {
+		method, path, secret string
+		status               int
+	}{
+		{method: http.MethodGet, path: "/", status: http.StatusOK},
+		{method: http.MethodPost, path: "/admin/load", status: http.StatusOK},
+		{method: http.MethodOptions, path: "/v1/chat/completions", status: http.StatusOK},
+		{method: http.MethodOptions, path: "/models/create", secret: "wrong", status: http.StatusUnauthorized},
+		{method: http.MethodPost, path: "/v1/chat/completions", status: http.StatusUnauthorized},
+		{method: http.MethodPost, path: "/v1/chat/completions", secret: "wrong", status: http.StatusUnauthorized},
+		{method: http.MethodGet, path: "/engines/v1/models", secret: "ci-secret", status: http.StatusOK},
+		{method: http.MethodPost, path: "/models/create", secret: "ci-secret", status: http.StatusOK},
+		{method: http.MethodPost, path: "/models/create", secret: "alice-secret", status: http.StatusForbidden},
+		{method: http.MethodPost, path: "/engines/unload", secret: "alice-secret", status: http.StatusForbidden},
+		{method: http.MethodGet, path: "/usage/keys", secret: "alice-secret", status: http.StatusForbidden},
+		{method: http.MethodGet, path: "/models", secret: "alice-secret", status: http.StatusOK},
+		{method: http.MethodPost, path: "/engines/llama.cpp/v1/chat/completions", secret: "alice-secret", status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/chat", secret: "alice-secret", status: http.StatusTooManyRequests},
+		{method: http.MethodGet, path: "/v1/sessions/chat-42", secret: "bob-secret", status: http.StatusOK},
+		{method: http.MethodDelete, path: "/engines/v1/sessions/chat-42", secret: "bob-secret", status: http.StatusOK},
+		{method: http.MethodPost, path: "/v1/sessions/chat-42", secret: "bob-secret", status: http.StatusForbidden},
+		{method: http.MethodDelete, path: "/models/registry.example/v1/sessions/m", secret: "bob-secret", status: http.StatusForbidden},
+		{method: http.MethodPost, path: "/models/v1/chat/completions", secret: "bob-secret", status: http.StatusForbidden},
+		{method: http.MethodGet, path: "/models/json/v1/models/m", secret: "bob-secret", status: http.StatusForbidden},
+		{method: http.MethodGet, path: "/engines/v1/models/ai/smollm2", secret: "bob-secret", status: http.StatusOK},
+		{method: http.MethodPost, path: "/apps/support/v1/chat/completions", secret: "bob-secret", status: http.StatusOK},
+		{method: http.MethodOptions, path: "/models/create", secret: "bob-secret", status: http.StatusForbidden},
+	} {
+		if w := serve(test.method, test.path, test.secret); w.Code != test.status {
+			t.Errorf("Expected status %d for %s %s with %q, got %d", test.status, test.method, test.path, test.secret, w.Code)
+		}
+	}
+
+	w := serve(http.MethodPost, "/v1/completions", "alice-secret")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Errorf("Expected the rate limit to be exceeded, got %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if serve(http.MethodPost, "/v1/embeddings", "ci-secret"); served.Name != "ci" {
+		t.Errorf("Expected the key to be passed on, got %+v", served)
+	}
+}
@@ -0,0 +1,82 @@
+// Package apps exposes models under dedicated, per-application route prefixes
+// (e.g. /apps/support-bot/v1/chat/completions), each with its own API keys
+// and request defaults.
+package apps
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Prefix is the route prefix under which applications are exposed.
+const Prefix = "/apps"
+
+// validName matches valid application names, which are used as path segments.
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// App is an application exposed under its own route prefix.
+type App struct {
+	// Name is the name of the application, which determines its route prefix.
+	Name string `json:"name"`
+	// Model is the model that serves the application's requests, regardless
+	// of the model requested.
+	Model string `json:"model"`
+	// Backend is the backend used to run the model. The default backend is
+	// used if it's empty.
+	Backend string `json:"backend,omitempty"`
+	// APIKeys are the keys accepted as bearer tokens for the application. If
+	// empty, requests aren't authenticated.
+	APIKeys []string `json:"api_keys,omitempty"`
+	// Defaults are request parameters (e.g. temperature or max_tokens) applied
+	// to requests that don't set them.
+	Defaults map[string]json.RawMessage `json:"defaults,omitempty"`
+	// Disabled revokes the application's endpoints.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Config is the content of an applications file.
+type Config struct {
+	// Apps are the configured applications.
+	Apps []App `json:"apps"`
+}
+
+// Parse parses and validates the content of an applications file.
+func Parse(data []byte) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid applications file: %w", err)
+	}
+	seen := make(map[string]bool, len(config.Apps))
+	for i, app := range config.Apps {
+		if !validName.MatchString(app.Name) {
+			return nil, fmt.Errorf("application %d: invalid name %q", i, app.Name)
+		}
+		if seen[app.Name] {
+			return nil, fmt.Errorf("application %d: duplicate name %q", i, app.Name)
+		}
+		seen[app.Name] = true
+		if app.Model == "" {
+			return nil, fmt.Errorf("application %q: model is required", app.Name)
+		}
+		if _, ok := app.Defaults["model"]; ok {
+			return nil, fmt.Errorf("application %q: model can't be set in defaults", app.Name)
+		}
+		for _, key := range app.APIKeys {
+			if key == "" {
+				return nil, fmt.Errorf("application %q: empty API key", app.Name)
+			}
+		}
+	}
+	return &config, nil
+}
+
+// Load reads and parses an applications file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
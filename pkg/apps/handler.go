@@ -0,0 +1,181 @@
+package apps
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// maximumRequestSize is the maximum size of an application request body,
+// matching the limit applied by the scheduler.
+const maximumRequestSize = 10 * 1024 * 1024
+
+// endpoints are the OpenAI API endpoints that applications expose.
+var endpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+}
+
+// Handler serves application routes by forwarding requests to the inference
+// handler. The applications file is reloaded when it changes, so that
+// applications can be added, reconfigured, or revoked without a restart.
+type Handler struct {
+	// log is the associated logger.
+	log logging.Logger
+	// path is the path to the applications file.
+	path string
+	// next is the inference handler.
+	next http.Handler
+	// lock protects the fields below.
+	lock sync.Mutex
+	// modTime is the modification time of the loaded applications file.
+	modTime time.Time
+	// apps are the loaded applications, indexed by name.
+	apps map[string]App
+}
+
+// NewHandler creates a new application handler that forwards requests to
+// next, which should serve the inference API.
+func NewHandler(log logging.Logger, path string, next http.Handler) (*Handler, error) {
+	h := &Handler{log: log, path: path, next: next}
+	if err := h.reload(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// reload loads the applications file if it changed since it was last loaded.
+// The caller must hold the lock, unless the handler isn't shared yet.
+func (h *Handler) reload() error {
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return err
+	}
+	if h.apps != nil && info.ModTime().Equal(h.modTime) {
+		return nil
+	}
+	config, err := Load(h.path)
+	if err != nil {
+		return err
+	}
+	apps := make(map[string]App, len(config.Apps))
+	for _, app := range config.Apps {
+		apps[app.Name] = app
+	}
+	h.apps = apps
+	h.modTime = info.ModTime()
+	return nil
+}
+
+// lookup returns the current configuration of the named application.
+func (h *Handler) lookup(name string) (App, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err := h.reload(); err != nil {
+		// Keep serving the last valid configuration.
+		h.log.Warnf("Failed to reload applications from %s: %v", h.path, err)
+	}
+	app, ok := h.apps[name]
+	return app, ok && !app.Disabled
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, Prefix+"/"), "/")
+	endpoint = "/" + endpoint
+	app, ok := h.lookup(name)
+	if !ok || !endpoints[endpoint] {
+		http.NotFound(w, r)
+		return
+	}
+	if !authorized(app, r.Header.Get("Authorization")) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+app.Name+`"`)
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maximumRequestSize))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(w, "request too large", http.StatusBadRequest)
+		} else {
+			http.Error(w, "failed to read request body", http.StatusInternalServerError)
+		}
+		return
+	}
+	body, err = applyDefaults(app, body)
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	upstream := r.Clone(r.Context())
+	upstream.URL.Path = inference.InferencePrefix + endpoint
+	if app.Backend != "" {
+		upstream.URL.Path = inference.InferencePrefix + "/" + app.Backend + endpoint
+	}
+	upstream.URL.RawPath = ""
+	// The application's API key is meaningless to the backend.
+	upstream.Header.Del("Authorization")
+	upstream.Body = io.NopCloser(bytes.NewReader(body))
+	upstream.ContentLength = int64(len(body))
+	h.next.ServeHTTP(w, upstream)
+}
+
+// authorized returns true if the Authorization header carries one of the
+// application's API keys, or if the application doesn't require one.
+func authorized(app App, header string) bool {
+	if len(app.APIKeys) == 0 {
+		return true
+	}
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return false
+	}
+	for _, key := range app.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// applyDefaults sets the application's model and fills in default parameters
+// that the request doesn't set.
+func applyDefaults(app App, body []byte) ([]byte, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	if request == nil {
+		request = make(map[string]json.RawMessage)
+	}
+	for key, value := range app.Defaults {
+		if _, ok := request[key]; !ok {
+			request[key] = value
+		}
+	}
+	model, err := json.Marshal(app.Model)
+	if err != nil {
+		return nil, err
+	}
+	request["model"] = model
+	return json.Marshal(request)
+}
@@ -0,0 +1,183 @@
+package apps
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const appsFile = `{"apps": [
+	{"name": "support-bot", "model": "ai/smollm2", "api_keys": ["secret"], "defaults": {"temperature": 0.2}},
+	{"name": "search", "model": "ai/embeddinggemma", "backend": "llama.cpp"},
+	{"name": "retired", "model": "ai/smollm2", "disabled": true}
+]}`
+
+func TestHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apps.json")
+	if err := os.WriteFile(path, []byte(appsFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var upstreamPath, upstreamAuth string
+	var upstreamBody map[string]any
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamPath = r.URL.Path
+		upstreamAuth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		upstreamBody = nil
+		json.Unmarshal(body, &upstreamBody)
+	})
+	handler, err := NewHandler(logrus.New(), path, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		auth         string
+		body         string
+		status       int
+		expectedPath string
+		expected     map[string]any
+	}{
+		{
+			name:         "defaults applied",
+			path:         "/apps/support-bot/v1/chat/completions",
+			auth:         "Bearer secret",
+			body:         `{"model": "other", "messages": []}`,
+			status:       http.StatusOK,
+			expectedPath: "/engines/v1/chat/completions",
+			expected:     map[string]any{"model": "ai/smollm2", "temperature": 0.2},
+		},
+		{
+			name:         "request overrides defaults",
+			path:         "/apps/support-bot/v1/chat/completions",
+			auth:         "Bearer secret",
+			body:         `{"temperature": 1}`,
+			status:       http.StatusOK,
+			expectedPath: "/engines/v1/chat/completions",
+			expected:     map[string]any{"model": "ai/smollm2", "temperature": 1.0},
+		},
+		{
+			name:         "backend route",
+			path:         "/apps/search/v1/embeddings",
+			body:         `{"input": "hello"}`,
+			status:       http.StatusOK,
+			expectedPath: "/engines/llama.cpp/v1/embeddings",
+			expected:     map[string]any{"model": "ai/embeddinggemma"},
+		},
+		{
+			name:   "missing API key",
+			path:   "/apps/support-bot/v1/chat/completions",
+			body:   `{}`,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "wrong API key",
+			path:   "/apps/support-bot/v1/chat/completions",
+			auth:   "Bearer guess",
+			body:   `{}`,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "disabled application",
+			path:   "/apps/retired/v1/chat/completions",
+			body:   `{}`,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "unknown application",
+			path:   "/apps/unknown/v1/chat/completions",
+			body:   `{}`,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "unsupported endpoint",
+			path:   "/apps/search/v1/models",
+			body:   `{}`,
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamPath, upstreamAuth = "", ""
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				if upstreamPath != "" {
+					t.Error("request shouldn't have been forwarded")
+				}
+				return
+			}
+			if upstreamPath != tt.expectedPath {
+				t.Errorf("expected upstream path %q, got %q", tt.expectedPath, upstreamPath)
+			}
+			if upstreamAuth != "" {
+				t.Error("expected Authorization header to be removed")
+			}
+			for key, value := range tt.expected {
+				if upstreamBody[key] != value {
+					t.Errorf("expected %s=%v, got %v", key, value, upstreamBody[key])
+				}
+			}
+		})
+	}
+
+	// Revoking an application takes effect without recreating the handler.
+	revoked := strings.Replace(appsFile, `"api_keys": ["secret"]`, `"api_keys": ["secret"], "disabled": true`, 1)
+	if err := os.WriteFile(path, []byte(revoked), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/apps/support-bot/v1/chat/completions", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected revoked application to return %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "valid", data: appsFile},
+		{name: "invalid name", data: `{"apps": [{"name": "Support Bot", "model": "ai/smollm2"}]}`, wantErr: true},
+		{name: "duplicate name", data: `{"apps": [{"name": "a", "model": "m"}, {"name": "a", "model": "m"}]}`, wantErr: true},
+		{name: "missing model", data: `{"apps": [{"name": "a"}]}`, wantErr: true},
+		{name: "model default", data: `{"apps": [{"name": "a", "model": "m", "defaults": {"model": "x"}}]}`, wantErr: true},
+		{name: "empty API key", data: `{"apps": [{"name": "a", "model": "m", "api_keys": [""]}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
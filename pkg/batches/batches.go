@@ -0,0 +1,222 @@
+// Package batches implements the OpenAI Batch API: JSONL files of requests are
+// uploaded through /v1/files, processed asynchronously in a low-priority
+// background lane through /v1/batches, and their results are downloaded as
+// files.
+package batches
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	// FilesPrefix is the route prefix of the files API.
+	FilesPrefix = "/v1/files"
+	// BatchesPrefix is the route prefix of the batches API.
+	BatchesPrefix = "/v1/batches"
+)
+
+// Purposes of files.
+const (
+	// PurposeBatch is the purpose of uploaded batch input files.
+	PurposeBatch = "batch"
+	// PurposeBatchOutput is the purpose of batch output and error files.
+	PurposeBatchOutput = "batch_output"
+)
+
+// Statuses of batches.
+const (
+	// StatusValidating indicates that a batch is queued, and its input file is
+	// validated before it's processed.
+	StatusValidating = "validating"
+	// StatusFailed indicates that the input file of a batch is invalid.
+	StatusFailed = "failed"
+	// StatusInProgress indicates that the requests of a batch are processed.
+	StatusInProgress = "in_progress"
+	// StatusFinalizing indicates that the output files of a batch are written.
+	StatusFinalizing = "finalizing"
+	// StatusCompleted indicates that every request of a batch was processed.
+	StatusCompleted = "completed"
+	// StatusExpired indicates that a batch didn't complete within its
+	// completion window.
+	StatusExpired = "expired"
+	// StatusCancelling indicates that a batch is being cancelled.
+	StatusCancelling = "cancelling"
+	// StatusCancelled indicates that a batch was cancelled.
+	StatusCancelled = "cancelled"
+)
+
+// completionWindow is the only completion window accepted, as with OpenAI.
+const completionWindow = "24h"
+
+// maximumRequests is the maximum number of requests in a batch, as with
+// OpenAI.
+const maximumRequests = 50000
+
+// endpoints are the endpoints that batches can target.
+var endpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+}
+
+// File is an uploaded or generated file.
+type File struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+}
+
+// RequestCounts are the counts of the requests of a batch.
+type RequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// Error is an error of a batch, such as an invalid line of its input file.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// Errors are the errors of a batch.
+type Errors struct {
+	Object string  `json:"object"`
+	Data   []Error `json:"data"`
+}
+
+// Batch is a batch of requests processed in the background.
+type Batch struct {
+	ID               string            `json:"id"`
+	Object           string            `json:"object"`
+	Endpoint         string            `json:"endpoint"`
+	Errors           *Errors           `json:"errors,omitempty"`
+	InputFileID      string            `json:"input_file_id"`
+	CompletionWindow string            `json:"completion_window"`
+	Status           string            `json:"status"`
+	OutputFileID     string            `json:"output_file_id,omitempty"`
+	ErrorFileID      string            `json:"error_file_id,omitempty"`
+	CreatedAt        int64             `json:"created_at"`
+	InProgressAt     int64             `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64             `json:"expires_at"`
+	FinalizingAt     int64             `json:"finalizing_at,omitempty"`
+	CompletedAt      int64             `json:"completed_at,omitempty"`
+	FailedAt         int64             `json:"failed_at,omitempty"`
+	ExpiredAt        int64             `json:"expired_at,omitempty"`
+	CancellingAt     int64             `json:"cancelling_at,omitempty"`
+	CancelledAt      int64             `json:"cancelled_at,omitempty"`
+	RequestCounts    RequestCounts     `json:"request_counts"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+// done returns true if a batch reached a final status.
+func (b *Batch) done() bool {
+	switch b.Status {
+	case StatusFailed, StatusCompleted, StatusExpired, StatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// request is a line of a batch input file.
+type request struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// response is the response to a request in a batch output or error file.
+type response struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// result is a line of a batch output or error file.
+type result struct {
+	ID       string    `json:"id"`
+	CustomID string    `json:"custom_id"`
+	Response *response `json:"response"`
+	Error    *Error    `json:"error"`
+}
+
+// parseInput parses and validates the requests of a batch input file, which
+// must all target the batch's endpoint. It returns the errors of invalid
+// lines, if any, rather than the requests.
+func parseInput(r io.Reader, endpoint string) ([]request, []Error, error) {
+	var requests []request
+	var errs []Error
+	seen := make(map[string]bool)
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			if request, invalid := parseRequest(data, endpoint); invalid != "" {
+				errs = append(errs, Error{Code: "invalid_request", Message: invalid, Line: line})
+			} else if seen[request.CustomID] {
+				errs = append(errs, Error{Code: "duplicate_custom_id", Message: fmt.Sprintf("duplicate custom_id %q", request.CustomID), Line: line})
+			} else {
+				seen[request.CustomID] = true
+				requests = append(requests, request)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if len(errs) == 0 && len(requests) == 0 {
+		errs = append(errs, Error{Code: "empty_file", Message: "the input file contains no requests"})
+	} else if len(requests)+len(errs) > maximumRequests {
+		errs = append(errs, Error{Code: "too_many_requests", Message: fmt.Sprintf("batches are limited to %d requests", maximumRequests)})
+	}
+	if len(errs) > 0 {
+		return nil, errs, nil
+	}
+	return requests, nil, nil
+}
+
+// parseRequest parses a line of a batch input file. It returns why the line
+// is invalid, if it is.
+func parseRequest(data []byte, endpoint string) (request, string) {
+	var r request
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, "invalid JSON"
+	}
+	if r.CustomID == "" {
+		return r, "custom_id is required"
+	}
+	if r.Method != http.MethodPost {
+		return r, fmt.Sprintf("unsupported method %q", r.Method)
+	}
+	if r.URL != endpoint {
+		return r, fmt.Sprintf("url %q doesn't match the batch endpoint %q", r.URL, endpoint)
+	}
+	var body struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	if err := json.Unmarshal(r.Body, &body); err != nil || bytes.HasPrefix(bytes.TrimSpace(r.Body), []byte("null")) {
+		return r, "body must be an object"
+	}
+	if body.Model == "" {
+		return r, "body.model is required"
+	}
+	if body.Stream {
+		return r, "streaming isn't supported in batches"
+	}
+	return r, ""
+}
@@ -0,0 +1,51 @@
+package batches
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseInput(t *testing.T) {
+	const chat = `{"custom_id":"%s","method":"POST","url":"/v1/chat/completions","body":{"model":"ai/smollm2","messages":[]}}`
+	line := func(id string) string {
+		return strings.Replace(chat, "%s", id, 1)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		requests int
+		errors   []int
+	}{
+		{name: "valid", input: line("a") + "\n\n" + line("b"), requests: 2},
+		{name: "trailing newline", input: line("a") + "\n", requests: 1},
+		{name: "empty", input: "\n", errors: []int{0}},
+		{name: "invalid JSON", input: line("a") + "\n{", errors: []int{2}},
+		{name: "duplicate custom_id", input: line("a") + "\n" + line("a"), errors: []int{2}},
+		{name: "missing custom_id", input: line(""), errors: []int{1}},
+		{name: "wrong method", input: strings.Replace(line("a"), "POST", "GET", 1), errors: []int{1}},
+		{name: "wrong url", input: strings.Replace(line("a"), "/v1/chat/completions", "/v1/embeddings", 1), errors: []int{1}},
+		{name: "missing body", input: `{"custom_id":"a","method":"POST","url":"/v1/chat/completions"}`, errors: []int{1}},
+		{name: "missing model", input: `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{}}`, errors: []int{1}},
+		{name: "streaming", input: strings.Replace(line("a"), `"messages"`, `"stream":true,"messages"`, 1), errors: []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, errs, err := parseInput(strings.NewReader(tt.input), "/v1/chat/completions")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(requests) != tt.requests {
+				t.Errorf("Expected %d requests, got %d", tt.requests, len(requests))
+			}
+			if len(errs) != len(tt.errors) {
+				t.Fatalf("Expected errors on lines %v, got %+v", tt.errors, errs)
+			}
+			for i, e := range errs {
+				if e.Line != tt.errors[i] {
+					t.Errorf("Expected errors on lines %v, got %+v", tt.errors, errs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package batches
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+const (
+	// defaultListLimit is the default number of batches listed at once.
+	defaultListLimit = 20
+	// maximumListLimit is the maximum number of batches listed at once.
+	maximumListLimit = 100
+	// maximumFormMemory is the size of uploaded files above which they're
+	// buffered on disk while being uploaded.
+	maximumFormMemory = 32 * 1024 * 1024
+)
+
+// HTTPHandler serves the files and batches APIs.
+type HTTPHandler struct {
+	// log is the associated logger.
+	log logging.Logger
+	// router is the HTTP request router.
+	router *http.ServeMux
+	// manager stores and processes batches.
+	manager *Manager
+}
+
+// NewHTTPHandler creates a new HTTP handler for the files and batches APIs.
+func NewHTTPHandler(log logging.Logger, manager *Manager) *HTTPHandler {
+	h := &HTTPHandler{log: log, router: http.NewServeMux(), manager: manager}
+	h.router.HandleFunc("POST "+FilesPrefix, h.handleCreateFile)
+	h.router.HandleFunc("GET "+FilesPrefix, h.handleListFiles)
+	h.router.HandleFunc("GET "+FilesPrefix+"/{id}", h.handleGetFile)
+	h.router.HandleFunc("DELETE "+FilesPrefix+"/{id}", h.handleDeleteFile)
+	h.router.HandleFunc("GET "+FilesPrefix+"/{id}/content", h.handleGetFileContent)
+	h.router.HandleFunc("POST "+BatchesPrefix, h.handleCreateBatch)
+	h.router.HandleFunc("GET "+BatchesPrefix, h.handleListBatches)
+	h.router.HandleFunc("GET "+BatchesPrefix+"/{id}", h.handleGetBatch)
+	h.router.HandleFunc("POST "+BatchesPrefix+"/{id}/cancel", h.handleCancelBatch)
+	return h
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// handleCreateFile handles POST /v1/files requests, which upload a file as
+// multipart form data with its purpose.
+func (h *HTTPHandler) handleCreateFile(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maximumFileSize+maximumFormMemory)
+	if err := r.ParseMultipartForm(maximumFormMemory); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "invalid multipart form", http.StatusBadRequest)
+		}
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	upload, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer upload.Close()
+
+	file, err := h.manager.CreateFile(header.Filename, r.FormValue("purpose"), upload)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeJSON(w, file)
+}
+
+// handleListFiles handles GET /v1/files requests, with an optional purpose
+// query parameter.
+func (h *HTTPHandler) handleListFiles(w http.ResponseWriter, r *http.Request) {
+	files, err := h.manager.Files(r.URL.Query().Get("purpose"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeJSON(w, map[string]any{"object": "list", "data": files})
+}
+
+// handleGetFile handles GET /v1/files/{id} requests.
+func (h *HTTPHandler) handleGetFile(w http.ResponseWriter, r *http.Request) {
+	file, err := h.manager.File(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeJSON(w, file)
+}
+
+// handleDeleteFile handles DELETE /v1/files/{id} requests.
+func (h *HTTPHandler) handleDeleteFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := h.manager.DeleteFile(id); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeJSON(w, map[string]any{"id": id, "object": "file", "deleted": true})
+}
+
+// handleGetFileContent handles GET /v1/files/{id}/content requests.
+func (h *HTTPHandler) handleGetFileContent(w http.ResponseWriter, r *http.Request) {
+	f, err := h.manager.OpenFile(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/jsonl")
+	if _, err := io.Copy(w, f); err != nil {
+		h.log.Warnf("Failed to send file content: %v", err)
+	}
+}
+
+// handleCreateBatch handles POST /v1/batches requests.
+func (h *HTTPHandler) handleCreateBatch(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		InputFileID      string            `json:"input_file_id"`
+		Endpoint         string            `json:"endpoint"`
+		CompletionWindow string            `json:"completion_window"`
+		Metadata         map[string]string `json:"metadata"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024*1024)).Decode(&request); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	batch, err := h.manager.CreateBatch(request.InputFileID, request.Endpoint, request.CompletionWindow, request.Metadata)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeJSON(w, batch)
+}
+
+// handleListBatches handles GET /v1/batches requests, with optional after and
+// limit query parameters for pagination.
+func (h *HTTPHandler) handleListBatches(w http.ResponseWriter, r *http.Request) {
+	limit := defaultListLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > maximumListLimit {
+			http.Error(w, "invalid limit query parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	batches, more := h.manager.Batches(r.URL.Query().Get("after"), limit)
+	list := map[string]any{"object": "list", "data": batches, "has_more": more}
+	if len(batches) > 0 {
+		list["first_id"] = batches[0].ID
+		list["last_id"] = batches[len(batches)-1].ID
+	}
+	h.writeJSON(w, list)
+}
+
+// handleGetBatch handles GET /v1/batches/{id} requests.
+func (h *HTTPHandler) handleGetBatch(w http.ResponseWriter, r *http.Request) {
+	batch, err := h.manager.Batch(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeJSON(w, batch)
+}
+
+// handleCancelBatch handles POST /v1/batches/{id}/cancel requests.
+func (h *HTTPHandler) handleCancelBatch(w http.ResponseWriter, r *http.Request) {
+	batch, err := h.manager.CancelBatch(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeJSON(w, batch)
+}
+
+// writeError writes the response for an error of the manager.
+func (h *HTTPHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		h.log.Warnf("Batch request failed: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeJSON writes a JSON response.
+func (h *HTTPHandler) writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.log.Warnf("Failed to encode response: %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package batches
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeInference serves chat completions echoing the content of the last
+// message, failing those with a content of "bad" and rejecting the first
+// request as if the scheduler's queue was full.
+type fakeInference struct {
+	mu sync.Mutex
+	// requests are the numbers of requests received by path.
+	requests map[string]int
+	// priorities are the priorities of the requests received.
+	priorities []string
+	// block, if set, blocks requests until it's closed.
+	block chan struct{}
+}
+
+func (f *fakeInference) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	json.NewDecoder(r.Body).Decode(&request)
+	f.mu.Lock()
+	f.requests[r.URL.Path]++
+	first := f.requests[r.URL.Path] == 1
+	f.priorities = append(f.priorities, r.Header.Get(inference.RequestPriorityHeader))
+	block := f.block
+	f.mu.Unlock()
+	if first {
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, "queue full", http.StatusTooManyRequests)
+		return
+	}
+	if block != nil {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	content := request.Messages[len(request.Messages)-1].Content
+	if content == "bad" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"object":"chat.completion","choices":[{"message":{"content":%q}}]}`, content)
+}
+
+// input returns a batch input file of chat completion requests.
+func input(contents ...string) string {
+	var lines []string
+	for i, content := range contents {
+		lines = append(lines, fmt.Sprintf(`{"custom_id":"request-%d","method":"POST","url":"/v1/chat/completions","body":{"model":"ai/smollm2","messages":[{"role":"user","content":%q}]}}`, i, content))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// do sends a request to the handler and decodes its JSON response.
+func do(t *testing.T, h http.Handler, method, path, contentType string, body io.Reader, status int, v any) []byte {
+	t.Helper()
+	r := httptest.NewRequest(method, path, body)
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != status {
+		t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, status, w.Code, w.Body)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: failed to decode response: %v", method, path, err)
+		}
+	}
+	return w.Body.Bytes()
+}
+
+// upload uploads a batch input file.
+func upload(t *testing.T, h http.Handler, content string) File {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("purpose", PurposeBatch)
+	part, _ := form.CreateFormFile("file", "input.jsonl")
+	part.Write([]byte(content))
+	form.Close()
+	var file File
+	do(t, h, http.MethodPost, FilesPrefix, form.FormDataContentType(), &body, http.StatusOK, &file)
+	return file
+}
+
+// createBatch creates a batch of chat completions.
+func createBatch(t *testing.T, h http.Handler, file File) Batch {
+	t.Helper()
+	body := fmt.Sprintf(`{"input_file_id":%q,"endpoint":"/v1/chat/completions","completion_window":"24h","metadata":{"task":"labeling"}}`, file.ID)
+	var batch Batch
+	do(t, h, http.MethodPost, BatchesPrefix, "application/json", strings.NewReader(body), http.StatusOK, &batch)
+	return batch
+}
+
+// waitForStatus waits until a batch has one of the given statuses.
+func waitForStatus(t *testing.T, h http.Handler, id string, statuses ...string) Batch {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var batch Batch
+		do(t, h, http.MethodGet, BatchesPrefix+"/"+id, "", nil, http.StatusOK, &batch)
+		for _, status := range statuses {
+			if batch.Status == status {
+				return batch
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for batch %s to be %v, got %s", id, statuses, batch.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// results downloads and decodes a batch output or error file.
+func results(t *testing.T, h http.Handler, id string) map[string]result {
+	t.Helper()
+	content := do(t, h, http.MethodGet, FilesPrefix+"/"+id+"/content", "", nil, http.StatusOK, nil)
+	results := make(map[string]result)
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var r result
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("Failed to decode result %q: %v", line, err)
+		}
+		results[r.CustomID] = r
+	}
+	return results
+}
+
+func TestHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	next := &fakeInference{requests: make(map[string]int)}
+	manager, err := NewManager(logrus.New(), t.TempDir(), next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.SetConcurrency(2)
+	h := NewHTTPHandler(logrus.New(), manager)
+	go manager.Run(ctx)
+
+	file := upload(t, h, input("hello", "bad", "world"))
+	if file.Purpose != PurposeBatch || file.Bytes == 0 {
+		t.Fatalf("Unexpected file %+v", file)
+	}
+	batch := createBatch(t, h, file)
+	batch = waitForStatus(t, h, batch.ID, StatusCompleted)
+	if batch.RequestCounts != (RequestCounts{Total: 3, Completed: 2, Failed: 1}) {
+		t.Errorf("Unexpected request counts %+v", batch.RequestCounts)
+	}
+	if batch.Metadata["task"] != "labeling" || batch.CompletedAt == 0 {
+		t.Errorf("Unexpected batch %+v", batch)
+	}
+
+	output := results(t, h, batch.OutputFileID)
+	for id, content := range map[string]string{"request-0": "hello", "request-2": "world"} {
+		r, ok := output[id]
+		if !ok || r.Response == nil || r.Response.StatusCode != http.StatusOK || !strings.Contains(string(r.Response.Body), content) {
+			t.Errorf("Unexpected output for %s: %+v", id, r)
+		}
+	}
+	failed := results(t, h, batch.ErrorFileID)
+	if r, ok := failed["request-1"]; !ok || r.Response == nil || r.Response.StatusCode != http.StatusBadRequest || !json.Valid(r.Response.Body) {
+		t.Errorf("Unexpected error output %+v", failed)
+	}
+	next.mu.Lock()
+	for _, priority := range next.priorities {
+		if priority != "low" {
+			t.Errorf("Expected batch requests to have a low priority, got %q", priority)
+		}
+	}
+	if next.requests["/engines/v1/chat/completions"] != 4 {
+		t.Errorf("Expected the rejected request to be retried, got %v", next.requests)
+	}
+	next.mu.Unlock()
+
+	var files struct {
+		Data []File `json:"data"`
+	}
+	do(t, h, http.MethodGet, FilesPrefix+"?purpose="+PurposeBatchOutput, "", nil, http.StatusOK, &files)
+	if len(files.Data) != 2 {
+		t.Errorf("Expected the output and error files to be listed, got %+v", files.Data)
+	}
+
+	// Invalid input files fail validation.
+	invalid := createBatch(t, h, upload(t, h, input("hello")+"\nnot json"))
+	invalid = waitForStatus(t, h, invalid.ID, StatusFailed)
+	if invalid.Errors == nil || len(invalid.Errors.Data) != 1 || invalid.Errors.Data[0].Line != 2 {
+		t.Errorf("Expected an error on line 2, got %+v", invalid.Errors)
+	}
+
+	var list struct {
+		Data    []Batch `json:"data"`
+		HasMore bool    `json:"has_more"`
+		LastID  string  `json:"last_id"`
+	}
+	do(t, h, http.MethodGet, BatchesPrefix+"?limit=1", "", nil, http.StatusOK, &list)
+	if len(list.Data) != 1 || !list.HasMore || list.LastID != invalid.ID {
+		t.Errorf("Unexpected first page %+v", list)
+	}
+	do(t, h, http.MethodGet, BatchesPrefix+"?limit=1&after="+list.LastID, "", nil, http.StatusOK, &list)
+	if len(list.Data) != 1 || list.HasMore || list.Data[0].ID != batch.ID {
+		t.Errorf("Unexpected second page %+v", list)
+	}
+
+	do(t, h, http.MethodPost, BatchesPrefix, "application/json", strings.NewReader(`{"input_file_id":"file-missing","endpoint":"/v1/chat/completions","completion_window":"24h"}`), http.StatusBadRequest, nil)
+	do(t, h, http.MethodPost, BatchesPrefix, "application/json", strings.NewReader(fmt.Sprintf(`{"input_file_id":%q,"endpoint":"/v1/audio/speech","completion_window":"24h"}`, file.ID)), http.StatusBadRequest, nil)
+	do(t, h, http.MethodGet, BatchesPrefix+"/batch_missing", "", nil, http.StatusNotFound, nil)
+	do(t, h, http.MethodDelete, FilesPrefix+"/"+file.ID, "", nil, http.StatusOK, nil)
+	do(t, h, http.MethodGet, FilesPrefix+"/"+file.ID, "", nil, http.StatusNotFound, nil)
+}
+
+func TestCancelAndResume(t *testing.T) {
+	dir := t.TempDir()
+	next := &fakeInference{requests: make(map[string]int), block: make(chan struct{})}
+	// The first request is rejected, so it's not blocked.
+	next.requests["/engines/v1/chat/completions"] = 1
+	manager, err := NewManager(logrus.New(), dir, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.SetConcurrency(1)
+	h := NewHTTPHandler(logrus.New(), manager)
+
+	// A batch interrupted by a shutdown is processed again on restart.
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		manager.Run(ctx)
+		close(stopped)
+	}()
+	interrupted := createBatch(t, h, upload(t, h, input("a", "b")))
+	waitForStatus(t, h, interrupted.ID, StatusInProgress)
+	cancel()
+	<-stopped
+
+	close(next.block)
+	next.block = nil
+	manager, err = NewManager(logrus.New(), dir, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.SetConcurrency(1)
+	h = NewHTTPHandler(logrus.New(), manager)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go manager.Run(ctx)
+	resumed := waitForStatus(t, h, interrupted.ID, StatusCompleted)
+	if resumed.RequestCounts.Completed != 2 || len(results(t, h, resumed.OutputFileID)) != 2 {
+		t.Errorf("Expected the resumed batch to complete every request once, got %+v", resumed.RequestCounts)
+	}
+
+	// Cancelled batches keep the results of the requests in flight.
+	next.mu.Lock()
+	next.block = make(chan struct{})
+	next.mu.Unlock()
+	batch := createBatch(t, h, upload(t, h, input("a", "b", "c")))
+	waitForRequests(t, next, 5)
+	var cancelling Batch
+	do(t, h, http.MethodPost, BatchesPrefix+"/"+batch.ID+"/cancel", "", nil, http.StatusOK, &cancelling)
+	if cancelling.Status != StatusCancelling {
+		t.Errorf("Expected the batch to be cancelling, got %s", cancelling.Status)
+	}
+	close(next.block)
+	cancelled := waitForStatus(t, h, batch.ID, StatusCancelled)
+	if cancelled.RequestCounts.Completed != 1 || len(results(t, h, cancelled.OutputFileID)) != 1 {
+		t.Errorf("Expected the request in flight to complete, got %+v", cancelled.RequestCounts)
+	}
+	do(t, h, http.MethodPost, BatchesPrefix+"/"+batch.ID+"/cancel", "", nil, http.StatusBadRequest, nil)
+}
+
+// waitForRequests waits until the inference handler received n requests.
+func waitForRequests(t *testing.T, next *fakeInference, n int) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		next.mu.Lock()
+		received := next.requests["/engines/v1/chat/completions"]
+		next.mu.Unlock()
+		if received >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d requests", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,625 @@
+package batches
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/scheduling"
+	"github.com/docker/model-runner/pkg/internal/relay"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// DefaultConcurrency is the default number of requests of a batch that are
+// processed concurrently.
+const DefaultConcurrency = 4
+
+// maximumFileSize is the maximum size of an uploaded file, as with OpenAI.
+const maximumFileSize = 200 * 1024 * 1024
+
+// maximumMetadata is the maximum number of metadata entries of a batch.
+const maximumMetadata = 16
+
+const (
+	// maximumAttempts is the number of times that a request is sent before
+	// its failure is recorded, if the scheduler is too busy to serve it.
+	maximumAttempts = 8
+	// maximumRetryDelay is the maximum delay between attempts.
+	maximumRetryDelay = time.Minute
+	// saveInterval is the minimum interval between saves of the request
+	// counts of a batch in progress.
+	saveInterval = time.Second
+)
+
+var (
+	// ErrInvalid indicates that a file or batch can't be created or cancelled
+	// as requested.
+	ErrInvalid = errors.New("invalid request")
+	// errCancelled is the cause of the cancellation of a batch by a client.
+	errCancelled = errors.New("batch cancelled")
+	// errExpired is the cause of the cancellation of a batch that didn't
+	// complete within its completion window.
+	errExpired = errors.New("batch expired")
+)
+
+// Manager stores batches and processes them one at a time in the background,
+// by sending their requests to the inference handler with a low priority, so
+// that they only use the capacity left by interactive requests. Batches
+// interrupted by a restart are processed again from the start.
+type Manager struct {
+	// log is the associated logger.
+	log logging.Logger
+	// store persists files and batches.
+	store *store
+	// next is the inference handler.
+	next http.Handler
+	// wake is signaled when a batch is queued.
+	wake chan struct{}
+	// lock protects the fields below.
+	lock sync.Mutex
+	// concurrency is the number of requests processed concurrently.
+	concurrency int
+	// batches are the batches, indexed by ID.
+	batches map[string]*Batch
+	// order are the IDs of the batches, in the order they were created.
+	order []string
+	// queue are the IDs of the batches waiting to be processed, in order.
+	queue []string
+	// running is the ID of the batch being processed, if any.
+	running string
+	// cancel stops sending the requests of the batch being processed.
+	cancel context.CancelCauseFunc
+}
+
+// NewManager creates a batch manager that stores files and batches in dir and
+// sends requests to next, which should serve the inference API.
+func NewManager(log logging.Logger, dir string, next http.Handler) (*Manager, error) {
+	s, err := newStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	batches, err := s.batches()
+	if err != nil {
+		return nil, fmt.Errorf("loading batches: %w", err)
+	}
+	m := &Manager{
+		log:         log,
+		store:       s,
+		next:        next,
+		wake:        make(chan struct{}, 1),
+		concurrency: DefaultConcurrency,
+		batches:     make(map[string]*Batch, len(batches)),
+	}
+	slices.SortStableFunc(batches, func(a, b *Batch) int {
+		return cmp.Compare(a.CreatedAt, b.CreatedAt)
+	})
+	for _, batch := range batches {
+		m.batches[batch.ID] = batch
+		m.order = append(m.order, batch.ID)
+		if batch.done() {
+			continue
+		}
+		if batch.Status == StatusCancelling {
+			batch.Status = StatusCancelled
+			batch.CancelledAt = time.Now().Unix()
+			if err := s.saveBatch(batch); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		m.queue = append(m.queue, batch.ID)
+	}
+	if len(m.queue) > 0 {
+		m.log.Infof("Resuming %d unfinished batches", len(m.queue))
+	}
+	return m, nil
+}
+
+// SetConcurrency sets the number of requests of a batch that are processed
+// concurrently.
+func (m *Manager) SetConcurrency(concurrency int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.log.Infof("Setting batch concurrency to %d", concurrency)
+	m.concurrency = max(concurrency, 1)
+}
+
+// CreateFile stores an uploaded file.
+func (m *Manager) CreateFile(filename, purpose string, r io.Reader) (File, error) {
+	if purpose != PurposeBatch {
+		return File{}, fmt.Errorf("%w: unsupported purpose %q (expected %q)", ErrInvalid, purpose, PurposeBatch)
+	}
+	file, err := m.store.createFile(filename, purpose, r, maximumFileSize)
+	if err != nil {
+		return File{}, err
+	}
+	return file, nil
+}
+
+// Files returns every file, most recent first, optionally only those with a
+// purpose.
+func (m *Manager) Files(purpose string) ([]File, error) {
+	files, err := m.store.files()
+	if err != nil {
+		return nil, err
+	}
+	if purpose != "" {
+		files = slices.DeleteFunc(files, func(f File) bool { return f.Purpose != purpose })
+	}
+	return files, nil
+}
+
+// File returns a file.
+func (m *Manager) File(id string) (File, error) {
+	return m.store.file(id)
+}
+
+// OpenFile opens the content of a file.
+func (m *Manager) OpenFile(id string) (*os.File, error) {
+	return m.store.openFile(id)
+}
+
+// DeleteFile deletes a file.
+func (m *Manager) DeleteFile(id string) error {
+	return m.store.deleteFile(id)
+}
+
+// CreateBatch queues a batch of the requests of an uploaded input file.
+func (m *Manager) CreateBatch(inputFileID, endpoint, window string, metadata map[string]string) (Batch, error) {
+	if !endpoints[endpoint] {
+		return Batch{}, fmt.Errorf("%w: unsupported endpoint %q", ErrInvalid, endpoint)
+	}
+	if window != completionWindow {
+		return Batch{}, fmt.Errorf("%w: unsupported completion window %q (expected %q)", ErrInvalid, window, completionWindow)
+	}
+	if len(metadata) > maximumMetadata {
+		return Batch{}, fmt.Errorf("%w: metadata is limited to %d entries", ErrInvalid, maximumMetadata)
+	}
+	file, err := m.store.file(inputFileID)
+	if errors.Is(err, ErrNotFound) {
+		return Batch{}, fmt.Errorf("%w: input file %q not found", ErrInvalid, inputFileID)
+	} else if err != nil {
+		return Batch{}, err
+	}
+	if file.Purpose != PurposeBatch {
+		return Batch{}, fmt.Errorf("%w: input file %q doesn't have purpose %q", ErrInvalid, inputFileID, PurposeBatch)
+	}
+
+	id, err := newID("batch_")
+	if err != nil {
+		return Batch{}, err
+	}
+	now := time.Now()
+	batch := &Batch{
+		ID:               id,
+		Object:           "batch",
+		Endpoint:         endpoint,
+		InputFileID:      inputFileID,
+		CompletionWindow: window,
+		Status:           StatusValidating,
+		CreatedAt:        now.Unix(),
+		ExpiresAt:        now.Add(24 * time.Hour).Unix(),
+		Metadata:         metadata,
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err := m.store.saveBatch(batch); err != nil {
+		return Batch{}, err
+	}
+	m.batches[id] = batch
+	m.order = append(m.order, id)
+	m.queue = append(m.queue, id)
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return *batch, nil
+}
+
+// Batch returns a batch.
+func (m *Manager) Batch(id string) (Batch, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	batch, ok := m.batches[id]
+	if !ok {
+		return Batch{}, ErrNotFound
+	}
+	return *batch, nil
+}
+
+// Batches returns up to limit batches, most recent first, starting after the
+// batch with the ID after if set, and whether there are more.
+func (m *Manager) Batches(after string, limit int) ([]Batch, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	batches := make([]Batch, 0, len(m.order))
+	for _, id := range slices.Backward(m.order) {
+		batches = append(batches, *m.batches[id])
+	}
+	if after != "" {
+		if i := slices.IndexFunc(batches, func(b Batch) bool { return b.ID == after }); i >= 0 {
+			batches = batches[i+1:]
+		}
+	}
+	if len(batches) > limit {
+		return batches[:limit], true
+	}
+	return batches, false
+}
+
+// CancelBatch cancels a batch. Batches waiting to be processed are cancelled
+// right away, and the batch being processed once its requests in flight
+// complete, keeping their results.
+func (m *Manager) CancelBatch(id string) (Batch, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	batch, ok := m.batches[id]
+	if !ok {
+		return Batch{}, ErrNotFound
+	}
+	if batch.done() || batch.Status == StatusCancelling {
+		return Batch{}, fmt.Errorf("%w: batch %s is %s", ErrInvalid, id, batch.Status)
+	}
+	now := time.Now().Unix()
+	if id == m.running {
+		batch.Status = StatusCancelling
+		batch.CancellingAt = now
+		m.cancel(errCancelled)
+	} else {
+		m.queue = slices.DeleteFunc(m.queue, func(queued string) bool { return queued == id })
+		batch.Status = StatusCancelled
+		batch.CancellingAt = now
+		batch.CancelledAt = now
+	}
+	if err := m.store.saveBatch(batch); err != nil {
+		return Batch{}, err
+	}
+	return *batch, nil
+}
+
+// Run processes queued batches until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	for {
+		batch, batchCtx, stop, ok := m.dequeue(ctx)
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-m.wake:
+				continue
+			}
+		}
+		if err := m.process(batchCtx, stop, batch); err != nil {
+			m.log.Warnf("Failed to process batch %s: %v", batch.ID, err)
+		}
+		m.lock.Lock()
+		m.cancel(nil)
+		m.running, m.cancel = "", nil
+		m.lock.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// dequeue returns the next queued batch, which becomes the running batch, a
+// context for its requests that's cancelled with errExpired once it expires,
+// and a context derived from it that's cancelled with errCancelled if the
+// batch is cancelled, so that its requests in flight can complete.
+func (m *Manager) dequeue(ctx context.Context) (*Batch, context.Context, context.Context, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if len(m.queue) == 0 || ctx.Err() != nil {
+		return nil, nil, nil, false
+	}
+	batch := m.batches[m.queue[0]]
+	m.queue = m.queue[1:]
+	batchCtx, cancelDeadline := context.WithDeadlineCause(ctx, time.Unix(batch.ExpiresAt, 0), errExpired)
+	stop, cancel := context.WithCancelCause(batchCtx)
+	m.running = batch.ID
+	m.cancel = func(cause error) {
+		cancel(cause)
+		if cause == nil {
+			cancelDeadline()
+		}
+	}
+	return batch, batchCtx, stop, true
+}
+
+// update applies a change to a batch and saves it.
+func (m *Manager) update(batch *Batch, change func(*Batch)) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	change(batch)
+	return m.store.saveBatch(batch)
+}
+
+// process validates the input file of a batch, sends its requests until stop
+// is cancelled, and writes their results to its output and error files.
+func (m *Manager) process(ctx, stop context.Context, batch *Batch) error {
+	input, err := m.store.openFile(batch.InputFileID)
+	if err != nil {
+		message := fmt.Sprintf("failed to open input file: %v", err)
+		return m.fail(batch, []Error{{Code: "invalid_input_file", Message: message}})
+	}
+	requests, invalid, err := parseInput(input, batch.Endpoint)
+	input.Close()
+	if err != nil {
+		return m.fail(batch, []Error{{Code: "invalid_input_file", Message: err.Error()}})
+	}
+	if len(invalid) > 0 {
+		return m.fail(batch, invalid)
+	}
+
+	m.lock.Lock()
+	concurrency := m.concurrency
+	m.lock.Unlock()
+	if err := m.update(batch, func(b *Batch) {
+		if b.Status != StatusCancelling {
+			b.Status = StatusInProgress
+		}
+		b.InProgressAt = time.Now().Unix()
+		b.RequestCounts = RequestCounts{Total: len(requests)}
+	}); err != nil {
+		return err
+	}
+	m.log.Infof("Processing batch %s of %d requests", batch.ID, len(requests))
+
+	output, err := newResultsFile(m.store)
+	if err != nil {
+		return err
+	}
+	defer output.discard()
+	errorOutput, err := newResultsFile(m.store)
+	if err != nil {
+		return err
+	}
+	defer errorOutput.discard()
+
+	// Requests are sent by workers in the order of the input file, and their
+	// results are recorded as they complete.
+	var lock sync.Mutex
+	var lastSave time.Time
+	var next int
+	sent := make([]bool, len(requests))
+	var wg sync.WaitGroup
+	for range min(concurrency, len(requests)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				lock.Lock()
+				if next == len(requests) || stop.Err() != nil {
+					lock.Unlock()
+					return
+				}
+				i := next
+				next++
+				lock.Unlock()
+
+				result, ok := m.send(ctx, requests[i])
+				if !ok {
+					return
+				}
+				lock.Lock()
+				sent[i] = true
+				var writeErr error
+				if result.Error == nil && result.Response.StatusCode < http.StatusBadRequest {
+					writeErr = output.write(result)
+				} else {
+					writeErr = errorOutput.write(result)
+				}
+				if writeErr != nil {
+					m.log.Warnf("Failed to record the result of request %q of batch %s: %v", requests[i].CustomID, batch.ID, writeErr)
+				}
+				save := time.Since(lastSave) >= saveInterval
+				if save {
+					lastSave = time.Now()
+				}
+				m.lock.Lock()
+				if writeErr == nil && result.Error == nil && result.Response.StatusCode < http.StatusBadRequest {
+					batch.RequestCounts.Completed++
+				} else {
+					batch.RequestCounts.Failed++
+				}
+				if save {
+					if err := m.store.saveBatch(batch); err != nil {
+						m.log.Warnf("Failed to save batch %s: %v", batch.ID, err)
+					}
+				}
+				m.lock.Unlock()
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	status := StatusCompleted
+	switch {
+	case context.Cause(ctx) == errExpired:
+		status = StatusExpired
+		for i, request := range requests {
+			if sent[i] {
+				continue
+			}
+			if err := errorOutput.write(failure(request, "batch_expired", "the batch expired before the request was processed")); err != nil {
+				return err
+			}
+			m.lock.Lock()
+			batch.RequestCounts.Failed++
+			m.lock.Unlock()
+		}
+	case ctx.Err() != nil:
+		// Shutting down: the batch is processed again on restart.
+		return nil
+	case context.Cause(stop) == errCancelled:
+		status = StatusCancelled
+	}
+	return m.finalize(batch, status, output, errorOutput)
+}
+
+// finalize stores the output and error files of a batch and records its final
+// status.
+func (m *Manager) finalize(batch *Batch, status string, output, errorOutput *resultsFile) error {
+	if err := m.update(batch, func(b *Batch) {
+		b.Status = StatusFinalizing
+		b.FinalizingAt = time.Now().Unix()
+	}); err != nil {
+		return err
+	}
+	var outputFileID, errorFileID string
+	if output.count > 0 {
+		file, err := output.add(batch.ID + "_output.jsonl")
+		if err != nil {
+			return err
+		}
+		outputFileID = file.ID
+	}
+	if errorOutput.count > 0 {
+		file, err := errorOutput.add(batch.ID + "_error.jsonl")
+		if err != nil {
+			return err
+		}
+		errorFileID = file.ID
+	}
+	m.log.Infof("Batch %s is %s: %d requests completed, %d failed", batch.ID, status, batch.RequestCounts.Completed, batch.RequestCounts.Failed)
+	return m.update(batch, func(b *Batch) {
+		now := time.Now().Unix()
+		b.Status = status
+		b.OutputFileID = outputFileID
+		b.ErrorFileID = errorFileID
+		switch status {
+		case StatusCompleted:
+			b.CompletedAt = now
+		case StatusCancelled:
+			b.CancelledAt = now
+		case StatusExpired:
+			b.ExpiredAt = now
+		}
+	})
+}
+
+// fail records that the input file of a batch is invalid.
+func (m *Manager) fail(batch *Batch, errs []Error) error {
+	m.log.Infof("Batch %s failed validation: %s", batch.ID, errs[0].Message)
+	return m.update(batch, func(b *Batch) {
+		b.Status = StatusFailed
+		b.FailedAt = time.Now().Unix()
+		b.Errors = &Errors{Object: "list", Data: errs}
+	})
+}
+
+// send sends a request of a batch to the inference handler with a low
+// priority, retrying it while the scheduler is too busy to serve it. It
+// returns false if ctx is cancelled before the request completes.
+func (m *Manager) send(ctx context.Context, r request) (result, bool) {
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, inference.InferencePrefix+r.URL, bytes.NewReader(r.Body))
+		if err != nil {
+			return failure(r, "invalid_request", err.Error()), true
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(inference.RequestPriorityHeader, scheduling.PriorityLow.String())
+		recorder := relay.NewRecorder()
+		m.next.ServeHTTP(recorder, req)
+		if ctx.Err() != nil {
+			return result{}, false
+		}
+
+		busy := recorder.Status() == http.StatusTooManyRequests || recorder.Status() == http.StatusServiceUnavailable
+		if busy && attempt < maximumAttempts {
+			wait := delay
+			if seconds, err := strconv.Atoi(recorder.Header().Get("Retry-After")); err == nil && seconds > 0 {
+				wait = time.Duration(seconds) * time.Second
+			}
+			delay = min(2*delay, maximumRetryDelay)
+			select {
+			case <-ctx.Done():
+				return result{}, false
+			case <-time.After(wait):
+				continue
+			}
+		}
+
+		id, err := newID("batch_req_")
+		if err != nil {
+			return failure(r, "internal_error", err.Error()), true
+		}
+		body := bytes.TrimSpace(recorder.Body())
+		if !json.Valid(body) {
+			body, _ = json.Marshal(map[string]any{"error": map[string]string{"message": string(body)}})
+		}
+		return result{
+			ID:       id,
+			CustomID: r.CustomID,
+			Response: &response{StatusCode: recorder.Status(), RequestID: id, Body: body},
+		}, true
+	}
+}
+
+// failure returns the result of a request that couldn't be sent.
+func failure(r request, code, message string) result {
+	id, _ := newID("batch_req_")
+	return result{ID: id, CustomID: r.CustomID, Error: &Error{Code: code, Message: message}}
+}
+
+// resultsFile is a batch output or error file being written.
+type resultsFile struct {
+	store  *store
+	file   *os.File
+	writer *bufio.Writer
+	// count is the number of results written.
+	count int
+}
+
+// newResultsFile creates a temporary results file in a store.
+func newResultsFile(s *store) (*resultsFile, error) {
+	f, err := s.createTemp()
+	if err != nil {
+		return nil, err
+	}
+	return &resultsFile{store: s, file: f, writer: bufio.NewWriter(f)}, nil
+}
+
+// write appends a result to the file.
+func (f *resultsFile) write(r result) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := f.writer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	f.count++
+	return nil
+}
+
+// add adds the file to the store as a batch output file.
+func (f *resultsFile) add(filename string) (File, error) {
+	if err := f.writer.Flush(); err != nil {
+		return File{}, err
+	}
+	if err := f.file.Close(); err != nil {
+		return File{}, err
+	}
+	return f.store.addFile(f.file.Name(), filename, PurposeBatchOutput)
+}
+
+// discard removes the file if it wasn't added to the store.
+func (f *resultsFile) discard() {
+	f.file.Close()
+	os.Remove(f.file.Name())
+}
@@ -0,0 +1,256 @@
+package batches
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNotFound indicates that a file or batch doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// validID matches the IDs of files and batches, which are used as file names.
+var validID = regexp.MustCompile(`^(file-|batch_)[0-9a-f]{24}$`)
+
+// newID generates a random ID with a prefix.
+func newID(prefix string) (string, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("generating ID: %w", err)
+	}
+	return prefix + hex.EncodeToString(id), nil
+}
+
+// store persists files and batches in a directory, with the content and
+// metadata of files in its files subdirectory and batches in its batches
+// subdirectory.
+type store struct {
+	// dir is the root directory of the store.
+	dir string
+}
+
+// newStore creates a store in a directory, creating it if needed, and removes
+// the temporary files left behind by an interrupted run.
+func newStore(dir string) (*store, error) {
+	s := &store{dir: dir}
+	for _, sub := range []string{s.filesDir(), s.batchesDir()} {
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			return nil, fmt.Errorf("creating %s: %w", sub, err)
+		}
+		temporary, err := filepath.Glob(filepath.Join(sub, ".tmp-*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range temporary {
+			os.Remove(path)
+		}
+	}
+	return s, nil
+}
+
+func (s *store) filesDir() string {
+	return filepath.Join(s.dir, "files")
+}
+
+func (s *store) batchesDir() string {
+	return filepath.Join(s.dir, "batches")
+}
+
+func (s *store) contentPath(id string) string {
+	return filepath.Join(s.filesDir(), id)
+}
+
+func (s *store) filePath(id string) string {
+	return filepath.Join(s.filesDir(), id+".json")
+}
+
+func (s *store) batchPath(id string) string {
+	return filepath.Join(s.batchesDir(), id+".json")
+}
+
+// createTemp creates a temporary file in the store, which becomes a file of
+// the store once it's added with addFile.
+func (s *store) createTemp() (*os.File, error) {
+	return os.CreateTemp(s.filesDir(), ".tmp-*")
+}
+
+// createFile stores the content read from r as a new file. It fails if the
+// content is larger than limit.
+func (s *store) createFile(filename, purpose string, r io.Reader, limit int64) (File, error) {
+	f, err := s.createTemp()
+	if err != nil {
+		return File{}, err
+	}
+	defer os.Remove(f.Name())
+	n, err := io.Copy(f, io.LimitReader(r, limit+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return File{}, err
+	}
+	if n > limit {
+		return File{}, fmt.Errorf("%w: files are limited to %d bytes", ErrInvalid, limit)
+	}
+	return s.addFile(f.Name(), filename, purpose)
+}
+
+// addFile moves a temporary file created with createTemp into the store.
+func (s *store) addFile(path, filename, purpose string) (File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return File{}, err
+	}
+	id, err := newID("file-")
+	if err != nil {
+		return File{}, err
+	}
+	file := File{
+		ID:        id,
+		Object:    "file",
+		Bytes:     info.Size(),
+		CreatedAt: time.Now().Unix(),
+		Filename:  filename,
+		Purpose:   purpose,
+	}
+	if err := os.Rename(path, s.contentPath(id)); err != nil {
+		return File{}, err
+	}
+	if err := writeJSON(s.filePath(id), file); err != nil {
+		os.Remove(s.contentPath(id))
+		return File{}, err
+	}
+	return file, nil
+}
+
+// file returns the metadata of a file.
+func (s *store) file(id string) (File, error) {
+	var file File
+	if !validID.MatchString(id) {
+		return file, ErrNotFound
+	}
+	if err := readJSON(s.filePath(id), &file); err != nil {
+		return file, err
+	}
+	return file, nil
+}
+
+// files returns the metadata of every file, most recent first.
+func (s *store) files() ([]File, error) {
+	entries, err := os.ReadDir(s.filesDir())
+	if err != nil {
+		return nil, err
+	}
+	files := []File{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		file, err := s.file(id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	slices.SortFunc(files, func(a, b File) int {
+		return cmp.Or(cmp.Compare(b.CreatedAt, a.CreatedAt), strings.Compare(b.ID, a.ID))
+	})
+	return files, nil
+}
+
+// openFile opens the content of a file.
+func (s *store) openFile(id string) (*os.File, error) {
+	if _, err := s.file(id); err != nil {
+		return nil, err
+	}
+	return os.Open(s.contentPath(id))
+}
+
+// deleteFile deletes a file.
+func (s *store) deleteFile(id string) error {
+	if _, err := s.file(id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.filePath(id)); err != nil {
+		return err
+	}
+	if err := os.Remove(s.contentPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// saveBatch stores a batch.
+func (s *store) saveBatch(batch *Batch) error {
+	return writeJSON(s.batchPath(batch.ID), batch)
+}
+
+// batches returns every stored batch.
+func (s *store) batches() ([]*Batch, error) {
+	entries, err := os.ReadDir(s.batchesDir())
+	if err != nil {
+		return nil, err
+	}
+	var batches []*Batch
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !validID.MatchString(id) {
+			continue
+		}
+		batch := &Batch{}
+		if err := readJSON(s.batchPath(id), batch); err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+// readJSON decodes a JSON file, returning ErrNotFound if it doesn't exist.
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSON atomically writes a value to a JSON file.
+func writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -31,6 +31,19 @@ func FromGGUF(path string) (*Builder, error) {
 	}, nil
 }
 
+// FromAdapter returns a *Builder that builds an adapter artifact from a LoRA
+// adapter in GGUF format. Adapters are applied to the base model that they
+// were trained for, by referencing them as BASE+ADAPTER.
+func FromAdapter(path string) (*Builder, error) {
+	mdl, err := gguf.NewAdapter(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Builder{
+		model: mdl,
+	}, nil
+}
+
 // FromSafetensors returns a *Builder that builds model artifacts from safetensors files
 func FromSafetensors(safetensorsPaths []string) (*Builder, error) {
 	mdl, err := safetensors.NewModel(safetensorsPaths)
@@ -74,6 +87,14 @@ func (b *Builder) WithContextSize(size uint64) *Builder {
 	}
 }
 
+// WithDerivedFrom records the ID of the model that the artifact is derived from.
+func (b *Builder) WithDerivedFrom(id string) *Builder {
+	return &Builder{
+		model:          mutate.DerivedFrom(b.model, id),
+		originalLayers: b.originalLayers,
+	}
+}
+
 // WithMultimodalProjector adds a Multimodal projector file to the artifact
 func (b *Builder) WithMultimodalProjector(path string) (*Builder, error) {
 	mmprojLayer, err := partial.NewLayer(path, types.MediaTypeMultimodalProjector)
@@ -0,0 +1,174 @@
+package distribution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/authn"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/registry"
+
+	mdregistry "github.com/docker/model-runner/pkg/distribution/registry"
+)
+
+// tokenRegistry is a test registry that issues bearer tokens to clients with
+// the expected credentials. Each token is only valid for a single request, so
+// clients have to refresh it throughout a pull.
+type tokenRegistry struct {
+	username, password string
+
+	mu     sync.Mutex
+	issued int
+	valid  map[string]bool
+}
+
+// newTokenRegistry starts a registry that requires the specified credentials,
+// and returns the host of the registry along with that of an unauthenticated
+// server for the same repositories.
+func newTokenRegistry(t *testing.T, username, password string) (*tokenRegistry, string, string) {
+	t.Helper()
+	tr := &tokenRegistry{username: username, password: password, valid: map[string]bool{}}
+	upstream := registry.New()
+
+	var realm string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tr.issueToken(w, r)
+			return
+		}
+		if !tr.useToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q,service="test"`, realm))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		upstream.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	realm = server.URL + "/token"
+	anonymous := httptest.NewServer(upstream)
+	t.Cleanup(anonymous.Close)
+
+	return tr, hostOf(t, server.URL), hostOf(t, anonymous.URL)
+}
+
+// issueToken issues a token if the request has the expected credentials.
+func (tr *tokenRegistry) issueToken(w http.ResponseWriter, r *http.Request) {
+	if username, password, ok := r.BasicAuth(); !ok || username != tr.username || password != tr.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	tr.mu.Lock()
+	tr.issued++
+	token := fmt.Sprintf("token-%d", tr.issued)
+	tr.valid[token] = true
+	tr.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
+
+// useToken returns true if the token is valid, invalidating it.
+func (tr *tokenRegistry) useToken(token string) bool {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if !tr.valid[token] {
+		return false
+	}
+	delete(tr.valid, token)
+	return true
+}
+
+// tokensIssued returns the number of tokens issued by the registry.
+func (tr *tokenRegistry) tokensIssued() int {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.issued
+}
+
+func hostOf(t *testing.T, serverURL string) string {
+	t.Helper()
+	uri, err := url.Parse(serverURL)
+	if err != nil {
+		t.Fatalf("Failed to parse server URL: %v", err)
+	}
+	return uri.Host
+}
+
+func TestPullModelWithAuth(t *testing.T) {
+	// Don't use the credentials of the Docker configuration running the tests
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	tr, host, anonymousHost := newTokenRegistry(t, "user", "secret")
+	if err := writeToRegistry(testGGUFFile, anonymousHost+"/private/model:v1"); err != nil {
+		t.Fatalf("Failed to write model to registry: %v", err)
+	}
+	reference := host + "/private/model:v1"
+
+	client, err := NewClient(WithStoreRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.PullModel(context.Background(), reference, nil); !errors.Is(err, mdregistry.ErrUnauthorized) {
+		t.Fatalf("Expected unauthorized error without credentials, got %v", err)
+	}
+	if err := client.PullModelWithAuth(context.Background(), reference, nil, authn.AuthConfig{Username: "user", Password: "wrong"}); err == nil {
+		t.Fatalf("Expected error with wrong credentials")
+	}
+	if err := client.PullModelWithAuth(context.Background(), reference, nil, authn.AuthConfig{Username: "user", Password: "secret"}); err != nil {
+		t.Fatalf("Failed to pull model: %v", err)
+	}
+	if _, err := client.GetModel(reference); err != nil {
+		t.Fatalf("Failed to get pulled model: %v", err)
+	}
+	// The manifest and each blob needs a fresh token
+	if tr.tokensIssued() < 3 {
+		t.Errorf("Expected the token to be refreshed during the pull, got %d tokens", tr.tokensIssued())
+	}
+}
+
+func TestPullModelWithCredentialHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Credential helper script requires a POSIX shell")
+	}
+	tr, host, anonymousHost := newTokenRegistry(t, "helper-user", "helper-secret")
+	if err := writeToRegistry(testGGUFFile, anonymousHost+"/private/model:v1"); err != nil {
+		t.Fatalf("Failed to write model to registry: %v", err)
+	}
+	reference := host + "/private/model:v1"
+
+	// Configure a credential helper for the registry in the Docker configuration
+	binDir := t.TempDir()
+	helper := "#!/bin/sh\nread server\necho '{\"ServerURL\":\"'$server'\",\"Username\":\"helper-user\",\"Secret\":\"helper-secret\"}'\n"
+	if err := os.WriteFile(filepath.Join(binDir, "docker-credential-test"), []byte(helper), 0o755); err != nil {
+		t.Fatalf("Failed to write credential helper: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	configDir := t.TempDir()
+	config := fmt.Sprintf(`{"credHelpers": {%q: "test"}}`, host)
+	if err := os.WriteFile(filepath.Join(configDir, "config.json"), []byte(config), 0o644); err != nil {
+		t.Fatalf("Failed to write Docker configuration: %v", err)
+	}
+	t.Setenv("DOCKER_CONFIG", configDir)
+
+	client, err := NewClient(WithStoreRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.PullModel(context.Background(), reference, nil); err != nil {
+		t.Fatalf("Failed to pull model: %v", err)
+	}
+	if _, err := client.GetModel(reference); err != nil {
+		t.Fatalf("Failed to get pulled model: %v", err)
+	}
+	if tr.tokensIssued() == 0 {
+		t.Errorf("Expected a token to be issued for the helper's credentials")
+	}
+}
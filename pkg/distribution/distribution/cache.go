@@ -0,0 +1,38 @@
+package distribution
+
+import (
+	"fmt"
+	"os"
+
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+	ggcrtypes "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/types"
+)
+
+// ReadManifest returns the raw manifest of a model in the store, along with
+// its media type and digest, as served by a registry.
+func (c *Client) ReadManifest(reference string) ([]byte, ggcrtypes.MediaType, v1.Hash, error) {
+	mdl, err := c.store.Read(reference)
+	if err != nil {
+		return nil, "", v1.Hash{}, fmt.Errorf("reading model from store: %w", err)
+	}
+	raw, err := mdl.RawManifest()
+	if err != nil {
+		return nil, "", v1.Hash{}, fmt.Errorf("reading manifest: %w", err)
+	}
+	mediaType, err := mdl.MediaType()
+	if err != nil {
+		return nil, "", v1.Hash{}, fmt.Errorf("getting manifest media type: %w", err)
+	}
+	digest, err := mdl.Digest()
+	if err != nil {
+		return nil, "", v1.Hash{}, fmt.Errorf("getting manifest digest: %w", err)
+	}
+	return raw, mediaType, digest, nil
+}
+
+// OpenBlob opens a blob in the store, such as a layer or the config of a
+// model, by digest. It returns an error satisfying
+// errors.Is(err, fs.ErrNotExist) if the blob isn't in the store.
+func (c *Client) OpenBlob(digest v1.Hash) (*os.File, error) {
+	return c.store.OpenBlob(digest)
+}
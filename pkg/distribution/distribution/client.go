@@ -2,31 +2,71 @@ package distribution
 
 import (
 	"context"
+	"crypto"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/docker/go-units"
 	"github.com/docker/model-runner/pkg/internal/utils"
 	"github.com/sirupsen/logrus"
 
+	"github.com/docker/model-runner/pkg/distribution/huggingface"
 	"github.com/docker/model-runner/pkg/distribution/internal/progress"
 	"github.com/docker/model-runner/pkg/distribution/internal/store"
 	"github.com/docker/model-runner/pkg/distribution/registry"
 	"github.com/docker/model-runner/pkg/distribution/tarball"
 	"github.com/docker/model-runner/pkg/distribution/types"
 	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/authn"
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
 	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/remote"
 	"github.com/docker/model-runner/pkg/inference/platform"
 )
 
+const (
+	// pullRetries is the number of times that an interrupted download is
+	// resumed within a single pull.
+	pullRetries = 3
+	// pullRetryDelay is the delay before an interrupted download is first
+	// resumed, which doubles with each further attempt.
+	pullRetryDelay = time.Second
+	// downloadChunkSize is the size of the chunks that layers are downloaded
+	// in when chunked downloads are enabled.
+	downloadChunkSize = 16 * 1024 * 1024
+)
+
 // Client provides model distribution functionality
 type Client struct {
-	store    *store.LocalStore
-	log      *logrus.Entry
-	registry *registry.Client
+	store          *store.LocalStore
+	log            *logrus.Entry
+	registry       *registry.Client
+	hub            *huggingface.Client
+	digestPinning  DigestPinningMode
+	downloadChunks int
+
+	signatureVerification SignatureVerificationMode
+	signatureKeys         []crypto.PublicKey
+
+	storeQuota uint64
+	modelInUse func(id string) bool
+
+	// peers are the base URLs of the model runners that layers are fetched
+	// from before falling back to their registry, using transport and
+	// authenticated with peerToken, if any.
+	peers     []string
+	peerToken string
+	transport http.RoundTripper
+
+	// writes is held for reading while models are written to the store, and
+	// for writing while models are deleted or garbage is collected, as the
+	// blobs of a model aren't referenced until its manifest is written. It's
+	// held along with the store lock, which does the same across processes.
+	writes sync.RWMutex
 }
 
 // GetStorePath returns the root path where models are stored
@@ -39,12 +79,25 @@ type Option func(*options)
 
 // options holds the configuration for a new Client
 type options struct {
-	storeRootPath string
-	logger        *logrus.Entry
-	transport     http.RoundTripper
-	userAgent     string
-	username      string
-	password      string
+	storeRootPath  string
+	logger         *logrus.Entry
+	transport      http.RoundTripper
+	userAgent      string
+	username       string
+	password       string
+	digestPinning  DigestPinningMode
+	minFreeSpace   uint64
+	downloadChunks int
+
+	signatureVerification SignatureVerificationMode
+	signatureKeys         []crypto.PublicKey
+
+	storeQuota uint64
+	modelInUse func(id string) bool
+
+	mirrors   registry.Mirrors
+	peers     []string
+	peerToken string
 }
 
 // WithStoreRootPath sets the store root path
@@ -93,11 +146,84 @@ func WithRegistryAuth(username, password string) Option {
 	}
 }
 
+// WithDigestPinning sets how the client reacts when a previously pulled tag
+// resolves to a different manifest digest.
+func WithDigestPinning(mode DigestPinningMode) Option {
+	return func(o *options) {
+		if mode != "" {
+			o.digestPinning = mode
+		}
+	}
+}
+
+// WithMinFreeSpace sets the free disk space, in bytes, kept in reserve when
+// writing models to the store. Pulls that would eat into the reserve are
+// refused, and downloads pause while free space is below it. Zero disables the
+// reserve.
+func WithMinFreeSpace(bytes uint64) Option {
+	return func(o *options) {
+		o.minFreeSpace = bytes
+	}
+}
+
+// WithDownloadChunks sets the number of concurrent ranged requests that each
+// large layer is downloaded with. Values below 2 download layers with a single
+// request.
+func WithDownloadChunks(chunks int) Option {
+	return func(o *options) {
+		o.downloadChunks = chunks
+	}
+}
+
+// WithSignatureVerification sets whether pulled models are verified to have a
+// cosign signature made with one of the trusted public keys.
+func WithSignatureVerification(mode SignatureVerificationMode, keys ...crypto.PublicKey) Option {
+	return func(o *options) {
+		if mode != "" {
+			o.signatureVerification = mode
+		}
+		o.signatureKeys = append(o.signatureKeys, keys...)
+	}
+}
+
+// WithStoreQuota sets the maximum disk space, in bytes, used by the models in
+// the store. When writing a model would exceed it, the least recently used
+// models that aren't pinned or in use are evicted. If zero, there's no quota.
+func WithStoreQuota(bytes uint64) Option {
+	return func(o *options) {
+		o.storeQuota = bytes
+	}
+}
+
+// WithModelInUse sets the function that reports whether the model with an ID
+// is in use, such as loaded by a backend, in which case it isn't evicted.
+func WithModelInUse(inUse func(id string) bool) Option {
+	return func(o *options) {
+		if inUse != nil {
+			o.modelInUse = inUse
+		}
+	}
+}
+
+// WithRegistryMirrors sets the mirrors that models are pulled from before
+// falling back to their registries, such as a pull-through cache shared by
+// the model runners of a network. Signatures are always read from the
+// registries themselves.
+func WithRegistryMirrors(mirrors registry.Mirrors) Option {
+	return func(o *options) {
+		o.mirrors = mirrors
+	}
+}
+
 func defaultOptions() *options {
 	return &options{
-		logger:    logrus.NewEntry(logrus.StandardLogger()),
-		transport: registry.DefaultTransport,
-		userAgent: registry.DefaultUserAgent,
+		logger:        logrus.NewEntry(logrus.StandardLogger()),
+		transport:     registry.DefaultTransport,
+		userAgent:     registry.DefaultUserAgent,
+		digestPinning: DigestPinningWarn,
+		minFreeSpace:  DefaultMinFreeSpace,
+
+		signatureVerification: SignatureVerificationOff,
 	}
 }
 
@@ -111,18 +237,34 @@ func NewClient(opts ...Option) (*Client, error) {
 	if options.storeRootPath == "" {
 		return nil, fmt.Errorf("store root path is required")
 	}
+	if options.signatureVerification != SignatureVerificationOff && len(options.signatureKeys) == 0 {
+		return nil, fmt.Errorf("signature verification requires at least one trusted public key")
+	}
 
 	s, err := store.New(store.Options{
-		RootPath: options.storeRootPath,
+		RootPath:     options.storeRootPath,
+		MinFreeSpace: options.minFreeSpace,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("initializing store: %w", err)
 	}
 
+	// Quarantine models with missing or corrupt content so that they don't
+	// break listing and scheduling of the other models
+	quarantined, err := s.Scan()
+	if err != nil {
+		options.logger.Warnf("Failed to scan store for integrity: %v", err)
+	}
+	for _, m := range quarantined {
+		options.logger.Warnf("Quarantined model %s %v: %s; pull it again to restore it",
+			utils.SanitizeForLog(m.ID), m.Tags, m.Reason)
+	}
+
 	// Create registry client options
 	registryOpts := []registry.ClientOption{
 		registry.WithTransport(options.transport),
 		registry.WithUserAgent(options.userAgent),
+		registry.WithMirrors(options.mirrors),
 	}
 
 	// Add auth if credentials are provided
@@ -132,15 +274,33 @@ func NewClient(opts ...Option) (*Client, error) {
 
 	options.logger.Infoln("Successfully initialized store")
 	return &Client{
-		store:    s,
-		log:      options.logger,
-		registry: registry.NewClient(registryOpts...),
+		store:          s,
+		log:            options.logger,
+		registry:       registry.NewClient(registryOpts...),
+		hub:            huggingface.NewClient(huggingface.WithTransport(options.transport), huggingface.WithUserAgent(options.userAgent)),
+		digestPinning:  options.digestPinning,
+		downloadChunks: options.downloadChunks,
+
+		signatureVerification: options.signatureVerification,
+		signatureKeys:         options.signatureKeys,
+
+		storeQuota: options.storeQuota,
+		modelInUse: options.modelInUse,
+
+		peers:     options.peers,
+		peerToken: options.peerToken,
+		transport: options.transport,
 	}, nil
 }
 
-// PullModel pulls a model from a registry and returns the local file path
+// PullModel pulls a model from a registry and returns the local file path.
+// References to Hugging Face Hub repositories are pulled from the Hub
+// directly.
 func (c *Client) PullModel(ctx context.Context, reference string, progressWriter io.Writer, bearerToken ...string) error {
 	c.log.Infoln("Starting model pull:", utils.SanitizeForLog(reference))
+	if huggingface.IsReference(reference) {
+		return c.pullFromHub(ctx, reference, progressWriter, bearerToken...)
+	}
 
 	// Use the client's registry, or create a temporary one if bearer token is provided
 	registryClient := c.registry
@@ -149,6 +309,31 @@ func (c *Client) PullModel(ctx context.Context, reference string, progressWriter
 		auth := &authn.Bearer{Token: bearerToken[0]}
 		registryClient = registry.FromClient(c.registry, registry.WithAuth(auth))
 	}
+	return c.pull(ctx, registryClient, reference, progressWriter)
+}
+
+// PullModelWithAuth pulls a model from a registry like PullModel, but
+// authenticates with the specified credentials rather than those found in the
+// Docker configuration and its credential helpers. Credentials with a username
+// and password or an identity token allow the registry token to be refreshed
+// if it expires during the pull.
+func (c *Client) PullModelWithAuth(ctx context.Context, reference string, progressWriter io.Writer, auth authn.AuthConfig) error {
+	c.log.Infoln("Starting model pull:", utils.SanitizeForLog(reference))
+	if huggingface.IsReference(reference) {
+		return c.pullFromHub(ctx, reference, progressWriter)
+	}
+	registryClient := c.registry
+	if auth != (authn.AuthConfig{}) {
+		registryClient = registry.FromClient(c.registry, registry.WithAuth(authn.FromConfig(auth)))
+	}
+	return c.pull(ctx, registryClient, reference, progressWriter)
+}
+
+// pull pulls a model from a registry with the specified registry client.
+func (c *Client) pull(ctx context.Context, registryClient *registry.Client, reference string, progressWriter io.Writer) error {
+	if c.downloadChunks > 1 {
+		ctx = remote.WithChunkedDownloads(ctx, c.downloadChunks, downloadChunkSize)
+	}
 
 	// First, fetch the remote model to get the manifest
 	remoteModel, err := registryClient.Model(ctx, reference)
@@ -165,39 +350,23 @@ func (c *Client) PullModel(ctx context.Context, reference string, progressWriter
 	}
 	c.log.Infoln("Remote model digest:", remoteDigest.String())
 
+	// Verify the digest against the one recorded when the tag was first pulled
+	if err := c.checkPinnedDigest(reference, remoteDigest.String(), progressWriter); err != nil {
+		return err
+	}
+
+	// Verify that the manifest is signed by a trusted key
+	if err := c.verifySignature(ctx, registryClient, reference, remoteDigest, progressWriter); err != nil {
+		return err
+	}
+
 	// Check for incomplete downloads and prepare resume offsets
 	layers, err := remoteModel.Layers()
 	if err != nil {
 		return fmt.Errorf("getting layers: %w", err)
 	}
 
-	// Build a map of digest -> resume offset for layers with incomplete downloads
-	resumeOffsets := make(map[string]int64)
-	for _, layer := range layers {
-		digest, err := layer.Digest()
-		if err != nil {
-			c.log.Warnf("Failed to get layer digest: %v", err)
-			continue
-		}
-
-		// Check if there's an incomplete download for this layer (use DiffID for uncompressed models)
-		diffID, err := layer.DiffID()
-		if err != nil {
-			c.log.Warnf("Failed to get layer diffID: %v", err)
-			continue
-		}
-
-		incompleteSize, err := c.store.GetIncompleteSize(diffID)
-		if err != nil {
-			c.log.Warnf("Failed to check incomplete size for layer %s: %v", digest, err)
-			continue
-		}
-
-		if incompleteSize > 0 {
-			c.log.Infof("Found incomplete download for layer %s: %d bytes", digest, incompleteSize)
-			resumeOffsets[digest.String()] = incompleteSize
-		}
-	}
+	resumeOffsets := c.resumeOffsets(layers)
 
 	// If we have any incomplete downloads, create a new context with resume offsets
 	// and re-fetch using the original reference to ensure compatibility with all registries
@@ -236,6 +405,8 @@ func (c *Client) PullModel(ctx context.Context, reference string, progressWriter
 		if err := c.store.AddTags(remoteDigest.String(), []string{reference}); err != nil {
 			return fmt.Errorf("tagging model: %w", err)
 		}
+		c.pinDigest(reference, remoteDigest.String())
+		c.markUsed(remoteDigest.String())
 		return nil
 	} else {
 		c.log.Infoln("Model not found in local store, pulling from remote:", utils.SanitizeForLog(reference))
@@ -243,12 +414,19 @@ func (c *Client) PullModel(ctx context.Context, reference string, progressWriter
 
 	// Model doesn't exist in local store or digests don't match, pull from remote
 
-	if err = c.store.Write(remoteModel, []string{reference}, progressWriter); err != nil {
+	// Make sure the download fits before starting it
+	if err := c.ensureSpaceForLayers(layers, remoteDigest.String(), progressWriter); err != nil {
+		return err
+	}
+
+	if err = c.writeWithResume(ctx, registryClient, reference, remoteModel, c.newPeerFetcher(ctx), progressWriter); err != nil {
 		if writeErr := progress.WriteError(progressWriter, fmt.Sprintf("Error: %s", err.Error())); writeErr != nil {
 			c.log.Warnf("Failed to write error message: %v", writeErr)
 		}
 		return fmt.Errorf("writing image to store: %w", err)
 	}
+	c.pinDigest(reference, remoteDigest.String())
+	c.markUsed(remoteDigest.String())
 
 	if err := progress.WriteSuccess(progressWriter, "Model pulled successfully"); err != nil {
 		c.log.Warnf("Failed to write success message: %v", err)
@@ -257,9 +435,150 @@ func (c *Client) PullModel(ctx context.Context, reference string, progressWriter
 	return nil
 }
 
+// resumeOffsets returns a map of digest -> resume offset for layers with
+// incomplete downloads.
+func (c *Client) resumeOffsets(layers []v1.Layer) map[string]int64 {
+	resumeOffsets := make(map[string]int64)
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			c.log.Warnf("Failed to get layer digest: %v", err)
+			continue
+		}
+
+		// Check if there's an incomplete download for this layer (use DiffID for uncompressed models)
+		diffID, err := layer.DiffID()
+		if err != nil {
+			c.log.Warnf("Failed to get layer diffID: %v", err)
+			continue
+		}
+
+		incompleteSize, err := c.store.GetIncompleteSize(diffID)
+		if err != nil {
+			c.log.Warnf("Failed to check incomplete size for layer %s: %v", digest, err)
+			continue
+		}
+
+		if incompleteSize > 0 {
+			c.log.Infof("Found incomplete download for layer %s: %d bytes", digest, incompleteSize)
+			resumeOffsets[digest.String()] = incompleteSize
+		}
+	}
+	return resumeOffsets
+}
+
+// writeWithResume writes a remote model to the store, fetching its layers from
+// peers if any. If the download is interrupted after some of it was stored,
+// it's resumed from where it stopped, up to pullRetries times. Layers that a
+// peer served corrupt are downloaded again, from another peer or the registry.
+func (c *Client) writeWithResume(ctx context.Context, registryClient *registry.Client, reference string, remoteModel types.ModelArtifact, peers *peerFetcher, progressWriter io.Writer) error {
+	remoteDigest, err := remoteModel.Digest()
+	if err != nil {
+		return fmt.Errorf("getting remote image digest: %w", err)
+	}
+	layers, err := remoteModel.Layers()
+	if err != nil {
+		return fmt.Errorf("getting layers: %w", err)
+	}
+	unlock, err := c.lockWrites()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	for attempt := 1; ; attempt++ {
+		err := c.store.WriteContext(ctx, withPeers(remoteModel, peers), []string{reference}, progressWriter)
+		if err == nil || attempt > pullRetries || ctx.Err() != nil ||
+			errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+			errors.Is(err, ErrInsufficientSpace) {
+			return err
+		}
+		resumeOffsets := c.resumeOffsets(layers)
+		if len(resumeOffsets) == 0 && !errors.Is(err, store.ErrBlobDigestMismatch) {
+			// Nothing was stored, so there's nothing to resume.
+			return err
+		}
+
+		delay := pullRetryDelay << (attempt - 1)
+		c.log.Warnf("Download of %s interrupted, resuming in %s (attempt %d of %d): %v",
+			utils.SanitizeForLog(reference), delay, attempt, pullRetries, err)
+		if writeErr := progress.WriteWarning(progressWriter, fmt.Sprintf("Download interrupted, resuming in %s", delay)); writeErr != nil {
+			c.log.Warnf("Failed to write warning message: %v", writeErr)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		remoteModel, err = registryClient.Model(remote.WithResumeOffsets(ctx, resumeOffsets), reference)
+		if err != nil {
+			return fmt.Errorf("reading model from registry with resume context: %w", err)
+		}
+		digest, err := remoteModel.Digest()
+		if err != nil {
+			return fmt.Errorf("getting remote image digest: %w", err)
+		}
+		if digest != remoteDigest {
+			return fmt.Errorf("model %s changed during download", utils.SanitizeForLog(reference))
+		}
+	}
+}
+
+// ensureSpaceForLayers checks that the layers which still need to be
+// downloaded fit in the store, evicting models to stay within the store quota
+// if needed. The model with the keep ID, which is being pulled, isn't evicted.
+func (c *Client) ensureSpaceForLayers(layers []v1.Layer, keep string, progressWriter io.Writer) error {
+	var required, missing, reused int64
+	var reusedLayers int
+	for _, layer := range layers {
+		size, err := layer.Size()
+		if err != nil {
+			return fmt.Errorf("getting layer size: %w", err)
+		}
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return fmt.Errorf("getting layer diffID: %w", err)
+		}
+		if c.store.HasBlob(diffID) {
+			// The layer is shared with a model that's already in the store.
+			reusedLayers++
+			reused += size
+			continue
+		}
+		missing += size
+		incompleteSize, err := c.store.GetIncompleteSize(diffID)
+		if err != nil {
+			c.log.Warnf("Failed to check incomplete size for layer %s: %v", diffID, err)
+		}
+		required += size - incompleteSize
+	}
+
+	if reusedLayers > 0 {
+		c.log.Infof("Reusing %d layer(s) already in the store, skipping %s of downloads",
+			reusedLayers, units.BytesSize(float64(reused)))
+	}
+
+	if err := c.ensureQuota(missing, keep, progressWriter); err != nil {
+		return err
+	}
+	if err := c.store.EnsureSpace(required); err != nil {
+		c.log.Errorln("Not enough disk space to pull model:", err)
+		if writeErr := progress.WriteError(progressWriter, fmt.Sprintf("Error: %s", err.Error())); writeErr != nil {
+			c.log.Warnf("Failed to write error message: %v", writeErr)
+		}
+		return err
+	}
+	return nil
+}
+
 // LoadModel loads the model from the reader to the store
 func (c *Client) LoadModel(r io.Reader, progressWriter io.Writer) (string, error) {
 	c.log.Infoln("Starting model load")
+	unlock, err := c.lockWrites()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
 
 	tr := tarball.NewReader(r)
 	for {
@@ -289,6 +608,12 @@ func (c *Client) LoadModel(r io.Reader, progressWriter io.Writer) (string, error
 	if err := c.store.WriteManifest(digest, manifest); err != nil {
 		return "", fmt.Errorf("write manifest: %w", err)
 	}
+	if tags := tr.Tags(); len(tags) > 0 {
+		c.log.Infoln("Tagging loaded model:", utils.SanitizeForLog(strings.Join(tags, ", ")))
+		if err := c.store.AddTags(digest.String(), tags); err != nil {
+			return "", fmt.Errorf("tagging model: %w", err)
+		}
+	}
 	c.log.Infoln("Loaded model with ID:", digest.String())
 
 	if err := progress.WriteSuccess(progressWriter, "Model loaded successfully"); err != nil {
@@ -298,6 +623,25 @@ func (c *Client) LoadModel(r io.Reader, progressWriter io.Writer) (string, error
 	return digest.String(), nil
 }
 
+// ExportModel writes a model in the store, with all of its blobs and its
+// manifest, to an archive that LoadModel loads, such as on another machine.
+// The archive records the model's tags, which are applied when it's loaded.
+func (c *Client) ExportModel(ctx context.Context, reference string, w io.Writer) error {
+	mdl, err := c.store.Read(reference)
+	if err != nil {
+		return fmt.Errorf("reading model from store: %w", err)
+	}
+	target, err := tarball.NewTarget(w, mdl.Tags()...)
+	if err != nil {
+		return fmt.Errorf("creating archive: %w", err)
+	}
+	c.log.Infoln("Exporting model:", utils.SanitizeForLog(reference))
+	if err := target.Write(ctx, mdl, nil); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+	return nil
+}
+
 // ListModels returns all available models
 func (c *Client) ListModels() ([]types.Model, error) {
 	c.log.Infoln("Listing available models")
@@ -394,6 +738,11 @@ func (c *Client) DeleteModel(reference string, force bool) (*DeleteModelResponse
 	}
 
 	c.log.Infoln("Deleting model:", id)
+	unlock, err := c.lockStore()
+	if err != nil {
+		return &DeleteModelResponse{}, err
+	}
+	defer unlock()
 	deletedID, tags, err := c.store.Delete(id)
 	if err != nil {
 		c.log.Errorln("Failed to delete model:", err, "tag:", reference)
@@ -407,16 +756,97 @@ func (c *Client) DeleteModel(reference string, force bool) (*DeleteModelResponse
 	return &resp, nil
 }
 
+// lockWrites locks the store for writing models, in this process and across
+// the processes that share the store, and returns the function that unlocks
+// it. Models can be written concurrently, but not while models are deleted or
+// garbage is collected.
+func (c *Client) lockWrites() (func(), error) {
+	c.writes.RLock()
+	unlock, err := c.store.LockShared()
+	if err != nil {
+		c.writes.RUnlock()
+		return nil, fmt.Errorf("locking store: %w", err)
+	}
+	return func() {
+		unlock()
+		c.writes.RUnlock()
+	}, nil
+}
+
+// lockStore locks the store for removing blobs, in this process and across
+// the processes that share the store, and returns the function that unlocks
+// it. It waits for the models that are being written to be written first.
+func (c *Client) lockStore() (func(), error) {
+	c.writes.Lock()
+	unlock, err := c.store.LockExclusive()
+	if err != nil {
+		c.writes.Unlock()
+		return nil, fmt.Errorf("locking store: %w", err)
+	}
+	return func() {
+		unlock()
+		c.writes.Unlock()
+	}, nil
+}
+
 // Tag adds a tag to a model
 func (c *Client) Tag(source string, target string) error {
 	c.log.Infoln("Tagging model, source:", source, "target:", utils.SanitizeForLog(target))
 	return c.store.AddTags(source, []string{target})
 }
 
-// PushModel pushes a tagged model from the content store to the registry.
-func (c *Client) PushModel(ctx context.Context, tag string, progressWriter io.Writer) (err error) {
-	// Parse the tag
-	target, err := c.registry.NewTarget(tag)
+// Untag removes a tag from the model that it points to and returns the ID of
+// that model. Models keep at least one tag: removing the last one fails with
+// ErrLastTag, and models must be deleted instead.
+func (c *Client) Untag(tag string) (string, error) {
+	c.log.Infoln("Untagging model:", utils.SanitizeForLog(tag))
+	return c.store.RemoveTag(tag)
+}
+
+// PushOption configures a push.
+type PushOption func(*pushOptions)
+
+// pushOptions holds the configuration of a push.
+type pushOptions struct {
+	destination string
+	auth        authn.Authenticator
+}
+
+// WithPushDestination pushes the model to the specified reference rather than
+// to the reference that it's read from, so that models can be pushed by ID or
+// under another name without tagging them first.
+func WithPushDestination(reference string) PushOption {
+	return func(o *pushOptions) {
+		if reference != "" {
+			o.destination = reference
+		}
+	}
+}
+
+// WithPushAuth authenticates the push with the specified credentials rather
+// than those found in the Docker configuration and its credential helpers.
+func WithPushAuth(auth authn.AuthConfig) PushOption {
+	return func(o *pushOptions) {
+		if auth != (authn.AuthConfig{}) {
+			o.auth = authn.FromConfig(auth)
+		}
+	}
+}
+
+// PushModel pushes a model from the content store to the registry. The model
+// is pushed to the tag that it's read by unless a destination is specified.
+func (c *Client) PushModel(ctx context.Context, tag string, progressWriter io.Writer, opts ...PushOption) (err error) {
+	options := pushOptions{destination: tag}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	// Parse the destination
+	registryClient := c.registry
+	if options.auth != nil {
+		registryClient = registry.FromClient(c.registry, registry.WithAuth(options.auth))
+	}
+	target, err := registryClient.NewTarget(options.destination)
 	if err != nil {
 		return fmt.Errorf("new tag: %w", err)
 	}
@@ -428,16 +858,16 @@ func (c *Client) PushModel(ctx context.Context, tag string, progressWriter io.Wr
 	}
 
 	// Push the model
-	c.log.Infoln("Pushing model:", tag)
+	c.log.Infoln("Pushing model:", utils.SanitizeForLog(tag), "to", utils.SanitizeForLog(options.destination))
 	if err := target.Write(ctx, mdl, progressWriter); err != nil {
-		c.log.Errorln("Failed to push image:", err, "reference:", tag)
+		c.log.Errorln("Failed to push image:", err, "reference:", utils.SanitizeForLog(options.destination))
 		if writeErr := progress.WriteError(progressWriter, fmt.Sprintf("Error: %s", err.Error())); writeErr != nil {
 			c.log.Warnf("Failed to write error message: %v", writeErr)
 		}
 		return fmt.Errorf("pushing image: %w", err)
 	}
 
-	c.log.Infoln("Successfully pushed model:", tag)
+	c.log.Infoln("Successfully pushed model:", utils.SanitizeForLog(options.destination))
 	if err := progress.WriteSuccess(progressWriter, "Model pushed successfully"); err != nil {
 		c.log.Warnf("Failed to write success message: %v", err)
 	}
@@ -445,11 +875,50 @@ func (c *Client) PushModel(ctx context.Context, tag string, progressWriter io.Wr
 	return nil
 }
 
+// WriteModel writes a model built locally, such as one derived from another
+// model, to the store with the specified tags.
+func (c *Client) WriteModel(mdl types.ModelArtifact, tags []string, progressWriter io.Writer) error {
+	layers, err := mdl.Layers()
+	if err != nil {
+		return fmt.Errorf("getting model layers: %w", err)
+	}
+	var size int64
+	for _, layer := range layers {
+		if layerSize, err := layer.Size(); err == nil {
+			size += layerSize
+		}
+	}
+	if err := c.ensureQuota(size, "", progressWriter); err != nil {
+		return err
+	}
+	if err := c.store.EnsureSpace(size); err != nil {
+		return err
+	}
+	c.log.Infoln("Writing model to store")
+	unlock, err := c.lockWrites()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := c.store.Write(mdl, tags, progressWriter); err != nil {
+		return fmt.Errorf("writing model to store: %w", err)
+	}
+	if digest, err := mdl.Digest(); err == nil {
+		c.markUsed(digest.String())
+	}
+	return nil
+}
+
 // WriteLightweightModel writes a model to the store without transferring layer data.
 // This is used for config-only modifications where the layer data hasn't changed.
 // The layers must already exist in the store.
 func (c *Client) WriteLightweightModel(mdl types.ModelArtifact, tags []string) error {
 	c.log.Infoln("Writing lightweight model variant")
+	unlock, err := c.lockWrites()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	return c.store.WriteLightweight(mdl, tags)
 }
 
@@ -9,13 +9,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/authn"
 	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/name"
 	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/registry"
 	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/remote"
@@ -23,9 +26,11 @@ import (
 
 	"github.com/docker/model-runner/pkg/distribution/internal/gguf"
 	"github.com/docker/model-runner/pkg/distribution/internal/mutate"
+	"github.com/docker/model-runner/pkg/distribution/internal/partial"
 	"github.com/docker/model-runner/pkg/distribution/internal/progress"
 	"github.com/docker/model-runner/pkg/distribution/internal/safetensors"
 	mdregistry "github.com/docker/model-runner/pkg/distribution/registry"
+	"github.com/docker/model-runner/pkg/distribution/types"
 	"github.com/docker/model-runner/pkg/inference/platform"
 )
 
@@ -593,6 +598,98 @@ func TestClientPullModel(t *testing.T) {
 	})
 }
 
+func TestClientPullModelResume(t *testing.T) {
+	model, err := gguf.NewModel(testGGUFFile)
+	if err != nil {
+		t.Fatalf("Failed to create model: %v", err)
+	}
+	layers, err := model.Layers()
+	if err != nil {
+		t.Fatalf("Failed to get layers: %v", err)
+	}
+	layerDigest, err := layers[0].Digest()
+	if err != nil {
+		t.Fatalf("Failed to get layer digest: %v", err)
+	}
+	modelContent, err := os.ReadFile(testGGUFFile)
+	if err != nil {
+		t.Fatalf("Failed to read test model file: %v", err)
+	}
+
+	for _, ignoreRanges := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ignore ranges %v", ignoreRanges), func(t *testing.T) {
+			// Interrupt the first download of the layer halfway through, and
+			// record the Range header of the download that resumes it.
+			var downloads atomic.Int32
+			var resumeRange atomic.Value
+			upstream := registry.New()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || !strings.HasSuffix(r.URL.Path, "/blobs/"+layerDigest.String()) {
+					upstream.ServeHTTP(w, r)
+					return
+				}
+				if downloads.Add(1) == 1 {
+					w.Header().Set("Content-Length", fmt.Sprint(len(modelContent)))
+					w.WriteHeader(http.StatusOK)
+					w.Write(modelContent[:len(modelContent)/2])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				resumeRange.Store(r.Header.Get("Range"))
+				if ignoreRanges {
+					r.Header.Del("Range")
+				}
+				upstream.ServeHTTP(w, r)
+			}))
+			defer server.Close()
+			registryURL, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatalf("Failed to parse registry URL: %v", err)
+			}
+
+			tag := registryURL.Host + "/resume-test/model:v1.0.0"
+			ref, err := name.ParseReference(tag)
+			if err != nil {
+				t.Fatalf("Failed to parse reference: %v", err)
+			}
+			if err := remote.Write(ref, model); err != nil {
+				t.Fatalf("Failed to push model: %v", err)
+			}
+
+			client, err := NewClient(WithStoreRootPath(t.TempDir()))
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			var progressBuffer bytes.Buffer
+			if err := client.PullModel(context.Background(), tag, &progressBuffer); err != nil {
+				t.Fatalf("Failed to pull model: %v", err)
+			}
+			if !strings.Contains(progressBuffer.String(), "Download interrupted") {
+				t.Errorf("Expected progress to report the interruption, got %q", progressBuffer.String())
+			}
+			if want := fmt.Sprintf("bytes=%d-", len(modelContent)/2); resumeRange.Load() != want {
+				t.Errorf("Expected download to resume with range %q, got %v", want, resumeRange.Load())
+			}
+
+			pulled, err := client.GetModel(tag)
+			if err != nil {
+				t.Fatalf("Failed to get model: %v", err)
+			}
+			paths, err := pulled.GGUFPaths()
+			if err != nil {
+				t.Fatalf("Failed to get model path: %v", err)
+			}
+			pulledContent, err := os.ReadFile(paths[0])
+			if err != nil {
+				t.Fatalf("Failed to read pulled model: %v", err)
+			}
+			if !bytes.Equal(pulledContent, modelContent) {
+				t.Errorf("Pulled content doesn't match original content")
+			}
+		})
+	}
+}
+
 func TestClientGetModel(t *testing.T) {
 	// Create temp directory for store
 	tempDir, err := os.MkdirTemp("", "model-distribution-test-*")
@@ -974,6 +1071,71 @@ func TestPush(t *testing.T) {
 	}
 }
 
+func TestPushToDestination(t *testing.T) {
+	client, err := NewClient(WithStoreRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// Create a test registry that requires credentials
+	upstream := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		upstream.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	uri, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse registry URL: %v", err)
+	}
+	destination := uri.Host + "/private/model:v1"
+
+	// Write an untagged model to the store, which is pushed by ID
+	mdl, err := gguf.NewModel(testGGUFFile)
+	if err != nil {
+		t.Fatalf("Failed to create model: %v", err)
+	}
+	digest, err := mdl.ID()
+	if err != nil {
+		t.Fatalf("Failed to get digest of original model: %v", err)
+	}
+	if err := client.store.Write(mdl, nil, nil); err != nil {
+		t.Fatalf("Failed to write model to store: %v", err)
+	}
+
+	err = client.PushModel(context.Background(), digest, nil, WithPushDestination(destination))
+	if !errors.Is(err, mdregistry.ErrUnauthorized) {
+		t.Fatalf("Expected unauthorized error without credentials, got %v", err)
+	}
+	if err := client.PushModel(context.Background(), digest, nil,
+		WithPushDestination(destination), WithPushAuth(authn.AuthConfig{Username: "user", Password: "secret"})); err != nil {
+		t.Fatalf("Failed to push model: %v", err)
+	}
+
+	// The pushed model is the same as the original
+	if _, err := client.DeleteModel(digest, true); err != nil {
+		t.Fatalf("Failed to delete model: %v", err)
+	}
+	pullClient, err := NewClient(WithStoreRootPath(t.TempDir()), WithRegistryAuth("user", "secret"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := pullClient.PullModel(context.Background(), destination, nil); err != nil {
+		t.Fatalf("Failed to pull model: %v", err)
+	}
+	pulled, err := pullClient.GetModel(destination)
+	if err != nil {
+		t.Fatalf("Failed to get pulled model: %v", err)
+	}
+	if pulledDigest, err := pulled.ID(); err != nil || pulledDigest != digest {
+		t.Fatalf("Digests don't match: got %s, want %s (error %v)", pulledDigest, digest, err)
+	}
+}
+
 func TestPushProgress(t *testing.T) {
 	// Create temp directory for store
 	tempDir, err := os.MkdirTemp("", "model-distribution-test-*")
@@ -1242,3 +1404,90 @@ func randomFile(size int64) (string, error) {
 
 	return f.Name(), nil
 }
+
+func TestClientPullModelReusesSharedLayers(t *testing.T) {
+	var blobRequests atomic.Int32
+	registryHandler := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			blobRequests.Add(1)
+		}
+		registryHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse registry URL: %v", err)
+	}
+
+	// The fine-tune shares the base model's weights, and adds a projector.
+	base, err := gguf.NewModel(testGGUFFile)
+	if err != nil {
+		t.Fatalf("Failed to create model: %v", err)
+	}
+	mmprojLayer, err := partial.NewLayer(filepath.Join("..", "assets", "dummy.mmproj"), types.MediaTypeMultimodalProjector)
+	if err != nil {
+		t.Fatalf("Failed to create projector layer: %v", err)
+	}
+	finetune := mutate.AppendLayers(base, mmprojLayer)
+	baseTag := registryURL.Host + "/org/base:latest"
+	finetuneTag := registryURL.Host + "/org/finetune:latest"
+	for tag, mdl := range map[string]types.ModelArtifact{baseTag: base, finetuneTag: finetune} {
+		ref, err := name.ParseReference(tag)
+		if err != nil {
+			t.Fatalf("Failed to parse reference: %v", err)
+		}
+		if err := remote.Write(ref, mdl); err != nil {
+			t.Fatalf("Failed to push model: %v", err)
+		}
+	}
+
+	client, err := NewClient(WithStoreRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.PullModel(context.Background(), baseTag, nil); err != nil {
+		t.Fatalf("Failed to pull base model: %v", err)
+	}
+
+	blobRequests.Store(0)
+	var progressBuffer bytes.Buffer
+	if err := client.PullModel(context.Background(), finetuneTag, &progressBuffer); err != nil {
+		t.Fatalf("Failed to pull fine-tune: %v", err)
+	}
+	// Only the fine-tune's config and projector are fetched.
+	if n := blobRequests.Load(); n != 2 {
+		t.Errorf("Expected only the config and projector to be fetched, got %d blob requests", n)
+	}
+
+	mmprojSize, err := mmprojLayer.Size()
+	if err != nil {
+		t.Fatalf("Failed to get layer size: %v", err)
+	}
+	scanner := bufio.NewScanner(&progressBuffer)
+	var cached bool
+	for scanner.Scan() {
+		var msg progress.Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			t.Fatalf("Failed to parse progress message: %v", err)
+		}
+		if msg.Type != "progress" {
+			continue
+		}
+		if msg.Total != uint64(mmprojSize) {
+			t.Errorf("Expected progress total to only count the projector (%d bytes), got %d", mmprojSize, msg.Total)
+		}
+		cached = cached || msg.Layer.Cached
+	}
+	if !cached {
+		t.Errorf("Expected progress to report the shared weights as cached")
+	}
+
+	bundle, err := client.GetBundle(finetuneTag)
+	if err != nil {
+		t.Fatalf("Failed to get bundle: %v", err)
+	}
+	if bundle.GGUFPath() == "" || bundle.MMPROJPath() == "" {
+		t.Errorf("Expected the fine-tune's bundle to include the weights and projector")
+	}
+}
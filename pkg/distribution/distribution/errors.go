@@ -17,6 +17,27 @@ var (
 		types.MediaTypeModelConfigV01,
 	)
 	ErrConflict = errors.New("resource conflict")
+	// ErrDigestMismatch indicates that a tag resolved to a different digest
+	// than the one pinned when it was first pulled.
+	ErrDigestMismatch = errors.New("model digest does not match pinned digest")
+	// ErrUntrustedSignature indicates that a model isn't signed by a trusted
+	// key while signature verification is enforced.
+	ErrUntrustedSignature = errors.New("model is not signed by a trusted key")
+	// ErrInsufficientSpace indicates that the store lacks the disk space to
+	// complete an operation.
+	ErrInsufficientSpace = store.ErrInsufficientSpace
+	// ErrQuotaExceeded indicates that writing a model would exceed the store
+	// quota, even after evicting the models that can be evicted.
+	ErrQuotaExceeded = fmt.Errorf("%w: store quota exceeded", ErrInsufficientSpace)
+	// ErrNoModelFiles indicates that a local path being imported doesn't
+	// contain GGUF or safetensors weights.
+	ErrNoModelFiles = errors.New("no GGUF or safetensors model files found")
+	// ErrLastTag indicates that removing a tag would leave its model untagged.
+	ErrLastTag = store.ErrLastTag
 )
 
+// DefaultMinFreeSpace is the free disk space kept in reserve by default when
+// writing models to the store.
+const DefaultMinFreeSpace = 1024 * 1024 * 1024
+
 const warnUnsupportedFormat = "vLLM backend currently only implemented for x86_64 NVIDIA platforms"
@@ -0,0 +1,39 @@
+package distribution
+
+import (
+	"fmt"
+
+	"github.com/docker/go-units"
+
+	"github.com/docker/model-runner/pkg/distribution/internal/store"
+)
+
+// Garbage describes the blobs in the store that no model references.
+type Garbage = store.Garbage
+
+// CollectGarbage removes the blobs in the store that no model references,
+// such as those left behind by interrupted deletes, and returns them. It
+// waits for models that are being written to the store to be written first.
+// If dryRun is set, the blobs are only reported, along with the disk space
+// that removing them would reclaim; blobs of models that are being written
+// are reported until their manifests are written.
+func (c *Client) CollectGarbage(dryRun bool) (Garbage, error) {
+	lock := c.lockStore
+	if dryRun {
+		lock = c.lockWrites
+	}
+	unlock, err := lock()
+	if err != nil {
+		return Garbage{}, err
+	}
+	defer unlock()
+	garbage, err := c.store.CollectGarbage(dryRun)
+	if err != nil {
+		return Garbage{}, fmt.Errorf("collecting garbage: %w", err)
+	}
+	if !dryRun && len(garbage.Blobs) > 0 {
+		c.log.Infof("Removed %d unreferenced blob(s), reclaiming %s",
+			len(garbage.Blobs), units.BytesSize(float64(garbage.Size)))
+	}
+	return garbage, nil
+}
@@ -0,0 +1,54 @@
+package distribution
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+)
+
+func TestClientCollectGarbage(t *testing.T) {
+	dir := t.TempDir()
+	writeContextGGUF(t, filepath.Join(dir, "model.gguf"), 4096)
+
+	client, err := NewClient(WithStoreRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.ImportModel(dir, "test/model:latest", 0, nil); err != nil {
+		t.Fatalf("Failed to import model: %v", err)
+	}
+
+	// A blob that no model references, as left behind by an interrupted
+	// delete.
+	content := "left behind"
+	hash, _, err := v1.SHA256(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Failed to hash blob: %v", err)
+	}
+	if err := client.store.WriteBlob(hash, strings.NewReader(content)); err != nil {
+		t.Fatalf("Failed to write blob: %v", err)
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		garbage, err := client.CollectGarbage(dryRun)
+		if err != nil {
+			t.Fatalf("Failed to collect garbage: %v", err)
+		}
+		if len(garbage.Blobs) != 1 || garbage.Blobs[0] != hash.String() || garbage.Size != int64(len(content)) {
+			t.Errorf("Expected only the unreferenced blob to be collected (dry run %t), got %+v", dryRun, garbage)
+		}
+		if client.store.HasBlob(hash) == !dryRun {
+			t.Errorf("Expected blob to be removed only without dry run (dry run %t)", dryRun)
+		}
+	}
+
+	bundle, err := client.GetBundle("test/model:latest")
+	if err != nil {
+		t.Fatalf("Failed to get bundle after collecting garbage: %v", err)
+	}
+	if bundle.GGUFPath() == "" {
+		t.Errorf("Expected the model's weights to be kept")
+	}
+}
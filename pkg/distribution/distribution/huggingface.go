@@ -0,0 +1,283 @@
+package distribution
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/docker/model-runner/pkg/distribution/builder"
+	"github.com/docker/model-runner/pkg/distribution/huggingface"
+	"github.com/docker/model-runner/pkg/distribution/internal/progress"
+	"github.com/docker/model-runner/pkg/distribution/packaging"
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+// hubStagingDir is the directory in the store root that files pulled from the
+// Hugging Face Hub are downloaded to before they're packaged.
+const hubStagingDir = "huggingface"
+
+// ListHubFiles lists the files in the Hugging Face Hub repository of a
+// reference, along with the files that pulling it selects.
+func (c *Client) ListHubFiles(ctx context.Context, reference string, bearerToken ...string) ([]huggingface.File, huggingface.Selection, error) {
+	ref, err := huggingface.ParseReference(reference)
+	if err != nil {
+		return nil, huggingface.Selection{}, err
+	}
+	hub := c.hubClient(bearerToken...)
+	files, err := hub.ListFiles(ctx, ref.Repository)
+	if err != nil {
+		return nil, huggingface.Selection{}, err
+	}
+	selection, err := huggingface.SelectFiles(files, ref.Quantization)
+	if err != nil {
+		return files, huggingface.Selection{}, err
+	}
+	return files, selection, nil
+}
+
+// hubClient returns the client's Hub client, authenticated with the bearer
+// token if one is provided.
+func (c *Client) hubClient(bearerToken ...string) *huggingface.Client {
+	if len(bearerToken) > 0 && bearerToken[0] != "" {
+		return huggingface.FromClient(c.hub, huggingface.WithToken(bearerToken[0]))
+	}
+	return c.hub
+}
+
+// pullFromHub pulls a model directly from a Hugging Face Hub repository: it
+// downloads the repository's GGUF or safetensors files and packages them as a
+// model in the store, tagged with the reference.
+func (c *Client) pullFromHub(ctx context.Context, reference string, progressWriter io.Writer, bearerToken ...string) error {
+	ref, err := huggingface.ParseReference(reference)
+	if err != nil {
+		return err
+	}
+	// Hugging Face repositories aren't OCI artifacts, so they can't be signed.
+	if c.signatureVerification != SignatureVerificationOff {
+		msg := fmt.Sprintf("%s can't be verified, since only models in OCI registries are signed", reference)
+		if c.signatureVerification == SignatureVerificationEnforce {
+			return fmt.Errorf("%s: %w", msg, ErrUntrustedSignature)
+		}
+		if err := progress.WriteWarning(progressWriter, fmt.Sprintf("Warning: %s", msg)); err != nil {
+			c.log.Warnf("Failed to write warning message: %v", err)
+		}
+	}
+	hub := c.hubClient(bearerToken...)
+	files, err := hub.ListFiles(ctx, ref.Repository)
+	if err != nil {
+		return fmt.Errorf("reading model from Hugging Face: %w", err)
+	}
+	selection, err := huggingface.SelectFiles(files, ref.Quantization)
+	if err != nil {
+		return fmt.Errorf("selecting files of %s: %w", ref.Repository, err)
+	}
+	selected := selection.Files()
+	c.log.Infof("Selected %d files from %s", len(selected), utils.SanitizeForLog(ref.Repository))
+
+	if c.hubModelCached(reference, selection) {
+		c.log.Infoln("Model found in local store:", utils.SanitizeForLog(reference))
+		if err := progress.WriteSuccess(progressWriter, "Using cached model"); err != nil {
+			c.log.Warnf("Writing progress: %v", err)
+		}
+		c.markUsed(reference)
+		return nil
+	}
+
+	stagingDir := filepath.Join(c.store.RootPath(), hubStagingDir, filepath.FromSlash(ref.Repository))
+	if err := os.MkdirAll(stagingDir, 0o755); err != nil {
+		return fmt.Errorf("creating staging directory: %w", err)
+	}
+	paths := make(map[string]string, len(selected))
+	staged := make(map[string]bool, len(selected))
+	// Files that are already in the store as blobs of other models, such as
+	// weights shared by related repositories, are staged from the store
+	// rather than downloaded.
+	cached := make(map[string]v1.Hash)
+	var total, required int64
+	for _, file := range selected {
+		// Files are staged by name, which keeps GGUF shards next to each other.
+		name := path.Base(file.Path)
+		if staged[name] {
+			return fmt.Errorf("selected files of %s have conflicting names: %s", ref.Repository, name)
+		}
+		staged[name] = true
+		paths[file.Path] = filepath.Join(stagingDir, name)
+		if file.SHA256 != "" {
+			if hash, err := v1.NewHash("sha256:" + file.SHA256); err == nil && c.store.HasBlob(hash) {
+				cached[file.Path] = hash
+				continue
+			}
+		}
+		total += file.Size
+		// Staged files are copied into the store, so they need space twice.
+		required += 2 * file.Size
+		if info, err := os.Stat(paths[file.Path]); err == nil && info.Size() == file.Size {
+			required -= file.Size
+		} else if info, err := os.Stat(paths[file.Path] + ".incomplete"); err == nil {
+			required -= min(info.Size(), file.Size)
+		}
+	}
+	if err := c.ensureQuota(total, "", progressWriter); err != nil {
+		return err
+	}
+	if err := c.store.EnsureSpace(required); err != nil {
+		c.log.Errorln("Not enough disk space to pull model:", err)
+		if writeErr := progress.WriteError(progressWriter, fmt.Sprintf("Error: %s", err.Error())); writeErr != nil {
+			c.log.Warnf("Failed to write error message: %v", writeErr)
+		}
+		return err
+	}
+
+	if len(cached) > 0 {
+		c.log.Infof("Reusing %d file(s) already in the store", len(cached))
+	}
+
+	for _, file := range selected {
+		if hash, ok := cached[file.Path]; ok {
+			err := c.store.LinkBlob(hash, paths[file.Path])
+			if err == nil {
+				if err := progress.WriteCached(progressWriter, uint64(total), uint64(max(file.Size, 0)), hash.String()); err != nil {
+					c.log.Warnf("Writing progress: %v", err)
+				}
+				continue
+			}
+			c.log.Warnf("Failed to stage %s from the store, downloading it: %v", utils.SanitizeForLog(file.Path), err)
+		}
+		if err := c.downloadHubFile(ctx, hub, ref.Repository, file, paths[file.Path], uint64(total), progressWriter); err != nil {
+			if writeErr := progress.WriteError(progressWriter, fmt.Sprintf("Error: %s", err.Error())); writeErr != nil {
+				c.log.Warnf("Failed to write error message: %v", writeErr)
+			}
+			return err
+		}
+	}
+
+	b, cleanup, err := packageFiles(selection, paths)
+	if err != nil {
+		return fmt.Errorf("packaging model: %w", err)
+	}
+	defer cleanup()
+	unlock, err := c.lockWrites()
+	if err != nil {
+		return err
+	}
+	err = c.store.WriteContext(ctx, b.Model(), []string{reference}, nil)
+	unlock()
+	if err != nil {
+		return fmt.Errorf("writing model to store: %w", err)
+	}
+	c.markUsed(reference)
+	if err := os.RemoveAll(stagingDir); err != nil {
+		c.log.Warnf("Failed to remove staging directory %s: %v", stagingDir, err)
+	}
+
+	if err := progress.WriteSuccess(progressWriter, "Model pulled successfully"); err != nil {
+		c.log.Warnf("Failed to write success message: %v", err)
+	}
+	return nil
+}
+
+// hubModelCached returns true if the model tagged with the reference consists
+// of the selected weights, which can only be determined if they're all stored
+// with Git LFS, since their digests are known upfront.
+func (c *Client) hubModelCached(reference string, selection huggingface.Selection) bool {
+	localModel, err := c.store.Read(reference)
+	if err != nil {
+		return false
+	}
+	layers, err := localModel.Layers()
+	if err != nil {
+		return false
+	}
+	var diffIDs []string
+	for _, layer := range layers {
+		if diffID, err := layer.DiffID(); err == nil {
+			diffIDs = append(diffIDs, diffID.Hex)
+		}
+	}
+	weights := selection.Weights
+	if selection.Projector != nil {
+		weights = append(slices.Clone(weights), *selection.Projector)
+	}
+	for _, file := range weights {
+		if file.SHA256 == "" || !slices.Contains(diffIDs, file.SHA256) {
+			return false
+		}
+	}
+	return true
+}
+
+// downloadHubFile downloads a file from a Hub repository, reporting its
+// progress in the same way as layers pulled from registries.
+func (c *Client) downloadHubFile(ctx context.Context, hub *huggingface.Client, repository string, file huggingface.File, dest string, total uint64, progressWriter io.Writer) error {
+	id := file.Path
+	if file.SHA256 != "" {
+		id = "sha256:" + file.SHA256
+	}
+	size := uint64(max(file.Size, 0))
+	var lastUpdate time.Time
+	var lastDownloaded int64
+	onProgress := func(downloaded int64) {
+		now := time.Now()
+		if now.Sub(lastUpdate) < progress.UpdateInterval &&
+			downloaded-lastDownloaded < progress.MinBytesForUpdate &&
+			downloaded != file.Size {
+			return
+		}
+		lastUpdate, lastDownloaded = now, downloaded
+		msg := fmt.Sprintf("Downloaded: %.2f MB", float64(downloaded)/1024/1024)
+		if err := progress.WriteProgress(progressWriter, msg, total, size, uint64(downloaded), id); err != nil {
+			c.log.Warnf("Writing progress: %v", err)
+		}
+	}
+	c.log.Infof("Downloading %s from %s", utils.SanitizeForLog(file.Path), utils.SanitizeForLog(repository))
+	return hub.Download(ctx, repository, file, dest, onProgress)
+}
+
+// packageFiles packages the selected files, at the local paths that they're
+// mapped to, as a model. The returned cleanup function removes temporary files
+// created while packaging.
+func packageFiles(selection huggingface.Selection, paths map[string]string) (*builder.Builder, func(), error) {
+	cleanup := func() {}
+	var b *builder.Builder
+	var err error
+	if selection.GGUF() {
+		if b, err = builder.FromGGUF(paths[selection.Weights[0].Path]); err != nil {
+			return nil, cleanup, err
+		}
+		if selection.Projector != nil {
+			if b, err = b.WithMultimodalProjector(paths[selection.Projector.Path]); err != nil {
+				return nil, cleanup, err
+			}
+		}
+		return b, cleanup, nil
+	}
+
+	weights := make([]string, 0, len(selection.Weights))
+	for _, file := range selection.Weights {
+		weights = append(weights, paths[file.Path])
+	}
+	if b, err = builder.FromSafetensors(weights); err != nil {
+		return nil, cleanup, err
+	}
+	if len(selection.Config) > 0 {
+		configFiles := make([]string, 0, len(selection.Config))
+		for _, file := range selection.Config {
+			configFiles = append(configFiles, paths[file.Path])
+		}
+		archive, err := packaging.CreateTempConfigArchive(configFiles)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		cleanup = func() { os.Remove(archive) }
+		if b, err = b.WithConfigArchive(archive); err != nil {
+			return nil, cleanup, err
+		}
+	}
+	return b, cleanup, nil
+}
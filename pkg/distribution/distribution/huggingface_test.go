@@ -0,0 +1,216 @@
+package distribution
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mdregistry "github.com/docker/model-runner/pkg/distribution/registry"
+)
+
+func TestClientPullModelFromHub(t *testing.T) {
+	files := map[string][]byte{}
+	for path, asset := range map[string]string{
+		"model-Q4_K_M.gguf":     testGGUFFile,
+		"mmproj-model-f16.gguf": filepath.Join("..", "assets", "dummy.mmproj"),
+		"README.md":             filepath.Join("..", "assets", "license.txt"),
+	} {
+		content, err := os.ReadFile(asset)
+		if err != nil {
+			t.Fatalf("Failed to read test asset: %v", err)
+		}
+		files[path] = content
+	}
+
+	var downloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer hf-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/api/models/org/repo/tree/main" {
+			var entries []map[string]any
+			for path, content := range files {
+				digest := sha256.Sum256(content)
+				entries = append(entries, map[string]any{
+					"type": "file", "path": path, "size": len(content),
+					"lfs": map[string]any{"oid": hex.EncodeToString(digest[:]), "size": len(content)},
+				})
+			}
+			json.NewEncoder(w).Encode(entries)
+			return
+		}
+		content, ok := files[strings.TrimPrefix(r.URL.Path, "/org/repo/resolve/main/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		downloads.Add(1)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	t.Setenv("HF_ENDPOINT", server.URL)
+
+	storePath := t.TempDir()
+	client, err := NewClient(WithStoreRootPath(storePath))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	const reference = "huggingface.co/org/repo:latest"
+
+	if err := client.PullModel(context.Background(), reference, nil); !errors.Is(err, mdregistry.ErrUnauthorized) {
+		t.Fatalf("Expected unauthorized error without a token, got %v", err)
+	}
+
+	var progressBuffer bytes.Buffer
+	if err := client.PullModel(context.Background(), reference, &progressBuffer, "hf-token"); err != nil {
+		t.Fatalf("Failed to pull model: %v", err)
+	}
+	if downloads.Load() != 2 {
+		t.Errorf("Expected the weights and projector to be downloaded, got %d downloads", downloads.Load())
+	}
+	if !strings.Contains(progressBuffer.String(), "Model pulled successfully") {
+		t.Errorf("Expected progress to report success, got %q", progressBuffer.String())
+	}
+	if _, err := os.Stat(filepath.Join(storePath, hubStagingDir, "org", "repo")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected staging directory to be removed, got %v", err)
+	}
+
+	bundle, err := client.GetBundle(reference)
+	if err != nil {
+		t.Fatalf("Failed to get bundle: %v", err)
+	}
+	pulledContent, err := os.ReadFile(bundle.GGUFPath())
+	if err != nil {
+		t.Fatalf("Failed to read pulled model: %v", err)
+	}
+	if !bytes.Equal(pulledContent, files["model-Q4_K_M.gguf"]) {
+		t.Errorf("Pulled content doesn't match original content")
+	}
+	if bundle.MMPROJPath() == "" {
+		t.Errorf("Expected bundle to include the multimodal projector")
+	}
+
+	// Pulling again doesn't download the unchanged files.
+	progressBuffer.Reset()
+	if err := client.PullModel(context.Background(), reference, &progressBuffer, "hf-token"); err != nil {
+		t.Fatalf("Failed to pull model again: %v", err)
+	}
+	if downloads.Load() != 2 {
+		t.Errorf("Expected cached model to be used, got %d downloads", downloads.Load())
+	}
+	if !strings.Contains(progressBuffer.String(), "Using cached model") {
+		t.Errorf("Expected progress to report the cached model, got %q", progressBuffer.String())
+	}
+
+	if err := client.PullModel(context.Background(), "huggingface.co/org/repo:q8_0", nil, "hf-token"); !errors.Is(err, mdregistry.ErrModelNotFound) {
+		t.Errorf("Expected model not found error for a missing quantization, got %v", err)
+	}
+}
+
+func TestClientPullModelFromHubReusesStoredFiles(t *testing.T) {
+	readAsset := func(path string) []byte {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read test asset: %v", err)
+		}
+		return content
+	}
+	finetunePath := filepath.Join(t.TempDir(), "finetune.gguf")
+	writeContextGGUF(t, finetunePath, 4096)
+	projector := readAsset(filepath.Join("..", "assets", "dummy.mmproj"))
+	repos := map[string]map[string][]byte{
+		"org/base": {
+			"base-Q4_K_M.gguf":      readAsset(testGGUFFile),
+			"mmproj-model-f16.gguf": projector,
+		},
+		// A fine-tune that shares the base model's projector.
+		"org/finetune": {
+			"finetune-Q4_K_M.gguf":  readAsset(finetunePath),
+			"mmproj-model-f16.gguf": projector,
+		},
+	}
+
+	var downloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for repo, files := range repos {
+			if r.URL.Path == "/api/models/"+repo+"/tree/main" {
+				var entries []map[string]any
+				for path, content := range files {
+					digest := sha256.Sum256(content)
+					entries = append(entries, map[string]any{
+						"type": "file", "path": path, "size": len(content),
+						"lfs": map[string]any{"oid": hex.EncodeToString(digest[:]), "size": len(content)},
+					})
+				}
+				json.NewEncoder(w).Encode(entries)
+				return
+			}
+			if content, ok := files[strings.TrimPrefix(r.URL.Path, "/"+repo+"/resolve/main/")]; ok {
+				downloads.Add(1)
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	t.Setenv("HF_ENDPOINT", server.URL)
+
+	client, err := NewClient(WithStoreRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.PullModel(context.Background(), "huggingface.co/org/base:latest", nil); err != nil {
+		t.Fatalf("Failed to pull base model: %v", err)
+	}
+	if downloads.Load() != 2 {
+		t.Fatalf("Expected the weights and projector to be downloaded, got %d downloads", downloads.Load())
+	}
+
+	// Only the fine-tune's weights are downloaded.
+	var progressBuffer bytes.Buffer
+	if err := client.PullModel(context.Background(), "huggingface.co/org/finetune:latest", &progressBuffer); err != nil {
+		t.Fatalf("Failed to pull fine-tune: %v", err)
+	}
+	if downloads.Load() != 3 {
+		t.Errorf("Expected only the fine-tune's weights to be downloaded, got %d downloads", downloads.Load())
+	}
+	if !strings.Contains(progressBuffer.String(), `"Cached":true`) {
+		t.Errorf("Expected progress to report the stored projector, got %q", progressBuffer.String())
+	}
+	bundle, err := client.GetBundle("huggingface.co/org/finetune:latest")
+	if err != nil {
+		t.Fatalf("Failed to get bundle: %v", err)
+	}
+	pulledProjector, err := os.ReadFile(bundle.MMPROJPath())
+	if err != nil {
+		t.Fatalf("Failed to read projector: %v", err)
+	}
+	if !bytes.Equal(pulledProjector, projector) {
+		t.Errorf("Expected the fine-tune's projector to match the stored one")
+	}
+
+	// The base model is unaffected by the fine-tune's files being removed.
+	if _, err := client.DeleteModel("huggingface.co/org/finetune:latest", false); err != nil {
+		t.Fatalf("Failed to delete fine-tune: %v", err)
+	}
+	bundle, err = client.GetBundle("huggingface.co/org/base:latest")
+	if err != nil {
+		t.Fatalf("Failed to get bundle: %v", err)
+	}
+	if _, err := os.Stat(bundle.MMPROJPath()); err != nil {
+		t.Errorf("Expected the shared projector to be kept, got %v", err)
+	}
+}
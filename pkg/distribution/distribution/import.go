@@ -0,0 +1,249 @@
+package distribution
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/distribution/huggingface"
+	"github.com/docker/model-runner/pkg/distribution/internal/progress"
+	"github.com/docker/model-runner/pkg/distribution/registry"
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+// chatTemplateFile is the name of the chat template file that tokenizers are
+// saved with, which is preferred over other templates next to the weights.
+const chatTemplateFile = "chat_template.jinja"
+
+// ImportModel packages a model from the local filesystem and writes it to the
+// store, tagged with the reference, without downloading anything. The path is
+// either a GGUF file, the first shard of a sharded GGUF model, or a directory
+// whose weights are selected in the same way as those of Hugging Face
+// repositories. The context size is read from the model's metadata unless
+// it's specified, and a chat template next to the weights is packaged with
+// them.
+func (c *Client) ImportModel(modelPath, reference string, contextSize uint64, progressWriter io.Writer) error {
+	info, err := os.Stat(modelPath)
+	if err != nil {
+		return fmt.Errorf("reading model path: %w", err)
+	}
+	files, paths, err := listLocalFiles(modelPath, info.IsDir())
+	if err != nil {
+		return err
+	}
+	var selection huggingface.Selection
+	if !info.IsDir() {
+		if selection, err = selectLocalGGUF(files); err != nil {
+			return err
+		}
+	} else if selection, err = huggingface.SelectFiles(files, ""); err != nil {
+		if errors.Is(err, registry.ErrModelNotFound) {
+			return fmt.Errorf("%w in %s", ErrNoModelFiles, modelPath)
+		}
+		return fmt.Errorf("selecting files in %s: %w", modelPath, err)
+	}
+	c.log.Infof("Importing %d files from %s", len(selection.Files()), utils.SanitizeForLog(modelPath))
+
+	b, cleanup, err := packageFiles(selection, paths)
+	if err != nil {
+		return fmt.Errorf("packaging model: %w", err)
+	}
+	defer cleanup()
+	if contextSize == 0 {
+		if config, err := b.Model().Config(); err == nil {
+			contextSize = localContextSize(config, selection, paths)
+		}
+	}
+	if contextSize > 0 {
+		b = b.WithContextSize(contextSize)
+	}
+	template, removeTemplate, err := localChatTemplate(files, selection, paths)
+	if err != nil {
+		return err
+	}
+	defer removeTemplate()
+	if template != "" {
+		if b, err = b.WithChatTemplateFile(template); err != nil {
+			return fmt.Errorf("packaging model: %w", err)
+		}
+	}
+
+	if err := c.WriteModel(b.Model(), []string{reference}, progressWriter); err != nil {
+		return err
+	}
+	if err := progress.WriteSuccess(progressWriter, "Model imported successfully"); err != nil {
+		c.log.Warnf("Failed to write success message: %v", err)
+	}
+	return nil
+}
+
+// listLocalFiles lists the files that a model at a local path may consist of,
+// along with their paths on disk, keyed by their slash-separated paths
+// relative to the model's directory. Directories are listed recursively,
+// except for hidden files, while only the files next to a GGUF file are
+// listed, starting with the GGUF file. Symbolic links to files are followed,
+// as in the Hugging Face cache.
+func listLocalFiles(modelPath string, isDir bool) ([]huggingface.File, map[string]string, error) {
+	dir := modelPath
+	if !isDir {
+		dir = filepath.Dir(modelPath)
+	}
+	var files []huggingface.File
+	paths := make(map[string]string)
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		if entry.IsDir() {
+			if !isDir || strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+		info, err := os.Stat(p)
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		file := huggingface.File{Path: rel, Size: info.Size()}
+		if !isDir && rel == filepath.Base(modelPath) {
+			files = append([]huggingface.File{file}, files...)
+		} else {
+			files = append(files, file)
+		}
+		paths[rel] = p
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing model files: %w", err)
+	}
+	return files, paths, nil
+}
+
+// selectLocalGGUF selects the GGUF file being imported, which is the first of
+// the listed files, along with a multimodal projector next to it, if any.
+func selectLocalGGUF(files []huggingface.File) (huggingface.Selection, error) {
+	if len(files) == 0 || !strings.HasSuffix(strings.ToLower(files[0].Path), ".gguf") {
+		return huggingface.Selection{}, fmt.Errorf("%w: only GGUF files can be imported, other models are imported by directory", ErrNoModelFiles)
+	}
+	selection := huggingface.Selection{Weights: files[:1]}
+	for _, file := range files[1:] {
+		name := strings.ToLower(file.Path)
+		if strings.HasSuffix(name, ".gguf") && strings.Contains(name, "mmproj") {
+			selection.Projector = &file
+			break
+		}
+	}
+	return selection, nil
+}
+
+// localContextSize returns the context size that a model was trained with,
+// as recorded in its GGUF metadata or the configuration of its safetensors
+// weights, or zero if it's unknown.
+func localContextSize(config types.Config, selection huggingface.Selection, paths map[string]string) uint64 {
+	if selection.GGUF() {
+		size, err := strconv.ParseUint(config.GGUF[config.GGUF["general.architecture"]+".context_length"], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return size
+	}
+	for _, file := range selection.Config {
+		if path.Base(file.Path) != "config.json" {
+			continue
+		}
+		data, err := os.ReadFile(paths[file.Path])
+		if err != nil {
+			return 0
+		}
+		var modelConfig struct {
+			MaxPositionEmbeddings uint64 `json:"max_position_embeddings"`
+			TextConfig            struct {
+				MaxPositionEmbeddings uint64 `json:"max_position_embeddings"`
+			} `json:"text_config"`
+		}
+		if err := json.Unmarshal(data, &modelConfig); err != nil {
+			return 0
+		}
+		return max(modelConfig.MaxPositionEmbeddings, modelConfig.TextConfig.MaxPositionEmbeddings)
+	}
+	return 0
+}
+
+// localChatTemplate returns the path of the chat template to package with a
+// model, which is a Jinja template next to its weights, or the template in
+// the tokenizer configuration of safetensors weights. Templates read from the
+// tokenizer configuration are written to a temporary file, which the returned
+// function removes.
+func localChatTemplate(files []huggingface.File, selection huggingface.Selection, paths map[string]string) (string, func(), error) {
+	cleanup := func() {}
+	dir := path.Dir(selection.Weights[0].Path)
+	var template string
+	for _, file := range files {
+		if path.Dir(file.Path) != dir || !strings.HasSuffix(strings.ToLower(file.Path), ".jinja") {
+			continue
+		}
+		if path.Base(file.Path) == chatTemplateFile {
+			return paths[file.Path], cleanup, nil
+		}
+		if template == "" {
+			template = paths[file.Path]
+		}
+	}
+	if template != "" || selection.GGUF() {
+		// GGUF files usually embed their chat template in their metadata.
+		return template, cleanup, nil
+	}
+
+	for _, file := range selection.Config {
+		if path.Base(file.Path) != "tokenizer_config.json" {
+			continue
+		}
+		data, err := os.ReadFile(paths[file.Path])
+		if err != nil {
+			return "", cleanup, fmt.Errorf("reading tokenizer configuration: %w", err)
+		}
+		var tokenizerConfig struct {
+			// ChatTemplate is either a template, or a list of named
+			// templates, which aren't packaged.
+			ChatTemplate json.RawMessage `json:"chat_template"`
+		}
+		var chatTemplate string
+		if json.Unmarshal(data, &tokenizerConfig) != nil ||
+			json.Unmarshal(tokenizerConfig.ChatTemplate, &chatTemplate) != nil || chatTemplate == "" {
+			return "", cleanup, nil
+		}
+		f, err := os.CreateTemp("", "chat-template-*.jinja")
+		if err != nil {
+			return "", cleanup, fmt.Errorf("creating chat template file: %w", err)
+		}
+		cleanup = func() { os.Remove(f.Name()) }
+		_, err = f.WriteString(chatTemplate)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", cleanup, fmt.Errorf("writing chat template file: %w", err)
+		}
+		return f.Name(), cleanup, nil
+	}
+	return "", cleanup, nil
+}
@@ -0,0 +1,180 @@
+package distribution
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeContextGGUF writes a GGUF file without tensors, whose metadata records
+// the context length that the model was trained with.
+func writeContextGGUF(t *testing.T, path string, contextLength uint32) {
+	t.Helper()
+	content := []byte("GGUF")
+	content = binary.LittleEndian.AppendUint32(content, 3) // Version
+	content = binary.LittleEndian.AppendUint64(content, 0) // Tensors
+	content = binary.LittleEndian.AppendUint64(content, 2) // Metadata
+	appendString := func(s string) {
+		content = binary.LittleEndian.AppendUint64(content, uint64(len(s)))
+		content = append(content, s...)
+	}
+	appendString("general.architecture")
+	content = binary.LittleEndian.AppendUint32(content, 8) // String
+	appendString("llama")
+	appendString("llama.context_length")
+	content = binary.LittleEndian.AppendUint32(content, 4) // Uint32
+	content = binary.LittleEndian.AppendUint32(content, contextLength)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("Failed to write GGUF file: %v", err)
+	}
+}
+
+// writeFiles writes files with the specified contents to a directory.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+}
+
+func TestClientImportModelGGUF(t *testing.T) {
+	dir := t.TempDir()
+	writeContextGGUF(t, filepath.Join(dir, "model-Q4_K_M.gguf"), 8192)
+	writeContextGGUF(t, filepath.Join(dir, "model-Q8_0.gguf"), 4096)
+	mmproj, err := os.ReadFile(filepath.Join("..", "assets", "dummy.mmproj"))
+	if err != nil {
+		t.Fatalf("Failed to read test asset: %v", err)
+	}
+	writeFiles(t, dir, map[string]string{
+		"mmproj-model-f16.gguf": string(mmproj),
+		"template.jinja":        "{{ messages }}",
+		".cache/other.gguf":     "ignored",
+	})
+
+	client, err := NewClient(WithStoreRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	var progressBuffer bytes.Buffer
+	if err := client.ImportModel(dir, "test/imported:latest", 0, &progressBuffer); err != nil {
+		t.Fatalf("Failed to import model: %v", err)
+	}
+	if !strings.Contains(progressBuffer.String(), "Model imported successfully") {
+		t.Errorf("Expected progress to report success, got %q", progressBuffer.String())
+	}
+	model, err := client.GetModel("test/imported:latest")
+	if err != nil {
+		t.Fatalf("Failed to get imported model: %v", err)
+	}
+	config, err := model.Config()
+	if err != nil {
+		t.Fatalf("Failed to get model config: %v", err)
+	}
+	if config.ContextSize == nil || *config.ContextSize != 8192 {
+		t.Errorf("Expected context size 8192 from the GGUF metadata, got %v", config.ContextSize)
+	}
+	bundle, err := client.GetBundle("test/imported:latest")
+	if err != nil {
+		t.Fatalf("Failed to get bundle: %v", err)
+	}
+	if bundle.MMPROJPath() == "" {
+		t.Errorf("Expected bundle to include the multimodal projector")
+	}
+	if bundle.ChatTemplatePath() == "" {
+		t.Errorf("Expected bundle to include the chat template")
+	}
+
+	// Importing a file selects that file, rather than the default
+	// quantization.
+	if err := client.ImportModel(filepath.Join(dir, "model-Q8_0.gguf"), "test/imported:Q8_0", 0, nil); err != nil {
+		t.Fatalf("Failed to import model: %v", err)
+	}
+	model, err = client.GetModel("test/imported:Q8_0")
+	if err != nil {
+		t.Fatalf("Failed to get imported model: %v", err)
+	}
+	if config, err := model.Config(); err != nil || config.ContextSize == nil || *config.ContextSize != 4096 {
+		t.Errorf("Expected context size 4096 of the imported file, got %+v (error %v)", config, err)
+	}
+
+	// The context size can be specified.
+	if err := client.ImportModel(dir, "test/imported:short", 2048, nil); err != nil {
+		t.Fatalf("Failed to import model: %v", err)
+	}
+	model, err = client.GetModel("test/imported:short")
+	if err != nil {
+		t.Fatalf("Failed to get imported model: %v", err)
+	}
+	if config, err := model.Config(); err != nil || config.ContextSize == nil || *config.ContextSize != 2048 {
+		t.Errorf("Expected the specified context size, got %+v (error %v)", config, err)
+	}
+}
+
+func TestClientImportModelSafetensors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"model.safetensors":     "fake safetensors content",
+		"config.json":           `{"architectures": ["Gemma3ForConditionalGeneration"], "text_config": {"max_position_embeddings": 131072}}`,
+		"tokenizer_config.json": `{"chat_template": "{{ bos_token }}{{ messages }}"}`,
+		"README.md":             "not packaged",
+	})
+
+	client, err := NewClient(WithStoreRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.ImportModel(dir, "test/safetensors:latest", 0, nil); err != nil {
+		t.Fatalf("Failed to import model: %v", err)
+	}
+	model, err := client.GetModel("test/safetensors:latest")
+	if err != nil {
+		t.Fatalf("Failed to get imported model: %v", err)
+	}
+	if config, err := model.Config(); err != nil || config.ContextSize == nil || *config.ContextSize != 131072 {
+		t.Errorf("Expected context size 131072 from the model configuration, got %+v (error %v)", config, err)
+	}
+	bundle, err := client.GetBundle("test/safetensors:latest")
+	if err != nil {
+		t.Fatalf("Failed to get bundle: %v", err)
+	}
+	if bundle.SafetensorsPath() == "" {
+		t.Errorf("Expected bundle to include the safetensors weights")
+	}
+	template, err := os.ReadFile(bundle.ChatTemplatePath())
+	if err != nil {
+		t.Fatalf("Failed to read chat template: %v", err)
+	}
+	if string(template) != "{{ bos_token }}{{ messages }}" {
+		t.Errorf("Expected the chat template of the tokenizer configuration, got %q", template)
+	}
+}
+
+func TestClientImportModelWithoutWeights(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"README.md": "no weights"})
+
+	client, err := NewClient(WithStoreRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.ImportModel(dir, "test/empty:latest", 0, nil); !errors.Is(err, ErrNoModelFiles) {
+		t.Errorf("Expected ErrNoModelFiles for a directory without weights, got %v", err)
+	}
+	if err := client.ImportModel(filepath.Join(dir, "README.md"), "test/empty:latest", 0, nil); !errors.Is(err, ErrNoModelFiles) {
+		t.Errorf("Expected ErrNoModelFiles for a file that isn't GGUF, got %v", err)
+	}
+	if err := client.ImportModel(filepath.Join(dir, "missing"), "test/empty:latest", 0, nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing path to be reported, got %v", err)
+	}
+}
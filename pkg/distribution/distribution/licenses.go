@@ -0,0 +1,32 @@
+package distribution
+
+import (
+	"time"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+// AcceptModelLicense records the acceptance of the license of a model. The
+// acceptance is kept for audits, even after the model is deleted.
+func (c *Client) AcceptModelLicense(acceptance types.LicenseAcceptance) error {
+	return c.store.AcceptLicense(acceptance)
+}
+
+// RevokeModelLicense revokes the acceptance of the license of the model with
+// the specified ID. It returns false if its license wasn't accepted.
+func (c *Client) RevokeModelLicense(id string) (bool, error) {
+	return c.store.RevokeLicense(id, time.Now())
+}
+
+// ModelLicenseAcceptance returns the acceptance of the license of the model
+// with the specified ID, if its license was accepted and the acceptance
+// wasn't revoked.
+func (c *Client) ModelLicenseAcceptance(id string) (types.LicenseAcceptance, bool, error) {
+	return c.store.LicenseAcceptance(id)
+}
+
+// ListLicenseAcceptances returns all the license acceptances recorded,
+// including revoked ones.
+func (c *Client) ListLicenseAcceptances() ([]types.LicenseAcceptance, error) {
+	return c.store.LicenseAcceptances()
+}
@@ -1,8 +1,11 @@
 package distribution
 
 import (
+	"bytes"
+	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/docker/model-runner/pkg/distribution/builder"
@@ -53,3 +56,73 @@ func TestLoadModel(t *testing.T) {
 		t.Fatalf("Failed to get model: %v", err)
 	}
 }
+
+func TestExportModel(t *testing.T) {
+	source, err := NewClient(WithStoreRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	bldr, err := builder.FromGGUF(testGGUFFile)
+	if err != nil {
+		t.Fatalf("Failed to create builder: %v", err)
+	}
+	if bldr, err = bldr.WithChatTemplateFile(filepath.Join("..", "assets", "template.jinja")); err != nil {
+		t.Fatalf("Failed to add chat template: %v", err)
+	}
+	tags := []string{"test/exported:latest", "test/exported:v1"}
+	if err := source.WriteModel(bldr.Model(), tags, nil); err != nil {
+		t.Fatalf("Failed to write model: %v", err)
+	}
+	model, err := source.GetModel(tags[0])
+	if err != nil {
+		t.Fatalf("Failed to get model: %v", err)
+	}
+	id, err := model.ID()
+	if err != nil {
+		t.Fatalf("Failed to get model ID: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if err := source.ExportModel(t.Context(), tags[0], &archive); err != nil {
+		t.Fatalf("Failed to export model: %v", err)
+	}
+	if err := source.ExportModel(t.Context(), "test/missing:latest", io.Discard); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("Expected ErrModelNotFound exporting a missing model, got %v", err)
+	}
+
+	// The archive loads into another store as the same model, with its tags.
+	destination, err := NewClient(WithStoreRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	loadedID, err := destination.LoadModel(&archive, nil)
+	if err != nil {
+		t.Fatalf("Failed to load model: %v", err)
+	}
+	if loadedID != id {
+		t.Errorf("Expected loaded model ID %s, got %s", id, loadedID)
+	}
+	for _, tag := range tags {
+		if _, err := destination.GetModel(tag); err != nil {
+			t.Errorf("Expected loaded model to be tagged %s: %v", tag, err)
+		}
+	}
+	bundle, err := destination.GetBundle(tags[1])
+	if err != nil {
+		t.Fatalf("Failed to get bundle: %v", err)
+	}
+	loaded, err := os.ReadFile(bundle.GGUFPath())
+	if err != nil {
+		t.Fatalf("Failed to read loaded model: %v", err)
+	}
+	original, err := os.ReadFile(testGGUFFile)
+	if err != nil {
+		t.Fatalf("Failed to read original model: %v", err)
+	}
+	if !bytes.Equal(loaded, original) {
+		t.Errorf("Loaded content doesn't match original content")
+	}
+	if bundle.ChatTemplatePath() == "" {
+		t.Errorf("Expected loaded bundle to include the chat template")
+	}
+}
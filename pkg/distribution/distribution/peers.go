@@ -0,0 +1,253 @@
+package distribution
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/model-runner/pkg/distribution/internal/store"
+	"github.com/docker/model-runner/pkg/distribution/types"
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+)
+
+// PeerBlobsPath is the path prefix at which model runners serve the blobs in
+// their store to their peers, followed by the digest of a blob.
+const PeerBlobsPath = "/peers/blobs/"
+
+// WithPeers sets the base URLs of the peers, the other model runners of a
+// cluster, that the layers of models are fetched from before falling back to
+// their registry. Base URLs don't have a trailing slash. Peers whose host name resolves to several addresses, such
+// as a headless service, are fetched from at each of them.
+func WithPeers(peers []string) Option {
+	return func(o *options) {
+		o.peers = peers
+	}
+}
+
+// WithPeerToken sets the bearer token that requests to peers are
+// authenticated with, which peers that require API keys accept from the other
+// model runners of their cluster.
+func WithPeerToken(token string) Option {
+	return func(o *options) {
+		o.peerToken = token
+	}
+}
+
+// peerFetcher fetches the blobs of a pull from peers. Peers that serve
+// content that doesn't match its digest aren't fetched from again during the
+// pull.
+type peerFetcher struct {
+	ctx    context.Context
+	log    *logrus.Entry
+	client *http.Client
+	store  *store.LocalStore
+	// token is the bearer token that requests are authenticated with, if any.
+	token string
+	// resolve resolves the addresses of the host of a peer.
+	resolve func(ctx context.Context, host string) ([]string, error)
+
+	peers []string
+	once  sync.Once
+
+	mu  sync.Mutex
+	bad map[string]bool
+}
+
+// newPeerFetcher returns a fetcher for the blobs of a pull, or nil if no peers
+// are configured.
+func (c *Client) newPeerFetcher(ctx context.Context) *peerFetcher {
+	if len(c.peers) == 0 {
+		return nil
+	}
+	return &peerFetcher{
+		ctx:     ctx,
+		log:     c.log,
+		client:  &http.Client{Transport: c.transport},
+		store:   c.store,
+		token:   c.peerToken,
+		resolve: net.DefaultResolver.LookupHost,
+		peers:   c.peers,
+		bad:     make(map[string]bool),
+	}
+}
+
+// candidates returns the addresses of the peers that blobs are fetched from,
+// in random order to spread the load across them. Peers are resolved once per
+// pull; those that can't be resolved are fetched from by name.
+func (f *peerFetcher) candidates() []string {
+	f.once.Do(func() {
+		var resolved []string
+		for _, peer := range f.peers {
+			u, err := url.Parse(peer)
+			if err != nil {
+				continue
+			}
+			addrs, err := f.resolve(f.ctx, u.Hostname())
+			if err != nil || len(addrs) == 0 {
+				resolved = append(resolved, peer)
+				continue
+			}
+			for _, addr := range addrs {
+				host := addr
+				if port := u.Port(); port != "" {
+					host = net.JoinHostPort(addr, port)
+				} else if strings.Contains(addr, ":") {
+					host = "[" + addr + "]"
+				}
+				resolved = append(resolved, u.Scheme+"://"+host+u.Path)
+			}
+		}
+		f.peers = resolved
+	})
+	candidates := make([]string, 0, len(f.peers))
+	f.mu.Lock()
+	for _, peer := range f.peers {
+		if !f.bad[peer] {
+			candidates = append(candidates, peer)
+		}
+	}
+	f.mu.Unlock()
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	return candidates
+}
+
+// fetch returns the content of a blob from the first peer that has it, or
+// false if none does. Blobs whose download was interrupted are resumed from
+// the registry instead, since their content so far can't be verified
+// incrementally.
+func (f *peerFetcher) fetch(diffID v1.Hash, size int64) (io.ReadCloser, bool) {
+	if diffID.Algorithm != "sha256" {
+		return nil, false
+	}
+	if incomplete, err := f.store.GetIncompleteSize(diffID); err != nil || incomplete > 0 {
+		return nil, false
+	}
+	for _, peer := range f.candidates() {
+		req, err := http.NewRequestWithContext(f.ctx, http.MethodGet, peer+PeerBlobsPath+diffID.String(), http.NoBody)
+		if err != nil {
+			continue
+		}
+		if f.token != "" {
+			req.Header.Set("Authorization", "Bearer "+f.token)
+		}
+		resp, err := f.client.Do(req)
+		if err != nil {
+			f.log.Debugf("Peer %s unavailable: %v", peer, err)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			continue
+		}
+		f.log.Infof("Fetching layer %s from peer %s", diffID, peer)
+		return &peerBlobReader{
+			ReadCloser: resp.Body,
+			fetcher:    f,
+			peer:       peer,
+			diffID:     diffID,
+			size:       size,
+			hash:       sha256.New(),
+		}, true
+	}
+	return nil, false
+}
+
+// markBad stops fetching blobs from a peer for the rest of the pull.
+func (f *peerFetcher) markBad(peer string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bad[peer] = true
+}
+
+// peerBlobReader verifies the content of a blob fetched from a peer against
+// its digest and size, failing the read of its end if it doesn't match.
+type peerBlobReader struct {
+	io.ReadCloser
+	fetcher *peerFetcher
+	peer    string
+	diffID  v1.Hash
+	size    int64
+	read    int64
+	hash    hash.Hash
+}
+
+func (r *peerBlobReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	r.read += int64(n)
+	if r.read > r.size || err == io.EOF && (r.read != r.size || hex.EncodeToString(r.hash.Sum(nil)) != r.diffID.Hex) {
+		r.fetcher.markBad(r.peer)
+		return n, fmt.Errorf("layer %s from peer %s: %w", r.diffID, r.peer, store.ErrBlobDigestMismatch)
+	}
+	return n, err
+}
+
+// peerModel is a remote model whose layers are fetched from peers before
+// falling back to its registry.
+type peerModel struct {
+	types.ModelArtifact
+	fetcher *peerFetcher
+}
+
+// withPeers returns a remote model whose layers are fetched from peers, if
+// any are configured.
+func withPeers(mdl types.ModelArtifact, fetcher *peerFetcher) types.ModelArtifact {
+	if fetcher == nil {
+		return mdl
+	}
+	return &peerModel{ModelArtifact: mdl, fetcher: fetcher}
+}
+
+// Layers implements v1.Image.Layers.
+func (m *peerModel) Layers() ([]v1.Layer, error) {
+	layers, err := m.ModelArtifact.Layers()
+	if err != nil {
+		return nil, err
+	}
+	peerLayers := make([]v1.Layer, len(layers))
+	for i, layer := range layers {
+		peerLayers[i] = &peerLayer{Layer: layer, fetcher: m.fetcher}
+	}
+	return peerLayers, nil
+}
+
+// peerLayer is a layer of a remote model that is fetched from peers before
+// falling back to its registry.
+type peerLayer struct {
+	v1.Layer
+	fetcher *peerFetcher
+}
+
+// Uncompressed implements v1.Layer.Uncompressed. Only uncompressed layers,
+// which model layers are, are fetched from peers, since peers serve the
+// uncompressed content of blobs.
+func (l *peerLayer) Uncompressed() (io.ReadCloser, error) {
+	diffID, err := l.DiffID()
+	if err != nil {
+		return nil, err
+	}
+	if digest, err := l.Digest(); err != nil || digest != diffID {
+		return l.Layer.Uncompressed()
+	}
+	size, err := l.Size()
+	if err != nil {
+		return nil, err
+	}
+	if rc, ok := l.fetcher.fetch(diffID, size); ok {
+		return rc, nil
+	}
+	return l.Layer.Uncompressed()
+}
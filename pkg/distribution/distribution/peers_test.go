@@ -0,0 +1,41 @@
+package distribution
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestPeerCandidates(t *testing.T) {
+	fetcher := &peerFetcher{
+		ctx: context.Background(),
+		log: logrus.NewEntry(logrus.StandardLogger()),
+		resolve: func(_ context.Context, host string) ([]string, error) {
+			switch host {
+			case "peers":
+				return []string{"10.0.0.1", "fd00::1"}, nil
+			case "proxied":
+				return []string{"10.0.0.2"}, nil
+			}
+			return nil, context.DeadlineExceeded
+		},
+		peers: []string{"http://peers:12434", "http://unresolved", "http://proxied/runner"},
+		bad:   make(map[string]bool),
+	}
+	candidates := fetcher.candidates()
+	slices.Sort(candidates)
+	expected := []string{"http://10.0.0.1:12434", "http://10.0.0.2/runner", "http://[fd00::1]:12434", "http://unresolved"}
+	if !slices.Equal(candidates, expected) {
+		t.Errorf("Expected candidates %v, got %v", expected, candidates)
+	}
+
+	// Peers that served corrupt content are no longer candidates.
+	fetcher.markBad("http://10.0.0.1:12434")
+	candidates = fetcher.candidates()
+	slices.Sort(candidates)
+	if expected := []string{"http://10.0.0.2/runner", "http://[fd00::1]:12434", "http://unresolved"}; !slices.Equal(candidates, expected) {
+		t.Errorf("Expected candidates %v, got %v", expected, candidates)
+	}
+}
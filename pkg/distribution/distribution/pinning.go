@@ -0,0 +1,84 @@
+package distribution
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/distribution/internal/progress"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+// DigestPinningMode controls how the client reacts when a tag that has been
+// pulled before resolves to a different manifest digest (trust on first use).
+type DigestPinningMode string
+
+const (
+	// DigestPinningOff disables digest pinning.
+	DigestPinningOff DigestPinningMode = "off"
+	// DigestPinningWarn reports a digest change but proceeds with the pull,
+	// pinning the tag to the new digest.
+	DigestPinningWarn DigestPinningMode = "warn"
+	// DigestPinningBlock refuses to pull a tag whose digest has changed.
+	DigestPinningBlock DigestPinningMode = "block"
+)
+
+// ParseDigestPinningMode parses a digest pinning mode.
+func ParseDigestPinningMode(s string) (DigestPinningMode, error) {
+	switch mode := DigestPinningMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case DigestPinningOff, DigestPinningWarn, DigestPinningBlock:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid digest pinning mode %q (expected off, warn, or block)", s)
+	}
+}
+
+// checkPinnedDigest compares the digest a tag resolved to against the digest
+// pinned when the tag was first pulled.
+func (c *Client) checkPinnedDigest(reference, digest string, progressWriter io.Writer) error {
+	if c.digestPinning == DigestPinningOff || strings.Contains(reference, "@") {
+		return nil
+	}
+	pinned, ok, err := c.store.PinnedDigest(reference)
+	if err != nil {
+		c.log.Warnf("Failed to read pinned digest for %s: %v", utils.SanitizeForLog(reference), err)
+		return nil
+	}
+	if !ok || pinned == digest {
+		return nil
+	}
+
+	msg := fmt.Sprintf("%s now resolves to %s, but was pinned to %s when first pulled", reference, digest, pinned)
+	if c.digestPinning == DigestPinningBlock {
+		msg += "; unpin the tag to accept the new digest"
+		c.log.Errorln("Refusing to pull model with changed digest:", utils.SanitizeForLog(msg))
+		if writeErr := progress.WriteError(progressWriter, fmt.Sprintf("Error: %s", msg)); writeErr != nil {
+			c.log.Warnf("Failed to write error message: %v", writeErr)
+		}
+		return fmt.Errorf("%s: %w", msg, ErrDigestMismatch)
+	}
+	c.log.Warnln("Model digest changed:", utils.SanitizeForLog(msg))
+	if err := progress.WriteWarning(progressWriter, fmt.Sprintf("Warning: %s", msg)); err != nil {
+		c.log.Warnf("Failed to write warning message: %v", err)
+	}
+	return nil
+}
+
+// pinDigest records the digest a tag resolved to. Failures are logged but
+// don't fail the pull.
+func (c *Client) pinDigest(reference, digest string) {
+	if c.digestPinning == DigestPinningOff || strings.Contains(reference, "@") {
+		return
+	}
+	if err := c.store.PinDigest(reference, digest); err != nil {
+		c.log.Warnf("Failed to pin digest for %s: %v", utils.SanitizeForLog(reference), err)
+	}
+}
+
+// UnpinDigest removes the digest pinned for a tag, so that a tag that was
+// re-pushed can be pulled again, pinning its new digest. It returns the
+// removed digest and whether the tag was pinned.
+func (c *Client) UnpinDigest(tag string) (string, bool, error) {
+	c.log.Infoln("Unpinning digest of:", utils.SanitizeForLog(tag))
+	return c.store.UnpinDigest(tag)
+}
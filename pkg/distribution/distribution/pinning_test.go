@@ -0,0 +1,120 @@
+package distribution
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/registry"
+)
+
+func TestClientPullModelDigestPinning(t *testing.T) {
+	// Set up test registry
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse registry URL: %v", err)
+	}
+	tag := registryURL.Host + "/testmodel:latest"
+
+	// Create a second model to swap in under the same tag
+	swapFile, err := randomFile(1024)
+	if err != nil {
+		t.Fatalf("Failed to create random file: %v", err)
+	}
+	defer os.Remove(swapFile)
+
+	tests := []struct {
+		name        string
+		mode        DigestPinningMode
+		expectError bool
+		expectWarn  bool
+	}{
+		{name: "off", mode: DigestPinningOff},
+		{name: "warn", mode: DigestPinningWarn, expectWarn: true},
+		{name: "block", mode: DigestPinningBlock, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := writeToRegistry(testGGUFFile, tag); err != nil {
+				t.Fatalf("Failed to push model: %v", err)
+			}
+
+			client, err := NewClient(WithStoreRootPath(t.TempDir()), WithDigestPinning(tt.mode))
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			if err := client.PullModel(context.Background(), tag, nil); err != nil {
+				t.Fatalf("Failed to pull model: %v", err)
+			}
+
+			// Swap the model behind the tag and pull again
+			if err := writeToRegistry(swapFile, tag); err != nil {
+				t.Fatalf("Failed to push swapped model: %v", err)
+			}
+			var progressBuffer bytes.Buffer
+			err = client.PullModel(context.Background(), tag, &progressBuffer)
+			if tt.expectError {
+				if !errors.Is(err, ErrDigestMismatch) {
+					t.Fatalf("Expected ErrDigestMismatch, got %v", err)
+				}
+
+				// Unpinning the tag accepts its new digest.
+				if _, ok, err := client.UnpinDigest(tag); err != nil || !ok {
+					t.Fatalf("Failed to unpin digest: ok=%v err=%v", ok, err)
+				}
+				if err := client.PullModel(context.Background(), tag, nil); err != nil {
+					t.Fatalf("Failed to pull swapped model after unpinning: %v", err)
+				}
+				if err := client.PullModel(context.Background(), tag, nil); err != nil {
+					t.Fatalf("Failed to pull swapped model again: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to pull swapped model: %v", err)
+			}
+			if warned := strings.Contains(progressBuffer.String(), "pinned to"); warned != tt.expectWarn {
+				t.Errorf("Expected warning=%v, got progress output %q", tt.expectWarn, progressBuffer.String())
+			}
+		})
+	}
+}
+
+func TestParseDigestPinningMode(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    DigestPinningMode
+		expectError bool
+	}{
+		{input: "off", expected: DigestPinningOff},
+		{input: "WARN", expected: DigestPinningWarn},
+		{input: " block ", expected: DigestPinningBlock},
+		{input: "strict", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mode, err := ParseDigestPinningMode(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("Expected error for %q", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if mode != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, mode)
+			}
+		})
+	}
+}
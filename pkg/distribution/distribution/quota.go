@@ -0,0 +1,104 @@
+package distribution
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+
+	"github.com/docker/model-runner/pkg/distribution/internal/progress"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+// ensureQuota makes room for required bytes within the store quota, by
+// evicting the least recently used models that aren't pinned or in use. The
+// model with the keep ID, which is being written, is never evicted.
+func (c *Client) ensureQuota(required int64, keep string, progressWriter io.Writer) error {
+	if c.storeQuota == 0 || required <= 0 {
+		return nil
+	}
+	size, err := c.store.Size()
+	if err != nil {
+		c.log.Warnf("Failed to determine store size: %v", err)
+		return nil
+	}
+	if uint64(size+required) <= c.storeQuota {
+		return nil
+	}
+
+	// Evictions remove blobs, which models that are being written may share.
+	unlock, err := c.lockStore()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	candidates, err := c.store.EvictionCandidates()
+	if err != nil {
+		return fmt.Errorf("listing models to evict: %w", err)
+	}
+	for _, candidate := range candidates {
+		if uint64(size+required) <= c.storeQuota {
+			break
+		}
+		if candidate.ID == keep || (c.modelInUse != nil && c.modelInUse(candidate.ID)) {
+			continue
+		}
+		if _, _, err := c.store.Delete(candidate.ID); err != nil {
+			c.log.Warnf("Failed to evict model %s: %v", utils.SanitizeForLog(candidate.ID), err)
+			continue
+		}
+		name := candidate.ID
+		if len(candidate.Tags) > 0 {
+			name = strings.Join(candidate.Tags, ", ")
+		}
+		msg := fmt.Sprintf("Evicted %s, last used %s, to stay within the store quota",
+			name, candidate.LastUsed.Format(time.RFC3339))
+		c.log.Infoln(utils.SanitizeForLog(msg))
+		if err := progress.WriteWarning(progressWriter, msg); err != nil {
+			c.log.Warnf("Failed to write warning message: %v", err)
+		}
+		if size, err = c.store.Size(); err != nil {
+			c.log.Warnf("Failed to determine store size: %v", err)
+			return nil
+		}
+	}
+
+	if uint64(size+required) > c.storeQuota {
+		err := fmt.Errorf("%w: %s required, but %s of the %s quota is used by models that are pinned or loaded",
+			ErrQuotaExceeded, units.BytesSize(float64(required)), units.BytesSize(float64(size)), units.BytesSize(float64(c.storeQuota)))
+		c.log.Errorln("Not enough space within the store quota:", err)
+		if writeErr := progress.WriteError(progressWriter, fmt.Sprintf("Error: %s", err.Error())); writeErr != nil {
+			c.log.Warnf("Failed to write error message: %v", writeErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// markUsed records that a model was used now. Failures are logged but don't
+// fail the operation that used the model.
+func (c *Client) markUsed(reference string) {
+	if err := c.store.MarkUsed(reference, time.Now()); err != nil {
+		c.log.Warnf("Failed to record use of %s: %v", utils.SanitizeForLog(reference), err)
+	}
+}
+
+// MarkUsed records that a model was used now, so that it's evicted after
+// models that were used less recently.
+func (c *Client) MarkUsed(reference string) error {
+	return c.store.MarkUsed(reference, time.Now())
+}
+
+// SetModelPinned pins or unpins a model. Pinned models are never evicted to
+// stay within the store quota.
+func (c *Client) SetModelPinned(reference string, pinned bool) error {
+	return c.store.SetPinned(reference, pinned)
+}
+
+// IsModelPinned returns true if the model with the specified ID is pinned.
+func (c *Client) IsModelPinned(id string) bool {
+	usage, err := c.store.ModelUsage(id)
+	return err == nil && usage.Pinned
+}
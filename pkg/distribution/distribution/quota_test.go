@@ -0,0 +1,130 @@
+package distribution
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/registry"
+)
+
+func TestClientPullModelStoreQuota(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse registry URL: %v", err)
+	}
+
+	// Each model is a little over 100 KiB, so that only two fit in the quota.
+	var tags []string
+	for i := range 3 {
+		modelFile, err := randomFile(100 * 1024)
+		if err != nil {
+			t.Fatalf("Failed to create random file: %v", err)
+		}
+		defer os.Remove(modelFile)
+		tag := fmt.Sprintf("%s/quota%d:latest", registryURL.Host, i)
+		if err := writeToRegistry(modelFile, tag); err != nil {
+			t.Fatalf("Failed to push model: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	var mu sync.Mutex
+	inUse := map[string]bool{}
+	client, err := NewClient(
+		WithStoreRootPath(t.TempDir()),
+		WithStoreQuota(250*1024),
+		WithModelInUse(func(id string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return inUse[id]
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	pull := func(tag string) (string, error) {
+		var progressBuffer bytes.Buffer
+		err := client.PullModel(context.Background(), tag, &progressBuffer)
+		return progressBuffer.String(), err
+	}
+	stored := func(tag string) bool {
+		_, err := client.GetModel(tag)
+		return err == nil
+	}
+
+	for _, tag := range tags[:2] {
+		if _, err := pull(tag); err != nil {
+			t.Fatalf("Failed to pull model: %v", err)
+		}
+	}
+
+	// The least recently used model is evicted to make room.
+	output, err := pull(tags[2])
+	if err != nil {
+		t.Fatalf("Failed to pull model: %v", err)
+	}
+	if stored(tags[0]) || !stored(tags[1]) || !stored(tags[2]) {
+		t.Errorf("Expected the least recently used model to be evicted")
+	}
+	if !strings.Contains(output, "Evicted "+tags[0]) {
+		t.Errorf("Expected eviction to be reported, got progress output %q", output)
+	}
+
+	// Pinned models aren't evicted, even if they're used least recently.
+	if err := client.SetModelPinned(tags[1], true); err != nil {
+		t.Fatalf("Failed to pin model: %v", err)
+	}
+	if _, err := pull(tags[0]); err != nil {
+		t.Fatalf("Failed to pull model: %v", err)
+	}
+	if !stored(tags[0]) || !stored(tags[1]) || stored(tags[2]) {
+		t.Errorf("Expected the pinned model to be kept")
+	}
+
+	// Models in use aren't evicted either, so the pull fails if nothing else
+	// can be evicted.
+	model, err := client.GetModel(tags[0])
+	if err != nil {
+		t.Fatalf("Failed to get model: %v", err)
+	}
+	id, err := model.ID()
+	if err != nil {
+		t.Fatalf("Failed to get model ID: %v", err)
+	}
+	mu.Lock()
+	inUse[id] = true
+	mu.Unlock()
+	if _, err := pull(tags[2]); !errors.Is(err, ErrQuotaExceeded) || !errors.Is(err, ErrInsufficientSpace) {
+		t.Fatalf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	if !stored(tags[0]) || !stored(tags[1]) || stored(tags[2]) {
+		t.Errorf("Expected models not to change after a failed pull")
+	}
+
+	// Unpinned models can be evicted again.
+	if err := client.SetModelPinned(tags[1], false); err != nil {
+		t.Fatalf("Failed to unpin model: %v", err)
+	}
+	if client.IsModelPinned(id) {
+		t.Errorf("Expected model not to be pinned")
+	}
+	if _, err := pull(tags[2]); err != nil {
+		t.Fatalf("Failed to pull model: %v", err)
+	}
+	if !stored(tags[0]) || stored(tags[1]) || !stored(tags[2]) {
+		t.Errorf("Expected the unpinned model to be evicted")
+	}
+	if err := client.SetModelPinned("missing/model", true); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("Expected ErrModelNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package distribution
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rateLimitReadSize bounds the size of individual reads from rate limited
+// response bodies, so that the limit is applied smoothly.
+const rateLimitReadSize = 64 * 1024
+
+// rateLimiter is a token bucket that limits the aggregate rate at which bytes
+// are read, allowing bursts of up to a second's worth of bytes.
+type rateLimiter struct {
+	// bytesPerSecond is the rate limit.
+	bytesPerSecond float64
+	// lock guards all subsequent fields.
+	lock sync.Mutex
+	// tokens are the bytes that can be read without waiting. They're negative
+	// if reads are waiting.
+	tokens float64
+	// updated is the time at which tokens was last updated.
+	updated time.Time
+}
+
+// reserve accounts for n bytes being read and returns how long the reader
+// must wait to stay within the rate limit.
+func (l *rateLimiter) reserve(n int) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	if l.updated.IsZero() {
+		l.tokens = l.bytesPerSecond
+	} else {
+		l.tokens = min(l.tokens+now.Sub(l.updated).Seconds()*l.bytesPerSecond, l.bytesPerSecond)
+	}
+	l.updated = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.bytesPerSecond * float64(time.Second))
+}
+
+// rateLimitedTransport limits the rate at which response bodies are read.
+type rateLimitedTransport struct {
+	transport http.RoundTripper
+	limiter   *rateLimiter
+}
+
+// NewRateLimitedTransport wraps a transport so that the response bodies of
+// all of its requests are read at no more than bytesPerSecond in aggregate.
+// Sharing the returned transport between clients shares the limit.
+func NewRateLimitedTransport(transport http.RoundTripper, bytesPerSecond uint64) http.RoundTripper {
+	return &rateLimitedTransport{
+		transport: transport,
+		limiter:   &rateLimiter{bytesPerSecond: float64(bytesPerSecond)},
+	}
+}
+
+// RoundTrip implements http.RoundTripper.RoundTrip.
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &rateLimitedBody{ReadCloser: resp.Body, req: req, limiter: t.limiter}
+	return resp, nil
+}
+
+// rateLimitedBody is a response body whose reads are rate limited.
+type rateLimitedBody struct {
+	io.ReadCloser
+	req     *http.Request
+	limiter *rateLimiter
+}
+
+// Read implements io.Reader.Read.
+func (b *rateLimitedBody) Read(p []byte) (int, error) {
+	if len(p) > rateLimitReadSize {
+		p = p[:rateLimitReadSize]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if delay := b.limiter.reserve(n); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-b.req.Context().Done():
+			return n, b.req.Context().Err()
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,57 @@
+package distribution
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	limiter := &rateLimiter{bytesPerSecond: 1000}
+
+	// A second's worth of bytes is read without waiting.
+	if delay := limiter.reserve(1000); delay != 0 {
+		t.Errorf("expected burst without delay, got %s", delay)
+	}
+	// Further bytes wait for the bucket to refill.
+	if delay := limiter.reserve(500); delay < 400*time.Millisecond || delay > 500*time.Millisecond {
+		t.Errorf("expected a delay of about 500ms, got %s", delay)
+	}
+	if delay := limiter.reserve(500); delay < 900*time.Millisecond || delay > time.Second {
+		t.Errorf("expected a delay of about 1s, got %s", delay)
+	}
+}
+
+type staticRoundTripper []byte
+
+func (b staticRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(b)), Request: req}, nil
+}
+
+func TestRateLimitedTransport(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 3*rateLimitReadSize)
+	transport := NewRateLimitedTransport(staticRoundTripper(content), 2*rateLimitReadSize)
+	req, err := http.NewRequest(http.MethodGet, "http://registry.example/v2/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("expected %d bytes, got %d", len(content), len(got))
+	}
+	// Two reads are covered by the burst, and the third waits half a second.
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("expected reads to be rate limited, took %s", elapsed)
+	}
+}
@@ -0,0 +1,18 @@
+package distribution
+
+import (
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+// GetModelSettings returns the settings stored with a model, which are empty
+// if none are.
+func (c *Client) GetModelSettings(reference string) (types.ModelSettings, error) {
+	return c.store.Settings(reference)
+}
+
+// SetModelSettings replaces the settings stored with a model. They're applied
+// to the bundles of the model, and removed along with it. Empty settings
+// remove them.
+func (c *Client) SetModelSettings(reference string, settings types.ModelSettings) error {
+	return c.store.SetSettings(reference, settings)
+}
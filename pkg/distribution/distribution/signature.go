@@ -0,0 +1,166 @@
+package distribution
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/distribution/internal/progress"
+	"github.com/docker/model-runner/pkg/distribution/registry"
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+// SignatureVerificationMode controls whether the client verifies the cosign
+// signatures of models when pulling them.
+type SignatureVerificationMode string
+
+const (
+	// SignatureVerificationOff disables signature verification.
+	SignatureVerificationOff SignatureVerificationMode = "off"
+	// SignatureVerificationWarn verifies signatures, but only reports models
+	// that are unsigned or not signed by a trusted key.
+	SignatureVerificationWarn SignatureVerificationMode = "warn"
+	// SignatureVerificationEnforce refuses to pull models that are unsigned
+	// or not signed by a trusted key.
+	SignatureVerificationEnforce SignatureVerificationMode = "enforce"
+)
+
+// ParseSignatureVerificationMode parses a signature verification mode.
+func ParseSignatureVerificationMode(s string) (SignatureVerificationMode, error) {
+	switch mode := SignatureVerificationMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case SignatureVerificationOff, SignatureVerificationWarn, SignatureVerificationEnforce:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid signature verification mode %q (expected off, warn, or enforce)", s)
+	}
+}
+
+// LoadPublicKeys reads the PEM encoded public keys in a file, such as the
+// cosign.pub file written by cosign generate-key-pair. ECDSA, RSA, and Ed25519
+// keys are supported.
+func LoadPublicKeys(path string) ([]crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading public keys: %w", err)
+	}
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing public key in %s: %w", path, err)
+		}
+		switch key.(type) {
+		case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T in %s", key, path)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no PEM encoded public keys in %s", path)
+	}
+	return keys, nil
+}
+
+// simpleSigningPayload is the part of a cosign simple signing payload that
+// identifies the signed manifest.
+type simpleSigningPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// cosignSignatureType is the type of cosign simple signing payloads.
+const cosignSignatureType = "cosign container image signature"
+
+// verifyPayloadSignature returns true if the signature of a payload was made
+// with the private key of the public key, in the same way as cosign signs.
+func verifyPayloadSignature(key crypto.PublicKey, payload, signature []byte) bool {
+	digest := sha256.Sum256(payload)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, payload, signature)
+	default:
+		return false
+	}
+}
+
+// trustedSignature returns true if one of the signatures is of a payload for
+// the manifest digest, made with one of the trusted keys.
+func trustedSignature(signatures []registry.Signature, digest v1.Hash, keys []crypto.PublicKey) bool {
+	for _, signature := range signatures {
+		var payload simpleSigningPayload
+		if err := json.Unmarshal(signature.Payload, &payload); err != nil ||
+			payload.Critical.Type != cosignSignatureType ||
+			payload.Critical.Image.DockerManifestDigest != digest.String() {
+			continue
+		}
+		for _, key := range keys {
+			if verifyPayloadSignature(key, signature.Payload, signature.Signature) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifySignature verifies that the manifest a reference resolved to has a
+// cosign signature made with a trusted key.
+func (c *Client) verifySignature(ctx context.Context, registryClient *registry.Client, reference string, digest v1.Hash, progressWriter io.Writer) error {
+	if c.signatureVerification == SignatureVerificationOff {
+		return nil
+	}
+	signatures, err := registryClient.Signatures(ctx, reference, digest)
+	if err != nil {
+		return fmt.Errorf("reading model signatures: %w", err)
+	}
+
+	var msg string
+	switch {
+	case trustedSignature(signatures, digest, c.signatureKeys):
+		c.log.Infoln("Verified model signature:", utils.SanitizeForLog(reference))
+		return nil
+	case len(signatures) == 0:
+		msg = fmt.Sprintf("%s (%s) is not signed", reference, digest)
+	default:
+		msg = fmt.Sprintf("%s (%s) is not signed by a trusted key", reference, digest)
+	}
+	if c.signatureVerification == SignatureVerificationEnforce {
+		c.log.Errorln("Refusing to pull model without a trusted signature:", utils.SanitizeForLog(msg))
+		if writeErr := progress.WriteError(progressWriter, fmt.Sprintf("Error: %s", msg)); writeErr != nil {
+			c.log.Warnf("Failed to write error message: %v", writeErr)
+		}
+		return fmt.Errorf("%s: %w", msg, ErrUntrustedSignature)
+	}
+	c.log.Warnln("Model signature not verified:", utils.SanitizeForLog(msg))
+	if err := progress.WriteWarning(progressWriter, fmt.Sprintf("Warning: %s", msg)); err != nil {
+		c.log.Warnf("Failed to write warning message: %v", err)
+	}
+	return nil
+}
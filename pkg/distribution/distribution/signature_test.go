@@ -0,0 +1,291 @@
+package distribution
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/name"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/registry"
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/empty"
+	ggcrmutate "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/mutate"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/remote"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/static"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/types"
+
+	mdregistry "github.com/docker/model-runner/pkg/distribution/registry"
+)
+
+// signModel pushes a cosign signature for the manifest that the tag refers
+// to, which is signed with the ECDSA key and whose payload identifies the
+// specified manifest digest.
+func signModel(t *testing.T, tag string, key *ecdsa.PrivateKey, digest v1.Hash) {
+	t.Helper()
+	payload := fmt.Sprintf(`{"critical":{"identity":{"docker-reference":%q},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`,
+		strings.Split(tag, ":")[0], digest)
+	hash := sha256.Sum256([]byte(payload))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatalf("Failed to sign payload: %v", err)
+	}
+	img, err := ggcrmutate.Append(empty.Image, ggcrmutate.Addendum{
+		Layer:       static.NewLayer([]byte(payload), types.MediaType(mdregistry.MediaTypeSimpleSigning)),
+		Annotations: map[string]string{mdregistry.SignatureAnnotation: base64.StdEncoding.EncodeToString(signature)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create signature image: %v", err)
+	}
+	signatureTag, err := mdregistry.SignatureTag(tag, remoteDigest(t, tag))
+	if err != nil {
+		t.Fatalf("Failed to get signature tag: %v", err)
+	}
+	if err := remote.Write(signatureTag, img); err != nil {
+		t.Fatalf("Failed to push signature: %v", err)
+	}
+}
+
+// remoteDigest returns the manifest digest that a tag refers to.
+func remoteDigest(t *testing.T, tag string) v1.Hash {
+	t.Helper()
+	ref, err := name.ParseReference(tag)
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	desc, err := remote.Head(ref)
+	if err != nil {
+		t.Fatalf("Failed to get remote digest: %v", err)
+	}
+	return desc.Digest
+}
+
+func TestClientPullModelSignatureVerification(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse registry URL: %v", err)
+	}
+
+	trusted, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	untrusted, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	// Each model has its own repository and contents, so that signatures
+	// don't carry over between cases.
+	tests := []struct {
+		name        string
+		mode        SignatureVerificationMode
+		sign        func(tag string)
+		expectError bool
+		expectWarn  bool
+	}{
+		{name: "off unsigned", mode: SignatureVerificationOff},
+		{name: "warn unsigned", mode: SignatureVerificationWarn, expectWarn: true},
+		{name: "enforce unsigned", mode: SignatureVerificationEnforce, expectError: true},
+		{
+			name: "enforce trusted",
+			mode: SignatureVerificationEnforce,
+			sign: func(tag string) { signModel(t, tag, trusted, remoteDigest(t, tag)) },
+		},
+		{
+			name:        "enforce untrusted",
+			mode:        SignatureVerificationEnforce,
+			sign:        func(tag string) { signModel(t, tag, untrusted, remoteDigest(t, tag)) },
+			expectError: true,
+		},
+		{
+			name: "enforce other digest",
+			mode: SignatureVerificationEnforce,
+			sign: func(tag string) {
+				digest := remoteDigest(t, tag)
+				signModel(t, tag, trusted, v1.Hash{Algorithm: digest.Algorithm, Hex: strings.Repeat("0", len(digest.Hex))})
+			},
+			expectError: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modelFile, err := randomFile(1024)
+			if err != nil {
+				t.Fatalf("Failed to create random file: %v", err)
+			}
+			defer os.Remove(modelFile)
+			tag := fmt.Sprintf("%s/signed%d:latest", registryURL.Host, i)
+			if err := writeToRegistry(modelFile, tag); err != nil {
+				t.Fatalf("Failed to push model: %v", err)
+			}
+			if tt.sign != nil {
+				tt.sign(tag)
+			}
+
+			client, err := NewClient(WithStoreRootPath(t.TempDir()), WithSignatureVerification(tt.mode, trusted.Public()))
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			var progressBuffer bytes.Buffer
+			err = client.PullModel(context.Background(), tag, &progressBuffer)
+			if tt.expectError {
+				if !errors.Is(err, ErrUntrustedSignature) {
+					t.Fatalf("Expected ErrUntrustedSignature, got %v", err)
+				}
+				if _, err := client.GetModel(tag); !errors.Is(err, ErrModelNotFound) {
+					t.Errorf("Expected refused model not to be stored, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to pull model: %v", err)
+			}
+			if warned := strings.Contains(progressBuffer.String(), "not signed"); warned != tt.expectWarn {
+				t.Errorf("Expected warning=%v, got progress output %q", tt.expectWarn, progressBuffer.String())
+			}
+		})
+	}
+}
+
+func TestNewClientSignatureVerificationRequiresKeys(t *testing.T) {
+	if _, err := NewClient(WithStoreRootPath(t.TempDir()), WithSignatureVerification(SignatureVerificationEnforce)); err == nil {
+		t.Errorf("Expected error enabling signature verification without keys")
+	}
+}
+
+func TestPullModelFromHubWithEnforcedSignatures(t *testing.T) {
+	key, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	client, err := NewClient(WithStoreRootPath(t.TempDir()), WithSignatureVerification(SignatureVerificationEnforce, key))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.PullModel(context.Background(), "hf.co/org/model", nil); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("Expected Hugging Face pull to be refused, got %v", err)
+	}
+}
+
+func TestVerifyPayloadSignature(t *testing.T) {
+	payload := []byte("payload")
+	hash := sha256.Sum256(payload)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ecdsaSignature, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, hash[:])
+	if err != nil {
+		t.Fatalf("Failed to sign payload: %v", err)
+	}
+	ed25519Public, ed25519Private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ed25519Signature := ed25519.Sign(ed25519Private, payload)
+
+	tests := []struct {
+		name      string
+		key       crypto.PublicKey
+		signature []byte
+		expected  bool
+	}{
+		{name: "ecdsa", key: ecdsaKey.Public(), signature: ecdsaSignature, expected: true},
+		{name: "ed25519", key: ed25519Public, signature: ed25519Signature, expected: true},
+		{name: "wrong key", key: ed25519Public, signature: ecdsaSignature},
+		{name: "wrong signature", key: ecdsaKey.Public(), signature: ed25519Signature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if verified := verifyPayloadSignature(tt.key, payload, tt.signature); verified != tt.expected {
+				t.Errorf("Expected verified=%v, got %v", tt.expected, verified)
+			}
+		})
+	}
+}
+
+func TestLoadPublicKeys(t *testing.T) {
+	dir := t.TempDir()
+	var keys bytes.Buffer
+	for range 2 {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			t.Fatalf("Failed to marshal key: %v", err)
+		}
+		pem.Encode(&keys, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+	path := filepath.Join(dir, "cosign.pub")
+	if err := os.WriteFile(path, keys.Bytes(), 0o644); err != nil {
+		t.Fatalf("Failed to write keys: %v", err)
+	}
+	loaded, err := LoadPublicKeys(path)
+	if err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	if len(loaded) != 2 {
+		t.Errorf("Expected 2 keys, got %d", len(loaded))
+	}
+
+	empty := filepath.Join(dir, "empty.pub")
+	if err := os.WriteFile(empty, []byte("not a key"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := LoadPublicKeys(empty); err == nil {
+		t.Errorf("Expected error loading a file without keys")
+	}
+}
+
+func TestParseSignatureVerificationMode(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    SignatureVerificationMode
+		expectError bool
+	}{
+		{input: "off", expected: SignatureVerificationOff},
+		{input: "WARN", expected: SignatureVerificationWarn},
+		{input: " enforce ", expected: SignatureVerificationEnforce},
+		{input: "block", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mode, err := ParseSignatureVerificationMode(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("Expected error for %q", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if mode != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, mode)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package distribution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/docker/go-units"
+	"github.com/docker/model-runner/pkg/internal/utils"
+
+	"github.com/docker/model-runner/pkg/distribution/internal/store"
+	"github.com/docker/model-runner/pkg/distribution/registry"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/name"
+)
+
+// Verification is the result of verifying the store.
+type Verification = store.Verification
+
+// DamagedModel is a model with damaged blobs.
+type DamagedModel = store.DamagedModel
+
+// DamagedBlob is a blob, or the manifest, of a model whose content doesn't
+// match its digest.
+type DamagedBlob = store.DamagedBlob
+
+// VerifyStore re-hashes the manifests and blobs of all the models in the
+// store against their digests, and reports the models whose content is
+// missing, truncated, or corrupt, such as after disk failures or interrupted
+// writes. If repair is set, damaged models are quarantined and pulled again by
+// digest from the repositories of their tags, which only fetches their damaged
+// blobs; models that can't be repaired stay quarantined until they're pulled
+// again.
+func (c *Client) VerifyStore(ctx context.Context, repair bool) (Verification, error) {
+	unlock, err := c.lockWrites()
+	if err != nil {
+		return Verification{}, err
+	}
+	verification, err := c.store.Verify()
+	unlock()
+	if err != nil {
+		return Verification{}, fmt.Errorf("verifying store: %w", err)
+	}
+	c.log.Infof("Verified %d model(s) and %d blob(s) totaling %s: %d damaged model(s)",
+		verification.Models, verification.Blobs, units.BytesSize(float64(verification.Size)), len(verification.Damaged))
+	if !repair || len(verification.Damaged) == 0 {
+		return verification, nil
+	}
+
+	unlock, err = c.lockStore()
+	if err != nil {
+		return Verification{}, err
+	}
+	err = c.store.QuarantineDamaged(verification.Damaged)
+	unlock()
+	if err != nil {
+		return Verification{}, fmt.Errorf("quarantining damaged models: %w", err)
+	}
+	for i := range verification.Damaged {
+		model := &verification.Damaged[i]
+		if err := c.repairModel(ctx, *model); err != nil {
+			c.log.Warnf("Failed to repair model %s: %v", utils.SanitizeForLog(model.ID), err)
+			model.Error = err.Error()
+			continue
+		}
+		model.Repaired = true
+	}
+	return verification, nil
+}
+
+// repairModel pulls a quarantined model again by digest from the repository
+// of one of its tags, restoring all of its tags.
+func (c *Client) repairModel(ctx context.Context, model DamagedModel) error {
+	if len(model.Tags) == 0 {
+		return errors.New("model has no tags to pull it from")
+	}
+	var errs []error
+	for _, tag := range model.Tags {
+		ref, err := name.ParseReference(tag, registry.GetDefaultRegistryOptions()...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parsing tag %s: %w", tag, err))
+			continue
+		}
+		reference := ref.Context().Name() + "@" + model.ID
+		remoteModel, err := c.registry.Model(ctx, reference)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reading model from registry: %w", err))
+			continue
+		}
+		unlock, err := c.lockWrites()
+		if err != nil {
+			return err
+		}
+		err = c.store.WriteContext(ctx, remoteModel, model.Tags, io.Discard)
+		unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("writing model to store: %w", err))
+			continue
+		}
+		c.log.Infof("Repaired model %s from %s", utils.SanitizeForLog(model.ID), utils.SanitizeForLog(reference))
+		return nil
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,95 @@
+package distribution
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/registry"
+)
+
+func TestClientVerifyStoreRepair(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse registry URL: %v", err)
+	}
+
+	const size = 64 * 1024
+	modelFile, err := randomFile(size)
+	if err != nil {
+		t.Fatalf("Failed to create random file: %v", err)
+	}
+	defer os.Remove(modelFile)
+	tag := registryURL.Host + "/verify:latest"
+	if err := writeToRegistry(modelFile, tag); err != nil {
+		t.Fatalf("Failed to push model: %v", err)
+	}
+
+	storePath := t.TempDir()
+	client, err := NewClient(WithStoreRootPath(storePath))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.PullModel(context.Background(), tag, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Failed to pull model: %v", err)
+	}
+	model, err := client.GetModel(tag)
+	if err != nil {
+		t.Fatalf("Failed to get model: %v", err)
+	}
+	id, err := model.ID()
+	if err != nil {
+		t.Fatalf("Failed to get model ID: %v", err)
+	}
+
+	// Corrupt the weights in place, keeping their size.
+	blobs, err := filepath.Glob(filepath.Join(storePath, "blobs", "sha256", "*"))
+	if err != nil {
+		t.Fatalf("Failed to list blobs: %v", err)
+	}
+	var corrupted bool
+	for _, blob := range blobs {
+		if fi, err := os.Stat(blob); err == nil && fi.Size() == size {
+			if err := os.WriteFile(blob, make([]byte, size), 0644); err != nil {
+				t.Fatalf("Failed to corrupt blob: %v", err)
+			}
+			corrupted = true
+		}
+	}
+	if !corrupted {
+		t.Fatal("Failed to find the weights blob")
+	}
+
+	verification, err := client.VerifyStore(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Failed to verify store: %v", err)
+	}
+	if len(verification.Damaged) != 1 || verification.Damaged[0].ID != id || verification.Damaged[0].Repaired {
+		t.Fatalf("Expected the model to be reported as damaged, got %+v", verification.Damaged)
+	}
+
+	verification, err = client.VerifyStore(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Failed to verify store: %v", err)
+	}
+	if len(verification.Damaged) != 1 || !verification.Damaged[0].Repaired {
+		t.Fatalf("Expected the model to be repaired, got %+v", verification.Damaged)
+	}
+	if _, err := client.GetModel(tag); err != nil {
+		t.Fatalf("Expected the repaired model to keep its tag: %v", err)
+	}
+
+	verification, err = client.VerifyStore(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Failed to verify store: %v", err)
+	}
+	if verification.Models != 1 || len(verification.Damaged) != 0 {
+		t.Fatalf("Expected the store to be intact after the repair, got %+v", verification)
+	}
+}
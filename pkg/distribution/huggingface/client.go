@@ -0,0 +1,295 @@
+package huggingface
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/distribution/registry"
+)
+
+const (
+	// DefaultEndpoint is the Hub endpoint used unless HF_ENDPOINT is set.
+	DefaultEndpoint = "https://huggingface.co"
+	// revision is the revision of repositories that files are pulled from.
+	revision = "main"
+	// incompleteSuffix is the suffix of files that are being downloaded.
+	incompleteSuffix = ".incomplete"
+)
+
+// File is a file in a Hub repository.
+type File struct {
+	// Path is the file's path in the repository.
+	Path string `json:"path"`
+	// Size is the file's size in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the file's SHA-256 digest in hex, if it's stored with Git
+	// LFS.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// treeEntry is an entry in a repository tree listing.
+type treeEntry struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	LFS  *struct {
+		OID  string `json:"oid"`
+		Size int64  `json:"size"`
+	} `json:"lfs,omitempty"`
+}
+
+// Client lists and downloads files from Hub repositories.
+type Client struct {
+	endpoint  string
+	transport http.RoundTripper
+	userAgent string
+	token     string
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithEndpoint sets the Hub endpoint.
+func WithEndpoint(endpoint string) ClientOption {
+	return func(c *Client) {
+		if endpoint != "" {
+			c.endpoint = strings.TrimSuffix(endpoint, "/")
+		}
+	}
+}
+
+// WithTransport sets the HTTP transport.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		if transport != nil {
+			c.transport = transport
+		}
+	}
+}
+
+// WithUserAgent sets the User-Agent header.
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) {
+		if userAgent != "" {
+			c.userAgent = userAgent
+		}
+	}
+}
+
+// WithToken sets the access token used to authenticate with the Hub.
+func WithToken(token string) ClientOption {
+	return func(c *Client) {
+		if token != "" {
+			c.token = token
+		}
+	}
+}
+
+// NewClient creates a new Client. The endpoint defaults to HF_ENDPOINT if it's
+// set, and to DefaultEndpoint otherwise.
+func NewClient(opts ...ClientOption) *Client {
+	client := &Client{
+		endpoint:  DefaultEndpoint,
+		transport: http.DefaultTransport,
+		userAgent: registry.DefaultUserAgent,
+	}
+	WithEndpoint(os.Getenv("HF_ENDPOINT"))(client)
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
+}
+
+// FromClient creates a new Client by copying an existing client's
+// configuration and applying optional modifications.
+func FromClient(base *Client, opts ...ClientOption) *Client {
+	client := *base
+	for _, opt := range opts {
+		opt(&client)
+	}
+	return &client
+}
+
+// do sends a GET request for the specified URL, with additional headers, and
+// maps authentication and lookup failures to registry errors.
+func (c *Client) do(ctx context.Context, rawURL string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := (&http.Client{Transport: c.transport}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", registry.ErrUnauthorized, resp.Status)
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", registry.ErrModelNotFound, resp.Status)
+	case resp.StatusCode >= http.StatusBadRequest:
+		resp.Body.Close()
+		return nil, fmt.Errorf("request failed: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// ListFiles lists the files in a repository.
+func (c *Client) ListFiles(ctx context.Context, repository string) ([]File, error) {
+	next := fmt.Sprintf("%s/api/models/%s/tree/%s?recursive=true", c.endpoint, repository, revision)
+	var files []File
+	for next != "" {
+		resp, err := c.do(ctx, next, nil)
+		if err != nil {
+			return nil, fmt.Errorf("listing files of %s: %w", repository, err)
+		}
+		var entries []treeEntry
+		err = json.NewDecoder(resp.Body).Decode(&entries)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding file listing of %s: %w", repository, err)
+		}
+		for _, entry := range entries {
+			if entry.Type != "file" {
+				continue
+			}
+			file := File{Path: entry.Path, Size: entry.Size}
+			if entry.LFS != nil {
+				file.SHA256, file.Size = entry.LFS.OID, entry.LFS.Size
+			}
+			files = append(files, file)
+		}
+		next = nextPage(resp.Header.Get("Link"))
+	}
+	return files, nil
+}
+
+// nextPage returns the URL of the next page of a paginated listing, as
+// specified in its Link header, or an empty string if it's the last page.
+func nextPage(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+		if ok && strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
+
+// Download downloads a file from a repository to dest. Partial downloads are
+// kept next to dest and resumed by later downloads. Files stored with Git LFS
+// are verified against their digest. If dest already exists with the file's
+// size, it isn't downloaded again. onProgress, if not nil, is called with the
+// number of bytes downloaded so far.
+func (c *Client) Download(ctx context.Context, repository string, file File, dest string, onProgress func(int64)) error {
+	if info, err := os.Stat(dest); err == nil && info.Size() == file.Size {
+		return nil
+	}
+
+	incomplete := dest + incompleteSuffix
+	f, err := os.OpenFile(incomplete, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", incomplete, err)
+	}
+	defer f.Close()
+
+	// Hash what was downloaded previously, so that the whole file is verified.
+	hash := sha256.New()
+	offset, err := io.Copy(hash, f)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", incomplete, err)
+	}
+	if offset > file.Size {
+		if err := f.Truncate(0); err != nil {
+			return fmt.Errorf("truncating %s: %w", incomplete, err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("seeking %s: %w", incomplete, err)
+		}
+		hash.Reset()
+		offset = 0
+	}
+
+	if offset < file.Size {
+		segments := strings.Split(file.Path, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		header := http.Header{}
+		if offset > 0 {
+			header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		resp, err := c.do(ctx, fmt.Sprintf("%s/%s/resolve/%s/%s", c.endpoint, repository, revision, strings.Join(segments, "/")), header)
+		if err != nil {
+			return fmt.Errorf("downloading %s: %w", file.Path, err)
+		}
+		defer resp.Body.Close()
+		if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+			// The server ignored the range, so skip what was downloaded.
+			if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+				return fmt.Errorf("downloading %s: %w", file.Path, err)
+			}
+		}
+		w := io.MultiWriter(f, hash)
+		if onProgress != nil {
+			onProgress(offset)
+			w = io.MultiWriter(w, &progressWriter{downloaded: offset, onProgress: onProgress})
+		}
+		n, err := io.Copy(w, resp.Body)
+		if err != nil {
+			return fmt.Errorf("downloading %s: %w", file.Path, err)
+		}
+		offset += n
+	}
+
+	if offset != file.Size {
+		return fmt.Errorf("downloading %s: expected %d bytes, got %d", file.Path, file.Size, offset)
+	}
+	if file.SHA256 != "" {
+		if digest := hex.EncodeToString(hash.Sum(nil)); digest != file.SHA256 {
+			// Start over next time, since the partial download is corrupt.
+			f.Close()
+			if err := os.Remove(incomplete); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("removing %s: %w", incomplete, err)
+			}
+			return fmt.Errorf("downloading %s: digest mismatch: expected sha256:%s, got sha256:%s", file.Path, file.SHA256, digest)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", incomplete, err)
+	}
+	if err := os.Rename(incomplete, dest); err != nil {
+		return fmt.Errorf("renaming %s: %w", incomplete, err)
+	}
+	return nil
+}
+
+// progressWriter reports the number of bytes written to it.
+type progressWriter struct {
+	downloaded int64
+	onProgress func(int64)
+}
+
+// Write implements io.Writer.Write.
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.downloaded += int64(len(p))
+	w.onProgress(w.downloaded)
+	return len(p), nil
+}
@@ -0,0 +1,130 @@
+package huggingface
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/distribution/registry"
+)
+
+// newTestHub creates a fake Hub serving a repository with the specified files,
+// which requires the specified token. Its tree listing is split into pages of
+// one entry.
+func newTestHub(t *testing.T, repository, token string, files map[string][]byte) *httptest.Server {
+	t.Helper()
+	var paths []string
+	for path := range files {
+		paths = append(paths, path)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		treePrefix := "/api/models/" + repository + "/tree/main"
+		resolvePrefix := "/" + repository + "/resolve/main/"
+		switch {
+		case r.URL.Path == treePrefix:
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if page+1 < len(paths) {
+				w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?recursive=true&page=%d>; rel="next"`, r.Host, treePrefix, page+1))
+			}
+			content := files[paths[page]]
+			digest := sha256.Sum256(content)
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"type": "directory", "path": "docs"},
+				{"type": "file", "path": paths[page], "size": len(content), "lfs": map[string]any{"oid": hex.EncodeToString(digest[:]), "size": len(content)}},
+			})
+		case strings.HasPrefix(r.URL.Path, resolvePrefix):
+			content, ok := files[strings.TrimPrefix(r.URL.Path, resolvePrefix)]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestListFiles(t *testing.T) {
+	files := map[string][]byte{"a.gguf": []byte("a"), "b/c.gguf": []byte("bc"), "README.md": []byte("readme")}
+	server := newTestHub(t, "org/repo", "token", files)
+
+	client := NewClient(WithEndpoint(server.URL), WithToken("token"))
+	listed, err := client.ListFiles(context.Background(), "org/repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(listed) != len(files) {
+		t.Fatalf("expected %d files, got %+v", len(files), listed)
+	}
+	for _, file := range listed {
+		digest := sha256.Sum256(files[file.Path])
+		if file.Size != int64(len(files[file.Path])) || file.SHA256 != hex.EncodeToString(digest[:]) {
+			t.Errorf("unexpected file %+v", file)
+		}
+	}
+
+	if _, err := NewClient(WithEndpoint(server.URL)).ListFiles(context.Background(), "org/repo"); !errors.Is(err, registry.ErrUnauthorized) {
+		t.Errorf("expected unauthorized error, got %v", err)
+	}
+	if _, err := client.ListFiles(context.Background(), "org/missing"); !errors.Is(err, registry.ErrModelNotFound) {
+		t.Errorf("expected model not found error, got %v", err)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	digest := sha256.Sum256(content)
+	server := newTestHub(t, "org/repo", "token", map[string][]byte{"dir/model file.gguf": content})
+	client := NewClient(WithEndpoint(server.URL), WithToken("token"))
+	file := File{Path: "dir/model file.gguf", Size: int64(len(content)), SHA256: hex.EncodeToString(digest[:])}
+
+	dest := filepath.Join(t.TempDir(), "model.gguf")
+	// Resume a partial download.
+	if err := os.WriteFile(dest+incompleteSuffix, content[:4000], 0o644); err != nil {
+		t.Fatalf("failed to write partial download: %v", err)
+	}
+	var reported int64
+	if err := client.Download(context.Background(), "org/repo", file, dest, func(n int64) { reported = n }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, err := os.ReadFile(dest); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("unexpected download content (error %v)", err)
+	}
+	if reported != file.Size {
+		t.Errorf("expected %d bytes reported, got %d", file.Size, reported)
+	}
+	if _, err := os.Stat(dest + incompleteSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected partial download to be removed, got %v", err)
+	}
+
+	// Corrupt partial downloads are discarded.
+	corrupt := filepath.Join(t.TempDir(), "model.gguf")
+	if err := os.WriteFile(corrupt+incompleteSuffix, bytes.Repeat([]byte("x"), 4000), 0o644); err != nil {
+		t.Fatalf("failed to write partial download: %v", err)
+	}
+	if err := client.Download(context.Background(), "org/repo", file, corrupt, nil); err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Fatalf("expected digest mismatch, got %v", err)
+	}
+	if err := client.Download(context.Background(), "org/repo", file, corrupt, nil); err != nil {
+		t.Fatalf("unexpected error after discarding corrupt download: %v", err)
+	}
+}
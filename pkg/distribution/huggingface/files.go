@@ -0,0 +1,202 @@
+package huggingface
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/distribution/packaging"
+	"github.com/docker/model-runner/pkg/distribution/registry"
+)
+
+const (
+	// DefaultQuantization is the quantization selected from GGUF repositories
+	// when the reference doesn't specify one.
+	DefaultQuantization = "Q4_K_M"
+	// defaultTag is the tag of references that don't specify a quantization.
+	defaultTag = "latest"
+)
+
+// hosts are the host names of references to Hugging Face Hub repositories.
+var hosts = []string{"hf.co", "huggingface.co"}
+
+// repositoryPattern matches Hub repository IDs, such as "org/repo".
+var repositoryPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*/[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// shardPattern matches the suffix of GGUF shards, such as
+// "-00001-of-00003.gguf".
+var shardPattern = regexp.MustCompile(`-\d{5}-of-\d{5}\.gguf$`)
+
+// Reference is a reference to a model in a Hub repository, such as
+// "hf.co/org/repo:Q4_K_M".
+type Reference struct {
+	// Repository is the repository ID, such as "org/repo".
+	Repository string
+	// Quantization is the requested GGUF quantization, or empty for the
+	// default one.
+	Quantization string
+}
+
+// IsReference returns true if ref refers to a Hub repository.
+func IsReference(ref string) bool {
+	host, _, ok := strings.Cut(ref, "/")
+	return ok && slices.Contains(hosts, strings.ToLower(host))
+}
+
+// ParseReference parses a reference to a Hub repository. The reference's tag
+// selects a GGUF quantization, with "latest" selecting the default one.
+func ParseReference(ref string) (Reference, error) {
+	if !IsReference(ref) {
+		return Reference{}, registry.NewReferenceError(ref, fmt.Errorf("not a Hugging Face reference"))
+	}
+	if strings.Contains(ref, "@") {
+		return Reference{}, registry.NewReferenceError(ref, fmt.Errorf("digests aren't supported for Hugging Face references"))
+	}
+	_, repository, _ := strings.Cut(ref, "/")
+	var tag string
+	if i := strings.LastIndex(repository, ":"); i >= 0 {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	if !repositoryPattern.MatchString(repository) {
+		return Reference{}, registry.NewReferenceError(ref, fmt.Errorf("invalid repository %q", repository))
+	}
+	if strings.EqualFold(tag, defaultTag) {
+		tag = ""
+	}
+	return Reference{Repository: repository, Quantization: tag}, nil
+}
+
+// Selection is the set of files in a repository that make up a model.
+type Selection struct {
+	// Weights are the GGUF or safetensors weight files. GGUF shards are
+	// ordered, starting with the first one.
+	Weights []File
+	// Projector is the multimodal projector of GGUF models, if any.
+	Projector *File
+	// Config are the configuration files of safetensors models.
+	Config []File
+}
+
+// GGUF returns true if the selected weights are GGUF files.
+func (s Selection) GGUF() bool {
+	return len(s.Weights) > 0 && isGGUF(s.Weights[0].Path)
+}
+
+// Files returns all selected files.
+func (s Selection) Files() []File {
+	files := slices.Clone(s.Weights)
+	if s.Projector != nil {
+		files = append(files, *s.Projector)
+	}
+	return append(files, s.Config...)
+}
+
+// SelectFiles selects the files of a repository that make up a model. GGUF
+// weights are preferred, in which case the weights with the requested
+// quantization are selected, including all of their shards, along with a
+// multimodal projector if the repository has one. Otherwise, the safetensors
+// weights and configuration files at the root of the repository are selected.
+// An empty quantization selects DefaultQuantization if it's available, or the
+// first weights in the repository otherwise.
+func SelectFiles(files []File, quantization string) (Selection, error) {
+	files = slices.Clone(files)
+	slices.SortFunc(files, func(a, b File) int { return strings.Compare(a.Path, b.Path) })
+
+	var weights, projectors []File
+	for _, file := range files {
+		if !isGGUF(file.Path) {
+			continue
+		}
+		if strings.Contains(strings.ToLower(path.Base(file.Path)), "mmproj") {
+			projectors = append(projectors, file)
+		} else {
+			weights = append(weights, file)
+		}
+	}
+	if len(weights) == 0 {
+		if quantization != "" {
+			return Selection{}, fmt.Errorf("%w: no GGUF files for quantization %s", registry.ErrModelNotFound, quantization)
+		}
+		return selectSafetensors(files)
+	}
+
+	requested := quantization
+	if requested == "" {
+		requested = DefaultQuantization
+	}
+	matcher := quantizationMatcher(requested)
+	var selected *File
+	for _, file := range weights {
+		if matcher.MatchString(path.Base(file.Path)) {
+			selected = &file
+			break
+		}
+	}
+	if selected == nil {
+		if quantization != "" {
+			return Selection{}, fmt.Errorf("%w: no GGUF files for quantization %s", registry.ErrModelNotFound, quantization)
+		}
+		selected = &weights[0]
+	}
+
+	var selection Selection
+	if group := shardGroup(selected.Path); group != selected.Path {
+		for _, file := range weights {
+			if shardGroup(file.Path) == group {
+				selection.Weights = append(selection.Weights, file)
+			}
+		}
+	} else {
+		selection.Weights = []File{*selected}
+	}
+	for _, projector := range projectors {
+		if path.Dir(projector.Path) == path.Dir(selected.Path) {
+			selection.Projector = &projector
+			break
+		}
+	}
+	if selection.Projector == nil && len(projectors) > 0 {
+		selection.Projector = &projectors[0]
+	}
+	return selection, nil
+}
+
+// selectSafetensors selects the safetensors weights and configuration files
+// at the root of a repository.
+func selectSafetensors(files []File) (Selection, error) {
+	var selection Selection
+	for _, file := range files {
+		if strings.Contains(file.Path, "/") {
+			continue
+		}
+		if strings.HasSuffix(strings.ToLower(file.Path), ".safetensors") {
+			selection.Weights = append(selection.Weights, file)
+		} else if packaging.IsConfigFile(file.Path) {
+			selection.Config = append(selection.Config, file)
+		}
+	}
+	if len(selection.Weights) == 0 {
+		return Selection{}, fmt.Errorf("%w: no GGUF or safetensors files in repository", registry.ErrModelNotFound)
+	}
+	return selection, nil
+}
+
+// quantizationMatcher returns a pattern that matches file names containing
+// the specified quantization as a whole word, so that "Q4_K" doesn't match
+// "Q4_K_M".
+func quantizationMatcher(quantization string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(^|[^a-z0-9])` + regexp.QuoteMeta(quantization) + `([^a-z0-9_]|$)`)
+}
+
+// shardGroup returns the path shared by all shards of a GGUF file, which is
+// the file's path if it isn't sharded.
+func shardGroup(p string) string {
+	return shardPattern.ReplaceAllString(p, "")
+}
+
+// isGGUF returns true if the file at path p is a GGUF file.
+func isGGUF(p string) bool {
+	return strings.HasSuffix(strings.ToLower(p), ".gguf")
+}
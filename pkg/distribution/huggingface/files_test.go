@@ -0,0 +1,117 @@
+package huggingface
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/registry"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    Reference
+		wantErr bool
+	}{
+		{ref: "hf.co/org/repo", want: Reference{Repository: "org/repo"}},
+		{ref: "hf.co/org/repo:Q4_K_M", want: Reference{Repository: "org/repo", Quantization: "Q4_K_M"}},
+		{ref: "huggingface.co/org/repo:latest", want: Reference{Repository: "org/repo"}},
+		{ref: "huggingface.co/org/repo.v2:q8_0", want: Reference{Repository: "org/repo.v2", Quantization: "q8_0"}},
+		{ref: "ai/smollm2", wantErr: true},
+		{ref: "hf.co/repo", wantErr: true},
+		{ref: "hf.co/org/repo/extra", wantErr: true},
+		{ref: "hf.co/org/repo@sha256:abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ParseReference(tt.ref)
+			if tt.wantErr {
+				if !errors.Is(err, registry.ErrInvalidReference) {
+					t.Fatalf("expected invalid reference error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSelectFiles(t *testing.T) {
+	ggufRepo := []File{
+		{Path: "README.md"},
+		{Path: "model-Q4_K.gguf"},
+		{Path: "model-Q4_K_M.gguf"},
+		{Path: "model-Q8_0.gguf"},
+		{Path: "BF16/model-BF16-00002-of-00002.gguf"},
+		{Path: "BF16/model-BF16-00001-of-00002.gguf"},
+		{Path: "mmproj-model-f16.gguf"},
+	}
+	safetensorsRepo := []File{
+		{Path: ".gitattributes"},
+		{Path: "README.md"},
+		{Path: "config.json"},
+		{Path: "model-00001-of-00002.safetensors"},
+		{Path: "model-00002-of-00002.safetensors"},
+		{Path: "onnx/model.onnx"},
+		{Path: "tokenizer.model"},
+	}
+
+	tests := []struct {
+		name          string
+		files         []File
+		quantization  string
+		wantWeights   []string
+		wantProjector string
+		wantConfig    []string
+		wantErr       bool
+	}{
+		{name: "default quantization", files: ggufRepo, wantWeights: []string{"model-Q4_K_M.gguf"}, wantProjector: "mmproj-model-f16.gguf"},
+		{name: "quantization prefix", files: ggufRepo, quantization: "q4_k", wantWeights: []string{"model-Q4_K.gguf"}, wantProjector: "mmproj-model-f16.gguf"},
+		{name: "shards", files: ggufRepo, quantization: "BF16", wantWeights: []string{"BF16/model-BF16-00001-of-00002.gguf", "BF16/model-BF16-00002-of-00002.gguf"}, wantProjector: "mmproj-model-f16.gguf"},
+		{name: "missing quantization", files: ggufRepo, quantization: "Q2_K", wantErr: true},
+		{name: "default fallback", files: []File{{Path: "model-Q8_0.gguf"}, {Path: "model-F16.gguf"}}, wantWeights: []string{"model-F16.gguf"}},
+		{name: "safetensors", files: safetensorsRepo, wantWeights: []string{"model-00001-of-00002.safetensors", "model-00002-of-00002.safetensors"}, wantConfig: []string{"README.md", "config.json", "tokenizer.model"}},
+		{name: "safetensors quantization", files: safetensorsRepo, quantization: "Q4_K_M", wantErr: true},
+		{name: "no weights", files: []File{{Path: "README.md"}}, wantErr: true},
+	}
+	paths := func(files []File) []string {
+		var paths []string
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		return paths
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection, err := SelectFiles(tt.files, tt.quantization)
+			if tt.wantErr {
+				if !errors.Is(err, registry.ErrModelNotFound) {
+					t.Fatalf("expected model not found error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := paths(selection.Weights); !slices.Equal(got, tt.wantWeights) {
+				t.Errorf("expected weights %v, got %v", tt.wantWeights, got)
+			}
+			var projector string
+			if selection.Projector != nil {
+				projector = selection.Projector.Path
+			}
+			if projector != tt.wantProjector {
+				t.Errorf("expected projector %q, got %q", tt.wantProjector, projector)
+			}
+			if got := paths(selection.Config); !slices.Equal(got, tt.wantConfig) {
+				t.Errorf("expected config %v, got %v", tt.wantConfig, got)
+			}
+		})
+	}
+}
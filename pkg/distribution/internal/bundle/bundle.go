@@ -15,8 +15,13 @@ const (
 type Bundle struct {
 	dir              string
 	mmprojPath       string
+	adapterFile      string // path to LoRA adapter file, relative to the model subdirectory
 	ggufFile         string // path to GGUF file (first shard when model is split among files)
 	safetensorsFile  string // path to safetensors file (first shard when model is split among files)
+	onnxFile         string // path to ONNX model file, relative to the model subdirectory
+	whisperFile      string // path to whisper.cpp GGML model file, relative to the model subdirectory
+	diffusionFile    string // path to diffusion model checkpoint, relative to the model subdirectory
+	tensorrtDir      string // path to TensorRT-LLM engine directory, relative to the model subdirectory
 	runtimeConfig    types.Config
 	chatTemplatePath string
 }
@@ -43,6 +48,15 @@ func (b *Bundle) MMPROJPath() string {
 	return filepath.Join(b.dir, ModelSubdir, b.mmprojPath)
 }
 
+// AdapterPath returns the path to the LoRA adapter file of an adapter artifact
+// or "" if none is present.
+func (b *Bundle) AdapterPath() string {
+	if b.adapterFile == "" {
+		return ""
+	}
+	return filepath.Join(b.dir, ModelSubdir, b.adapterFile)
+}
+
 // ChatTemplatePath return the path to a Jinja chat template file or "" if none is present.
 func (b *Bundle) ChatTemplatePath() string {
 	if b.chatTemplatePath == "" {
@@ -59,6 +73,40 @@ func (b *Bundle) SafetensorsPath() string {
 	return filepath.Join(b.dir, ModelSubdir, b.safetensorsFile)
 }
 
+// ONNXPath returns the path to the model ONNX file or "" if none is present. The file's directory contains the
+// accompanying tokenizer and runtime configuration files.
+func (b *Bundle) ONNXPath() string {
+	if b.onnxFile == "" {
+		return ""
+	}
+	return filepath.Join(b.dir, ModelSubdir, b.onnxFile)
+}
+
+// WhisperPath returns the path to the whisper.cpp GGML model file or "" if none is present.
+func (b *Bundle) WhisperPath() string {
+	if b.whisperFile == "" {
+		return ""
+	}
+	return filepath.Join(b.dir, ModelSubdir, b.whisperFile)
+}
+
+// DiffusionPath returns the path to the diffusion model checkpoint or "" if none is present.
+func (b *Bundle) DiffusionPath() string {
+	if b.diffusionFile == "" {
+		return ""
+	}
+	return filepath.Join(b.dir, ModelSubdir, b.diffusionFile)
+}
+
+// TensorRTPath returns the path to the directory containing the TensorRT-LLM engines and their config.json or "" if
+// none is present.
+func (b *Bundle) TensorRTPath() string {
+	if b.tensorrtDir == "" {
+		return ""
+	}
+	return filepath.Join(b.dir, ModelSubdir, b.tensorrtDir)
+}
+
 // RuntimeConfig returns config that should be respected by the backend at runtime.
 func (b *Bundle) RuntimeConfig() types.Config {
 	return b.runtimeConfig
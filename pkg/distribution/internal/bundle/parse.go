@@ -31,30 +31,75 @@ func Parse(rootDir string) (*Bundle, error) {
 		return nil, err
 	}
 
-	// Ensure at least one model weight format is present
-	if ggufPath == "" && safetensorsPath == "" {
-		return nil, fmt.Errorf("no supported model weights found (neither GGUF nor safetensors)")
+	onnxPath, err := findONNXFile(modelDir)
+	if err != nil {
+		return nil, err
 	}
 
-	mmprojPath, err := findMultiModalProjectorFile(modelDir)
+	// Runtime config stays at bundle root
+	cfg, err := parseRuntimeConfig(rootDir)
 	if err != nil {
 		return nil, err
 	}
-	templatePath, err := findChatTemplateFile(modelDir)
+
+	// Whisper weights use a generic file extension, so only look for them in
+	// whisper models
+	var whisperPath string
+	if cfg.Format == types.FormatWhisper {
+		whisperPath, err = findWhisperFile(modelDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Diffusion checkpoints may use the same file extensions as language
+	// models, so only look for them in diffusion models
+	var diffusionPath string
+	if cfg.Format == types.FormatDiffusion {
+		diffusionPath, err = findDiffusionFile(modelDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// TensorRT-LLM engines are only used by TensorRT-LLM models
+	var tensorrtDir string
+	if cfg.Format == types.FormatTensorRT {
+		tensorrtDir, err = findTensorRTEngineDir(modelDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Adapters are applied to a base model instead of having weights
+	adapterPath, err := findAdapterFile(modelDir)
 	if err != nil {
 		return nil, err
 	}
 
-	// Runtime config stays at bundle root
-	cfg, err := parseRuntimeConfig(rootDir)
+	// Ensure at least one model weight format is present
+	if ggufPath == "" && safetensorsPath == "" && onnxPath == "" && whisperPath == "" && diffusionPath == "" && tensorrtDir == "" && adapterPath == "" {
+		return nil, fmt.Errorf("no supported model weights found (neither GGUF, safetensors, ONNX, whisper, diffusion, nor TensorRT)")
+	}
+
+	mmprojPath, err := findMultiModalProjectorFile(modelDir)
+	if err != nil {
+		return nil, err
+	}
+	templatePath, err := findChatTemplateFile(modelDir)
 	if err != nil {
 		return nil, err
 	}
 	return &Bundle{
 		dir:              rootDir,
 		mmprojPath:       mmprojPath,
+		adapterFile:      adapterPath,
 		ggufFile:         ggufPath,
 		safetensorsFile:  safetensorsPath,
+		onnxFile:         onnxPath,
+		whisperFile:      whisperPath,
+		diffusionFile:    diffusionPath,
+		tensorrtDir:      tensorrtDir,
 		runtimeConfig:    cfg,
 		chatTemplatePath: templatePath,
 	}, nil
@@ -97,6 +142,83 @@ func findSafetensorsFile(modelDir string) (string, error) {
 	return filepath.Base(safetensors[0]), nil
 }
 
+// findONNXFile returns the path of the ONNX model file relative to modelDir. ONNX models are unpacked from directory
+// archives, so the file may be at the root of modelDir or in one of its subdirectories. A file next to an ONNX
+// Runtime GenAI configuration is preferred, since exports may contain auxiliary graphs.
+func findONNXFile(modelDir string) (string, error) {
+	var candidates []string
+	for _, pattern := range []string{"[^.]*.onnx", filepath.Join("[^.]*", "[^.]*.onnx")} {
+		matches, err := filepath.Glob(filepath.Join(modelDir, pattern))
+		if err != nil {
+			return "", fmt.Errorf("find onnx files: %w", err)
+		}
+		candidates = append(candidates, matches...)
+	}
+	if len(candidates) == 0 {
+		// ONNX files are optional - GGUF and safetensors models won't have them
+		return "", nil
+	}
+	path := candidates[0]
+	for _, candidate := range candidates {
+		if _, err := os.Stat(filepath.Join(filepath.Dir(candidate), "genai_config.json")); err == nil {
+			path = candidate
+			break
+		}
+	}
+	return filepath.Rel(modelDir, path)
+}
+
+// findWhisperFile returns the path of the whisper.cpp GGML model file relative to modelDir. Like ONNX models, whisper
+// models are unpacked from directory archives, so the file may be at the root of modelDir or in one of its
+// subdirectories.
+func findWhisperFile(modelDir string) (string, error) {
+	var candidates []string
+	for _, pattern := range []string{"[^.]*.bin", filepath.Join("[^.]*", "[^.]*.bin")} {
+		matches, err := filepath.Glob(filepath.Join(modelDir, pattern))
+		if err != nil {
+			return "", fmt.Errorf("find whisper model files: %w", err)
+		}
+		candidates = append(candidates, matches...)
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	return filepath.Rel(modelDir, candidates[0])
+}
+
+// findDiffusionFile returns the path of the diffusion model checkpoint relative to modelDir. Diffusion models are
+// unpacked from directory archives, so the checkpoint may be at the root of modelDir or in one of its subdirectories.
+func findDiffusionFile(modelDir string) (string, error) {
+	for _, ext := range []string{".safetensors", ".gguf", ".ckpt"} {
+		for _, pattern := range []string{"[^.]*" + ext, filepath.Join("[^.]*", "[^.]*"+ext)} {
+			matches, err := filepath.Glob(filepath.Join(modelDir, pattern))
+			if err != nil {
+				return "", fmt.Errorf("find diffusion model files: %w", err)
+			}
+			if len(matches) > 0 {
+				return filepath.Rel(modelDir, matches[0])
+			}
+		}
+	}
+	return "", nil
+}
+
+// findTensorRTEngineDir returns the path of the directory containing the TensorRT-LLM engines relative to modelDir.
+// Engines are built per rank (rank0.engine, rank1.engine, ...) next to their config.json and are unpacked from
+// directory archives, so the directory may be modelDir itself or one of its subdirectories.
+func findTensorRTEngineDir(modelDir string) (string, error) {
+	for _, pattern := range []string{"[^.]*.engine", filepath.Join("[^.]*", "[^.]*.engine")} {
+		matches, err := filepath.Glob(filepath.Join(modelDir, pattern))
+		if err != nil {
+			return "", fmt.Errorf("find TensorRT engine files: %w", err)
+		}
+		if len(matches) > 0 {
+			return filepath.Rel(modelDir, filepath.Dir(matches[0]))
+		}
+	}
+	return "", nil
+}
+
 func findMultiModalProjectorFile(modelDir string) (string, error) {
 	mmprojPaths, err := filepath.Glob(filepath.Join(modelDir, "[^.]*.mmproj"))
 	if err != nil {
@@ -111,6 +233,20 @@ func findMultiModalProjectorFile(modelDir string) (string, error) {
 	return filepath.Base(mmprojPaths[0]), nil
 }
 
+func findAdapterFile(modelDir string) (string, error) {
+	adapterPaths, err := filepath.Glob(filepath.Join(modelDir, "[^.]*.lora"))
+	if err != nil {
+		return "", err
+	}
+	if len(adapterPaths) == 0 {
+		return "", nil
+	}
+	if len(adapterPaths) > 1 {
+		return "", fmt.Errorf("found multiple .lora files, but only 1 is supported")
+	}
+	return filepath.Base(adapterPaths[0]), nil
+}
+
 func findChatTemplateFile(modelDir string) (string, error) {
 	templatePaths, err := filepath.Glob(filepath.Join(modelDir, "[^.]*.jinja"))
 	if err != nil {
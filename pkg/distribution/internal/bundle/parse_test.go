@@ -41,7 +41,7 @@ func TestParse_NoModelWeights(t *testing.T) {
 		t.Fatal("Expected error when parsing bundle without model weights, got nil")
 	}
 
-	expectedErrMsg := "no supported model weights found (neither GGUF nor safetensors)"
+	expectedErrMsg := "no supported model weights found (neither GGUF, safetensors, ONNX, whisper, diffusion, nor TensorRT)"
 	if !strings.Contains(err.Error(), expectedErrMsg) {
 		t.Errorf("Expected error message to contain %q, got: %v", expectedErrMsg, err)
 	}
@@ -189,3 +189,178 @@ func TestParse_WithBothFormats(t *testing.T) {
 		t.Errorf("Expected safetensorsFile to be 'model.safetensors', got: %s", bundle.safetensorsFile)
 	}
 }
+
+func TestParse_WithONNX(t *testing.T) {
+	// Create a temporary directory for the test bundle
+	tempDir := t.TempDir()
+
+	// Create an ONNX Runtime GenAI export next to an auxiliary graph, as
+	// unpacked from a directory archive
+	modelDir := filepath.Join(tempDir, ModelSubdir)
+	exportDir := filepath.Join(modelDir, "cpu-int4")
+	if err := os.MkdirAll(exportDir, 0755); err != nil {
+		t.Fatalf("Failed to create model directory: %v", err)
+	}
+	for _, path := range []string{
+		filepath.Join(modelDir, "aux.onnx"),
+		filepath.Join(exportDir, "model.onnx"),
+		filepath.Join(exportDir, "genai_config.json"),
+	} {
+		if err := os.WriteFile(path, []byte("dummy content"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", path, err)
+		}
+	}
+
+	// Create a valid config.json at bundle root
+	cfg := types.Config{
+		Format: types.FormatONNX,
+	}
+	configPath := filepath.Join(tempDir, "config.json")
+	f, err := os.Create(configPath)
+	if err != nil {
+		t.Fatalf("Failed to create config.json: %v", err)
+	}
+	if err := json.NewEncoder(f).Encode(cfg); err != nil {
+		f.Close()
+		t.Fatalf("Failed to encode config: %v", err)
+	}
+	f.Close()
+
+	// Parse the bundle - should succeed
+	bundle, err := Parse(tempDir)
+	if err != nil {
+		t.Fatalf("Expected successful parse with ONNX file, got error: %v", err)
+	}
+
+	expected := filepath.Join(exportDir, "model.onnx")
+	if bundle.ONNXPath() != expected {
+		t.Errorf("Expected ONNXPath to be %q, got: %s", expected, bundle.ONNXPath())
+	}
+
+	if bundle.ggufFile != "" || bundle.safetensorsFile != "" {
+		t.Errorf("Expected no GGUF or safetensors files, got: %q, %q", bundle.ggufFile, bundle.safetensorsFile)
+	}
+}
+
+func TestParse_WithWhisper(t *testing.T) {
+	// Create a temporary directory for the test bundle
+	tempDir := t.TempDir()
+
+	// Create a whisper.cpp model, as unpacked from a directory archive
+	modelDir := filepath.Join(tempDir, ModelSubdir)
+	if err := os.MkdirAll(modelDir, 0755); err != nil {
+		t.Fatalf("Failed to create model directory: %v", err)
+	}
+	whisperPath := filepath.Join(modelDir, "ggml-base.en.bin")
+	if err := os.WriteFile(whisperPath, []byte("dummy content"), 0644); err != nil {
+		t.Fatalf("Failed to create whisper file: %v", err)
+	}
+
+	// Create a valid config.json at bundle root
+	cfg := types.Config{
+		Format: types.FormatWhisper,
+	}
+	configPath := filepath.Join(tempDir, "config.json")
+	f, err := os.Create(configPath)
+	if err != nil {
+		t.Fatalf("Failed to create config.json: %v", err)
+	}
+	if err := json.NewEncoder(f).Encode(cfg); err != nil {
+		f.Close()
+		t.Fatalf("Failed to encode config: %v", err)
+	}
+	f.Close()
+
+	// Parse the bundle - should succeed
+	bundle, err := Parse(tempDir)
+	if err != nil {
+		t.Fatalf("Expected successful parse with whisper file, got error: %v", err)
+	}
+
+	if bundle.WhisperPath() != whisperPath {
+		t.Errorf("Expected WhisperPath to be %q, got: %s", whisperPath, bundle.WhisperPath())
+	}
+}
+
+func TestParse_WithDiffusion(t *testing.T) {
+	// Create a temporary directory for the test bundle
+	tempDir := t.TempDir()
+
+	// Create a diffusion checkpoint, as unpacked from a directory archive
+	modelDir := filepath.Join(tempDir, ModelSubdir)
+	checkpointDir := filepath.Join(modelDir, "sd-v1-5")
+	if err := os.MkdirAll(checkpointDir, 0755); err != nil {
+		t.Fatalf("Failed to create model directory: %v", err)
+	}
+	checkpointPath := filepath.Join(checkpointDir, "sd-v1-5.safetensors")
+	if err := os.WriteFile(checkpointPath, []byte("dummy content"), 0644); err != nil {
+		t.Fatalf("Failed to create checkpoint file: %v", err)
+	}
+
+	// Create a valid config.json at bundle root
+	cfg := types.Config{
+		Format: types.FormatDiffusion,
+	}
+	configPath := filepath.Join(tempDir, "config.json")
+	f, err := os.Create(configPath)
+	if err != nil {
+		t.Fatalf("Failed to create config.json: %v", err)
+	}
+	if err := json.NewEncoder(f).Encode(cfg); err != nil {
+		f.Close()
+		t.Fatalf("Failed to encode config: %v", err)
+	}
+	f.Close()
+
+	// Parse the bundle - should succeed
+	bundle, err := Parse(tempDir)
+	if err != nil {
+		t.Fatalf("Expected successful parse with diffusion checkpoint, got error: %v", err)
+	}
+
+	if bundle.DiffusionPath() != checkpointPath {
+		t.Errorf("Expected DiffusionPath to be %q, got: %s", checkpointPath, bundle.DiffusionPath())
+	}
+}
+
+func TestParse_WithTensorRT(t *testing.T) {
+	// Create a temporary directory for the test bundle
+	tempDir := t.TempDir()
+
+	// Create TensorRT-LLM engines, as unpacked from a directory archive
+	modelDir := filepath.Join(tempDir, ModelSubdir)
+	engineDir := filepath.Join(modelDir, "engines")
+	if err := os.MkdirAll(engineDir, 0755); err != nil {
+		t.Fatalf("Failed to create engine directory: %v", err)
+	}
+	for _, name := range []string{"config.json", "rank0.engine", "rank1.engine"} {
+		if err := os.WriteFile(filepath.Join(engineDir, name), []byte("dummy content"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	// Create a valid config.json at bundle root
+	cfg := types.Config{
+		Format: types.FormatTensorRT,
+	}
+	configPath := filepath.Join(tempDir, "config.json")
+	f, err := os.Create(configPath)
+	if err != nil {
+		t.Fatalf("Failed to create config.json: %v", err)
+	}
+	if err := json.NewEncoder(f).Encode(cfg); err != nil {
+		f.Close()
+		t.Fatalf("Failed to encode config: %v", err)
+	}
+	f.Close()
+
+	// Parse the bundle - should succeed
+	bundle, err := Parse(tempDir)
+	if err != nil {
+		t.Fatalf("Expected successful parse with TensorRT engines, got error: %v", err)
+	}
+
+	if bundle.TensorRTPath() != engineDir {
+		t.Errorf("Expected TensorRTPath to be %q, got: %s", engineDir, bundle.TensorRTPath())
+	}
+}
@@ -14,7 +14,9 @@ import (
 )
 
 // Unpack creates and return a Bundle by unpacking files and config from model into dir.
-func Unpack(dir string, model types.Model) (*Bundle, error) {
+// The files extracted from archives are written through wrap, if not nil, such
+// as to guard against running out of disk space.
+func Unpack(dir string, model types.Model, wrap func(io.Writer) io.Writer) (*Bundle, error) {
 	bundle := &Bundle{
 		dir: dir,
 	}
@@ -25,6 +27,18 @@ func Unpack(dir string, model types.Model) (*Bundle, error) {
 		return nil, fmt.Errorf("create model directory: %w", err)
 	}
 
+	// Adapter artifacts have no weights of their own, only the adapter that's
+	// applied to a base model.
+	if hasLayerWithMediaType(model, types.MediaTypeLoRAAdapter) {
+		if err := unpackAdapter(bundle, model); err != nil {
+			return nil, fmt.Errorf("add adapter file to runtime bundle: %w", err)
+		}
+		if err := unpackRuntimeConfig(bundle, model); err != nil {
+			return nil, fmt.Errorf("add config.json to runtime bundle: %w", err)
+		}
+		return bundle, nil
+	}
+
 	// Inspect layers to determine what to unpack
 	modelFormat := detectModelFormat(model)
 
@@ -38,8 +52,11 @@ func Unpack(dir string, model types.Model) (*Bundle, error) {
 		if err := unpackSafetensors(bundle, model); err != nil {
 			return nil, fmt.Errorf("unpack safetensors files: %w", err)
 		}
+	case types.FormatONNX, types.FormatWhisper, types.FormatDiffusion, types.FormatTensorRT:
+		// ONNX, whisper, diffusion, and TensorRT-LLM models are unpacked with
+		// the directory archives below.
 	default:
-		return nil, fmt.Errorf("no supported model weights found (neither GGUF nor safetensors)")
+		return nil, fmt.Errorf("no supported model weights found (neither GGUF, safetensors, ONNX, whisper, diffusion, nor TensorRT)")
 	}
 
 	// Unpack optional components based on their presence
@@ -56,16 +73,60 @@ func Unpack(dir string, model types.Model) (*Bundle, error) {
 	}
 
 	if hasLayerWithMediaType(model, types.MediaTypeVLLMConfigArchive) {
-		if err := unpackConfigArchive(bundle, model); err != nil {
+		if err := unpackConfigArchive(bundle, model, wrap); err != nil {
 			return nil, fmt.Errorf("add config archive to runtime bundle: %w", err)
 		}
 	}
 
 	// Unpack directory tar archives (can be multiple)
-	if err := unpackDirTarArchives(bundle, model); err != nil {
+	if err := unpackDirTarArchives(bundle, model, wrap); err != nil {
 		return nil, fmt.Errorf("unpack directory tar archives: %w", err)
 	}
 
+	if modelFormat == types.FormatONNX {
+		onnxFile, err := findONNXFile(modelDir)
+		if err != nil {
+			return nil, fmt.Errorf("find ONNX model: %w", err)
+		}
+		if onnxFile == "" {
+			return nil, fmt.Errorf("no ONNX model file found in directory archives")
+		}
+		bundle.onnxFile = onnxFile
+	}
+
+	if modelFormat == types.FormatWhisper {
+		whisperFile, err := findWhisperFile(modelDir)
+		if err != nil {
+			return nil, fmt.Errorf("find whisper model: %w", err)
+		}
+		if whisperFile == "" {
+			return nil, fmt.Errorf("no whisper model file found in directory archives")
+		}
+		bundle.whisperFile = whisperFile
+	}
+
+	if modelFormat == types.FormatDiffusion {
+		diffusionFile, err := findDiffusionFile(modelDir)
+		if err != nil {
+			return nil, fmt.Errorf("find diffusion model: %w", err)
+		}
+		if diffusionFile == "" {
+			return nil, fmt.Errorf("no diffusion model checkpoint found in directory archives")
+		}
+		bundle.diffusionFile = diffusionFile
+	}
+
+	if modelFormat == types.FormatTensorRT {
+		tensorrtDir, err := findTensorRTEngineDir(modelDir)
+		if err != nil {
+			return nil, fmt.Errorf("find TensorRT engines: %w", err)
+		}
+		if tensorrtDir == "" {
+			return nil, fmt.Errorf("no TensorRT engine files found in directory archives")
+		}
+		bundle.tensorrtDir = tensorrtDir
+	}
+
 	// Always create the runtime config
 	if err := unpackRuntimeConfig(bundle, model); err != nil {
 		return nil, fmt.Errorf("add config.json to runtime bundle: %w", err)
@@ -88,6 +149,15 @@ func detectModelFormat(model types.Model) types.Format {
 		return types.FormatSafetensors
 	}
 
+	// ONNX, whisper, diffusion, and TensorRT-LLM models are identified by
+	// their config, since their files are packaged as directory archives
+	if cfg, err := model.Config(); err == nil {
+		switch cfg.Format {
+		case types.FormatONNX, types.FormatWhisper, types.FormatDiffusion, types.FormatTensorRT:
+			return cfg.Format
+		}
+	}
+
 	return ""
 }
 
@@ -99,6 +169,9 @@ func hasLayerWithMediaType(model types.Model, targetMediaType ggcrtypes.MediaTyp
 	case types.MediaTypeMultimodalProjector:
 		path, err := model.MMPROJPath()
 		return err == nil && path != ""
+	case types.MediaTypeLoRAAdapter:
+		path, err := model.AdapterPath()
+		return err == nil && path != ""
 	case types.MediaTypeChatTemplate:
 		path, err := model.ChatTemplatePath()
 		return err == nil && path != ""
@@ -171,6 +244,21 @@ func unpackMultiModalProjector(bundle *Bundle, mdl types.Model) error {
 	return nil
 }
 
+func unpackAdapter(bundle *Bundle, mdl types.Model) error {
+	path, err := mdl.AdapterPath()
+	if err != nil {
+		return err
+	}
+
+	modelDir := filepath.Join(bundle.dir, ModelSubdir)
+
+	if err = unpackFile(filepath.Join(modelDir, "model.lora"), path); err != nil {
+		return err
+	}
+	bundle.adapterFile = "model.lora"
+	return nil
+}
+
 func unpackTemplate(bundle *Bundle, mdl types.Model) error {
 	path, err := mdl.ChatTemplatePath()
 	if err != nil {
@@ -220,7 +308,7 @@ func unpackSafetensors(bundle *Bundle, mdl types.Model) error {
 	return nil
 }
 
-func unpackConfigArchive(bundle *Bundle, mdl types.Model) error {
+func unpackConfigArchive(bundle *Bundle, mdl types.Model, wrap func(io.Writer) io.Writer) error {
 	archivePath, err := mdl.ConfigArchivePath()
 	if err != nil {
 		return fmt.Errorf("get config archive path: %w", err)
@@ -230,14 +318,14 @@ func unpackConfigArchive(bundle *Bundle, mdl types.Model) error {
 
 	// Extract the tar archive into the model subdirectory
 	// This prevents config.json conflicts with the runtime config at bundle root
-	if err := extractTarArchive(archivePath, modelDir); err != nil {
+	if err := extractTarArchive(archivePath, modelDir, wrap); err != nil {
 		return fmt.Errorf("extract config archive: %w", err)
 	}
 
 	return nil
 }
 
-func unpackDirTarArchives(bundle *Bundle, mdl types.Model) error {
+func unpackDirTarArchives(bundle *Bundle, mdl types.Model, wrap func(io.Writer) io.Writer) error {
 	// Cast to ModelArtifact to access Layers() method
 	artifact, ok := mdl.(types.ModelArtifact)
 	if !ok {
@@ -273,7 +361,7 @@ func unpackDirTarArchives(bundle *Bundle, mdl types.Model) error {
 		}
 
 		// Stream directly to tar extraction - no temp file needed
-		if err := extractTarArchiveFromReader(uncompressed, modelDir); err != nil {
+		if err := extractTarArchiveFromReader(uncompressed, modelDir, wrap); err != nil {
 			uncompressed.Close()
 			return fmt.Errorf("extract directory tar archive: %w", err)
 		}
@@ -317,7 +405,7 @@ func validatePathWithinDirectory(baseDir, targetPath string) error {
 	return nil
 }
 
-func extractTarArchiveFromReader(r io.Reader, destDir string) error {
+func extractTarArchiveFromReader(r io.Reader, destDir string, wrap func(io.Writer) io.Writer) error {
 	// Get absolute path of destination directory for security checks
 	absDestDir, err := filepath.Abs(destDir)
 	if err != nil {
@@ -360,7 +448,7 @@ func extractTarArchiveFromReader(r io.Reader, destDir string) error {
 
 		case tar.TypeReg:
 			// Extract regular file
-			if err := extractFile(tr, absTarget, os.FileMode(header.Mode)); err != nil {
+			if err := extractFile(tr, absTarget, os.FileMode(header.Mode), wrap); err != nil {
 				return fmt.Errorf("extract file %s: %w", absTarget, err)
 			}
 
@@ -379,7 +467,7 @@ func extractTarArchiveFromReader(r io.Reader, destDir string) error {
 	return nil
 }
 
-func extractTarArchive(archivePath, destDir string) error {
+func extractTarArchive(archivePath, destDir string, wrap func(io.Writer) io.Writer) error {
 	// Open the tar file
 	file, err := os.Open(archivePath)
 	if err != nil {
@@ -388,11 +476,12 @@ func extractTarArchive(archivePath, destDir string) error {
 	defer file.Close()
 
 	// Delegate to the streaming version
-	return extractTarArchiveFromReader(file, destDir)
+	return extractTarArchiveFromReader(file, destDir, wrap)
 }
 
-// extractFile extracts a single file from the tar reader
-func extractFile(tr io.Reader, target string, mode os.FileMode) error {
+// extractFile extracts a single file from the tar reader, writing it through
+// wrap if not nil
+func extractFile(tr io.Reader, target string, mode os.FileMode, wrap func(io.Writer) io.Writer) error {
 	// Ensure parent directory exists
 	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
 		return fmt.Errorf("create parent directory: %w", err)
@@ -406,7 +495,11 @@ func extractFile(tr io.Reader, target string, mode os.FileMode) error {
 	defer file.Close()
 
 	// Copy contents
-	if _, err := io.Copy(file, tr); err != nil {
+	var w io.Writer = file
+	if wrap != nil {
+		w = wrap(file)
+	}
+	if _, err := io.Copy(w, tr); err != nil {
 		return fmt.Errorf("write file contents: %w", err)
 	}
 
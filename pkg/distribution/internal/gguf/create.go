@@ -7,6 +7,7 @@ import (
 	"time"
 
 	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+	ggcrtypes "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/types"
 	parser "github.com/gpustack/gguf-parser-go"
 
 	"github.com/docker/model-runner/pkg/distribution/internal/partial"
@@ -18,10 +19,21 @@ func NewModel(path string) (*Model, error) {
 	if len(shards) == 0 {
 		shards = []string{path} // single file
 	}
+	return newModel(path, shards, types.MediaTypeGGUF)
+}
+
+// NewAdapter creates an adapter artifact from a LoRA adapter in GGUF format.
+func NewAdapter(path string) (*Model, error) {
+	return newModel(path, []string{path}, types.MediaTypeLoRAAdapter)
+}
+
+// newModel creates a model artifact with a layer of the specified media type
+// for each GGUF file, whose config is read from the file at path.
+func newModel(path string, shards []string, mediaType ggcrtypes.MediaType) (*Model, error) {
 	layers := make([]v1.Layer, len(shards))
 	diffIDs := make([]v1.Hash, len(shards))
 	for i, shard := range shards {
-		layer, err := partial.NewLayer(shard, types.MediaTypeGGUF)
+		layer, err := partial.NewLayer(shard, mediaType)
 		if err != nil {
 			return nil, fmt.Errorf("create gguf layer: %w", err)
 		}
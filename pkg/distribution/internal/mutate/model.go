@@ -17,10 +17,11 @@ type model struct {
 	appended        []v1.Layer
 	configMediaType ggcr.MediaType
 	contextSize     *uint64
+	derivedFrom     string
 }
 
 func (m *model) Descriptor() (types.Descriptor, error) {
-	return partial.Descriptor(m.base)
+	return partial.Descriptor(m)
 }
 
 func (m *model) ID() (string, error) {
@@ -127,6 +128,9 @@ func (m *model) RawConfigFile() ([]byte, error) {
 	if m.contextSize != nil {
 		cf.Config.ContextSize = m.contextSize
 	}
+	if m.derivedFrom != "" {
+		cf.Descriptor.DerivedFrom = m.derivedFrom
+	}
 	raw, err := json.Marshal(cf)
 	if err != nil {
 		return nil, err
@@ -27,3 +27,12 @@ func ContextSize(mdl types.ModelArtifact, cs uint64) types.ModelArtifact {
 		contextSize: &cs,
 	}
 }
+
+// DerivedFrom records the ID of the model that mdl was derived from in its
+// descriptor.
+func DerivedFrom(mdl types.ModelArtifact, id string) types.ModelArtifact {
+	return &model{
+		base:        mdl,
+		derivedFrom: id,
+	}
+}
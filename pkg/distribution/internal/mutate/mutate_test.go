@@ -112,3 +112,26 @@ func TestContextSize(t *testing.T) {
 		t.Fatalf("Expected context size of 2096 got %d", *cfg2.ContextSize)
 	}
 }
+
+func TestDerivedFrom(t *testing.T) {
+	mdl1, err := gguf.NewModel(filepath.Join("..", "..", "assets", "dummy.gguf"))
+	if err != nil {
+		t.Fatalf("Failed to create model: %v", err)
+	}
+	desc1, err := mdl1.Descriptor()
+	if err != nil {
+		t.Fatalf("Failed to get descriptor: %v", err)
+	}
+
+	mdl2 := mutate.DerivedFrom(mdl1, "sha256:source")
+	desc2, err := mdl2.Descriptor()
+	if err != nil {
+		t.Fatalf("Failed to get descriptor: %v", err)
+	}
+	if desc2.DerivedFrom != "sha256:source" {
+		t.Fatalf("Expected model to be derived from sha256:source, got %q", desc2.DerivedFrom)
+	}
+	if desc2.Created == nil || !desc2.Created.Equal(*desc1.Created) {
+		t.Fatalf("Expected creation time to be preserved")
+	}
+}
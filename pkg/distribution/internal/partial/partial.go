@@ -84,6 +84,22 @@ func MMPROJPath(i WithLayers) (string, error) {
 	return paths[0], err
 }
 
+// AdapterPath returns the path to the LoRA adapter of an adapter artifact.
+func AdapterPath(i WithLayers) (string, error) {
+	paths, err := layerPathsByMediaType(i, types.MediaTypeLoRAAdapter)
+	if err != nil {
+		return "", fmt.Errorf("get adapter layer paths: %w", err)
+	}
+	if len(paths) == 0 {
+		return "", fmt.Errorf("model does not contain any layer of type %q", types.MediaTypeLoRAAdapter)
+	}
+	if len(paths) > 1 {
+		return "", fmt.Errorf("found %d files of type %q, expected exactly 1",
+			len(paths), types.MediaTypeLoRAAdapter)
+	}
+	return paths[0], err
+}
+
 func ChatTemplatePath(i WithLayers) (string, error) {
 	paths, err := layerPathsByMediaType(i, types.MediaTypeChatTemplate)
 	if err != nil {
@@ -20,6 +20,7 @@ type Layer struct {
 	ID      string // Layer ID
 	Size    uint64 // Layer size
 	Current uint64 // Current bytes transferred
+	Cached  bool   `json:",omitempty"` // Layer is already in the store, so it isn't transferred
 }
 
 // Message represents a structured message for progress reporting
@@ -139,6 +140,21 @@ func WriteProgress(w io.Writer, msg string, imageSize, layerSize, current uint64
 	})
 }
 
+// WriteCached writes a progress update for a layer that is already in the
+// store, so it isn't transferred and doesn't count towards the image size.
+func WriteCached(w io.Writer, imageSize, layerSize uint64, layerID string) error {
+	return write(w, Message{
+		Type:    "progress",
+		Message: "Already exists",
+		Total:   imageSize,
+		Layer: Layer{
+			ID:     layerID,
+			Size:   layerSize,
+			Cached: true,
+		},
+	})
+}
+
 // WriteSuccess writes a success message
 func WriteSuccess(w io.Writer, message string) error {
 	return write(w, Message{
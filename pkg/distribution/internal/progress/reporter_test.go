@@ -97,6 +97,29 @@ func TestMessages(t *testing.T) {
 		}
 	})
 
+	t.Run("writeCached", func(t *testing.T) {
+		var buf bytes.Buffer
+		layer := newMockLayer(2016)
+		if err := WriteCached(&buf, 1, uint64(layer.size), layer.diffID); err != nil {
+			t.Fatalf("Failed to write cached message: %v", err)
+		}
+
+		var msg Message
+		if err := json.Unmarshal(buf.Bytes(), &msg); err != nil {
+			t.Fatalf("Failed to parse JSON: %v", err)
+		}
+
+		if msg.Type != "progress" {
+			t.Errorf("Expected type 'progress', got '%s'", msg.Type)
+		}
+		if msg.Total != 1 {
+			t.Errorf("Expected total 1, got %d", msg.Total)
+		}
+		if !msg.Layer.Cached || msg.Layer.Size != uint64(2016) || msg.Layer.Current != 0 {
+			t.Errorf("Expected a cached layer of %d bytes with nothing transferred, got %+v", 2016, msg.Layer)
+		}
+	})
+
 	t.Run("writeSuccess", func(t *testing.T) {
 		var buf bytes.Buffer
 		err := WriteSuccess(&buf, "Model pulled successfully")
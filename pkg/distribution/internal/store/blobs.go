@@ -82,9 +82,10 @@ type blob interface {
 	Uncompressed() (io.ReadCloser, error)
 }
 
-// writeLayer writes the layer blob to the store.
+// writeLayer writes the layer blob to the store, invoking notify if the write
+// is paused for lack of disk space until ctx is done.
 // It returns true when a new blob was created and the blob's DiffID.
-func (s *LocalStore) writeLayer(layer blob, updates chan<- v1.Update) (bool, v1.Hash, error) {
+func (s *LocalStore) writeLayer(ctx context.Context, layer blob, updates chan<- v1.Update, notify func(string)) (bool, v1.Hash, error) {
 	hash, err := layer.DiffID()
 	if err != nil {
 		return false, v1.Hash{}, fmt.Errorf("get file hash: %w", err)
@@ -120,7 +121,7 @@ func (s *LocalStore) writeLayer(layer blob, updates chan<- v1.Update) (bool, v1.
 
 	// WriteBlob will handle appending to incomplete files
 	// The HTTP layer will handle resuming via Range headers
-	if err := s.WriteBlob(hash, r); err != nil {
+	if err := s.writeBlob(ctx, hash, r, notify); err != nil {
 		return false, hash, err
 	}
 	return true, hash, nil
@@ -130,6 +131,14 @@ func (s *LocalStore) writeLayer(layer blob, updates chan<- v1.Update) (bool, v1.
 // If the blob is already in the store, it is a no-op and the blob is not consumed from the reader.
 // If an incomplete download exists, it will be resumed by appending to the existing file.
 func (s *LocalStore) WriteBlob(diffID v1.Hash, r io.Reader) error {
+	return s.writeBlob(context.Background(), diffID, r, func(msg string) {
+		fmt.Printf("Warning: %s\n", msg)
+	})
+}
+
+// writeBlob implements WriteBlob, invoking notify if the write is paused for
+// lack of disk space until ctx is done.
+func (s *LocalStore) writeBlob(ctx context.Context, diffID v1.Hash, r io.Reader, notify func(string)) error {
 	hasBlob, err := s.hasBlob(diffID)
 	if err != nil {
 		return fmt.Errorf("check blob existence: %w", err)
@@ -181,11 +190,14 @@ func (s *LocalStore) WriteBlob(diffID v1.Hash, r io.Reader) error {
 	}
 	defer f.Close()
 
-	if _, err := io.Copy(f, r); err != nil {
+	if _, err := io.Copy(&spaceGuardWriter{ctx: ctx, store: s, w: f, notify: notify}, r); err != nil {
 		// If we were resuming and copy failed, only delete the incomplete file if it's
-		// not a context cancellation. Context cancellation is a normal interruption and
-		// the file should be preserved for future resume attempts.
-		if isResume && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		// not a context cancellation or lack of disk space. These are normal
+		// interruptions and the file should be preserved for future resume attempts.
+		// Content that is known not to match the digest is never resumed.
+		if errors.Is(err, ErrBlobDigestMismatch) ||
+			isResume && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+				!errors.Is(err, ErrInsufficientSpace) {
 			_ = os.Remove(incompletePath)
 		}
 		return fmt.Errorf("copy blob %q to store: %w", diffID.String(), err)
@@ -233,6 +245,43 @@ func (s *LocalStore) removeBlob(hash v1.Hash) error {
 	return os.Remove(path)
 }
 
+// LinkBlob makes the blob with the given hash available at path, as a hard
+// link if possible or as a copy otherwise, such as when path is on another
+// file system. Any existing file at path is replaced.
+func (s *LocalStore) LinkBlob(hash v1.Hash, path string) error {
+	blobPath, err := s.blobPath(hash)
+	if err != nil {
+		return fmt.Errorf("get blob path: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove existing file: %w", err)
+	}
+	if err := os.Link(blobPath, path); err == nil {
+		return nil
+	}
+	src, err := os.Open(blobPath)
+	if err != nil {
+		return fmt.Errorf("open blob: %w", err)
+	}
+	defer src.Close()
+	dst, err := createFile(path)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(path)
+		return fmt.Errorf("copy blob: %w", err)
+	}
+	return dst.Close()
+}
+
+// HasBlob returns true if the blob with the given hash is in the store.
+func (s *LocalStore) HasBlob(hash v1.Hash) bool {
+	has, err := s.hasBlob(hash)
+	return err == nil && has
+}
+
 func (s *LocalStore) hasBlob(hash v1.Hash) (bool, error) {
 	path, err := s.blobPath(hash)
 	if err != nil {
@@ -244,6 +293,16 @@ func (s *LocalStore) hasBlob(hash v1.Hash) (bool, error) {
 	return false, nil
 }
 
+// OpenBlob opens the blob with the given hash for reading. It returns an error
+// satisfying errors.Is(err, fs.ErrNotExist) if the blob isn't in the store.
+func (s *LocalStore) OpenBlob(hash v1.Hash) (*os.File, error) {
+	path, err := s.blobPath(hash)
+	if err != nil {
+		return nil, fmt.Errorf("get blob path: %w", err)
+	}
+	return os.Open(path)
+}
+
 // GetIncompleteSize returns the size of an incomplete blob if it exists, or 0 if it doesn't.
 func (s *LocalStore) GetIncompleteSize(hash v1.Hash) (int64, error) {
 	path, err := s.blobPath(hash)
@@ -1,7 +1,9 @@
 package store
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -20,7 +22,8 @@ func (s *LocalStore) bundlePath(hash v1.Hash) string {
 	return filepath.Join(s.rootPath, bundlesDir, hash.Algorithm, hash.Hex)
 }
 
-// BundleForModel returns a runtime bundle for the given model
+// BundleForModel returns a runtime bundle for the given model, with the
+// settings stored with the model applied.
 func (s *LocalStore) BundleForModel(ref string) (types.ModelBundle, error) {
 	mdl, err := s.Read(ref)
 	if err != nil {
@@ -31,29 +34,68 @@ func (s *LocalStore) BundleForModel(ref string) (types.ModelBundle, error) {
 		return nil, fmt.Errorf("get model ID: %w", err)
 	}
 	path := s.bundlePath(dgst)
-	if bdl, err := bundle.Parse(path); err != nil {
-		// create for first time or replace bad/corrupted bundle
-		return s.createBundle(path, mdl)
-	} else {
-		return bdl, nil
+	var bdl types.ModelBundle
+	if parsed, err := bundle.Parse(path); err == nil {
+		bdl = parsed
+	} else if bdl, err = s.createBundle(path, mdl); err != nil {
+		// failed to create for first time or replace bad/corrupted bundle
+		return nil, err
 	}
+	return s.withSettings(dgst, bdl)
 }
 
-// createBundle unpacks the bundle to path, replacing existing bundle if one is found
+// createBundle unpacks the bundle to path, replacing existing bundle if one is
+// found. The archives extracted into the bundle must fit in the free disk
+// space, and a partially unpacked bundle is removed.
 func (s *LocalStore) createBundle(path string, mdl *Model) (types.ModelBundle, error) {
 	if err := os.RemoveAll(path); err != nil {
 		return nil, fmt.Errorf("remove %s: %w", path, err)
 	}
+	size, err := archivesSize(mdl)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.EnsureSpace(size); err != nil {
+		return nil, fmt.Errorf("unpack bundle: %w", err)
+	}
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return nil, fmt.Errorf("create bundle directory: %w", err)
 	}
-	bdl, err := bundle.Unpack(path, mdl)
+	bdl, err := bundle.Unpack(path, mdl, func(w io.Writer) io.Writer {
+		return &spaceGuardWriter{ctx: context.Background(), store: s, w: w}
+	})
 	if err != nil {
+		if removeErr := os.RemoveAll(path); removeErr != nil {
+			fmt.Printf("Warning: failed to remove partial bundle %q: %v\n", path, removeErr)
+		}
 		return nil, fmt.Errorf("unpack bundle: %w", err)
 	}
 	return bdl, nil
 }
 
+// archivesSize returns the size of the archive layers of a model, which are
+// extracted into its bundle, unlike its other files, which are linked. The
+// size of compressed archives underestimates the space they take up.
+func archivesSize(mdl *Model) (int64, error) {
+	layers, err := mdl.Layers()
+	if err != nil {
+		return 0, fmt.Errorf("get model layers: %w", err)
+	}
+	var size int64
+	for _, layer := range layers {
+		mediaType, err := layer.MediaType()
+		if err != nil || (mediaType != types.MediaTypeDirTar && mediaType != types.MediaTypeVLLMConfigArchive) {
+			continue
+		}
+		layerSize, err := layer.Size()
+		if err != nil {
+			return 0, fmt.Errorf("get layer size: %w", err)
+		}
+		size += layerSize
+	}
+	return size, nil
+}
+
 func (s *LocalStore) removeBundle(hash v1.Hash) error {
 	return os.RemoveAll(s.bundlePath(hash))
 }
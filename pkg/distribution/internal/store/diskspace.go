@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+)
+
+// ErrInsufficientSpace indicates that there isn't enough free disk space in
+// the store to complete an operation.
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+var (
+	// spaceCheckInterval is the number of bytes written between free space
+	// checks while writing blobs.
+	spaceCheckInterval int64 = 64 * 1024 * 1024
+	// spacePollInterval is how often free space is re-checked while a write is
+	// paused for lack of space.
+	spacePollInterval = 5 * time.Second
+	// spacePauseTimeout is how long a write remains paused waiting for disk
+	// space before failing.
+	spacePauseTimeout = 10 * time.Minute
+)
+
+// availableSpaceFunc returns the free disk space available to the store. It's
+// a variable so that it can be replaced in tests.
+var availableSpaceFunc = availableSpace
+
+// EnsureSpace checks that required bytes can be written to the store while
+// leaving the configured minimum free space in reserve. If the free space
+// can't be determined, the check passes.
+func (s *LocalStore) EnsureSpace(required int64) error {
+	if s.minFreeSpace == 0 && required <= 0 {
+		return nil
+	}
+	available, err := availableSpaceFunc(s.rootPath)
+	if err != nil {
+		return nil
+	}
+	needed := s.minFreeSpace
+	if required > 0 {
+		needed += uint64(required)
+	}
+	if available < needed {
+		return fmt.Errorf("%w: %s required (including %s reserve), %s available",
+			ErrInsufficientSpace, formatBytes(needed), formatBytes(s.minFreeSpace), formatBytes(available))
+	}
+	return nil
+}
+
+// waitForSpace blocks while free space is below the configured reserve,
+// invoking notify once when the write is paused. It fails with
+// ErrInsufficientSpace if space doesn't become available in time, or with the
+// cause of ctx being done if the write is cancelled first.
+func (s *LocalStore) waitForSpace(ctx context.Context, notify func(string)) error {
+	if s.minFreeSpace == 0 {
+		return nil
+	}
+	var pausedAt time.Time
+	for {
+		available, err := availableSpaceFunc(s.rootPath)
+		if err != nil || available >= s.minFreeSpace {
+			if !pausedAt.IsZero() {
+				notify("Disk space available again, resuming download")
+			}
+			return nil
+		}
+		if pausedAt.IsZero() {
+			pausedAt = time.Now()
+			notify(fmt.Sprintf("Low disk space (%s available, %s reserved), pausing download until space is freed",
+				formatBytes(available), formatBytes(s.minFreeSpace)))
+		} else if time.Since(pausedAt) >= spacePauseTimeout {
+			return fmt.Errorf("%w: %s available, %s reserved", ErrInsufficientSpace,
+				formatBytes(available), formatBytes(s.minFreeSpace))
+		}
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(spacePollInterval):
+		}
+	}
+}
+
+// spaceGuardWriter pauses writes while free disk space is low and translates
+// out-of-space write failures into ErrInsufficientSpace. If notify is nil,
+// writes fail with ErrInsufficientSpace instead of pausing.
+type spaceGuardWriter struct {
+	ctx       context.Context
+	store     *LocalStore
+	w         io.Writer
+	notify    func(string)
+	written   int64
+	nextCheck int64
+}
+
+// Write implements io.Writer.Write.
+func (g *spaceGuardWriter) Write(p []byte) (int, error) {
+	if g.written >= g.nextCheck {
+		var err error
+		if g.notify == nil {
+			err = g.store.EnsureSpace(0)
+		} else {
+			err = g.store.waitForSpace(g.ctx, g.notify)
+		}
+		if err != nil {
+			return 0, err
+		}
+		g.nextCheck = g.written + spaceCheckInterval
+	}
+	n, err := g.w.Write(p)
+	g.written += int64(n)
+	if errors.Is(err, syscall.ENOSPC) {
+		return n, fmt.Errorf("%w: %w", ErrInsufficientSpace, err)
+	}
+	return n, err
+}
+
+// formatBytes formats a byte count for display.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/distribution/internal/gguf"
+	"github.com/docker/model-runner/pkg/distribution/internal/mutate"
+	"github.com/docker/model-runner/pkg/distribution/internal/partial"
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+// stubAvailableSpace replaces the free space lookup for the duration of a test.
+func stubAvailableSpace(t *testing.T, f func() uint64) {
+	t.Helper()
+	original := availableSpaceFunc
+	availableSpaceFunc = func(string) (uint64, error) {
+		return f(), nil
+	}
+	t.Cleanup(func() {
+		availableSpaceFunc = original
+	})
+}
+
+func TestEnsureSpace(t *testing.T) {
+	stubAvailableSpace(t, func() uint64 { return 1000 })
+
+	tests := []struct {
+		name         string
+		minFreeSpace uint64
+		required     int64
+		expectError  bool
+	}{
+		{name: "fits", minFreeSpace: 100, required: 900},
+		{name: "eats into reserve", minFreeSpace: 200, required: 900, expectError: true},
+		{name: "nothing required", minFreeSpace: 1000, required: 0},
+		{name: "reserve exceeds free space", minFreeSpace: 2000, required: 0, expectError: true},
+		{name: "no reserve", minFreeSpace: 0, required: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &LocalStore{rootPath: t.TempDir(), minFreeSpace: tt.minFreeSpace}
+			err := s.EnsureSpace(tt.required)
+			if tt.expectError && !errors.Is(err, ErrInsufficientSpace) {
+				t.Errorf("expected ErrInsufficientSpace, got %v", err)
+			} else if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSpaceGuardWriterPausesWhileSpaceIsLow(t *testing.T) {
+	origInterval, origPoll := spaceCheckInterval, spacePollInterval
+	spaceCheckInterval, spacePollInterval = 4, time.Millisecond
+	t.Cleanup(func() {
+		spaceCheckInterval, spacePollInterval = origInterval, origPoll
+	})
+
+	// Report low space for the first few checks, then recover.
+	var checks atomic.Int32
+	stubAvailableSpace(t, func() uint64 {
+		if checks.Add(1) <= 3 {
+			return 10
+		}
+		return 1000
+	})
+
+	s := &LocalStore{rootPath: t.TempDir(), minFreeSpace: 100}
+	var out bytes.Buffer
+	var notifications []string
+	w := &spaceGuardWriter{ctx: context.Background(), store: s, w: &out, notify: func(msg string) {
+		notifications = append(notifications, msg)
+	}}
+	if _, err := fmt.Fprint(w, "hello world"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "hello world" {
+		t.Errorf("expected all data to be written, got %q", out.String())
+	}
+	if len(notifications) != 2 || !strings.Contains(notifications[0], "pausing") || !strings.Contains(notifications[1], "resuming") {
+		t.Errorf("expected pause and resume notifications, got %v", notifications)
+	}
+}
+
+func TestSpaceGuardWriterTimesOut(t *testing.T) {
+	origPoll, origTimeout := spacePollInterval, spacePauseTimeout
+	spacePollInterval, spacePauseTimeout = time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() {
+		spacePollInterval, spacePauseTimeout = origPoll, origTimeout
+	})
+	stubAvailableSpace(t, func() uint64 { return 10 })
+
+	s := &LocalStore{rootPath: t.TempDir(), minFreeSpace: 100}
+	w := &spaceGuardWriter{ctx: context.Background(), store: s, w: &bytes.Buffer{}, notify: func(string) {}}
+	if _, err := w.Write([]byte("data")); !errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("expected ErrInsufficientSpace, got %v", err)
+	}
+}
+
+func TestSpaceGuardWriterCancelled(t *testing.T) {
+	stubAvailableSpace(t, func() uint64 { return 10 })
+
+	s := &LocalStore{rootPath: t.TempDir(), minFreeSpace: 100}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &spaceGuardWriter{ctx: ctx, store: s, w: &bytes.Buffer{}, notify: func(string) { cancel() }}
+	done := make(chan error, 1)
+	go func() {
+		_, err := w.Write([]byte("data"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("expected the paused write to stop once cancelled")
+	}
+}
+
+func TestBundleExtractionChecksSpace(t *testing.T) {
+	// Build a model with a directory archive, which is extracted into its
+	// bundle rather than linked.
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	content := []byte(strings.Repeat("x", 1024))
+	if err := tw.WriteHeader(&tar.Header{Name: "model/weights.onnx", Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archivePath := filepath.Join(t.TempDir(), "model.tar")
+	if err := os.WriteFile(archivePath, archive.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mdl, err := gguf.NewModel(filepath.Join("testdata", "dummy.gguf"))
+	if err != nil {
+		t.Fatalf("failed to create model: %v", err)
+	}
+	layer, err := partial.NewLayer(archivePath, types.MediaTypeDirTar)
+	if err != nil {
+		t.Fatalf("failed to create layer: %v", err)
+	}
+	s, err := New(Options{RootPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	if err := s.Write(mutate.AppendLayers(mdl, layer), []string{"ai/model:latest"}, nil); err != nil {
+		t.Fatalf("failed to write model: %v", err)
+	}
+	s.minFreeSpace = 100
+	bundles := filepath.Join(s.rootPath, bundlesDir)
+
+	tests := []struct {
+		name      string
+		available func(calls int32) uint64
+	}{
+		// The archives don't fit before extraction starts.
+		{name: "before extraction", available: func(int32) uint64 { return 200 }},
+		// Space runs out while the archives are extracted.
+		{name: "during extraction", available: func(calls int32) uint64 {
+			if calls == 1 {
+				return 1 << 30
+			}
+			return 10
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			stubAvailableSpace(t, func() uint64 { return tt.available(calls.Add(1)) })
+			if _, err := s.BundleForModel("ai/model"); !errors.Is(err, ErrInsufficientSpace) {
+				t.Fatalf("expected ErrInsufficientSpace, got %v", err)
+			}
+			// No partially unpacked bundle is left behind.
+			if entries, _ := filepath.Glob(filepath.Join(bundles, "*", "*")); len(entries) != 0 {
+				t.Errorf("expected no bundles, got %v", entries)
+			}
+		})
+	}
+
+	stubAvailableSpace(t, func() uint64 { return 1 << 30 })
+	if _, err := s.BundleForModel("ai/model"); err != nil {
+		t.Errorf("unexpected error with enough space: %v", err)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[uint64]string{
+		512:                "512 B",
+		2048:               "2.0 KiB",
+		5 * 1024 * 1024:    "5.0 MiB",
+		1536 * 1024 * 1024: "1.5 GiB",
+	}
+	for n, expected := range tests {
+		if got := formatBytes(n); got != expected {
+			t.Errorf("formatBytes(%d) = %q, expected %q", n, got, expected)
+		}
+	}
+}
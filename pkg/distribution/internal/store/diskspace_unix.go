@@ -0,0 +1,17 @@
+//go:build !windows
+
+package store
+
+import (
+	"syscall"
+)
+
+// availableSpace returns the disk space available to unprivileged users on
+// the filesystem containing path.
+func availableSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -5,3 +5,11 @@ import (
 )
 
 var ErrModelNotFound = errors.New("model not found")
+
+// ErrLastTag indicates that removing a tag would leave its model untagged.
+var ErrLastTag = errors.New("tag is the only tag of its model")
+
+// ErrBlobDigestMismatch indicates that the content written to a blob doesn't
+// match its digest. The incomplete blob is discarded, rather than kept for the
+// download to be resumed.
+var ErrBlobDigestMismatch = errors.New("blob digest mismatch")
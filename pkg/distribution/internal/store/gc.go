@@ -0,0 +1,92 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+)
+
+// Garbage describes the blobs in the store that no model references.
+type Garbage struct {
+	// Blobs are the digests of the unreferenced blobs.
+	Blobs []string `json:"blobs"`
+	// Size is the disk space, in bytes, taken up by the unreferenced blobs.
+	Size int64 `json:"size"`
+}
+
+// CollectGarbage finds the blobs that aren't referenced by the manifest of
+// any model in the index, such as those left behind by failed deletes, and
+// removes them unless dryRun is set. Blobs of quarantined models are kept, so
+// that pulling the models again only fetches their damaged content, and
+// incomplete downloads are left to CleanupStaleIncompleteFiles.
+// Callers must ensure that no model is being written concurrently, as its
+// blobs aren't referenced until its manifest is written.
+func (s *LocalStore) CollectGarbage(dryRun bool) (Garbage, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return Garbage{}, fmt.Errorf("reading models index: %w", err)
+	}
+	quarantine, err := s.readQuarantine()
+	if err != nil {
+		return Garbage{}, err
+	}
+	referenced := make(map[string]bool)
+	for _, entry := range index.Models {
+		for _, file := range entry.Files {
+			referenced[file] = true
+		}
+	}
+	for _, m := range quarantine.Models {
+		for _, file := range m.Files {
+			referenced[file] = true
+		}
+	}
+
+	garbage := Garbage{Blobs: []string{}}
+	err = filepath.WalkDir(s.blobsDir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".incomplete") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.blobsDir(), path)
+		if err != nil {
+			return nil
+		}
+		hash, err := v1.NewHash(strings.Replace(filepath.ToSlash(rel), "/", ":", 1))
+		if err != nil || validateHash(hash) != nil {
+			// Not a blob written by the store.
+			return nil
+		}
+		if referenced[hash.String()] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if !dryRun {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("removing blob %q: %w", hash.String(), err)
+			}
+		}
+		garbage.Blobs = append(garbage.Blobs, hash.String())
+		garbage.Size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return Garbage{}, fmt.Errorf("walking blobs directory: %w", err)
+	}
+	slices.Sort(garbage.Blobs)
+	return garbage, nil
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	s, err := New(Options{RootPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	digest := func(c string) string { return "sha256:" + strings.Repeat(c, 64) }
+	blobPath := func(file string) string {
+		hash, err := v1.NewHash(file)
+		if err != nil {
+			t.Fatalf("Failed to parse hash: %v", err)
+		}
+		path, err := s.blobPath(hash)
+		if err != nil {
+			t.Fatalf("Failed to get blob path: %v", err)
+		}
+		return path
+	}
+	writeFile := func(path string, size int) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create blob directory: %v", err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatalf("Failed to write blob: %v", err)
+		}
+	}
+
+	// Blobs 1 and 2 are referenced by a model and a quarantined model, while
+	// blobs 3 and 4 are left behind. Incomplete downloads and files that
+	// aren't blobs are ignored.
+	for i, file := range []string{digest("1"), digest("2"), digest("3"), digest("4")} {
+		writeFile(blobPath(file), (i+1)*100)
+	}
+	writeFile(incompletePath(blobPath(digest("5"))), 500)
+	writeFile(filepath.Join(s.blobsDir(), "sha256", "not-a-blob"), 600)
+	if err := s.writeIndex(Index{Models: []IndexEntry{
+		{ID: digest("a"), Tags: []string{"ai/model:latest"}, Files: []string{digest("1")}},
+	}}); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+	if err := s.writeQuarantine(Quarantine{Models: []QuarantinedModel{
+		{IndexEntry: IndexEntry{ID: digest("b"), Files: []string{digest("2")}}, Reason: "missing blob"},
+	}}); err != nil {
+		t.Fatalf("Failed to write quarantine: %v", err)
+	}
+
+	expected := []string{digest("3"), digest("4")}
+	garbage, err := s.CollectGarbage(true)
+	if err != nil {
+		t.Fatalf("Failed to collect garbage: %v", err)
+	}
+	if !slices.Equal(garbage.Blobs, expected) || garbage.Size != 700 {
+		t.Errorf("Expected blobs %v of 700 bytes, got %+v", expected, garbage)
+	}
+	if _, err := os.Stat(blobPath(digest("3"))); err != nil {
+		t.Errorf("Expected dry run to keep blobs, got %v", err)
+	}
+
+	garbage, err = s.CollectGarbage(false)
+	if err != nil {
+		t.Fatalf("Failed to collect garbage: %v", err)
+	}
+	if !slices.Equal(garbage.Blobs, expected) || garbage.Size != 700 {
+		t.Errorf("Expected blobs %v of 700 bytes, got %+v", expected, garbage)
+	}
+	for _, file := range expected {
+		if _, err := os.Stat(blobPath(file)); !os.IsNotExist(err) {
+			t.Errorf("Expected blob %s to be removed, got %v", file, err)
+		}
+	}
+	for _, path := range []string{blobPath(digest("1")), blobPath(digest("2")), incompletePath(blobPath(digest("5")))} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", path, err)
+		}
+	}
+
+	garbage, err = s.CollectGarbage(false)
+	if err != nil || len(garbage.Blobs) != 0 || garbage.Size != 0 {
+		t.Errorf("Expected no garbage left, got %+v (error %v)", garbage, err)
+	}
+}
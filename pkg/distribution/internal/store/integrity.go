@@ -0,0 +1,244 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+)
+
+const (
+	quarantineDir = "quarantine"
+)
+
+// QuarantinedModel is a model that was removed from the index because its
+// content failed the integrity scan. Blobs that are intact stay in the store,
+// so pulling one of the model's tags again only fetches the damaged content.
+type QuarantinedModel struct {
+	IndexEntry
+	// Reason describes why the model was quarantined.
+	Reason string `json:"reason"`
+	// QuarantinedAt is the time at which the model was quarantined.
+	QuarantinedAt time.Time `json:"quarantinedAt"`
+}
+
+// Quarantine is the list of quarantined models.
+type Quarantine struct {
+	Models []QuarantinedModel `json:"models"`
+}
+
+// quarantinePath returns the path to the quarantine file
+func (s *LocalStore) quarantinePath() string {
+	return filepath.Join(s.rootPath, "quarantine.json")
+}
+
+// quarantinedBlobPath returns the path to which a corrupt blob is moved.
+func (s *LocalStore) quarantinedBlobPath(hash v1.Hash) string {
+	return filepath.Join(s.rootPath, quarantineDir, blobsDir, hash.Algorithm, hash.Hex)
+}
+
+// readQuarantine reads the quarantine file
+func (s *LocalStore) readQuarantine() (Quarantine, error) {
+	data, err := os.ReadFile(s.quarantinePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return Quarantine{}, nil
+	} else if err != nil {
+		return Quarantine{}, fmt.Errorf("reading quarantine file: %w", err)
+	}
+
+	var quarantine Quarantine
+	if err := json.Unmarshal(data, &quarantine); err != nil {
+		return Quarantine{}, fmt.Errorf("unmarshaling quarantine: %w", err)
+	}
+	return quarantine, nil
+}
+
+// writeQuarantine writes the quarantine file
+func (s *LocalStore) writeQuarantine(quarantine Quarantine) error {
+	data, err := json.MarshalIndent(quarantine, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling quarantine: %w", err)
+	}
+	if err := writeFile(s.quarantinePath(), data); err != nil {
+		return fmt.Errorf("writing quarantine file: %w", err)
+	}
+	return nil
+}
+
+// Quarantined returns the models that are currently quarantined.
+func (s *LocalStore) Quarantined() ([]QuarantinedModel, error) {
+	quarantine, err := s.readQuarantine()
+	if err != nil {
+		return nil, err
+	}
+	return quarantine.Models, nil
+}
+
+// Scan checks that the manifest and blobs of every model in the index are
+// present and consistent. Models that fail the check are moved from the index
+// to the quarantine list and any blobs with unexpected sizes are moved out of
+// the blob store, so that a later pull fetches them again. The scan doesn't
+// hash blob contents, so it's cheap enough to run on every startup.
+// It returns the models quarantined by this scan.
+func (s *LocalStore) Scan() ([]QuarantinedModel, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return nil, fmt.Errorf("reading models index: %w", err)
+	}
+
+	reasons := make(map[string]string)
+	corrupt := make(map[string][]v1.Hash)
+	for _, entry := range index.Models {
+		corruptBlobs, reason := s.checkEntry(entry)
+		if reason != "" {
+			reasons[entry.ID] = reason
+			corrupt[entry.ID] = corruptBlobs
+		}
+	}
+	return s.quarantineModels(index, reasons, corrupt)
+}
+
+// quarantineModels moves models from the index to the quarantine list, along
+// with their blobs that are present but corrupt, and removes their unpacked
+// bundles. reasons maps the IDs of the models to quarantine to why they're
+// quarantined, and corrupt maps them to their corrupt blobs. It returns the
+// quarantined models.
+func (s *LocalStore) quarantineModels(index Index, reasons map[string]string, corrupt map[string][]v1.Hash) ([]QuarantinedModel, error) {
+	var healthy []IndexEntry
+	var quarantined []QuarantinedModel
+	for _, entry := range index.Models {
+		reason, ok := reasons[entry.ID]
+		if !ok {
+			healthy = append(healthy, entry)
+			continue
+		}
+		for _, hash := range corrupt[entry.ID] {
+			if err := s.quarantineBlob(hash); err != nil {
+				return nil, err
+			}
+		}
+		if hash, err := v1.NewHash(entry.ID); err == nil {
+			if err := s.removeBundle(hash); err != nil {
+				fmt.Printf("Warning: failed to remove bundle %q: %v\n", hash, err)
+			}
+		}
+		quarantined = append(quarantined, QuarantinedModel{
+			IndexEntry:    entry,
+			Reason:        reason,
+			QuarantinedAt: time.Now(),
+		})
+	}
+	if len(quarantined) == 0 {
+		return nil, nil
+	}
+
+	quarantine, err := s.readQuarantine()
+	if err != nil {
+		return nil, err
+	}
+	quarantine.Models = append(quarantine.Models, quarantined...)
+	if err := s.writeQuarantine(quarantine); err != nil {
+		return nil, err
+	}
+	if healthy == nil {
+		healthy = []IndexEntry{}
+	}
+	if err := s.writeIndex(Index{Models: healthy}); err != nil {
+		return nil, fmt.Errorf("writing models index: %w", err)
+	}
+	return quarantined, nil
+}
+
+// checkEntry checks the manifest and blobs of an index entry. It returns a
+// non-empty reason if the entry is inconsistent, along with the blobs that are
+// present but have an unexpected size.
+func (s *LocalStore) checkEntry(entry IndexEntry) ([]v1.Hash, string) {
+	digest, err := v1.NewHash(entry.ID)
+	if err != nil {
+		return nil, fmt.Sprintf("invalid model ID: %v", err)
+	}
+	rawManifest, err := os.ReadFile(s.manifestPath(digest))
+	if err != nil {
+		return nil, fmt.Sprintf("reading manifest: %v", err)
+	}
+	actual, _, err := v1.SHA256(bytes.NewReader(rawManifest))
+	if err != nil {
+		return nil, fmt.Sprintf("hashing manifest: %v", err)
+	}
+	if actual != digest {
+		return nil, fmt.Sprintf("manifest digest is %s", actual)
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(rawManifest))
+	if err != nil {
+		return nil, fmt.Sprintf("parsing manifest: %v", err)
+	}
+
+	var corrupt []v1.Hash
+	var reason string
+	descriptors := append([]v1.Descriptor{manifest.Config}, manifest.Layers...)
+	for _, desc := range descriptors {
+		path, err := s.blobPath(desc.Digest)
+		if err != nil {
+			return nil, fmt.Sprintf("blob %s: %v", desc.Digest, err)
+		}
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			if reason == "" {
+				reason = fmt.Sprintf("blob %s is missing", desc.Digest)
+			}
+			continue
+		} else if err != nil {
+			return nil, fmt.Sprintf("blob %s: %v", desc.Digest, err)
+		}
+		if desc.Size > 0 && info.Size() != desc.Size {
+			corrupt = append(corrupt, desc.Digest)
+			if reason == "" {
+				reason = fmt.Sprintf("blob %s has size %d, expected %d", desc.Digest, info.Size(), desc.Size)
+			}
+		}
+	}
+	return corrupt, reason
+}
+
+// quarantineBlob moves a corrupt blob out of the blob store.
+func (s *LocalStore) quarantineBlob(hash v1.Hash) error {
+	path, err := s.blobPath(hash)
+	if err != nil {
+		return fmt.Errorf("get blob path: %w", err)
+	}
+	target := s.quarantinedBlobPath(hash)
+	if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+		return fmt.Errorf("create quarantine directory: %w", err)
+	}
+	if err := os.Rename(path, target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("quarantine blob %s: %w", hash, err)
+	}
+	return nil
+}
+
+// releaseQuarantine removes a model from the quarantine list once it has
+// been written to the store again.
+func (s *LocalStore) releaseQuarantine(id string) error {
+	quarantine, err := s.readQuarantine()
+	if err != nil {
+		return err
+	}
+	var remaining []QuarantinedModel
+	for _, m := range quarantine.Models {
+		if m.ID != id {
+			remaining = append(remaining, m)
+		}
+	}
+	if len(remaining) == len(quarantine.Models) {
+		return nil
+	}
+	if remaining == nil {
+		remaining = []QuarantinedModel{}
+	}
+	return s.writeQuarantine(Quarantine{Models: remaining})
+}
@@ -0,0 +1,120 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/internal/gguf"
+	"github.com/docker/model-runner/pkg/distribution/internal/store"
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+func TestScanQuarantinesDamagedModels(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(t *testing.T, blobPath string)
+	}{
+		{
+			name: "missing blob",
+			damage: func(t *testing.T, blobPath string) {
+				if err := os.Remove(blobPath); err != nil {
+					t.Fatalf("Failed to remove blob: %v", err)
+				}
+			},
+		},
+		{
+			name: "truncated blob",
+			damage: func(t *testing.T, blobPath string) {
+				if err := os.Truncate(blobPath, 1); err != nil {
+					t.Fatalf("Failed to truncate blob: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storePath := t.TempDir()
+			s, err := store.New(store.Options{RootPath: storePath})
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+
+			healthy := newTestModel(t)
+			if err := s.Write(healthy, []string{"healthy:latest"}, nil); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			modelPath := filepath.Join(t.TempDir(), "damaged.gguf")
+			if err := os.WriteFile(modelPath, []byte("damaged model content"), 0644); err != nil {
+				t.Fatalf("Failed to create model file: %v", err)
+			}
+			damaged, err := gguf.NewModel(modelPath)
+			if err != nil {
+				t.Fatalf("Failed to create model: %v", err)
+			}
+			if err := s.Write(damaged, []string{"damaged:latest"}, nil); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			blobPath := layerBlobPath(t, storePath, damaged)
+			tt.damage(t, blobPath)
+
+			quarantined, err := s.Scan()
+			if err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+			if len(quarantined) != 1 || quarantined[0].Tags[0] != "damaged:latest" || quarantined[0].Reason == "" {
+				t.Fatalf("Expected damaged model to be quarantined, got %+v", quarantined)
+			}
+
+			models, err := s.List()
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(models) != 1 || models[0].Tags[0] != "healthy:latest" {
+				t.Fatalf("Expected only the healthy model to be listed, got %+v", models)
+			}
+			if _, err := s.Read("healthy:latest"); err != nil {
+				t.Fatalf("Failed to read healthy model: %v", err)
+			}
+			if _, err := os.Stat(blobPath); !os.IsNotExist(err) {
+				t.Fatalf("Expected damaged blob to be moved out of the blob store")
+			}
+
+			// A second scan finds nothing new
+			if again, err := s.Scan(); err != nil || len(again) != 0 {
+				t.Fatalf("Expected no new quarantined models, got %v (err=%v)", again, err)
+			}
+
+			// Writing the model again restores it and releases it from quarantine
+			if err := s.Write(damaged, []string{"damaged:latest"}, nil); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if _, err := s.Read("damaged:latest"); err != nil {
+				t.Fatalf("Failed to read restored model: %v", err)
+			}
+			remaining, err := s.Quarantined()
+			if err != nil {
+				t.Fatalf("Quarantined failed: %v", err)
+			}
+			if len(remaining) != 0 {
+				t.Fatalf("Expected quarantine to be empty, got %+v", remaining)
+			}
+		})
+	}
+}
+
+// layerBlobPath returns the path of the first layer blob of mdl in the store.
+func layerBlobPath(t *testing.T, storePath string, mdl types.ModelArtifact) string {
+	layers, err := mdl.Layers()
+	if err != nil {
+		t.Fatalf("Failed to get layers: %v", err)
+	}
+	digest, err := layers[0].Digest()
+	if err != nil {
+		t.Fatalf("Failed to get layer digest: %v", err)
+	}
+	return filepath.Join(storePath, "blobs", digest.Algorithm, digest.Hex)
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+// Licenses records the acceptances of the licenses of models.
+type Licenses struct {
+	Acceptances []types.LicenseAcceptance `json:"acceptances"`
+}
+
+// licensesPath returns the path to the licenses file
+func (s *LocalStore) licensesPath() string {
+	return filepath.Join(s.rootPath, "licenses.json")
+}
+
+// readLicenses reads the license acceptances from the licenses file
+func (s *LocalStore) readLicenses() (Licenses, error) {
+	data, err := os.ReadFile(s.licensesPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return Licenses{}, nil
+	} else if err != nil {
+		return Licenses{}, fmt.Errorf("reading licenses file: %w", err)
+	}
+
+	var licenses Licenses
+	if err := json.Unmarshal(data, &licenses); err != nil {
+		return Licenses{}, fmt.Errorf("unmarshaling licenses: %w", err)
+	}
+	return licenses, nil
+}
+
+// writeLicenses writes the license acceptances to the licenses file
+func (s *LocalStore) writeLicenses(licenses Licenses) error {
+	data, err := json.MarshalIndent(licenses, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling licenses: %w", err)
+	}
+	if err := writeFile(s.licensesPath(), data); err != nil {
+		return fmt.Errorf("writing licenses file: %w", err)
+	}
+	return nil
+}
+
+// updateLicenses applies an update to the license acceptances. Updates are
+// serialized with the index lock, so that acceptances recorded by other
+// processes sharing the store aren't lost.
+func (s *LocalStore) updateLicenses(update func(*Licenses)) error {
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	licenses, err := s.readLicenses()
+	if err != nil {
+		return err
+	}
+	update(&licenses)
+	return s.writeLicenses(licenses)
+}
+
+// AcceptLicense records the acceptance of the license of a model.
+func (s *LocalStore) AcceptLicense(acceptance types.LicenseAcceptance) error {
+	return s.updateLicenses(func(l *Licenses) {
+		l.Acceptances = append(l.Acceptances, acceptance)
+	})
+}
+
+// RevokeLicense revokes the acceptances of the license of the model with the
+// specified ID that haven't been revoked yet. It returns false if there were
+// none.
+func (s *LocalStore) RevokeLicense(id string, at time.Time) (bool, error) {
+	revoked := false
+	err := s.updateLicenses(func(l *Licenses) {
+		for i := range l.Acceptances {
+			if l.Acceptances[i].Model == id && l.Acceptances[i].RevokedAt == nil {
+				l.Acceptances[i].RevokedAt = &at
+				revoked = true
+			}
+		}
+	})
+	return revoked, err
+}
+
+// LicenseAcceptances returns all the license acceptances recorded, including
+// revoked ones, in the order they were recorded.
+func (s *LocalStore) LicenseAcceptances() ([]types.LicenseAcceptance, error) {
+	licenses, err := s.readLicenses()
+	if err != nil {
+		return nil, err
+	}
+	return licenses.Acceptances, nil
+}
+
+// LicenseAcceptance returns the latest acceptance of the license of the model
+// with the specified ID that hasn't been revoked, if any.
+func (s *LocalStore) LicenseAcceptance(id string) (types.LicenseAcceptance, bool, error) {
+	licenses, err := s.readLicenses()
+	if err != nil {
+		return types.LicenseAcceptance{}, false, err
+	}
+	for i := len(licenses.Acceptances) - 1; i >= 0; i-- {
+		if acceptance := licenses.Acceptances[i]; acceptance.Model == id && acceptance.RevokedAt == nil {
+			return acceptance, true, nil
+		}
+	}
+	return types.LicenseAcceptance{}, false, nil
+}
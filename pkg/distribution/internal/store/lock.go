@@ -0,0 +1,75 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// storeLockFile is the lock file that coordinates the writes of models
+	// with the operations that remove blobs, across the processes that share
+	// the store.
+	storeLockFile = "store.lock"
+	// indexLockFile is the lock file that serializes updates of the index
+	// across the processes that share the store.
+	indexLockFile = "index.lock"
+)
+
+// lockFile acquires an advisory lock on the file at path, creating it if
+// needed, and returns the function that releases it. Shared locks can be held
+// together, while an exclusive lock is held alone; lockFile waits until the
+// lock can be acquired.
+//
+// Locks are held through the open file rather than by the existence of the
+// file, so the operating system releases the locks of processes that exit or
+// crash: a lock can't be left stale, and lock files that are left behind are
+// simply reused.
+func lockFile(path string, exclusive bool) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+	if err := lockHandle(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	return func() {
+		_ = unlockHandle(f)
+		f.Close()
+	}, nil
+}
+
+// LockShared locks the store for writing models, across the processes that
+// share it. Models can be written concurrently, but not while blobs are
+// removed, since the blobs of a model aren't referenced until its manifest is
+// written and the blobs that it shares with other models aren't written again.
+func (s *LocalStore) LockShared() (func(), error) {
+	return lockFile(filepath.Join(s.rootPath, storeLockFile), false)
+}
+
+// LockExclusive locks the store for removing blobs, as deletes and garbage
+// collection do, across the processes that share it. It waits for the models
+// that are being written to be written first.
+func (s *LocalStore) LockExclusive() (func(), error) {
+	return lockFile(filepath.Join(s.rootPath, storeLockFile), true)
+}
+
+// lockIndex serializes an update of the index, which reads, modifies and
+// writes it, with the updates of this process and of the other processes that
+// share the store, so that concurrent updates aren't lost.
+func (s *LocalStore) lockIndex() (func(), error) {
+	s.indexMu.Lock()
+	unlock, err := lockFile(filepath.Join(s.rootPath, indexLockFile), true)
+	if err != nil {
+		s.indexMu.Unlock()
+		return nil, fmt.Errorf("locking models index: %w", err)
+	}
+	return func() {
+		unlock()
+		s.indexMu.Unlock()
+	}, nil
+}
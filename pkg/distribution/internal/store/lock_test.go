@@ -0,0 +1,133 @@
+package store_test
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/distribution/internal/store"
+)
+
+// lockHelperEnv is set for the process started by TestStoreLockAcrossProcesses
+// to hold a lock on the store.
+const lockHelperEnv = "STORE_LOCK_HELPER_ROOT"
+
+func TestConcurrentTaggingAcrossStores(t *testing.T) {
+	root := t.TempDir()
+	stores := make([]*store.LocalStore, 2)
+	for i := range stores {
+		s, err := store.New(store.Options{RootPath: root})
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		stores[i] = s
+	}
+	if err := stores[0].Write(newTestModel(t), []string{"ai/model:latest"}, nil); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Tags added concurrently through stores that share a directory, as
+	// separate processes do, aren't lost.
+	const tags = 20
+	var wg sync.WaitGroup
+	for i := range tags {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := stores[i%2].AddTags("ai/model:latest", []string{fmt.Sprintf("ai/model:v%d", i)}); err != nil {
+				t.Errorf("AddTags failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	models, err := stores[0].List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(models) != 1 || len(models[0].Tags) != tags+1 {
+		t.Errorf("Expected one model with %d tags, got %+v", tags+1, models)
+	}
+}
+
+func TestStoreLockAcrossProcesses(t *testing.T) {
+	if root := os.Getenv(lockHelperEnv); root != "" {
+		// Hold a shared lock, as a pull does, until the process is killed.
+		s, err := store.New(store.Options{RootPath: root})
+		if err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		if _, err := s.LockShared(); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("locked")
+		select {}
+	}
+
+	root := t.TempDir()
+	s, err := store.New(store.Options{RootPath: root})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	helper := exec.Command(os.Args[0], "-test.run=^TestStoreLockAcrossProcesses$")
+	helper.Env = append(os.Environ(), lockHelperEnv+"="+root)
+	stdout, err := helper.StdoutPipe()
+	if err != nil {
+		t.Fatalf("Failed to get helper output: %v", err)
+	}
+	if err := helper.Start(); err != nil {
+		t.Fatalf("Failed to start helper: %v", err)
+	}
+	defer func() {
+		_ = helper.Process.Kill()
+		_ = helper.Wait()
+	}()
+	scanner := bufio.NewScanner(stdout)
+	if !scanner.Scan() || scanner.Text() != "locked" {
+		t.Fatalf("Expected the helper to lock the store, got %q", scanner.Text())
+	}
+
+	// Shared locks are held together.
+	unlock, err := s.LockShared()
+	if err != nil {
+		t.Fatalf("LockShared failed: %v", err)
+	}
+	unlock()
+
+	// An exclusive lock waits for the shared lock of the other process.
+	locked := make(chan func())
+	go func() {
+		unlock, err := s.LockExclusive()
+		if err != nil {
+			t.Errorf("LockExclusive failed: %v", err)
+			close(locked)
+			return
+		}
+		locked <- unlock
+	}()
+	select {
+	case <-locked:
+		t.Fatal("Expected the exclusive lock to wait for the other process")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Locks held by processes that die without releasing them aren't left
+	// stale.
+	if err := helper.Process.Kill(); err != nil {
+		t.Fatalf("Failed to kill helper: %v", err)
+	}
+	select {
+	case unlock, ok := <-locked:
+		if ok {
+			unlock()
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the exclusive lock to be acquired once the other process died")
+	}
+}
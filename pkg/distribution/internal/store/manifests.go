@@ -39,6 +39,11 @@ func (s *LocalStore) WriteManifest(hash v1.Hash, raw []byte) error {
 	}
 
 	// Add the manifest to the index
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	idx, err := s.readIndex()
 	if err != nil {
 		return fmt.Errorf("reading models: %w", err)
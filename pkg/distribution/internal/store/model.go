@@ -126,6 +126,10 @@ func (m *Model) MMPROJPath() (string, error) {
 	return mdpartial.MMPROJPath(m)
 }
 
+func (m *Model) AdapterPath() (string, error) {
+	return mdpartial.AdapterPath(m)
+}
+
 func (m *Model) ChatTemplatePath() (string, error) {
 	return mdpartial.ChatTemplatePath(m)
 }
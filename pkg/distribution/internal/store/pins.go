@@ -0,0 +1,135 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/name"
+
+	"github.com/docker/model-runner/pkg/distribution/registry"
+)
+
+// Pins records the manifest digest that each tag resolved to the first time
+// it was pulled.
+type Pins struct {
+	Tags map[string]string `json:"tags"`
+}
+
+// pinsPath returns the path to the pins file
+func (s *LocalStore) pinsPath() string {
+	return filepath.Join(s.rootPath, "pins.json")
+}
+
+// readPins reads the pins from the pins file
+func (s *LocalStore) readPins() (Pins, error) {
+	data, err := os.ReadFile(s.pinsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return Pins{Tags: map[string]string{}}, nil
+	} else if err != nil {
+		return Pins{}, fmt.Errorf("reading pins file: %w", err)
+	}
+
+	var pins Pins
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return Pins{}, fmt.Errorf("unmarshaling pins: %w", err)
+	}
+	if pins.Tags == nil {
+		pins.Tags = map[string]string{}
+	}
+	return pins, nil
+}
+
+// writePins writes the pins to the pins file
+func (s *LocalStore) writePins(pins Pins) error {
+	data, err := json.MarshalIndent(pins, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling pins: %w", err)
+	}
+	if err := writeFile(s.pinsPath(), data); err != nil {
+		return fmt.Errorf("writing pins file: %w", err)
+	}
+	return nil
+}
+
+// normalizePinTag returns the fully qualified name of a tag.
+func normalizePinTag(tag string) (string, error) {
+	tagRef, err := name.NewTag(tag, registry.GetDefaultRegistryOptions()...)
+	if err != nil {
+		return "", fmt.Errorf("invalid tag: %w", err)
+	}
+	return tagRef.Name(), nil
+}
+
+// PinnedDigest returns the digest pinned for a tag, if any.
+func (s *LocalStore) PinnedDigest(tag string) (string, bool, error) {
+	key, err := normalizePinTag(tag)
+	if err != nil {
+		return "", false, err
+	}
+	pins, err := s.readPins()
+	if err != nil {
+		return "", false, err
+	}
+	digest, ok := pins.Tags[key]
+	return digest, ok, nil
+}
+
+// PinDigest pins a tag to a digest, replacing any existing pin.
+func (s *LocalStore) PinDigest(tag string, digest string) error {
+	key, err := normalizePinTag(tag)
+	if err != nil {
+		return err
+	}
+	return s.updatePins(func(pins *Pins) bool {
+		if pins.Tags[key] == digest {
+			return false
+		}
+		pins.Tags[key] = digest
+		return true
+	})
+}
+
+// UnpinDigest removes the pin of a tag, so that the next pull pins the digest
+// that the tag resolves to then. It returns the removed digest and whether the
+// tag was pinned.
+func (s *LocalStore) UnpinDigest(tag string) (string, bool, error) {
+	key, err := normalizePinTag(tag)
+	if err != nil {
+		return "", false, err
+	}
+	var digest string
+	var ok bool
+	err = s.updatePins(func(pins *Pins) bool {
+		if digest, ok = pins.Tags[key]; ok {
+			delete(pins.Tags, key)
+		}
+		return ok
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return digest, ok, nil
+}
+
+// updatePins applies an update to the pins, writing them if update returns
+// true. Updates are serialized with the index lock, so that pins recorded
+// concurrently, by other pulls or other processes sharing the store, aren't
+// lost.
+func (s *LocalStore) updatePins(update func(*Pins) bool) error {
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	pins, err := s.readPins()
+	if err != nil {
+		return err
+	}
+	if !update(&pins) {
+		return nil
+	}
+	return s.writePins(pins)
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPinDigest(t *testing.T) {
+	s, err := New(Options{RootPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	if _, ok, err := s.PinnedDigest("ai/model:latest"); err != nil || ok {
+		t.Fatalf("Expected no pin, got ok=%v err=%v", ok, err)
+	}
+
+	if err := s.PinDigest("ai/model", "sha256:aaaa"); err != nil {
+		t.Fatalf("Failed to pin digest: %v", err)
+	}
+
+	// The pin should be found regardless of how the tag is written.
+	for _, tag := range []string{"ai/model", "ai/model:latest", "index.docker.io/ai/model:latest"} {
+		digest, ok, err := s.PinnedDigest(tag)
+		if err != nil {
+			t.Fatalf("Failed to read pin for %q: %v", tag, err)
+		}
+		if !ok || digest != "sha256:aaaa" {
+			t.Errorf("Expected pin sha256:aaaa for %q, got %q (ok=%v)", tag, digest, ok)
+		}
+	}
+
+	if err := s.PinDigest("ai/model", "sha256:bbbb"); err != nil {
+		t.Fatalf("Failed to re-pin digest: %v", err)
+	}
+	if digest, _, _ := s.PinnedDigest("ai/model"); digest != "sha256:bbbb" {
+		t.Errorf("Expected pin sha256:bbbb, got %q", digest)
+	}
+
+	if digest, ok, err := s.UnpinDigest("ai/model:latest"); err != nil || !ok || digest != "sha256:bbbb" {
+		t.Errorf("Expected pin sha256:bbbb to be removed, got %q (ok=%v err=%v)", digest, ok, err)
+	}
+	if _, ok, err := s.PinnedDigest("ai/model"); err != nil || ok {
+		t.Errorf("Expected no pin after unpinning, got ok=%v err=%v", ok, err)
+	}
+	if _, ok, err := s.UnpinDigest("ai/model"); err != nil || ok {
+		t.Errorf("Expected nothing to unpin, got ok=%v err=%v", ok, err)
+	}
+
+	if _, _, err := s.PinnedDigest("Invalid Tag"); err == nil {
+		t.Errorf("Expected error for invalid tag")
+	}
+}
+
+func TestConcurrentPinDigest(t *testing.T) {
+	s, err := New(Options{RootPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// Tags pinned concurrently aren't lost.
+	const tags = 20
+	var wg sync.WaitGroup
+	for i := range tags {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.PinDigest(fmt.Sprintf("ai/model:v%d", i), fmt.Sprintf("sha256:%d", i)); err != nil {
+				t.Errorf("Failed to pin digest: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	pins, err := s.readPins()
+	if err != nil {
+		t.Fatalf("Failed to read pins: %v", err)
+	}
+	if len(pins.Tags) != tags {
+		t.Errorf("Expected %d pins, got %+v", tags, pins.Tags)
+	}
+}
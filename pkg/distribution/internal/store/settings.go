@@ -0,0 +1,153 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+const (
+	settingsDir = "settings"
+	// settingsFile is the name of the file that settings are stored in.
+	settingsFile = "settings.json"
+	// settingsChatTemplateFile is the name of the file that the Jinja chat
+	// template of the settings is written to, so that backends can read it.
+	settingsChatTemplateFile = "template.jinja"
+)
+
+// settingsPath returns the path to the settings directory for the given hash.
+// It's kept apart from the bundle, which is recreated from the model's
+// artifact whenever it's found to be invalid.
+func (s *LocalStore) settingsPath(hash v1.Hash) string {
+	return filepath.Join(s.rootPath, settingsDir, hash.Algorithm, hash.Hex)
+}
+
+// modelHash returns the ID of the model with the specified reference.
+func (s *LocalStore) modelHash(reference string) (v1.Hash, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("reading models index: %w", err)
+	}
+	entry, _, ok := index.Find(reference)
+	if !ok {
+		return v1.Hash{}, ErrModelNotFound
+	}
+	return v1.NewHash(entry.ID)
+}
+
+// Settings returns the settings stored with the model with the specified
+// reference, which are empty if none are.
+func (s *LocalStore) Settings(reference string) (types.ModelSettings, error) {
+	hash, err := s.modelHash(reference)
+	if err != nil {
+		return types.ModelSettings{}, err
+	}
+	return s.readSettings(hash)
+}
+
+// readSettings reads the settings stored with the model with the specified
+// ID.
+func (s *LocalStore) readSettings(hash v1.Hash) (types.ModelSettings, error) {
+	data, err := os.ReadFile(filepath.Join(s.settingsPath(hash), settingsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return types.ModelSettings{}, nil
+	} else if err != nil {
+		return types.ModelSettings{}, fmt.Errorf("reading settings file: %w", err)
+	}
+
+	var settings types.ModelSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return types.ModelSettings{}, fmt.Errorf("unmarshaling settings: %w", err)
+	}
+	return settings, nil
+}
+
+// SetSettings replaces the settings stored with the model with the specified
+// reference. Empty settings remove them.
+func (s *LocalStore) SetSettings(reference string, settings types.ModelSettings) error {
+	hash, err := s.modelHash(reference)
+	if err != nil {
+		return err
+	}
+	if settings.IsZero() {
+		return s.removeSettings(hash)
+	}
+
+	path := s.settingsPath(hash)
+	templatePath := filepath.Join(path, settingsChatTemplateFile)
+	if settings.ChatTemplate != "" && settings.ChatTemplateFormat != types.ChatTemplateFormatGo {
+		if err := writeFile(templatePath, []byte(settings.ChatTemplate)); err != nil {
+			return fmt.Errorf("writing chat template file: %w", err)
+		}
+	} else if err := os.Remove(templatePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing chat template file: %w", err)
+	}
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling settings: %w", err)
+	}
+	if err := writeFile(filepath.Join(path, settingsFile), data); err != nil {
+		return fmt.Errorf("writing settings file: %w", err)
+	}
+	return nil
+}
+
+// removeSettings removes the settings stored with a model, if any.
+func (s *LocalStore) removeSettings(hash v1.Hash) error {
+	return os.RemoveAll(s.settingsPath(hash))
+}
+
+// withSettings applies the settings stored with the model with the specified
+// ID to its bundle. Go chat templates aren't applied, since backends can't
+// read them.
+func (s *LocalStore) withSettings(hash v1.Hash, bdl types.ModelBundle) (types.ModelBundle, error) {
+	settings, err := s.readSettings(hash)
+	if err != nil {
+		return nil, err
+	}
+	jinja := settings.ChatTemplate != "" && settings.ChatTemplateFormat != types.ChatTemplateFormatGo
+	if settings.ContextSize == nil && !jinja {
+		return bdl, nil
+	}
+	sb := &settingsBundle{ModelBundle: bdl, contextSize: settings.ContextSize}
+	if jinja {
+		sb.chatTemplatePath = filepath.Join(s.settingsPath(hash), settingsChatTemplateFile)
+	}
+	return sb, nil
+}
+
+// settingsBundle is a bundle whose context size and chat template are
+// overridden by the settings stored with its model.
+type settingsBundle struct {
+	types.ModelBundle
+	// contextSize overrides the context size of the bundle, if set.
+	contextSize *uint64
+	// chatTemplatePath overrides the chat template of the bundle, if set.
+	chatTemplatePath string
+}
+
+// ChatTemplatePath returns the path to the chat template of the settings, or
+// to the bundle's if they don't override it.
+func (b *settingsBundle) ChatTemplatePath() string {
+	if b.chatTemplatePath != "" {
+		return b.chatTemplatePath
+	}
+	return b.ModelBundle.ChatTemplatePath()
+}
+
+// RuntimeConfig returns the bundle's config, with the context size of the
+// settings, if set.
+func (b *settingsBundle) RuntimeConfig() types.Config {
+	config := b.ModelBundle.RuntimeConfig()
+	if b.contextSize != nil {
+		config.ContextSize = b.contextSize
+	}
+	return config
+}
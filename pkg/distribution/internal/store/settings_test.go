@@ -0,0 +1,102 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+// stubBundle is a bundle with a chat template and a context size.
+type stubBundle struct {
+	types.ModelBundle
+}
+
+func (stubBundle) ChatTemplatePath() string {
+	return "/bundle/model/template.jinja"
+}
+
+func (stubBundle) RuntimeConfig() types.Config {
+	contextSize := uint64(4096)
+	return types.Config{Format: types.FormatGGUF, ContextSize: &contextSize}
+}
+
+func TestSettings(t *testing.T) {
+	s, err := New(Options{RootPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	id := "sha256:" + strings.Repeat("a", 64)
+	hash, err := v1.NewHash(id)
+	if err != nil {
+		t.Fatalf("Failed to parse hash: %v", err)
+	}
+	if err := s.writeIndex(Index{Models: []IndexEntry{{ID: id, Tags: []string{"ai/model:latest"}}}}); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+
+	// Models have no settings until they're set.
+	if settings, err := s.Settings("ai/model:latest"); err != nil || !settings.IsZero() {
+		t.Errorf("Expected no settings, got %+v (error %v)", settings, err)
+	}
+	if bdl, err := s.withSettings(hash, stubBundle{}); err != nil || bdl != (stubBundle{}) {
+		t.Errorf("Expected the bundle to be unchanged, got %+v (error %v)", bdl, err)
+	}
+	if err := s.SetSettings("ai/missing:latest", types.ModelSettings{Backend: "vllm"}); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("Expected ErrModelNotFound, got %v", err)
+	}
+
+	contextSize := uint64(8192)
+	settings := types.ModelSettings{
+		ContextSize:  &contextSize,
+		ChatTemplate: "{{ messages }}",
+		Backend:      "vllm",
+		RuntimeFlags: []string{"--seed", "42"},
+	}
+	if err := s.SetSettings("ai/model:latest", settings); err != nil {
+		t.Fatalf("Failed to set settings: %v", err)
+	}
+	stored, err := s.Settings(id)
+	if err != nil {
+		t.Fatalf("Failed to get settings: %v", err)
+	}
+	if *stored.ContextSize != contextSize || stored.ChatTemplate != settings.ChatTemplate ||
+		stored.Backend != "vllm" || strings.Join(stored.RuntimeFlags, " ") != "--seed 42" {
+		t.Errorf("Expected settings %+v, got %+v", settings, stored)
+	}
+
+	// The settings override the context size and chat template of the bundle.
+	bdl, err := s.withSettings(hash, stubBundle{})
+	if err != nil {
+		t.Fatalf("Failed to apply settings: %v", err)
+	}
+	if config := bdl.RuntimeConfig(); *config.ContextSize != contextSize || config.Format != types.FormatGGUF {
+		t.Errorf("Expected the context size to be overridden, got %+v", config)
+	}
+	template, err := os.ReadFile(bdl.ChatTemplatePath())
+	if err != nil || string(template) != settings.ChatTemplate {
+		t.Errorf("Expected the chat template %q, got %q (error %v)", settings.ChatTemplate, template, err)
+	}
+
+	// Settings without a Jinja chat template keep the bundle's, since backends
+	// can't apply Go templates.
+	goSettings := types.ModelSettings{ChatTemplate: "{{ .Prompt }}", ChatTemplateFormat: types.ChatTemplateFormatGo}
+	if err := s.SetSettings(id, goSettings); err != nil {
+		t.Fatalf("Failed to set settings: %v", err)
+	}
+	if bdl, err := s.withSettings(hash, stubBundle{}); err != nil || bdl.ChatTemplatePath() != "/bundle/model/template.jinja" {
+		t.Errorf("Expected the bundle's chat template, got %+v (error %v)", bdl, err)
+	}
+
+	// Settings are removed along with the model.
+	if _, _, err := s.Delete(id); err != nil {
+		t.Fatalf("Failed to delete model: %v", err)
+	}
+	if _, err := os.Stat(s.settingsPath(hash)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the settings to be removed, got %v", err)
+	}
+}
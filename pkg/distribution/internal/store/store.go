@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -9,9 +10,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/name"
 	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
 
 	"github.com/docker/model-runner/pkg/distribution/internal/progress"
+	"github.com/docker/model-runner/pkg/distribution/registry"
 )
 
 const (
@@ -21,7 +24,13 @@ const (
 
 // LocalStore implements the Store interface for local storage
 type LocalStore struct {
-	rootPath string
+	rootPath     string
+	minFreeSpace uint64
+
+	// indexMu serializes updates of the index, which read, modify and
+	// write it, so that concurrent tag changes aren't lost. It's held along
+	// with the index lock file, which serializes them across processes.
+	indexMu sync.Mutex
 }
 
 // RootPath returns the root path of the store
@@ -32,12 +41,17 @@ func (s *LocalStore) RootPath() string {
 // Options represents options for creating a store
 type Options struct {
 	RootPath string
+	// MinFreeSpace is the free disk space, in bytes, that writes to the store
+	// keep in reserve. Writes pause while free space is below it. If zero, no
+	// space is reserved.
+	MinFreeSpace uint64
 }
 
 // New creates a new LocalStore
 func New(opts Options) (*LocalStore, error) {
 	store := &LocalStore{
-		rootPath: opts.RootPath,
+		rootPath:     opts.RootPath,
+		minFreeSpace: opts.MinFreeSpace,
 	}
 
 	// Initialize store if it doesn't exist
@@ -104,6 +118,11 @@ func (s *LocalStore) List() ([]IndexEntry, error) {
 
 // Delete deletes a model by reference
 func (s *LocalStore) Delete(ref string) (string, []string, error) {
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return "", nil, err
+	}
+	defer unlock()
 	idx, err := s.readIndex()
 	if err != nil {
 		return "", nil, fmt.Errorf("reading models file: %w", err)
@@ -128,6 +147,11 @@ func (s *LocalStore) Delete(ref string) (string, []string, error) {
 		fmt.Printf("Warning: failed to remove bundle %q: %v\n", digest, err)
 	}
 
+	// Remove settings if any exist
+	if err := s.removeSettings(digest); err != nil {
+		fmt.Printf("Warning: failed to remove settings %q: %v\n", digest, err)
+	}
+
 	// Before deleting blobs, check if they are referenced by other models
 	blobRefs := make(map[string]int)
 	for _, m := range idx.Models {
@@ -157,11 +181,20 @@ func (s *LocalStore) Delete(ref string) (string, []string, error) {
 
 	idx = idx.Remove(model.ID)
 
+	if err := s.removeUsage(model.ID); err != nil {
+		fmt.Printf("Warning: failed to remove usage of %q: %v\n", model.ID, err)
+	}
+
 	return model.ID, model.Tags, s.writeIndex(idx)
 }
 
 // AddTags adds tags to an existing model
 func (s *LocalStore) AddTags(ref string, newTags []string) error {
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	index, err := s.readIndex()
 	if err != nil {
 		return fmt.Errorf("reading models file: %w", err)
@@ -178,6 +211,11 @@ func (s *LocalStore) AddTags(ref string, newTags []string) error {
 
 // RemoveTags removes tags from models
 func (s *LocalStore) RemoveTags(tags []string) ([]string, error) {
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 	index, err := s.readIndex()
 	if err != nil {
 		return nil, fmt.Errorf("reading modelss index: %w", err)
@@ -198,6 +236,36 @@ func (s *LocalStore) RemoveTags(tags []string) ([]string, error) {
 	return tagRefs, s.writeIndex(index)
 }
 
+// RemoveTag removes a tag from the model that it points to, and returns the
+// model's ID. It returns ErrLastTag if the tag is the model's only tag, since
+// the model could then only be referenced by its ID.
+func (s *LocalStore) RemoveTag(tag string) (string, error) {
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	index, err := s.readIndex()
+	if err != nil {
+		return "", fmt.Errorf("reading models index: %w", err)
+	}
+	tagRef, err := name.NewTag(tag, registry.GetDefaultRegistryOptions()...)
+	if err != nil {
+		return "", fmt.Errorf("invalid tag: %w", err)
+	}
+	for i, entry := range index.Models {
+		if !entry.hasTag(tagRef) {
+			continue
+		}
+		if len(entry.Tags) == 1 {
+			return "", ErrLastTag
+		}
+		index.Models[i] = entry.UnTag(tagRef)
+		return entry.ID, s.writeIndex(index)
+	}
+	return "", ErrModelNotFound
+}
+
 // Version returns the store version
 func (s *LocalStore) Version() string {
 	layout, err := s.readLayout()
@@ -222,7 +290,13 @@ func (sw *syncWriter) Write(p []byte) (n int, err error) {
 }
 
 // Write writes a model to the store
-func (s *LocalStore) Write(mdl v1.Image, tags []string, w io.Writer) (err error) {
+func (s *LocalStore) Write(mdl v1.Image, tags []string, w io.Writer) error {
+	return s.WriteContext(context.Background(), mdl, tags, w)
+}
+
+// WriteContext writes a model to the store like Write, failing if ctx is done
+// while the write is paused for lack of disk space.
+func (s *LocalStore) WriteContext(ctx context.Context, mdl v1.Image, tags []string, w io.Writer) (err error) {
 	initialIndex, err := s.readIndex()
 	if err != nil {
 		return fmt.Errorf("reading models index: %w", err)
@@ -274,12 +348,28 @@ func (s *LocalStore) Write(mdl v1.Image, tags []string, w io.Writer) (err error)
 		return fmt.Errorf("getting layers: %w", err)
 	}
 
+	// Layers that are already in the store, such as base layers shared with
+	// other models, aren't transferred again, so only the remaining layers
+	// count towards the size reported in progress updates.
+	type cachedLayer struct {
+		diffID v1.Hash
+		size   int64
+	}
 	imageSize := int64(0)
+	var cached []cachedLayer
 	for _, layer := range layers {
 		size, err := layer.Size()
 		if err != nil {
 			return fmt.Errorf("getting layer size: %w", err)
 		}
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return fmt.Errorf("getting layer diffID: %w", err)
+		}
+		if s.HasBlob(diffID) {
+			cached = append(cached, cachedLayer{diffID: diffID, size: size})
+			continue
+		}
 		imageSize += size
 	}
 
@@ -287,6 +377,11 @@ func (s *LocalStore) Write(mdl v1.Image, tags []string, w io.Writer) (err error)
 	var safeWriter io.Writer
 	if w != nil {
 		safeWriter = &syncWriter{w: w}
+		for _, layer := range cached {
+			if err := progress.WriteCached(safeWriter, uint64(imageSize), uint64(layer.size), layer.diffID.String()); err != nil {
+				fmt.Printf("Warning: failed to write progress: %v\n", err)
+			}
+		}
 	}
 
 	// Pull all layers in parallel
@@ -311,7 +406,13 @@ func (s *LocalStore) Write(mdl v1.Image, tags []string, w io.Writer) (err error)
 				progressChan = pr.Updates()
 			}
 
-			created, diffID, err := s.writeLayer(l, progressChan)
+			created, diffID, err := s.writeLayer(ctx, l, progressChan, func(msg string) {
+				if safeWriter == nil {
+					fmt.Printf("Warning: %s\n", msg)
+				} else if err := progress.WriteWarning(safeWriter, msg); err != nil {
+					fmt.Printf("Warning: failed to write progress: %v\n", err)
+				}
+			})
 
 			if progressChan != nil {
 				close(progressChan)
@@ -403,6 +504,9 @@ func (s *LocalStore) Write(mdl v1.Image, tags []string, w io.Writer) (err error)
 	if err := s.AddTags(digest.String(), tags); err != nil {
 		return fmt.Errorf("adding tags: %w", err)
 	}
+	if err := s.releaseQuarantine(digest.String()); err != nil {
+		fmt.Printf("Warning: failed to release model %q from quarantine: %v\n", digest, err)
+	}
 	success = true
 	return nil
 }
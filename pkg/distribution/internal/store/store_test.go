@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/docker/model-runner/pkg/distribution/internal/gguf"
@@ -831,6 +832,73 @@ func newTestModelWithMultimodalProjector(t *testing.T) types.ModelArtifact {
 }
 
 // TestWriteLightweight tests the WriteLightweight method
+func TestRemoveTag(t *testing.T) {
+	s, err := store.New(store.Options{RootPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	model := newTestModel(t)
+	digest, err := model.Digest()
+	if err != nil {
+		t.Fatalf("Digest failed: %v", err)
+	}
+	if err := s.Write(model, []string{"ai/model:latest", "ai/model:prod"}, nil); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	id, err := s.RemoveTag("ai/model:prod")
+	if err != nil {
+		t.Fatalf("RemoveTag failed: %v", err)
+	}
+	if id != digest.String() {
+		t.Errorf("Expected ID %s of the untagged model, got %s", digest, id)
+	}
+	if _, err := s.Read("ai/model:prod"); !errors.Is(err, store.ErrModelNotFound) {
+		t.Errorf("Expected removed tag not to resolve, got %v", err)
+	}
+	if _, err := s.Read("ai/model:latest"); err != nil {
+		t.Errorf("Expected remaining tag to resolve, got %v", err)
+	}
+
+	if _, err := s.RemoveTag("ai/model:latest"); !errors.Is(err, store.ErrLastTag) {
+		t.Errorf("Expected ErrLastTag when removing the only tag, got %v", err)
+	}
+	if _, err := s.RemoveTag("ai/missing:latest"); !errors.Is(err, store.ErrModelNotFound) {
+		t.Errorf("Expected ErrModelNotFound for an unknown tag, got %v", err)
+	}
+}
+
+func TestConcurrentTagging(t *testing.T) {
+	s, err := store.New(store.Options{RootPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := s.Write(newTestModel(t), []string{"ai/model:latest"}, nil); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Tags added concurrently aren't lost.
+	const tags = 20
+	var wg sync.WaitGroup
+	for i := range tags {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.AddTags("ai/model:latest", []string{fmt.Sprintf("ai/model:v%d", i)}); err != nil {
+				t.Errorf("AddTags failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	models, err := s.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(models) != 1 || len(models[0].Tags) != tags+1 {
+		t.Errorf("Expected one model with %d tags, got %+v", tags+1, models)
+	}
+}
+
 func TestResetStore(t *testing.T) {
 	tests := []struct {
 		name        string
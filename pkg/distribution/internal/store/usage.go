@@ -0,0 +1,203 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+)
+
+// Usage records when each model was last used, and which models are pinned
+// so that they're never evicted to stay within the store quota.
+type Usage struct {
+	Models map[string]ModelUsage `json:"models"`
+}
+
+// ModelUsage is the usage of a single model.
+type ModelUsage struct {
+	// LastUsed is when the model was last pulled or loaded.
+	LastUsed time.Time `json:"last_used,omitzero"`
+	// Pinned is true if the model is never evicted.
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// EvictionCandidate is a model that can be evicted to free space.
+type EvictionCandidate struct {
+	// ID is the model's ID.
+	ID string
+	// Tags are the model's tags.
+	Tags []string
+	// LastUsed is when the model was last used. For models whose use hasn't
+	// been recorded, it's when the model was written to the store.
+	LastUsed time.Time
+}
+
+// usagePath returns the path to the usage file
+func (s *LocalStore) usagePath() string {
+	return filepath.Join(s.rootPath, "usage.json")
+}
+
+// readUsage reads the usage from the usage file
+func (s *LocalStore) readUsage() (Usage, error) {
+	data, err := os.ReadFile(s.usagePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return Usage{Models: map[string]ModelUsage{}}, nil
+	} else if err != nil {
+		return Usage{}, fmt.Errorf("reading usage file: %w", err)
+	}
+
+	var usage Usage
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return Usage{}, fmt.Errorf("unmarshaling usage: %w", err)
+	}
+	if usage.Models == nil {
+		usage.Models = map[string]ModelUsage{}
+	}
+	return usage, nil
+}
+
+// writeUsage writes the usage to the usage file
+func (s *LocalStore) writeUsage(usage Usage) error {
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling usage: %w", err)
+	}
+	if err := writeFile(s.usagePath(), data); err != nil {
+		return fmt.Errorf("writing usage file: %w", err)
+	}
+	return nil
+}
+
+// updateUsage applies an update to the usage of the model with the specified
+// reference. Updates are serialized with the index lock, so that concurrent
+// updates, such as a pin and a use recorded by another process, aren't lost.
+func (s *LocalStore) updateUsage(reference string, update func(*ModelUsage)) error {
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	index, err := s.readIndex()
+	if err != nil {
+		return fmt.Errorf("reading models index: %w", err)
+	}
+	entry, _, ok := index.Find(reference)
+	if !ok {
+		return ErrModelNotFound
+	}
+	usage, err := s.readUsage()
+	if err != nil {
+		return err
+	}
+	modelUsage := usage.Models[entry.ID]
+	update(&modelUsage)
+	usage.Models[entry.ID] = modelUsage
+	return s.writeUsage(usage)
+}
+
+// MarkUsed records that a model was used at the specified time.
+func (s *LocalStore) MarkUsed(reference string, at time.Time) error {
+	return s.updateUsage(reference, func(u *ModelUsage) {
+		if at.After(u.LastUsed) {
+			u.LastUsed = at
+		}
+	})
+}
+
+// SetPinned pins or unpins a model. Pinned models are never evicted.
+func (s *LocalStore) SetPinned(reference string, pinned bool) error {
+	return s.updateUsage(reference, func(u *ModelUsage) {
+		u.Pinned = pinned
+	})
+}
+
+// ModelUsage returns the usage of the model with the specified ID.
+func (s *LocalStore) ModelUsage(id string) (ModelUsage, error) {
+	usage, err := s.readUsage()
+	if err != nil {
+		return ModelUsage{}, err
+	}
+	return usage.Models[id], nil
+}
+
+// removeUsage removes the usage of a deleted model.
+func (s *LocalStore) removeUsage(id string) error {
+	usage, err := s.readUsage()
+	if err != nil {
+		return err
+	}
+	if _, ok := usage.Models[id]; !ok {
+		return nil
+	}
+	delete(usage.Models, id)
+	return s.writeUsage(usage)
+}
+
+// Size returns the disk space used by the blobs of the models in the store,
+// counting blobs shared between models once.
+func (s *LocalStore) Size() (int64, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return 0, fmt.Errorf("reading models index: %w", err)
+	}
+	var size int64
+	seen := make(map[string]bool)
+	for _, entry := range index.Models {
+		for _, file := range entry.Files {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+			hash, err := v1.NewHash(file)
+			if err != nil {
+				continue
+			}
+			path, err := s.blobPath(hash)
+			if err != nil {
+				continue
+			}
+			if info, err := os.Stat(path); err == nil {
+				size += info.Size()
+			}
+		}
+	}
+	return size, nil
+}
+
+// EvictionCandidates returns the models that aren't pinned, least recently
+// used first.
+func (s *LocalStore) EvictionCandidates() ([]EvictionCandidate, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return nil, fmt.Errorf("reading models index: %w", err)
+	}
+	usage, err := s.readUsage()
+	if err != nil {
+		return nil, err
+	}
+	var candidates []EvictionCandidate
+	for _, entry := range index.Models {
+		modelUsage := usage.Models[entry.ID]
+		if modelUsage.Pinned {
+			continue
+		}
+		lastUsed := modelUsage.LastUsed
+		if lastUsed.IsZero() {
+			if hash, err := v1.NewHash(entry.ID); err == nil {
+				if info, err := os.Stat(s.manifestPath(hash)); err == nil {
+					lastUsed = info.ModTime()
+				}
+			}
+		}
+		candidates = append(candidates, EvictionCandidate{ID: entry.ID, Tags: entry.Tags, LastUsed: lastUsed})
+	}
+	slices.SortStableFunc(candidates, func(a, b EvictionCandidate) int {
+		return a.LastUsed.Compare(b.LastUsed)
+	})
+	return candidates, nil
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+)
+
+func TestUsage(t *testing.T) {
+	s, err := New(Options{RootPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	digest := func(c string) string { return "sha256:" + strings.Repeat(c, 64) }
+	writeBlob := func(file string, size int) {
+		hash, err := v1.NewHash(file)
+		if err != nil {
+			t.Fatalf("Failed to parse hash: %v", err)
+		}
+		path, err := s.blobPath(hash)
+		if err != nil {
+			t.Fatalf("Failed to get blob path: %v", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create blob directory: %v", err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatalf("Failed to write blob: %v", err)
+		}
+	}
+
+	// The models share a blob, which only counts towards the store size once.
+	writeBlob(digest("1"), 100)
+	writeBlob(digest("2"), 200)
+	writeBlob(digest("3"), 10)
+	if err := s.writeIndex(Index{Models: []IndexEntry{
+		{ID: digest("a"), Tags: []string{"ai/old:latest"}, Files: []string{digest("1"), digest("3")}},
+		{ID: digest("b"), Tags: []string{"ai/new:latest"}, Files: []string{digest("2"), digest("3")}},
+	}}); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+	if size, err := s.Size(); err != nil || size != 310 {
+		t.Errorf("Expected store size 310, got %d (error %v)", size, err)
+	}
+
+	now := time.Now()
+	if err := s.MarkUsed("ai/new", now); err != nil {
+		t.Fatalf("Failed to mark model used: %v", err)
+	}
+	if err := s.MarkUsed(digest("a"), now.Add(-time.Hour)); err != nil {
+		t.Fatalf("Failed to mark model used: %v", err)
+	}
+	// Uses are only recorded if they're more recent.
+	if err := s.MarkUsed("ai/new", now.Add(-2*time.Hour)); err != nil {
+		t.Fatalf("Failed to mark model used: %v", err)
+	}
+	if err := s.MarkUsed("ai/missing", now); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("Expected ErrModelNotFound, got %v", err)
+	}
+
+	candidates, err := s.EvictionCandidates()
+	if err != nil {
+		t.Fatalf("Failed to list eviction candidates: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].ID != digest("a") || candidates[1].ID != digest("b") {
+		t.Fatalf("Expected least recently used model first, got %+v", candidates)
+	}
+	if !candidates[1].LastUsed.Equal(now) {
+		t.Errorf("Expected last use at %v, got %v", now, candidates[1].LastUsed)
+	}
+
+	// Pinned models aren't candidates for eviction.
+	if err := s.SetPinned("ai/old", true); err != nil {
+		t.Fatalf("Failed to pin model: %v", err)
+	}
+	if candidates, _ := s.EvictionCandidates(); len(candidates) != 1 || candidates[0].ID != digest("b") {
+		t.Errorf("Expected only the unpinned model, got %+v", candidates)
+	}
+	if usage, err := s.ModelUsage(digest("a")); err != nil || !usage.Pinned {
+		t.Errorf("Expected model to be pinned, got %+v (error %v)", usage, err)
+	}
+	if err := s.SetPinned("ai/old", false); err != nil {
+		t.Fatalf("Failed to unpin model: %v", err)
+	}
+	if candidates, _ := s.EvictionCandidates(); len(candidates) != 2 {
+		t.Errorf("Expected both models after unpinning, got %+v", candidates)
+	}
+
+	// Deleting a model removes its usage.
+	if _, _, err := s.Delete("ai/new"); err != nil {
+		t.Fatalf("Failed to delete model: %v", err)
+	}
+	if usage, err := s.readUsage(); err != nil || len(usage.Models) != 1 {
+		t.Errorf("Expected the deleted model's usage to be removed, got %+v (error %v)", usage, err)
+	}
+	if size, err := s.Size(); err != nil || size != 110 {
+		t.Errorf("Expected store size 110, got %d (error %v)", size, err)
+	}
+}
+
+func TestConcurrentUsage(t *testing.T) {
+	s, err := New(Options{RootPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	id := "sha256:" + strings.Repeat("a", 64)
+	if err := s.writeIndex(Index{Models: []IndexEntry{{ID: id, Tags: []string{"ai/model:latest"}}}}); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+
+	// Pinning a model while its uses are recorded doesn't lose the pin.
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if i == 10 {
+				err = s.SetPinned("ai/model", true)
+			} else {
+				err = s.MarkUsed("ai/model", time.Now())
+			}
+			if err != nil {
+				t.Errorf("Failed to update usage: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if usage, err := s.ModelUsage(id); err != nil || !usage.Pinned || usage.LastUsed.IsZero() {
+		t.Errorf("Expected the model to be pinned and used, got %+v (error %v)", usage, err)
+	}
+}
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+)
+
+// Problems of damaged blobs.
+const (
+	// BlobMissing indicates that a blob isn't in the store.
+	BlobMissing = "missing"
+	// BlobTruncated indicates that a blob is smaller than recorded in its
+	// model's manifest, such as after an interrupted write.
+	BlobTruncated = "truncated"
+	// BlobCorrupt indicates that the content of a blob doesn't match its
+	// digest.
+	BlobCorrupt = "corrupt"
+)
+
+// DamagedBlob is a blob, or the manifest, of a model whose content doesn't
+// match its digest.
+type DamagedBlob struct {
+	// Digest is the digest of the blob.
+	Digest string `json:"digest"`
+	// Problem is BlobMissing, BlobTruncated, or BlobCorrupt.
+	Problem string `json:"problem"`
+	// Size is the size of the blob recorded in the manifest, if known.
+	Size int64 `json:"size"`
+	// ActualSize is the size of the blob in the store.
+	ActualSize int64 `json:"actual-size"`
+}
+
+// DamagedModel is a model with damaged blobs.
+type DamagedModel struct {
+	// ID is the ID of the model.
+	ID string `json:"id"`
+	// Tags are the tags of the model.
+	Tags []string `json:"tags"`
+	// Blobs are the damaged blobs of the model.
+	Blobs []DamagedBlob `json:"blobs"`
+	// Repaired indicates whether the damaged blobs were pulled again.
+	Repaired bool `json:"repaired"`
+	// Error describes why the model couldn't be repaired, if it was to be.
+	Error string `json:"error,omitempty"`
+}
+
+// Verification is the result of verifying the store.
+type Verification struct {
+	// Models is the number of models verified.
+	Models int `json:"models"`
+	// Blobs is the number of distinct blobs verified.
+	Blobs int `json:"blobs"`
+	// Size is the number of bytes hashed.
+	Size int64 `json:"size"`
+	// Damaged are the models with damaged blobs.
+	Damaged []DamagedModel `json:"damaged"`
+}
+
+// Verify re-hashes the manifest and blobs of every model in the index against
+// their digests, and reports the models whose content is missing, truncated,
+// or corrupt. Unlike Scan, it reads all of the content of the store, so it's
+// only run on request. Callers must ensure that blobs aren't removed
+// concurrently.
+func (s *LocalStore) Verify() (Verification, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return Verification{}, fmt.Errorf("reading models index: %w", err)
+	}
+
+	verification := Verification{Damaged: []DamagedModel{}}
+	// checked maps the digests of the blobs that were already verified, which
+	// models may share, to their damage if any.
+	checked := make(map[string]*DamagedBlob)
+	for _, entry := range index.Models {
+		verification.Models++
+		model := DamagedModel{ID: entry.ID, Tags: entry.Tags}
+		descriptors, damage := s.verifyManifest(entry.ID)
+		if damage != nil {
+			model.Blobs = append(model.Blobs, *damage)
+		}
+		for _, desc := range descriptors {
+			damage, ok := checked[desc.Digest.String()]
+			if !ok {
+				var size int64
+				damage, size, err = s.verifyBlob(desc)
+				if err != nil {
+					return Verification{}, err
+				}
+				checked[desc.Digest.String()] = damage
+				verification.Blobs++
+				verification.Size += size
+			}
+			if damage != nil {
+				model.Blobs = append(model.Blobs, *damage)
+			}
+		}
+		if len(model.Blobs) > 0 {
+			verification.Damaged = append(verification.Damaged, model)
+		}
+	}
+	return verification, nil
+}
+
+// verifyManifest verifies the manifest of a model against its ID, and returns
+// the descriptors of its config and layers if it's intact.
+func (s *LocalStore) verifyManifest(id string) ([]v1.Descriptor, *DamagedBlob) {
+	digest, err := v1.NewHash(id)
+	if err != nil {
+		return nil, &DamagedBlob{Digest: id, Problem: BlobCorrupt}
+	}
+	raw, err := os.ReadFile(s.manifestPath(digest))
+	if err != nil {
+		return nil, &DamagedBlob{Digest: id, Problem: BlobMissing}
+	}
+	actual, _, err := v1.SHA256(bytes.NewReader(raw))
+	if err != nil || actual != digest {
+		return nil, &DamagedBlob{Digest: id, Problem: BlobCorrupt, ActualSize: int64(len(raw))}
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, &DamagedBlob{Digest: id, Problem: BlobCorrupt, ActualSize: int64(len(raw))}
+	}
+	return append([]v1.Descriptor{manifest.Config}, manifest.Layers...), nil
+}
+
+// verifyBlob verifies a blob against its descriptor. It returns the damage of
+// the blob, if any, and the number of bytes hashed.
+func (s *LocalStore) verifyBlob(desc v1.Descriptor) (*DamagedBlob, int64, error) {
+	damage := &DamagedBlob{Digest: desc.Digest.String(), Size: desc.Size}
+	path, err := s.blobPath(desc.Digest)
+	if err != nil {
+		damage.Problem = BlobCorrupt
+		return damage, 0, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		damage.Problem = BlobMissing
+		return damage, 0, nil
+	} else if err != nil {
+		return nil, 0, fmt.Errorf("opening blob %s: %w", desc.Digest, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("stat blob %s: %w", desc.Digest, err)
+	}
+	damage.ActualSize = info.Size()
+	if desc.Size > 0 && info.Size() < desc.Size {
+		damage.Problem = BlobTruncated
+		return damage, 0, nil
+	}
+	if desc.Size > 0 && info.Size() > desc.Size {
+		damage.Problem = BlobCorrupt
+		return damage, 0, nil
+	}
+
+	hasher, err := v1.Hasher(desc.Digest.Algorithm)
+	if err != nil {
+		damage.Problem = BlobCorrupt
+		return damage, 0, nil
+	}
+	size, err := io.Copy(hasher, f)
+	if err != nil {
+		return nil, size, fmt.Errorf("hashing blob %s: %w", desc.Digest, err)
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != desc.Digest.Hex {
+		damage.Problem = BlobCorrupt
+		return damage, size, nil
+	}
+	return nil, size, nil
+}
+
+// QuarantineDamaged moves damaged models from the index to the quarantine
+// list, and their damaged blobs out of the blob store, so that they aren't
+// used until they're written to the store again. Their intact blobs are kept,
+// so that writing them again only fetches the damaged ones.
+func (s *LocalStore) QuarantineDamaged(damaged []DamagedModel) error {
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	index, err := s.readIndex()
+	if err != nil {
+		return fmt.Errorf("reading models index: %w", err)
+	}
+
+	reasons := make(map[string]string)
+	corrupt := make(map[string][]v1.Hash)
+	for _, model := range damaged {
+		for _, blob := range model.Blobs {
+			if _, ok := reasons[model.ID]; !ok {
+				reasons[model.ID] = fmt.Sprintf("blob %s is %s", blob.Digest, blob.Problem)
+			}
+			// The manifest is overwritten when the model is written again.
+			if blob.Problem == BlobMissing || blob.Digest == model.ID {
+				continue
+			}
+			if hash, err := v1.NewHash(blob.Digest); err == nil {
+				corrupt[model.ID] = append(corrupt[model.ID], hash)
+			}
+		}
+	}
+	_, err = s.quarantineModels(index, reasons, corrupt)
+	return err
+}
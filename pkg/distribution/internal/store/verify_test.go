@@ -0,0 +1,132 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/internal/gguf"
+	"github.com/docker/model-runner/pkg/distribution/internal/store"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		damage  func(t *testing.T, blobPath string)
+		problem string
+	}{
+		{
+			name: "missing blob",
+			damage: func(t *testing.T, blobPath string) {
+				if err := os.Remove(blobPath); err != nil {
+					t.Fatalf("Failed to remove blob: %v", err)
+				}
+			},
+			problem: store.BlobMissing,
+		},
+		{
+			name: "truncated blob",
+			damage: func(t *testing.T, blobPath string) {
+				if err := os.Truncate(blobPath, 1); err != nil {
+					t.Fatalf("Failed to truncate blob: %v", err)
+				}
+			},
+			problem: store.BlobTruncated,
+		},
+		{
+			name: "corrupt blob",
+			damage: func(t *testing.T, blobPath string) {
+				if err := os.WriteFile(blobPath, []byte("corrupt model content"), 0644); err != nil {
+					t.Fatalf("Failed to overwrite blob: %v", err)
+				}
+			},
+			problem: store.BlobCorrupt,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storePath := t.TempDir()
+			s, err := store.New(store.Options{RootPath: storePath})
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+
+			healthy := newTestModel(t)
+			if err := s.Write(healthy, []string{"healthy:latest"}, nil); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			modelPath := filepath.Join(t.TempDir(), "damaged.gguf")
+			if err := os.WriteFile(modelPath, []byte("damaged model content"), 0644); err != nil {
+				t.Fatalf("Failed to create model file: %v", err)
+			}
+			damaged, err := gguf.NewModel(modelPath)
+			if err != nil {
+				t.Fatalf("Failed to create model: %v", err)
+			}
+			if err := s.Write(damaged, []string{"damaged:latest"}, nil); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			verification, err := s.Verify()
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if verification.Models != 2 || len(verification.Damaged) != 0 {
+				t.Fatalf("Expected 2 intact models, got %+v", verification)
+			}
+
+			blobPath := layerBlobPath(t, storePath, damaged)
+			tt.damage(t, blobPath)
+
+			verification, err = s.Verify()
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if len(verification.Damaged) != 1 {
+				t.Fatalf("Expected 1 damaged model, got %+v", verification.Damaged)
+			}
+			model := verification.Damaged[0]
+			if len(model.Tags) != 1 || model.Tags[0] != "damaged:latest" {
+				t.Errorf("Expected the damaged model to be reported, got %+v", model)
+			}
+			if len(model.Blobs) != 1 || model.Blobs[0].Problem != tt.problem {
+				t.Fatalf("Expected a %s blob, got %+v", tt.problem, model.Blobs)
+			}
+
+			if err := s.QuarantineDamaged(verification.Damaged); err != nil {
+				t.Fatalf("QuarantineDamaged failed: %v", err)
+			}
+			models, err := s.List()
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(models) != 1 || models[0].Tags[0] != "healthy:latest" {
+				t.Fatalf("Expected only the healthy model to be listed, got %+v", models)
+			}
+			if _, err := os.Stat(blobPath); !os.IsNotExist(err) {
+				t.Fatalf("Expected damaged blob to be moved out of the blob store")
+			}
+			quarantined, err := s.Quarantined()
+			if err != nil {
+				t.Fatalf("Quarantined failed: %v", err)
+			}
+			if len(quarantined) != 1 || quarantined[0].Reason == "" {
+				t.Fatalf("Expected the damaged model to be quarantined, got %+v", quarantined)
+			}
+
+			// Writing the model again repairs it
+			if err := s.Write(damaged, []string{"damaged:latest"}, nil); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			verification, err = s.Verify()
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if verification.Models != 2 || len(verification.Damaged) != 0 {
+				t.Fatalf("Expected the repaired model to be intact, got %+v", verification)
+			}
+		})
+	}
+}
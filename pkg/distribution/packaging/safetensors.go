@@ -41,7 +41,7 @@ func PackageFromDirectory(dirPath string) (safetensorsPaths []string, tempConfig
 		}
 
 		// Collect config files
-		if isConfigFile(name) {
+		if IsConfigFile(name) {
 			configFiles = append(configFiles, fullPath)
 		}
 	}
@@ -149,9 +149,9 @@ func addFileToTar(tw *tar.Writer, filePath string) error {
 	return nil
 }
 
-// isConfigFile checks if a file should be included as a config file based on its name.
+// IsConfigFile checks if a file should be included as a config file based on its name.
 // It checks for extensions listed in configExtensions and the special case of the tokenizer.model file.
-func isConfigFile(name string) bool {
+func IsConfigFile(name string) bool {
 	lower := strings.ToLower(name)
 	for _, ext := range configExtensions {
 		if strings.HasSuffix(lower, ext) {
@@ -54,6 +54,7 @@ type Client struct {
 	userAgent string
 	keychain  authn.Keychain
 	auth      authn.Authenticator
+	mirrors   Mirrors
 }
 
 type ClientOption func(*Client)
@@ -114,6 +115,7 @@ func FromClient(base *Client, opts ...ClientOption) *Client {
 		userAgent: base.userAgent,
 		keychain:  base.keychain,
 		auth:      base.auth,
+		mirrors:   base.mirrors,
 	}
 	for _, opt := range opts {
 		opt(client)
@@ -142,11 +144,23 @@ func (c *Client) Model(ctx context.Context, reference string) (types.ModelArtifa
 		authOpts = append(authOpts, remote.WithAuthFromKeychain(c.keychain))
 	}
 
+	// Try the mirrors of the registry first, in order, so that models are
+	// only pulled from the registry itself if no mirror serves them
+	for _, mirror := range c.mirrors[ref.Context().RegistryStr()] {
+		mirrorRef, err := mirrorReference(ref, mirror)
+		if err != nil {
+			continue
+		}
+		if remoteImg, err := remote.Image(mirrorRef, c.mirrorOptions(ctx, ref, mirrorRef)...); err == nil {
+			return &artifact{remoteImg}, nil
+		}
+	}
+
 	// Return the artifact at the given reference
 	remoteImg, err := remote.Image(ref, authOpts...)
 	if err != nil {
 		errStr := err.Error()
-		if strings.Contains(errStr, "UNAUTHORIZED") {
+		if isUnauthorized(err) {
 			return nil, NewRegistryError(reference, "UNAUTHORIZED", "Authentication required for this model", err)
 		}
 		if strings.Contains(errStr, "MANIFEST_UNKNOWN") {
@@ -215,7 +229,7 @@ type Target struct {
 func (c *Client) NewTarget(tag string) (*Target, error) {
 	ref, err := name.NewTag(tag, GetDefaultRegistryOptions()...)
 	if err != nil {
-		return nil, fmt.Errorf("invalid tag: %q: %w", tag, err)
+		return nil, NewReferenceError(tag, err)
 	}
 	return &Target{
 		reference: ref,
@@ -259,7 +273,7 @@ func (t *Target) Write(ctx context.Context, model types.ModelArtifact, progressW
 	}
 
 	if err := remote.Write(t.reference, model, authOpts...); err != nil {
-		return fmt.Errorf("write to registry %q: %w", t.reference.String(), err)
+		return newPushError(t.reference.String(), err)
 	}
 	return nil
 }
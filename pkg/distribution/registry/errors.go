@@ -3,6 +3,10 @@ package registry
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/remote/transport"
 )
 
 var (
@@ -77,3 +81,23 @@ func NewRegistryError(reference, code, message string, err error) error {
 		Err:       err,
 	}
 }
+
+// isUnauthorized returns true if an error returned by a registry is an
+// authentication failure, including failures to exchange credentials for a
+// token.
+func isUnauthorized(err error) bool {
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) && (transportErr.StatusCode == http.StatusUnauthorized || transportErr.StatusCode == http.StatusForbidden) {
+		return true
+	}
+	return strings.Contains(err.Error(), "UNAUTHORIZED") || strings.Contains(err.Error(), "DENIED")
+}
+
+// newPushError wraps an error returned by a registry while pushing to a
+// reference, classifying authentication failures as ErrUnauthorized.
+func newPushError(reference string, err error) error {
+	if isUnauthorized(err) {
+		return fmt.Errorf("write to registry %q: %w: %w", reference, ErrUnauthorized, err)
+	}
+	return fmt.Errorf("write to registry %q: %w", reference, err)
+}
@@ -0,0 +1,115 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/name"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/remote"
+)
+
+// NamespaceParameter is the query parameter that identifies the upstream
+// registry of requests sent to mirrors, following the convention of
+// containerd, so that a mirror can serve several upstream registries.
+const NamespaceParameter = "ns"
+
+// Mirrors maps upstream registries, such as index.docker.io, to the base URLs
+// of the mirrors that models are pulled from before falling back to them.
+type Mirrors map[string][]string
+
+// ParseMirrors parses a comma-separated list of REGISTRY=URL mirrors, such as
+// "docker.io=http://mirror.internal:5000". A registry can be listed several
+// times to be mirrored by several mirrors, which are tried in order.
+func ParseMirrors(s string) (Mirrors, error) {
+	mirrors := Mirrors{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		upstream, mirror, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mirror %q: expected REGISTRY=URL", entry)
+		}
+		reg, err := name.NewRegistry(strings.TrimSpace(upstream))
+		if err != nil {
+			return nil, fmt.Errorf("invalid mirrored registry %q: %w", upstream, err)
+		}
+		u, err := url.Parse(strings.TrimSpace(mirror))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+			return nil, fmt.Errorf("invalid mirror URL %q: expected http(s)://HOST[:PORT]", mirror)
+		}
+		mirrors[reg.RegistryStr()] = append(mirrors[reg.RegistryStr()], u.Scheme+"://"+u.Host)
+	}
+	return mirrors, nil
+}
+
+// WithMirrors sets the mirrors that models are pulled from before falling
+// back to their upstream registries.
+func WithMirrors(mirrors Mirrors) ClientOption {
+	return func(c *Client) {
+		if len(mirrors) > 0 {
+			c.mirrors = mirrors
+		}
+	}
+}
+
+// mirrorReference returns the reference of ref in the repository of the same
+// name on a mirror.
+func mirrorReference(ref name.Reference, mirror string) (name.Reference, error) {
+	u, err := url.Parse(mirror)
+	if err != nil {
+		return nil, err
+	}
+	var opts []name.Option
+	if u.Scheme == "http" {
+		opts = append(opts, name.Insecure)
+	}
+	reg, err := name.NewRegistry(u.Host, opts...)
+	if err != nil {
+		return nil, err
+	}
+	repo := reg.Repo(ref.Context().RepositoryStr())
+	if _, ok := ref.(name.Digest); ok {
+		return repo.Digest(ref.Identifier()), nil
+	}
+	return repo.Tag(ref.Identifier()), nil
+}
+
+// mirrorOptions returns the options to read from a mirror of the registry of
+// ref. Credentials are resolved for the mirror rather than for the upstream
+// registry, so that they aren't sent to mirrors.
+func (c *Client) mirrorOptions(ctx context.Context, ref name.Reference, mirror name.Reference) []remote.Option {
+	return []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithTransport(&namespaceTransport{
+			base:      c.transport,
+			host:      mirror.Context().RegistryStr(),
+			namespace: ref.Context().RegistryStr(),
+		}),
+		remote.WithUserAgent(c.userAgent),
+		remote.WithAuthFromKeychain(c.keychain),
+	}
+}
+
+// namespaceTransport adds the upstream registry to the requests sent to a
+// mirror.
+type namespaceTransport struct {
+	base      http.RoundTripper
+	host      string
+	namespace string
+}
+
+func (t *namespaceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.host || !strings.HasPrefix(req.URL.Path, "/v2/") {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	query := req.URL.Query()
+	query.Set(NamespaceParameter, t.namespace)
+	req.URL.RawQuery = query.Encode()
+	return t.base.RoundTrip(req)
+}
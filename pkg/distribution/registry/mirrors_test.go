@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/name"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/registry"
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/random"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/remote"
+)
+
+func TestParseMirrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Mirrors
+		valid    bool
+	}{
+		{"", Mirrors{}, true},
+		{"docker.io=http://mirror:5000", Mirrors{"index.docker.io": {"http://mirror:5000"}}, true},
+		{
+			"docker.io=https://a.internal/, ghcr.io=http://b.internal,docker.io=http://c.internal",
+			Mirrors{"index.docker.io": {"https://a.internal", "http://c.internal"}, "ghcr.io": {"http://b.internal"}},
+			true,
+		},
+		{"docker.io", nil, false},
+		{"docker.io=mirror:5000", nil, false},
+		{"docker.io=ftp://mirror", nil, false},
+		{"docker.io=http://mirror/path", nil, false},
+	}
+	for _, tt := range tests {
+		mirrors, err := ParseMirrors(tt.input)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseMirrors(%q): expected valid %t, got error %v", tt.input, tt.valid, err)
+			continue
+		}
+		if tt.valid && !reflect.DeepEqual(mirrors, tt.expected) {
+			t.Errorf("ParseMirrors(%q): expected %v, got %v", tt.input, tt.expected, mirrors)
+		}
+	}
+}
+
+func TestModelFromMirror(t *testing.T) {
+	upstream := httptest.NewServer(registry.New())
+	defer upstream.Close()
+	var mu sync.Mutex
+	var namespaces []string
+	mirrorRegistry := registry.New()
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		namespaces = append(namespaces, r.URL.Query().Get(NamespaceParameter))
+		mu.Unlock()
+		mirrorRegistry.ServeHTTP(w, r)
+	}))
+	defer mirror.Close()
+
+	upstreamHost := mustHost(t, upstream.URL)
+	mirrorHost := mustHost(t, mirror.URL)
+	write := func(ref string) v1.Hash {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatalf("Failed to create image: %v", err)
+		}
+		tag, err := name.NewTag(ref)
+		if err != nil {
+			t.Fatalf("Failed to parse tag: %v", err)
+		}
+		if err := remote.Write(tag, img); err != nil {
+			t.Fatalf("Failed to write image: %v", err)
+		}
+		digest, err := img.Digest()
+		if err != nil {
+			t.Fatalf("Failed to get image digest: %v", err)
+		}
+		return digest
+	}
+	mirrored := write(mirrorHost + "/test/model:latest")
+	write(upstreamHost + "/test/model:latest")
+	upstreamOnly := write(upstreamHost + "/test/model:other")
+	namespaces = nil
+
+	client := NewClient(WithMirrors(Mirrors{upstreamHost: {mirror.URL}}))
+	digest := func(ref string) v1.Hash {
+		mdl, err := client.Model(context.Background(), ref)
+		if err != nil {
+			t.Fatalf("Failed to get model %s: %v", ref, err)
+		}
+		d, err := mdl.Digest()
+		if err != nil {
+			t.Fatalf("Failed to get model digest: %v", err)
+		}
+		return d
+	}
+
+	if d := digest(upstreamHost + "/test/model:latest"); d != mirrored {
+		t.Errorf("Expected the model to be read from the mirror, got digest %s", d)
+	}
+	mu.Lock()
+	for _, ns := range namespaces {
+		if ns != upstreamHost {
+			t.Errorf("Expected requests to the mirror to identify %s as the upstream registry, got %q", upstreamHost, ns)
+		}
+	}
+	mu.Unlock()
+
+	// Models that the mirror doesn't serve are read from the registry, as
+	// are all models if the mirror is down.
+	if d := digest(upstreamHost + "/test/model:other"); d != upstreamOnly {
+		t.Errorf("Expected the model to be read from the registry, got digest %s", d)
+	}
+	mirror.Close()
+	if d := digest(upstreamHost + "/test/model:other"); d != upstreamOnly {
+		t.Errorf("Expected the model to be read from the registry while the mirror is down, got digest %s", d)
+	}
+}
+
+func mustHost(t *testing.T, rawURL string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL: %v", err)
+	}
+	return u.Host
+}
@@ -0,0 +1,117 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/name"
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/remote"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/remote/transport"
+)
+
+const (
+	// MediaTypeSimpleSigning is the media type of cosign signature payloads.
+	MediaTypeSimpleSigning = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// SignatureAnnotation is the layer annotation that holds the base64
+	// encoded signature of a cosign signature payload.
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+	// maximumSignaturePayloadSize is the maximum size of a signature payload
+	// that is read from a registry.
+	maximumSignaturePayloadSize = 1024 * 1024
+)
+
+// Signature is a cosign signature of a manifest.
+type Signature struct {
+	// Payload is the signed simple signing payload, which identifies the
+	// signed manifest by digest.
+	Payload []byte
+	// Signature is the signature of the payload.
+	Signature []byte
+}
+
+// SignatureTag returns the tag that cosign stores the signatures of the
+// manifest with the specified digest under, in the repository of the
+// reference.
+func SignatureTag(reference string, digest v1.Hash) (name.Tag, error) {
+	ref, err := name.ParseReference(reference, GetDefaultRegistryOptions()...)
+	if err != nil {
+		return name.Tag{}, NewReferenceError(reference, err)
+	}
+	return ref.Context().Tag(fmt.Sprintf("%s-%s.sig", digest.Algorithm, digest.Hex)), nil
+}
+
+// Signatures returns the cosign signatures of the manifest with the specified
+// digest, in the repository of the reference. Manifests without signatures
+// have none, which isn't an error.
+func (c *Client) Signatures(ctx context.Context, reference string, digest v1.Hash) ([]Signature, error) {
+	tag, err := SignatureTag(reference, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	authOpts := []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithTransport(c.transport),
+		remote.WithUserAgent(c.userAgent),
+	}
+	if c.auth != nil {
+		authOpts = append(authOpts, remote.WithAuth(c.auth))
+	} else {
+		authOpts = append(authOpts, remote.WithAuthFromKeychain(c.keychain))
+	}
+
+	img, err := remote.Image(tag, authOpts...)
+	if err != nil {
+		var terr *transport.Error
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		if errStr := err.Error(); strings.Contains(errStr, "MANIFEST_UNKNOWN") || strings.Contains(errStr, "NAME_UNKNOWN") {
+			return nil, nil
+		}
+		if isUnauthorized(err) {
+			return nil, NewRegistryError(tag.String(), "UNAUTHORIZED", "Authentication required for signatures", err)
+		}
+		return nil, NewRegistryError(tag.String(), "UNKNOWN", err.Error(), err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("reading signature manifest: %w", err)
+	}
+
+	var signatures []Signature
+	for _, desc := range manifest.Layers {
+		encoded, ok := desc.Annotations[SignatureAnnotation]
+		if !ok || string(desc.MediaType) != MediaTypeSimpleSigning {
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decoding signature of %s: %w", desc.Digest, err)
+		}
+		if desc.Size > maximumSignaturePayloadSize {
+			return nil, fmt.Errorf("signature payload %s is too large (%d bytes)", desc.Digest, desc.Size)
+		}
+		layer, err := img.LayerByDigest(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("getting signature payload %s: %w", desc.Digest, err)
+		}
+		rc, err := layer.Compressed()
+		if err != nil {
+			return nil, fmt.Errorf("reading signature payload %s: %w", desc.Digest, err)
+		}
+		payload, err := io.ReadAll(io.LimitReader(rc, maximumSignaturePayloadSize))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading signature payload %s: %w", desc.Digest, err)
+		}
+		signatures = append(signatures, Signature{Payload: payload, Signature: signature})
+	}
+	return signatures, nil
+}
@@ -3,6 +3,7 @@ package tarball
 import (
 	"archive/tar"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -16,6 +17,7 @@ type Reader struct {
 	tr          *tar.Reader
 	rawManifest []byte
 	digest      v1.Hash
+	tags        []string
 	done        bool
 }
 
@@ -62,6 +64,12 @@ func (r *Reader) Next() (v1.Hash, error) {
 			}
 			continue
 		}
+		if hdr.Name == TagsFile {
+			if err := json.NewDecoder(r.tr).Decode(&r.tags); err != nil {
+				return v1.Hash{}, fmt.Errorf("read %s: %w", TagsFile, err)
+			}
+			continue
+		}
 		cleanPath := filepath.Clean(hdr.Name)
 		if strings.Contains(cleanPath, "..") {
 			return v1.Hash{}, fmt.Errorf("invalid path detected: %s", hdr.Name)
@@ -91,6 +99,12 @@ func (r *Reader) Manifest() ([]byte, v1.Hash, error) {
 	return r.rawManifest, r.digest, nil
 }
 
+// Tags returns the tags recorded in the archive, if any, which are only
+// known once all blobs have been read.
+func (r *Reader) Tags() []string {
+	return r.tags
+}
+
 func NewReader(r io.Reader) *Reader {
 	return &Reader{
 		tr: tar.NewReader(r),
@@ -3,6 +3,7 @@ package tarball
 import (
 	"archive/tar"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -13,17 +14,24 @@ import (
 	"github.com/docker/model-runner/pkg/distribution/types"
 )
 
+// TagsFile is the name of the file in an archive that lists the tags of its
+// artifact, which are applied when the archive is loaded.
+const TagsFile = "tags.json"
+
 // Target stores an artifact as a TAR archive
 type Target struct {
 	writer io.Writer
 	dirs   map[string]struct{}
+	tags   []string
 }
 
-// NewTarget returns a *Target for the given writer
-func NewTarget(w io.Writer) (*Target, error) {
+// NewTarget returns a *Target for the given writer. The tags, if any, are
+// recorded in the archive.
+func NewTarget(w io.Writer, tags ...string) (*Target, error) {
 	return &Target{
 		writer: w,
 		dirs:   make(map[string]struct{}),
+		tags:   tags,
 	}, nil
 }
 
@@ -79,6 +87,23 @@ func (t *Target) Write(ctx context.Context, mdl types.ModelArtifact, progressWri
 		return fmt.Errorf("write config blob contents: %w", err)
 	}
 
+	if len(t.tags) > 0 {
+		tags, err := json.Marshal(t.tags)
+		if err != nil {
+			return fmt.Errorf("marshal tags: %w", err)
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name: TagsFile,
+			Size: int64(len(tags)),
+			Mode: 0666,
+		}); err != nil {
+			return fmt.Errorf("write %s header: %w", TagsFile, err)
+		}
+		if _, err = tw.Write(tags); err != nil {
+			return fmt.Errorf("write %s contents: %w", TagsFile, err)
+		}
+	}
+
 	if err := tw.WriteHeader(&tar.Header{
 		Name: "manifest.json",
 		Size: int64(len(rm)),
@@ -29,11 +29,29 @@ const (
 	// MediaTypeMultimodalProjector indicates a Multimodal projector file
 	MediaTypeMultimodalProjector = types.MediaType("application/vnd.docker.ai.mmproj")
 
+	// MediaTypeLoRAAdapter indicates a LoRA adapter in GGUF format. Adapters
+	// are distributed as artifacts of their own, whose only weights are a layer
+	// of this type, and are applied to a base model when it's loaded.
+	MediaTypeLoRAAdapter = types.MediaType("application/vnd.docker.ai.lora.gguf")
+
 	// MediaTypeChatTemplate indicates a Jinja chat template
 	MediaTypeChatTemplate = types.MediaType("application/vnd.docker.ai.chat.template.jinja")
 
 	FormatGGUF        = Format("gguf")
 	FormatSafetensors = Format("safetensors")
+	// FormatONNX indicates an ONNX model, packaged as a directory archive
+	// containing the ONNX graph and its tokenizer and runtime configuration.
+	FormatONNX = Format("onnx")
+	// FormatWhisper indicates a whisper.cpp speech recognition model,
+	// packaged as a directory archive containing the GGML weights file.
+	FormatWhisper = Format("whisper")
+	// FormatDiffusion indicates a stable-diffusion.cpp image generation model,
+	// packaged as a directory archive containing a single-file checkpoint.
+	FormatDiffusion = Format("diffusion")
+	// FormatTensorRT indicates a TensorRT-LLM model, packaged as a directory
+	// archive containing prebuilt engines, their config.json, and the
+	// tokenizer.
+	FormatTensorRT = Format("tensorrt")
 
 	// OCI Annotation keys for model layers
 	// See https://github.com/opencontainers/image-spec/blob/main/annotations.md
@@ -68,11 +86,26 @@ type Config struct {
 	GGUF         map[string]string `json:"gguf,omitempty"`
 	Safetensors  map[string]string `json:"safetensors,omitempty"`
 	ContextSize  *uint64           `json:"context_size,omitempty"`
+	RopeScaling  *RopeScaling      `json:"rope_scaling,omitempty"`
+}
+
+// RopeScaling describes the RoPE scaling used to run a model with a context
+// longer than the one it was trained with.
+type RopeScaling struct {
+	// Type is the scaling method, either "linear" or "yarn".
+	Type string `json:"type"`
+	// Factor is the context extension factor.
+	Factor float64 `json:"factor"`
+	// OriginalContextSize is the context size the model was trained with.
+	OriginalContextSize uint64 `json:"original_context_size,omitempty"`
 }
 
 // Descriptor provides metadata about the provenance of the model.
 type Descriptor struct {
 	Created *time.Time `json:"created,omitempty"`
+	// DerivedFrom is the ID of the model that this model was derived from
+	// locally, such as by quantization, if any.
+	DerivedFrom string `json:"derived_from,omitempty"`
 }
 
 // FileMetadata represents the metadata of file, which is the value definition of AnnotationFileMetadata.
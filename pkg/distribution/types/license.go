@@ -0,0 +1,25 @@
+package types
+
+import "time"
+
+// LicenseAcceptance records that the license of a model was accepted, so
+// that acceptances can be audited. Acceptances are kept after they're revoked
+// and after their model is deleted.
+type LicenseAcceptance struct {
+	// Model is the ID of the model whose license was accepted.
+	Model string `json:"model"`
+	// Reference is the reference that the model was accepted by, such as
+	// "ai/llama3.2:latest".
+	Reference string `json:"reference,omitempty"`
+	// License is the identifier of the license, such as "llama3.2", if the
+	// model's metadata records one.
+	License string `json:"license,omitempty"`
+	// Digests are the digests of the license files packaged with the model.
+	Digests []string `json:"digests,omitempty"`
+	// AcceptedBy identifies who accepted the license, if known.
+	AcceptedBy string `json:"accepted-by,omitempty"`
+	// AcceptedAt is when the license was accepted.
+	AcceptedAt time.Time `json:"accepted-at"`
+	// RevokedAt is when the acceptance was revoked, if it was.
+	RevokedAt *time.Time `json:"revoked-at,omitempty"`
+}
@@ -10,6 +10,7 @@ type Model interface {
 	SafetensorsPaths() ([]string, error)
 	ConfigArchivePath() (string, error)
 	MMPROJPath() (string, error)
+	AdapterPath() (string, error)
 	Config() (Config, error)
 	Tags() []string
 	Descriptor() (Descriptor, error)
@@ -27,7 +28,12 @@ type ModelBundle interface {
 	RootDir() string
 	GGUFPath() string
 	SafetensorsPath() string
+	ONNXPath() string
+	WhisperPath() string
+	DiffusionPath() string
+	TensorRTPath() string
 	ChatTemplatePath() string
 	MMPROJPath() string
+	AdapterPath() string
 	RuntimeConfig() Config
 }
@@ -0,0 +1,42 @@
+package types
+
+// ChatTemplateFormat is the format of a chat template.
+type ChatTemplateFormat string
+
+const (
+	// ChatTemplateFormatJinja is the format of Jinja templates, which are
+	// applied by backends, such as those of Hugging Face tokenizers and GGUF
+	// files.
+	ChatTemplateFormatJinja ChatTemplateFormat = "jinja"
+	// ChatTemplateFormatGo is the format of Go templates, such as those of
+	// Ollama models, which backends don't apply.
+	ChatTemplateFormatGo ChatTemplateFormat = "go"
+)
+
+// ModelSettings are settings stored with a model in the store, which change
+// how it's run without rebuilding its artifact. They take precedence over the
+// model's config and over the configuration of its runners.
+type ModelSettings struct {
+	// ContextSize overrides the context size of the model, if set.
+	ContextSize *uint64 `json:"context-size,omitempty"`
+	// ChatTemplate overrides the chat template of the model, if set.
+	ChatTemplate string `json:"chat-template,omitempty"`
+	// ChatTemplateFormat is the format of ChatTemplate. Only Jinja templates
+	// override the chat template of the model's bundles.
+	ChatTemplateFormat ChatTemplateFormat `json:"chat-template-format,omitempty"`
+	// Backend is the backend that runs the model when requests don't specify
+	// one, if set.
+	Backend string `json:"backend,omitempty"`
+	// RuntimeFlags are passed to the backend after the runtime flags that
+	// the model's runner is configured with.
+	RuntimeFlags []string `json:"runtime-flags,omitempty"`
+	// TemplateCompletions applies the model's chat template to the prompts
+	// of completion requests, as the message of a user, for chat-tuned
+	// models whose legacy clients send raw prompts.
+	TemplateCompletions bool `json:"template-completions,omitempty"`
+}
+
+// IsZero returns true if no setting is set.
+func (s ModelSettings) IsZero() bool {
+	return s.ContextSize == nil && s.ChatTemplate == "" && s.Backend == "" && len(s.RuntimeFlags) == 0 && !s.TemplateCompletions
+}
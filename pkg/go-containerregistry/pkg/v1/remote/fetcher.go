@@ -17,6 +17,7 @@ package remote
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -276,6 +277,168 @@ func getResumeOffset(ctx context.Context, digest string) int64 {
 	return 0
 }
 
+const chunkedDownloadsKey contextKey = "chunkedDownloads"
+
+// chunkedDownloads configures blobs to be downloaded in concurrent ranged chunks.
+type chunkedDownloads struct {
+	chunks    int
+	chunkSize int64
+}
+
+// errRangesUnsupported indicates that a server doesn't support range requests.
+var errRangesUnsupported = errors.New("range requests unsupported")
+
+// WithChunkedDownloads returns a context in which blobs of at least two chunks
+// are downloaded as up to the given number of concurrent ranged requests of
+// chunkSize bytes each. Chunks are returned in order, so at most chunks chunks
+// are buffered in memory. Servers that don't support range requests are read
+// with a single request.
+func WithChunkedDownloads(ctx context.Context, chunks int, chunkSize int64) context.Context {
+	return context.WithValue(ctx, chunkedDownloadsKey, chunkedDownloads{chunks: chunks, chunkSize: chunkSize})
+}
+
+// getChunkedDownloads returns the chunked download configuration from context
+// if a blob of the given remaining size should be downloaded in chunks.
+func getChunkedDownloads(ctx context.Context, remaining int64) (chunkedDownloads, bool) {
+	cd, ok := ctx.Value(chunkedDownloadsKey).(chunkedDownloads)
+	return cd, ok && cd.chunks > 1 && cd.chunkSize > 0 && remaining >= 2*cd.chunkSize
+}
+
+// fetchRange requests the bytes [start, end) of the blob at u.
+func fetchRange(ctx context.Context, do func(*http.Request) (*http.Response, error), u url.URL, start, end int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	resp, err := do(req)
+	if err != nil {
+		return nil, redact.Error(err)
+	}
+	if err := transport.CheckError(resp, http.StatusOK, http.StatusPartialContent); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, errRangesUnsupported
+	}
+	if resp.ContentLength != -1 && resp.ContentLength != end-start {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: Content-Length header %d does not match chunk size %d", u.String(), resp.ContentLength, end-start)
+	}
+	return resp, nil
+}
+
+// fetchChunked downloads the bytes [offset, size) of the blob at u in
+// concurrent chunks. It returns errRangesUnsupported if the server doesn't
+// support range requests, in which case nothing was read.
+func fetchChunked(ctx context.Context, do func(*http.Request) (*http.Response, error), u url.URL, offset, size int64, cd chunkedDownloads) (io.ReadCloser, error) {
+	first, err := fetchRange(ctx, do, u, offset, offset+cd.chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	n := int((size - offset + cd.chunkSize - 1) / cd.chunkSize)
+	r := &chunkedReader{
+		ctx:     ctx,
+		cancel:  cancel,
+		results: make([]chan chunkResult, n),
+		slots:   make(chan struct{}, cd.chunks),
+	}
+	for i := range r.results {
+		r.results[i] = make(chan chunkResult, 1)
+	}
+	r.slots <- struct{}{}
+	r.results[0] <- chunkResult{body: first.Body}
+
+	go func() {
+		for i := 1; i < n; i++ {
+			// Wait for a chunk to be consumed before fetching another.
+			select {
+			case r.slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			start := offset + int64(i)*cd.chunkSize
+			end := min(start+cd.chunkSize, size)
+			go func() {
+				resp, err := fetchRange(ctx, do, u, start, end)
+				if err != nil {
+					r.results[i] <- chunkResult{err: err}
+					return
+				}
+				defer resp.Body.Close()
+				b, err := io.ReadAll(resp.Body)
+				if err == nil && int64(len(b)) != end-start {
+					err = fmt.Errorf("GET %s: got %d bytes, expected chunk size %d", u.String(), len(b), end-start)
+				}
+				r.results[i] <- chunkResult{body: io.NopCloser(bytes.NewReader(b)), err: err}
+			}()
+		}
+	}()
+	return r, nil
+}
+
+// chunkResult is a downloaded chunk or the error that prevented its download.
+type chunkResult struct {
+	body io.ReadCloser
+	err  error
+}
+
+// chunkedReader reads the chunks of a blob in order as they're downloaded.
+type chunkedReader struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	results []chan chunkResult
+	// slots limits the number of chunks that are downloading or buffered.
+	slots   chan struct{}
+	next    int
+	current io.ReadCloser
+}
+
+// Read implements io.Reader.
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if r.next == len(r.results) {
+				return 0, io.EOF
+			}
+			select {
+			case result := <-r.results[r.next]:
+				if result.err != nil {
+					return 0, result.err
+				}
+				r.current = result.body
+				r.next++
+			case <-r.ctx.Done():
+				return 0, r.ctx.Err()
+			}
+		}
+		n, err := r.current.Read(p)
+		if errors.Is(err, io.EOF) {
+			r.current.Close()
+			r.current = nil
+			<-r.slots
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Close implements io.Closer, stopping outstanding downloads.
+func (r *chunkedReader) Close() error {
+	r.cancel()
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
 func (f *fetcher) fetchBlob(ctx context.Context, size int64, h v1.Hash) (io.ReadCloser, error) {
 	u := f.url("blobs", h.String())
 	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
@@ -285,6 +448,24 @@ func (f *fetcher) fetchBlob(ctx context.Context, size int64, h v1.Hash) (io.Read
 
 	// Check if we should resume from a specific offset
 	resumeOffset := getResumeOffset(ctx, h.String())
+
+	// Download large blobs in concurrent chunks, if configured.
+	if size != verify.SizeUnknown {
+		if cd, ok := getChunkedDownloads(ctx, size-resumeOffset); ok {
+			rc, err := fetchChunked(ctx, f.Do, u, resumeOffset, size, cd)
+			if err == nil {
+				if resumeOffset > 0 {
+					// The complete file will be verified after all bytes are
+					// written to disk.
+					return rc, nil
+				}
+				return verify.ReadCloser(rc, size, h)
+			}
+			if !errors.Is(err, errRangesUnsupported) {
+				return nil, err
+			}
+		}
+	}
 	if resumeOffset > 0 {
 		// Add Range header to resume download
 		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", resumeOffset))
@@ -296,20 +477,23 @@ func (f *fetcher) fetchBlob(ctx context.Context, size int64, h v1.Hash) (io.Read
 	}
 
 	// Accept both 200 OK (full content) and 206 Partial Content (resumed)
-	if resumeOffset > 0 {
-		// If we requested a Range but got 200, the server doesn't support ranges
-		// We'll have to download from scratch
-		if resp.StatusCode == http.StatusOK {
-			// Server doesn't support range requests, will download full content
-			resumeOffset = 0
-		}
-	}
-
 	if err := transport.CheckError(resp, http.StatusOK, http.StatusPartialContent); err != nil {
 		resp.Body.Close()
 		return nil, err
 	}
 
+	// If we requested a Range but got 200, the server doesn't support ranges
+	// We'll get the full content, so skip the part that was already downloaded,
+	// which is appended to. The complete file will be verified after all bytes
+	// are written to disk.
+	if resumeOffset > 0 && resp.StatusCode == http.StatusOK {
+		if _, err := io.CopyN(io.Discard, resp.Body, resumeOffset); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s: skipping downloaded content: %w", u.String(), err)
+		}
+		return resp.Body, nil
+	}
+
 	// For partial content (resumed downloads), we can't verify the hash on the stream
 	// since we're only getting part of the file. The complete file will be verified
 	// after all bytes are written to disk.
@@ -17,6 +17,7 @@ package remote
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -205,6 +206,23 @@ func (rl *remoteImageLayer) Compressed() (io.ReadCloser, error) {
 	// TODO: Maybe we don't want to try pulling from the registry first?
 	var lastErr error
 	for _, u := range urls {
+		// Download large blobs in concurrent chunks, if configured.
+		if cd, ok := getChunkedDownloads(ctx, d.Size-resumeOffset); ok {
+			rc, err := fetchChunked(ctx, rl.ri.fetcher.Do, u, resumeOffset, d.Size, cd)
+			if err == nil {
+				if resumeOffset > 0 {
+					// The complete file will be verified after all bytes are
+					// written to disk.
+					return rc, nil
+				}
+				return verify.ReadCloser(rc, d.Size, rl.digest)
+			}
+			if !errors.Is(err, errRangesUnsupported) {
+				lastErr = err
+				continue
+			}
+		}
+
 		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
 		if err != nil {
 			return nil, err
@@ -229,9 +247,16 @@ func (rl *remoteImageLayer) Compressed() (io.ReadCloser, error) {
 		}
 
 		// If we requested a range but got 200, server doesn't support ranges
-		// We'll get the full content
+		// We'll get the full content, so skip the part that was already
+		// downloaded, which is appended to. The complete file will be verified
+		// after all bytes are written to disk.
 		if resumeOffset > 0 && resp.StatusCode == http.StatusOK {
-			resumeOffset = 0
+			if _, err := io.CopyN(io.Discard, resp.Body, resumeOffset); err != nil {
+				resp.Body.Close()
+				lastErr = fmt.Errorf("skipping downloaded content: %w", err)
+				continue
+			}
+			return resp.Body, nil
 		}
 
 		// For partial content (resumed downloads), we can't verify the hash on the stream
@@ -25,6 +25,7 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -747,3 +748,90 @@ func TestData(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestRemoteImageLayerChunked(t *testing.T) {
+	for _, ignoreRanges := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ignore ranges %v", ignoreRanges), func(t *testing.T) {
+			img, err := random.Image(1000, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			layers, err := img.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := io.ReadAll(mustCompressed(t, layers[0]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			digest, err := layers[0].Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ranged []string
+			var mu sync.Mutex
+			upstream := registry.New()
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/blobs/"+digest.String()) {
+					mu.Lock()
+					ranged = append(ranged, r.Header.Get("Range"))
+					mu.Unlock()
+					if ignoreRanges {
+						r.Header.Del("Range")
+					}
+				}
+				upstream.ServeHTTP(w, r)
+			}))
+			defer s.Close()
+			u, err := url.Parse(s.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ref, err := name.ParseReference(fmt.Sprintf("%s/chunked:latest", u.Host))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Write(ref, img); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := WithChunkedDownloads(context.Background(), 3, 100)
+			pulled, err := Image(ref, WithContext(ctx))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pulledLayers, err := pulled.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(mustCompressed(t, pulledLayers[0]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("chunked download returned %d bytes that don't match the %d bytes of the layer", len(got), len(want))
+			}
+
+			wantRequests := (len(want) + 99) / 100
+			if ignoreRanges {
+				// The first chunk request is answered in full and retried
+				// without chunking.
+				wantRequests = 2
+			}
+			if len(ranged) != wantRequests {
+				t.Errorf("got %d blob requests, want %d: %v", len(ranged), wantRequests, ranged)
+			}
+		})
+	}
+}
+
+func mustCompressed(t *testing.T, l v1.Layer) io.ReadCloser {
+	t.Helper()
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rc.Close() })
+	return rc
+}
@@ -15,3 +15,26 @@ func New(modelRuntimeInstallPath string) *GPUInfo {
 func (g *GPUInfo) GetVRAMSize() (uint64, error) {
 	return getVRAMSize(g.modelRuntimeInstallPath)
 }
+
+// Device describes a single GPU.
+type Device struct {
+	// Index is the device index, as used by e.g. CUDA_VISIBLE_DEVICES.
+	Index int `json:"index"`
+	// VRAM is the total memory of the device in bytes.
+	VRAM uint64 `json:"vram"`
+}
+
+// GetDevices returns the GPUs available on the system.
+func (g *GPUInfo) GetDevices() ([]Device, error) {
+	return getDevices(g.modelRuntimeInstallPath)
+}
+
+// singleDevice reports the system GPU memory as a single device, for platforms
+// where per-device information isn't available.
+func singleDevice(modelRuntimeInstallPath string) ([]Device, error) {
+	vram, err := getVRAMSize(modelRuntimeInstallPath)
+	if err != nil {
+		return nil, err
+	}
+	return []Device{{Index: 0, VRAM: vram}}, nil
+}
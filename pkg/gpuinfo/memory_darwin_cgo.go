@@ -17,3 +17,8 @@ func getVRAMSize(_ string) (uint64, error) {
 	}
 	return uint64(vramSize), nil
 }
+
+// getDevices returns the GPUs on the system
+func getDevices(modelRuntimeInstallPath string) ([]Device, error) {
+	return singleDevice(modelRuntimeInstallPath)
+}
@@ -17,3 +17,23 @@ func getVRAMSize(_ string) (uint64, error) {
 	}
 	return uint64(vramSize), nil
 }
+
+// maximumDevices is the maximum number of GPUs that are enumerated.
+const maximumDevices = 64
+
+// getDevices returns the nvidia GPUs on the system
+func getDevices(_ string) ([]Device, error) {
+	var sizes [maximumDevices]C.ulonglong
+	count := int(C.getDeviceVRAMSizes(&sizes[0], maximumDevices))
+	if count == 0 {
+		return nil, errors.New("could not enumerate nvidia devices")
+	}
+	devices := make([]Device, 0, count)
+	for i := 0; i < count; i++ {
+		if sizes[i] == 0 {
+			continue
+		}
+		devices = append(devices, Device{Index: i, VRAM: uint64(sizes[i])})
+	}
+	return devices, nil
+}
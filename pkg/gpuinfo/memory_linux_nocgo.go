@@ -8,3 +8,8 @@ import "errors"
 func getVRAMSize(_ string) (uint64, error) {
 	return 0, errors.New("unimplemented without cgo")
 }
+
+// getDevices returns the GPUs on the system
+func getDevices(_ string) ([]Device, error) {
+	return nil, errors.New("unimplemented without cgo")
+}
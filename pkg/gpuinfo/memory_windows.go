@@ -39,3 +39,8 @@ func getVRAMSize(modelRuntimeInstallPath string) (uint64, error) {
 	}
 	return 0, errors.New("unexpected nv-gpu-info output format")
 }
+
+// getDevices returns the GPUs on the system
+func getDevices(modelRuntimeInstallPath string) ([]Device, error) {
+	return singleDevice(modelRuntimeInstallPath)
+}
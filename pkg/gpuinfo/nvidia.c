@@ -68,4 +68,184 @@ size_t getVRAMSize() {
     nvmlShutdown();
     dlclose(handle);
     return memory.total;
-}
\ No newline at end of file
+}
+int getDeviceVRAMSizes(unsigned long long* sizes, int max) {
+    void* handle;
+    nvmlReturn_t (*nvmlInit)(void);
+    nvmlReturn_t (*nvmlShutdown)(void);
+    nvmlReturn_t (*nvmlDeviceGetCount)(unsigned int* count);
+    nvmlReturn_t (*nvmlDeviceGetHandleByIndex)(unsigned int index, nvmlDevice_t* device);
+    nvmlReturn_t (*nvmlDeviceGetMemoryInfo)(nvmlDevice_t device, nvmlMemory_t* memory);
+
+    unsigned int count;
+    int i;
+    nvmlDevice_t device;
+    nvmlMemory_t memory;
+
+    handle = dlopen("libnvidia-ml.so.1", RTLD_LAZY);
+    if (!handle) {
+        handle = dlopen("libnvidia-ml.so", RTLD_LAZY);
+        if (!handle) {
+            return 0;
+        }
+    }
+
+    nvmlInit = dlsym(handle, "nvmlInit");
+    nvmlShutdown = dlsym(handle, "nvmlShutdown");
+    nvmlDeviceGetCount = dlsym(handle, "nvmlDeviceGetCount");
+    nvmlDeviceGetHandleByIndex = dlsym(handle, "nvmlDeviceGetHandleByIndex");
+    nvmlDeviceGetMemoryInfo = dlsym(handle, "nvmlDeviceGetMemoryInfo");
+
+    if (!nvmlInit || !nvmlShutdown || !nvmlDeviceGetCount || !nvmlDeviceGetHandleByIndex || !nvmlDeviceGetMemoryInfo) {
+        dlclose(handle);
+        return 0;
+    }
+
+    if (nvmlInit() != NVML_SUCCESS) {
+        dlclose(handle);
+        return 0;
+    }
+
+    if (nvmlDeviceGetCount(&count) != NVML_SUCCESS) {
+        nvmlShutdown();
+        dlclose(handle);
+        return 0;
+    }
+
+    for (i = 0; i < (int)count && i < max; i++) {
+        sizes[i] = 0;
+        if (nvmlDeviceGetHandleByIndex(i, &device) != NVML_SUCCESS) {
+            continue;
+        }
+        if (nvmlDeviceGetMemoryInfo(device, &memory) != NVML_SUCCESS) {
+            continue;
+        }
+        sizes[i] = memory.total;
+    }
+
+    nvmlShutdown();
+    dlclose(handle);
+    return i;
+}
+
+typedef struct {
+    unsigned int gpu;
+    unsigned int memory;
+} nvmlUtilization_t;
+
+typedef struct {
+    unsigned int pid;
+    unsigned long long usedGpuMemory;
+    unsigned int gpuInstanceId;
+    unsigned int computeInstanceId;
+} nvmlProcessInfo_t;
+
+// NVML_TEMPERATURE_GPU is the sensor of the GPU die.
+#define NVML_TEMPERATURE_GPU 0
+
+// NVML_VALUE_NOT_AVAILABLE is reported as the memory used by processes when
+// it's unknown.
+#define NVML_VALUE_NOT_AVAILABLE (~0ULL)
+
+int sampleDevices(deviceSample* samples, int max) {
+    void* handle;
+    nvmlReturn_t (*nvmlInit)(void);
+    nvmlReturn_t (*nvmlShutdown)(void);
+    nvmlReturn_t (*nvmlDeviceGetCount)(unsigned int* count);
+    nvmlReturn_t (*nvmlDeviceGetHandleByIndex)(unsigned int index, nvmlDevice_t* device);
+    nvmlReturn_t (*nvmlDeviceGetMemoryInfo)(nvmlDevice_t device, nvmlMemory_t* memory);
+    nvmlReturn_t (*nvmlDeviceGetName)(nvmlDevice_t device, char* name, unsigned int length);
+    nvmlReturn_t (*nvmlDeviceGetUtilizationRates)(nvmlDevice_t device, nvmlUtilization_t* utilization);
+    nvmlReturn_t (*nvmlDeviceGetTemperature)(nvmlDevice_t device, int sensor, unsigned int* temperature);
+    nvmlReturn_t (*nvmlDeviceGetPowerUsage)(nvmlDevice_t device, unsigned int* power);
+    nvmlReturn_t (*nvmlDeviceGetComputeRunningProcesses)(nvmlDevice_t device, unsigned int* count, nvmlProcessInfo_t* infos);
+
+    unsigned int count;
+    unsigned int processCount;
+    int i;
+    unsigned int j;
+    nvmlDevice_t device;
+    nvmlMemory_t memory;
+    nvmlUtilization_t utilization;
+    nvmlProcessInfo_t processes[MAXIMUM_PROCESSES];
+
+    handle = dlopen("libnvidia-ml.so.1", RTLD_LAZY);
+    if (!handle) {
+        handle = dlopen("libnvidia-ml.so", RTLD_LAZY);
+        if (!handle) {
+            return -1;
+        }
+    }
+
+    nvmlInit = dlsym(handle, "nvmlInit");
+    nvmlShutdown = dlsym(handle, "nvmlShutdown");
+    nvmlDeviceGetCount = dlsym(handle, "nvmlDeviceGetCount");
+    nvmlDeviceGetHandleByIndex = dlsym(handle, "nvmlDeviceGetHandleByIndex");
+    nvmlDeviceGetMemoryInfo = dlsym(handle, "nvmlDeviceGetMemoryInfo");
+    nvmlDeviceGetName = dlsym(handle, "nvmlDeviceGetName");
+    nvmlDeviceGetUtilizationRates = dlsym(handle, "nvmlDeviceGetUtilizationRates");
+    nvmlDeviceGetTemperature = dlsym(handle, "nvmlDeviceGetTemperature");
+    nvmlDeviceGetPowerUsage = dlsym(handle, "nvmlDeviceGetPowerUsage");
+    // Older drivers only have earlier versions of the process listing, whose
+    // records are laid out differently, so processes aren't listed there.
+    nvmlDeviceGetComputeRunningProcesses = dlsym(handle, "nvmlDeviceGetComputeRunningProcesses_v3");
+    if (!nvmlDeviceGetComputeRunningProcesses) {
+        nvmlDeviceGetComputeRunningProcesses = dlsym(handle, "nvmlDeviceGetComputeRunningProcesses_v2");
+    }
+
+    if (!nvmlInit || !nvmlShutdown || !nvmlDeviceGetCount || !nvmlDeviceGetHandleByIndex || !nvmlDeviceGetMemoryInfo) {
+        dlclose(handle);
+        return -1;
+    }
+
+    if (nvmlInit() != NVML_SUCCESS) {
+        dlclose(handle);
+        return -1;
+    }
+
+    if (nvmlDeviceGetCount(&count) != NVML_SUCCESS) {
+        nvmlShutdown();
+        dlclose(handle);
+        return -1;
+    }
+
+    for (i = 0; i < (int)count && i < max; i++) {
+        memset(&samples[i], 0, sizeof(deviceSample));
+        if (nvmlDeviceGetHandleByIndex(i, &device) != NVML_SUCCESS) {
+            continue;
+        }
+        if (nvmlDeviceGetMemoryInfo(device, &memory) != NVML_SUCCESS) {
+            continue;
+        }
+        samples[i].valid = 1;
+        samples[i].memoryUsed = memory.used;
+        samples[i].memoryTotal = memory.total;
+        if (nvmlDeviceGetName && nvmlDeviceGetName(device, samples[i].name, sizeof(samples[i].name)) != NVML_SUCCESS) {
+            samples[i].name[0] = '\0';
+        }
+        if (nvmlDeviceGetUtilizationRates && nvmlDeviceGetUtilizationRates(device, &utilization) == NVML_SUCCESS) {
+            samples[i].hasUtilization = 1;
+            samples[i].utilization = utilization.gpu;
+        }
+        if (nvmlDeviceGetTemperature && nvmlDeviceGetTemperature(device, NVML_TEMPERATURE_GPU, &samples[i].temperature) == NVML_SUCCESS) {
+            samples[i].hasTemperature = 1;
+        }
+        if (nvmlDeviceGetPowerUsage && nvmlDeviceGetPowerUsage(device, &samples[i].power) == NVML_SUCCESS) {
+            samples[i].hasPower = 1;
+        }
+        processCount = MAXIMUM_PROCESSES;
+        if (nvmlDeviceGetComputeRunningProcesses && nvmlDeviceGetComputeRunningProcesses(device, &processCount, processes) == NVML_SUCCESS) {
+            for (j = 0; j < processCount && j < MAXIMUM_PROCESSES; j++) {
+                samples[i].pids[j] = processes[j].pid;
+                if (processes[j].usedGpuMemory != NVML_VALUE_NOT_AVAILABLE) {
+                    samples[i].processMemory[j] = processes[j].usedGpuMemory;
+                }
+            }
+            samples[i].processCount = (int)j;
+        }
+    }
+
+    nvmlShutdown();
+    dlclose(handle);
+    return i;
+}
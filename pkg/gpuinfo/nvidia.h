@@ -1,6 +1,33 @@
 //go:build linux
 
 #include <stddef.h>
+#include <string.h>
 #include <dlfcn.h>
 
-size_t getVRAMSize();
\ No newline at end of file
+// MAXIMUM_PROCESSES is the maximum number of processes listed per device.
+#define MAXIMUM_PROCESSES 64
+
+// deviceSample is a sample of the telemetry of a device.
+typedef struct {
+    // valid is nonzero if the device could be sampled.
+    int valid;
+    char name[96];
+    unsigned long long memoryUsed;
+    unsigned long long memoryTotal;
+    int hasUtilization;
+    // utilization is a percentage.
+    unsigned int utilization;
+    int hasTemperature;
+    // temperature is in degrees Celsius.
+    unsigned int temperature;
+    int hasPower;
+    // power is in milliwatts.
+    unsigned int power;
+    int processCount;
+    unsigned int pids[MAXIMUM_PROCESSES];
+    unsigned long long processMemory[MAXIMUM_PROCESSES];
+} deviceSample;
+
+size_t getVRAMSize();
+int getDeviceVRAMSizes(unsigned long long* sizes, int max);
+int sampleDevices(deviceSample* samples, int max);
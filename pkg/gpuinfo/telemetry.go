@@ -0,0 +1,137 @@
+package gpuinfo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrNoTelemetry indicates that no GPU could be sampled, either because
+// there's none or because neither NVML nor ROCm SMI is available.
+var ErrNoTelemetry = errors.New("no GPU telemetry available")
+
+// DeviceSample is a sample of the telemetry of a GPU.
+type DeviceSample struct {
+	// Index is the device index, as used by e.g. CUDA_VISIBLE_DEVICES.
+	Index int
+	// Vendor is the vendor of the device: nvidia or amd.
+	Vendor string
+	// Name is the product name of the device, if known.
+	Name string
+	// Utilization is the percentage of time that the device was busy over
+	// the last sampling period of the driver, if known.
+	Utilization *float64
+	// MemoryUsed is the memory in use in bytes.
+	MemoryUsed uint64
+	// MemoryTotal is the total memory of the device in bytes.
+	MemoryTotal uint64
+	// Temperature is the temperature of the device in degrees Celsius, if
+	// known.
+	Temperature *float64
+	// Power is the power draw of the device in watts, if known.
+	Power *float64
+	// Processes are the processes using the device.
+	Processes []ProcessSample
+}
+
+// ProcessSample is the usage of a GPU by a process.
+type ProcessSample struct {
+	// PID is the ID of the process.
+	PID int
+	// Memory is the memory of the device used by the process in bytes, or
+	// zero if it's unknown.
+	Memory uint64
+}
+
+// Sample samples the telemetry of the GPUs on the system, which is read via
+// NVML for NVIDIA GPUs and ROCm SMI for AMD GPUs. It returns ErrNoTelemetry
+// if no GPU could be sampled.
+func (g *GPUInfo) Sample() ([]DeviceSample, error) {
+	return sampleDevices()
+}
+
+// rocmCardPattern matches the keys of the cards in the output of ROCm SMI.
+var rocmCardPattern = regexp.MustCompile(`^card(\d+)$`)
+
+// parseROCmSMI parses the JSON output of rocm-smi --showuse --showmeminfo vram
+// --showtemp --showpower --showproductname --showpids --json. ROCm SMI
+// doesn't report which devices processes use, so processes are only listed
+// on hosts with a single device.
+func parseROCmSMI(output []byte) ([]DeviceSample, error) {
+	var cards map[string]map[string]any
+	if err := json.Unmarshal(output, &cards); err != nil {
+		return nil, fmt.Errorf("parsing rocm-smi output: %w", err)
+	}
+
+	var devices []DeviceSample
+	for key, fields := range cards {
+		match := rocmCardPattern.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[1])
+		device := DeviceSample{Index: index, Vendor: "amd", Name: rocmField(fields, "Card Series")}
+		if device.Name == "" {
+			device.Name = rocmField(fields, "Card series")
+		}
+		device.Utilization = rocmFloat(fields, "GPU use (%)")
+		device.MemoryUsed, _ = strconv.ParseUint(rocmField(fields, "VRAM Total Used Memory (B)"), 10, 64)
+		device.MemoryTotal, _ = strconv.ParseUint(rocmField(fields, "VRAM Total Memory (B)"), 10, 64)
+		device.Temperature = rocmFloat(fields, "Temperature (Sensor edge) (C)", "Temperature (Sensor junction) (C)")
+		device.Power = rocmFloat(fields, "Average Graphics Package Power (W)", "Current Socket Graphics Package Power (W)")
+		devices = append(devices, device)
+	}
+	slices.SortFunc(devices, func(a, b DeviceSample) int {
+		return a.Index - b.Index
+	})
+
+	// Processes are listed in the system section by --showpids, as "name,
+	// number of GPUs, VRAM, SDMA, CU occupancy" under the key PID<pid>.
+	if len(devices) != 1 {
+		return devices, nil
+	}
+	system := cards["system"]
+	for key := range system {
+		pid, err := strconv.Atoi(strings.TrimPrefix(key, "PID"))
+		if err != nil || !strings.HasPrefix(key, "PID") {
+			continue
+		}
+		process := ProcessSample{PID: pid}
+		if fields := strings.Split(rocmField(system, key), ","); len(fields) >= 3 {
+			process.Memory, _ = strconv.ParseUint(strings.TrimSpace(fields[2]), 10, 64)
+		}
+		devices[0].Processes = append(devices[0].Processes, process)
+	}
+	slices.SortFunc(devices[0].Processes, func(a, b ProcessSample) int {
+		return a.PID - b.PID
+	})
+	return devices, nil
+}
+
+// rocmField returns a field of the output of ROCm SMI as a string, which
+// most fields are reported as.
+func rocmField(fields map[string]any, key string) string {
+	switch value := fields[key].(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// rocmFloat returns the value of the first of the fields of a card that's a
+// number, if any.
+func rocmFloat(fields map[string]any, keys ...string) *float64 {
+	for _, key := range keys {
+		if value, err := strconv.ParseFloat(rocmField(fields, key), 64); err == nil {
+			return &value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+//go:build linux
+
+package gpuinfo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// rocmSMIPath is the path of ROCm SMI in ROCm installations, for when it
+// isn't on the PATH.
+const rocmSMIPath = "/opt/rocm/bin/rocm-smi"
+
+// rocmSMITimeout bounds the time that ROCm SMI may take to report.
+const rocmSMITimeout = 10 * time.Second
+
+// sampleDevices samples the NVIDIA GPUs on the system or, if there's none,
+// the AMD GPUs.
+func sampleDevices() ([]DeviceSample, error) {
+	if devices, ok := sampleNVIDIADevices(); ok && len(devices) > 0 {
+		return devices, nil
+	}
+	devices, err := sampleAMDDevices()
+	if err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, ErrNoTelemetry
+	}
+	return devices, nil
+}
+
+// sampleAMDDevices samples the AMD GPUs on the system via ROCm SMI.
+func sampleAMDDevices() ([]DeviceSample, error) {
+	path, err := exec.LookPath("rocm-smi")
+	if err != nil {
+		if _, err := os.Stat(rocmSMIPath); err != nil {
+			return nil, ErrNoTelemetry
+		}
+		path = rocmSMIPath
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rocmSMITimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path,
+		"--showuse", "--showmeminfo", "vram", "--showtemp", "--showpower", "--showproductname", "--showpids", "--json",
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("running rocm-smi: %w", err)
+	}
+	// Warnings may precede the JSON output.
+	if i := bytes.IndexByte(output, '{'); i > 0 {
+		output = output[i:]
+	}
+	return parseROCmSMI(output)
+}
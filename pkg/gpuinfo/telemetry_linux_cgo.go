@@ -0,0 +1,53 @@
+//go:build linux && cgo
+
+package gpuinfo
+
+/*
+#cgo LDFLAGS: -ldl
+#include "nvidia.h"
+*/
+import "C"
+
+// sampleNVIDIADevices samples the nvidia GPUs on the system via NVML. It
+// returns false if NVML is unavailable.
+func sampleNVIDIADevices() ([]DeviceSample, bool) {
+	var samples [maximumDevices]C.deviceSample
+	count := int(C.sampleDevices(&samples[0], maximumDevices))
+	if count < 0 {
+		return nil, false
+	}
+	devices := make([]DeviceSample, 0, count)
+	for i := 0; i < count; i++ {
+		sample := &samples[i]
+		if sample.valid == 0 {
+			continue
+		}
+		device := DeviceSample{
+			Index:       i,
+			Vendor:      "nvidia",
+			Name:        C.GoString(&sample.name[0]),
+			MemoryUsed:  uint64(sample.memoryUsed),
+			MemoryTotal: uint64(sample.memoryTotal),
+		}
+		if sample.hasUtilization != 0 {
+			utilization := float64(sample.utilization)
+			device.Utilization = &utilization
+		}
+		if sample.hasTemperature != 0 {
+			temperature := float64(sample.temperature)
+			device.Temperature = &temperature
+		}
+		if sample.hasPower != 0 {
+			power := float64(sample.power) / 1000
+			device.Power = &power
+		}
+		for j := 0; j < int(sample.processCount); j++ {
+			device.Processes = append(device.Processes, ProcessSample{
+				PID:    int(sample.pids[j]),
+				Memory: uint64(sample.processMemory[j]),
+			})
+		}
+		devices = append(devices, device)
+	}
+	return devices, true
+}
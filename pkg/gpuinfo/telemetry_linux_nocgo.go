@@ -0,0 +1,9 @@
+//go:build linux && !cgo
+
+package gpuinfo
+
+// sampleNVIDIADevices samples the nvidia GPUs on the system, which requires
+// cgo to load NVML.
+func sampleNVIDIADevices() ([]DeviceSample, bool) {
+	return nil, false
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package gpuinfo
+
+// sampleDevices samples the GPUs on the system, which is only supported on
+// Linux.
+func sampleDevices() ([]DeviceSample, error) {
+	return nil, ErrNoTelemetry
+}
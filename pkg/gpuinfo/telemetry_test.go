@@ -0,0 +1,89 @@
+package gpuinfo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func TestParseROCmSMI(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []DeviceSample
+	}{
+		{
+			name: "single device with processes",
+			output: `{
+				"card0": {
+					"GPU use (%)": "87",
+					"Temperature (Sensor edge) (C)": "61.0",
+					"Average Graphics Package Power (W)": "212.0",
+					"VRAM Total Memory (B)": "25753026560",
+					"VRAM Total Used Memory (B)": "8589934592",
+					"Card Series": "Radeon RX 7900 XTX"
+				},
+				"system": {
+					"Driver version": "6.7.0",
+					"PID4242": "llama-server, 1, 4294967296, 0, 0",
+					"PID17": "python3, 1, 1073741824, 0, 0"
+				}
+			}`,
+			want: []DeviceSample{{
+				Index:       0,
+				Vendor:      "amd",
+				Name:        "Radeon RX 7900 XTX",
+				Utilization: float64Ptr(87),
+				MemoryUsed:  8589934592,
+				MemoryTotal: 25753026560,
+				Temperature: float64Ptr(61),
+				Power:       float64Ptr(212),
+				Processes:   []ProcessSample{{PID: 17, Memory: 1073741824}, {PID: 4242, Memory: 4294967296}},
+			}},
+		},
+		{
+			name: "several devices with missing readings",
+			output: `{
+				"card1": {
+					"GPU use (%)": "0",
+					"Current Socket Graphics Package Power (W)": "90.5",
+					"VRAM Total Memory (B)": "68702699520",
+					"VRAM Total Used Memory (B)": "0"
+				},
+				"card0": {
+					"GPU use (%)": "N/A",
+					"Temperature (Sensor junction) (C)": "40.0",
+					"VRAM Total Memory (B)": "68702699520",
+					"VRAM Total Used Memory (B)": "1048576"
+				},
+				"system": {
+					"PID4242": "llama-server, 2, 4294967296, 0, 0"
+				}
+			}`,
+			want: []DeviceSample{
+				{Index: 0, Vendor: "amd", MemoryUsed: 1048576, MemoryTotal: 68702699520, Temperature: float64Ptr(40)},
+				{Index: 1, Vendor: "amd", Utilization: float64Ptr(0), MemoryTotal: 68702699520, Power: float64Ptr(90.5)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseROCmSMI([]byte(tt.output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseROCmSMI() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseROCmSMIInvalid(t *testing.T) {
+	if _, err := parseROCmSMI([]byte("ERROR: GPU[0] : Unable to read")); err == nil {
+		t.Error("parseROCmSMI() succeeded on invalid output")
+	}
+}
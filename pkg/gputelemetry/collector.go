@@ -0,0 +1,193 @@
+// Package gputelemetry collects the telemetry of the GPUs on the system:
+// their utilization, memory, temperature, and power draw, along with the
+// memory used by the processes running on them, which is attributed to the
+// runners whose backend processes started them.
+package gputelemetry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/gpuinfo"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// DefaultInterval is the default interval at which GPUs are sampled.
+const DefaultInterval = 15 * time.Second
+
+// maximumAncestors is the number of ancestors of a process that are searched
+// for the backend process of a runner.
+const maximumAncestors = 16
+
+// Sampler samples the telemetry of GPUs.
+type Sampler interface {
+	// Sample samples the telemetry of the GPUs on the system.
+	Sample() ([]gpuinfo.DeviceSample, error)
+}
+
+// Runner is a runner whose backend process, or the processes that it
+// starts, may use GPUs.
+type Runner struct {
+	// PID is the PID of the runner's backend process.
+	PID int
+	// Backend is the name of the runner's backend.
+	Backend string
+	// Model is the model reference used to load the runner.
+	Model string
+}
+
+// Status is the latest sample of the telemetry of the GPUs, as described by
+// the GPU telemetry API.
+type Status struct {
+	// SampledAt is when the GPUs were last sampled, in Unix seconds, or zero
+	// if they haven't been yet.
+	SampledAt int64 `json:"sampled_at,omitempty"`
+	// Error describes why the GPUs couldn't be sampled, if they couldn't.
+	Error string `json:"error,omitempty"`
+	// Devices are the GPUs.
+	Devices []Device `json:"devices"`
+}
+
+// Device is the telemetry of a GPU.
+type Device struct {
+	Index       int       `json:"index"`
+	Vendor      string    `json:"vendor"`
+	Name        string    `json:"name,omitempty"`
+	Utilization *float64  `json:"utilization,omitempty"`
+	MemoryUsed  uint64    `json:"memory_used"`
+	MemoryTotal uint64    `json:"memory_total"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	Power       *float64  `json:"power,omitempty"`
+	Processes   []Process `json:"processes"`
+}
+
+// Process is the usage of a GPU by a process, along with the runner it's
+// attributed to, if any.
+type Process struct {
+	PID     int    `json:"pid"`
+	Memory  uint64 `json:"memory"`
+	Backend string `json:"backend,omitempty"`
+	Model   string `json:"model,omitempty"`
+}
+
+// Collector samples the telemetry of GPUs periodically and attributes the
+// processes using them to runners.
+type Collector struct {
+	// log is the associated logger.
+	log logging.Logger
+	// sampler samples the GPUs.
+	sampler Sampler
+	// runners returns the runners that processes are attributed to.
+	runners func(context.Context) []Runner
+	// interval is the interval between samples.
+	interval time.Duration
+	// parent returns the PID of the parent of a process.
+	parent func(pid int) (int, bool)
+	// lock protects the fields below.
+	lock sync.Mutex
+	// status is the latest sample.
+	status Status
+}
+
+// NewCollector creates a collector that samples GPUs at the specified
+// interval, or DefaultInterval if it isn't positive, and attributes the
+// processes using them to the runners returned by runners.
+func NewCollector(log logging.Logger, sampler Sampler, runners func(context.Context) []Runner, interval time.Duration) *Collector {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Collector{
+		log:      log,
+		sampler:  sampler,
+		runners:  runners,
+		interval: interval,
+		parent:   parentPID,
+	}
+}
+
+// Run samples the GPUs until ctx is cancelled.
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.collect(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the latest sample.
+func (c *Collector) Status() Status {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.status
+}
+
+// collect samples the GPUs and records the sample.
+func (c *Collector) collect(ctx context.Context) {
+	samples, err := c.sampler.Sample()
+	status := Status{SampledAt: time.Now().Unix(), Devices: []Device{}}
+	if err != nil {
+		status.Error = err.Error()
+		if c.Status().Error != status.Error {
+			if errors.Is(err, gpuinfo.ErrNoTelemetry) {
+				c.log.Infof("GPU telemetry unavailable: %v", err)
+			} else {
+				c.log.Warnf("Failed to sample GPUs: %v", err)
+			}
+		}
+	}
+
+	runners := make(map[int]Runner)
+	if len(samples) > 0 {
+		for _, runner := range c.runners(ctx) {
+			runners[runner.PID] = runner
+		}
+	}
+	for _, sample := range samples {
+		device := Device{
+			Index:       sample.Index,
+			Vendor:      sample.Vendor,
+			Name:        sample.Name,
+			Utilization: sample.Utilization,
+			MemoryUsed:  sample.MemoryUsed,
+			MemoryTotal: sample.MemoryTotal,
+			Temperature: sample.Temperature,
+			Power:       sample.Power,
+			Processes:   []Process{},
+		}
+		for _, process := range sample.Processes {
+			attributed := Process{PID: process.PID, Memory: process.Memory}
+			if runner, ok := c.owner(process.PID, runners); ok {
+				attributed.Backend, attributed.Model = runner.Backend, runner.Model
+			}
+			device.Processes = append(device.Processes, attributed)
+		}
+		status.Devices = append(status.Devices, device)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.status = status
+}
+
+// owner returns the runner whose backend process is a process or one of its
+// ancestors, since backends may run their models in child processes.
+func (c *Collector) owner(pid int, runners map[int]Runner) (Runner, bool) {
+	for range maximumAncestors {
+		if runner, ok := runners[pid]; ok {
+			return runner, true
+		}
+		parent, ok := c.parent(pid)
+		if !ok || parent <= 1 {
+			break
+		}
+		pid = parent
+	}
+	return Runner{}, false
+}
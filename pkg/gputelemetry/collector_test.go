@@ -0,0 +1,131 @@
+package gputelemetry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/gpuinfo"
+	"github.com/prometheus/common/expfmt"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeSampler returns fixed samples.
+type fakeSampler struct {
+	samples []gpuinfo.DeviceSample
+	err     error
+}
+
+func (s *fakeSampler) Sample() ([]gpuinfo.DeviceSample, error) {
+	return s.samples, s.err
+}
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func newTestCollector(sampler Sampler, runners []Runner, parents map[int]int) *Collector {
+	c := NewCollector(logrus.NewEntry(logrus.New()), sampler, func(context.Context) []Runner { return runners }, 0)
+	c.parent = func(pid int) (int, bool) {
+		parent, ok := parents[pid]
+		return parent, ok
+	}
+	return c
+}
+
+func TestCollectAttributesProcesses(t *testing.T) {
+	sampler := &fakeSampler{samples: []gpuinfo.DeviceSample{
+		{
+			Index: 0, Vendor: "nvidia", Name: "NVIDIA L4",
+			Utilization: float64Ptr(93), MemoryUsed: 12 << 30, MemoryTotal: 24 << 30,
+			Temperature: float64Ptr(71), Power: float64Ptr(68.5),
+			Processes: []gpuinfo.ProcessSample{
+				{PID: 100, Memory: 4 << 30},
+				{PID: 201, Memory: 6 << 30},
+				{PID: 202, Memory: 1 << 30},
+				{PID: 999, Memory: 1 << 30},
+			},
+		},
+		{Index: 1, Vendor: "nvidia", Name: "NVIDIA L4", MemoryTotal: 24 << 30},
+	}}
+	runners := []Runner{
+		{PID: 100, Backend: "llama.cpp", Model: "ai/smollm2"},
+		{PID: 200, Backend: "vllm", Model: "ai/qwen3"},
+	}
+	// The vLLM backend runs the model in child processes.
+	parents := map[int]int{201: 200, 202: 201, 200: 1, 999: 1}
+	c := newTestCollector(sampler, runners, parents)
+	c.collect(context.Background())
+
+	status := c.Status()
+	if status.SampledAt == 0 || status.Error != "" {
+		t.Errorf("status = %+v, want a sample without error", status)
+	}
+	want := []Process{
+		{PID: 100, Memory: 4 << 30, Backend: "llama.cpp", Model: "ai/smollm2"},
+		{PID: 201, Memory: 6 << 30, Backend: "vllm", Model: "ai/qwen3"},
+		{PID: 202, Memory: 1 << 30, Backend: "vllm", Model: "ai/qwen3"},
+		{PID: 999, Memory: 1 << 30},
+	}
+	if len(status.Devices) != 2 {
+		t.Fatalf("got %d devices, want 2", len(status.Devices))
+	}
+	if !reflect.DeepEqual(status.Devices[0].Processes, want) {
+		t.Errorf("processes = %+v, want %+v", status.Devices[0].Processes, want)
+	}
+	if status.Devices[1].Processes == nil || len(status.Devices[1].Processes) != 0 {
+		t.Errorf("processes of idle device = %#v, want an empty list", status.Devices[1].Processes)
+	}
+
+	var exposition bytes.Buffer
+	for _, family := range c.Families() {
+		if _, err := expfmt.MetricFamilyToText(&exposition, family); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, line := range []string{
+		`model_runner_gpu_utilization_percent{device="0",name="NVIDIA L4"} 93`,
+		`model_runner_gpu_memory_used_bytes{device="1",name="NVIDIA L4"} 0`,
+		`model_runner_gpu_memory_total_bytes{device="0",name="NVIDIA L4"} 2.5769803776e+10`,
+		`model_runner_gpu_temperature_celsius{device="0",name="NVIDIA L4"} 71`,
+		`model_runner_gpu_power_watts{device="0",name="NVIDIA L4"} 68.5`,
+		`model_runner_gpu_runner_memory_bytes{device="0",model="ai/qwen3",backend="vllm"} 7.516192768e+09`,
+		`model_runner_gpu_runner_memory_bytes{device="0",model="ai/smollm2",backend="llama.cpp"} 4.294967296e+09`,
+	} {
+		if !strings.Contains(exposition.String(), line+"\n") {
+			t.Errorf("metrics don't contain %s:\n%s", line, exposition.String())
+		}
+	}
+	if strings.Contains(exposition.String(), `model_runner_gpu_utilization_percent{device="1"`) {
+		t.Errorf("metrics contain the unknown utilization of device 1:\n%s", exposition.String())
+	}
+}
+
+func TestCollectError(t *testing.T) {
+	sampler := &fakeSampler{err: gpuinfo.ErrNoTelemetry}
+	c := newTestCollector(sampler, nil, nil)
+	c.collect(context.Background())
+
+	status := c.Status()
+	if status.Error != gpuinfo.ErrNoTelemetry.Error() || len(status.Devices) != 0 {
+		t.Errorf("status = %+v, want the error", status)
+	}
+	if families := c.Families(); len(families) != 0 {
+		t.Errorf("got %d metric families, want none", len(families))
+	}
+
+	// Samples replace errors.
+	sampler.samples, sampler.err = []gpuinfo.DeviceSample{{Index: 0, Vendor: "amd"}}, nil
+	c.collect(context.Background())
+	if status := c.Status(); status.Error != "" || len(status.Devices) != 1 {
+		t.Errorf("status = %+v, want the sample", status)
+	}
+	sampler.samples, sampler.err = nil, errors.New("rocm-smi: exit status 2")
+	c.collect(context.Background())
+	if status := c.Status(); status.Error != "rocm-smi: exit status 2" || len(status.Devices) != 0 {
+		t.Errorf("status = %+v, want the error", status)
+	}
+}
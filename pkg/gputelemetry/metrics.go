@@ -0,0 +1,84 @@
+package gputelemetry
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// Families returns the metric families of the latest sample: a gauge of each
+// quantity by device, along with the memory used by the runners on each
+// device.
+func (c *Collector) Families() []*dto.MetricFamily {
+	status := c.Status()
+	utilization := newGauge("model_runner_gpu_utilization_percent", "Percentage of time that the GPU was busy.")
+	memoryUsed := newGauge("model_runner_gpu_memory_used_bytes", "Memory of the GPU in use.")
+	memoryTotal := newGauge("model_runner_gpu_memory_total_bytes", "Total memory of the GPU.")
+	temperature := newGauge("model_runner_gpu_temperature_celsius", "Temperature of the GPU.")
+	power := newGauge("model_runner_gpu_power_watts", "Power draw of the GPU.")
+	runnerMemory := newGauge("model_runner_gpu_runner_memory_bytes", "Memory of the GPU used by the processes of the runners of a model.")
+
+	for _, device := range status.Devices {
+		labels := []string{"device", strconv.Itoa(device.Index), "name", device.Name}
+		addGauge(utilization, device.Utilization, labels...)
+		memoryUsed.Metric = append(memoryUsed.Metric, gauge(float64(device.MemoryUsed), labels...))
+		memoryTotal.Metric = append(memoryTotal.Metric, gauge(float64(device.MemoryTotal), labels...))
+		addGauge(temperature, device.Temperature, labels...)
+		addGauge(power, device.Power, labels...)
+
+		// Sum the memory of the processes of each runner.
+		type runnerKey struct{ backend, model string }
+		var keys []runnerKey
+		memory := make(map[runnerKey]uint64)
+		for _, process := range device.Processes {
+			if process.Backend == "" {
+				continue
+			}
+			key := runnerKey{process.Backend, process.Model}
+			if _, ok := memory[key]; !ok {
+				keys = append(keys, key)
+			}
+			memory[key] += process.Memory
+		}
+		slices.SortFunc(keys, func(a, b runnerKey) int {
+			return cmp.Or(cmp.Compare(a.model, b.model), cmp.Compare(a.backend, b.backend))
+		})
+		for _, key := range keys {
+			runnerMemory.Metric = append(runnerMemory.Metric, gauge(float64(memory[key]),
+				"device", strconv.Itoa(device.Index), "model", key.model, "backend", key.backend))
+		}
+	}
+
+	var families []*dto.MetricFamily
+	for _, family := range []*dto.MetricFamily{utilization, memoryUsed, memoryTotal, temperature, power, runnerMemory} {
+		if len(family.Metric) > 0 {
+			families = append(families, family)
+		}
+	}
+	return families
+}
+
+// newGauge creates an empty gauge family.
+func newGauge(name, help string) *dto.MetricFamily {
+	return &dto.MetricFamily{Name: proto.String(name), Help: proto.String(help), Type: dto.MetricType_GAUGE.Enum()}
+}
+
+// addGauge adds a series to a gauge family if its value is known.
+func addGauge(family *dto.MetricFamily, value *float64, labels ...string) {
+	if value != nil {
+		family.Metric = append(family.Metric, gauge(*value, labels...))
+	}
+}
+
+// gauge returns a series of a gauge with the specified value and labels,
+// which are given as pairs of names and values.
+func gauge(value float64, labels ...string) *dto.Metric {
+	metric := &dto.Metric{Gauge: &dto.Gauge{Value: proto.Float64(value)}}
+	for i := 0; i+1 < len(labels); i += 2 {
+		metric.Label = append(metric.Label, &dto.LabelPair{Name: proto.String(labels[i]), Value: proto.String(labels[i+1])})
+	}
+	return metric
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+package gputelemetry
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// parentPID returns the PID of the parent of a process, from its status in
+// procfs.
+func parentPID(pid int) (int, bool) {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0, false
+	}
+	// The status is "pid (comm) state ppid ...", where comm may contain
+	// spaces and parentheses.
+	end := bytes.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, false
+	}
+	fields := bytes.Fields(stat[end+1:])
+	if len(fields) < 2 {
+		return 0, false
+	}
+	parent, err := strconv.Atoi(string(fields[1]))
+	return parent, err == nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package gputelemetry
+
+// parentPID returns the PID of the parent of a process, which is only
+// supported on Linux, where GPUs are sampled.
+func parentPID(int) (int, bool) {
+	return 0, false
+}
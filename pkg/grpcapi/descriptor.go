@@ -0,0 +1,236 @@
+package grpcapi
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	// protoPackage is the protocol buffers package of the service.
+	protoPackage = "modelrunner.inference.v1"
+	// ServiceName is the full name of the gRPC service.
+	ServiceName = protoPackage + ".Inference"
+)
+
+// The types of the fields of messages.
+const (
+	typeString = descriptorpb.FieldDescriptorProto_TYPE_STRING
+	typeDouble = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+	typeFloat  = descriptorpb.FieldDescriptorProto_TYPE_FLOAT
+	typeInt32  = descriptorpb.FieldDescriptorProto_TYPE_INT32
+	typeInt64  = descriptorpb.FieldDescriptorProto_TYPE_INT64
+)
+
+// fieldSpec describes a field of a message.
+type fieldSpec struct {
+	// name is the name of the field, which is that of the field of the
+	// OpenAI API that it mirrors, so that messages translate to and from
+	// JSON by name.
+	name string
+	// number is the field number.
+	number int32
+	// kind is the type of scalar fields.
+	kind descriptorpb.FieldDescriptorProto_Type
+	// message is the name of the message type of message fields.
+	message string
+	// repeated indicates whether the field is repeated.
+	repeated bool
+	// optional indicates whether the presence of a scalar field is tracked,
+	// so that zero values, such as a temperature of 0, are sent.
+	optional bool
+}
+
+// messageSpec describes a message.
+type messageSpec struct {
+	name   string
+	fields []fieldSpec
+}
+
+// methodSpec describes a method of the service.
+type methodSpec struct {
+	name   string
+	input  string
+	output string
+	// stream indicates whether the server streams its responses.
+	stream bool
+}
+
+// samplingFields are the sampling fields of completion and chat completion
+// requests, along with a JSON object of the other fields of the OpenAI
+// request, such as tools or response_format, which is merged into it.
+var samplingFields = []fieldSpec{
+	{name: "temperature", number: 3, kind: typeDouble, optional: true},
+	{name: "top_p", number: 4, kind: typeDouble, optional: true},
+	{name: "max_tokens", number: 5, kind: typeInt32, optional: true},
+	{name: "stop", number: 6, kind: typeString, repeated: true},
+	{name: "seed", number: 7, kind: typeInt32, optional: true},
+	{name: "extra_json", number: 15, kind: typeString},
+}
+
+// messages are the messages of the service, in the order in which they're
+// declared.
+var messages = []messageSpec{
+	{name: "Usage", fields: []fieldSpec{
+		{name: "prompt_tokens", number: 1, kind: typeInt32},
+		{name: "completion_tokens", number: 2, kind: typeInt32},
+		{name: "total_tokens", number: 3, kind: typeInt32},
+	}},
+	{name: "Timings", fields: []fieldSpec{
+		{name: "time_to_first_token_ms", number: 1, kind: typeDouble},
+		{name: "generation_ms", number: 2, kind: typeDouble},
+		{name: "tokens_per_second", number: 3, kind: typeDouble},
+	}},
+	{name: "FunctionCall", fields: []fieldSpec{
+		{name: "name", number: 1, kind: typeString},
+		{name: "arguments", number: 2, kind: typeString},
+	}},
+	{name: "ToolCall", fields: []fieldSpec{
+		{name: "index", number: 1, kind: typeInt32},
+		{name: "id", number: 2, kind: typeString},
+		{name: "type", number: 3, kind: typeString},
+		{name: "function", number: 4, message: "FunctionCall"},
+	}},
+	{name: "ChatMessage", fields: []fieldSpec{
+		{name: "role", number: 1, kind: typeString},
+		{name: "content", number: 2, kind: typeString},
+		{name: "reasoning_content", number: 3, kind: typeString},
+		{name: "name", number: 4, kind: typeString},
+		{name: "tool_call_id", number: 5, kind: typeString},
+		{name: "tool_calls", number: 6, message: "ToolCall", repeated: true},
+	}},
+	{name: "ChatCompletionRequest", fields: append([]fieldSpec{
+		{name: "model", number: 1, kind: typeString},
+		{name: "messages", number: 2, message: "ChatMessage", repeated: true},
+	}, samplingFields...)},
+	{name: "ChatCompletionChoice", fields: []fieldSpec{
+		{name: "index", number: 1, kind: typeInt32},
+		{name: "message", number: 2, message: "ChatMessage"},
+		{name: "finish_reason", number: 3, kind: typeString},
+	}},
+	{name: "ChatCompletionResponse", fields: []fieldSpec{
+		{name: "id", number: 1, kind: typeString},
+		{name: "model", number: 2, kind: typeString},
+		{name: "created", number: 3, kind: typeInt64},
+		{name: "choices", number: 4, message: "ChatCompletionChoice", repeated: true},
+		{name: "usage", number: 5, message: "Usage"},
+	}},
+	{name: "ChatCompletionChunkChoice", fields: []fieldSpec{
+		{name: "index", number: 1, kind: typeInt32},
+		{name: "delta", number: 2, message: "ChatMessage"},
+		{name: "finish_reason", number: 3, kind: typeString},
+	}},
+	{name: "ChatCompletionChunk", fields: []fieldSpec{
+		{name: "id", number: 1, kind: typeString},
+		{name: "model", number: 2, kind: typeString},
+		{name: "created", number: 3, kind: typeInt64},
+		{name: "choices", number: 4, message: "ChatCompletionChunkChoice", repeated: true},
+		{name: "usage", number: 5, message: "Usage"},
+		{name: "timings", number: 6, message: "Timings"},
+	}},
+	{name: "CompletionRequest", fields: append([]fieldSpec{
+		{name: "model", number: 1, kind: typeString},
+		{name: "prompt", number: 2, kind: typeString},
+	}, samplingFields...)},
+	{name: "CompletionChoice", fields: []fieldSpec{
+		{name: "index", number: 1, kind: typeInt32},
+		{name: "text", number: 2, kind: typeString},
+		{name: "finish_reason", number: 3, kind: typeString},
+	}},
+	{name: "CompletionResponse", fields: []fieldSpec{
+		{name: "id", number: 1, kind: typeString},
+		{name: "model", number: 2, kind: typeString},
+		{name: "created", number: 3, kind: typeInt64},
+		{name: "choices", number: 4, message: "CompletionChoice", repeated: true},
+		{name: "usage", number: 5, message: "Usage"},
+		{name: "timings", number: 6, message: "Timings"},
+	}},
+	{name: "EmbeddingsRequest", fields: []fieldSpec{
+		{name: "model", number: 1, kind: typeString},
+		{name: "input", number: 2, kind: typeString, repeated: true},
+		{name: "dimensions", number: 3, kind: typeInt32, optional: true},
+		{name: "extra_json", number: 15, kind: typeString},
+	}},
+	{name: "Embedding", fields: []fieldSpec{
+		{name: "index", number: 1, kind: typeInt32},
+		{name: "embedding", number: 2, kind: typeFloat, repeated: true},
+	}},
+	{name: "EmbeddingsResponse", fields: []fieldSpec{
+		{name: "model", number: 1, kind: typeString},
+		{name: "data", number: 2, message: "Embedding", repeated: true},
+		{name: "usage", number: 3, message: "Usage"},
+	}},
+}
+
+// methods are the methods of the service.
+var methods = []methodSpec{
+	{name: "ChatCompletion", input: "ChatCompletionRequest", output: "ChatCompletionResponse"},
+	{name: "StreamChatCompletion", input: "ChatCompletionRequest", output: "ChatCompletionChunk", stream: true},
+	{name: "Completion", input: "CompletionRequest", output: "CompletionResponse"},
+	{name: "StreamCompletion", input: "CompletionRequest", output: "CompletionResponse", stream: true},
+	{name: "Embeddings", input: "EmbeddingsRequest", output: "EmbeddingsResponse"},
+}
+
+// fileDescriptorProto returns the descriptor of the file declaring the
+// service, which inference.proto declares for clients.
+func fileDescriptorProto() *descriptorpb.FileDescriptorProto {
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("inference.proto"),
+		Package: proto.String(protoPackage),
+		Syntax:  proto.String("proto3"),
+	}
+	for _, m := range messages {
+		message := &descriptorpb.DescriptorProto{Name: proto.String(m.name)}
+		for _, f := range m.fields {
+			field := &descriptorpb.FieldDescriptorProto{
+				Name:   proto.String(f.name),
+				Number: proto.Int32(f.number),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:   f.kind.Enum(),
+			}
+			if f.message != "" {
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				field.TypeName = proto.String("." + protoPackage + "." + f.message)
+			}
+			if f.repeated {
+				field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			}
+			if f.optional {
+				// Optional fields of proto3 are members of synthetic oneofs.
+				field.Proto3Optional = proto.Bool(true)
+				field.OneofIndex = proto.Int32(int32(len(message.OneofDecl)))
+				message.OneofDecl = append(message.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + f.name)})
+			}
+			message.Field = append(message.Field, field)
+		}
+		file.MessageType = append(file.MessageType, message)
+	}
+	service := &descriptorpb.ServiceDescriptorProto{Name: proto.String("Inference")}
+	for _, m := range methods {
+		service.Method = append(service.Method, &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(m.name),
+			InputType:       proto.String("." + protoPackage + "." + m.input),
+			OutputType:      proto.String("." + protoPackage + "." + m.output),
+			ServerStreaming: proto.Bool(m.stream),
+		})
+	}
+	file.Service = append(file.Service, service)
+	return file
+}
+
+// file is the descriptor of the file declaring the service.
+var file = func() protoreflect.FileDescriptor {
+	file, err := protodesc.NewFile(fileDescriptorProto(), nil)
+	if err != nil {
+		panic(fmt.Sprintf("invalid inference service descriptor: %v", err))
+	}
+	return file
+}()
+
+// messageDescriptor returns the descriptor of a message of the service.
+func messageDescriptor(name string) protoreflect.MessageDescriptor {
+	return file.Messages().ByName(protoreflect.Name(name))
+}
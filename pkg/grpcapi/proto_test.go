@@ -0,0 +1,69 @@
+package grpcapi
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// renderProto renders the declarations of a file descriptor in the
+// protocol buffers language, without comments or blank lines.
+func renderProto(file *descriptorpb.FileDescriptorProto) []string {
+	lines := []string{
+		fmt.Sprintf("syntax = %q;", file.GetSyntax()),
+		fmt.Sprintf("package %s;", file.GetPackage()),
+	}
+	typeName := func(field *descriptorpb.FieldDescriptorProto) string {
+		if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+			return strings.TrimPrefix(field.GetTypeName(), "."+file.GetPackage()+".")
+		}
+		return strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+	}
+	for _, message := range file.GetMessageType() {
+		lines = append(lines, fmt.Sprintf("message %s {", message.GetName()))
+		for _, field := range message.GetField() {
+			label := ""
+			if field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+				label = "repeated "
+			} else if field.GetProto3Optional() {
+				label = "optional "
+			}
+			lines = append(lines, fmt.Sprintf("  %s%s %s = %d;", label, typeName(field), field.GetName(), field.GetNumber()))
+		}
+		lines = append(lines, "}")
+	}
+	for _, service := range file.GetService() {
+		lines = append(lines, fmt.Sprintf("service %s {", service.GetName()))
+		for _, method := range service.GetMethod() {
+			output := strings.TrimPrefix(method.GetOutputType(), "."+file.GetPackage()+".")
+			if method.GetServerStreaming() {
+				output = "stream " + output
+			}
+			input := strings.TrimPrefix(method.GetInputType(), "."+file.GetPackage()+".")
+			lines = append(lines, fmt.Sprintf("  rpc %s(%s) returns (%s);", method.GetName(), input, output))
+		}
+		lines = append(lines, "}")
+	}
+	return lines
+}
+
+func TestProtoFile(t *testing.T) {
+	data, err := os.ReadFile("inference.proto")
+	if err != nil {
+		t.Fatalf("Failed to read inference.proto: %v", err)
+	}
+	var declarations []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		declarations = append(declarations, line)
+	}
+	expected := renderProto(fileDescriptorProto())
+	if got, want := strings.Join(declarations, "\n"), strings.Join(expected, "\n"); got != want {
+		t.Errorf("inference.proto doesn't declare the service, expected declarations:\n%s", want)
+	}
+}
@@ -0,0 +1,329 @@
+// Package grpcapi serves a gRPC inference API, mirroring the chat
+// completions, completions, and embeddings of the OpenAI API, alongside HTTP,
+// for services that prefer protocol buffers over parsing JSON and server-sent
+// events. Requests are translated to requests of the inference API, served by
+// the scheduler, whose responses, or the chunks of their streams, are
+// translated back. Messages are declared by inference.proto and built at run
+// time, so the API doesn't depend on generated code.
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/internal/relay"
+	"github.com/docker/model-runner/pkg/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// endpoints are the paths of the inference endpoints that serve the methods
+// of the service.
+var endpoints = map[string]string{
+	"ChatCompletion":       "/v1/chat/completions",
+	"StreamChatCompletion": "/v1/chat/completions",
+	"Completion":           "/v1/completions",
+	"StreamCompletion":     "/v1/completions",
+	"Embeddings":           "/v1/embeddings",
+}
+
+// server implements the service on top of the inference API.
+type server struct {
+	// log is the associated logger.
+	log logging.Logger
+	// next is the inference handler.
+	next http.Handler
+}
+
+// NewServer creates a gRPC server serving the inference API with next, which
+// should serve the inference API over HTTP.
+func NewServer(log logging.Logger, next http.Handler) *grpc.Server {
+	s := &server{log: log, next: next}
+	desc := grpc.ServiceDesc{
+		ServiceName: ServiceName,
+		HandlerType: (*any)(nil),
+		Metadata:    "inference.proto",
+	}
+	for _, m := range methods {
+		if m.stream {
+			desc.Streams = append(desc.Streams, s.streamHandler(m))
+		} else {
+			desc.Methods = append(desc.Methods, s.unaryHandler(m))
+		}
+	}
+	grpcServer := grpc.NewServer()
+	grpcServer.RegisterService(&desc, s)
+	return grpcServer
+}
+
+// unaryHandler returns the gRPC handler of a method whose response isn't
+// streamed.
+func (s *server) unaryHandler(m methodSpec) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: m.name,
+		Handler: func(_ any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			request := dynamicpb.NewMessage(messageDescriptor(m.input))
+			if err := dec(request); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, request any) (any, error) {
+				return s.call(ctx, m, request.(proto.Message))
+			}
+			if interceptor == nil {
+				return handler(ctx, request)
+			}
+			return interceptor(ctx, request, &grpc.UnaryServerInfo{
+				Server:     s,
+				FullMethod: "/" + ServiceName + "/" + m.name,
+			}, handler)
+		},
+	}
+}
+
+// streamHandler returns the gRPC handler of a method whose responses are
+// streamed.
+func (s *server) streamHandler(m methodSpec) grpc.StreamDesc {
+	return grpc.StreamDesc{
+		StreamName:    m.name,
+		ServerStreams: true,
+		Handler: func(_ any, stream grpc.ServerStream) error {
+			request := dynamicpb.NewMessage(messageDescriptor(m.input))
+			if err := stream.RecvMsg(request); err != nil {
+				return err
+			}
+			return s.stream(stream, m, request)
+		},
+	}
+}
+
+// call serves a request whose response isn't streamed.
+func (s *server) call(ctx context.Context, m methodSpec, request proto.Message) (proto.Message, error) {
+	body, err := requestBody(request, false)
+	if err != nil {
+		return nil, err
+	}
+	recorder := relay.NewRecorder()
+	s.next.ServeHTTP(recorder, s.httpRequest(ctx, m, body))
+	if recorder.Status() != http.StatusOK {
+		return nil, statusError(recorder.Status(), recorder.Body())
+	}
+	response := dynamicpb.NewMessage(messageDescriptor(m.output))
+	if err := unmarshalOptions.Unmarshal(recorder.Body(), response); err != nil {
+		s.log.Warnf("Failed to translate %s response: %v", m.name, err)
+		return nil, status.Errorf(codes.Internal, "invalid response from backend: %v", err)
+	}
+	return response, nil
+}
+
+// stream serves a request whose response is streamed, sending its chunks as
+// they're received.
+func (s *server) stream(stream grpc.ServerStream, m methodSpec, request proto.Message) error {
+	body, err := requestBody(request, true)
+	if err != nil {
+		return err
+	}
+	w := &streamWriter{header: make(http.Header), stream: stream, method: m}
+	s.next.ServeHTTP(w, s.httpRequest(stream.Context(), m, body))
+	if w.err != nil {
+		return w.err
+	}
+	if w.status != http.StatusOK {
+		return statusError(w.status, w.body.Bytes())
+	}
+	return nil
+}
+
+// httpRequest returns the inference API request serving a method, with the
+// metadata of the call, such as its authorization or priority, as headers.
+func (s *server) httpRequest(ctx context.Context, m methodSpec, body []byte) *http.Request {
+	r, _ := http.NewRequestWithContext(ctx, http.MethodPost, inference.InferencePrefix+endpoints[m.name], bytes.NewReader(body))
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			if strings.HasPrefix(key, ":") || strings.HasPrefix(key, "grpc-") || key == "content-type" || key == "te" {
+				continue
+			}
+			for _, value := range values {
+				r.Header.Add(key, value)
+			}
+		}
+	}
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+// marshalOptions translate requests to JSON with the names of the fields of
+// the OpenAI API.
+var marshalOptions = protojson.MarshalOptions{UseProtoNames: true}
+
+// unmarshalOptions translate responses from JSON, ignoring the fields of the
+// OpenAI API that messages don't mirror.
+var unmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// requestBody translates a request to the body of an inference API request,
+// merging in the fields of its extra_json object that it doesn't set.
+// Streams report their usage in their last chunk.
+func requestBody(request proto.Message, stream bool) ([]byte, error) {
+	encoded, err := marshalOptions.Marshal(request)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &body); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode request: %v", err)
+	}
+	if extra := body["extra_json"]; extra != nil {
+		delete(body, "extra_json")
+		var text string
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(extra, &text); err != nil || json.Unmarshal([]byte(text), &fields) != nil {
+			return nil, status.Error(codes.InvalidArgument, "extra_json must be a JSON object")
+		}
+		for key, value := range fields {
+			if _, ok := body[key]; !ok {
+				body[key] = value
+			}
+		}
+	}
+	if stream {
+		body["stream"] = json.RawMessage("true")
+		body["stream_options"] = json.RawMessage(`{"include_usage":true}`)
+	} else {
+		delete(body, "stream")
+		delete(body, "stream_options")
+	}
+	encoded, err = json.Marshal(body)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode request: %v", err)
+	}
+	return encoded, nil
+}
+
+// statusError translates an error response of the inference API to a gRPC
+// status.
+func statusError(httpStatus int, body []byte) error {
+	message := strings.TrimSpace(string(body))
+	var response struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &response) == nil && response.Error.Message != "" {
+		message = response.Error.Message
+	}
+	code := codes.Internal
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		code = codes.DeadlineExceeded
+	case http.StatusNotImplemented:
+		code = codes.Unimplemented
+	}
+	return status.Error(code, message)
+}
+
+// streamWriter translates the server-sent events of a streamed response of
+// the inference API to messages sent on a gRPC stream as they're written.
+// Error responses are recorded.
+type streamWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	// stream is the gRPC stream.
+	stream grpc.ServerStream
+	// method is the method being served.
+	method methodSpec
+	// body is the incomplete line written last, or the body of error
+	// responses.
+	body bytes.Buffer
+	// err is the error that ended the stream, if any.
+	err error
+}
+
+// Header implements net/http.ResponseWriter.Header.
+func (w *streamWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements net/http.ResponseWriter.WriteHeader.
+func (w *streamWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+}
+
+// Write implements net/http.ResponseWriter.Write.
+func (w *streamWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.body.Write(data)
+	if w.status != http.StatusOK {
+		return len(data), nil
+	}
+	for {
+		line, _, found := bytes.Cut(w.body.Bytes(), []byte("\n"))
+		if !found {
+			break
+		}
+		err := w.send(line)
+		w.body.Next(len(line) + 1)
+		if err != nil {
+			w.err = err
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// send sends the chunk of a line of the stream, if it has one. Error events
+// end the stream.
+func (w *streamWriter) send(line []byte) error {
+	data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data: "))
+	if !ok || bytes.Equal(data, []byte("[DONE]")) {
+		return nil
+	}
+	var event struct {
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &event) == nil && event.Error != nil {
+		return status.Error(codes.Internal, event.Error.Message)
+	}
+	chunk := dynamicpb.NewMessage(messageDescriptor(w.method.output))
+	if err := unmarshalOptions.Unmarshal(data, chunk); err != nil {
+		return status.Errorf(codes.Internal, "invalid chunk from backend: %v", err)
+	}
+	if err := w.stream.SendMsg(chunk); err != nil {
+		return fmt.Errorf("sending chunk: %w", err)
+	}
+	return nil
+}
+
+// Flush implements http.Flusher.Flush. Chunks are sent as they're written.
+func (w *streamWriter) Flush() {}
@@ -0,0 +1,231 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// fakeInference serves the inference API, recording the requests it
+// receives.
+type fakeInference struct {
+	requests chan map[string]any
+	headers  chan http.Header
+}
+
+func (f *fakeInference) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request map[string]any
+	json.NewDecoder(r.Body).Decode(&request)
+	f.requests <- request
+	f.headers <- r.Header
+	if request["model"] == "missing" {
+		http.Error(w, `{"error":{"message":"model not found"}}`, http.StatusNotFound)
+		return
+	}
+	switch r.URL.Path {
+	case inference.InferencePrefix + "/v1/chat/completions":
+		if request["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"id\":\"c\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hel\"}}]}\n\n")
+			fmt.Fprint(w, "data: {\"id\":\"c\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n")
+			fmt.Fprint(w, "\ndata: {\"id\":\"c\",\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2,\"total_tokens\":5},\"timings\":{\"tokens_per_second\":12.5,\"prompt_ms\":1}}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		fmt.Fprint(w, `{"id":"c","object":"chat.completion","created":1700000000,"choices":[{"index":0,"message":{"role":"assistant","content":"Hello","tool_calls":[{"id":"t","type":"function","function":{"name":"f","arguments":"{}"}}]},"logprobs":null,"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`)
+	case inference.InferencePrefix + "/v1/embeddings":
+		fmt.Fprint(w, `{"object":"list","model":"e","data":[{"object":"embedding","index":0,"embedding":[0.5,-1]}],"usage":{"prompt_tokens":2,"total_tokens":2}}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newClient serves the service with an inference handler and returns a
+// connection to it.
+func newClient(t *testing.T, next http.Handler) *grpc.ClientConn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(logrus.New(), next)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// message returns a message of the service decoded from JSON.
+func message(t *testing.T, name, data string) *dynamicpb.Message {
+	t.Helper()
+	m := dynamicpb.NewMessage(messageDescriptor(name))
+	if err := protojson.Unmarshal([]byte(data), m); err != nil {
+		t.Fatalf("Invalid %s: %v", name, err)
+	}
+	return m
+}
+
+// encode returns a message of the service encoded as JSON, with the names
+// of fields of the OpenAI API.
+func encode(t *testing.T, m *dynamicpb.Message) map[string]any {
+	t.Helper()
+	data, err := marshalOptions.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestChatCompletion(t *testing.T) {
+	fake := &fakeInference{requests: make(chan map[string]any, 1), headers: make(chan http.Header, 1)}
+	conn := newClient(t, fake)
+
+	request := message(t, "ChatCompletionRequest", `{"model":"m","messages":[{"role":"user","content":"Hi"}],"temperature":0,"extra_json":"{\"temperature\":1,\"response_format\":{\"type\":\"json_object\"}}"}`)
+	response := dynamicpb.NewMessage(messageDescriptor("ChatCompletionResponse"))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	if err := conn.Invoke(ctx, "/"+ServiceName+"/ChatCompletion", request, response); err != nil {
+		t.Fatalf("ChatCompletion failed: %v", err)
+	}
+
+	received := <-fake.requests
+	if received["temperature"] != 0.0 {
+		t.Errorf("Expected the temperature of 0 to take precedence, got %v", received)
+	}
+	if _, ok := received["response_format"]; !ok {
+		t.Errorf("Expected extra_json to be merged in, got %v", received)
+	}
+	if _, ok := received["extra_json"]; ok {
+		t.Errorf("Expected extra_json to be removed, got %v", received)
+	}
+	if _, ok := received["top_p"]; ok {
+		t.Errorf("Expected unset fields to be omitted, got %v", received)
+	}
+	if header := <-fake.headers; header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Expected the authorization to be passed along, got %v", header)
+	}
+
+	decoded := encode(t, response)
+	choice := decoded["choices"].([]any)[0].(map[string]any)
+	message := choice["message"].(map[string]any)
+	if message["content"] != "Hello" || choice["finish_reason"] != "stop" || decoded["created"] != "1700000000" {
+		t.Errorf("Unexpected response %v", decoded)
+	}
+	if calls, _ := message["tool_calls"].([]any); len(calls) != 1 {
+		t.Errorf("Expected a tool call, got %v", decoded)
+	}
+	if usage := decoded["usage"].(map[string]any); usage["total_tokens"] != 5.0 {
+		t.Errorf("Expected the usage, got %v", decoded)
+	}
+}
+
+func TestStreamChatCompletion(t *testing.T) {
+	fake := &fakeInference{requests: make(chan map[string]any, 1), headers: make(chan http.Header, 1)}
+	conn := newClient(t, fake)
+
+	desc := &grpc.StreamDesc{StreamName: "StreamChatCompletion", ServerStreams: true}
+	stream, err := conn.NewStream(context.Background(), desc, "/"+ServiceName+"/StreamChatCompletion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(message(t, "ChatCompletionRequest", `{"model":"m","messages":[{"role":"user","content":"Hi"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	var content string
+	var last map[string]any
+	for {
+		chunk := dynamicpb.NewMessage(messageDescriptor("ChatCompletionChunk"))
+		if err := stream.RecvMsg(chunk); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		last = encode(t, chunk)
+		choices, _ := last["choices"].([]any)
+		for _, c := range choices {
+			content += c.(map[string]any)["delta"].(map[string]any)["content"].(string)
+		}
+	}
+	if content != "Hello" {
+		t.Errorf("Expected the content to be streamed, got %q", content)
+	}
+	if usage, _ := last["usage"].(map[string]any); usage["total_tokens"] != 5.0 {
+		t.Errorf("Expected the usage in the last chunk, got %v", last)
+	}
+	if timings, _ := last["timings"].(map[string]any); timings["tokens_per_second"] != 12.5 {
+		t.Errorf("Expected the timings in the last chunk, got %v", last)
+	}
+
+	received := <-fake.requests
+	options, _ := received["stream_options"].(map[string]any)
+	if received["stream"] != true || options["include_usage"] != true {
+		t.Errorf("Expected a stream including its usage to be requested, got %v", received)
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	fake := &fakeInference{requests: make(chan map[string]any, 1), headers: make(chan http.Header, 1)}
+	conn := newClient(t, fake)
+
+	response := dynamicpb.NewMessage(messageDescriptor("EmbeddingsResponse"))
+	if err := conn.Invoke(context.Background(), "/"+ServiceName+"/Embeddings", message(t, "EmbeddingsRequest", `{"model":"e","input":["a"]}`), response); err != nil {
+		t.Fatalf("Embeddings failed: %v", err)
+	}
+	decoded := encode(t, response)
+	embedding := decoded["data"].([]any)[0].(map[string]any)["embedding"].([]any)
+	if len(embedding) != 2 || embedding[0] != 0.5 || embedding[1] != -1.0 {
+		t.Errorf("Unexpected embeddings %v", decoded)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	fake := &fakeInference{requests: make(chan map[string]any, 2), headers: make(chan http.Header, 2)}
+	conn := newClient(t, fake)
+
+	response := dynamicpb.NewMessage(messageDescriptor("ChatCompletionResponse"))
+	err := conn.Invoke(context.Background(), "/"+ServiceName+"/ChatCompletion", message(t, "ChatCompletionRequest", `{"model":"missing"}`), response)
+	if s := status.Convert(err); s.Code() != codes.NotFound || s.Message() != "model not found" {
+		t.Errorf("Expected a NotFound status, got %v", err)
+	}
+
+	desc := &grpc.StreamDesc{StreamName: "StreamCompletion", ServerStreams: true}
+	stream, err := conn.NewStream(context.Background(), desc, "/"+ServiceName+"/StreamCompletion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.SendMsg(message(t, "CompletionRequest", `{"model":"missing"}`))
+	stream.CloseSend()
+	if err := stream.RecvMsg(dynamicpb.NewMessage(messageDescriptor("CompletionResponse"))); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected a NotFound status, got %v", err)
+	}
+
+	err = conn.Invoke(context.Background(), "/"+ServiceName+"/ChatCompletion", message(t, "ChatCompletionRequest", `{"model":"m","extra_json":"[]"}`), response)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected an InvalidArgument status for invalid extra_json, got %v", err)
+	}
+}
@@ -20,4 +20,61 @@ const RequestOriginHeader = "X-Request-Origin"
 const (
 	// OriginOllamaCompletion indicates the request came from the Ollama /api/chat or /api/generate endpoints
 	OriginOllamaCompletion = "ollama/completion"
+	// OriginResponses indicates the request came from the OpenAI /v1/responses endpoint
+	OriginResponses = "openai/responses"
+	// OriginModerations indicates the request came from the OpenAI /v1/moderations endpoint
+	OriginModerations = "openai/moderations"
+	// OriginWebSocketChat indicates the request came from the /v1/chat/ws WebSocket endpoint
+	OriginWebSocketChat = "websocket/chat"
 )
+
+// RequestDeadlineHeader is the HTTP header used by clients to set an absolute
+// deadline for an inference request, either as an RFC 3339 timestamp or as
+// Unix seconds.
+const RequestDeadlineHeader = "X-Request-Deadline"
+
+// RequestTimeoutHeader is the HTTP header used by clients to set a timeout for
+// an inference request, either as a duration (e.g. "30s") or in seconds.
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// SessionIDHeader is the HTTP header used by clients to identify a session,
+// such as a conversation or user, whose requests are routed to the same
+// replica of a model so that its prefix cache is reused.
+const SessionIDHeader = "X-Session-ID"
+
+// ConversationIDHeader is the HTTP header used by clients to identify a
+// conversation stored by the model runner, so that its chat completions
+// requests only send their new messages.
+const ConversationIDHeader = "X-Conversation-ID"
+
+// ClientIDHeader is the HTTP header used by clients, or proxies acting on their
+// behalf, to identify themselves for fair scheduling of inference requests.
+const ClientIDHeader = "X-Client-ID"
+
+// RequestPriorityHeader is the HTTP header used by clients to set the
+// scheduling priority of an inference request: "high" for interactive
+// requests, "normal", or "low" for batch and background requests.
+const RequestPriorityHeader = "X-Request-Priority"
+
+// RequestIDHeader is the HTTP header that identifies an inference request to
+// the backend serving it, so that the backend can abort its generation if the
+// request is cut off.
+const RequestIDHeader = "X-Request-Id"
+
+// ForwardedHeader is the HTTP header set on inference requests forwarded to a
+// peer in a cluster, which serves them itself rather than forwarding them
+// again.
+const ForwardedHeader = "X-Model-Runner-Forwarded"
+
+// QueuePositionHeader is the response header that reports the position of a
+// request among the requests waiting for its model, when the model has to be
+// loaded first.
+const QueuePositionHeader = "X-Queue-Position"
+
+// LoadEstimateHeader is the response header that reports the estimated time,
+// in seconds, for a request's model to load, based on its previous loads.
+const LoadEstimateHeader = "X-Model-Load-Estimate"
+
+// LoadDurationHeader is the response header that reports the time, in
+// seconds, that a request waited for its model to load.
+const LoadDurationHeader = "X-Model-Load-Duration"
@@ -2,7 +2,11 @@ package inference
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 // BackendMode encodes the mode in which a backend should operate.
@@ -16,8 +20,40 @@ const (
 	// mode.
 	BackendModeEmbedding
 	BackendModeReranking
+	// BackendModeTranscription indicates that the backend should run in audio
+	// transcription mode.
+	BackendModeTranscription
+	// BackendModeImageGeneration indicates that the backend should run in
+	// image generation mode.
+	BackendModeImageGeneration
 )
 
+// ErrBackendNotInstalled indicates that a backend isn't installed on the host.
+// Backends return errors wrapping it from Install, so that missing backends
+// are recognized without knowing each backend's error.
+var ErrBackendNotInstalled = errors.New("backend not installed")
+
+// NotInstalledError returns an error with the specified message that wraps
+// ErrBackendNotInstalled.
+func NotInstalledError(message string) error {
+	return &notInstalledError{message: message}
+}
+
+// notInstalledError is an error wrapping ErrBackendNotInstalled.
+type notInstalledError struct {
+	message string
+}
+
+// Error implements error.Error.
+func (e *notInstalledError) Error() string {
+	return e.message
+}
+
+// Unwrap returns ErrBackendNotInstalled.
+func (e *notInstalledError) Unwrap() error {
+	return ErrBackendNotInstalled
+}
+
 type ErrGGUFParse struct {
 	Err error
 }
@@ -35,21 +71,273 @@ func (m BackendMode) String() string {
 		return "embedding"
 	case BackendModeReranking:
 		return "reranking"
+	case BackendModeTranscription:
+		return "transcription"
+	case BackendModeImageGeneration:
+		return "image-generation"
 	default:
 		return "unknown"
 	}
 }
 
+// ParseBackendMode parses a backend mode from its string representation.
+func ParseBackendMode(s string) (BackendMode, error) {
+	for _, mode := range []BackendMode{
+		BackendModeCompletion,
+		BackendModeEmbedding,
+		BackendModeReranking,
+		BackendModeTranscription,
+		BackendModeImageGeneration,
+	} {
+		if s == mode.String() {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown backend mode %q", s)
+}
+
 type SpeculativeDecodingConfig struct {
 	DraftModel        string  `json:"draft_model,omitempty"`
 	NumTokens         int     `json:"num_tokens,omitempty"`
 	MinAcceptanceRate float64 `json:"min_acceptance_rate,omitempty"`
 }
 
+// KVCacheType is the data type used to store the KV cache. Quantized types
+// trade accuracy for memory, allowing for longer contexts.
+type KVCacheType string
+
+const (
+	// KVCacheTypeF16 stores the KV cache unquantized.
+	KVCacheTypeF16 KVCacheType = "f16"
+	// KVCacheTypeQ8_0 stores the KV cache with 8-bit quantization.
+	KVCacheTypeQ8_0 KVCacheType = "q8_0"
+	// KVCacheTypeQ4_0 stores the KV cache with 4-bit quantization.
+	KVCacheTypeQ4_0 KVCacheType = "q4_0"
+)
+
+// ParseKVCacheType parses a KV cache type. An empty string is returned as-is
+// and means that the backend default is used.
+func ParseKVCacheType(s string) (KVCacheType, error) {
+	switch t := KVCacheType(strings.ToLower(strings.TrimSpace(s))); t {
+	case "", KVCacheTypeF16, KVCacheTypeQ8_0, KVCacheTypeQ4_0:
+		return t, nil
+	default:
+		return "", fmt.Errorf("invalid KV cache type %q (expected f16, q8_0, or q4_0)", s)
+	}
+}
+
+// RopeScalingType is a RoPE scaling method used to extend the context of a
+// model beyond the length it was trained with.
+type RopeScalingType string
+
+const (
+	// RopeScalingLinear interpolates positions linearly.
+	RopeScalingLinear RopeScalingType = "linear"
+	// RopeScalingYaRN uses YaRN, which degrades less than linear scaling at
+	// large factors.
+	RopeScalingYaRN RopeScalingType = "yarn"
+)
+
+// RopeScalingConfig configures RoPE scaling.
+type RopeScalingConfig struct {
+	// Type is the scaling method.
+	Type RopeScalingType `json:"type"`
+	// Factor is the context extension factor.
+	Factor float64 `json:"factor"`
+	// OriginalContextSize is the context size the model was trained with. It's
+	// only used by YaRN, and read from the model if it's 0.
+	OriginalContextSize uint64 `json:"original-context-size,omitempty"`
+}
+
+// Validate checks that the RoPE scaling configuration is usable, normalizing
+// its type.
+func (c *RopeScalingConfig) Validate() error {
+	switch t := RopeScalingType(strings.ToLower(strings.TrimSpace(string(c.Type)))); t {
+	case RopeScalingLinear, RopeScalingYaRN:
+		c.Type = t
+	default:
+		return fmt.Errorf("invalid RoPE scaling type %q (expected linear or yarn)", c.Type)
+	}
+	if c.Factor <= 1 {
+		return fmt.Errorf("invalid RoPE scaling factor %g (must be greater than 1)", c.Factor)
+	}
+	return nil
+}
+
+// EmbeddingPooling is the method used to combine token embeddings into a
+// single embedding for an input.
+type EmbeddingPooling string
+
+const (
+	// EmbeddingPoolingMean averages the token embeddings.
+	EmbeddingPoolingMean EmbeddingPooling = "mean"
+	// EmbeddingPoolingCLS uses the embedding of the first (CLS) token.
+	EmbeddingPoolingCLS EmbeddingPooling = "cls"
+	// EmbeddingPoolingLast uses the embedding of the last token.
+	EmbeddingPoolingLast EmbeddingPooling = "last"
+)
+
+// ParseEmbeddingPooling parses an embedding pooling method. An empty string is
+// returned as-is and means that the model's pooling method is used.
+func ParseEmbeddingPooling(s string) (EmbeddingPooling, error) {
+	switch p := EmbeddingPooling(strings.ToLower(strings.TrimSpace(s))); p {
+	case "", EmbeddingPoolingMean, EmbeddingPoolingCLS, EmbeddingPoolingLast:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid embedding pooling %q (expected mean, cls, or last)", s)
+	}
+}
+
+// EmbeddingConfig configures runners in embedding mode.
+type EmbeddingConfig struct {
+	// Pooling overrides the model's pooling method, if set.
+	Pooling EmbeddingPooling `json:"pooling,omitempty"`
+	// Normalize controls whether embeddings are L2-normalized, which is the
+	// default.
+	Normalize *bool `json:"normalize,omitempty"`
+}
+
+// RemoteConfig configures a model served by an external OpenAI-compatible
+// server.
+type RemoteConfig struct {
+	// BaseURL is the base URL of the server's OpenAI API, such as
+	// https://api.openai.com/v1.
+	BaseURL string `json:"base-url"`
+	// APIKey is sent to the server as a bearer token, if set.
+	APIKey string `json:"api-key,omitempty"`
+	// Model is the name of the model on the server. If empty, the requested
+	// model name is forwarded as-is.
+	Model string `json:"model,omitempty"`
+	// ReplicaURLs are the base URLs of further servers replicating the model,
+	// which requests are balanced across along with BaseURL.
+	ReplicaURLs []string `json:"replica-urls,omitempty"`
+}
+
+// Validate checks that the remote configuration is usable, normalizing its
+// base URLs.
+func (c *RemoteConfig) Validate() error {
+	var err error
+	if c.BaseURL, err = normalizeRemoteURL(c.BaseURL); err != nil {
+		return err
+	}
+	for i, replicaURL := range c.ReplicaURLs {
+		if c.ReplicaURLs[i], err = normalizeRemoteURL(replicaURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeRemoteURL checks that a remote base URL is an http or https URL and
+// removes any trailing slash.
+func normalizeRemoteURL(baseURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid remote base URL %q: %w", baseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid remote base URL %q (expected an http or https URL)", baseURL)
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// ReplicaRouting determines how requests are distributed across the replicas
+// of a model.
+type ReplicaRouting string
+
+const (
+	// ReplicaRoutingLeastLoaded routes requests to the replica serving the
+	// fewest requests.
+	ReplicaRoutingLeastLoaded ReplicaRouting = "least-loaded"
+	// ReplicaRoutingRoundRobin routes requests to replicas in turn.
+	ReplicaRoutingRoundRobin ReplicaRouting = "round-robin"
+)
+
+// ParseReplicaRouting parses a replica routing policy. An empty string yields
+// ReplicaRoutingLeastLoaded.
+func ParseReplicaRouting(s string) (ReplicaRouting, error) {
+	switch routing := ReplicaRouting(strings.ToLower(strings.TrimSpace(s))); routing {
+	case "":
+		return ReplicaRoutingLeastLoaded, nil
+	case ReplicaRoutingLeastLoaded, ReplicaRoutingRoundRobin:
+		return routing, nil
+	default:
+		return "", fmt.Errorf("invalid replica routing %q (expected %q or %q)", s, ReplicaRoutingLeastLoaded, ReplicaRoutingRoundRobin)
+	}
+}
+
+// ReplicaConfig configures replicas of a model, which serve its requests under
+// a single name.
+type ReplicaConfig struct {
+	// Count is the maximum number of local runners for the model. Runners
+	// beyond the first are started when all others are busy.
+	Count int `json:"count,omitempty"`
+	// Routing determines how requests are distributed across the replicas,
+	// including the servers of remote models.
+	Routing ReplicaRouting `json:"routing,omitempty"`
+}
+
+// Validate checks that the replica configuration is usable, normalizing its
+// routing policy.
+func (c *ReplicaConfig) Validate() error {
+	if c.Count < 0 {
+		return fmt.Errorf("invalid replica count: %d", c.Count)
+	}
+	routing, err := ParseReplicaRouting(string(c.Routing))
+	if err != nil {
+		return err
+	}
+	c.Routing = routing
+	return nil
+}
+
 type BackendConfiguration struct {
 	ContextSize  int64                      `json:"context-size,omitempty"`
 	RuntimeFlags []string                   `json:"runtime-flags,omitempty"`
 	Speculative  *SpeculativeDecodingConfig `json:"speculative,omitempty"`
+	// KVCacheType overrides the backend's KV cache type, if set.
+	KVCacheType KVCacheType `json:"kv-cache-type,omitempty"`
+	// RopeScaling configures RoPE scaling, if set.
+	RopeScaling *RopeScalingConfig `json:"rope-scaling,omitempty"`
+	// Embeddings configures embedding mode, if set.
+	Embeddings *EmbeddingConfig `json:"embeddings,omitempty"`
+	// Remote configures the upstream server for backends implementing
+	// RemoteBackend.
+	Remote *RemoteConfig `json:"remote,omitempty"`
+	// Replicas configures replicas of the model, if set.
+	Replicas *ReplicaConfig `json:"replicas,omitempty"`
+	// Devices are the indices of the GPUs that the runner is placed on. When
+	// configuring a model, they pin its runners to specific GPUs. When running
+	// a backend, they're the GPUs assigned by the scheduler, which is only the
+	// case on systems with multiple GPUs.
+	Devices []int `json:"-"`
+}
+
+// AssignedDevices returns the indices of the GPUs that the runner is placed
+// on, if any. It's safe to call on a nil configuration.
+func (c *BackendConfiguration) AssignedDevices() []int {
+	if c == nil {
+		return nil
+	}
+	return c.Devices
+}
+
+// ReplicaCount returns the maximum number of local runners for the model,
+// which is at least 1. It's safe to call on a nil configuration.
+func (c *BackendConfiguration) ReplicaCount() int {
+	if c == nil || c.Replicas == nil {
+		return 1
+	}
+	return max(c.Replicas.Count, 1)
+}
+
+// ReplicaRouting returns how requests are distributed across the replicas of
+// the model. It's safe to call on a nil configuration.
+func (c *BackendConfiguration) ReplicaRouting() ReplicaRouting {
+	if c == nil || c.Replicas == nil || c.Replicas.Routing == "" {
+		return ReplicaRoutingLeastLoaded
+	}
+	return c.Replicas.Routing
 }
 
 type RequiredMemory struct {
@@ -103,3 +391,144 @@ type Backend interface {
 	// model.
 	GetRequiredMemoryForModel(ctx context.Context, model string, config *BackendConfiguration) (RequiredMemory, error)
 }
+
+// RequestTranslator is an optional interface that may be implemented by
+// backends whose servers don't accept the OpenAI API request format verbatim.
+// If implemented, TranslateRequest is invoked with the raw request body before
+// the request is forwarded to the backend and should return the body to send
+// upstream. The context is the request's context, and config is the runner
+// configuration for the requested model, which may be nil. Errors returned from
+// TranslateRequest are considered client errors.
+type RequestTranslator interface {
+	TranslateRequest(ctx context.Context, mode BackendMode, config *BackendConfiguration, body []byte) ([]byte, error)
+}
+
+// RequestAborter is an optional interface that may be implemented by backends
+// whose servers don't stop generating as soon as the connection of a request
+// closes. If implemented, AbortRequest is invoked when a request is cut off
+// before its response completes, such as when its client disconnects or a
+// generation timeout is exceeded, and should abort the request's generation
+// with the server's native mechanism, freeing its cache and batch slot. The
+// client targets the runner's server, and requestID is the ID that was sent
+// with the request in the RequestIDHeader header.
+type RequestAborter interface {
+	AbortRequest(ctx context.Context, client *http.Client, requestID string) error
+}
+
+// requestIDKey is the context key of inference request IDs.
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the ID that identifies an inference
+// request to the backend serving it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the inference request carried by ctx, or an
+// empty string if there's none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// promptCacheKeyKey is the context key of prompt cache keys.
+type promptCacheKeyKey struct{}
+
+// WithPromptCacheKey returns a context carrying the key of the prompt prefix
+// that an inference request shares with other requests, so that the backend
+// serving it can keep the prefix in the same part of its prefix cache.
+func WithPromptCacheKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, promptCacheKeyKey{}, key)
+}
+
+// PromptCacheKey returns the prompt cache key carried by ctx, or an empty
+// string if there's none.
+func PromptCacheKey(ctx context.Context) string {
+	key, _ := ctx.Value(promptCacheKeyKey{}).(string)
+	return key
+}
+
+// Uninstaller is an optional interface that may be implemented by backends
+// which manage their own installation on the host. Uninstall removes the
+// installation (and thus its disk usage). Backends must not be running when
+// Uninstall is invoked.
+type Uninstaller interface {
+	Uninstall(ctx context.Context) error
+}
+
+// MultiDeviceBackend is an optional interface that may be implemented by
+// backends which can split a model across multiple GPUs. The scheduler assigns
+// such backends a set of GPUs sized to the model's VRAM requirement and passes
+// them via BackendConfiguration.Devices. Assigned GPUs aren't shared with other
+// runners. Other backends are placed on a single GPU.
+type MultiDeviceBackend interface {
+	SupportsMultipleDevices() bool
+}
+
+// AdapterBackend is an optional interface that may be implemented by backends
+// which can apply LoRA adapters to a model. Adapters are requested with model
+// names such as base+adapter, which are passed to Run as they're resolved to
+// IDs; the scheduler rejects them for other backends.
+type AdapterBackend interface {
+	SupportsAdapters() bool
+}
+
+// ChatTemplateBackend is an optional interface that may be implemented by
+// backends which apply the Jinja chat templates of model bundles, including
+// those stored in the settings of models to override their own. The scheduler
+// rejects chat completions for models with such overrides for other backends.
+type ChatTemplateBackend interface {
+	AppliesChatTemplates() bool
+}
+
+// ToolCallBackend is an optional interface that may be implemented by
+// backends whose servers don't parse the tool calls of every model. If
+// ParsesToolCalls returns false for a model run with config, which may be nil,
+// the scheduler describes the tools of chat completions in their prompts and
+// parses the model's tool calls from its output instead. Backends that don't
+// implement it are assumed to parse tool calls.
+type ToolCallBackend interface {
+	ParsesToolCalls(model string, config *BackendConfiguration) bool
+}
+
+// RerankBackend is an optional interface that may be implemented by backends
+// whose rerank responses score documents with the raw logits of models rather
+// than with probabilities. The scheduler maps their scores to probabilities
+// with the logistic function, so that scores are comparable across backends.
+type RerankBackend interface {
+	RawRerankScores() bool
+}
+
+// TokenizerFormat is the format of the tokenizer endpoints of a backend's
+// server.
+type TokenizerFormat int
+
+const (
+	// TokenizerFormatLlamaCpp is the format of llama-server's /tokenize and
+	// /detokenize endpoints, whose conversations are rendered with its
+	// /apply-template endpoint.
+	TokenizerFormatLlamaCpp TokenizerFormat = iota
+	// TokenizerFormatVLLM is the format of vLLM's /tokenize and /detokenize
+	// endpoints, which the scheduler's endpoints share.
+	TokenizerFormatVLLM
+	// TokenizerFormatSGLang is the format of SGLang's /v1/tokenize and
+	// /v1/detokenize endpoints, which only tokenize prompts.
+	TokenizerFormatSGLang
+)
+
+// TokenizerBackend is an optional interface that may be implemented by
+// backends whose servers tokenize and detokenize text with the tokenizer of
+// their model. The scheduler serves /v1/tokenize and /v1/detokenize requests
+// for their models through those endpoints, in their format, and rejects them
+// for other backends.
+type TokenizerBackend interface {
+	TokenizerFormat() TokenizerFormat
+}
+
+// RemoteBackend is an optional interface that may be implemented by backends
+// which forward requests to external servers instead of running models
+// locally. The scheduler treats their runners as always loaded: they don't
+// require any memory and aren't evicted when idle.
+type RemoteBackend interface {
+	Remote() bool
+}
@@ -0,0 +1,53 @@
+package llamacpp
+
+import (
+	"bufio"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// device is a compute device reported by llama-server --list-devices.
+type device struct {
+	// accelerator is the lowercase name of the GGML backend driving the
+	// device, e.g. cuda, rocm, vulkan, or metal.
+	accelerator string
+	// description is the device description.
+	description string
+	// memory is the total memory of the device in bytes, or 0 if unknown.
+	memory uint64
+}
+
+var (
+	// deviceRe matches device lines, e.g. "  Vulkan0: AMD Radeon RX 7900 XTX
+	// (RADV NAVI31) (24560 MiB, 24000 MiB free)".
+	deviceRe = regexp.MustCompile(`^\s{2}([A-Za-z]+?)\d*:\s(.*)$`)
+	// deviceMemoryRe matches the memory suffix of device descriptions.
+	deviceMemoryRe = regexp.MustCompile(`\s*\((\d+) MiB, \d+ MiB free\)$`)
+)
+
+// parseDevices parses the output of llama-server --list-devices.
+func parseDevices(output string) []device {
+	var devices []device
+	sc := bufio.NewScanner(strings.NewReader(output))
+	expectDev := false
+	for sc.Scan() {
+		if !expectDev {
+			expectDev = strings.HasPrefix(sc.Text(), "Available devices:")
+			continue
+		}
+		matches := deviceRe.FindStringSubmatch(sc.Text())
+		if matches == nil {
+			continue
+		}
+		dev := device{accelerator: strings.ToLower(matches[1]), description: matches[2]}
+		if memory := deviceMemoryRe.FindStringSubmatch(dev.description); memory != nil {
+			if mib, err := strconv.ParseUint(memory[1], 10, 64); err == nil {
+				dev.memory = mib * 1024 * 1024
+			}
+			dev.description = strings.TrimSuffix(dev.description, memory[0])
+		}
+		devices = append(devices, dev)
+	}
+	return devices
+}
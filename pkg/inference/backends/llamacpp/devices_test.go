@@ -0,0 +1,63 @@
+package llamacpp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDevices(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected []device
+	}{
+		{
+			name:   "CPU only",
+			output: "Available devices:\n",
+		},
+		{
+			name: "CUDA",
+			output: "ggml_cuda_init: found 1 CUDA devices:\n" +
+				"  Device 0: NVIDIA GeForce RTX 4090, compute capability 8.9, VMM: yes\n" +
+				"Available devices:\n" +
+				"  CUDA0: NVIDIA GeForce RTX 4090 (24080 MiB, 23664 MiB free)\n",
+			expected: []device{
+				{accelerator: "cuda", description: "NVIDIA GeForce RTX 4090", memory: 24080 * 1024 * 1024},
+			},
+		},
+		{
+			name: "multiple Vulkan devices",
+			output: "Available devices:\n" +
+				"  Vulkan0: AMD Radeon RX 7900 XTX (RADV NAVI31) (24560 MiB, 24000 MiB free)\n" +
+				"  Vulkan1: Intel(R) UHD Graphics 770 (ADL-S GT1) (15872 MiB, 15872 MiB free)\n",
+			expected: []device{
+				{accelerator: "vulkan", description: "AMD Radeon RX 7900 XTX (RADV NAVI31)", memory: 24560 * 1024 * 1024},
+				{accelerator: "vulkan", description: "Intel(R) UHD Graphics 770 (ADL-S GT1)", memory: 15872 * 1024 * 1024},
+			},
+		},
+		{
+			name: "ROCm",
+			output: "Available devices:\n" +
+				"  ROCm0: AMD Radeon PRO W7900 (49136 MiB, 48972 MiB free)\n",
+			expected: []device{
+				{accelerator: "rocm", description: "AMD Radeon PRO W7900", memory: 49136 * 1024 * 1024},
+			},
+		},
+		{
+			name: "Metal without memory",
+			output: "Available devices:\n" +
+				"  Metal: Apple M2 Max\n",
+			expected: []device{
+				{accelerator: "metal", description: "Apple M2 Max"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDevices(tt.output); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package llamacpp
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,11 +17,11 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/docker/model-runner/pkg/inference/offline"
 	"github.com/docker/model-runner/pkg/internal/dockerhub"
 	"github.com/docker/model-runner/pkg/logging"
 )
 
-//nolint:unused // Used in platform-specific files (download_darwin.go, download_windows.go)
 const (
 	hubNamespace = "docker"
 	hubRepo      = "docker-model-backend-llamacpp"
@@ -32,8 +34,16 @@ var (
 	ShouldUpdateServerLock    sync.Mutex
 	DesiredServerVersion      = "latest"
 	DesiredServerVersionLock  sync.Mutex
+	desiredServerVersionSet   bool
 	errLlamaCppUpToDate       = errors.New("bundled llama.cpp version is up to date, no need to update")
 	errLlamaCppUpdateDisabled = errors.New("llama.cpp auto-updated is disabled")
+	// errLlamaCppChecksumMissing indicates that no checksum was recorded for
+	// the installed llama.cpp binary.
+	errLlamaCppChecksumMissing = errors.New("no llama.cpp checksum recorded")
+	// offlineBundle is the offline bundle that llama.cpp is preferably
+	// installed from, if any.
+	offlineBundle     *offline.Bundle
+	offlineBundleLock sync.Mutex
 )
 
 func GetDesiredServerVersion() string {
@@ -46,12 +56,42 @@ func SetDesiredServerVersion(version string) {
 	DesiredServerVersionLock.Lock()
 	defer DesiredServerVersionLock.Unlock()
 	DesiredServerVersion = version
+	desiredServerVersionSet = true
+}
+
+// isDesiredServerVersionSet returns true if a server version or channel has
+// been configured explicitly.
+func isDesiredServerVersionSet() bool {
+	DesiredServerVersionLock.Lock()
+	defer DesiredServerVersionLock.Unlock()
+	return desiredServerVersionSet
+}
+
+// SetOfflineBundle sets the offline bundle that llama.cpp is installed from,
+// without network access, if it provides the variant for the host.
+func SetOfflineBundle(bundle *offline.Bundle) {
+	offlineBundleLock.Lock()
+	defer offlineBundleLock.Unlock()
+	offlineBundle = bundle
+}
+
+// getOfflineBundle returns the offline bundle that llama.cpp is installed
+// from, if any.
+func getOfflineBundle() *offline.Bundle {
+	offlineBundleLock.Lock()
+	defer offlineBundleLock.Unlock()
+	return offlineBundle
 }
 
-//nolint:unused // Used in platform-specific files (download_darwin.go, download_windows.go)
 func (l *llamaCpp) downloadLatestLlamaCpp(ctx context.Context, log logging.Logger, httpClient *http.Client,
 	llamaCppPath, vendoredServerStoragePath, desiredVersion, desiredVariant string,
 ) error {
+	// The offline bundle is preferred over Docker Hub, even when updates are
+	// disabled, since it's installed without network access.
+	if installed, err := l.installFromOfflineBundle(log, llamaCppPath, desiredVariant); installed || err != nil {
+		return err
+	}
+
 	ShouldUpdateServerLock.Lock()
 	shouldUpdateServer := ShouldUpdateServer
 	ShouldUpdateServerLock.Unlock()
@@ -95,9 +135,12 @@ func (l *llamaCpp) downloadLatestLlamaCpp(ctx context.Context, log logging.Logge
 
 	bundledVersionFile := filepath.Join(vendoredServerStoragePath, "com.docker.llama-server.digest")
 	currentVersionFile := filepath.Join(filepath.Dir(llamaCppPath), ".llamacpp_version")
+	currentChecksumFile := filepath.Join(filepath.Dir(llamaCppPath), ".llamacpp_sha256")
 
 	data, err := os.ReadFile(bundledVersionFile)
-	if err != nil {
+	if errors.Is(err, os.ErrNotExist) {
+		log.Infoln("no bundled llama.cpp binary, proceeding to install llama.cpp binary")
+	} else if err != nil {
 		return fmt.Errorf("failed to read bundled llama.cpp version: %w", err)
 	} else if strings.TrimSpace(string(data)) == latest {
 		l.status = fmt.Sprintf("running llama.cpp %s (%s) version: %s",
@@ -111,10 +154,17 @@ func (l *llamaCpp) downloadLatestLlamaCpp(ctx context.Context, log logging.Logge
 		log.Warnf("proceeding to update llama.cpp binary")
 	} else if strings.TrimSpace(string(data)) == latest {
 		log.Infoln("current llama.cpp version is already up to date")
-		if _, err := os.Stat(llamaCppPath); err == nil {
+		err := verifyChecksum(llamaCppPath, currentChecksumFile)
+		if errors.Is(err, errLlamaCppChecksumMissing) {
+			// Installed before checksums were recorded, so record it now.
+			err = writeChecksum(llamaCppPath, currentChecksumFile)
+		}
+		if err == nil {
 			l.status = fmt.Sprintf("running llama.cpp %s (%s) version: %s",
 				desiredTag, latest, getLlamaCppVersion(log, llamaCppPath))
 			return nil
+		} else {
+			log.Warnf("llama.cpp binary failed verification: %v", err)
 		}
 		log.Infoln("llama.cpp binary must be updated, proceeding to update it")
 	} else {
@@ -133,26 +183,122 @@ func (l *llamaCpp) downloadLatestLlamaCpp(ctx context.Context, log logging.Logge
 		return fmt.Errorf("could not extract image: %w", err)
 	}
 
-	if err := os.RemoveAll(filepath.Dir(llamaCppPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("failed to clear inference binary dir: %w", err)
+	rootDir := fmt.Sprintf("com.docker.llama-server.native.%s.%s.%s", runtime.GOOS, desiredVariant, runtime.GOARCH)
+	if _, err := os.Stat(filepath.Join(downloadDir, rootDir, "bin", filepath.Base(llamaCppPath))); err != nil {
+		return fmt.Errorf("downloaded image has no llama.cpp binary: %w", err)
 	}
-	if err := os.RemoveAll(filepath.Join(filepath.Dir(filepath.Dir(llamaCppPath)), "lib")); err != nil && !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("failed to clear inference library dir: %w", err)
+
+	if err := installLlamaCpp(log, filepath.Join(downloadDir, rootDir), llamaCppPath); err != nil {
+		return err
 	}
 
-	if err := os.MkdirAll(filepath.Dir(filepath.Dir(llamaCppPath)), 0o755); err != nil {
-		return fmt.Errorf("could not create directory for llama.cpp artifacts: %w", err)
+	log.Infoln("successfully updated llama.cpp binary")
+	l.status = fmt.Sprintf("running llama.cpp %s (%s) version: %s", desiredTag, latest, getLlamaCppVersion(log, llamaCppPath))
+	log.Infoln(l.status)
+
+	if err := os.WriteFile(currentVersionFile, []byte(latest), 0o644); err != nil {
+		log.Warnf("failed to save llama.cpp version: %v", err)
+	}
+	if err := writeChecksum(llamaCppPath, currentChecksumFile); err != nil {
+		log.Warnf("failed to save llama.cpp checksum: %v", err)
 	}
 
-	rootDir := fmt.Sprintf("com.docker.llama-server.native.%s.%s.%s", runtime.GOOS, desiredVariant, runtime.GOARCH)
-	if err := os.Rename(filepath.Join(downloadDir, rootDir, "bin"), filepath.Dir(llamaCppPath)); err != nil {
+	return nil
+}
+
+// installFromOfflineBundle installs the llama.cpp variant provided by the
+// offline bundle, unless it's already installed. It returns false if there is
+// no offline bundle or it doesn't provide the variant.
+func (l *llamaCpp) installFromOfflineBundle(log logging.Logger, llamaCppPath, desiredVariant string) (bool, error) {
+	bundleDir, ok := getOfflineBundle().LlamaCppDir(runtime.GOOS, desiredVariant, runtime.GOARCH)
+	if !ok {
+		return false, nil
+	}
+	bundledServer := filepath.Join(bundleDir, "bin", filepath.Base(llamaCppPath))
+	checksum, err := fileSHA256(bundledServer)
+	if err != nil {
+		return true, fmt.Errorf("offline bundle has no llama.cpp binary: %w", err)
+	}
+	// Bundled binaries have no image digest, so they're versioned by their
+	// checksum.
+	version := "bundle:" + checksum
+	currentVersionFile := filepath.Join(filepath.Dir(llamaCppPath), ".llamacpp_version")
+	currentChecksumFile := filepath.Join(filepath.Dir(llamaCppPath), ".llamacpp_sha256")
+
+	if data, err := os.ReadFile(currentVersionFile); err == nil && strings.TrimSpace(string(data)) == version {
+		err := verifyChecksum(llamaCppPath, currentChecksumFile)
+		if err == nil {
+			log.Infoln("llama.cpp from the offline bundle is already installed")
+			l.status = fmt.Sprintf("running llama.cpp %s from offline bundle version: %s",
+				desiredVariant, getLlamaCppVersion(log, llamaCppPath))
+			return true, nil
+		}
+		log.Warnf("llama.cpp binary failed verification: %v", err)
+	}
+
+	installDir, err := os.MkdirTemp("", "llamacpp-install")
+	if err != nil {
+		return true, fmt.Errorf("could not create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(installDir)
+
+	l.status = fmt.Sprintf("installing %s variant of llama.cpp from offline bundle", desiredVariant)
+	rootDir := filepath.Join(installDir, filepath.Base(bundleDir))
+	if err := offline.CopyDir(rootDir, bundleDir); err != nil {
+		return true, fmt.Errorf("could not copy llama.cpp from offline bundle: %w", err)
+	}
+	if err := installLlamaCpp(log, rootDir, llamaCppPath); err != nil {
+		return true, err
+	}
+
+	log.Infof("successfully installed llama.cpp binary from offline bundle %s", getOfflineBundle().Dir())
+	l.status = fmt.Sprintf("running llama.cpp %s from offline bundle version: %s",
+		desiredVariant, getLlamaCppVersion(log, llamaCppPath))
+	log.Infoln(l.status)
+
+	if err := os.WriteFile(currentVersionFile, []byte(version), 0o644); err != nil {
+		log.Warnf("failed to save llama.cpp version: %v", err)
+	}
+	if err := writeChecksum(llamaCppPath, currentChecksumFile); err != nil {
+		log.Warnf("failed to save llama.cpp checksum: %v", err)
+	}
+	return true, nil
+}
+
+// installLlamaCpp moves the bin and lib directories of a llama.cpp variant
+// unpacked in rootDir into place, next to llamaCppPath.
+func installLlamaCpp(log logging.Logger, rootDir, llamaCppPath string) error {
+	// Keep the current binary until the new one is in place, so that a failed
+	// upgrade doesn't leave the backend without a server.
+	binDir := filepath.Dir(llamaCppPath)
+	previousBinDir := binDir + ".previous"
+	if err := os.RemoveAll(previousBinDir); err != nil {
+		return fmt.Errorf("failed to clear previous inference binary dir: %w", err)
+	}
+	if err := os.Rename(binDir, previousBinDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to move aside inference binary dir: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(binDir), 0o755); err != nil {
+		return fmt.Errorf("could not create directory for llama.cpp artifacts: %w", err)
+	}
+	if err := os.Rename(filepath.Join(rootDir, "bin"), binDir); err != nil {
+		if restoreErr := os.Rename(previousBinDir, binDir); restoreErr != nil && !errors.Is(restoreErr, os.ErrNotExist) {
+			log.Warnf("failed to restore previous llama.cpp binary: %v", restoreErr)
+		}
 		return fmt.Errorf("could not move llama.cpp binary: %w", err)
 	}
+	if err := os.RemoveAll(previousBinDir); err != nil {
+		log.Warnf("failed to remove previous llama.cpp binary: %v", err)
+	}
 	if err := os.Chmod(llamaCppPath, 0o755); err != nil {
 		return fmt.Errorf("could not chmod llama.cpp binary: %w", err)
 	}
 
-	libDir := filepath.Join(downloadDir, rootDir, "lib")
+	if err := os.RemoveAll(filepath.Join(filepath.Dir(binDir), "lib")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to clear inference library dir: %w", err)
+	}
+
+	libDir := filepath.Join(rootDir, "lib")
 	fi, err := os.Stat(libDir)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return fmt.Errorf("failed to stat llama.cpp lib dir: %w", err)
@@ -162,19 +308,52 @@ func (l *llamaCpp) downloadLatestLlamaCpp(ctx context.Context, log logging.Logge
 			return fmt.Errorf("could not move llama.cpp libs: %w", err)
 		}
 	}
+	return nil
+}
 
-	log.Infoln("successfully updated llama.cpp binary")
-	l.status = fmt.Sprintf("running llama.cpp %s (%s) version: %s", desiredTag, latest, getLlamaCppVersion(log, llamaCppPath))
-	log.Infoln(l.status)
+// fileSHA256 returns the hex-encoded SHA-256 digest of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	if err := os.WriteFile(currentVersionFile, []byte(latest), 0o644); err != nil {
-		log.Warnf("failed to save llama.cpp version: %v", err)
+// writeChecksum records the SHA-256 digest of the file at path in
+// checksumFile.
+func writeChecksum(path, checksumFile string) error {
+	checksum, err := fileSHA256(path)
+	if err != nil {
+		return err
 	}
+	return os.WriteFile(checksumFile, []byte(checksum), 0o644)
+}
 
+// verifyChecksum checks that the file at path matches the SHA-256 digest
+// recorded in checksumFile when it was installed.
+func verifyChecksum(path, checksumFile string) error {
+	expected, err := os.ReadFile(checksumFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return errLlamaCppChecksumMissing
+	} else if err != nil {
+		return fmt.Errorf("failed to read checksum: %w", err)
+	}
+	actual, err := fileSHA256(path)
+	if err != nil {
+		return fmt.Errorf("failed to compute checksum: %w", err)
+	}
+	if actual != strings.TrimSpace(string(expected)) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", strings.TrimSpace(string(expected)), actual)
+	}
 	return nil
 }
 
-//nolint:unused // Used in platform-specific files (download_darwin.go, download_windows.go)
 func extractFromImage(ctx context.Context, log logging.Logger, image, requiredOs, requiredArch, destination string) error {
 	log.Infof("Extracting image %q to %q", image, destination)
 	tmpDir, err := os.MkdirTemp("", "docker-tar-extract")
@@ -4,15 +4,71 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"path/filepath"
+	"slices"
 
+	"github.com/docker/model-runner/pkg/inference/platform"
 	"github.com/docker/model-runner/pkg/logging"
 )
 
-func (l *llamaCpp) ensureLatestLlamaCpp(_ context.Context, log logging.Logger, _ *http.Client,
-	_, vendoredServerStoragePath string,
+// vulkanLoaders are the paths at which the Vulkan loader is commonly
+// installed.
+var vulkanLoaders = []string{
+	"/usr/lib/x86_64-linux-gnu/libvulkan.so.1",
+	"/usr/lib/aarch64-linux-gnu/libvulkan.so.1",
+	"/usr/lib64/libvulkan.so.1",
+	"/usr/lib/libvulkan.so.1",
+}
+
+// rocmRuntimes are the paths at which the ROCm HIP runtime is commonly
+// installed.
+var rocmRuntimes = []string{
+	"/opt/rocm/lib/libamdhip64.so",
+	"/usr/lib/x86_64-linux-gnu/libamdhip64.so",
+	"/usr/lib64/libamdhip64.so",
+}
+
+func (l *llamaCpp) ensureLatestLlamaCpp(ctx context.Context, log logging.Logger, httpClient *http.Client,
+	llamaCppPath, vendoredServerStoragePath string,
 ) error {
-	l.status = fmt.Sprintf("running llama.cpp version: %s",
-		getLlamaCppVersion(log, filepath.Join(vendoredServerStoragePath, "com.docker.llama-server")))
-	return errLlamaCppUpdateDisabled
+	// The vendored server is used as is, unless there is none, a specific
+	// version or channel has been requested, or an offline bundle is to be
+	// installed from.
+	vendoredServer := filepath.Join(vendoredServerStoragePath, "com.docker.llama-server")
+	if _, err := os.Stat(vendoredServer); err == nil && !isDesiredServerVersionSet() && getOfflineBundle() == nil {
+		l.status = fmt.Sprintf("running llama.cpp version: %s", getLlamaCppVersion(log, vendoredServer))
+		return errLlamaCppUpdateDisabled
+	}
+
+	desiredVersion := GetDesiredServerVersion()
+	desiredVariant := linuxVariant(platform.GPUVendors(), func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	})
+	l.status = fmt.Sprintf("looking for updates for %s variant", desiredVariant)
+	return l.downloadLatestLlamaCpp(ctx, log, httpClient, llamaCppPath, vendoredServerStoragePath, desiredVersion,
+		desiredVariant)
+}
+
+// linuxVariant returns the llama.cpp variant to install for the GPUs
+// available on the host, using exists to probe for device and driver files.
+// AMD GPUs use the ROCm variant if the ROCm runtime is installed, and fall back
+// to Vulkan (as do Intel GPUs) otherwise. If vendors is empty, because GPUs
+// can't be enumerated (e.g. in containers without sysfs), any render node is
+// assumed to support Vulkan.
+func linuxVariant(vendors []platform.GPUVendor, exists func(string) bool) string {
+	if exists("/dev/nvidiactl") || exists("/proc/driver/nvidia/version") {
+		return "cuda"
+	}
+	if slices.Contains(vendors, platform.GPUVendorAMD) && exists("/dev/kfd") && slices.ContainsFunc(rocmRuntimes, exists) {
+		return "rocm"
+	}
+	vulkanCapable := len(vendors) == 0 ||
+		slices.Contains(vendors, platform.GPUVendorAMD) ||
+		slices.Contains(vendors, platform.GPUVendorIntel)
+	if vulkanCapable && exists("/dev/dri/renderD128") && slices.ContainsFunc(vulkanLoaders, exists) {
+		return "vulkan"
+	}
+	return "cpu"
 }
@@ -0,0 +1,72 @@
+package llamacpp
+
+import (
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference/platform"
+)
+
+func TestLinuxVariant(t *testing.T) {
+	tests := []struct {
+		name     string
+		vendors  []platform.GPUVendor
+		files    []string
+		expected string
+	}{
+		{
+			name:     "no GPU",
+			expected: "cpu",
+		},
+		{
+			name:     "NVIDIA driver",
+			files:    []string{"/proc/driver/nvidia/version", "/dev/dri/renderD128", "/usr/lib64/libvulkan.so.1"},
+			expected: "cuda",
+		},
+		{
+			name:     "render node with Vulkan loader",
+			files:    []string{"/dev/dri/renderD128", "/usr/lib/x86_64-linux-gnu/libvulkan.so.1"},
+			expected: "vulkan",
+		},
+		{
+			name:     "render node without Vulkan loader",
+			files:    []string{"/dev/dri/renderD128"},
+			expected: "cpu",
+		},
+		{
+			name:     "AMD GPU with ROCm runtime",
+			vendors:  []platform.GPUVendor{platform.GPUVendorAMD},
+			files:    []string{"/dev/kfd", "/opt/rocm/lib/libamdhip64.so", "/dev/dri/renderD128", "/usr/lib64/libvulkan.so.1"},
+			expected: "rocm",
+		},
+		{
+			name:     "AMD GPU without ROCm runtime",
+			vendors:  []platform.GPUVendor{platform.GPUVendorAMD},
+			files:    []string{"/dev/kfd", "/dev/dri/renderD128", "/usr/lib64/libvulkan.so.1"},
+			expected: "vulkan",
+		},
+		{
+			name:     "Intel GPU",
+			vendors:  []platform.GPUVendor{platform.GPUVendorIntel},
+			files:    []string{"/dev/dri/renderD128", "/usr/lib/x86_64-linux-gnu/libvulkan.so.1"},
+			expected: "vulkan",
+		},
+		{
+			name:     "NVIDIA GPU without driver",
+			vendors:  []platform.GPUVendor{platform.GPUVendorNVIDIA},
+			files:    []string{"/dev/dri/renderD128", "/usr/lib64/libvulkan.so.1"},
+			expected: "cpu",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make(map[string]bool)
+			for _, f := range tt.files {
+				files[f] = true
+			}
+			if got := linuxVariant(tt.vendors, func(path string) bool { return files[path] }); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package llamacpp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference/offline"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "com.docker.llama-server")
+	checksumFile := filepath.Join(dir, ".llamacpp_sha256")
+	if err := os.WriteFile(binary, []byte("llama-server"), 0o755); err != nil {
+		t.Fatalf("failed to write binary: %v", err)
+	}
+
+	if err := verifyChecksum(binary, checksumFile); !errors.Is(err, errLlamaCppChecksumMissing) {
+		t.Fatalf("expected missing checksum error, got %v", err)
+	}
+	if err := writeChecksum(binary, checksumFile); err != nil {
+		t.Fatalf("failed to write checksum: %v", err)
+	}
+	if err := verifyChecksum(binary, checksumFile); err != nil {
+		t.Fatalf("expected checksum to match, got %v", err)
+	}
+
+	if err := os.WriteFile(binary, []byte("tampered"), 0o755); err != nil {
+		t.Fatalf("failed to write binary: %v", err)
+	}
+	if err := verifyChecksum(binary, checksumFile); err == nil {
+		t.Fatal("expected checksum mismatch")
+	}
+}
+
+func TestInstallFromOfflineBundle(t *testing.T) {
+	bundleDir := t.TempDir()
+	variantDir := filepath.Join(bundleDir, "llama.cpp",
+		"com.docker.llama-server.native."+runtime.GOOS+".cpu."+runtime.GOARCH)
+	for path, content := range map[string]string{
+		filepath.Join(variantDir, "bin", "com.docker.llama-server"): "llama-server",
+		filepath.Join(variantDir, "lib", "libggml.so"):              "libggml",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	bundle, err := offline.Open(bundleDir)
+	if err != nil {
+		t.Fatalf("failed to open offline bundle: %v", err)
+	}
+	SetOfflineBundle(bundle)
+	defer SetOfflineBundle(nil)
+
+	l := &llamaCpp{}
+	log := logrus.NewEntry(logrus.StandardLogger())
+	installDir := t.TempDir()
+	llamaCppPath := filepath.Join(installDir, "bin", "com.docker.llama-server")
+
+	if installed, err := l.installFromOfflineBundle(log, llamaCppPath, "cuda"); installed || err != nil {
+		t.Fatalf("expected no install for a variant missing from the bundle, got %v (%v)", installed, err)
+	}
+	if installed, err := l.installFromOfflineBundle(log, llamaCppPath, "cpu"); !installed || err != nil {
+		t.Fatalf("expected an install from the bundle, got %v (%v)", installed, err)
+	}
+	if data, err := os.ReadFile(llamaCppPath); err != nil || string(data) != "llama-server" {
+		t.Errorf("expected the bundled binary to be installed, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(installDir, "lib", "libggml.so")); err != nil {
+		t.Errorf("expected the bundled libraries to be installed: %v", err)
+	}
+	if err := verifyChecksum(llamaCppPath, filepath.Join(installDir, "bin", ".llamacpp_sha256")); err != nil {
+		t.Errorf("expected the installed binary's checksum to be recorded: %v", err)
+	}
+	if _, err := os.Stat(variantDir); err != nil {
+		t.Errorf("expected the bundle to be left intact: %v", err)
+	}
+
+	// A tampered binary is reinstalled from the bundle.
+	if err := os.WriteFile(llamaCppPath, []byte("tampered"), 0o755); err != nil {
+		t.Fatalf("failed to write binary: %v", err)
+	}
+	if installed, err := l.installFromOfflineBundle(log, llamaCppPath, "cpu"); !installed || err != nil {
+		t.Fatalf("expected a reinstall from the bundle, got %v (%v)", installed, err)
+	}
+	if data, err := os.ReadFile(llamaCppPath); err != nil || string(data) != "llama-server" {
+		t.Errorf("expected the bundled binary to be reinstalled, got %q (%v)", data, err)
+	}
+}
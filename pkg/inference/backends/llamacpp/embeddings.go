@@ -0,0 +1,72 @@
+package llamacpp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// ErrInvalidEmbeddingRequest indicates that an embedding request can't be
+// served by the model.
+var ErrInvalidEmbeddingRequest = errors.New("invalid embedding request")
+
+// embeddingNormalizationNone is the llama-server embd_normalize value that
+// disables normalization.
+const embeddingNormalizationNone = -1
+
+// embeddingLength returns the length of the embeddings produced by a model, or
+// 0 if it can't be determined.
+func (l *llamaCpp) embeddingLength(ctx context.Context, model string) uint64 {
+	ggufFile, _, err := l.parseModel(ctx, model)
+	if err != nil {
+		l.log.Warnf("Unable to determine embedding length of %s: %v", model, err)
+		return 0
+	}
+	return ggufFile.Architecture().EmbeddingLength
+}
+
+// translateEmbeddings applies the embedding configuration to a raw embedding
+// request body and validates the requested dimensionality against the model,
+// whose embeddings can be truncated to fewer dimensions but not extended. If
+// there's nothing to do, the body is returned unmodified.
+func translateEmbeddings(body []byte, config *inference.EmbeddingConfig, embeddingLength func(model string) uint64) ([]byte, error) {
+	normalize := config == nil || config.Normalize == nil || *config.Normalize
+	hasDimensions := bytes.Contains(body, []byte(`"dimensions"`))
+	if normalize && !hasDimensions {
+		return body, nil
+	}
+
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEmbeddingRequest, err)
+	}
+
+	if raw, ok := request["dimensions"]; ok {
+		var dimensions uint64
+		if err := json.Unmarshal(raw, &dimensions); err != nil || dimensions == 0 {
+			return nil, fmt.Errorf("%w: dimensions must be a positive integer", ErrInvalidEmbeddingRequest)
+		}
+		var model string
+		if err := json.Unmarshal(request["model"], &model); err != nil {
+			return nil, fmt.Errorf("%w: model is required", ErrInvalidEmbeddingRequest)
+		}
+		if length := embeddingLength(model); length != 0 && dimensions > length {
+			return nil, fmt.Errorf("%w: model %q produces %d-dimensional embeddings, but %d dimensions were requested",
+				ErrInvalidEmbeddingRequest, model, length, dimensions)
+		}
+	}
+
+	// Leave the normalization to the request if it sets it explicitly.
+	if _, ok := request["embd_normalize"]; normalize || ok {
+		return body, nil
+	}
+	var err error
+	if request["embd_normalize"], err = json.Marshal(embeddingNormalizationNone); err != nil {
+		return nil, err
+	}
+	return json.Marshal(request)
+}
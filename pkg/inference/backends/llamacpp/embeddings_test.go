@@ -0,0 +1,110 @@
+package llamacpp
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+func TestTranslateEmbeddings(t *testing.T) {
+	disabled := false
+	enabled := true
+	embeddingLength := func(model string) uint64 {
+		if model == "ai/embeddinggemma" {
+			return 768
+		}
+		return 0
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		config    *inference.EmbeddingConfig
+		expected  string
+		unchanged bool
+		err       bool
+	}{
+		{
+			name:      "no configuration",
+			body:      `{"model":"ai/embeddinggemma","input":"hello"}`,
+			unchanged: true,
+		},
+		{
+			name:      "normalization enabled",
+			body:      `{"model":"ai/embeddinggemma","input":"hello"}`,
+			config:    &inference.EmbeddingConfig{Normalize: &enabled},
+			unchanged: true,
+		},
+		{
+			name:     "normalization disabled",
+			body:     `{"model":"ai/embeddinggemma","input":"hello"}`,
+			config:   &inference.EmbeddingConfig{Normalize: &disabled},
+			expected: `{"model":"ai/embeddinggemma","input":"hello","embd_normalize":-1}`,
+		},
+		{
+			name:      "normalization set by request",
+			body:      `{"model":"ai/embeddinggemma","input":"hello","embd_normalize":2}`,
+			config:    &inference.EmbeddingConfig{Normalize: &disabled},
+			unchanged: true,
+		},
+		{
+			name:      "matching dimensions",
+			body:      `{"model":"ai/embeddinggemma","input":"hello","dimensions":768}`,
+			unchanged: true,
+		},
+		{
+			name:      "fewer dimensions",
+			body:      `{"model":"ai/embeddinggemma","input":"hello","dimensions":256}`,
+			unchanged: true,
+		},
+		{
+			name: "more dimensions",
+			body: `{"model":"ai/embeddinggemma","input":"hello","dimensions":1024}`,
+			err:  true,
+		},
+		{
+			name: "invalid dimensions",
+			body: `{"model":"ai/embeddinggemma","input":"hello","dimensions":-1}`,
+			err:  true,
+		},
+		{
+			name:      "unknown embedding length",
+			body:      `{"model":"ai/unknown","input":"hello","dimensions":256}`,
+			unchanged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := translateEmbeddings([]byte(tt.body), tt.config, embeddingLength)
+			if tt.err {
+				if !errors.Is(err, ErrInvalidEmbeddingRequest) {
+					t.Fatalf("expected ErrInvalidEmbeddingRequest, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.unchanged {
+				if string(got) != tt.body {
+					t.Errorf("expected body to be unchanged, got %s", got)
+				}
+				return
+			}
+			var gotJSON, expectedJSON any
+			if err := json.Unmarshal(got, &gotJSON); err != nil {
+				t.Fatalf("invalid output JSON: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.expected), &expectedJSON); err != nil {
+				t.Fatalf("invalid expected JSON: %v", err)
+			}
+			if !reflect.DeepEqual(gotJSON, expectedJSON) {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package llamacpp
+
+import (
+	"strconv"
+
+	parser "github.com/gpustack/gguf-parser-go"
+)
+
+const (
+	// allGPULayers is the -ngl value that requests offloading every layer. It's
+	// reduced automatically when the model doesn't fit in VRAM.
+	allGPULayers uint64 = 999
+)
+
+// gpuLayersFlags are the llama.cpp flags that set the number of offloaded
+// layers.
+var gpuLayersFlags = map[string]bool{
+	"-ngl":           true,
+	"--gpu-layers":   true,
+	"--n-gpu-layers": true,
+}
+
+// autoGPULayers returns true if the number of offloaded layers may be tuned
+// automatically, i.e. unless args explicitly request a number of layers other
+// than allGPULayers.
+func autoGPULayers(args []string) bool {
+	value, ok := lastGPULayers(args)
+	return !ok || value == strconv.FormatUint(allGPULayers, 10)
+}
+
+// lastGPULayers returns the value of the last flag in args that sets the
+// number of offloaded layers.
+func lastGPULayers(args []string) (string, bool) {
+	var value string
+	var found bool
+	for i := 0; i < len(args)-1; i++ {
+		if gpuLayersFlags[args[i]] {
+			value, found = args[i+1], true
+		}
+	}
+	return value, found
+}
+
+// setGPULayers sets the number of offloaded layers in args, replacing any
+// existing value.
+func setGPULayers(args []string, layers uint64) []string {
+	value := strconv.FormatUint(layers, 10)
+	result := make([]string, 0, len(args)+2)
+	for i := 0; i < len(args); i++ {
+		if gpuLayersFlags[args[i]] && i+1 < len(args) {
+			i++
+			continue
+		}
+		result = append(result, args[i])
+	}
+	return append(result, "-ngl", value)
+}
+
+// totalGPULayers returns the number of layers llama.cpp can offload for a
+// model: its repeating blocks plus the output layer.
+func totalGPULayers(ggufFile *parser.GGUFFile) uint64 {
+	return ggufFile.Architecture().BlockCount + 1
+}
+
+// fitGPULayers returns the largest number of layers, up to total, whose
+// estimated VRAM usage doesn't exceed budget. The estimate must be
+// non-decreasing in the number of layers.
+func fitGPULayers(total, budget uint64, estimateVRAM func(layers uint64) uint64) uint64 {
+	if estimateVRAM(total) <= budget {
+		return total
+	}
+	low, high := uint64(0), total
+	for low < high {
+		mid := (low + high + 1) / 2
+		if estimateVRAM(mid) <= budget {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low
+}
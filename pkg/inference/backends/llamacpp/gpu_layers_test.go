@@ -0,0 +1,97 @@
+package llamacpp
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFitGPULayers(t *testing.T) {
+	const layerSize = 100
+	tests := []struct {
+		name     string
+		total    uint64
+		budget   uint64
+		expected uint64
+	}{
+		{
+			name:     "all layers fit",
+			total:    33,
+			budget:   10000,
+			expected: 33,
+		},
+		{
+			name:     "exact fit",
+			total:    33,
+			budget:   50 + 33*layerSize,
+			expected: 33,
+		},
+		{
+			name:     "partial offload",
+			total:    33,
+			budget:   50 + 20*layerSize + 99,
+			expected: 20,
+		},
+		{
+			name:     "no layers fit",
+			total:    33,
+			budget:   10,
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate := func(layers uint64) uint64 {
+				return 50 + layers*layerSize
+			}
+			if got := fitGPULayers(tt.total, tt.budget, estimate); got != tt.expected {
+				t.Errorf("expected %d layers, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAutoGPULayers(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected bool
+	}{
+		{
+			name:     "default",
+			args:     []string{"-ngl", "999", "--metrics"},
+			expected: true,
+		},
+		{
+			name:     "not set",
+			args:     []string{"--metrics"},
+			expected: true,
+		},
+		{
+			name:     "explicit layers",
+			args:     []string{"-ngl", "999", "--metrics", "--n-gpu-layers", "20"},
+			expected: false,
+		},
+		{
+			name:     "explicit full offload",
+			args:     []string{"--gpu-layers", "999"},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := autoGPULayers(tt.args); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSetGPULayers(t *testing.T) {
+	args := []string{"-ngl", "999", "--metrics", "--model", "model.gguf", "--gpu-layers", "999"}
+	expected := []string{"--metrics", "--model", "model.gguf", "-ngl", "12"}
+	if got := setGPULayers(args, 12); !slices.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
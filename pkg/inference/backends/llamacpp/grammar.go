@@ -0,0 +1,47 @@
+package llamacpp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/model-runner/pkg/inference/structured"
+)
+
+// translateStructuredOutput compiles the strict JSON schema response format of
+// a raw completion request body to a GBNF grammar, which llama-server
+// constrains sampling with. Schemas that can't be compiled are left to
+// llama-server's own schema conversion. If the request doesn't have a strict
+// JSON schema response format, the body is returned unmodified.
+func translateStructuredOutput(body []byte) ([]byte, error) {
+	if !bytes.Contains(body, []byte(`"response_format"`)) {
+		return body, nil
+	}
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return body, nil
+	}
+	format, err := structured.ParseResponseFormat(request)
+	if err != nil {
+		return nil, err
+	}
+	if format == nil || !format.Strict {
+		return body, nil
+	}
+	if _, ok := request["grammar"]; ok {
+		return nil, fmt.Errorf("%w: grammar can't be combined with a strict JSON schema response format", structured.ErrInvalidRequest)
+	}
+
+	grammar, err := structured.Grammar(format.Schema)
+	if errors.Is(err, structured.ErrUnsupportedGrammar) {
+		return body, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", structured.ErrInvalidRequest, err)
+	}
+	if request["grammar"], err = json.Marshal(grammar); err != nil {
+		return nil, err
+	}
+	delete(request, "response_format")
+	return json.Marshal(request)
+}
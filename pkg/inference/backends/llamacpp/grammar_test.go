@@ -0,0 +1,45 @@
+package llamacpp
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference/structured"
+)
+
+func TestTranslateStructuredOutput(t *testing.T) {
+	body := `{"model":"m","response_format":{"type":"json_schema","json_schema":{"name":"x","strict":true,"schema":{"type":"object","properties":{"a":{"type":"boolean"}},"required":["a"],"additionalProperties":false}}}}`
+	translated, err := translateStructuredOutput([]byte(body))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(translated, &request); err != nil {
+		t.Fatal(err)
+	}
+	var grammar string
+	if err := json.Unmarshal(request["grammar"], &grammar); err != nil || !strings.HasPrefix(grammar, "root ::= ") {
+		t.Errorf("Expected a grammar, got %s", translated)
+	}
+	if _, ok := request["response_format"]; ok {
+		t.Errorf("Expected the response format to be replaced, got %s", translated)
+	}
+
+	// Formats that aren't strict, and schemas that can't be compiled, are
+	// left to llama-server.
+	for _, body := range []string{
+		`{"model":"m"}`,
+		`{"model":"m","response_format":{"type":"json_schema","json_schema":{"schema":{"type":"object"}}}}`,
+		`{"model":"m","response_format":{"type":"json_schema","json_schema":{"strict":true,"schema":{"type":"string","pattern":"^a"}}}}`,
+	} {
+		if translated, err := translateStructuredOutput([]byte(body)); err != nil || string(translated) != body {
+			t.Errorf("Expected %s to be unchanged, got %s, %v", body, translated, err)
+		}
+	}
+
+	if _, err := translateStructuredOutput([]byte(`{"grammar":"root ::= \"a\"","response_format":{"type":"json_schema","json_schema":{"strict":true,"schema":{}}}}`)); !errors.Is(err, structured.ErrInvalidRequest) {
+		t.Errorf("Expected a grammar with a strict format to be rejected, got %v", err)
+	}
+}
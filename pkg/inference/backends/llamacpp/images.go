@@ -0,0 +1,249 @@
+package llamacpp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// maximumImageSize is the maximum decoded size of an image in a chat
+	// completion request.
+	maximumImageSize = 10 * 1024 * 1024
+	// imageFetchTimeout is the timeout for fetching an image URL.
+	imageFetchTimeout = 30 * time.Second
+	// maximumImages is the maximum number of images in a chat completion
+	// request, since each is held in memory while the request is translated.
+	maximumImages = 8
+)
+
+// ErrInvalidImageRequest indicates that a chat completion request contains
+// image content that can't be passed to the model.
+var ErrInvalidImageRequest = errors.New("invalid image content")
+
+// errNonPublicAddress indicates that an image URL resolved to an address that
+// isn't publicly routable.
+var errNonPublicAddress = errors.New("address isn't public")
+
+// imageClient is the client used to fetch image URLs, which only connects to
+// public addresses, so that clients can't have the model runner reach the
+// services of its host or network. It can be overridden for testing.
+var imageClient = newImageClient(publicAddress)
+
+// newImageClient creates a client to fetch image URLs that only connects to
+// the addresses that allowed accepts. Addresses are checked once resolved,
+// including when following redirects, and proxies aren't used, since they
+// would resolve addresses themselves.
+func newImageClient(allowed func(netip.Addr) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: imageFetchTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allowed(addrPort.Addr().Unmap()) {
+				return errNonPublicAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: imageFetchTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// reservedPrefixes are the prefixes of unicast addresses that aren't public
+// besides private ones: "this network" and the space shared by carrier-grade
+// NATs.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// publicAddress returns whether an address is publicly routable, as opposed
+// to loopback, private, link-local (such as cloud metadata services), or
+// otherwise reserved.
+func publicAddress(addr netip.Addr) bool {
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// supportsImages returns true if the model has a multimodal projector.
+func (l *llamaCpp) supportsImages(model string) bool {
+	bundle, err := l.modelManager.GetBundle(model)
+	if err != nil {
+		l.log.Warnf("Unable to get bundle for %s: %v", model, err)
+		return false
+	}
+	return bundle.MMPROJPath() != ""
+}
+
+// translateImages inlines the image URLs of a raw chat completion request
+// body. If the request doesn't contain images, the body is returned
+// unmodified.
+func translateImages(ctx context.Context, body []byte, supportsImages func(model string) bool) ([]byte, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImageRequest, err)
+	}
+	var messages []map[string]json.RawMessage
+	if raw, ok := request["messages"]; !ok {
+		return body, nil
+	} else if err := json.Unmarshal(raw, &messages); err != nil {
+		return nil, fmt.Errorf("%w: messages: %v", ErrInvalidImageRequest, err)
+	}
+
+	images := 0
+	for _, message := range messages {
+		var parts []map[string]json.RawMessage
+		// Content given as a plain string can't contain images.
+		if err := json.Unmarshal(message["content"], &parts); err != nil {
+			continue
+		}
+		for _, part := range parts {
+			var partType string
+			if err := json.Unmarshal(part["type"], &partType); err != nil || partType != "image_url" {
+				continue
+			}
+			if images == 0 {
+				var model string
+				if err := json.Unmarshal(request["model"], &model); err != nil || !supportsImages(model) {
+					return nil, fmt.Errorf("%w: model %q doesn't support images", ErrInvalidImageRequest, model)
+				}
+			}
+			if images++; images > maximumImages {
+				return nil, fmt.Errorf("%w: requests can contain at most %d images", ErrInvalidImageRequest, maximumImages)
+			}
+
+			var imageURL map[string]json.RawMessage
+			if err := json.Unmarshal(part["image_url"], &imageURL); err != nil {
+				return nil, fmt.Errorf("%w: image_url: %v", ErrInvalidImageRequest, err)
+			}
+			var url string
+			if err := json.Unmarshal(imageURL["url"], &url); err != nil {
+				return nil, fmt.Errorf("%w: image_url.url is required", ErrInvalidImageRequest)
+			}
+			dataURL, err := inlineImage(ctx, url)
+			if err != nil {
+				return nil, err
+			}
+			if imageURL["url"], err = json.Marshal(dataURL); err != nil {
+				return nil, err
+			}
+			if part["image_url"], err = json.Marshal(imageURL); err != nil {
+				return nil, err
+			}
+		}
+		var err error
+		if message["content"], err = json.Marshal(parts); err != nil {
+			return nil, err
+		}
+	}
+	if images == 0 {
+		return body, nil
+	}
+
+	var err error
+	if request["messages"], err = json.Marshal(messages); err != nil {
+		return nil, err
+	}
+	return json.Marshal(request)
+}
+
+// inlineImage returns a base64 data URL for the image at url, which may
+// itself be a data URL.
+func inlineImage(ctx context.Context, url string) (string, error) {
+	switch {
+	case strings.HasPrefix(url, "data:"):
+		if err := validateDataURL(url); err != nil {
+			return "", err
+		}
+		return url, nil
+	case strings.HasPrefix(url, "http://"), strings.HasPrefix(url, "https://"):
+		return fetchImage(ctx, url)
+	default:
+		return "", fmt.Errorf("%w: unsupported image URL scheme", ErrInvalidImageRequest)
+	}
+}
+
+// validateDataURL checks that a data URL contains a base64 encoded image no
+// larger than maximumImageSize.
+func validateDataURL(url string) error {
+	header, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !ok {
+		return fmt.Errorf("%w: malformed data URL", ErrInvalidImageRequest)
+	}
+	mediaType, encoding, _ := strings.Cut(header, ";")
+	if !strings.HasPrefix(mediaType, "image/") || encoding != "base64" {
+		return fmt.Errorf("%w: data URL must contain a base64 encoded image", ErrInvalidImageRequest)
+	}
+	if base64.StdEncoding.DecodedLen(len(data)) > maximumImageSize {
+		return fmt.Errorf("%w: image exceeds %d bytes", ErrInvalidImageRequest, maximumImageSize)
+	}
+	if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+		return fmt.Errorf("%w: invalid base64 image data: %v", ErrInvalidImageRequest, err)
+	}
+	return nil
+}
+
+// fetchImage downloads the image at url and returns it as a base64 data URL.
+// Failures to connect or unsuccessful responses are reported alike, so that
+// the errors don't reveal which hosts and ports are reachable.
+func fetchImage(ctx context.Context, url string) (string, error) {
+	errFetch := fmt.Errorf("%w: unable to fetch image %s", ErrInvalidImageRequest, url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidImageRequest, err)
+	}
+	resp, err := imageClient.Do(req)
+	if err != nil {
+		return "", errFetch
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errFetch
+	}
+	if resp.ContentLength > maximumImageSize {
+		return "", fmt.Errorf("%w: image exceeds %d bytes", ErrInvalidImageRequest, maximumImageSize)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maximumImageSize+1))
+	if err != nil {
+		return "", errFetch
+	}
+	if len(data) > maximumImageSize {
+		return "", fmt.Errorf("%w: image exceeds %d bytes", ErrInvalidImageRequest, maximumImageSize)
+	}
+
+	// Prefer the detected type, since servers often report a generic one.
+	mediaType := http.DetectContentType(data)
+	if !strings.HasPrefix(mediaType, "image/") {
+		mediaType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	}
+	if !strings.HasPrefix(mediaType, "image/") {
+		return "", fmt.Errorf("%w: %s is not an image", ErrInvalidImageRequest, url)
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
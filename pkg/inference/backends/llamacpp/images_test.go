@@ -0,0 +1,169 @@
+package llamacpp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// png is the signature of a PNG file, enough for content type detection.
+var png = []byte("\x89PNG\r\n\x1a\n0000")
+
+func TestTranslateImages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.png":
+			w.Write(png)
+		case "/large.png":
+			w.Write(append(png, make([]byte, maximumImageSize)...))
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	// The test server is on a loopback address.
+	defer func(client *http.Client) { imageClient = client }(imageClient)
+	imageClient = newImageClient(func(netip.Addr) bool { return true })
+
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+	request := func(model, url string) string {
+		return fmt.Sprintf(`{"model":%q,"messages":[{"role":"user","content":[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":%q}}]}]}`, model, url)
+	}
+	supportsImages := func(model string) bool {
+		return model == "vision"
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name: "no images",
+			body: `{"model":"text","messages":[{"role":"user","content":"Hello"}]}`,
+		},
+		{
+			name:     "data URL",
+			body:     request("vision", dataURL),
+			expected: dataURL,
+		},
+		{
+			name:     "fetched URL",
+			body:     request("vision", server.URL+"/image.png"),
+			expected: dataURL,
+		},
+		{
+			name:    "model without projector",
+			body:    request("text", dataURL),
+			wantErr: true,
+		},
+		{
+			name:    "image too large",
+			body:    request("vision", server.URL+"/large.png"),
+			wantErr: true,
+		},
+		{
+			name:    "not an image",
+			body:    request("vision", server.URL+"/page.html"),
+			wantErr: true,
+		},
+		{
+			name:    "fetch failure",
+			body:    request("vision", server.URL+"/missing.png"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid base64",
+			body:    request("vision", "data:image/png;base64,!!!"),
+			wantErr: true,
+		},
+		{
+			name:    "unsupported scheme",
+			body:    request("vision", "file:///etc/passwd"),
+			wantErr: true,
+		},
+		{
+			name: "too many images",
+			body: `{"model":"vision","messages":[{"role":"user","content":[` +
+				strings.Repeat(fmt.Sprintf(`{"type":"image_url","image_url":{"url":%q}},`, dataURL), maximumImages) +
+				fmt.Sprintf(`{"type":"image_url","image_url":{"url":%q}}]}]}`, dataURL),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := translateImages(context.Background(), []byte(tt.body), supportsImages)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidImageRequest) {
+					t.Fatalf("expected ErrInvalidImageRequest, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.expected == "" {
+				if string(result) != tt.body {
+					t.Errorf("expected body to be unmodified, got %s", result)
+				}
+				return
+			}
+			var translated struct {
+				Messages []struct {
+					Content []struct {
+						ImageURL struct {
+							URL string `json:"url"`
+						} `json:"image_url"`
+					} `json:"content"`
+				} `json:"messages"`
+			}
+			if err := json.Unmarshal(result, &translated); err != nil {
+				t.Fatalf("invalid result: %v", err)
+			}
+			if url := translated.Messages[0].Content[1].ImageURL.URL; url != tt.expected {
+				t.Errorf("expected URL %q, got %q", tt.expected, url)
+			}
+		})
+	}
+}
+
+func TestFetchImageNonPublicAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(png)
+	}))
+	defer server.Close()
+
+	if _, err := fetchImage(context.Background(), server.URL+"/image.png"); !errors.Is(err, ErrInvalidImageRequest) {
+		t.Fatalf("expected fetching from a loopback address to fail, got %v", err)
+	}
+
+	for address, public := range map[string]bool{
+		"93.184.215.14":        true,
+		"2606:2800:21f:cb07::": true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"192.168.65.254":       false,
+		"169.254.169.254":      false,
+		"100.100.100.200":      false,
+		"0.0.0.0":              false,
+		"fd00:ec2::254":        false,
+		"::ffff:127.0.0.1":     false,
+	} {
+		if got := publicAddress(netip.MustParseAddr(address).Unmap()); got != public {
+			t.Errorf("publicAddress(%s) = %t, want %t", address, got, public)
+		}
+	}
+}
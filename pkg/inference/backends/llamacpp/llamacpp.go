@@ -1,7 +1,6 @@
 package llamacpp
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"errors"
@@ -9,10 +8,9 @@ import (
 	"net/http"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
-	"strings"
 
 	"github.com/docker/model-runner/pkg/distribution/types"
 	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
@@ -52,6 +50,15 @@ type llamaCpp struct {
 	config config.BackendConfig
 	// gpuSupported indicates whether the underlying llama-server is built with GPU support.
 	gpuSupported bool
+	// accelerator is the GGML backend driving llama-server's GPUs, e.g. cuda,
+	// rocm, or vulkan. It's empty if llama-server runs on the CPU.
+	accelerator string
+	// deviceVRAM is the total memory of the GPUs reported by llama-server,
+	// used if the system VRAM size is unknown.
+	deviceVRAM uint64
+	// vramSize is the total VRAM available to llama-server, used to limit the
+	// number of offloaded layers. Values of 0 or 1 mean that it's unknown.
+	vramSize uint64
 }
 
 // New creates a new llama.cpp-based backend.
@@ -62,6 +69,7 @@ func New(
 	vendoredServerStoragePath string,
 	updatedServerStoragePath string,
 	conf config.BackendConfig,
+	vramSize uint64,
 ) (inference.Backend, error) {
 	// If no config is provided, use the default configuration
 	if conf == nil {
@@ -75,6 +83,7 @@ func New(
 		vendoredServerStoragePath: vendoredServerStoragePath,
 		updatedServerStoragePath:  updatedServerStoragePath,
 		config:                    conf,
+		vramSize:                  vramSize,
 	}, nil
 }
 
@@ -89,6 +98,38 @@ func (l *llamaCpp) UsesExternalModelManagement() bool {
 	return false
 }
 
+// SupportsAdapters implements inference.AdapterBackend.SupportsAdapters.
+func (l *llamaCpp) SupportsAdapters() bool {
+	return true
+}
+
+// AppliesChatTemplates implements
+// inference.ChatTemplateBackend.AppliesChatTemplates.
+func (l *llamaCpp) AppliesChatTemplates() bool {
+	return true
+}
+
+// TokenizerFormat implements inference.TokenizerBackend.TokenizerFormat.
+// llama-server tokenizes text with the tokenizer embedded in the GGUF file of
+// the model.
+func (l *llamaCpp) TokenizerFormat() inference.TokenizerFormat {
+	return inference.TokenizerFormatLlamaCpp
+}
+
+// ParsesToolCalls implements inference.ToolCallBackend.ParsesToolCalls.
+// llama-server only parses tool calls with Jinja chat templates, which aren't
+// enabled for models with a multimodal projector unless they're requested.
+func (l *llamaCpp) ParsesToolCalls(model string, config *inference.BackendConfiguration) bool {
+	return !l.supportsImages(model) || (config != nil && slices.Contains(config.RuntimeFlags, "--jinja"))
+}
+
+// RawRerankScores implements inference.RerankBackend.RawRerankScores.
+// llama-server scores documents with the output of the classification head of
+// reranker models.
+func (l *llamaCpp) RawRerankScores() bool {
+	return true
+}
+
 // Install implements inference.Backend.Install.
 func (l *llamaCpp) Install(ctx context.Context, httpClient *http.Client) error {
 	l.updatedLlamaCpp = false
@@ -124,8 +165,16 @@ func (l *llamaCpp) Install(ctx context.Context, httpClient *http.Client) error {
 		l.updatedLlamaCpp = true
 	}
 
-	l.gpuSupported = l.checkGPUSupport(ctx)
-	l.log.Infof("installed llama-server with gpuSupport=%t", l.gpuSupported)
+	devices := l.listDevices(ctx)
+	l.gpuSupported = len(devices) > 0
+	l.accelerator, l.deviceVRAM = "", 0
+	if l.gpuSupported {
+		l.accelerator = devices[0].accelerator
+		for _, dev := range devices {
+			l.deviceVRAM += dev.memory
+		}
+	}
+	l.log.Infof("installed llama-server with gpuSupport=%t accelerator=%s", l.gpuSupported, l.accelerator)
 
 	return nil
 }
@@ -145,6 +194,11 @@ func (l *llamaCpp) Run(ctx context.Context, socket, model string, _ string, mode
 		}
 	}
 
+	adapterBundles, err := l.modelManager.GetAdapterBundles(model)
+	if err != nil {
+		return fmt.Errorf("failed to get adapters: %w", err)
+	}
+
 	binPath := l.vendoredServerStoragePath
 	if l.updatedLlamaCpp {
 		binPath = l.updatedServerStoragePath
@@ -155,6 +209,17 @@ func (l *llamaCpp) Run(ctx context.Context, socket, model string, _ string, mode
 		return fmt.Errorf("failed to get args for llama.cpp: %w", err)
 	}
 
+	// Offload only as many layers as fit in VRAM, unless the number of layers
+	// has been set explicitly.
+	if autoGPULayers(args) {
+		if _, ngl, partial, err := l.estimateMemory(ctx, model, config); err != nil {
+			l.log.Warnf("Unable to determine GPU layers for %s, offloading all layers: %v", model, err)
+		} else if partial {
+			l.log.Infof("Model %s doesn't fit in VRAM, offloading %d layers to the GPU", model, ngl)
+			args = setGPULayers(args, ngl)
+		}
+	}
+
 	if draftBundle != nil && config != nil && config.Speculative != nil {
 		draftPath := draftBundle.GGUFPath()
 		if draftPath != "" {
@@ -168,6 +233,10 @@ func (l *llamaCpp) Run(ctx context.Context, socket, model string, _ string, mode
 		}
 	}
 
+	for _, adapter := range adapterBundles {
+		args = append(args, "--lora", adapter.AdapterPath())
+	}
+
 	return backends.RunBackend(ctx, backends.RunnerConfig{
 		BackendName:     "llama.cpp",
 		Socket:          socket,
@@ -175,15 +244,51 @@ func (l *llamaCpp) Run(ctx context.Context, socket, model string, _ string, mode
 		SandboxPath:     binPath,
 		SandboxConfig:   sandbox.ConfigurationLlamaCpp,
 		Args:            args,
+		Devices:         config.AssignedDevices(),
 		Logger:          l.log,
 		ServerLogWriter: l.serverLog.Writer(),
 	})
 }
 
 func (l *llamaCpp) Status() string {
+	if l.accelerator != "" {
+		return fmt.Sprintf("%s (accelerator: %s)", l.status, l.accelerator)
+	}
 	return l.status
 }
 
+// TranslateRequest implements inference.RequestTranslator.TranslateRequest. In
+// completion mode, it compiles strict JSON schema response formats to
+// grammars, pins prompt prefixes identified by prompt cache keys to slots,
+// validates the image content parts of chat completion requests, and inlines
+// images referenced by URL, since llama-server only accepts base64 data URLs.
+// In embedding mode, it applies the runner's
+// embedding configuration and validates the requested dimensionality.
+func (l *llamaCpp) TranslateRequest(ctx context.Context, mode inference.BackendMode, config *inference.BackendConfiguration, body []byte) ([]byte, error) {
+	switch mode {
+	case inference.BackendModeCompletion:
+		body, err := translateStructuredOutput(body)
+		if err != nil {
+			return nil, err
+		}
+		body, err = translatePromptCache(body, inference.PromptCacheKey(ctx), l.slots(config))
+		if err != nil || !bytes.Contains(body, []byte(`"image_url"`)) {
+			return body, err
+		}
+		return translateImages(ctx, body, l.supportsImages)
+	case inference.BackendModeEmbedding:
+		var embeddings *inference.EmbeddingConfig
+		if config != nil {
+			embeddings = config.Embeddings
+		}
+		return translateEmbeddings(body, embeddings, func(model string) uint64 {
+			return l.embeddingLength(ctx, model)
+		})
+	default:
+		return body, nil
+	}
+}
+
 func (l *llamaCpp) GetDiskUsage() (int64, error) {
 	size, err := diskusage.Size(l.updatedServerStoragePath)
 	if err != nil {
@@ -193,38 +298,88 @@ func (l *llamaCpp) GetDiskUsage() (int64, error) {
 }
 
 func (l *llamaCpp) GetRequiredMemoryForModel(ctx context.Context, model string, config *inference.BackendConfiguration) (inference.RequiredMemory, error) {
+	memory, _, _, err := l.estimateMemory(ctx, model, config)
+	return memory, err
+}
+
+// estimateMemory estimates the memory required to run a model, along with the
+// number of layers to offload to the GPU. If the number of offloaded layers
+// isn't set explicitly, it's limited to the layers that fit in VRAM, in which
+// case partial is true.
+func (l *llamaCpp) estimateMemory(ctx context.Context, model string, config *inference.BackendConfiguration) (memory inference.RequiredMemory, ngl uint64, partial bool, err error) {
 	mdlGguf, mdlConfig, err := l.parseModel(ctx, model)
 	if err != nil {
-		return inference.RequiredMemory{}, &inference.ErrGGUFParse{Err: err}
+		return inference.RequiredMemory{}, 0, false, &inference.ErrGGUFParse{Err: err}
 	}
 
 	contextSize := GetContextSize(mdlConfig, config)
+	cacheType := l.kvCacheType(config)
 
-	var ngl uint64
 	if l.gpuSupported {
-		ngl = 999
+		ngl = allGPULayers
 		if runtime.GOOS == "windows" && runtime.GOARCH == "arm64" && mdlConfig.Quantization != "Q4_0" {
 			ngl = 0 // only Q4_0 models can be accelerated on Adreno
 		}
 	}
 
-	memory := l.estimateMemoryFromGGUF(mdlGguf, contextSize, ngl)
-
+	var draftMemory inference.RequiredMemory
 	if config != nil && config.Speculative != nil && config.Speculative.DraftModel != "" {
 		draftGguf, _, err := l.parseModel(ctx, config.Speculative.DraftModel)
 		if err != nil {
-			return inference.RequiredMemory{}, fmt.Errorf("estimating draft model memory: %w", &inference.ErrGGUFParse{Err: err})
+			return inference.RequiredMemory{}, 0, false, fmt.Errorf("estimating draft model memory: %w", &inference.ErrGGUFParse{Err: err})
+		}
+		draftMemory = l.estimateMemoryFromGGUF(draftGguf, contextSize, ngl, "")
+	}
+
+	// Fall back to partial offload if the model and its draft model don't fit
+	// in VRAM together.
+	if vramSize := l.availableVRAM(); ngl > 0 && vramSize > 1 && l.autoGPULayers(config) && !(runtime.GOOS == "windows" && runtime.GOARCH == "arm64") {
+		var budget uint64
+		if draftMemory.VRAM < vramSize {
+			budget = vramSize - draftMemory.VRAM
+		}
+		total := totalGPULayers(mdlGguf)
+		layers := fitGPULayers(total, budget, func(layers uint64) uint64 {
+			return l.estimateMemoryFromGGUF(mdlGguf, contextSize, layers, cacheType).VRAM
+		})
+		if layers < total {
+			ngl, partial = layers, true
 		}
-		draftMemory := l.estimateMemoryFromGGUF(draftGguf, contextSize, ngl)
-		memory.RAM += draftMemory.RAM
-		memory.VRAM += draftMemory.VRAM
 	}
 
+	memory = l.estimateMemoryFromGGUF(mdlGguf, contextSize, ngl, cacheType)
+	memory.RAM += draftMemory.RAM
+	memory.VRAM += draftMemory.VRAM
+
 	if runtime.GOOS == "windows" && runtime.GOARCH == "arm64" {
 		memory.VRAM = 1
 	}
 
-	return memory, nil
+	return memory, ngl, partial, nil
+}
+
+// availableVRAM returns the VRAM available to llama-server. The system VRAM
+// size is only known for some GPUs (e.g. NVIDIA GPUs on Linux), so the memory
+// reported by llama-server's devices is used for others, such as AMD and Intel
+// GPUs driven by ROCm or Vulkan.
+func (l *llamaCpp) availableVRAM() uint64 {
+	if l.vramSize > 1 {
+		return l.vramSize
+	}
+	return l.deviceVRAM
+}
+
+// autoGPULayers returns true if the number of offloaded layers hasn't been set
+// explicitly in the backend or runner configuration.
+func (l *llamaCpp) autoGPULayers(config *inference.BackendConfiguration) bool {
+	var args []string
+	if c, ok := l.config.(*Config); ok {
+		args = append(args, c.Args...)
+	}
+	if config != nil {
+		args = append(args, config.RuntimeFlags...)
+	}
+	return autoGPULayers(args)
 }
 
 // parseModel parses a model (local or remote) and returns the GGUF file and config.
@@ -239,13 +394,40 @@ func (l *llamaCpp) parseModel(ctx context.Context, model string) (*parser.GGUFFi
 	return l.parseRemoteModel(ctx, model)
 }
 
+// kvCacheType returns the KV cache type for a runner, which may be empty if
+// llama.cpp's default is used.
+func (l *llamaCpp) kvCacheType(config *inference.BackendConfiguration) inference.KVCacheType {
+	if c, ok := l.config.(*Config); ok {
+		return c.kvCacheType(config)
+	}
+	if config != nil {
+		return config.KVCacheType
+	}
+	return ""
+}
+
+// kvCacheGGMLTypes maps quantized KV cache types to their GGML types.
+var kvCacheGGMLTypes = map[inference.KVCacheType]parser.GGMLType{
+	inference.KVCacheTypeQ8_0: parser.GGMLTypeQ8_0,
+	inference.KVCacheTypeQ4_0: parser.GGMLTypeQ4_0,
+}
+
 // estimateMemoryFromGGUF estimates memory requirements from a parsed GGUF file.
-func (l *llamaCpp) estimateMemoryFromGGUF(ggufFile *parser.GGUFFile, contextSize uint64, ngl uint64) inference.RequiredMemory {
-	estimate := ggufFile.EstimateLLaMACppRun(
+func (l *llamaCpp) estimateMemoryFromGGUF(ggufFile *parser.GGUFFile, contextSize uint64, ngl uint64, cacheType inference.KVCacheType) inference.RequiredMemory {
+	options := []parser.GGUFRunEstimateOption{
 		parser.WithLLaMACppContextSize(int32(contextSize)),
 		parser.WithLLaMACppLogicalBatchSize(2048),
 		parser.WithLLaMACppOffloadLayers(ngl),
-	)
+	}
+	if ggmlType, ok := kvCacheGGMLTypes[cacheType]; ok {
+		// llama.cpp needs flash attention for a quantized V cache.
+		options = append(options,
+			parser.WithLLaMACppCacheKeyType(ggmlType),
+			parser.WithLLaMACppCacheValueType(ggmlType),
+			parser.WithFlashAttention(),
+		)
+	}
+	estimate := ggufFile.EstimateLLaMACppRun(options...)
 	ram := uint64(estimate.Devices[0].Weight.Sum() + estimate.Devices[0].KVCache.Sum() + estimate.Devices[0].Computation.Sum())
 	var vram uint64
 	if len(estimate.Devices) > 1 {
@@ -325,7 +507,9 @@ func getGGUFLayers(layers []v1.Layer) []v1.Layer {
 	return filtered
 }
 
-func (l *llamaCpp) checkGPUSupport(ctx context.Context) bool {
+// listDevices returns the GPUs that llama-server can use, which depend on
+// both the host and the installed llama.cpp variant.
+func (l *llamaCpp) listDevices(ctx context.Context) []device {
 	binPath := l.vendoredServerStoragePath
 	if l.updatedLlamaCpp {
 		binPath = l.updatedServerStoragePath
@@ -344,25 +528,12 @@ func (l *llamaCpp) checkGPUSupport(ctx context.Context) bool {
 	)
 	if err != nil {
 		l.log.Warnf("Failed to start sandboxed llama.cpp process to probe GPU support: %v", err)
-		return false
+		return nil
 	}
 	defer llamaCppSandbox.Close()
 	if err := llamaCppSandbox.Command().Wait(); err != nil {
 		l.log.Warnf("Failed to determine if llama-server is built with GPU support: %v", err)
-		return false
-	}
-	sc := bufio.NewScanner(strings.NewReader(output.String()))
-	expectDev := false
-	devRe := regexp.MustCompile(`\s{2}.*:\s`)
-	ndevs := 0
-	for sc.Scan() {
-		if expectDev {
-			if devRe.MatchString(sc.Text()) {
-				ndevs++
-			}
-		} else {
-			expectDev = strings.HasPrefix(sc.Text(), "Available devices:")
-		}
+		return nil
 	}
-	return ndevs > 0
+	return parseDevices(output.String())
 }
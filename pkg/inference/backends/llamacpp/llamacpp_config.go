@@ -13,6 +13,18 @@ import (
 type Config struct {
 	// Args are the base arguments that are always included.
 	Args []string
+	// KVCacheType is the default KV cache type, used unless it's overridden
+	// by the runner configuration. llama.cpp's default is used if it's empty.
+	KVCacheType inference.KVCacheType
+	// Threads is the number of threads used for generation. llama.cpp's
+	// default is used if it's 0.
+	Threads int
+	// BatchThreads is the number of threads used for batch and prompt
+	// processing. Threads is used if it's 0.
+	BatchThreads int
+	// NUMA is the NUMA optimization strategy. NUMA optimizations are disabled
+	// if it's empty.
+	NUMA NUMAStrategy
 }
 
 // NewDefaultLlamaCppConfig creates a new LlamaCppConfig with default values.
@@ -24,18 +36,12 @@ func NewDefaultLlamaCppConfig() *Config {
 		args = append(args, "--no-mmap")
 	}
 
-	// Special case for ARM64
-	if runtime.GOARCH == "arm64" {
-		// Using a thread count equal to core count results in bad performance, and there seems to be little to no gain
-		// in going beyond core_count/2.
-		if !containsArg(args, "--threads") {
-			nThreads := max(2, runtime.NumCPU()/2)
-			args = append(args, "--threads", strconv.Itoa(nThreads))
-		}
-	}
+	threads, numa := defaultThreads()
 
 	return &Config{
-		Args: args,
+		Args:    args,
+		Threads: threads,
+		NUMA:    numa,
 	}
 }
 
@@ -44,6 +50,17 @@ func (c *Config) GetArgs(bundle types.ModelBundle, socket string, mode inference
 	// Start with the arguments from LlamaCppConfig
 	args := append([]string{}, c.Args...)
 
+	// Add thread and NUMA settings, unless they're set by the arguments
+	if c.Threads > 0 && !containsAnyArg(c.Args, threadsFlags) {
+		args = append(args, "--threads", strconv.Itoa(c.Threads))
+	}
+	if c.BatchThreads > 0 && !containsAnyArg(c.Args, batchThreadsFlags) {
+		args = append(args, "--threads-batch", strconv.Itoa(c.BatchThreads))
+	}
+	if c.NUMA != "" && !containsArg(c.Args, "--numa") {
+		args = append(args, "--numa", string(c.NUMA))
+	}
+
 	modelPath := bundle.GGUFPath()
 	if modelPath == "" {
 		return nil, fmt.Errorf("GGUF file required by llama.cpp backend")
@@ -61,6 +78,11 @@ func (c *Config) GetArgs(bundle types.ModelBundle, socket string, mode inference
 		}
 	case inference.BackendModeEmbedding:
 		args = append(args, "--embeddings")
+		// Add the pooling method from backend config, unless it's set by the
+		// runtime flags
+		if config != nil && config.Embeddings != nil && config.Embeddings.Pooling != "" && !containsArg(config.RuntimeFlags, "--pooling") {
+			args = append(args, "--pooling", string(config.Embeddings.Pooling))
+		}
 	case inference.BackendModeReranking:
 		args = append(args, "--embeddings", "--reranking")
 	default:
@@ -70,6 +92,20 @@ func (c *Config) GetArgs(bundle types.ModelBundle, socket string, mode inference
 	// Add context size from model config or backend config
 	args = append(args, "--ctx-size", strconv.FormatUint(GetContextSize(bundle.RuntimeConfig(), config), 10))
 
+	// Add the KV cache type from backend config or defaults
+	if cacheType := c.kvCacheType(config); cacheType != "" {
+		args = append(args, "--cache-type-k", string(cacheType), "--cache-type-v", string(cacheType))
+	}
+
+	// Add RoPE scaling from model config or backend config, unless it's set
+	// by the runtime flags
+	if rope := GetRopeScaling(bundle.RuntimeConfig(), config); rope != nil && (config == nil || !containsArg(config.RuntimeFlags, "--rope-scaling")) {
+		args = append(args, "--rope-scaling", string(rope.Type), "--rope-scale", strconv.FormatFloat(rope.Factor, 'f', -1, 64))
+		if rope.Type == inference.RopeScalingYaRN && rope.OriginalContextSize > 0 {
+			args = append(args, "--yarn-orig-ctx", strconv.FormatUint(rope.OriginalContextSize, 10))
+		}
+	}
+
 	// Add arguments from backend config
 	if config != nil {
 		args = append(args, config.RuntimeFlags...)
@@ -98,6 +134,45 @@ func GetContextSize(modelCfg types.Config, backendCfg *inference.BackendConfigur
 	return 4096 // llama.cpp default
 }
 
+// GetRopeScaling returns the RoPE scaling configuration for a runner, or nil
+// if RoPE scaling isn't configured. As with the context size, the model config
+// takes precedence.
+func GetRopeScaling(modelCfg types.Config, backendCfg *inference.BackendConfiguration) *inference.RopeScalingConfig {
+	if modelCfg.RopeScaling != nil {
+		rope := &inference.RopeScalingConfig{
+			Type:                inference.RopeScalingType(modelCfg.RopeScaling.Type),
+			Factor:              modelCfg.RopeScaling.Factor,
+			OriginalContextSize: modelCfg.RopeScaling.OriginalContextSize,
+		}
+		if rope.Validate() == nil {
+			return rope
+		}
+	}
+	if backendCfg != nil {
+		return backendCfg.RopeScaling
+	}
+	return nil
+}
+
+// kvCacheType returns the KV cache type for a runner, which may be empty if
+// llama.cpp's default should be used.
+func (c *Config) kvCacheType(config *inference.BackendConfiguration) inference.KVCacheType {
+	if config != nil && config.KVCacheType != "" {
+		return config.KVCacheType
+	}
+	return c.KVCacheType
+}
+
+// containsAnyArg checks if any of the given arguments is in the args slice.
+func containsAnyArg(args []string, candidates []string) bool {
+	for _, arg := range candidates {
+		if containsArg(args, arg) {
+			return true
+		}
+	}
+	return false
+}
+
 // containsArg checks if the given argument is already in the args slice.
 func containsArg(args []string, arg string) bool {
 	for _, a := range args {
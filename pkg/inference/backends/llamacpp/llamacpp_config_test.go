@@ -49,30 +49,10 @@ func TestNewDefaultLlamaCppConfig(t *testing.T) {
 
 	// Test Windows ARM64 specific case
 	if runtime.GOOS == "windows" && runtime.GOARCH == "arm64" {
-		if !containsArg(config.Args, "--threads") {
-			t.Error("Expected --threads argument to be present on Windows ARM64")
+		if config.Threads > max(2, runtime.NumCPU()/2) {
+			t.Errorf("Thread count %d exceeds maximum allowed value of %d", config.Threads, runtime.NumCPU()/2)
 		}
-		threadsIndex := -1
-		for i, arg := range config.Args {
-			if arg == "--threads" {
-				threadsIndex = i
-				break
-			}
-		}
-		if threadsIndex == -1 {
-			t.Error("Could not find --threads argument")
-		}
-		if threadsIndex+1 >= len(config.Args) {
-			t.Error("No value found after --threads argument")
-		}
-		threads, err := strconv.Atoi(config.Args[threadsIndex+1])
-		if err != nil {
-			t.Errorf("Failed to parse thread count: %v", err)
-		}
-		if threads > runtime.NumCPU()/2 {
-			t.Errorf("Thread count %d exceeds maximum allowed value of %d", threads, runtime.NumCPU()/2)
-		}
-		if threads < 1 {
+		if config.Threads < 1 {
 			t.Error("Thread count is less than 1")
 		}
 	}
@@ -88,9 +68,11 @@ func TestGetArgs(t *testing.T) {
 	if runtime.GOOS == "darwin" {
 		baseArgs = append(baseArgs, "--no-mmap")
 	}
-	if runtime.GOARCH == "arm64" {
-		nThreads := max(2, runtime.NumCPU()/2)
-		baseArgs = append(baseArgs, "--threads", strconv.Itoa(nThreads))
+	if config.Threads > 0 {
+		baseArgs = append(baseArgs, "--threads", strconv.Itoa(config.Threads))
+	}
+	if config.NUMA != "" {
+		baseArgs = append(baseArgs, "--numa", string(config.NUMA))
 	}
 
 	tests := []struct {
@@ -197,6 +179,111 @@ func TestGetArgs(t *testing.T) {
 				"--jinja",
 			),
 		},
+		{
+			name: "pooling from backend config",
+			mode: inference.BackendModeEmbedding,
+			bundle: &fakeBundle{
+				ggufPath: modelPath,
+			},
+			config: &inference.BackendConfiguration{
+				Embeddings: &inference.EmbeddingConfig{Pooling: inference.EmbeddingPoolingCLS},
+			},
+			expected: append(slices.Clone(baseArgs),
+				"--model", modelPath,
+				"--host", socket,
+				"--embeddings",
+				"--pooling", "cls",
+				"--ctx-size", "4096",
+				"--jinja",
+			),
+		},
+		{
+			name: "KV cache type from backend config",
+			mode: inference.BackendModeCompletion,
+			bundle: &fakeBundle{
+				ggufPath: modelPath,
+			},
+			config: &inference.BackendConfiguration{
+				KVCacheType: inference.KVCacheTypeQ8_0,
+			},
+			expected: append(slices.Clone(baseArgs),
+				"--model", modelPath,
+				"--host", socket,
+				"--ctx-size", "4096",
+				"--cache-type-k", "q8_0",
+				"--cache-type-v", "q8_0",
+				"--jinja",
+			),
+		},
+		{
+			name: "RoPE scaling from backend config",
+			mode: inference.BackendModeCompletion,
+			bundle: &fakeBundle{
+				ggufPath: modelPath,
+			},
+			config: &inference.BackendConfiguration{
+				ContextSize: 32768,
+				RopeScaling: &inference.RopeScalingConfig{
+					Type:                inference.RopeScalingYaRN,
+					Factor:              4,
+					OriginalContextSize: 8192,
+				},
+			},
+			expected: append(slices.Clone(baseArgs),
+				"--model", modelPath,
+				"--host", socket,
+				"--ctx-size", "32768",
+				"--rope-scaling", "yarn",
+				"--rope-scale", "4",
+				"--yarn-orig-ctx", "8192",
+				"--jinja",
+			),
+		},
+		{
+			name: "RoPE scaling from model config",
+			mode: inference.BackendModeCompletion,
+			bundle: &fakeBundle{
+				ggufPath: modelPath,
+				config: types.Config{
+					RopeScaling: &types.RopeScaling{Type: "linear", Factor: 2.5},
+				},
+			},
+			config: &inference.BackendConfiguration{
+				RopeScaling: &inference.RopeScalingConfig{
+					Type:   inference.RopeScalingYaRN,
+					Factor: 4,
+				},
+			},
+			expected: append(slices.Clone(baseArgs),
+				"--model", modelPath,
+				"--host", socket,
+				"--ctx-size", "4096",
+				"--rope-scaling", "linear", // model config takes precedence
+				"--rope-scale", "2.5",
+				"--jinja",
+			),
+		},
+		{
+			name: "RoPE scaling from runtime flags",
+			mode: inference.BackendModeCompletion,
+			bundle: &fakeBundle{
+				ggufPath: modelPath,
+			},
+			config: &inference.BackendConfiguration{
+				RuntimeFlags: []string{"--rope-scaling", "none"},
+				RopeScaling: &inference.RopeScalingConfig{
+					Type:   inference.RopeScalingLinear,
+					Factor: 2,
+				},
+			},
+			expected: append(slices.Clone(baseArgs),
+				"--model", modelPath,
+				"--host", socket,
+				"--ctx-size", "4096",
+				"--rope-scaling", "none",
+				"--jinja",
+			),
+		},
 		{
 			name: "multimodal projector removes jinja",
 			mode: inference.BackendModeCompletion,
@@ -313,10 +400,30 @@ func (f *fakeBundle) MMPROJPath() string {
 	return f.mmprojPath
 }
 
+func (f *fakeBundle) AdapterPath() string {
+	return ""
+}
+
 func (f *fakeBundle) SafetensorsPath() string {
 	return ""
 }
 
+func (f *fakeBundle) ONNXPath() string {
+	return ""
+}
+
+func (f *fakeBundle) WhisperPath() string {
+	return ""
+}
+
+func (f *fakeBundle) DiffusionPath() string {
+	return ""
+}
+
+func (f *fakeBundle) TensorRTPath() string {
+	return ""
+}
+
 func (f *fakeBundle) RuntimeConfig() types.Config {
 	return f.config
 }
@@ -324,3 +431,17 @@ func (f *fakeBundle) RuntimeConfig() types.Config {
 func uint64ptr(n uint64) *uint64 {
 	return &n
 }
+
+func TestKVCacheType(t *testing.T) {
+	config := &Config{KVCacheType: inference.KVCacheTypeQ4_0}
+	if got := config.kvCacheType(nil); got != inference.KVCacheTypeQ4_0 {
+		t.Errorf("expected default KV cache type q4_0, got %q", got)
+	}
+	override := &inference.BackendConfiguration{KVCacheType: inference.KVCacheTypeF16}
+	if got := config.kvCacheType(override); got != inference.KVCacheTypeF16 {
+		t.Errorf("expected overridden KV cache type f16, got %q", got)
+	}
+	if got := (&Config{}).kvCacheType(&inference.BackendConfiguration{}); got != "" {
+		t.Errorf("expected no KV cache type, got %q", got)
+	}
+}
@@ -0,0 +1,71 @@
+package llamacpp
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// parallelFlags are the llama.cpp flags that set the number of slots, which
+// serve requests in parallel, each with its own prompt cache.
+var parallelFlags = map[string]bool{
+	"-np":        true,
+	"--parallel": true,
+}
+
+// parallelSlots returns the number of slots set by the last flag in args
+// that sets it, or 0 if it isn't set or is invalid.
+func parallelSlots(args []string) int {
+	slots := 0
+	for i := 0; i < len(args)-1; i++ {
+		if parallelFlags[args[i]] {
+			slots, _ = strconv.Atoi(args[i+1])
+		}
+	}
+	return max(slots, 0)
+}
+
+// slots returns the number of slots of the runner, if it's set explicitly in
+// the backend or runner configuration.
+func (l *llamaCpp) slots(config *inference.BackendConfiguration) int {
+	var args []string
+	if c, ok := l.config.(*Config); ok {
+		args = append(args, c.Args...)
+	}
+	if config != nil {
+		args = append(args, config.RuntimeFlags...)
+	}
+	return parallelSlots(args)
+}
+
+// translatePromptCache pins the prompt prefix identified by a prompt cache
+// key in llama-server's prompt cache: the request's prompt is cached, and if
+// the number of slots is known, the request is assigned to the slot of its
+// key, so that requests sharing the prefix find it in their slot's cache
+// rather than evicting each other's. Requests that already choose a slot,
+// and requests without a key, are left unmodified.
+func translatePromptCache(body []byte, key string, slots int) ([]byte, error) {
+	if key == "" {
+		return body, nil
+	}
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return body, nil
+	}
+	if _, ok := request["cache_prompt"]; !ok {
+		request["cache_prompt"] = json.RawMessage("true")
+	}
+	if _, ok := request["id_slot"]; !ok && slots > 1 {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		request["id_slot"] = json.RawMessage(strconv.FormatUint(h.Sum64()%uint64(slots), 10))
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("encoding request: %w", err)
+	}
+	return body, nil
+}
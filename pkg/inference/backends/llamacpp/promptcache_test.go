@@ -0,0 +1,58 @@
+package llamacpp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParallelSlots(t *testing.T) {
+	for _, tt := range []struct {
+		args  []string
+		slots int
+	}{
+		{args: nil},
+		{args: []string{"-np", "4"}, slots: 4},
+		{args: []string{"-np", "4", "--parallel", "2"}, slots: 2},
+		{args: []string{"--parallel", "many"}},
+		{args: []string{"--parallel"}},
+	} {
+		if slots := parallelSlots(tt.args); slots != tt.slots {
+			t.Errorf("Expected %d slots for %v, got %d", tt.slots, tt.args, slots)
+		}
+	}
+}
+
+func TestTranslatePromptCache(t *testing.T) {
+	body := `{"model":"m","prompt":"Hi"}`
+	if translated, err := translatePromptCache([]byte(body), "", 4); err != nil || string(translated) != body {
+		t.Errorf("Expected requests without a key to be unmodified, got %s, %v", translated, err)
+	}
+
+	slot := func(key string, slots int) (json.RawMessage, json.RawMessage) {
+		translated, err := translatePromptCache([]byte(body), key, slots)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var request map[string]json.RawMessage
+		if err := json.Unmarshal(translated, &request); err != nil {
+			t.Fatal(err)
+		}
+		return request["cache_prompt"], request["id_slot"]
+	}
+	if cache, id := slot("agent", 1); string(cache) != "true" || id != nil {
+		t.Errorf("Expected the prompt to be cached without a slot, got %s, %s", cache, id)
+	}
+	cache, id := slot("agent", 4)
+	if id == nil || string(cache) != "true" {
+		t.Fatalf("Expected the request to be assigned a slot, got %s", id)
+	}
+	for range 3 {
+		if _, again := slot("agent", 4); string(again) != string(id) {
+			t.Errorf("Expected the key to keep its slot, got %s and %s", id, again)
+		}
+	}
+
+	if translated, _ := translatePromptCache([]byte(`{"id_slot":3}`), "agent", 4); string(translated) != `{"cache_prompt":true,"id_slot":3}` {
+		t.Errorf("Expected the requested slot to be kept, got %s", translated)
+	}
+}
@@ -0,0 +1,137 @@
+package llamacpp
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// NUMAStrategy is a llama.cpp NUMA optimization strategy.
+type NUMAStrategy string
+
+const (
+	// NUMADistribute spreads execution evenly over all NUMA nodes.
+	NUMADistribute NUMAStrategy = "distribute"
+	// NUMAIsolate only spawns threads on the CPUs of the node that execution
+	// started on.
+	NUMAIsolate NUMAStrategy = "isolate"
+	// NUMANumactl uses the CPU map provided by numactl.
+	NUMANumactl NUMAStrategy = "numactl"
+)
+
+// ParseNUMAStrategy parses a NUMA strategy. An empty string is returned as-is
+// and disables NUMA optimizations.
+func ParseNUMAStrategy(s string) (NUMAStrategy, error) {
+	switch strategy := NUMAStrategy(strings.ToLower(strings.TrimSpace(s))); strategy {
+	case "", NUMADistribute, NUMAIsolate, NUMANumactl:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("invalid NUMA strategy %q (expected distribute, isolate, or numactl)", s)
+	}
+}
+
+// threadsFlags are the llama.cpp flags that set the number of threads.
+var threadsFlags = []string{"-t", "--threads"}
+
+// batchThreadsFlags are the llama.cpp flags that set the number of threads
+// used for batch and prompt processing.
+var batchThreadsFlags = []string{"-tb", "--threads-batch"}
+
+// cgroupRoot is the mount point of the cgroup filesystem. It can be overridden
+// for testing.
+var cgroupRoot = "/sys/fs/cgroup"
+
+// numaNodesPath is the sysfs directory listing NUMA nodes. It can be
+// overridden for testing.
+var numaNodesPath = "/sys/devices/system/node"
+
+// availableCPUs returns the number of CPUs that the process can use, taking
+// both its CPU affinity and any cgroup CPU quota into account. It also returns
+// whether the count is limited by a cgroup quota.
+func availableCPUs() (int, bool) {
+	cpus := runtime.NumCPU()
+	if limit, ok := cgroupCPULimit(); ok {
+		if quota := max(1, int(math.Ceil(limit))); quota < cpus {
+			return quota, true
+		}
+	}
+	return cpus, false
+}
+
+// cgroupCPULimit returns the CPU quota of the process's cgroup, in CPUs.
+func cgroupCPULimit() (float64, bool) {
+	// cgroup v2
+	if data, err := os.ReadFile(filepath.Join(cgroupRoot, "cpu.max")); err == nil {
+		return parseCPUMax(string(data))
+	}
+	// cgroup v1
+	quota, err := os.ReadFile(filepath.Join(cgroupRoot, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0, false
+	}
+	period, err := os.ReadFile(filepath.Join(cgroupRoot, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, false
+	}
+	return parseCPUQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// parseCPUMax parses the content of a cgroup v2 cpu.max file.
+func parseCPUMax(content string) (float64, bool) {
+	fields := strings.Fields(content)
+	if len(fields) != 2 {
+		return 0, false
+	}
+	return parseCPUQuota(fields[0], fields[1])
+}
+
+// parseCPUQuota computes a CPU limit from a quota and period in microseconds.
+// A quota of "max" or -1 means that there's no limit.
+func parseCPUQuota(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q / p, true
+}
+
+// numaNodes returns the number of NUMA nodes, or 0 if it's unknown.
+func numaNodes() int {
+	nodes, err := filepath.Glob(filepath.Join(numaNodesPath, "node[0-9]*"))
+	if err != nil {
+		return 0
+	}
+	return len(nodes)
+}
+
+// defaultThreads returns the default number of threads and NUMA strategy.
+// A thread count of 0 leaves the choice to llama.cpp, which sizes its thread
+// pool by the host's CPUs and therefore oversubscribes CPU-limited containers.
+func defaultThreads() (int, NUMAStrategy) {
+	cpus, limited := availableCPUs()
+
+	var threads int
+	if runtime.GOARCH == "arm64" {
+		// Using a thread count equal to core count results in bad performance, and there seems to be little to no gain
+		// in going beyond core_count/2.
+		threads = max(2, cpus/2)
+	} else if limited {
+		threads = cpus
+	}
+
+	// Spread work over all nodes of multi-socket hosts, rather than letting
+	// threads and memory migrate between them.
+	var numa NUMAStrategy
+	if numaNodes() > 1 {
+		numa = NUMADistribute
+	}
+	return threads, numa
+}
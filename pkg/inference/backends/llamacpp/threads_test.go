@@ -0,0 +1,87 @@
+package llamacpp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCPUMax(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected float64
+		ok       bool
+	}{
+		{content: "max 100000\n", ok: false},
+		{content: "200000 100000\n", expected: 2, ok: true},
+		{content: "150000 100000", expected: 1.5, ok: true},
+		{content: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		limit, ok := parseCPUMax(tt.content)
+		if ok != tt.ok || limit != tt.expected {
+			t.Errorf("parseCPUMax(%q) = %v, %v, expected %v, %v", tt.content, limit, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestCgroupCPULimit(t *testing.T) {
+	root := t.TempDir()
+	original := cgroupRoot
+	cgroupRoot = root
+	defer func() { cgroupRoot = original }()
+
+	if _, ok := cgroupCPULimit(); ok {
+		t.Error("expected no limit without cgroup files")
+	}
+
+	// cgroup v1
+	if err := os.Mkdir(filepath.Join(root, "cpu"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"), []byte("-1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"), []byte("100000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cgroupCPULimit(); ok {
+		t.Error("expected no limit for unlimited cgroup v1 quota")
+	}
+
+	// cgroup v2 takes precedence
+	if err := os.WriteFile(filepath.Join(root, "cpu.max"), []byte("400000 100000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if limit, ok := cgroupCPULimit(); !ok || limit != 4 {
+		t.Errorf("expected limit of 4 CPUs, got %v, %v", limit, ok)
+	}
+}
+
+func TestNumaNodes(t *testing.T) {
+	root := t.TempDir()
+	original := numaNodesPath
+	numaNodesPath = root
+	defer func() { numaNodesPath = original }()
+
+	for _, name := range []string{"node0", "node1", "possible"} {
+		if err := os.Mkdir(filepath.Join(root, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if nodes := numaNodes(); nodes != 2 {
+		t.Errorf("expected 2 NUMA nodes, got %d", nodes)
+	}
+}
+
+func TestParseNUMAStrategy(t *testing.T) {
+	for _, input := range []string{"", "distribute", " Isolate ", "numactl"} {
+		if _, err := ParseNUMAStrategy(input); err != nil {
+			t.Errorf("unexpected error for %q: %v", input, err)
+		}
+	}
+	if _, err := ParseNUMAStrategy("mirror"); err == nil {
+		t.Error("expected error for invalid strategy")
+	}
+}
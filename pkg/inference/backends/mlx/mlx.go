@@ -124,6 +124,7 @@ func (m *mlx) Run(ctx context.Context, socket, model string, modelRef string, mo
 		SandboxPath:     "",
 		SandboxConfig:   "",
 		Args:            args,
+		Devices:         backendConfig.AssignedDevices(),
 		Logger:          m.log,
 		ServerLogWriter: m.serverLog.Writer(),
 	})
@@ -139,11 +140,33 @@ func (m *mlx) GetDiskUsage() (int64, error) {
 	return 0, nil
 }
 
-func (m *mlx) GetRequiredMemoryForModel(ctx context.Context, model string, config *inference.BackendConfiguration) (inference.RequiredMemory, error) {
-	// TODO: Implement accurate memory estimation based on model size.
-	// MLX runs on unified memory architecture (Apple Silicon), so memory estimation
-	// will need to account for the unified nature of RAM and VRAM on Apple Silicon.
-	// Returning an error prevents the scheduler from making incorrect decisions based
-	// on placeholder values.
-	return inference.RequiredMemory{}, errors.New("not implemented")
+func (m *mlx) GetRequiredMemoryForModel(_ context.Context, model string, _ *inference.BackendConfiguration) (inference.RequiredMemory, error) {
+	if !platform.SupportsMLX() {
+		return inference.RequiredMemory{}, errors.New("not implemented")
+	}
+
+	// Apple Silicon GPUs use unified memory, which the scheduler sees as VRAM
+	// limited to Metal's recommended working set. MLX allocates the weights
+	// and KV cache there, so they're accounted for as VRAM only, as they would
+	// otherwise be counted twice against the same physical memory.
+	vram := uint64(1)
+	if weights, err := m.weightsSize(model); err != nil {
+		m.log.Warnf("Could not estimate memory for model %s: %v", model, err)
+	} else {
+		vram = backends.EstimateFromWeights(weights, backends.WeightsOverheadFactor)
+	}
+
+	return inference.RequiredMemory{
+		RAM:  1,
+		VRAM: vram,
+	}, nil
+}
+
+// weightsSize returns the total size of a model's weights.
+func (m *mlx) weightsSize(model string) (int64, error) {
+	mdl, err := m.modelManager.GetLocal(model)
+	if err != nil {
+		return 0, err
+	}
+	return backends.WeightsSize(mdl)
 }
@@ -20,6 +20,22 @@ func (m *mockModelBundle) SafetensorsPath() string {
 	return m.safetensorsPath
 }
 
+func (m *mockModelBundle) ONNXPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) WhisperPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) DiffusionPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) TensorRTPath() string {
+	return ""
+}
+
 func (m *mockModelBundle) ChatTemplatePath() string {
 	return ""
 }
@@ -28,6 +44,10 @@ func (m *mockModelBundle) MMPROJPath() string {
 	return ""
 }
 
+func (m *mockModelBundle) AdapterPath() string {
+	return ""
+}
+
 func (m *mockModelBundle) RuntimeConfig() types.Config {
 	return m.runtimeConfig
 }
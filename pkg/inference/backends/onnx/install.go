@@ -0,0 +1,160 @@
+package onnx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/inference/platform"
+	"github.com/docker/model-runner/pkg/internal/dockerhub"
+)
+
+const (
+	// DefaultVersion is the ONNX Runtime GenAI version of the server
+	// downloaded into managed installations.
+	DefaultVersion = "0.9.0"
+	// hubNamespace and hubRepo identify the images on Docker Hub that
+	// distribute the server, tagged with the version and variant.
+	hubNamespace = "docker"
+	hubRepo      = "docker-model-backend-onnx"
+	// serverBinary is the name of the server binary, a native
+	// OpenAI-compatible server built on the ONNX Runtime GenAI C API.
+	serverBinary = "com.docker.onnx-server"
+	// tagFile is the name of the file recording the image tag that a managed
+	// installation was downloaded from. It also marks the installation as
+	// created by the model runner.
+	tagFile = ".onnx_tag"
+	// variantFile is the name of the file recording the variant of an
+	// installation.
+	variantFile = ".onnx_variant"
+)
+
+// variant identifies the execution provider that a server build targets.
+type variant string
+
+const (
+	variantCPU  variant = "cpu"
+	variantCUDA variant = "cuda"
+)
+
+// variantFor returns the server variant for the vendors of the host's GPUs.
+func variantFor(vendors []platform.GPUVendor) variant {
+	if slices.Contains(vendors, platform.GPUVendorNVIDIA) {
+		return variantCUDA
+	}
+	return variantCPU
+}
+
+// installManaged downloads the server for the configured version into the
+// managed installation directory. An installation from the same image tag is
+// reused.
+func (o *onnx) installManaged(ctx context.Context) error {
+	dir := o.config.ManagedInstallDir
+	v := variantFor(platform.GPUVendors())
+	tag := o.config.version() + "-" + string(v)
+	if installed, err := backends.ReadEnvFile(dir, tagFile); err == nil && installed == tag {
+		if _, err := os.Stat(serverPath(dir)); err == nil {
+			return nil
+		}
+	}
+
+	o.log.Infof("Installing ONNX Runtime GenAI server %s into %s", tag, dir)
+	o.status = fmt.Sprintf("downloading %s variant of the ONNX Runtime GenAI server", tag)
+
+	// Download next to the installation directory, so that the server can be
+	// moved into place.
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return fmt.Errorf("could not create directory for the ONNX server: %w", err)
+	}
+	downloadDir, err := os.MkdirTemp(filepath.Dir(dir), ".onnx-install")
+	if err != nil {
+		return fmt.Errorf("could not create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(downloadDir)
+
+	image := fmt.Sprintf("registry-1.docker.io/%s/%s:%s", hubNamespace, hubRepo, tag)
+	imageTar := filepath.Join(downloadDir, "save.tar")
+	if err := dockerhub.PullPlatform(ctx, image, imageTar, runtime.GOOS, runtime.GOARCH); err != nil {
+		return fmt.Errorf("could not pull %s: %w", image, err)
+	}
+	extractDir := filepath.Join(downloadDir, "image")
+	if err := dockerhub.Extract(imageTar, runtime.GOARCH, runtime.GOOS, extractDir); err != nil {
+		return fmt.Errorf("could not extract %s: %w", image, err)
+	}
+
+	rootDir := filepath.Join(extractDir, fmt.Sprintf("com.docker.onnx-server.native.%s.%s.%s", runtime.GOOS, v, runtime.GOARCH))
+	if err := installServer(rootDir, dir, tag, v); err != nil {
+		return err
+	}
+	o.log.Infof("Installed ONNX Runtime GenAI server %s", tag)
+	return nil
+}
+
+// installServer moves the bin and lib directories of a server build unpacked
+// in rootDir into the managed installation directory, replacing any previous
+// installation, and records the tag and variant it was installed from.
+func installServer(rootDir, dir, tag string, v variant) error {
+	if _, err := os.Stat(serverPath(rootDir)); err != nil {
+		return fmt.Errorf("downloaded image has no ONNX server binary: %w", err)
+	}
+	if err := os.Chmod(serverPath(rootDir), 0o755); err != nil {
+		return fmt.Errorf("could not chmod ONNX server binary: %w", err)
+	}
+	for name, content := range map[string]string{tagFile: tag, variantFile: string(v)} {
+		if err := os.WriteFile(filepath.Join(rootDir, name), []byte(content+"\n"), 0o644); err != nil {
+			return fmt.Errorf("failed to record ONNX server installation: %w", err)
+		}
+	}
+	if err := removeManaged(dir); err != nil {
+		return fmt.Errorf("failed to remove existing ONNX server: %w", err)
+	}
+	if err := os.Rename(rootDir, dir); err != nil {
+		return fmt.Errorf("could not move ONNX server into place: %w", err)
+	}
+	return nil
+}
+
+// removeManaged removes a managed installation. A missing or empty directory
+// is removed without error, but a non-empty directory that wasn't installed by
+// the model runner is left untouched.
+func removeManaged(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		if _, err := os.Stat(filepath.Join(dir, tagFile)); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s", backends.ErrNotManaged, dir)
+		} else if err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(dir)
+}
+
+// serverPath returns the path to the server binary of an installation.
+func serverPath(dir string) string {
+	return filepath.Join(dir, "bin", serverBinary)
+}
+
+// Uninstall implements inference.Uninstaller.Uninstall. Only managed
+// installations can be uninstalled.
+func (o *onnx) Uninstall(_ context.Context) error {
+	if o.config.ManagedInstallDir == "" || o.installDir != o.config.ManagedInstallDir {
+		return backends.ErrNotManaged
+	}
+	if err := removeManaged(o.config.ManagedInstallDir); err != nil {
+		return fmt.Errorf("failed to remove ONNX server: %w", err)
+	}
+	o.log.Infof("Uninstalled ONNX Runtime GenAI server from %s", o.config.ManagedInstallDir)
+	o.status = "not installed"
+	return nil
+}
@@ -0,0 +1,203 @@
+package onnx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/diskusage"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/inference/platform"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+const (
+	// Name is the backend name.
+	Name = "onnx"
+	// defaultInstallDir is the ONNX Runtime GenAI server installation provided
+	// by the model runner image.
+	defaultInstallDir = "/opt/onnx-server"
+)
+
+var ErrorNotFound = inference.NotInstalledError("ONNX Runtime GenAI server not found")
+
+// onnx is the ONNX Runtime-based backend implementation.
+type onnx struct {
+	// log is the associated logger.
+	log logging.Logger
+	// modelManager is the shared model manager.
+	modelManager *models.Manager
+	// serverLog is the logger to use for the ONNX server process.
+	serverLog logging.Logger
+	// config is the configuration for the ONNX backend.
+	config *Config
+	// status is the state in which the ONNX backend is in.
+	status string
+	// installDir is the root of the server installation in use.
+	installDir string
+	// variant is the variant of the server installation in use.
+	variant variant
+}
+
+// New creates a new ONNX Runtime-based backend.
+func New(log logging.Logger, modelManager *models.Manager, serverLog logging.Logger, conf *Config) (inference.Backend, error) {
+	// If no config is provided, use the default configuration
+	if conf == nil {
+		conf = NewDefaultONNXConfig()
+	}
+
+	return &onnx{
+		log:          log,
+		modelManager: modelManager,
+		serverLog:    serverLog,
+		config:       conf,
+		status:       "not installed",
+		installDir:   defaultInstallDir,
+		variant:      variantCPU,
+	}, nil
+}
+
+// Name implements inference.Backend.Name.
+func (o *onnx) Name() string {
+	return Name
+}
+
+// UsesExternalModelManagement implements
+// inference.Backend.UsesExternalModelManagement.
+func (o *onnx) UsesExternalModelManagement() bool {
+	return false
+}
+
+// Install implements inference.Backend.Install.
+func (o *onnx) Install(ctx context.Context, _ *http.Client) error {
+	if !platform.SupportsONNX() {
+		return errors.New("not implemented")
+	}
+
+	// Prefer the server provided by the image, falling back to a managed
+	// installation on the host if one has been configured.
+	o.installDir = defaultInstallDir
+	if _, err := os.Stat(serverPath(o.installDir)); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to check ONNX Runtime GenAI server: %w", err)
+		}
+		if o.config.ManagedInstallDir == "" {
+			o.status = ErrorNotFound.Error()
+			return ErrorNotFound
+		}
+		o.installDir = o.config.ManagedInstallDir
+		if err := o.installManaged(ctx); err != nil {
+			o.status = fmt.Sprintf("installation failed: %v", err)
+			return err
+		}
+	}
+
+	o.variant = variantCPU
+	if v, err := backends.ReadEnvFile(o.installDir, variantFile); err == nil && v != "" {
+		o.variant = variant(v)
+	}
+	tag, err := backends.ReadEnvFile(o.installDir, tagFile)
+	if err != nil {
+		o.log.Warnf("could not get ONNX Runtime GenAI server version: %v", err)
+		tag = "unknown"
+	}
+	o.status = fmt.Sprintf("running ONNX Runtime GenAI server version: %s (variant: %s)", tag, o.variant)
+
+	return nil
+}
+
+// Run implements inference.Backend.Run.
+func (o *onnx) Run(ctx context.Context, socket, model string, modelRef string, mode inference.BackendMode, backendConfig *inference.BackendConfiguration) error {
+	bundle, err := o.modelManager.GetBundle(model)
+	if err != nil {
+		return fmt.Errorf("failed to get model: %w", err)
+	}
+
+	args, err := o.config.GetArgs(bundle, socket, mode, backendConfig)
+	if err != nil {
+		return fmt.Errorf("failed to get ONNX arguments: %w", err)
+	}
+	if o.variant == variantCUDA {
+		args = append(args, "--provider", "cuda")
+	}
+	args = append(args, "--served-model-name", model, modelRef)
+
+	return backends.RunBackend(ctx, backends.RunnerConfig{
+		BackendName:     "ONNX",
+		Socket:          socket,
+		BinaryPath:      serverPath(o.installDir),
+		SandboxPath:     filepath.Join(o.installDir, "bin"),
+		SandboxConfig:   "",
+		Args:            args,
+		Env:             []string{"LD_LIBRARY_PATH=" + filepath.Join(o.installDir, "lib")},
+		Devices:         backendConfig.AssignedDevices(),
+		Logger:          o.log,
+		ServerLogWriter: o.serverLog.Writer(),
+	})
+}
+
+func (o *onnx) Status() string {
+	return o.status
+}
+
+func (o *onnx) GetDiskUsage() (int64, error) {
+	size, err := diskusage.Size(o.installDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("error while getting store size: %w", err)
+	}
+	return size, nil
+}
+
+func (o *onnx) GetRequiredMemoryForModel(_ context.Context, model string, _ *inference.BackendConfiguration) (inference.RequiredMemory, error) {
+	if !platform.SupportsONNX() {
+		return inference.RequiredMemory{}, errors.New("not implemented")
+	}
+
+	// Estimate memory from the size of the model weights, falling back to
+	// unknown if they can't be inspected.
+	required := uint64(1)
+	if weights, err := o.weightsSize(model); err != nil {
+		o.log.Warnf("Could not estimate memory for model %s: %v", model, err)
+	} else {
+		required = backends.EstimateFromWeights(weights, backends.WeightsOverheadFactor)
+	}
+
+	if o.variant == variantCUDA {
+		return inference.RequiredMemory{RAM: 1, VRAM: required}, nil
+	}
+	return inference.RequiredMemory{RAM: required, VRAM: 0}, nil
+}
+
+// weightsSize returns the total size of a model's ONNX graph and external
+// data files.
+func (o *onnx) weightsSize(model string) (int64, error) {
+	bundle, err := o.modelManager.GetBundle(model)
+	if err != nil {
+		return 0, err
+	}
+	if bundle.ONNXPath() == "" {
+		return 0, errors.New("model has no ONNX files")
+	}
+	entries, err := os.ReadDir(filepath.Dir(bundle.ONNXPath()))
+	if err != nil {
+		return 0, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".onnx") || strings.HasSuffix(name, ".data")) {
+			continue
+		}
+		paths = append(paths, filepath.Join(filepath.Dir(bundle.ONNXPath()), name))
+	}
+	return backends.FilesSize(paths...)
+}
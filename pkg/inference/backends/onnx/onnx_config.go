@@ -0,0 +1,101 @@
+package onnx
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// Config is the configuration for the ONNX backend.
+type Config struct {
+	// Args are the base arguments that are always included.
+	Args []string
+	// ManagedInstallDir is the directory into which the ONNX Runtime GenAI
+	// server is downloaded if one isn't provided by the image. If empty,
+	// managed installation is disabled.
+	ManagedInstallDir string
+	// Version is the ONNX Runtime GenAI version of the server to download into
+	// the managed installation directory. If empty, DefaultVersion is used.
+	Version string
+}
+
+// NewDefaultONNXConfig creates a new ONNX Config with default values.
+func NewDefaultONNXConfig() *Config {
+	return &Config{
+		Args: []string{},
+	}
+}
+
+// version returns the ONNX Runtime GenAI version of the server to download
+// into the managed installation directory.
+func (c *Config) version() string {
+	if c.Version != "" {
+		return c.Version
+	}
+	return DefaultVersion
+}
+
+// GetArgs implements BackendConfig.GetArgs. The arguments are passed to the
+// server binary.
+func (c *Config) GetArgs(bundle types.ModelBundle, socket string, mode inference.BackendMode, config *inference.BackendConfiguration) ([]string, error) {
+	// Start with the arguments from Config
+	args := append([]string{}, c.Args...)
+
+	modelPath := bundle.ONNXPath()
+	if modelPath == "" {
+		return nil, fmt.Errorf("ONNX model required by ONNX backend")
+	}
+
+	// Add model and socket arguments
+	args = append(args, "--model", modelPath, "--socket", socket)
+
+	// Add mode-specific arguments
+	switch mode {
+	case inference.BackendModeCompletion:
+		args = append(args, "--mode", "completion")
+	case inference.BackendModeEmbedding:
+		args = append(args, "--mode", "embedding")
+		if config != nil && config.Embeddings != nil {
+			if config.Embeddings.Pooling != "" {
+				args = append(args, "--pooling", string(config.Embeddings.Pooling))
+			}
+			if config.Embeddings.Normalize != nil && !*config.Embeddings.Normalize {
+				args = append(args, "--no-normalize")
+			}
+		}
+	case inference.BackendModeReranking:
+		return nil, fmt.Errorf("reranking mode not supported by ONNX backend")
+	default:
+		return nil, fmt.Errorf("unsupported backend mode %q", mode)
+	}
+
+	// Add context size if specified in model config or backend config
+	if contextSize := GetContextSize(bundle.RuntimeConfig(), config); contextSize != nil {
+		args = append(args, "--context-size", strconv.FormatUint(*contextSize, 10))
+	}
+
+	// Add arguments from backend config
+	if config != nil {
+		args = append(args, config.RuntimeFlags...)
+	}
+
+	return args, nil
+}
+
+// GetContextSize returns the context size from model config or backend config.
+// Model config takes precedence over backend config. Returns nil if neither is
+// specified, in which case the model's maximum length is used.
+func GetContextSize(modelCfg types.Config, backendCfg *inference.BackendConfiguration) *uint64 {
+	// Model config takes precedence
+	if modelCfg.ContextSize != nil {
+		return modelCfg.ContextSize
+	}
+	// else use backend config
+	if backendCfg != nil && backendCfg.ContextSize > 0 {
+		val := uint64(backendCfg.ContextSize)
+		return &val
+	}
+	return nil
+}
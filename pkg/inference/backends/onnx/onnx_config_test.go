@@ -0,0 +1,224 @@
+package onnx
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/inference/platform"
+)
+
+type mockModelBundle struct {
+	onnxPath      string
+	runtimeConfig types.Config
+}
+
+func (m *mockModelBundle) GGUFPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) SafetensorsPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) ONNXPath() string {
+	return m.onnxPath
+}
+
+func (m *mockModelBundle) WhisperPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) DiffusionPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) TensorRTPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) ChatTemplatePath() string {
+	return ""
+}
+
+func (m *mockModelBundle) MMPROJPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) AdapterPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) RuntimeConfig() types.Config {
+	return m.runtimeConfig
+}
+
+func (m *mockModelBundle) RootDir() string {
+	return "/path/to/bundle"
+}
+
+func TestGetArgs(t *testing.T) {
+	disabled := false
+	contextSize := uint64(2048)
+
+	tests := []struct {
+		name        string
+		mode        inference.BackendMode
+		config      *inference.BackendConfiguration
+		bundle      *mockModelBundle
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:        "empty ONNX path should error",
+			mode:        inference.BackendModeCompletion,
+			bundle:      &mockModelBundle{},
+			expectError: true,
+		},
+		{
+			name:   "completion mode",
+			mode:   inference.BackendModeCompletion,
+			bundle: &mockModelBundle{onnxPath: "/path/to/model.onnx"},
+			expected: []string{
+				"--model", "/path/to/model.onnx",
+				"--socket", "/tmp/socket",
+				"--mode", "completion",
+			},
+		},
+		{
+			name:   "model context size takes precedence",
+			mode:   inference.BackendModeCompletion,
+			bundle: &mockModelBundle{onnxPath: "/path/to/model.onnx", runtimeConfig: types.Config{ContextSize: &contextSize}},
+			config: &inference.BackendConfiguration{
+				ContextSize:  8192,
+				RuntimeFlags: []string{"--provider", "cuda"},
+			},
+			expected: []string{
+				"--model", "/path/to/model.onnx",
+				"--socket", "/tmp/socket",
+				"--mode", "completion",
+				"--context-size", "2048",
+				"--provider", "cuda",
+			},
+		},
+		{
+			name:   "embedding mode with options",
+			mode:   inference.BackendModeEmbedding,
+			bundle: &mockModelBundle{onnxPath: "/path/to/model.onnx"},
+			config: &inference.BackendConfiguration{
+				Embeddings: &inference.EmbeddingConfig{
+					Pooling:   inference.EmbeddingPoolingCLS,
+					Normalize: &disabled,
+				},
+			},
+			expected: []string{
+				"--model", "/path/to/model.onnx",
+				"--socket", "/tmp/socket",
+				"--mode", "embedding",
+				"--pooling", "cls",
+				"--no-normalize",
+			},
+		},
+		{
+			name:        "reranking mode should error",
+			mode:        inference.BackendModeReranking,
+			bundle:      &mockModelBundle{onnxPath: "/path/to/model.onnx"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := NewDefaultONNXConfig().GetArgs(tt.bundle, "/tmp/socket", tt.mode, tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected error, got args %v", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(args, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, args)
+			}
+		})
+	}
+}
+
+func TestVariantFor(t *testing.T) {
+	for _, tt := range []struct {
+		vendors  []platform.GPUVendor
+		expected variant
+	}{
+		{expected: variantCPU},
+		{vendors: []platform.GPUVendor{platform.GPUVendorAMD, platform.GPUVendorIntel}, expected: variantCPU},
+		{vendors: []platform.GPUVendor{platform.GPUVendorIntel, platform.GPUVendorNVIDIA}, expected: variantCUDA},
+	} {
+		if v := variantFor(tt.vendors); v != tt.expected {
+			t.Errorf("expected variant %s for %v, got %s", tt.expected, tt.vendors, v)
+		}
+	}
+}
+
+func TestInstallServer(t *testing.T) {
+	root := t.TempDir()
+	build := func() string {
+		dir := filepath.Join(root, "com.docker.onnx-server.native.linux.cuda.amd64")
+		for _, path := range []string{serverPath(dir), filepath.Join(dir, "lib", "libonnxruntime-genai.so")} {
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	dir := filepath.Join(root, "onnx")
+
+	// An existing installation is replaced.
+	for range 2 {
+		if err := installServer(build(), dir, "0.9.0-cuda", variantCUDA); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if info, err := os.Stat(serverPath(dir)); err != nil || info.Mode().Perm()&0o111 == 0 {
+		t.Fatalf("expected an executable server binary, got %v (error %v)", info, err)
+	}
+	for name, expected := range map[string]string{tagFile: "0.9.0-cuda", variantFile: "cuda"} {
+		if content, err := backends.ReadEnvFile(dir, name); err != nil || content != expected {
+			t.Errorf("expected %s to be %q, got %q (error %v)", name, expected, content, err)
+		}
+	}
+	if err := removeManaged(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the installation to be removed, got %v", err)
+	}
+
+	// Directories that weren't installed by the model runner are left
+	// untouched.
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := installServer(build(), dir, "0.9.0-cuda", variantCUDA); !errors.Is(err, backends.ErrNotManaged) {
+		t.Errorf("expected ErrNotManaged, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "data")); err != nil {
+		t.Errorf("expected the directory to be left untouched, got %v", err)
+	}
+	if err := installServer(filepath.Join(root, "missing"), filepath.Join(root, "other"), "0.9.0-cpu", variantCPU); err == nil {
+		t.Error("expected an error for a build without a server binary")
+	}
+}
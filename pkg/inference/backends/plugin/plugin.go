@@ -0,0 +1,277 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// startTimeout is how long a plugin has to start serving the protocol.
+	startTimeout = 10 * time.Second
+	// stopTimeout is how long a plugin has to stop its runs after being
+	// interrupted, after which it's killed.
+	stopTimeout = 10 * time.Second
+)
+
+// validName matches backend names usable as path components.
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// backend is an inference.Backend implemented by a plugin process.
+type backend struct {
+	// name is the backend name reported by the plugin.
+	name string
+	// multipleDevices indicates whether the plugin can split models across
+	// multiple GPUs.
+	multipleDevices bool
+	// conn is the connection to the plugin.
+	conn *grpc.ClientConn
+	// getBundle looks up the files of a model.
+	getBundle func(model string) (types.ModelBundle, error)
+}
+
+// Load starts the plugin binary at path and connects to it. The plugin runs
+// until the context is cancelled.
+func Load(ctx context.Context, log logging.Logger, modelManager *models.Manager, path string) (inference.Backend, error) {
+	// Serve the protocol in a private directory, since Unix domain socket
+	// paths are length-limited.
+	dir, err := os.MkdirTemp("", "model-runner-plugin-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plugin socket directory: %w", err)
+	}
+	socket := filepath.Join(dir, "plugin.sock")
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Env = append(os.Environ(),
+		protocolEnv+"="+strconv.Itoa(ProtocolVersion),
+		socketEnv+"="+socket,
+	)
+	cmd.Stdout = log.Writer()
+	cmd.Stderr = log.Writer()
+	// Give plugins a chance to stop their runs.
+	if runtime.GOOS != "windows" {
+		cmd.Cancel = func() error {
+			return cmd.Process.Signal(os.Interrupt)
+		}
+	}
+	cmd.WaitDelay = stopTimeout
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to start plugin: %w", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			log.Warnf("Plugin %s exited: %v", path, err)
+		}
+		os.RemoveAll(dir)
+		close(exited)
+	}()
+
+	b, err := connect(ctx, socket, exited)
+	if err != nil {
+		cmd.Process.Kill()
+		return nil, err
+	}
+	b.getBundle = modelManager.GetBundle
+	return b, nil
+}
+
+// connect connects to a plugin serving the protocol on socket and checks that
+// it's compatible. Connecting fails early if exited is closed.
+func connect(ctx context.Context, socket string, exited <-chan struct{}) (*backend, error) {
+	conn, err := grpc.NewClient("unix://"+socket,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(codecName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plugin client: %w", err)
+	}
+
+	infoCtx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-exited:
+			cancel()
+		case <-infoCtx.Done():
+		}
+	}()
+	var info infoResponse
+	if err := conn.Invoke(infoCtx, fullMethod("Info"), &infoRequest{ProtocolVersion: ProtocolVersion}, &info, grpc.WaitForReady(true)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to plugin: %w", protocolError(err))
+	}
+	if info.ProtocolVersion != ProtocolVersion {
+		conn.Close()
+		return nil, fmt.Errorf("unsupported plugin protocol version %d (expected %d)", info.ProtocolVersion, ProtocolVersion)
+	}
+	if !validName.MatchString(info.Name) {
+		conn.Close()
+		return nil, fmt.Errorf("invalid plugin backend name %q", info.Name)
+	}
+	return &backend{
+		name:            info.Name,
+		multipleDevices: info.MultipleDevices,
+		conn:            conn,
+	}, nil
+}
+
+// Discover loads every executable in dir as a plugin. Plugins that fail to
+// load are logged and skipped.
+func Discover(ctx context.Context, log logging.Logger, modelManager *models.Manager, dir string) []inference.Backend {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Warnf("Failed to read plugins directory %s: %v", dir, err)
+		return nil
+	}
+	var plugins []inference.Backend
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || (runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		plugin, err := Load(ctx, log, modelManager, path)
+		if err != nil {
+			log.Warnf("Failed to load plugin %s: %v", path, err)
+			continue
+		}
+		log.Infof("Loaded %s backend from plugin %s", plugin.Name(), path)
+		plugins = append(plugins, plugin)
+	}
+	return plugins
+}
+
+// protocolError strips the gRPC status from errors returned by plugins.
+func protocolError(err error) error {
+	if s, ok := status.FromError(err); ok {
+		return errors.New(s.Message())
+	}
+	return err
+}
+
+// invoke calls a protocol method.
+func (b *backend) invoke(ctx context.Context, method string, request, response any) error {
+	if err := b.conn.Invoke(ctx, fullMethod(method), request, response); err != nil {
+		return protocolError(err)
+	}
+	return nil
+}
+
+// model describes a model to the plugin.
+func (b *backend) model(model, modelRef string) (Model, error) {
+	bundle, err := b.getBundle(model)
+	if err != nil {
+		return Model{}, fmt.Errorf("failed to get model: %w", err)
+	}
+	return Model{
+		ID:               model,
+		Ref:              modelRef,
+		RootDir:          bundle.RootDir(),
+		GGUFPath:         bundle.GGUFPath(),
+		SafetensorsPath:  bundle.SafetensorsPath(),
+		ONNXPath:         bundle.ONNXPath(),
+		ChatTemplatePath: bundle.ChatTemplatePath(),
+		MMPROJPath:       bundle.MMPROJPath(),
+	}, nil
+}
+
+// Name implements inference.Backend.Name.
+func (b *backend) Name() string {
+	return b.name
+}
+
+// UsesExternalModelManagement implements
+// inference.Backend.UsesExternalModelManagement.
+func (b *backend) UsesExternalModelManagement() bool {
+	return false
+}
+
+// SupportsMultipleDevices implements
+// inference.MultiDeviceBackend.SupportsMultipleDevices.
+func (b *backend) SupportsMultipleDevices() bool {
+	return b.multipleDevices
+}
+
+// AppliesChatTemplates implements
+// inference.ChatTemplateBackend.AppliesChatTemplates. Plugins are given the
+// path of the chat template of the model's bundle.
+func (b *backend) AppliesChatTemplates() bool {
+	return true
+}
+
+// Install implements inference.Backend.Install. Plugins perform any downloads
+// themselves.
+func (b *backend) Install(ctx context.Context, _ *http.Client) error {
+	return b.invoke(ctx, "Install", &installRequest{}, &installResponse{})
+}
+
+// Run implements inference.Backend.Run.
+func (b *backend) Run(ctx context.Context, socket, model string, modelRef string, mode inference.BackendMode, config *inference.BackendConfiguration) error {
+	m, err := b.model(model, modelRef)
+	if err != nil {
+		return err
+	}
+	request := &runRequest{
+		Socket: socket,
+		Model:  m,
+		Mode:   mode.String(),
+		Config: config,
+	}
+	if config != nil {
+		request.Devices = config.Devices
+	}
+	if err := b.invoke(ctx, "Run", request, &runResponse{}); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// Status implements inference.Backend.Status.
+func (b *backend) Status() string {
+	var response statusResponse
+	if err := b.invoke(context.Background(), "Status", &statusRequest{}, &response); err != nil {
+		return fmt.Sprintf("plugin unavailable: %v", err)
+	}
+	return response.Status
+}
+
+// GetDiskUsage implements inference.Backend.GetDiskUsage.
+func (b *backend) GetDiskUsage() (int64, error) {
+	var response diskUsageResponse
+	if err := b.invoke(context.Background(), "GetDiskUsage", &diskUsageRequest{}, &response); err != nil {
+		return 0, err
+	}
+	return response.Bytes, nil
+}
+
+// GetRequiredMemoryForModel implements
+// inference.Backend.GetRequiredMemoryForModel.
+func (b *backend) GetRequiredMemoryForModel(ctx context.Context, model string, config *inference.BackendConfiguration) (inference.RequiredMemory, error) {
+	m, err := b.model(model, model)
+	if err != nil {
+		return inference.RequiredMemory{}, err
+	}
+	var response memoryResponse
+	if err := b.invoke(ctx, "GetRequiredMemoryForModel", &memoryRequest{Model: m, Config: config}, &response); err != nil {
+		return inference.RequiredMemory{}, err
+	}
+	return inference.RequiredMemory{RAM: response.RAM, VRAM: response.VRAM}, nil
+}
@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// fakeBackend is a plugin backend that records the runs it's asked for.
+type fakeBackend struct {
+	runs chan Model
+	mode inference.BackendMode
+}
+
+func (f *fakeBackend) Name() string                      { return "fake" }
+func (f *fakeBackend) Install(ctx context.Context) error { return nil }
+func (f *fakeBackend) Status() string                    { return "ready" }
+func (f *fakeBackend) GetDiskUsage() (int64, error)      { return 0, errors.New("disk usage unavailable") }
+func (f *fakeBackend) SupportsMultipleDevices() bool     { return true }
+
+func (f *fakeBackend) Run(ctx context.Context, socket string, model Model, mode inference.BackendMode, config *inference.BackendConfiguration) error {
+	f.mode = mode
+	f.runs <- model
+	<-ctx.Done()
+	return nil
+}
+
+func (f *fakeBackend) GetRequiredMemoryForModel(_ context.Context, model Model, config *inference.BackendConfiguration) (inference.RequiredMemory, error) {
+	return inference.RequiredMemory{RAM: uint64(len(model.GGUFPath)), VRAM: uint64(config.ContextSize)}, nil
+}
+
+// fakeBundle is a model bundle with a GGUF file.
+type fakeBundle struct {
+	types.ModelBundle
+}
+
+func (fakeBundle) RootDir() string          { return "/models/fake" }
+func (fakeBundle) GGUFPath() string         { return "/models/fake/model.gguf" }
+func (fakeBundle) SafetensorsPath() string  { return "" }
+func (fakeBundle) ONNXPath() string         { return "" }
+func (fakeBundle) ChatTemplatePath() string { return "" }
+func (fakeBundle) MMPROJPath() string       { return "" }
+
+func TestProtocol(t *testing.T) {
+	dir, err := os.MkdirTemp("", "plugin-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "plugin.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fake := &fakeBackend{runs: make(chan Model, 1)}
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, listener, fake)
+	}()
+
+	b, err := connect(ctx, socket, make(chan struct{}))
+	if err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	b.getBundle = func(string) (types.ModelBundle, error) { return fakeBundle{}, nil }
+
+	if b.Name() != "fake" || !b.SupportsMultipleDevices() {
+		t.Errorf("unexpected backend info: name=%q, multipleDevices=%v", b.Name(), b.SupportsMultipleDevices())
+	}
+	if err := b.Install(ctx, nil); err != nil {
+		t.Errorf("Install failed: %v", err)
+	}
+	if status := b.Status(); status != "ready" {
+		t.Errorf("Status() = %q, want %q", status, "ready")
+	}
+	if _, err := b.GetDiskUsage(); err == nil || err.Error() != "disk usage unavailable" {
+		t.Errorf("GetDiskUsage() error = %v, want plugin error", err)
+	}
+
+	memory, err := b.GetRequiredMemoryForModel(ctx, "sha256:fake", &inference.BackendConfiguration{ContextSize: 4096})
+	if err != nil {
+		t.Fatalf("GetRequiredMemoryForModel failed: %v", err)
+	}
+	if memory.RAM != uint64(len("/models/fake/model.gguf")) || memory.VRAM != 4096 {
+		t.Errorf("unexpected memory: %+v", memory)
+	}
+
+	runCtx, stopRun := context.WithCancel(ctx)
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- b.Run(runCtx, "/tmp/runner.sock", "sha256:fake", "ai/fake", inference.BackendModeEmbedding, nil)
+	}()
+	model := <-fake.runs
+	if model.ID != "sha256:fake" || model.Ref != "ai/fake" || model.GGUFPath != "/models/fake/model.gguf" {
+		t.Errorf("unexpected model: %+v", model)
+	}
+	if fake.mode != inference.BackendModeEmbedding {
+		t.Errorf("mode = %s, want %s", fake.mode, inference.BackendModeEmbedding)
+	}
+	stopRun()
+	if err := <-runErr; err != nil {
+		t.Errorf("Run returned %v after cancellation, want nil", err)
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("serve returned %v", err)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range []inference.BackendMode{
+		inference.BackendModeCompletion,
+		inference.BackendModeEmbedding,
+		inference.BackendModeReranking,
+		inference.BackendModeTranscription,
+		inference.BackendModeImageGeneration,
+	} {
+		parsed, err := parseMode(mode.String())
+		if err != nil || parsed != mode {
+			t.Errorf("parseMode(%q) = %v, %v", mode, parsed, err)
+		}
+	}
+	if _, err := parseMode("unknown"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
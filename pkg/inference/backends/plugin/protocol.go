@@ -0,0 +1,174 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// ProtocolVersion is the version of the plugin protocol. The model runner
+// refuses to load plugins that speak a different version.
+const ProtocolVersion = 1
+
+const (
+	// protocolEnv is the environment variable through which the model runner
+	// passes ProtocolVersion to plugins. Its presence also distinguishes
+	// plugin launches from users running plugin binaries directly.
+	protocolEnv = "MODEL_RUNNER_PLUGIN_PROTOCOL"
+	// socketEnv is the environment variable through which the model runner
+	// passes the path of the Unix domain socket on which plugins serve the
+	// protocol.
+	socketEnv = "MODEL_RUNNER_PLUGIN_SOCKET"
+	// serviceName is the gRPC service implemented by plugins.
+	serviceName = "modelrunner.plugin.v1.Backend"
+	// codecName is the content subtype used for protocol messages.
+	codecName = "json"
+)
+
+// Model describes the model that a plugin should serve. Plugins don't have
+// access to the model store, so models are passed as paths to their files,
+// which are empty if a model doesn't have the corresponding file.
+type Model struct {
+	// ID is the model ID.
+	ID string `json:"id"`
+	// Ref is the reference by which the model was requested.
+	Ref string `json:"ref"`
+	// RootDir is the directory containing the unpacked model.
+	RootDir string `json:"root-dir,omitempty"`
+	// GGUFPath is the path to the model's first GGUF file.
+	GGUFPath string `json:"gguf-path,omitempty"`
+	// SafetensorsPath is the path to the model's first safetensors file.
+	SafetensorsPath string `json:"safetensors-path,omitempty"`
+	// ONNXPath is the path to the model's ONNX graph.
+	ONNXPath string `json:"onnx-path,omitempty"`
+	// ChatTemplatePath is the path to the model's chat template.
+	ChatTemplatePath string `json:"chat-template-path,omitempty"`
+	// MMPROJPath is the path to the model's multimodal projector.
+	MMPROJPath string `json:"mmproj-path,omitempty"`
+}
+
+// The messages exchanged by the protocol. They're encoded as JSON rather than
+// protocol buffers so that plugins can be implemented without code
+// generation.
+type (
+	infoRequest struct {
+		ProtocolVersion int `json:"protocol-version"`
+	}
+	infoResponse struct {
+		Name            string `json:"name"`
+		ProtocolVersion int    `json:"protocol-version"`
+		MultipleDevices bool   `json:"multiple-devices,omitempty"`
+	}
+	installRequest  struct{}
+	installResponse struct{}
+	runRequest      struct {
+		Socket string                          `json:"socket"`
+		Model  Model                           `json:"model"`
+		Mode   string                          `json:"mode"`
+		Config *inference.BackendConfiguration `json:"config,omitempty"`
+		// Devices is sent separately since BackendConfiguration doesn't
+		// serialize it.
+		Devices []int `json:"devices,omitempty"`
+	}
+	runResponse    struct{}
+	statusRequest  struct{}
+	statusResponse struct {
+		Status string `json:"status"`
+	}
+	diskUsageRequest  struct{}
+	diskUsageResponse struct {
+		Bytes int64 `json:"bytes"`
+	}
+	memoryRequest struct {
+		Model  Model                           `json:"model"`
+		Config *inference.BackendConfiguration `json:"config,omitempty"`
+	}
+	memoryResponse struct {
+		RAM  uint64 `json:"ram"`
+		VRAM uint64 `json:"vram"`
+	}
+)
+
+// codec encodes protocol messages as JSON.
+type codec struct{}
+
+// Marshal implements encoding.Codec.Marshal.
+func (codec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements encoding.Codec.Unmarshal.
+func (codec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// Name implements encoding.Codec.Name.
+func (codec) Name() string {
+	return codecName
+}
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+// unaryHandler adapts a server method to a gRPC method handler.
+func unaryHandler[Req, Resp any](method string, call func(*server, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			request := new(Req)
+			if err := dec(request); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, request any) (any, error) {
+				return call(srv.(*server), ctx, request.(*Req))
+			}
+			if interceptor == nil {
+				return handler(ctx, request)
+			}
+			return interceptor(ctx, request, &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: fullMethod(method),
+			}, handler)
+		},
+	}
+}
+
+// fullMethod returns the full gRPC name of a protocol method.
+func fullMethod(method string) string {
+	return fmt.Sprintf("/%s/%s", serviceName, method)
+}
+
+// serviceDesc describes the protocol's gRPC service.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryHandler("Info", (*server).info),
+		unaryHandler("Install", (*server).install),
+		unaryHandler("Run", (*server).run),
+		unaryHandler("Status", (*server).status),
+		unaryHandler("GetDiskUsage", (*server).diskUsage),
+		unaryHandler("GetRequiredMemoryForModel", (*server).memory),
+	},
+}
+
+// parseMode parses a backend mode sent over the protocol.
+func parseMode(s string) (inference.BackendMode, error) {
+	for _, mode := range []inference.BackendMode{
+		inference.BackendModeCompletion,
+		inference.BackendModeEmbedding,
+		inference.BackendModeReranking,
+		inference.BackendModeTranscription,
+		inference.BackendModeImageGeneration,
+	} {
+		if mode.String() == s {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown backend mode %q", s)
+}
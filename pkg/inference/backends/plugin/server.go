@@ -0,0 +1,152 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"google.golang.org/grpc"
+)
+
+// ErrNotLaunchedByModelRunner indicates that a plugin binary was run directly
+// rather than being launched by the model runner.
+var ErrNotLaunchedByModelRunner = errors.New("plugin must be launched by the model runner")
+
+// Backend is the interface implemented by plugin backends. It mirrors
+// inference.Backend, except that models are passed as their files rather than
+// as references into the model store. Plugins that can split models across
+// multiple GPUs may also implement inference.MultiDeviceBackend.
+type Backend interface {
+	// Name returns the backend name, with the same requirements as
+	// inference.Backend.Name.
+	Name() string
+	// Install ensures that the backend is installed.
+	Install(ctx context.Context) error
+	// Run runs an OpenAI API web server on the specified Unix domain socket
+	// for the specified model, with the same semantics as
+	// inference.Backend.Run.
+	Run(ctx context.Context, socket string, model Model, mode inference.BackendMode, config *inference.BackendConfiguration) error
+	// Status returns a description of the backend's state.
+	Status() string
+	// GetDiskUsage returns the disk usage of the backend.
+	GetDiskUsage() (int64, error)
+	// GetRequiredMemoryForModel returns the required working memory for a
+	// given model.
+	GetRequiredMemoryForModel(ctx context.Context, model Model, config *inference.BackendConfiguration) (inference.RequiredMemory, error)
+}
+
+// Serve serves a backend over the plugin protocol. It should be called from
+// the main function of plugin binaries and returns once the model runner stops
+// the plugin.
+func Serve(backend Backend) error {
+	if os.Getenv(protocolEnv) != strconv.Itoa(ProtocolVersion) {
+		return ErrNotLaunchedByModelRunner
+	}
+	listener, err := net.Listen("unix", os.Getenv(socketEnv))
+	if err != nil {
+		return fmt.Errorf("failed to listen on plugin socket: %w", err)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	return serve(ctx, listener, backend)
+}
+
+// serve serves a backend on a listener until the context is cancelled, at
+// which point any runs are cancelled and waited for.
+func serve(ctx context.Context, listener net.Listener, backend Backend) error {
+	s := &server{backend: backend}
+	grpcServer := grpc.NewServer()
+	grpcServer.RegisterService(&serviceDesc, s)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+		// Stopping the server cancels the contexts of running RPCs, which
+		// makes runs return.
+		grpcServer.Stop()
+		s.runs.Wait()
+		return nil
+	}
+}
+
+// server implements the plugin protocol on top of a Backend.
+type server struct {
+	// backend is the backend being served.
+	backend Backend
+	// runs tracks active runs.
+	runs sync.WaitGroup
+}
+
+func (s *server) info(_ context.Context, request *infoRequest) (*infoResponse, error) {
+	response := &infoResponse{
+		Name:            s.backend.Name(),
+		ProtocolVersion: ProtocolVersion,
+	}
+	if multiDevice, ok := s.backend.(inference.MultiDeviceBackend); ok {
+		response.MultipleDevices = multiDevice.SupportsMultipleDevices()
+	}
+	return response, nil
+}
+
+func (s *server) install(ctx context.Context, _ *installRequest) (*installResponse, error) {
+	if err := s.backend.Install(ctx); err != nil {
+		return nil, err
+	}
+	return &installResponse{}, nil
+}
+
+func (s *server) run(ctx context.Context, request *runRequest) (*runResponse, error) {
+	s.runs.Add(1)
+	defer s.runs.Done()
+
+	mode, err := parseMode(request.Mode)
+	if err != nil {
+		return nil, err
+	}
+	config := request.Config
+	if len(request.Devices) > 0 {
+		assigned := inference.BackendConfiguration{}
+		if config != nil {
+			assigned = *config
+		}
+		assigned.Devices = request.Devices
+		config = &assigned
+	}
+	if err := s.backend.Run(ctx, request.Socket, request.Model, mode, config); err != nil {
+		return nil, err
+	}
+	return &runResponse{}, nil
+}
+
+func (s *server) status(_ context.Context, _ *statusRequest) (*statusResponse, error) {
+	return &statusResponse{Status: s.backend.Status()}, nil
+}
+
+func (s *server) diskUsage(_ context.Context, _ *diskUsageRequest) (*diskUsageResponse, error) {
+	size, err := s.backend.GetDiskUsage()
+	if err != nil {
+		return nil, err
+	}
+	return &diskUsageResponse{Bytes: size}, nil
+}
+
+func (s *server) memory(ctx context.Context, request *memoryRequest) (*memoryResponse, error) {
+	memory, err := s.backend.GetRequiredMemoryForModel(ctx, request.Model, request.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryResponse{RAM: memory.RAM, VRAM: memory.VRAM}, nil
+}
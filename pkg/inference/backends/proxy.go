@@ -0,0 +1,78 @@
+package backends
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// LoopbackAddress returns a loopback TCP address with a currently unused
+// port. It's used by backends whose servers can't listen on a Unix domain
+// socket, which then proxy the runner socket to the address.
+func LoopbackAddress() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)), nil
+}
+
+// ServeSocket serves handler on the Unix domain socket at the specified path.
+// The returned function stops the server and removes the socket.
+func ServeSocket(socket string, handler http.Handler, log Logger) (func(), error) {
+	if err := os.RemoveAll(socket); err != nil {
+		log.Warnf("failed to remove socket file %s: %v\n", socket, err)
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on runner socket: %w", err)
+	}
+	server := &http.Server{Handler: handler}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warnf("runner socket server failed: %v", err)
+		}
+	}()
+	return func() { server.Close() }, nil
+}
+
+// ModelsHandler returns a handler for GET /v1/models requests that lists the
+// served model names once the server at the upstream address accepts
+// connections. It's used as the readiness check for servers that only start
+// listening after loading the model and don't implement the endpoint.
+func ModelsHandler(address string, models []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&net.Dialer{}).DialContext(r.Context(), "tcp", address)
+		if err != nil {
+			http.Error(w, "server not ready", http.StatusServiceUnavailable)
+			return
+		}
+		conn.Close()
+
+		WriteModels(w, models)
+	}
+}
+
+// WriteModels writes an OpenAI API model list response containing the
+// specified model names.
+func WriteModels(w http.ResponseWriter, models []string) {
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		OwnedBy string `json:"owned_by"`
+	}
+	list := struct {
+		Object string  `json:"object"`
+		Data   []model `json:"data"`
+	}{Object: "list", Data: make([]model, len(models))}
+	for i, name := range models {
+		list.Data[i] = model{ID: name, Object: "model", OwnedBy: "docker"}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
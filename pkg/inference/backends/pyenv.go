@@ -0,0 +1,150 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// EnvVersionFile is the name of the file recording the version of the
+	// package installed into a Python environment at its root.
+	EnvVersionFile = "version"
+	// envMarker is the name of the file marking a directory as a Python
+	// environment created by the model runner. Installation directories may be
+	// supplied by the operator, so only marked directories are ever removed.
+	envMarker = ".model-runner-managed"
+)
+
+// ErrNotManaged indicates that an installation was not created by the model
+// runner and thus can't be removed by it.
+var ErrNotManaged = errors.New("installation is not managed by the model runner")
+
+// PythonEnv is a Python virtual environment into which the model runner
+// installs a backend's packages with pip.
+type PythonEnv struct {
+	// Dir is the root of the environment.
+	Dir string
+	// Name is the display name of the installed backend (e.g. "vLLM").
+	Name string
+	// Version is the version of the backend installed into the environment.
+	// It's recorded last, since it marks the installation as complete.
+	Version string
+	// PipArgs are the packages and options passed to pip install.
+	PipArgs []string
+	// Files are additional files, by name, recorded at the root of the
+	// environment once the packages are installed.
+	Files map[string]string
+	// ReplaceUnmarked allows an existing environment that wasn't created by
+	// the model runner to be replaced. It must only be set for directories
+	// owned by the model runner image.
+	ReplaceUnmarked bool
+	// Output receives the output of the installation commands.
+	Output io.Writer
+}
+
+// Installed returns true if the environment has completed installation of
+// the configured version.
+func (e *PythonEnv) Installed() bool {
+	installed, err := ReadEnvFile(e.Dir, EnvVersionFile)
+	if err != nil || installed != e.Version {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(e.Dir, "bin", "python"))
+	return err == nil
+}
+
+// Install creates the environment and installs the configured packages into
+// it. An existing environment is replaced, so that upgrades don't mix
+// packages. The environment is removed if installation fails.
+func (e *PythonEnv) Install(ctx context.Context) error {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		return fmt.Errorf("unable to install %s: python3 not found: %w", e.Name, err)
+	}
+
+	if e.ReplaceUnmarked {
+		err = os.RemoveAll(e.Dir)
+	} else {
+		err = RemovePythonEnv(e.Dir)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove existing %s environment: %w", e.Name, err)
+	}
+	if err := os.MkdirAll(e.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s environment: %w", e.Name, err)
+	}
+	if err := os.WriteFile(filepath.Join(e.Dir, envMarker), nil, 0o644); err != nil {
+		return fmt.Errorf("failed to mark %s environment: %w", e.Name, err)
+	}
+
+	if err := e.install(ctx, python); err != nil {
+		_ = RemovePythonEnv(e.Dir)
+		return err
+	}
+	return nil
+}
+
+// install creates the virtual environment and installs the packages into it.
+func (e *PythonEnv) install(ctx context.Context, python string) error {
+	if err := e.run(ctx, python, "-m", "venv", e.Dir); err != nil {
+		return fmt.Errorf("failed to create %s environment: %w", e.Name, err)
+	}
+	args := append([]string{"-m", "pip", "install", "--no-cache-dir"}, e.PipArgs...)
+	if err := e.run(ctx, filepath.Join(e.Dir, "bin", "python"), args...); err != nil {
+		return fmt.Errorf("failed to install %s: %w", e.Name, err)
+	}
+	for name, content := range e.Files {
+		if err := os.WriteFile(filepath.Join(e.Dir, name), []byte(content+"\n"), 0o644); err != nil {
+			return fmt.Errorf("failed to record %s %s: %w", e.Name, name, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(e.Dir, EnvVersionFile), []byte(e.Version+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to record %s version: %w", e.Name, err)
+	}
+	return nil
+}
+
+// run runs an installation command, forwarding its output.
+func (e *PythonEnv) run(ctx context.Context, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = e.Output
+	cmd.Stderr = e.Output
+	return cmd.Run()
+}
+
+// RemovePythonEnv removes a Python environment created by the model runner.
+// A missing or empty directory is removed without error, but a non-empty
+// directory that wasn't created by the model runner is left untouched.
+func RemovePythonEnv(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		if _, err := os.Stat(filepath.Join(dir, envMarker)); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrNotManaged, dir)
+		} else if err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(dir)
+}
+
+// ReadEnvFile reads a file recorded at the root of a Python environment, or
+// of another installation managed by the model runner.
+func ReadEnvFile(dir, name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,86 @@
+package backends
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemovePythonEnv(t *testing.T) {
+	root := t.TempDir()
+
+	missing := filepath.Join(root, "missing")
+	if err := RemovePythonEnv(missing); err != nil {
+		t.Fatalf("unexpected error for missing directory: %v", err)
+	}
+
+	empty := filepath.Join(root, "empty")
+	if err := os.Mkdir(empty, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := RemovePythonEnv(empty); err != nil {
+		t.Fatalf("unexpected error for empty directory: %v", err)
+	}
+	if _, err := os.Stat(empty); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected empty directory to be removed, got %v", err)
+	}
+
+	foreign := filepath.Join(root, "foreign")
+	if err := os.Mkdir(foreign, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(foreign, "data"), []byte("keep"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := RemovePythonEnv(foreign); !errors.Is(err, ErrNotManaged) {
+		t.Fatalf("expected ErrNotManaged, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(foreign, "data")); err != nil {
+		t.Errorf("expected unmanaged directory to be kept, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(foreign, envMarker), nil, 0o644); err != nil {
+		t.Fatalf("failed to write marker: %v", err)
+	}
+	if err := RemovePythonEnv(foreign); err != nil {
+		t.Fatalf("unexpected error for managed directory: %v", err)
+	}
+	if _, err := os.Stat(foreign); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected managed directory to be removed, got %v", err)
+	}
+}
+
+func TestPythonEnvInstalled(t *testing.T) {
+	dir := t.TempDir()
+	env := &PythonEnv{Dir: dir, Name: "test", Version: "1.2.3"}
+	if env.Installed() {
+		t.Fatal("expected empty environment not to be installed")
+	}
+	if _, err := ReadEnvFile(dir, EnvVersionFile); err == nil {
+		t.Fatal("expected error for missing version file")
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "python"), nil, 0o755); err != nil {
+		t.Fatalf("failed to write interpreter: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, EnvVersionFile), []byte("1.2.2\n"), 0o644); err != nil {
+		t.Fatalf("failed to write version file: %v", err)
+	}
+	if env.Installed() {
+		t.Error("expected environment with another version not to be installed")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, EnvVersionFile), []byte("1.2.3\n"), 0o644); err != nil {
+		t.Fatalf("failed to write version file: %v", err)
+	}
+	if version, err := ReadEnvFile(dir, EnvVersionFile); err != nil || version != "1.2.3" {
+		t.Errorf("expected version 1.2.3, got %q, %v", version, err)
+	}
+	if !env.Installed() {
+		t.Error("expected environment to be installed")
+	}
+}
@@ -0,0 +1,231 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// Name is the backend name.
+const Name = "remote"
+
+// ErrNotConfigured indicates that a model hasn't been configured with an
+// upstream server.
+var ErrNotConfigured = errors.New("model has no remote configuration")
+
+// remote is a backend that forwards requests to external OpenAI-compatible
+// servers.
+type remote struct {
+	// log is the associated logger.
+	log logging.Logger
+}
+
+// New creates a new remote backend.
+func New(log logging.Logger) (inference.Backend, error) {
+	return &remote{log: log}, nil
+}
+
+// Name implements inference.Backend.Name.
+func (r *remote) Name() string {
+	return Name
+}
+
+// UsesExternalModelManagement implements
+// inference.Backend.UsesExternalModelManagement. Remote models are managed by
+// their servers.
+func (r *remote) UsesExternalModelManagement() bool {
+	return true
+}
+
+// Remote implements inference.RemoteBackend.Remote.
+func (r *remote) Remote() bool {
+	return true
+}
+
+// Install implements inference.Backend.Install. There's nothing to install.
+func (r *remote) Install(_ context.Context, _ *http.Client) error {
+	return nil
+}
+
+// Run implements inference.Backend.Run. Rather than starting a server, it
+// serves a reverse proxy to the model's upstream server on the socket.
+func (r *remote) Run(ctx context.Context, socket, model string, modelRef string, _ inference.BackendMode, config *inference.BackendConfiguration) error {
+	if config == nil || config.Remote == nil {
+		return fmt.Errorf("%w: %s", ErrNotConfigured, modelRef)
+	}
+	balancer := &balancer{routing: config.ReplicaRouting()}
+	var redacted []string
+	for _, baseURL := range append([]string{config.Remote.BaseURL}, config.Remote.ReplicaURLs...) {
+		upstream, err := url.Parse(baseURL)
+		if err != nil {
+			return fmt.Errorf("invalid remote base URL: %w", err)
+		}
+		balancer.upstreams = append(balancer.upstreams, &balancedUpstream{proxy: newProxy(upstream, config.Remote.APIKey)})
+		redacted = append(redacted, upstream.Redacted())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, _ *http.Request) {
+		backends.WriteModels(w, []string{model, modelRef})
+	})
+	mux.Handle("/", balancer)
+	stop, err := backends.ServeSocket(socket, mux, r.log)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	r.log.Infof("Forwarding requests for %s to %s", modelRef, strings.Join(redacted, ", "))
+	<-ctx.Done()
+	return nil
+}
+
+// newProxy creates a reverse proxy to the server at the upstream base URL,
+// authenticating with the API key, if any.
+func newProxy(upstream *url.URL, apiKey string) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = upstream.Scheme
+			r.Out.URL.Host = upstream.Host
+			r.Out.URL.Path = upstreamPath(upstream.Path, r.In.URL.Path)
+			r.Out.URL.RawPath = ""
+			r.Out.Host = upstream.Host
+			// Never forward the client's credentials to the upstream server.
+			r.Out.Header.Del("Authorization")
+			if apiKey != "" {
+				r.Out.Header.Set("Authorization", "Bearer "+apiKey)
+			}
+		},
+	}
+}
+
+// balancedUpstream is an upstream server that requests are balanced across.
+type balancedUpstream struct {
+	// proxy is the reverse proxy to the server.
+	proxy *httputil.ReverseProxy
+	// inFlight is the number of requests being served by the server.
+	inFlight atomic.Int64
+}
+
+// balancer distributes requests across the upstream servers replicating a
+// model.
+type balancer struct {
+	// routing determines how requests are distributed.
+	routing inference.ReplicaRouting
+	// upstreams are the upstream servers.
+	upstreams []*balancedUpstream
+	// next is the number of requests distributed so far, which determines the
+	// next server in turn.
+	next atomic.Uint64
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (b *balancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	session := r.Header.Get(inference.SessionIDHeader)
+	if session == "" {
+		session = inference.PromptCacheKey(r.Context())
+	}
+	upstream := b.pick(session)
+	upstream.inFlight.Add(1)
+	defer upstream.inFlight.Add(-1)
+	upstream.proxy.ServeHTTP(w, r)
+}
+
+// pick selects the upstream server for a request in the specified session, if
+// any. Requests in a session, or sharing a prompt cache key, are consistently
+// routed to the same server, so that its prefix cache is reused. Otherwise, servers are taken in turn,
+// except that with least-loaded routing, the server with the fewest requests
+// in flight is preferred.
+func (b *balancer) pick(session string) *balancedUpstream {
+	if session != "" {
+		return b.upstreams[sessionUpstream(session, len(b.upstreams))]
+	}
+	start := int((b.next.Add(1) - 1) % uint64(len(b.upstreams)))
+	selected := b.upstreams[start]
+	if b.routing == inference.ReplicaRoutingRoundRobin {
+		return selected
+	}
+	for i := 1; i < len(b.upstreams); i++ {
+		upstream := b.upstreams[(start+i)%len(b.upstreams)]
+		if upstream.inFlight.Load() < selected.inFlight.Load() {
+			selected = upstream
+		}
+	}
+	return selected
+}
+
+// sessionUpstream returns the index of the upstream server of a session,
+// using rendezvous hashing so that only the sessions of added or removed
+// servers move.
+func sessionUpstream(session string, upstreams int) int {
+	var selected int
+	var selectedRank uint64
+	for i := range upstreams {
+		h := fnv.New64a()
+		h.Write([]byte(session))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.Itoa(i)))
+		if rank := h.Sum64(); i == 0 || rank > selectedRank {
+			selected, selectedRank = i, rank
+		}
+	}
+	return selected
+}
+
+// upstreamPath maps the path of an OpenAI API request onto the base path of
+// the upstream server, which includes the API version.
+func upstreamPath(basePath, path string) string {
+	path = strings.TrimPrefix(path, "/v1")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return strings.TrimSuffix(basePath, "/") + path
+}
+
+// TranslateRequest implements inference.RequestTranslator.TranslateRequest. It
+// replaces the requested model name with the upstream model name, if one is
+// configured.
+func (r *remote) TranslateRequest(_ context.Context, _ inference.BackendMode, config *inference.BackendConfiguration, body []byte) ([]byte, error) {
+	if config == nil || config.Remote == nil || config.Remote.Model == "" {
+		return body, nil
+	}
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	model, err := json.Marshal(config.Remote.Model)
+	if err != nil {
+		return nil, err
+	}
+	request["model"] = model
+	return json.Marshal(request)
+}
+
+// Status implements inference.Backend.Status.
+func (r *remote) Status() string {
+	return "running"
+}
+
+// GetDiskUsage implements inference.Backend.GetDiskUsage.
+func (r *remote) GetDiskUsage() (int64, error) {
+	return 0, nil
+}
+
+// GetRequiredMemoryForModel implements
+// inference.Backend.GetRequiredMemoryForModel. Remote models don't use any
+// local memory.
+func (r *remote) GetRequiredMemoryForModel(_ context.Context, _ string, _ *inference.BackendConfiguration) (inference.RequiredMemory, error) {
+	return inference.RequiredMemory{}, nil
+}
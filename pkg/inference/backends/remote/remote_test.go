@@ -0,0 +1,191 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+func TestUpstreamPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		basePath string
+		path     string
+		expected string
+	}{
+		{
+			name:     "versioned base URL",
+			basePath: "/v1",
+			path:     "/v1/chat/completions",
+			expected: "/v1/chat/completions",
+		},
+		{
+			name:     "custom base path",
+			basePath: "/openai/v1/",
+			path:     "/v1/embeddings",
+			expected: "/openai/v1/embeddings",
+		},
+		{
+			name:     "unversioned request",
+			basePath: "/v1",
+			path:     "/rerank",
+			expected: "/v1/rerank",
+		},
+		{
+			name:     "empty base path",
+			basePath: "",
+			path:     "/v1/completions",
+			expected: "/completions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := upstreamPath(tt.basePath, tt.path); got != tt.expected {
+				t.Errorf("upstreamPath(%q, %q) = %q, want %q", tt.basePath, tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTranslateRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *inference.BackendConfiguration
+		body     string
+		expected string
+	}{
+		{
+			name:     "no config",
+			body:     `{"model":"gpt"}`,
+			expected: "gpt",
+		},
+		{
+			name:     "no upstream model",
+			config:   &inference.BackendConfiguration{Remote: &inference.RemoteConfig{BaseURL: "https://example.com/v1"}},
+			body:     `{"model":"gpt"}`,
+			expected: "gpt",
+		},
+		{
+			name: "upstream model",
+			config: &inference.BackendConfiguration{Remote: &inference.RemoteConfig{
+				BaseURL: "https://example.com/v1",
+				Model:   "gpt-4o-mini",
+			}},
+			body:     `{"model":"gpt","messages":[]}`,
+			expected: "gpt-4o-mini",
+		},
+	}
+
+	r := &remote{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := r.TranslateRequest(context.Background(), inference.BackendModeCompletion, tt.config, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var request struct {
+				Model string `json:"model"`
+			}
+			if err := json.Unmarshal(body, &request); err != nil {
+				t.Fatalf("invalid translated request: %v", err)
+			}
+			if request.Model != tt.expected {
+				t.Errorf("model = %q, want %q", request.Model, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var path, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	upstream, err := url.Parse(server.URL + "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(newProxy(upstream, "secret"))
+	defer proxy.Close()
+
+	req, err := http.NewRequest(http.MethodPost, proxy.URL+"/v1/chat/completions", http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer client")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if path != "/api/v1/chat/completions" {
+		t.Errorf("path = %q, want %q", path, "/api/v1/chat/completions")
+	}
+	if authorization != "Bearer secret" {
+		t.Errorf("Authorization = %q, want %q", authorization, "Bearer secret")
+	}
+}
+
+func TestBalancerPick(t *testing.T) {
+	newBalancer := func(routing inference.ReplicaRouting, inFlight ...int64) *balancer {
+		b := &balancer{routing: routing}
+		for _, n := range inFlight {
+			upstream := &balancedUpstream{}
+			upstream.inFlight.Store(n)
+			b.upstreams = append(b.upstreams, upstream)
+		}
+		return b
+	}
+	indexOf := func(b *balancer, upstream *balancedUpstream) int {
+		for i, u := range b.upstreams {
+			if u == upstream {
+				return i
+			}
+		}
+		return -1
+	}
+
+	b := newBalancer(inference.ReplicaRoutingRoundRobin, 5, 0, 0)
+	for i, expected := range []int{0, 1, 2, 0} {
+		if got := indexOf(b, b.pick("")); got != expected {
+			t.Errorf("round-robin pick %d = %d, want %d", i, got, expected)
+		}
+	}
+
+	b = newBalancer(inference.ReplicaRoutingLeastLoaded, 5, 1, 3)
+	for i := range 3 {
+		if got := indexOf(b, b.pick("")); got != 1 {
+			t.Errorf("least-loaded pick %d = %d, want 1", i, got)
+		}
+	}
+
+	// Servers with equal load are taken in turn.
+	b = newBalancer(inference.ReplicaRoutingLeastLoaded, 0, 0)
+	if first, second := indexOf(b, b.pick("")), indexOf(b, b.pick("")); first == second {
+		t.Errorf("least-loaded picks = %d, %d, want distinct servers", first, second)
+	}
+}
+
+func TestBalancerPickSession(t *testing.T) {
+	b := &balancer{routing: inference.ReplicaRoutingLeastLoaded}
+	for range 3 {
+		b.upstreams = append(b.upstreams, &balancedUpstream{})
+	}
+	for _, session := range []string{"a", "b", "c"} {
+		first := b.pick(session)
+		first.inFlight.Add(10)
+		if b.pick(session) != first {
+			t.Errorf("session %q moved to another server", session)
+		}
+	}
+}
@@ -9,9 +9,13 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/model-runner/pkg/internal/utils"
+	"github.com/docker/model-runner/pkg/logging"
 	"github.com/docker/model-runner/pkg/sandbox"
 	"github.com/docker/model-runner/pkg/tailbuffer"
 )
@@ -20,7 +24,8 @@ import (
 type RunnerConfig struct {
 	// BackendName is the display name of the backend (e.g., "llama.cpp", "vLLM")
 	BackendName string
-	// Socket is the unix socket path
+	// Socket is the unix socket path. It may be empty if the backend manages
+	// the socket itself.
 	Socket string
 	// BinaryPath is the path to the backend binary
 	BinaryPath string
@@ -30,12 +35,43 @@ type RunnerConfig struct {
 	SandboxConfig string
 	// Args are the command line arguments
 	Args []string
+	// Env are additional environment variables (in "key=value" form) for the
+	// backend process
+	Env []string
+	// Devices are the indices of the GPUs that the backend process is
+	// restricted to. If empty, the process sees all GPUs.
+	Devices []int
 	// Logger provides logging functionality
 	Logger Logger
 	// ServerLogWriter provides a writer for server logs
 	ServerLogWriter io.WriteCloser
 }
 
+// stopTimeout is the time a backend process has to exit after being
+// interrupted before it's killed, so that wedged processes don't outlive
+// their runners.
+const stopTimeout = 30 * time.Second
+
+// outputKey is the context key of the writer set by WithOutput.
+type outputKey struct{}
+
+// WithOutput returns a context under which RunBackend also writes the error
+// output of the backend process to w, for example to keep its recent output
+// for inspection.
+func WithOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+// processKey is the context key of the function set by WithProcess.
+type processKey struct{}
+
+// WithProcess returns a context under which RunBackend calls started with the
+// PID of the backend process once it's started, for example to attribute the
+// resources that it uses.
+func WithProcess(ctx context.Context, started func(pid int)) context.Context {
+	return context.WithValue(ctx, processKey{}, started)
+}
+
 // Logger interface for backend logging
 type Logger interface {
 	Infof(format string, args ...interface{})
@@ -43,6 +79,19 @@ type Logger interface {
 	Warnln(args ...interface{})
 }
 
+// deviceEnv returns the environment variables restricting a process to the
+// specified GPUs.
+func deviceEnv(devices []int) []string {
+	if len(devices) == 0 {
+		return nil
+	}
+	indices := make([]string, len(devices))
+	for i, device := range devices {
+		indices[i] = strconv.Itoa(device)
+	}
+	return []string{"CUDA_VISIBLE_DEVICES=" + strings.Join(indices, ",")}
+}
+
 // RunBackend runs a backend process with common error handling and logging.
 // It handles:
 // - Socket cleanup
@@ -52,9 +101,11 @@ type Logger interface {
 // - Context cancellation
 func RunBackend(ctx context.Context, config RunnerConfig) error {
 	// Remove old socket file
-	if err := os.RemoveAll(config.Socket); err != nil && !errors.Is(err, fs.ErrNotExist) {
-		config.Logger.Warnf("failed to remove socket file %s: %v\n", config.Socket, err)
-		config.Logger.Warnln(config.BackendName + " may not be able to start")
+	if config.Socket != "" {
+		if err := os.RemoveAll(config.Socket); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			config.Logger.Warnf("failed to remove socket file %s: %v\n", config.Socket, err)
+			config.Logger.Warnln(config.BackendName + " may not be able to start")
+		}
 	}
 
 	// Sanitize args for safe logging
@@ -64,9 +115,18 @@ func RunBackend(ctx context.Context, config RunnerConfig) error {
 	}
 	config.Logger.Infof("%s args: %v", config.BackendName, sanitizedArgs)
 
+	// Server output is dropped rather than blocking the server while the disk
+	// holding the server log is full.
+	serverLog := logging.NewDiskFullWriter(config.ServerLogWriter, func(err error) {
+		config.Logger.Warnf("Dropping %s output while the disk is full: %v", config.BackendName, err)
+	})
+
 	// Create tail buffer for error output
 	tailBuf := tailbuffer.NewTailBuffer(1024)
-	out := io.MultiWriter(config.ServerLogWriter, tailBuf)
+	out := io.MultiWriter(serverLog, tailBuf)
+	if w, ok := ctx.Value(outputKey{}).(io.Writer); ok {
+		out = io.MultiWriter(out, w)
+	}
 
 	// Create sandbox with process cancellation
 	backendSandbox, err := sandbox.Create(
@@ -79,8 +139,12 @@ func RunBackend(ctx context.Context, config RunnerConfig) error {
 				}
 				return command.Process.Signal(os.Interrupt)
 			}
-			command.Stdout = config.ServerLogWriter
+			command.WaitDelay = stopTimeout
+			command.Stdout = serverLog
 			command.Stderr = out
+			if env := slices.Concat(config.Env, deviceEnv(config.Devices)); len(env) > 0 {
+				command.Env = append(os.Environ(), env...)
+			}
 		},
 		config.SandboxPath,
 		config.BinaryPath,
@@ -90,6 +154,9 @@ func RunBackend(ctx context.Context, config RunnerConfig) error {
 		return fmt.Errorf("unable to start %s: %w", config.BackendName, err)
 	}
 	defer backendSandbox.Close()
+	if started, ok := ctx.Value(processKey{}).(func(int)); ok {
+		started(backendSandbox.Command().Process.Pid)
+	}
 
 	// Handle backend process errors
 	backendErrors := make(chan error, 1)
@@ -110,8 +177,10 @@ func RunBackend(ctx context.Context, config RunnerConfig) error {
 
 		backendErrors <- backendErr
 		close(backendErrors)
-		if err := os.Remove(config.Socket); err != nil && !errors.Is(err, fs.ErrNotExist) {
-			config.Logger.Warnf("failed to remove socket file %s on exit: %v\n", config.Socket, err)
+		if config.Socket != "" {
+			if err := os.Remove(config.Socket); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				config.Logger.Warnf("failed to remove socket file %s on exit: %v\n", config.Socket, err)
+			}
 		}
 	}()
 	defer func() {
@@ -0,0 +1,132 @@
+package sdcpp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// maximumImages is the maximum number of images generated per request,
+	// matching the OpenAI API.
+	maximumImages = 10
+	// maximumSteps is the maximum number of sampling steps per request.
+	maximumSteps = 150
+)
+
+// ErrInvalidImageRequest indicates that an image generation request can't be
+// served.
+var ErrInvalidImageRequest = errors.New("invalid image generation request")
+
+// imageRequest is an OpenAI API image generation request, extended with
+// stable-diffusion.cpp sampling parameters.
+type imageRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	N              *int   `json:"n,omitempty"`
+	Size           string `json:"size,omitempty"`
+	ResponseFormat string `json:"response_format,omitempty"`
+	// NegativePrompt describes what the image should not contain.
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	// Steps is the number of sampling steps.
+	Steps *int `json:"steps,omitempty"`
+	// Seed makes generation reproducible.
+	Seed *int64 `json:"seed,omitempty"`
+	// CFGScale is the classifier-free guidance scale.
+	CFGScale *float64 `json:"cfg_scale,omitempty"`
+	// Sampler is the sampling method, such as euler_a.
+	Sampler string `json:"sampler,omitempty"`
+}
+
+// extraArgs are the sampling parameters that sd-server reads from the prompt,
+// since the OpenAI API has no fields for them.
+type extraArgs struct {
+	NegativePrompt string   `json:"negative_prompt,omitempty"`
+	SampleSteps    *int     `json:"sample_steps,omitempty"`
+	Seed           *int64   `json:"seed,omitempty"`
+	CFGScale       *float64 `json:"cfg_scale,omitempty"`
+	SampleMethod   string   `json:"sample_method,omitempty"`
+}
+
+// serverRequest is an sd-server image generation request.
+type serverRequest struct {
+	Model        string `json:"model,omitempty"`
+	Prompt       string `json:"prompt"`
+	N            int    `json:"n"`
+	Size         string `json:"size,omitempty"`
+	OutputFormat string `json:"output_format"`
+}
+
+// translateImageRequest validates an image generation request and translates
+// it for sd-server, rejecting images larger than maxSize.
+func translateImageRequest(body []byte, maxSize ImageSize) ([]byte, error) {
+	var request imageRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImageRequest, err)
+	}
+
+	if strings.TrimSpace(request.Prompt) == "" {
+		return nil, fmt.Errorf("%w: prompt is required", ErrInvalidImageRequest)
+	}
+	if strings.Contains(request.Prompt, "<sd_cpp_extra_args>") {
+		return nil, fmt.Errorf("%w: prompt must not contain sd_cpp_extra_args", ErrInvalidImageRequest)
+	}
+
+	// Only base64 responses are supported, since there's nowhere to host
+	// images.
+	if request.ResponseFormat != "" && request.ResponseFormat != "b64_json" {
+		return nil, fmt.Errorf("%w: unsupported response_format %q (expected b64_json)", ErrInvalidImageRequest, request.ResponseFormat)
+	}
+
+	n := 1
+	if request.N != nil {
+		n = *request.N
+		if n < 1 || n > maximumImages {
+			return nil, fmt.Errorf("%w: n must be between 1 and %d", ErrInvalidImageRequest, maximumImages)
+		}
+	}
+
+	var size string
+	if request.Size != "" && request.Size != "auto" {
+		parsed, err := ParseImageSize(request.Size)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidImageRequest, err)
+		}
+		if parsed.pixels() > maxSize.pixels() {
+			return nil, fmt.Errorf("%w: size %s exceeds the maximum of %s pixels", ErrInvalidImageRequest, parsed, maxSize)
+		}
+		size = parsed.String()
+	}
+
+	if request.Steps != nil && (*request.Steps < 1 || *request.Steps > maximumSteps) {
+		return nil, fmt.Errorf("%w: steps must be between 1 and %d", ErrInvalidImageRequest, maximumSteps)
+	}
+	if request.CFGScale != nil && *request.CFGScale < 0 {
+		return nil, fmt.Errorf("%w: cfg_scale must not be negative", ErrInvalidImageRequest)
+	}
+
+	prompt := request.Prompt
+	extra := extraArgs{
+		NegativePrompt: request.NegativePrompt,
+		SampleSteps:    request.Steps,
+		Seed:           request.Seed,
+		CFGScale:       request.CFGScale,
+		SampleMethod:   request.Sampler,
+	}
+	if extra != (extraArgs{}) {
+		encoded, err := json.Marshal(extra)
+		if err != nil {
+			return nil, err
+		}
+		prompt += "<sd_cpp_extra_args>" + string(encoded) + "</sd_cpp_extra_args>"
+	}
+
+	return json.Marshal(serverRequest{
+		Model:        request.Model,
+		Prompt:       prompt,
+		N:            n,
+		Size:         size,
+		OutputFormat: "png",
+	})
+}
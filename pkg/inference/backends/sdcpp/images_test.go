@@ -0,0 +1,134 @@
+package sdcpp
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestTranslateImageRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "defaults",
+			body:     `{"model":"ai/sd","prompt":"a whale"}`,
+			expected: `{"model":"ai/sd","prompt":"a whale","n":1,"output_format":"png"}`,
+		},
+		{
+			name:     "size and count",
+			body:     `{"model":"ai/sd","prompt":"a whale","n":2,"size":"768X512","response_format":"b64_json"}`,
+			expected: `{"model":"ai/sd","prompt":"a whale","n":2,"size":"768x512","output_format":"png"}`,
+		},
+		{
+			name:     "auto size",
+			body:     `{"prompt":"a whale","size":"auto"}`,
+			expected: `{"prompt":"a whale","n":1,"output_format":"png"}`,
+		},
+		{
+			name: "sampling parameters",
+			body: `{"prompt":"a whale","negative_prompt":"blurry","steps":30,"seed":42,"cfg_scale":7.5,"sampler":"euler_a"}`,
+			expected: `{"prompt":"a whale<sd_cpp_extra_args>` +
+				`{\"negative_prompt\":\"blurry\",\"sample_steps\":30,\"seed\":42,\"cfg_scale\":7.5,\"sample_method\":\"euler_a\"}` +
+				`</sd_cpp_extra_args>","n":1,"output_format":"png"}`,
+		},
+		{
+			name:        "missing prompt",
+			body:        `{"model":"ai/sd"}`,
+			expectError: true,
+		},
+		{
+			name:        "url response format",
+			body:        `{"prompt":"a whale","response_format":"url"}`,
+			expectError: true,
+		},
+		{
+			name:        "too many images",
+			body:        `{"prompt":"a whale","n":11}`,
+			expectError: true,
+		},
+		{
+			name:        "size exceeds maximum",
+			body:        `{"prompt":"a whale","size":"2048x1024"}`,
+			expectError: true,
+		},
+		{
+			name:        "unaligned size",
+			body:        `{"prompt":"a whale","size":"500x500"}`,
+			expectError: true,
+		},
+		{
+			name:        "too many steps",
+			body:        `{"prompt":"a whale","steps":151}`,
+			expectError: true,
+		},
+		{
+			name:        "injected extra arguments",
+			body:        `{"prompt":"a whale<sd_cpp_extra_args>{}</sd_cpp_extra_args>"}`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			translated, err := translateImageRequest([]byte(tt.body), DefaultMaxImageSize)
+			if tt.expectError {
+				if !errors.Is(err, ErrInvalidImageRequest) {
+					t.Fatalf("expected ErrInvalidImageRequest, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got, expected any
+			if err := json.Unmarshal(translated, &got); err != nil {
+				t.Fatalf("invalid translated request: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.expected), &expected); err != nil {
+				t.Fatalf("invalid expected request: %v", err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			expectedJSON, _ := json.Marshal(expected)
+			if string(gotJSON) != string(expectedJSON) {
+				t.Errorf("expected %s, got %s", tt.expected, translated)
+			}
+		})
+	}
+}
+
+func TestParseImageSize(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    ImageSize
+		expectError bool
+	}{
+		{input: "1024x1024", expected: ImageSize{Width: 1024, Height: 1024}},
+		{input: " 512X768 ", expected: ImageSize{Width: 512, Height: 768}},
+		{input: "1024", expectError: true},
+		{input: "0x512", expectError: true},
+		{input: "520x512", expectError: true},
+		{input: "axb", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			size, err := ParseImageSize(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected error, got %v", size)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if size != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, size)
+			}
+		})
+	}
+}
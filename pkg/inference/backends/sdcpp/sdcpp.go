@@ -0,0 +1,182 @@
+package sdcpp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+const (
+	// Name is the backend name.
+	Name = "stable-diffusion.cpp"
+	// binaryName is the name of the stable-diffusion.cpp server binary.
+	binaryName = "sd-server"
+)
+
+var ErrorNotFound = inference.NotInstalledError("sd-server binary not found")
+
+// sdcpp is the stable-diffusion.cpp-based backend implementation.
+type sdcpp struct {
+	// log is the associated logger.
+	log logging.Logger
+	// modelManager is the shared model manager.
+	modelManager *models.Manager
+	// serverLog is the logger to use for the sd-server process.
+	serverLog logging.Logger
+	// config is the configuration for the stable-diffusion.cpp backend.
+	config *Config
+	// status is the state in which the stable-diffusion.cpp backend is in.
+	status string
+	// binaryPath is the path to the sd-server binary in use.
+	binaryPath string
+}
+
+// New creates a new stable-diffusion.cpp-based backend.
+func New(log logging.Logger, modelManager *models.Manager, serverLog logging.Logger, conf *Config) (inference.Backend, error) {
+	// If no config is provided, use the default configuration
+	if conf == nil {
+		conf = NewDefaultSDConfig()
+	}
+
+	return &sdcpp{
+		log:          log,
+		modelManager: modelManager,
+		serverLog:    serverLog,
+		config:       conf,
+		status:       "not installed",
+	}, nil
+}
+
+// Name implements inference.Backend.Name.
+func (s *sdcpp) Name() string {
+	return Name
+}
+
+// UsesExternalModelManagement implements
+// inference.Backend.UsesExternalModelManagement.
+func (s *sdcpp) UsesExternalModelManagement() bool {
+	return false
+}
+
+// Install implements inference.Backend.Install. sd-server isn't downloaded by
+// the model runner, so it must either be configured or be available on the
+// PATH.
+func (s *sdcpp) Install(_ context.Context, _ *http.Client) error {
+	binaryPath := s.config.BinaryPath
+	if binaryPath == "" {
+		var err error
+		if binaryPath, err = exec.LookPath(binaryName); err != nil {
+			s.status = ErrorNotFound.Error()
+			return ErrorNotFound
+		}
+	} else if _, err := os.Stat(binaryPath); err != nil {
+		s.status = ErrorNotFound.Error()
+		return fmt.Errorf("%w: %w", ErrorNotFound, err)
+	}
+	s.binaryPath = binaryPath
+	s.status = fmt.Sprintf("running stable-diffusion.cpp (%s, max image size: %s)", binaryPath, s.config.MaxImageSize)
+	return nil
+}
+
+// Run implements inference.Backend.Run.
+func (s *sdcpp) Run(ctx context.Context, socket, model string, modelRef string, mode inference.BackendMode, backendConfig *inference.BackendConfiguration) error {
+	bundle, err := s.modelManager.GetBundle(model)
+	if err != nil {
+		return fmt.Errorf("failed to get model: %w", err)
+	}
+
+	// sd-server only listens on TCP, so bind it to a free loopback port and
+	// proxy the runner socket to it.
+	address, err := backends.LoopbackAddress()
+	if err != nil {
+		return fmt.Errorf("failed to allocate sd-server address: %w", err)
+	}
+
+	args, err := s.config.GetArgs(bundle, address, mode, backendConfig)
+	if err != nil {
+		return fmt.Errorf("failed to get stable-diffusion.cpp arguments: %w", err)
+	}
+
+	upstream, err := url.Parse("http://" + address)
+	if err != nil {
+		return fmt.Errorf("invalid sd-server address: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", backends.ModelsHandler(address, []string{model, modelRef}))
+	mux.Handle("/", httputil.NewSingleHostReverseProxy(upstream))
+	stop, err := backends.ServeSocket(socket, mux, s.log)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	return backends.RunBackend(ctx, backends.RunnerConfig{
+		BackendName:     "stable-diffusion.cpp",
+		BinaryPath:      s.binaryPath,
+		SandboxPath:     filepath.Dir(s.binaryPath),
+		SandboxConfig:   "",
+		Args:            args,
+		Devices:         backendConfig.AssignedDevices(),
+		Logger:          s.log,
+		ServerLogWriter: s.serverLog.Writer(),
+	})
+}
+
+// TranslateRequest implements inference.RequestTranslator.TranslateRequest.
+func (s *sdcpp) TranslateRequest(_ context.Context, mode inference.BackendMode, _ *inference.BackendConfiguration, body []byte) ([]byte, error) {
+	if mode != inference.BackendModeImageGeneration {
+		return body, nil
+	}
+	return translateImageRequest(body, s.config.MaxImageSize)
+}
+
+func (s *sdcpp) Status() string {
+	return s.status
+}
+
+// GetDiskUsage implements inference.Backend.GetDiskUsage. sd-server isn't
+// managed by the model runner, so it doesn't count towards its disk usage.
+func (s *sdcpp) GetDiskUsage() (int64, error) {
+	return 0, nil
+}
+
+// activationBytesPerPixel approximates the VAE decoding and attention
+// activations, which grow with the size of the generated image.
+const activationBytesPerPixel = 1024
+
+// GetRequiredMemoryForModel implements
+// inference.Backend.GetRequiredMemoryForModel. Besides the weights, memory is
+// reserved for generating an image of the maximum size, since larger requests
+// are rejected.
+func (s *sdcpp) GetRequiredMemoryForModel(_ context.Context, model string, _ *inference.BackendConfiguration) (inference.RequiredMemory, error) {
+	weights, err := s.weightsSize(model)
+	if err != nil {
+		s.log.Warnf("Could not estimate VRAM for model %s: %v", model, err)
+		return inference.RequiredMemory{RAM: 1, VRAM: 1}, nil
+	}
+	vram := backends.EstimateFromWeights(weights, backends.WeightsOverheadFactor) + uint64(s.config.MaxImageSize.pixels())*activationBytesPerPixel
+	return inference.RequiredMemory{RAM: 1, VRAM: vram}, nil
+}
+
+// weightsSize returns the size of a model's checkpoint.
+func (s *sdcpp) weightsSize(model string) (int64, error) {
+	bundle, err := s.modelManager.GetBundle(model)
+	if err != nil {
+		return 0, err
+	}
+	if bundle.DiffusionPath() == "" {
+		return 0, errors.New("model has no diffusion checkpoint")
+	}
+	return backends.FilesSize(bundle.DiffusionPath())
+}
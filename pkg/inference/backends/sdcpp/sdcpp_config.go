@@ -0,0 +1,110 @@
+package sdcpp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// ImageSize is the size of a generated image in pixels.
+type ImageSize struct {
+	Width  int
+	Height int
+}
+
+// DefaultMaxImageSize is the largest image that can be generated by default.
+var DefaultMaxImageSize = ImageSize{Width: 1024, Height: 1024}
+
+// imageSizeAlignment is the multiple of pixels that image dimensions must be
+// aligned to, so that they map onto whole latent tiles.
+const imageSizeAlignment = 64
+
+// ParseImageSize parses an image size in WIDTHxHEIGHT form.
+func ParseImageSize(s string) (ImageSize, error) {
+	width, height, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+	if !ok {
+		return ImageSize{}, fmt.Errorf("invalid image size %q (expected WIDTHxHEIGHT)", s)
+	}
+	w, err := strconv.Atoi(width)
+	if err != nil {
+		return ImageSize{}, fmt.Errorf("invalid image width in %q: %w", s, err)
+	}
+	h, err := strconv.Atoi(height)
+	if err != nil {
+		return ImageSize{}, fmt.Errorf("invalid image height in %q: %w", s, err)
+	}
+	if w < imageSizeAlignment || h < imageSizeAlignment || w%imageSizeAlignment != 0 || h%imageSizeAlignment != 0 {
+		return ImageSize{}, fmt.Errorf("invalid image size %q (dimensions must be positive multiples of %d)", s, imageSizeAlignment)
+	}
+	return ImageSize{Width: w, Height: h}, nil
+}
+
+// String implements Stringer.String for ImageSize.
+func (s ImageSize) String() string {
+	return fmt.Sprintf("%dx%d", s.Width, s.Height)
+}
+
+// pixels returns the number of pixels in an image of the size.
+func (s ImageSize) pixels() int {
+	return s.Width * s.Height
+}
+
+// Config is the configuration for the stable-diffusion.cpp backend.
+type Config struct {
+	// Args are the base arguments that are always included.
+	Args []string
+	// BinaryPath is the path to the sd-server binary. If empty, the binary
+	// is looked up on the PATH.
+	BinaryPath string
+	// MaxImageSize is the largest image, by pixel count, that can be
+	// generated. It bounds the memory reserved for diffusion models.
+	MaxImageSize ImageSize
+}
+
+// NewDefaultSDConfig creates a new stable-diffusion.cpp Config with default
+// values.
+func NewDefaultSDConfig() *Config {
+	return &Config{
+		Args:         []string{},
+		MaxImageSize: DefaultMaxImageSize,
+	}
+}
+
+// GetArgs implements BackendConfig.GetArgs. sd-server can't listen on a Unix
+// domain socket, so socket is the loopback TCP address that the server should
+// listen on, which the backend proxies to.
+func (c *Config) GetArgs(bundle types.ModelBundle, socket string, mode inference.BackendMode, config *inference.BackendConfiguration) ([]string, error) {
+	// Start with the arguments from Config
+	args := append([]string{}, c.Args...)
+
+	modelPath := bundle.DiffusionPath()
+	if modelPath == "" {
+		return nil, fmt.Errorf("diffusion model required by stable-diffusion.cpp backend")
+	}
+
+	if mode != inference.BackendModeImageGeneration {
+		return nil, fmt.Errorf("%s mode not supported by stable-diffusion.cpp backend", mode)
+	}
+
+	host, port, err := net.SplitHostPort(socket)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sd-server address %q: %w", socket, err)
+	}
+
+	args = append(args,
+		"--model", modelPath,
+		"--listen-ip", host,
+		"--listen-port", port,
+	)
+
+	// Add arguments from backend config
+	if config != nil {
+		args = append(args, config.RuntimeFlags...)
+	}
+
+	return args, nil
+}
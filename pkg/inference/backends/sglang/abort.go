@@ -0,0 +1,60 @@
+package sglang
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// TranslateRequest implements inference.RequestTranslator.TranslateRequest. It
+// sets the request ID of completions as their SGLang request ID, so that their
+// generation can be aborted. Requests for several choices are left unchanged,
+// since SGLang derives their request IDs from the one given.
+func (s *sglang) TranslateRequest(ctx context.Context, mode inference.BackendMode, _ *inference.BackendConfiguration, body []byte) ([]byte, error) {
+	requestID := inference.RequestID(ctx)
+	if mode != inference.BackendModeCompletion || requestID == "" {
+		return body, nil
+	}
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if _, ok := request["rid"]; ok {
+		return body, nil
+	}
+	if n, ok := request["n"]; ok && string(n) != "1" && string(n) != "null" {
+		return body, nil
+	}
+	request["rid"], _ = json.Marshal(requestID)
+	return json.Marshal(request)
+}
+
+// AbortRequest implements inference.RequestAborter.AbortRequest. SGLang only
+// notices that the client of a non-streamed request disconnected when it
+// polls the connection, so requests are aborted through its abort endpoint.
+func (s *sglang) AbortRequest(ctx context.Context, client *http.Client, requestID string) error {
+	body, err := json.Marshal(map[string]string{"rid": requestID})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost/abort_request", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("abort request failed with status %s", response.Status)
+	}
+	return nil
+}
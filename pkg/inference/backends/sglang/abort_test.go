@@ -0,0 +1,79 @@
+package sglang
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+func TestTranslateRequestID(t *testing.T) {
+	s := &sglang{}
+	ctx := inference.WithRequestID(context.Background(), "req-1")
+	for _, test := range []struct {
+		body string
+		mode inference.BackendMode
+		rid  any
+	}{
+		{body: `{"model":"m","prompt":"Hi"}`, mode: inference.BackendModeCompletion, rid: "req-1"},
+		{body: `{"model":"m","n":1}`, mode: inference.BackendModeCompletion, rid: "req-1"},
+		{body: `{"model":"m","n":2}`, mode: inference.BackendModeCompletion},
+		{body: `{"model":"m","rid":"mine"}`, mode: inference.BackendModeCompletion, rid: "mine"},
+		{body: `{"model":"m","input":"Hi"}`, mode: inference.BackendModeEmbedding},
+	} {
+		body, err := s.TranslateRequest(ctx, test.mode, nil, []byte(test.body))
+		if err != nil {
+			t.Fatalf("Failed to translate %s: %v", test.body, err)
+		}
+		var request map[string]any
+		json.Unmarshal(body, &request)
+		if request["rid"] != test.rid {
+			t.Errorf("Expected request ID %v for %s, got %s", test.rid, test.body, body)
+		}
+	}
+
+	body := `{"model":"m"}`
+	if translated, _ := s.TranslateRequest(context.Background(), inference.BackendModeCompletion, nil, []byte(body)); string(translated) != body {
+		t.Errorf("Expected requests without an ID to be unchanged, got %s", translated)
+	}
+}
+
+func TestAbortRequest(t *testing.T) {
+	aborted := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			RID string `json:"rid"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		if r.Method != http.MethodPost || r.URL.Path != "/abort_request" {
+			http.NotFound(w, r)
+			return
+		}
+		aborted <- request.RID
+	}))
+	defer server.Close()
+
+	client := server.Client()
+	client.Transport = rewriteHost{base: client.Transport, host: server.Listener.Addr().String()}
+	if err := (&sglang{}).AbortRequest(context.Background(), client, "req-1"); err != nil {
+		t.Fatalf("Failed to abort request: %v", err)
+	}
+	if rid := <-aborted; rid != "req-1" {
+		t.Errorf("Expected request req-1 to be aborted, got %q", rid)
+	}
+}
+
+// rewriteHost sends requests to a fixed host, as the runner's transport does.
+type rewriteHost struct {
+	base http.RoundTripper
+	host string
+}
+
+func (t rewriteHost) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Host = t.host
+	return t.base.RoundTrip(r)
+}
@@ -0,0 +1,48 @@
+package sglang
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/model-runner/pkg/inference/backends"
+)
+
+// offlinePipInstallArgs returns the arguments to pip install for the
+// specified SGLang version from a directory of wheels, without access to a
+// package index.
+func offlinePipInstallArgs(wheelsDir, version string, dataParallel bool) []string {
+	args := []string{"--no-index", "--find-links", wheelsDir, "sglang==" + version}
+	if dataParallel {
+		args = append(args, "sglang-router")
+	}
+	return args
+}
+
+// installFromOfflineBundle creates the SGLang environment from the wheels of
+// the offline bundle. An existing environment with the bundled version is
+// reused.
+func (s *sglang) installFromOfflineBundle(ctx context.Context, wheelsDir, version string) error {
+	env := &backends.PythonEnv{
+		Dir:     envDir,
+		Name:    "SGLang",
+		Version: version,
+		PipArgs: offlinePipInstallArgs(wheelsDir, version, s.config.DataParallelSize > 1),
+		// The environment directory belongs to the model runner image.
+		ReplaceUnmarked: true,
+	}
+	if env.Installed() {
+		return nil
+	}
+
+	s.log.Infof("Installing SGLang %s from offline bundle %s into %s", version, s.config.OfflineBundle.Dir(), envDir)
+	s.status = fmt.Sprintf("installing sglang version %s from offline bundle", version)
+
+	out := s.serverLog.Writer()
+	defer out.Close()
+	env.Output = out
+	if err := env.Install(ctx); err != nil {
+		return err
+	}
+	s.log.Infof("Installed SGLang %s", version)
+	return nil
+}
@@ -0,0 +1,33 @@
+package sglang
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOfflinePipInstallArgs(t *testing.T) {
+	tests := []struct {
+		name         string
+		dataParallel bool
+		expected     []string
+	}{
+		{
+			name:     "single server",
+			expected: []string{"--no-index", "--find-links", "/bundle/sglang/wheels", "sglang==0.5.2"},
+		},
+		{
+			name:         "data parallel",
+			dataParallel: true,
+			expected:     []string{"--no-index", "--find-links", "/bundle/sglang/wheels", "sglang==0.5.2", "sglang-router"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := offlinePipInstallArgs("/bundle/sglang/wheels", "0.5.2", tt.dataParallel)
+			if !slices.Equal(args, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package sglang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/model-runner/pkg/diskusage"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/inference/platform"
+	"github.com/docker/model-runner/pkg/inference/toolcalls"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+const (
+	// Name is the backend name.
+	Name = "sglang"
+	// envDir is the SGLang environment provided by the model runner image.
+	envDir = "/opt/sglang-env"
+)
+
+var ErrorNotFound = inference.NotInstalledError("SGLang not found")
+
+// sglang is the SGLang-based backend implementation.
+type sglang struct {
+	// log is the associated logger.
+	log logging.Logger
+	// modelManager is the shared model manager.
+	modelManager *models.Manager
+	// serverLog is the logger to use for the SGLang server processes.
+	serverLog logging.Logger
+	// config is the configuration for the SGLang backend.
+	config *Config
+	// status is the state in which the SGLang backend is in.
+	status string
+}
+
+// New creates a new SGLang-based backend.
+func New(log logging.Logger, modelManager *models.Manager, serverLog logging.Logger, conf *Config) (inference.Backend, error) {
+	// If no config is provided, use the default configuration
+	if conf == nil {
+		conf = NewDefaultSGLangConfig()
+	}
+
+	return &sglang{
+		log:          log,
+		modelManager: modelManager,
+		serverLog:    serverLog,
+		config:       conf,
+		status:       "not installed",
+	}, nil
+}
+
+// Name implements inference.Backend.Name.
+func (s *sglang) Name() string {
+	return Name
+}
+
+// UsesExternalModelManagement implements
+// inference.Backend.UsesExternalModelManagement.
+func (s *sglang) UsesExternalModelManagement() bool {
+	return false
+}
+
+// Install implements inference.Backend.Install.
+func (s *sglang) Install(ctx context.Context, _ *http.Client) error {
+	if !platform.SupportsSGLang() {
+		return errors.New("not implemented")
+	}
+
+	// The offline bundle's SGLang is preferred over the environment provided
+	// by the model runner image.
+	if wheelsDir, version, ok := s.config.OfflineBundle.SGLangWheels(); ok {
+		if err := s.installFromOfflineBundle(ctx, wheelsDir, version); err != nil {
+			s.status = fmt.Sprintf("failed to install sglang from offline bundle: %v", err)
+			return err
+		}
+	}
+
+	if _, err := os.Stat(pythonPath()); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to check SGLang environment: %w", err)
+		}
+		s.status = ErrorNotFound.Error()
+		return ErrorNotFound
+	}
+
+	// Read the SGLang version recorded when the environment was created.
+	version, err := backends.ReadEnvFile(envDir, backends.EnvVersionFile)
+	if err != nil {
+		s.log.Warnf("could not get sglang version: %v", err)
+		s.status = "running sglang version: unknown"
+	} else {
+		s.status = fmt.Sprintf("running sglang version: %s", version)
+	}
+
+	return nil
+}
+
+// Run implements inference.Backend.Run. SGLang only serves HTTP over TCP, so it
+// listens on a free loopback port that is exposed on the runner socket.
+func (s *sglang) Run(ctx context.Context, socket, model string, modelRef string, mode inference.BackendMode, backendConfig *inference.BackendConfiguration) error {
+	if !platform.SupportsSGLang() {
+		s.log.Warn("SGLang backend is not yet supported")
+		return errors.New("not implemented")
+	}
+
+	bundle, err := s.modelManager.GetBundle(model)
+	if err != nil {
+		return fmt.Errorf("failed to get model: %w", err)
+	}
+
+	address, err := freeLocalAddress()
+	if err != nil {
+		return fmt.Errorf("failed to allocate SGLang server address: %w", err)
+	}
+
+	args, err := s.config.GetArgs(bundle, address, mode, backendConfig)
+	if err != nil {
+		return fmt.Errorf("failed to get SGLang arguments: %w", err)
+	}
+	args = append(args, "--served-model-name", modelRef)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	socketErrors := make(chan error, 1)
+	go func() {
+		err := serveSocket(runCtx, socket, address)
+		if err != nil {
+			// Without the socket the runner is unreachable, so stop SGLang.
+			cancel()
+		}
+		socketErrors <- err
+	}()
+
+	err = backends.RunBackend(runCtx, backends.RunnerConfig{
+		BackendName:     "SGLang",
+		Socket:          socket,
+		BinaryPath:      pythonPath(),
+		SandboxPath:     filepath.Join(envDir, "bin"),
+		SandboxConfig:   "",
+		Args:            args,
+		Devices:         backendConfig.AssignedDevices(),
+		Logger:          s.log,
+		ServerLogWriter: s.serverLog.Writer(),
+	})
+	cancel()
+	if socketErr := <-socketErrors; socketErr != nil {
+		return fmt.Errorf("unable to expose SGLang on runner socket: %w", socketErr)
+	}
+	return err
+}
+
+// Status implements inference.Backend.Status.
+func (s *sglang) Status() string {
+	return s.status
+}
+
+// GetDiskUsage implements inference.Backend.GetDiskUsage.
+func (s *sglang) GetDiskUsage() (int64, error) {
+	size, err := diskusage.Size(envDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("error while getting store size: %w", err)
+	}
+	return size, nil
+}
+
+// GetRequiredMemoryForModel implements
+// inference.Backend.GetRequiredMemoryForModel. Every data-parallel worker
+// holds a full copy of the model.
+func (s *sglang) GetRequiredMemoryForModel(_ context.Context, model string, _ *inference.BackendConfiguration) (inference.RequiredMemory, error) {
+	if !platform.SupportsSGLang() {
+		return inference.RequiredMemory{}, errors.New("not implemented")
+	}
+
+	vram := uint64(1)
+	if weights, err := s.weightsSize(model); err != nil {
+		s.log.Warnf("Could not estimate VRAM for model %s: %v", model, err)
+	} else {
+		vram = backends.EstimateFromWeights(weights, backends.WeightsOverheadFactor*float64(max(1, s.config.DataParallelSize)))
+	}
+
+	return inference.RequiredMemory{
+		RAM:  1,
+		VRAM: vram,
+	}, nil
+}
+
+// TokenizerFormat implements inference.TokenizerBackend.TokenizerFormat.
+func (s *sglang) TokenizerFormat() inference.TokenizerFormat {
+	return inference.TokenizerFormatSGLang
+}
+
+// ParsesToolCalls implements inference.ToolCallBackend.ParsesToolCalls.
+// SGLang only parses tool calls with a tool call parser, which is selected for
+// the formats that it supports.
+func (s *sglang) ParsesToolCalls(model string, config *inference.BackendConfiguration) bool {
+	if toolcalls.HasParser(config) {
+		return true
+	}
+	bundle, err := s.modelManager.GetBundle(model)
+	if err != nil {
+		return false
+	}
+	return toolcalls.SGLangParser(bundle) != ""
+}
+
+// weightsSize returns the total size of a model's weights.
+func (s *sglang) weightsSize(model string) (int64, error) {
+	mdl, err := s.modelManager.GetLocal(model)
+	if err != nil {
+		return 0, err
+	}
+	return backends.WeightsSize(mdl)
+}
+
+// pythonPath returns the path to the Python interpreter of the SGLang
+// environment.
+func pythonPath() string {
+	return filepath.Join(envDir, "bin", "python3")
+}
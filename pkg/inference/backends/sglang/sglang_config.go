@@ -0,0 +1,128 @@
+package sglang
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/offline"
+	"github.com/docker/model-runner/pkg/inference/toolcalls"
+)
+
+// grammarBackendFlag is the SGLang flag that selects the grammar backend of
+// structured outputs.
+const grammarBackendFlag = "--grammar-backend"
+
+// Config is the configuration for the SGLang backend.
+type Config struct {
+	// Args are the base arguments that are always included.
+	Args []string
+	// DataParallelSize is the number of SGLang worker processes to launch
+	// behind SGLang's data-parallel router, each serving a full copy of the
+	// model on its own GPU. Values below 2 launch a single server without the
+	// router.
+	DataParallelSize int
+	// OfflineBundle is the offline bundle whose SGLang wheels, if any, the
+	// SGLang environment is created from without network access.
+	OfflineBundle *offline.Bundle
+}
+
+// NewDefaultSGLangConfig creates a new SGLang configuration with default
+// values.
+func NewDefaultSGLangConfig() *Config {
+	return &Config{
+		Args: []string{},
+	}
+}
+
+// GetArgs implements BackendConfig.GetArgs. Since SGLang can't listen on a Unix
+// socket, address is the local TCP address that the server binds to; the
+// backend exposes it on the runner socket.
+func (c *Config) GetArgs(bundle types.ModelBundle, address string, mode inference.BackendMode, config *inference.BackendConfiguration) ([]string, error) {
+	safetensorsPath := bundle.SafetensorsPath()
+	if safetensorsPath == "" {
+		return nil, fmt.Errorf("safetensors path required by SGLang backend")
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SGLang server address %q: %w", address, err)
+	}
+
+	// Launch the data-parallel router, which starts and manages the workers,
+	// when more than one worker is requested.
+	module := "sglang.launch_server"
+	if c.DataParallelSize > 1 {
+		module = "sglang_router.launch_server"
+	}
+	args := append([]string{"-m", module}, c.Args...)
+	args = append(args,
+		"--model-path", filepath.Dir(safetensorsPath),
+		"--host", host,
+		"--port", port,
+	)
+	if c.DataParallelSize > 1 {
+		args = append(args, "--dp-size", strconv.Itoa(c.DataParallelSize))
+	}
+
+	switch mode {
+	case inference.BackendModeCompletion:
+		// Parse the tool calls of the model in its format, unless a parser
+		// has been configured
+		if parser := toolcalls.SGLangParser(bundle); parser != "" && !toolcalls.HasParser(config) {
+			args = append(args, toolcalls.ParserFlag, parser)
+		}
+		// Enforce the JSON schemas of structured outputs with xgrammar,
+		// unless a grammar backend has been configured.
+		if !hasFlag(config, grammarBackendFlag) {
+			args = append(args, grammarBackendFlag, "xgrammar")
+		}
+	case inference.BackendModeEmbedding:
+		args = append(args, "--is-embedding")
+	default:
+		return nil, fmt.Errorf("unsupported backend mode %q", mode)
+	}
+
+	// Add the context length if specified in model config or backend config
+	if contextLength := getContextLength(bundle.RuntimeConfig(), config); contextLength != nil {
+		args = append(args, "--context-length", strconv.FormatUint(*contextLength, 10))
+	}
+
+	// Add arguments from backend config
+	if config != nil {
+		args = append(args, config.RuntimeFlags...)
+	}
+
+	return args, nil
+}
+
+// hasFlag returns true if the runtime flags of a backend configuration set a
+// flag.
+func hasFlag(config *inference.BackendConfiguration, flag string) bool {
+	if config == nil {
+		return false
+	}
+	for _, f := range config.RuntimeFlags {
+		if name, _, _ := strings.Cut(f, "="); name == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// getContextLength returns the context length from model config or backend
+// config. Model config takes precedence. Returns nil if neither is specified,
+// in which case SGLang derives it from the model.
+func getContextLength(modelCfg types.Config, backendCfg *inference.BackendConfiguration) *uint64 {
+	if modelCfg.ContextSize != nil {
+		return modelCfg.ContextSize
+	}
+	if backendCfg != nil && backendCfg.ContextSize > 0 {
+		val := uint64(backendCfg.ContextSize)
+		return &val
+	}
+	return nil
+}
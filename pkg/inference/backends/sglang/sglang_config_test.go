@@ -0,0 +1,179 @@
+package sglang
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+type mockModelBundle struct {
+	safetensorsPath string
+	runtimeConfig   types.Config
+}
+
+func (m *mockModelBundle) GGUFPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) SafetensorsPath() string {
+	return m.safetensorsPath
+}
+
+func (m *mockModelBundle) ONNXPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) WhisperPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) DiffusionPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) TensorRTPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) ChatTemplatePath() string {
+	return ""
+}
+
+func (m *mockModelBundle) MMPROJPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) AdapterPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) RuntimeConfig() types.Config {
+	return m.runtimeConfig
+}
+
+func (m *mockModelBundle) RootDir() string {
+	return "/path/to/bundle"
+}
+
+func TestGetArgs(t *testing.T) {
+	contextSize := uint64(8192)
+	tests := []struct {
+		name        string
+		dpSize      int
+		mode        inference.BackendMode
+		bundle      *mockModelBundle
+		config      *inference.BackendConfiguration
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:        "empty safetensors path should error",
+			bundle:      &mockModelBundle{},
+			mode:        inference.BackendModeCompletion,
+			expectError: true,
+		},
+		{
+			name:   "single server",
+			bundle: &mockModelBundle{safetensorsPath: "/models/model/model.safetensors"},
+			mode:   inference.BackendModeCompletion,
+			expected: []string{
+				"-m", "sglang.launch_server",
+				"--model-path", "/models/model",
+				"--host", "127.0.0.1",
+				"--port", "30000",
+				"--grammar-backend", "xgrammar",
+			},
+		},
+		{
+			name:   "configured grammar backend",
+			bundle: &mockModelBundle{safetensorsPath: "/models/model/model.safetensors"},
+			mode:   inference.BackendModeCompletion,
+			config: &inference.BackendConfiguration{RuntimeFlags: []string{"--grammar-backend=outlines"}},
+			expected: []string{
+				"-m", "sglang.launch_server",
+				"--model-path", "/models/model",
+				"--host", "127.0.0.1",
+				"--port", "30000",
+				"--grammar-backend=outlines",
+			},
+		},
+		{
+			name:   "data-parallel router for embeddings",
+			dpSize: 4,
+			bundle: &mockModelBundle{safetensorsPath: "/models/model/model.safetensors"},
+			mode:   inference.BackendModeEmbedding,
+			config: &inference.BackendConfiguration{ContextSize: 4096, RuntimeFlags: []string{"--mem-fraction-static", "0.8"}},
+			expected: []string{
+				"-m", "sglang_router.launch_server",
+				"--model-path", "/models/model",
+				"--host", "127.0.0.1",
+				"--port", "30000",
+				"--dp-size", "4",
+				"--is-embedding",
+				"--context-length", "4096",
+				"--mem-fraction-static", "0.8",
+			},
+		},
+		{
+			name: "model context size takes precedence",
+			bundle: &mockModelBundle{
+				safetensorsPath: "/models/model/model.safetensors",
+				runtimeConfig:   types.Config{ContextSize: &contextSize},
+			},
+			mode:   inference.BackendModeCompletion,
+			config: &inference.BackendConfiguration{ContextSize: 4096},
+			expected: []string{
+				"-m", "sglang.launch_server",
+				"--model-path", "/models/model",
+				"--host", "127.0.0.1",
+				"--port", "30000",
+				"--grammar-backend", "xgrammar",
+				"--context-length", "8192",
+			},
+		},
+		{
+			name: "tool call parser of architecture",
+			bundle: &mockModelBundle{
+				safetensorsPath: "/models/model/model.safetensors",
+				runtimeConfig:   types.Config{Architecture: "DeepseekV3ForCausalLM"},
+			},
+			mode: inference.BackendModeCompletion,
+			expected: []string{
+				"-m", "sglang.launch_server",
+				"--model-path", "/models/model",
+				"--host", "127.0.0.1",
+				"--port", "30000",
+				"--tool-call-parser", "deepseekv3",
+				"--grammar-backend", "xgrammar",
+			},
+		},
+		{
+			name:        "reranking is unsupported",
+			bundle:      &mockModelBundle{safetensorsPath: "/models/model/model.safetensors"},
+			mode:        inference.BackendModeReranking,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewDefaultSGLangConfig()
+			config.DataParallelSize = tt.dpSize
+			args, err := config.GetArgs(tt.bundle, "127.0.0.1:30000", tt.mode, tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(args, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, args)
+			}
+		})
+	}
+}
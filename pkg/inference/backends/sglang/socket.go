@@ -0,0 +1,64 @@
+package sglang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// readyPollInterval is the interval at which the SGLang server is polled
+// until it accepts connections.
+const readyPollInterval = 500 * time.Millisecond
+
+// freeLocalAddress returns a loopback TCP address that's currently unused.
+func freeLocalAddress() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer ln.Close()
+	return ln.Addr().String(), nil
+}
+
+// serveSocket exposes the HTTP server at address on a Unix socket until ctx is
+// cancelled. The socket is only created once the server accepts connections,
+// so that the runner reports ready when SGLang is.
+func serveSocket(ctx context.Context, socket, address string) error {
+	for {
+		conn, err := net.DialTimeout("tcp", address, readyPollInterval)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(readyPollInterval):
+		}
+	}
+
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", socket, err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: address})
+	// Flush immediately so that streamed completions aren't buffered.
+	proxy.FlushInterval = -1
+	server := &http.Server{
+		Handler:           proxy,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package tei
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// ErrInvalidRerankRequest indicates that a rerank request can't be served.
+var ErrInvalidRerankRequest = errors.New("invalid rerank request")
+
+// rerankRequest is a rerank request, in the format accepted by llama.cpp and
+// other OpenAI-compatible servers.
+type rerankRequest struct {
+	Model           string            `json:"model"`
+	Query           string            `json:"query"`
+	Documents       []json.RawMessage `json:"documents"`
+	TopN            *int              `json:"top_n,omitempty"`
+	ReturnDocuments bool              `json:"return_documents,omitempty"`
+}
+
+// serverRerankRequest is a TEI rerank request.
+type serverRerankRequest struct {
+	Query string   `json:"query"`
+	Texts []string `json:"texts"`
+}
+
+// serverRank is an entry of a TEI rerank response.
+type serverRank struct {
+	Index int     `json:"index"`
+	Score float64 `json:"score"`
+}
+
+// rerankDocument is a document included in a rerank response.
+type rerankDocument struct {
+	Text string `json:"text"`
+}
+
+// rerankResult is an entry of a rerank response.
+type rerankResult struct {
+	Index          int             `json:"index"`
+	RelevanceScore float64         `json:"relevance_score"`
+	Document       *rerankDocument `json:"document,omitempty"`
+}
+
+// rerankResponse is a rerank response.
+type rerankResponse struct {
+	Model   string         `json:"model"`
+	Object  string         `json:"object"`
+	Results []rerankResult `json:"results"`
+}
+
+// documentText returns the text of a document, which is either a string or an
+// object with a text field.
+func documentText(document json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(document, &text); err == nil {
+		return text, nil
+	}
+	var object rerankDocument
+	if err := json.Unmarshal(document, &object); err != nil {
+		return "", errors.New("documents must be strings or objects with a text field")
+	}
+	return object.Text, nil
+}
+
+// translateRerankRequest validates a rerank request and translates it for
+// TEI, returning the parsed request for translating the response.
+func translateRerankRequest(body []byte) (*rerankRequest, []string, []byte, error) {
+	var request rerankRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %w", ErrInvalidRerankRequest, err)
+	}
+	if request.Query == "" {
+		return nil, nil, nil, fmt.Errorf("%w: query is required", ErrInvalidRerankRequest)
+	}
+	if len(request.Documents) == 0 {
+		return nil, nil, nil, fmt.Errorf("%w: documents are required", ErrInvalidRerankRequest)
+	}
+	if request.TopN != nil && *request.TopN < 1 {
+		return nil, nil, nil, fmt.Errorf("%w: top_n must be positive", ErrInvalidRerankRequest)
+	}
+	texts := make([]string, len(request.Documents))
+	for i, document := range request.Documents {
+		text, err := documentText(document)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%w: %w", ErrInvalidRerankRequest, err)
+		}
+		texts[i] = text
+	}
+	translated, err := json.Marshal(serverRerankRequest{Query: request.Query, Texts: texts})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return &request, texts, translated, nil
+}
+
+// translateRerankResponse translates a TEI rerank response, ordering the
+// results by relevance and limiting them to the top_n of the request.
+func translateRerankResponse(request *rerankRequest, texts []string, body []byte) ([]byte, error) {
+	var ranks []serverRank
+	if err := json.Unmarshal(body, &ranks); err != nil {
+		return nil, fmt.Errorf("invalid TEI rerank response: %w", err)
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		return ranks[i].Score > ranks[j].Score
+	})
+	if request.TopN != nil && *request.TopN < len(ranks) {
+		ranks = ranks[:*request.TopN]
+	}
+
+	response := rerankResponse{
+		Model:   request.Model,
+		Object:  "list",
+		Results: make([]rerankResult, len(ranks)),
+	}
+	for i, rank := range ranks {
+		if rank.Index < 0 || rank.Index >= len(texts) {
+			return nil, fmt.Errorf("invalid TEI rerank response: index %d out of range", rank.Index)
+		}
+		response.Results[i] = rerankResult{Index: rank.Index, RelevanceScore: rank.Score}
+		if request.ReturnDocuments {
+			response.Results[i].Document = &rerankDocument{Text: texts[rank.Index]}
+		}
+	}
+	return json.Marshal(response)
+}
+
+// proxy serves the OpenAI API on a runner socket, forwarding requests to TEI
+// and translating rerank requests.
+type proxy struct {
+	// log is the associated logger.
+	log logging.Logger
+	// upstream is the base URL of TEI.
+	upstream string
+	// client is the client used to reach TEI.
+	client *http.Client
+	// mux routes requests to their handlers.
+	mux *http.ServeMux
+}
+
+// newProxy creates a new proxy targeting TEI at the specified address.
+func newProxy(log logging.Logger, address string, models []string) (*proxy, error) {
+	upstream, err := url.Parse("http://" + address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid text-embeddings-router address: %w", err)
+	}
+	p := &proxy{
+		log:      log,
+		upstream: upstream.String(),
+		client:   &http.Client{},
+		mux:      http.NewServeMux(),
+	}
+	p.mux.HandleFunc("GET /v1/models", backends.ModelsHandler(address, models))
+	p.mux.HandleFunc("POST /rerank", p.handleRerank)
+	p.mux.HandleFunc("POST /v1/rerank", p.handleRerank)
+	p.mux.Handle("/", httputil.NewSingleHostReverseProxy(upstream))
+	return p, nil
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// handleRerank translates a rerank request and forwards it to TEI.
+func (p *proxy) handleRerank(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusInternalServerError)
+		return
+	}
+	request, texts, translated, err := translateRerankRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, p.upstream+"/rerank", bytes.NewReader(translated))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("text-embeddings-router request failed: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read rerank response: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	// Forward errors as-is.
+	if resp.StatusCode != http.StatusOK {
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		w.WriteHeader(resp.StatusCode)
+		w.Write(responseBody)
+		return
+	}
+
+	translatedResponse, err := translateRerankResponse(request, texts, responseBody)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(translatedResponse); err != nil {
+		p.log.Warnf("Failed to forward rerank response: %v", err)
+	}
+}
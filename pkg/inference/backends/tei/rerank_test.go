@@ -0,0 +1,117 @@
+package tei
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTranslateRerankRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "string documents",
+			body:     `{"model":"ai/reranker","query":"whales","documents":["krill","plankton"],"top_n":1}`,
+			expected: `{"query":"whales","texts":["krill","plankton"]}`,
+		},
+		{
+			name:     "object documents",
+			body:     `{"model":"ai/reranker","query":"whales","documents":[{"text":"krill"}]}`,
+			expected: `{"query":"whales","texts":["krill"]}`,
+		},
+		{
+			name:        "missing query",
+			body:        `{"model":"ai/reranker","documents":["krill"]}`,
+			expectError: true,
+		},
+		{
+			name:        "missing documents",
+			body:        `{"model":"ai/reranker","query":"whales"}`,
+			expectError: true,
+		},
+		{
+			name:        "invalid top_n",
+			body:        `{"model":"ai/reranker","query":"whales","documents":["krill"],"top_n":0}`,
+			expectError: true,
+		},
+		{
+			name:        "invalid document",
+			body:        `{"model":"ai/reranker","query":"whales","documents":[42]}`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, translated, err := translateRerankRequest([]byte(tt.body))
+			if tt.expectError {
+				if !errors.Is(err, ErrInvalidRerankRequest) {
+					t.Fatalf("expected ErrInvalidRerankRequest, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(translated) != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, translated)
+			}
+		})
+	}
+}
+
+func TestTranslateRerankResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		request     string
+		response    string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "sorted by relevance",
+			request:  `{"model":"ai/reranker","query":"whales","documents":["krill","plankton","ships"]}`,
+			response: `[{"index":2,"score":0.1},{"index":0,"score":0.9},{"index":1,"score":0.5}]`,
+			expected: `{"model":"ai/reranker","object":"list","results":[` +
+				`{"index":0,"relevance_score":0.9},{"index":1,"relevance_score":0.5},{"index":2,"relevance_score":0.1}]}`,
+		},
+		{
+			name:     "top_n with documents",
+			request:  `{"model":"ai/reranker","query":"whales","documents":["krill","plankton"],"top_n":1,"return_documents":true}`,
+			response: `[{"index":1,"score":0.7},{"index":0,"score":0.2}]`,
+			expected: `{"model":"ai/reranker","object":"list","results":[` +
+				`{"index":1,"relevance_score":0.7,"document":{"text":"plankton"}}]}`,
+		},
+		{
+			name:        "index out of range",
+			request:     `{"model":"ai/reranker","query":"whales","documents":["krill"]}`,
+			response:    `[{"index":3,"score":0.7}]`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, texts, _, err := translateRerankRequest([]byte(tt.request))
+			if err != nil {
+				t.Fatalf("unexpected request error: %v", err)
+			}
+			translated, err := translateRerankResponse(request, texts, []byte(tt.response))
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected error, got %s", translated)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(translated) != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, translated)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package tei
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+const (
+	// Name is the backend name.
+	Name = "tei"
+	// binaryName is the name of the text-embeddings-inference server binary.
+	binaryName = "text-embeddings-router"
+)
+
+var ErrorNotFound = inference.NotInstalledError("text-embeddings-router binary not found")
+
+// tei is the text-embeddings-inference-based backend implementation. It only
+// serves embedding and reranking models, which TEI batches dynamically.
+type tei struct {
+	// log is the associated logger.
+	log logging.Logger
+	// modelManager is the shared model manager.
+	modelManager *models.Manager
+	// serverLog is the logger to use for the text-embeddings-router process.
+	serverLog logging.Logger
+	// config is the configuration for the TEI backend.
+	config *Config
+	// status is the state in which the TEI backend is in.
+	status string
+	// binaryPath is the path to the text-embeddings-router binary in use.
+	binaryPath string
+}
+
+// New creates a new text-embeddings-inference-based backend.
+func New(log logging.Logger, modelManager *models.Manager, serverLog logging.Logger, conf *Config) (inference.Backend, error) {
+	// If no config is provided, use the default configuration
+	if conf == nil {
+		conf = NewDefaultTEIConfig()
+	}
+
+	return &tei{
+		log:          log,
+		modelManager: modelManager,
+		serverLog:    serverLog,
+		config:       conf,
+		status:       "not installed",
+	}, nil
+}
+
+// Name implements inference.Backend.Name.
+func (t *tei) Name() string {
+	return Name
+}
+
+// UsesExternalModelManagement implements
+// inference.Backend.UsesExternalModelManagement.
+func (t *tei) UsesExternalModelManagement() bool {
+	return false
+}
+
+// Install implements inference.Backend.Install. text-embeddings-router isn't
+// downloaded by the model runner, so it must either be configured or be
+// available on the PATH.
+func (t *tei) Install(_ context.Context, _ *http.Client) error {
+	binaryPath := t.config.BinaryPath
+	if binaryPath == "" {
+		var err error
+		if binaryPath, err = exec.LookPath(binaryName); err != nil {
+			t.status = ErrorNotFound.Error()
+			return ErrorNotFound
+		}
+	} else if _, err := os.Stat(binaryPath); err != nil {
+		t.status = ErrorNotFound.Error()
+		return fmt.Errorf("%w: %w", ErrorNotFound, err)
+	}
+	t.binaryPath = binaryPath
+	t.status = fmt.Sprintf("running text-embeddings-inference (%s)", binaryPath)
+	return nil
+}
+
+// Run implements inference.Backend.Run.
+func (t *tei) Run(ctx context.Context, socket, model string, modelRef string, mode inference.BackendMode, backendConfig *inference.BackendConfiguration) error {
+	bundle, err := t.modelManager.GetBundle(model)
+	if err != nil {
+		return fmt.Errorf("failed to get model: %w", err)
+	}
+
+	// text-embeddings-router only listens on TCP, so bind it to a free
+	// loopback port and proxy the runner socket to it.
+	address, err := backends.LoopbackAddress()
+	if err != nil {
+		return fmt.Errorf("failed to allocate text-embeddings-router address: %w", err)
+	}
+
+	args, err := t.config.GetArgs(bundle, address, mode, backendConfig)
+	if err != nil {
+		return fmt.Errorf("failed to get TEI arguments: %w", err)
+	}
+
+	p, err := newProxy(t.log, address, []string{model, modelRef})
+	if err != nil {
+		return err
+	}
+	stop, err := backends.ServeSocket(socket, p, t.log)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	return backends.RunBackend(ctx, backends.RunnerConfig{
+		BackendName:     "TEI",
+		BinaryPath:      t.binaryPath,
+		SandboxPath:     filepath.Dir(t.binaryPath),
+		SandboxConfig:   "",
+		Args:            args,
+		Devices:         backendConfig.AssignedDevices(),
+		Logger:          t.log,
+		ServerLogWriter: t.serverLog.Writer(),
+	})
+}
+
+func (t *tei) Status() string {
+	return t.status
+}
+
+// GetDiskUsage implements inference.Backend.GetDiskUsage.
+// text-embeddings-router isn't managed by the model runner, so it doesn't
+// count towards its disk usage.
+func (t *tei) GetDiskUsage() (int64, error) {
+	return 0, nil
+}
+
+// GetRequiredMemoryForModel implements
+// inference.Backend.GetRequiredMemoryForModel.
+func (t *tei) GetRequiredMemoryForModel(_ context.Context, model string, _ *inference.BackendConfiguration) (inference.RequiredMemory, error) {
+	// Estimate VRAM from the size of the model weights, falling back to
+	// unknown if they can't be inspected.
+	vram := uint64(1)
+	if weights, err := t.weightsSize(model); err != nil {
+		t.log.Warnf("Could not estimate VRAM for model %s: %v", model, err)
+	} else {
+		vram = backends.EstimateFromWeights(weights, backends.WeightsOverheadFactor)
+	}
+
+	return inference.RequiredMemory{
+		RAM:  1,
+		VRAM: vram,
+	}, nil
+}
+
+// weightsSize returns the total size of a model's weights.
+func (t *tei) weightsSize(model string) (int64, error) {
+	mdl, err := t.modelManager.GetLocal(model)
+	if err != nil {
+		return 0, err
+	}
+	return backends.WeightsSize(mdl)
+}
@@ -0,0 +1,99 @@
+package tei
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// Config is the configuration for the TEI backend.
+type Config struct {
+	// Args are the base arguments that are always included.
+	Args []string
+	// BinaryPath is the path to the text-embeddings-router binary. If empty,
+	// the binary is looked up on the PATH.
+	BinaryPath string
+	// MaxBatchTokens is the maximum number of tokens in a dynamic batch. If
+	// zero, the TEI default is used.
+	MaxBatchTokens int
+	// MaxConcurrentRequests is the maximum number of requests that are
+	// batched concurrently, after which requests are rejected. If zero, the
+	// TEI default is used.
+	MaxConcurrentRequests int
+}
+
+// NewDefaultTEIConfig creates a new TEI Config with default values.
+func NewDefaultTEIConfig() *Config {
+	return &Config{
+		Args: []string{},
+	}
+}
+
+// poolingMethods maps embedding pooling methods to their TEI names.
+var poolingMethods = map[inference.EmbeddingPooling]string{
+	inference.EmbeddingPoolingMean: "mean",
+	inference.EmbeddingPoolingCLS:  "cls",
+	inference.EmbeddingPoolingLast: "last-token",
+}
+
+// GetArgs implements BackendConfig.GetArgs. TEI can't listen on a Unix domain
+// socket, so socket is the loopback TCP address that the server should listen
+// on, which the backend proxies to.
+func (c *Config) GetArgs(bundle types.ModelBundle, socket string, mode inference.BackendMode, config *inference.BackendConfiguration) ([]string, error) {
+	// Start with the arguments from Config
+	args := append([]string{}, c.Args...)
+
+	safetensorsPath := bundle.SafetensorsPath()
+	if safetensorsPath == "" {
+		return nil, fmt.Errorf("safetensors file required by TEI backend")
+	}
+
+	host, port, err := net.SplitHostPort(socket)
+	if err != nil {
+		return nil, fmt.Errorf("invalid text-embeddings-router address %q: %w", socket, err)
+	}
+
+	// TEI loads the model from its directory, which also contains the
+	// tokenizer and model config.
+	args = append(args,
+		"--model-id", filepath.Dir(safetensorsPath),
+		"--hostname", host,
+		"--port", port,
+	)
+
+	// TEI infers whether a model embeds or reranks from its architecture, so
+	// only embedding options need to be passed.
+	switch mode {
+	case inference.BackendModeEmbedding:
+		if config != nil && config.Embeddings != nil {
+			if config.Embeddings.Pooling != "" {
+				args = append(args, "--pooling", poolingMethods[config.Embeddings.Pooling])
+			}
+			if config.Embeddings.Normalize != nil && !*config.Embeddings.Normalize {
+				return nil, fmt.Errorf("disabling embedding normalization is not supported by TEI backend")
+			}
+		}
+	case inference.BackendModeReranking:
+	default:
+		return nil, fmt.Errorf("%s mode not supported by TEI backend", mode)
+	}
+
+	// Add dynamic batching limits
+	if c.MaxBatchTokens > 0 {
+		args = append(args, "--max-batch-tokens", strconv.Itoa(c.MaxBatchTokens))
+	}
+	if c.MaxConcurrentRequests > 0 {
+		args = append(args, "--max-concurrent-requests", strconv.Itoa(c.MaxConcurrentRequests))
+	}
+
+	// Add arguments from backend config
+	if config != nil {
+		args = append(args, config.RuntimeFlags...)
+	}
+
+	return args, nil
+}
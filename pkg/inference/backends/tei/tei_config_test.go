@@ -0,0 +1,153 @@
+package tei
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+type mockModelBundle struct {
+	safetensorsPath string
+}
+
+func (m *mockModelBundle) GGUFPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) SafetensorsPath() string {
+	return m.safetensorsPath
+}
+
+func (m *mockModelBundle) ONNXPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) WhisperPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) DiffusionPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) TensorRTPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) ChatTemplatePath() string {
+	return ""
+}
+
+func (m *mockModelBundle) MMPROJPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) AdapterPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) RuntimeConfig() types.Config {
+	return types.Config{}
+}
+
+func (m *mockModelBundle) RootDir() string {
+	return "/path/to/bundle"
+}
+
+func TestGetArgs(t *testing.T) {
+	normalize := false
+	tests := []struct {
+		name        string
+		mode        inference.BackendMode
+		config      *inference.BackendConfiguration
+		teiConfig   *Config
+		bundle      *mockModelBundle
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:        "empty safetensors path should error",
+			mode:        inference.BackendModeEmbedding,
+			bundle:      &mockModelBundle{},
+			expectError: true,
+		},
+		{
+			name:        "completion mode should error",
+			mode:        inference.BackendModeCompletion,
+			bundle:      &mockModelBundle{safetensorsPath: "/path/to/model/model.safetensors"},
+			expectError: true,
+		},
+		{
+			name:   "embedding mode",
+			mode:   inference.BackendModeEmbedding,
+			bundle: &mockModelBundle{safetensorsPath: "/path/to/model/model.safetensors"},
+			expected: []string{
+				"--model-id", "/path/to/model",
+				"--hostname", "127.0.0.1",
+				"--port", "8080",
+			},
+		},
+		{
+			name:   "embedding mode with pooling and batching limits",
+			mode:   inference.BackendModeEmbedding,
+			bundle: &mockModelBundle{safetensorsPath: "/path/to/model/model.safetensors"},
+			config: &inference.BackendConfiguration{
+				Embeddings:   &inference.EmbeddingConfig{Pooling: inference.EmbeddingPoolingLast},
+				RuntimeFlags: []string{"--auto-truncate"},
+			},
+			teiConfig: &Config{MaxBatchTokens: 32768, MaxConcurrentRequests: 64},
+			expected: []string{
+				"--model-id", "/path/to/model",
+				"--hostname", "127.0.0.1",
+				"--port", "8080",
+				"--pooling", "last-token",
+				"--max-batch-tokens", "32768",
+				"--max-concurrent-requests", "64",
+				"--auto-truncate",
+			},
+		},
+		{
+			name:   "disabled normalization should error",
+			mode:   inference.BackendModeEmbedding,
+			bundle: &mockModelBundle{safetensorsPath: "/path/to/model/model.safetensors"},
+			config: &inference.BackendConfiguration{
+				Embeddings: &inference.EmbeddingConfig{Normalize: &normalize},
+			},
+			expectError: true,
+		},
+		{
+			name:   "reranking mode",
+			mode:   inference.BackendModeReranking,
+			bundle: &mockModelBundle{safetensorsPath: "/path/to/reranker/model.safetensors"},
+			expected: []string{
+				"--model-id", "/path/to/reranker",
+				"--hostname", "127.0.0.1",
+				"--port", "8080",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.teiConfig
+			if config == nil {
+				config = NewDefaultTEIConfig()
+			}
+			args, err := config.GetArgs(tt.bundle, "127.0.0.1:8080", tt.mode, tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected error, got args %v", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(args, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package trtllm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// minimumComputeCapability is the oldest GPU architecture supported by
+// TensorRT-LLM (Ampere).
+var minimumComputeCapability = computeCapability{Major: 8, Minor: 0}
+
+// ErrUnsupportedGPU indicates that the host's GPUs can't run TensorRT-LLM
+// engines.
+var ErrUnsupportedGPU = errors.New("no GPU supported by TensorRT-LLM found")
+
+// computeCapability is the compute capability of an NVIDIA GPU, which
+// identifies its architecture. TensorRT engines only run on GPUs with the
+// compute capability they were built for.
+type computeCapability struct {
+	Major int
+	Minor int
+}
+
+// String implements Stringer.String for computeCapability, using the SM
+// notation of TensorRT-LLM.
+func (c computeCapability) String() string {
+	return fmt.Sprintf("sm_%d%d", c.Major, c.Minor)
+}
+
+// less returns true if c is an older architecture than other.
+func (c computeCapability) less(other computeCapability) bool {
+	return c.Major < other.Major || (c.Major == other.Major && c.Minor < other.Minor)
+}
+
+// parseComputeCapabilities parses the output of
+// nvidia-smi --query-gpu=compute_cap --format=csv,noheader and checks that the
+// GPUs can run TensorRT-LLM. Since engines are built for a single
+// architecture, all GPUs must share one.
+func parseComputeCapabilities(output string) (computeCapability, error) {
+	var result computeCapability
+	found := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		major, minor, ok := strings.Cut(line, ".")
+		if !ok {
+			return computeCapability{}, fmt.Errorf("invalid compute capability %q", line)
+		}
+		var c computeCapability
+		var err error
+		if c.Major, err = strconv.Atoi(major); err != nil {
+			return computeCapability{}, fmt.Errorf("invalid compute capability %q: %w", line, err)
+		}
+		if c.Minor, err = strconv.Atoi(minor); err != nil {
+			return computeCapability{}, fmt.Errorf("invalid compute capability %q: %w", line, err)
+		}
+		if c.less(minimumComputeCapability) {
+			return computeCapability{}, fmt.Errorf("%w: %s is older than the minimum of %s", ErrUnsupportedGPU, c, minimumComputeCapability)
+		}
+		if found && c != result {
+			return computeCapability{}, fmt.Errorf("%w: GPUs with mixed architectures (%s and %s)", ErrUnsupportedGPU, result, c)
+		}
+		result, found = c, true
+	}
+	if !found {
+		return computeCapability{}, ErrUnsupportedGPU
+	}
+	return result, nil
+}
+
+// engineConfig is the subset of the config.json written by trtllm-build that
+// describes an engine's shape and limits.
+type engineConfig struct {
+	PretrainedConfig struct {
+		Architecture      string `json:"architecture"`
+		DType             string `json:"dtype"`
+		NumHiddenLayers   int    `json:"num_hidden_layers"`
+		NumAttentionHeads int    `json:"num_attention_heads"`
+		NumKeyValueHeads  int    `json:"num_key_value_heads"`
+		HiddenSize        int    `json:"hidden_size"`
+		HeadSize          int    `json:"head_size"`
+		Mapping           struct {
+			WorldSize int `json:"world_size"`
+			TPSize    int `json:"tp_size"`
+			PPSize    int `json:"pp_size"`
+		} `json:"mapping"`
+		Quantization struct {
+			KVCacheQuantAlgo string `json:"kv_cache_quant_algo"`
+		} `json:"quantization"`
+	} `json:"pretrained_config"`
+	BuildConfig struct {
+		MaxBatchSize int `json:"max_batch_size"`
+		MaxSeqLen    int `json:"max_seq_len"`
+	} `json:"build_config"`
+}
+
+// readEngineConfig reads the config.json in an engine directory.
+func readEngineConfig(engineDir string) (*engineConfig, error) {
+	data, err := os.ReadFile(filepath.Join(engineDir, "config.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read engine config: %w", err)
+	}
+	var config engineConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse engine config: %w", err)
+	}
+	if config.BuildConfig.MaxSeqLen <= 0 {
+		return nil, errors.New("engine config has no max_seq_len")
+	}
+	return &config, nil
+}
+
+// tpSize returns the tensor parallelism that the engine was built with.
+func (c *engineConfig) tpSize() int {
+	return max(c.PretrainedConfig.Mapping.TPSize, 1)
+}
+
+// ppSize returns the pipeline parallelism that the engine was built with.
+func (c *engineConfig) ppSize() int {
+	return max(c.PretrainedConfig.Mapping.PPSize, 1)
+}
+
+// worldSize returns the number of GPUs that the engine runs on.
+func (c *engineConfig) worldSize() int {
+	return max(c.PretrainedConfig.Mapping.WorldSize, c.tpSize()*c.ppSize())
+}
+
+// contextSize returns the context size to use for the engine, which can't
+// exceed the maximum sequence length that it was built with.
+func (c *engineConfig) contextSize(requested int64) (int64, error) {
+	maxSeqLen := int64(c.BuildConfig.MaxSeqLen)
+	if requested <= 0 {
+		return maxSeqLen, nil
+	}
+	if requested > maxSeqLen {
+		return 0, fmt.Errorf("context size %d exceeds the engine's maximum sequence length of %d", requested, maxSeqLen)
+	}
+	return requested, nil
+}
+
+// kvCacheBytesPerToken returns the size of the KV cache entries of a single
+// token across all GPUs.
+func (c *engineConfig) kvCacheBytesPerToken() uint64 {
+	p := c.PretrainedConfig
+	kvHeads := p.NumKeyValueHeads
+	if kvHeads <= 0 {
+		kvHeads = p.NumAttentionHeads
+	}
+	headSize := p.HeadSize
+	if headSize <= 0 && p.NumAttentionHeads > 0 {
+		headSize = p.HiddenSize / p.NumAttentionHeads
+	}
+	bytesPerValue := 2
+	switch strings.ToUpper(p.Quantization.KVCacheQuantAlgo) {
+	case "FP8", "INT8":
+		bytesPerValue = 1
+	default:
+		if p.DType == "float32" {
+			bytesPerValue = 4
+		}
+	}
+	// Keys and values are cached for every layer.
+	return uint64(2 * p.NumHiddenLayers * kvHeads * headSize * bytesPerValue)
+}
+
+// enginesSize returns the total size of the engines in an engine directory.
+func enginesSize(engineDir string) (int64, error) {
+	engines, err := filepath.Glob(filepath.Join(engineDir, "*.engine"))
+	if err != nil {
+		return 0, err
+	}
+	if len(engines) == 0 {
+		return 0, errors.New("no engine files found")
+	}
+	var size int64
+	for _, engine := range engines {
+		info, err := os.Stat(engine)
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
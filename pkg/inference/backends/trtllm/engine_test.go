@@ -0,0 +1,144 @@
+package trtllm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseComputeCapabilities(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		expected    string
+		unsupported bool
+	}{
+		{
+			name:     "single GPU",
+			output:   "8.9\n",
+			expected: "sm_89",
+		},
+		{
+			name:     "multiple matching GPUs",
+			output:   "9.0\n9.0\n",
+			expected: "sm_90",
+		},
+		{
+			name:        "too old",
+			output:      "7.5\n",
+			unsupported: true,
+		},
+		{
+			name:        "mixed architectures",
+			output:      "8.0\n8.6\n",
+			unsupported: true,
+		},
+		{
+			name:        "no GPUs",
+			output:      "",
+			unsupported: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capability, err := parseComputeCapabilities(tt.output)
+			if tt.unsupported {
+				if !errors.Is(err, ErrUnsupportedGPU) {
+					t.Fatalf("expected ErrUnsupportedGPU, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if capability.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, capability)
+			}
+		})
+	}
+
+	if _, err := parseComputeCapabilities("N/A\n"); err == nil || errors.Is(err, ErrUnsupportedGPU) {
+		t.Errorf("expected parse error for invalid output, got %v", err)
+	}
+}
+
+func TestEstimateVRAM(t *testing.T) {
+	// A Llama 3 8B engine with 32 layers, 8 KV heads, and a head size of 128.
+	newEngine := func(kvCacheQuantAlgo string, tpSize int) *engineConfig {
+		var engine engineConfig
+		engine.PretrainedConfig.DType = "bfloat16"
+		engine.PretrainedConfig.NumHiddenLayers = 32
+		engine.PretrainedConfig.NumAttentionHeads = 32
+		engine.PretrainedConfig.NumKeyValueHeads = 8
+		engine.PretrainedConfig.HiddenSize = 4096
+		engine.PretrainedConfig.Mapping.TPSize = tpSize
+		engine.PretrainedConfig.Quantization.KVCacheQuantAlgo = kvCacheQuantAlgo
+		engine.BuildConfig.MaxSeqLen = 8192
+		return &engine
+	}
+
+	tests := []struct {
+		name     string
+		engine   *engineConfig
+		tokens   int64
+		expected uint64
+	}{
+		{
+			name:   "16-bit KV cache",
+			engine: newEngine("", 1),
+			tokens: 8192,
+			// 16GB of engines, 128KiB of KV cache per token, and the overhead
+			// of a single GPU.
+			expected: 16_000_000_000 + 131072*8192 + runtimeOverheadPerGPU,
+		},
+		{
+			name:     "FP8 KV cache",
+			engine:   newEngine("FP8", 1),
+			tokens:   4096,
+			expected: 16_000_000_000 + 65536*4096 + runtimeOverheadPerGPU,
+		},
+		{
+			name:     "tensor parallelism",
+			engine:   newEngine("", 2),
+			tokens:   8192,
+			expected: 16_000_000_000 + 131072*8192 + 2*runtimeOverheadPerGPU,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimateVRAM(tt.engine, 16_000_000_000, tt.tokens); got != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestContextSize(t *testing.T) {
+	var engine engineConfig
+	engine.BuildConfig.MaxSeqLen = 4096
+
+	tests := []struct {
+		requested   int64
+		expected    int64
+		expectError bool
+	}{
+		{requested: 0, expected: 4096},
+		{requested: -1, expected: 4096},
+		{requested: 2048, expected: 2048},
+		{requested: 8192, expectError: true},
+	}
+
+	for _, tt := range tests {
+		size, err := engine.contextSize(tt.requested)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("contextSize(%d): expected error, got %d", tt.requested, size)
+			}
+			continue
+		}
+		if err != nil || size != tt.expected {
+			t.Errorf("contextSize(%d) = %d, %v; expected %d", tt.requested, size, err, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package trtllm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/inference/platform"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+const (
+	// Name is the backend name.
+	Name = "tensorrt-llm"
+	// binaryName is the name of the TensorRT-LLM server binary.
+	binaryName = "trtllm-serve"
+	// runtimeOverheadPerGPU approximates the memory used by the CUDA context
+	// and activations on each GPU.
+	runtimeOverheadPerGPU = 1024 * 1024 * 1024
+)
+
+var ErrorNotFound = inference.NotInstalledError("trtllm-serve binary not found")
+
+// trtllm is the TensorRT-LLM-based backend implementation.
+type trtllm struct {
+	// log is the associated logger.
+	log logging.Logger
+	// modelManager is the shared model manager.
+	modelManager *models.Manager
+	// serverLog is the logger to use for the trtllm-serve process.
+	serverLog logging.Logger
+	// config is the configuration for the TensorRT-LLM backend.
+	config *Config
+	// status is the state in which the TensorRT-LLM backend is in.
+	status string
+	// binaryPath is the path to the trtllm-serve binary in use.
+	binaryPath string
+}
+
+// New creates a new TensorRT-LLM-based backend.
+func New(log logging.Logger, modelManager *models.Manager, serverLog logging.Logger, conf *Config) (inference.Backend, error) {
+	// If no config is provided, use the default configuration
+	if conf == nil {
+		conf = NewDefaultTRTLLMConfig()
+	}
+
+	return &trtllm{
+		log:          log,
+		modelManager: modelManager,
+		serverLog:    serverLog,
+		config:       conf,
+		status:       "not installed",
+	}, nil
+}
+
+// Name implements inference.Backend.Name.
+func (t *trtllm) Name() string {
+	return Name
+}
+
+// UsesExternalModelManagement implements
+// inference.Backend.UsesExternalModelManagement.
+func (t *trtllm) UsesExternalModelManagement() bool {
+	return false
+}
+
+// Install implements inference.Backend.Install. trtllm-serve isn't downloaded
+// by the model runner, so it must either be configured or be available on the
+// PATH. Installation also fails if the host's GPUs can't run TensorRT-LLM.
+func (t *trtllm) Install(ctx context.Context, _ *http.Client) error {
+	if !platform.SupportsTensorRT() {
+		return errors.New("not implemented")
+	}
+
+	binaryPath := t.config.BinaryPath
+	if binaryPath == "" {
+		var err error
+		if binaryPath, err = exec.LookPath(binaryName); err != nil {
+			t.status = ErrorNotFound.Error()
+			return ErrorNotFound
+		}
+	} else if _, err := os.Stat(binaryPath); err != nil {
+		t.status = ErrorNotFound.Error()
+		return fmt.Errorf("%w: %w", ErrorNotFound, err)
+	}
+
+	output, err := exec.CommandContext(ctx, "nvidia-smi", "--query-gpu=compute_cap", "--format=csv,noheader").Output()
+	if err != nil {
+		t.status = ErrUnsupportedGPU.Error()
+		return fmt.Errorf("%w: unable to query GPUs: %w", ErrUnsupportedGPU, err)
+	}
+	capability, err := parseComputeCapabilities(string(output))
+	if err != nil {
+		t.status = err.Error()
+		return err
+	}
+
+	t.binaryPath = binaryPath
+	t.status = fmt.Sprintf("running TensorRT-LLM (%s, GPU architecture: %s)", binaryPath, capability)
+	return nil
+}
+
+// Run implements inference.Backend.Run.
+func (t *trtllm) Run(ctx context.Context, socket, model string, modelRef string, mode inference.BackendMode, backendConfig *inference.BackendConfiguration) error {
+	if !platform.SupportsTensorRT() {
+		t.log.Warn("TensorRT-LLM backend is not yet supported")
+		return errors.New("not implemented")
+	}
+
+	bundle, err := t.modelManager.GetBundle(model)
+	if err != nil {
+		return fmt.Errorf("failed to get model: %w", err)
+	}
+
+	// trtllm-serve only listens on TCP, so bind it to a free loopback port and
+	// proxy the runner socket to it.
+	address, err := backends.LoopbackAddress()
+	if err != nil {
+		return fmt.Errorf("failed to allocate trtllm-serve address: %w", err)
+	}
+
+	args, err := t.config.GetArgs(bundle, address, mode, backendConfig)
+	if err != nil {
+		return fmt.Errorf("failed to get TensorRT-LLM arguments: %w", err)
+	}
+
+	// TensorRT-LLM allocates most of the free GPU memory for the KV cache by
+	// default, so limit it to the amount reserved by the scheduler.
+	engine, err := readEngineConfig(bundle.TensorRTPath())
+	if err != nil {
+		return err
+	}
+	var contextSize int64
+	if backendConfig != nil {
+		contextSize = backendConfig.ContextSize
+	}
+	tokens, err := engine.contextSize(contextSize)
+	if err != nil {
+		return err
+	}
+	optionsDir, err := os.MkdirTemp("", "trtllm-")
+	if err != nil {
+		return fmt.Errorf("failed to create TensorRT-LLM options directory: %w", err)
+	}
+	defer os.RemoveAll(optionsDir)
+	optionsPath := filepath.Join(optionsDir, "options.yaml")
+	options := fmt.Sprintf("kv_cache_config:\n  max_tokens: %d\n", tokens)
+	if err := os.WriteFile(optionsPath, []byte(options), 0o644); err != nil {
+		return fmt.Errorf("failed to write TensorRT-LLM options: %w", err)
+	}
+	args = append(args, "--extra_llm_api_options", optionsPath)
+
+	upstream, err := url.Parse("http://" + address)
+	if err != nil {
+		return fmt.Errorf("invalid trtllm-serve address: %w", err)
+	}
+	stop, err := backends.ServeSocket(socket, httputil.NewSingleHostReverseProxy(upstream), t.log)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	return backends.RunBackend(ctx, backends.RunnerConfig{
+		BackendName:     "TensorRT-LLM",
+		BinaryPath:      t.binaryPath,
+		SandboxPath:     filepath.Dir(t.binaryPath),
+		SandboxConfig:   "",
+		Args:            args,
+		Devices:         backendConfig.AssignedDevices(),
+		Logger:          t.log,
+		ServerLogWriter: t.serverLog.Writer(),
+	})
+}
+
+func (t *trtllm) Status() string {
+	return t.status
+}
+
+// GetDiskUsage implements inference.Backend.GetDiskUsage. trtllm-serve isn't
+// managed by the model runner, so it doesn't count towards its disk usage.
+func (t *trtllm) GetDiskUsage() (int64, error) {
+	return 0, nil
+}
+
+// GetRequiredMemoryForModel implements
+// inference.Backend.GetRequiredMemoryForModel. Memory is estimated from the
+// engines and the KV cache for a full context, as described by the engine
+// config.
+func (t *trtllm) GetRequiredMemoryForModel(_ context.Context, model string, config *inference.BackendConfiguration) (inference.RequiredMemory, error) {
+	if !platform.SupportsTensorRT() {
+		return inference.RequiredMemory{}, errors.New("not implemented")
+	}
+
+	vram, err := t.estimateVRAM(model, config)
+	if err != nil {
+		t.log.Warnf("Could not estimate VRAM for model %s: %v", model, err)
+		return inference.RequiredMemory{RAM: 1, VRAM: 1}, nil
+	}
+	return inference.RequiredMemory{RAM: 1, VRAM: vram}, nil
+}
+
+// estimateVRAM estimates the VRAM needed to serve a model across all of its
+// GPUs.
+func (t *trtllm) estimateVRAM(model string, config *inference.BackendConfiguration) (uint64, error) {
+	bundle, err := t.modelManager.GetBundle(model)
+	if err != nil {
+		return 0, err
+	}
+	engineDir := bundle.TensorRTPath()
+	if engineDir == "" {
+		return 0, errors.New("model has no TensorRT engines")
+	}
+	engine, err := readEngineConfig(engineDir)
+	if err != nil {
+		return 0, err
+	}
+	engines, err := enginesSize(engineDir)
+	if err != nil {
+		return 0, err
+	}
+	var contextSize int64
+	if config != nil {
+		contextSize = config.ContextSize
+	}
+	tokens, err := engine.contextSize(contextSize)
+	if err != nil {
+		return 0, err
+	}
+	return estimateVRAM(engine, engines, tokens), nil
+}
+
+// estimateVRAM estimates the VRAM needed by an engine of the specified size
+// with a KV cache for the specified number of tokens.
+func estimateVRAM(engine *engineConfig, engines int64, tokens int64) uint64 {
+	kvCache := engine.kvCacheBytesPerToken() * uint64(tokens)
+	return uint64(engines) + kvCache + uint64(engine.worldSize())*runtimeOverheadPerGPU
+}
@@ -0,0 +1,87 @@
+package trtllm
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// Config is the configuration for the TensorRT-LLM backend.
+type Config struct {
+	// Args are the base arguments that are always included.
+	Args []string
+	// BinaryPath is the path to the trtllm-serve binary. If empty, the binary
+	// is looked up on the PATH.
+	BinaryPath string
+}
+
+// NewDefaultTRTLLMConfig creates a new TensorRT-LLM Config with default
+// values.
+func NewDefaultTRTLLMConfig() *Config {
+	return &Config{
+		Args: []string{},
+	}
+}
+
+// GetArgs implements BackendConfig.GetArgs. trtllm-serve can't listen on a
+// Unix domain socket, so socket is the loopback TCP address that the server
+// should listen on, which the backend proxies to.
+func (c *Config) GetArgs(bundle types.ModelBundle, socket string, mode inference.BackendMode, config *inference.BackendConfiguration) ([]string, error) {
+	engineDir := bundle.TensorRTPath()
+	if engineDir == "" {
+		return nil, fmt.Errorf("TensorRT engines required by TensorRT-LLM backend")
+	}
+
+	if mode != inference.BackendModeCompletion {
+		return nil, fmt.Errorf("%s mode not supported by TensorRT-LLM backend", mode)
+	}
+
+	engine, err := readEngineConfig(engineDir)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		if _, err := engine.contextSize(config.ContextSize); err != nil {
+			return nil, err
+		}
+	}
+
+	host, port, err := net.SplitHostPort(socket)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trtllm-serve address %q: %w", socket, err)
+	}
+
+	// Start with the engine directory, followed by the arguments from Config
+	args := append([]string{engineDir}, c.Args...)
+	args = append(args,
+		"--backend", "tensorrt",
+		"--tokenizer", tokenizerDir(engineDir),
+		"--host", host,
+		"--port", port,
+		"--tp_size", strconv.Itoa(engine.tpSize()),
+		"--pp_size", strconv.Itoa(engine.ppSize()),
+	)
+
+	// Add arguments from backend config
+	if config != nil {
+		args = append(args, config.RuntimeFlags...)
+	}
+
+	return args, nil
+}
+
+// tokenizerDir returns the directory containing the model's tokenizer, which
+// is packaged either next to the engines or in the directory above them.
+func tokenizerDir(engineDir string) string {
+	for _, dir := range []string{engineDir, filepath.Dir(engineDir)} {
+		if _, err := os.Stat(filepath.Join(dir, "tokenizer_config.json")); err == nil {
+			return dir
+		}
+	}
+	return engineDir
+}
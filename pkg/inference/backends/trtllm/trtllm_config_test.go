@@ -0,0 +1,142 @@
+package trtllm
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+type mockModelBundle struct {
+	tensorrtPath string
+}
+
+func (m *mockModelBundle) GGUFPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) SafetensorsPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) ONNXPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) WhisperPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) DiffusionPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) TensorRTPath() string {
+	return m.tensorrtPath
+}
+
+func (m *mockModelBundle) ChatTemplatePath() string {
+	return ""
+}
+
+func (m *mockModelBundle) MMPROJPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) AdapterPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) RuntimeConfig() types.Config {
+	return types.Config{}
+}
+
+func (m *mockModelBundle) RootDir() string {
+	return "/path/to/bundle"
+}
+
+func TestGetArgs(t *testing.T) {
+	// Create an engine directory for a model with 2-way tensor parallelism,
+	// with the tokenizer packaged above it.
+	modelDir := t.TempDir()
+	engineDir := filepath.Join(modelDir, "engines")
+	if err := os.MkdirAll(engineDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"pretrained_config":{"mapping":{"world_size":2,"tp_size":2,"pp_size":1}},"build_config":{"max_seq_len":4096}}`
+	if err := os.WriteFile(filepath.Join(engineDir, "config.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(modelDir, "tokenizer_config.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		mode        inference.BackendMode
+		config      *inference.BackendConfiguration
+		bundle      *mockModelBundle
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:        "empty engine path should error",
+			mode:        inference.BackendModeCompletion,
+			bundle:      &mockModelBundle{},
+			expectError: true,
+		},
+		{
+			name:        "embedding mode should error",
+			mode:        inference.BackendModeEmbedding,
+			bundle:      &mockModelBundle{tensorrtPath: engineDir},
+			expectError: true,
+		},
+		{
+			name:        "context size beyond engine limit should error",
+			mode:        inference.BackendModeCompletion,
+			bundle:      &mockModelBundle{tensorrtPath: engineDir},
+			config:      &inference.BackendConfiguration{ContextSize: 8192},
+			expectError: true,
+		},
+		{
+			name:   "completion mode with runtime flags",
+			mode:   inference.BackendModeCompletion,
+			bundle: &mockModelBundle{tensorrtPath: engineDir},
+			config: &inference.BackendConfiguration{
+				ContextSize:  2048,
+				RuntimeFlags: []string{"--max_batch_size", "4"},
+			},
+			expected: []string{
+				engineDir,
+				"--backend", "tensorrt",
+				"--tokenizer", modelDir,
+				"--host", "127.0.0.1",
+				"--port", "8000",
+				"--tp_size", "2",
+				"--pp_size", "1",
+				"--max_batch_size", "4",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := NewDefaultTRTLLMConfig().GetArgs(tt.bundle, "127.0.0.1:8000", tt.mode, tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected error, got args %v", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(args, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package vllm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// ErrInvalidGuidedDecodingRequest indicates that a request's structured output
+// parameters couldn't be mapped onto vLLM's guided decoding parameters.
+var ErrInvalidGuidedDecodingRequest = errors.New("invalid structured output request")
+
+// guidedDecodingBackends are the guided decoding backends accepted by vLLM.
+var guidedDecodingBackends = map[string]bool{
+	"auto":               true,
+	"outlines":           true,
+	"xgrammar":           true,
+	"guidance":           true,
+	"lm-format-enforcer": true,
+}
+
+// ValidGuidedDecodingBackend returns true if name is a guided decoding backend
+// understood by vLLM.
+func ValidGuidedDecodingBackend(name string) bool {
+	return guidedDecodingBackends[name]
+}
+
+// TranslateRequest implements inference.RequestTranslator.TranslateRequest. It
+// maps the OpenAI response_format, tools, and tool_choice parameters onto the
+// guided decoding parameters of the vLLM OpenAI server.
+func (v *vLLM) TranslateRequest(_ context.Context, mode inference.BackendMode, _ *inference.BackendConfiguration, body []byte) ([]byte, error) {
+	if mode != inference.BackendModeCompletion {
+		return body, nil
+	}
+	return translateGuidedDecoding(body, v.config.GuidedDecodingBackend)
+}
+
+// translateGuidedDecoding performs the guided decoding translation for a raw
+// chat completion or completion request body. If the request doesn't use any
+// structured output features, the body is returned unmodified.
+func translateGuidedDecoding(body []byte, guidedBackend string) ([]byte, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGuidedDecodingRequest, err)
+	}
+
+	_, hasResponseFormat := request["response_format"]
+	_, hasToolChoice := request["tool_choice"]
+	if !hasResponseFormat && !hasToolChoice {
+		return body, nil
+	}
+
+	guided, strict := false, false
+
+	if raw, ok := request["response_format"]; ok {
+		var format struct {
+			Type       string `json:"type"`
+			JSONSchema *struct {
+				Name   string          `json:"name"`
+				Schema json.RawMessage `json:"schema"`
+				Strict bool            `json:"strict"`
+			} `json:"json_schema"`
+		}
+		if err := json.Unmarshal(raw, &format); err != nil {
+			return nil, fmt.Errorf("%w: response_format: %v", ErrInvalidGuidedDecodingRequest, err)
+		}
+		switch format.Type {
+		case "", "text":
+		case "json_object":
+			request["guided_json"] = json.RawMessage(`{"type":"object"}`)
+			guided = true
+		case "json_schema":
+			if format.JSONSchema == nil || len(format.JSONSchema.Schema) == 0 {
+				return nil, fmt.Errorf("%w: response_format.json_schema.schema is required", ErrInvalidGuidedDecodingRequest)
+			}
+			request["guided_json"] = format.JSONSchema.Schema
+			guided, strict = true, format.JSONSchema.Strict
+		default:
+			return nil, fmt.Errorf("%w: unsupported response_format type %q", ErrInvalidGuidedDecodingRequest, format.Type)
+		}
+		delete(request, "response_format")
+	}
+
+	if raw, ok := request["tool_choice"]; ok {
+		required, err := validateToolChoice(raw, request["tools"])
+		if err != nil {
+			return nil, err
+		}
+		// vLLM constrains tool call arguments through the guided decoding
+		// backend when a tool call is forced.
+		guided = guided || required
+	}
+
+	// Strict schemas are enforced with xgrammar, which supports the full
+	// schemas of strict mode, unless another backend is configured.
+	if strict && guidedBackend == "" {
+		guidedBackend = "xgrammar"
+	}
+	if guided && guidedBackend != "" {
+		backend, err := json.Marshal(guidedBackend)
+		if err != nil {
+			return nil, fmt.Errorf("encoding guided decoding backend: %w", err)
+		}
+		request["guided_decoding_backend"] = backend
+	}
+
+	return json.Marshal(request)
+}
+
+// validateToolChoice checks that tool_choice is consistent with the tools in
+// the request. It returns true if tool_choice forces a tool call.
+func validateToolChoice(rawChoice, rawTools json.RawMessage) (bool, error) {
+	var tools []struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if len(rawTools) > 0 {
+		if err := json.Unmarshal(rawTools, &tools); err != nil {
+			return false, fmt.Errorf("%w: tools: %v", ErrInvalidGuidedDecodingRequest, err)
+		}
+	}
+
+	var choice string
+	if err := json.Unmarshal(rawChoice, &choice); err == nil {
+		switch choice {
+		case "none", "auto":
+			return false, nil
+		case "required":
+			if len(tools) == 0 {
+				return false, fmt.Errorf("%w: tool_choice %q requires tools", ErrInvalidGuidedDecodingRequest, choice)
+			}
+			return true, nil
+		default:
+			return false, fmt.Errorf("%w: unsupported tool_choice %q", ErrInvalidGuidedDecodingRequest, choice)
+		}
+	}
+
+	var named struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(rawChoice, &named); err != nil {
+		return false, fmt.Errorf("%w: tool_choice: %v", ErrInvalidGuidedDecodingRequest, err)
+	}
+	for _, tool := range tools {
+		if tool.Function.Name == named.Function.Name {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("%w: tool_choice references unknown function %q", ErrInvalidGuidedDecodingRequest, named.Function.Name)
+}
@@ -0,0 +1,103 @@
+package vllm
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTranslateGuidedDecoding(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		guidedBackend string
+		expected      string
+		expectError   bool
+	}{
+		{
+			name:     "no structured output",
+			body:     `{"model":"m","messages":[]}`,
+			expected: `{"model":"m","messages":[]}`,
+		},
+		{
+			name:     "text response format",
+			body:     `{"model":"m","response_format":{"type":"text"}}`,
+			expected: `{"model":"m"}`,
+		},
+		{
+			name:     "json object response format",
+			body:     `{"model":"m","response_format":{"type":"json_object"}}`,
+			expected: `{"model":"m","guided_json":{"type":"object"}}`,
+		},
+		{
+			name:          "json schema response format with guided backend",
+			body:          `{"model":"m","response_format":{"type":"json_schema","json_schema":{"name":"x","schema":{"type":"object","properties":{"a":{"type":"string"}}}}}}`,
+			guidedBackend: "xgrammar",
+			expected:      `{"model":"m","guided_json":{"type":"object","properties":{"a":{"type":"string"}}},"guided_decoding_backend":"xgrammar"}`,
+		},
+		{
+			name:     "strict json schema response format",
+			body:     `{"model":"m","response_format":{"type":"json_schema","json_schema":{"name":"x","strict":true,"schema":{"type":"object"}}}}`,
+			expected: `{"model":"m","guided_json":{"type":"object"},"guided_decoding_backend":"xgrammar"}`,
+		},
+		{
+			name:        "json schema without schema",
+			body:        `{"model":"m","response_format":{"type":"json_schema","json_schema":{"name":"x"}}}`,
+			expectError: true,
+		},
+		{
+			name:        "unsupported response format",
+			body:        `{"model":"m","response_format":{"type":"yaml"}}`,
+			expectError: true,
+		},
+		{
+			name:          "named tool choice",
+			body:          `{"model":"m","tools":[{"type":"function","function":{"name":"f"}}],"tool_choice":{"type":"function","function":{"name":"f"}}}`,
+			guidedBackend: "outlines",
+			expected:      `{"model":"m","tools":[{"type":"function","function":{"name":"f"}}],"tool_choice":{"type":"function","function":{"name":"f"}},"guided_decoding_backend":"outlines"}`,
+		},
+		{
+			name:        "named tool choice with unknown function",
+			body:        `{"model":"m","tools":[{"type":"function","function":{"name":"f"}}],"tool_choice":{"type":"function","function":{"name":"g"}}}`,
+			expectError: true,
+		},
+		{
+			name:        "required tool choice without tools",
+			body:        `{"model":"m","tool_choice":"required"}`,
+			expectError: true,
+		},
+		{
+			name:          "auto tool choice",
+			body:          `{"model":"m","tools":[{"type":"function","function":{"name":"f"}}],"tool_choice":"auto"}`,
+			guidedBackend: "outlines",
+			expected:      `{"model":"m","tools":[{"type":"function","function":{"name":"f"}}],"tool_choice":"auto"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := translateGuidedDecoding([]byte(tt.body), tt.guidedBackend)
+			if tt.expectError {
+				if !errors.Is(err, ErrInvalidGuidedDecodingRequest) {
+					t.Fatalf("expected ErrInvalidGuidedDecodingRequest, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got, want map[string]any
+			if err := json.Unmarshal(result, &got); err != nil {
+				t.Fatalf("invalid result JSON: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.expected), &want); err != nil {
+				t.Fatalf("invalid expected JSON: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package vllm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/inference/platform"
+)
+
+// DefaultVersion is the vLLM version installed into managed environments.
+const DefaultVersion = "0.10.1.1"
+
+// checkGPUs returns an error unless the host, whose GPUs are from the specified
+// vendors, can run the vLLM wheels on PyPI, which are CUDA builds.
+func checkGPUs(vendors []platform.GPUVendor) error {
+	switch {
+	case slices.Contains(vendors, platform.GPUVendorNVIDIA):
+		return nil
+	case slices.Contains(vendors, platform.GPUVendorAMD):
+		return errors.New("unsupported accelerator: the vLLM wheels on PyPI require an NVIDIA GPU, so AMD GPUs need an image with a ROCm build of vLLM")
+	default:
+		return errors.New("no supported GPU detected: the vLLM wheels on PyPI require an NVIDIA GPU")
+	}
+}
+
+// pipInstallArgs returns the arguments to pip install for the specified vLLM
+// version. The vLLM wheels on PyPI target CUDA, but torch must come from the
+// matching CUDA index.
+func pipInstallArgs(version string) []string {
+	return []string{"vllm==" + version, "--extra-index-url", "https://download.pytorch.org/whl/cu128"}
+}
+
+// installManaged creates a virtual environment in the managed installation
+// directory and installs the pinned vLLM version into it. An existing
+// environment with the pinned version is reused.
+func (v *vLLM) installManaged(ctx context.Context) error {
+	version := v.config.version()
+	env := &backends.PythonEnv{
+		Dir:     v.config.ManagedInstallDir,
+		Name:    "vLLM",
+		Version: version,
+	}
+	if env.Installed() {
+		return nil
+	}
+
+	if err := checkGPUs(platform.GPUVendors()); err != nil {
+		return fmt.Errorf("unable to install vLLM: %w", err)
+	}
+	env.PipArgs = pipInstallArgs(version)
+
+	v.log.Infof("Installing vLLM %s into %s", version, env.Dir)
+	v.status = fmt.Sprintf("installing vllm version %s", version)
+
+	out := v.serverLog.Writer()
+	defer out.Close()
+	env.Output = out
+	if err := env.Install(ctx); err != nil {
+		return err
+	}
+	v.log.Infof("Installed vLLM %s", version)
+	return nil
+}
+
+// Uninstall implements inference.Uninstaller.Uninstall. Only managed
+// installations can be uninstalled.
+func (v *vLLM) Uninstall(_ context.Context) error {
+	if v.config.ManagedInstallDir == "" || v.envDir != v.config.ManagedInstallDir {
+		return backends.ErrNotManaged
+	}
+	if err := backends.RemovePythonEnv(v.config.ManagedInstallDir); err != nil {
+		return fmt.Errorf("failed to remove vLLM environment: %w", err)
+	}
+	v.log.Infof("Uninstalled vLLM from %s", v.config.ManagedInstallDir)
+	v.status = "not installed"
+	return nil
+}
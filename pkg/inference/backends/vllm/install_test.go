@@ -0,0 +1,48 @@
+package vllm
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference/platform"
+)
+
+func TestPipInstallArgs(t *testing.T) {
+	expected := []string{"vllm==0.10.0", "--extra-index-url", "https://download.pytorch.org/whl/cu128"}
+	if args := pipInstallArgs("0.10.0"); !slices.Equal(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+}
+
+func TestCheckGPUs(t *testing.T) {
+	tests := []struct {
+		name    string
+		vendors []platform.GPUVendor
+		wantErr string
+	}{
+		{name: "nvidia", vendors: []platform.GPUVendor{platform.GPUVendorIntel, platform.GPUVendorNVIDIA}},
+		{name: "amd", vendors: []platform.GPUVendor{platform.GPUVendorAMD}, wantErr: "unsupported accelerator"},
+		{name: "none", wantErr: "no supported GPU"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkGPUs(tt.vendors)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfigVersion(t *testing.T) {
+	if v := NewDefaultVLLMConfig().version(); v != DefaultVersion {
+		t.Errorf("expected default version %q, got %q", DefaultVersion, v)
+	}
+	if v := (&Config{Version: "0.9.2"}).version(); v != "0.9.2" {
+		t.Errorf("expected version 0.9.2, got %q", v)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/docker/model-runner/pkg/diskusage"
 	"github.com/docker/model-runner/pkg/distribution/types"
@@ -17,16 +16,18 @@ import (
 	"github.com/docker/model-runner/pkg/inference/backends"
 	"github.com/docker/model-runner/pkg/inference/models"
 	"github.com/docker/model-runner/pkg/inference/platform"
+	"github.com/docker/model-runner/pkg/inference/toolcalls"
 	"github.com/docker/model-runner/pkg/logging"
 )
 
 const (
 	// Name is the backend name.
-	Name    = "vllm"
-	vllmDir = "/opt/vllm-env/bin"
+	Name = "vllm"
+	// defaultEnvDir is the vLLM environment provided by the model runner image.
+	defaultEnvDir = "/opt/vllm-env"
 )
 
-var ErrorNotFound = errors.New("vLLM binary not found")
+var ErrorNotFound = inference.NotInstalledError("vLLM binary not found")
 
 // vLLM is the vLLM-based backend implementation.
 type vLLM struct {
@@ -40,6 +41,8 @@ type vLLM struct {
 	config *Config
 	// status is the state in which the vLLM backend is in.
 	status string
+	// envDir is the root of the vLLM environment in use.
+	envDir string
 }
 
 // New creates a new vLLM-based backend.
@@ -55,6 +58,7 @@ func New(log logging.Logger, modelManager *models.Manager, serverLog logging.Log
 		serverLog:    serverLog,
 		config:       conf,
 		status:       "not installed",
+		envDir:       defaultEnvDir,
 	}, nil
 }
 
@@ -67,28 +71,37 @@ func (v *vLLM) UsesExternalModelManagement() bool {
 	return false
 }
 
-func (v *vLLM) Install(_ context.Context, _ *http.Client) error {
+func (v *vLLM) Install(ctx context.Context, _ *http.Client) error {
 	if !platform.SupportsVLLM() {
 		return errors.New("not implemented")
 	}
 
-	vllmBinaryPath := v.binaryPath()
-	if _, err := os.Stat(vllmBinaryPath); err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
+	// Prefer the environment provided by the image, falling back to a
+	// managed installation on the host if one has been configured.
+	v.envDir = defaultEnvDir
+	if _, err := os.Stat(v.binaryPath()); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to check vLLM binary: %w", err)
+		}
+		if v.config.ManagedInstallDir == "" {
 			v.status = ErrorNotFound.Error()
 			return ErrorNotFound
 		}
-		return fmt.Errorf("failed to check vLLM binary: %w", err)
+		v.envDir = v.config.ManagedInstallDir
+		if err := v.installManaged(ctx); err != nil {
+			v.status = fmt.Sprintf("installation failed: %v", err)
+			return err
+		}
 	}
 
-	// Read vLLM version from file (created in Dockerfile via `print(vllm.__version__)`).
-	versionPath := filepath.Join(filepath.Dir(vllmDir), "version")
-	versionBytes, err := os.ReadFile(versionPath)
+	// Read vLLM version from file (created in Dockerfile via `print(vllm.__version__)`
+	// or by the managed installation).
+	version, err := backends.ReadEnvFile(v.envDir, backends.EnvVersionFile)
 	if err != nil {
 		v.log.Warnf("could not get vllm version: %v", err)
 		v.status = "running vllm version: unknown"
 	} else {
-		v.status = fmt.Sprintf("running vllm version: %s", strings.TrimSpace(string(versionBytes)))
+		v.status = fmt.Sprintf("running vllm version: %s", version)
 	}
 
 	return nil
@@ -145,9 +158,10 @@ func (v *vLLM) Run(ctx context.Context, socket, model string, modelRef string, m
 		BackendName:     "vLLM",
 		Socket:          socket,
 		BinaryPath:      v.binaryPath(),
-		SandboxPath:     vllmDir,
+		SandboxPath:     filepath.Join(v.envDir, "bin"),
 		SandboxConfig:   "",
 		Args:            args,
+		Devices:         backendConfig.AssignedDevices(),
 		Logger:          v.log,
 		ServerLogWriter: v.serverLog.Writer(),
 	})
@@ -158,24 +172,69 @@ func (v *vLLM) Status() string {
 }
 
 func (v *vLLM) GetDiskUsage() (int64, error) {
-	size, err := diskusage.Size(vllmDir)
-	if err != nil {
+	size, err := diskusage.Size(v.envDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
 		return 0, fmt.Errorf("error while getting store size: %w", err)
 	}
 	return size, nil
 }
 
-func (v *vLLM) GetRequiredMemoryForModel(_ context.Context, _ string, _ *inference.BackendConfiguration) (inference.RequiredMemory, error) {
+func (v *vLLM) GetRequiredMemoryForModel(_ context.Context, model string, _ *inference.BackendConfiguration) (inference.RequiredMemory, error) {
 	if !platform.SupportsVLLM() {
 		return inference.RequiredMemory{}, errors.New("not implemented")
 	}
 
+	// Estimate VRAM from the size of the model weights, falling back to
+	// unknown if they can't be inspected.
+	vram := uint64(1)
+	if weights, err := v.weightsSize(model); err != nil {
+		v.log.Warnf("Could not estimate VRAM for model %s: %v", model, err)
+	} else {
+		vram = backends.EstimateFromWeights(weights, backends.WeightsOverheadFactor)
+	}
+
 	return inference.RequiredMemory{
 		RAM:  1,
-		VRAM: 1,
+		VRAM: vram,
 	}, nil
 }
 
+// weightsSize returns the total size of a model's weights.
+func (v *vLLM) weightsSize(model string) (int64, error) {
+	mdl, err := v.modelManager.GetLocal(model)
+	if err != nil {
+		return 0, err
+	}
+	return backends.WeightsSize(mdl)
+}
+
+// SupportsMultipleDevices implements
+// inference.MultiDeviceBackend.SupportsMultipleDevices.
+func (v *vLLM) SupportsMultipleDevices() bool {
+	return true
+}
+
+// TokenizerFormat implements inference.TokenizerBackend.TokenizerFormat.
+func (v *vLLM) TokenizerFormat() inference.TokenizerFormat {
+	return inference.TokenizerFormatVLLM
+}
+
+// ParsesToolCalls implements inference.ToolCallBackend.ParsesToolCalls. vLLM
+// only parses tool calls with a tool call parser, which is selected for the
+// formats that it supports.
+func (v *vLLM) ParsesToolCalls(model string, config *inference.BackendConfiguration) bool {
+	if toolcalls.HasParser(config) {
+		return true
+	}
+	bundle, err := v.modelManager.GetBundle(model)
+	if err != nil {
+		return false
+	}
+	return toolcalls.VLLMParser(bundle) != ""
+}
+
 func (v *vLLM) binaryPath() string {
-	return filepath.Join(vllmDir, "vllm")
+	return filepath.Join(v.envDir, "bin", "vllm")
 }
@@ -3,16 +3,29 @@ package vllm
 import (
 	"fmt"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/docker/model-runner/pkg/distribution/types"
 	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/toolcalls"
 )
 
 // Config is the configuration for the vLLM backend.
 type Config struct {
 	// Args are the base arguments that are always included.
 	Args []string
+	// GuidedDecodingBackend is the guided decoding backend (e.g. outlines or
+	// xgrammar) used for structured outputs. If empty, vLLM's default is used.
+	GuidedDecodingBackend string
+	// ManagedInstallDir is the directory in which a vLLM environment is created
+	// if vLLM isn't provided by the image. If empty, managed installation is
+	// disabled.
+	ManagedInstallDir string
+	// Version is the vLLM version to install into the managed environment. If
+	// empty, DefaultVersion is used.
+	Version string
 }
 
 // NewDefaultVLLMConfig creates a new VLLMConfig with default values.
@@ -22,6 +35,14 @@ func NewDefaultVLLMConfig() *Config {
 	}
 }
 
+// version returns the vLLM version to install into the managed environment.
+func (c *Config) version() string {
+	if c.Version != "" {
+		return c.Version
+	}
+	return DefaultVersion
+}
+
 // GetArgs implements BackendConfig.GetArgs.
 func (c *Config) GetArgs(bundle types.ModelBundle, socket string, mode inference.BackendMode, config *inference.BackendConfiguration) ([]string, error) {
 	// Start with the arguments from VLLMConfig
@@ -36,7 +57,8 @@ func (c *Config) GetArgs(bundle types.ModelBundle, socket string, mode inference
 	// vLLM expects the directory containing the safetensors files
 	args = append(args, "serve", modelPath)
 
-	// Add socket arguments
+	// Add socket arguments. vLLM serves its engine metrics at /metrics on the
+	// same socket, which the aggregated metrics handler scrapes.
 	args = append(args, "--uds", socket)
 
 	// Add mode-specific arguments
@@ -57,6 +79,29 @@ func (c *Config) GetArgs(bundle types.ModelBundle, socket string, mode inference
 	}
 	// If nil, vLLM will automatically derive from the model config
 
+	// Add the guided decoding backend if one has been configured
+	if c.GuidedDecodingBackend != "" {
+		args = append(args, "--guided-decoding-backend", c.GuidedDecodingBackend)
+	}
+
+	// Parse the tool calls of completion models in their format, unless a
+	// parser has been configured
+	if mode == inference.BackendModeCompletion && !toolcalls.HasParser(config) {
+		if parser := toolcalls.VLLMParser(bundle); parser != "" {
+			args = append(args, "--enable-auto-tool-choice", toolcalls.ParserFlag, parser)
+		}
+	}
+
+	// Split the model across the GPUs assigned by the scheduler, unless the
+	// parallelism has been configured explicitly
+	if config != nil && len(config.Devices) > 1 && !hasParallelismFlag(config.RuntimeFlags) {
+		tp, pp := Parallelism(len(config.Devices))
+		args = append(args,
+			"--tensor-parallel-size", strconv.Itoa(tp),
+			"--pipeline-parallel-size", strconv.Itoa(pp),
+		)
+	}
+
 	// Add arguments from backend config
 	if config != nil {
 		args = append(args, config.RuntimeFlags...)
@@ -81,3 +126,32 @@ func GetMaxModelLen(modelCfg types.Config, backendCfg *inference.BackendConfigur
 	// Return nil to let vLLM auto-derive from model config
 	return nil
 }
+
+// Parallelism splits n GPUs into tensor and pipeline parallel sizes. Tensor
+// parallelism is used for the largest power of two dividing n (since attention
+// head counts are typically powers of two), with pipeline parallelism across
+// the remaining factor.
+func Parallelism(n int) (tensor, pipeline int) {
+	if n <= 1 {
+		return 1, 1
+	}
+	tensor = 1
+	for n%(tensor*2) == 0 {
+		tensor *= 2
+	}
+	return tensor, n / tensor
+}
+
+// parallelismFlags are the vLLM flags controlling model parallelism.
+var parallelismFlags = []string{"--tensor-parallel-size", "-tp", "--pipeline-parallel-size", "-pp"}
+
+// hasParallelismFlag returns true if flags configure model parallelism.
+func hasParallelismFlag(flags []string) bool {
+	for _, flag := range flags {
+		name, _, _ := strings.Cut(flag, "=")
+		if slices.Contains(parallelismFlags, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -20,6 +20,22 @@ func (m *mockModelBundle) SafetensorsPath() string {
 	return m.safetensorsPath
 }
 
+func (m *mockModelBundle) ONNXPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) WhisperPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) DiffusionPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) TensorRTPath() string {
+	return ""
+}
+
 func (m *mockModelBundle) ChatTemplatePath() string {
 	return ""
 }
@@ -28,6 +44,10 @@ func (m *mockModelBundle) MMPROJPath() string {
 	return ""
 }
 
+func (m *mockModelBundle) AdapterPath() string {
+	return ""
+}
+
 func (m *mockModelBundle) RuntimeConfig() types.Config {
 	return m.runtimeConfig
 }
@@ -83,6 +103,45 @@ func TestGetArgs(t *testing.T) {
 				"8192",
 			},
 		},
+		{
+			name: "with tool call parser of architecture",
+			bundle: &mockModelBundle{
+				safetensorsPath: "/path/to/model",
+				runtimeConfig: types.Config{
+					Architecture: "Qwen3ForCausalLM",
+				},
+			},
+			config: nil,
+			expected: []string{
+				"serve",
+				"/path/to",
+				"--uds",
+				"/tmp/socket",
+				"--enable-auto-tool-choice",
+				"--tool-call-parser",
+				"hermes",
+			},
+		},
+		{
+			name: "with configured tool call parser",
+			bundle: &mockModelBundle{
+				safetensorsPath: "/path/to/model",
+				runtimeConfig: types.Config{
+					Architecture: "Qwen3ForCausalLM",
+				},
+			},
+			config: &inference.BackendConfiguration{
+				RuntimeFlags: []string{"--enable-auto-tool-choice", "--tool-call-parser=qwen3_coder"},
+			},
+			expected: []string{
+				"serve",
+				"/path/to",
+				"--uds",
+				"/tmp/socket",
+				"--enable-auto-tool-choice",
+				"--tool-call-parser=qwen3_coder",
+			},
+		},
 		{
 			name: "with runtime flags",
 			bundle: &mockModelBundle{
@@ -120,6 +179,42 @@ func TestGetArgs(t *testing.T) {
 				"16384",
 			},
 		},
+		{
+			name: "with assigned devices",
+			bundle: &mockModelBundle{
+				safetensorsPath: "/path/to/model",
+			},
+			config: &inference.BackendConfiguration{
+				Devices: []int{0, 1, 2, 3, 4, 5},
+			},
+			expected: []string{
+				"serve",
+				"/path/to",
+				"--uds",
+				"/tmp/socket",
+				"--tensor-parallel-size",
+				"2",
+				"--pipeline-parallel-size",
+				"3",
+			},
+		},
+		{
+			name: "with assigned devices and explicit parallelism",
+			bundle: &mockModelBundle{
+				safetensorsPath: "/path/to/model",
+			},
+			config: &inference.BackendConfiguration{
+				Devices:      []int{0, 1},
+				RuntimeFlags: []string{"--tensor-parallel-size=1"},
+			},
+			expected: []string{
+				"serve",
+				"/path/to",
+				"--uds",
+				"/tmp/socket",
+				"--tensor-parallel-size=1",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -207,3 +302,27 @@ func TestGetMaxModelLen(t *testing.T) {
 func ptrUint64(v uint64) *uint64 {
 	return &v
 }
+
+func TestParallelism(t *testing.T) {
+	tests := []struct {
+		devices  int
+		tensor   int
+		pipeline int
+	}{
+		{devices: 0, tensor: 1, pipeline: 1},
+		{devices: 1, tensor: 1, pipeline: 1},
+		{devices: 2, tensor: 2, pipeline: 1},
+		{devices: 3, tensor: 1, pipeline: 3},
+		{devices: 4, tensor: 4, pipeline: 1},
+		{devices: 6, tensor: 2, pipeline: 3},
+		{devices: 8, tensor: 8, pipeline: 1},
+	}
+
+	for _, tt := range tests {
+		tensor, pipeline := Parallelism(tt.devices)
+		if tensor != tt.tensor || pipeline != tt.pipeline {
+			t.Errorf("Parallelism(%d) = (%d, %d), expected (%d, %d)",
+				tt.devices, tensor, pipeline, tt.tensor, tt.pipeline)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package backends
+
+import (
+	"os"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+// WeightsOverheadFactor is the factor by which backends that estimate their
+// memory requirements from a model's weights scale them, to account for the
+// activations, KV cache, and buffers allocated on top of the weights.
+const WeightsOverheadFactor = 1.2
+
+// WeightsSize returns the total size of a model's weight files: its GGUF files
+// or, if it has none, its safetensors files.
+func WeightsSize(model types.Model) (int64, error) {
+	paths, err := model.GGUFPaths()
+	if err != nil || len(paths) == 0 {
+		if paths, err = model.SafetensorsPaths(); err != nil {
+			return 0, err
+		}
+	}
+	return FilesSize(paths...)
+}
+
+// FilesSize returns the total size of the files at the specified paths.
+func FilesSize(paths ...string) (int64, error) {
+	var size int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// EstimateFromWeights estimates the memory required to run a model from the
+// size of its weights, scaled by factor. The estimate is never less than 1,
+// which backends report if a model's requirements are unknown.
+func EstimateFromWeights(weights int64, factor float64) uint64 {
+	return max(uint64(float64(weights)*factor), 1)
+}
@@ -0,0 +1,36 @@
+package backends
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilesSize(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "model-00001.safetensors"), filepath.Join(dir, "model-00002.safetensors")
+	if err := os.WriteFile(first, make([]byte, 100), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(second, make([]byte, 50), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if size, err := FilesSize(first, second); err != nil || size != 150 {
+		t.Errorf("expected size 150, got %d, %v", size, err)
+	}
+	if _, err := FilesSize(first, filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestEstimateFromWeights(t *testing.T) {
+	if estimate := EstimateFromWeights(1000, WeightsOverheadFactor); estimate != 1200 {
+		t.Errorf("expected estimate 1200, got %d", estimate)
+	}
+	if estimate := EstimateFromWeights(1000, 2*WeightsOverheadFactor); estimate != 2400 {
+		t.Errorf("expected estimate 2400, got %d", estimate)
+	}
+	if estimate := EstimateFromWeights(0, WeightsOverheadFactor); estimate != 1 {
+		t.Errorf("expected unknown estimate 1, got %d", estimate)
+	}
+}
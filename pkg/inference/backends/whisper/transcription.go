@@ -0,0 +1,204 @@
+package whisper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+const (
+	// transcriptionsPath is the OpenAI API transcription endpoint.
+	transcriptionsPath = "/v1/audio/transcriptions"
+	// maximumFormMemory is the amount of an uploaded audio file that is kept
+	// in memory, with the remainder being stored in temporary files.
+	maximumFormMemory = 32 * 1024 * 1024
+)
+
+// ErrInvalidTranscriptionRequest indicates that a transcription request can't
+// be served.
+var ErrInvalidTranscriptionRequest = errors.New("invalid transcription request")
+
+// responseFormats are the transcription response formats supported by both
+// the OpenAI API and whisper-server.
+var responseFormats = []string{"json", "text", "srt", "verbose_json", "vtt"}
+
+// formValue returns the first value of a multipart form field.
+func formValue(form map[string][]string, key string) string {
+	if values := form[key]; len(values) > 0 {
+		return strings.TrimSpace(values[0])
+	}
+	return ""
+}
+
+// transcriptionParams translates the fields of an OpenAI API transcription
+// request into whisper-server inference parameters.
+func transcriptionParams(form map[string][]string) (map[string]string, error) {
+	params := make(map[string]string)
+
+	format := formValue(form, "response_format")
+	if format == "" {
+		format = "json"
+	}
+	if !slices.Contains(responseFormats, format) {
+		return nil, fmt.Errorf("%w: unsupported response_format %q (expected one of %s)",
+			ErrInvalidTranscriptionRequest, format, strings.Join(responseFormats, ", "))
+	}
+	params["response_format"] = format
+
+	// Unlike the OpenAI API, whisper-server defaults to English, so request
+	// language detection explicitly.
+	language := strings.ToLower(formValue(form, "language"))
+	if language == "" {
+		language = "auto"
+	} else if len(language) < 2 || len(language) > 3 || strings.IndexFunc(language, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) != -1 {
+		return nil, fmt.Errorf("%w: language must be an ISO-639-1 code", ErrInvalidTranscriptionRequest)
+	}
+	params["language"] = language
+
+	if prompt := formValue(form, "prompt"); prompt != "" {
+		params["prompt"] = prompt
+	}
+
+	if s := formValue(form, "temperature"); s != "" {
+		temperature, err := strconv.ParseFloat(s, 64)
+		if err != nil || temperature < 0 || temperature > 1 {
+			return nil, fmt.Errorf("%w: temperature must be between 0 and 1", ErrInvalidTranscriptionRequest)
+		}
+		params["temperature"] = s
+	}
+
+	// Clients send arrays as repeated fields, with or without brackets.
+	granularities := append(append([]string{}, form["timestamp_granularities[]"]...), form["timestamp_granularities"]...)
+	for _, granularity := range granularities {
+		switch granularity {
+		case "segment":
+		case "word":
+			// whisper.cpp produces word-level timestamps by limiting segments
+			// to a single word.
+			params["max_len"] = "1"
+			params["split_on_word"] = "true"
+		default:
+			return nil, fmt.Errorf("%w: unsupported timestamp granularity %q (expected segment or word)",
+				ErrInvalidTranscriptionRequest, granularity)
+		}
+	}
+	if len(granularities) > 0 && format != "verbose_json" {
+		return nil, fmt.Errorf("%w: timestamp_granularities requires response_format verbose_json", ErrInvalidTranscriptionRequest)
+	}
+
+	// Skip timestamp computation for formats that don't include timestamps.
+	if format == "json" || format == "text" {
+		params["no_timestamps"] = "true"
+	}
+
+	return params, nil
+}
+
+// proxy serves the OpenAI API on a runner socket, forwarding transcription
+// requests to whisper-server.
+type proxy struct {
+	// log is the associated logger.
+	log logging.Logger
+	// upstream is the base URL of whisper-server.
+	upstream string
+	// client is the client used to reach whisper-server.
+	client *http.Client
+	// mux routes requests to their handlers.
+	mux *http.ServeMux
+}
+
+// newProxy creates a new proxy targeting whisper-server at the specified
+// address.
+func newProxy(log logging.Logger, address string, models []string) *proxy {
+	p := &proxy{
+		log:      log,
+		upstream: "http://" + address,
+		client:   &http.Client{},
+		mux:      http.NewServeMux(),
+	}
+	p.mux.HandleFunc("GET /v1/models", backends.ModelsHandler(address, models))
+	p.mux.HandleFunc("POST "+transcriptionsPath, p.handleTranscription)
+	return p
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// handleTranscription translates an OpenAI API transcription request and
+// forwards it to whisper-server.
+func (p *proxy) handleTranscription(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(maximumFormMemory); err != nil {
+		http.Error(w, fmt.Sprintf("%v: %v", ErrInvalidTranscriptionRequest, err), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%v: file is required", ErrInvalidTranscriptionRequest), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	params, err := transcriptionParams(r.MultipartForm.Value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Build the whisper-server request.
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", header.Filename)
+	if err == nil {
+		_, err = io.Copy(part, file)
+	}
+	for key, value := range params {
+		if err != nil {
+			break
+		}
+		err = writer.WriteField(key, value)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to build transcription request: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, p.upstream+transcriptionsPath, &body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := p.client.Do(req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("whisper-server request failed: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		p.log.Warnf("Failed to forward transcription response: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package whisper
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestTranscriptionParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		form        map[string][]string
+		expected    map[string]string
+		expectError bool
+	}{
+		{
+			name: "defaults",
+			form: map[string][]string{"model": {"ai/whisper"}},
+			expected: map[string]string{
+				"response_format": "json",
+				"language":        "auto",
+				"no_timestamps":   "true",
+			},
+		},
+		{
+			name: "language, prompt, and temperature",
+			form: map[string][]string{
+				"response_format": {"srt"},
+				"language":        {"DE"},
+				"prompt":          {"Docker"},
+				"temperature":     {"0.2"},
+			},
+			expected: map[string]string{
+				"response_format": "srt",
+				"language":        "de",
+				"prompt":          "Docker",
+				"temperature":     "0.2",
+			},
+		},
+		{
+			name: "word timestamps",
+			form: map[string][]string{
+				"response_format":           {"verbose_json"},
+				"timestamp_granularities[]": {"segment", "word"},
+			},
+			expected: map[string]string{
+				"response_format": "verbose_json",
+				"language":        "auto",
+				"max_len":         "1",
+				"split_on_word":   "true",
+			},
+		},
+		{
+			name: "timestamps require verbose_json",
+			form: map[string][]string{
+				"timestamp_granularities": {"word"},
+			},
+			expectError: true,
+		},
+		{
+			name:        "unsupported timestamp granularity",
+			form:        map[string][]string{"response_format": {"verbose_json"}, "timestamp_granularities[]": {"char"}},
+			expectError: true,
+		},
+		{
+			name:        "unsupported response format",
+			form:        map[string][]string{"response_format": {"diarized_json"}},
+			expectError: true,
+		},
+		{
+			name:        "invalid language",
+			form:        map[string][]string{"language": {"english"}},
+			expectError: true,
+		},
+		{
+			name:        "temperature out of range",
+			form:        map[string][]string{"temperature": {"1.5"}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := transcriptionParams(tt.form)
+			if tt.expectError {
+				if !errors.Is(err, ErrInvalidTranscriptionRequest) {
+					t.Fatalf("expected ErrInvalidTranscriptionRequest, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !maps.Equal(params, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, params)
+			}
+		})
+	}
+}
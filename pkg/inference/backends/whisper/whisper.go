@@ -0,0 +1,161 @@
+package whisper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+const (
+	// Name is the backend name.
+	Name = "whisper"
+	// binaryName is the name of the whisper.cpp server binary.
+	binaryName = "whisper-server"
+)
+
+var ErrorNotFound = inference.NotInstalledError("whisper-server binary not found")
+
+// whisper is the whisper.cpp-based backend implementation.
+type whisper struct {
+	// log is the associated logger.
+	log logging.Logger
+	// modelManager is the shared model manager.
+	modelManager *models.Manager
+	// serverLog is the logger to use for the whisper-server process.
+	serverLog logging.Logger
+	// config is the configuration for the whisper.cpp backend.
+	config *Config
+	// status is the state in which the whisper.cpp backend is in.
+	status string
+	// binaryPath is the path to the whisper-server binary in use.
+	binaryPath string
+}
+
+// New creates a new whisper.cpp-based backend.
+func New(log logging.Logger, modelManager *models.Manager, serverLog logging.Logger, conf *Config) (inference.Backend, error) {
+	// If no config is provided, use the default configuration
+	if conf == nil {
+		conf = NewDefaultWhisperConfig()
+	}
+
+	return &whisper{
+		log:          log,
+		modelManager: modelManager,
+		serverLog:    serverLog,
+		config:       conf,
+		status:       "not installed",
+	}, nil
+}
+
+// Name implements inference.Backend.Name.
+func (w *whisper) Name() string {
+	return Name
+}
+
+// UsesExternalModelManagement implements
+// inference.Backend.UsesExternalModelManagement.
+func (w *whisper) UsesExternalModelManagement() bool {
+	return false
+}
+
+// Install implements inference.Backend.Install. whisper-server isn't
+// downloaded by the model runner, so it must either be configured or be
+// available on the PATH.
+func (w *whisper) Install(_ context.Context, _ *http.Client) error {
+	binaryPath := w.config.BinaryPath
+	if binaryPath == "" {
+		var err error
+		if binaryPath, err = exec.LookPath(binaryName); err != nil {
+			w.status = ErrorNotFound.Error()
+			return ErrorNotFound
+		}
+	} else if _, err := os.Stat(binaryPath); err != nil {
+		w.status = ErrorNotFound.Error()
+		return fmt.Errorf("%w: %w", ErrorNotFound, err)
+	}
+	w.binaryPath = binaryPath
+	w.status = fmt.Sprintf("running whisper.cpp (%s)", binaryPath)
+	return nil
+}
+
+// Run implements inference.Backend.Run.
+func (w *whisper) Run(ctx context.Context, socket, model string, modelRef string, mode inference.BackendMode, backendConfig *inference.BackendConfiguration) error {
+	bundle, err := w.modelManager.GetBundle(model)
+	if err != nil {
+		return fmt.Errorf("failed to get model: %w", err)
+	}
+
+	// whisper-server only listens on TCP, so bind it to a free loopback port
+	// and proxy the runner socket to it.
+	address, err := backends.LoopbackAddress()
+	if err != nil {
+		return fmt.Errorf("failed to allocate whisper-server address: %w", err)
+	}
+
+	args, err := w.config.GetArgs(bundle, address, mode, backendConfig)
+	if err != nil {
+		return fmt.Errorf("failed to get whisper.cpp arguments: %w", err)
+	}
+
+	stop, err := backends.ServeSocket(socket, newProxy(w.log, address, []string{model, modelRef}), w.log)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	return backends.RunBackend(ctx, backends.RunnerConfig{
+		BackendName:     "whisper.cpp",
+		BinaryPath:      w.binaryPath,
+		SandboxPath:     filepath.Dir(w.binaryPath),
+		SandboxConfig:   "",
+		Args:            args,
+		Devices:         backendConfig.AssignedDevices(),
+		Logger:          w.log,
+		ServerLogWriter: w.serverLog.Writer(),
+	})
+}
+
+func (w *whisper) Status() string {
+	return w.status
+}
+
+// GetDiskUsage implements inference.Backend.GetDiskUsage. whisper-server isn't
+// managed by the model runner, so it doesn't count towards its disk usage.
+func (w *whisper) GetDiskUsage() (int64, error) {
+	return 0, nil
+}
+
+// GetRequiredMemoryForModel implements
+// inference.Backend.GetRequiredMemoryForModel. whisper models are small, so
+// their memory is attributed to RAM regardless of whether whisper-server
+// offloads them to a GPU.
+func (w *whisper) GetRequiredMemoryForModel(_ context.Context, model string, _ *inference.BackendConfiguration) (inference.RequiredMemory, error) {
+	required := uint64(1)
+	if weights, err := w.weightsSize(model); err != nil {
+		w.log.Warnf("Could not estimate memory for model %s: %v", model, err)
+	} else {
+		required = backends.EstimateFromWeights(weights, backends.WeightsOverheadFactor)
+	}
+	return inference.RequiredMemory{RAM: required, VRAM: 0}, nil
+}
+
+// weightsSize returns the size of a model's GGML weights file.
+func (w *whisper) weightsSize(model string) (int64, error) {
+	bundle, err := w.modelManager.GetBundle(model)
+	if err != nil {
+		return 0, err
+	}
+	if bundle.WhisperPath() == "" {
+		return 0, errors.New("model has no whisper weights")
+	}
+	return backends.FilesSize(bundle.WhisperPath())
+}
@@ -0,0 +1,63 @@
+package whisper
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// Config is the configuration for the whisper.cpp backend.
+type Config struct {
+	// Args are the base arguments that are always included.
+	Args []string
+	// BinaryPath is the path to the whisper-server binary. If empty, the
+	// binary provided by the model runner image is used.
+	BinaryPath string
+}
+
+// NewDefaultWhisperConfig creates a new whisper.cpp Config with default
+// values.
+func NewDefaultWhisperConfig() *Config {
+	return &Config{
+		Args: []string{},
+	}
+}
+
+// GetArgs implements BackendConfig.GetArgs. whisper-server can't listen on a
+// Unix domain socket, so socket is the loopback TCP address that the server
+// should listen on, which the backend proxies to.
+func (c *Config) GetArgs(bundle types.ModelBundle, socket string, mode inference.BackendMode, config *inference.BackendConfiguration) ([]string, error) {
+	// Start with the arguments from Config
+	args := append([]string{}, c.Args...)
+
+	modelPath := bundle.WhisperPath()
+	if modelPath == "" {
+		return nil, fmt.Errorf("whisper model required by whisper.cpp backend")
+	}
+
+	if mode != inference.BackendModeTranscription {
+		return nil, fmt.Errorf("%s mode not supported by whisper.cpp backend", mode)
+	}
+
+	host, port, err := net.SplitHostPort(socket)
+	if err != nil {
+		return nil, fmt.Errorf("invalid whisper-server address %q: %w", socket, err)
+	}
+
+	// Serve the transcription endpoint at its OpenAI API path
+	args = append(args,
+		"--model", modelPath,
+		"--host", host,
+		"--port", port,
+		"--inference-path", transcriptionsPath,
+	)
+
+	// Add arguments from backend config
+	if config != nil {
+		args = append(args, config.RuntimeFlags...)
+	}
+
+	return args, nil
+}
@@ -0,0 +1,114 @@
+package whisper
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+type mockModelBundle struct {
+	whisperPath string
+}
+
+func (m *mockModelBundle) GGUFPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) SafetensorsPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) ONNXPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) WhisperPath() string {
+	return m.whisperPath
+}
+
+func (m *mockModelBundle) DiffusionPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) TensorRTPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) ChatTemplatePath() string {
+	return ""
+}
+
+func (m *mockModelBundle) MMPROJPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) AdapterPath() string {
+	return ""
+}
+
+func (m *mockModelBundle) RuntimeConfig() types.Config {
+	return types.Config{}
+}
+
+func (m *mockModelBundle) RootDir() string {
+	return "/path/to/bundle"
+}
+
+func TestGetArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        inference.BackendMode
+		config      *inference.BackendConfiguration
+		bundle      *mockModelBundle
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:        "empty whisper path should error",
+			mode:        inference.BackendModeTranscription,
+			bundle:      &mockModelBundle{},
+			expectError: true,
+		},
+		{
+			name:        "completion mode should error",
+			mode:        inference.BackendModeCompletion,
+			bundle:      &mockModelBundle{whisperPath: "/path/to/ggml-base.bin"},
+			expectError: true,
+		},
+		{
+			name:   "transcription mode with runtime flags",
+			mode:   inference.BackendModeTranscription,
+			bundle: &mockModelBundle{whisperPath: "/path/to/ggml-base.bin"},
+			config: &inference.BackendConfiguration{
+				RuntimeFlags: []string{"--threads", "4"},
+			},
+			expected: []string{
+				"--model", "/path/to/ggml-base.bin",
+				"--host", "127.0.0.1",
+				"--port", "8080",
+				"--inference-path", "/v1/audio/transcriptions",
+				"--threads", "4",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := NewDefaultWhisperConfig().GetArgs(tt.bundle, "127.0.0.1:8080", tt.mode, tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected error, got args %v", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(args, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Package chattemplate validates and renders the chat templates of models.
+// Chat templates are either Jinja templates, such as those of Hugging Face
+// tokenizers and GGUF files, which backends apply, or Go templates, such as
+// those of Ollama models, which are rendered by the model runner.
+package chattemplate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+// goFieldPattern matches a reference to a field in an action, such as
+// {{ .Prompt }} or {{ range .Messages }}, which Jinja templates don't have.
+var goFieldPattern = regexp.MustCompile(`\{\{-?\s*(?:[^}]*[\s(])?\.[A-Z]`)
+
+// Message is a message of a conversation.
+type Message struct {
+	// Role is the role of the author of the message, such as "user".
+	Role string `json:"role"`
+	// Content is the content of the message.
+	Content string `json:"content"`
+}
+
+// data is the data that Go chat templates are executed with. It follows the
+// conventions of Ollama templates, so that they can be used as-is.
+type data struct {
+	// Messages are the messages of the conversation.
+	Messages []Message
+	// System is the content of the system messages.
+	System string
+	// Prompt is the content of the last user message.
+	Prompt string
+	// Response is the start of the response, which is always empty.
+	Response string
+}
+
+// sampleConversation is the conversation that Go templates are rendered with
+// to be validated.
+var sampleConversation = []Message{
+	{Role: "system", Content: "You are a helpful assistant."},
+	{Role: "user", Content: "Hello"},
+	{Role: "assistant", Content: "Hi! How can I help?"},
+	{Role: "user", Content: "Tell me a joke."},
+}
+
+// Detect returns the format of a chat template. Go templates are recognized
+// by their references to fields, which aren't valid Jinja.
+func Detect(tmpl string) types.ChatTemplateFormat {
+	if !strings.Contains(tmpl, "{%") && goFieldPattern.MatchString(tmpl) {
+		return types.ChatTemplateFormatGo
+	}
+	return types.ChatTemplateFormatJinja
+}
+
+// Validate checks that a chat template is well formed and returns its format.
+// Go templates are rendered for a sample conversation, while the syntax of
+// Jinja templates is checked without rendering them, which only backends do.
+func Validate(tmpl string) (types.ChatTemplateFormat, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return "", fmt.Errorf("empty chat template")
+	}
+	format := Detect(tmpl)
+	var err error
+	if format == types.ChatTemplateFormatGo {
+		_, err = Render(tmpl, sampleConversation)
+	} else {
+		err = validateJinja(tmpl)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid %s chat template: %w", format, err)
+	}
+	return format, nil
+}
+
+// Render renders the prompt of a conversation with a Go chat template.
+func Render(tmpl string, messages []Message) (string, error) {
+	t, err := template.New("chat").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	d := data{Messages: messages}
+	var system []string
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+		case "user":
+			d.Prompt = m.Content
+		}
+	}
+	d.System = strings.Join(system, "\n")
+
+	var prompt strings.Builder
+	if err := t.Execute(&prompt, d); err != nil {
+		return "", err
+	}
+	return prompt.String(), nil
+}
@@ -0,0 +1,79 @@
+package chattemplate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		format   types.ChatTemplateFormat
+		err      string
+	}{
+		{
+			name:     "jinja",
+			template: "{% for message in messages %}{{- '<|' + message['role'] + '|>' }}{{ message.content }}{% if not loop.last %}\n{% endif %}{% endfor %}{% if add_generation_prompt %}<|assistant|>{% endif %}",
+			format:   types.ChatTemplateFormatJinja,
+		},
+		{
+			name:     "jinja with set, raw, and comments",
+			template: "{# system #}{% set ns = namespace(system='') %}{% set greeting %}Hi{% endset %}{% raw %}{% if %}{% endraw %}{{ {'a': '}}'}['a'] }}",
+			format:   types.ChatTemplateFormatJinja,
+		},
+		{
+			name:     "go",
+			template: "{{ if .System }}<|system|>{{ .System }}\n{{ end }}{{ range .Messages }}<|{{ .Role }}|>{{ .Content }}\n{{ end }}<|assistant|>",
+			format:   types.ChatTemplateFormatGo,
+		},
+		{name: "empty", template: " ", err: "empty chat template"},
+		{name: "unclosed block", template: "{% for m in messages %}{{ m }}", err: "line 1: for block isn't closed"},
+		{name: "mismatched block", template: "{% if x %}\n{% endfor %}", err: "line 2: unexpected endfor"},
+		{name: "unexpected branch", template: "{% for m in messages %}{% elif x %}{% endfor %}", err: "unexpected elif"},
+		{name: "unclosed tag", template: "{{ messages[0]", err: `tag isn't closed with "}}"`},
+		{name: "unbalanced brackets", template: "{{ messages[0 }}", err: "unbalanced '}'"},
+		{name: "unclosed string", template: "{{ 'hello }}", err: "string isn't closed"},
+		{name: "empty expression", template: "{{ }}", err: "empty expression"},
+		{name: "go parse error", template: "{{ range .Messages }}{{ .Content }}", err: "invalid go chat template"},
+		{name: "go unknown field", template: "{{ .Unknown }}", err: "can't evaluate field Unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := Validate(tt.template)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if format != tt.format {
+				t.Errorf("Expected format %q, got %q", tt.format, format)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	prompt, err := Render(
+		"{{ if .System }}[{{ .System }}]{{ end }}{{ range .Messages }}{{ if ne .Role \"system\" }}{{ .Role }}: {{ .Content }}\n{{ end }}{{ end }}last: {{ .Prompt }}",
+		[]Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "Hi"},
+			{Role: "assistant", Content: "Hello"},
+			{Role: "user", Content: "Bye"},
+		},
+	)
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	expected := "[Be brief.]user: Hi\nassistant: Hello\nuser: Bye\nlast: Bye"
+	if prompt != expected {
+		t.Errorf("Expected prompt %q, got %q", expected, prompt)
+	}
+}
@@ -0,0 +1,184 @@
+package chattemplate
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// jinjaBlocks maps the statements that open blocks in Jinja templates to the
+// statements that close them.
+var jinjaBlocks = map[string]string{
+	"for":        "endfor",
+	"if":         "endif",
+	"macro":      "endmacro",
+	"call":       "endcall",
+	"filter":     "endfilter",
+	"block":      "endblock",
+	"with":       "endwith",
+	"set":        "endset",
+	"generation": "endgeneration",
+}
+
+// jinjaBranches maps the statements that continue blocks to the statements
+// that open the blocks they can continue.
+var jinjaBranches = map[string][]string{
+	"elif": {"if"},
+	"else": {"if", "for"},
+}
+
+// jinjaTagDelimiters maps the delimiters that open Jinja tags to those that
+// close them.
+var jinjaTagDelimiters = map[string]string{
+	"{{": "}}",
+	"{%": "%}",
+	"{#": "#}",
+}
+
+var (
+	// jinjaStatementPattern matches the name of a statement.
+	jinjaStatementPattern = regexp.MustCompile(`^[a-z_]+`)
+	// jinjaEndRawPattern matches the tag that closes a raw block.
+	jinjaEndRawPattern = regexp.MustCompile(`\{%[-+]?\s*endraw\s*[-+]?%\}`)
+	// jinjaInlineSetPattern matches set statements that assign a value, rather
+	// than opening a block.
+	jinjaInlineSetPattern = regexp.MustCompile(`^set\s+[^=]+=`)
+)
+
+// jinjaBlock is a block opened in a Jinja template.
+type jinjaBlock struct {
+	// statement is the statement that opened the block.
+	statement string
+	// line is the line of the template that the block was opened on.
+	line int
+}
+
+// validateJinja checks the syntax of a Jinja template: that its tags are
+// closed, that the brackets and quotes in them are balanced, and that its
+// blocks are nested correctly.
+func validateJinja(tmpl string) error {
+	var blocks []jinjaBlock
+	pos := 0
+	for {
+		start := nextJinjaTag(tmpl, pos)
+		if start < 0 {
+			break
+		}
+		line := strings.Count(tmpl[:start], "\n") + 1
+		open := tmpl[start : start+2]
+		if open == "{#" {
+			end := strings.Index(tmpl[start+2:], "#}")
+			if end < 0 {
+				return fmt.Errorf("line %d: comment isn't closed", line)
+			}
+			pos = start + 2 + end + 2
+			continue
+		}
+
+		end, err := jinjaTagEnd(tmpl, start+2, jinjaTagDelimiters[open])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		pos = end + 2
+		content := strings.TrimSpace(strings.Trim(tmpl[start+2:end], "-+"))
+		if open == "{{" {
+			if content == "" {
+				return fmt.Errorf("line %d: empty expression", line)
+			}
+			continue
+		}
+
+		statement := jinjaStatementPattern.FindString(content)
+		switch {
+		case statement == "":
+			return fmt.Errorf("line %d: invalid statement %q", line, content)
+		case statement == "raw":
+			end := jinjaEndRawPattern.FindStringIndex(tmpl[pos:])
+			if end == nil {
+				return fmt.Errorf("line %d: raw block isn't closed", line)
+			}
+			pos += end[1]
+		case statement == "set" && jinjaInlineSetPattern.MatchString(content):
+		case jinjaBlocks[statement] != "":
+			blocks = append(blocks, jinjaBlock{statement: statement, line: line})
+		case jinjaBranches[statement] != nil:
+			if len(blocks) == 0 || !slices.Contains(jinjaBranches[statement], blocks[len(blocks)-1].statement) {
+				return fmt.Errorf("line %d: unexpected %s", line, statement)
+			}
+		case strings.HasPrefix(statement, "end"):
+			if len(blocks) == 0 || jinjaBlocks[blocks[len(blocks)-1].statement] != statement {
+				return fmt.Errorf("line %d: unexpected %s", line, statement)
+			}
+			blocks = blocks[:len(blocks)-1]
+		}
+	}
+	if len(blocks) > 0 {
+		block := blocks[len(blocks)-1]
+		return fmt.Errorf("line %d: %s block isn't closed", block.line, block.statement)
+	}
+	return nil
+}
+
+// nextJinjaTag returns the index of the next tag of a Jinja template from pos,
+// or -1 if there's none.
+func nextJinjaTag(tmpl string, pos int) int {
+	next := -1
+	for open := range jinjaTagDelimiters {
+		if i := strings.Index(tmpl[pos:], open); i >= 0 && (next < 0 || pos+i < next) {
+			next = pos + i
+		}
+	}
+	return next
+}
+
+// jinjaTagEnd returns the index of the delimiter that closes a Jinja tag whose
+// content starts at pos. Delimiters in strings and brackets don't close tags.
+func jinjaTagEnd(tmpl string, pos int, closing string) (int, error) {
+	var brackets []byte
+	var quote byte
+	for i := pos; i < len(tmpl); i++ {
+		c := tmpl[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if len(brackets) == 0 && strings.HasPrefix(tmpl[i:], closing) {
+			return i, nil
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+		case '(', '[', '{':
+			brackets = append(brackets, c)
+		case ')', ']', '}':
+			if len(brackets) == 0 || brackets[len(brackets)-1] != matchingBracket(c) {
+				return 0, fmt.Errorf("unbalanced %q", c)
+			}
+			brackets = brackets[:len(brackets)-1]
+		}
+	}
+	if quote != 0 {
+		return 0, fmt.Errorf("string isn't closed")
+	}
+	if len(brackets) > 0 {
+		return 0, fmt.Errorf("unbalanced %q", brackets[len(brackets)-1])
+	}
+	return 0, fmt.Errorf("tag isn't closed with %q", closing)
+}
+
+// matchingBracket returns the opening bracket of a closing bracket.
+func matchingBracket(c byte) byte {
+	switch c {
+	case ')':
+		return '('
+	case ']':
+		return '['
+	default:
+		return '{'
+	}
+}
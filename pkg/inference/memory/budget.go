@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// budgetedMemoryInfo limits the memory reported by a SystemMemoryInfo to a
+// budget.
+type budgetedMemoryInfo struct {
+	SystemMemoryInfo
+	totalMemory inference.RequiredMemory
+}
+
+// WithBudget limits the total memory reported by info, and thus the memory
+// that models can be loaded into, to the specified budget. A budget of zero
+// RAM or VRAM leaves the corresponding total unchanged. A budget also applies
+// if the system's memory is unknown, in which case it's assumed to be
+// accurate.
+func WithBudget(info SystemMemoryInfo, budget inference.RequiredMemory) SystemMemoryInfo {
+	if budget.RAM == 0 && budget.VRAM == 0 {
+		return info
+	}
+	totalMemory := info.GetTotalMemory()
+	totalMemory.RAM = applyBudget(totalMemory.RAM, budget.RAM)
+	totalMemory.VRAM = applyBudget(totalMemory.VRAM, budget.VRAM)
+	return &budgetedMemoryInfo{SystemMemoryInfo: info, totalMemory: totalMemory}
+}
+
+// applyBudget limits a memory size to a budget. The sentinel size 1 indicates
+// that the size is unknown.
+func applyBudget(size, budget uint64) uint64 {
+	if budget == 0 {
+		return size
+	}
+	if size == 1 {
+		return budget
+	}
+	return min(size, budget)
+}
+
+func (b *budgetedMemoryInfo) HaveSufficientMemory(req inference.RequiredMemory) (bool, error) {
+	return haveSufficientMemory(b.totalMemory, req)
+}
+
+func (b *budgetedMemoryInfo) GetTotalMemory() inference.RequiredMemory {
+	return b.totalMemory
+}
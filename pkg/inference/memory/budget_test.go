@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+const gb = 1024 * 1024 * 1024
+
+func TestWithBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    inference.RequiredMemory
+		budget   inference.RequiredMemory
+		expected inference.RequiredMemory
+	}{
+		{
+			name:     "no budget",
+			total:    inference.RequiredMemory{RAM: 32 * gb, VRAM: 24 * gb},
+			expected: inference.RequiredMemory{RAM: 32 * gb, VRAM: 24 * gb},
+		},
+		{
+			name:     "VRAM budget",
+			total:    inference.RequiredMemory{RAM: 32 * gb, VRAM: 24 * gb},
+			budget:   inference.RequiredMemory{VRAM: 16 * gb},
+			expected: inference.RequiredMemory{RAM: 32 * gb, VRAM: 16 * gb},
+		},
+		{
+			name:     "budget beyond system memory",
+			total:    inference.RequiredMemory{RAM: 32 * gb, VRAM: 24 * gb},
+			budget:   inference.RequiredMemory{RAM: 64 * gb, VRAM: 48 * gb},
+			expected: inference.RequiredMemory{RAM: 32 * gb, VRAM: 24 * gb},
+		},
+		{
+			name:     "budget for unknown VRAM",
+			total:    inference.RequiredMemory{RAM: 32 * gb, VRAM: 1},
+			budget:   inference.RequiredMemory{VRAM: 8 * gb},
+			expected: inference.RequiredMemory{RAM: 32 * gb, VRAM: 8 * gb},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := WithBudget(&systemMemoryInfo{totalMemory: tt.total}, tt.budget)
+			if got := info.GetTotalMemory(); got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+			if ok, err := info.HaveSufficientMemory(tt.expected); err != nil || !ok {
+				t.Errorf("expected budget to fit, got %v, %v", ok, err)
+			}
+			tooBig := inference.RequiredMemory{RAM: tt.expected.RAM, VRAM: tt.expected.VRAM + 1}
+			if ok, _ := info.HaveSufficientMemory(tooBig); ok {
+				t.Error("expected memory beyond budget not to fit")
+			}
+		})
+	}
+}
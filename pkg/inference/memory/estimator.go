@@ -10,8 +10,13 @@ import (
 
 type MemoryEstimator interface {
 	SetDefaultBackend(MemoryEstimatorBackend)
+	AddBackend(name string, backend MemoryEstimatorBackend)
 	GetRequiredMemoryForModel(context.Context, string, *inference.BackendConfiguration) (inference.RequiredMemory, error)
 	HaveSufficientMemoryForModel(ctx context.Context, model string, config *inference.BackendConfiguration) (bool, inference.RequiredMemory, inference.RequiredMemory, error)
+	// GetRequiredMemoryByBackend returns the memory that each backend added
+	// with AddBackend estimates it needs to run a model. Backends that can't
+	// estimate it, such as those that can't run the model, are omitted.
+	GetRequiredMemoryByBackend(ctx context.Context, model string, config *inference.BackendConfiguration) map[string]inference.RequiredMemory
 }
 
 type MemoryEstimatorBackend interface {
@@ -21,6 +26,8 @@ type MemoryEstimatorBackend interface {
 type memoryEstimator struct {
 	systemMemoryInfo SystemMemoryInfo
 	defaultBackend   MemoryEstimatorBackend
+	// backends are the backends that estimate memory by backend, by name.
+	backends map[string]MemoryEstimatorBackend
 }
 
 func NewEstimator(systemMemoryInfo SystemMemoryInfo) MemoryEstimator {
@@ -31,6 +38,13 @@ func (m *memoryEstimator) SetDefaultBackend(backend MemoryEstimatorBackend) {
 	m.defaultBackend = backend
 }
 
+func (m *memoryEstimator) AddBackend(name string, backend MemoryEstimatorBackend) {
+	if m.backends == nil {
+		m.backends = make(map[string]MemoryEstimatorBackend)
+	}
+	m.backends[name] = backend
+}
+
 func (m *memoryEstimator) GetRequiredMemoryForModel(ctx context.Context, model string, config *inference.BackendConfiguration) (inference.RequiredMemory, error) {
 	if m.defaultBackend == nil {
 		return inference.RequiredMemory{}, errors.New("default backend not configured")
@@ -51,3 +65,17 @@ func (m *memoryEstimator) HaveSufficientMemoryForModel(ctx context.Context, mode
 	}
 	return ok, req, m.systemMemoryInfo.GetTotalMemory(), nil
 }
+
+func (m *memoryEstimator) GetRequiredMemoryByBackend(ctx context.Context, model string, config *inference.BackendConfiguration) map[string]inference.RequiredMemory {
+	required := make(map[string]inference.RequiredMemory)
+	for name, backend := range m.backends {
+		req, err := backend.GetRequiredMemoryForModel(ctx, model, config)
+		// Backends estimate a single byte of the memory they can't estimate,
+		// and no memory at all if they run models elsewhere.
+		if err != nil || (req.RAM <= 1 && req.VRAM <= 1) {
+			continue
+		}
+		required[name] = req
+	}
+	return required
+}
@@ -12,11 +12,15 @@ import (
 type SystemMemoryInfo interface {
 	HaveSufficientMemory(inference.RequiredMemory) (bool, error)
 	GetTotalMemory() inference.RequiredMemory
+	// GetGPUDevices returns the individual GPUs on the system. It returns nil
+	// if they can't be enumerated.
+	GetGPUDevices() []gpuinfo.Device
 }
 
 type systemMemoryInfo struct {
 	log         logging.Logger
 	totalMemory inference.RequiredMemory
+	devices     []gpuinfo.Device
 }
 
 func NewSystemMemoryInfo(log logging.Logger, gpuInfo *gpuinfo.GPUInfo) (SystemMemoryInfo, error) {
@@ -29,6 +33,18 @@ func NewSystemMemoryInfo(log logging.Logger, gpuInfo *gpuinfo.GPUInfo) (SystemMe
 	} else {
 		log.Infof("Running on system with %d MB VRAM", vramSize/1024/1024)
 	}
+	devices, err := gpuInfo.GetDevices()
+	if err != nil {
+		log.Debugf("Could not enumerate GPUs: %s", err)
+		devices = nil
+	} else if len(devices) > 1 {
+		// Models can be split across GPUs, so account for all of them.
+		vramSize = 0
+		for _, device := range devices {
+			vramSize += device.VRAM
+		}
+		log.Infof("Running on system with %d GPUs and %d MB total VRAM", len(devices), vramSize/1024/1024)
+	}
 	ramSize := uint64(1)
 	hostInfo, err := sysinfo.Host()
 	if err != nil {
@@ -45,20 +61,31 @@ func NewSystemMemoryInfo(log logging.Logger, gpuInfo *gpuinfo.GPUInfo) (SystemMe
 	return &systemMemoryInfo{
 		log:         log,
 		totalMemory: inference.RequiredMemory{RAM: ramSize, VRAM: vramSize},
+		devices:     devices,
 	}, nil
 }
 
 func (s *systemMemoryInfo) HaveSufficientMemory(req inference.RequiredMemory) (bool, error) {
+	return haveSufficientMemory(s.totalMemory, req)
+}
+
+// haveSufficientMemory returns true if the required memory fits in the total
+// memory.
+func haveSufficientMemory(totalMemory, req inference.RequiredMemory) (bool, error) {
 	// Sentinel value of 1 indicates unknown RAM/VRAM
-	if req.RAM > 1 && s.totalMemory.RAM == 1 {
+	if req.RAM > 1 && totalMemory.RAM == 1 {
 		return false, errors.New("system RAM unknown")
 	}
-	if req.VRAM > 1 && s.totalMemory.VRAM == 1 {
+	if req.VRAM > 1 && totalMemory.VRAM == 1 {
 		return false, errors.New("system VRAM unknown")
 	}
-	return req.RAM <= s.totalMemory.RAM && req.VRAM <= s.totalMemory.VRAM, nil
+	return req.RAM <= totalMemory.RAM && req.VRAM <= totalMemory.VRAM, nil
 }
 
 func (s *systemMemoryInfo) GetTotalMemory() inference.RequiredMemory {
 	return s.totalMemory
 }
+
+func (s *systemMemoryInfo) GetGPUDevices() []gpuinfo.Device {
+	return s.devices
+}
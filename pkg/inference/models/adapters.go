@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+// AdapterSeparator separates a base model from the adapters that are applied
+// to it in model names, such as "ai/llama3.2+myorg/llama3.2-pirate". Several
+// adapters can be applied to the same base model.
+const AdapterSeparator = "+"
+
+// ErrNotAdapter indicates that a model that's applied to a base model isn't an
+// adapter artifact.
+var ErrNotAdapter = errors.New("model is not an adapter")
+
+// SplitAdapters splits a model name into the base model and the adapters that
+// are applied to it, in order.
+func SplitAdapters(name string) (string, []string) {
+	parts := strings.Split(name, AdapterSeparator)
+	return parts[0], parts[1:]
+}
+
+// getAdapter returns an adapter artifact from the store. It returns
+// ErrNotAdapter if the model isn't an adapter.
+func (m *Manager) getAdapter(ref string) (types.Model, error) {
+	adapter, err := m.getLocal(ref)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := adapter.AdapterPath(); err != nil {
+		return nil, fmt.Errorf("%s: %w", utils.SanitizeForLog(ref, -1), ErrNotAdapter)
+	}
+	return adapter, nil
+}
+
+// GetAdapterBundles returns the bundles of the adapters that a model name,
+// such as base+adapter, applies to its base model, in order. It returns no
+// bundles for the names of models without adapters.
+func (m *Manager) GetAdapterBundles(ref string) ([]types.ModelBundle, error) {
+	_, adapters := SplitAdapters(ref)
+	bundles := make([]types.ModelBundle, 0, len(adapters))
+	for _, name := range adapters {
+		adapter, err := m.getAdapter(name)
+		if err != nil {
+			return nil, err
+		}
+		id, err := adapter.ID()
+		if err != nil {
+			return nil, fmt.Errorf("error while getting adapter ID: %w", err)
+		}
+		bundle, err := m.distributionClient.GetBundle(id)
+		if err != nil {
+			return nil, fmt.Errorf("error while getting adapter bundle: %w", err)
+		}
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
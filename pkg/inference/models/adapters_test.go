@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/builder"
+	"github.com/docker/model-runner/pkg/distribution/distribution"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSplitAdapters(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		adapters []string
+	}{
+		{"ai/model", "ai/model", nil},
+		{"ai/model+ai/adapter", "ai/model", []string{"ai/adapter"}},
+		{"ai/model:latest+ai/a:v1+ai/b", "ai/model:latest", []string{"ai/a:v1", "ai/b"}},
+	}
+	for _, tt := range tests {
+		base, adapters := SplitAdapters(tt.name)
+		if base != tt.base || strings.Join(adapters, ",") != strings.Join(tt.adapters, ",") {
+			t.Errorf("SplitAdapters(%q) = %q, %q, expected %q, %q", tt.name, base, adapters, tt.base, tt.adapters)
+		}
+	}
+}
+
+func TestAdapters(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	manager := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log})
+	baseID := loadF16Model(t, manager)
+
+	b, err := builder.FromAdapter(writeGGUF(t, 1))
+	if err != nil {
+		t.Fatalf("Failed to create adapter builder: %v", err)
+	}
+	if err := manager.distributionClient.WriteModel(b.Model(), []string{"test/adapter:latest"}, io.Discard); err != nil {
+		t.Fatalf("Failed to write adapter: %v", err)
+	}
+	adapterID, err := b.Model().ID()
+	if err != nil {
+		t.Fatalf("Failed to get adapter ID: %v", err)
+	}
+
+	// Names with adapters resolve to the base model, and to a runner ID of
+	// their own.
+	model, err := manager.GetLocal("test/model+test/adapter")
+	if err != nil {
+		t.Fatalf("GetLocal failed: %v", err)
+	}
+	if id, _ := model.ID(); id != baseID {
+		t.Errorf("Expected the base model %s, got %s", baseID, id)
+	}
+	if id := manager.ResolveID("test/model+test/adapter"); id != baseID+"+"+adapterID {
+		t.Errorf("Expected ID %s+%s, got %s", baseID, adapterID, id)
+	}
+	if inStore, err := manager.InStore("test/model+test/adapter"); err != nil || !inStore {
+		t.Errorf("Expected the model and its adapter to be in the store, got %v (error %v)", inStore, err)
+	}
+
+	bundle, err := manager.GetBundle(baseID + "+" + adapterID)
+	if err != nil {
+		t.Fatalf("GetBundle failed: %v", err)
+	}
+	if bundle.GGUFPath() == "" || bundle.AdapterPath() != "" {
+		t.Errorf("Expected the bundle of the base model, got GGUF %q and adapter %q", bundle.GGUFPath(), bundle.AdapterPath())
+	}
+	adapters, err := manager.GetAdapterBundles(baseID + "+" + adapterID)
+	if err != nil {
+		t.Fatalf("GetAdapterBundles failed: %v", err)
+	}
+	if len(adapters) != 1 || adapters[0].AdapterPath() == "" {
+		t.Fatalf("Expected the bundle of the adapter, got %+v", adapters)
+	}
+	if adapters, err := manager.GetAdapterBundles(baseID); err != nil || len(adapters) != 0 {
+		t.Errorf("Expected no adapters for the base model, got %d (error %v)", len(adapters), err)
+	}
+
+	// Only adapter artifacts can be applied to a model.
+	if _, err := manager.GetLocal("test/adapter+test/model"); !errors.Is(err, ErrNotAdapter) {
+		t.Errorf("Expected ErrNotAdapter for a model applied as an adapter, got %v", err)
+	}
+	if _, err := manager.GetLocal("test/model+test/missing"); !errors.Is(err, distribution.ErrModelNotFound) {
+		t.Errorf("Expected ErrModelNotFound for a missing adapter, got %v", err)
+	}
+}
@@ -2,8 +2,10 @@ package models
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference/chattemplate"
 )
 
 // ModelCreateRequest represents a model create request. It is designed to
@@ -19,6 +21,57 @@ type ModelCreateRequest struct {
 	IgnoreRuntimeMemoryCheck bool `json:"ignore-runtime-memory-check,omitempty"`
 	// BearerToken is an optional bearer token for authentication.
 	BearerToken string `json:"bearer-token,omitempty"`
+	// Auth holds the registry credentials to pull with, if set. Otherwise,
+	// the credentials found in the Docker configuration of the model runner
+	// are used.
+	Auth *RegistryAuth `json:"auth,omitempty"`
+}
+
+// ModelPushRequest represents a model push request. Its body is optional: by
+// default, models are pushed to the reference they're identified by, with the
+// credentials found in the Docker configuration of the model runner.
+type ModelPushRequest struct {
+	// Destination is the reference to push the model to, if it differs from
+	// the reference of the model being pushed.
+	Destination string `json:"destination,omitempty"`
+	// Auth holds the registry credentials to push with, if set.
+	Auth *RegistryAuth `json:"auth,omitempty"`
+}
+
+// ModelTag associates a tag with the ID of the model that it points to.
+type ModelTag struct {
+	// Tag is the fully qualified tag, such as "ai/smollm2:latest".
+	Tag string `json:"tag"`
+	// ID is the ID of the model that the tag points to.
+	ID string `json:"id"`
+}
+
+// PinnedDigest is the manifest digest that a tag was pinned to when it was
+// first pulled.
+type PinnedDigest struct {
+	// Tag is the tag, such as "ai/smollm2:latest".
+	Tag string `json:"tag"`
+	// Digest is the manifest digest that the tag was pinned to.
+	Digest string `json:"digest"`
+}
+
+// ModelTagRequest represents a request to point a tag at a model.
+type ModelTagRequest struct {
+	// Model is the reference or ID of the model to point the tag at.
+	Model string `json:"model"`
+}
+
+// RegistryAuth holds registry credentials, as stored by Docker credential
+// helpers.
+type RegistryAuth struct {
+	// Username is the registry username.
+	Username string `json:"username,omitempty"`
+	// Password is the registry password.
+	Password string `json:"password,omitempty"`
+	// IdentityToken is a refresh token used to obtain registry tokens.
+	IdentityToken string `json:"identity-token,omitempty"`
+	// RegistryToken is a bearer token sent to the registry.
+	RegistryToken string `json:"registry-token,omitempty"`
 }
 
 // ModelPackageRequest represents a model package request, which creates a new model
@@ -32,6 +85,258 @@ type ModelPackageRequest struct {
 	ContextSize uint64 `json:"context-size,omitempty"`
 }
 
+// ModelImportRequest represents a request to import a model from the model
+// runner's filesystem into the store.
+type ModelImportRequest struct {
+	// Path is the absolute path of a GGUF file, or of a directory containing
+	// GGUF or safetensors weights.
+	Path string `json:"path"`
+	// Tag is the name to give the imported model.
+	Tag string `json:"tag"`
+	// ContextSize specifies the context size of the imported model. If zero,
+	// the context size that the model was trained with is used, if known.
+	ContextSize uint64 `json:"context-size,omitempty"`
+}
+
+// ModelConvertRequest represents a request to convert a safetensors model to
+// GGUF. Its body is optional.
+type ModelConvertRequest struct {
+	// OutputType is the weight type of the GGUF file, such as "f16" or
+	// "q8_0". If empty, the converter's default is used.
+	OutputType string `json:"output-type,omitempty"`
+}
+
+// HubFile describes a file in a Hugging Face Hub repository.
+type HubFile struct {
+	// Path is the file's path in the repository.
+	Path string `json:"path"`
+	// Size is the file's size in bytes.
+	Size int64 `json:"size"`
+	// Selected is true if pulling the model downloads the file.
+	Selected bool `json:"selected"`
+}
+
+// HubFileList describes the files in the Hugging Face Hub repository of a
+// model reference.
+type HubFileList struct {
+	// Model is the model reference.
+	Model string `json:"model"`
+	// Files are the files in the repository.
+	Files []HubFile `json:"files"`
+}
+
+// PullStatus is the status of a pull operation.
+type PullStatus string
+
+const (
+	// PullStatusQueued indicates that a pull waits for other pulls to
+	// finish.
+	PullStatusQueued PullStatus = "queued"
+	// PullStatusPulling indicates that a pull is in progress.
+	PullStatusPulling PullStatus = "pulling"
+	// PullStatusSucceeded indicates that a pull succeeded.
+	PullStatusSucceeded PullStatus = "succeeded"
+	// PullStatusFailed indicates that a pull failed.
+	PullStatusFailed PullStatus = "failed"
+	// PullStatusCanceled indicates that a pull was canceled.
+	PullStatusCanceled PullStatus = "canceled"
+)
+
+// PullLayer describes the download progress of a layer or file of a model.
+type PullLayer struct {
+	// ID identifies the layer, such as by its digest.
+	ID string `json:"id"`
+	// Size is the size of the layer in bytes.
+	Size uint64 `json:"size"`
+	// Downloaded is the number of bytes of the layer downloaded so far.
+	Downloaded uint64 `json:"downloaded"`
+	// Cached is true if the layer is already in the store, so it isn't
+	// downloaded.
+	Cached bool `json:"cached,omitempty"`
+	// Speed is the current download speed of the layer, in bytes per second.
+	Speed uint64 `json:"speed"`
+	// ETA is the estimated number of seconds until the layer is downloaded,
+	// if known.
+	ETA int64 `json:"eta,omitempty"`
+}
+
+// PullOperation describes a pull started with POST /models/pulls, and the
+// progress of its download.
+type PullOperation struct {
+	// ID identifies the pull.
+	ID string `json:"id"`
+	// Model is the model being pulled.
+	Model string `json:"model"`
+	// Status is the status of the pull.
+	Status PullStatus `json:"status"`
+	// Message is the latest status message of the pull, such as a warning.
+	Message string `json:"message,omitempty"`
+	// Error describes why the pull failed.
+	Error string `json:"error,omitempty"`
+	// Total is the number of bytes that the pull downloads, excluding the
+	// layers already in the store.
+	Total uint64 `json:"total"`
+	// Downloaded is the number of bytes downloaded so far.
+	Downloaded uint64 `json:"downloaded"`
+	// Speed is the current download speed, in bytes per second.
+	Speed uint64 `json:"speed"`
+	// ETA is the estimated number of seconds until the pull is downloaded, if
+	// known.
+	ETA int64 `json:"eta,omitempty"`
+	// Layers describes the progress of each layer, in the order in which
+	// their downloads started.
+	Layers []PullLayer `json:"layers"`
+	// Started is when the pull was started.
+	Started time.Time `json:"started"`
+	// Finished is when the pull finished, if it did.
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// ChatTemplateSource is where the chat template of a model comes from.
+type ChatTemplateSource string
+
+const (
+	// ChatTemplateSourceSettings indicates a chat template stored in the
+	// settings of the model, which overrides its own.
+	ChatTemplateSourceSettings ChatTemplateSource = "settings"
+	// ChatTemplateSourceArtifact indicates the chat template layer of the
+	// model's artifact.
+	ChatTemplateSourceArtifact ChatTemplateSource = "artifact"
+	// ChatTemplateSourceGGUF indicates the chat template in the metadata of
+	// the model's GGUF file.
+	ChatTemplateSourceGGUF ChatTemplateSource = "gguf"
+)
+
+// ChatTemplate is the chat template that a model is run with.
+type ChatTemplate struct {
+	// Template is the template.
+	Template string `json:"template"`
+	// Format is the format of the template.
+	Format types.ChatTemplateFormat `json:"format"`
+	// Source is where the template comes from.
+	Source ChatTemplateSource `json:"source"`
+}
+
+// ChatTemplateRequest represents a request to override the chat template of a
+// model, or to validate a chat template.
+type ChatTemplateRequest struct {
+	// Template is the chat template.
+	Template string `json:"template"`
+	// Messages are the messages that Go templates are rendered for when
+	// they're validated, if any.
+	Messages []chattemplate.Message `json:"messages,omitempty"`
+}
+
+// ChatTemplateValidation is the result of validating a chat template.
+type ChatTemplateValidation struct {
+	// Valid indicates whether the template is valid.
+	Valid bool `json:"valid"`
+	// Format is the format of the template, if it's valid.
+	Format types.ChatTemplateFormat `json:"format,omitempty"`
+	// Error describes why the template is invalid.
+	Error string `json:"error,omitempty"`
+	// Prompt is the prompt that a valid Go template renders for the messages
+	// of the request, if any.
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// ModelInspection describes the capabilities of a model, as gathered from the
+// metadata of its GGUF or safetensors weights, so that clients can choose
+// between models. Fields that can't be determined are omitted.
+type ModelInspection struct {
+	// ID is the globally unique model identifier.
+	ID string `json:"id"`
+	// Tags are the list of tags associated with the model.
+	Tags []string `json:"tags,omitempty"`
+	// Format is the format of the model's weights.
+	Format types.Format `json:"format,omitempty"`
+	// Architecture is the architecture of the model, such as "llama".
+	Architecture string `json:"architecture,omitempty"`
+	// Parameters is the number of parameters of the model, such as "1.5B".
+	Parameters string `json:"parameters,omitempty"`
+	// Quantization is the quantization of the model's weights, such as
+	// "Q4_K_M".
+	Quantization string `json:"quantization,omitempty"`
+	// ContextLength is the context length that the model was trained with.
+	ContextLength uint64 `json:"context-length,omitempty"`
+	// ContextSize is the context size that the model is run with, if it's
+	// configured by its artifact or its settings.
+	ContextSize uint64 `json:"context-size,omitempty"`
+	// Modalities are the modalities of the model's inputs and outputs.
+	Modalities ModelModalities `json:"modalities"`
+	// Tokenizer describes the tokenizer of the model.
+	Tokenizer *ModelTokenizer `json:"tokenizer,omitempty"`
+	// License describes the license of the model.
+	License *ModelLicense `json:"license,omitempty"`
+	// RequiredMemory is the memory that each backend that can run the model
+	// estimates it needs to, by backend name.
+	RequiredMemory map[string]ModelMemory `json:"required-memory,omitempty"`
+}
+
+// ModelModalities are the modalities of the inputs and outputs of a model:
+// "text", "image", "audio", or "embedding".
+type ModelModalities struct {
+	// Input are the modalities of the model's inputs.
+	Input []string `json:"input"`
+	// Output are the modalities of the model's outputs.
+	Output []string `json:"output"`
+}
+
+// ModelTokenizer describes the tokenizer of a model.
+type ModelTokenizer struct {
+	// Model is the tokenizer model of GGUF files, such as "gpt2", or the
+	// tokenizer class of safetensors models, such as "LlamaTokenizer".
+	Model string `json:"model,omitempty"`
+	// Pre is the pre-tokenizer of GGUF files, such as "llama-bpe".
+	Pre string `json:"pre,omitempty"`
+	// VocabSize is the size of the tokenizer's vocabulary.
+	VocabSize uint64 `json:"vocab-size,omitempty"`
+	// ChatTemplate indicates whether the model has a chat template.
+	ChatTemplate bool `json:"chat-template"`
+}
+
+// ModelLicense describes the license of a model.
+type ModelLicense struct {
+	// Name is the license identifier from the model's metadata, such as
+	// "apache-2.0".
+	Name string `json:"name,omitempty"`
+	// Text is the text of the license packaged with the model's artifact.
+	Text string `json:"text,omitempty"`
+}
+
+// ModelMemory is the memory required to run a model, in bytes.
+type ModelMemory struct {
+	// RAM is the system memory required.
+	RAM uint64 `json:"ram"`
+	// VRAM is the GPU memory required.
+	VRAM uint64 `json:"vram"`
+}
+
+// LicenseStatus describes the license of a model, and whether it must be
+// accepted before the model is served.
+type LicenseStatus struct {
+	// Model is the ID of the model.
+	Model string `json:"model"`
+	// License is the identifier of the license from the model's metadata,
+	// such as "llama3.2", if it records one.
+	License string `json:"license,omitempty"`
+	// Text is the text of the license files packaged with the model.
+	Text string `json:"text,omitempty"`
+	// Gated indicates whether the model is only served once its license is
+	// accepted.
+	Gated bool `json:"gated"`
+	// Acceptance is the acceptance of the license, if it was accepted.
+	Acceptance *types.LicenseAcceptance `json:"acceptance,omitempty"`
+}
+
+// LicenseAcceptRequest represents a request to accept the license of a model.
+// Its body is optional.
+type LicenseAcceptRequest struct {
+	// AcceptedBy identifies who accepts the license, for audits. If empty,
+	// the user agent of the request is recorded.
+	AcceptedBy string `json:"accepted-by,omitempty"`
+}
+
 // SimpleModel is a wrapper that allows creating a model with modified configuration
 type SimpleModel struct {
 	types.Model
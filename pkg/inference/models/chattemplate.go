@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference/chattemplate"
+)
+
+// ggufChatTemplateKey is the metadata key of the chat template of GGUF files.
+const ggufChatTemplateKey = "tokenizer.chat_template"
+
+// ErrNoChatTemplate indicates that a model has no chat template.
+var ErrNoChatTemplate = errors.New("model has no chat template")
+
+// GetChatTemplate returns the chat template that a model is run with: the one
+// stored in its settings, if any, or else the one of its artifact, or else the
+// one in the metadata of its GGUF file. It returns ErrNoChatTemplate if the
+// model has none.
+func (m *Manager) GetChatTemplate(ref string) (ChatTemplate, error) {
+	if m.distributionClient == nil {
+		return ChatTemplate{}, errors.New("model distribution service unavailable")
+	}
+	settings, err := m.GetSettings(ref)
+	if err != nil {
+		return ChatTemplate{}, err
+	}
+	if settings.ChatTemplate != "" {
+		return ChatTemplate{
+			Template: settings.ChatTemplate,
+			Format:   settings.ChatTemplateFormat,
+			Source:   ChatTemplateSourceSettings,
+		}, nil
+	}
+
+	model, err := m.settingsModel(ref)
+	if err != nil {
+		return ChatTemplate{}, err
+	}
+	if path, err := model.ChatTemplatePath(); err == nil {
+		template, err := os.ReadFile(path)
+		if err != nil {
+			return ChatTemplate{}, fmt.Errorf("reading chat template: %w", err)
+		}
+		return ChatTemplate{
+			Template: string(template),
+			Format:   chattemplate.Detect(string(template)),
+			Source:   ChatTemplateSourceArtifact,
+		}, nil
+	}
+	config, err := model.Config()
+	if err != nil {
+		return ChatTemplate{}, fmt.Errorf("reading model config: %w", err)
+	}
+	if template := config.GGUF[ggufChatTemplateKey]; template != "" {
+		return ChatTemplate{
+			Template: template,
+			Format:   chattemplate.Detect(template),
+			Source:   ChatTemplateSourceGGUF,
+		}, nil
+	}
+	return ChatTemplate{}, ErrNoChatTemplate
+}
+
+// SetChatTemplate stores a chat template in the settings of a model, which
+// overrides its own once it's validated, and returns it.
+func (m *Manager) SetChatTemplate(ref string, template string) (ChatTemplate, error) {
+	settings, err := m.GetSettings(ref)
+	if err != nil {
+		return ChatTemplate{}, err
+	}
+	settings.ChatTemplate = template
+	if settings, err = m.SetSettings(ref, settings); err != nil {
+		return ChatTemplate{}, err
+	}
+	return ChatTemplate{
+		Template: settings.ChatTemplate,
+		Format:   settings.ChatTemplateFormat,
+		Source:   ChatTemplateSourceSettings,
+	}, nil
+}
+
+// ResetChatTemplate removes the chat template stored in the settings of a
+// model, which is then run with its own.
+func (m *Manager) ResetChatTemplate(ref string) error {
+	settings, err := m.GetSettings(ref)
+	if err != nil {
+		return err
+	}
+	settings.ChatTemplate = ""
+	_, err = m.SetSettings(ref, settings)
+	return err
+}
+
+// ValidateChatTemplate validates a chat template, rendering Go templates for
+// the messages of the request, if any.
+func ValidateChatTemplate(request ChatTemplateRequest) ChatTemplateValidation {
+	format, err := chattemplate.Validate(request.Template)
+	if err != nil {
+		return ChatTemplateValidation{Error: err.Error()}
+	}
+	validation := ChatTemplateValidation{Valid: true, Format: format}
+	if len(request.Messages) > 0 && format == types.ChatTemplateFormatGo {
+		if validation.Prompt, err = chattemplate.Render(request.Template, request.Messages); err != nil {
+			return ChatTemplateValidation{Format: format, Error: err.Error()}
+		}
+	}
+	return validation
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference/chattemplate"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestChatTemplate(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	manager := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log})
+	loadF16Model(t, manager)
+
+	if _, err := manager.GetChatTemplate("test/model"); !errors.Is(err, ErrNoChatTemplate) {
+		t.Fatalf("Expected ErrNoChatTemplate, got %v", err)
+	}
+	if _, err := manager.SetChatTemplate("test/model", "{% if x %}"); !errors.Is(err, ErrInvalidSettings) {
+		t.Errorf("Expected ErrInvalidSettings, got %v", err)
+	}
+
+	// Overrides are stored with the other settings of the model, with their
+	// format.
+	contextSize := uint64(2048)
+	if _, err := manager.SetSettings("test/model", types.ModelSettings{ContextSize: &contextSize}); err != nil {
+		t.Fatalf("Failed to set settings: %v", err)
+	}
+	goTemplate := "{{ range .Messages }}{{ .Role }}: {{ .Content }}\n{{ end }}"
+	template, err := manager.SetChatTemplate("test/model+test/adapter", goTemplate)
+	if err != nil {
+		t.Fatalf("Failed to set chat template: %v", err)
+	}
+	expected := ChatTemplate{Template: goTemplate, Format: types.ChatTemplateFormatGo, Source: ChatTemplateSourceSettings}
+	if template != expected {
+		t.Errorf("Expected chat template %+v, got %+v", expected, template)
+	}
+	if template, err := manager.GetChatTemplate("test/model"); err != nil || template != expected {
+		t.Errorf("Expected chat template %+v, got %+v (error %v)", expected, template, err)
+	}
+	settings, err := manager.GetSettings("test/model")
+	if err != nil {
+		t.Fatalf("Failed to get settings: %v", err)
+	}
+	if settings.ChatTemplateFormat != types.ChatTemplateFormatGo || settings.ContextSize == nil || *settings.ContextSize != contextSize {
+		t.Errorf("Unexpected settings %+v", settings)
+	}
+
+	// Resetting the override keeps the other settings.
+	if err := manager.ResetChatTemplate("test/model"); err != nil {
+		t.Fatalf("Failed to reset chat template: %v", err)
+	}
+	if _, err := manager.GetChatTemplate("test/model"); !errors.Is(err, ErrNoChatTemplate) {
+		t.Errorf("Expected ErrNoChatTemplate, got %v", err)
+	}
+	if settings, err := manager.GetSettings("test/model"); err != nil || settings.ChatTemplate != "" || settings.ContextSize == nil {
+		t.Errorf("Unexpected settings %+v (error %v)", settings, err)
+	}
+}
+
+func TestValidateChatTemplate(t *testing.T) {
+	validation := ValidateChatTemplate(ChatTemplateRequest{
+		Template: "{{ range .Messages }}{{ .Role }}: {{ .Content }}\n{{ end }}",
+		Messages: []chattemplate.Message{{Role: "user", Content: "Hi"}},
+	})
+	if !validation.Valid || validation.Format != types.ChatTemplateFormatGo || validation.Prompt != "user: Hi\n" {
+		t.Errorf("Unexpected validation %+v", validation)
+	}
+	validation = ValidateChatTemplate(ChatTemplateRequest{Template: "{% for m in messages %}"})
+	if validation.Valid || validation.Error == "" {
+		t.Errorf("Expected the template to be invalid, got %+v", validation)
+	}
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+const (
+	// convertedGGUFFile is the name of the GGUF file that safetensors models
+	// are converted to, in the model directory of their bundle. Bundles are
+	// parsed by looking for GGUF files, so the converted file is found next
+	// to the safetensors files that it was converted from.
+	convertedGGUFFile = "model.gguf"
+	// maximumConverterOutput is the maximum number of bytes of converter
+	// output included in conversion errors.
+	maximumConverterOutput = 4096
+)
+
+var (
+	// ErrConversionUnavailable indicates that no GGUF converter is configured.
+	ErrConversionUnavailable = errors.New("model conversion is unavailable: no GGUF converter is configured")
+	// ErrConversionUnsupported indicates that a model can't be converted,
+	// because it doesn't consist of safetensors weights.
+	ErrConversionUnsupported = errors.New("only safetensors models can be converted to GGUF")
+	// ErrInvalidOutputType indicates that a conversion's output type isn't
+	// supported.
+	ErrInvalidOutputType = errors.New("unsupported GGUF output type")
+)
+
+// ggufOutputTypes are the weight types that models can be converted to. The
+// empty type uses the converter's default.
+var ggufOutputTypes = []string{"", "auto", "f32", "f16", "bf16", "q8_0"}
+
+// ConvertToGGUF converts a safetensors model to GGUF and places the result in
+// the model's bundle, so that the model can be served by llama.cpp as well as
+// by backends that serve safetensors models. Models that already have GGUF
+// weights aren't converted again. It returns the path of the GGUF file.
+func (m *Manager) ConvertToGGUF(ctx context.Context, ref string, outputType string) (string, error) {
+	if m.distributionClient == nil {
+		return "", errors.New("model distribution service unavailable")
+	}
+	if m.ggufConverter == "" {
+		return "", ErrConversionUnavailable
+	}
+	if !slices.Contains(ggufOutputTypes, outputType) {
+		return "", fmt.Errorf("%w %q, expected one of %s",
+			ErrInvalidOutputType, outputType, strings.Join(ggufOutputTypes[1:], ", "))
+	}
+
+	// Conversions use a lot of memory and disk I/O, so run one at a time.
+	m.conversionLock.Lock()
+	defer m.conversionLock.Unlock()
+
+	model, err := m.GetLocal(ref)
+	if err != nil {
+		return "", err
+	}
+	id, err := model.ID()
+	if err != nil {
+		return "", fmt.Errorf("getting model ID: %w", err)
+	}
+	bundle, err := m.GetBundle(id)
+	if err != nil {
+		return "", err
+	}
+	if path := bundle.GGUFPath(); path != "" {
+		return path, nil
+	}
+	if bundle.SafetensorsPath() == "" {
+		return "", ErrConversionUnsupported
+	}
+
+	// The converter reads the weights along with the configuration and
+	// tokenizer files, which are unpacked to the same directory. It writes to
+	// a hidden file, which isn't mistaken for the GGUF weights of the bundle
+	// until the conversion completes.
+	modelDir := filepath.Dir(bundle.SafetensorsPath())
+	outputPath := filepath.Join(modelDir, convertedGGUFFile)
+	tempPath := filepath.Join(modelDir, "."+convertedGGUFFile)
+	args := []string{modelDir, "--outfile", tempPath}
+	if outputType != "" {
+		args = append(args, "--outtype", outputType)
+	}
+	m.log.Infof("Converting model %s to GGUF", utils.SanitizeForLog(ref, -1))
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, m.ggufConverter, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		os.Remove(tempPath)
+		out := output.Bytes()
+		if len(out) > maximumConverterOutput {
+			out = out[len(out)-maximumConverterOutput:]
+		}
+		return "", fmt.Errorf("converting model to GGUF: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	if err := os.Rename(tempPath, outputPath); err != nil {
+		os.Remove(tempPath)
+		return "", fmt.Errorf("placing converted model in bundle: %w", err)
+	}
+	m.log.Infof("Converted model %s to GGUF", utils.SanitizeForLog(ref, -1))
+	return outputPath, nil
+}
+
+// HasGGUF returns true if a model has GGUF weights in its bundle, either
+// because it's a GGUF model or because it was converted to GGUF.
+func (m *Manager) HasGGUF(ref string) bool {
+	if m.distributionClient == nil {
+		return false
+	}
+	bundle, err := m.distributionClient.GetBundle(ref)
+	return err == nil && bundle.GGUFPath() != ""
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/builder"
+	"github.com/docker/model-runner/pkg/distribution/distribution"
+	"github.com/docker/model-runner/pkg/distribution/tarball"
+
+	"github.com/sirupsen/logrus"
+)
+
+// loadSafetensorsModel loads a minimal safetensors model into the manager's
+// store and returns its ID.
+func loadSafetensorsModel(t *testing.T, manager *Manager) string {
+	t.Helper()
+	header := []byte(`{"__metadata__":{"format":"pt"}}`)
+	content := binary.LittleEndian.AppendUint64(nil, uint64(len(header)))
+	content = append(content, header...)
+	path := filepath.Join(t.TempDir(), "model.safetensors")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("Failed to write safetensors file: %v", err)
+	}
+	b, err := builder.FromSafetensors([]string{path})
+	if err != nil {
+		t.Fatalf("Failed to create model builder: %v", err)
+	}
+	return loadBuiltModel(t, manager, b)
+}
+
+// loadBuiltModel loads a model built by the builder into the manager's store,
+// which must be empty, and returns its ID.
+func loadBuiltModel(t *testing.T, manager *Manager, b *builder.Builder) string {
+	t.Helper()
+	var archive bytes.Buffer
+	target, err := tarball.NewTarget(&archive)
+	if err != nil {
+		t.Fatalf("Failed to create tarball target: %v", err)
+	}
+	if err := b.Build(context.Background(), target, io.Discard); err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+	if err := manager.Load(&archive, io.Discard); err != nil {
+		t.Fatalf("Failed to load model: %v", err)
+	}
+	models, err := manager.RawList()
+	if err != nil || len(models) != 1 {
+		t.Fatalf("Expected a single model, got %d (error %v)", len(models), err)
+	}
+	id, err := models[0].ID()
+	if err != nil {
+		t.Fatalf("Failed to get model ID: %v", err)
+	}
+	return id
+}
+
+func TestConvertToGGUF(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake converter requires a POSIX shell")
+	}
+	log := logrus.NewEntry(logrus.StandardLogger())
+	gguf := filepath.Join(getProjectRoot(t), "assets", "dummy.gguf")
+
+	// The fake converter records its arguments and writes a GGUF file to the
+	// output path.
+	converterDir := t.TempDir()
+	argsPath := filepath.Join(converterDir, "args")
+	converter := filepath.Join(converterDir, "convert_hf_to_gguf.py")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %q\ncp %q \"$3\"\n", argsPath, gguf)
+	if err := os.WriteFile(converter, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write converter: %v", err)
+	}
+
+	manager := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log, GGUFConverter: converter})
+	id := loadSafetensorsModel(t, manager)
+	if manager.HasGGUF(id) {
+		t.Fatalf("Expected safetensors model not to have GGUF weights before conversion")
+	}
+
+	if _, err := manager.ConvertToGGUF(context.Background(), id, "q4_0"); !errors.Is(err, ErrInvalidOutputType) {
+		t.Fatalf("Expected invalid output type error, got %v", err)
+	}
+	path, err := manager.ConvertToGGUF(context.Background(), id, "q8_0")
+	if err != nil {
+		t.Fatalf("Failed to convert model: %v", err)
+	}
+	if filepath.Base(path) != convertedGGUFFile {
+		t.Errorf("Expected converted model at %s, got %s", convertedGGUFFile, path)
+	}
+	args, err := os.ReadFile(argsPath)
+	if err != nil {
+		t.Fatalf("Failed to read converter arguments: %v", err)
+	}
+	if expected := filepath.Dir(path) + " --outfile "; !strings.HasPrefix(string(args), expected) ||
+		!strings.HasSuffix(strings.TrimSpace(string(args)), "--outtype q8_0") {
+		t.Errorf("Unexpected converter arguments %q", args)
+	}
+
+	// The converted weights are part of the bundle, next to the safetensors
+	// weights.
+	if !manager.HasGGUF(id) {
+		t.Errorf("Expected model to have GGUF weights after conversion")
+	}
+	bundle, err := manager.GetBundle(id)
+	if err != nil {
+		t.Fatalf("Failed to get bundle: %v", err)
+	}
+	if bundle.GGUFPath() != path || bundle.SafetensorsPath() == "" {
+		t.Errorf("Expected bundle with GGUF and safetensors weights, got %q and %q", bundle.GGUFPath(), bundle.SafetensorsPath())
+	}
+
+	// Converted models aren't converted again.
+	if err := os.Remove(argsPath); err != nil {
+		t.Fatalf("Failed to remove converter arguments: %v", err)
+	}
+	if again, err := manager.ConvertToGGUF(context.Background(), id, ""); err != nil || again != path {
+		t.Errorf("Expected converted model to be reused, got %q (error %v)", again, err)
+	}
+	if _, err := os.Stat(argsPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected converter not to run again, got %v", err)
+	}
+}
+
+func TestConvertToGGUFFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake converter requires a POSIX shell")
+	}
+	log := logrus.NewEntry(logrus.StandardLogger())
+
+	unconfigured := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log})
+	id := loadSafetensorsModel(t, unconfigured)
+	if _, err := unconfigured.ConvertToGGUF(context.Background(), id, ""); !errors.Is(err, ErrConversionUnavailable) {
+		t.Errorf("Expected conversion to be unavailable, got %v", err)
+	}
+
+	converter := filepath.Join(t.TempDir(), "convert")
+	script := "#!/bin/sh\necho partial > \"$3\"\necho 'unsupported architecture' >&2\nexit 1\n"
+	if err := os.WriteFile(converter, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write converter: %v", err)
+	}
+	manager := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log, GGUFConverter: converter})
+	id = loadSafetensorsModel(t, manager)
+	if _, err := manager.ConvertToGGUF(context.Background(), id, ""); err == nil || !strings.Contains(err.Error(), "unsupported architecture") {
+		t.Fatalf("Expected conversion error with converter output, got %v", err)
+	}
+	if manager.HasGGUF(id) {
+		t.Errorf("Expected failed conversion not to leave GGUF weights")
+	}
+	if _, err := manager.ConvertToGGUF(context.Background(), "missing/model", ""); !errors.Is(err, distribution.ErrModelNotFound) {
+		t.Errorf("Expected model not found error, got %v", err)
+	}
+}
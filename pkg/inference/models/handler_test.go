@@ -1,6 +1,7 @@
 package models
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"io"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/docker/model-runner/pkg/distribution/builder"
 	reg "github.com/docker/model-runner/pkg/distribution/registry"
+	"github.com/docker/model-runner/pkg/distribution/tarball"
 	"github.com/docker/model-runner/pkg/inference"
 	"github.com/docker/model-runner/pkg/inference/memory"
 
@@ -26,6 +28,8 @@ type mockMemoryEstimator struct{}
 
 func (me *mockMemoryEstimator) SetDefaultBackend(_ memory.MemoryEstimatorBackend) {}
 
+func (me *mockMemoryEstimator) AddBackend(_ string, _ memory.MemoryEstimatorBackend) {}
+
 func (me *mockMemoryEstimator) GetRequiredMemoryForModel(_ context.Context, _ string, _ *inference.BackendConfiguration) (inference.RequiredMemory, error) {
 	return inference.RequiredMemory{RAM: 0, VRAM: 0}, nil
 }
@@ -34,6 +38,10 @@ func (me *mockMemoryEstimator) HaveSufficientMemoryForModel(_ context.Context, _
 	return true, inference.RequiredMemory{}, inference.RequiredMemory{}, nil
 }
 
+func (me *mockMemoryEstimator) GetRequiredMemoryByBackend(_ context.Context, _ string, _ *inference.BackendConfiguration) map[string]inference.RequiredMemory {
+	return map[string]inference.RequiredMemory{"llama.cpp": {RAM: 1 << 20, VRAM: 1 << 30}}
+}
+
 // getProjectRoot returns the absolute path to the project root directory
 func getProjectRoot(t *testing.T) string {
 	// Start from the current test file's directory
@@ -135,7 +143,7 @@ func TestPullModel(t *testing.T) {
 			}
 
 			w := httptest.NewRecorder()
-			err = handler.manager.Pull(tag, "", r, w)
+			err = handler.manager.Pull(tag, "", nil, r, w)
 			if err != nil {
 				t.Fatalf("Failed to pull model: %v", err)
 			}
@@ -246,7 +254,7 @@ func TestHandleGetModel(t *testing.T) {
 			if !tt.remote && !strings.Contains(tt.modelName, "nonexistent") {
 				r := httptest.NewRequest(http.MethodPost, "/models/create", strings.NewReader(`{"from": "`+tt.modelName+`"}`))
 				w := httptest.NewRecorder()
-				err = handler.manager.Pull(tt.modelName, "", r, w)
+				err = handler.manager.Pull(tt.modelName, "", nil, r, w)
 				if err != nil {
 					t.Fatalf("Failed to pull model: %v", err)
 				}
@@ -322,6 +330,7 @@ func TestCors(t *testing.T) {
 			m := NewHTTPHandler(log, ClientConfig{}, []string{"*"}, memEstimator)
 			req := httptest.NewRequest(http.MethodOptions, "http://model-runner.docker.internal"+tt.path, http.NoBody)
 			req.Header.Set("Origin", "docker.com")
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
 			w := httptest.NewRecorder()
 			m.ServeHTTP(w, req)
 
@@ -331,3 +340,91 @@ func TestCors(t *testing.T) {
 		})
 	}
 }
+
+func TestTagRoutes(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	handler := NewHTTPHandler(log, ClientConfig{
+		StoreRootPath: t.TempDir(),
+		Logger:        log,
+	}, nil, &mockMemoryEstimator{})
+	firstID := loadF16Model(t, handler.manager)
+	b, err := builder.FromGGUF(writeGGUF(t, 15))
+	if err != nil {
+		t.Fatalf("Failed to create model builder: %v", err)
+	}
+	var archive bytes.Buffer
+	target, err := tarball.NewTarget(&archive)
+	if err != nil {
+		t.Fatalf("Failed to create tarball target: %v", err)
+	}
+	if err := b.Build(context.Background(), target, io.Discard); err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+	if err := handler.manager.Load(&archive, io.Discard); err != nil {
+		t.Fatalf("Failed to load model: %v", err)
+	}
+	secondID, err := b.Model().ID()
+	if err != nil {
+		t.Fatalf("Failed to get model ID: %v", err)
+	}
+	if err := handler.manager.Tag(secondID, "test/other:latest"); err != nil {
+		t.Fatalf("Failed to tag model: %v", err)
+	}
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, inference.ModelsPrefix+path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder, v any) {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+			t.Fatalf("Failed to decode response body: %v", err)
+		}
+	}
+
+	// Point an alias at the first model, then move it to the second one:
+	// requests for the alias resolve to the model it currently points at.
+	for _, id := range []string{firstID, secondID} {
+		var tag ModelTag
+		decode(serve(http.MethodPut, "/tags/prod", `{"model": "`+id+`"}`), &tag)
+		if tag.Tag != "ai/prod:latest" || tag.ID != id {
+			t.Errorf("Expected ai/prod:latest to point at %s, got %+v", id, tag)
+		}
+		if resolved := handler.manager.ResolveID("prod"); resolved != id {
+			t.Errorf("Expected alias to resolve to %s, got %s", id, resolved)
+		}
+	}
+
+	var tags []ModelTag
+	decode(serve(http.MethodGet, "/tags?model=test/other", ""), &tags)
+	if len(tags) != 2 || tags[0].ID != secondID || tags[1].ID != secondID {
+		t.Errorf("Expected the second model to have two tags, got %+v", tags)
+	}
+	decode(serve(http.MethodGet, "/tags", ""), &tags)
+	if len(tags) != 3 {
+		t.Errorf("Expected three tags, got %+v", tags)
+	}
+
+	var removed ModelTag
+	decode(serve(http.MethodDelete, "/tags/prod", ""), &removed)
+	if removed.ID != secondID {
+		t.Errorf("Expected the alias to be removed from %s, got %+v", secondID, removed)
+	}
+	if w := serve(http.MethodDelete, "/tags/prod", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for a missing tag, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := serve(http.MethodDelete, "/tags/test/model:latest", ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d for the last tag of a model, got %d", http.StatusConflict, w.Code)
+	}
+	if w := serve(http.MethodPut, "/tags/prod", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d without a model, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := serve(http.MethodDelete, "/digests/prod", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for a tag without a pinned digest, got %d", http.StatusNotFound, w.Code)
+	}
+}
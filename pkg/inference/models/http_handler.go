@@ -2,18 +2,23 @@ package models
 
 import (
 	"context"
+	"crypto"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"html"
+	"io"
+	"io/fs"
 	"net/http"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/docker/model-runner/pkg/distribution/distribution"
 	"github.com/docker/model-runner/pkg/distribution/registry"
+	"github.com/docker/model-runner/pkg/distribution/types"
 	"github.com/docker/model-runner/pkg/inference"
 	"github.com/docker/model-runner/pkg/inference/memory"
 	"github.com/docker/model-runner/pkg/internal/utils"
@@ -53,6 +58,57 @@ type ClientConfig struct {
 	Transport http.RoundTripper
 	// UserAgent is the user agent to use.
 	UserAgent string
+	// DigestPinning controls how pulls react when a tag resolves to a
+	// different digest than when it was first pulled.
+	DigestPinning distribution.DigestPinningMode
+	// MinFreeSpace is the free disk space, in bytes, kept in reserve when
+	// pulling models, or zero to keep none. If nil,
+	// distribution.DefaultMinFreeSpace is used.
+	MinFreeSpace *uint64
+	// DownloadChunks is the number of concurrent ranged requests that each
+	// large layer is downloaded with. Values below 2 disable chunking.
+	DownloadChunks int
+	// GGUFConverter is the path of llama.cpp's convert_hf_to_gguf.py script,
+	// or of a command with the same interface, which converts safetensors
+	// models to GGUF. If empty, models can't be converted.
+	GGUFConverter string
+	// GGUFQuantizer is the path of llama.cpp's llama-quantize command, or of
+	// a command with the same interface, which derives quantized variants
+	// (e.g. model:Q4_K_M) of local GGUF models with higher precision weights.
+	// If empty, quantized variants are pulled like any other model.
+	GGUFQuantizer string
+	// SignatureVerification controls whether pulled models are verified to
+	// have a cosign signature made with one of SignatureKeys.
+	SignatureVerification distribution.SignatureVerificationMode
+	// SignatureKeys are the public keys trusted to sign models.
+	SignatureKeys []crypto.PublicKey
+	// StoreQuota is the maximum disk space, in bytes, used by the models in
+	// the store. When a pull would exceed it, the least recently used models
+	// that aren't pinned or in use are evicted. If zero, there's no quota.
+	StoreQuota uint64
+	// ModelInUse reports whether the model with an ID is in use, such as
+	// loaded by a backend, in which case it isn't evicted.
+	ModelInUse func(id string) bool
+	// ImportPaths are the directories that models can be imported from,
+	// along with their subdirectories. If empty, models can't be imported.
+	ImportPaths []string
+	// RegistryMirrors are the mirrors that models are pulled from before
+	// falling back to their registries.
+	RegistryMirrors registry.Mirrors
+	// Peers are the base URLs of the other model runners of a cluster that
+	// the layers of models are fetched from before falling back to their
+	// registries.
+	Peers []string
+	// PeerToken is the bearer token that requests to peers are authenticated
+	// with, if any.
+	PeerToken string
+	// RequireLicenseAcceptance indicates whether models that are packaged
+	// with a license are only served once their license is accepted.
+	RequireLicenseAcceptance bool
+	// AcceptedLicenses are the identifiers of the licenses, such as
+	// "apache-2.0", that are accepted by configuration, so that the models
+	// licensed under them don't have to be accepted one by one.
+	AcceptedLicenses []string
 }
 
 // NewHTTPHandler creates a new model's handler.
@@ -139,11 +195,36 @@ func (h *HTTPHandler) routeHandlers() map[string]http.HandlerFunc {
 		"POST " + inference.ModelsPrefix + "/create":                          h.handleCreateModel,
 		"POST " + inference.ModelsPrefix + "/load":                            h.handleLoadModel,
 		"POST " + inference.ModelsPrefix + "/package":                         h.handlePackageModel,
+		"POST " + inference.ModelsPrefix + "/import":                          h.handleImportModel,
 		"GET " + inference.ModelsPrefix:                                       h.handleGetModels,
+		"GET " + inference.ModelsPrefix + "/huggingface/files":                h.handleListHubFiles,
+		"GET " + inference.ModelsPrefix + "/export":                           h.handleExportModel,
 		"GET " + inference.ModelsPrefix + "/{name...}":                        h.handleGetModel,
 		"DELETE " + inference.ModelsPrefix + "/{name...}":                     h.handleDeleteModel,
 		"POST " + inference.ModelsPrefix + "/{nameAndAction...}":              h.handleModelAction,
 		"DELETE " + inference.ModelsPrefix + "/purge":                         h.handlePurge,
+		"POST " + inference.ModelsPrefix + "/prune":                           h.handlePrune,
+		"POST " + inference.ModelsPrefix + "/verify":                          h.handleVerify,
+		"GET " + inference.ModelsPrefix + "/tags":                             h.handleListTags,
+		"POST " + inference.ModelsPrefix + "/pulls":                           h.handleStartPull,
+		"GET " + inference.ModelsPrefix + "/pulls":                            h.handleListPulls,
+		"GET " + inference.ModelsPrefix + "/pulls/{id}":                       h.handleGetPull,
+		"GET " + inference.ModelsPrefix + "/pulls/{id}/events":                h.handlePullEvents,
+		"DELETE " + inference.ModelsPrefix + "/pulls/{id}":                    h.handleCancelPull,
+		"PUT " + inference.ModelsPrefix + "/tags/{tag...}":                    h.handleSetTag,
+		"GET " + inference.ModelsPrefix + "/settings/{name...}":               h.handleGetSettings,
+		"PUT " + inference.ModelsPrefix + "/settings/{name...}":               h.handleSetSettings,
+		"DELETE " + inference.ModelsPrefix + "/settings/{name...}":            h.handleResetSettings,
+		"GET " + inference.ModelsPrefix + "/chat-template/{name...}":          h.handleGetChatTemplate,
+		"PUT " + inference.ModelsPrefix + "/chat-template/{name...}":          h.handleSetChatTemplate,
+		"DELETE " + inference.ModelsPrefix + "/chat-template/{name...}":       h.handleResetChatTemplate,
+		"POST " + inference.ModelsPrefix + "/chat-template/validate":          h.handleValidateChatTemplate,
+		"GET " + inference.ModelsPrefix + "/license/{name...}":                h.handleGetLicense,
+		"POST " + inference.ModelsPrefix + "/license/{name...}":               h.handleAcceptLicense,
+		"DELETE " + inference.ModelsPrefix + "/license/{name...}":             h.handleRevokeLicense,
+		"GET " + inference.ModelsPrefix + "/licenses":                         h.handleListLicenseAcceptances,
+		"DELETE " + inference.ModelsPrefix + "/tags/{tag...}":                 h.handleRemoveTag,
+		"DELETE " + inference.ModelsPrefix + "/digests/{tag...}":              h.handleUnpinDigest,
 		"GET " + inference.InferencePrefix + "/{backend}/v1/models":           h.handleOpenAIGetModels,
 		"GET " + inference.InferencePrefix + "/{backend}/v1/models/{name...}": h.handleOpenAIGetModel,
 		"GET " + inference.InferencePrefix + "/v1/models":                     h.handleOpenAIGetModels,
@@ -165,22 +246,11 @@ func (h *HTTPHandler) handleCreateModel(w http.ResponseWriter, r *http.Request)
 
 	// Pull the model. In the future, we may support additional operations here
 	// besides pulling (such as model building).
-	if memory.RuntimeMemoryCheckEnabled() && !request.IgnoreRuntimeMemoryCheck {
-		h.log.Infof("Will estimate memory required for %q", request.From)
-		proceed, req, totalMem, err := h.memoryEstimator.HaveSufficientMemoryForModel(r.Context(), request.From, nil)
-		if err != nil {
-			h.log.Warnf("Failed to validate sufficient system memory for model %q: %s", request.From, err)
-			// Prefer staying functional in case of unexpected estimation errors.
-			proceed = true
-		}
-		if !proceed {
-			errstr := fmt.Sprintf("Runtime memory requirement for model %q exceeds total system memory: required %d RAM %d VRAM, system %d RAM %d VRAM", request.From, req.RAM, req.VRAM, totalMem.RAM, totalMem.VRAM)
-			h.log.Warnf(errstr)
-			http.Error(w, errstr, http.StatusInsufficientStorage)
-			return
-		}
+	if errstr := h.checkRuntimeMemory(r.Context(), request); errstr != "" {
+		http.Error(w, errstr, http.StatusInsufficientStorage)
+		return
 	}
-	if err := h.manager.Pull(request.From, request.BearerToken, r, w); err != nil {
+	if err := h.manager.Pull(request.From, request.BearerToken, request.Auth, r, w); err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 			h.log.Infof("Request canceled/timed out while pulling model %q", request.From)
 			return
@@ -200,6 +270,21 @@ func (h *HTTPHandler) handleCreateModel(w http.ResponseWriter, r *http.Request)
 			http.Error(w, "Model not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, distribution.ErrInsufficientSpace) {
+			h.log.Warnf("Not enough disk space to pull model %q: %v", request.From, err)
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		}
+		if errors.Is(err, distribution.ErrDigestMismatch) {
+			h.log.Warnf("Refusing to pull model %q: %v", request.From, err)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, distribution.ErrUntrustedSignature) {
+			h.log.Warnf("Refusing to pull model %q: %v", request.From, err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
 		// Note: ErrUnsupportedFormat is no longer treated as an error - it's a warning
 		// that's sent to the client via the progress stream
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -207,6 +292,28 @@ func (h *HTTPHandler) handleCreateModel(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// checkRuntimeMemory returns an error message if the runtime memory
+// requirement of the model to create exceeds the system memory, unless the
+// check is disabled.
+func (h *HTTPHandler) checkRuntimeMemory(ctx context.Context, request ModelCreateRequest) string {
+	if !memory.RuntimeMemoryCheckEnabled() || request.IgnoreRuntimeMemoryCheck {
+		return ""
+	}
+	h.log.Infof("Will estimate memory required for %q", request.From)
+	proceed, req, totalMem, err := h.memoryEstimator.HaveSufficientMemoryForModel(ctx, request.From, nil)
+	if err != nil {
+		h.log.Warnf("Failed to validate sufficient system memory for model %q: %s", request.From, err)
+		// Prefer staying functional in case of unexpected estimation errors.
+		return ""
+	}
+	if proceed {
+		return ""
+	}
+	errstr := fmt.Sprintf("Runtime memory requirement for model %q exceeds total system memory: required %d RAM %d VRAM, system %d RAM %d VRAM", request.From, req.RAM, req.VRAM, totalMem.RAM, totalMem.VRAM)
+	h.log.Warnf(errstr)
+	return errstr
+}
+
 // handleLoadModel handles POST <inference-prefix>/models/load requests.
 func (h *HTTPHandler) handleLoadModel(w http.ResponseWriter, r *http.Request) {
 	err := h.manager.Load(r.Body, w)
@@ -231,9 +338,15 @@ func (h *HTTPHandler) handleGetModels(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleGetModel handles GET <inference-prefix>/models/{name} requests.
+// handleGetModel handles GET <inference-prefix>/models/{name} requests, as
+// well as GET <inference-prefix>/models/{name}/inspect requests, since the
+// name of models is the last segment of their routes.
 func (h *HTTPHandler) handleGetModel(w http.ResponseWriter, r *http.Request) {
 	modelRef := r.PathValue("name")
+	if ref, ok := strings.CutSuffix(modelRef, "/inspect"); ok {
+		h.handleInspectModel(w, r, ref)
+		return
+	}
 
 	// Parse remote query parameter
 	remote := false
@@ -269,6 +382,84 @@ func (h *HTTPHandler) handleGetModel(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleInspectModel handles GET <inference-prefix>/models/{name}/inspect
+// requests, which describe the capabilities of a local model, along with the
+// memory that each backend that can run it requires to.
+func (h *HTTPHandler) handleInspectModel(w http.ResponseWriter, r *http.Request, modelRef string) {
+	inspection, err := h.manager.Inspect(modelRef)
+	if err != nil {
+		h.writeModelError(w, err)
+		return
+	}
+	if h.memoryEstimator != nil {
+		for backend, required := range h.memoryEstimator.GetRequiredMemoryByBackend(r.Context(), inspection.ID, nil) {
+			if inspection.RequiredMemory == nil {
+				inspection.RequiredMemory = make(map[string]ModelMemory)
+			}
+			inspection.RequiredMemory[backend] = ModelMemory{RAM: required.RAM, VRAM: required.VRAM}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(inspection); err != nil {
+		h.log.Warnln("Error while encoding model inspection response:", err)
+	}
+}
+
+// handleListHubFiles handles GET <inference-prefix>/models/huggingface/files
+// requests, which list the files in the Hugging Face Hub repository of the
+// model specified by the model query parameter, such as "hf.co/org/repo:Q4_K_M".
+// A bearer token in the Authorization header is used to access private
+// repositories.
+func (h *HTTPHandler) handleListHubFiles(w http.ResponseWriter, r *http.Request) {
+	model := r.URL.Query().Get("model")
+	if model == "" {
+		http.Error(w, "model query parameter is required", http.StatusBadRequest)
+		return
+	}
+	bearerToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		bearerToken = ""
+	}
+
+	list, err := h.manager.ListHubFiles(r.Context(), NormalizeModelName(model), bearerToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrInvalidReference):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, registry.ErrUnauthorized):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			h.writeModelError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		h.log.Warnln("Error while encoding Hugging Face file list response:", err)
+	}
+}
+
+// handleExportModel handles GET <inference-prefix>/models/export requests,
+// which export the model specified by the model query parameter as a TAR
+// archive that POST <inference-prefix>/models/load requests load.
+func (h *HTTPHandler) handleExportModel(w http.ResponseWriter, r *http.Request) {
+	model := r.URL.Query().Get("model")
+	if model == "" {
+		http.Error(w, "model query parameter is required", http.StatusBadRequest)
+		return
+	}
+	if err := h.manager.Export(r.Context(), model, w); err != nil {
+		if errors.Is(err, context.Canceled) {
+			h.log.Infof("Request canceled while exporting model %q", utils.SanitizeForLog(model, -1))
+			return
+		}
+		h.log.Warnf("Failed to export model %q: %v", utils.SanitizeForLog(model, -1), err)
+		h.writeModelError(w, err)
+	}
+}
+
 func (h *HTTPHandler) getRemoteAPIModel(ctx context.Context, modelRef string) (*Model, error) {
 	model, err := h.manager.GetRemote(ctx, modelRef)
 	if err != nil {
@@ -452,6 +643,10 @@ func (h *HTTPHandler) handleModelAction(w http.ResponseWriter, r *http.Request)
 		h.handleTagModel(w, r, NormalizeModelName(model))
 	case "push":
 		h.handlePushModel(w, r, model)
+	case "convert":
+		h.handleConvertModel(w, r, model)
+	case "pin", "unpin":
+		h.handlePinModel(w, r, model, action == "pin")
 	default:
 		http.Error(w, fmt.Sprintf("unknown action %q", action), http.StatusNotFound)
 	}
@@ -500,8 +695,22 @@ func (h *HTTPHandler) handleTagModel(w http.ResponseWriter, r *http.Request, mod
 }
 
 // handlePushModel handles POST <inference-prefix>/models/{name}/push requests.
+// The request body, a ModelPushRequest, is optional.
 func (h *HTTPHandler) handlePushModel(w http.ResponseWriter, r *http.Request, model string) {
-	if err := h.manager.Push(model, r, w); err != nil {
+	var request ModelPushRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if request.Destination != "" {
+		request.Destination = NormalizeModelName(request.Destination)
+	}
+	// Models pulled from Hugging Face are stored under their normalized name.
+	if strings.HasPrefix(model, "hf.co/") {
+		model = NormalizeModelName(model)
+	}
+
+	if err := h.manager.Push(model, request, r, w); err != nil {
 		if errors.Is(err, distribution.ErrInvalidReference) {
 			h.log.Warnf("Invalid model reference %q: %v", model, err)
 			http.Error(w, "Invalid model reference", http.StatusBadRequest)
@@ -522,6 +731,69 @@ func (h *HTTPHandler) handlePushModel(w http.ResponseWriter, r *http.Request, mo
 	}
 }
 
+// handleConvertModel handles POST <inference-prefix>/models/{name}/convert
+// requests, which convert safetensors models to GGUF.
+func (h *HTTPHandler) handleConvertModel(w http.ResponseWriter, r *http.Request, model string) {
+	var request ModelConvertRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	path, err := h.manager.ConvertToGGUF(r.Context(), model, request.OutputType)
+	if err != nil {
+		switch {
+		case errors.Is(err, distribution.ErrModelNotFound):
+			http.Error(w, "Model not found", http.StatusNotFound)
+		case errors.Is(err, ErrConversionUnavailable):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		case errors.Is(err, ErrConversionUnsupported), errors.Is(err, ErrInvalidOutputType):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			h.log.Warnf("Failed to convert model %q: %v", utils.SanitizeForLog(model, -1), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]string{
+		"message": fmt.Sprintf("Successfully converted model %s to GGUF", model),
+		"path":    path,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.log.Warnln("Error while encoding convert response:", err)
+	}
+}
+
+// handlePinModel handles POST <inference-prefix>/models/{name}/pin and
+// <inference-prefix>/models/{name}/unpin requests. Pinned models are never
+// evicted to stay within the store quota.
+func (h *HTTPHandler) handlePinModel(w http.ResponseWriter, _ *http.Request, model string, pinned bool) {
+	if err := h.manager.SetPinned(model, pinned); err != nil {
+		if errors.Is(err, distribution.ErrModelNotFound) {
+			http.Error(w, "Model not found", http.StatusNotFound)
+			return
+		}
+		h.log.Warnf("Failed to pin model %q: %v", utils.SanitizeForLog(model, -1), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	message := fmt.Sprintf("Model %s pinned", model)
+	if !pinned {
+		message = fmt.Sprintf("Model %s unpinned", model)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]any{
+		"message": message,
+		"pinned":  pinned,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.log.Warnln("Error while encoding pin response:", err)
+	}
+}
+
 // handlePackageModel handles POST <inference-prefix>/models/package requests.
 func (h *HTTPHandler) handlePackageModel(w http.ResponseWriter, r *http.Request) {
 
@@ -563,6 +835,42 @@ func (h *HTTPHandler) handlePackageModel(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// handleImportModel handles POST <inference-prefix>/models/import requests.
+func (h *HTTPHandler) handleImportModel(w http.ResponseWriter, r *http.Request) {
+	var request ModelImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if request.Path == "" || request.Tag == "" {
+		http.Error(w, "both 'path' and 'tag' fields are required", http.StatusBadRequest)
+		return
+	}
+	if !filepath.IsAbs(request.Path) {
+		http.Error(w, "'path' must be absolute", http.StatusBadRequest)
+		return
+	}
+	request.Tag = NormalizeModelName(request.Tag)
+
+	if err := h.manager.Import(request, r, w); err != nil {
+		switch {
+		case errors.Is(err, ErrImportUnavailable), errors.Is(err, ErrImportNotAllowed):
+			h.log.Warnf("Refusing to import model from %q: %v", utils.SanitizeForLog(request.Path, -1), err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, fs.ErrNotExist):
+			http.Error(w, "Model path not found", http.StatusNotFound)
+		case errors.Is(err, distribution.ErrNoModelFiles):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, distribution.ErrInsufficientSpace):
+			h.log.Warnf("Not enough disk space to import model %q: %v", utils.SanitizeForLog(request.Tag, -1), err)
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		default:
+			h.log.Warnf("Failed to import model %q: %v", utils.SanitizeForLog(request.Tag, -1), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
 // handlePurge handles DELETE <inference-prefix>/models/purge requests.
 func (h *HTTPHandler) handlePurge(w http.ResponseWriter, _ *http.Request) {
 	err := h.manager.Purge()
@@ -573,6 +881,458 @@ func (h *HTTPHandler) handlePurge(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// handlePrune handles POST <inference-prefix>/models/prune requests, which
+// remove the blobs in the store that no model references. If the dry-run
+// query parameter is true, the blobs are only reported.
+func (h *HTTPHandler) handlePrune(w http.ResponseWriter, r *http.Request) {
+	var dryRun bool
+	if r.URL.Query().Has("dry-run") {
+		val, err := strconv.ParseBool(r.URL.Query().Get("dry-run"))
+		if err != nil {
+			http.Error(w, "invalid dry-run query parameter", http.StatusBadRequest)
+			return
+		}
+		dryRun = val
+	}
+
+	garbage, err := h.manager.CollectGarbage(dryRun)
+	if err != nil {
+		h.log.Warnf("Failed to prune models store: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(garbage); err != nil {
+		h.log.Warnln("Error while encoding prune response:", err)
+	}
+}
+
+// handleVerify handles POST <inference-prefix>/models/verify requests, which
+// re-hash the content of the store and report the damaged models. If the
+// repair query parameter is true, the damaged blobs are pulled again.
+func (h *HTTPHandler) handleVerify(w http.ResponseWriter, r *http.Request) {
+	var repair bool
+	if r.URL.Query().Has("repair") {
+		val, err := strconv.ParseBool(r.URL.Query().Get("repair"))
+		if err != nil {
+			http.Error(w, "invalid repair query parameter", http.StatusBadRequest)
+			return
+		}
+		repair = val
+	}
+
+	verification, err := h.manager.VerifyStore(r.Context(), repair)
+	if err != nil {
+		h.log.Warnf("Failed to verify models store: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(verification); err != nil {
+		h.log.Warnln("Error while encoding verify response:", err)
+	}
+}
+
+// handleListTags handles GET <inference-prefix>/models/tags requests. The
+// optional model query parameter restricts the listing to the tags of one
+// model.
+func (h *HTTPHandler) handleListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.manager.ListTags(r.URL.Query().Get("model"))
+	if err != nil {
+		h.writeModelError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tags); err != nil {
+		h.log.Warnln("Error while encoding tags response:", err)
+	}
+}
+
+// handleSetTag handles PUT <inference-prefix>/models/tags/{tag} requests. It
+// points the tag at the model specified by the ModelTagRequest body, moving
+// it atomically if it already points at another model. Since models are
+// resolved when requests are made, requests using the tag are served by the
+// new model as soon as this returns.
+func (h *HTTPHandler) handleSetTag(w http.ResponseWriter, r *http.Request) {
+	var request ModelTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if request.Model == "" {
+		http.Error(w, "model is required", http.StatusBadRequest)
+		return
+	}
+	tag := NormalizeModelName(r.PathValue("tag"))
+
+	if err := h.manager.Tag(request.Model, tag); err != nil {
+		h.writeModelError(w, err)
+		return
+	}
+	model, err := h.manager.GetLocal(tag)
+	if err != nil {
+		h.writeModelError(w, err)
+		return
+	}
+	id, err := model.ID()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ModelTag{Tag: tag, ID: id}); err != nil {
+		h.log.Warnln("Error while encoding tag response:", err)
+	}
+}
+
+// handleRemoveTag handles DELETE <inference-prefix>/models/tags/{tag}
+// requests. Models keep the rest of their tags; removing the last tag of a
+// model is a conflict, and the model must be deleted instead.
+func (h *HTTPHandler) handleRemoveTag(w http.ResponseWriter, r *http.Request) {
+	removed, err := h.manager.Untag(r.PathValue("tag"))
+	if err != nil {
+		if errors.Is(err, distribution.ErrLastTag) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		h.writeModelError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(removed); err != nil {
+		h.log.Warnln("Error while encoding untag response:", err)
+	}
+}
+
+// handleUnpinDigest handles DELETE <inference-prefix>/models/digests/{tag}
+// requests, which remove the digest pinned for a tag when it was first pulled.
+func (h *HTTPHandler) handleUnpinDigest(w http.ResponseWriter, r *http.Request) {
+	pin, ok, err := h.manager.UnpinDigest(r.PathValue("tag"))
+	if err != nil {
+		h.writeModelError(w, err)
+		return
+	}
+	if !ok {
+		http.Error(w, fmt.Sprintf("no digest is pinned for %s", pin.Tag), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pin); err != nil {
+		h.log.Warnln("Error while encoding unpin response:", err)
+	}
+}
+
+// handleGetSettings handles GET <inference-prefix>/models/settings/{name}
+// requests, which return the settings stored with the model.
+func (h *HTTPHandler) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.manager.GetSettings(r.PathValue("name"))
+	if err != nil {
+		h.writeModelError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(settings); err != nil {
+		h.log.Warnln("Error while encoding settings response:", err)
+	}
+}
+
+// handleSetSettings handles PUT <inference-prefix>/models/settings/{name}
+// requests. It replaces the settings stored with the model with the
+// types.ModelSettings body, which apply the next time the model is loaded, and
+// responds with them along with the format of their chat template.
+func (h *HTTPHandler) handleSetSettings(w http.ResponseWriter, r *http.Request) {
+	var settings types.ModelSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	settings, err := h.manager.SetSettings(r.PathValue("name"), settings)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSettings) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.writeModelError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(settings); err != nil {
+		h.log.Warnln("Error while encoding settings response:", err)
+	}
+}
+
+// handleResetSettings handles DELETE <inference-prefix>/models/settings/{name}
+// requests, which remove the settings stored with the model.
+func (h *HTTPHandler) handleResetSettings(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.manager.SetSettings(r.PathValue("name"), types.ModelSettings{}); err != nil {
+		h.writeModelError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetChatTemplate handles GET <inference-prefix>/models/chat-template/{name}
+// requests, which return the chat template that the model is run with.
+func (h *HTTPHandler) handleGetChatTemplate(w http.ResponseWriter, r *http.Request) {
+	template, err := h.manager.GetChatTemplate(r.PathValue("name"))
+	if err != nil {
+		if errors.Is(err, ErrNoChatTemplate) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		h.writeModelError(w, err)
+		return
+	}
+	h.writeChatTemplate(w, template)
+}
+
+// handleSetChatTemplate handles PUT <inference-prefix>/models/chat-template/{name}
+// requests. It validates the template of the ChatTemplateRequest body and
+// stores it in the settings of the model, overriding its own chat template
+// the next time the model is loaded.
+func (h *HTTPHandler) handleSetChatTemplate(w http.ResponseWriter, r *http.Request) {
+	var request ChatTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if request.Template == "" {
+		http.Error(w, "template is required", http.StatusBadRequest)
+		return
+	}
+	template, err := h.manager.SetChatTemplate(r.PathValue("name"), request.Template)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSettings) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.writeModelError(w, err)
+		return
+	}
+	h.writeChatTemplate(w, template)
+}
+
+// handleResetChatTemplate handles DELETE
+// <inference-prefix>/models/chat-template/{name} requests, which remove the
+// chat template stored in the settings of the model.
+func (h *HTTPHandler) handleResetChatTemplate(w http.ResponseWriter, r *http.Request) {
+	if err := h.manager.ResetChatTemplate(r.PathValue("name")); err != nil {
+		h.writeModelError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleValidateChatTemplate handles POST
+// <inference-prefix>/models/chat-template/validate requests, which validate
+// the template of the ChatTemplateRequest body. Invalid templates are reported
+// in the ChatTemplateValidation response rather than with an error status.
+func (h *HTTPHandler) handleValidateChatTemplate(w http.ResponseWriter, r *http.Request) {
+	var request ChatTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ValidateChatTemplate(request)); err != nil {
+		h.log.Warnln("Error while encoding chat template validation response:", err)
+	}
+}
+
+// handleGetLicense handles GET <inference-prefix>/models/license/{name}
+// requests, which describe the license of the model and its acceptance.
+func (h *HTTPHandler) handleGetLicense(w http.ResponseWriter, r *http.Request) {
+	status, err := h.manager.GetLicense(r.PathValue("name"))
+	if err != nil {
+		h.writeModelError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		h.log.Warnln("Error while encoding license response:", err)
+	}
+}
+
+// handleAcceptLicense handles POST <inference-prefix>/models/license/{name}
+// requests, which accept the license of the model and respond with the
+// acceptance recorded. The request body, a LicenseAcceptRequest, is optional.
+func (h *HTTPHandler) handleAcceptLicense(w http.ResponseWriter, r *http.Request) {
+	var request LicenseAcceptRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if request.AcceptedBy == "" {
+		request.AcceptedBy = r.UserAgent()
+	}
+	acceptance, err := h.manager.AcceptLicense(r.PathValue("name"), request.AcceptedBy)
+	if err != nil {
+		if errors.Is(err, ErrNoLicense) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		h.writeModelError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(acceptance); err != nil {
+		h.log.Warnln("Error while encoding license acceptance response:", err)
+	}
+}
+
+// handleRevokeLicense handles DELETE <inference-prefix>/models/license/{name}
+// requests, which revoke the acceptance of the license of the model.
+func (h *HTTPHandler) handleRevokeLicense(w http.ResponseWriter, r *http.Request) {
+	if err := h.manager.RevokeLicense(r.PathValue("name")); err != nil {
+		if errors.Is(err, ErrLicenseNotAccepted) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		h.writeModelError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListLicenseAcceptances handles GET <inference-prefix>/models/licenses
+// requests, which list the license acceptances recorded, for audits.
+func (h *HTTPHandler) handleListLicenseAcceptances(w http.ResponseWriter, r *http.Request) {
+	acceptances, err := h.manager.LicenseAcceptances()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(acceptances); err != nil {
+		h.log.Warnln("Error while encoding license acceptances response:", err)
+	}
+}
+
+// writeChatTemplate writes a chat template response.
+func (h *HTTPHandler) writeChatTemplate(w http.ResponseWriter, template ChatTemplate) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(template); err != nil {
+		h.log.Warnln("Error while encoding chat template response:", err)
+	}
+}
+
+// handleStartPull handles POST <inference-prefix>/models/pulls requests. It
+// starts pulling the model specified by the ModelCreateRequest body in the
+// background, and responds with the pull operation, whose progress is
+// streamed by GET <inference-prefix>/models/pulls/{id}/events.
+func (h *HTTPHandler) handleStartPull(w http.ResponseWriter, r *http.Request) {
+	var request ModelCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if request.From == "" {
+		http.Error(w, "from is required", http.StatusBadRequest)
+		return
+	}
+	request.From = NormalizeModelName(request.From)
+	if errstr := h.checkRuntimeMemory(r.Context(), request); errstr != "" {
+		http.Error(w, errstr, http.StatusInsufficientStorage)
+		return
+	}
+
+	op, err := h.manager.StartPull(request.From, request.BearerToken, request.Auth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", inference.ModelsPrefix+"/pulls/"+op.ID)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(op); err != nil {
+		h.log.Warnln("Error while encoding pull response:", err)
+	}
+}
+
+// handleListPulls handles GET <inference-prefix>/models/pulls requests.
+func (h *HTTPHandler) handleListPulls(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.manager.ListPulls()); err != nil {
+		h.log.Warnln("Error while encoding pulls response:", err)
+	}
+}
+
+// handleGetPull handles GET <inference-prefix>/models/pulls/{id} requests.
+func (h *HTTPHandler) handleGetPull(w http.ResponseWriter, r *http.Request) {
+	op, _, err := h.manager.WatchPull(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(op); err != nil {
+		h.log.Warnln("Error while encoding pull response:", err)
+	}
+}
+
+// handlePullEvents handles GET <inference-prefix>/models/pulls/{id}/events
+// requests. It streams the state of the pull operation as server-sent
+// events: a progress event whenever it changes, and a done event once the
+// pull finishes, after which the stream ends.
+func (h *HTTPHandler) handlePullEvents(w http.ResponseWriter, r *http.Request) {
+	op, changed, err := h.manager.WatchPull(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		event := "progress"
+		if op.Finished != nil {
+			event = "done"
+		}
+		data, err := json.Marshal(op)
+		if err != nil {
+			h.log.Warnln("Error while encoding pull event:", err)
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return
+		}
+		flusher.Flush()
+		if op.Finished != nil {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		if op, changed, err = h.manager.WatchPull(op.ID); err != nil {
+			return
+		}
+	}
+}
+
+// handleCancelPull handles DELETE <inference-prefix>/models/pulls/{id}
+// requests. The pull stops shortly after, with the canceled status.
+func (h *HTTPHandler) handleCancelPull(w http.ResponseWriter, r *http.Request) {
+	op, err := h.manager.CancelPull(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(op); err != nil {
+		h.log.Warnln("Error while encoding pull response:", err)
+	}
+}
+
 // ServeHTTP implement net/http.HTTPHandler.ServeHTTP.
 func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.lock.RLock()
@@ -587,6 +1347,36 @@ type progressResponseWriter struct {
 	isJSON  bool
 }
 
+// newProgressResponseWriter sets up a response for streaming progress
+// updates, which are written as JSON if the request accepts it, or as plain
+// text otherwise.
+func newProgressResponseWriter(w http.ResponseWriter, r *http.Request) (*progressResponseWriter, error) {
+	// Set up response headers for streaming
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Transfer-Encoding", "chunked")
+
+	// Check Accept header to determine content type
+	isJSON := r.Header.Get("Accept") == "application/json"
+	if isJSON {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		// Defaults to text/plain
+		w.Header().Set("Content-Type", "text/plain")
+	}
+
+	// Create a flusher to ensure chunks are sent immediately
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("streaming not supported")
+	}
+	return &progressResponseWriter{
+		writer:  w,
+		flusher: flusher,
+		isJSON:  isJSON,
+	}, nil
+}
+
 func (w *progressResponseWriter) Write(p []byte) (n int, err error) {
 	var data []byte
 	if w.isJSON {
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+var (
+	// ErrImportUnavailable indicates that no directories are configured for
+	// models to be imported from.
+	ErrImportUnavailable = errors.New("model import is unavailable: no import paths are configured")
+	// ErrImportNotAllowed indicates that a path is outside the directories
+	// that models can be imported from.
+	ErrImportNotAllowed = errors.New("models can't be imported from outside the configured import paths")
+)
+
+// checkImportPath checks that models can be imported from a path, which must
+// be absolute and, once symbolic links are resolved, within one of the import
+// paths. It returns the resolved path.
+func (m *Manager) checkImportPath(path string) (string, error) {
+	if len(m.importPaths) == 0 {
+		return "", ErrImportUnavailable
+	}
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("import path %q must be absolute", path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("resolving import path: %w", err)
+	}
+	for _, dir := range m.importPaths {
+		if resolvedDir, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolvedDir
+		}
+		rel, err := filepath.Rel(dir, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", ErrImportNotAllowed
+}
+
+// Import imports a GGUF or safetensors model from the model runner's
+// filesystem into the store, streaming the progress of writing it to the
+// response.
+func (m *Manager) Import(request ModelImportRequest, r *http.Request, w http.ResponseWriter) error {
+	if m.distributionClient == nil {
+		return errors.New("model distribution service unavailable")
+	}
+	path, err := m.checkImportPath(request.Path)
+	if err != nil {
+		return err
+	}
+	progressWriter, err := newProgressResponseWriter(w, r)
+	if err != nil {
+		return err
+	}
+
+	m.log.Infof("Importing model %s from %s", utils.SanitizeForLog(request.Tag, -1), utils.SanitizeForLog(path, -1))
+	if err := m.distributionClient.ImportModel(path, request.Tag, request.ContextSize, progressWriter); err != nil {
+		return fmt.Errorf("error while importing model: %w", err)
+	}
+	return nil
+}
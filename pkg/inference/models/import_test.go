@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCheckImportPath(t *testing.T) {
+	root := t.TempDir()
+	allowed := filepath.Join(root, "allowed")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{filepath.Join(allowed, "model"), outside} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+
+	if _, err := (&Manager{}).checkImportPath(allowed); !errors.Is(err, ErrImportUnavailable) {
+		t.Errorf("Expected ErrImportUnavailable without import paths, got %v", err)
+	}
+
+	m := &Manager{importPaths: []string{allowed}}
+	for _, path := range []string{allowed, filepath.Join(allowed, "model")} {
+		if _, err := m.checkImportPath(path); err != nil {
+			t.Errorf("Expected import from %s to be allowed, got %v", path, err)
+		}
+	}
+	for _, path := range []string{outside, filepath.Join(allowed, "..", "outside"), allowed + "-other"} {
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if _, err := m.checkImportPath(path); !errors.Is(err, ErrImportNotAllowed) {
+			t.Errorf("Expected import from %s not to be allowed, got %v", path, err)
+		}
+	}
+	if _, err := m.checkImportPath("model"); err == nil {
+		t.Errorf("Expected relative import path to be refused")
+	}
+
+	// Symbolic links are resolved, so they can't lead outside the import
+	// paths.
+	if runtime.GOOS == "windows" {
+		return
+	}
+	link := filepath.Join(allowed, "link")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatalf("Failed to create symbolic link: %v", err)
+	}
+	if _, err := m.checkImportPath(link); !errors.Is(err, ErrImportNotAllowed) {
+		t.Errorf("Expected import through a symbolic link to be refused, got %v", err)
+	}
+}
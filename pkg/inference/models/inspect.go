@@ -0,0 +1,191 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+// Modalities of the inputs and outputs of models.
+const (
+	modalityText      = "text"
+	modalityImage     = "image"
+	modalityAudio     = "audio"
+	modalityEmbedding = "embedding"
+)
+
+// safetensorsConfig is the subset of the config.json of safetensors models
+// that describes their capabilities. Multimodal models nest the config of
+// their language model under text_config.
+type safetensorsConfig struct {
+	Architectures         []string        `json:"architectures"`
+	MaxPositionEmbeddings uint64          `json:"max_position_embeddings"`
+	VocabSize             uint64          `json:"vocab_size"`
+	VisionConfig          json.RawMessage `json:"vision_config"`
+	AudioConfig           json.RawMessage `json:"audio_config"`
+	TextConfig            struct {
+		MaxPositionEmbeddings uint64 `json:"max_position_embeddings"`
+		VocabSize             uint64 `json:"vocab_size"`
+	} `json:"text_config"`
+}
+
+// safetensorsTokenizerConfig is the subset of the tokenizer_config.json of
+// safetensors models that describes their tokenizer.
+type safetensorsTokenizerConfig struct {
+	TokenizerClass string `json:"tokenizer_class"`
+}
+
+// Inspect describes the capabilities of a local model from the metadata of
+// its weights. The memory that backends require to run it is left to the
+// caller, which knows the backends.
+func (m *Manager) Inspect(ref string) (ModelInspection, error) {
+	if m.distributionClient == nil {
+		return ModelInspection{}, errors.New("model distribution service unavailable")
+	}
+	model, err := m.getLocal(ref)
+	if err != nil {
+		return ModelInspection{}, err
+	}
+	id, err := model.ID()
+	if err != nil {
+		return ModelInspection{}, fmt.Errorf("getting model ID: %w", err)
+	}
+	config, err := model.Config()
+	if err != nil {
+		return ModelInspection{}, fmt.Errorf("reading model config: %w", err)
+	}
+
+	inspection := ModelInspection{
+		ID:           id,
+		Tags:         model.Tags(),
+		Format:       config.Format,
+		Architecture: config.Architecture,
+		Parameters:   config.Parameters,
+		Quantization: config.Quantization,
+		Modalities: ModelModalities{
+			Input:  []string{modalityText},
+			Output: []string{modalityText},
+		},
+		Tokenizer: &ModelTokenizer{},
+	}
+	// Models without a format predate safetensors support and are GGUF
+	// models.
+	if inspection.Format == "" {
+		inspection.Format = types.FormatGGUF
+	}
+
+	settings, err := m.GetSettings(id)
+	if err != nil {
+		return ModelInspection{}, err
+	}
+	if settings.ContextSize != nil {
+		inspection.ContextSize = *settings.ContextSize
+	} else if config.ContextSize != nil {
+		inspection.ContextSize = *config.ContextSize
+	}
+
+	switch inspection.Format {
+	case types.FormatGGUF:
+		inspectGGUF(config, &inspection)
+		if _, err := model.MMPROJPath(); err == nil {
+			inspection.Modalities.Input = append(inspection.Modalities.Input, modalityImage)
+		}
+	case types.FormatSafetensors:
+		if err := m.inspectSafetensors(id, &inspection); err != nil {
+			return ModelInspection{}, err
+		}
+	case types.FormatWhisper:
+		inspection.Modalities.Input = []string{modalityAudio}
+	case types.FormatDiffusion:
+		inspection.Modalities.Output = []string{modalityImage}
+	}
+
+	if _, err := m.GetChatTemplate(id); err == nil {
+		inspection.Tokenizer.ChatTemplate = true
+	} else if !errors.Is(err, ErrNoChatTemplate) {
+		return ModelInspection{}, err
+	}
+	if *inspection.Tokenizer == (ModelTokenizer{}) {
+		inspection.Tokenizer = nil
+	}
+
+	license, err := licenseText(model)
+	if err != nil {
+		return ModelInspection{}, err
+	}
+	if name := config.GGUF[ggufLicenseKey]; name != "" || license != "" {
+		inspection.License = &ModelLicense{Name: name, Text: license}
+	}
+	return inspection, nil
+}
+
+// inspectGGUF describes a GGUF model from the metadata of its GGUF file.
+func inspectGGUF(config types.Config, inspection *ModelInspection) {
+	arch := config.GGUF["general.architecture"]
+	if inspection.Architecture == "" {
+		inspection.Architecture = arch
+	}
+	inspection.ContextLength, _ = strconv.ParseUint(config.GGUF[arch+".context_length"], 10, 64)
+	inspection.Tokenizer.Model = config.GGUF["tokenizer.ggml.model"]
+	inspection.Tokenizer.Pre = config.GGUF["tokenizer.ggml.pre"]
+	inspection.Tokenizer.VocabSize, _ = strconv.ParseUint(config.GGUF[arch+".vocab_size"], 10, 64)
+	// Only embedding models pool the embeddings of their tokens.
+	if config.GGUF[arch+".pooling_type"] != "" {
+		inspection.Modalities.Output = []string{modalityEmbedding}
+	}
+}
+
+// inspectSafetensors describes a safetensors model from the configuration
+// files packaged with its weights, which are unpacked next to them in its
+// bundle.
+func (m *Manager) inspectSafetensors(id string, inspection *ModelInspection) error {
+	bundle, err := m.GetBundle(id)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(bundle.SafetensorsPath())
+
+	var config safetensorsConfig
+	if ok, err := readJSONFile(filepath.Join(dir, "config.json"), &config); err != nil {
+		return err
+	} else if ok {
+		if inspection.Architecture == "" && len(config.Architectures) > 0 {
+			inspection.Architecture = config.Architectures[0]
+		}
+		inspection.ContextLength = max(config.MaxPositionEmbeddings, config.TextConfig.MaxPositionEmbeddings)
+		inspection.Tokenizer.VocabSize = max(config.VocabSize, config.TextConfig.VocabSize)
+		if len(config.VisionConfig) > 0 && string(config.VisionConfig) != "null" {
+			inspection.Modalities.Input = append(inspection.Modalities.Input, modalityImage)
+		}
+		if len(config.AudioConfig) > 0 && string(config.AudioConfig) != "null" {
+			inspection.Modalities.Input = append(inspection.Modalities.Input, modalityAudio)
+		}
+	}
+
+	var tokenizerConfig safetensorsTokenizerConfig
+	if _, err := readJSONFile(filepath.Join(dir, "tokenizer_config.json"), &tokenizerConfig); err != nil {
+		return err
+	}
+	inspection.Tokenizer.Model = tokenizerConfig.TokenizerClass
+	return nil
+}
+
+// readJSONFile unmarshals a JSON file into v. It returns false if the file
+// doesn't exist.
+func readJSONFile(path string, v any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("reading %s: %w", filepath.Base(path), err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
+	}
+	return true, nil
+}
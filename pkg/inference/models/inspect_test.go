@@ -0,0 +1,86 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/builder"
+	"github.com/docker/model-runner/pkg/distribution/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestInspectModel(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	handler := NewHTTPHandler(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log}, nil, &mockMemoryEstimator{})
+
+	license := filepath.Join(t.TempDir(), "LICENSE")
+	if err := os.WriteFile(license, []byte("Apache License 2.0"), 0o644); err != nil {
+		t.Fatalf("Failed to write license: %v", err)
+	}
+	b, err := builder.FromGGUF(writeGGUF(t, 1))
+	if err != nil {
+		t.Fatalf("Failed to create model builder: %v", err)
+	}
+	if b, err = b.WithLicense(license); err != nil {
+		t.Fatalf("Failed to add license: %v", err)
+	}
+	id := loadBuiltModel(t, handler.manager, b.WithContextSize(4096))
+	if err := handler.manager.Tag(id, "test/model:latest"); err != nil {
+		t.Fatalf("Failed to tag model: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/models/test/model/inspect", http.NoBody)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var inspection ModelInspection
+	if err := json.NewDecoder(w.Body).Decode(&inspection); err != nil {
+		t.Fatalf("Failed to decode inspection: %v", err)
+	}
+
+	if inspection.ID != id || inspection.Format != types.FormatGGUF || inspection.Architecture != "llama" {
+		t.Errorf("Unexpected model description %+v", inspection)
+	}
+	if inspection.Quantization != "MOSTLY_F16" || inspection.ContextSize != 4096 {
+		t.Errorf("Expected F16 weights with a context size of 4096, got %q and %d", inspection.Quantization, inspection.ContextSize)
+	}
+	if !slices.Equal(inspection.Modalities.Input, []string{"text"}) || !slices.Equal(inspection.Modalities.Output, []string{"text"}) {
+		t.Errorf("Expected a text model, got %+v", inspection.Modalities)
+	}
+	if inspection.Tokenizer != nil {
+		t.Errorf("Expected no tokenizer info, got %+v", inspection.Tokenizer)
+	}
+	if inspection.License == nil || inspection.License.Text != "Apache License 2.0" {
+		t.Errorf("Expected the packaged license, got %+v", inspection.License)
+	}
+	if memory, ok := inspection.RequiredMemory["llama.cpp"]; !ok || memory.VRAM != 1<<30 {
+		t.Errorf("Expected the memory required by llama.cpp, got %+v", inspection.RequiredMemory)
+	}
+
+	// Chat template overrides are reported as the model's chat template.
+	if _, err := handler.manager.SetChatTemplate("test/model", "{{ .Prompt }}"); err != nil {
+		t.Fatalf("Failed to set chat template: %v", err)
+	}
+	inspection, err = handler.manager.Inspect("test/model")
+	if err != nil {
+		t.Fatalf("Failed to inspect model: %v", err)
+	}
+	if inspection.Tokenizer == nil || !inspection.Tokenizer.ChatTemplate {
+		t.Errorf("Expected a chat template, got %+v", inspection.Tokenizer)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/models/test/missing/inspect", http.NoBody)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing model, got %d", w.Code)
+	}
+}
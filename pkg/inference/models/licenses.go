@@ -0,0 +1,266 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+)
+
+// ggufLicenseKey is the metadata key of the license identifier of GGUF files.
+const ggufLicenseKey = "general.license"
+
+var (
+	// ErrNoLicense indicates that a model isn't packaged with a license.
+	ErrNoLicense = errors.New("model has no license")
+	// ErrLicenseNotAccepted indicates that a model is only served once its
+	// license is accepted.
+	ErrLicenseNotAccepted = errors.New("model license has not been accepted")
+)
+
+// modelLicense describes the license of a model without reading the license
+// files packaged with it.
+type modelLicense struct {
+	// name is the license identifier from the model's metadata, if any.
+	name string
+	// layers are the license files packaged with the model.
+	layers []v1.Layer
+}
+
+// exists returns true if the model has a license.
+func (l modelLicense) exists() bool {
+	return l.name != "" || len(l.layers) > 0
+}
+
+// digests returns the digests of the license files packaged with the model.
+func (l modelLicense) digests() []string {
+	var digests []string
+	for _, layer := range l.layers {
+		if digest, err := layer.Digest(); err == nil {
+			digests = append(digests, digest.String())
+		}
+	}
+	return digests
+}
+
+// licenseOf returns the license of a model.
+func licenseOf(model types.Model) (modelLicense, error) {
+	config, err := model.Config()
+	if err != nil {
+		return modelLicense{}, fmt.Errorf("reading model config: %w", err)
+	}
+	layers, err := licenseLayers(model)
+	if err != nil {
+		return modelLicense{}, err
+	}
+	return modelLicense{name: config.GGUF[ggufLicenseKey], layers: layers}, nil
+}
+
+// gated returns true if a model with the specified license is only served
+// once its license is accepted: if acceptance is required and the license
+// isn't accepted by configuration.
+func (m *Manager) gated(license modelLicense) bool {
+	if !m.requireLicenseAcceptance || !license.exists() {
+		return false
+	}
+	return license.name == "" || !slices.ContainsFunc(m.acceptedLicenses, func(accepted string) bool {
+		return strings.EqualFold(accepted, license.name)
+	})
+}
+
+// GetLicense returns the license of a model, along with whether it must be
+// accepted before the model is served and its acceptance, if any.
+func (m *Manager) GetLicense(ref string) (LicenseStatus, error) {
+	if m.distributionClient == nil {
+		return LicenseStatus{}, errors.New("model distribution service unavailable")
+	}
+	model, err := m.getLocal(ref)
+	if err != nil {
+		return LicenseStatus{}, err
+	}
+	id, err := model.ID()
+	if err != nil {
+		return LicenseStatus{}, fmt.Errorf("getting model ID: %w", err)
+	}
+	license, err := licenseOf(model)
+	if err != nil {
+		return LicenseStatus{}, err
+	}
+	text, err := licenseText(model)
+	if err != nil {
+		return LicenseStatus{}, err
+	}
+	status := LicenseStatus{Model: id, License: license.name, Text: text, Gated: m.gated(license)}
+	acceptance, ok, err := m.distributionClient.ModelLicenseAcceptance(id)
+	if err != nil {
+		return LicenseStatus{}, err
+	}
+	if ok {
+		status.Acceptance = &acceptance
+	}
+	return status, nil
+}
+
+// AcceptLicense records the acceptance of the license of a model by the
+// specified party, after which the model is served even if it's gated. It
+// returns ErrNoLicense if the model has no license.
+func (m *Manager) AcceptLicense(ref string, acceptedBy string) (types.LicenseAcceptance, error) {
+	if m.distributionClient == nil {
+		return types.LicenseAcceptance{}, errors.New("model distribution service unavailable")
+	}
+	model, err := m.getLocal(ref)
+	if err != nil {
+		return types.LicenseAcceptance{}, err
+	}
+	id, err := model.ID()
+	if err != nil {
+		return types.LicenseAcceptance{}, fmt.Errorf("getting model ID: %w", err)
+	}
+	license, err := licenseOf(model)
+	if err != nil {
+		return types.LicenseAcceptance{}, err
+	}
+	if !license.exists() {
+		return types.LicenseAcceptance{}, ErrNoLicense
+	}
+
+	acceptance := types.LicenseAcceptance{
+		Model:      id,
+		Reference:  ref,
+		License:    license.name,
+		Digests:    license.digests(),
+		AcceptedBy: acceptedBy,
+		AcceptedAt: time.Now().UTC(),
+	}
+	if err := m.distributionClient.AcceptModelLicense(acceptance); err != nil {
+		return types.LicenseAcceptance{}, fmt.Errorf("recording license acceptance: %w", err)
+	}
+	m.log.Infof("License of model %s accepted", id)
+	return acceptance, nil
+}
+
+// RevokeLicense revokes the acceptance of the license of a model. It returns
+// ErrLicenseNotAccepted if its license wasn't accepted.
+func (m *Manager) RevokeLicense(ref string) error {
+	if m.distributionClient == nil {
+		return errors.New("model distribution service unavailable")
+	}
+	model, err := m.getLocal(ref)
+	if err != nil {
+		return err
+	}
+	id, err := model.ID()
+	if err != nil {
+		return fmt.Errorf("getting model ID: %w", err)
+	}
+	revoked, err := m.distributionClient.RevokeModelLicense(id)
+	if err != nil {
+		return fmt.Errorf("revoking license acceptance: %w", err)
+	}
+	if !revoked {
+		return ErrLicenseNotAccepted
+	}
+	m.log.Infof("License acceptance of model %s revoked", id)
+	return nil
+}
+
+// LicenseAcceptances returns all the license acceptances recorded, including
+// revoked ones and those of deleted models, for audits.
+func (m *Manager) LicenseAcceptances() ([]types.LicenseAcceptance, error) {
+	if m.distributionClient == nil {
+		return nil, errors.New("model distribution service unavailable")
+	}
+	acceptances, err := m.distributionClient.ListLicenseAcceptances()
+	if err != nil {
+		return nil, err
+	}
+	if acceptances == nil {
+		acceptances = []types.LicenseAcceptance{}
+	}
+	return acceptances, nil
+}
+
+// CheckLicense returns ErrLicenseNotAccepted if a model is gated and its
+// license hasn't been accepted, in which case it mustn't be served. For names
+// that apply adapters to a base model, the licenses of the base model and of
+// the adapters are checked.
+func (m *Manager) CheckLicense(ref string) error {
+	if !m.requireLicenseAcceptance || m.distributionClient == nil {
+		return nil
+	}
+	base, adapters := SplitAdapters(ref)
+	for _, name := range append([]string{base}, adapters...) {
+		model, err := m.getLocal(name)
+		if err != nil {
+			return err
+		}
+		license, err := licenseOf(model)
+		if err != nil {
+			return err
+		}
+		if !m.gated(license) {
+			continue
+		}
+		id, err := model.ID()
+		if err != nil {
+			return fmt.Errorf("getting model ID: %w", err)
+		}
+		if _, ok, err := m.distributionClient.ModelLicenseAcceptance(id); err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("%w: accept the license of %s to use it", ErrLicenseNotAccepted, name)
+		}
+	}
+	return nil
+}
+
+// licenseLayers returns the layers of the license files packaged with a
+// model's artifact, if any.
+func licenseLayers(model types.Model) ([]v1.Layer, error) {
+	artifact, ok := model.(interface{ Layers() ([]v1.Layer, error) })
+	if !ok {
+		return nil, nil
+	}
+	layers, err := artifact.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("getting model layers: %w", err)
+	}
+	var licenses []v1.Layer
+	for _, layer := range layers {
+		if mediaType, err := layer.MediaType(); err == nil && mediaType == types.MediaTypeLicense {
+			licenses = append(licenses, layer)
+		}
+	}
+	return licenses, nil
+}
+
+// licenseText returns the text of the licenses packaged with a model's
+// artifact, if any.
+func licenseText(model types.Model) (string, error) {
+	layers, err := licenseLayers(model)
+	if err != nil {
+		return "", err
+	}
+	var text string
+	for _, layer := range layers {
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			return "", fmt.Errorf("opening license: %w", err)
+		}
+		license, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return "", fmt.Errorf("reading license: %w", err)
+		}
+		if text != "" {
+			text += "\n\n"
+		}
+		text += string(license)
+	}
+	return text, nil
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/builder"
+
+	"github.com/sirupsen/logrus"
+)
+
+// loadLicensedModel loads a GGUF model packaged with a license into the
+// manager's store, tagged test/licensed:latest, and returns its ID.
+func loadLicensedModel(t *testing.T, manager *Manager) string {
+	t.Helper()
+	license := filepath.Join(t.TempDir(), "LICENSE")
+	if err := os.WriteFile(license, []byte("Community License"), 0o644); err != nil {
+		t.Fatalf("Failed to write license: %v", err)
+	}
+	b, err := builder.FromGGUF(writeGGUF(t, 1))
+	if err != nil {
+		t.Fatalf("Failed to create model builder: %v", err)
+	}
+	if b, err = b.WithLicense(license); err != nil {
+		t.Fatalf("Failed to add license: %v", err)
+	}
+	id := loadBuiltModel(t, manager, b)
+	if err := manager.Tag(id, "test/licensed:latest"); err != nil {
+		t.Fatalf("Failed to tag model: %v", err)
+	}
+	return id
+}
+
+func TestLicenseAcceptance(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	manager := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log, RequireLicenseAcceptance: true})
+	id := loadLicensedModel(t, manager)
+
+	status, err := manager.GetLicense("test/licensed")
+	if err != nil {
+		t.Fatalf("Failed to get license: %v", err)
+	}
+	if status.Model != id || status.Text != "Community License" || !status.Gated || status.Acceptance != nil {
+		t.Errorf("Unexpected license status %+v", status)
+	}
+	if err := manager.CheckLicense("test/licensed"); !errors.Is(err, ErrLicenseNotAccepted) {
+		t.Errorf("Expected ErrLicenseNotAccepted, got %v", err)
+	}
+
+	acceptance, err := manager.AcceptLicense("test/licensed", "alice")
+	if err != nil {
+		t.Fatalf("Failed to accept license: %v", err)
+	}
+	if acceptance.Model != id || acceptance.AcceptedBy != "alice" || len(acceptance.Digests) != 1 {
+		t.Errorf("Unexpected acceptance %+v", acceptance)
+	}
+	if err := manager.CheckLicense("test/licensed"); err != nil {
+		t.Errorf("Expected the accepted model to be served, got %v", err)
+	}
+
+	// Revoked acceptances are kept for audits, but no longer let the model
+	// be served.
+	if err := manager.RevokeLicense("test/licensed"); err != nil {
+		t.Fatalf("Failed to revoke license: %v", err)
+	}
+	if err := manager.RevokeLicense("test/licensed"); !errors.Is(err, ErrLicenseNotAccepted) {
+		t.Errorf("Expected ErrLicenseNotAccepted, got %v", err)
+	}
+	if err := manager.CheckLicense("test/licensed"); !errors.Is(err, ErrLicenseNotAccepted) {
+		t.Errorf("Expected ErrLicenseNotAccepted, got %v", err)
+	}
+	acceptances, err := manager.LicenseAcceptances()
+	if err != nil {
+		t.Fatalf("Failed to list license acceptances: %v", err)
+	}
+	if len(acceptances) != 1 || acceptances[0].RevokedAt == nil {
+		t.Errorf("Expected a single revoked acceptance, got %+v", acceptances)
+	}
+}
+
+func TestLicenseAcceptanceNotRequired(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	manager := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log})
+	loadLicensedModel(t, manager)
+
+	if err := manager.CheckLicense("test/licensed"); err != nil {
+		t.Errorf("Expected models to be served without acceptance, got %v", err)
+	}
+	if status, err := manager.GetLicense("test/licensed"); err != nil || status.Gated {
+		t.Errorf("Expected the model not to be gated, got %+v (error %v)", status, err)
+	}
+}
+
+func TestLicenseGated(t *testing.T) {
+	manager := &Manager{requireLicenseAcceptance: true, acceptedLicenses: []string{"apache-2.0"}}
+	tests := []struct {
+		license modelLicense
+		gated   bool
+	}{
+		{modelLicense{}, false},
+		{modelLicense{name: "Apache-2.0"}, false},
+		{modelLicense{name: "llama3.2"}, true},
+	}
+	for _, tt := range tests {
+		if gated := manager.gated(tt.license); gated != tt.gated {
+			t.Errorf("Expected license %q to be gated: %v, got %v", tt.license.name, tt.gated, gated)
+		}
+	}
+}
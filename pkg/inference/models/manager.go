@@ -6,13 +6,17 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/docker/model-runner/pkg/diskusage"
 	"github.com/docker/model-runner/pkg/distribution/builder"
 	"github.com/docker/model-runner/pkg/distribution/distribution"
 	"github.com/docker/model-runner/pkg/distribution/registry"
 	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/go-containerregistry/pkg/authn"
 	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
 	"github.com/docker/model-runner/pkg/internal/utils"
 	"github.com/docker/model-runner/pkg/logging"
@@ -35,17 +39,51 @@ type Manager struct {
 	// pullTokens is a semaphore used to restrict the maximum number of
 	// concurrent pull requests.
 	pullTokens chan struct{}
+	// pulls tracks the pulls started in the background.
+	pulls *pullOperations
+	// ggufConverter is the path of the command that converts safetensors
+	// models to GGUF, if any.
+	ggufConverter string
+	// ggufQuantizer is the path of the command that quantizes GGUF models, if
+	// any.
+	ggufQuantizer string
+	// importPaths are the directories that models can be imported from.
+	importPaths []string
+	// requireLicenseAcceptance indicates whether models with a license are
+	// only served once their license is accepted.
+	requireLicenseAcceptance bool
+	// acceptedLicenses are the licenses accepted by configuration.
+	acceptedLicenses []string
+	// conversionLock serializes model conversions and quantizations.
+	conversionLock sync.Mutex
+	// usageLock protects usageRecorded.
+	usageLock sync.Mutex
+	// usageRecorded maps model references to when their use was last
+	// recorded in the store.
+	usageRecorded map[string]time.Time
 }
 
 // NewManager creates a new model models with the provided clients.
 func NewManager(log logging.Logger, c ClientConfig) *Manager {
 	// Create the model distribution client.
-	distributionClient, err := distribution.NewClient(
+	distributionOpts := []distribution.Option{
 		distribution.WithStoreRootPath(c.StoreRootPath),
 		distribution.WithLogger(c.Logger),
 		distribution.WithTransport(c.Transport),
 		distribution.WithUserAgent(c.UserAgent),
-	)
+		distribution.WithDigestPinning(c.DigestPinning),
+		distribution.WithDownloadChunks(c.DownloadChunks),
+		distribution.WithSignatureVerification(c.SignatureVerification, c.SignatureKeys...),
+		distribution.WithStoreQuota(c.StoreQuota),
+		distribution.WithModelInUse(c.ModelInUse),
+		distribution.WithRegistryMirrors(c.RegistryMirrors),
+		distribution.WithPeers(c.Peers),
+		distribution.WithPeerToken(c.PeerToken),
+	}
+	if c.MinFreeSpace != nil {
+		distributionOpts = append(distributionOpts, distribution.WithMinFreeSpace(*c.MinFreeSpace))
+	}
+	distributionClient, err := distribution.NewClient(distributionOpts...)
 	if err != nil {
 		log.Errorf("Failed to create distribution client: %v", err)
 		// Continue without distribution client. The model manager will still
@@ -56,6 +94,7 @@ func NewManager(log logging.Logger, c ClientConfig) *Manager {
 	registryClient := registry.NewClient(
 		registry.WithTransport(c.Transport),
 		registry.WithUserAgent(c.UserAgent),
+		registry.WithMirrors(c.RegistryMirrors),
 	)
 
 	tokens := make(chan struct{}, maximumConcurrentModelPulls)
@@ -66,20 +105,40 @@ func NewManager(log logging.Logger, c ClientConfig) *Manager {
 	}
 
 	return &Manager{
-		log:                log,
-		distributionClient: distributionClient,
-		registryClient:     registryClient,
-		pullTokens:         tokens,
+		log:                      log,
+		distributionClient:       distributionClient,
+		registryClient:           registryClient,
+		pullTokens:               tokens,
+		pulls:                    newPullOperations(),
+		ggufConverter:            c.GGUFConverter,
+		ggufQuantizer:            c.GGUFQuantizer,
+		importPaths:              c.ImportPaths,
+		requireLicenseAcceptance: c.RequireLicenseAcceptance,
+		acceptedLicenses:         c.AcceptedLicenses,
+		usageRecorded:            make(map[string]time.Time),
 	}
 }
 
 // GetLocal returns a single model by reference.
 // This is the core business logic for retrieving a model from the distribution client.
+//
+// For names that apply adapters to a base model, such as base+adapter, it
+// returns the base model, once the adapters are found to be in the store.
 func (m *Manager) GetLocal(ref string) (types.Model, error) {
 	if m.distributionClient == nil {
 		return nil, fmt.Errorf("model distribution service unavailable")
 	}
+	base, adapters := SplitAdapters(ref)
+	for _, adapter := range adapters {
+		if _, err := m.getAdapter(adapter); err != nil {
+			return nil, err
+		}
+	}
+	return m.getLocal(base)
+}
 
+// getLocal returns a model from the store.
+func (m *Manager) getLocal(ref string) (types.Model, error) {
 	// Query the model - first try without normalization (as ID), then with normalization
 	model, err := m.distributionClient.GetModel(ref)
 	if err != nil && errors.Is(err, distribution.ErrModelNotFound) {
@@ -97,7 +156,17 @@ func (m *Manager) GetLocal(ref string) (types.Model, error) {
 }
 
 // ResolveID resolves a model reference to a model ID. If resolution fails, it returns the original ref.
+// Names that apply adapters to a base model resolve to the IDs of the base
+// model and of the adapters, joined by AdapterSeparator.
 func (m *Manager) ResolveID(modelRef string) string {
+	if base, adapters := SplitAdapters(modelRef); len(adapters) > 0 {
+		ids := []string{m.ResolveID(base)}
+		for _, adapter := range adapters {
+			ids = append(ids, m.ResolveID(adapter))
+		}
+		return strings.Join(ids, AdapterSeparator)
+	}
+
 	// Sanitize modelRef to prevent log forgery
 	sanitizedModelRef := utils.SanitizeForLog(modelRef, -1)
 	model, err := m.GetLocal(sanitizedModelRef)
@@ -127,6 +196,16 @@ func (m *Manager) GetDiskUsage() (int64, error) {
 	return size, nil
 }
 
+// GetReclaimableDiskUsage returns the disk space taken up by blobs in the
+// store that no model references, which garbage collection would reclaim.
+func (m *Manager) GetReclaimableDiskUsage() (int64, error) {
+	garbage, err := m.CollectGarbage(true)
+	if err != nil {
+		return 0, err
+	}
+	return garbage.Size, nil
+}
+
 // GetRemote returns a single remote model.
 func (m *Manager) GetRemote(ctx context.Context, ref string) (types.ModelArtifact, error) {
 	if m.registryClient == nil {
@@ -158,18 +237,29 @@ func (m *Manager) BearerTokenForModel(ctx context.Context, ref string) (string,
 	return tok, nil
 }
 
-// GetBundle returns model bundle.
+// GetBundle returns model bundle. For names that apply adapters to a base
+// model, it returns the bundle of the base model; the bundles of the adapters
+// are returned by GetAdapterBundles.
 func (m *Manager) GetBundle(ref string) (types.ModelBundle, error) {
-	bundle, err := m.distributionClient.GetBundle(ref)
+	base, _ := SplitAdapters(ref)
+	bundle, err := m.distributionClient.GetBundle(base)
 	if err != nil {
 		return nil, fmt.Errorf("error while getting model bundle: %w", err)
 	}
 	return bundle, nil
 }
 
-// InStore checks if a given model is in the local store.
+// InStore checks if a given model is in the local store. For names that apply
+// adapters to a base model, the adapters must be in the store too.
 func (m *Manager) InStore(ref string) (bool, error) {
-	return m.distributionClient.IsModelInStore(ref)
+	base, adapters := SplitAdapters(ref)
+	for _, name := range append([]string{base}, adapters...) {
+		inStore, err := m.distributionClient.IsModelInStore(name)
+		if err != nil || !inStore {
+			return false, err
+		}
+	}
+	return true, nil
 }
 
 // List returns all models.
@@ -218,7 +308,7 @@ func (m *Manager) Delete(reference string, force bool) (*distribution.DeleteMode
 
 // Pull pulls a model to local storage. Any error it returns is suitable
 // for writing back to the client.
-func (m *Manager) Pull(model string, bearerToken string, r *http.Request, w http.ResponseWriter) error {
+func (m *Manager) Pull(model string, bearerToken string, auth *RegistryAuth, r *http.Request, w http.ResponseWriter) error {
 	// Restrict model pull concurrency.
 	select {
 	case <-m.pullTokens:
@@ -229,45 +319,39 @@ func (m *Manager) Pull(model string, bearerToken string, r *http.Request, w http
 		m.pullTokens <- struct{}{}
 	}()
 
-	// Set up response headers for streaming
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Transfer-Encoding", "chunked")
-
-	// Check Accept header to determine content type
-	acceptHeader := r.Header.Get("Accept")
-	isJSON := acceptHeader == "application/json"
-
-	if isJSON {
-		w.Header().Set("Content-Type", "application/json")
-	} else {
-		// Defaults to text/plain
-		w.Header().Set("Content-Type", "text/plain")
-	}
-
-	// Create a flusher to ensure chunks are sent immediately
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		return fmt.Errorf("streaming not supported")
+	progressWriter, err := newProgressResponseWriter(w, r)
+	if err != nil {
+		return err
 	}
+	return m.pull(r.Context(), model, bearerToken, auth, progressWriter)
+}
 
-	// Create a progress writer that writes to the response
-	progressWriter := &progressResponseWriter{
-		writer:  w,
-		flusher: flusher,
-		isJSON:  isJSON,
+// pull pulls a model to local storage, writing progress updates to
+// progressWriter. The caller must hold a pull token.
+func (m *Manager) pull(ctx context.Context, model string, bearerToken string, auth *RegistryAuth, progressWriter io.Writer) error {
+	// Quantized variants of local models are derived locally rather than
+	// pulled.
+	if m.CanQuantize(model) {
+		if err := m.Quantize(ctx, model, progressWriter); err != nil {
+			return fmt.Errorf("error while quantizing model: %w", err)
+		}
+		return nil
 	}
 
 	// Pull the model using the Docker model distribution client
 	m.log.Infoln("Pulling model:", utils.SanitizeForLog(model, -1))
 
-	// Use bearer token if provided
+	// Use bearer token or registry credentials if provided
 	var err error
-	if bearerToken != "" {
+	switch {
+	case bearerToken != "":
 		m.log.Infoln("Using provided bearer token for authentication")
-		err = m.distributionClient.PullModel(r.Context(), model, progressWriter, bearerToken)
-	} else {
-		err = m.distributionClient.PullModel(r.Context(), model, progressWriter)
+		err = m.distributionClient.PullModel(ctx, model, progressWriter, bearerToken)
+	case auth != nil:
+		m.log.Infoln("Using provided registry credentials for authentication")
+		err = m.distributionClient.PullModelWithAuth(ctx, model, progressWriter, registryAuthConfig(auth))
+	default:
+		err = m.distributionClient.PullModel(ctx, model, progressWriter)
 	}
 
 	if err != nil {
@@ -277,6 +361,62 @@ func (m *Manager) Pull(model string, bearerToken string, r *http.Request, w http
 	return nil
 }
 
+// ListHubFiles lists the files in the Hugging Face Hub repository of a model
+// reference, marking those that pulling it downloads.
+func (m *Manager) ListHubFiles(ctx context.Context, model string, bearerToken string) (*HubFileList, error) {
+	if m.distributionClient == nil {
+		return nil, fmt.Errorf("model distribution service unavailable")
+	}
+	files, selection, err := m.distributionClient.ListHubFiles(ctx, model, bearerToken)
+	if err != nil && files == nil {
+		return nil, err
+	}
+	// If no files match the reference, they're listed without a selection.
+	selected := selection.Files()
+	list := &HubFileList{Model: model, Files: make([]HubFile, 0, len(files))}
+	for _, file := range files {
+		list.Files = append(list.Files, HubFile{
+			Path:     file.Path,
+			Size:     file.Size,
+			Selected: slices.Contains(selected, file),
+		})
+	}
+	return list, nil
+}
+
+// EnsureLocal pulls a model to local storage unless it's already present. It's
+// used for models that are referenced by another model's configuration (e.g.
+// speculative decoding draft models), so pull progress isn't reported.
+func (m *Manager) EnsureLocal(ctx context.Context, model string) error {
+	if _, err := m.GetLocal(model); err == nil {
+		return nil
+	} else if !errors.Is(err, distribution.ErrModelNotFound) {
+		return err
+	}
+
+	// Restrict model pull concurrency.
+	select {
+	case <-m.pullTokens:
+	case <-ctx.Done():
+		return context.Canceled
+	}
+	defer func() {
+		m.pullTokens <- struct{}{}
+	}()
+
+	if m.CanQuantize(model) {
+		if err := m.Quantize(ctx, model, io.Discard); err != nil {
+			return fmt.Errorf("error while quantizing model: %w", err)
+		}
+		return nil
+	}
+	m.log.Infoln("Pulling model:", utils.SanitizeForLog(model, -1))
+	if err := m.distributionClient.PullModel(ctx, model, io.Discard); err != nil {
+		return fmt.Errorf("error while pulling model: %w", err)
+	}
+	return nil
+}
+
 func (m *Manager) Load(r io.Reader, progressWriter io.Writer) error {
 	if m.distributionClient == nil {
 		return fmt.Errorf("model distribution service unavailable")
@@ -288,6 +428,27 @@ func (m *Manager) Load(r io.Reader, progressWriter io.Writer) error {
 	return nil
 }
 
+// Export writes a model, with all of its blobs and its manifest, to the
+// response as an archive that Load loads, such as on another machine.
+func (m *Manager) Export(ctx context.Context, ref string, w http.ResponseWriter) error {
+	model, err := m.GetLocal(ref)
+	if err != nil {
+		return err
+	}
+	id, err := model.ID()
+	if err != nil {
+		return fmt.Errorf("getting model ID: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=%q", strings.TrimPrefix(id, "sha256:")[:12]+".tar"))
+	if err := m.distributionClient.ExportModel(ctx, id, w); err != nil {
+		return fmt.Errorf("error while exporting model: %w", err)
+	}
+	return nil
+}
+
 func (m *Manager) Tag(ref, target string) error {
 	if m.distributionClient == nil {
 		return fmt.Errorf("model distribution service unavailable")
@@ -379,46 +540,105 @@ func (m *Manager) Tag(ref, target string) error {
 	return nil
 }
 
-// Push pushes a model from the store to the registry.
-func (m *Manager) Push(model string, r *http.Request, w http.ResponseWriter) error {
-	// Set up response headers for streaming
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Transfer-Encoding", "chunked")
+// ListTags lists the tags of the models in the store, sorted by tag. If model
+// is set, only the tags of that model are listed.
+func (m *Manager) ListTags(model string) ([]ModelTag, error) {
+	var models []types.Model
+	if model != "" {
+		found, err := m.GetLocal(model)
+		if err != nil {
+			return nil, err
+		}
+		models = []types.Model{found}
+	} else {
+		var err error
+		if models, err = m.RawList(); err != nil {
+			return nil, err
+		}
+	}
 
-	// Check Accept header to determine content type
-	acceptHeader := r.Header.Get("Accept")
-	isJSON := acceptHeader == "application/json"
+	tags := []ModelTag{}
+	for _, mdl := range models {
+		id, err := mdl.ID()
+		if err != nil {
+			return nil, fmt.Errorf("error while getting model ID: %w", err)
+		}
+		for _, tag := range mdl.Tags() {
+			tags = append(tags, ModelTag{Tag: tag, ID: id})
+		}
+	}
+	slices.SortFunc(tags, func(a, b ModelTag) int { return strings.Compare(a.Tag, b.Tag) })
+	return tags, nil
+}
 
-	if isJSON {
-		w.Header().Set("Content-Type", "application/json")
-	} else {
-		w.Header().Set("Content-Type", "text/plain")
+// Untag removes a tag from the model that it points to and returns the
+// removed tag. The last tag of a model can't be removed.
+func (m *Manager) Untag(tag string) (ModelTag, error) {
+	if m.distributionClient == nil {
+		return ModelTag{}, fmt.Errorf("model distribution service unavailable")
 	}
 
-	// Create a flusher to ensure chunks are sent immediately
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		return errors.New("streaming not supported")
+	// First try the tag as-is, then normalized, as when getting models
+	id, err := m.distributionClient.Untag(tag)
+	if errors.Is(err, distribution.ErrModelNotFound) {
+		if normalized := NormalizeModelName(tag); normalized != tag {
+			tag = normalized
+			id, err = m.distributionClient.Untag(tag)
+		}
+	}
+	if err != nil {
+		return ModelTag{}, fmt.Errorf("error while untagging model: %w", err)
 	}
+	return ModelTag{Tag: tag, ID: id}, nil
+}
 
-	// Create a progress writer that writes to the response
-	progressWriter := &progressResponseWriter{
-		writer:  w,
-		flusher: flusher,
-		isJSON:  isJSON,
+// UnpinDigest removes the digest that a tag was pinned to when it was first
+// pulled, so that the tag can be pulled again after it's re-pushed. It returns
+// the removed pin and whether the tag was pinned.
+func (m *Manager) UnpinDigest(tag string) (PinnedDigest, bool, error) {
+	if m.distributionClient == nil {
+		return PinnedDigest{}, false, fmt.Errorf("model distribution service unavailable")
+	}
+	tag = NormalizeModelName(tag)
+	digest, ok, err := m.distributionClient.UnpinDigest(tag)
+	if err != nil {
+		return PinnedDigest{}, false, fmt.Errorf("error while unpinning digest: %w", err)
 	}
+	return PinnedDigest{Tag: tag, Digest: digest}, ok, nil
+}
 
-	// Pull the model using the Docker model distribution client
-	m.log.Infoln("Pushing model:", model)
-	err := m.distributionClient.PushModel(r.Context(), model, progressWriter)
+// Push pushes a model from the store to the registry, to the destination and
+// with the credentials specified by the request, if any.
+func (m *Manager) Push(model string, request ModelPushRequest, r *http.Request, w http.ResponseWriter) error {
+	progressWriter, err := newProgressResponseWriter(w, r)
 	if err != nil {
+		return err
+	}
+
+	// Push the model using the Docker model distribution client
+	m.log.Infoln("Pushing model:", utils.SanitizeForLog(model, -1))
+	opts := []distribution.PushOption{distribution.WithPushDestination(request.Destination)}
+	if request.Auth != nil {
+		opts = append(opts, distribution.WithPushAuth(registryAuthConfig(request.Auth)))
+	}
+	if err := m.distributionClient.PushModel(r.Context(), model, progressWriter, opts...); err != nil {
 		return fmt.Errorf("error while pushing model: %w", err)
 	}
 
 	return nil
 }
 
+// registryAuthConfig converts registry credentials of a request into the
+// configuration that the distribution client authenticates with.
+func registryAuthConfig(auth *RegistryAuth) authn.AuthConfig {
+	return authn.AuthConfig{
+		Username:      auth.Username,
+		Password:      auth.Password,
+		IdentityToken: auth.IdentityToken,
+		RegistryToken: auth.RegistryToken,
+	}
+}
+
 func (m *Manager) Package(ref string, tag string, contextSize uint64) error {
 	// Create a builder from an existing model by getting the bundle first
 	// Since ModelArtifact interface is needed to work with the builder
@@ -471,3 +691,22 @@ func (m *Manager) Purge() error {
 	}
 	return nil
 }
+
+// CollectGarbage removes the blobs in the store that no model references, or
+// only reports them if dryRun is set.
+func (m *Manager) CollectGarbage(dryRun bool) (distribution.Garbage, error) {
+	if m.distributionClient == nil {
+		return distribution.Garbage{}, fmt.Errorf("model distribution service unavailable")
+	}
+	return m.distributionClient.CollectGarbage(dryRun)
+}
+
+// VerifyStore re-hashes the content of the store against its digests, and
+// reports the damaged models, pulling their damaged blobs again if repair is
+// set.
+func (m *Manager) VerifyStore(ctx context.Context, repair bool) (distribution.Verification, error) {
+	if m.distributionClient == nil {
+		return distribution.Verification{}, fmt.Errorf("model distribution service unavailable")
+	}
+	return m.distributionClient.VerifyStore(ctx, repair)
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/model-runner/pkg/inference/offline"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+// LoadOfflineBundle loads the model archives of an offline bundle into the
+// store, with the tags that they record, so that they can be used without
+// network access. Blobs that are already in the store are skipped, so loading
+// a bundle again is cheap.
+func (m *Manager) LoadOfflineBundle(bundle *offline.Bundle) error {
+	if m.distributionClient == nil {
+		return errors.New("model distribution service unavailable")
+	}
+	archives, err := bundle.ModelArchives()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, archive := range archives {
+		m.log.Infof("Loading model from offline bundle archive %s", utils.SanitizeForLog(archive, -1))
+		if err := m.loadArchive(archive); err != nil {
+			errs = append(errs, fmt.Errorf("loading %s: %w", filepath.Base(archive), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadArchive loads the model archive at path into the store.
+func (m *Manager) loadArchive(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return m.Load(f, io.Discard)
+}
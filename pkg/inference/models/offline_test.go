@@ -0,0 +1,61 @@
+package models
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/builder"
+	"github.com/docker/model-runner/pkg/distribution/tarball"
+	"github.com/docker/model-runner/pkg/inference/offline"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLoadOfflineBundle(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	manager := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log})
+
+	bundleDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(bundleDir, "models"), 0o755); err != nil {
+		t.Fatalf("Failed to create models directory: %v", err)
+	}
+	f, err := os.Create(filepath.Join(bundleDir, "models", "model.tar"))
+	if err != nil {
+		t.Fatalf("Failed to create model archive: %v", err)
+	}
+	target, err := tarball.NewTarget(f, "test/offline:latest")
+	if err != nil {
+		t.Fatalf("Failed to create tarball target: %v", err)
+	}
+	b, err := builder.FromGGUF(writeGGUF(t, 1))
+	if err != nil {
+		t.Fatalf("Failed to create model builder: %v", err)
+	}
+	if err := b.Build(context.Background(), target, io.Discard); err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Failed to write model archive: %v", err)
+	}
+	bundle, err := offline.Open(bundleDir)
+	if err != nil {
+		t.Fatalf("Failed to open offline bundle: %v", err)
+	}
+
+	// Loading the bundle again, such as on every start, is a no-op.
+	for range 2 {
+		if err := manager.LoadOfflineBundle(bundle); err != nil {
+			t.Fatalf("Failed to load offline bundle: %v", err)
+		}
+	}
+	if _, err := manager.GetLocal("test/offline"); err != nil {
+		t.Errorf("Expected the bundled model to be in the store: %v", err)
+	}
+	models, err := manager.RawList()
+	if err != nil || len(models) != 1 {
+		t.Errorf("Expected a single model, got %d (error %v)", len(models), err)
+	}
+}
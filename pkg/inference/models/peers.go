@@ -0,0 +1,38 @@
+package models
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/distribution/distribution"
+)
+
+// PeerBlobsPrefix is the path prefix at which model runners serve the blobs
+// in their store to their peers.
+const PeerBlobsPrefix = distribution.PeerBlobsPath
+
+// PeerBlobServer serves the blobs in the store, by digest, to the peers of a
+// model runner, the other model runners of its cluster, so that they fetch the
+// layers of models from each other rather than from their registry. Peers
+// verify the content of blobs against their digest.
+type PeerBlobServer struct {
+	manager *Manager
+}
+
+// NewPeerBlobServer creates a server for the blobs of the manager's store.
+func NewPeerBlobServer(manager *Manager) *PeerBlobServer {
+	return &PeerBlobServer{manager: manager}
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (s *PeerBlobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeRegistryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "peer blobs are read-only")
+		return
+	}
+	if s.manager.distributionClient == nil {
+		writeRegistryError(w, http.StatusServiceUnavailable, "UNAVAILABLE", "model distribution service unavailable")
+		return
+	}
+	serveStoreBlob(w, r, s.manager.distributionClient, strings.TrimPrefix(r.URL.Path, PeerBlobsPrefix))
+}
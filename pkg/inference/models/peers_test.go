@@ -0,0 +1,146 @@
+package models
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/model-runner/pkg/apikeys"
+	"github.com/docker/model-runner/pkg/distribution/builder"
+	"github.com/docker/model-runner/pkg/distribution/registry"
+	ggcrregistry "github.com/docker/model-runner/pkg/go-containerregistry/pkg/registry"
+)
+
+func TestPeerBlobs(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	// Count the blobs downloaded from the registry, other than the config.
+	var registryBlobs atomic.Int32
+	reg := ggcrregistry.New()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			registryBlobs.Add(1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer upstream.Close()
+	uri, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse registry URL: %v", err)
+	}
+
+	model, err := builder.FromGGUF(filepath.Join(getProjectRoot(t), "assets", "dummy.gguf"))
+	if err != nil {
+		t.Fatalf("Failed to create model builder: %v", err)
+	}
+	tag := uri.Host + "/ai/model:v1"
+	target, err := registry.NewClient().NewTarget(tag)
+	if err != nil {
+		t.Fatalf("Failed to create model target: %v", err)
+	}
+	if err := model.Build(context.Background(), target, os.Stdout); err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+
+	pullWithToken := func(token string, peers ...string) error {
+		runner := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log, Peers: peers, PeerToken: token})
+		if err := runner.distributionClient.PullModel(context.Background(), tag, io.Discard); err != nil {
+			return err
+		}
+		_, err := runner.GetLocal(tag)
+		return err
+	}
+	pull := func(peers ...string) error {
+		return pullWithToken("", peers...)
+	}
+
+	seed := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log})
+	if err := seed.distributionClient.PullModel(context.Background(), tag, io.Discard); err != nil {
+		t.Fatalf("Failed to pull model into the seed runner: %v", err)
+	}
+	peer := httptest.NewServer(NewPeerBlobServer(seed))
+	defer peer.Close()
+
+	// Layers are fetched from the peer that has them, and only the config
+	// is downloaded from the registry along with the manifest.
+	registryBlobs.Store(0)
+	if err := pull(peer.URL); err != nil {
+		t.Fatalf("Failed to pull model from peer: %v", err)
+	}
+	if n := registryBlobs.Load(); n != 1 {
+		t.Errorf("Expected only the config to be downloaded from the registry, got %d blobs", n)
+	}
+
+	// Peers that require API keys serve blobs to the model runners of their
+	// cluster, which authenticate with the cluster token.
+	keysPath := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(keysPath, []byte(`{"keys":[{"name":"alice","key":"alice-secret"}]}`), 0o600)
+	keys, err := apikeys.NewStore(log, keysPath)
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	keyHandler := apikeys.NewHandler(keys, NewPeerBlobServer(seed))
+	keyHandler.AllowToken("cluster-secret", "GET "+PeerBlobsPrefix)
+	keyedPeer := httptest.NewServer(keyHandler)
+	defer keyedPeer.Close()
+	for token, expected := range map[string]int32{"": 2, "cluster-secret": 1} {
+		registryBlobs.Store(0)
+		if err := pullWithToken(token, keyedPeer.URL); err != nil {
+			t.Fatalf("Failed to pull model with token %q: %v", token, err)
+		}
+		if n := registryBlobs.Load(); n != expected {
+			t.Errorf("Expected %d blobs to be downloaded from the registry with token %q, got %d", expected, token, n)
+		}
+	}
+
+	// Layers that a peer serves corrupt are downloaded from the registry.
+	corrupt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("corrupt"))
+	}))
+	defer corrupt.Close()
+	registryBlobs.Store(0)
+	if err := pull(corrupt.URL); err != nil {
+		t.Fatalf("Failed to pull model despite a corrupt peer: %v", err)
+	}
+	if n := registryBlobs.Load(); n < 2 {
+		t.Errorf("Expected the layers to be downloaded from the registry, got %d blobs", n)
+	}
+
+	// Peers that are down are skipped.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	if err := pull(down.URL, peer.URL); err != nil {
+		t.Fatalf("Failed to pull model with a peer down: %v", err)
+	}
+
+	for _, tt := range []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodHead, PeerBlobsPrefix + "sha256:0000000000000000000000000000000000000000000000000000000000000000", http.StatusNotFound},
+		{http.MethodGet, PeerBlobsPrefix + "invalid", http.StatusBadRequest},
+		{http.MethodPut, PeerBlobsPrefix + "sha256:0000000000000000000000000000000000000000000000000000000000000000", http.StatusMethodNotAllowed},
+	} {
+		req, err := http.NewRequest(tt.method, peer.URL+tt.path, http.NoBody)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s %s: expected status code %d, got %d", tt.method, tt.path, tt.code, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+const (
+	// maximumFinishedPulls is the number of finished pull operations that are
+	// kept for inspection.
+	maximumFinishedPulls = 100
+	// pullSpeedSmoothing is the weight of the latest sample in the moving
+	// average of download speeds.
+	pullSpeedSmoothing = 0.3
+)
+
+// ErrPullNotFound indicates that a pull operation doesn't exist, or finished
+// too long ago to be kept.
+var ErrPullNotFound = errors.New("pull operation not found")
+
+// pullProgressMessage is a progress message written by the distribution
+// client while pulling.
+type pullProgressMessage struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Total   uint64 `json:"total"`
+	Layer   struct {
+		ID      string
+		Size    uint64
+		Current uint64
+		Cached  bool
+	} `json:"layer"`
+}
+
+// layerSample is the latest progress of a layer, from which its download
+// speed is derived.
+type layerSample struct {
+	// index is the index of the layer in PullOperation.Layers.
+	index int
+	// downloaded is the number of bytes downloaded at the time of the sample.
+	downloaded uint64
+	// at is the time of the sample.
+	at time.Time
+}
+
+// pullOperation tracks a pull started in the background. It implements
+// io.Writer to follow the progress messages of the pull.
+type pullOperation struct {
+	// cancel cancels the pull.
+	cancel context.CancelFunc
+	// lock guards all subsequent fields.
+	lock sync.Mutex
+	// state is the state of the pull.
+	state PullOperation
+	// samples maps layer IDs to their latest progress.
+	samples map[string]*layerSample
+	// changed is closed, and replaced, whenever the state changes.
+	changed chan struct{}
+	// pending is the incomplete progress message written last, if any.
+	pending []byte
+}
+
+// newPullOperation creates a queued pull operation for a model.
+func newPullOperation(model string, cancel context.CancelFunc) (*pullOperation, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generating pull ID: %w", err)
+	}
+	return &pullOperation{
+		cancel: cancel,
+		state: PullOperation{
+			ID:      hex.EncodeToString(id),
+			Model:   model,
+			Status:  PullStatusQueued,
+			Layers:  []PullLayer{},
+			Started: time.Now(),
+		},
+		samples: make(map[string]*layerSample),
+		changed: make(chan struct{}),
+	}, nil
+}
+
+// Write implements io.Writer.Write. Progress messages are written as lines of
+// JSON.
+func (o *pullOperation) Write(p []byte) (int, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.pending = append(o.pending, p...)
+	updated := false
+	for {
+		line, rest, found := bytes.Cut(o.pending, []byte("\n"))
+		if !found {
+			break
+		}
+		o.pending = rest
+		var msg pullProgressMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue
+		}
+		o.apply(msg, time.Now())
+		updated = true
+	}
+	if updated {
+		o.notify()
+	}
+	return len(p), nil
+}
+
+// apply updates the state of the pull with a progress message received at the
+// specified time. The caller must hold the lock.
+func (o *pullOperation) apply(msg pullProgressMessage, now time.Time) {
+	if msg.Type != "progress" {
+		o.state.Message = msg.Message
+		return
+	}
+	o.state.Status = PullStatusPulling
+	if msg.Total > 0 {
+		o.state.Total = msg.Total
+	}
+	if msg.Layer.ID == "" {
+		return
+	}
+
+	sample, ok := o.samples[msg.Layer.ID]
+	if !ok {
+		sample = &layerSample{index: len(o.state.Layers), downloaded: msg.Layer.Current, at: now}
+		o.samples[msg.Layer.ID] = sample
+		o.state.Layers = append(o.state.Layers, PullLayer{ID: msg.Layer.ID})
+	}
+	layer := &o.state.Layers[sample.index]
+	layer.Size = msg.Layer.Size
+	layer.Cached = msg.Layer.Cached
+	if !layer.Cached {
+		if elapsed := now.Sub(sample.at).Seconds(); elapsed > 0 && msg.Layer.Current >= sample.downloaded {
+			speed := float64(msg.Layer.Current-sample.downloaded) / elapsed
+			layer.Speed = uint64(pullSpeedSmoothing*speed + (1-pullSpeedSmoothing)*float64(layer.Speed))
+			sample.downloaded, sample.at = msg.Layer.Current, now
+		}
+		layer.Downloaded = msg.Layer.Current
+	}
+	layer.ETA = 0
+	if layer.Downloaded >= layer.Size || layer.Cached {
+		layer.Speed = 0
+	} else if layer.Speed > 0 {
+		layer.ETA = int64((layer.Size - layer.Downloaded + layer.Speed - 1) / layer.Speed)
+	}
+
+	o.state.Downloaded, o.state.Speed, o.state.ETA = 0, 0, 0
+	for _, l := range o.state.Layers {
+		o.state.Downloaded += l.Downloaded
+		o.state.Speed += l.Speed
+	}
+	if o.state.Speed > 0 && o.state.Total > o.state.Downloaded {
+		o.state.ETA = int64((o.state.Total - o.state.Downloaded + o.state.Speed - 1) / o.state.Speed)
+	}
+}
+
+// setStatus sets the status of the pull.
+func (o *pullOperation) setStatus(status PullStatus) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.state.Status = status
+	o.notify()
+}
+
+// finish records the outcome of the pull.
+func (o *pullOperation) finish(err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	now := time.Now()
+	o.state.Finished = &now
+	o.state.Speed, o.state.ETA = 0, 0
+	for i := range o.state.Layers {
+		o.state.Layers[i].Speed, o.state.Layers[i].ETA = 0, 0
+	}
+	switch {
+	case err == nil:
+		o.state.Status = PullStatusSucceeded
+	case errors.Is(err, context.Canceled):
+		o.state.Status = PullStatusCanceled
+	default:
+		o.state.Status = PullStatusFailed
+		o.state.Error = err.Error()
+	}
+	o.notify()
+}
+
+// notify wakes up the watchers of the pull. The caller must hold the lock.
+func (o *pullOperation) notify() {
+	close(o.changed)
+	o.changed = make(chan struct{})
+}
+
+// snapshot returns the state of the pull, along with a channel that is closed
+// when it changes.
+func (o *pullOperation) snapshot() (PullOperation, <-chan struct{}) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	state := o.state
+	state.Layers = append([]PullLayer{}, o.state.Layers...)
+	return state, o.changed
+}
+
+// done returns whether the pull finished.
+func (o *pullOperation) done() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.state.Finished != nil
+}
+
+// pullOperations tracks the pulls started in the background.
+type pullOperations struct {
+	// lock guards all subsequent fields.
+	lock sync.Mutex
+	// operations maps IDs to operations.
+	operations map[string]*pullOperation
+	// order lists the IDs of the operations in the order they were started.
+	order []string
+}
+
+// newPullOperations creates an empty set of pull operations.
+func newPullOperations() *pullOperations {
+	return &pullOperations{operations: make(map[string]*pullOperation)}
+}
+
+// add adds an operation, forgetting the oldest finished operations beyond
+// maximumFinishedPulls.
+func (p *pullOperations) add(o *pullOperation) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.operations[o.state.ID] = o
+	p.order = append(p.order, o.state.ID)
+
+	finished := 0
+	for i := len(p.order) - 1; i >= 0; i-- {
+		id := p.order[i]
+		if !p.operations[id].done() {
+			continue
+		}
+		if finished++; finished > maximumFinishedPulls {
+			delete(p.operations, id)
+			p.order = append(p.order[:i], p.order[i+1:]...)
+		}
+	}
+}
+
+// get returns the operation with an ID.
+func (p *pullOperations) get(id string) (*pullOperation, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	o, ok := p.operations[id]
+	if !ok {
+		return nil, ErrPullNotFound
+	}
+	return o, nil
+}
+
+// list returns the state of the operations, in the order they were started.
+func (p *pullOperations) list() []PullOperation {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	states := make([]PullOperation, 0, len(p.order))
+	for _, id := range p.order {
+		state, _ := p.operations[id].snapshot()
+		states = append(states, state)
+	}
+	return states
+}
+
+// StartPull starts pulling a model in the background and returns the pull
+// operation, whose progress is reported by WatchPull. Pulls are subject to the
+// same concurrency limit as those made with Pull, and queue until they can
+// start.
+func (m *Manager) StartPull(model string, bearerToken string, auth *RegistryAuth) (PullOperation, error) {
+	if m.distributionClient == nil {
+		return PullOperation{}, fmt.Errorf("model distribution service unavailable")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	op, err := newPullOperation(model, cancel)
+	if err != nil {
+		cancel()
+		return PullOperation{}, err
+	}
+	m.pulls.add(op)
+
+	go func() {
+		defer cancel()
+		select {
+		case <-m.pullTokens:
+		case <-ctx.Done():
+			op.finish(ctx.Err())
+			return
+		}
+		defer func() {
+			m.pullTokens <- struct{}{}
+		}()
+		op.setStatus(PullStatusPulling)
+		err := m.pull(ctx, model, bearerToken, auth, op)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			m.log.Warnf("Failed to pull model %s: %v", utils.SanitizeForLog(model, -1), err)
+		}
+		op.finish(err)
+	}()
+
+	state, _ := op.snapshot()
+	return state, nil
+}
+
+// WatchPull returns the state of a pull operation, along with a channel that
+// is closed when it changes.
+func (m *Manager) WatchPull(id string) (PullOperation, <-chan struct{}, error) {
+	op, err := m.pulls.get(id)
+	if err != nil {
+		return PullOperation{}, nil, err
+	}
+	state, changed := op.snapshot()
+	return state, changed, nil
+}
+
+// ListPulls returns the pull operations that are in progress or finished
+// recently, in the order they were started.
+func (m *Manager) ListPulls() []PullOperation {
+	return m.pulls.list()
+}
+
+// CancelPull cancels a pull operation. Canceling a finished pull has no
+// effect.
+func (m *Manager) CancelPull(id string) (PullOperation, error) {
+	op, err := m.pulls.get(id)
+	if err != nil {
+		return PullOperation{}, err
+	}
+	op.cancel()
+	state, _ := op.snapshot()
+	return state, nil
+}
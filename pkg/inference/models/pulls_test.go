@@ -0,0 +1,179 @@
+package models
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/model-runner/pkg/distribution/builder"
+	"github.com/docker/model-runner/pkg/distribution/registry"
+	ggcrregistry "github.com/docker/model-runner/pkg/go-containerregistry/pkg/registry"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+func TestPullOperationProgress(t *testing.T) {
+	op, err := newPullOperation("ai/model:latest", func() {})
+	if err != nil {
+		t.Fatalf("Failed to create pull operation: %v", err)
+	}
+	progress := func(id string, size, current uint64, cached bool, at time.Time) {
+		var msg pullProgressMessage
+		msg.Type = "progress"
+		msg.Total = 3000
+		msg.Layer.ID, msg.Layer.Size, msg.Layer.Current, msg.Layer.Cached = id, size, current, cached
+		op.apply(msg, at)
+	}
+
+	start := time.Now()
+	progress("sha256:cached", 500, 0, true, start)
+	progress("sha256:a", 1000, 0, false, start)
+	progress("sha256:b", 2000, 0, false, start)
+	progress("sha256:a", 1000, 500, false, start.Add(time.Second))
+	progress("sha256:b", 2000, 1000, false, start.Add(time.Second))
+
+	state, _ := op.snapshot()
+	if len(state.Layers) != 3 || !state.Layers[0].Cached || state.Layers[0].Speed != 0 {
+		t.Fatalf("Expected a cached layer followed by two downloading layers, got %+v", state.Layers)
+	}
+	// Speeds are smoothed, starting from zero.
+	a, b := state.Layers[1], state.Layers[2]
+	if a.Downloaded != 500 || a.Speed != 150 || a.ETA != 4 {
+		t.Errorf("Unexpected progress of layer a: %+v", a)
+	}
+	if b.Downloaded != 1000 || b.Speed != 300 || b.ETA != 4 {
+		t.Errorf("Unexpected progress of layer b: %+v", b)
+	}
+	if state.Status != PullStatusPulling || state.Total != 3000 || state.Downloaded != 1500 || state.Speed != 450 || state.ETA != 4 {
+		t.Errorf("Unexpected progress of pull: %+v", state)
+	}
+
+	progress("sha256:a", 1000, 1000, false, start.Add(2*time.Second))
+	state, _ = op.snapshot()
+	if a := state.Layers[1]; a.Speed != 0 || a.ETA != 0 {
+		t.Errorf("Expected a downloaded layer to have no speed or ETA, got %+v", a)
+	}
+
+	// Watchers are notified when the pull finishes.
+	_, changed := op.snapshot()
+	op.finish(errors.New("boom"))
+	select {
+	case <-changed:
+	default:
+		t.Fatalf("Expected watchers to be notified")
+	}
+	state, _ = op.snapshot()
+	if state.Status != PullStatusFailed || state.Error != "boom" || state.Finished == nil || state.Speed != 0 {
+		t.Errorf("Unexpected state of failed pull: %+v", state)
+	}
+}
+
+func TestPullOperationsRetention(t *testing.T) {
+	pulls := newPullOperations()
+	running, _ := newPullOperation("ai/running", func() {})
+	pulls.add(running)
+	for i := 0; i < maximumFinishedPulls+5; i++ {
+		op, err := newPullOperation("ai/model", func() {})
+		if err != nil {
+			t.Fatalf("Failed to create pull operation: %v", err)
+		}
+		pulls.add(op)
+		op.finish(nil)
+	}
+	last, _ := newPullOperation("ai/last", func() {})
+	pulls.add(last)
+
+	if _, err := pulls.get(running.state.ID); err != nil {
+		t.Errorf("Expected running pulls to be kept: %v", err)
+	}
+	if listed := pulls.list(); len(listed) != maximumFinishedPulls+2 {
+		t.Errorf("Expected %d pulls to be kept, got %d", maximumFinishedPulls+2, len(listed))
+	}
+}
+
+func TestPullEvents(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	server := httptest.NewServer(ggcrregistry.New())
+	defer server.Close()
+	uri, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse registry URL: %v", err)
+	}
+	model, err := builder.FromGGUF(filepath.Join(getProjectRoot(t), "assets", "dummy.gguf"))
+	if err != nil {
+		t.Fatalf("Failed to create model builder: %v", err)
+	}
+	tag := uri.Host + "/ai/model:v1"
+	target, err := registry.NewClient().NewTarget(tag)
+	if err != nil {
+		t.Fatalf("Failed to create model target: %v", err)
+	}
+	if err := model.Build(context.Background(), target, os.Stdout); err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+
+	handler := NewHTTPHandler(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log}, nil, &mockMemoryEstimator{})
+	runner := httptest.NewServer(handler)
+	defer runner.Close()
+
+	resp, err := http.Post(runner.URL+inference.ModelsPrefix+"/pulls", "application/json", strings.NewReader(`{"from": "`+tag+`"}`))
+	if err != nil {
+		t.Fatalf("Failed to start pull: %v", err)
+	}
+	var started PullOperation
+	err = json.NewDecoder(resp.Body).Decode(&started)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusAccepted || started.ID == "" {
+		t.Fatalf("Expected pull to be started, got status %d and %+v (error %v)", resp.StatusCode, started, err)
+	}
+	if location := resp.Header.Get("Location"); location != inference.ModelsPrefix+"/pulls/"+started.ID {
+		t.Errorf("Unexpected location %q", location)
+	}
+
+	resp, err = http.Get(runner.URL + inference.ModelsPrefix + "/pulls/" + started.ID + "/events")
+	if err != nil {
+		t.Fatalf("Failed to stream pull events: %v", err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", contentType)
+	}
+	var event string
+	var done PullOperation
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+		} else if data, ok := strings.CutPrefix(line, "data: "); ok && event == "done" {
+			if err := json.Unmarshal([]byte(data), &done); err != nil {
+				t.Fatalf("Failed to decode event: %v", err)
+			}
+		}
+	}
+	if done.Status != PullStatusSucceeded || done.Finished == nil || len(done.Layers) == 0 || done.Downloaded != done.Total {
+		t.Errorf("Expected the stream to end with the successful pull, got %+v", done)
+	}
+	if _, err := handler.manager.GetLocal(tag); err != nil {
+		t.Errorf("Expected the model to be pulled: %v", err)
+	}
+
+	resp, err = http.Get(runner.URL + inference.ModelsPrefix + "/pulls/unknown/events")
+	if err != nil {
+		t.Fatalf("Failed to request events: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d for an unknown pull, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
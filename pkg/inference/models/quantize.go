@@ -0,0 +1,228 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/distribution/builder"
+	"github.com/docker/model-runner/pkg/distribution/distribution"
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+// ErrQuantizationUnavailable indicates that no GGUF quantizer is configured.
+var ErrQuantizationUnavailable = errors.New("model quantization is unavailable: no GGUF quantizer is configured")
+
+// ggufQuantizationTypes are the types that models can be quantized to on
+// demand, as accepted by llama-quantize.
+var ggufQuantizationTypes = []string{
+	"Q2_K", "Q3_K_S", "Q3_K_M", "Q3_K_L", "Q4_0", "Q4_1", "Q4_K_S", "Q4_K_M",
+	"Q5_0", "Q5_1", "Q5_K_S", "Q5_K_M", "Q6_K", "Q8_0",
+}
+
+// quantizationSourceTypes are the weight types of GGUF models that can be
+// quantized, which are those with higher precision than any quantization type.
+var quantizationSourceTypes = []string{"F32", "F16", "BF16"}
+
+// normalizeQuantization normalizes the quantization of a model's config, which
+// may be prefixed with "MOSTLY_", for comparison with quantization types.
+func normalizeQuantization(quantization string) string {
+	return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(quantization)), "MOSTLY_")
+}
+
+// quantizedVariant splits a model reference whose tag is a quantization type,
+// such as "myorg/model:Q4_K_M", into its repository and quantization type.
+func quantizedVariant(ref string) (repository string, quantization string, ok bool) {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i:], "/") || strings.Contains(ref, "@") {
+		return "", "", false
+	}
+	quantization = strings.ToUpper(ref[i+1:])
+	if !slices.Contains(ggufQuantizationTypes, quantization) {
+		return "", "", false
+	}
+	return ref[:i], quantization, true
+}
+
+// quantizationSource returns the local model that a quantized variant can be
+// derived from: a GGUF model in the same repository with higher precision
+// weights, preferring the one tagged "latest".
+func (m *Manager) quantizationSource(repository string) (types.Model, error) {
+	models, err := m.distributionClient.ListModels()
+	if err != nil {
+		return nil, fmt.Errorf("listing models: %w", err)
+	}
+	var source types.Model
+	for _, model := range models {
+		if !slices.ContainsFunc(model.Tags(), func(tag string) bool {
+			return strings.HasPrefix(tag, repository+":")
+		}) {
+			continue
+		}
+		config, err := model.Config()
+		if err != nil || (config.Format != "" && config.Format != types.FormatGGUF) ||
+			!slices.Contains(quantizationSourceTypes, normalizeQuantization(config.Quantization)) {
+			continue
+		}
+		if slices.Contains(model.Tags(), repository+":"+defaultTag) {
+			return model, nil
+		}
+		if source == nil {
+			source = model
+		}
+	}
+	return source, nil
+}
+
+// CanQuantize returns true if the model reference is a quantized variant that
+// isn't in the store, but can be derived from a model that is.
+func (m *Manager) CanQuantize(ref string) bool {
+	if m.distributionClient == nil || m.ggufQuantizer == "" {
+		return false
+	}
+	repository, _, ok := quantizedVariant(ref)
+	if !ok {
+		return false
+	}
+	if _, err := m.distributionClient.GetModel(ref); err == nil {
+		return false
+	}
+	source, err := m.quantizationSource(repository)
+	return err == nil && source != nil
+}
+
+// Quantize derives a quantized variant of a model, such as "myorg/model:Q4_K_M",
+// from a model in the same repository with higher precision GGUF weights. The
+// quantized weights are packaged with the source model's projector, chat
+// template, and context size, and cached in the store as a new model tagged
+// with the reference, which records the model it's derived from.
+func (m *Manager) Quantize(ctx context.Context, ref string, progressWriter io.Writer) error {
+	if m.distributionClient == nil {
+		return errors.New("model distribution service unavailable")
+	}
+	if m.ggufQuantizer == "" {
+		return ErrQuantizationUnavailable
+	}
+	repository, quantization, ok := quantizedVariant(ref)
+	if !ok {
+		return fmt.Errorf("%q doesn't refer to a quantized variant, expected a tag of %s",
+			ref, strings.Join(ggufQuantizationTypes, ", "))
+	}
+
+	m.conversionLock.Lock()
+	defer m.conversionLock.Unlock()
+
+	// The variant may have been derived while waiting for the lock.
+	if _, err := m.distributionClient.GetModel(ref); err == nil {
+		return nil
+	}
+	source, err := m.quantizationSource(repository)
+	if err != nil {
+		return err
+	}
+	if source == nil {
+		return fmt.Errorf("no model with higher precision GGUF weights in %s to quantize: %w",
+			repository, distribution.ErrModelNotFound)
+	}
+	sourceID, err := source.ID()
+	if err != nil {
+		return fmt.Errorf("getting source model ID: %w", err)
+	}
+	bundle, err := m.distributionClient.GetBundle(sourceID)
+	if err != nil {
+		return fmt.Errorf("getting source model bundle: %w", err)
+	}
+
+	// Quantized weights are written next to the store, since they're about as
+	// large as a model and are copied into the store once packaged.
+	stagingDir, err := os.MkdirTemp(m.distributionClient.GetStorePath(), ".quantize-")
+	if err != nil {
+		return fmt.Errorf("creating staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingDir)
+	outputPath := filepath.Join(stagingDir, "model.gguf")
+
+	m.log.Infof("Quantizing model %s to %s", utils.SanitizeForLog(sourceID), quantization)
+	writeQuantizationProgress(progressWriter, fmt.Sprintf("Quantizing %s to %s", sourceID, quantization))
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, m.ggufQuantizer, bundle.GGUFPath(), outputPath, quantization)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		out := output.Bytes()
+		if len(out) > maximumConverterOutput {
+			out = out[len(out)-maximumConverterOutput:]
+		}
+		return fmt.Errorf("quantizing model: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	b, err := builder.FromGGUF(outputPath)
+	if err != nil {
+		return fmt.Errorf("packaging quantized model: %w", err)
+	}
+	if path := bundle.MMPROJPath(); path != "" {
+		if b, err = b.WithMultimodalProjector(path); err != nil {
+			return fmt.Errorf("packaging quantized model: %w", err)
+		}
+	}
+	if path := bundle.ChatTemplatePath(); path != "" {
+		if b, err = b.WithChatTemplateFile(path); err != nil {
+			return fmt.Errorf("packaging quantized model: %w", err)
+		}
+	}
+	if config, err := source.Config(); err == nil && config.ContextSize != nil {
+		b = b.WithContextSize(*config.ContextSize)
+	}
+	if err := m.distributionClient.WriteModel(b.WithDerivedFrom(sourceID).Model(), []string{ref}, progressWriter); err != nil {
+		return err
+	}
+	m.log.Infof("Quantized model %s to %s", utils.SanitizeForLog(sourceID), utils.SanitizeForLog(ref, -1))
+	writeQuantizationProgress(progressWriter, "Model quantized successfully")
+	return nil
+}
+
+// writeQuantizationProgress writes a progress message in the same way as pull
+// messages are written.
+func writeQuantizationProgress(w io.Writer, message string) {
+	if w == nil {
+		return
+	}
+	fmt.Fprintln(w, message)
+}
+
+// GetDerivedDiskUsage returns the disk space used by the weights of models
+// that were derived locally from other models, such as quantized variants.
+func (m *Manager) GetDerivedDiskUsage() (int64, error) {
+	if m.distributionClient == nil {
+		return 0, errors.New("model distribution service unavailable")
+	}
+	models, err := m.distributionClient.ListModels()
+	if err != nil {
+		return 0, fmt.Errorf("listing models: %w", err)
+	}
+	var size int64
+	for _, model := range models {
+		descriptor, err := model.Descriptor()
+		if err != nil || descriptor.DerivedFrom == "" {
+			continue
+		}
+		paths, err := model.GGUFPaths()
+		if err != nil {
+			continue
+		}
+		for _, path := range paths {
+			if info, err := os.Stat(path); err == nil {
+				size += info.Size()
+			}
+		}
+	}
+	return size, nil
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/builder"
+
+	"github.com/sirupsen/logrus"
+)
+
+// writeGGUF writes a GGUF file without tensors, whose metadata declares the
+// specified llama.cpp file type (e.g. 1 for F16 and 15 for Q4_K_M).
+func writeGGUF(t *testing.T, fileType uint32) string {
+	t.Helper()
+	content := []byte("GGUF")
+	content = binary.LittleEndian.AppendUint32(content, 3) // Version
+	content = binary.LittleEndian.AppendUint64(content, 0) // Tensors
+	content = binary.LittleEndian.AppendUint64(content, 2) // Metadata
+	appendString := func(s string) {
+		content = binary.LittleEndian.AppendUint64(content, uint64(len(s)))
+		content = append(content, s...)
+	}
+	appendString("general.architecture")
+	content = binary.LittleEndian.AppendUint32(content, 8) // String
+	appendString("llama")
+	appendString("general.file_type")
+	content = binary.LittleEndian.AppendUint32(content, 4) // Uint32
+	content = binary.LittleEndian.AppendUint32(content, fileType)
+
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("Failed to write GGUF file: %v", err)
+	}
+	return path
+}
+
+// loadF16Model loads a GGUF model with F16 weights into the manager's store,
+// tagged test/model:latest, and returns its ID.
+func loadF16Model(t *testing.T, manager *Manager) string {
+	t.Helper()
+	b, err := builder.FromGGUF(writeGGUF(t, 1))
+	if err != nil {
+		t.Fatalf("Failed to create model builder: %v", err)
+	}
+	id := loadBuiltModel(t, manager, b.WithContextSize(4096))
+	if err := manager.Tag(id, "test/model:latest"); err != nil {
+		t.Fatalf("Failed to tag model: %v", err)
+	}
+	return id
+}
+
+func TestQuantizedVariant(t *testing.T) {
+	tests := []struct {
+		ref          string
+		repository   string
+		quantization string
+		ok           bool
+	}{
+		{"test/model:Q4_K_M", "test/model", "Q4_K_M", true},
+		{"test/model:q8_0", "test/model", "Q8_0", true},
+		{"localhost:5000/test/model:Q5_K_S", "localhost:5000/test/model", "Q5_K_S", true},
+		{"test/model:latest", "", "", false},
+		{"test/model", "", "", false},
+		{"localhost:5000/test/model", "", "", false},
+		{"test/model@sha256:0123456789abcdef", "", "", false},
+	}
+	for _, tt := range tests {
+		repository, quantization, ok := quantizedVariant(tt.ref)
+		if repository != tt.repository || quantization != tt.quantization || ok != tt.ok {
+			t.Errorf("quantizedVariant(%q) = %q, %q, %v, expected %q, %q, %v",
+				tt.ref, repository, quantization, ok, tt.repository, tt.quantization, tt.ok)
+		}
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake quantizer requires a POSIX shell")
+	}
+	log := logrus.NewEntry(logrus.StandardLogger())
+
+	// The fake quantizer records its arguments and writes a Q4_K_M GGUF file
+	// to the output path.
+	quantized := writeGGUF(t, 15)
+	quantizerDir := t.TempDir()
+	argsPath := filepath.Join(quantizerDir, "args")
+	quantizer := filepath.Join(quantizerDir, "llama-quantize")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %q\ncp %q \"$2\"\n", argsPath, quantized)
+	if err := os.WriteFile(quantizer, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write quantizer: %v", err)
+	}
+
+	storePath := t.TempDir()
+	manager := NewManager(log, ClientConfig{StoreRootPath: storePath, Logger: log, GGUFQuantizer: quantizer})
+	id := loadF16Model(t, manager)
+	if !manager.CanQuantize("test/model:Q4_K_M") {
+		t.Fatalf("Expected F16 model to be quantizable")
+	}
+	if manager.CanQuantize("test/model:latest") || manager.CanQuantize("other/model:Q4_K_M") {
+		t.Errorf("Expected only quantized variants of local models to be quantizable")
+	}
+
+	if err := manager.Quantize(context.Background(), "test/model:Q4_K_M", nil); err != nil {
+		t.Fatalf("Failed to quantize model: %v", err)
+	}
+	args, err := os.ReadFile(argsPath)
+	if err != nil {
+		t.Fatalf("Failed to read quantizer arguments: %v", err)
+	}
+	if fields := strings.Fields(string(args)); len(fields) != 3 || fields[2] != "Q4_K_M" {
+		t.Errorf("Unexpected quantizer arguments %q", args)
+	}
+
+	// The quantized variant is a separate model, which records its source and
+	// keeps the source's configuration.
+	model, err := manager.GetLocal("test/model:Q4_K_M")
+	if err != nil {
+		t.Fatalf("Failed to get quantized model: %v", err)
+	}
+	if quantizedID, _ := model.ID(); quantizedID == id {
+		t.Errorf("Expected quantized model to have its own ID")
+	}
+	descriptor, err := model.Descriptor()
+	if err != nil || descriptor.DerivedFrom != id {
+		t.Errorf("Expected quantized model to be derived from %s, got %q (error %v)", id, descriptor.DerivedFrom, err)
+	}
+	config, err := model.Config()
+	if err != nil {
+		t.Fatalf("Failed to get quantized model config: %v", err)
+	}
+	if normalizeQuantization(config.Quantization) != "Q4_K_M" {
+		t.Errorf("Expected Q4_K_M quantization, got %q", config.Quantization)
+	}
+	if config.ContextSize == nil || *config.ContextSize != 4096 {
+		t.Errorf("Expected the source model's context size, got %v", config.ContextSize)
+	}
+	if manager.CanQuantize("test/model:Q4_K_M") {
+		t.Errorf("Expected quantized model not to be quantized again")
+	}
+
+	// Only the quantized weights count as derived disk usage.
+	info, err := os.Stat(quantized)
+	if err != nil {
+		t.Fatalf("Failed to stat quantized weights: %v", err)
+	}
+	if usage, err := manager.GetDerivedDiskUsage(); err != nil || usage != info.Size() {
+		t.Errorf("Expected derived disk usage of %d, got %d (error %v)", info.Size(), usage, err)
+	}
+
+	// Models that are ensured to be local are quantized rather than pulled.
+	if err := manager.EnsureLocal(context.Background(), "test/model:Q8_0"); err != nil {
+		t.Fatalf("Failed to ensure quantized model: %v", err)
+	}
+	if _, err := manager.GetLocal("test/model:Q8_0"); err != nil {
+		t.Errorf("Expected quantized model to be local: %v", err)
+	}
+	if entries, _ := filepath.Glob(filepath.Join(storePath, ".quantize-*")); len(entries) != 0 {
+		t.Errorf("Expected staging directories to be removed, got %v", entries)
+	}
+}
+
+func TestQuantizeFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake quantizer requires a POSIX shell")
+	}
+	log := logrus.NewEntry(logrus.StandardLogger())
+
+	unconfigured := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log})
+	loadF16Model(t, unconfigured)
+	if unconfigured.CanQuantize("test/model:Q4_K_M") {
+		t.Errorf("Expected models not to be quantizable without a quantizer")
+	}
+	if err := unconfigured.Quantize(context.Background(), "test/model:Q4_K_M", nil); !errors.Is(err, ErrQuantizationUnavailable) {
+		t.Errorf("Expected quantization to be unavailable, got %v", err)
+	}
+
+	quantizer := filepath.Join(t.TempDir(), "quantize")
+	script := "#!/bin/sh\necho 'unsupported tensor type' >&2\nexit 1\n"
+	if err := os.WriteFile(quantizer, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write quantizer: %v", err)
+	}
+	manager := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log, GGUFQuantizer: quantizer})
+	loadF16Model(t, manager)
+	if err := manager.Quantize(context.Background(), "test/model:Q4_K_M", nil); err == nil || !strings.Contains(err.Error(), "unsupported tensor type") {
+		t.Fatalf("Expected quantization error with quantizer output, got %v", err)
+	}
+	if _, err := manager.GetLocal("test/model:Q4_K_M"); err == nil {
+		t.Errorf("Expected failed quantization not to leave a model")
+	}
+	if err := manager.Quantize(context.Background(), "missing/model:Q4_K_M", nil); err == nil {
+		t.Errorf("Expected error quantizing a model without a source")
+	}
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/docker/model-runner/pkg/distribution/distribution"
+	"github.com/docker/model-runner/pkg/distribution/registry"
+	v1 "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1"
+	ggcrtypes "github.com/docker/model-runner/pkg/go-containerregistry/pkg/v1/types"
+	"github.com/docker/model-runner/pkg/internal/utils"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// RegistryCachePrefix is the path prefix of the registry API served by a
+// RegistryCache.
+const RegistryCachePrefix = "/v2/"
+
+// RegistryCache serves the models in the store through the read-only subset of
+// the OCI distribution API that pulls use, so that model runners can use it as
+// a registry mirror. Models that are requested by tag are pulled from their
+// registry first, so that the store acts as a pull-through cache: each model
+// is downloaded once for all the model runners that use the cache.
+type RegistryCache struct {
+	log     logging.Logger
+	manager *Manager
+	// pulls deduplicates concurrent pulls of the same model.
+	pulls singleflight.Group
+}
+
+// NewRegistryCache creates a registry cache that serves the models of the
+// manager's store.
+func NewRegistryCache(log logging.Logger, manager *Manager) *RegistryCache {
+	return &RegistryCache{log: log, manager: manager}
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (c *RegistryCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeRegistryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "the registry cache is read-only")
+		return
+	}
+	if c.manager.distributionClient == nil {
+		writeRegistryError(w, http.StatusServiceUnavailable, "UNAVAILABLE", "model distribution service unavailable")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, RegistryCachePrefix)
+	if path == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if repository, reference, ok := cutLast(path, "/manifests/"); ok {
+		c.serveManifest(w, r, repository, reference)
+		return
+	}
+	if _, digest, ok := cutLast(path, "/blobs/"); ok {
+		c.serveBlob(w, r, digest)
+		return
+	}
+	writeRegistryError(w, http.StatusNotFound, "NOT_FOUND", "not found")
+}
+
+// serveManifest serves the manifest of a model. Models requested by tag are
+// pulled first, which only downloads what isn't in the store yet; if the
+// registry can't be reached, the model in the store is served as-is.
+func (c *RegistryCache) serveManifest(w http.ResponseWriter, r *http.Request, repository, reference string) {
+	// Digests are looked up by ID, since the store doesn't record the
+	// repositories that models were pulled from.
+	lookup := reference
+	if !strings.Contains(reference, ":") {
+		lookup = repository + ":" + reference
+		if namespace := r.URL.Query().Get(registry.NamespaceParameter); namespace != "" {
+			lookup = namespace + "/" + lookup
+		}
+		if err := c.pull(r.Context(), lookup); err != nil {
+			c.log.Warnf("Registry cache failed to pull %s, serving the cached model if any: %v", utils.SanitizeForLog(lookup), err)
+		}
+	}
+
+	raw, mediaType, digest, err := c.manager.distributionClient.ReadManifest(lookup)
+	if err != nil {
+		if errors.Is(err, distribution.ErrModelNotFound) {
+			writeRegistryError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown")
+			return
+		}
+		writeRegistryError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+	if mediaType == "" {
+		mediaType = ggcrtypes.OCIManifestSchema1
+	}
+	w.Header().Set("Content-Type", string(mediaType))
+	w.Header().Set("Docker-Content-Digest", digest.String())
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(string(raw)))
+}
+
+// pull pulls a model into the store, sharing the pull with concurrent
+// requests for the same model. The pull isn't canceled if the request that
+// started it is, since other requests may be waiting for it.
+func (c *RegistryCache) pull(ctx context.Context, ref string) error {
+	ch := c.pulls.DoChan(ref, func() (any, error) {
+		c.log.Infoln("Registry cache pulling model:", utils.SanitizeForLog(ref))
+		return nil, c.manager.distributionClient.PullModel(context.WithoutCancel(ctx), ref, io.Discard)
+	})
+	select {
+	case res := <-ch:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serveBlob serves a blob from the store. Range requests are supported, so
+// that downloads can be resumed and chunked.
+func (c *RegistryCache) serveBlob(w http.ResponseWriter, r *http.Request, digest string) {
+	serveStoreBlob(w, r, c.manager.distributionClient, digest)
+}
+
+// serveStoreBlob serves a blob from the store of a distribution client,
+// reporting errors in the format of the OCI distribution API.
+func serveStoreBlob(w http.ResponseWriter, r *http.Request, client *distribution.Client, digest string) {
+	hash, err := v1.NewHash(digest)
+	if err != nil {
+		writeRegistryError(w, http.StatusBadRequest, "DIGEST_INVALID", "invalid digest")
+		return
+	}
+	f, err := client.OpenBlob(hash)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			writeRegistryError(w, http.StatusNotFound, "BLOB_UNKNOWN", "blob unknown")
+			return
+		}
+		writeRegistryError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", hash.String())
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// writeRegistryError writes an error in the format of the OCI distribution
+// API.
+func writeRegistryError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/model-runner/pkg/distribution/builder"
+	"github.com/docker/model-runner/pkg/distribution/registry"
+	ggcrregistry "github.com/docker/model-runner/pkg/go-containerregistry/pkg/registry"
+)
+
+func TestRegistryCache(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	upstream := httptest.NewServer(ggcrregistry.New())
+	defer upstream.Close()
+	uri, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse registry URL: %v", err)
+	}
+
+	model, err := builder.FromGGUF(filepath.Join(getProjectRoot(t), "assets", "dummy.gguf"))
+	if err != nil {
+		t.Fatalf("Failed to create model builder: %v", err)
+	}
+	tag := uri.Host + "/ai/model:v1"
+	target, err := registry.NewClient().NewTarget(tag)
+	if err != nil {
+		t.Fatalf("Failed to create model target: %v", err)
+	}
+	if err := model.Build(context.Background(), target, os.Stdout); err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+
+	cacheManager := NewManager(log, ClientConfig{StoreRootPath: t.TempDir(), Logger: log})
+	cache := httptest.NewServer(NewRegistryCache(log, cacheManager))
+	defer cache.Close()
+	pull := func() error {
+		runner := NewManager(log, ClientConfig{
+			StoreRootPath:   t.TempDir(),
+			Logger:          log,
+			RegistryMirrors: registry.Mirrors{uri.Host: {cache.URL}},
+		})
+		if err := runner.distributionClient.PullModel(context.Background(), tag, io.Discard); err != nil {
+			return err
+		}
+		_, err := runner.GetLocal(tag)
+		return err
+	}
+
+	// The first pull goes through the cache, which keeps the model in its
+	// store and serves it to later pulls even if the registry is down.
+	if err := pull(); err != nil {
+		t.Fatalf("Failed to pull model through the cache: %v", err)
+	}
+	if _, err := cacheManager.GetLocal(tag); err != nil {
+		t.Fatalf("Expected the cache to keep the model: %v", err)
+	}
+	upstream.Close()
+	if err := pull(); err != nil {
+		t.Fatalf("Failed to pull cached model while the registry is down: %v", err)
+	}
+
+	for _, tt := range []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodGet, "/v2/", http.StatusOK},
+		{http.MethodGet, "/v2/ai/missing/manifests/latest?ns=" + uri.Host, http.StatusNotFound},
+		{http.MethodHead, "/v2/ai/model/blobs/sha256:0000000000000000000000000000000000000000000000000000000000000000", http.StatusNotFound},
+		{http.MethodPut, "/v2/ai/model/manifests/v1", http.StatusMethodNotAllowed},
+	} {
+		req, err := http.NewRequest(tt.method, cache.URL+tt.path, http.NoBody)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s %s: expected status code %d, got %d", tt.method, tt.path, tt.code, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference/chattemplate"
+)
+
+// ErrInvalidSettings indicates that model settings are invalid.
+var ErrInvalidSettings = errors.New("invalid model settings")
+
+// GetSettings returns the settings stored with a model, which are empty if
+// none are.
+func (m *Manager) GetSettings(ref string) (types.ModelSettings, error) {
+	if m.distributionClient == nil {
+		return types.ModelSettings{}, errors.New("model distribution service unavailable")
+	}
+	model, err := m.settingsModel(ref)
+	if err != nil {
+		return types.ModelSettings{}, err
+	}
+	id, err := model.ID()
+	if err != nil {
+		return types.ModelSettings{}, err
+	}
+	return m.distributionClient.GetModelSettings(id)
+}
+
+// SetSettings replaces the settings stored with a model and returns them, with
+// the format of their chat template. They take precedence over the model's
+// config and over the configuration of its runners, and apply the next time
+// the model is loaded. Empty settings remove them.
+func (m *Manager) SetSettings(ref string, settings types.ModelSettings) (types.ModelSettings, error) {
+	if m.distributionClient == nil {
+		return types.ModelSettings{}, errors.New("model distribution service unavailable")
+	}
+	if settings.ContextSize != nil && *settings.ContextSize == 0 {
+		return types.ModelSettings{}, fmt.Errorf("%w: context size must be positive", ErrInvalidSettings)
+	}
+	settings.ChatTemplateFormat = ""
+	if settings.ChatTemplate != "" {
+		format, err := chattemplate.Validate(settings.ChatTemplate)
+		if err != nil {
+			return types.ModelSettings{}, fmt.Errorf("%w: %w", ErrInvalidSettings, err)
+		}
+		settings.ChatTemplateFormat = format
+	}
+	model, err := m.settingsModel(ref)
+	if err != nil {
+		return types.ModelSettings{}, err
+	}
+	id, err := model.ID()
+	if err != nil {
+		return types.ModelSettings{}, err
+	}
+	if err := m.distributionClient.SetModelSettings(id, settings); err != nil {
+		return types.ModelSettings{}, err
+	}
+	return settings, nil
+}
+
+// settingsModel returns the model whose settings are referred to by ref.
+// Settings are stored with base models, so names that apply adapters refer to
+// the base model's settings.
+func (m *Manager) settingsModel(ref string) (types.Model, error) {
+	base, _ := SplitAdapters(ref)
+	return m.getLocal(base)
+}
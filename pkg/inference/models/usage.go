@@ -0,0 +1,42 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/docker/model-runner/pkg/distribution/distribution"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+// usageRecordInterval is the minimum interval between recording uses of the
+// same model, which bounds how often the store's usage file is written.
+const usageRecordInterval = time.Minute
+
+// MarkUsed records that a model is being used, such as by a backend that
+// loads it, so that models used less recently are evicted first when the
+// store quota is exceeded. Failures are logged but otherwise ignored.
+func (m *Manager) MarkUsed(ref string) {
+	if m == nil || m.distributionClient == nil {
+		return
+	}
+	m.usageLock.Lock()
+	if last, ok := m.usageRecorded[ref]; ok && time.Since(last) < usageRecordInterval {
+		m.usageLock.Unlock()
+		return
+	}
+	m.usageRecorded[ref] = time.Now()
+	m.usageLock.Unlock()
+
+	if err := m.distributionClient.MarkUsed(ref); err != nil && !errors.Is(err, distribution.ErrModelNotFound) {
+		m.log.Warnf("Failed to record use of model %s: %v", utils.SanitizeForLog(ref, -1), err)
+	}
+}
+
+// SetPinned pins or unpins a model. Pinned models are never evicted to stay
+// within the store quota.
+func (m *Manager) SetPinned(ref string, pinned bool) error {
+	if m.distributionClient == nil {
+		return errors.New("model distribution service unavailable")
+	}
+	return m.distributionClient.SetModelPinned(ref, pinned)
+}
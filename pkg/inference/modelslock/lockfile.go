@@ -0,0 +1,119 @@
+// Package modelslock implements the models.lock file, which declares the
+// models that a runner should have available and how they should be
+// configured.
+package modelslock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/scheduling"
+)
+
+// Entry declares a single model in a models.lock file.
+type Entry struct {
+	// Model is the model reference.
+	Model string `json:"model"`
+	// Digest optionally pins the model to a manifest digest.
+	Digest string `json:"digest,omitempty"`
+	// Backend is the backend used to run the model. The default backend is
+	// used if it's empty.
+	Backend string `json:"backend,omitempty"`
+	// ContextSize is the context size to configure, if any.
+	ContextSize *int64 `json:"context-size,omitempty"`
+	// RuntimeFlags are the runtime flags to configure, if any.
+	RuntimeFlags []string `json:"runtime-flags,omitempty"`
+	// Speculative is the speculative decoding configuration, if any.
+	Speculative *inference.SpeculativeDecodingConfig `json:"speculative,omitempty"`
+	// KVCacheType is the KV cache type to configure, if any.
+	KVCacheType inference.KVCacheType `json:"kv-cache-type,omitempty"`
+	// RopeScaling is the RoPE scaling configuration, if any.
+	RopeScaling *inference.RopeScalingConfig `json:"rope-scaling,omitempty"`
+	// Embeddings is the embedding configuration, if any.
+	Embeddings *inference.EmbeddingConfig `json:"embeddings,omitempty"`
+	// Replicas is the number of runners kept loaded for the model. If it's
+	// zero, the model is only loaded on demand.
+	Replicas int `json:"replicas,omitempty"`
+	// KeepAlive is the keep-alive to configure, if any.
+	KeepAlive *scheduling.KeepAlive `json:"keep-alive,omitempty"`
+}
+
+// Reference returns the reference to pull for the entry.
+func (e Entry) Reference() string {
+	if e.Digest == "" {
+		return e.Model
+	}
+	return e.Model + "@" + e.Digest
+}
+
+// configured returns true if the entry specifies a runner configuration.
+func (e Entry) configured() bool {
+	return e.Backend != "" || e.ContextSize != nil || len(e.RuntimeFlags) > 0 || e.Speculative != nil || e.KVCacheType != "" || e.RopeScaling != nil || e.Embeddings != nil || e.Replicas > 0 || e.KeepAlive != nil
+}
+
+// configureRequest returns the runner configuration for the entry.
+func (e Entry) configureRequest() scheduling.ConfigureRequest {
+	req := scheduling.ConfigureRequest{
+		Model:        e.Model,
+		ContextSize:  -1,
+		RuntimeFlags: e.RuntimeFlags,
+		Speculative:  e.Speculative,
+		KVCacheType:  e.KVCacheType,
+		RopeScaling:  e.RopeScaling,
+		Embeddings:   e.Embeddings,
+		KeepAlive:    e.KeepAlive,
+	}
+	if e.ContextSize != nil {
+		req.ContextSize = *e.ContextSize
+	}
+	if e.Replicas > 0 {
+		req.Replicas = &inference.ReplicaConfig{Count: e.Replicas}
+	}
+	return req
+}
+
+// File is the content of a models.lock file.
+type File struct {
+	// Models are the declared models.
+	Models []Entry `json:"models"`
+}
+
+// Parse parses and validates the content of a models.lock file.
+func Parse(data []byte) (*File, error) {
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid models.lock: %w", err)
+	}
+	seen := make(map[string]bool, len(file.Models))
+	for i, entry := range file.Models {
+		if entry.Model == "" {
+			return nil, fmt.Errorf("models.lock entry %d: model is required", i)
+		}
+		if strings.Contains(entry.Model, "@") {
+			return nil, fmt.Errorf("models.lock entry %d: model %q must not contain a digest, use the digest field instead", i, entry.Model)
+		}
+		if entry.Digest != "" && !strings.HasPrefix(entry.Digest, "sha256:") {
+			return nil, fmt.Errorf("models.lock entry %d: invalid digest %q", i, entry.Digest)
+		}
+		if entry.Replicas < 0 {
+			return nil, fmt.Errorf("models.lock entry %d: invalid replica count %d", i, entry.Replicas)
+		}
+		if seen[entry.Model] {
+			return nil, fmt.Errorf("models.lock entry %d: duplicate model %q", i, entry.Model)
+		}
+		seen[entry.Model] = true
+	}
+	return &file, nil
+}
+
+// Load reads and parses a models.lock file.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
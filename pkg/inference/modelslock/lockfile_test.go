@@ -0,0 +1,107 @@
+package modelslock
+
+import (
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference/scheduling"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		models  int
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			data:   `{"models": [{"model": "ai/smollm2", "digest": "sha256:abc", "context-size": 4096}, {"model": "ai/gemma3", "backend": "llama.cpp"}]}`,
+			models: 2,
+		},
+		{
+			name:   "loaded",
+			data:   `{"models": [{"model": "ai/smollm2", "replicas": 2, "keep-alive": "-1"}]}`,
+			models: 1,
+		},
+		{
+			name: "empty",
+			data: `{}`,
+		},
+		{
+			name:    "invalid json",
+			data:    `{"models": [`,
+			wantErr: true,
+		},
+		{
+			name:    "missing model",
+			data:    `{"models": [{"digest": "sha256:abc"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "digest in model",
+			data:    `{"models": [{"model": "ai/smollm2@sha256:abc"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid digest",
+			data:    `{"models": [{"model": "ai/smollm2", "digest": "abc"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative replicas",
+			data:    `{"models": [{"model": "ai/smollm2", "replicas": -1}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid keep-alive",
+			data:    `{"models": [{"model": "ai/smollm2", "keep-alive": "soon"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate model",
+			data:    `{"models": [{"model": "ai/smollm2"}, {"model": "ai/smollm2"}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(file.Models) != tt.models {
+				t.Errorf("expected %d models, got %d", tt.models, len(file.Models))
+			}
+		})
+	}
+}
+
+func TestEntryConfigureRequest(t *testing.T) {
+	contextSize := int64(4096)
+	entry := Entry{Model: "ai/smollm2", Digest: "sha256:abc", ContextSize: &contextSize}
+	if ref := entry.Reference(); ref != "ai/smollm2@sha256:abc" {
+		t.Errorf("unexpected reference %q", ref)
+	}
+	if req := entry.configureRequest(); req.ContextSize != 4096 || req.Model != "ai/smollm2" {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if req := (Entry{Model: "ai/smollm2"}).configureRequest(); req.ContextSize != -1 || req.Replicas != nil || req.KeepAlive != nil {
+		t.Errorf("expected unset configuration, got %+v", req)
+	}
+
+	keepAlive := scheduling.KeepAliveForever
+	entry = Entry{Model: "ai/smollm2", Replicas: 2, KeepAlive: &keepAlive}
+	if !entry.configured() {
+		t.Error("expected replicas and keep-alive to be configured")
+	}
+	if req := entry.configureRequest(); req.Replicas == nil || req.Replicas.Count != 2 || req.KeepAlive == nil || *req.KeepAlive != keepAlive {
+		t.Errorf("unexpected request %+v", req)
+	}
+}
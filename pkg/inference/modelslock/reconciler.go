@@ -0,0 +1,216 @@
+package modelslock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/inference/scheduling"
+	"github.com/docker/model-runner/pkg/internal/utils"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// userAgent identifies configuration requests made by the reconciler.
+const userAgent = "models.lock"
+
+// appliedConfig is a runner configuration applied by the reconciler.
+type appliedConfig struct {
+	backend string
+	request scheduling.ConfigureRequest
+}
+
+// Reconciler continuously reconciles the local model store and runner
+// configurations with a models.lock file: declared models are pulled if
+// missing, models that aren't declared are removed, declared configurations
+// are applied, and declared replicas are kept loaded.
+type Reconciler struct {
+	// log is the associated logger.
+	log logging.Logger
+	// path is the path to the models.lock file.
+	path string
+	// interval is the interval at which the file is reconciled.
+	interval time.Duration
+	// modelManager is the shared model manager.
+	modelManager *models.Manager
+	// scheduler is the scheduler used to configure runners.
+	scheduler *scheduling.Scheduler
+	// applied tracks the configuration applied for each model, so that
+	// unchanged configurations aren't re-applied on every pass.
+	applied map[string]appliedConfig
+}
+
+// NewReconciler creates a new reconciler for the models.lock file at path.
+func NewReconciler(log logging.Logger, path string, interval time.Duration, modelManager *models.Manager, scheduler *scheduling.Scheduler) *Reconciler {
+	return &Reconciler{
+		log:          log,
+		path:         path,
+		interval:     interval,
+		modelManager: modelManager,
+		scheduler:    scheduler,
+		applied:      make(map[string]appliedConfig),
+	}
+}
+
+// Run reconciles the models.lock file until ctx is cancelled.
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Reconcile(ctx); err != nil && ctx.Err() == nil {
+			r.log.Warnf("Failed to reconcile %s: %v", r.path, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile performs a single reconciliation pass. Configuration and load
+// failures are logged and retried on the next pass.
+func (r *Reconciler) Reconcile(ctx context.Context) error {
+	file, err := Load(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Without a file there's no desired state to enforce.
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Make sure that declared models are present, failing the pass (and
+	// thereby skipping removals) if any can't be resolved, since removing
+	// models based on a partial view could delete the wrong ones.
+	desired := make(map[string]bool, len(file.Models))
+	for _, entry := range file.Models {
+		id, err := r.ensureModel(ctx, entry)
+		if err != nil {
+			return fmt.Errorf("unable to pull %s: %w", utils.SanitizeForLog(entry.Reference(), -1), err)
+		}
+		desired[id] = true
+		r.configure(ctx, entry)
+	}
+
+	// Remove models that aren't declared.
+	local, err := r.modelManager.RawList()
+	if err != nil {
+		return err
+	}
+	for _, model := range local {
+		id, err := model.ID()
+		if err != nil {
+			r.log.Warnf("Failed to get model ID: %v", err)
+			continue
+		}
+		if desired[id] {
+			continue
+		}
+		r.log.Infof("Removing model %s %v not declared in %s", id, model.Tags(), r.path)
+		if _, err := r.modelManager.Delete(id, true); err != nil {
+			r.log.Warnf("Failed to remove model %s: %v", id, err)
+		}
+	}
+
+	// Forget configurations of models that are no longer declared, so that
+	// they're applied again if the models are re-added.
+	for name := range r.applied {
+		if !slices.ContainsFunc(file.Models, func(entry Entry) bool { return entry.Model == name }) {
+			delete(r.applied, name)
+		}
+	}
+
+	// Load declared replicas once the store is reconciled, since loads may
+	// take a while.
+	for _, entry := range file.Models {
+		if entry.Replicas > 0 {
+			r.load(ctx, entry)
+		}
+	}
+	return nil
+}
+
+// ensureModel makes sure that the model declared by entry is present locally,
+// with its reference pointing at the pinned digest if any, and returns its ID.
+func (r *Reconciler) ensureModel(ctx context.Context, entry Entry) (string, error) {
+	if model, err := r.modelManager.GetLocal(entry.Model); err == nil {
+		id, err := model.ID()
+		if err != nil {
+			return "", err
+		}
+		if entry.Digest == "" || id == entry.Digest {
+			return id, nil
+		}
+	}
+
+	r.log.Infof("Pulling %s declared in %s", utils.SanitizeForLog(entry.Reference(), -1), r.path)
+	if err := r.modelManager.EnsureLocal(ctx, entry.Reference()); err != nil {
+		return "", err
+	}
+	model, err := r.modelManager.GetLocal(entry.Reference())
+	if err != nil {
+		return "", err
+	}
+	id, err := model.ID()
+	if err != nil {
+		return "", err
+	}
+	if entry.Digest != "" {
+		// Point the model's tag at the pinned version, so that requests
+		// using it are served by that version.
+		if err := r.modelManager.Tag(id, entry.Model); err != nil {
+			return "", fmt.Errorf("tagging pinned model: %w", err)
+		}
+	}
+	return id, nil
+}
+
+// configure applies the runner configuration declared by entry, unless it was
+// already applied.
+func (r *Reconciler) configure(ctx context.Context, entry Entry) {
+	if !entry.configured() {
+		delete(r.applied, entry.Model)
+		return
+	}
+	config := appliedConfig{backend: entry.Backend, request: entry.configureRequest()}
+	if applied, ok := r.applied[entry.Model]; ok && reflect.DeepEqual(applied, config) {
+		return
+	}
+
+	// Without a backend, one is selected for the model.
+	var backend inference.Backend
+	if entry.Backend != "" {
+		var err error
+		if backend, err = r.scheduler.LookupBackend(entry.Backend); err != nil {
+			r.log.Warnf("Unable to configure %s: %v", utils.SanitizeForLog(entry.Model, -1), err)
+			return
+		}
+	}
+	if _, err := r.scheduler.ConfigureRunner(ctx, backend, config.request, userAgent); err != nil {
+		// The runner may be in use, in which case this is retried on the
+		// next pass.
+		r.log.Warnf("Unable to configure %s: %v", utils.SanitizeForLog(entry.Model, -1), err)
+		return
+	}
+	r.applied[entry.Model] = config
+}
+
+// load keeps the replicas declared by entry loaded.
+func (r *Reconciler) load(ctx context.Context, entry Entry) {
+	var backend inference.Backend
+	if entry.Backend != "" {
+		var err error
+		if backend, err = r.scheduler.LookupBackend(entry.Backend); err != nil {
+			r.log.Warnf("Unable to load %s: %v", utils.SanitizeForLog(entry.Model, -1), err)
+			return
+		}
+	}
+	if err := r.scheduler.LoadReplicas(ctx, entry.Model, backend, entry.Replicas); err != nil && ctx.Err() == nil {
+		r.log.Warnf("Unable to load %s: %v", utils.SanitizeForLog(entry.Model, -1), err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package offline provides access to offline bundles, which package the
+// backend runtimes and models that the model runner installs for use in
+// air-gapped environments. A bundle is a directory laid out as follows:
+//
+//	llama.cpp/com.docker.llama-server.native.<os>.<variant>.<arch>/{bin,lib}
+//	sglang/version
+//	sglang/wheels/*.whl
+//	models/*.tar
+//
+// The llama.cpp directories have the layout of the llama.cpp backend images,
+// the SGLang wheels include those of all of SGLang's dependencies, and the
+// model archives are those exported by the model runner.
+package offline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Bundle is an offline bundle. Its methods may be called on a nil bundle,
+// which provides nothing.
+type Bundle struct {
+	// dir is the root directory of the bundle.
+	dir string
+}
+
+// Open opens the offline bundle at dir.
+func Open(dir string) (*Bundle, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("opening offline bundle: %w", err)
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("offline bundle %s is not a directory", dir)
+	}
+	return &Bundle{dir: dir}, nil
+}
+
+// Dir returns the root directory of the bundle.
+func (b *Bundle) Dir() string {
+	if b == nil {
+		return ""
+	}
+	return b.dir
+}
+
+// LlamaCppDir returns the directory of the llama.cpp variant for an OS and
+// architecture, which holds its bin and lib directories, if the bundle
+// provides it.
+func (b *Bundle) LlamaCppDir(goos, variant, goarch string) (string, bool) {
+	if b == nil {
+		return "", false
+	}
+	dir := filepath.Join(b.dir, "llama.cpp",
+		fmt.Sprintf("com.docker.llama-server.native.%s.%s.%s", goos, variant, goarch))
+	if fi, err := os.Stat(filepath.Join(dir, "bin")); err != nil || !fi.IsDir() {
+		return "", false
+	}
+	return dir, true
+}
+
+// SGLangWheels returns the directory of the wheels that SGLang is installed
+// from, and the SGLang version that they provide, if the bundle provides
+// them.
+func (b *Bundle) SGLangWheels() (string, string, bool) {
+	if b == nil {
+		return "", "", false
+	}
+	dir := filepath.Join(b.dir, "sglang", "wheels")
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return "", "", false
+	}
+	version, err := os.ReadFile(filepath.Join(b.dir, "sglang", "version"))
+	if err != nil {
+		return "", "", false
+	}
+	return dir, strings.TrimSpace(string(version)), true
+}
+
+// ModelArchives returns the paths of the model archives in the bundle, in
+// lexical order.
+func (b *Bundle) ModelArchives() ([]string, error) {
+	if b == nil {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(b.dir, "models", "*.tar"))
+	if err != nil {
+		return nil, fmt.Errorf("listing offline bundle models: %w", err)
+	}
+	return paths, nil
+}
+
+// CopyDir copies the directory tree at src to dst, which must not exist,
+// preserving the permissions of files and symbolic links. Bundles may be
+// mounted read-only, so their contents are copied rather than moved into
+// place.
+func CopyDir(dst, src string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(target, path, info.Mode().Perm())
+		default:
+			return fmt.Errorf("unsupported file type: %s", path)
+		}
+	})
+}
+
+// copyFile copies the regular file at src to dst, with the given permissions.
+func copyFile(dst, src string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return errors.Join(out.Close(), os.Chmod(dst, perm))
+}
@@ -0,0 +1,96 @@
+package offline
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string, perm os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+	llamaCpp := filepath.Join(dir, "llama.cpp", "com.docker.llama-server.native.linux.cuda.amd64")
+	writeFile(t, filepath.Join(llamaCpp, "bin", "com.docker.llama-server"), "llama-server", 0o755)
+	writeFile(t, filepath.Join(dir, "sglang", "version"), "0.5.2\n", 0o644)
+	writeFile(t, filepath.Join(dir, "sglang", "wheels", "sglang-0.5.2-py3-none-any.whl"), "", 0o644)
+	writeFile(t, filepath.Join(dir, "models", "b.tar"), "", 0o644)
+	writeFile(t, filepath.Join(dir, "models", "a.tar"), "", 0o644)
+	writeFile(t, filepath.Join(dir, "models", "README"), "", 0o644)
+
+	bundle, err := Open(dir)
+	if err != nil {
+		t.Fatalf("failed to open bundle: %v", err)
+	}
+	if got, ok := bundle.LlamaCppDir("linux", "cuda", "amd64"); !ok || got != llamaCpp {
+		t.Errorf("expected llama.cpp directory %s, got %s (%v)", llamaCpp, got, ok)
+	}
+	if _, ok := bundle.LlamaCppDir("linux", "cpu", "amd64"); ok {
+		t.Error("expected no llama.cpp directory for a missing variant")
+	}
+	if wheels, version, ok := bundle.SGLangWheels(); !ok || wheels != filepath.Join(dir, "sglang", "wheels") || version != "0.5.2" {
+		t.Errorf("unexpected SGLang wheels %s, version %q (%v)", wheels, version, ok)
+	}
+	archives, err := bundle.ModelArchives()
+	if err != nil {
+		t.Fatalf("failed to list model archives: %v", err)
+	}
+	expected := []string{filepath.Join(dir, "models", "a.tar"), filepath.Join(dir, "models", "b.tar")}
+	if !slices.Equal(archives, expected) {
+		t.Errorf("expected model archives %v, got %v", expected, archives)
+	}
+
+	var missing *Bundle
+	if _, ok := missing.LlamaCppDir("linux", "cuda", "amd64"); ok {
+		t.Error("expected a nil bundle to provide no llama.cpp")
+	}
+	if _, _, ok := missing.SGLangWheels(); ok {
+		t.Error("expected a nil bundle to provide no SGLang wheels")
+	}
+	if archives, err := missing.ModelArchives(); err != nil || len(archives) != 0 {
+		t.Errorf("expected a nil bundle to provide no models, got %v (%v)", archives, err)
+	}
+	if _, err := Open(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error opening a missing bundle")
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links require privileges on Windows")
+	}
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "bin", "server"), "server", 0o755)
+	writeFile(t, filepath.Join(src, "lib", "libggml.so.1"), "lib", 0o644)
+	if err := os.Symlink("libggml.so.1", filepath.Join(src, "lib", "libggml.so")); err != nil {
+		t.Fatalf("failed to create symbolic link: %v", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	if err := CopyDir(dst, src); err != nil {
+		t.Fatalf("failed to copy directory: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(dst, "bin", "server"))
+	if err != nil {
+		t.Fatalf("expected the server to be copied: %v", err)
+	}
+	if fi.Mode().Perm() != 0o755 {
+		t.Errorf("expected the server to stay executable, got %v", fi.Mode())
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "lib", "libggml.so")); err != nil || link != "libggml.so.1" {
+		t.Errorf("expected the symbolic link to be copied, got %q (%v)", link, err)
+	}
+	if err := CopyDir(dst, src); err == nil {
+		t.Error("expected an error copying over existing files")
+	}
+}
@@ -0,0 +1,54 @@
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// GPUVendor identifies the vendor of a GPU.
+type GPUVendor string
+
+const (
+	// GPUVendorNVIDIA identifies NVIDIA GPUs.
+	GPUVendorNVIDIA GPUVendor = "nvidia"
+	// GPUVendorAMD identifies AMD GPUs.
+	GPUVendorAMD GPUVendor = "amd"
+	// GPUVendorIntel identifies Intel GPUs.
+	GPUVendorIntel GPUVendor = "intel"
+)
+
+// pciVendors maps PCI vendor IDs to GPU vendors.
+var pciVendors = map[string]GPUVendor{
+	"0x10de": GPUVendorNVIDIA,
+	"0x1002": GPUVendorAMD,
+	"0x8086": GPUVendorIntel,
+}
+
+// drmPath is the sysfs directory listing DRM devices. It can be overridden for
+// testing.
+var drmPath = "/sys/class/drm"
+
+// GPUVendors returns the vendors of the GPUs exposed through DRM, in a stable
+// order and without duplicates. It returns nil if no GPUs are found or if DRM
+// devices can't be enumerated, which is always the case outside of Linux.
+func GPUVendors() []GPUVendor {
+	devices, err := filepath.Glob(filepath.Join(drmPath, "card[0-9]*", "device", "vendor"))
+	if err != nil {
+		return nil
+	}
+	var vendors []GPUVendor
+	for _, device := range devices {
+		id, err := os.ReadFile(device)
+		if err != nil {
+			continue
+		}
+		vendor, ok := pciVendors[strings.ToLower(strings.TrimSpace(string(id)))]
+		if ok && !slices.Contains(vendors, vendor) {
+			vendors = append(vendors, vendor)
+		}
+	}
+	slices.Sort(vendors)
+	return vendors
+}
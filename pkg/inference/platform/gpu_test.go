@@ -0,0 +1,67 @@
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGPUVendors(t *testing.T) {
+	tests := []struct {
+		name     string
+		devices  map[string]string
+		expected []GPUVendor
+	}{
+		{
+			name: "no GPU",
+		},
+		{
+			name: "discrete AMD GPU with Intel iGPU",
+			devices: map[string]string{
+				"card0": "0x8086\n",
+				"card1": "0x1002\n",
+			},
+			expected: []GPUVendor{GPUVendorAMD, GPUVendorIntel},
+		},
+		{
+			name: "multiple NVIDIA GPUs",
+			devices: map[string]string{
+				"card0": "0x10de\n",
+				"card1": "0x10DE\n",
+			},
+			expected: []GPUVendor{GPUVendorNVIDIA},
+		},
+		{
+			name: "unknown vendor",
+			devices: map[string]string{
+				"card0": "0x1234\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for card, vendor := range tt.devices {
+				path := filepath.Join(dir, card, "device")
+				if err := os.MkdirAll(path, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(path, "vendor"), []byte(vendor), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Connectors share the card prefix but have no vendor.
+			if err := os.MkdirAll(filepath.Join(dir, "card0-HDMI-A-1"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			drmPath = dir
+			defer func() { drmPath = "/sys/class/drm" }()
+			if got := GPUVendors(); !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
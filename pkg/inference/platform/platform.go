@@ -12,3 +12,21 @@ func SupportsVLLM() bool {
 func SupportsMLX() bool {
 	return runtime.GOOS == "darwin" && runtime.GOARCH == "arm64"
 }
+
+// SupportsSGLang returns true if SGLang is supported on the current platform.
+func SupportsSGLang() bool {
+	return runtime.GOOS == "linux"
+}
+
+// SupportsONNX returns true if the ONNX backend is supported on the current
+// platform. Its server listens on a Unix domain socket, which Python doesn't
+// support on Windows.
+func SupportsONNX() bool {
+	return runtime.GOOS == "linux" || runtime.GOOS == "darwin"
+}
+
+// SupportsTensorRT returns true if TensorRT-LLM is supported on the current
+// platform.
+func SupportsTensorRT() bool {
+	return runtime.GOOS == "linux"
+}
@@ -0,0 +1,90 @@
+// Package runnerconfig implements the runner configuration file, which holds
+// settings that can be reloaded without restarting the model runner.
+package runnerconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/scheduling"
+)
+
+// Model configures the runners of a single model.
+type Model struct {
+	// Backend is the backend used to run the model. If empty, one is
+	// selected for the model.
+	Backend string `json:"backend,omitempty"`
+	// ConfigureRequest is the runner configuration of the model.
+	scheduling.ConfigureRequest
+}
+
+// UnmarshalJSON implements json.Unmarshaler.UnmarshalJSON. It leaves the
+// context size unset unless one is specified.
+func (m *Model) UnmarshalJSON(data []byte) error {
+	type model Model
+	decoded := model{ConfigureRequest: scheduling.ConfigureRequest{ContextSize: -1}}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = Model(decoded)
+	return nil
+}
+
+// Config is the content of a runner configuration file. Settings that are
+// omitted revert to their defaults.
+type Config struct {
+	// RAMBudgetMB limits the RAM that models can be loaded into, in MB.
+	RAMBudgetMB uint64 `json:"ram-budget-mb,omitempty"`
+	// VRAMBudgetMB limits the VRAM that models can be loaded into, in MB.
+	VRAMBudgetMB uint64 `json:"vram-budget-mb,omitempty"`
+	// KeepAlive is how long models without their own keep-alive stay loaded
+	// after their last request.
+	KeepAlive *scheduling.KeepAlive `json:"keep-alive,omitempty"`
+	// AllowedModels are the models that can be used for inference. If empty,
+	// all models are allowed.
+	AllowedModels []string `json:"allowed-models,omitempty"`
+	// Models are the runner configurations of individual models.
+	Models []Model `json:"models,omitempty"`
+}
+
+// budget returns the memory budget set by the configuration, overriding the
+// specified default budget.
+func (c *Config) budget(defaults inference.RequiredMemory) inference.RequiredMemory {
+	if c.RAMBudgetMB > 0 {
+		defaults.RAM = c.RAMBudgetMB * 1024 * 1024
+	}
+	if c.VRAMBudgetMB > 0 {
+		defaults.VRAM = c.VRAMBudgetMB * 1024 * 1024
+	}
+	return defaults
+}
+
+// Parse parses and validates the content of a runner configuration file.
+func Parse(data []byte) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid runner configuration: %w", err)
+	}
+	seen := make(map[string]bool, len(config.Models))
+	for i, model := range config.Models {
+		if model.Model == "" {
+			return nil, fmt.Errorf("runner configuration model %d: model is required", i)
+		}
+		if seen[model.Model] {
+			return nil, fmt.Errorf("runner configuration model %d: duplicate model %q", i, model.Model)
+		}
+		seen[model.Model] = true
+	}
+	return &config, nil
+}
+
+// Load reads and parses a runner configuration file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
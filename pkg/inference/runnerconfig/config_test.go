@@ -0,0 +1,91 @@
+package runnerconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/scheduling"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		models  int
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			data:   `{"ram-budget-mb": 8192, "keep-alive": "10m", "allowed-models": ["ai/smollm2"], "models": [{"model": "ai/smollm2", "context-size": 4096}, {"model": "ai/gemma3", "backend": "llama.cpp"}]}`,
+			models: 2,
+		},
+		{
+			name: "empty",
+			data: `{}`,
+		},
+		{
+			name:    "invalid json",
+			data:    `{"models": [`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid keep-alive",
+			data:    `{"keep-alive": "soon"}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing model",
+			data:    `{"models": [{"context-size": 4096}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate model",
+			data:    `{"models": [{"model": "ai/smollm2"}, {"model": "ai/smollm2"}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := Parse([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(config.Models) != tt.models {
+				t.Errorf("expected %d models, got %d", tt.models, len(config.Models))
+			}
+		})
+	}
+}
+
+func TestParseModel(t *testing.T) {
+	config, err := Parse([]byte(`{"keep-alive": "10m", "models": [{"model": "ai/smollm2", "backend": "vllm"}, {"model": "ai/gemma3", "context-size": 4096}]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *config.KeepAlive != scheduling.KeepAlive(10*time.Minute) {
+		t.Errorf("unexpected keep-alive %s", *config.KeepAlive)
+	}
+	if model := config.Models[0]; model.Backend != "vllm" || model.Model != "ai/smollm2" || model.ContextSize != -1 {
+		t.Errorf("unexpected model %+v", model)
+	}
+	if model := config.Models[1]; model.ContextSize != 4096 {
+		t.Errorf("expected context size 4096, got %d", model.ContextSize)
+	}
+}
+
+func TestConfigBudget(t *testing.T) {
+	defaults := inference.RequiredMemory{RAM: 1024 * 1024 * 1024, VRAM: 512 * 1024 * 1024}
+	config := Config{VRAMBudgetMB: 2048}
+	want := inference.RequiredMemory{RAM: 1024 * 1024 * 1024, VRAM: 2048 * 1024 * 1024}
+	if got := config.budget(defaults); got != want {
+		t.Errorf("budget() = %+v, want %+v", got, want)
+	}
+}
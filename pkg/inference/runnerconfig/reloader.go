@@ -0,0 +1,172 @@
+package runnerconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/memory"
+	"github.com/docker/model-runner/pkg/inference/scheduling"
+	"github.com/docker/model-runner/pkg/internal/utils"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// userAgent identifies configuration requests made by the reloader.
+const userAgent = "runner-config"
+
+// Reloader applies a runner configuration file to the scheduler, applying only
+// the settings that changed since the file was last applied, so that models
+// whose settings didn't change keep running.
+type Reloader struct {
+	// log is the associated logger.
+	log logging.Logger
+	// path is the path to the runner configuration file.
+	path string
+	// scheduler is the scheduler that the configuration is applied to.
+	scheduler *scheduling.Scheduler
+	// sysMemInfo describes the system's memory, without any budget applied.
+	sysMemInfo memory.SystemMemoryInfo
+	// defaultBudget is the memory budget used if the file doesn't set one.
+	defaultBudget inference.RequiredMemory
+	// lock serializes reloads and guards all subsequent fields.
+	lock sync.Mutex
+	// totalMemory is the total memory applied to the scheduler.
+	totalMemory inference.RequiredMemory
+	// defaultKeepAlive is the keep-alive used if the file doesn't set one.
+	// It's captured on the first reload.
+	defaultKeepAlive *scheduling.KeepAlive
+	// keepAlive is the keep-alive applied to the scheduler.
+	keepAlive scheduling.KeepAlive
+	// allowedModels are the allowed models applied to the scheduler.
+	allowedModels []string
+	// applied maps model references to the configurations applied for them.
+	applied map[string]Model
+}
+
+// NewReloader creates a new reloader for the runner configuration file at
+// path. The default budget is the memory budget that the scheduler was
+// created with.
+func NewReloader(log logging.Logger, path string, scheduler *scheduling.Scheduler, sysMemInfo memory.SystemMemoryInfo, defaultBudget inference.RequiredMemory) *Reloader {
+	return &Reloader{
+		log:           log,
+		path:          path,
+		scheduler:     scheduler,
+		sysMemInfo:    sysMemInfo,
+		defaultBudget: defaultBudget,
+		totalMemory:   memory.WithBudget(sysMemInfo, defaultBudget).GetTotalMemory(),
+		applied:       make(map[string]Model),
+	}
+}
+
+// Reload reads the runner configuration file and applies the settings that
+// changed. A missing file reverts all settings to their defaults. Models whose
+// configurations can't be applied, e.g. because their runners are in use, are
+// retried on the next reload.
+func (r *Reloader) Reload(ctx context.Context) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	config, err := Load(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		config = &Config{}
+	} else if err != nil {
+		return err
+	}
+
+	if r.defaultKeepAlive == nil {
+		keepAlive, err := r.scheduler.DefaultKeepAlive(ctx)
+		if err != nil {
+			return err
+		}
+		r.defaultKeepAlive, r.keepAlive = &keepAlive, keepAlive
+	}
+
+	totalMemory := memory.WithBudget(r.sysMemInfo, config.budget(r.defaultBudget)).GetTotalMemory()
+	if totalMemory != r.totalMemory {
+		if err := r.scheduler.SetTotalMemory(ctx, totalMemory); err != nil {
+			return err
+		}
+		r.totalMemory = totalMemory
+	}
+
+	keepAlive := *r.defaultKeepAlive
+	if config.KeepAlive != nil {
+		keepAlive = *config.KeepAlive
+	}
+	if keepAlive != r.keepAlive {
+		if err := r.scheduler.SetKeepAlive(ctx, "", keepAlive); err != nil {
+			return err
+		}
+		r.keepAlive = keepAlive
+	}
+
+	if !slices.Equal(config.AllowedModels, r.allowedModels) {
+		r.scheduler.SetAllowedModels(config.AllowedModels)
+		r.allowedModels = config.AllowedModels
+	}
+
+	var errs []error
+	for _, model := range config.Models {
+		if err := r.configure(ctx, model); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Restore the default configurations of models that are no longer
+	// configured.
+	for name, applied := range r.applied {
+		if slices.ContainsFunc(config.Models, func(model Model) bool { return model.Model == name }) {
+			continue
+		}
+		reset := Model{
+			Backend:          applied.Backend,
+			ConfigureRequest: scheduling.ConfigureRequest{Model: name, ContextSize: -1},
+		}
+		if err := r.configure(ctx, reset); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(r.applied, name)
+	}
+	return errors.Join(errs...)
+}
+
+// configure applies the runner configuration of a model, unless it was
+// already applied.
+func (r *Reloader) configure(ctx context.Context, model Model) error {
+	if applied, ok := r.applied[model.Model]; ok && reflect.DeepEqual(applied, model) {
+		return nil
+	}
+
+	// Without a backend, one is selected for the model.
+	var backend inference.Backend
+	if model.Backend != "" {
+		var err error
+		if backend, err = r.scheduler.LookupBackend(model.Backend); err != nil {
+			return fmt.Errorf("unable to configure %s: %w", utils.SanitizeForLog(model.Model, -1), err)
+		}
+	}
+	if _, err := r.scheduler.ConfigureRunner(ctx, backend, model.ConfigureRequest, userAgent); err != nil {
+		return fmt.Errorf("unable to configure %s: %w", utils.SanitizeForLog(model.Model, -1), err)
+	}
+	r.applied[model.Model] = model
+	return nil
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP. It reloads the runner
+// configuration file.
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.log.Infof("Reloading %s", r.path)
+	if err := r.Reload(req.Context()); err != nil {
+		r.log.Warnf("Failed to reload %s: %v", r.path, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,36 @@
+package scheduling
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// abortTimeout is the maximum time that a runner waits for its backend to
+// abort the generation of a request.
+const abortTimeout = 5 * time.Second
+
+// newRequestID returns a new random ID identifying an inference request to
+// the backend serving it.
+func newRequestID() string {
+	id := make([]byte, 12)
+	rand.Read(id)
+	return "req-" + hex.EncodeToString(id)
+}
+
+// abort aborts the generation of a request that was cut off, if the runner's
+// backend doesn't stop generating as soon as the request's connection closes.
+func (r *runner) abort(requestID string) {
+	aborter, ok := r.backend.(inference.RequestAborter)
+	if !ok || requestID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer cancel()
+	if err := aborter.AbortRequest(ctx, r.client, requestID); err != nil {
+		r.log.Warnf("Failed to abort request %s: %v", requestID, err)
+	}
+}
@@ -0,0 +1,35 @@
+package scheduling
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// abortingBackend is a backend that records the requests it's asked to abort.
+type abortingBackend struct {
+	mockBackend
+	aborted []string
+}
+
+func (b *abortingBackend) AbortRequest(_ context.Context, _ *http.Client, requestID string) error {
+	b.aborted = append(b.aborted, requestID)
+	return nil
+}
+
+func TestRunnerAbort(t *testing.T) {
+	backend := &abortingBackend{mockBackend: mockBackend{name: "test-backend"}}
+	r := &runner{log: createTestLogger(), backend: backend, client: http.DefaultClient}
+	id := newRequestID()
+	if id == newRequestID() {
+		t.Error("Expected request IDs to be unique")
+	}
+	r.abort(id)
+	r.abort("")
+	if len(backend.aborted) != 1 || backend.aborted[0] != id {
+		t.Errorf("Expected request %s to be aborted, got %v", id, backend.aborted)
+	}
+
+	// Backends that stop generating when the connection closes aren't asked.
+	(&runner{log: createTestLogger(), backend: &mockBackend{name: "test-backend"}}).abort(id)
+}
@@ -0,0 +1,228 @@
+package scheduling
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+// maximumRunnerOutput is the amount of recent error output kept for each
+// runner's backend process.
+const maximumRunnerOutput = 64 * 1024
+
+// ErrInstanceNotFound indicates that an unknown instance was requested. If
+// returned in conjunction with an HTTP request, it should be paired with a
+// 404 response status.
+var ErrInstanceNotFound = errors.New("instance not found")
+
+// outputTail keeps the most recent output written to it, up to its capacity.
+// Older output is discarded a line at a time where possible.
+type outputTail struct {
+	// lock guards data.
+	lock sync.Mutex
+	// capacity is the maximum size of the output kept.
+	capacity int
+	// data is the output kept.
+	data []byte
+}
+
+// newOutputTail creates a new output tail with the specified capacity.
+func newOutputTail(capacity int) *outputTail {
+	return &outputTail{capacity: capacity}
+}
+
+// Write implements io.Writer.Write.
+func (t *outputTail) Write(p []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.data = append(t.data, p...)
+	if excess := len(t.data) - t.capacity; excess > 0 {
+		kept := t.data[excess:]
+		if i := bytes.IndexByte(kept, '\n'); i >= 0 && i < len(kept)-1 {
+			kept = kept[i+1:]
+		}
+		t.data = append(t.data[:0], kept...)
+	}
+	return len(p), nil
+}
+
+// Bytes returns a copy of the output kept.
+func (t *outputTail) Bytes() []byte {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return bytes.Clone(t.data)
+}
+
+// instance describes the runner with the specified key. The caller must hold
+// the loader lock.
+func (l *loader) instance(key runnerKey, info runnerInfo) Instance {
+	r := l.slots[info.slot]
+	instance := Instance{
+		ID:          info.slot,
+		BackendName: key.backend,
+		ModelName:   info.modelRef,
+		ModelID:     key.modelID,
+		Mode:        key.mode.String(),
+		Replica:     key.replica,
+		Devices:     l.deviceAssignments[info.slot],
+		Requests:    int(l.references[info.slot]),
+		Running:     true,
+	}
+	if l.references[info.slot] == 0 {
+		instance.LastUsed = l.timestamps[info.slot]
+	}
+	select {
+	case <-r.done:
+		instance.Running = false
+	default:
+	}
+	return instance
+}
+
+// lookupInstance returns the key and information of the runner in the slot
+// with the specified ID. The caller must hold the loader lock.
+func (l *loader) lookupInstance(id int) (runnerKey, runnerInfo, bool) {
+	for key, info := range l.runners {
+		if info.slot == id && l.slots[id] != nil {
+			return key, info, true
+		}
+	}
+	return runnerKey{}, runnerInfo{}, false
+}
+
+// runnerInstance describes the specified runner, if it's still loaded.
+func (l *loader) runnerInstance(ctx context.Context, r *runner) (Instance, error) {
+	if !l.lock(ctx) {
+		return Instance{}, context.Canceled
+	}
+	defer l.unlock()
+	for key, info := range l.runners {
+		if l.slots[info.slot] == r {
+			return l.instance(key, info), nil
+		}
+	}
+	return Instance{}, ErrInstanceNotFound
+}
+
+// Instances returns the backend processes of the loaded runners, ordered by
+// ID.
+func (s *Scheduler) Instances(ctx context.Context) []Instance {
+	if !s.loader.lock(ctx) {
+		return []Instance{}
+	}
+	defer s.loader.unlock()
+
+	instances := make([]Instance, 0, len(s.loader.runners))
+	for key, info := range s.loader.runners {
+		if s.loader.slots[info.slot] != nil {
+			instances = append(instances, s.loader.instance(key, info))
+		}
+	}
+	slices.SortFunc(instances, func(a, b Instance) int { return a.ID - b.ID })
+	return instances
+}
+
+// LoadInstance loads a model, unless it's loaded already, and returns the
+// instance serving it. The backend and mode are those the model is loaded
+// with ahead of requests, unless backendName or mode are set. Unlike
+// preloading, it doesn't pull the model if it's missing or warm it up.
+func (s *Scheduler) LoadInstance(ctx context.Context, backendName, model string, mode *inference.BackendMode) (Instance, error) {
+	if err := s.waitUntilRunning(ctx); err != nil {
+		return Instance{}, err
+	}
+	modelID := s.modelManager.ResolveID(model)
+	backend, loadMode, err := s.loadTarget(ctx, model, modelID)
+	if err != nil {
+		return Instance{}, err
+	}
+	if backendName != "" {
+		if backend, err = s.LookupBackend(backendName); err != nil {
+			return Instance{}, err
+		}
+	}
+	if mode != nil {
+		loadMode = *mode
+	}
+	if err := s.installer.wait(ctx, backend.Name()); err != nil {
+		return Instance{}, fmt.Errorf("%s backend unavailable: %w", backend.Name(), err)
+	}
+
+	s.log.Infof("Loading %s with the %s backend in %s mode on request", utils.SanitizeForLog(model, -1), backend.Name(), loadMode)
+	runner, err := s.loader.load(ctx, backend.Name(), modelID, model, loadMode)
+	if err != nil {
+		return Instance{}, fmt.Errorf("unable to load runner: %w", err)
+	}
+	s.loader.release(runner, nil)
+	return s.loader.runnerInstance(ctx, runner)
+}
+
+// Unload unloads the specified runners, as long as they aren't serving
+// requests, and returns the number of unloaded runners.
+func (s *Scheduler) Unload(ctx context.Context, unload UnloadRequest) int {
+	return s.loader.Unload(ctx, unload)
+}
+
+// RestartInstance terminates the backend process of an instance, such as one
+// that stopped responding, and loads its model again. Requests that the
+// instance is serving fail. It returns the instance that replaced it.
+func (s *Scheduler) RestartInstance(ctx context.Context, id int) (Instance, error) {
+	if !s.loader.lock(ctx) {
+		return Instance{}, context.Canceled
+	}
+	key, info, ok := s.loader.lookupInstance(id)
+	if !ok {
+		s.loader.unlock()
+		return Instance{}, ErrInstanceNotFound
+	}
+	r := s.loader.slots[id]
+	s.log.Infof("Restarting %s backend runner with model %s (%s) in %s mode on request",
+		key.backend, key.modelID, info.modelRef, key.mode,
+	)
+	r.cancel()
+	s.loader.unlock()
+
+	// Wait for the backend process to exit, which may take until it's killed
+	// if it doesn't respond to being interrupted.
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return Instance{}, ctx.Err()
+	}
+
+	// Evict the runner unless it's still serving requests, in which case
+	// it's evicted once they're done.
+	if !s.loader.lock(ctx) {
+		return Instance{}, context.Canceled
+	}
+	if current, ok := s.loader.runners[key]; ok && current.slot == id && s.loader.slots[id] == r && s.loader.references[id] == 0 {
+		s.loader.freeRunnerSlot(id, key, EventUnload, "restart requested")
+		s.loader.broadcast()
+	}
+	s.loader.unlock()
+
+	runner, err := s.loader.load(ctx, key.backend, key.modelID, info.modelRef, key.mode)
+	if err != nil {
+		return Instance{}, fmt.Errorf("unable to load runner: %w", err)
+	}
+	s.loader.release(runner, nil)
+	return s.loader.runnerInstance(ctx, runner)
+}
+
+// InstanceOutput returns the recent error output of an instance's backend
+// process.
+func (s *Scheduler) InstanceOutput(ctx context.Context, id int) ([]byte, error) {
+	if !s.loader.lock(ctx) {
+		return nil, context.Canceled
+	}
+	defer s.loader.unlock()
+	if _, _, ok := s.loader.lookupInstance(id); !ok {
+		return nil, ErrInstanceNotFound
+	}
+	return s.loader.slots[id].output.Bytes(), nil
+}
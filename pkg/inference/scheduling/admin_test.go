@@ -0,0 +1,185 @@
+package scheduling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// servingBackend is a backend that serves readiness requests on the runner's
+// socket until it's terminated.
+type servingBackend struct{ mockBackend }
+
+func (b *servingBackend) Run(ctx context.Context, socket, model string, modelRef string, mode inference.BackendMode, config *inference.BackendConfiguration) error {
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"object":"list","data":[]}`)
+	})}
+	go server.Serve(listener)
+	<-ctx.Done()
+	return server.Close()
+}
+
+func TestOutputTail(t *testing.T) {
+	tail := newOutputTail(16)
+	tail.Write([]byte("first line\n"))
+	tail.Write([]byte("second line\n"))
+	if output := string(tail.Bytes()); output != "second line\n" {
+		t.Errorf("Expected whole lines to be discarded, got %q", output)
+	}
+	tail.Write([]byte("a line longer than the tail"))
+	if output := string(tail.Bytes()); output != "er than the tail" {
+		t.Errorf("Expected the end of the output to be kept, got %q", output)
+	}
+}
+
+func TestRestartInstance(t *testing.T) {
+	dir := t.TempDir()
+	socketPath := RunnerSocketPath
+	RunnerSocketPath = func(slot int) (string, error) {
+		return filepath.Join(dir, fmt.Sprintf("runner-%d.sock", slot)), nil
+	}
+	t.Cleanup(func() { RunnerSocketPath = socketPath })
+
+	log := createTestLogger()
+	backend := &servingBackend{mockBackend{name: "test-backend", requiredMemory: inference.RequiredMemory{RAM: GB}}}
+	loader := newLoader(log, map[string]inference.Backend{"test-backend": backend}, nil, nil,
+		&mockSystemMemoryInfo{totalMemory: inference.RequiredMemory{RAM: 4 * GB}})
+	loader.loadsEnabled = true
+	s := &Scheduler{log: log, loader: loader}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r, err := loader.load(ctx, "test-backend", "model1", "model1:latest", inference.BackendModeEmbedding)
+	if err != nil {
+		t.Fatalf("Failed to load runner: %v", err)
+	}
+	loader.release(r, nil)
+	r.output.Write([]byte("wedged\n"))
+
+	instances := s.Instances(ctx)
+	if len(instances) != 1 || instances[0].ModelName != "model1:latest" || instances[0].Mode != "embedding" || !instances[0].Running {
+		t.Fatalf("Unexpected instances %+v", instances)
+	}
+	id := instances[0].ID
+	if output, err := s.InstanceOutput(ctx, id); err != nil || string(output) != "wedged\n" {
+		t.Errorf("Expected the instance's output, got %q, %v", output, err)
+	}
+
+	instance, err := s.RestartInstance(ctx, id)
+	if err != nil {
+		t.Fatalf("Failed to restart instance: %v", err)
+	}
+	select {
+	case <-r.done:
+	default:
+		t.Error("Expected the backend process to be terminated")
+	}
+	if instance.ModelID != "model1" || !instance.Running || loader.slots[instance.ID] == r {
+		t.Errorf("Expected the instance to be replaced, got %+v", instance)
+	}
+	if output, err := s.InstanceOutput(ctx, instance.ID); err != nil || len(output) != 0 {
+		t.Errorf("Expected the new instance to have no output, got %q, %v", output, err)
+	}
+	events := s.Events(EventFilter{Type: EventUnload})
+	if len(events) != 1 || events[0].Reason != "restart requested" {
+		t.Errorf("Expected the restart to be recorded, got %+v", events)
+	}
+
+	if _, err := s.RestartInstance(ctx, instance.ID+1); !errors.Is(err, ErrInstanceNotFound) {
+		t.Errorf("Expected unknown instances not to be found, got %v", err)
+	}
+	if _, err := s.InstanceOutput(ctx, instance.ID+1); !errors.Is(err, ErrInstanceNotFound) {
+		t.Errorf("Expected unknown instances not to be found, got %v", err)
+	}
+
+	loader.lock(ctx)
+	loader.evict(false)
+	loader.unlock()
+}
+
+// uninstallableBackend is a serving backend whose uninstallation is
+// performed by a hook.
+type uninstallableBackend struct {
+	servingBackend
+	uninstall func() error
+}
+
+func (b *uninstallableBackend) Uninstall(ctx context.Context) error {
+	return b.uninstall()
+}
+
+func TestUninstallBackend(t *testing.T) {
+	dir := t.TempDir()
+	socketPath := RunnerSocketPath
+	RunnerSocketPath = func(slot int) (string, error) {
+		return filepath.Join(dir, fmt.Sprintf("runner-%d.sock", slot)), nil
+	}
+	t.Cleanup(func() { RunnerSocketPath = socketPath })
+
+	log := createTestLogger()
+	backend := &uninstallableBackend{servingBackend: servingBackend{mockBackend{name: "test-backend", requiredMemory: inference.RequiredMemory{RAM: GB}}}}
+	backends := map[string]inference.Backend{"test-backend": backend}
+	loader := newLoader(log, backends, nil, nil,
+		&mockSystemMemoryInfo{totalMemory: inference.RequiredMemory{RAM: 4 * GB}})
+	loader.loadsEnabled = true
+	installer := newInstaller(log, backends, nil)
+	installer.started.Store(true)
+	close(installer.status("test-backend").installed)
+	s := &Scheduler{log: log, backends: backends, loader: loader, installer: installer}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	load := func() error {
+		r, err := loader.load(ctx, "test-backend", "model1", "model1:latest", inference.BackendModeEmbedding)
+		if err == nil {
+			loader.release(r, nil)
+		}
+		return err
+	}
+	if err := load(); err != nil {
+		t.Fatalf("Failed to load runner: %v", err)
+	}
+
+	// A failed uninstallation leaves the backend usable.
+	backend.uninstall = func() error { return errors.New("boom") }
+	if err := s.UninstallBackend(ctx, "test-backend"); err == nil {
+		t.Fatal("Expected the uninstallation to fail")
+	}
+	if err := installer.wait(ctx, "test-backend"); err != nil {
+		t.Errorf("Expected the backend to remain installed, got %v", err)
+	}
+	if err := load(); err != nil {
+		t.Errorf("Expected the backend to remain loadable, got %v", err)
+	}
+
+	// The backend can't be loaded while it's being uninstalled.
+	backend.uninstall = func() error {
+		if err := installer.wait(ctx, "test-backend"); !errors.Is(err, errBackendUninstalling) {
+			t.Errorf("Expected waits to fail during uninstallation, got %v", err)
+		}
+		if err := load(); !errors.Is(err, errBackendUninstalling) {
+			t.Errorf("Expected loads to fail during uninstallation, got %v", err)
+		}
+		return nil
+	}
+	if err := s.UninstallBackend(ctx, "test-backend"); err != nil {
+		t.Fatalf("Failed to uninstall backend: %v", err)
+	}
+	if err := installer.wait(ctx, "test-backend"); !errors.Is(err, errBackendUninstalled) {
+		t.Errorf("Expected waits to fail after uninstallation, got %v", err)
+	}
+	if err := load(); !errors.Is(err, errBackendUninstalling) {
+		t.Errorf("Expected loads to fail after uninstallation, got %v", err)
+	}
+}
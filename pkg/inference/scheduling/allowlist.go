@@ -0,0 +1,53 @@
+package scheduling
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ErrModelNotAllowed indicates that a request used a model that isn't in the
+// list of allowed models. If returned in conjunction with an HTTP request, it
+// should be paired with a 403 response status.
+var ErrModelNotAllowed = errors.New("model not allowed")
+
+// ErrModelNotAllowedForKey indicates that a request used a model that the API
+// key it was authenticated with can't use. If returned in conjunction with an
+// HTTP request, it should be paired with a 403 response status.
+var ErrModelNotAllowedForKey = errors.New("model not allowed for this API key")
+
+// modelAllowlist restricts the models that can be used for inference.
+type modelAllowlist struct {
+	// lock guards models.
+	lock sync.Mutex
+	// models are the allowed model references. If empty, all models are
+	// allowed.
+	models []string
+}
+
+// set sets the allowed models. An empty list allows all models.
+func (a *modelAllowlist) set(models []string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.models = slices.Clone(models)
+}
+
+// allows returns true if the model is allowed, either because it's listed by
+// reference or because a listed reference resolves to the same model ID.
+func (a *modelAllowlist) allows(model string, resolveID func(string) string) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return modelListed(a.models, model, resolveID)
+}
+
+// modelListed returns true if the models list the model, either by reference
+// or by a reference that resolves to the same model ID, or if they're empty.
+func modelListed(models []string, model string, resolveID func(string) string) bool {
+	if len(models) == 0 || slices.Contains(models, model) {
+		return true
+	}
+	modelID := resolveID(model)
+	return slices.ContainsFunc(models, func(allowed string) bool {
+		return resolveID(allowed) == modelID
+	})
+}
@@ -0,0 +1,41 @@
+package scheduling
+
+import (
+	"testing"
+)
+
+func TestModelAllowlist(t *testing.T) {
+	ids := map[string]string{
+		"ai/smollm2":        "sha256:smollm2",
+		"ai/smollm2:latest": "sha256:smollm2",
+	}
+	resolveID := func(ref string) string {
+		if id, ok := ids[ref]; ok {
+			return id
+		}
+		return ref
+	}
+
+	tests := []struct {
+		name    string
+		allowed []string
+		model   string
+		want    bool
+	}{
+		{name: "empty allows all", model: "ai/gemma3", want: true},
+		{name: "listed", allowed: []string{"ai/gemma3"}, model: "ai/gemma3", want: true},
+		{name: "same ID", allowed: []string{"ai/smollm2"}, model: "ai/smollm2:latest", want: true},
+		{name: "by ID", allowed: []string{"ai/smollm2"}, model: "sha256:smollm2", want: true},
+		{name: "not listed", allowed: []string{"ai/smollm2"}, model: "ai/gemma3", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var allowlist modelAllowlist
+			allowlist.set(tt.allowed)
+			if got := allowlist.allows(tt.model, resolveID); got != tt.want {
+				t.Errorf("allows(%q) = %v, want %v", tt.model, got, tt.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/models"
 )
 
 const (
@@ -13,6 +14,10 @@ const (
 	// enough to encompass any real-world request but also small enough to avoid
 	// DoS attacks.
 	maximumOpenAIInferenceRequestSize = 10 * 1024 * 1024
+	// maximumTranscriptionRequestSize is the maximum OpenAI API audio
+	// transcription request size that Scheduler will allow. It matches the
+	// upload limit of the OpenAI API.
+	maximumTranscriptionRequestSize = 25 * 1024 * 1024
 )
 
 // trimRequestPathToOpenAIRoot trims a request path to start at the first
@@ -32,12 +37,16 @@ func trimRequestPathToOpenAIRoot(path string) string {
 // OpenAI inference request. Its second parameter is true if and only if a valid
 // mode could be determined.
 func backendModeForRequest(path string) (inference.BackendMode, bool) {
-	if strings.HasSuffix(path, "/v1/chat/completions") || strings.HasSuffix(path, "/v1/completions") {
+	if strings.HasSuffix(path, "/v1/chat/completions") || strings.HasSuffix(path, "/v1/completions") || isTokenizerRequest(path) {
 		return inference.BackendModeCompletion, true
 	} else if strings.HasSuffix(path, "/v1/embeddings") {
 		return inference.BackendModeEmbedding, true
 	} else if strings.HasSuffix(path, "/rerank") || strings.HasSuffix(path, "/score") {
 		return inference.BackendModeReranking, true
+	} else if strings.HasSuffix(path, "/v1/audio/transcriptions") {
+		return inference.BackendModeTranscription, true
+	} else if strings.HasSuffix(path, "/v1/images/generations") {
+		return inference.BackendModeImageGeneration, true
 	}
 	return inference.BackendMode(0), false
 }
@@ -47,6 +56,15 @@ func backendModeForRequest(path string) (inference.BackendMode, bool) {
 type OpenAIInferenceRequest struct {
 	// Model is the requested model name.
 	Model string `json:"model"`
+	// KeepAlive is how long the model stays loaded after the request, if set.
+	KeepAlive *KeepAlive `json:"keep_alive,omitempty"`
+	// Priority is the scheduling priority of the request, if set.
+	Priority Priority `json:"priority,omitempty"`
+	// Stream indicates whether the response is streamed.
+	Stream bool `json:"stream,omitempty"`
+	// PromptCacheKey identifies the prompt prefix that the request shares
+	// with other requests, if set.
+	PromptCacheKey string `json:"prompt_cache_key,omitempty"`
 }
 
 // OpenAIErrorResponse is used to format an OpenAI API compatible error response
@@ -71,12 +89,55 @@ type BackendStatus struct {
 	LastUsed time.Time `json:"last_used,omitempty"`
 	// InUse indicates whether this backend is currently handling a request
 	InUse bool `json:"in_use,omitempty"`
+	// Devices are the GPUs assigned to the backend runner, if any
+	Devices []int `json:"devices,omitempty"`
+	// Replica is the index of the runner among the model's replicas
+	Replica int `json:"replica,omitempty"`
+	// Restarts is the number of times the model's runners were restarted
+	// after crashing
+	Restarts int `json:"restarts,omitempty"`
+	// Restarting indicates that the model's runner crashed and is waiting to
+	// be restarted
+	Restarting bool `json:"restarting,omitempty"`
+	// LastError describes the model's most recent crash, if any
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Instance describes the backend process of a runner, for the admin API.
+type Instance struct {
+	// ID identifies the instance. It's the index of the runner's slot, so
+	// IDs are reused once instances are unloaded.
+	ID int `json:"id"`
+	// BackendName is the name of the backend
+	BackendName string `json:"backend_name"`
+	// ModelName is the reference of the model loaded in the backend
+	ModelName string `json:"model_name"`
+	// ModelID is the ID of the model loaded in the backend
+	ModelID string `json:"model_id"`
+	// Mode is the mode the backend is operating in
+	Mode string `json:"mode"`
+	// Replica is the index of the runner among the model's replicas
+	Replica int `json:"replica,omitempty"`
+	// Devices are the GPUs assigned to the backend runner, if any
+	Devices []int `json:"devices,omitempty"`
+	// Requests is the number of requests the instance is serving
+	Requests int `json:"requests"`
+	// LastUsed is when the instance was last used, if it's idle
+	LastUsed time.Time `json:"last_used,omitempty"`
+	// Running indicates that the backend process hasn't exited
+	Running bool `json:"running"`
 }
 
 // DiskUsage represents the disk usage of the models and default backend.
 type DiskUsage struct {
 	ModelsDiskUsage         int64 `json:"models_disk_usage"`
 	DefaultBackendDiskUsage int64 `json:"default_backend_disk_usage"`
+	// DerivedModelsDiskUsage is the part of the models' disk usage taken up
+	// by models derived locally from other models, such as quantized variants.
+	DerivedModelsDiskUsage int64 `json:"derived_models_disk_usage"`
+	// ReclaimableDiskUsage is the part of the models' disk usage taken up by
+	// blobs that no model references, which pruning the store removes.
+	ReclaimableDiskUsage int64 `json:"reclaimable_disk_usage"`
 }
 
 // UnloadRequest is used to specify which models to unload.
@@ -98,4 +159,93 @@ type ConfigureRequest struct {
 	RuntimeFlags    []string                             `json:"runtime-flags,omitempty"`
 	RawRuntimeFlags string                               `json:"raw-runtime-flags,omitempty"`
 	Speculative     *inference.SpeculativeDecodingConfig `json:"speculative,omitempty"`
+	KVCacheType     inference.KVCacheType                `json:"kv-cache-type,omitempty"`
+	RopeScaling     *inference.RopeScalingConfig         `json:"rope-scaling,omitempty"`
+	Embeddings      *inference.EmbeddingConfig           `json:"embeddings,omitempty"`
+	Remote          *inference.RemoteConfig              `json:"remote,omitempty"`
+	KeepAlive       *KeepAlive                           `json:"keep-alive,omitempty"`
+	// MaxConcurrentRequests is the maximum number of requests served
+	// concurrently for the model. If zero, the default limit applies.
+	MaxConcurrentRequests int `json:"max-concurrent-requests,omitempty"`
+	// Devices are the indices of the GPUs that the model's runners are pinned
+	// to. If empty, runners are placed according to the placement policy.
+	Devices []int `json:"devices,omitempty"`
+	// Replicas configures replicas of the model, which serve its requests
+	// under a single name.
+	Replicas *inference.ReplicaConfig `json:"replicas,omitempty"`
+	// FirstTokenTimeout is the maximum time until the model starts responding
+	// to a request, as a duration or a number of seconds. If empty, the
+	// default limit applies.
+	FirstTokenTimeout string `json:"first-token-timeout,omitempty"`
+	// GenerationTimeout is the maximum time until the model finishes
+	// responding to a request, as a duration or a number of seconds. If
+	// empty, the default limit applies.
+	GenerationTimeout string `json:"generation-timeout,omitempty"`
+}
+
+// KeepAliveRequest sets how long idle runners stay loaded.
+type KeepAliveRequest struct {
+	// Model is the model whose keep-alive is set. If empty, the default
+	// keep-alive of all models without one is set.
+	Model string `json:"model,omitempty"`
+	// KeepAlive is how long the model stays loaded after its last request.
+	KeepAlive KeepAlive `json:"keep_alive"`
+}
+
+// ModelState is the load state of a model.
+type ModelState string
+
+const (
+	// ModelStateLoaded indicates that a model is loaded by a backend.
+	ModelStateLoaded ModelState = "loaded"
+	// ModelStateUnloaded indicates that a model isn't loaded by any backend.
+	ModelStateUnloaded ModelState = "unloaded"
+)
+
+// OpenAIModel is a model in the OpenAI models listing, along with its
+// lifecycle state.
+type OpenAIModel struct {
+	models.OpenAIModel
+	// State is the load state of the model.
+	State ModelState `json:"state"`
+	// Backend is the backend that the model is loaded by, if it's loaded.
+	Backend string `json:"backend,omitempty"`
+	// Quantization is the quantization of the model's weights, if known.
+	Quantization string `json:"quantization,omitempty"`
+	// Memory is the memory allocated to the model's runners, if it's loaded.
+	Memory *models.ModelMemory `json:"memory,omitempty"`
+	// LastUsed is the Unix epoch timestamp of when the model last served a
+	// request, if it did since the model runner started.
+	LastUsed int64 `json:"last_used,omitempty"`
+}
+
+// OpenAIModelList is the OpenAI models listing, along with the lifecycle
+// state of the models.
+type OpenAIModelList struct {
+	// Object is the object type. It's always "list".
+	Object string `json:"object"`
+	// Data is the list of models.
+	Data []*OpenAIModel `json:"data"`
+}
+
+// OpenAIModelDeletion is the response to the deletion of a model.
+type OpenAIModelDeletion struct {
+	// ID is the reference of the deleted model.
+	ID string `json:"id"`
+	// Object is the object type. It's always "model".
+	Object string `json:"object"`
+	// Deleted indicates that the model was deleted.
+	Deleted bool `json:"deleted"`
+}
+
+// ModelLoadRequest is the optional body of a request to load a model through
+// the OpenAI models API.
+type ModelLoadRequest struct {
+	// Backend is the backend to load the model with. If empty, the backend
+	// in the request path, if any, or the backend selected for the model is
+	// used.
+	Backend string `json:"backend,omitempty"`
+	// Mode is the mode to load the model in. If empty, it's the mode implied
+	// by the model.
+	Mode string `json:"mode,omitempty"`
 }
@@ -0,0 +1,77 @@
+package scheduling
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// bufferedResponse is a response that was buffered to be transformed before
+// it's written to the client.
+type bufferedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// errorResponse returns an error response with a plain text message.
+func errorResponse(status int, message string) bufferedResponse {
+	header := make(http.Header)
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	header.Set("X-Content-Type-Options", "nosniff")
+	return bufferedResponse{status: status, header: header, body: []byte(message + "\n")}
+}
+
+// write writes the response to a response writer.
+func (r bufferedResponse) write(w http.ResponseWriter) {
+	for key, values := range r.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(r.status)
+	_, _ = w.Write(r.body)
+}
+
+// bufferedResponseWriter is a response writer that buffers the response of a
+// runner.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header implements http.ResponseWriter.Header.
+func (r *bufferedResponseWriter) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader implements http.ResponseWriter.WriteHeader.
+func (r *bufferedResponseWriter) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// Write implements http.ResponseWriter.Write.
+func (r *bufferedResponseWriter) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+// serveBuffered sends a request body to a runner, on behalf of the original
+// request, and returns its buffered response.
+func serveBuffered(ctx context.Context, runner http.Handler, original *http.Request, body []byte) bufferedResponse {
+	req := original.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	// Let the transport negotiate compression, so that the response is
+	// decompressed before it's transformed.
+	req.Header.Del("Accept-Encoding")
+	buffered := &bufferedResponseWriter{header: make(http.Header)}
+	runner.ServeHTTP(buffered, req)
+	if buffered.status == 0 {
+		buffered.status = http.StatusOK
+	}
+	buffered.header.Del("Content-Length")
+	return bufferedResponse{status: buffered.status, header: buffered.header, body: buffered.body.Bytes()}
+}
@@ -0,0 +1,222 @@
+package scheduling
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/inference/chattemplate"
+)
+
+// chatOnlyFields are the fields of chat completion requests that completion
+// requests don't have, which are removed when the prompt is rendered.
+var chatOnlyFields = []string{"messages", "tools", "tool_choice", "parallel_tool_calls", "chat_template_kwargs"}
+
+// renderChatRequest translates a chat completion request into a completion
+// request whose prompt is its conversation rendered with a Go chat template.
+// Only text content can be rendered.
+func renderChatRequest(tmpl string, body []byte) ([]byte, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	var messages []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(request["messages"], &messages); err != nil {
+		return nil, fmt.Errorf("invalid messages: %w", err)
+	}
+
+	conversation := make([]chattemplate.Message, 0, len(messages))
+	for _, message := range messages {
+		content, err := messageText(message.Content)
+		if err != nil {
+			return nil, err
+		}
+		conversation = append(conversation, chattemplate.Message{Role: message.Role, Content: content})
+	}
+	prompt, err := chattemplate.Render(tmpl, conversation)
+	if err != nil {
+		return nil, fmt.Errorf("rendering chat template: %w", err)
+	}
+
+	for _, field := range chatOnlyFields {
+		delete(request, field)
+	}
+	completionLogprobsField(request)
+	if request["prompt"], err = json.Marshal(prompt); err != nil {
+		return nil, err
+	}
+	return json.Marshal(request)
+}
+
+// messageText returns the text of the content of a message, which is either a
+// string or an array of parts.
+func messageText(content json.RawMessage) (string, error) {
+	if len(content) == 0 || string(content) == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return text, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return "", fmt.Errorf("invalid message content: %w", err)
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type != "text" {
+			return "", fmt.Errorf("the chat template of the model only supports text content, not %q", part.Type)
+		}
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// chatResponseWriter is a response writer that translates completion
+// responses into chat completion responses, for chat completions whose prompt
+// was rendered by renderChatRequest, or that translates chat completion
+// responses with another translation. Error responses are written as-is.
+type chatResponseWriter struct {
+	http.ResponseWriter
+	// translate translates a response, or a chunk of a streamed response,
+	// which is dropped if it's translated to nil. If nil, completionToChat is
+	// used.
+	translate func(data []byte, chunk bool) []byte
+	// status is the status of the response, once it's written.
+	status int
+	// stream indicates whether the response is a stream of server-sent
+	// events.
+	stream bool
+	// pending is the incomplete line of a stream written last, or the whole
+	// body of other responses.
+	pending []byte
+}
+
+// WriteHeader implements http.ResponseWriter.WriteHeader.
+func (w *chatResponseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if status == http.StatusOK {
+		w.stream = strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream")
+		w.Header().Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.Write.
+func (w *chatResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.status != http.StatusOK {
+		return w.ResponseWriter.Write(b)
+	}
+	w.pending = append(w.pending, b...)
+	if !w.stream {
+		return len(b), nil
+	}
+	for {
+		line, rest, found := bytes.Cut(w.pending, []byte("\n"))
+		if !found {
+			break
+		}
+		w.pending = rest
+		if data, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+			translated := w.translation()(data, true)
+			if translated == nil {
+				continue
+			}
+			line = append([]byte("data: "), translated...)
+		}
+		if _, err := w.ResponseWriter.Write(append(line, '\n')); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Unwrap returns the underlying response writer, so that it can be flushed
+// via http.ResponseController.
+func (w *chatResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush implements http.Flusher.Flush.
+func (w *chatResponseWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// translation returns the translation of the writer.
+func (w *chatResponseWriter) translation() func(data []byte, chunk bool) []byte {
+	if w.translate != nil {
+		return w.translate
+	}
+	return completionToChat
+}
+
+// finish writes the translated body of responses that aren't streams, along
+// with the rest of streams. It must be called once the response is complete.
+func (w *chatResponseWriter) finish() {
+	if len(w.pending) == 0 {
+		return
+	}
+	body := w.pending
+	if !w.stream {
+		body = w.translation()(body, false)
+	}
+	w.pending = nil
+	_, _ = w.ResponseWriter.Write(body)
+}
+
+// completionToChat translates a completion response, or a chunk of a streamed
+// completion response, into a chat completion response. Anything else, such
+// as the end of a stream, is returned as-is.
+func completionToChat(data []byte, chunk bool) []byte {
+	var response map[string]any
+	if err := json.Unmarshal(data, &response); err != nil {
+		return data
+	}
+	choices, ok := response["choices"].([]any)
+	if !ok {
+		return data
+	}
+	for i, c := range choices {
+		choice, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		text, _ := choice["text"].(string)
+		translated := map[string]any{
+			"index":         choice["index"],
+			"finish_reason": choice["finish_reason"],
+		}
+		if logprobs, ok := choice["logprobs"]; ok {
+			translated["logprobs"] = chatLogprobs(logprobs)
+		}
+		if chunk {
+			translated["delta"] = map[string]any{"content": text}
+		} else {
+			translated["message"] = map[string]any{"role": "assistant", "content": text}
+		}
+		choices[i] = translated
+	}
+	response["object"] = "chat.completion"
+	if chunk {
+		response["object"] = "chat.completion.chunk"
+	}
+	translated, err := json.Marshal(response)
+	if err != nil {
+		return data
+	}
+	return translated
+}
@@ -0,0 +1,105 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderChatRequest(t *testing.T) {
+	body := `{"model": "ai/model", "stream": true, "tools": [], "messages": [
+		{"role": "system", "content": "Be brief."},
+		{"role": "user", "content": [{"type": "text", "text": "Hi"}]}
+	]}`
+	rendered, err := renderChatRequest("{{ .System }}|{{ range .Messages }}{{ .Role }}:{{ .Content }};{{ end }}", []byte(body))
+	if err != nil {
+		t.Fatalf("Failed to render request: %v", err)
+	}
+	var request map[string]any
+	if err := json.Unmarshal(rendered, &request); err != nil {
+		t.Fatalf("Failed to decode rendered request: %v", err)
+	}
+	if request["prompt"] != "Be brief.|system:Be brief.;user:Hi;" {
+		t.Errorf("Unexpected prompt %q", request["prompt"])
+	}
+	if request["model"] != "ai/model" || request["stream"] != true {
+		t.Errorf("Expected the other fields to be kept, got %v", request)
+	}
+	if _, ok := request["messages"]; ok {
+		t.Errorf("Expected the messages to be removed, got %v", request)
+	}
+	if _, ok := request["tools"]; ok {
+		t.Errorf("Expected the tools to be removed, got %v", request)
+	}
+
+	images := `{"messages": [{"role": "user", "content": [{"type": "image_url", "image_url": {"url": "data:"}}]}]}`
+	if _, err := renderChatRequest("{{ .Prompt }}", []byte(images)); err == nil || !strings.Contains(err.Error(), "only supports text content") {
+		t.Errorf("Expected images to be rejected, got %v", err)
+	}
+}
+
+func TestChatResponseWriter(t *testing.T) {
+	t.Run("response", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		w := &chatResponseWriter{ResponseWriter: recorder}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "1000")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id": "cmpl-1", "object": "text_completion", "choices": [{"index": 0, `))
+		w.Write([]byte(`"text": "Hello", "finish_reason": "stop"}], "usage": {"total_tokens": 3}}`))
+		w.finish()
+
+		if recorder.Header().Get("Content-Length") != "" {
+			t.Errorf("Expected the content length to be removed")
+		}
+		var response struct {
+			ID      string `json:"id"`
+			Object  string `json:"object"`
+			Choices []struct {
+				Message struct {
+					Role    string `json:"role"`
+					Content string `json:"content"`
+				} `json:"message"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage map[string]int `json:"usage"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response %q: %v", recorder.Body.String(), err)
+		}
+		if response.ID != "cmpl-1" || response.Object != "chat.completion" || response.Usage["total_tokens"] != 3 ||
+			len(response.Choices) != 1 || response.Choices[0].Message.Role != "assistant" ||
+			response.Choices[0].Message.Content != "Hello" || response.Choices[0].FinishReason != "stop" {
+			t.Errorf("Unexpected response %s", recorder.Body.String())
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		w := &chatResponseWriter{ResponseWriter: recorder}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"object\": \"text_completion\", \"choices\": [{\"index\": 0, \"text\": \"Hel\"}]}\n\ndata: {\"choices\": [{\"index\": 0, "))
+		w.Write([]byte("\"text\": \"lo\", \"finish_reason\": \"stop\"}]}\n\ndata: [DONE]\n\n"))
+		w.finish()
+
+		expected := "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"},\"finish_reason\":null,\"index\":0}],\"object\":\"chat.completion.chunk\"}\n\n" +
+			"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\",\"index\":0}],\"object\":\"chat.completion.chunk\"}\n\n" +
+			"data: [DONE]\n\n"
+		if recorder.Body.String() != expected {
+			t.Errorf("Expected stream %q, got %q", expected, recorder.Body.String())
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		w := &chatResponseWriter{ResponseWriter: recorder}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"message": "bad request"}}`))
+		w.finish()
+		if recorder.Code != http.StatusBadRequest || recorder.Body.String() != `{"error": {"message": "bad request"}}` {
+			t.Errorf("Expected the error to be written as-is, got %d %q", recorder.Code, recorder.Body.String())
+		}
+	})
+}
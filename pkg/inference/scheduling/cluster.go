@@ -0,0 +1,308 @@
+package scheduling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/logging"
+	"github.com/docker/model-runner/pkg/tracing"
+)
+
+const (
+	// peerPollInterval is the interval at which the status of peers is
+	// polled.
+	peerPollInterval = 10 * time.Second
+	// peerPollTimeout bounds the time that polling a peer's status may take.
+	peerPollTimeout = 5 * time.Second
+)
+
+// NodeStatus describes the capacity of a model runner instance, which peers
+// use to decide where to place models.
+type NodeStatus struct {
+	// TotalRAM is the RAM that models can be loaded into.
+	TotalRAM uint64 `json:"total_ram"`
+	// TotalVRAM is the VRAM that models can be loaded into.
+	TotalVRAM uint64 `json:"total_vram"`
+	// AvailableRAM is the RAM that isn't allocated to loaded models.
+	AvailableRAM uint64 `json:"available_ram"`
+	// AvailableVRAM is the VRAM that isn't allocated to loaded models.
+	AvailableVRAM uint64 `json:"available_vram"`
+	// LoadedModels are the IDs of the loaded models.
+	LoadedModels []string `json:"loaded_models"`
+}
+
+// fits returns true if the specified memory is available.
+func (s NodeStatus) fits(memory inference.RequiredMemory) bool {
+	return memory.RAM <= s.AvailableRAM && memory.VRAM <= s.AvailableVRAM
+}
+
+// PeerStatus describes a peer as seen by the local instance.
+type PeerStatus struct {
+	// URL is the peer's base URL.
+	URL string `json:"url"`
+	// Healthy is true if the peer responded to its last poll.
+	Healthy bool `json:"healthy"`
+	// LastError describes why the peer is unhealthy, if it is.
+	LastError string `json:"last_error,omitempty"`
+	// Updated is the time of the peer's last successful poll.
+	Updated time.Time `json:"updated,omitempty"`
+	// Status is the peer's status as of its last successful poll.
+	Status NodeStatus `json:"status"`
+}
+
+// peer is another model runner instance that models can be placed on.
+type peer struct {
+	// url is the peer's base URL.
+	url *url.URL
+	// proxy forwards requests to the peer.
+	proxy *httputil.ReverseProxy
+	// lock guards all subsequent fields.
+	lock sync.Mutex
+	// healthy is true if the peer responded to its last poll or request.
+	healthy bool
+	// lastError describes why the peer is unhealthy, if it is.
+	lastError string
+	// updated is the time of the peer's last successful poll.
+	updated time.Time
+	// status is the peer's status as of its last successful poll, adjusted
+	// for the models placed on it since.
+	status NodeStatus
+}
+
+// newPeer creates a new peer with the specified base URL.
+func newPeer(log logging.Logger, baseURL *url.URL) *peer {
+	p := &peer{url: baseURL}
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(baseURL)
+			// Peers serve forwarded requests themselves, which prevents
+			// requests from bouncing around the cluster.
+			r.Out.Header.Set(inference.ForwardedHeader, "1")
+		},
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Warnf("Failed to forward request to peer %s: %v", baseURL.Redacted(), err)
+			p.markUnhealthy(err)
+			http.Error(w, fmt.Sprintf("unable to reach peer %s", baseURL.Redacted()), http.StatusBadGateway)
+		},
+	}
+	return p
+}
+
+// markUnhealthy records that the peer couldn't be reached.
+func (p *peer) markUnhealthy(err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.healthy = false
+	p.lastError = err.Error()
+}
+
+// poll updates the peer's status, authenticating the request with token, if
+// any.
+func (p *peer) poll(ctx context.Context, client *http.Client, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, peerPollTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url.JoinPath(inference.InferencePrefix, "node").String(), http.NoBody)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status request failed with status %d", resp.StatusCode)
+	}
+	var status NodeStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("invalid status: %w", err)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.healthy, p.lastError, p.updated, p.status = true, "", time.Now(), status
+	return nil
+}
+
+// snapshot returns the peer's status as seen by the local instance.
+func (p *peer) snapshot() PeerStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	status := p.status
+	status.LoadedModels = slices.Clone(status.LoadedModels)
+	return PeerStatus{
+		URL:       p.url.Redacted(),
+		Healthy:   p.healthy,
+		LastError: p.lastError,
+		Updated:   p.updated,
+		Status:    status,
+	}
+}
+
+// cluster tracks the peers that models can be placed on.
+type cluster struct {
+	// log is the associated logger.
+	log logging.Logger
+	// client is used to poll peers.
+	client *http.Client
+	// token is the bearer token that peers are polled with, if any. It's set
+	// before the cluster runs.
+	token string
+	// lock guards peers.
+	lock sync.Mutex
+	// peers are the peers in the cluster.
+	peers []*peer
+	// changed is signalled when the peers change, so that they're polled
+	// immediately.
+	changed chan struct{}
+}
+
+// newCluster creates a new cluster without peers.
+func newCluster(log logging.Logger) *cluster {
+	return &cluster{
+		log:     log,
+		client:  &http.Client{},
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// ParsePeers parses a comma-separated list of peer base URLs, such as
+// "http://node2:12434,http://node3:12434".
+func ParsePeers(s string) ([]string, error) {
+	var peers []string
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		u, err := url.Parse(field)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid peer URL %q", field)
+		}
+		peers = append(peers, strings.TrimSuffix(u.String(), "/"))
+	}
+	return peers, nil
+}
+
+// setPeers replaces the peers with those at the specified base URLs.
+func (c *cluster) setPeers(urls []string) error {
+	peers := make([]*peer, 0, len(urls))
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("invalid peer URL %q: %w", rawURL, err)
+		}
+		peers = append(peers, newPeer(c.log, u))
+	}
+	c.lock.Lock()
+	c.peers = peers
+	c.lock.Unlock()
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// list returns the peers.
+func (c *cluster) list() []*peer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return slices.Clone(c.peers)
+}
+
+// monitor polls the status of peers until ctx is cancelled.
+func (c *cluster) monitor(ctx context.Context) {
+	ticker := time.NewTicker(peerPollInterval)
+	defer ticker.Stop()
+	for {
+		var wg sync.WaitGroup
+		for _, p := range c.list() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := p.poll(ctx, c.client, c.token); err != nil && ctx.Err() == nil {
+					if p.snapshot().Healthy {
+						c.log.Warnf("Peer %s is unreachable: %v", p.url.Redacted(), err)
+					}
+					p.markUnhealthy(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.changed:
+		}
+	}
+}
+
+// place selects the peer that a model that isn't loaded locally should be
+// placed on: a peer that has the model loaded or, if the model doesn't fit in
+// the memory available locally, the peer with the most VRAM available that it
+// fits on. It returns nil if the model should be loaded locally.
+func (c *cluster) place(modelID string, required, available inference.RequiredMemory) *peer {
+	peers := c.list()
+	for _, p := range peers {
+		p.lock.Lock()
+		loaded := p.healthy && slices.Contains(p.status.LoadedModels, modelID)
+		p.lock.Unlock()
+		if loaded {
+			return p
+		}
+	}
+	if required.RAM <= available.RAM && required.VRAM <= available.VRAM {
+		return nil
+	}
+
+	var best *peer
+	var bestVRAM uint64
+	for _, p := range peers {
+		p.lock.Lock()
+		if p.healthy && p.status.fits(required) && (best == nil || p.status.AvailableVRAM > bestVRAM) {
+			best, bestVRAM = p, p.status.AvailableVRAM
+		}
+		p.lock.Unlock()
+	}
+	if best == nil {
+		return nil
+	}
+
+	// Account for the model until the peer's status is next polled, so that
+	// subsequent requests follow it there and other models aren't placed on
+	// the peer based on memory that it no longer has.
+	best.lock.Lock()
+	defer best.lock.Unlock()
+	best.status.AvailableRAM -= required.RAM
+	best.status.AvailableVRAM -= required.VRAM
+	best.status.LoadedModels = append(best.status.LoadedModels, modelID)
+	return best
+}
+
+// forward forwards a request, whose body has already been read, to the peer,
+// which continues its trace.
+func (p *peer) forward(w http.ResponseWriter, r *http.Request, body []byte) {
+	r = r.Clone(r.Context())
+	tracing.Inject(r.Context(), r.Header)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	p.proxy.ServeHTTP(w, r)
+}
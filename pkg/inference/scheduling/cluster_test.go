@@ -0,0 +1,177 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/apikeys"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/sirupsen/logrus"
+)
+
+func TestParsePeers(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", input: ""},
+		{name: "single", input: "http://node2:12434", want: []string{"http://node2:12434"}},
+		{name: "multiple", input: "http://node2:12434/, https://node3 ,", want: []string{"http://node2:12434", "https://node3"}},
+		{name: "missing scheme", input: "node2:12434", wantErr: true},
+		{name: "unsupported scheme", input: "ftp://node2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePeers(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParsePeers(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterPlace(t *testing.T) {
+	newTestCluster := func() (*cluster, []*peer) {
+		c := newCluster(createTestLogger())
+		if err := c.setPeers([]string{"http://small:12434", "http://large:12434", "http://down:12434"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		peers := c.list()
+		peers[0].healthy, peers[0].status = true, NodeStatus{AvailableRAM: 8 * GB, AvailableVRAM: 8 * GB, LoadedModels: []string{"loaded"}}
+		peers[1].healthy, peers[1].status = true, NodeStatus{AvailableRAM: 32 * GB, AvailableVRAM: 24 * GB}
+		peers[2].status = NodeStatus{AvailableRAM: 64 * GB, AvailableVRAM: 64 * GB}
+		return c, peers
+	}
+
+	tests := []struct {
+		name      string
+		modelID   string
+		required  inference.RequiredMemory
+		available inference.RequiredMemory
+		want      int
+	}{
+		{name: "loaded on peer", modelID: "loaded", required: inference.RequiredMemory{RAM: GB, VRAM: GB}, available: inference.RequiredMemory{RAM: 64 * GB, VRAM: 64 * GB}, want: 0},
+		{name: "fits locally", modelID: "model", required: inference.RequiredMemory{RAM: GB, VRAM: GB}, available: inference.RequiredMemory{RAM: 2 * GB, VRAM: 2 * GB}, want: -1},
+		{name: "most VRAM", modelID: "model", required: inference.RequiredMemory{RAM: GB, VRAM: 4 * GB}, available: inference.RequiredMemory{RAM: 2 * GB, VRAM: 2 * GB}, want: 1},
+		{name: "fits nowhere", modelID: "model", required: inference.RequiredMemory{RAM: GB, VRAM: 48 * GB}, available: inference.RequiredMemory{RAM: 2 * GB, VRAM: 2 * GB}, want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, peers := newTestCluster()
+			got := c.place(tt.modelID, tt.required, tt.available)
+			if tt.want < 0 {
+				if got != nil {
+					t.Errorf("expected local placement, got %s", got.url)
+				}
+				return
+			}
+			if got != peers[tt.want] {
+				t.Fatalf("expected peer %s, got %v", peers[tt.want].url, got)
+			}
+		})
+	}
+
+	// Placing a model on a peer accounts for it until the next poll.
+	c, peers := newTestCluster()
+	if got := c.place("model", inference.RequiredMemory{RAM: GB, VRAM: 20 * GB}, inference.RequiredMemory{}); got != peers[1] {
+		t.Fatalf("expected peer %s, got %v", peers[1].url, got)
+	}
+	if got := c.place("other", inference.RequiredMemory{RAM: GB, VRAM: 20 * GB}, inference.RequiredMemory{}); got != nil {
+		t.Errorf("expected no peer with enough VRAM left, got %s", got.url)
+	}
+	if got := c.place("model", inference.RequiredMemory{RAM: GB, VRAM: 20 * GB}, inference.RequiredMemory{}); got != peers[1] {
+		t.Errorf("expected subsequent requests to follow the model to %s, got %v", peers[1].url, got)
+	}
+}
+
+func TestPeerPollAndForward(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case inference.InferencePrefix + "/node":
+			json.NewEncoder(w).Encode(NodeStatus{AvailableVRAM: 1024, LoadedModels: []string{"model"}})
+		case inference.InferencePrefix + "/v1/chat/completions":
+			if r.Header.Get(inference.ForwardedHeader) == "" {
+				t.Error("expected forwarded header")
+			}
+			w.Write([]byte("forwarded"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL)
+	p := newPeer(createTestLogger(), baseURL)
+	if err := p.poll(t.Context(), server.Client(), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status := p.snapshot(); !status.Healthy || status.Status.AvailableVRAM != 1024 || !slices.Equal(status.Status.LoadedModels, []string{"model"}) {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, inference.InferencePrefix+"/v1/chat/completions", http.NoBody)
+	recorder := httptest.NewRecorder()
+	p.forward(recorder, req, []byte(`{"model": "model"}`))
+	if body := strings.TrimSpace(recorder.Body.String()); recorder.Code != http.StatusOK || body != "forwarded" {
+		t.Errorf("unexpected response %d %q", recorder.Code, body)
+	}
+}
+
+func TestPeerPollWithAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(path, []byte(`{"keys":[{"name":"alice","key":"alice-secret"}]}`), 0o600)
+	keys, err := apikeys.NewStore(logrus.New(), path)
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	handler := apikeys.NewHandler(keys, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(NodeStatus{AvailableVRAM: 1024})
+	}))
+	handler.AllowToken("cluster-secret", "GET "+inference.InferencePrefix+"/node")
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL)
+	p := newPeer(createTestLogger(), baseURL)
+	if err := p.poll(t.Context(), server.Client(), ""); err == nil {
+		t.Error("expected polling without the cluster token to fail")
+	}
+	if err := p.poll(t.Context(), server.Client(), "cluster-secret"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status := p.snapshot(); !status.Healthy || status.Status.AvailableVRAM != 1024 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	// The cluster token isn't accepted on other routes.
+	req, _ := http.NewRequest(http.MethodPost, server.URL+inference.InferencePrefix+"/v1/chat/completions", http.NoBody)
+	req.Header.Set("Authorization", "Bearer cluster-secret")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status %d with the cluster token, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
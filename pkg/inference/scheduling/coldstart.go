@@ -0,0 +1,291 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// coldStartProgressInterval is the interval at which streamed requests that
+// wait for their model to load are sent progress comments.
+const coldStartProgressInterval = 5 * time.Second
+
+// loadTracker tracks which models have runners loaded and how long their
+// runners took to load, so that requests that have to wait for their model to
+// load can estimate how long that takes, as well as the memory available to
+// further runners. It has its own lock because the loader lock is held while
+// runners load.
+type loadTracker struct {
+	// lock guards all fields.
+	lock sync.Mutex
+	// loaded maps model keys to their number of loaded runners.
+	loaded map[runnerKey]int
+	// durations maps model keys to their estimated load durations.
+	durations map[runnerKey]time.Duration
+	// totalMemory is the loader's total memory.
+	totalMemory inference.RequiredMemory
+	// availableMemory is the loader's available memory.
+	availableMemory inference.RequiredMemory
+}
+
+// newLoadTracker creates a new load tracker.
+func newLoadTracker() *loadTracker {
+	return &loadTracker{
+		loaded:    make(map[runnerKey]int),
+		durations: make(map[runnerKey]time.Duration),
+	}
+}
+
+// modelKey returns the key under which the runners with the specified key are
+// tracked. Runners are tracked per model rather than per replica or draft
+// model, since requests don't determine either.
+func modelKey(key runnerKey) runnerKey {
+	key.replica = 0
+	key.draftModelID = ""
+	return key
+}
+
+// recordLoad records that a runner was loaded in the specified duration. The
+// estimated load duration of the runner's model is the average of its loads,
+// weighted towards the most recent ones.
+func (t *loadTracker) recordLoad(key runnerKey, duration time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key = modelKey(key)
+	t.loaded[key]++
+	if previous, ok := t.durations[key]; ok {
+		duration = (previous + duration) / 2
+	}
+	t.durations[key] = duration
+}
+
+// recordUnload records that a runner was unloaded.
+func (t *loadTracker) recordUnload(key runnerKey) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key = modelKey(key)
+	if t.loaded[key]--; t.loaded[key] <= 0 {
+		delete(t.loaded, key)
+	}
+}
+
+// recordMemory records the loader's total and available memory.
+func (t *loadTracker) recordMemory(totalMemory, availableMemory inference.RequiredMemory) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.totalMemory, t.availableMemory = totalMemory, availableMemory
+}
+
+// nodeStatus returns the loader's memory and loaded models.
+func (t *loadTracker) nodeStatus() NodeStatus {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	status := NodeStatus{
+		TotalRAM:      t.totalMemory.RAM,
+		TotalVRAM:     t.totalMemory.VRAM,
+		AvailableRAM:  t.availableMemory.RAM,
+		AvailableVRAM: t.availableMemory.VRAM,
+		LoadedModels:  []string{},
+	}
+	for key := range t.loaded {
+		if !slices.Contains(status.LoadedModels, key.modelID) {
+			status.LoadedModels = append(status.LoadedModels, key.modelID)
+		}
+	}
+	slices.Sort(status.LoadedModels)
+	return status
+}
+
+// coldStart returns true if a request for the model has to wait for it to
+// load, along with the estimated load duration, which is zero if the model
+// hasn't been loaded before.
+func (t *loadTracker) coldStart(backendName, modelID string, mode inference.BackendMode) (time.Duration, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := makeRunnerKey(backendName, modelID, "", mode)
+	if t.loaded[key] > 0 {
+		return 0, false
+	}
+	return t.durations[key], true
+}
+
+// formatSeconds formats a duration as a whole number of seconds, rounded up,
+// for use in headers.
+func formatSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// coldStartWriter is a response writer for streamed requests that have to
+// wait for their model to load. It commits the response as an event stream
+// and periodically sends comments describing the request's progress, so that
+// clients aren't left waiting silently. Since the response status is already
+// committed, errors written to it are sent as error events.
+type coldStartWriter struct {
+	http.ResponseWriter
+	// model is the requested model.
+	model string
+	// estimate is the estimated load duration, if known.
+	estimate time.Duration
+	// arrived is the time at which the request arrived.
+	arrived time.Time
+	// ticket is the request's queue ticket, once it's admitted.
+	ticket atomic.Pointer[queueTicket]
+	// lock guards writes to the underlying response writer and all subsequent
+	// fields.
+	lock sync.Mutex
+	// stopped is closed once progress reporting is stopped.
+	stopped chan struct{}
+	// failed is the status of an error written after progress reporting
+	// stopped, if any.
+	failed int
+}
+
+// newColdStartWriter commits the response to w as an event stream and starts
+// reporting the progress of the request for the specified model, whose
+// estimated load duration is specified.
+func newColdStartWriter(w http.ResponseWriter, model string, estimate time.Duration, queued int) *coldStartWriter {
+	cw := &coldStartWriter{
+		ResponseWriter: w,
+		model:          model,
+		estimate:       estimate,
+		arrived:        time.Now(),
+		stopped:        make(chan struct{}),
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set(inference.QueuePositionHeader, strconv.Itoa(queued+1))
+	if estimate > 0 {
+		w.Header().Set(inference.LoadEstimateHeader, formatSeconds(estimate))
+	}
+	w.WriteHeader(http.StatusOK)
+	cw.report()
+	go cw.run()
+	return cw
+}
+
+// admitted records the request's queue ticket.
+func (cw *coldStartWriter) admitted(ticket *queueTicket) {
+	cw.ticket.Store(ticket)
+}
+
+// run periodically reports progress until stopped.
+func (cw *coldStartWriter) run() {
+	ticker := time.NewTicker(coldStartProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cw.stopped:
+			return
+		case <-ticker.C:
+			cw.report()
+		}
+	}
+}
+
+// report sends a comment describing the request's progress.
+func (cw *coldStartWriter) report() {
+	message := "waiting for " + cw.model + " to load"
+	if ticket := cw.ticket.Load(); ticket != nil {
+		if position := ticket.position(); position > 0 {
+			message += fmt.Sprintf(", queue position %d", position)
+		}
+	}
+	elapsed := time.Since(cw.arrived)
+	if cw.estimate > elapsed {
+		message += fmt.Sprintf(", about %ss remaining", formatSeconds(cw.estimate-elapsed))
+	} else if cw.estimate > 0 {
+		message += fmt.Sprintf(", taking longer than the usual %ss", formatSeconds(cw.estimate))
+	}
+
+	cw.lock.Lock()
+	defer cw.lock.Unlock()
+	select {
+	case <-cw.stopped:
+		return
+	default:
+	}
+	fmt.Fprintf(cw.ResponseWriter, ": %s\n\n", message)
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// stop stops reporting progress. It's called once the request's runner is
+// ready or the response is written.
+func (cw *coldStartWriter) stop() {
+	cw.lock.Lock()
+	defer cw.lock.Unlock()
+	select {
+	case <-cw.stopped:
+	default:
+		close(cw.stopped)
+	}
+}
+
+// WriteHeader implements http.ResponseWriter.WriteHeader. Since the response
+// status is already committed, error statuses cause subsequent writes to be
+// sent as an error event.
+func (cw *coldStartWriter) WriteHeader(statusCode int) {
+	cw.stop()
+	cw.lock.Lock()
+	defer cw.lock.Unlock()
+	if statusCode >= http.StatusBadRequest {
+		cw.failed = statusCode
+	}
+}
+
+// Write implements http.ResponseWriter.Write.
+func (cw *coldStartWriter) Write(b []byte) (int, error) {
+	cw.stop()
+	cw.lock.Lock()
+	defer cw.lock.Unlock()
+	if cw.failed == 0 {
+		return cw.ResponseWriter.Write(b)
+	}
+
+	// Pass structured errors through, but wrap plain text ones.
+	event := b
+	if !json.Valid(b) {
+		var err error
+		event, err = json.Marshal(map[string]any{
+			"error": map[string]any{
+				"message": string(trimNewline(b)),
+				"code":    cw.failed,
+			},
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	if _, err := fmt.Fprintf(cw.ResponseWriter, "data: %s\n\n", trimNewline(event)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// trimNewline removes a trailing newline, as written by http.Error.
+func trimNewline(b []byte) []byte {
+	if len(b) > 0 && b[len(b)-1] == '\n' {
+		return b[:len(b)-1]
+	}
+	return b
+}
+
+// Unwrap returns the underlying response writer, so that it can be flushed
+// via http.ResponseController.
+func (cw *coldStartWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Flush implements http.Flusher.Flush.
+func (cw *coldStartWriter) Flush() {
+	cw.lock.Lock()
+	defer cw.lock.Unlock()
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
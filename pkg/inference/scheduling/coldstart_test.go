@@ -0,0 +1,99 @@
+package scheduling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+func TestLoadTracker(t *testing.T) {
+	tracker := newLoadTracker()
+	key := makeRunnerKey("llama.cpp", "model", "", inference.BackendModeCompletion)
+
+	if estimate, cold := tracker.coldStart("llama.cpp", "model", inference.BackendModeCompletion); !cold || estimate != 0 {
+		t.Errorf("expected cold start without estimate, got %s, %v", estimate, cold)
+	}
+
+	// Replicas and draft models are tracked as the same model.
+	replica := key
+	replica.replica = 1
+	replica.draftModelID = "draft"
+	tracker.recordLoad(key, 10*time.Second)
+	tracker.recordLoad(replica, 20*time.Second)
+	if _, cold := tracker.coldStart("llama.cpp", "model", inference.BackendModeCompletion); cold {
+		t.Error("expected loaded model not to require a cold start")
+	}
+	tracker.recordUnload(key)
+	if _, cold := tracker.coldStart("llama.cpp", "model", inference.BackendModeCompletion); cold {
+		t.Error("expected model with a loaded replica not to require a cold start")
+	}
+	tracker.recordUnload(replica)
+	if estimate, cold := tracker.coldStart("llama.cpp", "model", inference.BackendModeCompletion); !cold || estimate != 15*time.Second {
+		t.Errorf("expected cold start with 15s estimate, got %s, %v", estimate, cold)
+	}
+
+	// Other modes are tracked separately.
+	if estimate, _ := tracker.coldStart("llama.cpp", "model", inference.BackendModeEmbedding); estimate != 0 {
+		t.Errorf("expected no estimate for embedding mode, got %s", estimate)
+	}
+}
+
+func TestColdStartWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantBody string
+	}{
+		{
+			name:     "success",
+			status:   http.StatusOK,
+			body:     "data: {}\n\n",
+			wantBody: "data: {}\n\n",
+		},
+		{
+			name:     "plain error",
+			status:   http.StatusServiceUnavailable,
+			body:     "service unavailable\n",
+			wantBody: `data: {"error":{"code":503,"message":"service unavailable"}}` + "\n\n",
+		},
+		{
+			name:     "structured error",
+			status:   http.StatusBadRequest,
+			body:     `{"error":{"message":"bad"}}`,
+			wantBody: `data: {"error":{"message":"bad"}}` + "\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			cw := newColdStartWriter(recorder, "ai/smollm2", 30*time.Second, 1)
+			cw.WriteHeader(tt.status)
+			if _, err := cw.Write([]byte(tt.body)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if recorder.Code != http.StatusOK {
+				t.Errorf("expected committed status 200, got %d", recorder.Code)
+			}
+			if position := recorder.Header().Get(inference.QueuePositionHeader); position != "2" {
+				t.Errorf("expected queue position 2, got %q", position)
+			}
+			if estimate := recorder.Header().Get(inference.LoadEstimateHeader); estimate != "30" {
+				t.Errorf("expected load estimate 30, got %q", estimate)
+			}
+			progress, body, _ := strings.Cut(recorder.Body.String(), "\n\n")
+			if !strings.HasPrefix(progress, ": waiting for ai/smollm2 to load, about ") {
+				t.Errorf("unexpected progress comment %q", progress)
+			}
+			if body != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// untemplatedCompletionFields are the fields of completion requests that chat
+// completions don't have an equivalent of, along with their values that have
+// no effect. Completions that set any of them otherwise are sent with their
+// raw prompt.
+var untemplatedCompletionFields = map[string]string{"echo": "false", "suffix": `""`, "best_of": "1"}
+
+// templateCompletionRequest translates a completion request into a chat
+// completion request whose conversation is its prompt, as the message of a
+// user, so that the prompt is wrapped in the model's chat template. It
+// returns whether the request was translated: completions whose prompt isn't
+// a single string, and completions that set fields chat completions can't
+// honor, are returned as-is. Bodies that aren't JSON objects are left for the
+// backend to reject.
+func templateCompletionRequest(body []byte) ([]byte, bool, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return body, false, nil
+	}
+	for field, neutral := range untemplatedCompletionFields {
+		if raw := request[field]; !isNullField(raw) && string(raw) != neutral {
+			return body, false, nil
+		}
+	}
+	prompt, ok := completionPrompt(request["prompt"])
+	if !ok {
+		return body, false, nil
+	}
+
+	messages, err := json.Marshal([]map[string]string{{"role": "user", "content": prompt}})
+	if err != nil {
+		return nil, false, err
+	}
+	request["messages"] = messages
+	delete(request, "prompt")
+	for field := range untemplatedCompletionFields {
+		delete(request, field)
+	}
+	if raw := request["logprobs"]; !isNullField(raw) {
+		if err := checkTopLogprobs(raw, "logprobs"); err != nil {
+			return nil, false, err
+		}
+		request["logprobs"] = json.RawMessage("true")
+		request["top_logprobs"] = raw
+	}
+	if body, err = json.Marshal(request); err != nil {
+		return nil, false, fmt.Errorf("encoding request: %w", err)
+	}
+	return body, true, nil
+}
+
+// completionPrompt returns the prompt of a completion request if it's a single
+// string, either on its own or as the only element of an array.
+func completionPrompt(raw json.RawMessage) (string, bool) {
+	var prompt string
+	if err := json.Unmarshal(raw, &prompt); err == nil {
+		return prompt, true
+	}
+	var prompts []string
+	if err := json.Unmarshal(raw, &prompts); err == nil && len(prompts) == 1 {
+		return prompts[0], true
+	}
+	return "", false
+}
+
+// chatCompletionTranslator translates chat completion responses, or the
+// chunks of a streamed chat completion response, into completion responses,
+// for completions whose prompt was templated by templateCompletionRequest.
+type chatCompletionTranslator struct {
+	// logprobs translates the log probabilities of the responses into the
+	// format of completions.
+	logprobs *completionLogprobsTranslator
+}
+
+// newChatCompletionTranslator creates a translator of chat completion
+// responses into completion responses.
+func newChatCompletionTranslator() *chatCompletionTranslator {
+	return &chatCompletionTranslator{logprobs: newCompletionLogprobsTranslator()}
+}
+
+// translate translates a response or a chunk. Anything else, such as the end
+// of a stream, is returned as-is.
+func (t *chatCompletionTranslator) translate(data []byte, chunk bool) []byte {
+	var response map[string]any
+	if err := json.Unmarshal(data, &response); err != nil {
+		return data
+	}
+	choices, ok := response["choices"].([]any)
+	if !ok {
+		return data
+	}
+	for i, c := range choices {
+		choice, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		message, _ := choice["message"].(map[string]any)
+		if chunk {
+			message, _ = choice["delta"].(map[string]any)
+		}
+		text, _ := message["content"].(string)
+		choices[i] = map[string]any{
+			"index":         choice["index"],
+			"text":          text,
+			"logprobs":      choice["logprobs"],
+			"finish_reason": choice["finish_reason"],
+		}
+	}
+	response["object"] = "text_completion"
+	translated, err := json.Marshal(response)
+	if err != nil {
+		return data
+	}
+	return t.logprobs.translate(translated, chunk)
+}
@@ -0,0 +1,98 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTemplateCompletionRequest(t *testing.T) {
+	for _, tt := range []struct {
+		body      string
+		templated bool
+		expected  string
+		err       bool
+	}{
+		{
+			body:      `{"model":"m","prompt":"Hi","max_tokens":8}`,
+			templated: true,
+			expected:  `{"max_tokens":8,"messages":[{"content":"Hi","role":"user"}],"model":"m"}`,
+		},
+		{
+			body:      `{"model":"m","prompt":["Hi"],"logprobs":2,"echo":false}`,
+			templated: true,
+			expected:  `{"logprobs":true,"messages":[{"content":"Hi","role":"user"}],"model":"m","top_logprobs":2}`,
+		},
+		{body: `{"model":"m","prompt":["Hi","Hey"]}`},
+		{body: `{"model":"m","prompt":[1,2,3]}`},
+		{body: `{"model":"m","prompt":"Hi","echo":true}`},
+		{body: `{"model":"m","prompt":"Hi","suffix":"!"}`},
+		{body: `not json`},
+		{body: `{"model":"m","prompt":"Hi","logprobs":21}`, err: true},
+	} {
+		body, templated, err := templateCompletionRequest([]byte(tt.body))
+		if (err != nil) != tt.err {
+			t.Errorf("Unexpected error for %s: %v", tt.body, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if templated != tt.templated {
+			t.Errorf("Expected %s to be templated: %t, got %t", tt.body, tt.templated, templated)
+		}
+		expected := tt.expected
+		if !tt.templated {
+			expected = tt.body
+		}
+		if string(body) != expected {
+			t.Errorf("Expected %s, got %s", expected, body)
+		}
+	}
+}
+
+func TestChatCompletionTranslator(t *testing.T) {
+	translated := newChatCompletionTranslator().translate([]byte(`{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"logprobs":{"content":[{"token":"Hello","logprob":-0.5,"top_logprobs":[{"token":"Hello","logprob":-0.5}]}]},"finish_reason":"stop"}],"usage":{"completion_tokens":1}}`), false)
+	expected := `{"choices":[{"finish_reason":"stop","index":0,"logprobs":{"tokens":["Hello"],"token_logprobs":[-0.5],"top_logprobs":[{"Hello":-0.5}],"text_offset":[0]},"text":"Hello"}],"object":"text_completion","usage":{"completion_tokens":1}}`
+	if string(translated) != expected {
+		t.Errorf("Expected %s, got %s", expected, translated)
+	}
+
+	// Chunks of streams are translated from their deltas.
+	recorder := httptest.NewRecorder()
+	w := &chatResponseWriter{ResponseWriter: recorder, translate: newChatCompletionTranslator().translate}
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprint(w, `data: {"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant"}}]}`+"\n\n")
+	fmt.Fprint(w, `data: {"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}`+"\n\n")
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	w.finish()
+
+	var text strings.Builder
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Object  string `json:"object"`
+			Choices []struct {
+				Text string `json:"text"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		if chunk.Object != "text_completion" {
+			t.Errorf("Expected a completion chunk, got %s", data)
+		}
+		text.WriteString(chunk.Choices[0].Text)
+	}
+	if text.String() != "Hi" {
+		t.Errorf("Expected text Hi, got %q", text.String())
+	}
+	if !strings.HasSuffix(recorder.Body.String(), "data: [DONE]\n\n") {
+		t.Errorf("Expected the stream to end, got %s", recorder.Body.String())
+	}
+}
@@ -0,0 +1,76 @@
+package scheduling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// stainlessTimeoutHeader is the header used by the OpenAI SDKs to advertise
+// their request timeout in seconds.
+const stainlessTimeoutHeader = "X-Stainless-Timeout"
+
+// requestDeadline determines the deadline of a request from its headers. If
+// several headers are set, the earliest deadline applies. It returns false if
+// no deadline is set.
+func requestDeadline(header http.Header, now time.Time) (time.Time, bool, error) {
+	var deadline time.Time
+	set := func(d time.Time) {
+		if deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+
+	if value := header.Get(inference.RequestDeadlineHeader); value != "" {
+		d, err := parseDeadline(value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s header: %w", inference.RequestDeadlineHeader, err)
+		}
+		set(d)
+	}
+	for _, name := range []string{inference.RequestTimeoutHeader, stainlessTimeoutHeader} {
+		if value := header.Get(name); value != "" {
+			timeout, err := parseTimeout(value)
+			if err != nil {
+				return time.Time{}, false, fmt.Errorf("invalid %s header: %w", name, err)
+			}
+			set(now.Add(timeout))
+		}
+	}
+
+	return deadline, !deadline.IsZero(), nil
+}
+
+// parseDeadline parses an RFC 3339 timestamp or Unix seconds.
+func parseDeadline(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// parseTimeout parses a duration or a number of seconds.
+func parseTimeout(value string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, floatErr := strconv.ParseFloat(value, 64)
+		if floatErr != nil {
+			return 0, err
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	if timeout <= 0 {
+		return 0, errors.New("timeout must be positive")
+	}
+	return timeout, nil
+}
+
+// deadlineExceeded returns true if ctx ended because its deadline passed.
+func deadlineExceeded(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
@@ -0,0 +1,89 @@
+package scheduling
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRequestDeadline(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected time.Time
+		ok       bool
+		wantErr  bool
+	}{
+		{name: "no deadline"},
+		{
+			name:     "RFC 3339 deadline",
+			headers:  map[string]string{"X-Request-Deadline": "2025-01-01T12:00:30Z"},
+			expected: now.Add(30 * time.Second),
+			ok:       true,
+		},
+		{
+			name:     "Unix deadline",
+			headers:  map[string]string{"X-Request-Deadline": "1735732830"},
+			expected: now.Add(30 * time.Second),
+			ok:       true,
+		},
+		{
+			name:     "duration timeout",
+			headers:  map[string]string{"X-Request-Timeout": "1m"},
+			expected: now.Add(time.Minute),
+			ok:       true,
+		},
+		{
+			name:     "OpenAI SDK timeout",
+			headers:  map[string]string{"X-Stainless-Timeout": "600"},
+			expected: now.Add(10 * time.Minute),
+			ok:       true,
+		},
+		{
+			name: "earliest wins",
+			headers: map[string]string{
+				"X-Request-Deadline":  "2025-01-01T12:00:30Z",
+				"X-Stainless-Timeout": "10",
+			},
+			expected: now.Add(10 * time.Second),
+			ok:       true,
+		},
+		{
+			name:    "invalid deadline",
+			headers: map[string]string{"X-Request-Deadline": "tomorrow"},
+			wantErr: true,
+		},
+		{
+			name:    "negative timeout",
+			headers: map[string]string{"X-Request-Timeout": "-5"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+			deadline, ok, err := requestDeadline(header, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.ok {
+				t.Fatalf("expected ok=%v, got %v", tt.ok, ok)
+			}
+			if !deadline.Equal(tt.expected) {
+				t.Errorf("expected deadline %v, got %v", tt.expected, deadline)
+			}
+		})
+	}
+}
@@ -0,0 +1,514 @@
+package scheduling
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// DefaultEmbeddingBatchSize is the default maximum number of inputs in the
+// batches of embedding requests sent to backends, which matches the default
+// maximum client batch size of text-embeddings-inference.
+const DefaultEmbeddingBatchSize = 32
+
+// errInvalidEmbeddingsInput is returned for embeddings requests whose input
+// has an unsupported type.
+var errInvalidEmbeddingsInput = errors.New("input must be a string, an array of token IDs, or an array of strings or token ID arrays")
+
+// embeddingsRequest is an OpenAI embeddings request, whose inputs may be
+// embedded in a batch along with those of other requests.
+type embeddingsRequest struct {
+	// fields are the fields of the request that are passed to the backend,
+	// which exclude its input, encoding format, and dimensions.
+	fields map[string]json.RawMessage
+	// key identifies the requests whose inputs can be embedded together,
+	// which are those with the same fields.
+	key string
+	// inputs are the inputs of the request, each a string or an array of
+	// token IDs.
+	inputs []json.RawMessage
+	// weight approximates the size of the inputs, to apportion the token
+	// usage of batches between their requests.
+	weight int
+	// base64 indicates whether embeddings are returned as base64-encoded
+	// arrays of little-endian float32 values rather than arrays of numbers.
+	base64 bool
+	// dimensions is the number of dimensions that embeddings are truncated
+	// to, or 0 to keep all of them.
+	dimensions int
+}
+
+// parseEmbeddingsRequest parses an OpenAI embeddings request body.
+func parseEmbeddingsRequest(body []byte) (*embeddingsRequest, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, errors.New("invalid request")
+	}
+	request := &embeddingsRequest{fields: fields}
+
+	if raw, ok := fields["encoding_format"]; ok {
+		var format string
+		if err := json.Unmarshal(raw, &format); err != nil {
+			return nil, errors.New("encoding_format must be a string")
+		}
+		switch format {
+		case "", "float":
+		case "base64":
+			request.base64 = true
+		default:
+			return nil, fmt.Errorf("invalid encoding_format %q (expected float or base64)", format)
+		}
+		delete(fields, "encoding_format")
+	}
+	if raw, ok := fields["dimensions"]; ok {
+		if string(raw) != "null" {
+			if err := json.Unmarshal(raw, &request.dimensions); err != nil || request.dimensions <= 0 {
+				return nil, errors.New("dimensions must be a positive integer")
+			}
+		}
+		delete(fields, "dimensions")
+	}
+
+	var err error
+	if request.inputs, request.weight, err = parseEmbeddingsInput(fields["input"]); err != nil {
+		return nil, err
+	}
+	delete(fields, "input")
+
+	// Maps are marshaled with sorted keys, so requests with the same fields
+	// have the same key.
+	key, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	request.key = string(key)
+	return request, nil
+}
+
+// parseEmbeddingsInput splits the input of an embeddings request into
+// individual inputs, and returns them along with their total weight, which is
+// their length in bytes or tokens.
+func parseEmbeddingsInput(raw json.RawMessage) ([]json.RawMessage, int, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, 0, errors.New("input is required")
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		if text == "" {
+			return nil, 0, errors.New("input must not be empty")
+		}
+		return []json.RawMessage{raw}, len(text), nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, 0, errInvalidEmbeddingsInput
+	}
+	if len(items) == 0 {
+		return nil, 0, errors.New("input must not be empty")
+	}
+	// An array of token IDs is a single input.
+	var tokens []int64
+	if err := json.Unmarshal(raw, &tokens); err == nil {
+		return []json.RawMessage{raw}, len(tokens), nil
+	}
+	weight := 0
+	for _, item := range items {
+		var text string
+		if err := json.Unmarshal(item, &text); err == nil {
+			if text == "" {
+				return nil, 0, errors.New("input must not contain empty strings")
+			}
+			weight += len(text)
+			continue
+		}
+		var tokens []int64
+		if err := json.Unmarshal(item, &tokens); err != nil {
+			return nil, 0, errInvalidEmbeddingsInput
+		}
+		if len(tokens) == 0 {
+			return nil, 0, errors.New("input must not contain empty token arrays")
+		}
+		weight += len(tokens)
+	}
+	return items, weight, nil
+}
+
+// body returns the body of a request for the embeddings of inputs, with the
+// fields of the request.
+func (r *embeddingsRequest) body(inputs []json.RawMessage) ([]byte, error) {
+	fields := maps.Clone(r.fields)
+	input, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, err
+	}
+	fields["input"] = input
+	return json.Marshal(fields)
+}
+
+// embeddingsResponse is an OpenAI embeddings response.
+type embeddingsResponse struct {
+	Object string           `json:"object"`
+	Data   []embeddingsData `json:"data"`
+	Model  string           `json:"model"`
+	Usage  embeddingsUsage  `json:"usage"`
+}
+
+// embeddingsData is the embedding of an input in an embeddings response. The
+// embedding is kept as-is unless it's truncated or encoded, since backends
+// may return an embedding per token if they don't pool them.
+type embeddingsData struct {
+	Object    string          `json:"object"`
+	Embedding json.RawMessage `json:"embedding"`
+	Index     int             `json:"index"`
+}
+
+// embeddingsUsage is the token usage of an embeddings response.
+type embeddingsUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// response returns the response to the request, given the embeddings of its
+// inputs and its share of the token usage of its batch.
+func (r *embeddingsRequest) response(model string, data []embeddingsData, usage embeddingsUsage) bufferedResponse {
+	for i := range data {
+		data[i].Object = "embedding"
+		data[i].Index = i
+		if !r.base64 && r.dimensions == 0 {
+			continue
+		}
+		embedding, err := r.format(data[i].Embedding)
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, err.Error())
+		}
+		data[i].Embedding = embedding
+	}
+	body, err := json.Marshal(embeddingsResponse{Object: "list", Data: data, Model: model, Usage: usage})
+	if err != nil {
+		return errorResponse(http.StatusInternalServerError, "failed to encode response")
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	return bufferedResponse{status: http.StatusOK, header: header, body: body}
+}
+
+// format truncates an embedding to the requested dimensions and encodes it in
+// the requested format.
+func (r *embeddingsRequest) format(raw json.RawMessage) (json.RawMessage, error) {
+	var embedding []float64
+	if err := json.Unmarshal(raw, &embedding); err != nil {
+		return nil, errors.New("dimensions and base64 encoding require pooled embeddings")
+	}
+	if r.dimensions > 0 {
+		if r.dimensions > len(embedding) {
+			return nil, fmt.Errorf("the model produces %d-dimensional embeddings, but %d dimensions were requested",
+				len(embedding), r.dimensions)
+		}
+		embedding = truncateEmbedding(embedding, r.dimensions)
+	}
+	if r.base64 {
+		return json.Marshal(encodeEmbedding(embedding))
+	}
+	return json.Marshal(embedding)
+}
+
+// truncateEmbedding truncates an embedding to its first dimensions, as models
+// trained with Matryoshka representation learning allow. Embeddings that were
+// normalized are normalized again.
+func truncateEmbedding(embedding []float64, dimensions int) []float64 {
+	normalized := math.Abs(l2Norm(embedding)-1) < 1e-3
+	truncated := slices.Clone(embedding[:dimensions])
+	if norm := l2Norm(truncated); normalized && norm > 0 {
+		for i := range truncated {
+			truncated[i] /= norm
+		}
+	}
+	return truncated
+}
+
+// l2Norm returns the Euclidean norm of a vector.
+func l2Norm(v []float64) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
+
+// encodeEmbedding encodes an embedding as base64-encoded little-endian
+// float32 values, as the OpenAI API does.
+func encodeEmbedding(embedding []float64) string {
+	buf := make([]byte, 4*len(embedding))
+	for i, x := range embedding {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(x)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// embeddingCall is an embeddings request waiting for the embeddings of its
+// inputs.
+type embeddingCall struct {
+	// request is the embeddings request.
+	request *embeddingsRequest
+	// serve sends a request body to the runner of the request.
+	serve func(ctx context.Context, body []byte) bufferedResponse
+	// done is closed once result is set.
+	done chan struct{}
+	// result is the response to the request.
+	result bufferedResponse
+}
+
+// finish sets the response to the call.
+func (c *embeddingCall) finish(result bufferedResponse) {
+	c.result = result
+	close(c.done)
+}
+
+// embeddingBatch is a batch of embeddings requests whose inputs are embedded
+// by a single backend request.
+type embeddingBatch struct {
+	// calls are the requests in the batch.
+	calls []*embeddingCall
+	// inputs is the total number of inputs of the requests.
+	inputs int
+	// waiting is the number of requests whose clients are still waiting. It's
+	// guarded by the batcher's mutex.
+	waiting int
+	// ctx is the context of the backend request, which is cancelled once no
+	// client is waiting.
+	ctx context.Context
+	// cancel cancels ctx.
+	cancel context.CancelFunc
+}
+
+// embeddingBatchKey identifies the embeddings requests that can be batched
+// together, which are those served by the same runner with the same fields.
+type embeddingBatchKey struct {
+	runner *runner
+	fields string
+}
+
+// embeddingQueue tracks the batches of requests with the same key.
+type embeddingQueue struct {
+	// inFlight is the number of batches being embedded.
+	inFlight int
+	// pending is the batch collecting requests until it's sent, if any.
+	pending *embeddingBatch
+}
+
+// embeddingBatcher coalesces concurrent embeddings requests for the same
+// runner into batches, for throughput. A request that arrives while no batch
+// is being embedded is sent right away; requests that arrive while one is
+// being embedded are collected into the next batch, which is sent once the
+// batch in flight completes or it's full.
+type embeddingBatcher struct {
+	// mu guards the fields below.
+	mu sync.Mutex
+	// size is the maximum number of inputs in a batch. Requests with more
+	// inputs are sent on their own.
+	size int
+	// queues maps batch keys to their queues.
+	queues map[embeddingBatchKey]*embeddingQueue
+}
+
+// newEmbeddingBatcher creates a new embedding batcher.
+func newEmbeddingBatcher() *embeddingBatcher {
+	return &embeddingBatcher{
+		size:   DefaultEmbeddingBatchSize,
+		queues: make(map[embeddingBatchKey]*embeddingQueue),
+	}
+}
+
+// setSize sets the maximum number of inputs in a batch. Sizes below 2 disable
+// batching.
+func (b *embeddingBatcher) setSize(size int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.size = size
+}
+
+// embed embeds the inputs of a request served by a runner, in a batch with
+// those of other requests, and returns the response to the request. It
+// returns the cause of ctx if ctx is done first.
+func (b *embeddingBatcher) embed(ctx context.Context, runner *runner, request *embeddingsRequest, serve func(ctx context.Context, body []byte) bufferedResponse) (bufferedResponse, error) {
+	call := &embeddingCall{request: request, serve: serve, done: make(chan struct{})}
+	batch := b.enqueue(embeddingBatchKey{runner: runner, fields: request.key}, call)
+	select {
+	case <-call.done:
+		return call.result, nil
+	case <-ctx.Done():
+		b.abandon(batch)
+		return bufferedResponse{}, context.Cause(ctx)
+	}
+}
+
+// enqueue adds a call to the pending batch of its key, sending the batch if
+// no batch is in flight or it's full, and returns the batch.
+func (b *embeddingBatcher) enqueue(key embeddingBatchKey, call *embeddingCall) *embeddingBatch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queues[key]
+	if q == nil {
+		q = &embeddingQueue{}
+		b.queues[key] = q
+	}
+	if q.pending != nil && q.pending.inputs+len(call.request.inputs) > b.size {
+		b.dispatch(key, q, q.pending)
+		q.pending = nil
+	}
+	if q.pending == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		q.pending = &embeddingBatch{ctx: ctx, cancel: cancel}
+	}
+	batch := q.pending
+	batch.calls = append(batch.calls, call)
+	batch.inputs += len(call.request.inputs)
+	batch.waiting++
+	if q.inFlight == 0 || batch.inputs >= b.size {
+		b.dispatch(key, q, batch)
+		q.pending = nil
+	}
+	return batch
+}
+
+// abandon records that the client of a call in a batch stopped waiting, and
+// cancels the batch if no client is waiting anymore.
+func (b *embeddingBatcher) abandon(batch *embeddingBatch) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	batch.waiting--
+	if batch.waiting == 0 {
+		batch.cancel()
+	}
+}
+
+// dispatch sends a batch in the background, then the next pending batch of
+// its key once it completes. The caller must hold the batcher's mutex.
+func (b *embeddingBatcher) dispatch(key embeddingBatchKey, q *embeddingQueue, batch *embeddingBatch) {
+	q.inFlight++
+	go func() {
+		runEmbeddingBatch(batch)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		q.inFlight--
+		if q.pending != nil {
+			b.dispatch(key, q, q.pending)
+			q.pending = nil
+		} else if q.inFlight == 0 {
+			delete(b.queues, key)
+		}
+	}()
+}
+
+// runEmbeddingBatch embeds the inputs of a batch and finishes its calls. If the batch
+// fails, its requests are retried on their own, so that a bad input only
+// fails the request that it belongs to.
+func runEmbeddingBatch(batch *embeddingBatch) {
+	defer batch.cancel()
+	first := batch.calls[0]
+	var inputs []json.RawMessage
+	for _, call := range batch.calls {
+		inputs = append(inputs, call.request.inputs...)
+	}
+	body, err := first.request.body(inputs)
+	if err != nil {
+		for _, call := range batch.calls {
+			call.finish(errorResponse(http.StatusInternalServerError, "failed to encode embeddings request"))
+		}
+		return
+	}
+	result := first.serve(batch.ctx, body)
+	if splitEmbeddings(batch.calls, result) {
+		return
+	}
+	if len(batch.calls) == 1 {
+		first.finish(embeddingFailure(result))
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, call := range batch.calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, err := call.request.body(call.request.inputs)
+			if err != nil {
+				call.finish(errorResponse(http.StatusInternalServerError, "failed to encode embeddings request"))
+				return
+			}
+			result := call.serve(batch.ctx, body)
+			if !splitEmbeddings([]*embeddingCall{call}, result) {
+				call.finish(embeddingFailure(result))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// splitEmbeddings splits a successful response to a batch between its calls and
+// finishes them. It reports whether the response could be split.
+func splitEmbeddings(calls []*embeddingCall, result bufferedResponse) bool {
+	if result.status != http.StatusOK {
+		return false
+	}
+	var response embeddingsResponse
+	if err := json.Unmarshal(result.body, &response); err != nil {
+		return false
+	}
+	inputs, weight := 0, 0
+	for _, call := range calls {
+		inputs += len(call.request.inputs)
+		weight += call.request.weight
+	}
+	if len(response.Data) != inputs {
+		return false
+	}
+	slices.SortFunc(response.Data, func(a, b embeddingsData) int {
+		return a.Index - b.Index
+	})
+
+	offset := 0
+	remaining := response.Usage
+	for i, call := range calls {
+		n := len(call.request.inputs)
+		usage := remaining
+		if i < len(calls)-1 {
+			usage = embeddingsUsage{
+				PromptTokens: usageShare(response.Usage.PromptTokens, call.request.weight, weight),
+				TotalTokens:  usageShare(response.Usage.TotalTokens, call.request.weight, weight),
+			}
+			remaining.PromptTokens -= usage.PromptTokens
+			remaining.TotalTokens -= usage.TotalTokens
+		}
+		data := slices.Clone(response.Data[offset : offset+n])
+		call.finish(call.request.response(response.Model, data, usage))
+		offset += n
+	}
+	return true
+}
+
+// usageShare returns the share of a total in proportion to weight out of total
+// weight, rounded down.
+func usageShare(total, weight, totalWeight int) int {
+	if totalWeight == 0 {
+		return 0
+	}
+	return int(int64(total) * int64(weight) / int64(totalWeight))
+}
+
+// embeddingFailure returns the response to a request whose batch failed. Successful
+// responses that couldn't be split are invalid.
+func embeddingFailure(result bufferedResponse) bufferedResponse {
+	if result.status == http.StatusOK {
+		return errorResponse(http.StatusBadGateway, "invalid embeddings response from backend")
+	}
+	return result
+}
@@ -0,0 +1,290 @@
+package scheduling
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseEmbeddingsRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		inputs int
+		err    bool
+	}{
+		{name: "string", body: `{"model":"m","input":"hello"}`, inputs: 1},
+		{name: "strings", body: `{"model":"m","input":["hello","world"]}`, inputs: 2},
+		{name: "tokens", body: `{"model":"m","input":[1,2,3]}`, inputs: 1},
+		{name: "token arrays", body: `{"model":"m","input":[[1,2],[3]]}`, inputs: 2},
+		{name: "base64", body: `{"model":"m","input":"hello","encoding_format":"base64","dimensions":2}`, inputs: 1},
+		{name: "missing input", body: `{"model":"m"}`, err: true},
+		{name: "empty string", body: `{"model":"m","input":""}`, err: true},
+		{name: "empty array", body: `{"model":"m","input":[]}`, err: true},
+		{name: "empty string in array", body: `{"model":"m","input":["hello",""]}`, err: true},
+		{name: "invalid input", body: `{"model":"m","input":[{"text":"hello"}]}`, err: true},
+		{name: "invalid encoding format", body: `{"model":"m","input":"hello","encoding_format":"int8"}`, err: true},
+		{name: "invalid dimensions", body: `{"model":"m","input":"hello","dimensions":0}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := parseEmbeddingsRequest([]byte(tt.body))
+			if tt.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(request.inputs) != tt.inputs {
+				t.Errorf("Expected %d inputs, got %d", tt.inputs, len(request.inputs))
+			}
+			for _, field := range []string{"input", "encoding_format", "dimensions"} {
+				if _, ok := request.fields[field]; ok {
+					t.Errorf("Expected %s to be removed from the fields passed to the backend", field)
+				}
+			}
+		})
+	}
+
+	a, _ := parseEmbeddingsRequest([]byte(`{"model":"m","user":"u","input":"a"}`))
+	b, _ := parseEmbeddingsRequest([]byte(`{"user":"u","input":["b"],"model":"m","encoding_format":"base64"}`))
+	if a.key != b.key {
+		t.Errorf("Expected requests with the same fields to have the same key, got %s and %s", a.key, b.key)
+	}
+}
+
+func TestEmbeddingsResponseFormat(t *testing.T) {
+	request, err := parseEmbeddingsRequest([]byte(`{"model":"m","input":"hello","encoding_format":"base64","dimensions":2}`))
+	if err != nil {
+		t.Fatalf("Failed to parse request: %v", err)
+	}
+	// A normalized embedding, which is normalized again once truncated.
+	data := []embeddingsData{{Embedding: json.RawMessage(`[0.6, 0, 0.8]`)}}
+	result := request.response("m", data, embeddingsUsage{PromptTokens: 1, TotalTokens: 1})
+	if result.status != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", result.status, result.body)
+	}
+	var response struct {
+		Data []struct {
+			Embedding string `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(result.body, &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(response.Data[0].Embedding)
+	if err != nil || len(raw) != 8 {
+		t.Fatalf("Expected 2 base64-encoded float32 values, got %q (%v)", response.Data[0].Embedding, err)
+	}
+	x := math.Float32frombits(binary.LittleEndian.Uint32(raw[0:]))
+	y := math.Float32frombits(binary.LittleEndian.Uint32(raw[4:]))
+	if x != 1 || y != 0 {
+		t.Errorf("Expected the truncated embedding to be normalized again, got [%v, %v]", x, y)
+	}
+
+	request.dimensions = 4
+	if result := request.response("m", data, embeddingsUsage{}); result.status != http.StatusBadRequest {
+		t.Errorf("Expected more dimensions than the model produces to be rejected, got status %d", result.status)
+	}
+}
+
+// fakeEmbeddings serves embeddings requests whose embedding is the length of
+// each string input, failing requests with an input of "bad".
+type fakeEmbeddings struct {
+	mu sync.Mutex
+	// batches are the inputs of the requests served.
+	batches [][]string
+	// block, if set, blocks requests until it's closed.
+	block chan struct{}
+}
+
+func (f *fakeEmbeddings) serve(_ context.Context, body []byte) bufferedResponse {
+	var request struct {
+		Input []string `json:"input"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+	f.mu.Lock()
+	f.batches = append(f.batches, request.Input)
+	block := f.block
+	f.mu.Unlock()
+	if block != nil {
+		<-block
+	}
+
+	response := embeddingsResponse{Object: "list", Model: "m"}
+	for i, input := range request.Input {
+		if input == "bad" {
+			return errorResponse(http.StatusBadRequest, "input is too long")
+		}
+		embedding, _ := json.Marshal([]float64{float64(len(input))})
+		// Respond out of order, which the batcher must handle.
+		response.Data = append([]embeddingsData{{Object: "embedding", Embedding: embedding, Index: i}}, response.Data...)
+		response.Usage.PromptTokens += len(input)
+		response.Usage.TotalTokens += len(input)
+	}
+	body, _ = json.Marshal(response)
+	return bufferedResponse{status: http.StatusOK, header: http.Header{}, body: body}
+}
+
+func (f *fakeEmbeddings) served() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.batches
+}
+
+// embedResult embeds a request body with a batcher and decodes the response.
+func embedResult(t *testing.T, b *embeddingBatcher, r *runner, backend *fakeEmbeddings, body string) (int, []float64, int) {
+	request, err := parseEmbeddingsRequest([]byte(body))
+	if err != nil {
+		t.Errorf("Failed to parse request: %v", err)
+		return 0, nil, 0
+	}
+	result, err := b.embed(context.Background(), r, request, backend.serve)
+	if err != nil {
+		t.Errorf("Failed to embed: %v", err)
+		return 0, nil, 0
+	}
+	if result.status != http.StatusOK {
+		return result.status, nil, 0
+	}
+	var response struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+			Index     int       `json:"index"`
+		} `json:"data"`
+		Usage embeddingsUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(result.body, &response); err != nil {
+		t.Errorf("Failed to decode response: %v", err)
+		return 0, nil, 0
+	}
+	var embeddings []float64
+	for i, data := range response.Data {
+		if data.Index != i {
+			t.Errorf("Expected index %d, got %d", i, data.Index)
+		}
+		embeddings = append(embeddings, data.Embedding...)
+	}
+	return result.status, embeddings, response.Usage.PromptTokens
+}
+
+func TestEmbeddingBatcher(t *testing.T) {
+	b := newEmbeddingBatcher()
+	r := &runner{}
+	backend := &fakeEmbeddings{block: make(chan struct{})}
+
+	// The first request is sent right away, and the next ones wait for it.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		embedResult(t, b, r, backend, `{"model":"m","input":"a"}`)
+	}()
+	waitForBatches(t, backend, 1)
+
+	inputs := []string{`"bb"`, `["ccc","dddd"]`, `"bad"`}
+	expected := [][]float64{{2}, {3, 4}, nil}
+	for i, input := range inputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, embeddings, tokens := embedResult(t, b, r, backend, `{"model":"m","input":`+input+`}`)
+			if expected[i] == nil {
+				if status != http.StatusBadRequest {
+					t.Errorf("Expected the bad request to fail, got status %d", status)
+				}
+				return
+			}
+			if status != http.StatusOK || len(embeddings) != len(expected[i]) {
+				t.Errorf("Unexpected response to %s: status %d, embeddings %v", input, status, embeddings)
+				return
+			}
+			for j := range embeddings {
+				if embeddings[j] != expected[i][j] {
+					t.Errorf("Expected embeddings %v for %s, got %v", expected[i], input, embeddings)
+				}
+			}
+			if tokens <= 0 {
+				t.Errorf("Expected a share of the token usage for %s, got %d", input, tokens)
+			}
+		}()
+	}
+	waitForPending(t, b, 4)
+	close(backend.block)
+	wg.Wait()
+
+	// The three requests were batched together, then retried on their own
+	// once the bad input failed the batch.
+	batches := backend.served()
+	if len(batches) != 5 || len(batches[1]) != 4 {
+		t.Fatalf("Expected a batch of 4 inputs and 3 retries, got %v", batches)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.mu.Lock()
+		queues := len(b.queues)
+		b.mu.Unlock()
+		if queues == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the queues to be removed once idle, got %d", queues)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEmbeddingBatcherSize(t *testing.T) {
+	b := newEmbeddingBatcher()
+	b.setSize(0)
+	backend := &fakeEmbeddings{}
+	if status, embeddings, _ := embedResult(t, b, &runner{}, backend, `{"model":"m","input":["a","bb"]}`); status != http.StatusOK || len(embeddings) != 2 {
+		t.Fatalf("Unexpected response: status %d, embeddings %v", status, embeddings)
+	}
+}
+
+// waitForBatches waits until the backend has received n batches.
+func waitForBatches(t *testing.T, backend *fakeEmbeddings, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(backend.served()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d batches", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitForPending waits until n inputs are pending in the batcher.
+func waitForPending(t *testing.T, b *embeddingBatcher, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.mu.Lock()
+		pending := 0
+		for _, q := range b.queues {
+			if q.pending != nil {
+				pending += q.pending.inputs
+			}
+		}
+		b.mu.Unlock()
+		if pending == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d pending inputs", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -8,3 +8,12 @@ import (
 // returned in conjunction with an HTTP request, it should be paired with a
 // 404 response status.
 var ErrBackendNotFound = errors.New("backend not found")
+
+// errDraftModelUnavailable indicates that the draft model referenced by a
+// speculative decoding configuration couldn't be found or pulled.
+var errDraftModelUnavailable = errors.New("draft model unavailable")
+
+// ErrDeadlineExceeded indicates that an inference request couldn't complete
+// before the deadline set by the client. If returned in conjunction with an
+// HTTP request, it should be paired with a 504 response status.
+var ErrDeadlineExceeded = errors.New("request deadline exceeded")
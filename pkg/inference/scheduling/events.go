@@ -0,0 +1,158 @@
+package scheduling
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// maximumEvents is the number of scheduler events retained for querying.
+const maximumEvents = 1000
+
+// SchedulerEventType is the type of a scheduler decision.
+type SchedulerEventType string
+
+const (
+	// EventLoad records that a runner was loaded.
+	EventLoad SchedulerEventType = "load"
+	// EventLoadFailed records that a runner failed to load.
+	EventLoadFailed SchedulerEventType = "load_failed"
+	// EventPlace records the GPUs that a runner was placed on.
+	EventPlace SchedulerEventType = "place"
+	// EventEvict records that the scheduler unloaded a runner on its own
+	// accord, e.g. because it was idle or to make room for another runner.
+	EventEvict SchedulerEventType = "evict"
+	// EventUnload records that a runner was unloaded on request.
+	EventUnload SchedulerEventType = "unload"
+	// EventCrash records that a runner crashed or was killed because it
+	// stopped responding.
+	EventCrash SchedulerEventType = "crash"
+)
+
+// SchedulerEvent records a scheduler decision.
+type SchedulerEvent struct {
+	// Time is when the decision was made.
+	Time time.Time `json:"time"`
+	// Type is the type of decision.
+	Type SchedulerEventType `json:"type"`
+	// Backend is the name of the runner's backend.
+	Backend string `json:"backend"`
+	// Model is the model reference used to load the runner.
+	Model string `json:"model"`
+	// ModelID is the ID of the runner's model.
+	ModelID string `json:"model_id"`
+	// Mode is the runner's operation mode.
+	Mode string `json:"mode"`
+	// Replica is the index of the runner among the model's replicas.
+	Replica int `json:"replica,omitempty"`
+	// Reason explains the decision.
+	Reason string `json:"reason,omitempty"`
+	// RAM is the RAM allocated to the runner in bytes.
+	RAM uint64 `json:"ram,omitempty"`
+	// VRAM is the VRAM allocated to the runner in bytes.
+	VRAM uint64 `json:"vram,omitempty"`
+	// AvailableRAM is the RAM available to other runners after the decision.
+	AvailableRAM uint64 `json:"available_ram"`
+	// AvailableVRAM is the VRAM available to other runners after the
+	// decision.
+	AvailableVRAM uint64 `json:"available_vram"`
+	// Devices are the GPUs assigned to the runner, if any.
+	Devices []int `json:"devices,omitempty"`
+}
+
+// eventLog retains the most recent scheduler events.
+type eventLog struct {
+	// lock guards events.
+	lock sync.Mutex
+	// events are the retained events, oldest first.
+	events []SchedulerEvent
+}
+
+// record records an event, discarding the oldest event if the log is full.
+func (e *eventLog) record(event SchedulerEvent) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if len(e.events) == maximumEvents {
+		e.events = append(e.events[:0], e.events[1:]...)
+	}
+	e.events = append(e.events, event)
+}
+
+// EventFilter selects scheduler events.
+type EventFilter struct {
+	// Model selects the events of a model, by reference or ID.
+	Model string
+	// Type selects events of a type.
+	Type SchedulerEventType
+	// Since selects events after a time.
+	Since time.Time
+	// Limit selects at most this many of the most recent events.
+	Limit int
+}
+
+// parseEventFilter parses an event filter from query parameters.
+func parseEventFilter(query url.Values) (EventFilter, error) {
+	filter := EventFilter{
+		Model: query.Get("model"),
+		Type:  SchedulerEventType(query.Get("type")),
+	}
+	var err error
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = parseDeadline(since); err != nil {
+			return EventFilter{}, fmt.Errorf("invalid since %q (expected an RFC 3339 timestamp or Unix seconds)", since)
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			return EventFilter{}, fmt.Errorf("invalid limit %q", limit)
+		}
+	}
+	return filter, nil
+}
+
+// query returns the events selected by the filter, oldest first.
+func (e *eventLog) query(filter EventFilter) []SchedulerEvent {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	result := make([]SchedulerEvent, 0, len(e.events))
+	for _, event := range e.events {
+		if filter.Model != "" && event.Model != filter.Model && event.ModelID != filter.Model {
+			continue
+		}
+		if filter.Type != "" && event.Type != filter.Type {
+			continue
+		}
+		if !filter.Since.IsZero() && !event.Time.After(filter.Since) {
+			continue
+		}
+		result = append(result, event)
+	}
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[len(result)-filter.Limit:]
+	}
+	return result
+}
+
+// recordEvent records a decision about a runner with the specified memory
+// allocation and GPUs. The caller must hold the loader lock.
+func (l *loader) recordEvent(eventType SchedulerEventType, key runnerKey, modelRef string, allocation inference.RequiredMemory, devices []int, reason string) {
+	l.events.record(SchedulerEvent{
+		Time:          time.Now(),
+		Type:          eventType,
+		Backend:       key.backend,
+		Model:         modelRef,
+		ModelID:       key.modelID,
+		Mode:          key.mode.String(),
+		Replica:       key.replica,
+		Reason:        reason,
+		AvailableRAM:  l.availableMemory.RAM,
+		AvailableVRAM: l.availableMemory.VRAM,
+		RAM:           allocation.RAM,
+		VRAM:          allocation.VRAM,
+		Devices:       devices,
+	})
+}
@@ -0,0 +1,74 @@
+package scheduling
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestEventLogQuery(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	var log eventLog
+	for i, event := range []SchedulerEvent{
+		{Type: EventLoad, Model: "ai/smollm2", ModelID: "sha256:a"},
+		{Type: EventLoad, Model: "ai/gemma3", ModelID: "sha256:b"},
+		{Type: EventEvict, Model: "ai/smollm2", ModelID: "sha256:a", Reason: "make room for ai/llama3.2"},
+		{Type: EventLoad, Model: "ai/llama3.2", ModelID: "sha256:c"},
+	} {
+		event.Time = start.Add(time.Duration(i) * time.Second)
+		log.record(event)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{name: "all", query: "", expected: []string{"ai/smollm2", "ai/gemma3", "ai/smollm2", "ai/llama3.2"}},
+		{name: "model by reference", query: "model=ai/smollm2", expected: []string{"ai/smollm2", "ai/smollm2"}},
+		{name: "model by ID", query: "model=sha256:b", expected: []string{"ai/gemma3"}},
+		{name: "type", query: "type=evict", expected: []string{"ai/smollm2"}},
+		{name: "since", query: "since=1700000001", expected: []string{"ai/smollm2", "ai/llama3.2"}},
+		{name: "limit", query: "type=load&limit=2", expected: []string{"ai/gemma3", "ai/llama3.2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			filter, err := parseEventFilter(values)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			events := log.query(filter)
+			if len(events) != len(tt.expected) {
+				t.Fatalf("expected %d events, got %d", len(tt.expected), len(events))
+			}
+			for i, event := range events {
+				if event.Model != tt.expected[i] {
+					t.Errorf("event %d: expected %s, got %s", i, tt.expected[i], event.Model)
+				}
+			}
+		})
+	}
+
+	if _, err := parseEventFilter(url.Values{"limit": {"-1"}}); err == nil {
+		t.Error("expected error for negative limit")
+	}
+}
+
+func TestEventLogCapacity(t *testing.T) {
+	var log eventLog
+	for i := range maximumEvents + 10 {
+		log.record(SchedulerEvent{Replica: i})
+	}
+	events := log.query(EventFilter{})
+	if len(events) != maximumEvents {
+		t.Fatalf("expected %d events, got %d", maximumEvents, len(events))
+	}
+	if events[0].Replica != 10 {
+		t.Errorf("expected oldest events to be discarded, got replica %d first", events[0].Replica)
+	}
+}
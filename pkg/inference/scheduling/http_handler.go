@@ -7,15 +7,28 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/docker/model-runner/pkg/distribution/distribution"
+	"github.com/docker/model-runner/pkg/distribution/types"
 	"github.com/docker/model-runner/pkg/inference"
-	"github.com/docker/model-runner/pkg/inference/backends/vllm"
+	"github.com/docker/model-runner/pkg/inference/backends"
 	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/inference/structured"
+	"github.com/docker/model-runner/pkg/inference/toolcalls"
+	"github.com/docker/model-runner/pkg/internal/utils"
 	"github.com/docker/model-runner/pkg/metrics"
 	"github.com/docker/model-runner/pkg/middleware"
+	"github.com/docker/model-runner/pkg/tracing"
+	"github.com/docker/model-runner/pkg/usage"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // HTTPHandler handles HTTP requests for the scheduler.
@@ -62,28 +75,48 @@ func (h *HTTPHandler) routeHandlers() map[string]http.HandlerFunc {
 		"POST " + inference.InferencePrefix + "/v1/chat/completions",
 		"POST " + inference.InferencePrefix + "/v1/completions",
 		"POST " + inference.InferencePrefix + "/v1/embeddings",
+		"POST " + inference.InferencePrefix + "/{backend}/v1/audio/transcriptions",
+		"POST " + inference.InferencePrefix + "/v1/audio/transcriptions",
+		"POST " + inference.InferencePrefix + "/{backend}/v1/images/generations",
+		"POST " + inference.InferencePrefix + "/v1/images/generations",
 		"POST " + inference.InferencePrefix + "/{backend}/rerank",
 		"POST " + inference.InferencePrefix + "/rerank",
+		"POST " + inference.InferencePrefix + "/{backend}/v1/rerank",
+		"POST " + inference.InferencePrefix + "/v1/rerank",
 		"POST " + inference.InferencePrefix + "/{backend}/score",
 		"POST " + inference.InferencePrefix + "/score",
+		"POST " + inference.InferencePrefix + "/{backend}/v1/tokenize",
+		"POST " + inference.InferencePrefix + "/v1/tokenize",
+		"POST " + inference.InferencePrefix + "/{backend}/v1/detokenize",
+		"POST " + inference.InferencePrefix + "/v1/detokenize",
 	}
 	m := make(map[string]http.HandlerFunc)
 	for _, route := range openAIRoutes {
 		m[route] = h.handleOpenAIInference
 	}
 
-	// Register /v1/models routes - these delegate to the model manager
-	m["GET "+inference.InferencePrefix+"/{backend}/v1/models"] = h.handleModels
-	m["GET "+inference.InferencePrefix+"/{backend}/v1/models/{name...}"] = h.handleModels
-	m["GET "+inference.InferencePrefix+"/v1/models"] = h.handleModels
-	m["GET "+inference.InferencePrefix+"/v1/models/{name...}"] = h.handleModels
+	// Register /v1/models routes - listings delegate to the model manager and
+	// add the lifecycle state of models, which can be loaded, unloaded, and
+	// deleted through the same API
+	for _, prefix := range []string{inference.InferencePrefix + "/{backend}", inference.InferencePrefix} {
+		m["GET "+prefix+"/v1/models"] = h.handleOpenAIModels
+		m["GET "+prefix+"/v1/models/{name...}"] = h.handleOpenAIModel
+		m["DELETE "+prefix+"/v1/models/{name...}"] = h.handleOpenAIDeleteModel
+		m["POST "+prefix+"/v1/models/{nameAndAction...}"] = h.handleOpenAIModelAction
+	}
 
 	m["GET "+inference.InferencePrefix+"/status"] = h.GetBackendStatus
 	m["GET "+inference.InferencePrefix+"/ps"] = h.GetRunningBackends
+	m["GET "+inference.InferencePrefix+"/events"] = h.GetEvents
+	m["GET "+inference.InferencePrefix+"/node"] = h.GetNodeStatus
+	m["GET "+inference.InferencePrefix+"/cluster"] = h.GetPeers
+	m["GET "+inference.InferencePrefix+"/gpus"] = h.GetGPUTelemetry
 	m["GET "+inference.InferencePrefix+"/df"] = h.GetDiskUsage
 	m["POST "+inference.InferencePrefix+"/unload"] = h.Unload
+	m["POST "+inference.InferencePrefix+"/keep-alive"] = h.KeepAlive
 	m["POST "+inference.InferencePrefix+"/{backend}/_configure"] = h.Configure
 	m["POST "+inference.InferencePrefix+"/_configure"] = h.Configure
+	m["POST "+inference.InferencePrefix+"/{backend}/_uninstall"] = h.Uninstall
 	m["GET "+inference.InferencePrefix+"/requests"] = h.scheduler.openAIRecorder.GetRecordsHandler()
 	return m
 }
@@ -93,10 +126,21 @@ func (h *HTTPHandler) routeHandlers() map[string]http.HandlerFunc {
 // - POST <inference-prefix>/{backend}/v1/chat/completions
 // - POST <inference-prefix>/{backend}/v1/completions
 // - POST <inference-prefix>/{backend}/v1/embeddings
-// and 2 extras:
-// - POST <inference-prefix>/{backend}/rerank
+// - POST <inference-prefix>/{backend}/v1/audio/transcriptions
+// - POST <inference-prefix>/{backend}/v1/images/generations
+// and 4 extras:
+// - POST <inference-prefix>/{backend}/rerank (or /v1/rerank)
 // - POST <inference-prefix>/{backend}/score
+// - POST <inference-prefix>/{backend}/v1/tokenize
+// - POST <inference-prefix>/{backend}/v1/detokenize
 func (h *HTTPHandler) handleOpenAIInference(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+
+	// Trace routing the request to a backend and preparing it, until it's
+	// forwarded to a peer or queued.
+	_, routeSpan := tracing.Tracer().Start(r.Context(), "route")
+	defer routeSpan.End()
+
 	// Determine the requested backend and ensure that it's valid.
 	var backend inference.Backend
 	if b := r.PathValue("backend"); b == "" {
@@ -109,9 +153,43 @@ func (h *HTTPHandler) handleOpenAIInference(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Count the request by model, backend, and status once it's served.
+	serverMetrics := h.scheduler.loader.serverMetrics
+	var request OpenAIInferenceRequest
+	w, observeRequest := serverMetrics.RequestWriter(w)
+	defer func() {
+		observeRequest(request.Model, backend.Name())
+	}()
+
+	// Budget model loading, queueing, and generation within the deadline set
+	// by the client, if any.
+	if deadline, ok, err := requestDeadline(r.Header, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if ok {
+		if !time.Now().Before(deadline) {
+			http.Error(w, ErrDeadlineExceeded.Error(), http.StatusGatewayTimeout)
+			return
+		}
+		ctx, cancel := context.WithDeadline(r.Context(), deadline)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	// Determine the backend operation mode.
+	backendMode, ok := backendModeForRequest(r.URL.Path)
+	if !ok {
+		http.Error(w, "unknown request path", http.StatusInternalServerError)
+		return
+	}
+
 	// Read the entire request body. We put some basic size constraints in place
 	// to avoid DoS attacks. We do this early to avoid client write timeouts.
-	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maximumOpenAIInferenceRequestSize))
+	maximumRequestSize := int64(maximumOpenAIInferenceRequestSize)
+	if backendMode == inference.BackendModeTranscription {
+		maximumRequestSize = maximumTranscriptionRequestSize
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maximumRequestSize))
 	if err != nil {
 		var maxBytesError *http.MaxBytesError
 		if errors.As(err, &maxBytesError) {
@@ -122,16 +200,16 @@ func (h *HTTPHandler) handleOpenAIInference(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Determine the backend operation mode.
-	backendMode, ok := backendModeForRequest(r.URL.Path)
-	if !ok {
-		http.Error(w, "unknown request path", http.StatusInternalServerError)
-		return
-	}
-
-	// Decode the model specification portion of the request body.
-	var request OpenAIInferenceRequest
-	if err := json.Unmarshal(body, &request); err != nil {
+	// Decode the model specification portion of the request body. Audio is
+	// uploaded as a multipart form, whose fields are recorded in place of the
+	// body.
+	recordBody := body
+	if backendMode == inference.BackendModeTranscription {
+		if request, recordBody, err = decodeTranscriptionRequest(r.Header.Get("Content-Type"), body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if err := json.Unmarshal(body, &request); err != nil {
 		http.Error(w, "invalid request", http.StatusBadRequest)
 		return
 	}
@@ -139,6 +217,23 @@ func (h *HTTPHandler) handleOpenAIInference(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "model is required", http.StatusBadRequest)
 		return
 	}
+	middleware.SetModel(r.Context(), request.Model)
+	if !h.scheduler.modelAllowed(request.Model) {
+		http.Error(w, ErrModelNotAllowed.Error(), http.StatusForbidden)
+		return
+	}
+	if !h.scheduler.modelAllowedForKey(r.Context(), request.Model) {
+		http.Error(w, ErrModelNotAllowedForKey.Error(), http.StatusForbidden)
+		return
+	}
+
+	// Route remote models to the remote backend unless a backend was
+	// requested explicitly.
+	if r.PathValue("backend") == "" {
+		if remote := h.scheduler.remoteBackendForModel(r.Context(), request.Model, backendMode); remote != nil {
+			backend = remote
+		}
+	}
 
 	// Check if the shared model manager has the requested model available.
 	if !backend.UsesExternalModelManagement() {
@@ -146,6 +241,16 @@ func (h *HTTPHandler) handleOpenAIInference(w http.ResponseWriter, r *http.Reque
 		if err != nil {
 			if errors.Is(err, distribution.ErrModelNotFound) {
 				http.Error(w, err.Error(), http.StatusNotFound)
+			} else if errors.Is(err, models.ErrNotAdapter) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			} else {
+				http.Error(w, "model unavailable", http.StatusInternalServerError)
+			}
+			return
+		}
+		if err := h.scheduler.modelManager.CheckLicense(request.Model); err != nil {
+			if errors.Is(err, models.ErrLicenseNotAccepted) {
+				http.Error(w, err.Error(), http.StatusForbidden)
 			} else {
 				http.Error(w, "model unavailable", http.StatusInternalServerError)
 			}
@@ -157,7 +262,7 @@ func (h *HTTPHandler) handleOpenAIInference(w http.ResponseWriter, r *http.Reque
 		// Only trust whitelisted values to prevent header spoofing
 		if origin := r.Header.Get(inference.RequestOriginHeader); origin != "" {
 			switch origin {
-			case inference.OriginOllamaCompletion:
+			case inference.OriginOllamaCompletion, inference.OriginResponses, inference.OriginModerations, inference.OriginWebSocketChat:
 				action = origin
 				// If an unknown origin is provided, ignore it and use the default action
 				// This prevents untrusted clients from spoofing tracking data
@@ -167,15 +272,27 @@ func (h *HTTPHandler) handleOpenAIInference(w http.ResponseWriter, r *http.Reque
 		// Non-blocking call to track the model usage.
 		h.scheduler.tracker.TrackModel(model, r.UserAgent(), action)
 
-		// Automatically identify models for vLLM.
-		backend = h.scheduler.selectBackendForModel(model, backend, request.Model)
+		// Select the backend for the model, unless one was requested.
+		var requested inference.Backend
+		if r.PathValue("backend") != "" {
+			requested = backend
+		}
+		backend = h.scheduler.selectBackendForModel(model, requested, backendMode, request.Model)
+
+		// Adapters are applied by the backend when it loads the model.
+		if _, adapters := models.SplitAdapters(request.Model); len(adapters) > 0 && !supportsAdapters(backend) {
+			http.Error(w, fmt.Sprintf("backend %s doesn't support adapters", backend.Name()), http.StatusBadRequest)
+			return
+		}
 	}
 
 	// Wait for the corresponding backend installation to complete or fail. We
 	// don't allow any requests to be scheduled for a backend until it has
 	// completed installation.
 	if err := h.scheduler.installer.wait(r.Context(), backend.Name()); err != nil {
-		if errors.Is(err, ErrBackendNotFound) {
+		if deadlineExceeded(r.Context()) {
+			http.Error(w, ErrDeadlineExceeded.Error(), http.StatusGatewayTimeout)
+		} else if errors.Is(err, ErrBackendNotFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 		} else if errors.Is(err, errInstallerNotStarted) {
 			http.Error(w, err.Error(), http.StatusServiceUnavailable)
@@ -185,7 +302,7 @@ func (h *HTTPHandler) handleOpenAIInference(w http.ResponseWriter, r *http.Reque
 			// shutting down (since that will also cancel the request context).
 			// Either way, provide a response, even if it's ignored.
 			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
-		} else if errors.Is(err, vllm.ErrorNotFound) {
+		} else if errors.Is(err, inference.ErrBackendNotInstalled) {
 			http.Error(w, err.Error(), http.StatusPreconditionFailed)
 		} else {
 			http.Error(w, fmt.Errorf("backend installation failed: %w", err).Error(), http.StatusServiceUnavailable)
@@ -193,36 +310,406 @@ func (h *HTTPHandler) handleOpenAIInference(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	modelID := h.scheduler.modelManager.ResolveID(request.Model)
+	// Models managed externally aren't known to the model manager, so they're
+	// identified by name.
+	modelID := utils.SanitizeForLog(request.Model, -1)
+	if !backend.UsesExternalModelManagement() {
+		modelID = h.scheduler.modelManager.ResolveID(request.Model)
+	}
+	spanAttributes := []attribute.KeyValue{
+		tracing.ModelKey.String(request.Model),
+		tracing.BackendKey.String(backend.Name()),
+		tracing.ModeKey.String(backendMode.String()),
+	}
+	routeSpan.SetAttributes(spanAttributes...)
+
+	// Forward the request to a peer if its model is loaded there or only fits
+	// there.
+	if peer := h.scheduler.placeOnPeer(r, backend, modelID, backendMode); peer != nil {
+		routeSpan.SetAttributes(attribute.String("model_runner.peer", peer.url.Host))
+		routeSpan.End()
+		peer.forward(w, r, body)
+		return
+	}
+
+	// Apply the chat template stored in the settings of the model to chat
+	// completions. Backends that apply chat templates are given Jinja
+	// templates through the model's bundle, while Go templates, which no
+	// backend applies, are rendered here into the prompt of a completion.
+	// Completions of models whose settings template them are sent as chat
+	// completions of their prompt, whose responses are translated back.
+	isChat := strings.HasSuffix(r.URL.Path, "/v1/chat/completions")
+	isCompletion := strings.HasSuffix(r.URL.Path, "/v1/completions")
+	goTemplate := ""
+	templatedCompletion := false
+	if (isChat || isCompletion) && !backend.UsesExternalModelManagement() {
+		settings, err := h.scheduler.modelManager.GetSettings(request.Model)
+		if err != nil {
+			h.scheduler.log.Warnf("Failed to read the settings of model %s: %v", utils.SanitizeForLog(request.Model, -1), err)
+		} else {
+			if isCompletion && settings.TemplateCompletions {
+				if body, templatedCompletion, err = templateCompletionRequest(body); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if templatedCompletion {
+					isChat, isCompletion = true, false
+				}
+			}
+			if isChat && settings.ChatTemplate != "" && settings.ChatTemplateFormat == types.ChatTemplateFormatGo {
+				goTemplate = settings.ChatTemplate
+			} else if isChat && settings.ChatTemplate != "" && !appliesChatTemplates(backend) {
+				http.Error(w, fmt.Sprintf("backend %s can't apply the Jinja chat template stored in the settings of the model", backend.Name()), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	// Normalize tool calling for chat completions with tools. Models whose
+	// tool calls their backend's server doesn't parse, including those whose
+	// prompt is rendered with a Go template, are prompted with the tools
+	// instead, and their calls are parsed from their output.
+	var toolCalls *toolCallTranslator
+	if isChat && !isRemoteBackend(backend) {
+		var tools *toolcalls.Request
+		if body, tools, err = toolcalls.Parse(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if tools != nil {
+			runnerConfig := h.scheduler.loader.getRunnerConfig(r.Context(), backend.Name(), modelID, backendMode)
+			prompted := goTemplate != "" || !parsesToolCalls(backend, request.Model, runnerConfig)
+			if prompted {
+				if body, err = tools.Prompt(body); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			toolCalls = newToolCallTranslator(tools, prompted)
+		}
+	}
+
+	// Enforce strict JSON schema response formats of completions. Schemas are
+	// normalized to the semantics of strict mode before backends compile them
+	// to their constrained decoding mechanism, and outputs are validated
+	// against them before they're returned.
+	var schema *structured.Schema
+	if (isChat || isCompletion) && !isRemoteBackend(backend) {
+		if body, schema, err = structured.Parse(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Validate the log probabilities requested of completions, which are
+	// returned in the format of completions whichever format the backend
+	// returns them in.
+	var logprobs *completionLogprobsTranslator
+	if (isChat || isCompletion) && !isRemoteBackend(backend) {
+		requested, err := parseLogprobs(body, isChat)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if requested && isCompletion {
+			logprobs = newCompletionLogprobsTranslator()
+		}
+	}
+
+	// Report the usage and timing of streamed completions in their last chunk
+	// if it's requested, and always request the usage of the backend, so that
+	// it's metered.
+	var streamUsage *streamUsageTranslator
+	if (isChat || isCompletion) && !isRemoteBackend(backend) {
+		if body, streamUsage, err = parseStreamOptions(body, received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse the prompt cache hint of completions. Requests sharing a prompt
+	// prefix prefer the same replica, and backends keep the prefix in the
+	// same part of their prefix cache. Remote backends are given the hint
+	// as-is.
+	promptCacheKey := request.PromptCacheKey
+	if (isChat || isCompletion) && !isRemoteBackend(backend) {
+		if body, promptCacheKey, err = parsePromptCache(body, isChat); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if promptCacheKey != "" {
+		r = r.WithContext(inference.WithPromptCacheKey(r.Context(), promptCacheKey))
+	}
+
+	renderedChat := false
+	if goTemplate != "" {
+		if body, err = renderChatRequest(goTemplate, body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		renderedChat = true
+	}
+
+	// Tokenize and detokenize requests for local backends are normalized, and
+	// translated into requests to the tokenizer endpoints of the backend, so
+	// that every backend responds in the same format.
+	var tokenize *tokenizeRequest
+	if isTokenizerRequest(r.URL.Path) && !isRemoteBackend(backend) {
+		if tokenize, err = parseTokenizeRequest(r.URL.Path, body, backend); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Identify requests to local backends, so that their generations can be
+	// aborted if they're cut off.
+	requestID := ""
+	if !isRemoteBackend(backend) {
+		requestID = newRequestID()
+		r = r.WithContext(inference.WithRequestID(r.Context(), requestID))
+	}
+
+	// Translate the request body if the backend requires it. Transcription
+	// requests are multipart forms, which translators don't handle, and
+	// tokenize requests aren't sent to the backend as-is.
+	if translator, ok := backend.(inference.RequestTranslator); ok && backendMode != inference.BackendModeTranscription && tokenize == nil {
+		runnerConfig := h.scheduler.loader.getRunnerConfig(r.Context(), backend.Name(), modelID, backendMode)
+		body, err = translator.TranslateRequest(r.Context(), backendMode, runnerConfig, body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Embeddings requests for local backends are normalized, so that inputs of
+	// all types, encodings, and dimensionalities are supported by every
+	// backend, and their inputs are embedded in batches with those of
+	// concurrent requests.
+	var embeddings *embeddingsRequest
+	if backendMode == inference.BackendModeEmbedding && !isRemoteBackend(backend) {
+		if embeddings, err = parseEmbeddingsRequest(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Rerank requests for local backends are normalized, so that every
+	// backend is sent the same request and responds with probabilities
+	// ordered by relevance.
+	var rerank *rerankRequest
+	if strings.HasSuffix(r.URL.Path, "/rerank") && !isRemoteBackend(backend) {
+		if rerank, err = parseRerankRequest(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body, err = rerank.body(); err != nil {
+			http.Error(w, "failed to encode rerank request", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Queue the request behind other requests for the model, rejecting it if
+	// the model's queue is full or it waits too long. Low-priority requests
+	// may be cut off to make room for high-priority ones.
+	priority, err := requestPriority(r.Header, request.Priority)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, preempt := context.WithCancelCause(r.Context())
+	defer preempt(nil)
+	r = r.WithContext(ctx)
+	routeSpan.End()
+
+	// Report progress to clients of streamed requests that have to wait for
+	// their model to load, rather than leaving them waiting silently. Other
+	// clients learn how long they waited once the response starts.
+	waitStart := time.Now()
+	estimate, cold := h.scheduler.loader.loads.coldStart(backend.Name(), modelID, backendMode)
+	cold = cold && !isRemoteBackend(backend)
+	var progress *coldStartWriter
+	var admitted func(*queueTicket)
+	if cold && request.Stream {
+		progress = newColdStartWriter(w, request.Model, estimate, h.scheduler.queue.queued(modelID))
+		defer progress.stop()
+		w, admitted = progress, progress.admitted
+	}
 
-	// Request a runner to execute the request and defer its release.
-	runner, err := h.scheduler.loader.load(r.Context(), backend.Name(), modelID, request.Model, backendMode)
+	dequeue := serverMetrics.Enqueue(request.Model, backend.Name())
+	defer dequeue()
+	_, queueSpan := tracing.Tracer().Start(r.Context(), "queue", trace.WithAttributes(spanAttributes...),
+		trace.WithAttributes(attribute.String("model_runner.priority", priority.String())))
+	ticket, err := h.scheduler.queue.admit(r.Context(), modelID, queueRequest{
+		client:   requestClient(r),
+		priority: priority,
+		stream:   request.Stream,
+		preempt:  preempt,
+		admitted: admitted,
+	})
+	tracing.End(queueSpan, err)
+	if err != nil {
+		if errors.Is(err, ErrQueueFull) || errors.Is(err, ErrQueueTimeout) {
+			retryAfter := h.scheduler.queue.getLimits().RetryAfter()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		} else if deadlineExceeded(r.Context()) {
+			http.Error(w, ErrDeadlineExceeded.Error(), http.StatusGatewayTimeout)
+		} else {
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		}
+		return
+	}
+	defer ticket.done()
+
+	// Request a runner to execute the request and defer its release. Requests
+	// in the same session or sharing a prompt prefix prefer the same replica,
+	// to reuse its prefix cache.
+	_, loadSpan := tracing.Tracer().Start(r.Context(), "load", trace.WithAttributes(spanAttributes...),
+		trace.WithAttributes(attribute.Bool("model_runner.cold_start", cold)))
+	runner, err := h.scheduler.loader.load(withAffinity(r.Context(), requestAffinity(r.Header, promptCacheKey)), backend.Name(), modelID, request.Model, backendMode)
+	tracing.End(loadSpan, err)
 	if err != nil {
-		http.Error(w, fmt.Errorf("unable to load runner: %w", err).Error(), http.StatusInternalServerError)
+		if deadlineExceeded(r.Context()) {
+			http.Error(w, ErrDeadlineExceeded.Error(), http.StatusGatewayTimeout)
+			return
+		}
+		status := http.StatusInternalServerError
+		var sanityErr *TemplateSanityError
+		if errors.As(err, &sanityErr) {
+			status = http.StatusUnprocessableEntity
+		}
+		http.Error(w, fmt.Errorf("unable to load runner: %w", err).Error(), status)
 		return
 	}
-	defer h.scheduler.loader.release(runner)
+	defer h.scheduler.loader.release(runner, request.KeepAlive)
+	ticket.start()
+	dequeue()
+	if progress != nil {
+		progress.stop()
+	} else if cold {
+		w.Header().Set(inference.LoadDurationHeader, strconv.FormatFloat(time.Since(waitStart).Seconds(), 'f', 1, 64))
+	}
+
+	// Trace streaming the response to the client, until the translators of
+	// its chunks have flushed their last chunks.
+	if request.Stream {
+		var endStream func()
+		w, endStream = tracing.StreamWriter(r.Context(), w, spanAttributes...)
+		defer endStream()
+	}
 
 	// Record the request in the OpenAI recorder.
-	recordID := h.scheduler.openAIRecorder.RecordRequest(request.Model, r, body)
+	recordID := h.scheduler.openAIRecorder.RecordRequest(request.Model, r, recordBody)
 	w = h.scheduler.openAIRecorder.NewResponseRecorder(w)
 	defer func() {
 		// Record the response in the OpenAI recorder.
 		h.scheduler.openAIRecorder.RecordResponse(recordID, request.Model, w)
 	}()
 
-	// Create a request with the body replaced for forwarding upstream.
-	upstreamRequest := r.Clone(r.Context())
+	// Bound the time that the backend spends on the request. The request is
+	// cancelled, freeing the backend's slot, once a limit is exceeded or the
+	// client disconnects.
+	upstreamCtx, upstreamWriter, stopTimeouts := withGenerationTimeouts(r.Context(), w, h.scheduler.timeouts.forModel(modelID))
+	defer stopTimeouts()
+
+	// Create a request with the body replaced for forwarding upstream. Chat
+	// completions whose prompt was rendered are sent as completions, whose
+	// responses are translated back, before their tool calls are normalized
+	// and their outputs are validated. Completions whose prompt was templated
+	// are sent as chat completions, whose responses are translated back last.
+	upstreamRequest := r.Clone(upstreamCtx)
 	upstreamRequest.Body = io.NopCloser(bytes.NewReader(body))
+	upstreamRequest.ContentLength = int64(len(body))
+	if requestID != "" {
+		upstreamRequest.Header.Set(inference.RequestIDHeader, requestID)
+	}
+	if templatedCompletion {
+		upstreamRequest.URL.Path = strings.TrimSuffix(upstreamRequest.URL.Path, "/completions") + "/chat/completions"
+		upstreamRequest.URL.RawPath = ""
+		completionWriter := &chatResponseWriter{ResponseWriter: upstreamWriter, translate: newChatCompletionTranslator().translate}
+		defer completionWriter.finish()
+		upstreamWriter = completionWriter
+	}
+	if streamUsage != nil {
+		defer func() {
+			if firstToken, perToken, ok := streamUsage.latency(); ok {
+				serverMetrics.ObserveLatency(request.Model, backend.Name(), firstToken, perToken)
+			}
+		}()
+		usageWriter := &chatResponseWriter{ResponseWriter: upstreamWriter, translate: streamUsage.translate}
+		defer usageWriter.finish()
+		upstreamWriter = usageWriter
+	}
+	// Meter and count the tokens of the response before its usage is removed
+	// from streams whose client didn't ask for it.
+	upstreamWriter, meterTokens := usage.TokenWriter(r.Context(), upstreamWriter)
+	defer meterTokens()
+	upstreamWriter, countTokens := serverMetrics.TokenWriter(upstreamWriter, request.Model, backend.Name())
+	defer countTokens()
+	if schema != nil {
+		structuredWriter := newStructuredOutputWriter(upstreamWriter, schema)
+		defer structuredWriter.finish()
+		upstreamWriter = structuredWriter
+	}
+	if logprobs != nil {
+		logprobsWriter := &chatResponseWriter{ResponseWriter: upstreamWriter, translate: logprobs.translate}
+		defer logprobsWriter.finish()
+		upstreamWriter = logprobsWriter
+	}
+	if toolCalls != nil {
+		toolWriter := &chatResponseWriter{ResponseWriter: upstreamWriter, translate: toolCalls.translate}
+		defer toolWriter.finish()
+		upstreamWriter = toolWriter
+	}
+	if renderedChat {
+		upstreamRequest.URL.Path = strings.TrimSuffix(upstreamRequest.URL.Path, "/chat/completions") + "/completions"
+		upstreamRequest.URL.RawPath = ""
+		chatWriter := &chatResponseWriter{ResponseWriter: upstreamWriter}
+		defer chatWriter.finish()
+		upstreamWriter = chatWriter
+	}
 
-	// Perform the request.
-	runner.ServeHTTP(w, upstreamRequest)
-}
-
-// handleModels handles GET /engines/{backend}/v1/models* requests
-// by delegating to the model manager
-func (h *HTTPHandler) handleModels(w http.ResponseWriter, r *http.Request) {
-	h.modelHandler.ServeHTTP(w, r)
+	// Perform the request, tracing the generation by the backend, which
+	// continues the trace if it's traced itself.
+	generateCtx, generateSpan := tracing.Tracer().Start(upstreamCtx, "generate", trace.WithAttributes(spanAttributes...))
+	tracing.Inject(generateCtx, upstreamRequest.Header)
+	if embeddings != nil {
+		result, err := h.scheduler.embeddings.embed(upstreamCtx, runner, embeddings, func(ctx context.Context, body []byte) bufferedResponse {
+			return serveBuffered(ctx, runner, upstreamRequest, body)
+		})
+		if err == nil {
+			result.write(upstreamWriter)
+		} else if errors.Is(err, ErrFirstTokenTimeout) || errors.Is(err, ErrGenerationTimeout) {
+			http.Error(upstreamWriter, err.Error(), http.StatusGatewayTimeout)
+		} else if deadlineExceeded(upstreamCtx) {
+			http.Error(upstreamWriter, ErrDeadlineExceeded.Error(), http.StatusGatewayTimeout)
+		} else {
+			http.Error(upstreamWriter, "service unavailable", http.StatusServiceUnavailable)
+		}
+	} else if rerank != nil {
+		response := serveBuffered(upstreamCtx, runner, upstreamRequest, body)
+		if response.status == http.StatusOK {
+			response = rerank.response(response.body, rawRerankScores(backend))
+		}
+		response.write(upstreamWriter)
+	} else if tokenize != nil {
+		tokenize.serve(upstreamCtx, runner, upstreamRequest).write(upstreamWriter)
+	} else {
+		runner.ServeHTTP(upstreamWriter, upstreamRequest)
+		// Closing the connection of a request that was cut off doesn't stop
+		// the generation of every backend, so abort it explicitly.
+		if upstreamCtx.Err() != nil {
+			runner.abort(requestID)
+		}
+	}
+	tracing.End(generateSpan, context.Cause(upstreamCtx))
+	if cause := context.Cause(upstreamCtx); errors.Is(cause, ErrFirstTokenTimeout) || errors.Is(cause, ErrGenerationTimeout) {
+		h.scheduler.log.Warnf("Cancelled request for %s: %v", utils.SanitizeForLog(request.Model, -1), cause)
+	}
+	if errors.Is(context.Cause(ctx), ErrPreempted) {
+		h.scheduler.log.Infof("Preempted low-priority request for %s", utils.SanitizeForLog(request.Model, -1))
+	}
 }
 
 // GetBackendStatus returns the status of all backends.
@@ -253,6 +740,56 @@ func (h *HTTPHandler) GetRunningBackends(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// GetEvents returns the scheduler's recent decisions, optionally filtered by
+// the model, type, since, and limit query parameters.
+func (h *HTTPHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Model != "" {
+		filter.Model = h.scheduler.modelManager.ResolveID(filter.Model)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.scheduler.Events(filter)); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetNodeStatus returns the capacity of the local instance, which peers use
+// to place models.
+func (h *HTTPHandler) GetNodeStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.scheduler.NodeStatus()); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// GetPeers returns the status of the peers in the cluster.
+func (h *HTTPHandler) GetPeers(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.scheduler.Peers()); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// GetGPUTelemetry returns the latest sample of the telemetry of the GPUs,
+// with the processes using them attributed to runners.
+func (h *HTTPHandler) GetGPUTelemetry(w http.ResponseWriter, _ *http.Request) {
+	status, ok := h.scheduler.GPUTelemetry()
+	if !ok {
+		http.Error(w, "GPU telemetry is disabled", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
 // GetDiskUsage returns disk usage information for models and backends.
 func (h *HTTPHandler) GetDiskUsage(w http.ResponseWriter, _ *http.Request) {
 	modelsDiskUsage, err := h.scheduler.modelManager.GetDiskUsage()
@@ -261,6 +798,18 @@ func (h *HTTPHandler) GetDiskUsage(w http.ResponseWriter, _ *http.Request) {
 		return
 	}
 
+	derivedModelsDiskUsage, err := h.scheduler.modelManager.GetDerivedDiskUsage()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get derived models disk usage: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	reclaimableDiskUsage, err := h.scheduler.modelManager.GetReclaimableDiskUsage()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get reclaimable disk usage: %v", err), http.StatusInternalServerError)
+		return
+	}
+
 	// TODO: Get disk usage for each backend once the backends are implemented.
 	defaultBackendDiskUsage, err := h.scheduler.defaultBackend.GetDiskUsage()
 	if err != nil {
@@ -268,7 +817,7 @@ func (h *HTTPHandler) GetDiskUsage(w http.ResponseWriter, _ *http.Request) {
 		return
 	}
 
-	diskUsage := DiskUsage{modelsDiskUsage, defaultBackendDiskUsage}
+	diskUsage := DiskUsage{modelsDiskUsage, defaultBackendDiskUsage, derivedModelsDiskUsage, reclaimableDiskUsage}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(diskUsage); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
@@ -304,16 +853,62 @@ func (h *HTTPHandler) Unload(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// KeepAlive handles POST <inference-prefix>/keep-alive requests, which set how
+// long idle runners stay loaded, either for a model or by default.
+func (h *HTTPHandler) KeepAlive(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maximumOpenAIInferenceRequestSize))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(w, "request too large", http.StatusBadRequest)
+		} else {
+			http.Error(w, "failed to read request body", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var keepAliveRequest KeepAliveRequest
+	if err := json.Unmarshal(body, &keepAliveRequest); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.scheduler.SetKeepAlive(r.Context(), keepAliveRequest.Model, keepAliveRequest.KeepAlive); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Uninstall handles POST <inference-prefix>/{backend}/_uninstall requests.
+func (h *HTTPHandler) Uninstall(w http.ResponseWriter, r *http.Request) {
+	if err := h.scheduler.UninstallBackend(r.Context(), r.PathValue("backend")); err != nil {
+		switch {
+		case errors.Is(err, ErrBackendNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrBackendNotUninstallable), errors.Is(err, backends.ErrNotManaged):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, errBackendInUse):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, fmt.Errorf("backend uninstallation failed: %w", err).Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 // Configure handles POST <inference-prefix>/{backend}/_configure requests.
 func (h *HTTPHandler) Configure(w http.ResponseWriter, r *http.Request) {
-	// Determine the requested backend and ensure that it's valid.
+	// Determine the requested backend and ensure that it's valid. If no
+	// backend is requested, then one is selected for the model.
 	var backend inference.Backend
 	if b := r.PathValue("backend"); b == "" {
-		backend = h.scheduler.defaultBackend
-	} else {
-		backend = h.scheduler.backends[b]
-	}
-	if backend == nil {
+		if h.scheduler.defaultBackend == nil {
+			http.Error(w, ErrBackendNotFound.Error(), http.StatusNotFound)
+			return
+		}
+	} else if backend = h.scheduler.backends[b]; backend == nil {
 		http.Error(w, ErrBackendNotFound.Error(), http.StatusNotFound)
 		return
 	}
@@ -341,6 +936,8 @@ func (h *HTTPHandler) Configure(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, errRunnerAlreadyActive) {
 			http.Error(w, err.Error(), http.StatusConflict)
+		} else if errors.Is(err, errDraftModelUnavailable) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 		} else {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
@@ -376,3 +973,9 @@ func (h *HTTPHandler) GetLlamaCppSocket() (string, error) {
 func (h *HTTPHandler) GetAllActiveRunners() []metrics.ActiveRunner {
 	return h.scheduler.GetAllActiveRunners()
 }
+
+// GetServerMetrics delegates to the scheduler's business logic.
+// Required by metrics.SchedulerInterface.
+func (h *HTTPHandler) GetServerMetrics() []*dto.MetricFamily {
+	return h.scheduler.GetServerMetrics()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"sync"
 	"sync/atomic"
 
 	"github.com/docker/model-runner/pkg/inference"
@@ -17,6 +18,13 @@ var (
 	// errInstallerShuttingDown indicates that the installer's run loop has been
 	// terminated and the installer is shutting down.
 	errInstallerShuttingDown = errors.New("backend installer shutting down")
+	// ErrBackendNotUninstallable indicates that a backend doesn't support
+	// uninstallation.
+	ErrBackendNotUninstallable = errors.New("backend does not support uninstallation")
+	// errBackendUninstalled indicates that a backend has been uninstalled.
+	errBackendUninstalled = errors.New("backend has been uninstalled and will be reinstalled on restart")
+	// errBackendUninstalling indicates that a backend is being uninstalled.
+	errBackendUninstalling = errors.New("backend is being uninstalled")
 )
 
 // installStatus tracks the installation status of a backend.
@@ -42,6 +50,8 @@ type installer struct {
 	httpClient *http.Client
 	// started tracks whether or not the installer has been started.
 	started atomic.Bool
+	// statusesLock guards statuses.
+	statusesLock sync.Mutex
 	// statuses maps backend names to their installation statuses.
 	statuses map[string]*installStatus
 }
@@ -84,7 +94,7 @@ func (i *installer) run(ctx context.Context) {
 	// ubiquitous backend and mlx as a relatively lightweight backend (on macOS
 	// only), this granularity is probably less of a concern.
 	for name, backend := range i.backends {
-		status := i.statuses[name]
+		status := i.status(name)
 
 		var installedClosed bool
 		select {
@@ -116,8 +126,8 @@ func (i *installer) run(ctx context.Context) {
 // wait waits for installation of the specified backend to complete or fail.
 func (i *installer) wait(ctx context.Context, backend string) error {
 	// Grab the backend status.
-	status, ok := i.statuses[backend]
-	if !ok {
+	status := i.status(backend)
+	if status == nil {
 		return ErrBackendNotFound
 	}
 
@@ -138,3 +148,75 @@ func (i *installer) wait(ctx context.Context, backend string) error {
 		return status.err
 	}
 }
+
+// status returns the installation status of the specified backend, or nil if
+// the backend is unknown.
+func (i *installer) status(backend string) *installStatus {
+	i.statusesLock.Lock()
+	defer i.statusesLock.Unlock()
+	return i.statuses[backend]
+}
+
+// failed returns true if installation of the specified backend has failed or
+// if the backend is unknown.
+func (i *installer) failed(backend string) bool {
+	status := i.status(backend)
+	if status == nil {
+		return true
+	}
+	select {
+	case <-status.failed:
+		return true
+	default:
+		return false
+	}
+}
+
+// uninstall unloads and then uninstalls the specified backend. Waits for the
+// backend fail from before it's unloaded, so that it can't be loaded again
+// while it's being uninstalled, and until the installer is reset. If either
+// step fails, the previous installation status is restored.
+func (i *installer) uninstall(ctx context.Context, name string, unload func(context.Context) error) error {
+	backend, ok := i.backends[name]
+	if !ok {
+		return ErrBackendNotFound
+	}
+	uninstaller, ok := backend.(inference.Uninstaller)
+	if !ok {
+		return ErrBackendNotUninstallable
+	}
+
+	previous := i.setFailed(name, errBackendUninstalling)
+	if err := unload(ctx); err != nil {
+		i.setStatus(name, previous)
+		return err
+	}
+	if err := uninstaller.Uninstall(ctx); err != nil {
+		i.setStatus(name, previous)
+		return err
+	}
+	i.setFailed(name, errBackendUninstalled)
+	return nil
+}
+
+// setFailed marks installation of the specified backend as failed with the
+// specified error and returns its previous status.
+func (i *installer) setFailed(name string, err error) *installStatus {
+	failed := make(chan struct{})
+	close(failed)
+	return i.setStatus(name, &installStatus{
+		installed: make(chan struct{}),
+		failed:    failed,
+		err:       err,
+	})
+}
+
+// setStatus replaces the installation status of the specified backend and
+// returns its previous status.
+func (i *installer) setStatus(name string, status *installStatus) *installStatus {
+	i.statusesLock.Lock()
+	defer i.statusesLock.Unlock()
+	previous := i.statuses[name]
+	i.statuses[name] = status
+	return previous
+}
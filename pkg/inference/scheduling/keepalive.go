@@ -0,0 +1,84 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeepAlive is how long a runner stays loaded after its last request. A
+// negative value keeps the runner loaded indefinitely, and zero unloads it as
+// soon as it's idle.
+type KeepAlive time.Duration
+
+// KeepAliveForever keeps runners loaded indefinitely.
+const KeepAliveForever = KeepAlive(-1)
+
+// ParseKeepAlive parses a keep-alive, which is either a duration (e.g. "10m")
+// or a number of seconds. Negative values, such as "-1", keep runners loaded
+// indefinitely.
+func ParseKeepAlive(s string) (KeepAlive, error) {
+	s = strings.TrimSpace(s)
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		return keepAliveFromSeconds(seconds), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid keep-alive %q (expected a duration, a number of seconds, or -1)", s)
+	}
+	if d < 0 {
+		return KeepAliveForever, nil
+	}
+	return KeepAlive(d), nil
+}
+
+// keepAliveFromSeconds converts a number of seconds to a keep-alive.
+func keepAliveFromSeconds(seconds float64) KeepAlive {
+	if seconds < 0 {
+		return KeepAliveForever
+	}
+	return KeepAlive(seconds * float64(time.Second))
+}
+
+// Forever returns true if the keep-alive keeps runners loaded indefinitely.
+func (k KeepAlive) Forever() bool {
+	return k < 0
+}
+
+// String returns the keep-alive as a duration, or "-1" if it's indefinite.
+func (k KeepAlive) String() string {
+	if k.Forever() {
+		return "-1"
+	}
+	return time.Duration(k).String()
+}
+
+// MarshalJSON implements json.Marshaler.MarshalJSON.
+func (k KeepAlive) MarshalJSON() ([]byte, error) {
+	if k.Forever() {
+		return []byte("-1"), nil
+	}
+	return json.Marshal(k.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.UnmarshalJSON. It accepts either a
+// string or a number of seconds.
+func (k *KeepAlive) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*k = keepAliveFromSeconds(seconds)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid keep-alive %s (expected a string or a number)", data)
+	}
+	parsed, err := ParseKeepAlive(s)
+	if err != nil {
+		return err
+	}
+	*k = parsed
+	return nil
+}
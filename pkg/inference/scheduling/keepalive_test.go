@@ -0,0 +1,73 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseKeepAlive(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected KeepAlive
+		wantErr  bool
+	}{
+		{value: "10m", expected: KeepAlive(10 * time.Minute)},
+		{value: "0", expected: 0},
+		{value: "0s", expected: 0},
+		{value: "300", expected: KeepAlive(5 * time.Minute)},
+		{value: "-1", expected: KeepAliveForever},
+		{value: "-1m", expected: KeepAliveForever},
+		{value: "forever", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			keepAlive, err := ParseKeepAlive(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", keepAlive)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if keepAlive != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, keepAlive)
+			}
+		})
+	}
+}
+
+func TestKeepAliveJSON(t *testing.T) {
+	tests := []struct {
+		json     string
+		expected KeepAlive
+		marshal  string
+	}{
+		{json: `"10m"`, expected: KeepAlive(10 * time.Minute), marshal: `"10m0s"`},
+		{json: `60`, expected: KeepAlive(time.Minute), marshal: `"1m0s"`},
+		{json: `-1`, expected: KeepAliveForever, marshal: `-1`},
+		{json: `"-1"`, expected: KeepAliveForever, marshal: `-1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var keepAlive KeepAlive
+			if err := json.Unmarshal([]byte(tt.json), &keepAlive); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if keepAlive != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, keepAlive)
+			}
+			data, err := json.Marshal(keepAlive)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(data) != tt.marshal {
+				t.Errorf("expected %s, got %s", tt.marshal, data)
+			}
+		})
+	}
+}
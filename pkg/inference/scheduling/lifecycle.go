@@ -0,0 +1,269 @@
+package scheduling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/model-runner/pkg/distribution/distribution"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+// errModelInUse indicates that a model can't be unloaded or deleted because
+// it's serving requests. If returned in conjunction with an HTTP request, it
+// should be paired with a 409 response status.
+var errModelInUse = errors.New("model is serving requests")
+
+// modelRuntime describes the runners of a model.
+type modelRuntime struct {
+	// backends are the backends that the model is loaded by, in order.
+	backends []string
+	// memory is the memory allocated to the model's runners.
+	memory inference.RequiredMemory
+	// busy indicates that a runner of the model is serving requests.
+	busy bool
+	// lastUsed is when a runner of the model last finished serving a request.
+	lastUsed time.Time
+}
+
+// modelRuntimes describes the runners of models, by model ID, including the
+// models that were used but are no longer loaded.
+func (l *loader) modelRuntimes(ctx context.Context) map[string]*modelRuntime {
+	runtimes := make(map[string]*modelRuntime)
+	if !l.lock(ctx) {
+		return runtimes
+	}
+	defer l.unlock()
+	for id, lastUsed := range l.lastUsed {
+		runtimes[id] = &modelRuntime{lastUsed: lastUsed}
+	}
+	for key, info := range l.runners {
+		if l.slots[info.slot] == nil {
+			continue
+		}
+		runtime := runtimes[key.modelID]
+		if runtime == nil {
+			runtime = &modelRuntime{}
+			runtimes[key.modelID] = runtime
+		}
+		if !slices.Contains(runtime.backends, key.backend) {
+			runtime.backends = append(runtime.backends, key.backend)
+			slices.Sort(runtime.backends)
+		}
+		runtime.memory.RAM += l.allocations[info.slot].RAM
+		runtime.memory.VRAM += l.allocations[info.slot].VRAM
+		runtime.busy = runtime.busy || l.references[info.slot] > 0
+	}
+	return runtimes
+}
+
+// describeModel adds the lifecycle state of a model to its entry in the
+// OpenAI models listing.
+func (h *HTTPHandler) describeModel(model *OpenAIModel, runtimes map[string]*modelRuntime) {
+	model.State = ModelStateUnloaded
+	if local, err := h.scheduler.modelManager.GetLocal(model.ID); err == nil {
+		if config, err := local.Config(); err == nil {
+			model.Quantization = config.Quantization
+		}
+	}
+	runtime := runtimes[h.scheduler.modelManager.ResolveID(model.ID)]
+	if runtime == nil {
+		return
+	}
+	if len(runtime.backends) > 0 {
+		model.State = ModelStateLoaded
+		model.Backend = runtime.backends[0]
+		model.Memory = &models.ModelMemory{RAM: runtime.memory.RAM, VRAM: runtime.memory.VRAM}
+	}
+	if runtime.busy {
+		model.LastUsed = time.Now().Unix()
+	} else if !runtime.lastUsed.IsZero() {
+		model.LastUsed = runtime.lastUsed.Unix()
+	}
+}
+
+// modelLoaded returns true if a model is loaded by the specified backend, or
+// by any backend if it's empty.
+func (h *HTTPHandler) modelLoaded(ctx context.Context, ref, backend string) bool {
+	runtime := h.scheduler.loader.modelRuntimes(ctx)[h.scheduler.modelManager.ResolveID(ref)]
+	if runtime == nil {
+		return false
+	}
+	if backend == "" {
+		return len(runtime.backends) > 0
+	}
+	return slices.Contains(runtime.backends, backend)
+}
+
+// openAIModel returns the entry of a model in the OpenAI models listing,
+// along with its lifecycle state.
+func (h *HTTPHandler) openAIModel(ctx context.Context, ref string) (*OpenAIModel, error) {
+	local, err := h.scheduler.modelManager.GetLocal(ref)
+	if err != nil {
+		return nil, err
+	}
+	openAI, err := models.ToOpenAI(local)
+	if err != nil {
+		return nil, err
+	}
+	model := &OpenAIModel{OpenAIModel: *openAI}
+	h.describeModel(model, h.scheduler.loader.modelRuntimes(ctx))
+	return model, nil
+}
+
+// handleOpenAIModels handles GET <inference-prefix>/{backend}/v1/models and
+// GET <inference-prefix>/v1/models requests, which list the models of the
+// model manager along with their lifecycle state.
+func (h *HTTPHandler) handleOpenAIModels(w http.ResponseWriter, r *http.Request) {
+	response := serveBuffered(r.Context(), h.modelHandler, r, nil)
+	var list OpenAIModelList
+	if response.status != http.StatusOK || json.Unmarshal(response.body, &list) != nil {
+		response.write(w)
+		return
+	}
+	runtimes := h.scheduler.loader.modelRuntimes(r.Context())
+	for _, model := range list.Data {
+		h.describeModel(model, runtimes)
+	}
+	writeModelJSON(w, http.StatusOK, list)
+}
+
+// handleOpenAIModel handles GET <inference-prefix>/{backend}/v1/models/{name}
+// and GET <inference-prefix>/v1/models/{name} requests, which describe a model
+// along with its lifecycle state.
+func (h *HTTPHandler) handleOpenAIModel(w http.ResponseWriter, r *http.Request) {
+	response := serveBuffered(r.Context(), h.modelHandler, r, nil)
+	var model OpenAIModel
+	if response.status != http.StatusOK || json.Unmarshal(response.body, &model) != nil {
+		response.write(w)
+		return
+	}
+	h.describeModel(&model, h.scheduler.loader.modelRuntimes(r.Context()))
+	writeModelJSON(w, http.StatusOK, model)
+}
+
+// handleOpenAIDeleteModel handles DELETE <inference-prefix>/{backend}/v1/models/{name}
+// and DELETE <inference-prefix>/v1/models/{name} requests, which unload a
+// model and delete it, unless it's serving requests. Models with several tags
+// are only deleted with the force query parameter.
+func (h *HTTPHandler) handleOpenAIDeleteModel(w http.ResponseWriter, r *http.Request) {
+	ref := r.PathValue("name")
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	h.scheduler.Unload(r.Context(), UnloadRequest{Models: []string{ref}})
+	if h.modelLoaded(r.Context(), ref, "") {
+		http.Error(w, errModelInUse.Error(), http.StatusConflict)
+		return
+	}
+
+	_, err := h.scheduler.modelManager.Delete(ref, force)
+	if errors.Is(err, distribution.ErrModelNotFound) {
+		if normalized := models.NormalizeModelName(ref); normalized != ref {
+			_, err = h.scheduler.modelManager.Delete(normalized, force)
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, distribution.ErrModelNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, distribution.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			h.scheduler.log.Warnf("Failed to delete model %s: %v", utils.SanitizeForLog(ref, -1), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	writeModelJSON(w, http.StatusOK, OpenAIModelDeletion{ID: ref, Object: "model", Deleted: true})
+}
+
+// handleOpenAIModelAction handles POST <inference-prefix>/{backend}/v1/models/{name}/{action}
+// and POST <inference-prefix>/v1/models/{name}/{action} requests. Action is
+// one of:
+// - load: loads the model, unless it's loaded already, optionally with the
+// backend and in the mode of a ModelLoadRequest body.
+// - unload: unloads the model, unless it's serving requests.
+// Both respond with the model along with its lifecycle state.
+func (h *HTTPHandler) handleOpenAIModelAction(w http.ResponseWriter, r *http.Request) {
+	ref, action := path.Split(r.PathValue("nameAndAction"))
+	ref = strings.TrimRight(ref, "/")
+	if ref == "" {
+		http.Error(w, "model is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.scheduler.modelManager.GetLocal(ref); err != nil {
+		if errors.Is(err, distribution.ErrModelNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "model unavailable", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	switch action {
+	case "load":
+		if !h.scheduler.modelAllowed(ref) {
+			http.Error(w, ErrModelNotAllowed.Error(), http.StatusForbidden)
+			return
+		}
+		request := ModelLoadRequest{Backend: r.PathValue("backend")}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maximumOpenAIInferenceRequestSize)).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		var mode *inference.BackendMode
+		if request.Mode != "" {
+			parsed, err := inference.ParseBackendMode(request.Mode)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mode = &parsed
+		}
+		if _, err := h.scheduler.LoadInstance(r.Context(), request.Backend, ref, mode); err != nil {
+			h.scheduler.log.Warnf("Failed to load %s: %v", utils.SanitizeForLog(ref, -1), err)
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrBackendNotFound) {
+				status = http.StatusNotFound
+			} else if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+	case "unload":
+		backend := r.PathValue("backend")
+		h.scheduler.Unload(r.Context(), UnloadRequest{Backend: backend, Models: []string{ref}})
+		if h.modelLoaded(r.Context(), ref, backend) {
+			http.Error(w, errModelInUse.Error(), http.StatusConflict)
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unknown action %q", action), http.StatusNotFound)
+		return
+	}
+
+	model, err := h.openAIModel(r.Context(), ref)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeModelJSON(w, http.StatusOK, model)
+}
+
+// writeModelJSON responds with a value of the OpenAI models API encoded as
+// JSON.
+func writeModelJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,53 @@
+package scheduling
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+func TestModelRuntimes(t *testing.T) {
+	dir := t.TempDir()
+	socketPath := RunnerSocketPath
+	RunnerSocketPath = func(slot int) (string, error) {
+		return filepath.Join(dir, fmt.Sprintf("runner-%d.sock", slot)), nil
+	}
+	t.Cleanup(func() { RunnerSocketPath = socketPath })
+
+	log := createTestLogger()
+	backend := &servingBackend{mockBackend{name: "test-backend", requiredMemory: inference.RequiredMemory{RAM: GB}}}
+	loader := newLoader(log, map[string]inference.Backend{"test-backend": backend}, nil, nil,
+		&mockSystemMemoryInfo{totalMemory: inference.RequiredMemory{RAM: 4 * GB}})
+	loader.loadsEnabled = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if runtimes := loader.modelRuntimes(ctx); len(runtimes) != 0 {
+		t.Errorf("Expected no runtimes before models are loaded, got %+v", runtimes)
+	}
+
+	r, err := loader.load(ctx, "test-backend", "model1", "model1:latest", inference.BackendModeEmbedding)
+	if err != nil {
+		t.Fatalf("Failed to load runner: %v", err)
+	}
+	runtime := loader.modelRuntimes(ctx)["model1"]
+	if runtime == nil || !runtime.busy || len(runtime.backends) != 1 || runtime.backends[0] != "test-backend" || runtime.memory.RAM != GB {
+		t.Fatalf("Expected the model to be loaded and busy, got %+v", runtime)
+	}
+
+	loader.release(r, nil)
+	runtime = loader.modelRuntimes(ctx)["model1"]
+	if runtime == nil || runtime.busy || runtime.lastUsed.IsZero() {
+		t.Fatalf("Expected the model to be idle and recently used, got %+v", runtime)
+	}
+
+	loader.Unload(ctx, UnloadRequest{All: true})
+	runtime = loader.modelRuntimes(ctx)["model1"]
+	if runtime == nil || len(runtime.backends) != 0 || runtime.lastUsed.IsZero() {
+		t.Errorf("Expected the model to be unloaded and remember its last use, got %+v", runtime)
+	}
+}
@@ -7,9 +7,13 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"slices"
 	"time"
 
+	"github.com/docker/model-runner/pkg/distribution/distribution"
 	"github.com/docker/model-runner/pkg/environment"
+	"github.com/docker/model-runner/pkg/gpuinfo"
+	"github.com/docker/model-runner/pkg/gputelemetry"
 	"github.com/docker/model-runner/pkg/inference"
 	"github.com/docker/model-runner/pkg/inference/memory"
 	"github.com/docker/model-runner/pkg/inference/models"
@@ -37,6 +41,8 @@ var (
 	// errRunnerAlreadyActive indicates that a given runner is already active
 	// and therefore can't be reconfigured for example
 	errRunnerAlreadyActive = errors.New("runner already active")
+	// errBackendInUse indicates that a backend has runners which are in use.
+	errBackendInUse = errors.New("backend in use")
 )
 
 // runnerKey is used to index runners.
@@ -49,6 +55,8 @@ type runnerKey struct {
 	draftModelID string
 	// mode is the operation mode associated with the runner.
 	mode inference.BackendMode
+	// replica is the index of the runner among the model's replicas.
+	replica int
 }
 
 // makeConfigKey creates a runnerKey for configuration storage.
@@ -95,7 +103,9 @@ type loader struct {
 	backends map[string]inference.Backend
 	// modelManager is the shared model manager.
 	modelManager *models.Manager
-	// runnerIdleTimeout is the loader-specific default runner idle timeout.
+	// runnerIdleTimeout is the loader-specific default runner idle timeout. A
+	// negative timeout keeps runners loaded indefinitely. It's guarded by the
+	// loader lock.
 	runnerIdleTimeout time.Duration
 	// totalMemory is the total system memory allocated to the loader.
 	totalMemory inference.RequiredMemory
@@ -108,6 +118,9 @@ type loader struct {
 	guard chan struct{}
 	// loadsEnabled signals that loads are currently enabled.
 	loadsEnabled bool
+	// uninstalling is the set of backends that are being uninstalled, which
+	// can't be loaded.
+	uninstalling map[string]bool
 	// availableMemory is the available portion of the loader's total memory.
 	availableMemory inference.RequiredMemory
 	// waiters is the set of signal channels associated with waiting loaders. We
@@ -126,10 +139,38 @@ type loader struct {
 	// timestamps maps slot indices to last usage times. Values in this slice
 	// are only valid if the corresponding reference count is zero.
 	timestamps []time.Time
+	// requestKeepAlives maps slot indices to the keep-alive requested by the
+	// last request served by the corresponding runner, if any.
+	requestKeepAlives []*KeepAlive
+	// modelKeepAlives maps model IDs to their configured keep-alives, which
+	// override runnerIdleTimeout.
+	modelKeepAlives map[string]KeepAlive
 	// runnerConfigs maps model names to runner configurations
 	runnerConfigs map[runnerKey]inference.BackendConfiguration
 	// openAIRecorder is used to record OpenAI API inference requests and responses.
 	openAIRecorder *metrics.OpenAIRecorder
+	// devices are the GPUs available for assignment to runners.
+	devices []gpuinfo.Device
+	// deviceAssignments maps slot indices to the GPUs assigned to them.
+	deviceAssignments [][]int
+	// placement is the policy used to place runners on GPUs.
+	placement PlacementPolicy
+	// replicaCursors maps the keys of models' first replicas to the number of
+	// requests routed to their replicas in turn.
+	replicaCursors map[runnerKey]int
+	// crashes maps the keys of models' first replicas to records of their
+	// runners' crashes.
+	crashes map[runnerKey]*crashRecord
+	// events records the loader's decisions.
+	events *eventLog
+	// loads tracks which models are loaded and how long they took to load.
+	loads *loadTracker
+	// lastUsed maps model IDs to when a runner of the model last finished
+	// serving a request, including after the runner was unloaded.
+	lastUsed map[string]time.Time
+	// serverMetrics records the metrics of the requests served by the
+	// scheduler and of the runners it loads.
+	serverMetrics *metrics.ServerMetrics
 }
 
 // newLoader creates a new loader.
@@ -178,9 +219,22 @@ func newLoader(
 		references:        make([]uint, nSlots),
 		allocations:       make([]inference.RequiredMemory, nSlots),
 		timestamps:        make([]time.Time, nSlots),
+		requestKeepAlives: make([]*KeepAlive, nSlots),
+		modelKeepAlives:   make(map[string]KeepAlive),
 		runnerConfigs:     make(map[runnerKey]inference.BackendConfiguration),
 		openAIRecorder:    openAIRecorder,
+		devices:           sysMemInfo.GetGPUDevices(),
+		deviceAssignments: make([][]int, nSlots),
+		placement:         PlacementSpread,
+		replicaCursors:    make(map[runnerKey]int),
+		crashes:           make(map[runnerKey]*crashRecord),
+		events:            &eventLog{},
+		loads:             newLoadTracker(),
+		lastUsed:          make(map[string]time.Time),
+		uninstalling:      make(map[string]bool),
+		serverMetrics:     metrics.NewServerMetrics(),
 	}
+	l.loads.recordMemory(totalMemory, totalMemory)
 	l.guard <- struct{}{}
 	return l
 }
@@ -201,6 +255,22 @@ func (l *loader) unlock() {
 	l.guard <- struct{}{}
 }
 
+// modelLoaded returns true if a runner for the model with the specified ID is
+// loaded, either as the runner's model or as its draft model.
+func (l *loader) modelLoaded(ctx context.Context, modelID string) bool {
+	if !l.lock(ctx) {
+		// Err on the side of treating the model as loaded.
+		return true
+	}
+	defer l.unlock()
+	for key := range l.runners {
+		if key.modelID == modelID || key.draftModelID == modelID {
+			return true
+		}
+	}
+	return false
+}
+
 // broadcast signals all waiters. Callers must hold the loader lock.
 func (l *loader) broadcast() {
 	for waiter := range l.waiters {
@@ -220,18 +290,169 @@ func formatMemorySize(bytes uint64) string {
 	return fmt.Sprintf("%d MB", bytes/1024/1024)
 }
 
-// freeRunnerSlot frees a runner slot and reclaims its memory.
+// memoryEstimates returns the memory allocated to the loaded runners of each
+// model, as estimated by their backend.
+func (l *loader) memoryEstimates(ctx context.Context) []metrics.MemoryEstimate {
+	if !l.lock(ctx) {
+		return nil
+	}
+	defer l.unlock()
+	indices := make(map[[2]string]int)
+	var estimates []metrics.MemoryEstimate
+	for key, info := range l.runners {
+		if l.slots[info.slot] == nil {
+			continue
+		}
+		index, ok := indices[[2]string{key.backend, info.modelRef}]
+		if !ok {
+			index = len(estimates)
+			indices[[2]string{key.backend, info.modelRef}] = index
+			estimates = append(estimates, metrics.MemoryEstimate{Backend: key.backend, Model: info.modelRef})
+		}
+		estimates[index].RAM += l.allocations[info.slot].RAM
+		estimates[index].VRAM += l.allocations[info.slot].VRAM
+	}
+	return estimates
+}
+
+// gpuRunners returns the loaded runners whose backend processes have started,
+// to attribute the processes using GPUs to them.
+func (l *loader) gpuRunners(ctx context.Context) []gputelemetry.Runner {
+	if !l.lock(ctx) {
+		return nil
+	}
+	defer l.unlock()
+	var runners []gputelemetry.Runner
+	for key, info := range l.runners {
+		runner := l.slots[info.slot]
+		if runner == nil {
+			continue
+		}
+		if pid := runner.pid.Load(); pid > 0 {
+			runners = append(runners, gputelemetry.Runner{PID: int(pid), Backend: key.backend, Model: info.modelRef})
+		}
+	}
+	return runners
+}
+
+// freeRunnerSlot frees a runner slot and reclaims its memory, recording the
+// decision as an event of the specified type with the specified reason.
 // The caller must hold the loader lock.
-func (l *loader) freeRunnerSlot(slot int, key runnerKey) {
+func (l *loader) freeRunnerSlot(slot int, key runnerKey, eventType SchedulerEventType, reason string) {
+	allocation, devices := l.allocations[slot], l.deviceAssignments[slot]
 	l.slots[slot].terminate()
 	l.slots[slot] = nil
-	l.availableMemory.RAM += l.allocations[slot].RAM
-	l.availableMemory.VRAM += l.allocations[slot].VRAM
 	l.allocations[slot] = inference.RequiredMemory{RAM: 0, VRAM: 0}
+	l.updateAvailableMemory()
 	l.timestamps[slot] = time.Time{}
+	l.requestKeepAlives[slot] = nil
+	l.deviceAssignments[slot] = nil
+	l.recordEvent(eventType, key, l.runners[key].modelRef, allocation, devices, reason)
+	l.serverMetrics.ObserveUnload(l.runners[key].modelRef, key.backend, string(eventType))
+	l.loads.recordUnload(key)
 	delete(l.runners, key)
 }
 
+// allocatedMemory returns the memory allocated to runners. The caller must
+// hold the loader lock.
+func (l *loader) allocatedMemory() inference.RequiredMemory {
+	var allocated inference.RequiredMemory
+	for _, allocation := range l.allocations {
+		allocated.RAM += allocation.RAM
+		allocated.VRAM += allocation.VRAM
+	}
+	return allocated
+}
+
+// updateAvailableMemory computes the portion of the total memory that isn't
+// allocated to runners. Allocations may exceed the total memory if the memory
+// budget was reduced while runners were in use, or on Windows, where runners
+// may use shared GPU memory, in which case no memory is available. The caller
+// must hold the loader lock.
+func (l *loader) updateAvailableMemory() {
+	allocated := l.allocatedMemory()
+	l.availableMemory.RAM = l.totalMemory.RAM - min(allocated.RAM, l.totalMemory.RAM)
+	l.availableMemory.VRAM = l.totalMemory.VRAM - min(allocated.VRAM, l.totalMemory.VRAM)
+	l.loads.recordMemory(l.totalMemory, l.availableMemory)
+}
+
+// idleTimeout returns how long the runner in the specified slot stays loaded
+// after its last request: the keep-alive of that request, if any, or else the
+// keep-alive configured for the model or the default idle timeout. A negative
+// timeout keeps the runner loaded indefinitely. The caller must hold the
+// loader lock.
+func (l *loader) idleTimeout(slot int, modelID string) time.Duration {
+	if keepAlive := l.requestKeepAlives[slot]; keepAlive != nil {
+		return time.Duration(*keepAlive)
+	}
+	if keepAlive, ok := l.modelKeepAlives[modelID]; ok {
+		return time.Duration(keepAlive)
+	}
+	return l.runnerIdleTimeout
+}
+
+// setKeepAlive sets the keep-alive of a model or, if modelID is empty, the
+// default idle timeout for all models without a keep-alive. It applies to
+// loaded runners, except those whose last request set a keep-alive.
+func (l *loader) setKeepAlive(ctx context.Context, modelID string, keepAlive KeepAlive) error {
+	if !l.lock(ctx) {
+		return context.Canceled
+	}
+	defer l.unlock()
+
+	if modelID == "" {
+		l.runnerIdleTimeout = time.Duration(keepAlive)
+	} else {
+		l.modelKeepAlives[modelID] = keepAlive
+	}
+
+	// Reschedule idle eviction for the new timeouts.
+	select {
+	case l.idleCheck <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// setPlacementPolicy sets the policy used to place runners on GPUs. It applies
+// to subsequently loaded runners.
+func (l *loader) setPlacementPolicy(ctx context.Context, policy PlacementPolicy) error {
+	if !l.lock(ctx) {
+		return context.Canceled
+	}
+	defer l.unlock()
+	l.placement = policy
+	return nil
+}
+
+// setTotalMemory sets the total memory that runners can be loaded into.
+// Unused runners are evicted, least recently used first, until those that
+// remain fit. Runners in use are left running, but no memory is available to
+// other runners until enough of them are unloaded.
+func (l *loader) setTotalMemory(ctx context.Context, totalMemory inference.RequiredMemory) error {
+	if !l.lock(ctx) {
+		return context.Canceled
+	}
+	defer l.unlock()
+
+	l.totalMemory = totalMemory
+	overcommitted := func() bool {
+		allocated := l.allocatedMemory()
+		return allocated.RAM > totalMemory.RAM || allocated.VRAM > totalMemory.VRAM
+	}
+	for overcommitted() && l.evictLeastRecentlyUsed(false, "memory budget reduced") {
+	}
+	l.updateAvailableMemory()
+	if overcommitted() {
+		l.log.Warnf("Runners in use exceed the memory budget of %s RAM and %s VRAM",
+			formatMemorySize(totalMemory.RAM), formatMemorySize(totalMemory.VRAM))
+	}
+
+	// Waiting loads may fit now.
+	l.broadcast()
+	return nil
+}
+
 // evict evicts all unused runners from the loader. If idleOnly is true, then
 // only those unused, but functioning, runners which are considered "idle" (based
 // on usage timestamp) are evicted. Defunct (e.g. crashed) runners will be evicted
@@ -242,7 +463,10 @@ func (l *loader) evict(idleOnly bool) int {
 	evictedCount := 0
 	for r, runnerInfo := range l.runners {
 		unused := l.references[runnerInfo.slot] == 0
-		idle := unused && now.Sub(l.timestamps[runnerInfo.slot]) > l.runnerIdleTimeout
+		// Remote runners hold no resources, so they're never idle.
+		timeout := l.idleTimeout(runnerInfo.slot, r.modelID)
+		idle := unused && !isRemoteBackend(l.backends[r.backend]) && timeout >= 0 &&
+			now.Sub(l.timestamps[runnerInfo.slot]) > timeout
 		defunct := false
 		select {
 		case <-l.slots[runnerInfo.slot].done:
@@ -253,7 +477,16 @@ func (l *loader) evict(idleOnly bool) int {
 			l.log.Infof("Evicting %s backend runner with model %s (%s) in %s mode",
 				r.backend, r.modelID, runnerInfo.modelRef, r.mode,
 			)
-			l.freeRunnerSlot(runnerInfo.slot, r)
+			switch {
+			case defunct:
+				l.freeRunnerSlot(runnerInfo.slot, r, EventEvict, "runner exited")
+			case idle:
+				l.freeRunnerSlot(runnerInfo.slot, r, EventEvict, fmt.Sprintf("idle for longer than %s", timeout))
+			case !l.loadsEnabled:
+				l.freeRunnerSlot(runnerInfo.slot, r, EventUnload, "shutting down")
+			default:
+				l.freeRunnerSlot(runnerInfo.slot, r, EventUnload, "unload requested")
+			}
 			evictedCount++
 		} else if unused {
 			l.log.Debugf("Runner %s (%s) is unused but not evictable: idleOnly=%v, idle=%v, defunct=%v",
@@ -272,9 +505,50 @@ func (l *loader) evict(idleOnly bool) int {
 	return len(l.runners)
 }
 
-// evictRunner evicts a specific runner. The caller must hold the loader lock.
-// It returns the number of remaining runners.
-func (l *loader) evictRunner(backend, model string, mode inference.BackendMode) int {
+// evictLeastRecentlyUsed evicts the unused runner that was used least
+// recently, preferring defunct runners, so that resident models are only
+// evicted when the memory budget requires it. Remote runners hold no memory,
+// so functioning remote runners are only evicted if freeSlot is true. The
+// reason is recorded with the eviction. The caller must hold the loader lock.
+// It returns false if no runner could be evicted.
+func (l *loader) evictLeastRecentlyUsed(freeSlot bool, reason string) bool {
+	var victim runnerKey
+	var victimInfo runnerInfo
+	found, victimDefunct := false, false
+	for r, runnerInfo := range l.runners {
+		if l.references[runnerInfo.slot] > 0 {
+			continue
+		}
+		defunct := false
+		select {
+		case <-l.slots[runnerInfo.slot].done:
+			defunct = true
+		default:
+		}
+		if !defunct && !freeSlot && isRemoteBackend(l.backends[r.backend]) {
+			continue
+		}
+		older := l.timestamps[runnerInfo.slot].Before(l.timestamps[victimInfo.slot])
+		if !found || (defunct && !victimDefunct) || (defunct == victimDefunct && older) {
+			victim, victimInfo, victimDefunct, found = r, runnerInfo, defunct, true
+		}
+	}
+	if !found {
+		return false
+	}
+	l.log.Infof("Evicting least recently used %s backend runner with model %s (%s) in %s mode",
+		victim.backend, victim.modelID, victimInfo.modelRef, victim.mode,
+	)
+	if victimDefunct {
+		reason = "runner exited"
+	}
+	l.freeRunnerSlot(victimInfo.slot, victim, EventEvict, reason)
+	return true
+}
+
+// evictRunner evicts a specific runner, recording the reason. The caller must
+// hold the loader lock. It returns the number of remaining runners.
+func (l *loader) evictRunner(backend, model string, mode inference.BackendMode, reason string) int {
 	allBackends := backend == ""
 	for r, runnerInfo := range l.runners {
 		unused := l.references[runnerInfo.slot] == 0
@@ -282,12 +556,54 @@ func (l *loader) evictRunner(backend, model string, mode inference.BackendMode)
 			l.log.Infof("Evicting %s backend runner with model %s (%s) in %s mode",
 				r.backend, r.modelID, runnerInfo.modelRef, r.mode,
 			)
-			l.freeRunnerSlot(runnerInfo.slot, r)
+			l.freeRunnerSlot(runnerInfo.slot, r, EventUnload, reason)
 		}
 	}
 	return len(l.runners)
 }
 
+// unloadBackend evicts all runners for the specified backend and stops it from
+// being loaded again until allowBackend is called. It returns an error if any
+// of the backend's runners are still in use.
+func (l *loader) unloadBackend(ctx context.Context, backend string) error {
+	if !l.lock(ctx) {
+		return context.Canceled
+	}
+	defer l.unlock()
+
+	for r, runnerInfo := range l.runners {
+		if r.backend == backend && l.references[runnerInfo.slot] > 0 {
+			return errBackendInUse
+		}
+	}
+	l.uninstalling[backend] = true
+	for r, runnerInfo := range l.runners {
+		if r.backend != backend {
+			continue
+		}
+		l.log.Infof("Evicting %s backend runner with model %s (%s) in %s mode",
+			r.backend, r.modelID, runnerInfo.modelRef, r.mode,
+		)
+		l.freeRunnerSlot(runnerInfo.slot, r, EventUnload, "backend uninstalled")
+	}
+	for key := range l.runnerConfigs {
+		if key.backend == backend {
+			delete(l.runnerConfigs, key)
+		}
+	}
+	l.forgetCrashes(func(key runnerKey) bool { return key.backend == backend })
+	l.broadcast()
+	return nil
+}
+
+// allowBackend allows the specified backend to be loaded again after a failed
+// uninstallation.
+func (l *loader) allowBackend(backend string) {
+	l.lock(context.Background())
+	defer l.unlock()
+	delete(l.uninstalling, backend)
+}
+
 // Unload unloads runners and returns the number of unloaded runners.
 func (l *loader) Unload(ctx context.Context, unload UnloadRequest) int {
 	if !l.lock(ctx) {
@@ -298,6 +614,7 @@ func (l *loader) Unload(ctx context.Context, unload UnloadRequest) int {
 	return len(l.runners) - func() int {
 		if unload.All {
 			l.runnerConfigs = make(map[runnerKey]inference.BackendConfiguration)
+			l.crashes = make(map[runnerKey]*crashRecord)
 			return l.evict(false)
 		} else {
 			for _, model := range unload.Models {
@@ -308,10 +625,15 @@ func (l *loader) Unload(ctx context.Context, unload UnloadRequest) int {
 						delete(l.runnerConfigs, key)
 					}
 				}
-				// Evict both, completion and embedding models. We should consider
-				// accepting a mode parameter in unload requests.
-				l.evictRunner(unload.Backend, modelID, inference.BackendModeCompletion)
-				l.evictRunner(unload.Backend, modelID, inference.BackendModeEmbedding)
+				l.forgetCrashes(func(key runnerKey) bool {
+					return (unload.Backend == "" || key.backend == unload.Backend) && key.modelID == modelID
+				})
+				// Evict the model in every mode. We should consider accepting a
+				// mode parameter in unload requests.
+				l.evictRunner(unload.Backend, modelID, inference.BackendModeCompletion, "unload requested")
+				l.evictRunner(unload.Backend, modelID, inference.BackendModeEmbedding, "unload requested")
+				l.evictRunner(unload.Backend, modelID, inference.BackendModeTranscription, "unload requested")
+				l.evictRunner(unload.Backend, modelID, inference.BackendModeImageGeneration, "unload requested")
 			}
 			return len(l.runners)
 		}
@@ -328,43 +650,64 @@ func stopAndDrainTimer(timer *time.Timer) {
 }
 
 // idleCheckDuration computes the duration until the next idle runner eviction
-// should occur. The caller must hold the loader lock. If no runners are unused,
-// then -1 seconds is returned. If any unused runners are already expired, then
-// 0 seconds is returned. Otherwise a time in the future at which eviction
-// should occur is returned.
+// or restart of a crashed runner should occur. The caller must hold the loader
+// lock. If no unused runners can expire and no restarts are pending, then -1
+// seconds is returned. If any unused runners are already
+// expired, then 0 seconds is returned. Otherwise a time in the future at which
+// eviction should occur is returned.
 func (l *loader) idleCheckDuration() time.Duration {
-	// Compute the oldest usage time for any idle runner.
-	var oldest time.Time
-	for _, runnerInfo := range l.runners {
+	// Compute the earliest expiration time for any idle runner.
+	var earliest time.Time
+	for r, runnerInfo := range l.runners {
 		select {
 		case <-l.slots[runnerInfo.slot].done:
 			// Check immediately if a runner is defunct
 			return 0
 		default:
 		}
-		if l.references[runnerInfo.slot] == 0 {
-			timestamp := l.timestamps[runnerInfo.slot]
-			if oldest.IsZero() || timestamp.Before(oldest) {
-				oldest = timestamp
-			}
+		if l.references[runnerInfo.slot] > 0 || isRemoteBackend(l.backends[r.backend]) {
+			continue
+		}
+		timeout := l.idleTimeout(runnerInfo.slot, r.modelID)
+		if timeout < 0 {
+			continue
 		}
+		expiration := l.timestamps[runnerInfo.slot].Add(timeout)
+		if earliest.IsZero() || expiration.Before(earliest) {
+			earliest = expiration
+		}
+	}
+
+	// Include pending restarts of crashed runners.
+	if restart := l.nextRestart(); !restart.IsZero() && (earliest.IsZero() || restart.Before(earliest)) {
+		earliest = restart
 	}
 
-	// If there are no unused runners, then don't schedule a check.
-	if oldest.IsZero() {
+	// If there are no unused runners that can expire and no pending restarts,
+	// then don't schedule a check.
+	if earliest.IsZero() {
 		return -1 * time.Second
 	}
 
 	// Compute the remaining duration. If negative, check immediately, otherwise
 	// wait until 100 milliseconds after expiration time (to avoid checking
 	// right on the expiration boundary).
-	if remaining := l.runnerIdleTimeout - time.Since(oldest); remaining < 0 {
+	if remaining := time.Until(earliest); remaining < 0 {
 		return 0
 	} else {
 		return remaining + 100*time.Millisecond
 	}
 }
 
+// running returns true if the loader's run loop has enabled loads.
+func (l *loader) running(ctx context.Context) bool {
+	if !l.lock(ctx) {
+		return false
+	}
+	defer l.unlock()
+	return l.loadsEnabled
+}
+
 // run is the run loop for the loader. It drives idle runner eviction. By the
 // time run returns, all runners will have been evicted.
 func (l *loader) run(ctx context.Context) {
@@ -410,9 +753,10 @@ func (l *loader) run(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-idleTimer.C:
-			// Perform eviction.
+			// Perform eviction and restart crashed runners.
 			if l.lock(ctx) {
 				l.evict(true)
+				l.restartCrashed(ctx)
 				if nextCheck := l.idleCheckDuration(); nextCheck >= 0 {
 					idleTimer.Reset(nextCheck)
 				}
@@ -443,21 +787,10 @@ func (l *loader) load(ctx context.Context, backendName, modelID, modelRef string
 
 	// Estimate the amount of memory that will be used by the model and check
 	// that we're even capable of loading it.
-	var runnerConfig *inference.BackendConfiguration
+	runnerConfig := l.withModelSettings(modelID, l.lookupRunnerConfig(backendName, modelID, mode))
 	draftModelID := ""
-	if rc, ok := l.runnerConfigs[makeConfigKey(backendName, modelID, mode)]; ok {
-		runnerConfig = &rc
-		if runnerConfig.Speculative != nil && runnerConfig.Speculative.DraftModel != "" {
-			draftModelID = l.modelManager.ResolveID(runnerConfig.Speculative.DraftModel)
-		}
-	} else if mode == inference.BackendModeReranking {
-		// For reranking mode, fallback to completion config if specific config is not found.
-		if rc, ok := l.runnerConfigs[makeConfigKey(backendName, modelID, inference.BackendModeCompletion)]; ok {
-			runnerConfig = &rc
-			if runnerConfig.Speculative != nil && runnerConfig.Speculative.DraftModel != "" {
-				draftModelID = l.modelManager.ResolveID(runnerConfig.Speculative.DraftModel)
-			}
-		}
+	if runnerConfig != nil && runnerConfig.Speculative != nil && runnerConfig.Speculative.DraftModel != "" {
+		draftModelID = l.modelManager.ResolveID(runnerConfig.Speculative.DraftModel)
 	}
 	memory, err := backend.GetRequiredMemoryForModel(ctx, modelID, runnerConfig)
 	var parseErr *inference.ErrGGUFParse
@@ -479,11 +812,12 @@ func (l *loader) load(ctx context.Context, backendName, modelID, modelRef string
 		formatMemorySize(memory.RAM), formatMemorySize(memory.VRAM),
 		formatMemorySize(l.totalMemory.RAM), formatMemorySize(l.totalMemory.VRAM))
 
-	if l.totalMemory.RAM == 1 {
+	remote := isRemoteBackend(backend)
+	if l.totalMemory.RAM == 1 && !remote {
 		l.log.Warnf("RAM size unknown. Assume model will fit, but only one.")
 		memory.RAM = 1
 	}
-	if l.totalMemory.VRAM == 1 {
+	if l.totalMemory.VRAM == 1 && !remote {
 		l.log.Warnf("VRAM size unknown. Assume model will fit, but only one.")
 		memory.VRAM = 1
 	}
@@ -498,12 +832,33 @@ func (l *loader) load(ctx context.Context, backendName, modelID, modelRef string
 		return nil, errModelTooBig
 	}
 
+	// Record the use of the models, so that they're evicted last to stay
+	// within the store quota.
+	if !remote {
+		l.modelManager.MarkUsed(modelID)
+		if draftModelID != "" {
+			l.modelManager.MarkUsed(draftModelID)
+		}
+	}
+
 	// Acquire the loader lock and defer its release.
 	if !l.lock(ctx) {
 		return nil, context.Canceled
 	}
 	defer l.unlock()
 
+	// Determine whether the runner needs to be placed on specific GPUs. On
+	// systems with multiple GPUs, local runners are placed on specific GPUs,
+	// except for single-device runners too large for any one GPU, which are
+	// left to split themselves across all GPUs.
+	multiDevice := l.usesMultipleDevices(backend)
+	var pinned []int
+	if runnerConfig != nil {
+		pinned = runnerConfig.Devices
+	}
+	placed := !remote && len(l.devices) > 1 &&
+		(multiDevice || len(pinned) > 0 || fitsOnDevice(l.devices, memory.VRAM))
+
 	// Create a polling channel that we can use to detect state changes and
 	// ensure that it's deregistered by the time we return.
 	poll := make(chan struct{}, 1)
@@ -524,19 +879,31 @@ func (l *loader) load(ctx context.Context, backendName, modelID, modelRef string
 			availableVRAM += sharedRAM
 		}
 
+		// Select GPUs for the runner if it needs them.
+		var devices []int
+		devicesAvailable := true
+		if placed {
+			devices, devicesAvailable = placeRunner(l.deviceStates(), memory.VRAM, multiDevice, l.placement, pinned)
+		}
+
 		// If loads are disabled, then there's nothing we can do.
 		if !l.loadsEnabled {
 			return nil, errLoadsDisabled
 		}
+		if l.uninstalling[backendName] {
+			return nil, errBackendUninstalling
+		}
 
 		// See if we can satisfy the request with an existing runner.
-		existing, ok := l.runners[makeRunnerKey(backendName, modelID, draftModelID, mode)]
+		key, fallback := l.selectReplica(makeRunnerKey(backendName, modelID, draftModelID, mode),
+			runnerConfig.ReplicaCount(), runnerConfig.ReplicaRouting(), affinityFromContext(ctx))
+		existing, ok := l.runners[key]
 		if ok {
 			select {
 			case <-l.slots[existing.slot].done:
 				l.log.Warnf("%s runner for %s is defunct. Waiting for it to be evicted.", backendName, existing.modelRef)
 				if l.references[existing.slot] == 0 {
-					l.evictRunner(backendName, modelID, mode)
+					l.freeRunnerSlot(existing.slot, key, EventEvict, "runner exited")
 					// Continue the loop to retry loading after evicting the defunct runner
 					continue
 				} else {
@@ -549,25 +916,41 @@ func (l *loader) load(ctx context.Context, backendName, modelID, modelRef string
 			}
 		}
 
-		// If there's not sufficient memory or all slots are full, then try
-		// evicting unused runners.
-		if memory.RAM > l.availableMemory.RAM || memory.VRAM > availableVRAM || len(l.runners) == len(l.slots) {
+		// If there's no room for another replica of the model, then use a
+		// loaded replica rather than evicting other runners.
+		if fallback != nil && (memory.RAM > l.availableMemory.RAM || memory.VRAM > availableVRAM || !devicesAvailable || len(l.runners) == len(l.slots)) {
+			replica := l.runners[*fallback]
+			select {
+			case <-l.slots[replica.slot].done:
+			default:
+				l.references[replica.slot]++
+				l.timestamps[replica.slot] = time.Time{}
+				return l.slots[replica.slot], nil
+			}
+		}
+
+		// If there's not sufficient memory, GPUs, or all slots are full, then
+		// evict the least recently used runner. Other runners stay resident,
+		// so models that fit in the budget together aren't swapped.
+		if memory.RAM > l.availableMemory.RAM || memory.VRAM > availableVRAM || !devicesAvailable || len(l.runners) == len(l.slots) {
 			l.log.Infof("Evicting to make room: need %s RAM, %s VRAM; have %s RAM, %s VRAM available; %d/%d slots used",
 				formatMemorySize(memory.RAM), formatMemorySize(memory.VRAM),
 				formatMemorySize(l.availableMemory.RAM),
 				formatMemorySize(availableVRAM),
 				len(l.runners), len(l.slots))
-			runnerCountAtLoopStart := len(l.runners)
-			remainingRunners := l.evict(false)
 			// Restart the loop if eviction happened to recompute availableVRAM
 			// and re-evaluate all conditions with the updated state.
-			if remainingRunners < runnerCountAtLoopStart {
+			reason := fmt.Sprintf("make room for %s: need %s RAM, %s VRAM; have %s RAM, %s VRAM available; %d/%d slots used",
+				modelRef, formatMemorySize(memory.RAM), formatMemorySize(memory.VRAM),
+				formatMemorySize(l.availableMemory.RAM), formatMemorySize(availableVRAM),
+				len(l.runners), len(l.slots))
+			if l.evictLeastRecentlyUsed(len(l.runners) == len(l.slots), reason) {
 				continue
 			}
 		}
 
 		// If there's sufficient memory and a free slot, then find the slot.
-		if memory.RAM <= l.availableMemory.RAM && memory.VRAM <= availableVRAM && len(l.runners) < len(l.slots) {
+		if memory.RAM <= l.availableMemory.RAM && memory.VRAM <= availableVRAM && devicesAvailable && len(l.runners) < len(l.slots) {
 			for s, runner := range l.slots {
 				if runner == nil {
 					slot = s
@@ -588,12 +971,28 @@ func (l *loader) load(ctx context.Context, backendName, modelID, modelRef string
 		if slot >= 0 {
 			// runnerConfig was already retrieved earlier (lines 401-405), no need to look it up again
 			// Create the runner.
-			l.log.Infof("Loading %s backend runner with model %s in %s mode", backendName, modelID, mode)
-			runner, err := run(l.log, backend, modelID, modelRef, mode, slot, runnerConfig, l.openAIRecorder)
+			if key.replica > 0 {
+				l.log.Infof("Loading replica %d of %s backend runner with model %s in %s mode", key.replica, backendName, modelID, mode)
+			} else {
+				l.log.Infof("Loading %s backend runner with model %s in %s mode", backendName, modelID, mode)
+			}
+			slotConfig := runnerConfig
+			if len(devices) > 0 {
+				l.log.Infof("Assigning GPUs %v to %s backend runner with model %s", devices, backendName, modelID)
+				assigned := inference.BackendConfiguration{}
+				if runnerConfig != nil {
+					assigned = *runnerConfig
+				}
+				assigned.Devices = devices
+				slotConfig = &assigned
+			}
+			started := time.Now()
+			runner, err := run(l.log, backend, modelID, modelRef, mode, slot, slotConfig, l.openAIRecorder)
 			if err != nil {
 				l.log.Warnf("Unable to start %s backend runner with model %s in %s mode: %v",
 					backendName, modelID, mode, err,
 				)
+				l.recordEvent(EventLoadFailed, key, modelRef, memory, devices, err.Error())
 				return nil, fmt.Errorf("unable to start runner: %w", err)
 			}
 
@@ -608,17 +1007,47 @@ func (l *loader) load(ctx context.Context, backendName, modelID, modelRef string
 				l.log.Warnf("Initialization for %s backend runner with model %s in %s mode failed: %v",
 					backendName, modelID, mode, err,
 				)
+				l.recordEvent(EventLoadFailed, key, modelRef, memory, devices, err.Error())
 				return nil, fmt.Errorf("error waiting for runner to be ready: %w", err)
 			}
 
+			// Refuse to serve models whose tokenizer or chat template produce
+			// broken prompts or output. Remote models are outside of our
+			// control, so they're exempt.
+			if mode == inference.BackendModeCompletion && !remote {
+				if err := runner.checkSanity(ctx, modelRef); err != nil {
+					runner.terminate()
+					l.log.Warnf("Sanity check for %s backend runner with model %s failed: %v",
+						backendName, modelID, err,
+					)
+					l.recordEvent(EventLoadFailed, key, modelRef, memory, devices, err.Error())
+					return nil, err
+				}
+			}
+
 			// Perform registration and return the runner.
-			l.availableMemory.RAM -= memory.RAM
-			l.availableMemory.VRAM -= memory.VRAM
-			l.runners[makeRunnerKey(backendName, modelID, draftModelID, mode)] = runnerInfo{slot, modelRef}
+			l.runners[key] = runnerInfo{slot, modelRef}
 			l.slots[slot] = runner
 			l.references[slot] = 1
 			l.allocations[slot].RAM = memory.RAM
 			l.allocations[slot].VRAM = memory.VRAM
+			l.updateAvailableMemory()
+			l.loads.recordLoad(key, time.Since(started))
+			l.deviceAssignments[slot] = devices
+			if len(devices) > 0 {
+				reason := fmt.Sprintf("%s placement policy", l.placement)
+				if len(pinned) > 0 {
+					reason = "pinned"
+				}
+				l.recordEvent(EventPlace, key, modelRef, memory, devices, reason)
+			}
+			loadReason := ""
+			if fallback != nil {
+				loadReason = "all loaded replicas busy"
+			}
+			l.recordEvent(EventLoad, key, modelRef, memory, devices, loadReason)
+			l.serverMetrics.ObserveLoad(modelRef, key.backend)
+			go l.watch(key, modelRef, runner)
 			return runner, nil
 		}
 
@@ -638,22 +1067,28 @@ func (l *loader) load(ctx context.Context, backendName, modelID, modelRef string
 }
 
 // release releases a runner, which internally decrements its reference count.
-func (l *loader) release(runner *runner) {
+// keepAlive is the keep-alive requested by the released request, if any, which
+// determines how long the runner stays loaded if it's idle.
+func (l *loader) release(runner *runner, keepAlive *KeepAlive) {
 	// Acquire the loader lock and defer its release.
 	l.lock(context.Background())
 	defer l.unlock()
 
 	// Find the runner's slot by iterating through runners
+	var slotKey runnerKey
 	var slotInfo runnerInfo
 	for key, info := range l.runners {
-		if key.backend == runner.backend.Name() && key.modelID == runner.model && key.mode == runner.mode {
-			slotInfo = info
+		if l.slots[info.slot] == runner {
+			slotKey, slotInfo = key, info
 			break
 		}
 	}
 
-	// Decrement the runner's reference count.
+	// Decrement the runner's reference count and record the requested
+	// keep-alive.
 	l.references[slotInfo.slot]--
+	l.requestKeepAlives[slotInfo.slot] = keepAlive
+	l.lastUsed[slotKey.modelID] = time.Now()
 
 	// If the runner's reference count is now zero, then check if it is still
 	// active, and record now as its idle start time and signal the idle
@@ -661,7 +1096,10 @@ func (l *loader) release(runner *runner) {
 	if l.references[slotInfo.slot] == 0 {
 		select {
 		case <-runner.done:
-			l.evictRunner(runner.backend.Name(), runner.model, runner.mode)
+			l.log.Infof("Evicting %s backend runner with model %s (%s) in %s mode",
+				slotKey.backend, slotKey.modelID, slotInfo.modelRef, slotKey.mode,
+			)
+			l.freeRunnerSlot(slotInfo.slot, slotKey, EventEvict, "runner exited")
 		default:
 			l.timestamps[slotInfo.slot] = time.Now()
 			select {
@@ -675,6 +1113,54 @@ func (l *loader) release(runner *runner) {
 	l.broadcast()
 }
 
+// lookupRunnerConfig returns a copy of the runner configuration for a model,
+// or nil if it hasn't been configured.
+func (l *loader) lookupRunnerConfig(backendName, modelID string, mode inference.BackendMode) *inference.BackendConfiguration {
+	if rc, ok := l.runnerConfigs[makeConfigKey(backendName, modelID, mode)]; ok {
+		return &rc
+	} else if mode == inference.BackendModeReranking {
+		// For reranking mode, fallback to completion config if specific config is not found.
+		if rc, ok := l.runnerConfigs[makeConfigKey(backendName, modelID, inference.BackendModeCompletion)]; ok {
+			return &rc
+		}
+	}
+	return nil
+}
+
+// withModelSettings returns a runner configuration with the runtime flags of
+// the settings stored with the model appended, so that they take precedence
+// over the configured flags.
+func (l *loader) withModelSettings(modelID string, runnerConfig *inference.BackendConfiguration) *inference.BackendConfiguration {
+	if l.modelManager == nil {
+		return runnerConfig
+	}
+	settings, err := l.modelManager.GetSettings(modelID)
+	if err != nil {
+		if !errors.Is(err, distribution.ErrModelNotFound) {
+			l.log.Warnf("Failed to read the settings of model %s: %v", modelID, err)
+		}
+		return runnerConfig
+	}
+	if len(settings.RuntimeFlags) == 0 {
+		return runnerConfig
+	}
+	var config inference.BackendConfiguration
+	if runnerConfig != nil {
+		config = *runnerConfig
+	}
+	config.RuntimeFlags = append(slices.Clone(config.RuntimeFlags), settings.RuntimeFlags...)
+	return &config
+}
+
+// getRunnerConfig is like lookupRunnerConfig, but acquires the loader lock.
+func (l *loader) getRunnerConfig(ctx context.Context, backendName, modelID string, mode inference.BackendMode) *inference.BackendConfiguration {
+	if !l.lock(ctx) {
+		return nil
+	}
+	defer l.unlock()
+	return l.lookupRunnerConfig(backendName, modelID, mode)
+}
+
 func (l *loader) setRunnerConfig(ctx context.Context, backendName, modelID string, mode inference.BackendMode, runnerConfig inference.BackendConfiguration) error {
 	l.lock(ctx)
 	defer l.unlock()
@@ -695,15 +1181,15 @@ func (l *loader) setRunnerConfig(ctx context.Context, backendName, modelID strin
 	}
 	rKey := makeRunnerKey(backendName, modelID, draftModelID, mode)
 
-	// If there's an active runner whose configuration we want to override, then
-	// try evicting it (because it may not be in use).
-	if _, ok := l.runners[rKey]; ok {
-		l.evictRunner(backendName, modelID, mode)
+	// If there are active runners whose configuration we want to override,
+	// then try evicting them (because they may not be in use).
+	if l.hasReplicas(rKey) {
+		l.evictRunner(backendName, modelID, mode, "reconfigured")
 	}
 
-	// If there's still then active runner, then we can't (or at least
+	// If there are still active runners, then we can't (or at least
 	// shouldn't) change the configuration.
-	if _, ok := l.runners[rKey]; ok {
+	if l.hasReplicas(rKey) {
 		return errRunnerAlreadyActive
 	}
 
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/docker/model-runner/pkg/gpuinfo"
 	"github.com/docker/model-runner/pkg/inference"
 	"github.com/sirupsen/logrus"
 )
@@ -57,6 +58,7 @@ func (b *fastFailBackend) Run(ctx context.Context, socket, model string, modelRe
 // mockSystemMemoryInfo implements memory.SystemMemoryInfo for testing
 type mockSystemMemoryInfo struct {
 	totalMemory inference.RequiredMemory
+	devices     []gpuinfo.Device
 }
 
 func (m *mockSystemMemoryInfo) HaveSufficientMemory(req inference.RequiredMemory) (bool, error) {
@@ -67,6 +69,10 @@ func (m *mockSystemMemoryInfo) GetTotalMemory() inference.RequiredMemory {
 	return m.totalMemory
 }
 
+func (m *mockSystemMemoryInfo) GetGPUDevices() []gpuinfo.Device {
+	return m.devices
+}
+
 // createTestLogger creates a logger for testing
 func createTestLogger() *logrus.Entry {
 	log := logrus.New()
@@ -487,3 +493,172 @@ func TestUnusedRunnerEvictionTriggersRetry(t *testing.T) {
 		t.Error("Unexpected success; acceptable but unusual with fastFail backend")
 	}
 }
+
+// TestLoadEvictsLeastRecentlyUsedRunner tests that load() only evicts the least
+// recently used runner when memory runs out, keeping other runners resident.
+func TestLoadEvictsLeastRecentlyUsedRunner(t *testing.T) {
+	log := createTestLogger()
+
+	backend := &fastFailBackend{mockBackend: mockBackend{
+		name: "test-backend",
+		requiredMemory: inference.RequiredMemory{
+			RAM:  1 * GB,
+			VRAM: 1 * GB,
+		},
+	}}
+
+	// System has enough memory for three runners
+	sysMemInfo := &mockSystemMemoryInfo{
+		totalMemory: inference.RequiredMemory{
+			RAM:  3 * GB,
+			VRAM: 3 * GB,
+		},
+	}
+
+	backends := map[string]inference.Backend{"test-backend": backend}
+	loader := newLoader(log, backends, nil, nil, sysMemInfo)
+
+	if !loader.lock(context.Background()) {
+		t.Fatal("Failed to acquire loader lock")
+	}
+	loader.loadsEnabled = true
+
+	// Allocate enough slots regardless of the number of CPUs
+	const nSlots = 4
+	loader.slots = make([]*runner, nSlots)
+	loader.references = make([]uint, nSlots)
+	loader.allocations = make([]inference.RequiredMemory, nSlots)
+	loader.timestamps = make([]time.Time, nSlots)
+	loader.requestKeepAlives = make([]*KeepAlive, nSlots)
+	loader.deviceAssignments = make([][]int, nSlots)
+
+	// Install three unused runners, last used at different times
+	now := time.Now()
+	models := []string{"recent", "oldest", "older"}
+	lastUsed := []time.Time{now, now.Add(-2 * time.Minute), now.Add(-time.Minute)}
+	for slot, model := range models {
+		loader.slots[slot] = createAliveTerminableMockRunner(log, backend)
+		loader.runners[makeRunnerKey("test-backend", model, "", inference.BackendModeCompletion)] = runnerInfo{
+			slot:     slot,
+			modelRef: model + ":latest",
+		}
+		loader.allocations[slot] = inference.RequiredMemory{RAM: 1 * GB, VRAM: 1 * GB}
+		loader.timestamps[slot] = lastUsed[slot]
+	}
+	loader.availableMemory.RAM = 0
+	loader.availableMemory.VRAM = 0
+
+	loader.unlock()
+
+	// Loading another model requires a single eviction
+	if _, err := loader.load(context.Background(), "test-backend", "model1", "model1:latest", inference.BackendModeCompletion); err == nil {
+		t.Error("Unexpected success with fastFail backend")
+	}
+
+	if !loader.lock(context.Background()) {
+		t.Fatal("Failed to acquire loader lock")
+	}
+	defer loader.unlock()
+	for _, model := range models {
+		_, resident := loader.runners[makeRunnerKey("test-backend", model, "", inference.BackendModeCompletion)]
+		if resident == (model == "oldest") {
+			t.Errorf("Unexpected residency for runner %s: %v", model, resident)
+		}
+	}
+}
+
+// TestIdleTimeout tests that request keep-alives override model keep-alives,
+// which override the default idle timeout, and that runners kept alive
+// indefinitely aren't scheduled for eviction.
+func TestIdleTimeout(t *testing.T) {
+	log := createTestLogger()
+	backend := &mockBackend{name: "test-backend"}
+	loader := newLoader(log, map[string]inference.Backend{"test-backend": backend}, nil, nil, &mockSystemMemoryInfo{})
+	loader.slots = make([]*runner, 1)
+	loader.references = make([]uint, 1)
+	loader.timestamps = make([]time.Time, 1)
+	loader.requestKeepAlives = make([]*KeepAlive, 1)
+
+	loader.slots[0] = createAliveTerminableMockRunner(log, backend)
+	defer loader.slots[0].cancel()
+	loader.runners[makeRunnerKey("test-backend", "model1", "", inference.BackendModeCompletion)] = runnerInfo{slot: 0}
+	loader.timestamps[0] = time.Now()
+
+	if timeout := loader.idleTimeout(0, "model1"); timeout != defaultRunnerIdleTimeout {
+		t.Errorf("Expected default idle timeout, got %s", timeout)
+	}
+
+	if err := loader.setKeepAlive(context.Background(), "model1", KeepAliveForever); err != nil {
+		t.Fatal(err)
+	}
+	if timeout := loader.idleTimeout(0, "model1"); timeout >= 0 {
+		t.Errorf("Expected model to be kept alive indefinitely, got %s", timeout)
+	}
+	if d := loader.idleCheckDuration(); d >= 0 {
+		t.Errorf("Expected no idle check for runner kept alive indefinitely, got %s", d)
+	}
+
+	requested := KeepAlive(0)
+	loader.requestKeepAlives[0] = &requested
+	if d := loader.idleCheckDuration(); d != 0 {
+		t.Errorf("Expected immediate idle check for runner with zero keep-alive, got %s", d)
+	}
+	if remaining := loader.evict(true); remaining != 0 {
+		t.Errorf("Expected runner with zero keep-alive to be evicted, %d runners remain", remaining)
+	}
+}
+
+// TestSetTotalMemory tests that reducing the total memory evicts the least
+// recently used runners until the remaining ones fit, and that the available
+// memory follows the total memory.
+func TestSetTotalMemory(t *testing.T) {
+	log := createTestLogger()
+	backend := &mockBackend{name: "test-backend"}
+	sysMemInfo := &mockSystemMemoryInfo{
+		totalMemory: inference.RequiredMemory{RAM: 3 * GB, VRAM: 3 * GB},
+	}
+	loader := newLoader(log, map[string]inference.Backend{"test-backend": backend}, nil, nil, sysMemInfo)
+
+	const nSlots = 4
+	loader.slots = make([]*runner, nSlots)
+	loader.references = make([]uint, nSlots)
+	loader.allocations = make([]inference.RequiredMemory, nSlots)
+	loader.timestamps = make([]time.Time, nSlots)
+	loader.requestKeepAlives = make([]*KeepAlive, nSlots)
+	loader.deviceAssignments = make([][]int, nSlots)
+
+	// Install three unused runners, last used at different times
+	now := time.Now()
+	models := []string{"recent", "oldest", "older"}
+	lastUsed := []time.Time{now, now.Add(-2 * time.Minute), now.Add(-time.Minute)}
+	for slot, model := range models {
+		loader.slots[slot] = createAliveTerminableMockRunner(log, backend)
+		loader.runners[makeRunnerKey("test-backend", model, "", inference.BackendModeCompletion)] = runnerInfo{
+			slot:     slot,
+			modelRef: model + ":latest",
+		}
+		loader.allocations[slot] = inference.RequiredMemory{RAM: 1 * GB, VRAM: 1 * GB}
+		loader.timestamps[slot] = lastUsed[slot]
+	}
+	loader.updateAvailableMemory()
+
+	if err := loader.setTotalMemory(context.Background(), inference.RequiredMemory{RAM: 2 * GB, VRAM: 2 * GB}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, model := range models {
+		_, resident := loader.runners[makeRunnerKey("test-backend", model, "", inference.BackendModeCompletion)]
+		if resident == (model == "oldest") {
+			t.Errorf("Unexpected residency for runner %s: %v", model, resident)
+		}
+	}
+	if loader.availableMemory != (inference.RequiredMemory{}) {
+		t.Errorf("Expected no available memory, got %+v", loader.availableMemory)
+	}
+
+	if err := loader.setTotalMemory(context.Background(), inference.RequiredMemory{RAM: 4 * GB, VRAM: 4 * GB}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := (inference.RequiredMemory{RAM: 2 * GB, VRAM: 2 * GB}); loader.availableMemory != want {
+		t.Errorf("Expected %+v available memory, got %+v", want, loader.availableMemory)
+	}
+}
@@ -0,0 +1,227 @@
+package scheduling
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTopLogprobs is the largest number of most likely tokens whose log
+// probabilities can be requested at each position.
+const maxTopLogprobs = 20
+
+// tokenLogprob is the log probability of a token of a chat completion, along
+// with those of the most likely tokens at its position.
+type tokenLogprob struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes"`
+	TopLogprobs []topLogprob `json:"top_logprobs"`
+}
+
+// topLogprob is the log probability of one of the most likely tokens at a
+// position of a chat completion.
+type topLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
+}
+
+// completionLogprobs are the log probabilities of the tokens of a completion,
+// in the format of completions.
+type completionLogprobs struct {
+	Tokens        []string             `json:"tokens"`
+	TokenLogprobs []float64            `json:"token_logprobs"`
+	TopLogprobs   []map[string]float64 `json:"top_logprobs"`
+	TextOffset    []int                `json:"text_offset"`
+}
+
+// parseLogprobs validates the log probability fields of a chat completion or
+// completion request, and returns whether log probabilities are requested.
+// Chat completions request them with a boolean logprobs and the number of
+// most likely tokens with top_logprobs, and completions with that number as
+// logprobs. Bodies that aren't JSON objects are left for the backend to
+// reject.
+func parseLogprobs(body []byte, chat bool) (bool, error) {
+	var request struct {
+		Logprobs    json.RawMessage `json:"logprobs"`
+		TopLogprobs json.RawMessage `json:"top_logprobs"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return false, nil
+	}
+	if !chat {
+		if isNullField(request.Logprobs) {
+			return false, nil
+		}
+		if err := checkTopLogprobs(request.Logprobs, "logprobs"); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	var logprobs bool
+	if !isNullField(request.Logprobs) {
+		if err := json.Unmarshal(request.Logprobs, &logprobs); err != nil {
+			return false, errors.New("logprobs must be a boolean")
+		}
+	}
+	if !isNullField(request.TopLogprobs) {
+		if err := checkTopLogprobs(request.TopLogprobs, "top_logprobs"); err != nil {
+			return false, err
+		}
+		if !logprobs {
+			return false, errors.New("top_logprobs requires logprobs to be true")
+		}
+	}
+	return logprobs, nil
+}
+
+// checkTopLogprobs checks that a field is a number of most likely tokens.
+func checkTopLogprobs(raw json.RawMessage, field string) error {
+	var n int
+	if err := json.Unmarshal(raw, &n); err != nil || n < 0 || n > maxTopLogprobs {
+		return fmt.Errorf("%s must be an integer between 0 and %d", field, maxTopLogprobs)
+	}
+	return nil
+}
+
+// isNullField returns true if a field of a request is missing or null.
+func isNullField(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// completionLogprobsField translates the log probability fields of a chat
+// completion request into the logprobs field of a completion request.
+func completionLogprobsField(request map[string]json.RawMessage) {
+	var logprobs bool
+	json.Unmarshal(request["logprobs"], &logprobs)
+	top := request["top_logprobs"]
+	delete(request, "logprobs")
+	delete(request, "top_logprobs")
+	if !logprobs {
+		return
+	}
+	if isNullField(top) {
+		top = json.RawMessage("0")
+	}
+	request["logprobs"] = top
+}
+
+// chatLogprobs translates the log probabilities of a choice of a completion
+// into those of a chat completion. Log probabilities that are already in the
+// format of chat completions, as llama.cpp returns them, are returned as-is.
+func chatLogprobs(logprobs any) any {
+	encoded, err := json.Marshal(logprobs)
+	if err != nil {
+		return logprobs
+	}
+	var completion completionLogprobs
+	if err := json.Unmarshal(encoded, &completion); err != nil || completion.Tokens == nil {
+		return logprobs
+	}
+	content := make([]tokenLogprob, len(completion.Tokens))
+	for i, token := range completion.Tokens {
+		content[i] = tokenLogprob{Token: token, Bytes: tokenBytes(token), TopLogprobs: []topLogprob{}}
+		if i < len(completion.TokenLogprobs) {
+			content[i].Logprob = completion.TokenLogprobs[i]
+		}
+		if i < len(completion.TopLogprobs) {
+			for token, logprob := range completion.TopLogprobs[i] {
+				content[i].TopLogprobs = append(content[i].TopLogprobs, topLogprob{Token: token, Logprob: logprob, Bytes: tokenBytes(token)})
+			}
+			// Sort the most likely tokens by decreasing probability, since
+			// they're unordered in the format of completions.
+			slices.SortFunc(content[i].TopLogprobs, func(a, b topLogprob) int {
+				return cmp.Or(cmp.Compare(b.Logprob, a.Logprob), strings.Compare(a.Token, b.Token))
+			})
+		}
+	}
+	return map[string]any{"content": content}
+}
+
+// tokenBytes returns the UTF-8 bytes of a token.
+func tokenBytes(token string) []int {
+	encoded := make([]int, len(token))
+	for i := range len(token) {
+		encoded[i] = int(token[i])
+	}
+	return encoded
+}
+
+// completionLogprobsTranslator translates the log probabilities of completion
+// responses, or of the chunks of a streamed completion response, that are in
+// the format of chat completions, as llama.cpp returns them, into the format
+// of completions, which evaluation harnesses read.
+type completionLogprobsTranslator struct {
+	// offsets are the text offsets of the next tokens of the choices of a
+	// stream, by index.
+	offsets map[float64]int
+}
+
+// newCompletionLogprobsTranslator creates a translator of the log
+// probabilities of a completion response.
+func newCompletionLogprobsTranslator() *completionLogprobsTranslator {
+	return &completionLogprobsTranslator{offsets: make(map[float64]int)}
+}
+
+// translate translates the log probabilities of a response or a chunk. Anything
+// else, such as the end of a stream, is returned as-is.
+func (t *completionLogprobsTranslator) translate(data []byte, _ bool) []byte {
+	var response map[string]any
+	if err := json.Unmarshal(data, &response); err != nil {
+		return data
+	}
+	choices, _ := response["choices"].([]any)
+	translated := false
+	for _, c := range choices {
+		choice, ok := c.(map[string]any)
+		if !ok || choice["logprobs"] == nil {
+			continue
+		}
+		encoded, err := json.Marshal(choice["logprobs"])
+		if err != nil {
+			continue
+		}
+		var chat struct {
+			Content []tokenLogprob `json:"content"`
+		}
+		if err := json.Unmarshal(encoded, &chat); err != nil || chat.Content == nil {
+			continue
+		}
+
+		index, _ := choice["index"].(float64)
+		offset := t.offsets[index]
+		logprobs := completionLogprobs{
+			Tokens:        make([]string, len(chat.Content)),
+			TokenLogprobs: make([]float64, len(chat.Content)),
+			TopLogprobs:   make([]map[string]float64, len(chat.Content)),
+			TextOffset:    make([]int, len(chat.Content)),
+		}
+		for i, token := range chat.Content {
+			logprobs.Tokens[i] = token.Token
+			logprobs.TokenLogprobs[i] = token.Logprob
+			logprobs.TopLogprobs[i] = make(map[string]float64, len(token.TopLogprobs))
+			for _, top := range token.TopLogprobs {
+				logprobs.TopLogprobs[i][top.Token] = top.Logprob
+			}
+			logprobs.TextOffset[i] = offset
+			offset += utf8.RuneCountInString(token.Token)
+		}
+		t.offsets[index] = offset
+		choice["logprobs"] = logprobs
+		translated = true
+	}
+	if !translated {
+		return data
+	}
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return data
+	}
+	return encoded
+}
@@ -0,0 +1,98 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseLogprobs(t *testing.T) {
+	for _, tt := range []struct {
+		body      string
+		chat      bool
+		requested bool
+		err       bool
+	}{
+		{body: `{"logprobs": true, "top_logprobs": 5}`, chat: true, requested: true},
+		{body: `{"logprobs": false}`, chat: true},
+		{body: `{"model": "m"}`, chat: true},
+		{body: `{"top_logprobs": 5}`, chat: true, err: true},
+		{body: `{"logprobs": 1}`, chat: true, err: true},
+		{body: `{"logprobs": true, "top_logprobs": 21}`, chat: true, err: true},
+		{body: `{"logprobs": 0}`, requested: true},
+		{body: `{"logprobs": null}`},
+		{body: `{"logprobs": true}`, err: true},
+		{body: `{"logprobs": -1}`, err: true},
+	} {
+		requested, err := parseLogprobs([]byte(tt.body), tt.chat)
+		if requested != tt.requested || (err != nil) != tt.err {
+			t.Errorf("Unexpected result for %s: %t, %v", tt.body, requested, err)
+		}
+	}
+}
+
+func TestRenderedChatLogprobs(t *testing.T) {
+	body, err := renderChatRequest("{{range .Messages}}{{.Content}}{{end}}", []byte(`{"messages": [{"role": "user", "content": "Hi"}], "logprobs": true, "top_logprobs": 2}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		t.Fatal(err)
+	}
+	if string(request["logprobs"]) != "2" || request["top_logprobs"] != nil {
+		t.Errorf("Expected the completion to request 2 log probabilities, got %s", body)
+	}
+
+	// Log probabilities in the format of completions are translated.
+	translated := completionToChat([]byte(`{"choices":[{"index":0,"text":"Hi","logprobs":{"tokens":["Hi"],"token_logprobs":[-0.5],"top_logprobs":[{"Hey":-2,"Hi":-0.5}],"text_offset":[0]},"finish_reason":"stop"}]}`), false)
+	expected := `{"content":[{"token":"Hi","logprob":-0.5,"bytes":[72,105],"top_logprobs":[{"token":"Hi","logprob":-0.5,"bytes":[72,105]},{"token":"Hey","logprob":-2,"bytes":[72,101,121]}]}]}`
+	var response struct {
+		Choices []struct {
+			Logprobs json.RawMessage `json:"logprobs"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(translated, &response); err != nil {
+		t.Fatal(err)
+	}
+	if logprobs := string(response.Choices[0].Logprobs); logprobs != expected {
+		t.Errorf("Expected log probabilities %s, got %s", expected, logprobs)
+	}
+}
+
+func TestCompletionLogprobsTranslator(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := &chatResponseWriter{ResponseWriter: recorder, translate: newCompletionLogprobsTranslator().translate}
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, token := range []string{"Hé", "llo"} {
+		fmt.Fprintf(w, `data: {"choices":[{"index":0,"text":%q,"logprobs":{"content":[{"token":%q,"logprob":-1,"top_logprobs":[{"token":%q,"logprob":-1}]}]}}]}`+"\n\n", token, token, token)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	w.finish()
+
+	var offsets []int
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Choices []struct {
+				Logprobs completionLogprobs `json:"logprobs"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		logprobs := chunk.Choices[0].Logprobs
+		if len(logprobs.Tokens) != 1 || logprobs.TokenLogprobs[0] != -1 || logprobs.TopLogprobs[0][logprobs.Tokens[0]] != -1 {
+			t.Errorf("Unexpected log probabilities %+v", logprobs)
+		}
+		offsets = append(offsets, logprobs.TextOffset...)
+	}
+	if len(offsets) != 2 || offsets[0] != 0 || offsets[1] != 2 {
+		t.Errorf("Expected the text offsets to be counted in characters across chunks, got %v", offsets)
+	}
+}
@@ -0,0 +1,192 @@
+package scheduling
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/docker/model-runner/pkg/gpuinfo"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// PlacementPolicy determines how runners are placed on GPUs on systems with
+// multiple GPUs.
+type PlacementPolicy string
+
+const (
+	// PlacementSpread places runners on the GPUs with the most free memory,
+	// spreading load across GPUs.
+	PlacementSpread PlacementPolicy = "spread"
+	// PlacementPack places runners on the GPUs with the least free memory that
+	// fits them, keeping other GPUs free for large models.
+	PlacementPack PlacementPolicy = "pack"
+)
+
+// ParsePlacementPolicy parses a GPU placement policy. An empty string yields
+// PlacementSpread.
+func ParsePlacementPolicy(s string) (PlacementPolicy, error) {
+	switch policy := PlacementPolicy(s); policy {
+	case "":
+		return PlacementSpread, nil
+	case PlacementSpread, PlacementPack:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid placement policy %q (expected %q or %q)", s, PlacementSpread, PlacementPack)
+	}
+}
+
+// usesMultipleDevices returns true if the loader should assign GPUs to
+// runners for the specified backend.
+func (l *loader) usesMultipleDevices(backend inference.Backend) bool {
+	multiDevice, ok := backend.(inference.MultiDeviceBackend)
+	return ok && multiDevice.SupportsMultipleDevices() && len(l.devices) > 1
+}
+
+// isRemoteBackend returns true if the backend forwards requests to external
+// servers.
+func isRemoteBackend(backend inference.Backend) bool {
+	remote, ok := backend.(inference.RemoteBackend)
+	return ok && remote.Remote()
+}
+
+// supportsAdapters returns true if the backend can apply adapters to models.
+func supportsAdapters(backend inference.Backend) bool {
+	adapters, ok := backend.(inference.AdapterBackend)
+	return ok && adapters.SupportsAdapters()
+}
+
+// appliesChatTemplates returns true if the backend applies the Jinja chat
+// templates of model bundles.
+func appliesChatTemplates(backend inference.Backend) bool {
+	templates, ok := backend.(inference.ChatTemplateBackend)
+	return ok && templates.AppliesChatTemplates()
+}
+
+// rawRerankScores returns true if the backend scores reranked documents with
+// raw logits rather than probabilities.
+func rawRerankScores(backend inference.Backend) bool {
+	rerank, ok := backend.(inference.RerankBackend)
+	return ok && rerank.RawRerankScores()
+}
+
+// deviceState describes the current use of a GPU.
+type deviceState struct {
+	// index is the device index.
+	index int
+	// total is the total memory of the device in bytes.
+	total uint64
+	// free is the memory of the device that isn't allocated to runners.
+	free uint64
+	// used is true if any runner is assigned to the device.
+	used bool
+	// exclusive is true if a multi-device runner is assigned to the device.
+	exclusive bool
+}
+
+// deviceStates returns the current use of each GPU. A runner's VRAM allocation
+// is assumed to be split evenly across its GPUs. The caller must hold the
+// loader lock.
+func (l *loader) deviceStates() []deviceState {
+	states := make([]deviceState, len(l.devices))
+	positions := make(map[int]int, len(l.devices))
+	for i, device := range l.devices {
+		states[i] = deviceState{index: device.Index, total: device.VRAM, free: device.VRAM}
+		positions[device.Index] = i
+	}
+	for slot, devices := range l.deviceAssignments {
+		if len(devices) == 0 {
+			continue
+		}
+		exclusive := l.slots[slot] != nil && l.usesMultipleDevices(l.slots[slot].backend)
+		share := l.allocations[slot].VRAM / uint64(len(devices))
+		for _, index := range devices {
+			p, ok := positions[index]
+			if !ok {
+				continue
+			}
+			states[p].free -= min(share, states[p].free)
+			states[p].used = true
+			states[p].exclusive = states[p].exclusive || exclusive
+		}
+	}
+	return states
+}
+
+// placeRunner selects the GPUs for a runner requiring the specified VRAM.
+// Multi-device runners may span several GPUs, but don't share them with other
+// runners, since they reserve a fixed share of each GPU's memory. Other
+// runners are placed on a single GPU according to the policy. If pinned is
+// non-empty, the runner is placed on exactly those GPUs. It returns the
+// selected device indices in ascending order and false if the GPUs currently
+// lack the capacity for the runner. If the requirement is unknown (i.e. the
+// sentinel value 1 or 0), the runner is assumed to fit on any single GPU.
+func placeRunner(states []deviceState, required uint64, multiDevice bool, policy PlacementPolicy, pinned []int) ([]int, bool) {
+	available := func(state deviceState) bool {
+		return !state.exclusive && (!multiDevice || !state.used)
+	}
+
+	// Place pinned runners on their GPUs if they have capacity.
+	if len(pinned) > 0 {
+		var free uint64
+		for _, index := range pinned {
+			p := slices.IndexFunc(states, func(state deviceState) bool { return state.index == index })
+			if p < 0 || !available(states[p]) {
+				return nil, false
+			}
+			free += states[p].free
+		}
+		if required > 1 && free < required {
+			return nil, false
+		}
+		selected := slices.Clone(pinned)
+		slices.Sort(selected)
+		return slices.Compact(selected), true
+	}
+
+	candidates := slices.DeleteFunc(slices.Clone(states), func(state deviceState) bool {
+		return !available(state)
+	})
+
+	// Prefer a single GPU, selected according to the policy.
+	best := -1
+	for i, state := range candidates {
+		if state.free < required {
+			continue
+		}
+		if best < 0 ||
+			(policy == PlacementPack && state.free < candidates[best].free) ||
+			(policy != PlacementPack && state.free > candidates[best].free) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		return []int{candidates[best].index}, true
+	}
+	if !multiDevice {
+		return nil, false
+	}
+
+	// Otherwise, split multi-device runners across the fewest GPUs, preferring
+	// those with the most free memory.
+	slices.SortStableFunc(candidates, func(a, b deviceState) int {
+		return cmp.Compare(b.free, a.free)
+	})
+	var selected []int
+	var free uint64
+	for _, state := range candidates {
+		selected = append(selected, state.index)
+		free += state.free
+		if free >= required {
+			slices.Sort(selected)
+			return selected, true
+		}
+	}
+	return nil, false
+}
+
+// fitsOnDevice returns true if the specified VRAM fits on a single GPU.
+func fitsOnDevice(devices []gpuinfo.Device, required uint64) bool {
+	return slices.ContainsFunc(devices, func(device gpuinfo.Device) bool {
+		return device.VRAM >= required
+	})
+}
@@ -0,0 +1,98 @@
+package scheduling
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPlaceRunner(t *testing.T) {
+	const gb = 1024 * 1024 * 1024
+	idle := func() []deviceState {
+		return []deviceState{
+			{index: 0, total: 24 * gb, free: 24 * gb},
+			{index: 1, total: 80 * gb, free: 80 * gb},
+			{index: 2, total: 80 * gb, free: 80 * gb},
+			{index: 3, total: 24 * gb, free: 24 * gb},
+		}
+	}
+	// partial has 60 GB allocated on device 1 by a single-device runner and
+	// device 2 held by a multi-device runner.
+	partial := func() []deviceState {
+		states := idle()
+		states[1].free, states[1].used = 20*gb, true
+		states[2].free, states[2].used, states[2].exclusive = 0, true, true
+		return states
+	}
+
+	tests := []struct {
+		name        string
+		states      []deviceState
+		required    uint64
+		multiDevice bool
+		policy      PlacementPolicy
+		pinned      []int
+		expected    []int
+		ok          bool
+	}{
+		{name: "unknown requirement", states: idle(), required: 1, multiDevice: true, expected: []int{1}, ok: true},
+		{name: "fits on largest device", states: idle(), required: 60 * gb, multiDevice: true, expected: []int{1}, ok: true},
+		{name: "needs two devices", states: idle(), required: 120 * gb, multiDevice: true, expected: []int{1, 2}, ok: true},
+		{name: "needs all devices", states: idle(), required: 200 * gb, multiDevice: true, expected: []int{0, 1, 2, 3}, ok: true},
+		{name: "exceeds all devices", states: idle(), required: 300 * gb, multiDevice: true, ok: false},
+		{name: "multi-device skips used devices", states: partial(), required: 40 * gb, multiDevice: true, expected: []int{0, 3}, ok: true},
+		{name: "insufficient unused devices", states: partial(), required: 60 * gb, multiDevice: true, ok: false},
+		{name: "spread", states: partial(), required: 8 * gb, policy: PlacementSpread, expected: []int{0}, ok: true},
+		{name: "pack", states: partial(), required: 8 * gb, policy: PlacementPack, expected: []int{1}, ok: true},
+		{name: "pack skips full devices", states: partial(), required: 22 * gb, policy: PlacementPack, expected: []int{0}, ok: true},
+		{name: "single device only", states: idle(), required: 100 * gb, ok: false},
+		{name: "skips exclusive devices", states: partial(), required: 30 * gb, ok: false},
+		{name: "pinned", states: partial(), required: 8 * gb, pinned: []int{3}, expected: []int{3}, ok: true},
+		{name: "pinned to multiple devices", states: idle(), required: 40 * gb, pinned: []int{3, 0}, expected: []int{0, 3}, ok: true},
+		{name: "pinned device full", states: partial(), required: 30 * gb, pinned: []int{1}, ok: false},
+		{name: "pinned device exclusive", states: partial(), required: 8 * gb, pinned: []int{2}, ok: false},
+		{name: "pinned device missing", states: idle(), required: 8 * gb, pinned: []int{7}, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, ok := placeRunner(tt.states, tt.required, tt.multiDevice, tt.policy, tt.pinned)
+			if ok != tt.ok {
+				t.Fatalf("expected ok=%v, got %v", tt.ok, ok)
+			}
+			if !slices.Equal(selected, tt.expected) {
+				t.Errorf("expected devices %v, got %v", tt.expected, selected)
+			}
+		})
+	}
+}
+
+func TestParsePlacementPolicy(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected PlacementPolicy
+		wantErr  bool
+	}{
+		{value: "", expected: PlacementSpread},
+		{value: "spread", expected: PlacementSpread},
+		{value: "pack", expected: PlacementPack},
+		{value: "round-robin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			policy, err := ParsePlacementPolicy(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", policy)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if policy != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, policy)
+			}
+		})
+	}
+}
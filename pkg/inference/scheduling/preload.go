@@ -0,0 +1,208 @@
+package scheduling
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+const (
+	// warmUpTimeout bounds the time spent warming up a preloaded runner.
+	warmUpTimeout = 5 * time.Minute
+	// warmUpTokens is the number of tokens generated to warm up a runner.
+	warmUpTokens = 16
+	// startPollInterval is the interval at which preloading polls for the
+	// scheduler to start.
+	startPollInterval = 100 * time.Millisecond
+)
+
+// PreloadEntry declares a model that's loaded when the runner starts.
+type PreloadEntry struct {
+	// Model is the model reference.
+	Model string
+	// KeepAlive is the keep-alive configured for the model, if set.
+	KeepAlive *KeepAlive
+}
+
+// ParsePreloadList parses a comma-separated list of models to preload, each
+// optionally followed by "=" and its keep-alive, e.g.
+// "ai/smollm2=-1,ai/mxbai-embed-large".
+func ParsePreloadList(s string) ([]PreloadEntry, error) {
+	var entries []PreloadEntry
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		model, keepAlive, hasKeepAlive := strings.Cut(item, "=")
+		entry := PreloadEntry{Model: strings.TrimSpace(model)}
+		if entry.Model == "" {
+			return nil, fmt.Errorf("invalid preload entry %q: model is required", item)
+		}
+		if hasKeepAlive {
+			k, err := ParseKeepAlive(keepAlive)
+			if err != nil {
+				return nil, fmt.Errorf("invalid preload entry %q: %w", item, err)
+			}
+			entry.KeepAlive = &k
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Preload pulls the specified models if they're missing, then loads and warms
+// them up one at a time, so that the first requests for them don't pay
+// cold-start costs. Failures are logged and don't prevent the remaining models
+// from being preloaded. It blocks until preloading completes or ctx is
+// cancelled.
+func (s *Scheduler) Preload(ctx context.Context, entries []PreloadEntry) {
+	if err := s.waitUntilRunning(ctx); err != nil {
+		return
+	}
+	for _, entry := range entries {
+		model := utils.SanitizeForLog(entry.Model, -1)
+		start := time.Now()
+		if err := s.preload(ctx, entry); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.log.Warnf("Failed to preload %s: %v", model, err)
+			continue
+		}
+		s.log.Infof("Preloaded %s in %s", model, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// Load loads and warms up a single model as preloading does, for clients that
+// load models ahead of their requests, and returns once it's loaded. Unlike
+// preloading, it doesn't pull the model if it's missing.
+func (s *Scheduler) Load(ctx context.Context, entry PreloadEntry) error {
+	if err := s.waitUntilRunning(ctx); err != nil {
+		return err
+	}
+	if _, err := s.modelManager.GetLocal(entry.Model); err != nil {
+		return err
+	}
+	return s.preload(ctx, entry)
+}
+
+// waitUntilRunning waits until the scheduler's installer and loader have
+// started, since loads fail before then.
+func (s *Scheduler) waitUntilRunning(ctx context.Context) error {
+	ticker := time.NewTicker(startPollInterval)
+	defer ticker.Stop()
+	for {
+		if s.installer.started.Load() && s.loader.running(ctx) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// preload pulls, loads, and warms up a single model.
+func (s *Scheduler) preload(ctx context.Context, entry PreloadEntry) error {
+	if err := s.modelManager.EnsureLocal(ctx, entry.Model); err != nil {
+		return fmt.Errorf("unable to pull model: %w", err)
+	}
+	modelID := s.modelManager.ResolveID(entry.Model)
+	backend, mode, err := s.loadTarget(ctx, entry.Model, modelID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.installer.wait(ctx, backend.Name()); err != nil {
+		return fmt.Errorf("%s backend unavailable: %w", backend.Name(), err)
+	}
+	if entry.KeepAlive != nil {
+		if err := s.loader.setKeepAlive(ctx, modelID, *entry.KeepAlive); err != nil {
+			return err
+		}
+	}
+
+	s.log.Infof("Preloading %s with the %s backend in %s mode", utils.SanitizeForLog(entry.Model, -1), backend.Name(), mode)
+	runner, err := s.loader.load(ctx, backend.Name(), modelID, entry.Model, mode)
+	if err != nil {
+		return fmt.Errorf("unable to load runner: %w", err)
+	}
+	defer s.loader.release(runner, nil)
+
+	if err := runner.warmUp(ctx, entry.Model, mode); err != nil {
+		// The runner is loaded, so the model remains usable.
+		s.log.Warnf("Unable to warm up %s: %v", utils.SanitizeForLog(entry.Model, -1), err)
+	}
+	return nil
+}
+
+// loadTarget returns the backend and mode that a model is loaded with when
+// it's loaded ahead of requests: the mode implied by its format, reranking
+// mode for reranker models or, for models only configured for embeddings,
+// embedding mode.
+func (s *Scheduler) loadTarget(ctx context.Context, modelRef, modelID string) (inference.Backend, inference.BackendMode, error) {
+	model, err := s.modelManager.GetLocal(modelRef)
+	if err != nil {
+		return nil, 0, err
+	}
+	mode := inference.BackendModeCompletion
+	if config, err := model.Config(); err == nil {
+		switch config.Format {
+		case types.FormatWhisper:
+			mode = inference.BackendModeTranscription
+		case types.FormatDiffusion:
+			mode = inference.BackendModeImageGeneration
+		default:
+			if isReranker(config) {
+				mode = inference.BackendModeReranking
+			}
+		}
+	}
+	backend := s.selectBackendForModel(model, nil, mode, modelRef)
+	if mode == inference.BackendModeCompletion &&
+		s.loader.getRunnerConfig(ctx, backend.Name(), modelID, inference.BackendModeEmbedding) != nil &&
+		s.loader.getRunnerConfig(ctx, backend.Name(), modelID, inference.BackendModeCompletion) == nil {
+		mode = inference.BackendModeEmbedding
+		backend = s.selectBackendForModel(model, nil, mode, modelRef)
+	}
+	return backend, mode, nil
+}
+
+// warmUp sends a short request to the runner, so that costs incurred by the
+// first request, such as CUDA graph capture and memory allocation, aren't paid
+// by clients. Runners in modes without a cheap request aren't warmed up.
+func (r *runner) warmUp(ctx context.Context, modelRef string, mode inference.BackendMode) error {
+	ctx, cancel := context.WithTimeout(ctx, warmUpTimeout)
+	defer cancel()
+
+	var response json.RawMessage
+	switch mode {
+	case inference.BackendModeCompletion:
+		return r.post(ctx, "/v1/chat/completions", map[string]any{
+			"model":      modelRef,
+			"messages":   []map[string]string{{"role": "user", "content": sanityCheckMessage}},
+			"max_tokens": warmUpTokens,
+		}, &response)
+	case inference.BackendModeEmbedding:
+		return r.post(ctx, "/v1/embeddings", map[string]any{
+			"model": modelRef,
+			"input": sanityCheckMessage,
+		}, &response)
+	case inference.BackendModeReranking:
+		return r.post(ctx, "/rerank", map[string]any{
+			"model":     modelRef,
+			"query":     sanityCheckMessage,
+			"documents": []string{sanityCheckMessage},
+		}, &response)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,75 @@
+package scheduling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePreloadList(t *testing.T) {
+	forever := KeepAliveForever
+	tenMinutes := KeepAlive(10 * time.Minute)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected []PreloadEntry
+		wantErr  bool
+	}{
+		{
+			name:  "empty",
+			value: "",
+		},
+		{
+			name:     "single model",
+			value:    "ai/smollm2",
+			expected: []PreloadEntry{{Model: "ai/smollm2"}},
+		},
+		{
+			name:  "keep-alives",
+			value: "ai/smollm2=-1, ai/mxbai-embed-large ,ai/gemma3:4B=10m,",
+			expected: []PreloadEntry{
+				{Model: "ai/smollm2", KeepAlive: &forever},
+				{Model: "ai/mxbai-embed-large"},
+				{Model: "ai/gemma3:4B", KeepAlive: &tenMinutes},
+			},
+		},
+		{
+			name:    "missing model",
+			value:   "=10m",
+			wantErr: true,
+		},
+		{
+			name:    "invalid keep-alive",
+			value:   "ai/smollm2=forever",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ParsePreloadList(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != len(tt.expected) {
+				t.Fatalf("expected %d entries, got %d", len(tt.expected), len(entries))
+			}
+			for i, entry := range entries {
+				expected := tt.expected[i]
+				if entry.Model != expected.Model {
+					t.Errorf("entry %d: expected model %q, got %q", i, expected.Model, entry.Model)
+				}
+				if (entry.KeepAlive == nil) != (expected.KeepAlive == nil) ||
+					(entry.KeepAlive != nil && *entry.KeepAlive != *expected.KeepAlive) {
+					t.Errorf("entry %d: expected keep-alive %v, got %v", i, expected.KeepAlive, entry.KeepAlive)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// Priority is the scheduling priority of an inference request. Requests with
+// higher priorities are served ahead of queued requests with lower priorities.
+type Priority int
+
+const (
+	// PriorityLow is the priority of batch and background requests.
+	PriorityLow Priority = -1
+	// PriorityNormal is the default priority.
+	PriorityNormal Priority = 0
+	// PriorityHigh is the priority of interactive requests.
+	PriorityHigh Priority = 1
+)
+
+// ParsePriority parses a request priority. An empty string yields
+// PriorityNormal.
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high", "interactive":
+		return PriorityHigh, nil
+	case "low", "batch", "background":
+		return PriorityLow, nil
+	default:
+		return PriorityNormal, fmt.Errorf("invalid priority %q (expected \"high\", \"normal\", or \"low\")", s)
+	}
+}
+
+// String implements fmt.Stringer.String.
+func (p Priority) String() string {
+	switch {
+	case p > PriorityNormal:
+		return "high"
+	case p < PriorityNormal:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// UnmarshalJSON implements json.Unmarshaler.UnmarshalJSON. Numeric priorities,
+// which some backends (e.g. vLLM) accept for their own scheduling, are passed
+// through to the backend and don't affect the request's priority here.
+func (p *Priority) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		*p = PriorityNormal
+		return nil
+	}
+	priority, err := ParsePriority(s)
+	if err != nil {
+		return err
+	}
+	*p = priority
+	return nil
+}
+
+// requestPriority determines the priority of a request from the priority
+// header, falling back to the priority set in its body.
+func requestPriority(header http.Header, body Priority) (Priority, error) {
+	value := header.Get(inference.RequestPriorityHeader)
+	if value == "" {
+		return body, nil
+	}
+	priority, err := ParsePriority(value)
+	if err != nil {
+		return PriorityNormal, fmt.Errorf("invalid %s header: %w", inference.RequestPriorityHeader, err)
+	}
+	return priority, nil
+}
@@ -0,0 +1,54 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+func TestRequestPriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		body     string
+		expected Priority
+		wantErr  bool
+	}{
+		{name: "default", body: `{}`, expected: PriorityNormal},
+		{name: "body", body: `{"priority":"interactive"}`, expected: PriorityHigh},
+		{name: "numeric body", body: `{"priority":10}`, expected: PriorityNormal},
+		{name: "header", header: "low", body: `{}`, expected: PriorityLow},
+		{name: "header overrides body", header: "batch", body: `{"priority":"high"}`, expected: PriorityLow},
+		{name: "invalid header", header: "urgent", body: `{}`, wantErr: true},
+		{name: "invalid body", body: `{"priority":"urgent"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request OpenAIInferenceRequest
+			err := json.Unmarshal([]byte(tt.body), &request)
+			var priority Priority
+			if err == nil {
+				header := http.Header{}
+				if tt.header != "" {
+					header.Set(inference.RequestPriorityHeader, tt.header)
+				}
+				priority, err = requestPriority(header, request.Priority)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", priority)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if priority != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, priority)
+			}
+		})
+	}
+}
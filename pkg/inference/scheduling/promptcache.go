@@ -0,0 +1,113 @@
+package scheduling
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// parsePromptCache parses the prompt cache hint of a chat completion or
+// completion request and returns the request without it, along with the key
+// of the prompt prefix that the request shares with other requests, if any.
+// The key is set explicitly with prompt_cache_key or, for chat completions,
+// derived from the messages up to the last one marked with cache_control as
+// the end of a static prefix. Cache control markers are removed, since local
+// backends don't understand them. Bodies that aren't JSON objects are left
+// for the backend to reject.
+func parsePromptCache(body []byte, chat bool) ([]byte, string, error) {
+	if !bytes.Contains(body, []byte(`"prompt_cache_key"`)) && !bytes.Contains(body, []byte(`"cache_control"`)) {
+		return body, "", nil
+	}
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return body, "", nil
+	}
+
+	var key string
+	if raw, ok := request["prompt_cache_key"]; ok {
+		if !isNullField(raw) {
+			if err := json.Unmarshal(raw, &key); err != nil {
+				return nil, "", errors.New("prompt_cache_key must be a string")
+			}
+		}
+		delete(request, "prompt_cache_key")
+	}
+
+	if chat && !isNullField(request["messages"]) {
+		var messages []map[string]json.RawMessage
+		if err := json.Unmarshal(request["messages"], &messages); err != nil {
+			return body, "", nil
+		}
+		prefix := -1
+		for i, message := range messages {
+			if removeCacheControl(message) {
+				prefix = i
+			}
+		}
+		encoded, err := json.Marshal(messages)
+		if err != nil {
+			return nil, "", err
+		}
+		request["messages"] = encoded
+		if key == "" && prefix >= 0 {
+			static, err := json.Marshal(messages[:prefix+1])
+			if err != nil {
+				return nil, "", err
+			}
+			key = prefixCacheKey(request["model"], static)
+		}
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, key, nil
+}
+
+// removeCacheControl removes the cache control markers of a chat message and
+// of its content parts, and returns whether there were any.
+func removeCacheControl(message map[string]json.RawMessage) bool {
+	_, marked := message["cache_control"]
+	delete(message, "cache_control")
+	var parts []map[string]json.RawMessage
+	if json.Unmarshal(message["content"], &parts) != nil {
+		return marked
+	}
+	partMarked := false
+	for _, part := range parts {
+		if _, ok := part["cache_control"]; ok {
+			delete(part, "cache_control")
+			partMarked = true
+		}
+	}
+	if partMarked {
+		message["content"], _ = json.Marshal(parts)
+	}
+	return marked || partMarked
+}
+
+// prefixCacheKey derives the cache key of a static prompt prefix of a model's
+// requests.
+func prefixCacheKey(model json.RawMessage, prefix []byte) string {
+	h := sha256.New()
+	h.Write(model)
+	h.Write([]byte{0})
+	h.Write(prefix)
+	return "prefix-" + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// requestAffinity returns the session whose replica a request prefers: the
+// session identified by the request's header or, failing that, its prompt
+// cache key, so that requests sharing a prompt prefix reuse the same cache.
+func requestAffinity(header http.Header, promptCacheKey string) string {
+	if session := header.Get(inference.SessionIDHeader); session != "" {
+		return session
+	}
+	return promptCacheKey
+}
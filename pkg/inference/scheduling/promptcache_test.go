@@ -0,0 +1,63 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+func TestParsePromptCache(t *testing.T) {
+	body, key, err := parsePromptCache([]byte(`{"model": "m", "prompt_cache_key": "agent", "messages": [{"role": "user", "content": "Hi"}]}`), true)
+	if err != nil || key != "agent" || strings.Contains(string(body), "prompt_cache_key") {
+		t.Errorf("Expected the explicit key to be removed and returned, got %q, %s, %v", key, body, err)
+	}
+
+	if _, _, err := parsePromptCache([]byte(`{"prompt_cache_key": 1}`), false); err == nil {
+		t.Error("Expected a key that isn't a string to be rejected")
+	}
+
+	// Keys are derived from the messages up to the last marked one, and
+	// markers are removed.
+	marked := func(question string) string {
+		return `{"model": "m", "messages": [
+			{"role": "system", "content": [{"type": "text", "text": "Be brief.", "cache_control": {"type": "ephemeral"}}]},
+			{"role": "user", "content": "` + question + `"}]}`
+	}
+	body, first, err := parsePromptCache([]byte(marked("Hi")), true)
+	if err != nil || first == "" || strings.Contains(string(body), "cache_control") {
+		t.Fatalf("Expected a key to be derived and markers to be removed, got %q, %s, %v", first, body, err)
+	}
+	var request struct {
+		Messages []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil || len(request.Messages) != 2 || !strings.Contains(string(request.Messages[0].Content), "Be brief.") {
+		t.Errorf("Expected the messages to be kept, got %s", body)
+	}
+	if _, second, _ := parsePromptCache([]byte(marked("Bye")), true); second != first {
+		t.Errorf("Expected requests sharing the prefix to share the key, got %q and %q", first, second)
+	}
+	if _, other, _ := parsePromptCache([]byte(strings.Replace(marked("Hi"), `"m"`, `"n"`, 1)), true); other == first {
+		t.Error("Expected the key to depend on the model")
+	}
+
+	unmarked := `{"model": "m", "messages": [{"role": "user", "content": "Hi"}]}`
+	if body, key, err := parsePromptCache([]byte(unmarked), true); err != nil || key != "" || string(body) != unmarked {
+		t.Errorf("Expected requests without hints to be unmodified, got %q, %s, %v", key, body, err)
+	}
+}
+
+func TestRequestAffinity(t *testing.T) {
+	header := http.Header{}
+	if session := requestAffinity(header, "prefix"); session != "prefix" {
+		t.Errorf("Expected the prompt cache key, got %q", session)
+	}
+	header.Set(inference.SessionIDHeader, "conversation")
+	if session := requestAffinity(header, "prefix"); session != "conversation" {
+		t.Errorf("Expected the session to take precedence, got %q", session)
+	}
+}
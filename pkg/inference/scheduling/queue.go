@@ -0,0 +1,480 @@
+package scheduling
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// ErrQueueFull indicates that a request was rejected because too many requests
+// for its model are already queued. If returned in conjunction with an HTTP
+// request, it should be paired with a 429 response status.
+var ErrQueueFull = errors.New("too many queued requests for model")
+
+// ErrQueueTimeout indicates that a request waited too long in its model's
+// queue. If returned in conjunction with an HTTP request, it should be paired
+// with a 429 response status.
+var ErrQueueTimeout = errors.New("request timed out in queue")
+
+// ErrPreempted indicates that a low-priority request was cut off to make room
+// for waiting high-priority requests.
+var ErrPreempted = errors.New("request preempted by higher-priority requests")
+
+// defaultRetryAfter is the retry delay suggested to rejected clients if no
+// maximum wait is configured.
+const defaultRetryAfter = time.Second
+
+// QueueLimits bounds the requests queued for each model. Zero values disable
+// the corresponding limit.
+type QueueLimits struct {
+	// MaxConcurrent is the maximum number of requests served concurrently for
+	// a model. Further requests wait in the model's queue. It can be
+	// overridden for individual models.
+	MaxConcurrent int
+	// MaxDepth is the maximum number of requests queued for a model, including
+	// requests waiting for the model to load. Further requests are rejected.
+	MaxDepth int
+	// MaxWait is the maximum time that a request waits for one of its model's
+	// concurrent request slots to free up.
+	MaxWait time.Duration
+	// FairShare shares each model's concurrent request slots fairly between
+	// clients rather than in arrival order. Free slots go to the waiting
+	// client with the fewest requests being served, and a client whose
+	// requests fill a model's queue yields its most recent request to other
+	// clients.
+	FairShare bool
+	// PreemptionThreshold is the number of high-priority requests waiting for
+	// a model at which a low-priority streaming request being served is cut
+	// off to make room for them. Zero disables preemption.
+	PreemptionThreshold int
+}
+
+// RetryAfter returns the delay that rejected clients should wait before
+// retrying.
+func (l QueueLimits) RetryAfter() time.Duration {
+	if l.MaxWait > 0 {
+		return l.MaxWait
+	}
+	return defaultRetryAfter
+}
+
+// requestClient identifies the client that sent a request for the purpose of
+// fair scheduling. Clients are identified by the client ID header, by their
+// API key, or failing those, by their address. API keys are hashed so that
+// they aren't retained.
+func requestClient(r *http.Request) string {
+	if id := r.Header.Get(inference.ClientIDHeader); id != "" {
+		return "id:" + id
+	}
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))))
+		return "key:" + hex.EncodeToString(sum[:8])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// queueRequest describes a request being admitted to a queue.
+type queueRequest struct {
+	// client identifies the client that sent the request.
+	client string
+	// priority is the scheduling priority of the request.
+	priority Priority
+	// stream indicates whether the response is streamed.
+	stream bool
+	// preempt cuts off the request if it's preempted. It may be nil.
+	preempt context.CancelCauseFunc
+	// admitted is called with the request's ticket once it's admitted to the
+	// queue, before it waits for a concurrent request slot. It's called with
+	// the queue lock held, so it must not call into the queue. It may be nil.
+	admitted func(*queueTicket)
+}
+
+// queueWaiter is a request waiting for a concurrent request slot.
+type queueWaiter struct {
+	// ticket is the request's ticket.
+	ticket *queueTicket
+	// ready is closed once the request is granted a slot or rejected.
+	ready chan struct{}
+	// err is the reason that the request was rejected, if it was. It's set
+	// before ready is closed.
+	err error
+}
+
+// modelQueue tracks the requests for a single model.
+type modelQueue struct {
+	// active is the number of requests holding a concurrent request slot.
+	active int
+	// clients is the number of requests holding a concurrent request slot for
+	// each client.
+	clients map[string]int
+	// serving are the requests holding a concurrent request slot, in the
+	// order that they were granted one.
+	serving []*queueTicket
+	// queued is the number of requests that haven't started being served.
+	queued int
+	// waiters are the requests waiting for a concurrent request slot, in
+	// arrival order.
+	waiters []*queueWaiter
+}
+
+// requestQueue applies queue limits to requests on a per-model basis.
+type requestQueue struct {
+	// lock guards all fields.
+	lock sync.Mutex
+	// limits are the queue limits.
+	limits QueueLimits
+	// maxConcurrent maps model IDs to their maximum number of concurrent
+	// requests, overriding limits.MaxConcurrent.
+	maxConcurrent map[string]int
+	// models maps model IDs to their queues. Queues are removed once they
+	// have no requests.
+	models map[string]*modelQueue
+	// admissions is the number of requests admitted so far, which orders
+	// tickets by arrival.
+	admissions uint64
+}
+
+// newRequestQueue creates a new request queue.
+func newRequestQueue(limits QueueLimits) *requestQueue {
+	return &requestQueue{
+		limits:        limits,
+		maxConcurrent: make(map[string]int),
+		models:        make(map[string]*modelQueue),
+	}
+}
+
+// setLimits updates the queue limits. Requests that are already queued aren't
+// affected by a reduced maximum depth.
+func (q *requestQueue) setLimits(limits QueueLimits) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.limits = limits
+	for modelID, mq := range q.models {
+		q.grant(modelID, mq)
+	}
+}
+
+// getLimits returns the queue limits.
+func (q *requestQueue) getLimits() QueueLimits {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.limits
+}
+
+// setMaxConcurrent sets the maximum number of requests served concurrently
+// for a model. A value of zero reverts to the default limit.
+func (q *requestQueue) setMaxConcurrent(modelID string, maxConcurrent int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if maxConcurrent > 0 {
+		q.maxConcurrent[modelID] = maxConcurrent
+	} else {
+		delete(q.maxConcurrent, modelID)
+	}
+	if mq := q.models[modelID]; mq != nil {
+		q.grant(modelID, mq)
+	}
+}
+
+// queued returns the number of requests for a model that haven't started
+// being served.
+func (q *requestQueue) queued(modelID string) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if mq := q.models[modelID]; mq != nil {
+		return mq.queued
+	}
+	return 0
+}
+
+// concurrencyLimit returns the maximum number of requests served concurrently
+// for a model, or zero if it's unlimited. The caller must hold the queue lock.
+func (q *requestQueue) concurrencyLimit(modelID string) int {
+	if limit, ok := q.maxConcurrent[modelID]; ok {
+		return limit
+	}
+	return q.limits.MaxConcurrent
+}
+
+// queueTicket represents a request admitted to a model's queue.
+type queueTicket struct {
+	queueRequest
+	queue     *requestQueue
+	modelID   string
+	sequence  uint64
+	started   bool
+	preempted bool
+}
+
+// admit admits a request for the specified model to the queue, waiting for a
+// concurrent request slot if necessary. Waiting requests are granted slots in
+// order of priority. It returns ErrQueueFull if the model's queue is full and
+// ErrQueueTimeout if no slot frees up within the maximum wait. The returned
+// ticket must be released with done.
+func (q *requestQueue) admit(ctx context.Context, modelID string, request queueRequest) (*queueTicket, error) {
+	q.lock.Lock()
+	mq := q.models[modelID]
+	if mq == nil {
+		mq = &modelQueue{clients: make(map[string]int)}
+		q.models[modelID] = mq
+	}
+	if q.limits.MaxDepth > 0 && mq.queued >= q.limits.MaxDepth && !q.displace(mq, request) {
+		q.removeIfUnused(modelID, mq)
+		q.lock.Unlock()
+		return nil, ErrQueueFull
+	}
+	mq.queued++
+	q.admissions++
+	ticket := &queueTicket{queueRequest: request, queue: q, modelID: modelID, sequence: q.admissions}
+	if request.admitted != nil {
+		request.admitted(ticket)
+	}
+	if limit := q.concurrencyLimit(modelID); limit <= 0 || (mq.active < limit && len(mq.waiters) == 0) {
+		mq.activate(ticket)
+		q.lock.Unlock()
+		return ticket, nil
+	}
+
+	// Wait for a slot to be granted.
+	waiter := &queueWaiter{ticket: ticket, ready: make(chan struct{})}
+	mq.waiters = append(mq.waiters, waiter)
+	q.preempt(mq, request.priority)
+	var timeout <-chan time.Time
+	if q.limits.MaxWait > 0 {
+		timer := time.NewTimer(q.limits.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	q.lock.Unlock()
+
+	var err error
+	select {
+	case <-waiter.ready:
+		if waiter.err != nil {
+			return nil, waiter.err
+		}
+		return ticket, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrQueueTimeout
+	}
+
+	// Withdraw from the queue, unless the request was granted a slot in the
+	// meantime, in which case it's passed on, or was displaced.
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	select {
+	case <-waiter.ready:
+		if waiter.err != nil {
+			return nil, waiter.err
+		}
+		mq.deactivate(ticket)
+	default:
+		mq.removeWaiter(waiter)
+	}
+	mq.queued--
+	q.grant(modelID, mq)
+	q.removeIfUnused(modelID, mq)
+	return nil, err
+}
+
+// displace makes room in a full queue for a request by rejecting the most
+// recent waiting request with the lowest priority, if it's lower than the
+// request's, or otherwise the most recent waiting request of the client with
+// the most waiting requests, provided that it's fair to do so. Requests with
+// higher priorities are never displaced by fair sharing. It returns true if a
+// request was displaced. The caller must hold the queue lock.
+func (q *requestQueue) displace(mq *modelQueue, request queueRequest) bool {
+	lowest := -1
+	for i, w := range mq.waiters {
+		if lowest < 0 || w.ticket.priority <= mq.waiters[lowest].ticket.priority {
+			lowest = i
+		}
+	}
+	if lowest >= 0 && mq.waiters[lowest].ticket.priority < request.priority {
+		mq.reject(lowest)
+		return true
+	}
+
+	if !q.limits.FairShare {
+		return false
+	}
+	waiting := make(map[string]int)
+	for _, w := range mq.waiters {
+		if w.ticket.priority <= request.priority {
+			waiting[w.ticket.client]++
+		}
+	}
+	heaviest := ""
+	for c, n := range waiting {
+		if n > waiting[heaviest] || (n == waiting[heaviest] && c < heaviest) {
+			heaviest = c
+		}
+	}
+	if heaviest == "" || waiting[heaviest] <= waiting[request.client]+1 {
+		return false
+	}
+	for i := len(mq.waiters) - 1; i >= 0; i-- {
+		if w := mq.waiters[i]; w.ticket.client == heaviest && w.ticket.priority <= request.priority {
+			mq.reject(i)
+			return true
+		}
+	}
+	return false
+}
+
+// preempt cuts off the most recently granted low-priority streaming request
+// being served if enough high-priority requests are waiting. The caller must
+// hold the queue lock.
+func (q *requestQueue) preempt(mq *modelQueue, priority Priority) {
+	if q.limits.PreemptionThreshold <= 0 || priority < PriorityHigh {
+		return
+	}
+	waiting := 0
+	for _, w := range mq.waiters {
+		if w.ticket.priority >= PriorityHigh {
+			waiting++
+		}
+	}
+	if waiting < q.limits.PreemptionThreshold {
+		return
+	}
+	for i := len(mq.serving) - 1; i >= 0; i-- {
+		if t := mq.serving[i]; t.priority <= PriorityLow && t.stream && t.preempt != nil && !t.preempted {
+			t.preempted = true
+			t.preempt(ErrPreempted)
+			return
+		}
+	}
+}
+
+// grant grants free concurrent request slots to waiting requests. The caller
+// must hold the queue lock.
+func (q *requestQueue) grant(modelID string, mq *modelQueue) {
+	limit := q.concurrencyLimit(modelID)
+	for len(mq.waiters) > 0 && (limit <= 0 || mq.active < limit) {
+		next := 0
+		for i, w := range mq.waiters {
+			best := mq.waiters[next].ticket
+			if w.ticket.priority > best.priority ||
+				(w.ticket.priority == best.priority && q.limits.FairShare && mq.clients[w.ticket.client] < mq.clients[best.client]) {
+				next = i
+			}
+		}
+		waiter := mq.waiters[next]
+		mq.waiters = append(mq.waiters[:next], mq.waiters[next+1:]...)
+		mq.activate(waiter.ticket)
+		close(waiter.ready)
+	}
+}
+
+// removeIfUnused removes a model's queue if it has no requests. The caller
+// must hold the queue lock.
+func (q *requestQueue) removeIfUnused(modelID string, mq *modelQueue) {
+	if mq.active == 0 && mq.queued == 0 && len(mq.waiters) == 0 {
+		delete(q.models, modelID)
+	}
+}
+
+// activate records that a request holds a concurrent request slot.
+func (mq *modelQueue) activate(t *queueTicket) {
+	mq.active++
+	mq.clients[t.client]++
+	mq.serving = append(mq.serving, t)
+}
+
+// deactivate records that a request released its concurrent request slot.
+func (mq *modelQueue) deactivate(t *queueTicket) {
+	mq.active--
+	if mq.clients[t.client]--; mq.clients[t.client] == 0 {
+		delete(mq.clients, t.client)
+	}
+	for i, s := range mq.serving {
+		if s == t {
+			mq.serving = append(mq.serving[:i], mq.serving[i+1:]...)
+			break
+		}
+	}
+}
+
+// reject rejects the waiting request at the specified index because the queue
+// is full.
+func (mq *modelQueue) reject(i int) {
+	w := mq.waiters[i]
+	mq.waiters = append(mq.waiters[:i], mq.waiters[i+1:]...)
+	mq.queued--
+	w.err = ErrQueueFull
+	close(w.ready)
+}
+
+// removeWaiter removes a waiting request.
+func (mq *modelQueue) removeWaiter(waiter *queueWaiter) {
+	for i, w := range mq.waiters {
+		if w == waiter {
+			mq.waiters = append(mq.waiters[:i], mq.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// start records that the request has started being served, so it no longer
+// counts towards its model's queue depth.
+func (t *queueTicket) start() {
+	t.queue.lock.Lock()
+	defer t.queue.lock.Unlock()
+	if !t.started {
+		t.started = true
+		t.queue.models[t.modelID].queued--
+	}
+}
+
+// position returns the position of the request among the requests for its
+// model that haven't started being served, in arrival order, starting at 1.
+// It returns 0 once the request has started being served or left the queue.
+func (t *queueTicket) position() int {
+	t.queue.lock.Lock()
+	defer t.queue.lock.Unlock()
+	mq := t.queue.models[t.modelID]
+	if t.started || mq == nil {
+		return 0
+	}
+	position := 1
+	for _, s := range mq.serving {
+		if !s.started && s.sequence < t.sequence {
+			position++
+		}
+	}
+	for _, w := range mq.waiters {
+		if w.ticket.sequence < t.sequence {
+			position++
+		}
+	}
+	return position
+}
+
+// done releases the request's concurrent request slot.
+func (t *queueTicket) done() {
+	q := t.queue
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	mq := q.models[t.modelID]
+	if !t.started {
+		mq.queued--
+	}
+	mq.deactivate(t)
+	q.grant(t.modelID, mq)
+	q.removeIfUnused(t.modelID, mq)
+}
@@ -0,0 +1,256 @@
+package scheduling
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRequestQueueMaxDepth(t *testing.T) {
+	q := newRequestQueue(QueueLimits{MaxDepth: 2})
+	ctx := context.Background()
+
+	first, err := q.admit(ctx, "model", queueRequest{client: "client"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := q.admit(ctx, "model", queueRequest{client: "client"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := q.admit(ctx, "model", queueRequest{client: "client"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	// Other models have their own queues.
+	other, err := q.admit(ctx, "other", queueRequest{client: "client"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other.done()
+
+	// Requests being served don't count towards the depth.
+	first.start()
+	third, err := q.admit(ctx, "model", queueRequest{client: "client"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	first.done()
+	second.done()
+	third.done()
+	if len(q.models) != 0 {
+		t.Errorf("expected model queues to be removed, got %d", len(q.models))
+	}
+}
+
+func TestRequestQueueMaxConcurrent(t *testing.T) {
+	q := newRequestQueue(QueueLimits{MaxConcurrent: 1, MaxWait: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	active, err := q.admit(ctx, "model", queueRequest{client: "client"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	active.start()
+
+	// Requests beyond the concurrency limit time out if the slot isn't freed.
+	if _, err := q.admit(ctx, "model", queueRequest{client: "client"}); !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("expected ErrQueueTimeout, got %v", err)
+	}
+
+	// Cancelled requests leave the queue.
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := q.admit(cancelled, "model", queueRequest{client: "client"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// Waiting requests are granted the slot once it's freed.
+	admitted := make(chan error, 1)
+	go func() {
+		ticket, err := q.admit(ctx, "model", queueRequest{client: "client"})
+		if err == nil {
+			ticket.done()
+		}
+		admitted <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	active.done()
+	if err := <-admitted; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.models) != 0 {
+		t.Errorf("expected model queues to be removed, got %d", len(q.models))
+	}
+}
+
+func TestRequestQueueFairShare(t *testing.T) {
+	q := newRequestQueue(QueueLimits{MaxConcurrent: 2, MaxDepth: 3, FairShare: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Both slots are held, by clients a and b.
+	a, err := q.admit(ctx, "model", queueRequest{client: "a"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a.start()
+	defer a.done()
+	b, err := q.admit(ctx, "model", queueRequest{client: "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b.start()
+
+	// enqueue queues a request from a client and waits for it to be queued.
+	enqueue := func(client string, waiters int) chan error {
+		result := make(chan error, 1)
+		go func() {
+			ticket, err := q.admit(ctx, "model", queueRequest{client: client})
+			if err == nil {
+				ticket.done()
+			}
+			result <- err
+		}()
+		for {
+			q.lock.Lock()
+			queued := len(q.models["model"].waiters)
+			q.lock.Unlock()
+			if queued == waiters {
+				return result
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	enqueue("a", 1)
+	displaced := enqueue("a", 2)
+	granted := enqueue("b", 3)
+
+	// A full queue yields client a's most recent request to client c.
+	enqueue("c", 3)
+	if err := <-displaced; !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	// Client b's slot goes to a client without active requests, rather than
+	// to the oldest waiting request, which is client a's.
+	b.done()
+	if err := <-granted; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRequestQueuePriority(t *testing.T) {
+	q := newRequestQueue(QueueLimits{MaxConcurrent: 1, MaxDepth: 2, PreemptionThreshold: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The slot is held by a low-priority streaming request.
+	preempted := make(chan error, 1)
+	active, err := q.admit(ctx, "model", queueRequest{
+		client:   "batch",
+		priority: PriorityLow,
+		stream:   true,
+		preempt:  func(cause error) { preempted <- cause },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	active.start()
+
+	// enqueue queues a request and waits for it to be queued.
+	enqueue := func(priority Priority, waiters int) chan error {
+		result := make(chan error, 1)
+		go func() {
+			ticket, err := q.admit(ctx, "model", queueRequest{client: priority.String(), priority: priority})
+			if err == nil {
+				ticket.done()
+			}
+			result <- err
+		}()
+		for {
+			q.lock.Lock()
+			queued := len(q.models["model"].waiters)
+			q.lock.Unlock()
+			if queued == waiters {
+				return result
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	displaced := enqueue(PriorityLow, 1)
+	normal := enqueue(PriorityNormal, 2)
+
+	// A high-priority request displaces the low-priority request from the
+	// full queue and preempts the low-priority request being served.
+	high := enqueue(PriorityHigh, 2)
+	if err := <-displaced; !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if cause := <-preempted; !errors.Is(cause, ErrPreempted) {
+		t.Fatalf("expected ErrPreempted, got %v", cause)
+	}
+
+	// The high-priority request is granted the slot ahead of the older
+	// normal-priority request.
+	active.done()
+	if err := <-high; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := <-normal; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRequestQueuePosition(t *testing.T) {
+	q := newRequestQueue(QueueLimits{MaxConcurrent: 1})
+	ctx := context.Background()
+
+	first, err := q.admit(ctx, "model", queueRequest{client: "client"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.queued("model") != 1 {
+		t.Errorf("expected 1 queued request, got %d", q.queued("model"))
+	}
+
+	// The second request reports its ticket once admitted, before it waits
+	// for the first request's slot.
+	admitted := make(chan *queueTicket, 1)
+	result := make(chan error, 1)
+	go func() {
+		second, err := q.admit(ctx, "model", queueRequest{
+			client:   "client",
+			admitted: func(t *queueTicket) { admitted <- t },
+		})
+		if err == nil {
+			second.done()
+		}
+		result <- err
+	}()
+	second := <-admitted
+	if position := first.position(); position != 1 {
+		t.Errorf("expected first request at position 1, got %d", position)
+	}
+	if position := second.position(); position != 2 {
+		t.Errorf("expected second request at position 2, got %d", position)
+	}
+
+	// Requests being served leave the queue.
+	first.start()
+	if position := first.position(); position != 0 {
+		t.Errorf("expected started request at position 0, got %d", position)
+	}
+	if position := second.position(); position != 1 {
+		t.Errorf("expected second request at position 1, got %d", position)
+	}
+	first.done()
+	if err := <-result; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
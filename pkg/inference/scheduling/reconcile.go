@@ -0,0 +1,124 @@
+package scheduling
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/internal/utils"
+)
+
+// modelTag returns a model reference without its digest and with the default
+// organization and tag, which identifies the model across versions.
+func modelTag(ref string) string {
+	ref, _, _ = strings.Cut(ref, "@")
+	return models.NormalizeModelName(ref)
+}
+
+// LoadReplicas loads replicas of a local model until the specified number of
+// them are running, for models that are kept loaded, and unloads idle runners
+// of the model's tag that run another version of it or use another backend.
+// If backend is nil, one is selected for the model.
+func (s *Scheduler) LoadReplicas(ctx context.Context, model string, backend inference.Backend, replicas int) error {
+	if err := s.waitUntilRunning(ctx); err != nil {
+		return err
+	}
+	modelID := s.modelManager.ResolveID(model)
+	selected, mode, err := s.loadTarget(ctx, model, modelID)
+	if err != nil {
+		return err
+	}
+	if backend == nil {
+		backend = selected
+	}
+	if err := s.installer.wait(ctx, backend.Name()); err != nil {
+		return fmt.Errorf("%s backend unavailable: %w", backend.Name(), err)
+	}
+
+	key := makeRunnerKey(backend.Name(), modelID, "", mode)
+	if evicted := s.loader.evictDrifted(ctx, modelTag(model), key); evicted > 0 {
+		s.log.Infof("Unloaded %d runners that drifted from %s", evicted, utils.SanitizeForLog(model, -1))
+	}
+
+	// Replicas are only started for models configured with enough of them.
+	runnerConfig := s.loader.getRunnerConfig(ctx, backend.Name(), modelID, mode)
+	if runnerConfig.ReplicaCount() < replicas {
+		updated := inference.BackendConfiguration{}
+		if runnerConfig != nil {
+			updated = *runnerConfig
+		}
+		updated.Replicas = &inference.ReplicaConfig{Count: replicas, Routing: runnerConfig.ReplicaRouting()}
+		if err := s.loader.setRunnerConfig(ctx, backend.Name(), modelID, mode, updated); err != nil {
+			return fmt.Errorf("unable to configure replicas: %w", err)
+		}
+	}
+
+	loaded := s.loader.loadedReplicas(ctx, key)
+	if loaded >= replicas {
+		return nil
+	}
+	s.log.Infof("Loading %d of %d replicas of %s with the %s backend in %s mode",
+		replicas-loaded, replicas, utils.SanitizeForLog(model, -1), backend.Name(), mode,
+	)
+	// Holding a runner for each replica makes every load after the first
+	// start another replica, since all loaded ones are busy.
+	var runners []*runner
+	defer func() {
+		for _, runner := range runners {
+			s.loader.release(runner, nil)
+		}
+	}()
+	for range replicas {
+		runner, err := s.loader.load(ctx, backend.Name(), modelID, model, mode)
+		if err != nil {
+			return fmt.Errorf("unable to load runner: %w", err)
+		}
+		runners = append(runners, runner)
+	}
+	return nil
+}
+
+// loadedReplicas returns the number of loaded replicas of the model with the
+// specified key.
+func (l *loader) loadedReplicas(ctx context.Context, key runnerKey) int {
+	if !l.lock(ctx) {
+		return 0
+	}
+	defer l.unlock()
+	loaded := 0
+	for r := range l.runners {
+		if modelKey(r) == modelKey(key) {
+			loaded++
+		}
+	}
+	return loaded
+}
+
+// evictDrifted evicts the idle runners of the model with the specified tag
+// that run in the desired key's mode but not with its model ID or backend,
+// i.e. that run another version of the model or run it with another backend.
+// It returns the number of evicted runners.
+func (l *loader) evictDrifted(ctx context.Context, tag string, desired runnerKey) int {
+	if !l.lock(ctx) {
+		return 0
+	}
+	defer l.unlock()
+	evicted := 0
+	for r, runnerInfo := range l.runners {
+		if modelTag(runnerInfo.modelRef) != tag || r.mode != desired.mode ||
+			(r.modelID == desired.modelID && r.backend == desired.backend) || l.references[runnerInfo.slot] > 0 {
+			continue
+		}
+		l.log.Infof("Evicting %s backend runner with model %s (%s) in %s mode",
+			r.backend, r.modelID, runnerInfo.modelRef, r.mode,
+		)
+		l.freeRunnerSlot(runnerInfo.slot, r, EventUnload, "drifted from models.lock")
+		evicted++
+	}
+	if evicted > 0 {
+		l.broadcast()
+	}
+	return evicted
+}
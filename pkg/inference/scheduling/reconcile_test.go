@@ -0,0 +1,80 @@
+package scheduling
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+func TestModelTag(t *testing.T) {
+	tests := map[string]string{
+		"smollm2":                            "ai/smollm2:latest",
+		"ai/smollm2":                         "ai/smollm2:latest",
+		"ai/smollm2:360M":                    "ai/smollm2:360M",
+		"ai/smollm2@sha256:abc":              "ai/smollm2:latest",
+		"ai/smollm2:360M@sha256:abc":         "ai/smollm2:360M",
+		"registry.local:5000/ai/smollm2:tag": "registry.local:5000/ai/smollm2:tag",
+	}
+	for ref, want := range tests {
+		if got := modelTag(ref); got != want {
+			t.Errorf("modelTag(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestEvictDrifted(t *testing.T) {
+	log := createTestLogger()
+	backend := &mockBackend{name: "test-backend"}
+	other := &mockBackend{name: "other-backend"}
+	sysMemInfo := &mockSystemMemoryInfo{
+		totalMemory: inference.RequiredMemory{RAM: 8 * GB, VRAM: 8 * GB},
+	}
+	loader := newLoader(log, map[string]inference.Backend{"test-backend": backend, "other-backend": other}, nil, nil, sysMemInfo)
+
+	const nSlots = 7
+	loader.slots = make([]*runner, nSlots)
+	loader.references = make([]uint, nSlots)
+	loader.allocations = make([]inference.RequiredMemory, nSlots)
+	loader.timestamps = make([]time.Time, nSlots)
+	loader.requestKeepAlives = make([]*KeepAlive, nSlots)
+	loader.deviceAssignments = make([][]int, nSlots)
+
+	runners := []struct {
+		key      runnerKey
+		modelRef string
+		inUse    bool
+		drifted  bool
+	}{
+		{key: runnerKey{backend: "test-backend", modelID: "current", mode: inference.BackendModeCompletion}, modelRef: "ai/smollm2@sha256:current"},
+		{key: runnerKey{backend: "test-backend", modelID: "current", mode: inference.BackendModeCompletion, replica: 1}, modelRef: "ai/smollm2@sha256:current"},
+		{key: runnerKey{backend: "test-backend", modelID: "old", mode: inference.BackendModeCompletion}, modelRef: "ai/smollm2:latest", drifted: true},
+		{key: runnerKey{backend: "other-backend", modelID: "current", mode: inference.BackendModeCompletion}, modelRef: "ai/smollm2", drifted: true},
+		{key: runnerKey{backend: "test-backend", modelID: "older", mode: inference.BackendModeCompletion}, modelRef: "ai/smollm2", inUse: true},
+		{key: runnerKey{backend: "test-backend", modelID: "other", mode: inference.BackendModeCompletion}, modelRef: "ai/smollm2:360M"},
+		{key: runnerKey{backend: "test-backend", modelID: "old", mode: inference.BackendModeEmbedding}, modelRef: "ai/smollm2:latest"},
+	}
+	for slot, r := range runners {
+		loader.slots[slot] = createAliveTerminableMockRunner(log, backend)
+		loader.runners[r.key] = runnerInfo{slot: slot, modelRef: r.modelRef}
+		loader.allocations[slot] = inference.RequiredMemory{RAM: GB, VRAM: GB}
+		if r.inUse {
+			loader.references[slot] = 1
+		}
+	}
+	loader.updateAvailableMemory()
+
+	desired := makeRunnerKey("test-backend", "current", "", inference.BackendModeCompletion)
+	if got := loader.loadedReplicas(context.Background(), desired); got != 2 {
+		t.Errorf("expected 2 loaded replicas, got %d", got)
+	}
+	if evicted := loader.evictDrifted(context.Background(), "ai/smollm2:latest", desired); evicted != 2 {
+		t.Errorf("expected 2 evicted runners, got %d", evicted)
+	}
+	for _, r := range runners {
+		if _, resident := loader.runners[r.key]; resident == r.drifted {
+			t.Errorf("unexpected residency for runner %+v: %v", r.key, resident)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package scheduling
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+const (
+	// restartInitialBackoff is the delay before a crashed runner is first
+	// restarted.
+	restartInitialBackoff = time.Second
+	// restartMaximumBackoff bounds the delay before a crashed runner is
+	// restarted.
+	restartMaximumBackoff = time.Minute
+	// restartStablePeriod is the time after which a runner that has been
+	// running is considered stable, resetting its backoff if it crashes.
+	restartStablePeriod = 5 * time.Minute
+	// maximumRestartAttempts is the number of consecutive failures after which
+	// a crashed runner is no longer restarted.
+	maximumRestartAttempts = 5
+)
+
+// crashRecord tracks the crashes of a model's runners.
+type crashRecord struct {
+	// modelRef is the model reference used to load the crashed runner.
+	modelRef string
+	// restarts is the number of times the model's runners have been
+	// restarted successfully.
+	restarts int
+	// failures is the number of consecutive crashes or failed restarts.
+	failures int
+	// lastError describes the most recent crash or failed restart.
+	lastError string
+	// restartAt is the time at which the model is restarted. It's zero if no
+	// restart is pending.
+	restartAt time.Time
+	// restarting is true while a restart is in progress.
+	restarting bool
+}
+
+// restartBackoff returns the delay before the restart following the specified
+// number of consecutive failures.
+func restartBackoff(failures int) time.Duration {
+	backoff := restartInitialBackoff
+	for i := 1; i < failures && backoff < restartMaximumBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, restartMaximumBackoff)
+}
+
+// crashKey returns the key under which crashes of the runner with the
+// specified key are recorded. Crashes are recorded per model rather than per
+// replica.
+func crashKey(key runnerKey) runnerKey {
+	key.replica = 0
+	return key
+}
+
+// watch waits for a runner to exit and, if it crashed, records the crash so
+// that the runner is restarted.
+func (l *loader) watch(key runnerKey, modelRef string, r *runner) {
+	loaded := time.Now()
+	<-r.done
+	if !r.crashed {
+		return
+	}
+	l.lock(context.Background())
+	defer l.unlock()
+	if !l.loadsEnabled {
+		return
+	}
+	message := errBackendQuitUnexpectedly.Error()
+	if r.hung.Load() {
+		message = errBackendHung.Error()
+	} else if r.err != nil {
+		message = r.err.Error()
+	}
+	l.log.Warnf("%s backend runner with model %s (%s) in %s mode crashed: %s",
+		key.backend, key.modelID, modelRef, key.mode, message,
+	)
+	l.recordEvent(EventCrash, key, modelRef, inference.RequiredMemory{}, nil, message)
+	l.recordCrash(key, modelRef, time.Since(loaded), message)
+
+	// Evict the defunct runner and schedule the restart.
+	select {
+	case l.idleCheck <- struct{}{}:
+	default:
+	}
+}
+
+// recordCrash records a crash of the runner with the specified key, which ran
+// for the specified uptime, and schedules its restart. A zero uptime records
+// a failed restart. The caller must hold the loader lock.
+func (l *loader) recordCrash(key runnerKey, modelRef string, uptime time.Duration, message string) {
+	key = crashKey(key)
+	record := l.crashes[key]
+	if record == nil {
+		record = &crashRecord{modelRef: modelRef}
+		l.crashes[key] = record
+	}
+	if uptime >= restartStablePeriod {
+		record.failures = 0
+	}
+	record.failures++
+	record.lastError = message
+	if record.failures > maximumRestartAttempts {
+		l.log.Errorf("Giving up on restarting %s backend runner with model %s (%s) in %s mode after %d consecutive failures",
+			key.backend, key.modelID, record.modelRef, key.mode, record.failures,
+		)
+		record.restartAt = time.Time{}
+		return
+	}
+	backoff := restartBackoff(record.failures)
+	l.log.Infof("Restarting %s backend runner with model %s (%s) in %s mode in %s",
+		key.backend, key.modelID, record.modelRef, key.mode, backoff,
+	)
+	record.restartAt = time.Now().Add(backoff)
+}
+
+// nextRestart returns the time of the earliest pending restart, or the zero
+// time if no restarts are pending. The caller must hold the loader lock.
+func (l *loader) nextRestart() time.Time {
+	var earliest time.Time
+	for _, record := range l.crashes {
+		if record.restartAt.IsZero() || record.restarting {
+			continue
+		}
+		if earliest.IsZero() || record.restartAt.Before(earliest) {
+			earliest = record.restartAt
+		}
+	}
+	return earliest
+}
+
+// restartCrashed starts the restarts that are due. The caller must hold the
+// loader lock.
+func (l *loader) restartCrashed(ctx context.Context) {
+	now := time.Now()
+	for key, record := range l.crashes {
+		if record.restartAt.IsZero() || record.restarting || record.restartAt.After(now) {
+			continue
+		}
+		if l.hasReplicas(key) {
+			// The model was loaded again in the meantime.
+			record.failures = 0
+			record.restartAt = time.Time{}
+			continue
+		}
+		record.restarting = true
+		go l.restart(ctx, key, record.modelRef)
+	}
+}
+
+// restart reloads a crashed model, replaying its readiness and sanity checks,
+// and schedules another attempt if that fails.
+func (l *loader) restart(ctx context.Context, key runnerKey, modelRef string) {
+	l.log.Infof("Restarting %s backend runner with model %s (%s) in %s mode",
+		key.backend, key.modelID, modelRef, key.mode,
+	)
+	runner, err := l.load(ctx, key.backend, key.modelID, modelRef, key.mode)
+	if err == nil {
+		l.release(runner, nil)
+	}
+
+	if !l.lock(ctx) {
+		return
+	}
+	defer l.unlock()
+	record := l.crashes[key]
+	if record == nil {
+		// The model was unloaded in the meantime.
+		return
+	}
+	record.restarting = false
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		l.log.Warnf("Unable to restart %s backend runner with model %s (%s) in %s mode: %v",
+			key.backend, key.modelID, modelRef, key.mode, err,
+		)
+		l.recordCrash(key, modelRef, 0, err.Error())
+	} else {
+		record.restarts++
+		record.restartAt = time.Time{}
+	}
+
+	// Reschedule the idle check for the restarted runner or the next restart.
+	select {
+	case l.idleCheck <- struct{}{}:
+	default:
+	}
+}
+
+// forgetCrashes discards the crash records of the models matching the
+// specified predicate, cancelling their pending restarts. The caller must
+// hold the loader lock.
+func (l *loader) forgetCrashes(match func(key runnerKey) bool) {
+	for key := range l.crashes {
+		if match(key) {
+			delete(l.crashes, key)
+		}
+	}
+}
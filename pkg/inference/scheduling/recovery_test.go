@@ -0,0 +1,70 @@
+package scheduling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 1, expected: time.Second},
+		{failures: 2, expected: 2 * time.Second},
+		{failures: 4, expected: 8 * time.Second},
+		{failures: 7, expected: time.Minute},
+		{failures: 100, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		if backoff := restartBackoff(tt.failures); backoff != tt.expected {
+			t.Errorf("restartBackoff(%d): expected %s, got %s", tt.failures, tt.expected, backoff)
+		}
+	}
+}
+
+func TestRecordCrash(t *testing.T) {
+	l := newLoader(createTestLogger(), nil, nil, nil, &mockSystemMemoryInfo{})
+	key := makeRunnerKey("llama.cpp", "model1", "", inference.BackendModeCompletion)
+	replica := key
+	replica.replica = 1
+
+	// Crashes of any replica are recorded for the model.
+	l.recordCrash(replica, "ai/model1", time.Second, "boom")
+	record := l.crashes[key]
+	if record == nil {
+		t.Fatal("expected crash to be recorded")
+	}
+	if record.failures != 1 || record.restartAt.IsZero() || record.lastError != "boom" {
+		t.Fatalf("unexpected crash record: %+v", record)
+	}
+	if next := l.nextRestart(); !next.Equal(record.restartAt) {
+		t.Errorf("expected next restart at %s, got %s", record.restartAt, next)
+	}
+
+	// Crashes after a stable period reset the backoff.
+	l.recordCrash(key, "ai/model1", time.Second, "boom")
+	l.recordCrash(key, "ai/model1", restartStablePeriod, "boom")
+	if record.failures != 1 {
+		t.Errorf("expected failures to be reset, got %d", record.failures)
+	}
+
+	// Restarts stop after too many consecutive failures.
+	for range maximumRestartAttempts {
+		l.recordCrash(key, "ai/model1", 0, "boom")
+	}
+	if !record.restartAt.IsZero() {
+		t.Error("expected restarts to stop")
+	}
+	if next := l.nextRestart(); !next.IsZero() {
+		t.Errorf("expected no pending restarts, got %s", next)
+	}
+
+	l.forgetCrashes(func(key runnerKey) bool { return key.modelID == "model1" })
+	if len(l.crashes) != 0 {
+		t.Errorf("expected crash records to be discarded, got %d", len(l.crashes))
+	}
+}
@@ -0,0 +1,124 @@
+package scheduling
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// affinityKey is the context key for session affinity.
+type affinityKey struct{}
+
+// withAffinity returns a context whose loads prefer the replica associated
+// with the specified session. An empty session has no affinity.
+func withAffinity(ctx context.Context, session string) context.Context {
+	if session == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, affinityKey{}, session)
+}
+
+// affinityFromContext returns the session whose replica is preferred by loads
+// using ctx, if any.
+func affinityFromContext(ctx context.Context) string {
+	session, _ := ctx.Value(affinityKey{}).(string)
+	return session
+}
+
+// affinityRank ranks a replica for a session. Each session prefers the
+// replica with the highest rank, so that sessions are spread evenly across
+// replicas and, as replicas come and go, only the sessions of those replicas
+// move.
+func affinityRank(session string, replica int) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(session))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.Itoa(replica)))
+	return h.Sum64()
+}
+
+// selectReplica selects the replica of a model that serves a request, given
+// the key of the model's first replica. A replica that isn't loaded is
+// selected if no replicas are loaded or if all loaded replicas are busy, in
+// which case the loaded replica that would otherwise have been selected is
+// also returned, to fall back to if there's no room for another replica. If
+// the request has an affinity for a session, the session's replica is
+// selected regardless of load, falling back to the loaded replica that the
+// session ranks highest. The caller must hold the loader lock.
+func (l *loader) selectReplica(first runnerKey, count int, routing inference.ReplicaRouting, session string) (runnerKey, *runnerKey) {
+	var loaded []runnerKey
+	missing := -1
+	busy := true
+	for replica := range count {
+		key := first
+		key.replica = replica
+		if info, ok := l.runners[key]; ok {
+			loaded = append(loaded, key)
+			busy = busy && l.references[info.slot] > 0
+		} else if missing < 0 {
+			missing = replica
+		}
+	}
+	if session != "" && count > 1 {
+		return l.selectSessionReplica(first, count, loaded, session)
+	}
+	if len(loaded) == 0 {
+		return first, nil
+	}
+
+	var selected runnerKey
+	switch routing {
+	case inference.ReplicaRoutingRoundRobin:
+		selected = loaded[l.replicaCursors[first]%len(loaded)]
+		l.replicaCursors[first]++
+	default:
+		selected = loaded[0]
+		for _, key := range loaded[1:] {
+			if l.references[l.runners[key].slot] < l.references[l.runners[selected].slot] {
+				selected = key
+			}
+		}
+	}
+
+	if missing >= 0 && busy {
+		key := first
+		key.replica = missing
+		return key, &selected
+	}
+	return selected, nil
+}
+
+// selectSessionReplica selects the replica of a session, given the model's
+// loaded replicas. The caller must hold the loader lock.
+func (l *loader) selectSessionReplica(first runnerKey, count int, loaded []runnerKey, session string) (runnerKey, *runnerKey) {
+	preferred := first
+	for replica := range count {
+		if affinityRank(session, replica) > affinityRank(session, preferred.replica) {
+			preferred.replica = replica
+		}
+	}
+	if _, ok := l.runners[preferred]; ok || len(loaded) == 0 {
+		return preferred, nil
+	}
+	fallback := loaded[0]
+	for _, key := range loaded[1:] {
+		if affinityRank(session, key.replica) > affinityRank(session, fallback.replica) {
+			fallback = key
+		}
+	}
+	return preferred, &fallback
+}
+
+// hasReplicas returns true if any replica of the runner with the specified
+// key is loaded. The caller must hold the loader lock.
+func (l *loader) hasReplicas(key runnerKey) bool {
+	for r := range l.runners {
+		if r.backend == key.backend && r.modelID == key.modelID &&
+			r.draftModelID == key.draftModelID && r.mode == key.mode {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package scheduling
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+func TestSelectReplica(t *testing.T) {
+	first := makeRunnerKey("test-backend", "model", "", inference.BackendModeCompletion)
+	replica := func(i int) runnerKey {
+		key := first
+		key.replica = i
+		return key
+	}
+
+	tests := []struct {
+		name     string
+		routing  inference.ReplicaRouting
+		session  string
+		count    int
+		loaded   []uint // reference counts of loaded replicas 0..n-1
+		expected []int  // selected replicas over successive selections
+		fallback int    // fallback replica of the first selection, or -1
+	}{
+		{name: "nothing loaded", count: 3, expected: []int{0}, fallback: -1},
+		{name: "single replica", count: 1, loaded: []uint{4}, expected: []int{0, 0}, fallback: -1},
+		{name: "least loaded", count: 3, loaded: []uint{2, 0, 1}, expected: []int{1, 1}, fallback: -1},
+		{name: "scale up when busy", count: 3, loaded: []uint{2, 1}, expected: []int{2}, fallback: 1},
+		{name: "all replicas busy", count: 2, loaded: []uint{2, 1}, expected: []int{1}, fallback: -1},
+		{name: "single replica session", session: "chat", count: 1, loaded: []uint{1}, expected: []int{0}, fallback: -1},
+		{name: "round robin", routing: inference.ReplicaRoutingRoundRobin, count: 2, loaded: []uint{0, 3}, expected: []int{0, 1, 0}, fallback: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := newLoader(createTestLogger(), nil, nil, nil, &mockSystemMemoryInfo{})
+			loader.references = make([]uint, len(tt.loaded))
+			for i, references := range tt.loaded {
+				loader.runners[replica(i)] = runnerInfo{slot: i}
+				loader.references[i] = references
+			}
+
+			for i, expected := range tt.expected {
+				key, fallback := loader.selectReplica(first, tt.count, tt.routing, tt.session)
+				if key != replica(expected) {
+					t.Errorf("selection %d: expected replica %d, got %d", i, expected, key.replica)
+				}
+				if i > 0 {
+					continue
+				}
+				if tt.fallback < 0 && fallback != nil {
+					t.Errorf("expected no fallback, got replica %d", fallback.replica)
+				} else if tt.fallback >= 0 && (fallback == nil || *fallback != replica(tt.fallback)) {
+					t.Errorf("expected fallback to replica %d, got %v", tt.fallback, fallback)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectReplicaAffinity(t *testing.T) {
+	const count = 4
+	first := makeRunnerKey("test-backend", "model", "", inference.BackendModeCompletion)
+	loader := newLoader(createTestLogger(), nil, nil, nil, &mockSystemMemoryInfo{})
+	loader.references = make([]uint, count)
+	loadReplica := func(replica int) {
+		key := first
+		key.replica = replica
+		loader.runners[key] = runnerInfo{slot: replica}
+	}
+
+	// Sessions are routed to the same replica, even before it's loaded, and
+	// are spread across replicas.
+	preferred := make(map[string]int)
+	used := make(map[int]bool)
+	for i := range 32 {
+		session := fmt.Sprintf("session-%d", i)
+		key, _ := loader.selectReplica(first, count, inference.ReplicaRoutingLeastLoaded, session)
+		preferred[session] = key.replica
+		used[key.replica] = true
+	}
+	if len(used) < 2 {
+		t.Errorf("expected sessions to be spread across replicas, got %v", used)
+	}
+
+	// Sessions whose replicas aren't loaded fall back to a loaded replica.
+	loadReplica(0)
+	loadReplica(1)
+	loader.references[0] = 5
+	for session, replica := range preferred {
+		key, fallback := loader.selectReplica(first, count, inference.ReplicaRoutingLeastLoaded, session)
+		if key.replica != replica {
+			t.Errorf("session %s: expected replica %d, got %d", session, replica, key.replica)
+		}
+		if replica < 2 && fallback != nil {
+			t.Errorf("session %s: unexpected fallback to replica %d", session, fallback.replica)
+		} else if replica >= 2 && (fallback == nil || fallback.replica >= 2) {
+			t.Errorf("session %s: expected fallback to a loaded replica, got %v", session, fallback)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package scheduling
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+// ggufPoolingTypeRank is the GGUF pooling type of reranker models, which
+// score pairs of queries and documents with a classification head.
+const ggufPoolingTypeRank = "4"
+
+// isReranker returns true if a model is a reranker, which is loaded in
+// reranking mode ahead of requests. Reranker GGUF files rank their pooled
+// embeddings, and safetensors rerankers are sequence classification models.
+func isReranker(config types.Config) bool {
+	if arch := config.GGUF["general.architecture"]; arch != "" {
+		return config.GGUF[arch+".pooling_type"] == ggufPoolingTypeRank
+	}
+	return strings.HasSuffix(config.Architecture, "ForSequenceClassification")
+}
+
+// rerankRequest is a rerank request in the shape of the Cohere and Jina APIs,
+// which every backend is sent in the same form, and whose response is
+// normalized.
+type rerankRequest struct {
+	// fields are the fields of the request that are passed to the backend,
+	// with the documents as strings and without top_n and return_documents,
+	// which are applied to the response.
+	fields map[string]json.RawMessage
+	// documents are the texts of the documents to rerank.
+	documents []string
+	// topN is the number of results to return, or 0 to return all of them.
+	topN int
+	// returnDocuments indicates whether the texts of the documents are
+	// included in the results.
+	returnDocuments bool
+}
+
+// parseRerankRequest parses a rerank request body.
+func parseRerankRequest(body []byte) (*rerankRequest, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, errors.New("invalid request")
+	}
+	request := &rerankRequest{fields: fields}
+
+	var query string
+	if err := json.Unmarshal(fields["query"], &query); err != nil || query == "" {
+		return nil, errors.New("query is required")
+	}
+	var documents []json.RawMessage
+	if err := json.Unmarshal(fields["documents"], &documents); err != nil || len(documents) == 0 {
+		return nil, errors.New("documents are required")
+	}
+	request.documents = make([]string, len(documents))
+	for i, document := range documents {
+		text, err := rerankDocumentText(document)
+		if err != nil {
+			return nil, err
+		}
+		request.documents[i] = text
+	}
+	if raw, ok := fields["top_n"]; ok && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &request.topN); err != nil || request.topN < 1 {
+			return nil, errors.New("top_n must be a positive integer")
+		}
+	}
+	if raw, ok := fields["return_documents"]; ok && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &request.returnDocuments); err != nil {
+			return nil, errors.New("return_documents must be a boolean")
+		}
+	}
+	delete(fields, "top_n")
+	delete(fields, "return_documents")
+
+	var err error
+	if fields["documents"], err = json.Marshal(request.documents); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// rerankDocumentText returns the text of a document, which is either a string
+// or an object with a text field.
+func rerankDocumentText(document json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(document, &text); err == nil {
+		return text, nil
+	}
+	var object rerankDocument
+	if err := json.Unmarshal(document, &object); err != nil {
+		return "", errors.New("documents must be strings or objects with a text field")
+	}
+	return object.Text, nil
+}
+
+// body returns the body of the request sent to backends.
+func (r *rerankRequest) body() ([]byte, error) {
+	return json.Marshal(r.fields)
+}
+
+// rerankDocument is a document included in a rerank response.
+type rerankDocument struct {
+	Text string `json:"text"`
+}
+
+// rerankResult is an entry of a rerank response.
+type rerankResult struct {
+	Index          int             `json:"index"`
+	RelevanceScore float64         `json:"relevance_score"`
+	Document       *rerankDocument `json:"document,omitempty"`
+}
+
+// rerankUsage is the token usage of a rerank response.
+type rerankUsage struct {
+	PromptTokens int `json:"prompt_tokens,omitempty"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// rerankResponse is a normalized rerank response.
+type rerankResponse struct {
+	Model   string         `json:"model,omitempty"`
+	Object  string         `json:"object"`
+	Results []rerankResult `json:"results"`
+	Usage   *rerankUsage   `json:"usage,omitempty"`
+}
+
+// backendRerankResponse is a rerank response from a backend. Backends name
+// the scores of documents relevance_score or score.
+type backendRerankResponse struct {
+	Model   string `json:"model"`
+	Results []struct {
+		Index          int      `json:"index"`
+		RelevanceScore *float64 `json:"relevance_score"`
+		Score          *float64 `json:"score"`
+	} `json:"results"`
+	Usage *rerankUsage `json:"usage"`
+}
+
+// response normalizes the response of a backend to the request: scores are
+// mapped to probabilities if they're raw logits, results are ordered by
+// relevance and limited to top_n, and documents are included if requested.
+func (r *rerankRequest) response(body []byte, rawScores bool) bufferedResponse {
+	var backendResponse backendRerankResponse
+	if err := json.Unmarshal(body, &backendResponse); err != nil {
+		return errorResponse(http.StatusBadGateway, "invalid rerank response from backend")
+	}
+	response := rerankResponse{
+		Model:   backendResponse.Model,
+		Object:  "list",
+		Results: make([]rerankResult, 0, len(backendResponse.Results)),
+		Usage:   backendResponse.Usage,
+	}
+	seen := make([]bool, len(r.documents))
+	for _, result := range backendResponse.Results {
+		if result.Index < 0 || result.Index >= len(r.documents) || seen[result.Index] {
+			return errorResponse(http.StatusBadGateway, fmt.Sprintf("invalid rerank response from backend: unexpected index %d", result.Index))
+		}
+		seen[result.Index] = true
+		score := result.RelevanceScore
+		if score == nil {
+			score = result.Score
+		}
+		if score == nil {
+			return errorResponse(http.StatusBadGateway, "invalid rerank response from backend: missing score")
+		}
+		relevance := *score
+		if rawScores {
+			relevance = 1 / (1 + math.Exp(-relevance))
+		}
+		response.Results = append(response.Results, rerankResult{Index: result.Index, RelevanceScore: relevance})
+	}
+	slices.SortFunc(response.Results, func(a, b rerankResult) int {
+		if c := cmp.Compare(b.RelevanceScore, a.RelevanceScore); c != 0 {
+			return c
+		}
+		return a.Index - b.Index
+	})
+	if r.topN > 0 && len(response.Results) > r.topN {
+		response.Results = response.Results[:r.topN]
+	}
+	if r.returnDocuments {
+		for i := range response.Results {
+			response.Results[i].Document = &rerankDocument{Text: r.documents[response.Results[i].Index]}
+		}
+	}
+
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return errorResponse(http.StatusInternalServerError, "failed to encode response")
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	return bufferedResponse{status: http.StatusOK, header: header, body: encoded}
+}
@@ -0,0 +1,159 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+)
+
+func TestParseRerankRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		documents []string
+		err       bool
+	}{
+		{name: "strings", body: `{"model":"m","query":"q","documents":["a","b"]}`, documents: []string{"a", "b"}},
+		{name: "objects", body: `{"model":"m","query":"q","documents":[{"text":"a"},"b"],"top_n":1,"return_documents":true}`, documents: []string{"a", "b"}},
+		{name: "missing query", body: `{"model":"m","documents":["a"]}`, err: true},
+		{name: "missing documents", body: `{"model":"m","query":"q"}`, err: true},
+		{name: "empty documents", body: `{"model":"m","query":"q","documents":[]}`, err: true},
+		{name: "invalid document", body: `{"model":"m","query":"q","documents":[1]}`, err: true},
+		{name: "invalid top_n", body: `{"model":"m","query":"q","documents":["a"],"top_n":0}`, err: true},
+		{name: "invalid return_documents", body: `{"model":"m","query":"q","documents":["a"],"return_documents":"yes"}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := parseRerankRequest([]byte(tt.body))
+			if tt.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			body, err := request.body()
+			if err != nil {
+				t.Fatalf("Failed to encode request: %v", err)
+			}
+			var sent struct {
+				Documents       []string `json:"documents"`
+				TopN            *int     `json:"top_n"`
+				ReturnDocuments *bool    `json:"return_documents"`
+			}
+			if err := json.Unmarshal(body, &sent); err != nil {
+				t.Fatalf("Failed to decode request: %v", err)
+			}
+			if len(sent.Documents) != len(tt.documents) {
+				t.Fatalf("Expected documents %v, got %v", tt.documents, sent.Documents)
+			}
+			for i := range tt.documents {
+				if sent.Documents[i] != tt.documents[i] {
+					t.Errorf("Expected documents %v, got %v", tt.documents, sent.Documents)
+				}
+			}
+			if sent.TopN != nil || sent.ReturnDocuments != nil {
+				t.Error("Expected top_n and return_documents to be removed from the request passed to the backend")
+			}
+		})
+	}
+}
+
+func TestRerankResponse(t *testing.T) {
+	request, err := parseRerankRequest([]byte(`{"model":"m","query":"q","documents":["a","b","c"],"top_n":2,"return_documents":true}`))
+	if err != nil {
+		t.Fatalf("Failed to parse request: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		rawScores bool
+		indexes   []int
+		scores    []float64
+		status    int
+	}{
+		{
+			name:    "probabilities",
+			body:    `{"model":"m","results":[{"index":0,"relevance_score":0.1},{"index":1,"relevance_score":0.9},{"index":2,"relevance_score":0.5}]}`,
+			indexes: []int{1, 2},
+			scores:  []float64{0.9, 0.5},
+			status:  http.StatusOK,
+		},
+		{
+			name:      "logits",
+			body:      `{"model":"m","results":[{"index":0,"score":0},{"index":1,"score":-2},{"index":2,"score":0}]}`,
+			rawScores: true,
+			indexes:   []int{0, 2},
+			scores:    []float64{0.5, 0.5},
+			status:    http.StatusOK,
+		},
+		{
+			name:   "unexpected index",
+			body:   `{"results":[{"index":3,"relevance_score":0.1}]}`,
+			status: http.StatusBadGateway,
+		},
+		{
+			name:   "duplicate index",
+			body:   `{"results":[{"index":0,"relevance_score":0.1},{"index":0,"relevance_score":0.2}]}`,
+			status: http.StatusBadGateway,
+		},
+		{
+			name:   "missing score",
+			body:   `{"results":[{"index":0}]}`,
+			status: http.StatusBadGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := request.response([]byte(tt.body), tt.rawScores)
+			if result.status != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, result.status, result.body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var response rerankResponse
+			if err := json.Unmarshal(result.body, &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Results) != len(tt.indexes) {
+				t.Fatalf("Expected %d results, got %v", len(tt.indexes), response.Results)
+			}
+			for i, result := range response.Results {
+				if result.Index != tt.indexes[i] || math.Abs(result.RelevanceScore-tt.scores[i]) > 1e-9 {
+					t.Errorf("Expected result %d to be document %d with score %v, got %+v", i, tt.indexes[i], tt.scores[i], result)
+				}
+				if result.Document == nil || result.Document.Text != request.documents[result.Index] {
+					t.Errorf("Expected result %d to include its document, got %+v", i, result.Document)
+				}
+			}
+		})
+	}
+}
+
+func TestIsReranker(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   types.Config
+		expected bool
+	}{
+		{name: "gguf reranker", config: types.Config{GGUF: map[string]string{"general.architecture": "bert", "bert.pooling_type": "4"}}, expected: true},
+		{name: "gguf embeddings", config: types.Config{GGUF: map[string]string{"general.architecture": "bert", "bert.pooling_type": "2"}}},
+		{name: "gguf completions", config: types.Config{GGUF: map[string]string{"general.architecture": "llama"}}},
+		{name: "safetensors reranker", config: types.Config{Architecture: "XLMRobertaForSequenceClassification"}, expected: true},
+		{name: "safetensors completions", config: types.Config{Architecture: "LlamaForCausalLM"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isReranker(tt.config); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
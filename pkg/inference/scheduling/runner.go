@@ -11,9 +11,11 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/backends"
 	"github.com/docker/model-runner/pkg/internal/utils"
 	"github.com/docker/model-runner/pkg/logging"
 	"github.com/docker/model-runner/pkg/metrics"
@@ -69,6 +71,18 @@ type runner struct {
 	openAIRecorder *metrics.OpenAIRecorder
 	// err is the error returned by the runner's backend, only valid after done is closed.
 	err error
+	// crashed is true if the runner's backend exited without being
+	// terminated or was killed by the watchdog, only valid after done is
+	// closed.
+	crashed bool
+	// hung is set if the watchdog killed the runner's backend because it
+	// stopped responding.
+	hung atomic.Bool
+	// output is the recent error output of the runner's backend process.
+	output *outputTail
+	// pid is the PID of the runner's backend process, once it's started, or
+	// zero.
+	pid atomic.Int64
 }
 
 // run creates a new runner instance.
@@ -147,6 +161,7 @@ func run(
 		proxy:          proxy,
 		proxyLog:       proxyLog,
 		openAIRecorder: openAIRecorder,
+		output:         newOutputTail(maximumRunnerOutput),
 	}
 
 	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
@@ -172,6 +187,13 @@ func run(
 				return
 			case <-time.After(30 * time.Second):
 			}
+		} else if cause := context.Cause(req.Context()); errors.Is(cause, ErrFirstTokenTimeout) || errors.Is(cause, ErrGenerationTimeout) {
+			// A generation limit was exceeded before the backend responded.
+			http.Error(w, cause.Error(), http.StatusGatewayTimeout)
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			// The request deadline set by the client passed before the
+			// backend responded.
+			http.Error(w, ErrDeadlineExceeded.Error(), http.StatusGatewayTimeout)
 		} else {
 			w.WriteHeader(http.StatusBadGateway)
 		}
@@ -184,12 +206,16 @@ func run(
 
 	// Start the backend run loop.
 	go func() {
-		if err := backend.Run(runCtx, socket, modelID, modelRef, mode, runnerConfig); err != nil {
+		backendCtx := backends.WithProcess(backends.WithOutput(runCtx, r.output), func(pid int) {
+			r.pid.Store(int64(pid))
+		})
+		if err := backend.Run(backendCtx, socket, modelID, modelRef, mode, runnerConfig); err != nil {
 			log.Warnf("Backend %s running model %s exited with error: %v",
 				backend.Name(), utils.SanitizeForLog(modelRef), err,
 			)
 			r.err = err
 		}
+		r.crashed = runCtx.Err() == nil || r.hung.Load()
 		close(runDone)
 	}()
 
@@ -0,0 +1,132 @@
+package scheduling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// sanityCheckTimeout bounds the time spent on the sanity check performed
+	// once a runner is ready.
+	sanityCheckTimeout = time.Minute
+	// sanityCheckMessage is the user message used for the sanity check.
+	sanityCheckMessage = "Hello"
+)
+
+// unrenderedTemplatePattern matches Jinja syntax left over in a prompt whose
+// chat template wasn't rendered correctly.
+var unrenderedTemplatePattern = regexp.MustCompile(`\{\{|\}\}|\{%|%\}`)
+
+// specialTokenPattern matches raw special tokens, which are expected in a
+// rendered prompt but indicate a broken tokenizer or template in output.
+var specialTokenPattern = regexp.MustCompile(`<\|[A-Za-z0-9_]+\|>|</?s>|\[/?INST\]|<(?:start|end)_of_turn>`)
+
+// TemplateSanityError indicates that a model failed the tokenizer/template
+// sanity check performed after loading. It carries the rendered prompt (when
+// the backend can render one) and the generated output for debugging.
+type TemplateSanityError struct {
+	// Reason describes the detected problem.
+	Reason string
+	// Prompt is the prompt rendered from the sanity check messages.
+	Prompt string
+	// Output is the text generated for the sanity check.
+	Output string
+}
+
+// Error implements error.Error.
+func (e *TemplateSanityError) Error() string {
+	return fmt.Sprintf("model failed template sanity check: %s (rendered prompt: %q, output: %q)",
+		e.Reason, e.Prompt, e.Output)
+}
+
+// checkOutput verifies a rendered prompt and the generated output. An empty
+// prompt means that the backend can't render prompts.
+func checkOutput(prompt, output string) error {
+	if prompt != "" {
+		if artifact := unrenderedTemplatePattern.FindString(prompt); artifact != "" {
+			return &TemplateSanityError{Reason: fmt.Sprintf("unrendered template syntax %q in prompt", artifact), Prompt: prompt, Output: output}
+		}
+		if !strings.Contains(prompt, sanityCheckMessage) {
+			return &TemplateSanityError{Reason: "rendered prompt doesn't contain the message", Prompt: prompt, Output: output}
+		}
+	}
+	for _, pattern := range []*regexp.Regexp{unrenderedTemplatePattern, specialTokenPattern} {
+		if artifact := pattern.FindString(output); artifact != "" {
+			return &TemplateSanityError{Reason: fmt.Sprintf("template artifact %q in output", artifact), Prompt: prompt, Output: output}
+		}
+	}
+	return nil
+}
+
+// checkSanity renders the chat template for a short conversation and generates
+// a single token from it, failing if either contains template artifacts.
+// Problems unrelated to the template, such as backends that don't support the
+// requests, are logged and otherwise ignored.
+func (r *runner) checkSanity(ctx context.Context, modelRef string) error {
+	ctx, cancel := context.WithTimeout(ctx, sanityCheckTimeout)
+	defer cancel()
+
+	messages := []map[string]string{{"role": "user", "content": sanityCheckMessage}}
+
+	// Render the prompt using llama.cpp's template endpoint. Other backends
+	// don't expose one, in which case only the output is checked.
+	var rendered struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := r.post(ctx, "/apply-template", map[string]any{"messages": messages}, &rendered); err != nil {
+		r.log.Debugf("Unable to render prompt for sanity check of %s: %v", r.model, err)
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := r.post(ctx, "/v1/chat/completions", map[string]any{
+		"model":       modelRef,
+		"messages":    messages,
+		"max_tokens":  1,
+		"temperature": 0,
+	}, &completion); err != nil {
+		r.log.Warnf("Unable to run sanity check for %s: %v", r.model, err)
+		return nil
+	}
+	var output string
+	if len(completion.Choices) > 0 {
+		output = completion.Choices[0].Message.Content
+	}
+
+	return checkOutput(rendered.Prompt, output)
+}
+
+// post sends a JSON request to the backend and decodes its JSON response.
+func (r *runner) post(ctx context.Context, path string, body, response any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
@@ -0,0 +1,44 @@
+package scheduling
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckOutput(t *testing.T) {
+	const chatML = "<|im_start|>user\nHello<|im_end|>\n<|im_start|>assistant\n"
+
+	tests := []struct {
+		name   string
+		prompt string
+		output string
+		ok     bool
+	}{
+		{name: "valid", prompt: chatML, output: "Hi", ok: true},
+		{name: "no rendered prompt", output: "Hi", ok: true},
+		{name: "unrendered template", prompt: "{{ messages[0].content }}", output: "Hi"},
+		{name: "message missing from prompt", prompt: "<|im_start|>assistant\n", output: "Hi"},
+		{name: "special token in output", prompt: chatML, output: "<|im_start|>"},
+		{name: "end of sequence in output", output: "</s>"},
+		{name: "instruction marker in output", output: "[INST]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOutput(tt.prompt, tt.output)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var sanityErr *TemplateSanityError
+			if !errors.As(err, &sanityErr) {
+				t.Fatalf("expected TemplateSanityError, got %v", err)
+			}
+			if sanityErr.Prompt != tt.prompt {
+				t.Errorf("expected prompt %q, got %q", tt.prompt, sanityErr.Prompt)
+			}
+		})
+	}
+}
@@ -1,23 +1,29 @@
 package scheduling
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/docker/model-runner/pkg/apikeys"
 	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/gputelemetry"
 	"github.com/docker/model-runner/pkg/inference"
 	"github.com/docker/model-runner/pkg/inference/backends/llamacpp"
-	"github.com/docker/model-runner/pkg/inference/backends/vllm"
 	"github.com/docker/model-runner/pkg/inference/memory"
 	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/docker/model-runner/pkg/inference/platform"
 	"github.com/docker/model-runner/pkg/internal/utils"
 	"github.com/docker/model-runner/pkg/logging"
 	"github.com/docker/model-runner/pkg/metrics"
 	"github.com/mattn/go-shellwords"
+	dto "github.com/prometheus/client_model/go"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -36,10 +42,30 @@ type Scheduler struct {
 	installer *installer
 	// loader is the backend loader.
 	loader *loader
+	// queue bounds the requests queued for each model.
+	queue *requestQueue
+	// timeouts bounds the time that backends spend serving requests.
+	timeouts *timeoutSettings
+	// embeddings coalesces embeddings requests into batches.
+	embeddings *embeddingBatcher
+	// allowlist restricts the models that can be used for inference.
+	allowlist *modelAllowlist
+	// cluster tracks the peers that models can be placed on.
+	cluster *cluster
 	// tracker is the metrics tracker.
 	tracker *metrics.Tracker
 	// openAIRecorder is used to record OpenAI API inference requests and responses.
 	openAIRecorder *metrics.OpenAIRecorder
+	// host describes the hardware that backends are selected for.
+	host hostPlatform
+	// preferredBackendsLock guards preferredBackends.
+	preferredBackendsLock sync.Mutex
+	// preferredBackends maps model IDs to the names of the backends that they
+	// were explicitly configured with.
+	preferredBackends map[string]string
+	// gpuTelemetry collects the telemetry of the GPUs, if enabled. It's set
+	// before the scheduler runs.
+	gpuTelemetry *gputelemetry.Collector
 }
 
 // NewScheduler creates a new inference scheduler.
@@ -62,8 +88,19 @@ func NewScheduler(
 		modelManager:   modelManager,
 		installer:      newInstaller(log, backends, httpClient),
 		loader:         newLoader(log, backends, modelManager, openAIRecorder, sysMemInfo),
+		queue:          newRequestQueue(QueueLimits{}),
+		timeouts:       newTimeoutSettings(),
+		embeddings:     newEmbeddingBatcher(),
+		allowlist:      &modelAllowlist{},
+		cluster:        newCluster(log.WithField("component", "cluster")),
 		tracker:        tracker,
 		openAIRecorder: openAIRecorder,
+		host: hostPlatform{
+			appleSilicon: platform.SupportsMLX(),
+			gpuVendors:   platform.GPUVendors(),
+			devices:      sysMemInfo.GetGPUDevices(),
+		},
+		preferredBackends: make(map[string]string),
 	}
 
 	// Scheduler successfully initialized.
@@ -88,36 +125,162 @@ func (s *Scheduler) Run(ctx context.Context) error {
 		return nil
 	})
 
+	// Start the watchdog for hung runners.
+	workers.Go(func() error {
+		s.loader.watchdog(workerCtx)
+		return nil
+	})
+
+	// Start polling peers.
+	workers.Go(func() error {
+		s.cluster.monitor(workerCtx)
+		return nil
+	})
+
+	// Start sampling GPUs, if enabled.
+	if s.gpuTelemetry != nil {
+		workers.Go(func() error {
+			s.gpuTelemetry.Run(workerCtx)
+			return nil
+		})
+	}
+
 	// Wait for all workers to exit.
 	return workers.Wait()
 }
 
-// selectBackendForModel selects the appropriate backend for a model based on its format.
-// If the model is in safetensors format, it will prefer vLLM if available.
-func (s *Scheduler) selectBackendForModel(model types.Model, backend inference.Backend, modelRef string) inference.Backend {
+// selectBackendForModel selects the backend that serves a model in the
+// specified mode. A requested backend is used if it can serve the model's
+// format. Otherwise, the backend that the model was explicitly configured with
+// or, failing that, the most suitable installed backend for the model and host
+// is used. requested is nil if no backend was requested.
+func (s *Scheduler) selectBackendForModel(model types.Model, requested inference.Backend, mode inference.BackendMode, modelRef string) inference.Backend {
+	fallback := requested
+	if fallback == nil {
+		fallback = s.defaultBackend
+	}
+
 	config, err := model.Config()
 	if err != nil {
 		s.log.Warnln("failed to fetch model config:", err)
-		return backend
+		return fallback
+	}
+
+	// Safetensors models that were converted to GGUF can also be served by
+	// backends that serve GGUF models.
+	converted := config.Format == types.FormatSafetensors && s.convertedToGGUF(model)
+	supported := func(backend inference.Backend) bool {
+		return supportsFormat(backend.Name(), config.Format) ||
+			(converted && supportsFormat(backend.Name(), types.FormatGGUF))
 	}
 
-	if config.Format == types.FormatSafetensors {
-		if vllmBackend, ok := s.backends[vllm.Name]; ok && vllmBackend != nil {
-			return vllmBackend
+	if requested != nil && supported(requested) {
+		return requested
+	}
+	if requested == nil {
+		if id, err := model.ID(); err == nil {
+			if stored := s.settingsBackend(id); stored != nil && supported(stored) {
+				return stored
+			}
+			if preferred := s.preferredBackend(id); preferred != nil && supported(preferred) {
+				return preferred
+			}
+		}
+	}
+
+	// Prefer installed backends, but fall back to the best backend whose
+	// installation failed so that the failure is reported.
+	candidates := backendCandidates(config.Format, weightsSize(model), mode, s.host)
+	if converted {
+		candidates = append(candidates, llamacpp.Name)
+	}
+	for _, name := range candidates {
+		if backend := s.backends[name]; backend != nil && !s.installer.failed(name) {
+			return backend
+		}
+	}
+	for _, name := range candidates {
+		if backend := s.backends[name]; backend != nil {
+			return backend
 		}
-		s.log.Warnf("Model %s is in safetensors format but vLLM backend is not available. "+
-			"Backend %s may not support this format and could fail at runtime.",
-			utils.SanitizeForLog(modelRef), backend.Name())
 	}
 
+	s.log.Warnf("Model %s is in %s format but no backend supporting it is available. "+
+		"Backend %s may not support this format and could fail at runtime.",
+		utils.SanitizeForLog(modelRef), config.Format, fallback.Name())
+	return fallback
+}
+
+// convertedToGGUF returns true if a model's bundle has GGUF weights that it was
+// converted to.
+func (s *Scheduler) convertedToGGUF(model types.Model) bool {
+	if s.modelManager == nil {
+		return false
+	}
+	id, err := model.ID()
+	return err == nil && s.modelManager.HasGGUF(id)
+}
+
+// settingsBackend returns the backend specified by the settings stored with a
+// model, if any.
+func (s *Scheduler) settingsBackend(modelID string) inference.Backend {
+	if s.modelManager == nil {
+		return nil
+	}
+	settings, err := s.modelManager.GetSettings(modelID)
+	if err != nil || settings.Backend == "" {
+		return nil
+	}
+	backend := s.backends[settings.Backend]
+	if backend == nil {
+		s.log.Warnf("Ignoring unknown backend %q in the settings of model %s", utils.SanitizeForLog(settings.Backend), modelID)
+	}
 	return backend
 }
 
+// preferredBackend returns the backend that a model was explicitly configured
+// with, if any.
+func (s *Scheduler) preferredBackend(modelID string) inference.Backend {
+	s.preferredBackendsLock.Lock()
+	defer s.preferredBackendsLock.Unlock()
+	return s.backends[s.preferredBackends[modelID]]
+}
+
+// setPreferredBackend records the backend that a model was explicitly
+// configured with, so that requests which don't specify a backend are served
+// by it.
+func (s *Scheduler) setPreferredBackend(modelID string, backend inference.Backend) {
+	s.preferredBackendsLock.Lock()
+	defer s.preferredBackendsLock.Unlock()
+	s.preferredBackends[modelID] = backend.Name()
+}
+
 // ResetInstaller resets the backend installer with a new HTTP client.
 func (s *Scheduler) ResetInstaller(httpClient *http.Client) {
 	s.installer = newInstaller(s.log, s.backends, httpClient)
 }
 
+// UninstallBackend unloads all runners for the specified backend and then
+// uninstalls it. The backend can't be loaded again in between.
+func (s *Scheduler) UninstallBackend(ctx context.Context, backend string) error {
+	if _, ok := s.backends[backend]; !ok {
+		return ErrBackendNotFound
+	}
+	err := s.installer.uninstall(ctx, backend, func(ctx context.Context) error {
+		return s.loader.unloadBackend(ctx, backend)
+	})
+	if err != nil {
+		s.loader.allowBackend(backend)
+	}
+	return err
+}
+
+// ModelLoaded returns true if the model with the specified ID is loaded by a
+// runner, in which case it mustn't be evicted from the store.
+func (s *Scheduler) ModelLoaded(id string) bool {
+	return s.loader.modelLoaded(context.Background(), id)
+}
+
 // GetRunningBackendsInfo returns information about all running backends as a slice
 func (s *Scheduler) GetRunningBackendsInfo(ctx context.Context) []BackendStatus {
 	return s.getLoaderStatus(ctx)
@@ -140,19 +303,64 @@ func (s *Scheduler) getLoaderStatus(ctx context.Context) []BackendStatus {
 				Mode:        key.mode.String(),
 				LastUsed:    time.Time{},
 				InUse:       s.loader.references[runnerInfo.slot] > 0,
+				Devices:     s.loader.deviceAssignments[runnerInfo.slot],
+				Replica:     key.replica,
 			}
 
 			if s.loader.references[runnerInfo.slot] == 0 {
 				status.LastUsed = s.loader.timestamps[runnerInfo.slot]
 			}
+			if record := s.loader.crashes[crashKey(key)]; record != nil {
+				status.Restarts = record.restarts
+				status.LastError = record.lastError
+			}
 
 			result = append(result, status)
 		}
 	}
 
+	// Report crashed models that aren't loaded, so that they don't silently
+	// disappear.
+	for key, record := range s.loader.crashes {
+		if s.loader.hasReplicas(key) {
+			continue
+		}
+		result = append(result, BackendStatus{
+			BackendName: key.backend,
+			ModelName:   record.modelRef,
+			Mode:        key.mode.String(),
+			Restarts:    record.restarts,
+			Restarting:  !record.restartAt.IsZero() || record.restarting,
+			LastError:   record.lastError,
+		})
+	}
+
 	return result
 }
 
+// InFlightRequests returns the number of requests being served by the runners
+// of each backend with requests in flight.
+func (s *Scheduler) InFlightRequests(ctx context.Context) map[string]int {
+	if !s.loader.lock(ctx) {
+		return nil
+	}
+	defer s.loader.unlock()
+
+	inFlight := make(map[string]int)
+	for key, runnerInfo := range s.loader.runners {
+		if references := s.loader.references[runnerInfo.slot]; references > 0 {
+			inFlight[key.backend] += int(references)
+		}
+	}
+	return inFlight
+}
+
+// Events returns the recent scheduler decisions selected by the filter,
+// oldest first.
+func (s *Scheduler) Events(filter EventFilter) []SchedulerEvent {
+	return s.loader.events.query(filter)
+}
+
 // GetAllActiveRunners returns information about all active runners
 func (s *Scheduler) GetAllActiveRunners() []metrics.ActiveRunner {
 	runningBackends := s.getLoaderStatus(context.Background())
@@ -189,6 +397,26 @@ func (s *Scheduler) GetAllActiveRunners() []metrics.ActiveRunner {
 	return activeRunners
 }
 
+// GetServerMetrics returns the metrics of the requests served by the
+// scheduler and of the runners it loads, along with the memory that the
+// backends estimate that the loaded models require.
+func (s *Scheduler) GetServerMetrics() []*dto.MetricFamily {
+	families := s.loader.serverMetrics.Families(s.loader.memoryEstimates(context.Background()))
+	if s.gpuTelemetry != nil {
+		families = append(families, s.gpuTelemetry.Families()...)
+	}
+	return families
+}
+
+// GPUTelemetry returns the latest sample of the telemetry of the GPUs, or
+// false if GPU telemetry is disabled.
+func (s *Scheduler) GPUTelemetry() (gputelemetry.Status, bool) {
+	if s.gpuTelemetry == nil {
+		return gputelemetry.Status{}, false
+	}
+	return s.gpuTelemetry.Status(), true
+}
+
 // GetLlamaCppSocket returns the Unix socket path for an active llama.cpp runner
 func (s *Scheduler) GetLlamaCppSocket() (string, error) {
 	runningBackends := s.getLoaderStatus(context.Background())
@@ -216,6 +444,176 @@ func (s *Scheduler) GetLlamaCppSocket() (string, error) {
 	return "", errors.New("no active llama.cpp backend found")
 }
 
+// LookupBackend returns the backend with the specified name, or the default
+// backend if name is empty.
+func (s *Scheduler) LookupBackend(name string) (inference.Backend, error) {
+	backend := s.defaultBackend
+	if name != "" {
+		backend = s.backends[name]
+	}
+	if backend == nil {
+		return nil, ErrBackendNotFound
+	}
+	return backend, nil
+}
+
+// SetKeepAlive sets how long a model stays loaded after its last request or,
+// if model is empty, the default for all models without a keep-alive.
+func (s *Scheduler) SetKeepAlive(ctx context.Context, model string, keepAlive KeepAlive) error {
+	modelID := ""
+	if model != "" {
+		modelID = s.modelManager.ResolveID(model)
+	}
+	s.log.Infof("Setting keep-alive for %s to %s", cmp.Or(utils.SanitizeForLog(model, -1), "all models"), keepAlive)
+	return s.loader.setKeepAlive(ctx, modelID, keepAlive)
+}
+
+// SetPlacementPolicy sets the policy used to place runners on GPUs on systems
+// with multiple GPUs.
+func (s *Scheduler) SetPlacementPolicy(ctx context.Context, policy PlacementPolicy) error {
+	s.log.Infof("Setting GPU placement policy to %s", policy)
+	return s.loader.setPlacementPolicy(ctx, policy)
+}
+
+// EnableGPUTelemetry enables sampling the telemetry of the GPUs at the
+// specified interval while the scheduler runs, attributing the processes
+// using them to runners. It must be called before the scheduler runs.
+func (s *Scheduler) EnableGPUTelemetry(sampler gputelemetry.Sampler, interval time.Duration) {
+	s.gpuTelemetry = gputelemetry.NewCollector(s.log.WithField("component", "gpu-telemetry"), sampler, s.loader.gpuRunners, interval)
+}
+
+// SetQueueLimits sets the limits on the requests queued for each model.
+func (s *Scheduler) SetQueueLimits(limits QueueLimits) {
+	s.log.Infof("Setting queue limits: %d concurrent requests, %d queued requests, %s wait per model (fair share: %t)",
+		limits.MaxConcurrent, limits.MaxDepth, limits.MaxWait, limits.FairShare)
+	s.queue.setLimits(limits)
+}
+
+// SetGenerationTimeouts sets the default limits on the time that backends
+// spend serving requests, which apply to models without their own.
+func (s *Scheduler) SetGenerationTimeouts(timeouts GenerationTimeouts) {
+	s.log.Infof("Setting generation timeouts: %s to first token, %s in total", timeouts.FirstToken, timeouts.Total)
+	s.timeouts.setDefaults(timeouts)
+}
+
+// SetEmbeddingBatchSize sets the maximum number of inputs in the batches that
+// concurrent embeddings requests are coalesced into. Sizes below 2 disable
+// batching.
+func (s *Scheduler) SetEmbeddingBatchSize(size int) {
+	s.log.Infof("Setting embedding batch size: %d inputs", size)
+	s.embeddings.setSize(size)
+}
+
+// SetTotalMemory sets the total memory that models can be loaded into, e.g.
+// after the memory budget changed. Unused runners are evicted if the loaded
+// models no longer fit.
+func (s *Scheduler) SetTotalMemory(ctx context.Context, totalMemory inference.RequiredMemory) error {
+	s.log.Infof("Setting total memory to %s RAM and %s VRAM",
+		formatMemorySize(totalMemory.RAM), formatMemorySize(totalMemory.VRAM))
+	return s.loader.setTotalMemory(ctx, totalMemory)
+}
+
+// DefaultKeepAlive returns how long models without a keep-alive stay loaded
+// after their last request.
+func (s *Scheduler) DefaultKeepAlive(ctx context.Context) (KeepAlive, error) {
+	if !s.loader.lock(ctx) {
+		return 0, context.Canceled
+	}
+	defer s.loader.unlock()
+	return KeepAlive(s.loader.runnerIdleTimeout), nil
+}
+
+// SetAllowedModels restricts the models that can be used for inference to
+// those listed. An empty list allows all models.
+func (s *Scheduler) SetAllowedModels(models []string) {
+	if len(models) == 0 {
+		s.log.Infof("Allowing all models")
+	} else {
+		s.log.Infof("Allowing only models %s", utils.SanitizeForLog(strings.Join(models, ", "), -1))
+	}
+	s.allowlist.set(models)
+}
+
+// modelAllowed returns true if the model can be used for inference.
+func (s *Scheduler) modelAllowed(model string) bool {
+	return s.allowlist.allows(model, s.resolveLocalID)
+}
+
+// modelAllowedForKey returns true if the API key that authenticated the
+// request associated with ctx, if any, can use the model.
+func (s *Scheduler) modelAllowedForKey(ctx context.Context, model string) bool {
+	key, ok := apikeys.FromContext(ctx)
+	return !ok || modelListed(key.Models, model, s.resolveLocalID)
+}
+
+// resolveLocalID resolves a model reference to the ID of the local model, or
+// returns the reference if the model isn't available locally, so that it's
+// matched by reference.
+func (s *Scheduler) resolveLocalID(ref string) string {
+	if local, err := s.modelManager.GetLocal(ref); err == nil {
+		if id, err := local.ID(); err == nil {
+			return id
+		}
+	}
+	return ref
+}
+
+// SetPeers sets the base URLs of the peers that models can be placed on when
+// they don't fit locally.
+func (s *Scheduler) SetPeers(urls []string) error {
+	s.log.Infof("Setting cluster peers: %s", strings.Join(urls, ", "))
+	return s.cluster.setPeers(urls)
+}
+
+// SetClusterToken sets the bearer token that peers are polled with, which
+// peers that require API keys accept from the other model runners of their
+// cluster. It must be called before Run.
+func (s *Scheduler) SetClusterToken(token string) {
+	s.cluster.token = token
+}
+
+// NodeStatus returns the capacity of the local instance.
+func (s *Scheduler) NodeStatus() NodeStatus {
+	return s.loader.loads.nodeStatus()
+}
+
+// Peers returns the status of the peers.
+func (s *Scheduler) Peers() []PeerStatus {
+	peers := s.cluster.list()
+	statuses := make([]PeerStatus, len(peers))
+	for i, p := range peers {
+		statuses[i] = p.snapshot()
+	}
+	return statuses
+}
+
+// placeOnPeer returns the peer that a request for a model should be forwarded
+// to, or nil if it should be served locally. Requests are forwarded if their
+// model isn't loaded locally but is loaded on a peer, or if it doesn't fit in
+// the memory available locally but fits on a peer. Requests forwarded by
+// peers are always served locally.
+func (s *Scheduler) placeOnPeer(r *http.Request, backend inference.Backend, modelID string, mode inference.BackendMode) *peer {
+	if r.Header.Get(inference.ForwardedHeader) != "" || backend.UsesExternalModelManagement() || len(s.cluster.list()) == 0 {
+		return nil
+	}
+	if _, cold := s.loader.loads.coldStart(backend.Name(), modelID, mode); !cold {
+		return nil
+	}
+	runnerConfig := s.loader.getRunnerConfig(r.Context(), backend.Name(), modelID, mode)
+	required, err := backend.GetRequiredMemoryForModel(r.Context(), modelID, runnerConfig)
+	if err != nil {
+		// The local load reports the error.
+		return nil
+	}
+	status := s.loader.loads.nodeStatus()
+	available := inference.RequiredMemory{RAM: status.AvailableRAM, VRAM: status.AvailableVRAM}
+	p := s.cluster.place(modelID, required, available)
+	if p != nil {
+		s.log.Infof("Forwarding request for %s to peer %s", modelID, p.url.Redacted())
+	}
+	return p
+}
+
 // parseBackendMode converts a string mode to BackendMode
 func parseBackendMode(mode string) inference.BackendMode {
 	switch mode {
@@ -223,6 +621,10 @@ func parseBackendMode(mode string) inference.BackendMode {
 		return inference.BackendModeCompletion
 	case "embedding":
 		return inference.BackendModeEmbedding
+	case "transcription":
+		return inference.BackendModeTranscription
+	case "image-generation":
+		return inference.BackendModeImageGeneration
 	default:
 		return inference.BackendModeCompletion
 	}
@@ -230,8 +632,10 @@ func parseBackendMode(mode string) inference.BackendMode {
 
 // ConfigureRunner configures a runner for a specific model and backend.
 // It handles all the business logic of configuration including parsing flags,
-// determining mode, selecting backend, and setting runner configuration.
+// determining mode, selecting backend, and setting runner configuration. If
+// backend is nil, the backend is selected automatically.
 func (s *Scheduler) ConfigureRunner(ctx context.Context, backend inference.Backend, req ConfigureRequest, userAgent string) (inference.Backend, error) {
+	requested := backend
 	if backend == nil {
 		backend = s.defaultBackend
 	}
@@ -253,20 +657,102 @@ func (s *Scheduler) ConfigureRunner(ctx context.Context, backend inference.Backe
 	runnerConfig.ContextSize = req.ContextSize
 	runnerConfig.RuntimeFlags = runtimeFlags
 	runnerConfig.Speculative = req.Speculative
+	kvCacheType, err := inference.ParseKVCacheType(string(req.KVCacheType))
+	if err != nil {
+		return nil, err
+	}
+	runnerConfig.KVCacheType = kvCacheType
+	for _, device := range req.Devices {
+		if device < 0 {
+			return nil, fmt.Errorf("invalid device index: %d", device)
+		}
+	}
+	runnerConfig.Devices = req.Devices
+	if req.MaxConcurrentRequests < 0 {
+		return nil, fmt.Errorf("invalid maximum concurrent requests: %d", req.MaxConcurrentRequests)
+	}
+	var timeouts GenerationTimeouts
+	if req.FirstTokenTimeout != "" {
+		if timeouts.FirstToken, err = parseTimeout(req.FirstTokenTimeout); err != nil {
+			return nil, fmt.Errorf("invalid time to first token limit %q: %w", req.FirstTokenTimeout, err)
+		}
+	}
+	if req.GenerationTimeout != "" {
+		if timeouts.Total, err = parseTimeout(req.GenerationTimeout); err != nil {
+			return nil, fmt.Errorf("invalid generation time limit %q: %w", req.GenerationTimeout, err)
+		}
+	}
+	if req.RopeScaling != nil {
+		ropeScaling := *req.RopeScaling
+		if err := ropeScaling.Validate(); err != nil {
+			return nil, err
+		}
+		runnerConfig.RopeScaling = &ropeScaling
+	}
+
+	if req.Embeddings != nil {
+		embeddings := *req.Embeddings
+		if embeddings.Pooling, err = inference.ParseEmbeddingPooling(string(embeddings.Pooling)); err != nil {
+			return nil, err
+		}
+		runnerConfig.Embeddings = &embeddings
+	}
+
+	if req.Replicas != nil {
+		replicas := *req.Replicas
+		if err := replicas.Validate(); err != nil {
+			return nil, err
+		}
+		runnerConfig.Replicas = &replicas
+	}
 
-	// Determine mode from flags
+	// Remote models are served by the remote backend, in any mode.
+	if req.Remote != nil {
+		remoteConfig := *req.Remote
+		if err := remoteConfig.Validate(); err != nil {
+			return nil, err
+		}
+		runnerConfig.Remote = &remoteConfig
+		if backend = s.remoteBackend(); backend == nil {
+			return nil, ErrBackendNotFound
+		}
+		return backend, s.configureRemoteRunner(ctx, backend, req.Model, runnerConfig)
+	}
+
+	// Make sure the draft model is available before the runner is started
+	if req.Speculative != nil && req.Speculative.DraftModel != "" {
+		if err := s.modelManager.EnsureLocal(ctx, req.Speculative.DraftModel); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", errDraftModelUnavailable, utils.SanitizeForLog(req.Speculative.DraftModel, -1), err)
+		}
+	}
+
+	// Determine mode from flags and embedding options
 	mode := inference.BackendModeCompletion
-	if slices.Contains(runnerConfig.RuntimeFlags, "--embeddings") {
+	if slices.Contains(runnerConfig.RuntimeFlags, "--embeddings") || runnerConfig.Embeddings != nil {
 		mode = inference.BackendModeEmbedding
 	}
 
 	// Get model, track usage, and select appropriate backend
 	if model, err := s.modelManager.GetLocal(req.Model); err == nil {
+		// whisper and diffusion models only run in a single mode
+		if config, err := model.Config(); err == nil {
+			switch config.Format {
+			case types.FormatWhisper:
+				mode = inference.BackendModeTranscription
+			case types.FormatDiffusion:
+				mode = inference.BackendModeImageGeneration
+			}
+		}
+
 		// Configure is called by compose for each model
 		s.tracker.TrackModel(model, userAgent, "configure/"+mode.String())
 
-		// Automatically identify models for vLLM
-		backend = s.selectBackendForModel(model, backend, req.Model)
+		// Select the backend, remembering an explicit choice for requests
+		// that don't specify a backend
+		backend = s.selectBackendForModel(model, requested, mode, req.Model)
+		if id, err := model.ID(); err == nil && requested != nil {
+			s.setPreferredBackend(id, backend)
+		}
 	}
 
 	// Resolve model ID
@@ -278,5 +764,62 @@ func (s *Scheduler) ConfigureRunner(ctx context.Context, backend inference.Backe
 		return nil, err
 	}
 
+	// Set how long the model stays loaded, how many requests it serves
+	// concurrently, and how long it may take to serve them, which don't
+	// require restarting its runners
+	if req.KeepAlive != nil {
+		if err := s.loader.setKeepAlive(ctx, modelID, *req.KeepAlive); err != nil {
+			return nil, err
+		}
+	}
+	s.queue.setMaxConcurrent(modelID, req.MaxConcurrentRequests)
+	s.timeouts.setModel(modelID, timeouts)
+
 	return backend, nil
 }
+
+// remoteModes are the modes in which remote models are configured, since
+// their servers determine which endpoints are available.
+var remoteModes = []inference.BackendMode{
+	inference.BackendModeCompletion,
+	inference.BackendModeEmbedding,
+	inference.BackendModeTranscription,
+	inference.BackendModeImageGeneration,
+}
+
+// configureRemoteRunner sets the runner configuration of a remote model for
+// every mode.
+func (s *Scheduler) configureRemoteRunner(ctx context.Context, backend inference.Backend, model string, runnerConfig inference.BackendConfiguration) error {
+	modelID := utils.SanitizeForLog(model, -1)
+	for _, mode := range remoteModes {
+		if err := s.loader.setRunnerConfig(ctx, backend.Name(), modelID, mode, runnerConfig); err != nil {
+			s.log.Warnf("Failed to configure %s runner for %s: %s", backend.Name(), modelID, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// remoteBackend returns the backend that serves remote models, if any.
+func (s *Scheduler) remoteBackend() inference.Backend {
+	for _, backend := range s.backends {
+		if isRemoteBackend(backend) {
+			return backend
+		}
+	}
+	return nil
+}
+
+// remoteBackendForModel returns the remote backend if the model has been
+// configured as a remote model, which allows remote models to be requested
+// without specifying a backend.
+func (s *Scheduler) remoteBackendForModel(ctx context.Context, model string, mode inference.BackendMode) inference.Backend {
+	backend := s.remoteBackend()
+	if backend == nil {
+		return nil
+	}
+	if s.loader.getRunnerConfig(ctx, backend.Name(), utils.SanitizeForLog(model, -1), mode) == nil {
+		return nil
+	}
+	return backend
+}
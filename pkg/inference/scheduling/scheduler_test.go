@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/docker/model-runner/pkg/gpuinfo"
 	"github.com/docker/model-runner/pkg/inference"
 	"github.com/sirupsen/logrus"
 )
@@ -20,6 +21,10 @@ func (i systemMemoryInfo) GetTotalMemory() inference.RequiredMemory {
 	return inference.RequiredMemory{}
 }
 
+func (i systemMemoryInfo) GetGPUDevices() []gpuinfo.Device {
+	return nil
+}
+
 func TestCors(t *testing.T) {
 	// Verify that preflight requests work against non-existing handlers or
 	// method-specific handlers that do not support OPTIONS
@@ -48,6 +53,7 @@ func TestCors(t *testing.T) {
 			httpHandler := NewHTTPHandler(s, nil, []string{"*"})
 			req := httptest.NewRequest(http.MethodOptions, "http://model-runner.docker.internal"+tt.path, http.NoBody)
 			req.Header.Set("Origin", "docker.com")
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
 			w := httptest.NewRecorder()
 			httpHandler.ServeHTTP(w, req)
 
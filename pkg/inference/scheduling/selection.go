@@ -0,0 +1,133 @@
+package scheduling
+
+import (
+	"slices"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/gpuinfo"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/backends"
+	"github.com/docker/model-runner/pkg/inference/backends/llamacpp"
+	"github.com/docker/model-runner/pkg/inference/backends/mlx"
+	"github.com/docker/model-runner/pkg/inference/backends/onnx"
+	"github.com/docker/model-runner/pkg/inference/backends/sdcpp"
+	"github.com/docker/model-runner/pkg/inference/backends/sglang"
+	"github.com/docker/model-runner/pkg/inference/backends/tei"
+	"github.com/docker/model-runner/pkg/inference/backends/trtllm"
+	"github.com/docker/model-runner/pkg/inference/backends/vllm"
+	"github.com/docker/model-runner/pkg/inference/backends/whisper"
+	"github.com/docker/model-runner/pkg/inference/platform"
+)
+
+// backendFormats maps built-in backends to the model formats they can serve.
+// Backends that aren't listed, such as plugins, are assumed to serve any
+// format.
+var backendFormats = map[string][]types.Format{
+	llamacpp.Name: {types.FormatGGUF},
+	vllm.Name:     {types.FormatSafetensors},
+	sglang.Name:   {types.FormatSafetensors},
+	mlx.Name:      {types.FormatSafetensors},
+	tei.Name:      {types.FormatSafetensors},
+	onnx.Name:     {types.FormatONNX},
+	whisper.Name:  {types.FormatWhisper},
+	sdcpp.Name:    {types.FormatDiffusion},
+	trtllm.Name:   {types.FormatTensorRT},
+}
+
+// supportsFormat returns true if the named backend can serve models in the
+// specified format. Models without a format predate safetensors support and
+// are GGUF models.
+func supportsFormat(backend string, format types.Format) bool {
+	formats, ok := backendFormats[backend]
+	if !ok {
+		return true
+	}
+	if format == "" {
+		format = types.FormatGGUF
+	}
+	return slices.Contains(formats, format)
+}
+
+// hostPlatform describes the hardware that backends are selected for.
+type hostPlatform struct {
+	// appleSilicon indicates whether the host is an Apple Silicon Mac, where
+	// MLX is available.
+	appleSilicon bool
+	// gpuVendors are the vendors of the host's GPUs.
+	gpuVendors []platform.GPUVendor
+	// devices are the host's GPUs.
+	devices []gpuinfo.Device
+}
+
+// shardsAcrossGPUs returns true if a model of the specified size doesn't fit
+// on a single GPU but fits across all of them, which only backends that
+// support tensor parallelism can take advantage of.
+func (p hostPlatform) shardsAcrossGPUs(size uint64) bool {
+	if size == 0 || len(p.devices) < 2 {
+		return false
+	}
+	if !slices.Contains(p.gpuVendors, platform.GPUVendorNVIDIA) && !slices.Contains(p.gpuVendors, platform.GPUVendorAMD) {
+		return false
+	}
+	var largest, total uint64
+	for _, device := range p.devices {
+		largest = max(largest, device.VRAM)
+		total += device.VRAM
+	}
+	return size > largest && size <= total
+}
+
+// backendCandidates returns the names of the backends that can serve a model
+// with the specified format and weights size in the specified mode, in order of
+// preference.
+func backendCandidates(format types.Format, size uint64, mode inference.BackendMode, host hostPlatform) []string {
+	switch format {
+	case types.FormatONNX:
+		return []string{onnx.Name}
+	case types.FormatWhisper:
+		return []string{whisper.Name}
+	case types.FormatDiffusion:
+		return []string{sdcpp.Name}
+	case types.FormatTensorRT:
+		return []string{trtllm.Name}
+	case types.FormatSafetensors:
+	default:
+		return []string{llamacpp.Name}
+	}
+
+	// Prefer MLX on Apple Silicon, where vLLM and SGLang aren't available.
+	var generative []string
+	if host.appleSilicon {
+		generative = append(generative, mlx.Name)
+	}
+	generative = append(generative, vllm.Name, sglang.Name)
+
+	switch mode {
+	case inference.BackendModeEmbedding, inference.BackendModeReranking:
+		// Prefer TEI, which is dedicated to embedding and reranking models,
+		// unless the model needs to be sharded, which it doesn't support.
+		candidates := append([]string{tei.Name}, generative...)
+		if host.shardsAcrossGPUs(size) {
+			candidates = append(slices.Clone(generative), tei.Name)
+		}
+		// Neither MLX nor SGLang support reranking.
+		if mode == inference.BackendModeReranking {
+			candidates = slices.DeleteFunc(candidates, func(name string) bool {
+				return name == mlx.Name || name == sglang.Name
+			})
+		}
+		return candidates
+	default:
+		return generative
+	}
+}
+
+// weightsSize returns the total size of a model's weights, or zero if it can't
+// be determined.
+func weightsSize(model types.Model) uint64 {
+	size, err := backends.WeightsSize(model)
+	if err != nil {
+		return 0
+	}
+	return uint64(size)
+}
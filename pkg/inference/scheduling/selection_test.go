@@ -0,0 +1,143 @@
+package scheduling
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/gpuinfo"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/platform"
+)
+
+func TestSupportsFormat(t *testing.T) {
+	tests := []struct {
+		backend  string
+		format   types.Format
+		expected bool
+	}{
+		{backend: "llama.cpp", format: types.FormatGGUF, expected: true},
+		{backend: "llama.cpp", format: "", expected: true},
+		{backend: "llama.cpp", format: types.FormatSafetensors, expected: false},
+		{backend: "vllm", format: types.FormatSafetensors, expected: true},
+		{backend: "vllm", format: types.FormatGGUF, expected: false},
+		{backend: "whisper", format: types.FormatWhisper, expected: true},
+		{backend: "my-plugin", format: types.FormatSafetensors, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend+"/"+string(tt.format), func(t *testing.T) {
+			if got := supportsFormat(tt.backend, tt.format); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestBackendCandidates(t *testing.T) {
+	const gib = 1 << 30
+	linux := hostPlatform{}
+	mac := hostPlatform{appleSilicon: true}
+	multiGPU := hostPlatform{
+		gpuVendors: []platform.GPUVendor{platform.GPUVendorNVIDIA},
+		devices:    []gpuinfo.Device{{Index: 0, VRAM: 24 * gib}, {Index: 1, VRAM: 24 * gib}},
+	}
+
+	tests := []struct {
+		name     string
+		format   types.Format
+		size     uint64
+		mode     inference.BackendMode
+		host     hostPlatform
+		expected []string
+	}{
+		{
+			name:     "GGUF",
+			format:   types.FormatGGUF,
+			mode:     inference.BackendModeCompletion,
+			host:     linux,
+			expected: []string{"llama.cpp"},
+		},
+		{
+			name:     "model without format",
+			mode:     inference.BackendModeEmbedding,
+			host:     linux,
+			expected: []string{"llama.cpp"},
+		},
+		{
+			name:     "ONNX",
+			format:   types.FormatONNX,
+			mode:     inference.BackendModeEmbedding,
+			host:     linux,
+			expected: []string{"onnx"},
+		},
+		{
+			name:     "TensorRT engines",
+			format:   types.FormatTensorRT,
+			mode:     inference.BackendModeCompletion,
+			host:     multiGPU,
+			expected: []string{"tensorrt-llm"},
+		},
+		{
+			name:     "safetensors completion",
+			format:   types.FormatSafetensors,
+			mode:     inference.BackendModeCompletion,
+			host:     linux,
+			expected: []string{"vllm", "sglang"},
+		},
+		{
+			name:     "safetensors completion on Apple Silicon",
+			format:   types.FormatSafetensors,
+			mode:     inference.BackendModeCompletion,
+			host:     mac,
+			expected: []string{"mlx", "vllm", "sglang"},
+		},
+		{
+			name:     "safetensors embeddings",
+			format:   types.FormatSafetensors,
+			size:     gib,
+			mode:     inference.BackendModeEmbedding,
+			host:     multiGPU,
+			expected: []string{"tei", "vllm", "sglang"},
+		},
+		{
+			name:     "safetensors embeddings on Apple Silicon",
+			format:   types.FormatSafetensors,
+			mode:     inference.BackendModeEmbedding,
+			host:     mac,
+			expected: []string{"tei", "mlx", "vllm", "sglang"},
+		},
+		{
+			name:     "safetensors embeddings sharded across GPUs",
+			format:   types.FormatSafetensors,
+			size:     32 * gib,
+			mode:     inference.BackendModeEmbedding,
+			host:     multiGPU,
+			expected: []string{"vllm", "sglang", "tei"},
+		},
+		{
+			name:     "safetensors embeddings exceeding all GPUs",
+			format:   types.FormatSafetensors,
+			size:     64 * gib,
+			mode:     inference.BackendModeEmbedding,
+			host:     multiGPU,
+			expected: []string{"tei", "vllm", "sglang"},
+		},
+		{
+			name:     "safetensors reranking",
+			format:   types.FormatSafetensors,
+			mode:     inference.BackendModeReranking,
+			host:     mac,
+			expected: []string{"tei", "vllm"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := backendCandidates(tt.format, tt.size, tt.mode, tt.host)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+)
+
+// streamUsageTranslator reports the token usage of streamed completions and
+// chat completions, along with their timing, in the chunk that ends them.
+// Backends are always asked for the usage of streams, so that it's metered,
+// and it's removed from streams whose client didn't ask for it.
+type streamUsageTranslator struct {
+	// includeUsage indicates whether the client asked for the usage.
+	includeUsage bool
+	// start is when the request was received.
+	start time.Time
+	// firstToken is when the first token was streamed, once it is.
+	firstToken time.Time
+	// end is when the usage of the stream was streamed, once it is.
+	end time.Time
+	// completionTokens is the number of completion tokens of the stream, once
+	// its usage is streamed.
+	completionTokens float64
+	// now returns the current time.
+	now func() time.Time
+}
+
+// parseStreamOptions parses the stream options of a streamed completion or
+// chat completion request, and returns the body with the usage requested of
+// the backend, along with a translator of its response. Other requests are
+// returned as-is, without a translator. Bodies that aren't JSON objects are
+// left for the backend to reject.
+func parseStreamOptions(body []byte, start time.Time) ([]byte, *streamUsageTranslator, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return body, nil, nil
+	}
+	var stream bool
+	if raw, ok := request["stream"]; ok && !isNullField(raw) {
+		if err := json.Unmarshal(raw, &stream); err != nil {
+			return body, nil, nil
+		}
+	}
+	var options map[string]json.RawMessage
+	if raw := request["stream_options"]; !isNullField(raw) {
+		if !stream {
+			return nil, nil, errors.New("stream_options is only allowed when stream is true")
+		}
+		if err := json.Unmarshal(raw, &options); err != nil {
+			return nil, nil, errors.New("stream_options must be an object")
+		}
+	}
+	if !stream {
+		return body, nil, nil
+	}
+
+	translator := &streamUsageTranslator{start: start, now: time.Now}
+	if raw := options["include_usage"]; !isNullField(raw) {
+		if err := json.Unmarshal(raw, &translator.includeUsage); err != nil {
+			return nil, nil, errors.New("stream_options.include_usage must be a boolean")
+		}
+	}
+	if options == nil {
+		options = make(map[string]json.RawMessage)
+	}
+	options["include_usage"] = json.RawMessage("true")
+	var err error
+	if request["stream_options"], err = json.Marshal(options); err != nil {
+		return nil, nil, err
+	}
+	if body, err = json.Marshal(request); err != nil {
+		return nil, nil, err
+	}
+	return body, translator, nil
+}
+
+// translate records when the first token of a stream is streamed, and adds
+// the timing of the stream to the chunk with its usage, or removes the usage
+// if the client didn't ask for it, dropping chunks left without choices.
+// Anything else, such as the end of the stream, is returned as-is.
+func (t *streamUsageTranslator) translate(data []byte, chunk bool) []byte {
+	var response map[string]any
+	if !chunk || json.Unmarshal(data, &response) != nil {
+		return data
+	}
+	choices, _ := response["choices"].([]any)
+	if t.firstToken.IsZero() && hasOutput(choices) {
+		t.firstToken = t.now()
+	}
+	usage, ok := response["usage"].(map[string]any)
+	if !ok {
+		return data
+	}
+	t.end = t.now()
+	t.completionTokens, _ = usage["completion_tokens"].(float64)
+
+	if !t.includeUsage {
+		if len(choices) == 0 {
+			return nil
+		}
+		delete(response, "usage")
+	} else {
+		timings, _ := response["timings"].(map[string]any)
+		if timings == nil {
+			timings = make(map[string]any)
+		}
+		for key, value := range t.timings() {
+			timings[key] = value
+		}
+		response["timings"] = timings
+	}
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return data
+	}
+	return encoded
+}
+
+// timings returns the time to the first token of a stream, the time spent
+// generating the rest, and the number of completion tokens generated per
+// second, which is only known if tokens were generated over time.
+func (t *streamUsageTranslator) timings() map[string]any {
+	if t.firstToken.IsZero() {
+		return map[string]any{}
+	}
+	generation := t.end.Sub(t.firstToken)
+	timings := map[string]any{
+		"time_to_first_token_ms": milliseconds(t.firstToken.Sub(t.start)),
+		"generation_ms":          milliseconds(generation),
+	}
+	if t.completionTokens > 0 && generation > 0 {
+		timings["tokens_per_second"] = math.Round(t.completionTokens/generation.Seconds()*100) / 100
+	}
+	return timings
+}
+
+// latency returns the time to the first token of the stream, and the time per
+// output token after it if the stream reported that more than one was
+// generated, or false if no token was streamed.
+func (t *streamUsageTranslator) latency() (time.Duration, time.Duration, bool) {
+	if t.firstToken.IsZero() {
+		return 0, 0, false
+	}
+	var perToken time.Duration
+	if t.completionTokens > 1 && !t.end.IsZero() {
+		perToken = time.Duration(float64(t.end.Sub(t.firstToken)) / (t.completionTokens - 1))
+	}
+	return t.firstToken.Sub(t.start), perToken, true
+}
+
+// milliseconds returns a duration in milliseconds, to the microsecond.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// hasOutput returns true if a choice of a chunk streams output: text,
+// content, reasoning, or tool calls.
+func hasOutput(choices []any) bool {
+	for _, c := range choices {
+		choice, _ := c.(map[string]any)
+		if text, _ := choice["text"].(string); text != "" {
+			return true
+		}
+		delta, _ := choice["delta"].(map[string]any)
+		for _, field := range []string{"content", "reasoning_content"} {
+			if text, _ := delta[field].(string); text != "" {
+				return true
+			}
+		}
+		if toolCalls, _ := delta["tool_calls"].([]any); len(toolCalls) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStreamOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		translator   bool
+		includeUsage bool
+		err          bool
+	}{
+		{name: "not streamed", body: `{"model":"m"}`},
+		{name: "streamed", body: `{"model":"m","stream":true}`, translator: true},
+		{name: "include usage", body: `{"model":"m","stream":true,"stream_options":{"include_usage":true}}`, translator: true, includeUsage: true},
+		{name: "exclude usage", body: `{"model":"m","stream":true,"stream_options":{"include_usage":false}}`, translator: true},
+		{name: "options without stream", body: `{"model":"m","stream_options":{"include_usage":true}}`, err: true},
+		{name: "invalid options", body: `{"model":"m","stream":true,"stream_options":true}`, err: true},
+		{name: "invalid include usage", body: `{"model":"m","stream":true,"stream_options":{"include_usage":"yes"}}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, translator, err := parseStreamOptions([]byte(tt.body), time.Now())
+			if tt.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (translator != nil) != tt.translator {
+				t.Fatalf("Expected a translator: %v, got %v", tt.translator, translator)
+			}
+			if translator == nil {
+				if string(body) != tt.body {
+					t.Errorf("Expected the body as-is, got %s", body)
+				}
+				return
+			}
+			if translator.includeUsage != tt.includeUsage {
+				t.Errorf("Expected include_usage %v, got %v", tt.includeUsage, translator.includeUsage)
+			}
+			var request struct {
+				StreamOptions struct {
+					IncludeUsage bool `json:"include_usage"`
+				} `json:"stream_options"`
+			}
+			if err := json.Unmarshal(body, &request); err != nil || !request.StreamOptions.IncludeUsage {
+				t.Errorf("Expected the usage to be requested of the backend, got %s", body)
+			}
+		})
+	}
+}
+
+func TestStreamUsageTranslator(t *testing.T) {
+	stream := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"role":"assistant"}}]}`,
+		``,
+		`data: {"choices":[{"index":0,"delta":{"content":"Hi"}}]}`,
+		``,
+		`data: {"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		``,
+		`data: {"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":9,"total_tokens":12},"timings":{"prompt_ms":5}}`,
+		``,
+		`data: [DONE]`,
+		``,
+		``,
+	}, "\n")
+
+	tests := []struct {
+		name         string
+		includeUsage bool
+	}{
+		{name: "include usage", includeUsage: true},
+		{name: "exclude usage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+			now := start
+			translator := &streamUsageTranslator{includeUsage: tt.includeUsage, start: start, now: func() time.Time {
+				now = now.Add(500 * time.Millisecond)
+				return now
+			}}
+			recorder := httptest.NewRecorder()
+			w := &chatResponseWriter{ResponseWriter: recorder, translate: translator.translate}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			for _, line := range strings.SplitAfter(stream, "\n") {
+				w.Write([]byte(line))
+			}
+			w.finish()
+
+			var usage *struct {
+				Usage   map[string]float64 `json:"usage"`
+				Timings map[string]float64 `json:"timings"`
+			}
+			for _, line := range strings.Split(recorder.Body.String(), "\n") {
+				data, ok := strings.CutPrefix(line, "data: ")
+				if !ok || !strings.Contains(data, `"usage"`) {
+					continue
+				}
+				if err := json.Unmarshal([]byte(data), &usage); err != nil {
+					t.Fatalf("Failed to decode chunk: %v", err)
+				}
+			}
+			if !strings.Contains(recorder.Body.String(), "data: [DONE]") {
+				t.Errorf("Expected the end of the stream, got %s", recorder.Body)
+			}
+			if !tt.includeUsage {
+				if usage != nil {
+					t.Errorf("Expected the usage to be removed, got %s", recorder.Body)
+				}
+				return
+			}
+			if usage == nil {
+				t.Fatalf("Expected the usage, got %s", recorder.Body)
+			}
+			expected := map[string]float64{"prompt_ms": 5, "time_to_first_token_ms": 500, "generation_ms": 500, "tokens_per_second": 18}
+			for key, value := range expected {
+				if usage.Timings[key] != value {
+					t.Errorf("Expected timing %s of %v, got %v", key, value, usage.Timings)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamUsageLatency(t *testing.T) {
+	start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	now := start
+	translator := &streamUsageTranslator{start: start, now: func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}}
+	if _, _, ok := translator.latency(); ok {
+		t.Error("Expected no latency before the first token")
+	}
+	translator.translate([]byte(`{"choices":[{"index":0,"delta":{"content":"Hi"}}]}`), true)
+	translator.translate([]byte(`{"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":5,"total_tokens":8}}`), true)
+	firstToken, perToken, ok := translator.latency()
+	if !ok || firstToken != time.Second || perToken != 250*time.Millisecond {
+		t.Errorf("Expected a first token after 1s and 250ms per token, got %v, %v (%t)", firstToken, perToken, ok)
+	}
+}
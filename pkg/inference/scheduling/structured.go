@@ -0,0 +1,215 @@
+package scheduling
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/inference/structured"
+)
+
+// structuredOutputWriter is a response writer that validates the outputs of
+// completions with a strict JSON schema response format against the schema.
+// Responses that aren't streams are buffered until they're complete, and fail
+// with a bad gateway error if an output doesn't match. Streams are passed
+// through as they're written, with an error event before their end if an
+// output doesn't match.
+type structuredOutputWriter struct {
+	http.ResponseWriter
+	// schema is the compiled schema of the response format.
+	schema *structured.Schema
+	// status is the status of the response, once it's written.
+	status int
+	// stream indicates whether the response is a stream of server-sent
+	// events.
+	stream bool
+	// pending is the incomplete line of a stream written last, or the whole
+	// body of other responses.
+	pending []byte
+	// outputs are the outputs of the choices of a stream, by index.
+	outputs map[int]*strings.Builder
+	// finishReasons are the finish reasons of the choices of a stream, by
+	// index.
+	finishReasons map[int]string
+}
+
+// newStructuredOutputWriter creates a writer that validates outputs against a
+// schema.
+func newStructuredOutputWriter(w http.ResponseWriter, schema *structured.Schema) *structuredOutputWriter {
+	return &structuredOutputWriter{
+		ResponseWriter: w,
+		schema:         schema,
+		outputs:        make(map[int]*strings.Builder),
+		finishReasons:  make(map[int]string),
+	}
+}
+
+// structuredChoice is a choice of a completion, or of a chunk of a streamed
+// completion, whose output is validated.
+type structuredChoice struct {
+	Index   int     `json:"index"`
+	Text    *string `json:"text"`
+	Message *struct {
+		Content *string `json:"content"`
+	} `json:"message"`
+	Delta *struct {
+		Content *string `json:"content"`
+	} `json:"delta"`
+	FinishReason *string `json:"finish_reason"`
+}
+
+// output returns the output of a choice, or of a chunk of one.
+func (c structuredChoice) output() string {
+	switch {
+	case c.Message != nil && c.Message.Content != nil:
+		return *c.Message.Content
+	case c.Delta != nil && c.Delta.Content != nil:
+		return *c.Delta.Content
+	case c.Text != nil:
+		return *c.Text
+	default:
+		return ""
+	}
+}
+
+// WriteHeader implements http.ResponseWriter.WriteHeader. Successful
+// responses that aren't streams are written once they're validated.
+func (w *structuredOutputWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if status == http.StatusOK {
+		w.stream = strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream")
+		if !w.stream {
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.Write.
+func (w *structuredOutputWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.status != http.StatusOK {
+		return w.ResponseWriter.Write(b)
+	}
+	w.pending = append(w.pending, b...)
+	if !w.stream {
+		return len(b), nil
+	}
+	for {
+		line, rest, found := bytes.Cut(w.pending, []byte("\n"))
+		if !found {
+			break
+		}
+		w.pending = rest
+		if data, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+			if err := w.record(data); err != nil {
+				event, _ := json.Marshal(map[string]any{"error": map[string]any{"message": err.Error()}})
+				if _, err := fmt.Fprintf(w.ResponseWriter, "data: %s\n\n", event); err != nil {
+					return 0, err
+				}
+			}
+		}
+		if _, err := w.ResponseWriter.Write(append(line, '\n')); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// record records the outputs of a chunk of a stream. At the end of the
+// stream, it validates the outputs of the choices that finished, returning
+// the first mismatch.
+func (w *structuredOutputWriter) record(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("[DONE]")) {
+		for index, reason := range w.finishReasons {
+			if err := w.validate(reason, w.outputs[index].String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var chunk struct {
+		Choices []structuredChoice `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return nil
+	}
+	for _, choice := range chunk.Choices {
+		output, ok := w.outputs[choice.Index]
+		if !ok {
+			output = &strings.Builder{}
+			w.outputs[choice.Index] = output
+		}
+		output.WriteString(choice.output())
+		if choice.FinishReason != nil {
+			w.finishReasons[choice.Index] = *choice.FinishReason
+		}
+	}
+	return nil
+}
+
+// validate validates the output of a choice with a finish reason. Only the
+// outputs of choices that stopped are complete, so others aren't validated.
+func (w *structuredOutputWriter) validate(finishReason, output string) error {
+	if finishReason != "stop" {
+		return nil
+	}
+	if err := w.schema.Validate([]byte(output)); err != nil {
+		return fmt.Errorf("model output doesn't match the JSON schema of the response format: %w", err)
+	}
+	return nil
+}
+
+// Unwrap returns the underlying response writer, so that it can be flushed
+// via http.ResponseController.
+func (w *structuredOutputWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush implements http.Flusher.Flush. Responses that aren't streams are
+// only flushed once they're written.
+func (w *structuredOutputWriter) Flush() {
+	if w.status == http.StatusOK && !w.stream {
+		return
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// finish validates and writes responses that aren't streams, along with the
+// rest of streams. It must be called once the response is complete.
+func (w *structuredOutputWriter) finish() {
+	if w.status != http.StatusOK {
+		return
+	}
+	if w.stream {
+		if len(w.pending) > 0 {
+			_, _ = w.ResponseWriter.Write(w.pending)
+			w.pending = nil
+		}
+		return
+	}
+
+	var response struct {
+		Choices []structuredChoice `json:"choices"`
+	}
+	if err := json.Unmarshal(w.pending, &response); err == nil {
+		for _, choice := range response.Choices {
+			if choice.FinishReason == nil {
+				continue
+			}
+			if err := w.validate(*choice.FinishReason, choice.output()); err != nil {
+				http.Error(w.ResponseWriter, err.Error(), http.StatusBadGateway)
+				return
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+	_, _ = w.ResponseWriter.Write(w.pending)
+}
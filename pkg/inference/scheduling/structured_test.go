@@ -0,0 +1,73 @@
+package scheduling
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference/structured"
+)
+
+func TestStructuredOutputWriter(t *testing.T) {
+	schema, err := structured.Compile([]byte(`{"type":"object","properties":{"ok":{"type":"boolean"}},"required":["ok"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tt := range map[string]struct {
+		body   string
+		status int
+	}{
+		"valid":     {`{"choices":[{"index":0,"message":{"role":"assistant","content":"{\"ok\": true}"},"finish_reason":"stop"}]}`, http.StatusOK},
+		"mismatch":  {`{"choices":[{"index":0,"message":{"role":"assistant","content":"{\"ok\": 1}"},"finish_reason":"stop"}]}`, http.StatusBadGateway},
+		"truncated": {`{"choices":[{"index":0,"text":"{\"ok\"","finish_reason":"length"}]}`, http.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			w := newStructuredOutputWriter(recorder, schema)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, tt.body)
+			if recorder.Body.Len() != 0 {
+				t.Fatal("Expected the response to be buffered until it's complete")
+			}
+			w.finish()
+			if recorder.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, recorder.Code, recorder.Body)
+			}
+			if tt.status == http.StatusOK && recorder.Body.String() != tt.body {
+				t.Errorf("Expected the response to be passed through, got %s", recorder.Body)
+			}
+		})
+	}
+
+	// Streams are passed through, with an error before their end if an
+	// output doesn't match.
+	for name, tt := range map[string]struct {
+		content []string
+		failed  bool
+	}{
+		"valid":    {[]string{`{\"ok\"`, `: false}`}, false},
+		"mismatch": {[]string{`{\"ok\"`, `: \"no\"}`}, true},
+	} {
+		t.Run("stream "+name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			w := newStructuredOutputWriter(recorder, schema)
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, content := range tt.content {
+				fmt.Fprintf(w, `data: {"choices":[{"index":0,"delta":{"content":"%s"}}]}`+"\n\n", content)
+			}
+			fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`+"\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			w.finish()
+			body := recorder.Body.String()
+			if !strings.HasSuffix(body, "data: [DONE]\n\n") {
+				t.Errorf("Expected the stream to end, got %s", body)
+			}
+			if failed := strings.Contains(body, `data: {"error":`); failed != tt.failed {
+				t.Errorf("Expected an error event: %t, got %s", tt.failed, body)
+			}
+		})
+	}
+}
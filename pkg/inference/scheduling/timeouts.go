@@ -0,0 +1,129 @@
+package scheduling
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrFirstTokenTimeout indicates that a backend didn't start responding to a
+// request within the maximum time to first token. If returned in conjunction
+// with an HTTP request, it should be paired with a 504 response status.
+var ErrFirstTokenTimeout = errors.New("backend didn't respond within the time to first token limit")
+
+// ErrGenerationTimeout indicates that a backend didn't finish responding to a
+// request within the maximum generation time. If returned in conjunction with
+// an HTTP request, it should be paired with a 504 response status.
+var ErrGenerationTimeout = errors.New("backend didn't finish responding within the generation time limit")
+
+// GenerationTimeouts bounds the time that backends spend serving requests,
+// measured from when a request is forwarded to its runner. Zero values
+// disable the corresponding timeout.
+type GenerationTimeouts struct {
+	// FirstToken is the maximum time until the backend starts responding.
+	FirstToken time.Duration
+	// Total is the maximum time until the backend finishes responding.
+	Total time.Duration
+}
+
+// override returns the timeouts with the non-zero timeouts of o applied.
+func (t GenerationTimeouts) override(o GenerationTimeouts) GenerationTimeouts {
+	if o.FirstToken > 0 {
+		t.FirstToken = o.FirstToken
+	}
+	if o.Total > 0 {
+		t.Total = o.Total
+	}
+	return t
+}
+
+// timeoutSettings holds the default generation timeouts and those configured
+// for individual models.
+type timeoutSettings struct {
+	// lock guards all fields.
+	lock sync.Mutex
+	// defaults are the timeouts of models without their own.
+	defaults GenerationTimeouts
+	// models maps model IDs to their timeouts, which override defaults.
+	models map[string]GenerationTimeouts
+}
+
+// newTimeoutSettings creates new timeout settings without timeouts.
+func newTimeoutSettings() *timeoutSettings {
+	return &timeoutSettings{models: make(map[string]GenerationTimeouts)}
+}
+
+// setDefaults sets the default timeouts.
+func (s *timeoutSettings) setDefaults(timeouts GenerationTimeouts) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.defaults = timeouts
+}
+
+// setModel sets the timeouts of a model. Zero values revert to the defaults.
+func (s *timeoutSettings) setModel(modelID string, timeouts GenerationTimeouts) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if timeouts == (GenerationTimeouts{}) {
+		delete(s.models, modelID)
+	} else {
+		s.models[modelID] = timeouts
+	}
+}
+
+// forModel returns the timeouts that apply to a model.
+func (s *timeoutSettings) forModel(modelID string) GenerationTimeouts {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.defaults.override(s.models[modelID])
+}
+
+// withGenerationTimeouts returns a context that is cancelled once the total
+// generation time passes, and a response writer that cancels it if nothing is
+// written before the time to first token passes. The returned stop function
+// must be called once the response is complete.
+func withGenerationTimeouts(ctx context.Context, w http.ResponseWriter, timeouts GenerationTimeouts) (context.Context, http.ResponseWriter, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	stops := []func(){func() { cancel(nil) }}
+	if timeouts.Total > 0 {
+		timer := time.AfterFunc(timeouts.Total, func() { cancel(ErrGenerationTimeout) })
+		stops = append(stops, func() { timer.Stop() })
+	}
+	if timeouts.FirstToken > 0 {
+		fw := &firstTokenWriter{ResponseWriter: w}
+		fw.timer = time.AfterFunc(timeouts.FirstToken, func() { cancel(ErrFirstTokenTimeout) })
+		stops = append(stops, func() { fw.timer.Stop() })
+		w = fw
+	}
+	return ctx, w, func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+}
+
+// firstTokenWriter is a response writer that stops its timer once the first
+// part of the response body is written.
+type firstTokenWriter struct {
+	http.ResponseWriter
+	timer *time.Timer
+}
+
+// Write implements http.ResponseWriter.Write.
+func (w *firstTokenWriter) Write(b []byte) (int, error) {
+	w.timer.Stop()
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying response writer, so that it can be flushed
+// via http.ResponseController.
+func (w *firstTokenWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush implements http.Flusher.Flush.
+func (w *firstTokenWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
@@ -0,0 +1,60 @@
+package scheduling
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutSettings(t *testing.T) {
+	s := newTimeoutSettings()
+	s.setDefaults(GenerationTimeouts{FirstToken: time.Minute, Total: 10 * time.Minute})
+	s.setModel("model", GenerationTimeouts{Total: time.Hour})
+
+	if timeouts := s.forModel("model"); timeouts != (GenerationTimeouts{FirstToken: time.Minute, Total: time.Hour}) {
+		t.Errorf("unexpected model timeouts: %+v", timeouts)
+	}
+	if timeouts := s.forModel("other"); timeouts != (GenerationTimeouts{FirstToken: time.Minute, Total: 10 * time.Minute}) {
+		t.Errorf("unexpected default timeouts: %+v", timeouts)
+	}
+
+	s.setModel("model", GenerationTimeouts{})
+	if timeouts := s.forModel("model"); timeouts.Total != 10*time.Minute {
+		t.Errorf("expected model timeouts to revert to defaults, got %+v", timeouts)
+	}
+}
+
+func TestWithGenerationTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts GenerationTimeouts
+		write    bool
+		expected error
+	}{
+		{name: "first token exceeded", timeouts: GenerationTimeouts{FirstToken: 10 * time.Millisecond}, expected: ErrFirstTokenTimeout},
+		{name: "first token received", timeouts: GenerationTimeouts{FirstToken: 10 * time.Millisecond}, write: true},
+		{name: "total exceeded", timeouts: GenerationTimeouts{FirstToken: time.Minute, Total: 10 * time.Millisecond}, write: true, expected: ErrGenerationTimeout},
+		{name: "no timeouts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, w, stop := withGenerationTimeouts(context.Background(), httptest.NewRecorder(), tt.timeouts)
+			defer stop()
+			if tt.write {
+				if _, err := w.Write([]byte("data: {}\n\n")); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(50 * time.Millisecond):
+			}
+			if cause := context.Cause(ctx); !errors.Is(cause, tt.expected) {
+				t.Errorf("expected cause %v, got %v", tt.expected, cause)
+			}
+		})
+	}
+}
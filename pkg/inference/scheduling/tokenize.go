@@ -0,0 +1,298 @@
+package scheduling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// isTokenizerRequest returns true if a request path is that of a tokenize or
+// detokenize request.
+func isTokenizerRequest(path string) bool {
+	return strings.HasSuffix(path, "/v1/tokenize") || strings.HasSuffix(path, "/v1/detokenize")
+}
+
+// tokenizeRequest is a tokenize or detokenize request in the shape of vLLM's
+// API, which is translated into the format of the tokenizer endpoints of the
+// backend, and whose response is normalized.
+type tokenizeRequest struct {
+	// format is the format of the backend's tokenizer endpoints.
+	format inference.TokenizerFormat
+	// detokenize indicates whether tokens are detokenized rather than text
+	// tokenized.
+	detokenize bool
+	// model is the requested model.
+	model string
+	// prompt is the text to tokenize, if set.
+	prompt *string
+	// messages is the conversation to tokenize, with the model's chat
+	// template, if set.
+	messages json.RawMessage
+	// addSpecialTokens indicates whether special tokens, such as the
+	// beginning of sequence token, are added.
+	addSpecialTokens bool
+	// addGenerationPrompt indicates whether the prompt of the assistant's
+	// reply is added to conversations.
+	addGenerationPrompt bool
+	// returnTokenStrs indicates whether the text of the tokens is returned.
+	returnTokenStrs bool
+	// tokens are the tokens to detokenize.
+	tokens []int
+}
+
+// tokenizeResponse is the normalized response of a tokenize request.
+type tokenizeResponse struct {
+	Count       int      `json:"count"`
+	MaxModelLen int      `json:"max_model_len,omitempty"`
+	Tokens      []int    `json:"tokens"`
+	TokenStrs   []string `json:"token_strs,omitempty"`
+}
+
+// detokenizeResponse is the normalized response of a detokenize request.
+type detokenizeResponse struct {
+	Prompt string `json:"prompt"`
+}
+
+// parseTokenizeRequest parses a tokenize or detokenize request body for a
+// backend, which must implement inference.TokenizerBackend.
+func parseTokenizeRequest(path string, body []byte, backend inference.Backend) (*tokenizeRequest, error) {
+	tokenizer, ok := backend.(inference.TokenizerBackend)
+	if !ok {
+		return nil, fmt.Errorf("backend %s can't tokenize text", backend.Name())
+	}
+	var fields struct {
+		Model               string          `json:"model"`
+		Prompt              *string         `json:"prompt"`
+		Messages            json.RawMessage `json:"messages"`
+		AddSpecialTokens    *bool           `json:"add_special_tokens"`
+		AddGenerationPrompt *bool           `json:"add_generation_prompt"`
+		ReturnTokenStrs     bool            `json:"return_token_strs"`
+		Tokens              []int           `json:"tokens"`
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, errors.New("invalid request")
+	}
+	request := &tokenizeRequest{
+		format:          tokenizer.TokenizerFormat(),
+		detokenize:      strings.HasSuffix(path, "/v1/detokenize"),
+		model:           fields.Model,
+		prompt:          fields.Prompt,
+		returnTokenStrs: fields.ReturnTokenStrs,
+		tokens:          fields.Tokens,
+	}
+	if !isNullField(fields.Messages) {
+		request.messages = fields.Messages
+	}
+
+	if request.detokenize {
+		if request.tokens == nil {
+			return nil, errors.New("tokens are required")
+		}
+		for _, token := range request.tokens {
+			if token < 0 {
+				return nil, errors.New("tokens must be non-negative integers")
+			}
+		}
+		return request, nil
+	}
+	if (request.prompt == nil) == (request.messages == nil) {
+		return nil, errors.New("either prompt or messages is required")
+	}
+	if request.messages != nil && request.format == inference.TokenizerFormatSGLang {
+		return nil, fmt.Errorf("backend %s can't tokenize messages", backend.Name())
+	}
+	// As with vLLM, special tokens are added to prompts but not to
+	// conversations, whose chat template adds them, and the prompt of the
+	// assistant's reply is added to conversations.
+	request.addSpecialTokens = request.messages == nil
+	if fields.AddSpecialTokens != nil {
+		request.addSpecialTokens = *fields.AddSpecialTokens
+	}
+	request.addGenerationPrompt = true
+	if fields.AddGenerationPrompt != nil {
+		request.addGenerationPrompt = *fields.AddGenerationPrompt
+	}
+	return request, nil
+}
+
+// serve serves the request with a runner, on behalf of the original request,
+// and returns its normalized response.
+func (t *tokenizeRequest) serve(ctx context.Context, runner http.Handler, original *http.Request) bufferedResponse {
+	var response any
+	var err error
+	switch {
+	case t.detokenize:
+		response, err = t.serveDetokenize(ctx, runner, original)
+	case t.format == inference.TokenizerFormatLlamaCpp:
+		response, err = t.serveLlamaCppTokenize(ctx, runner, original)
+	default:
+		response, err = t.serveTokenize(ctx, runner, original)
+	}
+	var failed *backendError
+	if errors.As(err, &failed) {
+		return failed.response
+	} else if err != nil {
+		return errorResponse(http.StatusBadGateway, err.Error())
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		return errorResponse(http.StatusInternalServerError, "failed to encode response")
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	return bufferedResponse{status: http.StatusOK, header: header, body: body}
+}
+
+// backendError is an error response of a backend, which is passed through.
+type backendError struct {
+	response bufferedResponse
+}
+
+// Error implements error.Error.
+func (e *backendError) Error() string {
+	return string(e.response.body)
+}
+
+// callTokenizer sends a request to an endpoint of a runner, on behalf of the
+// original request, and decodes its response into result. Error responses of
+// the backend are returned as a backendError.
+func callTokenizer(ctx context.Context, runner http.Handler, original *http.Request, method, path string, request, result any) error {
+	var body []byte
+	if request != nil {
+		var err error
+		if body, err = json.Marshal(request); err != nil {
+			return err
+		}
+	}
+	endpoint := original.Clone(ctx)
+	endpoint.Method = method
+	endpoint.URL.Path = path
+	endpoint.URL.RawPath = ""
+	response := serveBuffered(ctx, runner, endpoint, body)
+	if response.status != http.StatusOK {
+		return &backendError{response: response}
+	}
+	if err := json.Unmarshal(response.body, result); err != nil {
+		return fmt.Errorf("invalid %s response from backend", path)
+	}
+	return nil
+}
+
+// serveTokenize tokenizes text with the tokenize endpoint of vLLM or SGLang,
+// whose requests and responses share the normalized format.
+func (t *tokenizeRequest) serveTokenize(ctx context.Context, runner http.Handler, original *http.Request) (*tokenizeResponse, error) {
+	request := map[string]any{
+		"model":              t.model,
+		"add_special_tokens": t.addSpecialTokens,
+	}
+	path := "/v1/tokenize"
+	if t.format == inference.TokenizerFormatVLLM {
+		path = "/tokenize"
+		request["return_token_strs"] = t.returnTokenStrs
+	}
+	if t.prompt != nil {
+		request["prompt"] = *t.prompt
+	} else {
+		request["messages"] = t.messages
+		request["add_generation_prompt"] = t.addGenerationPrompt
+	}
+	var response tokenizeResponse
+	if err := callTokenizer(ctx, runner, original, http.MethodPost, path, request, &response); err != nil {
+		return nil, err
+	}
+	response.Count = len(response.Tokens)
+	if !t.returnTokenStrs {
+		response.TokenStrs = nil
+	}
+	return &response, nil
+}
+
+// serveLlamaCppTokenize tokenizes text with llama-server's tokenize endpoint,
+// rendering conversations with its chat template endpoint first. The context
+// size of the model is read from the server's properties.
+func (t *tokenizeRequest) serveLlamaCppTokenize(ctx context.Context, runner http.Handler, original *http.Request) (*tokenizeResponse, error) {
+	prompt := t.prompt
+	if prompt == nil {
+		var rendered struct {
+			Prompt string `json:"prompt"`
+		}
+		request := map[string]any{"messages": t.messages, "add_generation_prompt": t.addGenerationPrompt}
+		if err := callTokenizer(ctx, runner, original, http.MethodPost, "/apply-template", request, &rendered); err != nil {
+			return nil, err
+		}
+		prompt = &rendered.Prompt
+	}
+
+	var tokenized struct {
+		Tokens []json.RawMessage `json:"tokens"`
+	}
+	request := map[string]any{"content": *prompt, "add_special": t.addSpecialTokens, "with_pieces": t.returnTokenStrs}
+	if err := callTokenizer(ctx, runner, original, http.MethodPost, "/tokenize", request, &tokenized); err != nil {
+		return nil, err
+	}
+	response := &tokenizeResponse{Count: len(tokenized.Tokens), Tokens: make([]int, len(tokenized.Tokens))}
+	if t.returnTokenStrs {
+		response.TokenStrs = make([]string, len(tokenized.Tokens))
+	}
+	for i, raw := range tokenized.Tokens {
+		// Tokens are returned with their pieces, which are strings, or
+		// arrays of bytes if they aren't valid UTF-8, when requested.
+		var token struct {
+			ID    int             `json:"id"`
+			Piece json.RawMessage `json:"piece"`
+		}
+		if err := json.Unmarshal(raw, &response.Tokens[i]); err == nil {
+			continue
+		} else if err := json.Unmarshal(raw, &token); err != nil {
+			return nil, errors.New("invalid /tokenize response from backend")
+		}
+		response.Tokens[i] = token.ID
+		if t.returnTokenStrs {
+			var piece string
+			if err := json.Unmarshal(token.Piece, &piece); err != nil {
+				var pieceBytes []byte
+				var values []int
+				json.Unmarshal(token.Piece, &values)
+				for _, value := range values {
+					pieceBytes = append(pieceBytes, byte(value))
+				}
+				piece = string(pieceBytes)
+			}
+			response.TokenStrs[i] = piece
+		}
+	}
+
+	var props struct {
+		DefaultGenerationSettings struct {
+			NCtx int `json:"n_ctx"`
+		} `json:"default_generation_settings"`
+	}
+	if err := callTokenizer(ctx, runner, original, http.MethodGet, "/props", nil, &props); err == nil {
+		response.MaxModelLen = props.DefaultGenerationSettings.NCtx
+	}
+	return response, nil
+}
+
+// serveDetokenize detokenizes tokens with the detokenize endpoint of the
+// backend.
+func (t *tokenizeRequest) serveDetokenize(ctx context.Context, runner http.Handler, original *http.Request) (*detokenizeResponse, error) {
+	var detokenized struct {
+		Content string `json:"content"` // llama.cpp
+		Prompt  string `json:"prompt"`  // vLLM
+		Text    string `json:"text"`    // SGLang
+	}
+	request := map[string]any{"model": t.model, "tokens": t.tokens}
+	path := "/detokenize"
+	if t.format == inference.TokenizerFormatSGLang {
+		path = "/v1/detokenize"
+	}
+	if err := callTokenizer(ctx, runner, original, http.MethodPost, path, request, &detokenized); err != nil {
+		return nil, err
+	}
+	return &detokenizeResponse{Prompt: detokenized.Content + detokenized.Prompt + detokenized.Text}, nil
+}
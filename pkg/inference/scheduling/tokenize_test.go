@@ -0,0 +1,206 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// tokenizerBackend is a mock backend with tokenizer endpoints in a format.
+type tokenizerBackend struct {
+	mockBackend
+	format inference.TokenizerFormat
+}
+
+func (b *tokenizerBackend) TokenizerFormat() inference.TokenizerFormat {
+	return b.format
+}
+
+func TestParseTokenizeRequest(t *testing.T) {
+	llamaCpp := &tokenizerBackend{mockBackend: mockBackend{name: "llama.cpp"}, format: inference.TokenizerFormatLlamaCpp}
+	sglang := &tokenizerBackend{mockBackend: mockBackend{name: "sglang"}, format: inference.TokenizerFormatSGLang}
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		backend inference.Backend
+		special bool
+		err     bool
+	}{
+		{name: "prompt", path: "/v1/tokenize", body: `{"model":"m","prompt":"hi"}`, backend: llamaCpp, special: true},
+		{name: "messages", path: "/v1/tokenize", body: `{"model":"m","messages":[{"role":"user","content":"hi"}]}`, backend: llamaCpp},
+		{name: "special tokens", path: "/v1/tokenize", body: `{"model":"m","prompt":"hi","add_special_tokens":false}`, backend: llamaCpp},
+		{name: "missing prompt", path: "/v1/tokenize", body: `{"model":"m"}`, backend: llamaCpp, err: true},
+		{name: "prompt and messages", path: "/v1/tokenize", body: `{"model":"m","prompt":"hi","messages":[]}`, backend: llamaCpp, err: true},
+		{name: "unsupported messages", path: "/v1/tokenize", body: `{"model":"m","messages":[]}`, backend: sglang, err: true},
+		{name: "unsupported backend", path: "/v1/tokenize", body: `{"model":"m","prompt":"hi"}`, backend: &mockBackend{name: "mock"}, err: true},
+		{name: "invalid", path: "/v1/tokenize", body: `{"model":"m","prompt":1}`, backend: llamaCpp, err: true},
+		{name: "detokenize", path: "/v1/detokenize", body: `{"model":"m","tokens":[1,2]}`, backend: sglang},
+		{name: "missing tokens", path: "/v1/detokenize", body: `{"model":"m"}`, backend: sglang, err: true},
+		{name: "negative tokens", path: "/v1/detokenize", body: `{"model":"m","tokens":[-1]}`, backend: sglang, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := parseTokenizeRequest(tt.path, []byte(tt.body), tt.backend)
+			if tt.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if request.addSpecialTokens != tt.special {
+				t.Errorf("Expected add_special_tokens %v, got %v", tt.special, request.addSpecialTokens)
+			}
+		})
+	}
+}
+
+// tokenizerRunner is a fake runner that serves the tokenizer endpoints of a
+// backend, recording the requests it's sent by path.
+type tokenizerRunner struct {
+	responses map[string]string
+	requests  map[string]map[string]any
+}
+
+func (r *tokenizerRunner) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	response, ok := r.responses[req.Method+" "+req.URL.Path]
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	var request map[string]any
+	json.Unmarshal(body, &request)
+	r.requests[req.URL.Path] = request
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, response)
+}
+
+func TestTokenizeServe(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    inference.TokenizerFormat
+		path      string
+		body      string
+		responses map[string]string
+		expected  string
+		sent      map[string]map[string]any
+	}{
+		{
+			name:   "llama.cpp prompt",
+			format: inference.TokenizerFormatLlamaCpp,
+			path:   "/v1/tokenize",
+			body:   `{"model":"m","prompt":"héllo","return_token_strs":true}`,
+			responses: map[string]string{
+				"POST /tokenize": `{"tokens":[{"id":1,"piece":"h"},{"id":2,"piece":[195,169]},{"id":3,"piece":"llo"}]}`,
+				"GET /props":     `{"default_generation_settings":{"n_ctx":4096}}`,
+			},
+			expected: `{"count":3,"max_model_len":4096,"tokens":[1,2,3],"token_strs":["h","é","llo"]}`,
+			sent: map[string]map[string]any{
+				"/tokenize": {"content": "héllo", "add_special": true, "with_pieces": true},
+				"/props":    nil,
+			},
+		},
+		{
+			name:   "llama.cpp messages",
+			format: inference.TokenizerFormatLlamaCpp,
+			path:   "/v1/tokenize",
+			body:   `{"model":"m","messages":[{"role":"user","content":"hi"}]}`,
+			responses: map[string]string{
+				"POST /apply-template": `{"prompt":"<user>hi<assistant>"}`,
+				"POST /tokenize":       `{"tokens":[1,2,3]}`,
+			},
+			expected: `{"count":3,"tokens":[1,2,3]}`,
+			sent: map[string]map[string]any{
+				"/apply-template": {"messages": []any{map[string]any{"role": "user", "content": "hi"}}, "add_generation_prompt": true},
+				"/tokenize":       {"content": "<user>hi<assistant>", "add_special": false, "with_pieces": false},
+			},
+		},
+		{
+			name:   "vLLM",
+			format: inference.TokenizerFormatVLLM,
+			path:   "/v1/tokenize",
+			body:   `{"model":"m","prompt":"hi"}`,
+			responses: map[string]string{
+				"POST /tokenize": `{"count":2,"max_model_len":8192,"tokens":[1,2],"token_strs":null}`,
+			},
+			expected: `{"count":2,"max_model_len":8192,"tokens":[1,2]}`,
+			sent: map[string]map[string]any{
+				"/tokenize": {"model": "m", "prompt": "hi", "add_special_tokens": true, "return_token_strs": false},
+			},
+		},
+		{
+			name:   "SGLang",
+			format: inference.TokenizerFormatSGLang,
+			path:   "/v1/tokenize",
+			body:   `{"model":"m","prompt":"hi"}`,
+			responses: map[string]string{
+				"POST /v1/tokenize": `{"tokens":[1,2],"count":2,"max_model_len":8192}`,
+			},
+			expected: `{"count":2,"max_model_len":8192,"tokens":[1,2]}`,
+			sent: map[string]map[string]any{
+				"/v1/tokenize": {"model": "m", "prompt": "hi", "add_special_tokens": true},
+			},
+		},
+		{
+			name:      "llama.cpp detokenize",
+			format:    inference.TokenizerFormatLlamaCpp,
+			path:      "/v1/detokenize",
+			body:      `{"model":"m","tokens":[1,2]}`,
+			responses: map[string]string{"POST /detokenize": `{"content":"hi"}`},
+			expected:  `{"prompt":"hi"}`,
+			sent:      map[string]map[string]any{"/detokenize": {"model": "m", "tokens": []any{1.0, 2.0}}},
+		},
+		{
+			name:      "SGLang detokenize",
+			format:    inference.TokenizerFormatSGLang,
+			path:      "/v1/detokenize",
+			body:      `{"model":"m","tokens":[1,2]}`,
+			responses: map[string]string{"POST /v1/detokenize": `{"text":"hi"}`},
+			expected:  `{"prompt":"hi"}`,
+			sent:      map[string]map[string]any{"/v1/detokenize": {"model": "m", "tokens": []any{1.0, 2.0}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &tokenizerBackend{mockBackend: mockBackend{name: "mock"}, format: tt.format}
+			request, err := parseTokenizeRequest(tt.path, []byte(tt.body), backend)
+			if err != nil {
+				t.Fatalf("Failed to parse request: %v", err)
+			}
+			runner := &tokenizerRunner{responses: tt.responses, requests: make(map[string]map[string]any)}
+			original := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			response := request.serve(t.Context(), runner, original)
+			if response.status != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, response.status, response.body)
+			}
+			if string(response.body) != tt.expected {
+				t.Errorf("Expected response %s, got %s", tt.expected, response.body)
+			}
+			if !reflect.DeepEqual(runner.requests, tt.sent) {
+				t.Errorf("Expected requests %v, got %v", tt.sent, runner.requests)
+			}
+		})
+	}
+}
+
+func TestTokenizeServeBackendError(t *testing.T) {
+	backend := &tokenizerBackend{mockBackend: mockBackend{name: "mock"}, format: inference.TokenizerFormatVLLM}
+	request, err := parseTokenizeRequest("/v1/tokenize", []byte(`{"model":"m","prompt":"hi"}`), backend)
+	if err != nil {
+		t.Fatalf("Failed to parse request: %v", err)
+	}
+	runner := &tokenizerRunner{requests: make(map[string]map[string]any)}
+	response := request.serve(t.Context(), runner, httptest.NewRequest(http.MethodPost, "/v1/tokenize", nil))
+	if response.status != http.StatusNotFound {
+		t.Errorf("Expected the backend's status %d, got %d", http.StatusNotFound, response.status)
+	}
+}
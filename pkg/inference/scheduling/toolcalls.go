@@ -0,0 +1,238 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/inference/toolcalls"
+)
+
+// parsesToolCalls returns true if the backend's server parses the tool calls
+// of the model when run with config.
+func parsesToolCalls(backend inference.Backend, model string, config *inference.BackendConfiguration) bool {
+	tools, ok := backend.(inference.ToolCallBackend)
+	return !ok || tools.ParsesToolCalls(model, config)
+}
+
+// toolCallTranslator normalizes the tool calls of chat completion responses,
+// so that clients receive the same calls whichever backend serves them. Calls
+// parsed by servers are given IDs, the function type, and encoded arguments,
+// and the deltas of streamed calls are indexed, with their ID, type, and name
+// only in their first delta. The calls of models prompted with the tools are
+// parsed from their output instead. The finish reason of choices with calls is
+// tool_calls.
+type toolCallTranslator struct {
+	// request describes the tools of the request.
+	request *toolcalls.Request
+	// prompted indicates whether the model was prompted with the tools.
+	prompted bool
+	// choices are the states of the choices of streamed responses, by index.
+	choices map[int]*toolCallChoice
+}
+
+// toolCallChoice is the state of a choice of a streamed response.
+type toolCallChoice struct {
+	// parser parses the calls of prompted models.
+	parser *toolcalls.Parser
+	// started are the indices of the calls started so far.
+	started map[int]bool
+	// last is the index of the call of the last delta.
+	last int
+}
+
+// newToolCallTranslator creates a translator for the responses to a request
+// with tools.
+func newToolCallTranslator(request *toolcalls.Request, prompted bool) *toolCallTranslator {
+	return &toolCallTranslator{request: request, prompted: prompted, choices: make(map[int]*toolCallChoice)}
+}
+
+// translate normalizes the tool calls of a chat completion response, or of a
+// chunk of a streamed response. Anything else, such as the end of a stream, is
+// returned as-is.
+func (t *toolCallTranslator) translate(data []byte, chunk bool) []byte {
+	var response map[string]any
+	if err := json.Unmarshal(data, &response); err != nil {
+		return data
+	}
+	choices, ok := response["choices"].([]any)
+	if !ok {
+		return data
+	}
+	for i, c := range choices {
+		choice, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		var hasCalls bool
+		if chunk {
+			delta, _ := choice["delta"].(map[string]any)
+			if delta == nil {
+				delta = make(map[string]any)
+				choice["delta"] = delta
+			}
+			index := i
+			if n, ok := choice["index"].(float64); ok {
+				index = int(n)
+			}
+			hasCalls = t.translateDelta(t.choice(index), delta, choice["finish_reason"] != nil)
+		} else if message, ok := choice["message"].(map[string]any); ok {
+			hasCalls = t.translateMessage(message)
+		}
+		if hasCalls && choice["finish_reason"] == "stop" {
+			choice["finish_reason"] = "tool_calls"
+		}
+	}
+	translated, err := json.Marshal(response)
+	if err != nil {
+		return data
+	}
+	return translated
+}
+
+// choice returns the state of a choice of a streamed response.
+func (t *toolCallTranslator) choice(index int) *toolCallChoice {
+	state, ok := t.choices[index]
+	if !ok {
+		state = &toolCallChoice{started: make(map[int]bool)}
+		if t.prompted {
+			state.parser = t.request.NewParser()
+		}
+		t.choices[index] = state
+	}
+	return state
+}
+
+// translateDelta normalizes the tool calls of the delta of a choice, which
+// finishes the choice if finished is true. It returns true if the choice has
+// calls.
+func (t *toolCallTranslator) translateDelta(state *toolCallChoice, delta map[string]any, finished bool) bool {
+	if t.prompted {
+		content, hasContent := delta["content"].(string)
+		text, calls := state.parser.Write(content)
+		if finished {
+			rest, more := state.parser.Close()
+			text += rest
+			calls = append(calls, more...)
+		}
+		if hasContent || text != "" {
+			delta["content"] = text
+		}
+		if len(calls) > 0 {
+			deltas := make([]any, 0, len(calls))
+			for _, call := range calls {
+				index := len(state.started)
+				state.started[index] = true
+				deltas = append(deltas, map[string]any{
+					"index":    index,
+					"id":       toolcalls.NewCallID(),
+					"type":     "function",
+					"function": map[string]any{"name": call.Name, "arguments": call.Arguments},
+				})
+			}
+			delta["tool_calls"] = deltas
+		}
+		return len(state.started) > 0
+	}
+
+	calls, _ := delta["tool_calls"].([]any)
+	for _, c := range calls {
+		call, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		function, _ := call["function"].(map[string]any)
+		if function == nil {
+			function = make(map[string]any)
+			call["function"] = function
+		}
+		// Deltas without an index continue the last call, unless they start
+		// another call with an ID or name.
+		index := state.last
+		if n, ok := call["index"].(float64); ok {
+			index = int(n)
+		} else if id, _ := call["id"].(string); len(state.started) > 0 && (id != "" || function["name"] != nil) {
+			index = len(state.started)
+		}
+		state.last = index
+		call["index"] = index
+
+		if state.started[index] {
+			delete(call, "id")
+			delete(call, "type")
+			delete(function, "name")
+			function["arguments"] = encodeArguments(function["arguments"], "")
+			continue
+		}
+		state.started[index] = true
+		if id, _ := call["id"].(string); id == "" {
+			call["id"] = toolcalls.NewCallID()
+		}
+		call["type"] = "function"
+		function["arguments"] = encodeArguments(function["arguments"], "")
+	}
+	return len(state.started) > 0
+}
+
+// translateMessage normalizes the tool calls of the message of a choice. It
+// returns true if the message has calls.
+func (t *toolCallTranslator) translateMessage(message map[string]any) bool {
+	if t.prompted {
+		content, _ := message["content"].(string)
+		parser := t.request.NewParser()
+		text, calls := parser.Write(content)
+		rest, more := parser.Close()
+		text += rest
+		calls = append(calls, more...)
+		if len(calls) == 0 {
+			return false
+		}
+		toolCalls := make([]any, 0, len(calls))
+		for _, call := range calls {
+			toolCalls = append(toolCalls, map[string]any{
+				"id":       toolcalls.NewCallID(),
+				"type":     "function",
+				"function": map[string]any{"name": call.Name, "arguments": call.Arguments},
+			})
+		}
+		message["tool_calls"] = toolCalls
+		message["content"] = nil
+		if text = strings.TrimSpace(text); text != "" {
+			message["content"] = text
+		}
+		return true
+	}
+
+	calls, _ := message["tool_calls"].([]any)
+	for _, c := range calls {
+		call, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		if id, _ := call["id"].(string); id == "" {
+			call["id"] = toolcalls.NewCallID()
+		}
+		call["type"] = "function"
+		if function, ok := call["function"].(map[string]any); ok {
+			function["arguments"] = encodeArguments(function["arguments"], "{}")
+		}
+	}
+	return len(calls) > 0
+}
+
+// encodeArguments returns the arguments of a call as a JSON-encoded string,
+// since some servers return them as objects, or empty if they're missing.
+func encodeArguments(arguments any, empty string) string {
+	switch arguments := arguments.(type) {
+	case nil:
+		return empty
+	case string:
+		return arguments
+	default:
+		encoded, err := json.Marshal(arguments)
+		if err != nil {
+			return empty
+		}
+		return string(encoded)
+	}
+}
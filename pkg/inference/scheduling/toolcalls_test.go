@@ -0,0 +1,140 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference/toolcalls"
+)
+
+// chunkToolCalls decodes the tool calls of the data of a streamed response.
+func chunkToolCalls(t *testing.T, data string) ([]map[string]any, any) {
+	t.Helper()
+	var chunk struct {
+		Choices []struct {
+			Delta struct {
+				Content   any              `json:"content"`
+				ToolCalls []map[string]any `json:"tool_calls"`
+			} `json:"delta"`
+			FinishReason any `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		t.Fatalf("Failed to decode chunk %q: %v", data, err)
+	}
+	return chunk.Choices[0].Delta.ToolCalls, chunk.Choices[0].FinishReason
+}
+
+func TestToolCallTranslatorStream(t *testing.T) {
+	translator := newToolCallTranslator(&toolcalls.Request{Choice: toolcalls.ChoiceAuto, Parallel: true}, false)
+	chunks := []string{
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"function":{"name":"weather","arguments":{"city":"Paris"}}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"function":{"arguments":""}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"time","arguments":"{"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"time","arguments":"}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	}
+	var translated [][]map[string]any
+	var finishReason any
+	for _, chunk := range chunks {
+		calls, reason := chunkToolCalls(t, string(translator.translate([]byte(chunk), true)))
+		translated = append(translated, calls)
+		finishReason = reason
+	}
+
+	first := translated[0][0]
+	if first["index"] != float64(0) || first["type"] != "function" || !strings.HasPrefix(first["id"].(string), "call_") {
+		t.Errorf("Expected the first delta to have an index, ID, and type, got %v", first)
+	}
+	if arguments := first["function"].(map[string]any)["arguments"]; arguments != `{"city":"Paris"}` {
+		t.Errorf("Expected the arguments to be encoded, got %v", arguments)
+	}
+	if continued := translated[1][0]; continued["index"] != float64(0) || continued["id"] != nil {
+		t.Errorf("Expected a delta without an index to continue the call, got %v", continued)
+	}
+	if second := translated[2][0]; second["index"] != float64(1) || second["id"] != "call_2" {
+		t.Errorf("Expected the second call to keep its ID, got %v", second)
+	}
+	if continued := translated[3][0]; continued["id"] != nil || continued["type"] != nil || continued["function"].(map[string]any)["name"] != nil {
+		t.Errorf("Expected continued deltas to only have arguments, got %v", continued)
+	}
+	if finishReason != "tool_calls" {
+		t.Errorf("Expected the finish reason to be tool_calls, got %v", finishReason)
+	}
+}
+
+func TestToolCallTranslatorPrompted(t *testing.T) {
+	request := &toolcalls.Request{Choice: toolcalls.ChoiceAuto, Parallel: true}
+	recorder := httptest.NewRecorder()
+	w := &chatResponseWriter{ResponseWriter: recorder, translate: newToolCallTranslator(request, true).translate}
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, content := range []string{"Checking.", "<tool_", "call>{\"name\": \"weather\",", " \"arguments\": {}}</tool_call>"} {
+		data, _ := json.Marshal(content)
+		fmt.Fprintf(w, `data: {"choices":[{"index":0,"delta":{"content":%s}}]}`+"\n\n", data)
+	}
+	fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+	w.finish()
+
+	var content string
+	var calls []map[string]any
+	var finishReason any
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		json.Unmarshal([]byte(data), &chunk)
+		content += chunk.Choices[0].Delta.Content
+		chunkCalls, reason := chunkToolCalls(t, data)
+		calls = append(calls, chunkCalls...)
+		finishReason = reason
+	}
+	if content != "Checking." {
+		t.Errorf("Expected the call to be removed from the content, got %q", content)
+	}
+	if len(calls) != 1 || calls[0]["function"].(map[string]any)["name"] != "weather" || calls[0]["function"].(map[string]any)["arguments"] != "{}" {
+		t.Errorf("Expected the call to be parsed, got %v", calls)
+	}
+	if finishReason != "tool_calls" {
+		t.Errorf("Expected the finish reason to be tool_calls, got %v", finishReason)
+	}
+
+	// The calls of responses that aren't streamed are parsed once complete.
+	recorder = httptest.NewRecorder()
+	w = &chatResponseWriter{ResponseWriter: recorder, translate: newToolCallTranslator(request, true).translate}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"<tool_call>{\"name\": \"time\", \"arguments\": {}}</tool_call>"},"finish_reason":"stop"}]}`)
+	w.finish()
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content   *string `json:"content"`
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Function struct {
+						Name string `json:"name"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	choice := response.Choices[0]
+	if choice.Message.Content != nil || len(choice.Message.ToolCalls) != 1 || choice.Message.ToolCalls[0].Function.Name != "time" || choice.FinishReason != "tool_calls" {
+		t.Errorf("Unexpected response %s", recorder.Body)
+	}
+}
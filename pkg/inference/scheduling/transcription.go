@@ -0,0 +1,55 @@
+package scheduling
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+)
+
+// decodeTranscriptionRequest extracts the model specification from an OpenAI
+// API audio transcription request, which is uploaded as a multipart form. It
+// also returns the form's fields other than the audio file as JSON, which is
+// used in place of the body when recording the request.
+func decodeTranscriptionRequest(contentType string, body []byte) (OpenAIInferenceRequest, []byte, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return OpenAIInferenceRequest{}, nil, errors.New("transcription requests must be multipart/form-data")
+	}
+
+	fields := make(map[string]string)
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return OpenAIInferenceRequest{}, nil, fmt.Errorf("invalid multipart form: %w", err)
+		}
+		if part.FileName() == "" {
+			value, err := io.ReadAll(part)
+			if err != nil {
+				return OpenAIInferenceRequest{}, nil, fmt.Errorf("invalid multipart form: %w", err)
+			}
+			fields[part.FormName()] = string(value)
+		}
+		part.Close()
+	}
+
+	record, err := json.Marshal(fields)
+	if err != nil {
+		return OpenAIInferenceRequest{}, nil, err
+	}
+	request := OpenAIInferenceRequest{Model: fields["model"]}
+	if value, ok := fields["keep_alive"]; ok {
+		keepAlive, err := ParseKeepAlive(value)
+		if err != nil {
+			return OpenAIInferenceRequest{}, nil, err
+		}
+		request.KeepAlive = &keepAlive
+	}
+	return request, record, nil
+}
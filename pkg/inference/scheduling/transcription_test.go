@@ -0,0 +1,43 @@
+package scheduling
+
+import (
+	"bytes"
+	"mime/multipart"
+	"testing"
+)
+
+func TestDecodeTranscriptionRequest(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.WriteField("model", "ai/whisper"); err != nil {
+		t.Fatal(err)
+	}
+	file, err := writer.CreateFormFile("file", "speech.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte("RIFF")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteField("language", "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	request, record, err := decodeTranscriptionRequest(writer.FormDataContentType(), body.Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.Model != "ai/whisper" {
+		t.Errorf("expected model ai/whisper, got %q", request.Model)
+	}
+	if expected := `{"language":"en","model":"ai/whisper"}`; string(record) != expected {
+		t.Errorf("expected record %s, got %s", expected, record)
+	}
+
+	if _, _, err := decodeTranscriptionRequest("application/json", []byte(`{"model":"ai/whisper"}`)); err == nil {
+		t.Error("expected error for non-multipart request")
+	}
+}
@@ -0,0 +1,135 @@
+package scheduling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// watchdogInterval is the interval at which the watchdog probes runners.
+	watchdogInterval = 30 * time.Second
+	// watchdogProbeTimeout bounds the time that a runner has to respond to a
+	// health probe.
+	watchdogProbeTimeout = 10 * time.Second
+	// watchdogFailureThreshold is the number of consecutive failed probes
+	// after which a runner is considered hung.
+	watchdogFailureThreshold = 3
+)
+
+// errBackendHung indicates that an inference backend stopped responding and
+// was restarted by the watchdog.
+var errBackendHung = errors.New("inference backend stopped responding")
+
+// probe checks that the runner's backend responds to a health request.
+func (r *runner) probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, watchdogProbeTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/v1/models", http.NoBody)
+	if err != nil {
+		return fmt.Errorf("health request creation failed: %w", err)
+	}
+	response, err := r.client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected health status: %s", response.Status)
+	}
+	return nil
+}
+
+// watchedRunner identifies a runner probed by the watchdog.
+type watchedRunner struct {
+	key      runnerKey
+	modelRef string
+	runner   *runner
+}
+
+// watchdog periodically probes the loaded runners and kills those that fail
+// several consecutive probes, e.g. backends that still accept connections but
+// stopped responding after running out of GPU memory. Killed runners are
+// restarted like crashed runners. It blocks until ctx is cancelled.
+func (l *loader) watchdog(ctx context.Context) {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+	failures := make(map[*runner]int)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Collect the running local runners. Remote runners don't have
+		// processes to restart.
+		if !l.lock(ctx) {
+			return
+		}
+		var watched []watchedRunner
+		for key, info := range l.runners {
+			r := l.slots[info.slot]
+			if isRemoteBackend(l.backends[key.backend]) {
+				continue
+			}
+			select {
+			case <-r.done:
+				continue
+			default:
+			}
+			watched = append(watched, watchedRunner{key, info.modelRef, r})
+		}
+		l.unlock()
+
+		// Probe the runners concurrently, so that hung runners don't delay
+		// probes of others.
+		results := make([]error, len(watched))
+		var wg sync.WaitGroup
+		for i, w := range watched {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = w.runner.probe(ctx)
+			}()
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			return
+		}
+
+		current := make(map[*runner]int, len(watched))
+		for i, w := range watched {
+			if results[i] == nil {
+				continue
+			}
+			current[w.runner] = failures[w.runner] + 1
+			l.log.Warnf("Health probe of %s backend runner with model %s (%s) failed (%d/%d): %v",
+				w.key.backend, w.key.modelID, w.modelRef, current[w.runner], watchdogFailureThreshold, results[i])
+			if current[w.runner] >= watchdogFailureThreshold {
+				l.killHung(w)
+				delete(current, w.runner)
+			}
+		}
+		failures = current
+	}
+}
+
+// killHung kills a hung runner, so that it's restarted.
+func (l *loader) killHung(w watchedRunner) {
+	l.log.WithFields(logrus.Fields{
+		"event":   "runner_hung",
+		"backend": w.key.backend,
+		"model":   w.modelRef,
+		"mode":    w.key.mode.String(),
+		"replica": w.key.replica,
+	}).Errorf("%s backend runner with model %s (%s) in %s mode stopped responding, restarting it",
+		w.key.backend, w.key.modelID, w.modelRef, w.key.mode)
+	w.runner.hung.Store(true)
+	w.runner.cancel()
+}
@@ -0,0 +1,406 @@
+package structured
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedGrammar indicates that a schema uses keywords that can't be
+// compiled to a grammar.
+var ErrUnsupportedGrammar = errors.New("schema can't be compiled to a grammar")
+
+// primitiveRules are the GBNF rules of JSON values, with the rules that they
+// reference, in the style of llama.cpp's JSON schema conversion.
+var primitiveRules = map[string]struct {
+	body string
+	deps []string
+}{
+	"space":         {`| " " | "\n" [ \t]{0,20}`, nil},
+	"boolean":       {`("true" | "false") space`, []string{"space"}},
+	"null":          {`"null" space`, []string{"space"}},
+	"char":          {`[^"\\\x7F\x00-\x1F] | [\\] (["\\bfnrt] | "u" [0-9a-fA-F]{4})`, nil},
+	"string":        {`"\"" char* "\"" space`, []string{"char", "space"}},
+	"integral-part": {`[0] | [1-9] [0-9]{0,15}`, nil},
+	"decimal-part":  {`[0-9]{1,16}`, nil},
+	"number":        {`("-"? integral-part) ("." decimal-part)? ([eE] [-+]? integral-part)? space`, []string{"integral-part", "decimal-part", "space"}},
+	"integer":       {`("-"? integral-part) space`, []string{"integral-part", "space"}},
+	"value":         {`object | array | string | number | boolean | null`, []string{"object", "array", "string", "number", "boolean", "null"}},
+	"object":        {`"{" space ( string ":" space value ("," space string ":" space value)* )? "}" space`, []string{"string", "value", "space"}},
+	"array":         {`"[" space ( value ("," space value)* )? "]" space`, []string{"value", "space"}},
+}
+
+// grammarKeywords are the keywords that grammars constrain values with, or
+// that don't constrain values. Schemas with other keywords can't be compiled.
+var grammarKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true, "$ref": true, "anyOf": true, "oneOf": true,
+	"properties": true, "required": true, "additionalProperties": true,
+	"items": true, "minItems": true, "maxItems": true, "minLength": true, "maxLength": true,
+	"$defs": true, "definitions": true, "$schema": true, "$id": true, "$comment": true,
+	"title": true, "description": true, "default": true, "examples": true, "strict": true,
+	"deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// invalidRuleChars are the characters that can't be part of rule names.
+var invalidRuleChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// Grammar compiles a JSON schema to a GBNF grammar, the grammar format of
+// llama.cpp, that only matches JSON values valid against the schema. Objects
+// are matched with their properties in the order of the schema, and without
+// other properties unless they have none. It returns ErrUnsupportedGrammar
+// for schemas with keywords that grammars can't constrain values with, such
+// as patterns and numeric ranges.
+func Grammar(raw json.RawMessage) (string, error) {
+	root, order, err := decodeOrdered(raw)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	g := &grammar{root: root, order: order, rules: make(map[string]string), refs: make(map[string]string)}
+	// Reserve the root rule, which is added once the schema is compiled.
+	g.rules["root"] = ""
+	expression, err := g.expression(root, "root")
+	if err != nil {
+		return "", err
+	}
+	g.rules["root"] = expression
+
+	var text strings.Builder
+	text.WriteString("root ::= " + expression + "\n")
+	names := make([]string, 0, len(g.rules))
+	for name := range g.rules {
+		if name != "root" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		text.WriteString(name + " ::= " + g.rules[name] + "\n")
+	}
+	return text.String(), nil
+}
+
+// grammar is a grammar being compiled from a schema.
+type grammar struct {
+	// root is the decoded schema, which references are resolved in.
+	root any
+	// order are the keys of the objects of the schema in the order in which
+	// they're declared, by object.
+	order map[uintptr][]string
+	// rules are the bodies of the rules of the grammar, by name.
+	rules map[string]string
+	// refs are the names of the rules of references.
+	refs map[string]string
+}
+
+// primitive adds a primitive rule to the grammar, with the rules that it
+// references, and returns its name.
+func (g *grammar) primitive(name string) string {
+	if _, ok := g.rules[name]; !ok {
+		rule := primitiveRules[name]
+		g.rules[name] = rule.body
+		for _, dep := range rule.deps {
+			g.primitive(dep)
+		}
+	}
+	return name
+}
+
+// rule adds a rule to the grammar with a name derived from name, and returns
+// the name of the rule. Rules with the same body are shared.
+func (g *grammar) rule(name, body string) string {
+	for existing, existingBody := range g.rules {
+		if existingBody == body {
+			return existing
+		}
+	}
+	name = g.reserve(name)
+	g.rules[name] = body
+	return name
+}
+
+// reserve returns a name derived from name that no rule has.
+func (g *grammar) reserve(name string) string {
+	name = strings.Trim(invalidRuleChars.ReplaceAllString(name, "-"), "-")
+	if name == "" {
+		name = "rule"
+	}
+	unique := name
+	for i := 1; ; i++ {
+		if _, ok := g.rules[unique]; !ok {
+			if _, ok := primitiveRules[unique]; !ok {
+				return unique
+			}
+		}
+		unique = name + "-" + strconv.Itoa(i)
+	}
+}
+
+// expression returns a grammar expression that matches the values valid
+// against a schema, adding the rules that it references. name is used to name
+// those rules.
+func (g *grammar) expression(schema any, name string) (string, error) {
+	object, ok := schema.(map[string]any)
+	if !ok {
+		if schema == true {
+			return g.primitive("value"), nil
+		}
+		return "", fmt.Errorf("%w: schemas that allow no value", ErrUnsupportedGrammar)
+	}
+	for keyword := range object {
+		if !grammarKeywords[keyword] {
+			return "", fmt.Errorf("%w: unsupported keyword %q", ErrUnsupportedGrammar, keyword)
+		}
+	}
+
+	if ref, ok := object["$ref"].(string); ok {
+		if len(object) > 1 && !onlyAnnotations(object, "$ref") {
+			return "", fmt.Errorf("%w: $ref with other keywords", ErrUnsupportedGrammar)
+		}
+		return g.ref(ref)
+	}
+	if constant, ok := object["const"]; ok {
+		g.primitive("space")
+		return literal(constant)
+	}
+	if enum, ok := object["enum"].([]any); ok {
+		g.primitive("space")
+		alternatives := make([]string, 0, len(enum))
+		for _, value := range enum {
+			alternative, err := literal(value)
+			if err != nil {
+				return "", err
+			}
+			alternatives = append(alternatives, alternative)
+		}
+		return g.rule(name, strings.Join(alternatives, " | ")), nil
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		if subs, ok := object[keyword].([]any); ok {
+			alternatives := make([]string, 0, len(subs))
+			for i, sub := range subs {
+				alternative, err := g.expression(sub, fmt.Sprintf("%s-%d", name, i))
+				if err != nil {
+					return "", err
+				}
+				alternatives = append(alternatives, alternative)
+			}
+			return g.rule(name, strings.Join(alternatives, " | ")), nil
+		}
+	}
+
+	var types []string
+	switch t := object["type"].(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, name := range t {
+			if name, ok := name.(string); ok {
+				types = append(types, name)
+			}
+		}
+	case nil:
+		// Untyped schemas are inferred from their keywords.
+		if _, ok := object["properties"]; ok {
+			types = []string{"object"}
+		} else if _, ok := object["items"]; ok {
+			types = []string{"array"}
+		} else {
+			return g.primitive("value"), nil
+		}
+	}
+	alternatives := make([]string, 0, len(types))
+	for _, t := range types {
+		alternative, err := g.typed(object, t, name)
+		if err != nil {
+			return "", err
+		}
+		alternatives = append(alternatives, alternative)
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0], nil
+	}
+	return g.rule(name, strings.Join(alternatives, " | ")), nil
+}
+
+// typed returns a grammar expression that matches the values of a type valid
+// against a schema.
+func (g *grammar) typed(schema map[string]any, t, name string) (string, error) {
+	switch t {
+	case "string":
+		minLength, hasMin := number(schema["minLength"])
+		maxLength, hasMax := number(schema["maxLength"])
+		if !hasMin && !hasMax {
+			return g.primitive("string"), nil
+		}
+		g.primitive("char")
+		g.primitive("space")
+		return g.rule(name, `"\"" char`+repetition(int(minLength), int(maxLength), hasMax)+` "\"" space`), nil
+	case "number", "integer", "boolean", "null":
+		return g.primitive(t), nil
+	case "object":
+		return g.object(schema, name)
+	case "array":
+		items, ok := schema["items"]
+		if !ok {
+			items = true
+		}
+		item, err := g.expression(items, name+"-item")
+		if err != nil {
+			return "", err
+		}
+		minItems, _ := number(schema["minItems"])
+		maxItems, hasMax := number(schema["maxItems"])
+		g.primitive("space")
+		var body string
+		switch {
+		case hasMax && maxItems == 0:
+			body = `"[" space "]" space`
+		case minItems == 0:
+			body = fmt.Sprintf(`"[" space ( %s ("," space %s)%s )? "]" space`, item, item, repetition(0, int(maxItems)-1, hasMax))
+		default:
+			body = fmt.Sprintf(`"[" space %s ("," space %s)%s "]" space`, item, item, repetition(int(minItems)-1, int(maxItems)-1, hasMax))
+		}
+		return g.rule(name, body), nil
+	default:
+		return "", fmt.Errorf("%w: unknown type %q", ErrInvalidSchema, t)
+	}
+}
+
+// object returns a grammar expression that matches the objects valid against
+// a schema. Required properties come first, in the order of the schema,
+// followed by the optional properties that are present.
+func (g *grammar) object(schema map[string]any, name string) (string, error) {
+	properties, _ := schema["properties"].(map[string]any)
+	additional, hasAdditional := schema["additionalProperties"]
+	if len(properties) == 0 {
+		if !hasAdditional || additional == true {
+			return g.primitive("object"), nil
+		}
+		if additional == false {
+			g.primitive("space")
+			return g.rule(name, `"{" space "}" space`), nil
+		}
+		return "", fmt.Errorf("%w: additionalProperties schemas", ErrUnsupportedGrammar)
+	}
+	if hasAdditional && additional != false {
+		return "", fmt.Errorf("%w: additional properties of objects with properties", ErrUnsupportedGrammar)
+	}
+
+	required := make(map[string]bool)
+	if names, ok := schema["required"].([]any); ok {
+		for _, n := range names {
+			if n, ok := n.(string); ok {
+				required[n] = true
+			}
+		}
+	}
+	var requiredRules, optionalRules []string
+	for _, property := range g.keys(properties) {
+		value, err := g.expression(properties[property], name+"-"+property)
+		if err != nil {
+			return "", err
+		}
+		key, err := literal(property)
+		if err != nil {
+			return "", err
+		}
+		kv := g.rule(name+"-"+property+"-kv", key+` ":" space `+value)
+		if required[property] {
+			requiredRules = append(requiredRules, kv)
+		} else {
+			optionalRules = append(optionalRules, kv)
+		}
+	}
+	g.primitive("space")
+
+	// With required properties, optional properties follow them. Otherwise,
+	// the first property present is followed by any of the later ones.
+	var body strings.Builder
+	body.WriteString(`"{" space `)
+	if len(requiredRules) > 0 {
+		body.WriteString(strings.Join(requiredRules, ` "," space `))
+		for _, kv := range optionalRules {
+			body.WriteString(` ("," space ` + kv + `)?`)
+		}
+	} else {
+		alternatives := make([]string, 0, len(optionalRules))
+		for i, kv := range optionalRules {
+			alternative := kv
+			for _, later := range optionalRules[i+1:] {
+				alternative += ` ("," space ` + later + `)?`
+			}
+			alternatives = append(alternatives, alternative)
+		}
+		body.WriteString("( " + strings.Join(alternatives, " | ") + " )?")
+	}
+	body.WriteString(` "}" space`)
+	return g.rule(name, body.String()), nil
+}
+
+// ref returns the name of the rule of a reference, compiling it the first
+// time that it's referenced.
+func (g *grammar) ref(ref string) (string, error) {
+	if name, ok := g.refs[ref]; ok {
+		return name, nil
+	}
+	target, err := resolve(g.root, ref)
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimPrefix(ref[strings.LastIndex(ref, "/")+1:], "#")
+	name = g.reserve(cmp.Or(name, "ref"))
+	// Reserve the rule before compiling it, for recursive references.
+	g.refs[ref] = name
+	g.rules[name] = ""
+	expression, err := g.expression(target, name)
+	if err != nil {
+		return "", err
+	}
+	g.rules[name] = expression
+	return name, nil
+}
+
+// keys returns the keys of an object of the schema in the order in which
+// they're declared.
+func (g *grammar) keys(object map[string]any) []string {
+	return orderedKeys(object, g.order)
+}
+
+// onlyAnnotations returns true if a schema has no keywords other than except
+// and annotations.
+func onlyAnnotations(schema map[string]any, except string) bool {
+	for keyword := range schema {
+		switch keyword {
+		case except, "title", "description", "default", "examples", "$comment", "deprecated", "readOnly", "writeOnly":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// literal returns a grammar literal matching a JSON value.
+func literal(value any) (string, error) {
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strconv.Quote(strings.TrimSuffix(encoded.String(), "\n")) + " space", nil
+}
+
+// repetition returns the repetition of a grammar item between min and max
+// times, without a maximum if hasMax is false.
+func repetition(min, max int, hasMax bool) string {
+	if !hasMax {
+		return fmt.Sprintf("{%d,}", min)
+	}
+	return fmt.Sprintf("{%d,%d}", min, max)
+}
@@ -0,0 +1,77 @@
+package structured
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGrammar(t *testing.T) {
+	grammar, err := Grammar(json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "description": "The name."},
+			"kind": {"enum": ["a", "b"]},
+			"tags": {"type": "array", "items": {"type": "string"}, "minItems": 1, "maxItems": 3},
+			"parent": {"$ref": "#"}
+		},
+		"required": ["kind", "name"],
+		"additionalProperties": false
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rules := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(grammar), "\n") {
+		name, body, ok := strings.Cut(line, " ::= ")
+		if !ok {
+			t.Fatalf("Invalid rule %q", line)
+		}
+		rules[name] = body
+	}
+	for name, expected := range map[string]string{
+		"root":          `ref-1`,
+		"ref":           `ref-1`,
+		"ref-1":         `"{" space root-name-kv "," space root-kind-kv ("," space root-tags-kv)? ("," space ref-parent-kv)? "}" space`,
+		"root-name-kv":  `"\"name\"" space ":" space string`,
+		"root-kind":     `"\"a\"" space | "\"b\"" space`,
+		"root-tags":     `"[" space string ("," space string){0,2} "]" space`,
+		"ref-parent-kv": `"\"parent\"" space ":" space ref`,
+	} {
+		if rules[name] != expected {
+			t.Errorf("Expected rule %s ::= %s, got %q", name, expected, rules[name])
+		}
+	}
+	for _, name := range []string{"space", "string", "char"} {
+		if _, ok := rules[name]; !ok {
+			t.Errorf("Expected the primitive rule %s", name)
+		}
+	}
+
+	// Arrays of objects without required properties allow any of them.
+	grammar, err = Grammar(json.RawMessage(`{"$defs": {"item": {"type": "object", "properties": {"id": {"type": "integer"}, "note": {"type": "string"}}}}, "type": "array", "items": {"$ref": "#/$defs/item"}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, rule := range []string{
+		`root ::= root-1` + "\n",
+		`root-1 ::= "[" space ( item ("," space item){0,} )? "]" space` + "\n",
+		`item-1 ::= "{" space ( item-id-kv ("," space item-note-kv)? | item-note-kv )? "}" space` + "\n",
+	} {
+		if !strings.Contains(grammar, rule) {
+			t.Errorf("Expected rule %q in grammar:\n%s", rule, grammar)
+		}
+	}
+
+	// Keywords that grammars can't constrain values with aren't compiled.
+	for _, schema := range []string{
+		`{"type": "string", "pattern": "^a"}`,
+		`{"type": "integer", "minimum": 0}`,
+		`{"type": "object", "additionalProperties": {"type": "string"}}`,
+	} {
+		if _, err := Grammar(json.RawMessage(schema)); !errors.Is(err, ErrUnsupportedGrammar) {
+			t.Errorf("Expected %s to be unsupported, got %v", schema, err)
+		}
+	}
+}
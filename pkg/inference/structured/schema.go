@@ -0,0 +1,492 @@
+package structured
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrInvalidSchema indicates that a JSON schema is invalid or uses
+	// unsupported references.
+	ErrInvalidSchema = errors.New("invalid JSON schema")
+	// ErrSchemaMismatch indicates that a value isn't valid against a schema.
+	ErrSchemaMismatch = errors.New("value doesn't match the JSON schema")
+)
+
+// Schema is a compiled JSON schema, which validates JSON values. It supports
+// the keywords of JSON Schema draft 2020-12 that describe the structure of
+// values, with local references. Annotations, such as descriptions and
+// formats, aren't validated.
+type Schema struct {
+	// root is the decoded schema.
+	root any
+	// patterns are the compiled patterns of the schema.
+	patterns map[string]*regexp.Regexp
+}
+
+// Compile compiles a JSON schema.
+func Compile(raw json.RawMessage) (*Schema, error) {
+	root, err := decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	s := &Schema{root: root, patterns: make(map[string]*regexp.Regexp)}
+	if err := s.check(root, make(map[string]bool)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// decode decodes JSON, keeping numbers exact.
+func decode(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the value")
+	}
+	return value, nil
+}
+
+// subschemaKeywords are the keywords whose value is a schema.
+var subschemaKeywords = []string{"additionalProperties", "items", "not", "contains"}
+
+// subschemaListKeywords are the keywords whose value is a list of schemas.
+var subschemaListKeywords = []string{"anyOf", "oneOf", "allOf", "prefixItems"}
+
+// subschemaMapKeywords are the keywords whose value maps names to schemas.
+var subschemaMapKeywords = []string{"properties", "$defs", "definitions"}
+
+// check checks that the references of a schema resolve and its patterns
+// compile, compiling them.
+func (s *Schema) check(schema any, checked map[string]bool) error {
+	object, ok := schema.(map[string]any)
+	if !ok {
+		if _, ok := schema.(bool); !ok {
+			return fmt.Errorf("%w: schemas must be objects or booleans", ErrInvalidSchema)
+		}
+		return nil
+	}
+	if ref, ok := object["$ref"].(string); ok && !checked[ref] {
+		checked[ref] = true
+		target, err := s.resolve(ref)
+		if err != nil {
+			return err
+		}
+		if err := s.check(target, checked); err != nil {
+			return err
+		}
+	}
+	if pattern, ok := object["pattern"].(string); ok {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%w: unsupported pattern %q: %v", ErrInvalidSchema, pattern, err)
+		}
+		s.patterns[pattern] = compiled
+	}
+	for _, keyword := range subschemaKeywords {
+		if sub, ok := object[keyword]; ok {
+			if list, ok := sub.([]any); ok && keyword == "items" {
+				// Draft 4 tuples are lists of item schemas.
+				sub = map[string]any{"prefixItems": list}
+			}
+			if err := s.check(sub, checked); err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range subschemaListKeywords {
+		if list, ok := object[keyword]; ok {
+			subs, ok := list.([]any)
+			if !ok {
+				return fmt.Errorf("%w: %s must be a list of schemas", ErrInvalidSchema, keyword)
+			}
+			for _, sub := range subs {
+				if err := s.check(sub, checked); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, keyword := range subschemaMapKeywords {
+		if m, ok := object[keyword]; ok {
+			subs, ok := m.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w: %s must map names to schemas", ErrInvalidSchema, keyword)
+			}
+			for _, sub := range subs {
+				if err := s.check(sub, checked); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolve resolves a local reference, which is a JSON pointer into the
+// schema.
+func (s *Schema) resolve(ref string) (any, error) {
+	return resolve(s.root, ref)
+}
+
+// resolve resolves a local reference into a schema.
+func resolve(root any, ref string) (any, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported $ref %q: only local references are supported", ErrInvalidSchema, ref)
+	}
+	target := root
+	if pointer == "" {
+		return target, nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch node := target.(type) {
+		case map[string]any:
+			if target, ok = node[token]; !ok {
+				return nil, fmt.Errorf("%w: unresolved $ref %q", ErrInvalidSchema, ref)
+			}
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("%w: unresolved $ref %q", ErrInvalidSchema, ref)
+			}
+			target = node[i]
+		default:
+			return nil, fmt.Errorf("%w: unresolved $ref %q", ErrInvalidSchema, ref)
+		}
+	}
+	return target, nil
+}
+
+// Validate validates a JSON document against the schema.
+func (s *Schema) Validate(data []byte) error {
+	value, err := decode(data)
+	if err != nil {
+		return fmt.Errorf("%w: invalid JSON: %v", ErrSchemaMismatch, err)
+	}
+	return s.validate(s.root, value, "$")
+}
+
+// mismatch returns a mismatch error for the value at a path.
+func mismatch(path, format string, args ...any) error {
+	return fmt.Errorf("%w: %s: %s", ErrSchemaMismatch, path, fmt.Sprintf(format, args...))
+}
+
+// validate validates the value at a path against a schema.
+func (s *Schema) validate(schema, value any, path string) error {
+	object, ok := schema.(map[string]any)
+	if !ok {
+		if schema == false {
+			return mismatch(path, "no value is allowed")
+		}
+		return nil
+	}
+
+	if ref, ok := object["$ref"].(string); ok {
+		target, err := s.resolve(ref)
+		if err != nil {
+			return err
+		}
+		if err := s.validate(target, value, path); err != nil {
+			return err
+		}
+	}
+	if types, ok := object["type"]; ok && !matchesType(types, value) {
+		return mismatch(path, "expected %s, got %s", typeNames(types), typeOf(value))
+	}
+	if enum, ok := object["enum"].([]any); ok && !containsValue(enum, value) {
+		return mismatch(path, "value isn't one of the enum values")
+	}
+	if constant, ok := object["const"]; ok && !equal(constant, value) {
+		return mismatch(path, "value isn't the constant value")
+	}
+
+	switch value := value.(type) {
+	case string:
+		if err := s.validateString(object, value, path); err != nil {
+			return err
+		}
+	case json.Number:
+		if err := validateNumber(object, value, path); err != nil {
+			return err
+		}
+	case map[string]any:
+		if err := s.validateObject(object, value, path); err != nil {
+			return err
+		}
+	case []any:
+		if err := s.validateArray(object, value, path); err != nil {
+			return err
+		}
+	}
+
+	if subs, ok := object["allOf"].([]any); ok {
+		for _, sub := range subs {
+			if err := s.validate(sub, value, path); err != nil {
+				return err
+			}
+		}
+	}
+	if subs, ok := object["anyOf"].([]any); ok {
+		matched := false
+		for _, sub := range subs {
+			if s.validate(sub, value, path) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return mismatch(path, "value doesn't match any schema of anyOf")
+		}
+	}
+	if subs, ok := object["oneOf"].([]any); ok {
+		matches := 0
+		for _, sub := range subs {
+			if s.validate(sub, value, path) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return mismatch(path, "value matches %d schemas of oneOf instead of one", matches)
+		}
+	}
+	if not, ok := object["not"]; ok && s.validate(not, value, path) == nil {
+		return mismatch(path, "value matches the schema of not")
+	}
+	return nil
+}
+
+// validateString validates a string against the string keywords of a schema.
+func (s *Schema) validateString(schema map[string]any, value, path string) error {
+	length := utf8.RuneCountInString(value)
+	if n, ok := number(schema["minLength"]); ok && float64(length) < n {
+		return mismatch(path, "string is shorter than %v characters", n)
+	}
+	if n, ok := number(schema["maxLength"]); ok && float64(length) > n {
+		return mismatch(path, "string is longer than %v characters", n)
+	}
+	if pattern, ok := schema["pattern"].(string); ok && !s.patterns[pattern].MatchString(value) {
+		return mismatch(path, "string doesn't match the pattern %q", pattern)
+	}
+	return nil
+}
+
+// validateNumber validates a number against the numeric keywords of a schema.
+func validateNumber(schema map[string]any, value json.Number, path string) error {
+	v, err := value.Float64()
+	if err != nil {
+		return mismatch(path, "invalid number %s", value)
+	}
+	if n, ok := number(schema["minimum"]); ok && v < n {
+		return mismatch(path, "number is less than the minimum %v", n)
+	}
+	if n, ok := number(schema["maximum"]); ok && v > n {
+		return mismatch(path, "number is greater than the maximum %v", n)
+	}
+	if n, ok := number(schema["exclusiveMinimum"]); ok && v <= n {
+		return mismatch(path, "number isn't greater than the exclusive minimum %v", n)
+	}
+	if n, ok := number(schema["exclusiveMaximum"]); ok && v >= n {
+		return mismatch(path, "number isn't less than the exclusive maximum %v", n)
+	}
+	if n, ok := number(schema["multipleOf"]); ok && n > 0 {
+		if q := v / n; math.Abs(q-math.Round(q)) > 1e-9 {
+			return mismatch(path, "number isn't a multiple of %v", n)
+		}
+	}
+	return nil
+}
+
+// validateObject validates an object against the object keywords of a schema.
+func (s *Schema) validateObject(schema map[string]any, value map[string]any, path string) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, ok := value[name]; !ok {
+					return mismatch(path, "missing required property %q", name)
+				}
+			}
+		}
+	}
+	if n, ok := number(schema["minProperties"]); ok && float64(len(value)) < n {
+		return mismatch(path, "object has fewer than %v properties", n)
+	}
+	if n, ok := number(schema["maxProperties"]); ok && float64(len(value)) > n {
+		return mismatch(path, "object has more than %v properties", n)
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	additional, hasAdditional := schema["additionalProperties"]
+	for name, property := range value {
+		propertyPath := path + "." + name
+		if sub, ok := properties[name]; ok {
+			if err := s.validate(sub, property, propertyPath); err != nil {
+				return err
+			}
+		} else if hasAdditional {
+			if additional == false {
+				return mismatch(path, "unexpected property %q", name)
+			}
+			if err := s.validate(additional, property, propertyPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateArray validates an array against the array keywords of a schema.
+func (s *Schema) validateArray(schema map[string]any, value []any, path string) error {
+	if n, ok := number(schema["minItems"]); ok && float64(len(value)) < n {
+		return mismatch(path, "array has fewer than %v items", n)
+	}
+	if n, ok := number(schema["maxItems"]); ok && float64(len(value)) > n {
+		return mismatch(path, "array has more than %v items", n)
+	}
+	prefix, _ := schema["prefixItems"].([]any)
+	items, hasItems := schema["items"]
+	if tuple, ok := items.([]any); ok {
+		prefix, hasItems = tuple, false
+	}
+	for i, item := range value {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if i < len(prefix) {
+			if err := s.validate(prefix[i], item, itemPath); err != nil {
+				return err
+			}
+		} else if hasItems {
+			if err := s.validate(items, item, itemPath); err != nil {
+				return err
+			}
+		}
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range value {
+			for j := i + 1; j < len(value); j++ {
+				if equal(value[i], value[j]) {
+					return mismatch(path, "array items %d and %d aren't unique", i, j)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchesType returns true if a value has one of the types of a schema.
+func matchesType(types, value any) bool {
+	names, ok := types.([]any)
+	if !ok {
+		names = []any{types}
+	}
+	actual := typeOf(value)
+	for _, name := range names {
+		if name == actual || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeNames describes the types of a schema.
+func typeNames(types any) string {
+	names, ok := types.([]any)
+	if !ok {
+		return fmt.Sprint(types)
+	}
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprint(name))
+	}
+	return strings.Join(parts, " or ")
+}
+
+// typeOf returns the JSON schema type of a value.
+func typeOf(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if v, err := value.Float64(); err == nil && v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// number returns the value of a numeric keyword.
+func number(value any) (float64, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	v, err := n.Float64()
+	return v, err == nil
+}
+
+// equal returns true if two JSON values are equal, comparing numbers by
+// value.
+func equal(a, b any) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		af, aerr := an.Float64()
+		bf, berr := bn.Float64()
+		return aerr == nil && berr == nil && af == bf
+	}
+	switch a := a.(type) {
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			if other, ok := b[key]; !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+// containsValue returns true if values contain a value equal to value.
+func containsValue(values []any, value any) bool {
+	for _, v := range values {
+		if equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,265 @@
+// Package structured enforces strict JSON schema structured outputs. Schemas
+// are normalized to the semantics of OpenAI's strict mode, compiled to the
+// constrained decoding mechanisms of backends, and validated against the
+// final outputs of models.
+package structured
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// ErrInvalidRequest indicates that the response format of a request is
+// invalid.
+var ErrInvalidRequest = errors.New("invalid structured output request")
+
+// ResponseFormat is the JSON schema of the response format of a request.
+type ResponseFormat struct {
+	// Schema is the JSON schema.
+	Schema json.RawMessage
+	// Strict indicates whether the output must match the schema.
+	Strict bool
+}
+
+// ParseResponseFormat returns the JSON schema response format of a request,
+// or nil if its response format isn't a JSON schema.
+func ParseResponseFormat(request map[string]json.RawMessage) (*ResponseFormat, error) {
+	raw, ok := request["response_format"]
+	if !ok || isNull(raw) {
+		return nil, nil
+	}
+	var format struct {
+		Type       string `json:"type"`
+		JSONSchema *struct {
+			Schema json.RawMessage `json:"schema"`
+			Strict *bool           `json:"strict"`
+		} `json:"json_schema"`
+	}
+	if err := json.Unmarshal(raw, &format); err != nil {
+		return nil, fmt.Errorf("%w: response_format: %v", ErrInvalidRequest, err)
+	}
+	if format.Type != "json_schema" {
+		return nil, nil
+	}
+	if format.JSONSchema == nil || len(format.JSONSchema.Schema) == 0 || isNull(format.JSONSchema.Schema) {
+		return nil, fmt.Errorf("%w: response_format.json_schema.schema is required", ErrInvalidRequest)
+	}
+	return &ResponseFormat{
+		Schema: format.JSONSchema.Schema,
+		Strict: format.JSONSchema.Strict != nil && *format.JSONSchema.Strict,
+	}, nil
+}
+
+// Parse returns, for requests with a strict JSON schema response format, the
+// request body with the schema normalized for strict mode and the compiled
+// schema, which the output is validated against. Other requests are returned
+// as-is, without a schema. Bodies that aren't JSON objects are returned as-is,
+// for the backend to reject.
+func Parse(body []byte) ([]byte, *Schema, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return body, nil, nil
+	}
+	format, err := ParseResponseFormat(request)
+	if err != nil || format == nil || !format.Strict {
+		return body, nil, err
+	}
+
+	var responseFormat map[string]json.RawMessage
+	var jsonSchema map[string]json.RawMessage
+	if err := json.Unmarshal(request["response_format"], &responseFormat); err != nil {
+		return nil, nil, fmt.Errorf("%w: response_format: %v", ErrInvalidRequest, err)
+	}
+	if err := json.Unmarshal(responseFormat["json_schema"], &jsonSchema); err != nil {
+		return nil, nil, fmt.Errorf("%w: response_format.json_schema: %v", ErrInvalidRequest, err)
+	}
+	strict, err := strictSchema(format.Schema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	schema, err := Compile(strict)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	jsonSchema["schema"] = strict
+	if responseFormat["json_schema"], err = json.Marshal(jsonSchema); err != nil {
+		return nil, nil, err
+	}
+	if request["response_format"], err = json.Marshal(responseFormat); err != nil {
+		return nil, nil, err
+	}
+	if body, err = json.Marshal(request); err != nil {
+		return nil, nil, err
+	}
+	return body, schema, nil
+}
+
+// strictSchema normalizes a schema for strict mode, in which objects with
+// properties don't allow other properties unless their schema does. Object
+// schemas without properties are left free-form. The order of properties is
+// kept, since grammars generate them in order.
+func strictSchema(raw json.RawMessage) (json.RawMessage, error) {
+	schema, order, err := decodeOrdered(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	addStrict(schema, order)
+	return encodeOrdered(schema, order)
+}
+
+// addStrict disallows other properties in the objects of a decoded schema
+// with properties that don't declare additionalProperties.
+func addStrict(schema any, order map[uintptr][]string) {
+	object, ok := schema.(map[string]any)
+	if !ok {
+		return
+	}
+	if _, ok := object["properties"]; ok {
+		if _, ok := object["additionalProperties"]; !ok {
+			object["additionalProperties"] = false
+			key := reflect.ValueOf(object).Pointer()
+			order[key] = append(order[key], "additionalProperties")
+		}
+	}
+	for _, keyword := range subschemaKeywords {
+		addStrict(object[keyword], order)
+	}
+	for _, keyword := range subschemaListKeywords {
+		subs, _ := object[keyword].([]any)
+		for _, sub := range subs {
+			addStrict(sub, order)
+		}
+	}
+	for _, keyword := range subschemaMapKeywords {
+		subs, _ := object[keyword].(map[string]any)
+		for _, sub := range subs {
+			addStrict(sub, order)
+		}
+	}
+}
+
+// decodeOrdered decodes JSON like decode, also returning the keys of its
+// objects in the order in which they're declared, by object.
+func decodeOrdered(data []byte) (any, map[uintptr][]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	order := make(map[uintptr][]string)
+	var value func() (any, error)
+	value = func() (any, error) {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch token {
+		case json.Delim('{'):
+			object := make(map[string]any)
+			var keys []string
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				name, _ := key.(string)
+				v, err := value()
+				if err != nil {
+					return nil, err
+				}
+				if _, ok := object[name]; !ok {
+					keys = append(keys, name)
+				}
+				object[name] = v
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, err
+			}
+			order[reflect.ValueOf(object).Pointer()] = keys
+			return object, nil
+		case json.Delim('['):
+			list := []any{}
+			for decoder.More() {
+				v, err := value()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, err
+			}
+			return list, nil
+		default:
+			return token, nil
+		}
+	}
+	root, err := value()
+	if err != nil {
+		return nil, nil, err
+	}
+	if decoder.More() {
+		return nil, nil, errors.New("unexpected data after the value")
+	}
+	return root, order, nil
+}
+
+// encodeOrdered encodes a value decoded by decodeOrdered, with the keys of
+// its objects in their order.
+func encodeOrdered(value any, order map[uintptr][]string) ([]byte, error) {
+	switch value := value.(type) {
+	case map[string]any:
+		encoded := []byte{'{'}
+		for i, key := range orderedKeys(value, order) {
+			if i > 0 {
+				encoded = append(encoded, ',')
+			}
+			name, err := json.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			v, err := encodeOrdered(value[key], order)
+			if err != nil {
+				return nil, err
+			}
+			encoded = append(append(append(encoded, name...), ':'), v...)
+		}
+		return append(encoded, '}'), nil
+	case []any:
+		encoded := []byte{'['}
+		for i, item := range value {
+			if i > 0 {
+				encoded = append(encoded, ',')
+			}
+			v, err := encodeOrdered(item, order)
+			if err != nil {
+				return nil, err
+			}
+			encoded = append(encoded, v...)
+		}
+		return append(encoded, ']'), nil
+	default:
+		return json.Marshal(value)
+	}
+}
+
+// orderedKeys returns the keys of an object decoded by decodeOrdered in the
+// order in which they're declared, or sorted if it wasn't decoded.
+func orderedKeys(object map[string]any, order map[uintptr][]string) []string {
+	if keys, ok := order[reflect.ValueOf(object).Pointer()]; ok {
+		return keys
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// isNull returns true if a raw JSON value is null.
+func isNull(raw json.RawMessage) bool {
+	return string(raw) == "null"
+}
@@ -0,0 +1,100 @@
+package structured
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	body := `{"model": "m", "response_format": {"type": "json_schema", "json_schema": {"name": "person", "strict": true, "schema": {"type": "object", "properties": {"name": {"type": "string"}, "address": {"type": "object", "properties": {"city": {"type": "string"}}}, "tags": {"type": "object"}}, "required": ["name"]}}}}`
+	translated, schema, err := Parse([]byte(body))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var request struct {
+		ResponseFormat struct {
+			JSONSchema struct {
+				Schema json.RawMessage `json:"schema"`
+			} `json:"json_schema"`
+		} `json:"response_format"`
+	}
+	if err := json.Unmarshal(translated, &request); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"object","properties":{"name":{"type":"string"},"address":{"type":"object","properties":{"city":{"type":"string"}},"additionalProperties":false},"tags":{"type":"object"}},"required":["name"],"additionalProperties":false}`
+	if string(request.ResponseFormat.JSONSchema.Schema) != expected {
+		t.Errorf("Expected schema %s, got %s", expected, request.ResponseFormat.JSONSchema.Schema)
+	}
+	if err := schema.Validate([]byte(`{"name": "Ada", "address": {"city": "London"}, "tags": {"any": 1}}`)); err != nil {
+		t.Errorf("Unexpected mismatch: %v", err)
+	}
+	if err := schema.Validate([]byte(`{"name": "Ada", "age": 36}`)); !errors.Is(err, ErrSchemaMismatch) {
+		t.Errorf("Expected other properties to be rejected, got %v", err)
+	}
+
+	// Formats that aren't strict aren't enforced.
+	for _, body := range []string{
+		`{"response_format": {"type": "json_schema", "json_schema": {"schema": {"type": "object"}}}}`,
+		`{"response_format": {"type": "json_object"}}`,
+		`{"model": "m"}`,
+	} {
+		if translated, schema, err := Parse([]byte(body)); err != nil || schema != nil || string(translated) != body {
+			t.Errorf("Expected %s to be unchanged, got %s, %v, %v", body, translated, schema, err)
+		}
+	}
+
+	for _, body := range []string{
+		`{"response_format": {"type": "json_schema", "json_schema": {"strict": true}}}`,
+		`{"response_format": {"type": "json_schema", "json_schema": {"strict": true, "schema": {"$ref": "https://example.com/schema"}}}}`,
+		`{"response_format": {"type": "json_schema", "json_schema": {"strict": true, "schema": {"pattern": "(?<"}}}}`,
+	} {
+		if _, _, err := Parse([]byte(body)); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Expected %s to be rejected, got %v", body, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := Compile(json.RawMessage(`{
+		"$defs": {"node": {"type": "object", "properties": {"value": {"type": "integer"}, "next": {"anyOf": [{"$ref": "#/$defs/node"}, {"type": "null"}]}}, "required": ["value", "next"], "additionalProperties": false}},
+		"type": "object",
+		"properties": {
+			"id": {"type": "string", "pattern": "^[a-z]+$", "minLength": 2},
+			"kind": {"enum": ["a", "b"]},
+			"score": {"type": "number", "minimum": 0, "exclusiveMaximum": 1},
+			"list": {"$ref": "#/$defs/node"},
+			"pair": {"type": "array", "prefixItems": [{"type": "string"}, {"type": "integer"}], "items": false},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true}
+		},
+		"required": ["id"]
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	valid := `{"id": "ab", "kind": "a", "score": 0.5, "list": {"value": 1, "next": {"value": 2.0, "next": null}}, "pair": ["x", 1], "tags": ["x", "y"]}`
+	if err := schema.Validate([]byte(valid)); err != nil {
+		t.Errorf("Unexpected mismatch: %v", err)
+	}
+	for name, value := range map[string]string{
+		"invalid JSON":       `{"id": "ab"`,
+		"missing required":   `{}`,
+		"wrong type":         `{"id": 1}`,
+		"pattern":            `{"id": "AB"}`,
+		"min length":         `{"id": "a"}`,
+		"enum":               `{"id": "ab", "kind": "c"}`,
+		"exclusive maximum":  `{"id": "ab", "score": 1}`,
+		"recursive ref":      `{"id": "ab", "list": {"value": 1, "next": {"value": 1.5, "next": null}}}`,
+		"closed object":      `{"id": "ab", "list": {"value": 1, "next": null, "other": true}}`,
+		"tuple items":        `{"id": "ab", "pair": ["x", 1, 2]}`,
+		"unique items":       `{"id": "ab", "tags": ["x", "x"]}`,
+		"max items":          `{"id": "ab", "tags": ["x", "y", "z"]}`,
+		"data after a value": `{"id": "ab"} {}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := schema.Validate([]byte(value)); !errors.Is(err, ErrSchemaMismatch) {
+				t.Errorf("Expected a mismatch, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package toolcalls
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// ParserFlag is the flag that selects the tool call parser of vLLM and
+// SGLang.
+const ParserFlag = "--tool-call-parser"
+
+// format is a format in which models write tool calls.
+type format string
+
+const (
+	formatHermes   format = "hermes"
+	formatLlama3   format = "llama3"
+	formatMistral  format = "mistral"
+	formatDeepSeek format = "deepseek_v3"
+	formatGranite  format = "granite"
+	formatInternLM format = "internlm"
+	formatPhi4Mini format = "phi4_mini"
+	formatGPTOSS   format = "gpt_oss"
+	formatKimiK2   format = "kimi_k2"
+	formatGLM45    format = "glm45"
+	formatPythonic format = "pythonic"
+)
+
+// templateMarkers are markers of the chat templates of models that identify
+// their tool call format, in the order in which they're checked.
+var templateMarkers = []struct {
+	marker string
+	format format
+}{
+	{"<arg_key>", formatGLM45},
+	{"<|tool_calls_section_begin|>", formatKimiK2},
+	{"<｜tool▁calls▁begin｜>", formatDeepSeek},
+	{"<|channel|>", formatGPTOSS},
+	{"[TOOL_CALLS]", formatMistral},
+	{"<|action_start|>", formatInternLM},
+	{"functools[", formatPhi4Mini},
+	{"<|tool_call|>", formatGranite},
+	{"<tool_call>", formatHermes},
+	{"<|python_tag|>", formatLlama3},
+	{"respond with a JSON for a function call", formatLlama3},
+	{"[func_name1(", formatPythonic},
+}
+
+// architectureFormats are the tool call formats of model architectures whose
+// chat templates aren't known.
+var architectureFormats = map[string]format{
+	"Qwen2ForCausalLM":                 formatHermes,
+	"Qwen2MoeForCausalLM":              formatHermes,
+	"Qwen3ForCausalLM":                 formatHermes,
+	"Qwen3MoeForCausalLM":              formatHermes,
+	"MistralForCausalLM":               formatMistral,
+	"Mistral3ForConditionalGeneration": formatMistral,
+	"DeepseekV3ForCausalLM":            formatDeepSeek,
+	"GraniteForCausalLM":               formatGranite,
+	"GptOssForCausalLM":                formatGPTOSS,
+	"Glm4MoeForCausalLM":               formatGLM45,
+}
+
+// vllmParsers are the names of vLLM's tool call parsers for each format.
+var vllmParsers = map[format]string{
+	formatHermes:   "hermes",
+	formatLlama3:   "llama3_json",
+	formatMistral:  "mistral",
+	formatDeepSeek: "deepseek_v3",
+	formatGranite:  "granite",
+	formatInternLM: "internlm",
+	formatPhi4Mini: "phi4_mini_json",
+	formatGPTOSS:   "openai",
+	formatKimiK2:   "kimi_k2",
+	formatGLM45:    "glm45",
+	formatPythonic: "pythonic",
+}
+
+// sglangParsers are the names of SGLang's tool call parsers for each format.
+var sglangParsers = map[format]string{
+	formatHermes:   "qwen25",
+	formatLlama3:   "llama3",
+	formatMistral:  "mistral",
+	formatDeepSeek: "deepseekv3",
+	formatGPTOSS:   "gpt-oss",
+	formatKimiK2:   "kimi_k2",
+	formatGLM45:    "glm45",
+	formatPythonic: "pythonic",
+}
+
+// VLLMParser returns the name of vLLM's tool call parser for the model in a
+// bundle, or an empty string if its tool call format isn't known.
+func VLLMParser(bundle types.ModelBundle) string {
+	return vllmParsers[detectFormat(bundle.RuntimeConfig().Architecture, chatTemplate(bundle))]
+}
+
+// SGLangParser returns the name of SGLang's tool call parser for the model in
+// a bundle, or an empty string if its tool call format isn't known.
+func SGLangParser(bundle types.ModelBundle) string {
+	return sglangParsers[detectFormat(bundle.RuntimeConfig().Architecture, chatTemplate(bundle))]
+}
+
+// HasParser returns true if the runtime flags of a backend configuration,
+// which may be nil, select a tool call parser.
+func HasParser(config *inference.BackendConfiguration) bool {
+	if config == nil {
+		return false
+	}
+	for _, flag := range config.RuntimeFlags {
+		if name, _, _ := strings.Cut(flag, "="); name == ParserFlag {
+			return true
+		}
+	}
+	return false
+}
+
+// detectFormat detects the tool call format of a model from its chat
+// template, falling back to its architecture.
+func detectFormat(architecture, template string) format {
+	for _, m := range templateMarkers {
+		if strings.Contains(template, m.marker) {
+			return m.format
+		}
+	}
+	return architectureFormats[architecture]
+}
+
+// chatTemplate returns the chat template of the model in a bundle: the
+// template packaged with the model or, for safetensors models, the template
+// in their tokenizer configuration. It returns an empty string if the model
+// has none.
+func chatTemplate(bundle types.ModelBundle) string {
+	if path := bundle.ChatTemplatePath(); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			return string(data)
+		}
+	}
+	path := bundle.SafetensorsPath()
+	if path == "" {
+		return ""
+	}
+	dir := filepath.Dir(path)
+	if data, err := os.ReadFile(filepath.Join(dir, "chat_template.jinja")); err == nil {
+		return string(data)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "tokenizer_config.json"))
+	if err != nil {
+		return ""
+	}
+	var config struct {
+		ChatTemplate json.RawMessage `json:"chat_template"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ""
+	}
+	// The template is either a string or a list of named templates.
+	var template string
+	if err := json.Unmarshal(config.ChatTemplate, &template); err == nil {
+		return template
+	}
+	var templates []struct {
+		Template string `json:"template"`
+	}
+	json.Unmarshal(config.ChatTemplate, &templates)
+	var all []string
+	for _, t := range templates {
+		all = append(all, t.Template)
+	}
+	return strings.Join(all, "\n")
+}
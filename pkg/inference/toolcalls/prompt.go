@@ -0,0 +1,355 @@
+package toolcalls
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// callStart and callEnd delimit the calls of prompted models, which is
+	// the format that most models are trained on.
+	callStart = "<tool_call>"
+	callEnd   = "</tool_call>"
+	// responseStart and responseEnd delimit the results of calls in the
+	// prompts of prompted models.
+	responseStart = "<tool_response>"
+	responseEnd   = "</tool_response>"
+)
+
+// Prompt translates a chat completion request for a server that doesn't parse
+// tool calls, such as llama.cpp without Jinja chat templates, into one whose
+// prompt describes the tools. Previous calls and their results are written
+// into the conversation as text, and models that must call a function are
+// constrained to a JSON call by a JSON schema, which servers enforce with a
+// grammar. The model's calls are extracted from its output by a Parser.
+func (r *Request) Prompt(body []byte) ([]byte, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	var messages []map[string]json.RawMessage
+	if err := json.Unmarshal(request["messages"], &messages); err != nil {
+		return nil, fmt.Errorf("%w: invalid messages: %v", ErrInvalidRequest, err)
+	}
+	for _, field := range []string{"tools", "tool_choice", "parallel_tool_calls"} {
+		delete(request, field)
+	}
+
+	for _, message := range messages {
+		if err := writeCalls(message); err != nil {
+			return nil, err
+		}
+	}
+	if r.Choice != ChoiceNone {
+		prompt, err := r.systemPrompt()
+		if err != nil {
+			return nil, err
+		}
+		if messages, err = addSystemPrompt(messages, prompt); err != nil {
+			return nil, err
+		}
+	}
+	if r.Required() {
+		if raw, ok := request["response_format"]; ok && !isNull(raw) {
+			return nil, fmt.Errorf("%w: response_format can't be combined with a required tool_choice for this model", ErrInvalidRequest)
+		}
+		format, err := r.responseFormat()
+		if err != nil {
+			return nil, err
+		}
+		request["response_format"] = format
+	}
+
+	var err error
+	if request["messages"], err = json.Marshal(messages); err != nil {
+		return nil, err
+	}
+	return json.Marshal(request)
+}
+
+// systemPrompt returns the prompt that describes the functions to the model.
+func (r *Request) systemPrompt() (string, error) {
+	var prompt strings.Builder
+	prompt.WriteString("You can call functions to help answer the user. The functions are described by the following JSON objects:\n")
+	for _, function := range r.Functions {
+		if r.Function != "" && function.Name != r.Function {
+			continue
+		}
+		description, err := json.Marshal(function)
+		if err != nil {
+			return "", err
+		}
+		prompt.Write(description)
+		prompt.WriteString("\n")
+	}
+	switch {
+	case r.Function != "":
+		fmt.Fprintf(&prompt, "\nCall the %s function by replying with a JSON object with its name and arguments.", r.Function)
+	case r.Required():
+		prompt.WriteString("\nCall a function by replying with a JSON object with its name and arguments.")
+	default:
+		prompt.WriteString("\nTo call a function, reply with a JSON object with its name and arguments within " + callStart + callEnd + " tags, for example:\n")
+		prompt.WriteString(callStart + "\n{\"name\": \"function_name\", \"arguments\": {\"argument\": \"value\"}}\n" + callEnd + "\n")
+		if !r.Parallel {
+			prompt.WriteString("Call at most one function at a time. ")
+		}
+		prompt.WriteString("The results of calls are returned within " + responseStart + responseEnd + " tags. If no function is needed, reply directly.")
+	}
+	return prompt.String(), nil
+}
+
+// responseFormat returns the JSON schema response format that constrains the
+// model to a call of one of the functions that it must call.
+func (r *Request) responseFormat() (json.RawMessage, error) {
+	var calls []map[string]any
+	for _, function := range r.Functions {
+		if r.Function != "" && function.Name != r.Function {
+			continue
+		}
+		arguments := function.Parameters
+		if len(arguments) == 0 || isNull(arguments) {
+			arguments = json.RawMessage(`{"type":"object"}`)
+		}
+		calls = append(calls, map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"name":      map[string]string{"const": function.Name},
+				"arguments": arguments,
+			},
+			"required": []string{"name", "arguments"},
+		})
+	}
+	schema := calls[0]
+	if len(calls) > 1 {
+		schema = map[string]any{"anyOf": calls}
+	}
+	return json.Marshal(map[string]any{
+		"type": "json_schema",
+		"json_schema": map[string]any{
+			"name":   "tool_call",
+			"schema": schema,
+		},
+	})
+}
+
+// writeCalls writes the tool calls of an assistant message into its content,
+// and the result of a call in a tool message into the content of a user
+// message, since the chat templates of prompted models may not support them.
+func writeCalls(message map[string]json.RawMessage) error {
+	var role string
+	json.Unmarshal(message["role"], &role)
+	switch role {
+	case "assistant":
+		var calls []struct {
+			Function struct {
+				Name      string          `json:"name"`
+				Arguments json.RawMessage `json:"arguments"`
+			} `json:"function"`
+		}
+		if raw, ok := message["tool_calls"]; !ok || isNull(raw) {
+			return nil
+		} else if err := json.Unmarshal(raw, &calls); err != nil {
+			return fmt.Errorf("%w: invalid tool_calls: %v", ErrInvalidRequest, err)
+		}
+		content, err := text(message["content"])
+		if err != nil {
+			return err
+		}
+		for _, call := range calls {
+			arguments := call.Function.Arguments
+			var encoded string
+			if json.Unmarshal(arguments, &encoded) == nil && json.Valid([]byte(encoded)) {
+				arguments = json.RawMessage(encoded)
+			}
+			if len(arguments) == 0 {
+				arguments = json.RawMessage("{}")
+			}
+			written, err := json.Marshal(map[string]any{"name": call.Function.Name, "arguments": arguments})
+			if err != nil {
+				return fmt.Errorf("%w: invalid arguments of a call to %s", ErrInvalidRequest, call.Function.Name)
+			}
+			content += "\n" + callStart + "\n" + string(written) + "\n" + callEnd
+		}
+		message["content"], _ = json.Marshal(strings.TrimPrefix(content, "\n"))
+		delete(message, "tool_calls")
+	case "tool", "function":
+		content, err := text(message["content"])
+		if err != nil {
+			return err
+		}
+		message["role"] = json.RawMessage(`"user"`)
+		message["content"], _ = json.Marshal(responseStart + "\n" + content + "\n" + responseEnd)
+		delete(message, "tool_call_id")
+		delete(message, "name")
+	}
+	return nil
+}
+
+// addSystemPrompt adds a prompt to the system message of a conversation,
+// adding one if it has none.
+func addSystemPrompt(messages []map[string]json.RawMessage, prompt string) ([]map[string]json.RawMessage, error) {
+	if len(messages) > 0 {
+		var role string
+		json.Unmarshal(messages[0]["role"], &role)
+		if role == "system" || role == "developer" {
+			content, err := text(messages[0]["content"])
+			if err != nil {
+				return nil, err
+			}
+			messages[0]["content"], _ = json.Marshal(content + "\n\n" + prompt)
+			return messages, nil
+		}
+	}
+	system := map[string]json.RawMessage{"role": json.RawMessage(`"system"`)}
+	system["content"], _ = json.Marshal(prompt)
+	return append([]map[string]json.RawMessage{system}, messages...), nil
+}
+
+// text returns the text of message content, which is either a string or an
+// array of text parts.
+func text(content json.RawMessage) (string, error) {
+	if len(content) == 0 || isNull(content) {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(content, &s); err == nil {
+		return s, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return "", fmt.Errorf("%w: invalid message content: %v", ErrInvalidRequest, err)
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type != "text" {
+			return "", fmt.Errorf("%w: messages of tool calling conversations only support text content for this model, not %q", ErrInvalidRequest, part.Type)
+		}
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// Call is a function call extracted from the output of a prompted model.
+type Call struct {
+	// Name is the name of the function.
+	Name string
+	// Arguments are the JSON-encoded arguments of the call.
+	Arguments string
+}
+
+// Parser extracts the function calls of a model prompted by Request.Prompt
+// from its output as it's generated. Calls are delimited by tags, unless the
+// model must call a function, in which case its whole output is one call.
+type Parser struct {
+	// whole indicates whether the whole output is a call.
+	whole bool
+	// buffer is the output that hasn't been returned yet.
+	buffer string
+	// inCall indicates whether the buffer is the inside of a call.
+	inCall bool
+}
+
+// NewParser creates a parser for the output of a model prompted with the
+// request.
+func (r *Request) NewParser() *Parser {
+	return &Parser{whole: r.Required()}
+}
+
+// Write consumes generated text, returning the text that isn't part of a call
+// and the calls that it completes. Text that may start a call is held back
+// until it's known not to.
+func (p *Parser) Write(s string) (string, []Call) {
+	p.buffer += s
+	if p.whole {
+		return "", nil
+	}
+	var content strings.Builder
+	var calls []Call
+	for {
+		if !p.inCall {
+			if i := strings.Index(p.buffer, callStart); i >= 0 {
+				content.WriteString(p.buffer[:i])
+				p.buffer = p.buffer[i+len(callStart):]
+				p.inCall = true
+				continue
+			}
+			held := partialPrefix(p.buffer, callStart)
+			content.WriteString(p.buffer[:len(p.buffer)-held])
+			p.buffer = p.buffer[len(p.buffer)-held:]
+			break
+		}
+		i := strings.Index(p.buffer, callEnd)
+		if i < 0 {
+			break
+		}
+		if call, ok := parseCall(p.buffer[:i]); ok {
+			calls = append(calls, call)
+		} else {
+			content.WriteString(callStart + p.buffer[:i+len(callEnd)])
+		}
+		p.buffer = p.buffer[i+len(callEnd):]
+		p.inCall = false
+	}
+	return content.String(), calls
+}
+
+// Close returns the remaining text and calls once generation is complete.
+// Calls that aren't closed, because generation stopped at their end tag, are
+// still extracted.
+func (p *Parser) Close() (string, []Call) {
+	buffer := p.buffer
+	p.buffer = ""
+	if !p.whole && !p.inCall {
+		return buffer, nil
+	}
+	if call, ok := parseCall(buffer); ok {
+		return "", []Call{call}
+	}
+	if p.inCall {
+		buffer = callStart + buffer
+	}
+	return buffer, nil
+}
+
+// parseCall parses a JSON call with a name and arguments, which are also
+// accepted as an encoded string or as parameters.
+func parseCall(s string) (Call, bool) {
+	var call struct {
+		Name       string          `json:"name"`
+		Arguments  json.RawMessage `json:"arguments"`
+		Parameters json.RawMessage `json:"parameters"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(s)), &call); err != nil || call.Name == "" {
+		return Call{}, false
+	}
+	arguments := call.Arguments
+	if len(arguments) == 0 {
+		arguments = call.Parameters
+	}
+	var encoded string
+	if len(arguments) == 0 || isNull(arguments) {
+		encoded = "{}"
+	} else if err := json.Unmarshal(arguments, &encoded); err != nil {
+		compact, err := json.Marshal(arguments)
+		if err != nil {
+			return Call{}, false
+		}
+		encoded = string(compact)
+	}
+	return Call{Name: call.Name, Arguments: encoded}, true
+}
+
+// partialPrefix returns the length of the longest suffix of s that's a proper
+// prefix of tag.
+func partialPrefix(s, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,191 @@
+// Package toolcalls normalizes OpenAI tool calling across inference backends.
+// Requests are validated and translated from the legacy functions API, servers
+// that don't parse tool calls are prompted with the tools instead, and the
+// servers that do are configured with the tool call parser of the model.
+package toolcalls
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidRequest indicates that the tools of a request are invalid.
+var ErrInvalidRequest = errors.New("invalid tool calling request")
+
+// Tool choices.
+const (
+	ChoiceAuto     = "auto"
+	ChoiceNone     = "none"
+	ChoiceRequired = "required"
+)
+
+// Function is a function that the model may call.
+type Function struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// Request describes the tools of a chat completion request.
+type Request struct {
+	// Functions are the functions that the model may call.
+	Functions []Function
+	// Choice is ChoiceAuto, ChoiceNone, or ChoiceRequired.
+	Choice string
+	// Function is the name of the function that the model must call, if the
+	// request names one.
+	Function string
+	// Parallel indicates whether the model may call several functions at once.
+	Parallel bool
+}
+
+// Required returns true if the model must call a function.
+func (r *Request) Required() bool {
+	return r.Choice == ChoiceRequired || r.Function != ""
+}
+
+// Parse validates the tools of a chat completion request body. Requests using
+// the legacy functions and function_call fields are translated to tools and
+// tool_choice. It returns the body to send upstream and the request's tools,
+// which are nil if it has none. Bodies that aren't JSON objects are returned
+// as-is, for the backend to reject.
+func Parse(body []byte) ([]byte, *Request, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return body, nil, nil
+	}
+
+	translated, err := translateLegacy(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	if translated {
+		if body, err = json.Marshal(request); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var tools []struct {
+		Type     string    `json:"type"`
+		Function *Function `json:"function"`
+	}
+	if raw, ok := request["tools"]; !ok || isNull(raw) {
+		return body, nil, nil
+	} else if err := json.Unmarshal(raw, &tools); err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid tools: %v", ErrInvalidRequest, err)
+	}
+	if len(tools) == 0 {
+		return body, nil, nil
+	}
+
+	tc := &Request{Choice: ChoiceAuto, Parallel: true}
+	for _, tool := range tools {
+		if tool.Type != "function" {
+			return nil, nil, fmt.Errorf("%w: unsupported tool type %q", ErrInvalidRequest, tool.Type)
+		}
+		if tool.Function == nil || tool.Function.Name == "" {
+			return nil, nil, fmt.Errorf("%w: tools must have a function name", ErrInvalidRequest)
+		}
+		tc.Functions = append(tc.Functions, *tool.Function)
+	}
+	if err := tc.parseChoice(request["tool_choice"]); err != nil {
+		return nil, nil, err
+	}
+	if raw, ok := request["parallel_tool_calls"]; ok && !isNull(raw) {
+		if err := json.Unmarshal(raw, &tc.Parallel); err != nil {
+			return nil, nil, fmt.Errorf("%w: parallel_tool_calls must be a boolean", ErrInvalidRequest)
+		}
+	}
+	return body, tc, nil
+}
+
+// parseChoice parses the tool_choice of a request, which is a choice or
+// names a function.
+func (r *Request) parseChoice(raw json.RawMessage) error {
+	if len(raw) == 0 || isNull(raw) {
+		return nil
+	}
+	var choice string
+	if err := json.Unmarshal(raw, &choice); err == nil {
+		switch choice {
+		case ChoiceAuto, ChoiceNone, ChoiceRequired:
+			r.Choice = choice
+			return nil
+		}
+		return fmt.Errorf("%w: unsupported tool_choice %q", ErrInvalidRequest, choice)
+	}
+	var named struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Type != "function" || named.Function.Name == "" {
+		return fmt.Errorf("%w: tool_choice must be auto, none, required, or name a function", ErrInvalidRequest)
+	}
+	for _, function := range r.Functions {
+		if function.Name == named.Function.Name {
+			r.Function = function.Name
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: tool_choice names unknown function %q", ErrInvalidRequest, named.Function.Name)
+}
+
+// translateLegacy replaces the legacy functions and function_call fields of a
+// request with tools and tool_choice, returning true if it had any.
+func translateLegacy(request map[string]json.RawMessage) (bool, error) {
+	functions, hasFunctions := request["functions"]
+	functionCall, hasFunctionCall := request["function_call"]
+	if !hasFunctions && !hasFunctionCall {
+		return false, nil
+	}
+	delete(request, "functions")
+	delete(request, "function_call")
+
+	if _, ok := request["tools"]; hasFunctions && !ok && !isNull(functions) {
+		var definitions []json.RawMessage
+		if err := json.Unmarshal(functions, &definitions); err != nil {
+			return false, fmt.Errorf("%w: invalid functions: %v", ErrInvalidRequest, err)
+		}
+		tools := make([]map[string]json.RawMessage, 0, len(definitions))
+		for _, definition := range definitions {
+			tools = append(tools, map[string]json.RawMessage{"type": json.RawMessage(`"function"`), "function": definition})
+		}
+		request["tools"], _ = json.Marshal(tools)
+	}
+
+	if _, ok := request["tool_choice"]; hasFunctionCall && !ok && !isNull(functionCall) {
+		var choice string
+		if err := json.Unmarshal(functionCall, &choice); err == nil {
+			request["tool_choice"] = functionCall
+			return true, nil
+		}
+		var named struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(functionCall, &named); err != nil {
+			return false, fmt.Errorf("%w: invalid function_call: %v", ErrInvalidRequest, err)
+		}
+		request["tool_choice"], _ = json.Marshal(map[string]any{
+			"type":     "function",
+			"function": map[string]string{"name": named.Name},
+		})
+	}
+	return true, nil
+}
+
+// isNull returns true if a raw JSON value is null.
+func isNull(raw json.RawMessage) bool {
+	return string(raw) == "null"
+}
+
+// NewCallID generates a random tool call ID.
+func NewCallID() string {
+	id := make([]byte, 12)
+	rand.Read(id)
+	return "call_" + hex.EncodeToString(id)
+}
@@ -0,0 +1,221 @@
+package toolcalls
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+func TestParse(t *testing.T) {
+	body := `{"model": "m", "messages": [], "tools": [{"type": "function", "function": {"name": "weather", "parameters": {"type": "object"}}}], "tool_choice": {"type": "function", "function": {"name": "weather"}}, "parallel_tool_calls": false}`
+	translated, request, err := Parse([]byte(body))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(translated) != body {
+		t.Errorf("Expected the body to be unchanged, got %s", translated)
+	}
+	if len(request.Functions) != 1 || request.Function != "weather" || request.Parallel || !request.Required() {
+		t.Errorf("Unexpected request %+v", request)
+	}
+
+	// Requests without tools aren't translated.
+	if _, request, err := Parse([]byte(`{"model": "m", "tools": []}`)); err != nil || request != nil {
+		t.Errorf("Expected no tools, got %+v, %v", request, err)
+	}
+}
+
+func TestParseLegacy(t *testing.T) {
+	translated, request, err := Parse([]byte(`{"model": "m", "functions": [{"name": "weather"}], "function_call": {"name": "weather"}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(translated, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if string(fields["tools"]) != `[{"function":{"name":"weather"},"type":"function"}]` {
+		t.Errorf("Unexpected tools %s", fields["tools"])
+	}
+	if string(fields["tool_choice"]) != `{"function":{"name":"weather"},"type":"function"}` {
+		t.Errorf("Unexpected tool choice %s", fields["tool_choice"])
+	}
+	if _, ok := fields["functions"]; ok {
+		t.Errorf("Expected the legacy fields to be removed, got %s", translated)
+	}
+	if request.Function != "weather" {
+		t.Errorf("Unexpected request %+v", request)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"unsupported tool":      `{"tools": [{"type": "web_search"}]}`,
+		"missing name":          `{"tools": [{"type": "function", "function": {}}]}`,
+		"unsupported choice":    `{"tools": [{"type": "function", "function": {"name": "f"}}], "tool_choice": "any"}`,
+		"unknown function":      `{"tools": [{"type": "function", "function": {"name": "f"}}], "tool_choice": {"type": "function", "function": {"name": "g"}}}`,
+		"invalid parallel":      `{"tools": [{"type": "function", "function": {"name": "f"}}], "parallel_tool_calls": "yes"}`,
+		"invalid functions":     `{"functions": {"name": "f"}}`,
+		"invalid legacy choice": `{"functions": [{"name": "f"}], "function_call": 1}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := Parse([]byte(body)); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected an invalid request error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	request := &Request{
+		Functions: []Function{{Name: "weather", Parameters: json.RawMessage(`{"type":"object"}`)}},
+		Choice:    ChoiceAuto,
+		Parallel:  true,
+	}
+	body := `{
+		"model": "m",
+		"messages": [
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": "Weather in Paris?"},
+			{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "sunny"}
+		],
+		"tools": [],
+		"tool_choice": "auto"
+	}`
+	prompted, err := request.Prompt([]byte(body))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var translated struct {
+		Messages []map[string]any `json:"messages"`
+		Tools    any              `json:"tools"`
+		Format   any              `json:"response_format"`
+	}
+	if err := json.Unmarshal(prompted, &translated); err != nil {
+		t.Fatal(err)
+	}
+	if translated.Tools != nil || translated.Format != nil {
+		t.Errorf("Expected the tools to be removed without a response format, got %s", prompted)
+	}
+	messages := translated.Messages
+	if len(messages) != 4 {
+		t.Fatalf("Unexpected messages %s", prompted)
+	}
+	if system := messages[0]["content"].(string); !strings.HasPrefix(system, "Be brief.\n\n") || !strings.Contains(system, `{"name":"weather","parameters":{"type":"object"}}`) || !strings.Contains(system, callStart) {
+		t.Errorf("Expected the tools to be described in the system prompt, got %q", system)
+	}
+	if call := messages[2]["content"]; call != "<tool_call>\n{\"arguments\":{\"city\":\"Paris\"},\"name\":\"weather\"}\n</tool_call>" {
+		t.Errorf("Expected the call to be written into the assistant message, got %q", call)
+	}
+	if messages[3]["role"] != "user" || messages[3]["content"] != "<tool_response>\nsunny\n</tool_response>" || messages[3]["tool_call_id"] != nil {
+		t.Errorf("Expected the result to be written into a user message, got %v", messages[3])
+	}
+
+	// Models that must call a function are constrained to a call.
+	request.Function = "weather"
+	prompted, err = request.Prompt([]byte(`{"messages": [{"role": "user", "content": "Hi"}]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := json.Unmarshal(prompted, &translated); err != nil {
+		t.Fatal(err)
+	}
+	format, _ := json.Marshal(translated.Format)
+	expected := `{"json_schema":{"name":"tool_call","schema":{"properties":{"arguments":{"type":"object"},"name":{"const":"weather"}},"required":["name","arguments"],"type":"object"}},"type":"json_schema"}`
+	if string(format) != expected {
+		t.Errorf("Expected response format %s, got %s", expected, format)
+	}
+	if translated.Messages[0]["role"] != "system" {
+		t.Errorf("Expected a system prompt to be added, got %v", translated.Messages[0])
+	}
+	if _, err := request.Prompt([]byte(`{"messages": [], "response_format": {"type": "json_object"}}`)); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Expected a response format to be rejected, got %v", err)
+	}
+}
+
+func TestParser(t *testing.T) {
+	parser := (&Request{Choice: ChoiceAuto}).NewParser()
+	var content string
+	var calls []Call
+	for _, s := range []string{"Let me", " check.<to", "ol_call>\n{\"name\": \"weather\", ", "\"arguments\": {\"city\": \"Paris\"}}\n</tool_call>", "<tool_call>{\"name\": \"time\"}"} {
+		text, completed := parser.Write(s)
+		content += text
+		calls = append(calls, completed...)
+		if strings.Contains(text, "<") {
+			t.Errorf("Expected a partial tag to be held back, got %q", text)
+		}
+	}
+	text, completed := parser.Close()
+	content += text
+	calls = append(calls, completed...)
+	if content != "Let me check." {
+		t.Errorf("Unexpected content %q", content)
+	}
+	expected := []Call{{Name: "weather", Arguments: `{"city":"Paris"}`}, {Name: "time", Arguments: "{}"}}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected calls %+v, got %+v", expected, calls)
+	}
+
+	// Text that isn't a call is returned as-is.
+	parser = (&Request{Choice: ChoiceAuto}).NewParser()
+	if text, calls := parser.Write("a <tool_call>not json</tool_call> <tool"); text != "a <tool_call>not json</tool_call> " || len(calls) != 0 {
+		t.Errorf("Unexpected output %q, %+v", text, calls)
+	}
+	if text, _ := parser.Close(); text != "<tool" {
+		t.Errorf("Expected the held back text, got %q", text)
+	}
+
+	// The whole output of models that must call a function is a call.
+	parser = (&Request{Choice: ChoiceRequired}).NewParser()
+	parser.Write(`{"name": "weather", `)
+	parser.Write(`"arguments": "{\"city\":\"Paris\"}"}`)
+	if text, calls := parser.Close(); text != "" || !reflect.DeepEqual(calls, []Call{{Name: "weather", Arguments: `{"city":"Paris"}`}}) {
+		t.Errorf("Unexpected output %q, %+v", text, calls)
+	}
+}
+
+// bundle is a model bundle for parser selection.
+type bundle struct {
+	types.ModelBundle
+	dir    string
+	config types.Config
+}
+
+func (b *bundle) ChatTemplatePath() string    { return "" }
+func (b *bundle) SafetensorsPath() string     { return filepath.Join(b.dir, "model.safetensors") }
+func (b *bundle) RuntimeConfig() types.Config { return b.config }
+
+func TestParsers(t *testing.T) {
+	dir := t.TempDir()
+	b := &bundle{dir: dir, config: types.Config{Architecture: "LlamaForCausalLM"}}
+	if parser := VLLMParser(b); parser != "" {
+		t.Errorf("Expected no parser for a Llama model without a template, got %q", parser)
+	}
+
+	tokenizer := `{"chat_template": [{"name": "default", "template": "{%- if tools %}<|python_tag|>{%- endif %}"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "tokenizer_config.json"), []byte(tokenizer), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if vllm, sglang := VLLMParser(b), SGLangParser(b); vllm != "llama3_json" || sglang != "llama3" {
+		t.Errorf("Expected the Llama 3 parsers, got %q and %q", vllm, sglang)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "chat_template.jinja"), []byte("<|tool_call|>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if vllm, sglang := VLLMParser(b), SGLangParser(b); vllm != "granite" || sglang != "" {
+		t.Errorf("Expected only vLLM to have a Granite parser, got %q and %q", vllm, sglang)
+	}
+
+	if !HasParser(&inference.BackendConfiguration{RuntimeFlags: []string{"--tool-call-parser=hermes"}}) || HasParser(nil) {
+		t.Error("Expected only configured parsers to be detected")
+	}
+}
@@ -0,0 +1,74 @@
+// Package relay implements the helpers shared by the handlers that serve
+// their requests by sending requests to the inference API, such as the
+// moderations, Responses API, and WebSocket chat handlers.
+package relay
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/inference"
+)
+
+// ChatCompletionsPath returns the path of the chat completions endpoint that
+// serves a request to the endpoint at the end of path, such as "/moderations",
+// for the same backend if one is set.
+func ChatCompletionsPath(path, endpoint string) string {
+	path = strings.TrimSuffix(path, endpoint) + "/chat/completions"
+	if !strings.HasPrefix(path, inference.InferencePrefix+"/") {
+		path = inference.InferencePrefix + path
+	}
+	return path
+}
+
+// Recorder records a response of the inference API.
+type Recorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// NewRecorder creates a new response recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{header: make(http.Header), status: http.StatusOK}
+}
+
+// Header implements net/http.ResponseWriter.Header.
+func (r *Recorder) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader implements net/http.ResponseWriter.WriteHeader.
+func (r *Recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+}
+
+// Write implements net/http.ResponseWriter.Write.
+func (r *Recorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(data)
+}
+
+// Status returns the status of the recorded response.
+func (r *Recorder) Status() int {
+	return r.status
+}
+
+// Body returns the body of the recorded response.
+func (r *Recorder) Body() []byte {
+	return r.body.Bytes()
+}
+
+// Replay writes the recorded response, including its headers, to w.
+func (r *Recorder) Replay(w http.ResponseWriter) {
+	for key, values := range r.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(r.status)
+	w.Write(r.body.Bytes())
+}
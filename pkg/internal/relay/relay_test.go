@@ -0,0 +1,41 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChatCompletionsPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		endpoint string
+		expected string
+	}{
+		{path: "/v1/moderations", endpoint: "/moderations", expected: "/engines/v1/chat/completions"},
+		{path: "/engines/v1/responses", endpoint: "/responses", expected: "/engines/v1/chat/completions"},
+		{path: "/engines/llama.cpp/v1/responses", endpoint: "/responses", expected: "/engines/llama.cpp/v1/chat/completions"},
+		{path: "/engines/vllm/v1/chat/ws", endpoint: "/chat/ws", expected: "/engines/vllm/v1/chat/completions"},
+	}
+
+	for _, tt := range tests {
+		if path := ChatCompletionsPath(tt.path, tt.endpoint); path != tt.expected {
+			t.Errorf("Expected %q for %q, got %q", tt.expected, tt.path, path)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder()
+	http.Error(recorder, "model not found", http.StatusNotFound)
+	recorder.WriteHeader(http.StatusOK)
+	if recorder.Status() != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, recorder.Status())
+	}
+
+	w := httptest.NewRecorder()
+	recorder.Replay(w)
+	if w.Code != http.StatusNotFound || w.Body.String() != "model not found\n" || w.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("Expected the recorded response, got %d %q with headers %v", w.Code, w.Body.String(), w.Header())
+	}
+}
@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"syscall"
+)
+
+// DiskFullWriter wraps a log writer so that running out of disk space
+// degrades logging instead of failing the writer's callers: writes that fail
+// because the disk is full are dropped and reported as successful. The first
+// dropped write of each outage is passed to report, and writes resume once
+// space is freed.
+type DiskFullWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	report func(error)
+	full   bool
+}
+
+// NewDiskFullWriter creates a new DiskFullWriter writing to w.
+func NewDiskFullWriter(w io.Writer, report func(error)) *DiskFullWriter {
+	return &DiskFullWriter{w: w, report: report}
+}
+
+// Write implements io.Writer.Write.
+func (d *DiskFullWriter) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	if !errors.Is(err, syscall.ENOSPC) {
+		if err == nil {
+			d.mu.Lock()
+			d.full = false
+			d.mu.Unlock()
+		}
+		return n, err
+	}
+	d.mu.Lock()
+	first := !d.full
+	d.full = true
+	d.mu.Unlock()
+	if first {
+		d.report(err)
+	}
+	return len(p), nil
+}
+
+// Close closes the wrapped writer, if it's an io.Closer.
+func (d *DiskFullWriter) Close() error {
+	if c, ok := d.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"fmt"
+	"syscall"
+	"testing"
+)
+
+// fullDisk is a writer that fails with ENOSPC while full is set.
+type fullDisk struct {
+	full    bool
+	written []byte
+}
+
+func (f *fullDisk) Write(p []byte) (int, error) {
+	if f.full {
+		return 0, fmt.Errorf("write log: %w", syscall.ENOSPC)
+	}
+	f.written = append(f.written, p...)
+	return len(p), nil
+}
+
+func TestDiskFullWriter(t *testing.T) {
+	disk := &fullDisk{}
+	var reports int
+	w := NewDiskFullWriter(disk, func(error) { reports++ })
+	write := func(s string) {
+		t.Helper()
+		if n, err := w.Write([]byte(s)); err != nil || n != len(s) {
+			t.Fatalf("Expected the write to succeed, got %d, %v", n, err)
+		}
+	}
+
+	write("a")
+	// Writes are dropped while the disk is full, and each outage is only
+	// reported once.
+	disk.full = true
+	write("b")
+	write("c")
+	disk.full = false
+	write("d")
+	disk.full = true
+	write("e")
+	disk.full = false
+	write("f")
+	if string(disk.written) != "adf" || reports != 2 {
+		t.Errorf("Expected \"adf\" to be written with 2 reports, got %q with %d", disk.written, reports)
+	}
+}
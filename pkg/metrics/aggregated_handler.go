@@ -38,15 +38,20 @@ func (h *AggregatedMetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Requ
 	}
 
 	runners := h.scheduler.GetAllActiveRunners()
-	if len(runners) == 0 {
+	serverFamilies := h.scheduler.GetServerMetrics()
+	if len(runners) == 0 && len(serverFamilies) == 0 {
 		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "# No active runners\n")
 		return
 	}
 
-	// Collect and aggregate metrics from all runners
+	// Collect and aggregate metrics from all runners, along with those of the
+	// requests served by the model runner itself
 	allFamilies := h.collectAndAggregateMetrics(r.Context(), runners)
+	for _, family := range serverFamilies {
+		allFamilies[family.GetName()] = family
+	}
 
 	// Write aggregated response using Prometheus encoder
 	h.writeAggregatedMetrics(w, allFamilies)
@@ -69,6 +74,10 @@ func (h *AggregatedMetricsHandler) collectAndAggregateMetrics(ctx context.Contex
 				return
 			}
 
+			// Expose engine metrics such as KV-cache usage and queue depth
+			// under common names, regardless of the backend
+			deriveEngineMetrics(families)
+
 			// Add labels to metrics and merge into allFamilies
 			labels := map[string]string{
 				"backend": runner.BackendName,
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	dto "github.com/prometheus/client_model/go"
+)
+
+// engineMetric describes a normalized engine metric and the backend-specific
+// metric families it is derived from.
+type engineMetric struct {
+	// name is the name of the normalized metric family.
+	name string
+	// help is the help text of the normalized metric family.
+	help string
+	// sources are the backend metric families that the normalized metric is
+	// derived from, in order of preference. Only the first source present in a
+	// runner's metrics is used, so that engines exposing both an older and a
+	// newer name for the same series don't produce duplicates.
+	sources []string
+}
+
+// engineMetrics are the engine metrics that are exposed under common names for
+// all backends, in addition to the backend-specific metrics.
+var engineMetrics = []engineMetric{
+	{
+		name:    "model_runner_kv_cache_usage_ratio",
+		help:    "KV-cache usage of the inference engine. 1 means 100 percent usage.",
+		sources: []string{"vllm:kv_cache_usage_perc", "vllm:gpu_cache_usage_perc", "llamacpp:kv_cache_usage_ratio"},
+	},
+	{
+		name:    "model_runner_requests_running",
+		help:    "Number of requests currently being processed by the inference engine.",
+		sources: []string{"vllm:num_requests_running", "llamacpp:requests_processing"},
+	},
+	{
+		name:    "model_runner_requests_waiting",
+		help:    "Number of requests waiting to be processed by the inference engine.",
+		sources: []string{"vllm:num_requests_waiting", "llamacpp:requests_deferred"},
+	},
+	{
+		name:    "model_runner_prompt_tokens_total",
+		help:    "Number of prompt tokens processed by the inference engine.",
+		sources: []string{"vllm:prompt_tokens_total", "llamacpp:prompt_tokens_total"},
+	},
+	{
+		name:    "model_runner_generation_tokens_total",
+		help:    "Number of tokens generated by the inference engine.",
+		sources: []string{"vllm:generation_tokens_total", "llamacpp:tokens_predicted_total"},
+	},
+}
+
+// deriveEngineMetrics adds the normalized engine metrics to a runner's metric
+// families. The derived families hold copies of the source metrics so that
+// labels can subsequently be added to both independently.
+func deriveEngineMetrics(families map[string]*dto.MetricFamily) {
+	for _, em := range engineMetrics {
+		if _, exists := families[em.name]; exists {
+			continue
+		}
+		for _, source := range em.sources {
+			family, ok := families[source]
+			if !ok {
+				continue
+			}
+			derived := &dto.MetricFamily{
+				Name: stringPtr(em.name),
+				Help: stringPtr(em.help),
+				Type: family.Type,
+			}
+			for _, metric := range family.GetMetric() {
+				derived.Metric = append(derived.Metric, &dto.Metric{
+					Label:       append([]*dto.LabelPair(nil), metric.GetLabel()...),
+					Gauge:       metric.Gauge,
+					Counter:     metric.Counter,
+					Untyped:     metric.Untyped,
+					TimestampMs: metric.TimestampMs,
+				})
+			}
+			families[em.name] = derived
+			break
+		}
+	}
+}
+
+// stringPtr returns a pointer to s.
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+func TestDeriveEngineMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]float64
+	}{
+		{
+			name: "vLLM",
+			input: `# TYPE vllm:kv_cache_usage_perc gauge
+vllm:kv_cache_usage_perc{engine="0",model_name="ai/qwen3"} 0.25
+# TYPE vllm:num_requests_running gauge
+vllm:num_requests_running{engine="0",model_name="ai/qwen3"} 2
+# TYPE vllm:num_requests_waiting gauge
+vllm:num_requests_waiting{engine="0",model_name="ai/qwen3"} 3
+# TYPE vllm:prompt_tokens_total counter
+vllm:prompt_tokens_total{engine="0",model_name="ai/qwen3"} 100
+# TYPE vllm:generation_tokens_total counter
+vllm:generation_tokens_total{engine="0",model_name="ai/qwen3"} 50
+`,
+			expected: map[string]float64{
+				"model_runner_kv_cache_usage_ratio":    0.25,
+				"model_runner_requests_running":        2,
+				"model_runner_requests_waiting":        3,
+				"model_runner_prompt_tokens_total":     100,
+				"model_runner_generation_tokens_total": 50,
+			},
+		},
+		{
+			name: "vLLM prefers newer KV-cache metric",
+			input: `# TYPE vllm:gpu_cache_usage_perc gauge
+vllm:gpu_cache_usage_perc{model_name="ai/qwen3"} 0.5
+# TYPE vllm:kv_cache_usage_perc gauge
+vllm:kv_cache_usage_perc{model_name="ai/qwen3"} 0.75
+`,
+			expected: map[string]float64{
+				"model_runner_kv_cache_usage_ratio": 0.75,
+			},
+		},
+		{
+			name: "llama.cpp",
+			input: `# TYPE llamacpp:prompt_tokens_total counter
+llamacpp:prompt_tokens_total 10
+# TYPE llamacpp:tokens_predicted_total counter
+llamacpp:tokens_predicted_total 20
+# TYPE llamacpp:requests_processing gauge
+llamacpp:requests_processing 1
+# TYPE llamacpp:requests_deferred gauge
+llamacpp:requests_deferred 0
+`,
+			expected: map[string]float64{
+				"model_runner_requests_running":        1,
+				"model_runner_requests_waiting":        0,
+				"model_runner_prompt_tokens_total":     10,
+				"model_runner_generation_tokens_total": 20,
+			},
+		},
+		{
+			name: "unknown metrics",
+			input: `# TYPE process_cpu_seconds_total counter
+process_cpu_seconds_total 1
+`,
+			expected: map[string]float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := expfmt.NewTextParser(model.LegacyValidation)
+			families, err := parser.TextToMetricFamilies(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("failed to parse metrics: %v", err)
+			}
+			sourceCount := len(families)
+
+			deriveEngineMetrics(families)
+
+			if len(families) != sourceCount+len(tt.expected) {
+				t.Errorf("expected %d derived families, got %d", len(tt.expected), len(families)-sourceCount)
+			}
+			for name, want := range tt.expected {
+				family, ok := families[name]
+				if !ok {
+					t.Errorf("missing derived family %s", name)
+					continue
+				}
+				if len(family.GetMetric()) != 1 {
+					t.Fatalf("expected 1 metric in %s, got %d", name, len(family.GetMetric()))
+				}
+				metric := family.GetMetric()[0]
+				got := metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+				if got != want {
+					t.Errorf("%s = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDeriveEngineMetricsCopiesLabels(t *testing.T) {
+	parser := expfmt.NewTextParser(model.LegacyValidation)
+	families, err := parser.TextToMetricFamilies(strings.NewReader(`# TYPE vllm:num_requests_running gauge
+vllm:num_requests_running{model_name="ai/qwen3"} 2
+`))
+	if err != nil {
+		t.Fatalf("failed to parse metrics: %v", err)
+	}
+	deriveEngineMetrics(families)
+
+	h := &AggregatedMetricsHandler{}
+	all := make(map[string]*dto.MetricFamily)
+	h.addLabelsAndMerge(families, map[string]string{"backend": "vllm"}, all)
+
+	for _, name := range []string{"vllm:num_requests_running", "model_runner_requests_running"} {
+		labels := all[name].GetMetric()[0].GetLabel()
+		if len(labels) != 2 {
+			t.Errorf("%s: expected 2 labels, got %d", name, len(labels))
+		}
+	}
+}
@@ -220,7 +220,14 @@ func (r *OpenAIRecorder) SetConfigForModel(model string, config *inference.Backe
 		}
 	}
 
-	r.records[modelID].Config = *config
+	stored := *config
+	// Don't expose the credentials of remote models.
+	if stored.Remote != nil {
+		remote := *stored.Remote
+		remote.APIKey = ""
+		stored.Remote = &remote
+	}
+	r.records[modelID].Config = stored
 }
 
 func (r *OpenAIRecorder) RecordRequest(model string, req *http.Request, body []byte) string {
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/docker/model-runner/pkg/logging"
+	dto "github.com/prometheus/client_model/go"
 )
 
 // SchedulerMetricsHandler handles metrics requests by finding active llama.cpp runners
@@ -20,6 +21,7 @@ type SchedulerInterface interface {
 	GetRunningBackends(w http.ResponseWriter, r *http.Request)
 	GetLlamaCppSocket() (string, error)
 	GetAllActiveRunners() []ActiveRunner
+	GetServerMetrics() []*dto.MetricFamily
 }
 
 // ActiveRunner contains information about an active runner
@@ -0,0 +1,272 @@
+package metrics
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/middleware"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// ttftBuckets are the upper bounds of the buckets of the time to first token,
+// in seconds.
+var ttftBuckets = []float64{0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// tpotBuckets are the upper bounds of the buckets of the time per output
+// token, in seconds.
+var tpotBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.15, 0.25, 0.5, 1}
+
+// MemoryEstimate is the memory that a backend estimates that the runners of a
+// model require.
+type MemoryEstimate struct {
+	// Backend is the name of the backend.
+	Backend string
+	// Model is the model reference used to load the runners.
+	Model string
+	// RAM is the estimated RAM in bytes.
+	RAM uint64
+	// VRAM is the estimated VRAM in bytes.
+	VRAM uint64
+}
+
+// ServerMetrics records the metrics of the requests served by the model
+// runner itself, as opposed to those reported by its runners: request counts
+// by status, queue depths, model loads and unloads, token throughput, and the
+// latency of streamed generations, all labeled by model and backend.
+type ServerMetrics struct {
+	// lock protects the fields below.
+	lock sync.Mutex
+	// requests counts the requests by model, backend, and status code.
+	requests *vec
+	// queued is the number of queued requests by model and backend.
+	queued *vec
+	// loads counts the runners loaded by model and backend.
+	loads *vec
+	// unloads counts the runners unloaded by model, backend, and reason.
+	unloads *vec
+	// tokens counts the tokens by model, backend, and type.
+	tokens *vec
+	// ttft is the histogram of the time to first token by model and backend.
+	ttft *vec
+	// tpot is the histogram of the time per output token by model and
+	// backend.
+	tpot *vec
+}
+
+// NewServerMetrics creates a new, empty, set of server metrics.
+func NewServerMetrics() *ServerMetrics {
+	labels := []string{"model", "backend"}
+	return &ServerMetrics{
+		requests: newVec("model_runner_requests_total", "Number of inference requests served, by status code.", dto.MetricType_COUNTER, append(labels, "code"), nil),
+		queued:   newVec("model_runner_queue_depth", "Number of inference requests waiting for a request slot or for their model to load.", dto.MetricType_GAUGE, labels, nil),
+		loads:    newVec("model_runner_model_loads_total", "Number of runners loaded.", dto.MetricType_COUNTER, labels, nil),
+		unloads:  newVec("model_runner_model_unloads_total", "Number of runners unloaded, by reason: unload on request, or evict by the scheduler.", dto.MetricType_COUNTER, append(labels, "reason"), nil),
+		tokens:   newVec("model_runner_tokens_total", "Number of tokens processed, by type: prompt or completion.", dto.MetricType_COUNTER, append(labels, "type"), nil),
+		ttft:     newVec("model_runner_time_to_first_token_seconds", "Time from receiving a streamed request to streaming its first token.", dto.MetricType_HISTOGRAM, labels, ttftBuckets),
+		tpot:     newVec("model_runner_time_per_output_token_seconds", "Time to generate each token of a streamed request after the first.", dto.MetricType_HISTOGRAM, labels, tpotBuckets),
+	}
+}
+
+// RequestWriter wraps the writer of the response to a request, so that the
+// request is counted with the status of its response when the returned
+// function is called with its model and backend, once it's served.
+func (m *ServerMetrics) RequestWriter(w http.ResponseWriter) (http.ResponseWriter, func(model, backend string)) {
+	rw := middleware.NewUsageRecorder(w)
+	return rw, func(model, backend string) {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		m.requests.add(1, model, backend, strconv.Itoa(rw.Status()))
+	}
+}
+
+// Enqueue records a request that starts waiting in the queue of a model, and
+// returns a function that records that it stopped waiting, which can safely
+// be called several times.
+func (m *ServerMetrics) Enqueue(model, backend string) func() {
+	m.lock.Lock()
+	m.queued.add(1, model, backend)
+	m.lock.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.lock.Lock()
+			defer m.lock.Unlock()
+			m.queued.add(-1, model, backend)
+		})
+	}
+}
+
+// ObserveLoad records that a runner was loaded.
+func (m *ServerMetrics) ObserveLoad(model, backend string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.loads.add(1, model, backend)
+}
+
+// ObserveUnload records that a runner was unloaded for a reason.
+func (m *ServerMetrics) ObserveUnload(model, backend, reason string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.unloads.add(1, model, backend, reason)
+}
+
+// ObserveLatency records the time to the first token of a streamed request,
+// along with the time per output token after it, if more than one token was
+// generated.
+func (m *ServerMetrics) ObserveLatency(model, backend string, firstToken time.Duration, perToken time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.ttft.observe(firstToken.Seconds(), model, backend)
+	if perToken > 0 {
+		m.tpot.observe(perToken.Seconds(), model, backend)
+	}
+}
+
+// TokenWriter wraps the writer of an inference response, so that the token
+// usage that it reports is counted when the returned function is called,
+// once the response is complete.
+func (m *ServerMetrics) TokenWriter(w http.ResponseWriter, model, backend string) (http.ResponseWriter, func()) {
+	rw := middleware.NewUsageRecorder(w)
+	return rw, func() {
+		prompt, completion := rw.Usage()
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		if prompt != nil {
+			m.tokens.add(float64(*prompt), model, backend, "prompt")
+		}
+		if completion != nil {
+			m.tokens.add(float64(*completion), model, backend, "completion")
+		}
+	}
+}
+
+// Families returns the metric families of the server metrics, along with the
+// memory estimates of the loaded models.
+func (m *ServerMetrics) Families(memory []MemoryEstimate) []*dto.MetricFamily {
+	estimates := newVec("model_runner_memory_estimate_bytes", "Memory that the backend estimates that the loaded runners of a model require, by type: ram or vram.", dto.MetricType_GAUGE, []string{"model", "backend", "type"}, nil)
+	for _, estimate := range memory {
+		estimates.add(float64(estimate.RAM), estimate.Model, estimate.Backend, "ram")
+		estimates.add(float64(estimate.VRAM), estimate.Model, estimate.Backend, "vram")
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var families []*dto.MetricFamily
+	for _, v := range []*vec{m.requests, m.queued, m.loads, m.unloads, m.tokens, m.ttft, m.tpot, estimates} {
+		if family := v.family(); len(family.Metric) > 0 {
+			families = append(families, family)
+		}
+	}
+	return families
+}
+
+// vec is a metric family whose series are identified by the values of its
+// labels.
+type vec struct {
+	// name is the name of the family.
+	name string
+	// help is the help text of the family.
+	help string
+	// kind is the type of the family: a counter, gauge, or histogram.
+	kind dto.MetricType
+	// labels are the names of the labels of the family.
+	labels []string
+	// buckets are the upper bounds of the buckets of histograms.
+	buckets []float64
+	// series are the series of the family, by their joined label values.
+	series map[string]*series
+}
+
+// series is a series of a metric family.
+type series struct {
+	// labels are the values of the labels of the series.
+	labels []string
+	// value is the value of counters and gauges, or the sum of the
+	// observations of histograms.
+	value float64
+	// count is the number of observations of histograms.
+	count uint64
+	// buckets are the number of observations of histograms in each bucket,
+	// not counting those in lower buckets.
+	buckets []uint64
+}
+
+// newVec creates a metric family.
+func newVec(name, help string, kind dto.MetricType, labels []string, buckets []float64) *vec {
+	return &vec{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: make(map[string]*series)}
+}
+
+// get returns the series with the specified label values, creating it if
+// needed.
+func (v *vec) get(labels []string) *series {
+	key := strings.Join(labels, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labels: labels, buckets: make([]uint64, len(v.buckets))}
+		v.series[key] = s
+	}
+	return s
+}
+
+// add adds a value to the series of a counter or gauge.
+func (v *vec) add(value float64, labels ...string) {
+	v.get(labels).value += value
+}
+
+// observe adds an observation to the series of a histogram.
+func (v *vec) observe(value float64, labels ...string) {
+	s := v.get(labels)
+	s.value += value
+	s.count++
+	if i, _ := slices.BinarySearch(v.buckets, value); i < len(v.buckets) {
+		s.buckets[i]++
+	}
+}
+
+// family returns the family in the format of the Prometheus client model,
+// with its series sorted by label values.
+func (v *vec) family() *dto.MetricFamily {
+	family := &dto.MetricFamily{Name: stringPtr(v.name), Help: stringPtr(v.help), Type: v.kind.Enum()}
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		s := v.series[key]
+		metric := &dto.Metric{}
+		for i, name := range v.labels {
+			metric.Label = append(metric.Label, &dto.LabelPair{Name: stringPtr(name), Value: stringPtr(s.labels[i])})
+		}
+		switch v.kind {
+		case dto.MetricType_COUNTER:
+			metric.Counter = &dto.Counter{Value: float64Ptr(s.value)}
+		case dto.MetricType_GAUGE:
+			metric.Gauge = &dto.Gauge{Value: float64Ptr(s.value)}
+		case dto.MetricType_HISTOGRAM:
+			histogram := &dto.Histogram{SampleCount: uint64Ptr(s.count), SampleSum: float64Ptr(s.value)}
+			cumulative := uint64(0)
+			for i, bound := range v.buckets {
+				cumulative += s.buckets[i]
+				histogram.Bucket = append(histogram.Bucket, &dto.Bucket{UpperBound: float64Ptr(bound), CumulativeCount: uint64Ptr(cumulative)})
+			}
+			metric.Histogram = histogram
+		}
+		family.Metric = append(family.Metric, metric)
+	}
+	return family
+}
+
+// float64Ptr returns a pointer to f.
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+// uint64Ptr returns a pointer to u.
+func uint64Ptr(u uint64) *uint64 {
+	return &u
+}
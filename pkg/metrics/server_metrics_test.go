@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestServerMetrics(t *testing.T) {
+	m := NewServerMetrics()
+
+	w, observe := m.RequestWriter(httptest.NewRecorder())
+	w, countTokens := m.TokenWriter(w, "ai/smollm2", "llama.cpp")
+	fmt.Fprint(w, `{"choices":[],"usage":{"prompt_tokens":7,"completion_tokens":3,"total_tokens":10}}`)
+	countTokens()
+	observe("ai/smollm2", "llama.cpp")
+	w, observe = m.RequestWriter(httptest.NewRecorder())
+	http.Error(w, "model not found", http.StatusNotFound)
+	observe("ai/missing", "llama.cpp")
+
+	dequeue := m.Enqueue("ai/smollm2", "llama.cpp")
+	m.Enqueue("ai/smollm2", "llama.cpp")
+	dequeue()
+	dequeue()
+	m.ObserveLoad("ai/smollm2", "llama.cpp")
+	m.ObserveUnload("ai/smollm2", "llama.cpp", "evict")
+	m.ObserveLatency("ai/smollm2", "llama.cpp", 300*time.Millisecond, 20*time.Millisecond)
+	m.ObserveLatency("ai/smollm2", "llama.cpp", 2*time.Second, 0)
+
+	var out bytes.Buffer
+	encoder := expfmt.NewEncoder(&out, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range m.Families([]MemoryEstimate{{Backend: "llama.cpp", Model: "ai/smollm2", RAM: 100, VRAM: 200}}) {
+		if err := encoder.Encode(family); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, expected := range []string{
+		`model_runner_requests_total{model="ai/smollm2",backend="llama.cpp",code="200"} 1`,
+		`model_runner_requests_total{model="ai/missing",backend="llama.cpp",code="404"} 1`,
+		`model_runner_queue_depth{model="ai/smollm2",backend="llama.cpp"} 1`,
+		`model_runner_model_loads_total{model="ai/smollm2",backend="llama.cpp"} 1`,
+		`model_runner_model_unloads_total{model="ai/smollm2",backend="llama.cpp",reason="evict"} 1`,
+		`model_runner_tokens_total{model="ai/smollm2",backend="llama.cpp",type="prompt"} 7`,
+		`model_runner_tokens_total{model="ai/smollm2",backend="llama.cpp",type="completion"} 3`,
+		`model_runner_time_to_first_token_seconds_bucket{model="ai/smollm2",backend="llama.cpp",le="0.5"} 1`,
+		`model_runner_time_to_first_token_seconds_bucket{model="ai/smollm2",backend="llama.cpp",le="2.5"} 2`,
+		`model_runner_time_to_first_token_seconds_count{model="ai/smollm2",backend="llama.cpp"} 2`,
+		`model_runner_time_per_output_token_seconds_count{model="ai/smollm2",backend="llama.cpp"} 1`,
+		`model_runner_memory_estimate_bytes{model="ai/smollm2",backend="llama.cpp",type="vram"} 200`,
+	} {
+		if !strings.Contains(out.String(), expected+"\n") {
+			t.Errorf("Expected %s in:\n%s", expected, out.String())
+		}
+	}
+}
@@ -3,28 +3,104 @@ package middleware
 import (
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+
+	"github.com/docker/model-runner/pkg/inference"
 )
 
+// allowedMethods are the methods allowed in cross-origin requests.
+const allowedMethods = "GET, POST, DELETE"
+
+// exposedHeaders are the response headers that cross-origin requests can read,
+// besides the CORS-safelisted ones.
+var exposedHeaders = strings.Join([]string{
+	inference.QueuePositionHeader,
+	inference.LoadEstimateHeader,
+	inference.LoadDurationHeader,
+	"Retry-After",
+}, ", ")
+
+// CORSConfig configures the cross-origin requests accepted from browsers.
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to make requests. An origin may
+	// contain a "*" wildcard, as in "http://localhost:*", and a single "*"
+	// allows every origin. If empty, no origin is allowed.
+	AllowedOrigins []string
+	// AllowedHeaders are the request headers allowed in cross-origin
+	// requests. If empty, the headers requested by preflight requests are
+	// allowed.
+	AllowedHeaders []string
+	// AllowCredentials is whether cross-origin requests can include
+	// credentials, such as cookies and authorization headers. It's ignored
+	// if every origin is allowed, since any website could then make requests
+	// with the credentials of its visitors.
+	AllowCredentials bool
+}
+
+// CredentialsAllowed returns whether cross-origin requests can include
+// credentials, which they can't if every origin is allowed.
+func (c CORSConfig) CredentialsAllowed() bool {
+	return c.AllowCredentials && !c.allowAll()
+}
+
+// allowAll returns whether every origin is allowed.
+func (c CORSConfig) allowAll() bool {
+	return len(c.AllowedOrigins) == 1 && c.AllowedOrigins[0] == "*"
+}
+
+// CORSConfigFromEnv returns the CORS configuration set by the environment: the
+// allowed origins are listed by DMR_ORIGINS and the allowed request headers by
+// DMR_CORS_HEADERS, both comma-separated, and credentials are allowed if
+// DMR_CORS_CREDENTIALS is true.
+func CORSConfigFromEnv() CORSConfig {
+	config := CORSConfig{
+		AllowedOrigins: getAllowedOrigins(),
+		AllowedHeaders: splitList(os.Getenv("DMR_CORS_HEADERS")),
+	}
+	if s := os.Getenv("DMR_CORS_CREDENTIALS"); s != "" {
+		if allow, err := strconv.ParseBool(s); err == nil {
+			config.AllowCredentials = allow
+		}
+	}
+	return config
+}
+
 // CorsMiddleware handles CORS and OPTIONS preflight requests with optional allowedOrigins.
 // If allowedOrigins is nil or empty, it falls back to getAllowedOrigins().
-// This middleware intercepts OPTIONS requests only if the Origin header is present and valid,
+// This middleware intercepts only preflight requests from allowed origins,
 // otherwise passing the request to the router (allowing 405/404 responses as appropriate).
+// The remaining CORS configuration is read from the environment.
 func CorsMiddleware(allowedOrigins []string, next http.Handler) http.Handler {
-	if len(allowedOrigins) == 0 {
-		allowedOrigins = getAllowedOrigins()
+	config := CORSConfigFromEnv()
+	if len(allowedOrigins) != 0 {
+		config.AllowedOrigins = allowedOrigins
 	}
+	return CORSHandler(config, next)
+}
 
-	allowAll := len(allowedOrigins) == 1 && allowedOrigins[0] == "*"
-	allowedSet := make(map[string]struct{}, len(allowedOrigins))
-	for _, o := range allowedOrigins {
-		allowedSet[o] = struct{}{}
+// CORSHandler handles CORS and OPTIONS preflight requests as configured.
+// Requests from origins that aren't allowed are rejected, and preflight
+// requests from allowed origins are answered without passing them on, so that
+// they don't require authorization.
+func CORSHandler(config CORSConfig, next http.Handler) http.Handler {
+	allowAll := config.allowAll()
+	allowCredentials := config.CredentialsAllowed()
+	allowedSet := make(map[string]struct{}, len(config.AllowedOrigins))
+	var patterns []string
+	for _, o := range config.AllowedOrigins {
+		if strings.Contains(o, "*") && o != "*" {
+			patterns = append(patterns, o)
+		} else {
+			allowedSet[o] = struct{}{}
+		}
 	}
+	allowedHeaders := strings.Join(config.AllowedHeaders, ", ")
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("Origin")
 
-		allowed := allowAll || originAllowed(origin, allowedSet)
+		allowed := allowAll || originAllowed(origin, allowedSet) || originMatches(origin, patterns)
 
 		if origin != "" && !allowed {
 			http.Error(w, "Origin not allowed", http.StatusForbidden)
@@ -33,53 +109,90 @@ func CorsMiddleware(allowedOrigins []string, next http.Handler) http.Handler {
 
 		// Set CORS headers if origin is allowed
 		if origin != "" && allowed {
+			if !varies(w.Header(), "Origin") {
+				w.Header().Add("Vary", "Origin")
+			}
 			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if allowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
 		}
 
-		// Handle OPTIONS requests with origin validation.
-		// Only intercept OPTIONS if the origin is valid to prevent unauthorized preflight requests.
-		if r.Method == http.MethodOptions {
-			// Require valid Origin header for OPTIONS requests
-			if origin == "" || !allowed {
-				// No origin or invalid origin - pass to router for proper 405/404 response
-				next.ServeHTTP(w, r)
-				return
+		// Answer preflight requests, whose origin was validated above.
+		// Other OPTIONS requests are passed to the router like any other
+		// request, so that they require authorization.
+		if Preflight(r) {
+			// Unless headers are configured, those requested are allowed,
+			// since the "*" wildcard doesn't apply to credentialed requests.
+			w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+			switch requested := r.Header.Get("Access-Control-Request-Headers"); {
+			case allowedHeaders != "":
+				w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+			case requested != "":
+				w.Header().Set("Access-Control-Allow-Headers", requested)
+			default:
+				w.Header().Set("Access-Control-Allow-Headers", "*")
 			}
-
-			// Valid origin - handle OPTIONS with CORS headers
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE")
-			w.Header().Set("Access-Control-Allow-Headers", "*")
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
+		if origin != "" {
+			w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// Preflight returns whether a request is a CORS preflight request: an OPTIONS
+// request with an Origin header and an Access-Control-Request-Method header.
+func Preflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
 func originAllowed(origin string, allowedSet map[string]struct{}) bool {
 	_, ok := allowedSet[origin]
 	return ok
 }
 
-// getAllowedOrigins retrieves allowed origins from the DMR_ORIGINS environment variable.
-// If the variable is not set it returns nil, indicating no origins are allowed.
-func getAllowedOrigins() (origins []string) {
-	dmrOrigins := os.Getenv("DMR_ORIGINS")
-	if dmrOrigins == "" {
-		return nil
+// originMatches returns whether an origin matches one of the patterns, whose
+// "*" wildcard matches any text.
+func originMatches(origin string, patterns []string) bool {
+	for _, pattern := range patterns {
+		prefix, suffix, _ := strings.Cut(pattern, "*")
+		if len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
 	}
+	return false
+}
 
-	for _, o := range strings.Split(dmrOrigins, ",") {
-		if trimmed := strings.TrimSpace(o); trimmed != "" {
-			origins = append(origins, trimmed)
+// varies returns whether the Vary header lists the specified request header.
+func varies(header http.Header, name string) bool {
+	for _, value := range header.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), name) {
+				return true
+			}
 		}
 	}
+	return false
+}
 
-	if len(origins) == 0 {
-		return nil
-	}
+// getAllowedOrigins retrieves allowed origins from the DMR_ORIGINS environment variable.
+// If the variable is not set it returns nil, indicating no origins are allowed.
+func getAllowedOrigins() []string {
+	return splitList(os.Getenv("DMR_ORIGINS"))
+}
 
-	return origins
+// splitList splits a comma-separated list, discarding empty items. It returns
+// nil if the list is empty.
+func splitList(list string) (items []string) {
+	for _, item := range strings.Split(list, ",") {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+	return items
 }
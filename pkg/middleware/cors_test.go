@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -48,7 +49,7 @@ func TestCorsMiddleware(t *testing.T) {
 			origin:         "http://foo.com",
 			wantStatus:     http.StatusNoContent,
 			wantHeaders: map[string]string{
-				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Credentials": "",
 				"Access-Control-Allow-Methods":     "GET, POST, DELETE",
 				"Access-Control-Allow-Headers":     "*",
 			},
@@ -89,6 +90,9 @@ func TestCorsMiddleware(t *testing.T) {
 			if tt.origin != "" {
 				req.Header.Set("Origin", tt.origin)
 			}
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
 			rec := httptest.NewRecorder()
 			handler.ServeHTTP(rec, req)
 
@@ -116,3 +120,93 @@ func TestOriginAllowed(t *testing.T) {
 		t.Errorf("expected originAllowed to return false")
 	}
 }
+
+func TestCORSHandler(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	serve := func(config CORSConfig, method, origin, requestHeaders string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", http.NoBody)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		if requestHeaders != "" {
+			req.Header.Set("Access-Control-Request-Headers", requestHeaders)
+		}
+		rec := httptest.NewRecorder()
+		CORSHandler(config, next).ServeHTTP(rec, req)
+		return rec
+	}
+
+	config := CORSConfig{AllowedOrigins: []string{"http://localhost:*", "https://*.example.com"}}
+	for origin, status := range map[string]int{
+		"http://localhost:3000":     http.StatusOK,
+		"https://app.example.com":   http.StatusOK,
+		"https://example.com":       http.StatusForbidden,
+		"http://localhost.evil.com": http.StatusForbidden,
+	} {
+		if rec := serve(config, http.MethodGet, origin, ""); rec.Code != status {
+			t.Errorf("expected status %d for %s, got %d", status, origin, rec.Code)
+		}
+	}
+
+	rec := serve(config, http.MethodGet, "http://localhost:3000", "")
+	if rec.Header().Get("Vary") != "Origin" || rec.Header().Get("Access-Control-Allow-Credentials") != "" ||
+		!strings.Contains(rec.Header().Get("Access-Control-Expose-Headers"), "X-Queue-Position") {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+
+	rec = serve(config, http.MethodOptions, "http://localhost:3000", "authorization,content-type")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Headers") != "authorization,content-type" {
+		t.Errorf("expected the requested headers to be allowed, got %d %v", rec.Code, rec.Header())
+	}
+
+	config.AllowedHeaders = []string{"Authorization", "Content-Type"}
+	config.AllowCredentials = true
+	rec = serve(config, http.MethodOptions, "http://localhost:3000", "x-custom")
+	if rec.Header().Get("Access-Control-Allow-Headers") != "Authorization, Content-Type" || rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("expected the configured headers to be allowed, got %v", rec.Header())
+	}
+
+	// OPTIONS requests that aren't preflight requests are passed on.
+	req := httptest.NewRequest(http.MethodOptions, "/", http.NoBody)
+	req.Header.Set("Origin", "http://localhost:3000")
+	rec = httptest.NewRecorder()
+	CORSHandler(config, next).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected an OPTIONS request without a requested method to be passed on, got %d", rec.Code)
+	}
+
+	// Credentials are never allowed along with every origin.
+	config.AllowedOrigins = []string{"*"}
+	rec = serve(config, http.MethodOptions, "https://evil.example", "")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://evil.example" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("expected credentials not to be allowed with every origin, got %v", rec.Header())
+	}
+	if config.CredentialsAllowed() {
+		t.Error("expected credentials not to be allowed with every origin")
+	}
+}
+
+func TestCORSConfigFromEnv(t *testing.T) {
+	t.Setenv("DMR_ORIGINS", "http://foo.com, http://bar.com")
+	t.Setenv("DMR_CORS_HEADERS", "Authorization,,Content-Type")
+	t.Setenv("DMR_CORS_CREDENTIALS", "false")
+	config := CORSConfigFromEnv()
+	if len(config.AllowedOrigins) != 2 || config.AllowedOrigins[1] != "http://bar.com" ||
+		len(config.AllowedHeaders) != 2 || config.AllowedHeaders[1] != "Content-Type" || config.AllowCredentials {
+		t.Errorf("unexpected configuration %+v", config)
+	}
+
+	t.Setenv("DMR_CORS_CREDENTIALS", "")
+	if config := CORSConfigFromEnv(); config.AllowCredentials {
+		t.Error("expected credentials not to be allowed by default")
+	}
+	t.Setenv("DMR_CORS_CREDENTIALS", "true")
+	if config := CORSConfigFromEnv(); !config.AllowCredentials {
+		t.Error("expected credentials to be allowed")
+	}
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// annotations holds the request details that are only known to the handlers
+// serving the request.
+type annotations struct {
+	mu    sync.Mutex
+	model string
+}
+
+type annotationsKey struct{}
+
+// Annotate returns r with a context in which the handlers serving it can
+// record the model that it targets with SetModel. A request that is already
+// annotated is returned as-is, so that every middleware wrapping the handlers
+// reads the same annotations.
+func Annotate(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(annotationsKey{}).(*annotations); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), annotationsKey{}, &annotations{}))
+}
+
+// SetModel records the model targeted by the request associated with ctx.
+// It's a no-op if the request isn't annotated.
+func SetModel(ctx context.Context, model string) {
+	if a, ok := ctx.Value(annotationsKey{}).(*annotations); ok {
+		a.mu.Lock()
+		a.model = model
+		a.mu.Unlock()
+	}
+}
+
+// Model returns the model recorded with SetModel for the request associated
+// with ctx, if any.
+func Model(ctx context.Context) string {
+	if a, ok := ctx.Value(annotationsKey{}).(*annotations); ok {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return a.model
+	}
+	return ""
+}
+
+// maximumTailSize is the number of trailing response bytes retained to find
+// the token usage reported by the backend. Usage is reported at the end of
+// both regular and streaming responses.
+const maximumTailSize = 8 * 1024
+
+// UsageRecorder records the status, size, and tail of a response, in which it
+// finds the token usage reported by the backend.
+type UsageRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	bytes       int64
+	tail        bytes.Buffer
+}
+
+// NewUsageRecorder creates a new usage recorder for the response written to
+// w.
+func NewUsageRecorder(w http.ResponseWriter) *UsageRecorder {
+	return &UsageRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rw *UsageRecorder) WriteHeader(statusCode int) {
+	if !rw.wroteHeader {
+		rw.status = statusCode
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *UsageRecorder) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	rw.tail.Write(b[:n])
+	if excess := rw.tail.Len() - maximumTailSize; excess > 0 {
+		rw.tail.Next(excess)
+	}
+	return n, err
+}
+
+func (rw *UsageRecorder) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rw *UsageRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Status returns the status of the response.
+func (rw *UsageRecorder) Status() int {
+	return rw.status
+}
+
+// Bytes returns the number of bytes written in the response body.
+func (rw *UsageRecorder) Bytes() int64 {
+	return rw.bytes
+}
+
+// usageKey marks the start of an OpenAI usage object.
+var usageKey = []byte(`"usage":`)
+
+// Usage returns the prompt and completion token counts of the last OpenAI
+// usage object in the response, if any.
+func (rw *UsageRecorder) Usage() (*int64, *int64) {
+	tail := rw.tail.Bytes()
+	idx := bytes.LastIndex(tail, usageKey)
+	if idx < 0 {
+		return nil, nil
+	}
+	var usage struct {
+		PromptTokens     *int64 `json:"prompt_tokens"`
+		CompletionTokens *int64 `json:"completion_tokens"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(tail[idx+len(usageKey):]))
+	if err := decoder.Decode(&usage); err != nil {
+		return nil, nil
+	}
+	return usage.PromptTokens, usage.CompletionTokens
+}
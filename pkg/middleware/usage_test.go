@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnnotate(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodPost, "/engines/v1/chat/completions", nil)
+	SetModel(r.Context(), "ignored")
+	if model := Model(r.Context()); model != "" {
+		t.Errorf("Expected no model without annotations, got %q", model)
+	}
+
+	// Nested middleware share the annotations of the outermost one.
+	outer := Annotate(r)
+	inner := Annotate(outer)
+	SetModel(inner.Context(), "ai/smollm2")
+	if model := Model(outer.Context()); model != "ai/smollm2" {
+		t.Errorf("Expected model %q, got %q", "ai/smollm2", model)
+	}
+}
+
+func TestUsageRecorder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		prompt     int64
+		completion int64
+		noUsage    bool
+	}{
+		{
+			name:       "response",
+			status:     http.StatusOK,
+			body:       `{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":34,"total_tokens":46}}`,
+			prompt:     12,
+			completion: 34,
+		},
+		{
+			name:       "stream",
+			status:     http.StatusOK,
+			body:       strings.Repeat("data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n", 1000) + "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":6}}\n\ndata: [DONE]\n\n",
+			prompt:     5,
+			completion: 6,
+		},
+		{
+			name:    "error",
+			status:  http.StatusNotFound,
+			body:    "not found\n",
+			noUsage: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := NewUsageRecorder(httptest.NewRecorder())
+			rw.WriteHeader(tt.status)
+			fmt.Fprint(rw, tt.body)
+
+			if rw.Status() != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, rw.Status())
+			}
+			if rw.Bytes() != int64(len(tt.body)) {
+				t.Errorf("Expected %d bytes, got %d", len(tt.body), rw.Bytes())
+			}
+			prompt, completion := rw.Usage()
+			if tt.noUsage {
+				if prompt != nil || completion != nil {
+					t.Errorf("Expected no usage, got %v and %v", prompt, completion)
+				}
+				return
+			}
+			if prompt == nil || *prompt != tt.prompt || completion == nil || *completion != tt.completion {
+				t.Errorf("Expected usage %d and %d, got %v and %v", tt.prompt, tt.completion, prompt, completion)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package moderations
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/internal/relay"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// maximumRequestSize is the maximum size of a request body, matching the
+// limit applied by the scheduler.
+const maximumRequestSize = 10 * 1024 * 1024
+
+// HTTPHandler serves the moderations API by classifying inputs with a safety
+// classifier model, whose chat completions requests are served by the
+// inference handler.
+type HTTPHandler struct {
+	// log is the associated logger.
+	log logging.Logger
+	// router is the HTTP request router.
+	router *http.ServeMux
+	// next is the inference handler.
+	next http.Handler
+	// model is the classifier model used when requests don't name a local
+	// model, if configured.
+	model string
+}
+
+// NewHTTPHandler creates a new moderations API handler that forwards chat
+// completions requests to next, which should serve the inference API, with
+// model as the default classifier model, if set.
+func NewHTTPHandler(log logging.Logger, next http.Handler, model string) *HTTPHandler {
+	h := &HTTPHandler{
+		log:    log,
+		router: http.NewServeMux(),
+		next:   next,
+		model:  model,
+	}
+	for _, prefix := range Prefixes() {
+		h.router.HandleFunc("POST "+prefix+Path, h.handleCreate)
+	}
+	return h
+}
+
+// Prefixes returns the prefixes under which the moderations API is served:
+// the root, like the other OpenAI endpoints, and the inference prefix, with
+// or without a backend.
+func Prefixes() []string {
+	return []string{"", inference.InferencePrefix, inference.InferencePrefix + "/{backend}"}
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// classifierModel returns the classifier model of a request. OpenAI's
+// moderation models, which clients name by default, stand for the
+// configured model.
+func (h *HTTPHandler) classifierModel(model string) string {
+	if model == "" || strings.HasPrefix(model, "omni-moderation") || strings.HasPrefix(model, "text-moderation") {
+		return h.model
+	}
+	return model
+}
+
+// handleCreate handles POST /v1/moderations requests.
+func (h *HTTPHandler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maximumRequestSize))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(w, "request too large", http.StatusBadRequest)
+		} else {
+			http.Error(w, "failed to read request body", http.StatusInternalServerError)
+		}
+		return
+	}
+	var request Request
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	model := h.classifierModel(request.Model)
+	if model == "" {
+		http.Error(w, "no moderation model is configured: set MODEL_RUNNER_MODERATION_MODEL or the model of the request", http.StatusBadRequest)
+		return
+	}
+	texts, err := inputTexts(request.Input)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := Response{ID: newID(), Model: model, Results: make([]Result, len(texts))}
+	for i, text := range texts {
+		chatBody, err := json.Marshal(chatRequest(model, text))
+		if err != nil {
+			http.Error(w, "failed to encode request", http.StatusInternalServerError)
+			return
+		}
+		upstream := r.Clone(r.Context())
+		upstream.URL.Path = relay.ChatCompletionsPath(r.URL.Path, "/moderations")
+		upstream.URL.RawPath = ""
+		upstream.Body = io.NopCloser(bytes.NewReader(chatBody))
+		upstream.ContentLength = int64(len(chatBody))
+		upstream.Header.Set("Content-Type", "application/json")
+		upstream.Header.Set(inference.RequestOriginHeader, inference.OriginModerations)
+
+		recorder := relay.NewRecorder()
+		h.next.ServeHTTP(recorder, upstream)
+		if recorder.Status() != http.StatusOK {
+			recorder.Replay(w)
+			return
+		}
+		if response.Results[i], err = classify(recorder.Body()); err != nil {
+			h.log.Warnf("Failed to classify input with %s: %v", model, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.log.Warnf("Failed to encode response: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package moderations
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeClassifier serves chat completions, recording their requests, and
+// classifies inputs containing "attack" as violent.
+type fakeClassifier struct {
+	// path is the path of the last request.
+	path string
+	// origin is the origin header of the last request.
+	origin string
+	// models are the models of the requests.
+	models []string
+}
+
+func (f *fakeClassifier) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	json.NewDecoder(r.Body).Decode(&request)
+	f.path = r.URL.Path
+	f.origin = r.Header.Get(inference.RequestOriginHeader)
+	f.models = append(f.models, request.Model)
+	if request.Model == "missing" {
+		http.Error(w, "model not found", http.StatusNotFound)
+		return
+	}
+	verdict := "safe"
+	if strings.Contains(request.Messages[0].Content, "attack") {
+		verdict = `unsafe\nS1`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"%s"},"finish_reason":"stop"}]}`, verdict)
+}
+
+// moderate sends a moderations request to the handler.
+func moderate(t *testing.T, h http.Handler, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	classifier := &fakeClassifier{}
+	h := NewHTTPHandler(logrus.New(), classifier, "ai/llama-guard3")
+
+	w := moderate(t, h, "/v1/moderations", `{"model":"omni-moderation-latest","input":["hello","attack them"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	var response Response
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.HasPrefix(response.ID, "modr-") || response.Model != "ai/llama-guard3" {
+		t.Errorf("Unexpected ID %q or model %q", response.ID, response.Model)
+	}
+	if len(response.Results) != 2 || response.Results[0].Flagged || !response.Results[1].Flagged || !response.Results[1].Categories["violence"] {
+		t.Errorf("Unexpected results %+v", response.Results)
+	}
+	if classifier.path != inference.InferencePrefix+"/v1/chat/completions" {
+		t.Errorf("Expected a chat completions request, got %s", classifier.path)
+	}
+	if classifier.origin != inference.OriginModerations {
+		t.Errorf("Expected origin %s, got %s", inference.OriginModerations, classifier.origin)
+	}
+
+	// Requests naming a local model are classified by it, for their backend.
+	moderate(t, h, inference.InferencePrefix+"/llama.cpp/v1/moderations", `{"model":"ai/shieldgemma","input":"hello"}`)
+	if model := classifier.models[len(classifier.models)-1]; model != "ai/shieldgemma" {
+		t.Errorf("Expected model ai/shieldgemma, got %s", model)
+	}
+	if classifier.path != inference.InferencePrefix+"/llama.cpp/v1/chat/completions" {
+		t.Errorf("Expected a chat completions request for the backend, got %s", classifier.path)
+	}
+
+	// Errors of the inference handler are passed through.
+	if w := moderate(t, h, "/v1/moderations", `{"model":"missing","input":"hello"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := moderate(t, h, "/v1/moderations", `{"input":[]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestHandlerWithoutModel(t *testing.T) {
+	h := NewHTTPHandler(logrus.New(), &fakeClassifier{}, "")
+	if w := moderate(t, h, "/v1/moderations", `{"input":"hello"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := moderate(t, h, "/v1/moderations", `{"model":"ai/llama-guard3","input":"hello"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+}
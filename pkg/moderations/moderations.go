@@ -0,0 +1,219 @@
+// Package moderations implements the OpenAI moderations API (/v1/moderations)
+// with a local safety classifier model, such as Llama Guard 3, served by the
+// chat completions API of the backends: each input is classified by the model,
+// whose verdict and violated categories are translated to OpenAI's categories,
+// scored with the probability of its verdict.
+package moderations
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Path is the path of the moderations API.
+const Path = "/v1/moderations"
+
+// ErrInvalidRequest indicates that a request can't be classified.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Categories are the moderation categories of OpenAI, which every result
+// reports.
+var Categories = []string{
+	"harassment",
+	"harassment/threatening",
+	"hate",
+	"hate/threatening",
+	"illicit",
+	"illicit/violent",
+	"self-harm",
+	"self-harm/intent",
+	"self-harm/instructions",
+	"sexual",
+	"sexual/minors",
+	"violence",
+	"violence/graphic",
+}
+
+// hazardCategories maps the hazard categories of the MLCommons taxonomy, which
+// Llama Guard 3 reports, to the moderation categories of OpenAI. Defamation,
+// specialized advice, privacy, intellectual property, and elections have no
+// counterpart, so they flag inputs without setting a category.
+var hazardCategories = map[string][]string{
+	"S1":  {"violence"},
+	"S2":  {"illicit"},
+	"S3":  {"sexual", "illicit"},
+	"S4":  {"sexual", "sexual/minors"},
+	"S9":  {"illicit/violent"},
+	"S10": {"hate"},
+	"S11": {"self-harm"},
+	"S12": {"sexual"},
+	"S14": {"illicit"},
+}
+
+// Request is a moderations request.
+type Request struct {
+	Model string          `json:"model"`
+	Input json.RawMessage `json:"input"`
+}
+
+// Response is a moderations response.
+type Response struct {
+	ID      string   `json:"id"`
+	Model   string   `json:"model"`
+	Results []Result `json:"results"`
+}
+
+// Result is the classification of an input.
+type Result struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// newID generates a random moderation ID.
+func newID() string {
+	id := make([]byte, 12)
+	rand.Read(id)
+	return "modr-" + hex.EncodeToString(id)
+}
+
+// inputTexts returns the texts of the input of a request, which is a string,
+// a list of strings, or a list of text content parts, classified together.
+func inputTexts(input json.RawMessage) ([]string, error) {
+	var text string
+	if err := json.Unmarshal(input, &text); err == nil {
+		return []string{text}, nil
+	}
+	var texts []string
+	if err := json.Unmarshal(input, &texts); err == nil {
+		if len(texts) == 0 {
+			return nil, fmt.Errorf("%w: input is empty", ErrInvalidRequest)
+		}
+		return texts, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(input, &parts); err != nil || len(parts) == 0 {
+		return nil, fmt.Errorf("%w: input must be a string, a list of strings, or a list of content parts", ErrInvalidRequest)
+	}
+	var combined []string
+	for _, part := range parts {
+		if part.Type != "text" {
+			return nil, fmt.Errorf("%w: unsupported input type %q", ErrInvalidRequest, part.Type)
+		}
+		combined = append(combined, part.Text)
+	}
+	return []string{strings.Join(combined, "\n")}, nil
+}
+
+// chatRequest returns the chat completions request that classifies a text
+// with a model, whose chat template holds the classification prompt. The log
+// probabilities of the first tokens score the verdict.
+func chatRequest(model, text string) map[string]any {
+	return map[string]any{
+		"model":        model,
+		"messages":     []map[string]any{{"role": "user", "content": text}},
+		"temperature":  0,
+		"max_tokens":   20,
+		"logprobs":     true,
+		"top_logprobs": 5,
+	}
+}
+
+// chatResponse is a chat completions response of a classifier model.
+type chatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Logprobs *struct {
+			Content []tokenLogprobs `json:"content"`
+		} `json:"logprobs"`
+	} `json:"choices"`
+}
+
+// tokenLogprobs are the log probabilities of the most likely tokens at the
+// position of a token of a chat completion.
+type tokenLogprobs struct {
+	Token       string `json:"token"`
+	TopLogprobs []struct {
+		Token   string  `json:"token"`
+		Logprob float64 `json:"logprob"`
+	} `json:"top_logprobs"`
+}
+
+// classify translates the chat completions response of a classifier model,
+// whose output is "safe", or "unsafe" followed by a line with the violated
+// hazard categories, to a result.
+func classify(body []byte) (Result, error) {
+	var response chatResponse
+	if err := json.Unmarshal(body, &response); err != nil || len(response.Choices) == 0 {
+		return Result{}, errors.New("invalid chat completions response")
+	}
+	choice := response.Choices[0]
+	verdict, hazards, _ := strings.Cut(strings.TrimSpace(choice.Message.Content), "\n")
+	verdict = strings.ToLower(strings.TrimSpace(verdict))
+	if verdict != "safe" && verdict != "unsafe" {
+		return Result{}, fmt.Errorf("unexpected classifier output %q", choice.Message.Content)
+	}
+
+	result := Result{
+		Flagged:        verdict == "unsafe",
+		Categories:     make(map[string]bool, len(Categories)),
+		CategoryScores: make(map[string]float64, len(Categories)),
+	}
+	for _, category := range Categories {
+		result.Categories[category] = false
+		result.CategoryScores[category] = 0
+	}
+	if !result.Flagged {
+		return result, nil
+	}
+	score := 1.0
+	if choice.Logprobs != nil {
+		score = unsafeProbability(choice.Logprobs.Content)
+	}
+	for _, hazard := range strings.Split(hazards, ",") {
+		for _, category := range hazardCategories[strings.ToUpper(strings.TrimSpace(hazard))] {
+			result.Categories[category] = true
+			result.CategoryScores[category] = score
+		}
+	}
+	return result, nil
+}
+
+// unsafeProbability returns the probability that the classifier's verdict is
+// unsafe, from the most likely tokens at the position of its first token,
+// relative to that of it being safe. It's 1 if they're unknown.
+func unsafeProbability(tokens []tokenLogprobs) float64 {
+	for _, token := range tokens {
+		if strings.TrimSpace(token.Token) == "" {
+			continue
+		}
+		var safe, unsafe float64
+		for _, top := range token.TopLogprobs {
+			// Tokens of a single character are too ambiguous to count.
+			text := strings.ToLower(strings.TrimSpace(top.Token))
+			if len(text) < 2 {
+				continue
+			}
+			if strings.HasPrefix("unsafe", text) {
+				unsafe += math.Exp(top.Logprob)
+			} else if strings.HasPrefix("safe", text) {
+				safe += math.Exp(top.Logprob)
+			}
+		}
+		if unsafe == 0 {
+			return 1
+		}
+		return unsafe / (safe + unsafe)
+	}
+	return 1
+}
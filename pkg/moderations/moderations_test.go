@@ -0,0 +1,106 @@
+package moderations
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestInputTexts(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		texts []string
+		err   bool
+	}{
+		{name: "string", input: `"hi"`, texts: []string{"hi"}},
+		{name: "strings", input: `["a","b"]`, texts: []string{"a", "b"}},
+		{name: "parts", input: `[{"type":"text","text":"a"},{"type":"text","text":"b"}]`, texts: []string{"a\nb"}},
+		{name: "empty", input: `[]`, err: true},
+		{name: "image", input: `[{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]`, err: true},
+		{name: "missing", input: ``, err: true},
+		{name: "number", input: `1`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			texts, err := inputTexts(json.RawMessage(tt.input))
+			if tt.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(texts, tt.texts) {
+				t.Errorf("Expected texts %q, got %q", tt.texts, texts)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		flagged    bool
+		categories map[string]float64
+		err        bool
+	}{
+		{
+			name: "safe",
+			body: `{"choices":[{"message":{"content":"safe"}}]}`,
+		},
+		{
+			name:       "unsafe",
+			body:       `{"choices":[{"message":{"content":"\n\nunsafe\nS1,S10"}}]}`,
+			flagged:    true,
+			categories: map[string]float64{"violence": 1, "hate": 1},
+		},
+		{
+			name:       "scored",
+			body:       `{"choices":[{"message":{"content":"unsafe\nS11"},"logprobs":{"content":[{"token":"\n\n","top_logprobs":[]},{"token":"unsafe","top_logprobs":[{"token":"unsafe","logprob":-0.2231435513},{"token":"safe","logprob":-1.6094379124},{"token":"S","logprob":-5}]}]}}]}`,
+			flagged:    true,
+			categories: map[string]float64{"self-harm": 0.8},
+		},
+		{
+			name:    "unmapped hazard",
+			body:    `{"choices":[{"message":{"content":"unsafe\nS7"}}]}`,
+			flagged: true,
+		},
+		{name: "unexpected output", body: `{"choices":[{"message":{"content":"Hello!"}}]}`, err: true},
+		{name: "no choices", body: `{"choices":[]}`, err: true},
+		{name: "invalid", body: `nope`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := classify([]byte(tt.body))
+			if tt.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Flagged != tt.flagged {
+				t.Errorf("Expected flagged %v, got %v", tt.flagged, result.Flagged)
+			}
+			if len(result.Categories) != len(Categories) || len(result.CategoryScores) != len(Categories) {
+				t.Fatalf("Expected every category, got %v and %v", result.Categories, result.CategoryScores)
+			}
+			for _, category := range Categories {
+				score := tt.categories[category]
+				if result.Categories[category] != (score > 0) {
+					t.Errorf("Expected category %s to be %v", category, score > 0)
+				}
+				if math.Abs(result.CategoryScores[category]-score) > 1e-6 {
+					t.Errorf("Expected score %v for %s, got %v", score, category, result.CategoryScores[category])
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package ollama
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 const (
 	// APIPrefix Ollama API prefix
@@ -40,53 +43,115 @@ type ShowRequest struct {
 
 // ShowResponse is the response for /api/show
 type ShowResponse struct {
-	License    string       `json:"license,omitempty"`
-	Modelfile  string       `json:"modelfile,omitempty"`
-	Parameters string       `json:"parameters,omitempty"`
-	Template   string       `json:"template,omitempty"`
-	Details    ModelDetails `json:"details,omitempty"`
+	License      string                 `json:"license,omitempty"`
+	Modelfile    string                 `json:"modelfile,omitempty"`
+	Parameters   string                 `json:"parameters,omitempty"`
+	Template     string                 `json:"template,omitempty"`
+	Details      ModelDetails           `json:"details,omitempty"`
+	ModelInfo    map[string]interface{} `json:"model_info,omitempty"`   // GGUF metadata, e.g. "llama.context_length"
+	Capabilities []string               `json:"capabilities,omitempty"` // e.g. "completion", "tools", "vision"
+	ModifiedAt   time.Time              `json:"modified_at,omitempty"`
 }
 
 // ChatRequest is the request for /api/chat
 type ChatRequest struct {
-	Name      string                 `json:"name"`  // Ollama uses 'name' field
-	Model     string                 `json:"model"` // Also accept 'model' for compatibility
-	Messages  []Message              `json:"messages"`
-	Stream    *bool                  `json:"stream,omitempty"`
-	KeepAlive string                 `json:"keep_alive,omitempty"` // Duration like "5m" or "0s" to unload immediately
-	Options   map[string]interface{} `json:"options,omitempty"`
+	Name        string                 `json:"name"`  // Ollama uses 'name' field
+	Model       string                 `json:"model"` // Also accept 'model' for compatibility
+	Messages    []Message              `json:"messages"`
+	Tools       []json.RawMessage      `json:"tools,omitempty"`  // Function tools, in the same format as OpenAI's
+	Format      json.RawMessage        `json:"format,omitempty"` // "json" or a JSON schema
+	Think       interface{}            `json:"think,omitempty"`  // true, false, or "low", "medium", or "high"
+	Stream      *bool                  `json:"stream,omitempty"`
+	KeepAlive   string                 `json:"keep_alive,omitempty"` // Duration like "5m" or "0s" to unload immediately
+	Options     map[string]interface{} `json:"options,omitempty"`
+	Logprobs    bool                   `json:"logprobs,omitempty"`     // Return the log probabilities of the output tokens
+	TopLogprobs int                    `json:"top_logprobs,omitempty"` // Most likely tokens to return at each position
 }
 
 // Message represents a chat message
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Thinking  string     `json:"thinking,omitempty"`
+	Images    []string   `json:"images,omitempty"` // Base64-encoded images
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"` // Name of the tool whose result a tool message is
+}
+
+// ToolCall represents a tool call requested by the model
+type ToolCall struct {
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction is the function called by a tool call
+type ToolCallFunction struct {
+	Index     int                    `json:"index,omitempty"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// TokenLogprob is the log probability of a token
+type TokenLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes,omitempty"`
+}
+
+// Logprob is the log probability of an output token, along with those of the
+// most likely tokens at its position
+type Logprob struct {
+	TokenLogprob
+	TopLogprobs []TokenLogprob `json:"top_logprobs,omitempty"`
+}
+
+// Metrics are the durations and token counts reported by final responses
+type Metrics struct {
+	TotalDuration      time.Duration `json:"total_duration,omitempty"`
+	LoadDuration       time.Duration `json:"load_duration,omitempty"`
+	PromptEvalCount    int           `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration time.Duration `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int           `json:"eval_count,omitempty"`
+	EvalDuration       time.Duration `json:"eval_duration,omitempty"`
 }
 
 // ChatResponse is the response for /api/chat
 type ChatResponse struct {
-	Model     string    `json:"model"`
-	CreatedAt time.Time `json:"created_at"`
-	Message   Message   `json:"message,omitempty"`
-	Done      bool      `json:"done"`
+	Model      string    `json:"model"`
+	CreatedAt  time.Time `json:"created_at"`
+	Message    Message   `json:"message,omitempty"`
+	Logprobs   []Logprob `json:"logprobs,omitempty"`
+	Done       bool      `json:"done"`
+	DoneReason string    `json:"done_reason,omitempty"`
+	Metrics
 }
 
 // GenerateRequest is the request for /api/generate
 type GenerateRequest struct {
-	Name      string                 `json:"name"`  // Ollama uses 'name' field
-	Model     string                 `json:"model"` // Also accept 'model' for compatibility
-	Prompt    string                 `json:"prompt"`
-	Stream    *bool                  `json:"stream,omitempty"`
-	KeepAlive string                 `json:"keep_alive,omitempty"` // Duration like "5m" or "0s" to unload immediately
-	Options   map[string]interface{} `json:"options,omitempty"`
+	Name        string                 `json:"name"`  // Ollama uses 'name' field
+	Model       string                 `json:"model"` // Also accept 'model' for compatibility
+	Prompt      string                 `json:"prompt"`
+	System      string                 `json:"system,omitempty"`
+	Images      []string               `json:"images,omitempty"` // Base64-encoded images
+	Raw         bool                   `json:"raw,omitempty"`    // Send the prompt without applying the chat template
+	Format      json.RawMessage        `json:"format,omitempty"` // "json" or a JSON schema
+	Think       interface{}            `json:"think,omitempty"`  // true, false, or "low", "medium", or "high"
+	Stream      *bool                  `json:"stream,omitempty"`
+	KeepAlive   string                 `json:"keep_alive,omitempty"` // Duration like "5m" or "0s" to unload immediately
+	Options     map[string]interface{} `json:"options,omitempty"`
+	Logprobs    bool                   `json:"logprobs,omitempty"`     // Return the log probabilities of the output tokens
+	TopLogprobs int                    `json:"top_logprobs,omitempty"` // Most likely tokens to return at each position
 }
 
 // GenerateResponse is the response for /api/generate
 type GenerateResponse struct {
-	Model     string    `json:"model"`
-	CreatedAt time.Time `json:"created_at"`
-	Response  string    `json:"response,omitempty"`
-	Done      bool      `json:"done"`
+	Model      string    `json:"model"`
+	CreatedAt  time.Time `json:"created_at"`
+	Response   string    `json:"response"`
+	Thinking   string    `json:"thinking,omitempty"`
+	Logprobs   []Logprob `json:"logprobs,omitempty"`
+	Done       bool      `json:"done"`
+	DoneReason string    `json:"done_reason,omitempty"`
+	Metrics
 }
 
 // DeleteRequest is the request for DELETE /api/delete
@@ -127,6 +192,7 @@ type progressLayer struct {
 	ID      string `json:"id"`
 	Size    uint64 `json:"size"`
 	Current uint64 `json:"current"`
+	Cached  bool   `json:"cached"`
 }
 
 // ollamaPullStatus represents the Ollama pull status response format
@@ -138,22 +204,69 @@ type ollamaPullStatus struct {
 	Error     string `json:"error,omitempty"`
 }
 
+// openAIMessage represents an OpenAI chat completion message or delta
+type openAIMessage struct {
+	Content          string           `json:"content"`
+	ReasoningContent string           `json:"reasoning_content"` // llama.cpp
+	Reasoning        string           `json:"reasoning"`         // vLLM
+	ToolCalls        []openAIToolCall `json:"tool_calls"`
+}
+
+// openAIToolCall represents an OpenAI tool call, or a delta of one when streaming
+type openAIToolCall struct {
+	Index    int `json:"index"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"` // JSON-encoded arguments
+	} `json:"function"`
+}
+
+// openAILogprobs represents the log probabilities of the tokens of an OpenAI
+// chat completion or, in the format of completions, of a completion
+type openAILogprobs struct {
+	Content       []Logprob            `json:"content"`
+	Tokens        []string             `json:"tokens"`         // Completions
+	TokenLogprobs []float64            `json:"token_logprobs"` // Completions
+	TopLogprobs   []map[string]float64 `json:"top_logprobs"`   // Completions
+}
+
+// openAIUsage represents the token usage of an OpenAI completion
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// openAITimings represents the timings that llama.cpp adds to completions
+type openAITimings struct {
+	PromptMS    float64 `json:"prompt_ms"`
+	PredictedMS float64 `json:"predicted_ms"`
+}
+
 // openAIChatResponse represents the OpenAI chat completion response
 type openAIChatResponse struct {
 	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
+		Message      openAIMessage   `json:"message"`
+		Text         string          `json:"text"` // Completions (raw generate requests)
+		Logprobs     *openAILogprobs `json:"logprobs"`
+		FinishReason string          `json:"finish_reason"`
 	} `json:"choices"`
+	Usage   *openAIUsage   `json:"usage"`
+	Timings *openAITimings `json:"timings"`
 }
 
 // openAIChatStreamChunk represents a chunk from OpenAI chat completion stream
 type openAIChatStreamChunk struct {
 	Choices []struct {
-		Delta struct {
-			Content string `json:"content"`
-		} `json:"delta"`
+		Delta        openAIMessage   `json:"delta"`
+		Text         string          `json:"text"` // Completions (raw generate requests)
+		Logprobs     *openAILogprobs `json:"logprobs"`
+		FinishReason *string         `json:"finish_reason"`
 	} `json:"choices"`
+	Usage   *openAIUsage   `json:"usage"`
+	Timings *openAITimings `json:"timings"`
+	Error   *struct {
+		Message string `json:"message"`
+	} `json:"error"`
 }
 
 // openAIErrorResponse represents the OpenAI error response format
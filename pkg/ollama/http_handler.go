@@ -2,14 +2,22 @@ package ollama
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/docker/go-units"
+	"github.com/docker/model-runner/pkg/distribution/distribution"
+	"github.com/docker/model-runner/pkg/distribution/types"
 	"github.com/docker/model-runner/pkg/inference"
 	"github.com/docker/model-runner/pkg/inference/models"
 	"github.com/docker/model-runner/pkg/inference/scheduling"
@@ -18,6 +26,13 @@ import (
 	"github.com/docker/model-runner/pkg/middleware"
 )
 
+var (
+	// chatCompletionsPath is the path of the chat completions endpoint
+	chatCompletionsPath = inference.InferencePrefix + "/v1/chat/completions"
+	// completionsPath is the path of the completions endpoint
+	completionsPath = inference.InferencePrefix + "/v1/completions"
+)
+
 // HTTPHandler implements the Ollama API compatibility layer
 type HTTPHandler struct {
 	log           logging.Logger
@@ -77,6 +92,7 @@ type ollamaProgressWriter struct {
 	writer      http.ResponseWriter
 	log         logging.Logger
 	headersSent bool
+	quiet       bool // Discard progress, for requests that don't stream it
 }
 
 func (w *ollamaProgressWriter) Header() http.Header {
@@ -84,6 +100,10 @@ func (w *ollamaProgressWriter) Header() http.Header {
 }
 
 func (w *ollamaProgressWriter) Write(p []byte) (n int, err error) {
+	if w.quiet {
+		return len(p), nil
+	}
+
 	// Ensure headers are sent with correct content type
 	if !w.headersSent {
 		w.writer.Header().Set("Content-Type", "application/x-ndjson")
@@ -118,6 +138,10 @@ func (w *ollamaProgressWriter) Write(p []byte) (n int, err error) {
 		}
 		ollamaMsg.Total = msg.Layer.Size
 		ollamaMsg.Completed = msg.Layer.Current
+		if msg.Layer.Cached {
+			// Layers already in the store are complete without a download
+			ollamaMsg.Completed = msg.Layer.Size
+		}
 
 	case "success":
 		ollamaMsg.Status = "success"
@@ -158,13 +182,16 @@ func (w *ollamaProgressWriter) Write(p []byte) (n int, err error) {
 }
 
 func (w *ollamaProgressWriter) WriteHeader(statusCode int) {
-	if !w.headersSent {
+	if !w.headersSent && !w.quiet {
 		w.writer.WriteHeader(statusCode)
 		w.headersSent = true
 	}
 }
 
 func (w *ollamaProgressWriter) Flush() {
+	if w.quiet {
+		return
+	}
 	if flusher, ok := w.writer.(http.Flusher); ok {
 		flusher.Flush()
 	}
@@ -199,17 +226,8 @@ func (h *HTTPHandler) handleListModels(w http.ResponseWriter, r *http.Request) {
 
 	for _, model := range modelsList {
 		// Extract details from the model
-		details := ModelDetails{
-			Format:            "gguf", // Default to gguf for now
-			Family:            model.Config.Architecture,
-			Families:          []string{model.Config.Architecture},
-			ParameterSize:     model.Config.Parameters,
-			QuantizationLevel: model.Config.Quantization,
-		}
-
-		// Parse size from config string to int64
-		size := int64(0)
-		// TODO: Parse size from model.Config.Size if needed
+		details := modelDetails(model.Config)
+		size := parseSize(model.Config.Size)
 
 		// Get tags, or use ID if no tags exist
 		tags := model.Tags
@@ -327,15 +345,35 @@ func (h *HTTPHandler) handleShowModel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Describe the model's capabilities from the metadata of its weights
+	inspection, err := h.modelManager.Inspect(modelName)
+	if err != nil {
+		h.log.Errorf("Failed to inspect model: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to inspect model: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var template string
+	if chatTemplate, err := h.modelManager.GetChatTemplate(modelName); err == nil {
+		template = chatTemplate.Template
+	} else if !errors.Is(err, models.ErrNoChatTemplate) {
+		h.log.Warnf("Failed to get chat template: %v", err)
+	}
+
 	// Build response
 	response := ShowResponse{
-		Details: ModelDetails{
-			Format:            "gguf",
-			Family:            config.Architecture,
-			Families:          []string{config.Architecture},
-			ParameterSize:     config.Parameters,
-			QuantizationLevel: config.Quantization,
-		},
+		Template:     template,
+		Details:      modelDetails(config),
+		ModelInfo:    modelInfo(config, inspection, req.Verbose),
+		Capabilities: capabilities(inspection, template),
+	}
+	if inspection.License != nil {
+		response.License = inspection.License.Text
+	}
+	if inspection.ContextSize > 0 {
+		response.Parameters = fmt.Sprintf("num_ctx %d", inspection.ContextSize)
+	}
+	if descriptor, err := model.Descriptor(); err == nil && descriptor.Created != nil {
+		response.ModifiedAt = *descriptor.Created
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -344,9 +382,95 @@ func (h *HTTPHandler) handleShowModel(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// modelDetails returns the details of a model from its config
+func modelDetails(config types.Config) ModelDetails {
+	format := string(config.Format)
+	if format == "" {
+		// Models without a format predate safetensors support
+		format = string(types.FormatGGUF)
+	}
+	return ModelDetails{
+		Format:            format,
+		Family:            config.Architecture,
+		Families:          []string{config.Architecture},
+		ParameterSize:     config.Parameters,
+		QuantizationLevel: config.Quantization,
+	}
+}
+
+// parseSize parses the size recorded in a model's config, which is in binary
+// units for GGUF models (e.g. "1.92GiB") and decimal units for safetensors
+// models (e.g. "2.06GB"), returning 0 if it's unknown
+func parseSize(size string) int64 {
+	var bytes int64
+	var err error
+	if strings.Contains(size, "i") {
+		bytes, err = units.RAMInBytes(size)
+	} else {
+		bytes, err = units.FromHumanSize(size)
+	}
+	if err != nil {
+		return 0
+	}
+	return bytes
+}
+
+// modelInfo returns the model_info of /api/show responses: the metadata of the
+// model's GGUF file, with numbers decoded, and its architecture and context
+// length for other models. As with Ollama, the tokenizer metadata is only
+// included in verbose responses.
+func modelInfo(config types.Config, inspection models.ModelInspection, verbose bool) map[string]interface{} {
+	info := make(map[string]interface{}, len(config.GGUF)+2)
+	for key, value := range config.GGUF {
+		if !verbose && strings.HasPrefix(key, "tokenizer.") {
+			continue
+		}
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			info[key] = n
+		} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+			info[key] = f
+		} else {
+			info[key] = value
+		}
+	}
+	if arch := inspection.Architecture; arch != "" {
+		if _, ok := info["general.architecture"]; !ok {
+			info["general.architecture"] = arch
+		}
+		if _, ok := info[arch+".context_length"]; !ok && inspection.ContextLength > 0 {
+			info[arch+".context_length"] = inspection.ContextLength
+		}
+	}
+	return info
+}
+
+// capabilities returns the Ollama capabilities of a model: "completion" or
+// "embedding", "vision" for models that accept images, and "tools" and
+// "thinking" for models whose chat template supports them
+func capabilities(inspection models.ModelInspection, template string) []string {
+	var result []string
+	switch {
+	case slices.Contains(inspection.Modalities.Output, "embedding"):
+		result = append(result, "embedding")
+	case slices.Contains(inspection.Modalities.Output, "text"):
+		result = append(result, "completion")
+	}
+	if slices.Contains(inspection.Modalities.Input, "image") {
+		result = append(result, "vision")
+	}
+	if strings.Contains(template, "tools") {
+		result = append(result, "tools")
+	}
+	if strings.Contains(template, "<think>") || strings.Contains(template, "enable_thinking") || strings.Contains(template, "reasoning_content") {
+		result = append(result, "thinking")
+	}
+	return result
+}
+
 // handleChat handles POST /api/chat
 func (h *HTTPHandler) handleChat(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	start := time.Now()
 
 	var req ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -380,25 +504,27 @@ func (h *HTTPHandler) handleChat(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Convert to OpenAI format chat completion request
-	openAIReq := map[string]interface{}{
-		"model":    modelName,
-		"messages": convertMessages(req.Messages),
-		"stream":   req.Stream == nil || *req.Stream,
+	// Requests without messages only load the model
+	if len(req.Messages) == 0 {
+		h.loadModel(ctx, w, modelName, req.KeepAlive, false)
+		return
 	}
 
-	// Add options if present
-	if req.Options != nil {
-		if temp, ok := req.Options["temperature"]; ok {
-			openAIReq["temperature"] = temp
-		}
-		if maxTokens, ok := req.Options["num_predict"]; ok {
-			openAIReq["max_tokens"] = maxTokens
-		}
+	// Convert to OpenAI format chat completion request
+	stream := req.Stream == nil || *req.Stream
+	openAIReq, err := newOpenAIRequest(modelName, stream, req.KeepAlive, req.Options, req.Format, req.Think)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	openAIReq["messages"] = convertMessages(req.Messages)
+	setLogprobs(openAIReq, req.Logprobs, req.TopLogprobs, false)
+	if len(req.Tools) > 0 {
+		openAIReq["tools"] = req.Tools
 	}
 
 	// Make request to scheduler
-	h.proxyToChatCompletions(ctx, w, r, openAIReq, modelName, req.Stream == nil || *req.Stream)
+	h.proxy(ctx, w, r, openAIReq, chatCompletionsPath, modelName, stream, false, start)
 }
 
 func (h *HTTPHandler) configure(ctx context.Context, numCtxRaw, raw interface{}, modelName, userAgent string) {
@@ -421,6 +547,7 @@ func (h *HTTPHandler) configure(ctx context.Context, numCtxRaw, raw interface{},
 // handleGenerate handles POST /api/generate
 func (h *HTTPHandler) handleGenerate(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	start := time.Now()
 
 	var req GenerateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -456,27 +583,86 @@ func (h *HTTPHandler) handleGenerate(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Requests without a prompt only load the model
+	if req.Prompt == "" && len(req.Images) == 0 {
+		h.loadModel(ctx, w, modelName, req.KeepAlive, true)
+		return
+	}
+
 	// Convert to OpenAI format completion request
-	openAIReq := map[string]interface{}{
-		"model": modelName,
-		"messages": convertMessages([]Message{
-			{Role: "user", Content: req.Prompt},
-		}),
-		"stream": req.Stream == nil || *req.Stream,
+	stream := req.Stream == nil || *req.Stream
+	openAIReq, err := newOpenAIRequest(modelName, stream, req.KeepAlive, req.Options, req.Format, req.Think)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	path := chatCompletionsPath
+	if req.Raw {
+		// Raw prompts are completed as is, without the chat template
+		openAIReq["prompt"] = req.Prompt
+		path = completionsPath
+	} else {
+		var messages []Message
+		if req.System != "" {
+			messages = append(messages, Message{Role: "system", Content: req.System})
+		}
+		messages = append(messages, Message{Role: "user", Content: req.Prompt, Images: req.Images})
+		openAIReq["messages"] = convertMessages(messages)
 	}
+	setLogprobs(openAIReq, req.Logprobs, req.TopLogprobs, req.Raw)
 
-	// Add options if present
-	if req.Options != nil {
-		if temp, ok := req.Options["temperature"]; ok {
-			openAIReq["temperature"] = temp
+	// Make request to scheduler
+	h.proxy(ctx, w, r, openAIReq, path, modelName, stream, true, start)
+}
+
+// loadModel handles chat and generate requests without input, which Ollama
+// clients send to load a model ahead of their requests
+func (h *HTTPHandler) loadModel(ctx context.Context, w http.ResponseWriter, modelName, keepAlive string, generate bool) {
+	entry := scheduling.PreloadEntry{Model: modelName}
+	if keepAlive != "" {
+		k, err := scheduling.ParseKeepAlive(keepAlive)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+			return
 		}
-		if maxTokens, ok := req.Options["num_predict"]; ok {
-			openAIReq["max_tokens"] = maxTokens
+		entry.KeepAlive = &k
+	}
+
+	start := time.Now()
+	if err := h.scheduler.Load(ctx, entry); err != nil {
+		h.log.Warnf("loadModel: failed to load model %s: %v", utils.SanitizeForLog(modelName, -1), err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, distribution.ErrModelNotFound) {
+			status = http.StatusNotFound
 		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
 	}
 
-	// Make request to scheduler
-	h.proxyToCompletions(ctx, w, r, openAIReq, modelName)
+	metrics := Metrics{TotalDuration: time.Since(start), LoadDuration: time.Since(start)}
+	var response interface{} = ChatResponse{
+		Model:      modelName,
+		CreatedAt:  time.Now(),
+		Message:    Message{Role: "assistant"},
+		Done:       true,
+		DoneReason: "load",
+		Metrics:    metrics,
+	}
+	if generate {
+		response = GenerateResponse{
+			Model:      modelName,
+			CreatedAt:  time.Now(),
+			Done:       true,
+			DoneReason: "load",
+			Metrics:    metrics,
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.log.Errorf("Failed to encode response: %v", err)
+	}
 }
 
 // unloadModel unloads a model from memory
@@ -639,14 +825,25 @@ func (h *HTTPHandler) handlePull(w http.ResponseWriter, r *http.Request) {
 	r.Header.Set("Accept", "application/json")
 
 	// Wrap the response writer with ollama progress adapter
+	stream := req.Stream == nil || *req.Stream
 	ollamaWriter := &ollamaProgressWriter{
 		writer:      w,
 		log:         h.log,
 		headersSent: false,
+		quiet:       !stream,
 	}
 
 	// Call the model manager's Pull method with the wrapped writer
-	if err := h.modelManager.Pull(modelName, "", r, ollamaWriter); err != nil {
+	err := h.modelManager.Pull(modelName, "", nil, r, ollamaWriter)
+	if err == nil && !stream {
+		// Without streaming, only the final status is sent
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ollamaPullStatus{Status: "success"}); err != nil {
+			h.log.Errorf("failed to encode response: %v", err)
+		}
+		return
+	}
+	if err != nil {
 		h.log.Errorf("Failed to pull model: %v", err)
 
 		// Send error in Ollama JSON format
@@ -671,18 +868,188 @@ func (h *HTTPHandler) handlePull(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// convertMessages converts Ollama messages to OpenAI format
+// optionFields maps the Ollama options that OpenAI requests support to their
+// fields, including those of llama.cpp and vLLM beyond the OpenAI API
+var optionFields = map[string]string{
+	"temperature":       "temperature",
+	"top_p":             "top_p",
+	"top_k":             "top_k",
+	"min_p":             "min_p",
+	"typical_p":         "typical_p",
+	"seed":              "seed",
+	"stop":              "stop",
+	"num_predict":       "max_tokens",
+	"repeat_penalty":    "repeat_penalty",
+	"repeat_last_n":     "repeat_last_n",
+	"presence_penalty":  "presence_penalty",
+	"frequency_penalty": "frequency_penalty",
+}
+
+// newOpenAIRequest creates an OpenAI request with the parameters that chat and
+// generate requests share
+func newOpenAIRequest(modelName string, stream bool, keepAlive string, options map[string]interface{}, format json.RawMessage, think interface{}) (map[string]interface{}, error) {
+	openAIReq := map[string]interface{}{
+		"model":  modelName,
+		"stream": stream,
+	}
+	if stream {
+		// Report token counts at the end of the stream
+		openAIReq["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+	if keepAlive != "" {
+		openAIReq["keep_alive"] = keepAlive
+	}
+
+	// Add options if present
+	for option, value := range options {
+		field, ok := optionFields[option]
+		if !ok {
+			continue
+		}
+		// Negative num_predict means no limit
+		if option == "num_predict" && convertToInt64(value) < 0 {
+			continue
+		}
+		openAIReq[field] = value
+	}
+
+	responseFormat, err := convertFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	if responseFormat != nil {
+		openAIReq["response_format"] = responseFormat
+	}
+
+	switch think := think.(type) {
+	case nil:
+	case bool:
+		openAIReq["chat_template_kwargs"] = map[string]interface{}{"enable_thinking": think}
+	case string:
+		openAIReq["reasoning_effort"] = think
+	default:
+		return nil, fmt.Errorf("invalid think value %v", think)
+	}
+	return openAIReq, nil
+}
+
+// setLogprobs requests the log probabilities of the output tokens, as chat
+// completions request them or, for raw generate requests, as completions do
+func setLogprobs(openAIReq map[string]interface{}, logprobs bool, topLogprobs int, completion bool) {
+	if !logprobs {
+		return
+	}
+	if completion {
+		openAIReq["logprobs"] = topLogprobs
+		return
+	}
+	openAIReq["logprobs"] = true
+	if topLogprobs > 0 {
+		openAIReq["top_logprobs"] = topLogprobs
+	}
+}
+
+// convertFormat converts the format of a request, either "json" or a JSON
+// schema, to an OpenAI response format
+func convertFormat(format json.RawMessage) (map[string]interface{}, error) {
+	if len(format) == 0 || string(format) == "null" {
+		return nil, nil
+	}
+	var name string
+	if err := json.Unmarshal(format, &name); err == nil {
+		switch name {
+		case "":
+			return nil, nil
+		case "json":
+			return map[string]interface{}{"type": "json_object"}, nil
+		}
+		return nil, fmt.Errorf("unsupported format %q", name)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(format, &schema); err != nil {
+		return nil, fmt.Errorf("invalid format: %w", err)
+	}
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   "response",
+			"strict": true,
+			"schema": schema,
+		},
+	}, nil
+}
+
+// convertMessages converts Ollama messages to OpenAI format. Ollama tool calls
+// have no IDs, so they're given IDs, which the tool messages that follow them
+// refer to in order.
 func convertMessages(messages []Message) []map[string]interface{} {
+	type pendingCall struct {
+		id   string
+		name string
+	}
+	var pending []pendingCall
+
 	result := make([]map[string]interface{}, len(messages))
 	for i, msg := range messages {
 		result[i] = map[string]interface{}{
 			"role":    msg.Role,
 			"content": msg.Content,
 		}
+		if len(msg.Images) > 0 {
+			parts := []map[string]interface{}{{"type": "text", "text": msg.Content}}
+			for _, image := range msg.Images {
+				parts = append(parts, map[string]interface{}{
+					"type":      "image_url",
+					"image_url": map[string]string{"url": imageURL(image)},
+				})
+			}
+			result[i]["content"] = parts
+		}
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]map[string]interface{}, len(msg.ToolCalls))
+			for j, call := range msg.ToolCalls {
+				id := fmt.Sprintf("call_%d_%d", i, j)
+				arguments, err := json.Marshal(call.Function.Arguments)
+				if err != nil || call.Function.Arguments == nil {
+					arguments = []byte("{}")
+				}
+				calls[j] = map[string]interface{}{
+					"id":   id,
+					"type": "function",
+					"function": map[string]string{
+						"name":      call.Function.Name,
+						"arguments": string(arguments),
+					},
+				}
+				pending = append(pending, pendingCall{id: id, name: call.Function.Name})
+			}
+			result[i]["tool_calls"] = calls
+		}
+		if msg.Role == "tool" && len(pending) > 0 {
+			// Answer the first pending call to the tool, or else the first
+			// pending call
+			index := slices.IndexFunc(pending, func(call pendingCall) bool { return call.name == msg.ToolName })
+			if index < 0 {
+				index = 0
+			}
+			result[i]["tool_call_id"] = pending[index].id
+			pending = slices.Delete(pending, index, index+1)
+		}
 	}
 	return result
 }
 
+// imageURL returns the data URL of a base64-encoded image
+func imageURL(image string) string {
+	mediaType := "image/jpeg"
+	if data, err := base64.StdEncoding.DecodeString(image); err == nil {
+		if detected := http.DetectContentType(data); strings.HasPrefix(detected, "image/") {
+			mediaType = detected
+		}
+	}
+	return "data:" + mediaType + ";base64," + image
+}
+
 // convertToInt64 converts various numeric types to int64
 func convertToInt64(v interface{}) int64 {
 	switch val := v.(type) {
@@ -706,8 +1073,9 @@ func convertToInt64(v interface{}) int64 {
 	return 0
 }
 
-// proxyToChatCompletions proxies the request to the OpenAI chat completions endpoint
-func (h *HTTPHandler) proxyToChatCompletions(ctx context.Context, w http.ResponseWriter, r *http.Request, openAIReq map[string]interface{}, modelName string, stream bool) {
+// proxy proxies the request to an OpenAI endpoint, converting its response to
+// a chat or generate response
+func (h *HTTPHandler) proxy(ctx context.Context, w http.ResponseWriter, r *http.Request, openAIReq map[string]interface{}, path, modelName string, stream, generate bool, start time.Time) {
 	// Marshal the OpenAI request
 	reqBody, err := json.Marshal(openAIReq)
 	if err != nil {
@@ -717,7 +1085,7 @@ func (h *HTTPHandler) proxyToChatCompletions(ctx context.Context, w http.Respons
 
 	// Clone the original request to preserve headers (User-Agent, auth, etc.)
 	newReq := r.Clone(ctx)
-	newReq.URL.Path = "/engines/v1/chat/completions"
+	newReq.URL.Path = path
 	newReq.Body = io.NopCloser(bytes.NewReader(reqBody))
 	newReq.ContentLength = int64(len(reqBody))
 	newReq.Header.Set("Content-Type", "application/json")
@@ -725,10 +1093,12 @@ func (h *HTTPHandler) proxyToChatCompletions(ctx context.Context, w http.Respons
 
 	if stream {
 		// Use streaming response writer that processes SSE on the fly
-		streamWriter := &streamingChatResponseWriter{
+		streamWriter := &streamingResponseWriter{
 			w:         w,
 			modelName: modelName,
 			log:       h.log,
+			generate:  generate,
+			start:     start,
 		}
 		// Forward to scheduler HTTP handler with streaming writer
 		h.schedulerHTTP.ServeHTTP(streamWriter, newReq)
@@ -746,50 +1116,7 @@ func (h *HTTPHandler) proxyToChatCompletions(ctx context.Context, w http.Respons
 	h.schedulerHTTP.ServeHTTP(respRecorder, newReq)
 
 	// Convert non-streaming response
-	h.convertChatResponse(w, respRecorder, modelName)
-}
-
-// proxyToCompletions proxies the request to the OpenAI completions endpoint
-func (h *HTTPHandler) proxyToCompletions(ctx context.Context, w http.ResponseWriter, r *http.Request, openAIReq map[string]interface{}, modelName string) {
-	// Marshal the OpenAI request
-	reqBody, err := json.Marshal(openAIReq)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to marshal request: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	// Clone the original request to preserve headers (User-Agent, auth, etc.)
-	newReq := r.Clone(ctx)
-	newReq.URL.Path = "/engines/v1/chat/completions"
-	newReq.Body = io.NopCloser(bytes.NewReader(reqBody))
-	newReq.ContentLength = int64(len(reqBody))
-	newReq.Header.Set("Content-Type", "application/json")
-	newReq.Header.Set(inference.RequestOriginHeader, inference.OriginOllamaCompletion)
-
-	if stream, ok := openAIReq["stream"].(bool); ok && stream {
-		// Use streaming response writer that processes SSE on the fly
-		streamWriter := &streamingGenerateResponseWriter{
-			w:         w,
-			modelName: modelName,
-			log:       h.log,
-		}
-		// Forward to scheduler HTTP handler with streaming writer
-		h.schedulerHTTP.ServeHTTP(streamWriter, newReq)
-		return
-	}
-
-	// For non-streaming, use a response recorder to capture the response
-	respRecorder := &responseRecorder{
-		statusCode: http.StatusOK,
-		headers:    make(http.Header),
-		body:       &strings.Builder{},
-	}
-
-	// Forward to scheduler HTTP handler
-	h.schedulerHTTP.ServeHTTP(respRecorder, newReq)
-
-	// Convert non-streaming response
-	h.convertGenerateResponse(w, respRecorder, modelName)
+	h.convertResponse(w, respRecorder, modelName, generate, start)
 }
 
 // responseRecorder is a custom ResponseWriter that records the response
@@ -811,36 +1138,52 @@ func (rr *responseRecorder) WriteHeader(statusCode int) {
 	rr.statusCode = statusCode
 }
 
-// streamingChatResponseWriter is a custom ResponseWriter that converts OpenAI chat SSE to Ollama format on the fly
-type streamingChatResponseWriter struct {
+// streamingResponseWriter is a custom ResponseWriter that converts OpenAI SSE
+// to Ollama chat or generate chunks on the fly
+type streamingResponseWriter struct {
 	w           http.ResponseWriter
 	modelName   string
 	log         logging.Logger
+	generate    bool      // Write generate chunks rather than chat chunks
+	start       time.Time // When the request started, for its total duration
 	buffer      strings.Builder
 	headersSent bool
+	failed      bool // The request failed, so its error is written
+
+	// Accumulated until the end of the stream
+	toolCalls    []openAIToolCall
+	finishReason string
+	usage        *openAIUsage
+	timings      *openAITimings
 }
 
-func (s *streamingChatResponseWriter) Header() http.Header {
+func (s *streamingResponseWriter) Header() http.Header {
 	return s.w.Header()
 }
 
-func (s *streamingChatResponseWriter) WriteHeader(statusCode int) {
+func (s *streamingResponseWriter) WriteHeader(statusCode int) {
 	s.headersSent = true
+	s.w.Header().Set("Content-Type", "application/json")
 	if statusCode != http.StatusOK {
 		// Pass through non-success status codes
+		s.failed = true
 		s.w.WriteHeader(statusCode)
 		return
 	}
 	// Set headers for Ollama streaming
-	s.w.Header().Set("Content-Type", "application/json")
 	s.w.Header().Set("Transfer-Encoding", "chunked")
 	s.w.WriteHeader(statusCode)
 }
 
-func (s *streamingChatResponseWriter) Write(data []byte) (int, error) {
+func (s *streamingResponseWriter) Write(data []byte) (int, error) {
 	if !s.headersSent {
 		s.WriteHeader(http.StatusOK)
 	}
+	if s.failed {
+		// Errors are written at once, so they're converted as written
+		s.writeChunk(map[string]string{"error": errorMessage(string(data))})
+		return len(data), nil
+	}
 
 	// Add data to buffer
 	s.buffer.Write(data)
@@ -864,181 +1207,144 @@ func (s *streamingChatResponseWriter) Write(data []byte) (int, error) {
 		if !strings.HasPrefix(line, "data: ") {
 			continue
 		}
-
-		dataStr := strings.TrimPrefix(line, "data: ")
-		if dataStr == "[DONE]" {
-			// Send final done message
-			finalResp := ChatResponse{
-				Model:     s.modelName,
-				CreatedAt: time.Now(),
-				Done:      true,
-			}
-			if jsonData, err := json.Marshal(finalResp); err == nil {
-				s.w.Write(jsonData)
-				s.w.Write([]byte("\n"))
-			}
-			continue
-		}
-
-		// Parse OpenAI chunk using proper struct
-		var chunk openAIChatStreamChunk
-		if err := json.Unmarshal([]byte(dataStr), &chunk); err != nil {
-			s.log.Warnf("Failed to parse OpenAI chat stream chunk: %v", err)
-			continue
-		}
-
-		// Extract content from structured response
-		var content string
-		if len(chunk.Choices) > 0 {
-			content = chunk.Choices[0].Delta.Content
-		}
-
-		// Build Ollama chunk
-		ollamaChunk := ChatResponse{
-			Model:     s.modelName,
-			CreatedAt: time.Now(),
-			Message: Message{
-				Role:    "assistant",
-				Content: content,
-			},
-			Done: false,
-		}
-
-		if jsonData, err := json.Marshal(ollamaChunk); err == nil {
-			s.w.Write(jsonData)
-			s.w.Write([]byte("\n"))
-		}
-	}
-
-	// Flush if possible
-	if flusher, ok := s.w.(http.Flusher); ok {
-		flusher.Flush()
+		s.processData(strings.TrimPrefix(line, "data: "))
 	}
 
+	s.Flush()
 	return len(data), nil
 }
 
-// streamingGenerateResponseWriter is a custom ResponseWriter that converts OpenAI completion SSE to Ollama format on the fly
-type streamingGenerateResponseWriter struct {
-	w           http.ResponseWriter
-	modelName   string
-	log         logging.Logger
-	buffer      strings.Builder
-	headersSent bool
-}
-
-func (s *streamingGenerateResponseWriter) Header() http.Header {
-	return s.w.Header()
-}
-
-func (s *streamingGenerateResponseWriter) WriteHeader(statusCode int) {
-	s.headersSent = true
-	if statusCode != http.StatusOK {
-		// Pass through non-success status codes
-		s.w.WriteHeader(statusCode)
-		return
+func (s *streamingResponseWriter) Flush() {
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
 	}
-	// Set headers for Ollama streaming
-	s.w.Header().Set("Content-Type", "application/json")
-	s.w.Header().Set("Transfer-Encoding", "chunked")
-	s.w.WriteHeader(statusCode)
 }
 
-func (s *streamingGenerateResponseWriter) Write(data []byte) (int, error) {
-	if !s.headersSent {
-		s.WriteHeader(http.StatusOK)
-	}
-
-	// Add data to buffer
-	s.buffer.Write(data)
-
-	// Process complete lines from buffer
-	bufferStr := s.buffer.String()
-	lines := strings.Split(bufferStr, "\n")
-
-	// Keep the last incomplete line in the buffer
-	if len(lines) > 0 && !strings.HasSuffix(bufferStr, "\n") {
-		s.buffer.Reset()
-		s.buffer.WriteString(lines[len(lines)-1])
-		lines = lines[:len(lines)-1]
-	} else {
-		s.buffer.Reset()
-	}
-
-	// Process complete lines
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		dataStr := strings.TrimPrefix(line, "data: ")
-		if dataStr == "[DONE]" {
-			// Send final done message
-			finalResp := GenerateResponse{
+// processData converts the data of an SSE event to Ollama chunks
+func (s *streamingResponseWriter) processData(dataStr string) {
+	if dataStr == "[DONE]" {
+		// Tool calls are streamed in pieces, so they're sent once complete
+		if !s.generate && len(s.toolCalls) > 0 {
+			s.writeChunk(ChatResponse{
 				Model:     s.modelName,
 				CreatedAt: time.Now(),
-				Done:      true,
-			}
-			if jsonData, err := json.Marshal(finalResp); err == nil {
-				s.w.Write(jsonData)
-				s.w.Write([]byte("\n"))
-			}
-			continue
+				Message:   Message{Role: "assistant", ToolCalls: convertToolCalls(s.toolCalls, s.log)},
+			})
+			s.toolCalls = nil
 		}
 
-		// Parse OpenAI chunk using proper struct
-		var chunk openAIChatStreamChunk
-		if err := json.Unmarshal([]byte(dataStr), &chunk); err != nil {
-			s.log.Warnf("Failed to parse OpenAI chat stream chunk: %v", err)
-			continue
+		// Send final done message
+		metrics := newMetrics(s.start, s.usage, s.timings)
+		if s.generate {
+			s.writeChunk(GenerateResponse{
+				Model:      s.modelName,
+				CreatedAt:  time.Now(),
+				Done:       true,
+				DoneReason: doneReason(s.finishReason),
+				Metrics:    metrics,
+			})
+		} else {
+			s.writeChunk(ChatResponse{
+				Model:      s.modelName,
+				CreatedAt:  time.Now(),
+				Message:    Message{Role: "assistant"},
+				Done:       true,
+				DoneReason: doneReason(s.finishReason),
+				Metrics:    metrics,
+			})
 		}
+		return
+	}
 
-		// Extract content from structured response
-		var content string
-		if len(chunk.Choices) > 0 {
-			content = chunk.Choices[0].Delta.Content
-		}
+	// Parse OpenAI chunk using proper struct
+	var chunk openAIChatStreamChunk
+	if err := json.Unmarshal([]byte(dataStr), &chunk); err != nil {
+		s.log.Warnf("Failed to parse OpenAI chat stream chunk: %v", err)
+		return
+	}
+	if chunk.Error != nil {
+		s.writeChunk(map[string]string{"error": chunk.Error.Message})
+		return
+	}
+	if chunk.Usage != nil {
+		s.usage = chunk.Usage
+	}
+	if chunk.Timings != nil {
+		s.timings = chunk.Timings
+	}
+	if len(chunk.Choices) == 0 {
+		return
+	}
+
+	choice := chunk.Choices[0]
+	for _, call := range choice.Delta.ToolCalls {
+		s.addToolCall(call)
+	}
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		s.finishReason = *choice.FinishReason
+	}
+	content := cmp.Or(choice.Delta.Content, choice.Text)
+	thinking := cmp.Or(choice.Delta.ReasoningContent, choice.Delta.Reasoning)
+	if content == "" && thinking == "" {
+		return
+	}
 
-		// Build Ollama generate chunk
-		ollamaChunk := GenerateResponse{
+	logprobs := convertLogprobs(choice.Logprobs)
+	if s.generate {
+		s.writeChunk(GenerateResponse{
 			Model:     s.modelName,
 			CreatedAt: time.Now(),
 			Response:  content,
-			Done:      false,
-		}
-
-		if jsonData, err := json.Marshal(ollamaChunk); err == nil {
-			s.w.Write(jsonData)
-			s.w.Write([]byte("\n"))
-		}
+			Thinking:  thinking,
+			Logprobs:  logprobs,
+		})
+	} else {
+		s.writeChunk(ChatResponse{
+			Model:     s.modelName,
+			CreatedAt: time.Now(),
+			Message: Message{
+				Role:     "assistant",
+				Content:  content,
+				Thinking: thinking,
+			},
+			Logprobs: logprobs,
+		})
 	}
+}
 
-	// Flush if possible
-	if flusher, ok := s.w.(http.Flusher); ok {
-		flusher.Flush()
+// addToolCall accumulates a streamed piece of a tool call
+func (s *streamingResponseWriter) addToolCall(call openAIToolCall) {
+	if call.Index < 0 || call.Index > len(s.toolCalls) {
+		s.log.Warnf("Ignoring tool call with unexpected index %d", call.Index)
+		return
+	}
+	if call.Index == len(s.toolCalls) {
+		s.toolCalls = append(s.toolCalls, openAIToolCall{Index: call.Index})
+	}
+	toolCall := &s.toolCalls[call.Index]
+	if call.Function.Name != "" {
+		toolCall.Function.Name = call.Function.Name
 	}
+	toolCall.Function.Arguments += call.Function.Arguments
+}
 
-	return len(data), nil
+// writeChunk writes a chunk as a line of JSON
+func (s *streamingResponseWriter) writeChunk(chunk interface{}) {
+	if jsonData, err := json.Marshal(chunk); err == nil {
+		s.w.Write(jsonData)
+		s.w.Write([]byte("\n"))
+	}
 }
 
-// convertChatResponse converts OpenAI chat completion response to Ollama format
-func (h *HTTPHandler) convertChatResponse(w http.ResponseWriter, respRecorder *responseRecorder, modelName string) {
-	// Handle error responses by converting OpenAI format to Ollama format
+// convertResponse converts an OpenAI chat completion or completion response to
+// an Ollama chat or generate response
+func (h *HTTPHandler) convertResponse(w http.ResponseWriter, respRecorder *responseRecorder, modelName string, generate bool, start time.Time) {
+	// Handle error responses by converting them to Ollama format
 	if respRecorder.statusCode != http.StatusOK {
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(respRecorder.statusCode)
-
-		// Try to parse OpenAI error format
-		var openAIErr openAIErrorResponse
-		if err := json.Unmarshal([]byte(respRecorder.body.String()), &openAIErr); err == nil && openAIErr.Error.Message != "" {
-			// Convert to Ollama error format (simple string)
-			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(map[string]string{"error": openAIErr.Error.Message}); err != nil {
-				h.log.Errorf("failed to encode response: %v", err)
-			}
-		} else {
-			// Fallback: return raw error body
-			w.Write([]byte(respRecorder.body.String()))
+		if err := json.NewEncoder(w).Encode(map[string]string{"error": errorMessage(respRecorder.body.String())}); err != nil {
+			h.log.Errorf("failed to encode response: %v", err)
 		}
 		return
 	}
@@ -1051,21 +1357,45 @@ func (h *HTTPHandler) convertChatResponse(w http.ResponseWriter, respRecorder *r
 		return
 	}
 
-	// Extract the message content from structured response
-	var content string
+	// Extract the message from structured response
+	var message openAIMessage
+	var content, finishReason string
+	var logprobs []Logprob
 	if len(openAIResp.Choices) > 0 {
-		content = openAIResp.Choices[0].Message.Content
+		message = openAIResp.Choices[0].Message
+		content = cmp.Or(message.Content, openAIResp.Choices[0].Text)
+		finishReason = openAIResp.Choices[0].FinishReason
+		logprobs = convertLogprobs(openAIResp.Choices[0].Logprobs)
 	}
+	thinking := cmp.Or(message.ReasoningContent, message.Reasoning)
+	metrics := newMetrics(start, openAIResp.Usage, openAIResp.Timings)
 
 	// Build Ollama response
-	response := ChatResponse{
+	var response interface{} = ChatResponse{
 		Model:     modelName,
 		CreatedAt: time.Now(),
 		Message: Message{
-			Role:    "assistant",
-			Content: content,
+			Role:      "assistant",
+			Content:   content,
+			Thinking:  thinking,
+			ToolCalls: convertToolCalls(message.ToolCalls, h.log),
 		},
-		Done: true,
+		Logprobs:   logprobs,
+		Done:       true,
+		DoneReason: doneReason(finishReason),
+		Metrics:    metrics,
+	}
+	if generate {
+		response = GenerateResponse{
+			Model:      modelName,
+			CreatedAt:  time.Now(),
+			Response:   content,
+			Thinking:   thinking,
+			Logprobs:   logprobs,
+			Done:       true,
+			DoneReason: doneReason(finishReason),
+			Metrics:    metrics,
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -1074,51 +1404,97 @@ func (h *HTTPHandler) convertChatResponse(w http.ResponseWriter, respRecorder *r
 	}
 }
 
-// convertGenerateResponse converts OpenAI chat completion response to Ollama generate format
-func (h *HTTPHandler) convertGenerateResponse(w http.ResponseWriter, respRecorder *responseRecorder, modelName string) {
-	// Handle error responses by converting OpenAI format to Ollama format
-	if respRecorder.statusCode != http.StatusOK {
-		w.WriteHeader(respRecorder.statusCode)
+// convertToolCalls converts OpenAI tool calls, whose arguments are encoded as
+// JSON, to Ollama tool calls
+func convertToolCalls(calls []openAIToolCall, log logging.Logger) []ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]ToolCall, len(calls))
+	for i, call := range calls {
+		arguments := map[string]interface{}{}
+		if call.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &arguments); err != nil {
+				log.Warnf("Failed to parse arguments of tool call %s: %v", utils.SanitizeForLog(call.Function.Name, -1), err)
+				arguments = map[string]interface{}{}
+			}
+		}
+		result[i] = ToolCall{Function: ToolCallFunction{
+			Index:     i,
+			Name:      call.Function.Name,
+			Arguments: arguments,
+		}}
+	}
+	return result
+}
 
-		// Try to parse OpenAI error format
-		var openAIErr openAIErrorResponse
-		if err := json.Unmarshal([]byte(respRecorder.body.String()), &openAIErr); err == nil && openAIErr.Error.Message != "" {
-			// Convert to Ollama error format (simple string)
-			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(map[string]string{"error": openAIErr.Error.Message}); err != nil {
-				h.log.Errorf("failed to encode response: %v", err)
+// convertLogprobs converts the log probabilities of an OpenAI chat completion
+// or completion to Ollama log probabilities
+func convertLogprobs(logprobs *openAILogprobs) []Logprob {
+	if logprobs == nil {
+		return nil
+	}
+	if logprobs.Content != nil {
+		return logprobs.Content
+	}
+	result := make([]Logprob, len(logprobs.Tokens))
+	for i, token := range logprobs.Tokens {
+		result[i].TokenLogprob = TokenLogprob{Token: token, Bytes: tokenBytes(token)}
+		if i < len(logprobs.TokenLogprobs) {
+			result[i].Logprob = logprobs.TokenLogprobs[i]
+		}
+		if i < len(logprobs.TopLogprobs) {
+			for token, logprob := range logprobs.TopLogprobs[i] {
+				result[i].TopLogprobs = append(result[i].TopLogprobs, TokenLogprob{Token: token, Logprob: logprob, Bytes: tokenBytes(token)})
 			}
-		} else {
-			// Fallback: return raw error body
-			w.Write([]byte(respRecorder.body.String()))
+			// Completions don't order the most likely tokens
+			slices.SortFunc(result[i].TopLogprobs, func(a, b TokenLogprob) int {
+				return cmp.Or(cmp.Compare(b.Logprob, a.Logprob), strings.Compare(a.Token, b.Token))
+			})
 		}
-		return
 	}
+	return result
+}
 
-	// Parse OpenAI chat response (since we're now using chat completions endpoint)
-	var openAIResp openAIChatResponse
-	if err := json.Unmarshal([]byte(respRecorder.body.String()), &openAIResp); err != nil {
-		h.log.Errorf("Failed to parse OpenAI chat response: %v", err)
-		http.Error(w, "Failed to parse response", http.StatusInternalServerError)
-		return
+// tokenBytes returns the UTF-8 bytes of a token
+func tokenBytes(token string) []int {
+	encoded := make([]int, len(token))
+	for i := range len(token) {
+		encoded[i] = int(token[i])
 	}
+	return encoded
+}
 
-	// Extract the message content from structured response
-	var content string
-	if len(openAIResp.Choices) > 0 {
-		content = openAIResp.Choices[0].Message.Content
+// doneReason converts an OpenAI finish reason to an Ollama done reason
+func doneReason(finishReason string) string {
+	switch finishReason {
+	case "", "tool_calls", "function_call":
+		return "stop"
 	}
+	return finishReason
+}
 
-	// Build Ollama generate response
-	response := GenerateResponse{
-		Model:     modelName,
-		CreatedAt: time.Now(),
-		Response:  content,
-		Done:      true,
+// newMetrics returns the metrics of a response from the token counts of the
+// OpenAI response and, for llama.cpp, its timings
+func newMetrics(start time.Time, usage *openAIUsage, timings *openAITimings) Metrics {
+	metrics := Metrics{TotalDuration: time.Since(start)}
+	if usage != nil {
+		metrics.PromptEvalCount = usage.PromptTokens
+		metrics.EvalCount = usage.CompletionTokens
 	}
+	if timings != nil {
+		metrics.PromptEvalDuration = time.Duration(timings.PromptMS * float64(time.Millisecond))
+		metrics.EvalDuration = time.Duration(timings.PredictedMS * float64(time.Millisecond))
+	}
+	return metrics
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.log.Errorf("Failed to encode response: %v", err)
+// errorMessage returns the message of an error response, which is either an
+// OpenAI error or plain text
+func errorMessage(body string) string {
+	var openAIErr openAIErrorResponse
+	if err := json.Unmarshal([]byte(body), &openAIErr); err == nil && openAIErr.Error.Message != "" {
+		return openAIErr.Error.Message
 	}
+	return strings.TrimSpace(body)
 }
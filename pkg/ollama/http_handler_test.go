@@ -0,0 +1,361 @@
+package ollama
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/distribution/types"
+	"github.com/docker/model-runner/pkg/inference/models"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeScheduler serves OpenAI requests, recording the last one, with a fixed
+// response body, or fixed SSE events for streamed requests.
+type fakeScheduler struct {
+	path    string
+	request map[string]interface{}
+	status  int
+	body    string
+	events  []string
+}
+
+func (f *fakeScheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.path = r.URL.Path
+	f.request = nil
+	json.NewDecoder(r.Body).Decode(&f.request)
+	if f.status != 0 && f.status != http.StatusOK {
+		http.Error(w, f.body, f.status)
+		return
+	}
+	if stream, _ := f.request["stream"].(bool); stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range f.events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, f.body)
+}
+
+// post sends a request to the handler.
+func post(h http.Handler, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return w
+}
+
+// encode encodes a value as JSON for comparisons.
+func encode(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestChat(t *testing.T) {
+	scheduler := &fakeScheduler{body: `{
+		"choices": [{
+			"message": {
+				"role": "assistant",
+				"content": "",
+				"reasoning_content": "Let me check the weather.",
+				"tool_calls": [{"id": "1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]
+			},
+			"finish_reason": "tool_calls"
+		}],
+		"usage": {"prompt_tokens": 12, "completion_tokens": 8},
+		"timings": {"prompt_ms": 20, "predicted_ms": 100}
+	}`}
+	h := NewHTTPHandler(logrus.New(), nil, scheduler, nil, nil)
+
+	w := post(h, APIPrefix+"/chat", `{
+		"model": "ai/qwen3",
+		"stream": false,
+		"think": true,
+		"format": "json",
+		"options": {"temperature": 0.2, "top_k": 20, "num_predict": -1, "stop": ["\n"], "mirostat": 1},
+		"tools": [{"type": "function", "function": {"name": "get_weather", "parameters": {"type": "object"}}}],
+		"messages": [
+			{"role": "user", "content": "What is in this picture?", "images": ["iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR4nGNgYGD4DwABBAEAwS2OUAAAAABJRU5ErkJggg=="]},
+			{"role": "assistant", "content": "", "tool_calls": [{"function": {"name": "get_time", "arguments": {}}}, {"function": {"name": "get_weather", "arguments": {"city": "Paris"}}}]},
+			{"role": "tool", "tool_name": "get_weather", "content": "sunny"},
+			{"role": "tool", "content": "noon"}
+		]
+	}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", w.Code, w.Body)
+	}
+
+	if scheduler.path != chatCompletionsPath {
+		t.Errorf("Expected a chat completions request, got %s", scheduler.path)
+	}
+	request := scheduler.request
+	expected := map[string]interface{}{
+		"temperature":          0.2,
+		"top_k":                float64(20),
+		"stop":                 []interface{}{"\n"},
+		"stream":               false,
+		"chat_template_kwargs": map[string]interface{}{"enable_thinking": true},
+		"response_format":      map[string]interface{}{"type": "json_object"},
+	}
+	for field, value := range expected {
+		if !reflect.DeepEqual(request[field], value) {
+			t.Errorf("Expected %s to be %v, got %v", field, value, request[field])
+		}
+	}
+	for _, field := range []string{"max_tokens", "mirostat", "stream_options"} {
+		if _, ok := request[field]; ok {
+			t.Errorf("Expected no %s, got %v", field, request[field])
+		}
+	}
+	if tools, _ := request["tools"].([]interface{}); len(tools) != 1 {
+		t.Errorf("Expected the tools to be passed through, got %v", request["tools"])
+	}
+
+	messages := encode(t, request["messages"])
+	for _, part := range []string{
+		`{"image_url":{"url":"data:image/png;base64,iVBOR`,
+		`"tool_calls":[{"function":{"arguments":"{}","name":"get_time"},"id":"call_1_0","type":"function"},{"function":{"arguments":"{\"city\":\"Paris\"}","name":"get_weather"},"id":"call_1_1","type":"function"}]`,
+		`{"content":"sunny","role":"tool","tool_call_id":"call_1_1"}`,
+		`{"content":"noon","role":"tool","tool_call_id":"call_1_0"}`,
+	} {
+		if !strings.Contains(messages, part) {
+			t.Errorf("Expected the messages to contain %s, got %s", part, messages)
+		}
+	}
+
+	var response ChatResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !response.Done || response.DoneReason != "stop" || response.Message.Thinking != "Let me check the weather." {
+		t.Errorf("Unexpected response %+v", response)
+	}
+	expectedCalls := []ToolCall{{Function: ToolCallFunction{Name: "get_weather", Arguments: map[string]interface{}{"city": "Paris"}}}}
+	if !reflect.DeepEqual(response.Message.ToolCalls, expectedCalls) {
+		t.Errorf("Expected tool calls %+v, got %+v", expectedCalls, response.Message.ToolCalls)
+	}
+	if response.PromptEvalCount != 12 || response.EvalCount != 8 || response.EvalDuration.Milliseconds() != 100 || response.TotalDuration <= 0 {
+		t.Errorf("Unexpected metrics %+v", response.Metrics)
+	}
+}
+
+// readChunks reads the chunks of a streamed response.
+func readChunks[T any](t *testing.T, w *httptest.ResponseRecorder) []T {
+	t.Helper()
+	var chunks []T
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var chunk T
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			t.Fatalf("Failed to decode chunk %q: %v", scanner.Text(), err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func TestChatStream(t *testing.T) {
+	scheduler := &fakeScheduler{events: []string{
+		`{"choices":[{"delta":{"role":"assistant"}}]}`,
+		`{"choices":[{"delta":{"reasoning_content":"Hmm."}}]}`,
+		`{"choices":[{"delta":{"content":"Checking"}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"1","function":{"name":"get_weather","arguments":"{\"ci"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ty\":\"Paris\"}"}}]}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"tool_calls"}],"timings":{"prompt_ms":5,"predicted_ms":50}}`,
+		`{"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":4}}`,
+	}}
+	h := NewHTTPHandler(logrus.New(), nil, scheduler, nil, nil)
+
+	w := post(h, APIPrefix+"/chat", `{"model": "ai/qwen3", "messages": [{"role": "user", "content": "Weather?"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", w.Code, w.Body)
+	}
+	if !reflect.DeepEqual(scheduler.request["stream_options"], map[string]interface{}{"include_usage": true}) {
+		t.Errorf("Expected usage to be requested, got %v", scheduler.request["stream_options"])
+	}
+
+	chunks := readChunks[ChatResponse](t, w)
+	if len(chunks) != 4 {
+		t.Fatalf("Expected 4 chunks, got %s", w.Body)
+	}
+	if chunks[0].Message.Thinking != "Hmm." || chunks[1].Message.Content != "Checking" {
+		t.Errorf("Unexpected chunks %+v %+v", chunks[0], chunks[1])
+	}
+	if calls := chunks[2].Message.ToolCalls; len(calls) != 1 || calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments["city"] != "Paris" {
+		t.Errorf("Expected the tool call to be accumulated, got %+v", calls)
+	}
+	final := chunks[3]
+	if !final.Done || final.DoneReason != "stop" || final.PromptEvalCount != 3 || final.EvalCount != 4 || final.EvalDuration.Milliseconds() != 50 {
+		t.Errorf("Unexpected final chunk %+v", final)
+	}
+	for _, chunk := range chunks[:3] {
+		if chunk.Done {
+			t.Errorf("Expected only the final chunk to be done, got %+v", chunk)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	scheduler := &fakeScheduler{body: `{"choices":[{"text":"world","finish_reason":"length"}],"usage":{"prompt_tokens":1,"completion_tokens":1}}`}
+	h := NewHTTPHandler(logrus.New(), nil, scheduler, nil, nil)
+
+	// Raw prompts are completed without the chat template
+	w := post(h, APIPrefix+"/generate", `{"model": "ai/smollm2", "prompt": "Hello", "raw": true, "stream": false, "options": {"num_predict": 1}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", w.Code, w.Body)
+	}
+	if scheduler.path != completionsPath || scheduler.request["prompt"] != "Hello" || scheduler.request["max_tokens"] != float64(1) {
+		t.Errorf("Unexpected request to %s: %v", scheduler.path, scheduler.request)
+	}
+	var response GenerateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Response != "world" || !response.Done || response.DoneReason != "length" {
+		t.Errorf("Unexpected response %+v", response)
+	}
+
+	// Other prompts are chat completions, with the system prompt
+	scheduler.events = []string{`{"choices":[{"delta":{"content":"Hi"}}]}`, `{"choices":[{"delta":{"content":"!"},"finish_reason":"stop"}]}`}
+	w = post(h, APIPrefix+"/generate", `{"model": "ai/smollm2", "prompt": "Hello", "system": "Be nice.", "format": {"type": "object"}}`)
+	if scheduler.path != chatCompletionsPath {
+		t.Errorf("Expected a chat completions request, got %s", scheduler.path)
+	}
+	expectedMessages := `[{"content":"Be nice.","role":"system"},{"content":"Hello","role":"user"}]`
+	if messages := encode(t, scheduler.request["messages"]); messages != expectedMessages {
+		t.Errorf("Expected messages %s, got %s", expectedMessages, messages)
+	}
+	expectedFormat := `{"json_schema":{"name":"response","schema":{"type":"object"},"strict":true},"type":"json_schema"}`
+	if format := encode(t, scheduler.request["response_format"]); format != expectedFormat {
+		t.Errorf("Expected response format %s, got %s", expectedFormat, format)
+	}
+	chunks := readChunks[GenerateResponse](t, w)
+	if len(chunks) != 3 || chunks[0].Response+chunks[1].Response != "Hi!" || !chunks[2].Done {
+		t.Errorf("Unexpected chunks %s", w.Body)
+	}
+
+	if w := post(h, APIPrefix+"/generate", `{"model": "ai/smollm2", "prompt": "Hello", "format": "xml"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unsupported format to be rejected, got %d", w.Code)
+	}
+}
+
+func TestLogprobs(t *testing.T) {
+	scheduler := &fakeScheduler{body: `{"choices":[{"text":"Hi","logprobs":{"tokens":["Hi"],"token_logprobs":[-0.5],"top_logprobs":[{"Hey":-2,"Hi":-0.5}]},"finish_reason":"stop"}]}`}
+	h := NewHTTPHandler(logrus.New(), nil, scheduler, nil, nil)
+
+	// Raw prompts request log probabilities as completions do
+	w := post(h, APIPrefix+"/generate", `{"model": "ai/smollm2", "prompt": "Hello", "raw": true, "stream": false, "logprobs": true, "top_logprobs": 2}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", w.Code, w.Body)
+	}
+	if scheduler.request["logprobs"] != float64(2) || scheduler.request["top_logprobs"] != nil {
+		t.Errorf("Unexpected request %v", scheduler.request)
+	}
+	var response GenerateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"token":"Hi","logprob":-0.5,"bytes":[72,105],"top_logprobs":[{"token":"Hi","logprob":-0.5,"bytes":[72,105]},{"token":"Hey","logprob":-2,"bytes":[72,101,121]}]}]`
+	if logprobs := encode(t, response.Logprobs); logprobs != expected {
+		t.Errorf("Expected log probabilities %s, got %s", expected, logprobs)
+	}
+
+	// Chat requests request them as chat completions do
+	scheduler.events = []string{
+		`{"choices":[{"delta":{"content":"Hi"},"logprobs":{"content":[{"token":"Hi","logprob":-0.5,"bytes":[72,105],"top_logprobs":[]}]}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"stop"}]}`,
+	}
+	w = post(h, APIPrefix+"/chat", `{"model": "ai/smollm2", "messages": [{"role": "user", "content": "Hello"}], "logprobs": true}`)
+	if scheduler.request["logprobs"] != true || scheduler.request["top_logprobs"] != nil {
+		t.Errorf("Unexpected request %v", scheduler.request)
+	}
+	chunks := readChunks[ChatResponse](t, w)
+	if len(chunks) != 2 || len(chunks[0].Logprobs) != 1 || chunks[0].Logprobs[0].Token != "Hi" || chunks[0].Logprobs[0].Logprob != -0.5 {
+		t.Errorf("Unexpected chunks %s", w.Body)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	scheduler := &fakeScheduler{status: http.StatusNotFound, body: "model not found"}
+	h := NewHTTPHandler(logrus.New(), nil, scheduler, nil, nil)
+
+	for _, body := range []string{
+		`{"model": "ai/missing", "stream": false, "messages": [{"role": "user", "content": "Hi"}]}`,
+		`{"model": "ai/missing", "messages": [{"role": "user", "content": "Hi"}]}`,
+	} {
+		w := post(h, APIPrefix+"/chat", body)
+		if w.Code != http.StatusNotFound || strings.TrimSpace(w.Body.String()) != `{"error":"model not found"}` {
+			t.Errorf("Expected the error in Ollama format, got %d: %s", w.Code, w.Body)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for size, expected := range map[string]int64{
+		"1.00GiB": 1 << 30,
+		"2.50GB":  2500000000,
+		"512MiB":  512 << 20,
+		"":        0,
+		"unknown": 0,
+	} {
+		if actual := parseSize(size); actual != expected {
+			t.Errorf("Expected %q to be %d bytes, got %d", size, expected, actual)
+		}
+	}
+}
+
+func TestModelInfoAndCapabilities(t *testing.T) {
+	config := types.Config{GGUF: map[string]string{
+		"general.architecture":    "qwen3",
+		"qwen3.context_length":    "40960",
+		"qwen3.rope.freq_base":    "1000000.5",
+		"general.name":            "Qwen3 8B",
+		"tokenizer.ggml.model":    "gpt2",
+		"tokenizer.chat_template": "{% if tools %}...{% endif %}<think>",
+	}}
+	inspection := models.ModelInspection{
+		Architecture:  "qwen3",
+		ContextLength: 40960,
+		Modalities:    models.ModelModalities{Input: []string{"text", "image"}, Output: []string{"text"}},
+	}
+
+	info := modelInfo(config, inspection, false)
+	expected := map[string]interface{}{
+		"general.architecture": "qwen3",
+		"qwen3.context_length": int64(40960),
+		"qwen3.rope.freq_base": 1000000.5,
+		"general.name":         "Qwen3 8B",
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("Expected model info %v, got %v", expected, info)
+	}
+	if info := modelInfo(config, inspection, true); info["tokenizer.ggml.model"] != "gpt2" {
+		t.Errorf("Expected verbose model info to include the tokenizer, got %v", info)
+	}
+
+	// Models without GGUF metadata are described from their inspection
+	info = modelInfo(types.Config{}, inspection, false)
+	if info["general.architecture"] != "qwen3" || info["qwen3.context_length"] != uint64(40960) {
+		t.Errorf("Unexpected model info %v", info)
+	}
+
+	expectedCapabilities := []string{"completion", "vision", "tools", "thinking"}
+	if actual := capabilities(inspection, config.GGUF["tokenizer.chat_template"]); !reflect.DeepEqual(actual, expectedCapabilities) {
+		t.Errorf("Expected capabilities %v, got %v", expectedCapabilities, actual)
+	}
+	embedding := models.ModelInspection{Modalities: models.ModelModalities{Input: []string{"text"}, Output: []string{"embedding"}}}
+	if actual := capabilities(embedding, ""); !reflect.DeepEqual(actual, []string{"embedding"}) {
+		t.Errorf("Expected an embedding model, got %v", actual)
+	}
+}
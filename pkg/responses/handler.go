@@ -0,0 +1,222 @@
+package responses
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/internal/relay"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// maximumRequestSize is the maximum size of a request body, matching the
+// limit applied by the scheduler.
+const maximumRequestSize = 10 * 1024 * 1024
+
+// maximumStoredResponses is the number of stored responses, which later
+// requests can continue with previous_response_id, beyond which the oldest
+// are forgotten.
+const maximumStoredResponses = 1000
+
+// storedResponse is a stored response.
+type storedResponse struct {
+	// response is the response.
+	response *Response
+	// conversation are the messages of the conversation that the response
+	// continued, without its instructions, followed by its output.
+	conversation []chatMessage
+}
+
+// HTTPHandler serves the Responses API by translating its requests to chat
+// completions requests served by the inference handler. Responses are stored
+// in memory, so that conversations can be continued without sending them
+// again until they're forgotten or the model runner restarts.
+type HTTPHandler struct {
+	// log is the associated logger.
+	log logging.Logger
+	// router is the HTTP request router.
+	router *http.ServeMux
+	// next is the inference handler.
+	next http.Handler
+	// lock protects the fields below.
+	lock sync.Mutex
+	// responses are the stored responses, indexed by ID.
+	responses map[string]*storedResponse
+	// order are the IDs of the stored responses, oldest first.
+	order []string
+}
+
+// NewHTTPHandler creates a new Responses API handler that forwards chat
+// completions requests to next, which should serve the inference API.
+func NewHTTPHandler(log logging.Logger, next http.Handler) *HTTPHandler {
+	h := &HTTPHandler{
+		log:       log,
+		router:    http.NewServeMux(),
+		next:      next,
+		responses: make(map[string]*storedResponse),
+	}
+	for _, prefix := range Prefixes() {
+		h.router.HandleFunc("POST "+prefix+Path, h.handleCreate)
+		h.router.HandleFunc("GET "+prefix+Path+"/{id}", h.handleGet)
+		h.router.HandleFunc("DELETE "+prefix+Path+"/{id}", h.handleDelete)
+	}
+	return h
+}
+
+// Prefixes returns the prefixes under which the Responses API is served: the
+// root, like the other OpenAI endpoints, and the inference prefix, with or
+// without a backend.
+func Prefixes() []string {
+	return []string{"", inference.InferencePrefix, inference.InferencePrefix + "/{backend}"}
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// handleCreate handles POST /v1/responses requests.
+func (h *HTTPHandler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maximumRequestSize))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(w, "request too large", http.StatusBadRequest)
+		} else {
+			http.Error(w, "failed to read request body", http.StatusInternalServerError)
+		}
+		return
+	}
+	var request Request
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	var history []chatMessage
+	if request.PreviousResponseID != "" {
+		previous, ok := h.stored(request.PreviousResponseID)
+		if !ok {
+			http.Error(w, "previous response "+request.PreviousResponseID+" not found", http.StatusBadRequest)
+			return
+		}
+		history = previous.conversation
+	}
+	chat, conversation, err := chatRequest(&request, history)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	chatBody, err := json.Marshal(chat)
+	if err != nil {
+		http.Error(w, "failed to encode request", http.StatusInternalServerError)
+		return
+	}
+
+	upstream := r.Clone(r.Context())
+	upstream.URL.Path = relay.ChatCompletionsPath(r.URL.Path, "/responses")
+	upstream.URL.RawPath = ""
+	upstream.Body = io.NopCloser(bytes.NewReader(chatBody))
+	upstream.ContentLength = int64(len(chatBody))
+	upstream.Header.Set("Content-Type", "application/json")
+	upstream.Header.Set(inference.RequestOriginHeader, inference.OriginResponses)
+
+	response := newResponse(&request, time.Now().Unix())
+	if request.Stream {
+		stream := newStreamWriter(w, h.log, response)
+		h.next.ServeHTTP(stream, upstream)
+		stream.end()
+		if response.Status == StatusCompleted || response.Status == StatusIncomplete {
+			h.store(response, conversation)
+		}
+		return
+	}
+
+	recorder := relay.NewRecorder()
+	h.next.ServeHTTP(recorder, upstream)
+	if recorder.Status() != http.StatusOK {
+		recorder.Replay(w)
+		return
+	}
+	if err := translateResponse(response, recorder.Body()); err != nil {
+		h.log.Warnf("Failed to translate chat completions response: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	h.store(response, conversation)
+	h.writeJSON(w, response)
+}
+
+// handleGet handles GET /v1/responses/{id} requests.
+func (h *HTTPHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	stored, ok := h.stored(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "response not found", http.StatusNotFound)
+		return
+	}
+	h.writeJSON(w, stored.response)
+}
+
+// handleDelete handles DELETE /v1/responses/{id} requests.
+func (h *HTTPHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	h.lock.Lock()
+	_, ok := h.responses[id]
+	delete(h.responses, id)
+	h.lock.Unlock()
+	if !ok {
+		http.Error(w, "response not found", http.StatusNotFound)
+		return
+	}
+	h.writeJSON(w, map[string]any{"id": id, "object": "response", "deleted": true})
+}
+
+// store stores a response, if requested, forgetting the oldest responses
+// beyond maximumStoredResponses.
+func (h *HTTPHandler) store(response *Response, conversation []chatMessage) {
+	if !response.Store {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.responses[response.ID] = &storedResponse{
+		response:     response,
+		conversation: append(conversation, outputMessages(response.Output)...),
+	}
+	h.order = append(h.order, response.ID)
+	for len(h.responses) > maximumStoredResponses {
+		delete(h.responses, h.order[0])
+		h.order = h.order[1:]
+	}
+	// Drop the IDs of deleted responses once they dominate.
+	if len(h.order) > 2*maximumStoredResponses {
+		live := h.order[:0]
+		for _, id := range h.order {
+			if _, ok := h.responses[id]; ok {
+				live = append(live, id)
+			}
+		}
+		h.order = live
+	}
+}
+
+// stored returns a stored response.
+func (h *HTTPHandler) stored(id string) (*storedResponse, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	stored, ok := h.responses[id]
+	return stored, ok
+}
+
+// writeJSON writes a JSON response.
+func (h *HTTPHandler) writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.log.Warnf("Failed to encode response: %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package responses
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeChat serves chat completions, recording their requests, and replies
+// with a fixed message or, for streamed requests, with fixed chunks.
+type fakeChat struct {
+	// path is the path of the last request.
+	path string
+	// origin is the origin header of the last request.
+	origin string
+	// messages are the messages of the last request.
+	messages []json.RawMessage
+	// chunks are the chunks of streamed responses.
+	chunks []string
+}
+
+func (f *fakeChat) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Model    string            `json:"model"`
+		Messages []json.RawMessage `json:"messages"`
+		Stream   bool              `json:"stream"`
+	}
+	json.NewDecoder(r.Body).Decode(&request)
+	f.path = r.URL.Path
+	f.origin = r.Header.Get(inference.RequestOriginHeader)
+	f.messages = request.Messages
+	if request.Model == "missing" {
+		http.Error(w, "model not found", http.StatusNotFound)
+		return
+	}
+	if request.Stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range f.chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"reply %d"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`, len(request.Messages))
+}
+
+// create sends a Responses API request to the handler.
+func create(t *testing.T, h http.Handler, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	chat := &fakeChat{}
+	h := NewHTTPHandler(logrus.New(), chat)
+
+	w := create(t, h, Path, `{"model": "ai/smollm2", "instructions": "Be brief.", "input": "Hi"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", w.Code, w.Body)
+	}
+	var first Response
+	if err := json.Unmarshal(w.Body.Bytes(), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Object != "response" || first.Status != StatusCompleted || len(first.Output) != 1 || first.Output[0].Content[0].Text != "reply 2" {
+		t.Errorf("Unexpected response %+v", first)
+	}
+	if first.Usage == nil || first.Usage.TotalTokens != 5 {
+		t.Errorf("Unexpected usage %+v", first.Usage)
+	}
+	if chat.path != "/engines/v1/chat/completions" || chat.origin != inference.OriginResponses {
+		t.Errorf("Unexpected chat completions request to %s with origin %q", chat.path, chat.origin)
+	}
+
+	// Continuing the conversation sends the previous messages and output,
+	// without the previous instructions.
+	w = create(t, h, inference.InferencePrefix+"/llama.cpp"+Path, fmt.Sprintf(`{"model": "ai/smollm2", "previous_response_id": %q, "input": "And you?"}`, first.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", w.Code, w.Body)
+	}
+	if len(chat.messages) != 3 || !strings.Contains(string(chat.messages[1]), "reply 2") {
+		t.Errorf("Expected the previous conversation to be continued, got %s", chat.messages)
+	}
+	if chat.path != "/engines/llama.cpp/v1/chat/completions" {
+		t.Errorf("Expected the backend to be kept, got %s", chat.path)
+	}
+
+	get := httptest.NewRecorder()
+	h.ServeHTTP(get, httptest.NewRequest(http.MethodGet, Path+"/"+first.ID, nil))
+	if get.Code != http.StatusOK || !strings.Contains(get.Body.String(), first.ID) {
+		t.Errorf("Expected the stored response, got %d: %s", get.Code, get.Body)
+	}
+	del := httptest.NewRecorder()
+	h.ServeHTTP(del, httptest.NewRequest(http.MethodDelete, Path+"/"+first.ID, nil))
+	if del.Code != http.StatusOK {
+		t.Errorf("Unexpected status deleting the response: %d", del.Code)
+	}
+	if w := create(t, h, Path, fmt.Sprintf(`{"model": "ai/smollm2", "previous_response_id": %q, "input": "Hi"}`, first.ID)); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown previous response to be rejected, got %d", w.Code)
+	}
+
+	// Responses that aren't stored can't be continued.
+	w = create(t, h, Path, `{"model": "ai/smollm2", "input": "Hi", "store": false}`)
+	var unstored Response
+	json.Unmarshal(w.Body.Bytes(), &unstored)
+	if w := create(t, h, Path, fmt.Sprintf(`{"model": "ai/smollm2", "previous_response_id": %q, "input": "Hi"}`, unstored.ID)); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a response that isn't stored to be unknown, got %d", w.Code)
+	}
+
+	// Errors of the chat completions request are passed through.
+	if w := create(t, h, Path, `{"model": "missing", "input": "Hi"}`); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "model not found") {
+		t.Errorf("Expected the error to be passed through, got %d: %s", w.Code, w.Body)
+	}
+	if w := create(t, h, Path, `{"model": "ai/smollm2", "input": 1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid input to be rejected, got %d", w.Code)
+	}
+}
+
+// event is an event of a streamed response.
+type event struct {
+	name string
+	data map[string]any
+}
+
+// readEvents reads the events of a streamed response.
+func readEvents(t *testing.T, body io.Reader) []event {
+	t.Helper()
+	var events []event
+	scanner := bufio.NewScanner(body)
+	var name string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "event: "); ok {
+			name = value
+		} else if value, ok := strings.CutPrefix(line, "data: "); ok {
+			var data map[string]any
+			if err := json.Unmarshal([]byte(value), &data); err != nil {
+				t.Fatalf("Failed to decode event %q: %v", value, err)
+			}
+			if data["type"] != name {
+				t.Errorf("Expected the event %s to have the same type, got %v", name, data["type"])
+			}
+			if int(data["sequence_number"].(float64)) != len(events) {
+				t.Errorf("Expected sequence number %d, got %v", len(events), data["sequence_number"])
+			}
+			events = append(events, event{name: name, data: data})
+		}
+	}
+	return events
+}
+
+func TestHandlerStream(t *testing.T) {
+	chat := &fakeChat{chunks: []string{
+		`{"choices":[{"delta":{"role":"assistant","reasoning_content":"Think"}}]}`,
+		`{"choices":[{"delta":{"reasoning_content":"ing."}}]}`,
+		`{"choices":[{"delta":{"content":"Hel"}}]}`,
+		`{"choices":[{"delta":{"content":"lo"}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"ci"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ty\":1}"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"time","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":7,"total_tokens":10}}`,
+	}}
+	h := NewHTTPHandler(logrus.New(), chat)
+	w := create(t, h, Path, `{"model": "ai/qwen3", "input": "Weather?", "stream": true}`)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Unexpected status %d with content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	events := readEvents(t, w.Body)
+	var names []string
+	for _, e := range events {
+		names = append(names, e.name)
+	}
+	expected := []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added",
+		"response.reasoning_text.delta",
+		"response.reasoning_text.delta",
+		"response.reasoning_text.done",
+		"response.output_item.done",
+		"response.output_item.added",
+		"response.content_part.added",
+		"response.output_text.delta",
+		"response.output_text.delta",
+		"response.output_text.done",
+		"response.content_part.done",
+		"response.output_item.done",
+		"response.output_item.added",
+		"response.function_call_arguments.delta",
+		"response.function_call_arguments.delta",
+		"response.function_call_arguments.done",
+		"response.output_item.done",
+		"response.output_item.added",
+		"response.function_call_arguments.delta",
+		"response.function_call_arguments.done",
+		"response.output_item.done",
+		"response.completed",
+	}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Unexpected events:\n%s", strings.Join(names, "\n"))
+	}
+	if text := events[11].data["text"]; text != "Hello" {
+		t.Errorf("Expected the text to be Hello, got %v", text)
+	}
+	if arguments := events[17].data["arguments"]; arguments != `{"city":1}` {
+		t.Errorf("Expected the arguments to be accumulated, got %v", arguments)
+	}
+
+	completed, _ := json.Marshal(events[len(events)-1].data["response"])
+	var response Response
+	if err := json.Unmarshal(completed, &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != StatusCompleted || len(response.Output) != 4 || response.Usage == nil || response.Usage.OutputTokens != 7 {
+		t.Errorf("Unexpected completed response %s", completed)
+	}
+	if item := response.Output[2]; item.Status != StatusCompleted || item.CallID != "call_1" || *item.Arguments != `{"city":1}` {
+		t.Errorf("Unexpected function call %+v", item)
+	}
+
+	// Streamed responses are stored once completed.
+	w = create(t, h, Path, fmt.Sprintf(`{"model": "ai/qwen3", "previous_response_id": %q, "input": [{"type": "function_call_output", "call_id": "call_1", "output": "sunny"}]}`, response.ID))
+	if w.Code != http.StatusOK || len(chat.messages) != 3 || !strings.Contains(string(chat.messages[1]), "call_2") {
+		t.Errorf("Expected the streamed response to be continued, got %d with messages %s", w.Code, chat.messages)
+	}
+
+	// Streams that end without completing fail the response.
+	chat.chunks = []string{`{"error":{"message":"out of memory"}}`}
+	events = readEvents(t, create(t, h, Path, `{"model": "ai/qwen3", "input": "Hi", "stream": true}`).Body)
+	if last := events[len(events)-1]; last.name != "response.failed" {
+		t.Errorf("Expected the response to fail, got %s", last.name)
+	}
+}
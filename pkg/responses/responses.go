@@ -0,0 +1,654 @@
+// Package responses implements the OpenAI Responses API (/v1/responses) on
+// top of the chat completions API of the backends: requests, including
+// multi-turn items and function calls, are translated to chat completions
+// requests, and their responses, streamed or not, to responses.
+package responses
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Path is the path of the Responses API.
+const Path = "/v1/responses"
+
+// ErrInvalidRequest indicates that a request can't be translated.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Request is a Responses API request.
+type Request struct {
+	Model              string            `json:"model"`
+	Input              json.RawMessage   `json:"input"`
+	Instructions       string            `json:"instructions,omitempty"`
+	PreviousResponseID string            `json:"previous_response_id,omitempty"`
+	Tools              []Tool            `json:"tools,omitempty"`
+	ToolChoice         json.RawMessage   `json:"tool_choice,omitempty"`
+	ParallelToolCalls  *bool             `json:"parallel_tool_calls,omitempty"`
+	Temperature        *float64          `json:"temperature,omitempty"`
+	TopP               *float64          `json:"top_p,omitempty"`
+	MaxOutputTokens    *int              `json:"max_output_tokens,omitempty"`
+	Text               *TextConfig       `json:"text,omitempty"`
+	Reasoning          *ReasoningConfig  `json:"reasoning,omitempty"`
+	Stream             bool              `json:"stream,omitempty"`
+	Store              *bool             `json:"store,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	User               string            `json:"user,omitempty"`
+}
+
+// Tool is a tool that models may call. Only function tools are supported.
+type Tool struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+// TextConfig configures the format of text outputs.
+type TextConfig struct {
+	Format *TextFormat `json:"format,omitempty"`
+}
+
+// TextFormat is the format of text outputs: text, json_object, or
+// json_schema.
+type TextFormat struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+// ReasoningConfig configures the reasoning of reasoning models.
+type ReasoningConfig struct {
+	Effort string `json:"effort,omitempty"`
+}
+
+// Response is a Responses API response.
+type Response struct {
+	ID                 string             `json:"id"`
+	Object             string             `json:"object"`
+	CreatedAt          int64              `json:"created_at"`
+	Status             string             `json:"status"`
+	Error              *ResponseError     `json:"error"`
+	IncompleteDetails  *IncompleteDetails `json:"incomplete_details"`
+	Instructions       *string            `json:"instructions"`
+	MaxOutputTokens    *int               `json:"max_output_tokens"`
+	Model              string             `json:"model"`
+	Output             []OutputItem       `json:"output"`
+	ParallelToolCalls  bool               `json:"parallel_tool_calls"`
+	PreviousResponseID *string            `json:"previous_response_id"`
+	Reasoning          *ReasoningConfig   `json:"reasoning,omitempty"`
+	Store              bool               `json:"store"`
+	Temperature        *float64           `json:"temperature"`
+	Text               TextConfig         `json:"text"`
+	ToolChoice         json.RawMessage    `json:"tool_choice"`
+	Tools              []Tool             `json:"tools"`
+	TopP               *float64           `json:"top_p"`
+	Usage              *Usage             `json:"usage"`
+	User               string             `json:"user,omitempty"`
+	Metadata           map[string]string  `json:"metadata"`
+}
+
+// ResponseError is the error of a failed response.
+type ResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// IncompleteDetails explains why a response is incomplete.
+type IncompleteDetails struct {
+	Reason string `json:"reason"`
+}
+
+// OutputItem is an item of the output of a response: a message, a function
+// call, or reasoning.
+type OutputItem struct {
+	Type      string          `json:"type"`
+	ID        string          `json:"id"`
+	Status    string          `json:"status,omitempty"`
+	Role      string          `json:"role,omitempty"`
+	Content   []OutputContent `json:"content,omitempty"`
+	Summary   []OutputContent `json:"summary,omitempty"`
+	CallID    string          `json:"call_id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Arguments *string         `json:"arguments,omitempty"`
+}
+
+// OutputContent is a part of the content of an output item.
+type OutputContent struct {
+	Type        string            `json:"type"`
+	Text        string            `json:"text"`
+	Annotations []json.RawMessage `json:"annotations"`
+}
+
+// Usage is the token usage of a response.
+type Usage struct {
+	InputTokens         int                 `json:"input_tokens"`
+	InputTokensDetails  InputTokensDetails  `json:"input_tokens_details"`
+	OutputTokens        int                 `json:"output_tokens"`
+	OutputTokensDetails OutputTokensDetails `json:"output_tokens_details"`
+	TotalTokens         int                 `json:"total_tokens"`
+}
+
+// InputTokensDetails details the input tokens of a response.
+type InputTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+// OutputTokensDetails details the output tokens of a response.
+type OutputTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+// Statuses of responses and output items.
+const (
+	StatusCompleted  = "completed"
+	StatusIncomplete = "incomplete"
+	StatusInProgress = "in_progress"
+	StatusFailed     = "failed"
+)
+
+// newID generates a random ID with a prefix, such as resp or msg.
+func newID(prefix string) string {
+	id := make([]byte, 12)
+	rand.Read(id)
+	return prefix + "_" + hex.EncodeToString(id)
+}
+
+// chatMessage is a chat completions message.
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    any            `json:"content,omitempty"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// chatContentPart is a part of the content of a chat completions message.
+type chatContentPart struct {
+	Type     string         `json:"type"`
+	Text     string         `json:"text,omitempty"`
+	ImageURL *chatImageURL  `json:"image_url,omitempty"`
+	Audio    *chatAudioData `json:"input_audio,omitempty"`
+}
+
+// chatImageURL is an image in a chat completions message.
+type chatImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// chatAudioData is audio in a chat completions message.
+type chatAudioData struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
+
+// chatToolCall is a tool call of a chat completions message.
+type chatToolCall struct {
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function chatFunction `json:"function"`
+}
+
+// chatFunction is the function of a chat completions tool call.
+type chatFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// inputItem is an item of the input of a request: a message, a function call
+// or its output, or reasoning, which is dropped.
+type inputItem struct {
+	Type      string          `json:"type"`
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"`
+	CallID    string          `json:"call_id"`
+	Name      string          `json:"name"`
+	Arguments string          `json:"arguments"`
+	Output    json.RawMessage `json:"output"`
+}
+
+// inputContent is a part of the content of an input message.
+type inputContent struct {
+	Type       string `json:"type"`
+	Text       string `json:"text"`
+	ImageURL   string `json:"image_url"`
+	Detail     string `json:"detail"`
+	InputAudio *struct {
+		Data   string `json:"data"`
+		Format string `json:"format"`
+	} `json:"input_audio"`
+}
+
+// inputMessages translates the input of a request, a string or an array of
+// items, to chat completions messages.
+func inputMessages(input json.RawMessage) ([]chatMessage, error) {
+	var text string
+	if err := json.Unmarshal(input, &text); err == nil {
+		return []chatMessage{{Role: "user", Content: text}}, nil
+	}
+	var items []inputItem
+	if err := json.Unmarshal(input, &items); err != nil {
+		return nil, fmt.Errorf("%w: input must be a string or an array of items", ErrInvalidRequest)
+	}
+
+	var messages []chatMessage
+	for i, item := range items {
+		switch item.Type {
+		case "message", "":
+			message, err := itemMessage(item)
+			if err != nil {
+				return nil, fmt.Errorf("input item %d: %w", i, err)
+			}
+			messages = append(messages, message)
+		case "function_call":
+			if item.CallID == "" || item.Name == "" {
+				return nil, fmt.Errorf("%w: input item %d: function calls require a call_id and a name", ErrInvalidRequest, i)
+			}
+			call := chatToolCall{ID: item.CallID, Type: "function", Function: chatFunction{Name: item.Name, Arguments: item.Arguments}}
+			// Consecutive function calls are parallel calls of the same
+			// assistant message, which may also have text.
+			if last := len(messages) - 1; last >= 0 && messages[last].Role == "assistant" {
+				messages[last].ToolCalls = append(messages[last].ToolCalls, call)
+			} else {
+				messages = append(messages, chatMessage{Role: "assistant", ToolCalls: []chatToolCall{call}})
+			}
+		case "function_call_output":
+			if item.CallID == "" {
+				return nil, fmt.Errorf("%w: input item %d: function call outputs require a call_id", ErrInvalidRequest, i)
+			}
+			output, err := contentText(item.Output)
+			if err != nil {
+				return nil, fmt.Errorf("input item %d: %w", i, err)
+			}
+			messages = append(messages, chatMessage{Role: "tool", ToolCallID: item.CallID, Content: output})
+		case "reasoning":
+			// Chat templates drop the reasoning of previous turns.
+		default:
+			return nil, fmt.Errorf("%w: input item %d: unsupported type %q", ErrInvalidRequest, i, item.Type)
+		}
+	}
+	return messages, nil
+}
+
+// itemMessage translates an input message item to a chat completions
+// message.
+func itemMessage(item inputItem) (chatMessage, error) {
+	role := item.Role
+	switch role {
+	case "user", "assistant", "system":
+	case "developer":
+		role = "system"
+	default:
+		return chatMessage{}, fmt.Errorf("%w: unsupported role %q", ErrInvalidRequest, item.Role)
+	}
+	var text string
+	if err := json.Unmarshal(item.Content, &text); err == nil {
+		return chatMessage{Role: role, Content: text}, nil
+	}
+	var parts []inputContent
+	if err := json.Unmarshal(item.Content, &parts); err != nil {
+		return chatMessage{}, fmt.Errorf("%w: content must be a string or an array of parts", ErrInvalidRequest)
+	}
+	var chatParts []chatContentPart
+	textOnly := true
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text", "text", "refusal":
+			chatParts = append(chatParts, chatContentPart{Type: "text", Text: part.Text})
+		case "input_image":
+			if part.ImageURL == "" {
+				return chatMessage{}, fmt.Errorf("%w: images require an image_url", ErrInvalidRequest)
+			}
+			chatParts = append(chatParts, chatContentPart{Type: "image_url", ImageURL: &chatImageURL{URL: part.ImageURL, Detail: part.Detail}})
+			textOnly = false
+		case "input_audio":
+			if part.InputAudio == nil {
+				return chatMessage{}, fmt.Errorf("%w: audio requires input_audio", ErrInvalidRequest)
+			}
+			chatParts = append(chatParts, chatContentPart{Type: "input_audio", Audio: &chatAudioData{Data: part.InputAudio.Data, Format: part.InputAudio.Format}})
+			textOnly = false
+		default:
+			return chatMessage{}, fmt.Errorf("%w: unsupported content type %q", ErrInvalidRequest, part.Type)
+		}
+	}
+	// Chat templates of text-only models expect string content.
+	if textOnly {
+		var texts []string
+		for _, part := range chatParts {
+			texts = append(texts, part.Text)
+		}
+		return chatMessage{Role: role, Content: strings.Join(texts, "\n")}, nil
+	}
+	return chatMessage{Role: role, Content: chatParts}, nil
+}
+
+// contentText returns the text of the output of a function call, which is a
+// string or an array of text parts.
+func contentText(content json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return text, nil
+	}
+	var parts []inputContent
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return "", fmt.Errorf("%w: output must be a string or an array of parts", ErrInvalidRequest)
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type != "input_text" && part.Type != "output_text" {
+			return "", fmt.Errorf("%w: unsupported output content type %q", ErrInvalidRequest, part.Type)
+		}
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// chatRequest translates a request, whose conversation is history followed
+// by its input, to a chat completions request.
+func chatRequest(request *Request, history []chatMessage) (map[string]any, []chatMessage, error) {
+	if request.Model == "" {
+		return nil, nil, fmt.Errorf("%w: model is required", ErrInvalidRequest)
+	}
+	if len(request.Input) == 0 {
+		return nil, nil, fmt.Errorf("%w: input is required", ErrInvalidRequest)
+	}
+	input, err := inputMessages(request.Input)
+	if err != nil {
+		return nil, nil, err
+	}
+	conversation := append(append([]chatMessage(nil), history...), input...)
+	messages := conversation
+	if request.Instructions != "" {
+		messages = append([]chatMessage{{Role: "system", Content: request.Instructions}}, conversation...)
+	}
+
+	chat := map[string]any{
+		"model":    request.Model,
+		"messages": messages,
+	}
+	if len(request.Tools) > 0 {
+		var tools []map[string]any
+		for _, tool := range request.Tools {
+			if tool.Type != "function" {
+				return nil, nil, fmt.Errorf("%w: unsupported tool type %q (only function tools are supported)", ErrInvalidRequest, tool.Type)
+			}
+			function := map[string]any{"name": tool.Name}
+			if tool.Description != "" {
+				function["description"] = tool.Description
+			}
+			if len(tool.Parameters) > 0 {
+				function["parameters"] = tool.Parameters
+			}
+			if tool.Strict != nil {
+				function["strict"] = *tool.Strict
+			}
+			tools = append(tools, map[string]any{"type": "function", "function": function})
+		}
+		chat["tools"] = tools
+	}
+	if len(request.ToolChoice) > 0 {
+		toolChoice, err := chatToolChoice(request.ToolChoice)
+		if err != nil {
+			return nil, nil, err
+		}
+		chat["tool_choice"] = toolChoice
+	}
+	if request.ParallelToolCalls != nil {
+		chat["parallel_tool_calls"] = *request.ParallelToolCalls
+	}
+	if request.Temperature != nil {
+		chat["temperature"] = *request.Temperature
+	}
+	if request.TopP != nil {
+		chat["top_p"] = *request.TopP
+	}
+	if request.MaxOutputTokens != nil {
+		chat["max_tokens"] = *request.MaxOutputTokens
+	}
+	if request.Reasoning != nil && request.Reasoning.Effort != "" {
+		chat["reasoning_effort"] = request.Reasoning.Effort
+	}
+	if request.User != "" {
+		chat["user"] = request.User
+	}
+	if request.Text != nil && request.Text.Format != nil {
+		switch format := request.Text.Format; format.Type {
+		case "text":
+		case "json_object":
+			chat["response_format"] = map[string]any{"type": "json_object"}
+		case "json_schema":
+			schema := map[string]any{"name": format.Name, "schema": format.Schema}
+			if format.Description != "" {
+				schema["description"] = format.Description
+			}
+			if format.Strict != nil {
+				schema["strict"] = *format.Strict
+			}
+			chat["response_format"] = map[string]any{"type": "json_schema", "json_schema": schema}
+		default:
+			return nil, nil, fmt.Errorf("%w: unsupported text format %q", ErrInvalidRequest, format.Type)
+		}
+	}
+	if request.Stream {
+		chat["stream"] = true
+		chat["stream_options"] = map[string]any{"include_usage": true}
+	}
+	return chat, conversation, nil
+}
+
+// chatToolChoice translates the tool choice of a request: "auto", "none",
+// "required", or a function.
+func chatToolChoice(toolChoice json.RawMessage) (any, error) {
+	var mode string
+	if err := json.Unmarshal(toolChoice, &mode); err == nil {
+		return mode, nil
+	}
+	var function struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(toolChoice, &function); err != nil || function.Type != "function" || function.Name == "" {
+		return nil, fmt.Errorf("%w: unsupported tool_choice", ErrInvalidRequest)
+	}
+	return map[string]any{"type": "function", "function": map[string]string{"name": function.Name}}, nil
+}
+
+// chatResponse is a chat completions response.
+type chatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content          *string        `json:"content"`
+			ReasoningContent string         `json:"reasoning_content"`
+			Reasoning        string         `json:"reasoning"`
+			ToolCalls        []chatToolCall `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *chatUsage `json:"usage"`
+}
+
+// chatUsage is the token usage of a chat completions response.
+type chatUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	CompletionTokensDetails *struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+// usage translates the token usage of a chat completions response.
+func (u *chatUsage) usage() *Usage {
+	if u == nil {
+		return nil
+	}
+	usage := &Usage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+	if u.PromptTokensDetails != nil {
+		usage.InputTokensDetails.CachedTokens = u.PromptTokensDetails.CachedTokens
+	}
+	if u.CompletionTokensDetails != nil {
+		usage.OutputTokensDetails.ReasoningTokens = u.CompletionTokensDetails.ReasoningTokens
+	}
+	return usage
+}
+
+// newResponse returns a response to a request, without output.
+func newResponse(request *Request, createdAt int64) *Response {
+	response := &Response{
+		ID:                newID("resp"),
+		Object:            "response",
+		CreatedAt:         createdAt,
+		Status:            StatusInProgress,
+		MaxOutputTokens:   request.MaxOutputTokens,
+		Model:             request.Model,
+		Output:            []OutputItem{},
+		ParallelToolCalls: request.ParallelToolCalls == nil || *request.ParallelToolCalls,
+		Reasoning:         request.Reasoning,
+		Store:             request.Store == nil || *request.Store,
+		Temperature:       request.Temperature,
+		Text:              TextConfig{Format: &TextFormat{Type: "text"}},
+		ToolChoice:        request.ToolChoice,
+		Tools:             request.Tools,
+		TopP:              request.TopP,
+		User:              request.User,
+		Metadata:          request.Metadata,
+	}
+	if response.Tools == nil {
+		response.Tools = []Tool{}
+	}
+	if len(response.ToolChoice) == 0 {
+		response.ToolChoice = json.RawMessage(`"auto"`)
+	}
+	if request.Text != nil && request.Text.Format != nil {
+		response.Text = *request.Text
+	}
+	if response.Metadata == nil {
+		response.Metadata = map[string]string{}
+	}
+	if request.Instructions != "" {
+		response.Instructions = &request.Instructions
+	}
+	if request.PreviousResponseID != "" {
+		response.PreviousResponseID = &request.PreviousResponseID
+	}
+	return response
+}
+
+// finish sets the status of a response from the finish reason of the chat
+// completion.
+func (r *Response) finish(finishReason string) {
+	r.Status = StatusCompleted
+	switch finishReason {
+	case "length":
+		r.Status = StatusIncomplete
+		r.IncompleteDetails = &IncompleteDetails{Reason: "max_output_tokens"}
+	case "content_filter":
+		r.Status = StatusIncomplete
+		r.IncompleteDetails = &IncompleteDetails{Reason: "content_filter"}
+	}
+}
+
+// reasoningItem returns a reasoning output item.
+func reasoningItem(text string) OutputItem {
+	return OutputItem{
+		Type:    "reasoning",
+		ID:      newID("rs"),
+		Summary: []OutputContent{},
+		Content: []OutputContent{{Type: "reasoning_text", Text: text, Annotations: []json.RawMessage{}}},
+	}
+}
+
+// messageItem returns an assistant message output item.
+func messageItem(text string) OutputItem {
+	return OutputItem{
+		Type:    "message",
+		ID:      newID("msg"),
+		Status:  StatusCompleted,
+		Role:    "assistant",
+		Content: []OutputContent{{Type: "output_text", Text: text, Annotations: []json.RawMessage{}}},
+	}
+}
+
+// functionCallItem returns a function call output item.
+func functionCallItem(callID, name, arguments string) OutputItem {
+	if callID == "" {
+		callID = newID("call")
+	}
+	return OutputItem{
+		Type:      "function_call",
+		ID:        newID("fc"),
+		Status:    StatusCompleted,
+		CallID:    callID,
+		Name:      name,
+		Arguments: &arguments,
+	}
+}
+
+// translateResponse translates a chat completions response to the output of a
+// response.
+func translateResponse(response *Response, body []byte) error {
+	var chat chatResponse
+	if err := json.Unmarshal(body, &chat); err != nil {
+		return fmt.Errorf("invalid chat completions response: %w", err)
+	}
+	if len(chat.Choices) == 0 {
+		return errors.New("invalid chat completions response: no choices")
+	}
+	choice := chat.Choices[0]
+	if reasoning := cmp.Or(choice.Message.ReasoningContent, choice.Message.Reasoning); reasoning != "" {
+		response.Output = append(response.Output, reasoningItem(reasoning))
+	}
+	if choice.Message.Content != nil && *choice.Message.Content != "" {
+		response.Output = append(response.Output, messageItem(*choice.Message.Content))
+	}
+	for _, call := range choice.Message.ToolCalls {
+		response.Output = append(response.Output, functionCallItem(call.ID, call.Function.Name, call.Function.Arguments))
+	}
+	response.Usage = chat.Usage.usage()
+	response.finish(choice.FinishReason)
+	return nil
+}
+
+// outputMessages translates the output of a response to chat completions
+// messages, which continue the conversation of later responses.
+func outputMessages(output []OutputItem) []chatMessage {
+	var message *chatMessage
+	for _, item := range output {
+		switch item.Type {
+		case "message":
+			if message == nil {
+				message = &chatMessage{Role: "assistant"}
+			}
+			var texts []string
+			for _, part := range item.Content {
+				texts = append(texts, part.Text)
+			}
+			message.Content = strings.Join(texts, "\n")
+		case "function_call":
+			if message == nil {
+				message = &chatMessage{Role: "assistant"}
+			}
+			message.ToolCalls = append(message.ToolCalls, chatToolCall{
+				ID:       item.CallID,
+				Type:     "function",
+				Function: chatFunction{Name: item.Name, Arguments: *item.Arguments},
+			})
+		}
+	}
+	if message == nil {
+		return nil
+	}
+	return []chatMessage{*message}
+}
@@ -0,0 +1,194 @@
+package responses
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestChatRequest(t *testing.T) {
+	body := `{
+		"model": "ai/qwen3",
+		"instructions": "Be brief.",
+		"input": [
+			{"role": "developer", "content": "Use tools."},
+			{"type": "message", "role": "user", "content": [{"type": "input_text", "text": "Weather in Paris?"}, {"type": "input_image", "image_url": "data:image/png;base64,AA=="}]},
+			{"type": "reasoning", "summary": []},
+			{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "Checking."}]},
+			{"type": "function_call", "call_id": "call_1", "name": "weather", "arguments": "{\"city\":\"Paris\"}"},
+			{"type": "function_call", "call_id": "call_2", "name": "time", "arguments": "{}"},
+			{"type": "function_call_output", "call_id": "call_1", "output": "sunny"},
+			{"type": "function_call_output", "call_id": "call_2", "output": [{"type": "input_text", "text": "noon"}]}
+		],
+		"tools": [{"type": "function", "name": "weather", "parameters": {"type": "object"}}],
+		"tool_choice": {"type": "function", "name": "weather"},
+		"max_output_tokens": 64,
+		"text": {"format": {"type": "json_schema", "name": "answer", "schema": {"type": "object"}}},
+		"reasoning": {"effort": "low"},
+		"stream": true
+	}`
+	var request Request
+	if err := json.Unmarshal([]byte(body), &request); err != nil {
+		t.Fatal(err)
+	}
+	history := []chatMessage{{Role: "user", Content: "Hi"}, {Role: "assistant", Content: "Hello"}}
+	chat, conversation, err := chatRequest(&request, history)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(conversation) != 7 {
+		t.Errorf("Expected the conversation to exclude the instructions, got %d messages", len(conversation))
+	}
+
+	data, _ := json.Marshal(chat)
+	var translated struct {
+		Messages []struct {
+			Role       string          `json:"role"`
+			Content    json.RawMessage `json:"content"`
+			ToolCalls  []chatToolCall  `json:"tool_calls"`
+			ToolCallID string          `json:"tool_call_id"`
+		} `json:"messages"`
+		Tools []struct {
+			Type     string `json:"type"`
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		} `json:"tools"`
+		ToolChoice struct {
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		} `json:"tool_choice"`
+		MaxTokens       int    `json:"max_tokens"`
+		ReasoningEffort string `json:"reasoning_effort"`
+		ResponseFormat  struct {
+			Type       string `json:"type"`
+			JSONSchema struct {
+				Name string `json:"name"`
+			} `json:"json_schema"`
+		} `json:"response_format"`
+		StreamOptions struct {
+			IncludeUsage bool `json:"include_usage"`
+		} `json:"stream_options"`
+	}
+	if err := json.Unmarshal(data, &translated); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		role      string
+		content   string
+		toolCalls int
+		toolCall  string
+	}{
+		{role: "system", content: `"Be brief."`},
+		{role: "user", content: `"Hi"`},
+		{role: "assistant", content: `"Hello"`},
+		{role: "system", content: `"Use tools."`},
+		{role: "user", content: `[{"type":"text","text":"Weather in Paris?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AA=="}}]`},
+		{role: "assistant", content: `"Checking."`, toolCalls: 2},
+		{role: "tool", content: `"sunny"`, toolCall: "call_1"},
+		{role: "tool", content: `"noon"`, toolCall: "call_2"},
+	}
+	if len(translated.Messages) != len(expected) {
+		t.Fatalf("Expected %d messages, got %s", len(expected), data)
+	}
+	for i, message := range translated.Messages {
+		if message.Role != expected[i].role || string(message.Content) != expected[i].content ||
+			len(message.ToolCalls) != expected[i].toolCalls || message.ToolCallID != expected[i].toolCall {
+			t.Errorf("Unexpected message %d: %+v", i, message)
+		}
+	}
+	if translated.Messages[5].ToolCalls[1].Function.Name != "time" {
+		t.Errorf("Expected parallel function calls to be merged, got %+v", translated.Messages[5].ToolCalls)
+	}
+	if len(translated.Tools) != 1 || translated.Tools[0].Type != "function" || translated.Tools[0].Function.Name != "weather" {
+		t.Errorf("Unexpected tools %+v", translated.Tools)
+	}
+	if translated.ToolChoice.Function.Name != "weather" {
+		t.Errorf("Unexpected tool choice %+v", translated.ToolChoice)
+	}
+	if translated.MaxTokens != 64 || translated.ReasoningEffort != "low" || !translated.StreamOptions.IncludeUsage {
+		t.Errorf("Unexpected parameters %s", data)
+	}
+	if translated.ResponseFormat.Type != "json_schema" || translated.ResponseFormat.JSONSchema.Name != "answer" {
+		t.Errorf("Unexpected response format %+v", translated.ResponseFormat)
+	}
+}
+
+func TestChatRequestInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "missing model", body: `{"input": "hi"}`},
+		{name: "missing input", body: `{"model": "m"}`},
+		{name: "invalid input", body: `{"model": "m", "input": 1}`},
+		{name: "unsupported item", body: `{"model": "m", "input": [{"type": "item_reference", "id": "msg_1"}]}`},
+		{name: "unsupported role", body: `{"model": "m", "input": [{"role": "tool", "content": "hi"}]}`},
+		{name: "unsupported content", body: `{"model": "m", "input": [{"role": "user", "content": [{"type": "input_file", "file_id": "f"}]}]}`},
+		{name: "function call without call_id", body: `{"model": "m", "input": [{"type": "function_call", "name": "f"}]}`},
+		{name: "unsupported tool", body: `{"model": "m", "input": "hi", "tools": [{"type": "web_search"}]}`},
+		{name: "unsupported text format", body: `{"model": "m", "input": "hi", "text": {"format": {"type": "xml"}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request Request
+			if err := json.Unmarshal([]byte(tt.body), &request); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := chatRequest(&request, nil); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected an invalid request error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTranslateResponse(t *testing.T) {
+	request := &Request{Model: "ai/qwen3"}
+	response := newResponse(request, 1)
+	body := `{
+		"model": "ai/qwen3",
+		"choices": [{
+			"message": {
+				"role": "assistant",
+				"reasoning_content": "The user wants the weather.",
+				"content": "Let me check.",
+				"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "weather", "arguments": "{\"city\":\"Paris\"}"}}]
+			},
+			"finish_reason": "tool_calls"
+		}],
+		"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15, "prompt_tokens_details": {"cached_tokens": 4}}
+	}`
+	if err := translateResponse(response, []byte(body)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.Status != StatusCompleted || len(response.Output) != 3 {
+		t.Fatalf("Unexpected response %+v", response)
+	}
+	if item := response.Output[0]; item.Type != "reasoning" || item.Content[0].Text != "The user wants the weather." {
+		t.Errorf("Unexpected reasoning item %+v", item)
+	}
+	if item := response.Output[1]; item.Type != "message" || item.Role != "assistant" || item.Content[0].Type != "output_text" || item.Content[0].Text != "Let me check." {
+		t.Errorf("Unexpected message item %+v", item)
+	}
+	if item := response.Output[2]; item.Type != "function_call" || item.CallID != "call_1" || item.Name != "weather" || *item.Arguments != `{"city":"Paris"}` {
+		t.Errorf("Unexpected function call item %+v", item)
+	}
+	if response.Usage == nil || response.Usage.InputTokens != 10 || response.Usage.OutputTokens != 5 || response.Usage.InputTokensDetails.CachedTokens != 4 {
+		t.Errorf("Unexpected usage %+v", response.Usage)
+	}
+
+	messages := outputMessages(response.Output)
+	if len(messages) != 1 || messages[0].Content != "Let me check." || len(messages[0].ToolCalls) != 1 || messages[0].ToolCalls[0].ID != "call_1" {
+		t.Errorf("Expected the output to continue the conversation as one assistant message, got %+v", messages)
+	}
+
+	truncated := newResponse(request, 1)
+	if err := translateResponse(truncated, []byte(`{"choices": [{"message": {"content": "Lorem"}, "finish_reason": "length"}]}`)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if truncated.Status != StatusIncomplete || truncated.IncompleteDetails == nil || truncated.IncompleteDetails.Reason != "max_output_tokens" {
+		t.Errorf("Expected a truncated response to be incomplete, got %+v", truncated)
+	}
+}
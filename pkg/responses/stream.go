@@ -0,0 +1,304 @@
+package responses
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// chatChunk is a chunk of a streamed chat completions response.
+type chatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string         `json:"content"`
+			ReasoningContent string         `json:"reasoning_content"`
+			Reasoning        string         `json:"reasoning"`
+			ToolCalls        []chatToolCall `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *chatUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// streamWriter translates a streamed chat completions response to the events
+// of a streamed response as it's written. Output items are streamed one after
+// the other: reasoning, then text, then function calls.
+type streamWriter struct {
+	w        http.ResponseWriter
+	log      logging.Logger
+	response *Response
+	// failed indicates that the chat completion failed before streaming, in
+	// which case its response is passed through.
+	failed bool
+	// started indicates that the stream started.
+	started bool
+	// done indicates that the final event was sent.
+	done bool
+	// buffer holds the incomplete line at the end of the data written.
+	buffer bytes.Buffer
+	// sequence is the sequence number of the next event.
+	sequence int
+	// current is the index of the output item being streamed, or -1.
+	current int
+	// text is the text or arguments of the output item being streamed.
+	text strings.Builder
+	// calls maps the indexes of the tool calls of the chat completion to the
+	// indexes of their output items.
+	calls map[int]int
+	// finishReason is the finish reason of the chat completion.
+	finishReason string
+}
+
+// newStreamWriter creates a stream writer for a response.
+func newStreamWriter(w http.ResponseWriter, log logging.Logger, response *Response) *streamWriter {
+	return &streamWriter{w: w, log: log, response: response, current: -1, calls: make(map[int]int)}
+}
+
+// Header implements net/http.ResponseWriter.Header.
+func (s *streamWriter) Header() http.Header {
+	return s.w.Header()
+}
+
+// WriteHeader implements net/http.ResponseWriter.WriteHeader.
+func (s *streamWriter) WriteHeader(status int) {
+	if s.started || s.failed {
+		return
+	}
+	if status != http.StatusOK {
+		s.failed = true
+		s.w.WriteHeader(status)
+		return
+	}
+	s.start()
+}
+
+// start starts the stream.
+func (s *streamWriter) start() {
+	s.started = true
+	s.w.Header().Del("Content-Length")
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.WriteHeader(http.StatusOK)
+	s.emit("response.created", map[string]any{"response": s.response})
+	s.emit("response.in_progress", map[string]any{"response": s.response})
+}
+
+// Write implements net/http.ResponseWriter.Write.
+func (s *streamWriter) Write(data []byte) (int, error) {
+	if s.failed {
+		return s.w.Write(data)
+	}
+	if !s.started {
+		s.start()
+	}
+	s.buffer.Write(data)
+	for {
+		line, err := s.buffer.ReadBytes('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write.
+			s.buffer.Write(line)
+			break
+		}
+		s.line(string(bytes.TrimSpace(line)))
+	}
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return len(data), nil
+}
+
+// Flush implements net/http.Flusher.Flush.
+func (s *streamWriter) Flush() {
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// line handles a line of the chat completions stream.
+func (s *streamWriter) line(line string) {
+	data, ok := strings.CutPrefix(line, "data:")
+	if !ok || s.done {
+		return
+	}
+	data = strings.TrimSpace(data)
+	if data == "[DONE]" {
+		s.finish()
+		return
+	}
+	var chunk chatChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		s.log.Warnf("Failed to parse chat completions stream chunk: %v", err)
+		return
+	}
+	if chunk.Error != nil {
+		s.fail(chunk.Error.Message)
+		return
+	}
+	if chunk.Usage != nil {
+		s.response.Usage = chunk.Usage.usage()
+	}
+	if len(chunk.Choices) == 0 {
+		return
+	}
+	choice := chunk.Choices[0]
+	if reasoning := cmp.Or(choice.Delta.ReasoningContent, choice.Delta.Reasoning); reasoning != "" {
+		if s.currentType() != "reasoning" {
+			s.open(reasoningItem(""))
+		}
+		s.text.WriteString(reasoning)
+		s.emit("response.reasoning_text.delta", s.itemFields(map[string]any{"content_index": 0, "delta": reasoning}))
+	}
+	if choice.Delta.Content != "" {
+		if s.currentType() != "message" {
+			s.open(messageItem(""))
+		}
+		s.text.WriteString(choice.Delta.Content)
+		s.emit("response.output_text.delta", s.itemFields(map[string]any{"content_index": 0, "delta": choice.Delta.Content}))
+	}
+	for _, call := range choice.Delta.ToolCalls {
+		index := 0
+		if call.Index != nil {
+			index = *call.Index
+		}
+		if output, ok := s.calls[index]; !ok {
+			s.open(functionCallItem(call.ID, call.Function.Name, ""))
+			s.calls[index] = s.current
+		} else if output != s.current {
+			s.log.Warnf("Ignoring interleaved tool call %d in chat completions stream", index)
+			continue
+		}
+		if call.Function.Arguments != "" {
+			s.text.WriteString(call.Function.Arguments)
+			s.emit("response.function_call_arguments.delta", s.itemFields(map[string]any{"delta": call.Function.Arguments}))
+		}
+	}
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		s.finishReason = *choice.FinishReason
+	}
+}
+
+// currentType returns the type of the output item being streamed, if any.
+func (s *streamWriter) currentType() string {
+	if s.current < 0 {
+		return ""
+	}
+	return s.response.Output[s.current].Type
+}
+
+// itemFields adds the fields identifying the output item being streamed to
+// the fields of an event.
+func (s *streamWriter) itemFields(fields map[string]any) map[string]any {
+	fields["item_id"] = s.response.Output[s.current].ID
+	fields["output_index"] = s.current
+	return fields
+}
+
+// open closes the output item being streamed, if any, and starts streaming
+// an item.
+func (s *streamWriter) open(item OutputItem) {
+	s.close()
+	item.Status = StatusInProgress
+	if item.Type == "message" {
+		item.Content = []OutputContent{}
+	}
+	if item.Type == "reasoning" {
+		item.Content = nil
+	}
+	s.response.Output = append(s.response.Output, item)
+	s.current = len(s.response.Output) - 1
+	s.text.Reset()
+	s.emit("response.output_item.added", map[string]any{"output_index": s.current, "item": item})
+	if item.Type == "message" {
+		part := OutputContent{Type: "output_text", Annotations: []json.RawMessage{}}
+		s.emit("response.content_part.added", s.itemFields(map[string]any{"content_index": 0, "part": part}))
+	}
+}
+
+// close finishes streaming the current output item, if any.
+func (s *streamWriter) close() {
+	if s.current < 0 {
+		return
+	}
+	item := &s.response.Output[s.current]
+	text := s.text.String()
+	switch item.Type {
+	case "reasoning":
+		item.Content = []OutputContent{{Type: "reasoning_text", Text: text, Annotations: []json.RawMessage{}}}
+		s.emit("response.reasoning_text.done", s.itemFields(map[string]any{"content_index": 0, "text": text}))
+		item.Status = ""
+	case "message":
+		part := OutputContent{Type: "output_text", Text: text, Annotations: []json.RawMessage{}}
+		item.Content = []OutputContent{part}
+		s.emit("response.output_text.done", s.itemFields(map[string]any{"content_index": 0, "text": text}))
+		s.emit("response.content_part.done", s.itemFields(map[string]any{"content_index": 0, "part": part}))
+		item.Status = StatusCompleted
+	case "function_call":
+		item.Arguments = &text
+		s.emit("response.function_call_arguments.done", s.itemFields(map[string]any{"arguments": text}))
+		item.Status = StatusCompleted
+	}
+	s.emit("response.output_item.done", map[string]any{"output_index": s.current, "item": *item})
+	s.current = -1
+	s.text.Reset()
+}
+
+// finish closes the stream once the chat completion is complete.
+func (s *streamWriter) finish() {
+	s.close()
+	s.response.finish(s.finishReason)
+	event := "response.completed"
+	if s.response.Status == StatusIncomplete {
+		event = "response.incomplete"
+	}
+	s.emit(event, map[string]any{"response": s.response})
+	s.done = true
+}
+
+// fail closes the stream after the chat completion failed.
+func (s *streamWriter) fail(message string) {
+	s.close()
+	s.response.Status = StatusFailed
+	s.response.Error = &ResponseError{Code: "server_error", Message: message}
+	s.emit("response.failed", map[string]any{"response": s.response})
+	s.done = true
+}
+
+// end ends the stream once the chat completions handler returns, failing the
+// response if the chat completions stream ended without completing.
+func (s *streamWriter) end() {
+	if s.failed || s.done {
+		return
+	}
+	if !s.started {
+		s.start()
+	}
+	if s.buffer.Len() > 0 {
+		s.line(strings.TrimSpace(s.buffer.String()))
+		s.buffer.Reset()
+	}
+	if !s.done {
+		s.fail("the chat completions stream ended unexpectedly")
+	}
+	s.Flush()
+}
+
+// emit sends an event.
+func (s *streamWriter) emit(event string, fields map[string]any) {
+	fields["type"] = event
+	fields["sequence_number"] = s.sequence
+	s.sequence++
+	data, err := json.Marshal(fields)
+	if err != nil {
+		s.log.Warnf("Failed to encode %s event: %v", event, err)
+		return
+	}
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,317 @@
+package sessions
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/docker/model-runner/pkg/apikeys"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// Path is the path of the sessions API.
+const Path = "/v1/sessions"
+
+// maximumRequestSize is the maximum size of a request body, matching the
+// limit applied by the scheduler.
+const maximumRequestSize = 10 * 1024 * 1024
+
+// Session is a stored session, as described by the sessions API.
+type Session struct {
+	ID        string            `json:"id"`
+	Object    string            `json:"object"`
+	Messages  []json.RawMessage `json:"messages"`
+	ExpiresAt int64             `json:"expires_at"`
+}
+
+// HTTPHandler serves chat completions requests in sessions by reconstructing
+// their conversation from a store before forwarding them to the inference
+// handler, and storing their new messages along with their reply. Requests
+// without a session, and other requests, are forwarded as-is. It also serves
+// the sessions API, which describes and deletes sessions.
+type HTTPHandler struct {
+	// log is the associated logger.
+	log logging.Logger
+	// router is the HTTP request router.
+	router *http.ServeMux
+	// next is the inference handler.
+	next http.Handler
+	// store stores the sessions.
+	store *Store
+}
+
+// NewHTTPHandler creates a new sessions handler that forwards requests to
+// next, which should serve the inference API.
+func NewHTTPHandler(log logging.Logger, store *Store, next http.Handler) *HTTPHandler {
+	h := &HTTPHandler{
+		log:    log,
+		router: http.NewServeMux(),
+		next:   next,
+		store:  store,
+	}
+	for _, prefix := range []string{inference.InferencePrefix, inference.InferencePrefix + "/{backend}"} {
+		h.router.HandleFunc("POST "+prefix+"/v1/chat/completions", h.handleChatCompletion)
+		h.router.HandleFunc("GET "+prefix+Path+"/{id}", h.handleGet)
+		h.router.HandleFunc("DELETE "+prefix+Path+"/{id}", h.handleDelete)
+	}
+	h.router.Handle("/", next)
+	return h
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// sessionKey returns the key of the session with the specified ID, which is
+// scoped to the API key that authenticated the request, if any.
+func sessionKey(r *http.Request, id string) Key {
+	key := Key{ID: id}
+	if apiKey, ok := apikeys.FromContext(r.Context()); ok {
+		key.Owner = apiKey.ID
+	}
+	return key
+}
+
+// handleChatCompletion handles POST /v1/chat/completions requests. The
+// messages of requests in a session are appended to those of the session, and
+// the prefix they share is cached by the backend under the session's ID,
+// unless the request sets a prompt cache key. Requests in a session are
+// served one at a time, and are routed like requests with the session's ID as
+// their X-Session-ID header unless they have one.
+func (h *HTTPHandler) handleChatCompletion(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(inference.ConversationIDHeader)
+	if id == "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	r = r.Clone(r.Context())
+	if r.Header.Get(inference.SessionIDHeader) == "" {
+		r.Header.Set(inference.SessionIDHeader, id)
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maximumRequestSize))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(w, "request too large", http.StatusBadRequest)
+		} else {
+			http.Error(w, "failed to read request body", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var request map[string]json.RawMessage
+	var messages []json.RawMessage
+	if json.Unmarshal(body, &request) != nil || json.Unmarshal(request["messages"], &messages) != nil {
+		// Leave invalid requests for the inference handler to reject.
+		h.forward(w, r, body)
+		return
+	}
+	key := sessionKey(r, id)
+	done, err := h.store.Acquire(r.Context(), key)
+	if err != nil {
+		http.Error(w, "request cancelled", http.StatusServiceUnavailable)
+		return
+	}
+	defer done()
+	history, _, _ := h.store.Messages(key)
+	if request["messages"], err = json.Marshal(append(history, messages...)); err != nil {
+		http.Error(w, "failed to encode request", http.StatusInternalServerError)
+		return
+	}
+	if _, ok := request["prompt_cache_key"]; !ok {
+		request["prompt_cache_key"], _ = json.Marshal(id)
+	}
+	if body, err = json.Marshal(request); err != nil {
+		http.Error(w, "failed to encode request", http.StatusInternalServerError)
+		return
+	}
+
+	recorder := &replyRecorder{ResponseWriter: w}
+	h.forward(recorder, r, body)
+	if recorder.status != http.StatusOK {
+		return
+	}
+	reply, err := recorder.reply()
+	if err != nil {
+		h.log.Warnf("Failed to read the reply of a session's chat completion: %v", err)
+		return
+	}
+	h.store.Append(key, append(messages, reply)...)
+}
+
+// forward forwards a request to the inference handler with its body
+// replaced.
+func (h *HTTPHandler) forward(w http.ResponseWriter, r *http.Request, body []byte) {
+	upstream := r.Clone(r.Context())
+	upstream.Body = io.NopCloser(bytes.NewReader(body))
+	upstream.ContentLength = int64(len(body))
+	h.next.ServeHTTP(w, upstream)
+}
+
+// handleGet handles GET /v1/sessions/{id} requests.
+func (h *HTTPHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	messages, expires, ok := h.store.Messages(sessionKey(r, id))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	h.writeJSON(w, Session{ID: id, Object: "session", Messages: messages, ExpiresAt: expires.Unix()})
+}
+
+// handleDelete handles DELETE /v1/sessions/{id} requests.
+func (h *HTTPHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !h.store.Delete(sessionKey(r, id)) {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	h.writeJSON(w, map[string]any{"id": id, "object": "session", "deleted": true})
+}
+
+// writeJSON writes a JSON response.
+func (h *HTTPHandler) writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.log.Warnf("Failed to encode response: %v", err)
+	}
+}
+
+// replyRecorder is a response writer that records the response of a chat
+// completion while it's written to the client.
+type replyRecorder struct {
+	http.ResponseWriter
+	// status is the status of the response, once it's written.
+	status int
+	// body is the body of the response.
+	body bytes.Buffer
+}
+
+// WriteHeader implements net/http.ResponseWriter.WriteHeader.
+func (r *replyRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements net/http.ResponseWriter.Write.
+func (r *replyRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+// Unwrap returns the underlying response writer, so that it can be flushed
+// via http.ResponseController.
+func (r *replyRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush implements http.Flusher.Flush.
+func (r *replyRecorder) Flush() {
+	_ = http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// reply returns the message of the first choice of the recorded response,
+// which is assembled from the content and tool call deltas of streamed
+// responses.
+func (r *replyRecorder) reply() (json.RawMessage, error) {
+	if !strings.HasPrefix(r.Header().Get("Content-Type"), "text/event-stream") {
+		var response struct {
+			Choices []struct {
+				Message json.RawMessage `json:"message"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal(r.body.Bytes(), &response); err != nil {
+			return nil, err
+		}
+		if len(response.Choices) == 0 || len(response.Choices[0].Message) == 0 {
+			return nil, errors.New("response has no message")
+		}
+		return response.Choices[0].Message, nil
+	}
+
+	var content strings.Builder
+	calls := make(map[int]*toolCall)
+	scanner := bufio.NewScanner(&r.body)
+	scanner.Buffer(nil, maximumRequestSize)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Choices []struct {
+				Index int `json:"index"`
+				Delta struct {
+					Content   string `json:"content"`
+					ToolCalls []struct {
+						Index int `json:"index"`
+						toolCall
+					} `json:"tool_calls"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if json.Unmarshal([]byte(data), &chunk) != nil {
+			continue
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			// The ID, type, and name of a tool call are sent with its first
+			// delta, and its arguments in fragments.
+			for _, delta := range choice.Delta.ToolCalls {
+				call, ok := calls[delta.Index]
+				if !ok {
+					call = &toolCall{Type: "function"}
+					calls[delta.Index] = call
+				}
+				call.ID = cmp.Or(call.ID, delta.ID)
+				call.Type = cmp.Or(delta.Type, call.Type)
+				call.Function.Name = cmp.Or(call.Function.Name, delta.Function.Name)
+				call.Function.Arguments += delta.Function.Arguments
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	message := struct {
+		Role      string      `json:"role"`
+		Content   *string     `json:"content"`
+		ToolCalls []*toolCall `json:"tool_calls,omitempty"`
+	}{Role: "assistant"}
+	for _, index := range slices.Sorted(maps.Keys(calls)) {
+		message.ToolCalls = append(message.ToolCalls, calls[index])
+	}
+	// Assistant messages with tool calls have no content unless some text
+	// was generated before the calls.
+	if text := content.String(); text != "" || len(calls) == 0 {
+		message.Content = &text
+	}
+	return json.Marshal(message)
+}
+
+// toolCall is a tool call of a chat completions message.
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
@@ -0,0 +1,205 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/apikeys"
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeChat serves chat completions, recording their requests, and replies
+// with a fixed message or, for streamed requests, with fixed chunks, which
+// call two tools if the request has tools.
+type fakeChat struct {
+	// path is the path of the last request.
+	path string
+	// sessionID is the X-Session-ID header of the last request.
+	sessionID string
+	// messages are the messages of the last request.
+	messages []json.RawMessage
+	// promptCacheKey is the prompt cache key of the last request.
+	promptCacheKey string
+}
+
+func (f *fakeChat) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Model          string            `json:"model"`
+		Messages       []json.RawMessage `json:"messages"`
+		Stream         bool              `json:"stream"`
+		Tools          json.RawMessage   `json:"tools"`
+		PromptCacheKey string            `json:"prompt_cache_key"`
+	}
+	json.NewDecoder(r.Body).Decode(&request)
+	f.path = r.URL.Path
+	f.sessionID = r.Header.Get(inference.SessionIDHeader)
+	f.messages = request.Messages
+	f.promptCacheKey = request.PromptCacheKey
+	if request.Model == "missing" {
+		http.Error(w, "model not found", http.StatusNotFound)
+		return
+	}
+	if request.Stream && request.Tools != nil {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"role":"assistant","content":null}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"get_time","arguments":"{}"}}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]},"finish_reason":"tool_calls"}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		return
+	}
+	if request.Stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"role":"assistant"}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"content":"streamed "}}]}`+"\n\n")
+		fmt.Fprintf(w, `data: {"choices":[{"index":0,"delta":{"content":"reply %d"},"finish_reason":"stop"}]}`+"\n\n", len(request.Messages))
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"reply %d"},"finish_reason":"stop"}]}`, len(request.Messages))
+}
+
+// chat sends a chat completions request in a session to the handler.
+func chat(t *testing.T, h http.Handler, session, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, inference.InferencePrefix+"/v1/chat/completions", strings.NewReader(body))
+	if session != "" {
+		r.Header.Set(inference.ConversationIDHeader, session)
+	}
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestSessionConversation(t *testing.T) {
+	fake := &fakeChat{}
+	h := NewHTTPHandler(logrus.New(), NewStore(time.Hour, 0), fake)
+
+	chat(t, h, "s", `{"model":"m","messages":[{"role":"user","content":"Hi"}]}`)
+	if fake.promptCacheKey != "s" {
+		t.Errorf("Expected the session to be the prompt cache key, got %q", fake.promptCacheKey)
+	}
+	if fake.sessionID != "s" {
+		t.Errorf("Expected the session to be routed by its ID, got %q", fake.sessionID)
+	}
+	w := chat(t, h, "s", `{"model":"m","messages":[{"role":"user","content":"Again"}],"stream":true}`)
+	if !strings.Contains(w.Body.String(), "reply 3") {
+		t.Errorf("Expected the streamed reply to be written, got %s", w.Body.String())
+	}
+	// Failed requests aren't stored.
+	chat(t, h, "s", `{"model":"missing","messages":[{"role":"user","content":"Lost"}]}`)
+	chat(t, h, "s", `{"model":"m","messages":[{"role":"user","content":"Last"}],"prompt_cache_key":"k"}`)
+
+	expected := `[{"role":"user","content":"Hi"},{"role":"assistant","content":"reply 1"},{"role":"user","content":"Again"},{"role":"assistant","content":"streamed reply 3"},{"role":"user","content":"Last"}]`
+	if encoded, _ := json.Marshal(fake.messages); string(encoded) != expected {
+		t.Errorf("Expected the conversation %s, got %s", expected, encoded)
+	}
+	if fake.promptCacheKey != "k" {
+		t.Errorf("Expected the prompt cache key of the request to be kept, got %q", fake.promptCacheKey)
+	}
+
+	// Requests without a session are forwarded as-is.
+	chat(t, h, "", `{"model":"m","messages":[{"role":"user","content":"Alone"}]}`)
+	if len(fake.messages) != 1 || fake.promptCacheKey != "" {
+		t.Errorf("Expected the request to be forwarded as-is, got %d messages", len(fake.messages))
+	}
+
+	// Requests that only set the routing affinity of a session send their
+	// full conversation, which isn't stored.
+	r := httptest.NewRequest(http.MethodPost, inference.InferencePrefix+"/v1/chat/completions",
+		strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"Full"}]}`))
+	r.Header.Set(inference.SessionIDHeader, "s")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if len(fake.messages) != 1 || fake.promptCacheKey != "" {
+		t.Errorf("Expected the request with X-Session-ID to be forwarded as-is, got %d messages", len(fake.messages))
+	}
+}
+
+func TestSessionStreamedToolCalls(t *testing.T) {
+	fake := &fakeChat{}
+	h := NewHTTPHandler(logrus.New(), NewStore(time.Hour, 0), fake)
+
+	chat(t, h, "s", `{"model":"m","messages":[{"role":"user","content":"Weather?"}],"tools":[],"stream":true}`)
+	chat(t, h, "s", `{"model":"m","messages":[{"role":"tool","tool_call_id":"call_1","content":"Sunny"},{"role":"tool","tool_call_id":"call_2","content":"Noon"}]}`)
+
+	expected := `[{"role":"user","content":"Weather?"},` +
+		`{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},{"id":"call_2","type":"function","function":{"name":"get_time","arguments":"{}"}}]},` +
+		`{"role":"tool","tool_call_id":"call_1","content":"Sunny"},{"role":"tool","tool_call_id":"call_2","content":"Noon"}]`
+	if encoded, _ := json.Marshal(fake.messages); string(encoded) != expected {
+		t.Errorf("Expected the conversation %s, got %s", expected, encoded)
+	}
+}
+
+func TestSessionOwners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(path, []byte(`{"keys":[{"key":"alice-secret"},{"key":"bob-secret"}]}`), 0o600)
+	keys, err := apikeys.NewStore(logrus.New(), path)
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	fake := &fakeChat{}
+	h := apikeys.NewHandler(keys, NewHTTPHandler(logrus.New(), NewStore(time.Hour, 0), fake))
+	serve := func(method, path, secret, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+secret)
+		r.Header.Set(inference.ConversationIDHeader, "s")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	serve(http.MethodPost, inference.InferencePrefix+"/v1/chat/completions", "alice-secret", `{"model":"m","messages":[{"role":"user","content":"Secret"}]}`)
+	serve(http.MethodPost, inference.InferencePrefix+"/v1/chat/completions", "bob-secret", `{"model":"m","messages":[{"role":"user","content":"Hi"}]}`)
+	if len(fake.messages) != 1 {
+		t.Errorf("Expected another key's session not to be continued, got %d messages", len(fake.messages))
+	}
+	if w := serve(http.MethodDelete, inference.InferencePrefix+Path+"/s", "bob-secret", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected the key's session to be deleted, got status %d", w.Code)
+	}
+	w := serve(http.MethodGet, inference.InferencePrefix+Path+"/s", "alice-secret", "")
+	var session Session
+	if err := json.Unmarshal(w.Body.Bytes(), &session); err != nil || len(session.Messages) != 2 {
+		t.Errorf("Expected the key's session to be kept, got %s", w.Body.String())
+	}
+}
+
+func TestSessionAPI(t *testing.T) {
+	fake := &fakeChat{}
+	h := NewHTTPHandler(logrus.New(), NewStore(time.Hour, 0), fake)
+	chat(t, h, "s", `{"model":"m","messages":[{"role":"user","content":"Hi"}]}`)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, inference.InferencePrefix+Path+"/s", http.NoBody))
+	var session Session
+	if err := json.Unmarshal(w.Body.Bytes(), &session); err != nil {
+		t.Fatalf("Failed to decode session: %v", err)
+	}
+	if session.ID != "s" || len(session.Messages) != 2 || session.ExpiresAt == 0 {
+		t.Errorf("Unexpected session: %s", w.Body.String())
+	}
+
+	for _, status := range []int{http.StatusOK, http.StatusNotFound} {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, inference.InferencePrefix+Path+"/s", http.NoBody))
+		if w.Code != status {
+			t.Errorf("Expected status %d, got %d", status, w.Code)
+		}
+	}
+
+	// Other requests are forwarded.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, inference.InferencePrefix+"/v1/completions", strings.NewReader(`{"model":"m"}`)))
+	if fake.path != inference.InferencePrefix+"/v1/completions" {
+		t.Errorf("Expected the request to be forwarded, got path %q", fake.path)
+	}
+}
@@ -0,0 +1,207 @@
+// Package sessions implements server-side conversations: chat completions
+// requests that identify a session with the X-Conversation-ID header only send
+// their new messages, and the model runner reconstructs the conversation from
+// the messages of the session's earlier requests and their replies, which it
+// stores in memory for a limited time.
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// maximumSessions is the number of stored sessions, beyond which the least
+// recently used are forgotten.
+const maximumSessions = 1000
+
+// DefaultMaxMessages is the default number of messages stored per session.
+const DefaultMaxMessages = 200
+
+// Key identifies a session.
+type Key struct {
+	// Owner is the ID of the API key that the session's requests are
+	// authenticated with, if any, so that keys can't use the sessions of
+	// other keys.
+	Owner string
+	// ID is the ID of the session chosen by its client.
+	ID string
+}
+
+// session is a stored session.
+type session struct {
+	// messages are the messages of the conversation, oldest first.
+	messages []json.RawMessage
+	// used is when the session was last used.
+	used time.Time
+}
+
+// Store stores the conversations of sessions in memory. Sessions expire once
+// they're unused for their time to live, and only their latest messages are
+// kept, along with a leading system message.
+type Store struct {
+	// ttl is the time to live of unused sessions.
+	ttl time.Duration
+	// maxMessages is the number of messages stored per session.
+	maxMessages int
+	// now returns the current time.
+	now func() time.Time
+	// lock protects the fields below.
+	lock sync.Mutex
+	// sessions are the stored sessions.
+	sessions map[Key]*session
+	// turns serialize the requests of sessions that are in progress.
+	turns map[Key]*turn
+}
+
+// turn serializes the requests of a session.
+type turn struct {
+	// held is full while a request of the session is in progress.
+	held chan struct{}
+	// waiters is the number of requests holding or waiting for the turn.
+	waiters int
+}
+
+// NewStore creates a store of sessions that expire once they're unused for
+// ttl and keep maxMessages messages each, or DefaultMaxMessages if it isn't
+// positive.
+func NewStore(ttl time.Duration, maxMessages int) *Store {
+	if maxMessages <= 0 {
+		maxMessages = DefaultMaxMessages
+	}
+	return &Store{
+		ttl:         ttl,
+		maxMessages: maxMessages,
+		now:         time.Now,
+		sessions:    make(map[Key]*session),
+		turns:       make(map[Key]*turn),
+	}
+}
+
+// Acquire waits until no other request of a session is in progress, so that
+// the requests of a session read and append its messages one at a time, and
+// returns a function that ends the request's turn. It returns the cause of
+// ctx being done if it's done first.
+func (s *Store) Acquire(ctx context.Context, key Key) (func(), error) {
+	s.lock.Lock()
+	t, ok := s.turns[key]
+	if !ok {
+		t = &turn{held: make(chan struct{}, 1)}
+		s.turns[key] = t
+	}
+	t.waiters++
+	s.lock.Unlock()
+
+	leave := func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if t.waiters--; t.waiters == 0 {
+			delete(s.turns, key)
+		}
+	}
+	select {
+	case t.held <- struct{}{}:
+		return func() {
+			<-t.held
+			leave()
+		}, nil
+	case <-ctx.Done():
+		leave()
+		return nil, context.Cause(ctx)
+	}
+}
+
+// Messages returns the messages of a session and when it expires, or false if
+// it doesn't exist or has expired.
+func (s *Store) Messages(key Key) ([]json.RawMessage, time.Time, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stored, ok := s.live(key)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	return append([]json.RawMessage(nil), stored.messages...), stored.used.Add(s.ttl), true
+}
+
+// Append appends messages to a session, creating it if it doesn't exist, and
+// forgets its oldest messages beyond the maximum.
+func (s *Store) Append(key Key, messages ...json.RawMessage) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.now()
+	stored, ok := s.live(key)
+	if !ok {
+		stored = &session{}
+		s.sessions[key] = stored
+	}
+	stored.messages = truncate(append(stored.messages, messages...), s.maxMessages)
+	stored.used = now
+	s.evict(now)
+}
+
+// Delete deletes a session and returns whether it existed.
+func (s *Store) Delete(key Key) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.live(key)
+	delete(s.sessions, key)
+	return ok
+}
+
+// live returns a session unless it has expired, in which case it's forgotten.
+// The caller must hold the lock.
+func (s *Store) live(key Key) (*session, bool) {
+	stored, ok := s.sessions[key]
+	if !ok {
+		return nil, false
+	}
+	if s.now().Sub(stored.used) >= s.ttl {
+		delete(s.sessions, key)
+		return nil, false
+	}
+	return stored, true
+}
+
+// evict forgets expired sessions, along with the least recently used sessions
+// beyond maximumSessions. The caller must hold the lock.
+func (s *Store) evict(now time.Time) {
+	if len(s.sessions) <= maximumSessions {
+		return
+	}
+	for key, stored := range s.sessions {
+		if now.Sub(stored.used) >= s.ttl {
+			delete(s.sessions, key)
+		}
+	}
+	for len(s.sessions) > maximumSessions {
+		var oldest *Key
+		for key, stored := range s.sessions {
+			if oldest == nil || stored.used.Before(s.sessions[*oldest].used) {
+				oldest = &key
+			}
+		}
+		delete(s.sessions, *oldest)
+	}
+}
+
+// truncate returns the latest limit messages of a conversation, keeping its
+// leading system message, if any, in place of the oldest of them.
+func truncate(messages []json.RawMessage, limit int) []json.RawMessage {
+	if len(messages) <= limit {
+		return messages
+	}
+	if limit > 1 && role(messages[0]) == "system" {
+		return append(messages[:1:1], messages[len(messages)-limit+1:]...)
+	}
+	return append([]json.RawMessage(nil), messages[len(messages)-limit:]...)
+}
+
+// role returns the role of a message.
+func role(message json.RawMessage) string {
+	var m struct {
+		Role string `json:"role"`
+	}
+	json.Unmarshal(message, &m)
+	return m.Role
+}
@@ -0,0 +1,98 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStoreExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewStore(time.Minute, 0)
+	store.now = func() time.Time { return now }
+
+	store.Append(Key{ID: "s"}, json.RawMessage(`{"role":"user","content":"Hi"}`))
+	now = now.Add(30 * time.Second)
+	messages, expires, ok := store.Messages(Key{ID: "s"})
+	if !ok || len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d (%t)", len(messages), ok)
+	}
+	if expected := time.Unix(1060, 0); !expires.Equal(expected) {
+		t.Errorf("Expected the session to expire at %v, got %v", expected, expires)
+	}
+
+	now = now.Add(time.Minute)
+	if _, _, ok := store.Messages(Key{ID: "s"}); ok {
+		t.Error("Expected the session to expire")
+	}
+	if store.Delete(Key{ID: "s"}) {
+		t.Error("Expected the expired session to be forgotten")
+	}
+}
+
+func TestStoreTruncation(t *testing.T) {
+	store := NewStore(time.Minute, 3)
+	store.Append(Key{ID: "s"}, json.RawMessage(`{"role":"system","content":"Be brief."}`))
+	for i := range 4 {
+		store.Append(Key{ID: "s"}, json.RawMessage(fmt.Sprintf(`{"role":"user","content":"%d"}`, i)))
+	}
+	store.Append(Key{ID: "t"}, json.RawMessage(`{"role":"user","content":"a"}`), json.RawMessage(`{"role":"assistant","content":"b"}`))
+	store.Append(Key{ID: "t"}, json.RawMessage(`{"role":"user","content":"c"}`), json.RawMessage(`{"role":"assistant","content":"d"}`))
+
+	for id, expected := range map[string]string{
+		"s": `[{"role":"system","content":"Be brief."},{"role":"user","content":"2"},{"role":"user","content":"3"}]`,
+		"t": `[{"role":"assistant","content":"b"},{"role":"user","content":"c"},{"role":"assistant","content":"d"}]`,
+	} {
+		messages, _, _ := store.Messages(Key{ID: id})
+		if encoded, _ := json.Marshal(messages); string(encoded) != expected {
+			t.Errorf("Expected session %s to keep %s, got %s", id, expected, encoded)
+		}
+	}
+}
+
+func TestStoreEviction(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewStore(time.Hour, 0)
+	store.now = func() time.Time { return now }
+	for i := range maximumSessions + 1 {
+		now = now.Add(time.Second)
+		store.Append(Key{ID: fmt.Sprint(i)}, json.RawMessage(`{"role":"user","content":"Hi"}`))
+	}
+	if _, _, ok := store.Messages(Key{ID: "0"}); ok {
+		t.Error("Expected the least recently used session to be evicted")
+	}
+	if _, _, ok := store.Messages(Key{ID: fmt.Sprint(maximumSessions)}); !ok {
+		t.Error("Expected the latest session to be kept")
+	}
+}
+
+func TestStoreTurns(t *testing.T) {
+	store := NewStore(time.Minute, 0)
+	done, err := store.Acquire(context.Background(), Key{ID: "s"})
+	if err != nil {
+		t.Fatalf("Failed to acquire the session: %v", err)
+	}
+	// Other sessions, including those of other owners, aren't blocked.
+	other, err := store.Acquire(context.Background(), Key{Owner: "k", ID: "s"})
+	if err != nil {
+		t.Fatalf("Failed to acquire another session: %v", err)
+	}
+	other()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := store.Acquire(ctx, Key{ID: "s"}); err == nil {
+		t.Fatal("Expected the session to be held by the first request")
+	}
+	done()
+	done, err = store.Acquire(context.Background(), Key{ID: "s"})
+	if err != nil {
+		t.Fatalf("Failed to acquire the released session: %v", err)
+	}
+	done()
+	if len(store.turns) != 0 {
+		t.Errorf("Expected the turns of idle sessions to be forgotten, got %d", len(store.turns))
+	}
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StreamWriter wraps the writer of a streamed response, so that streaming it
+// is traced by a span, named stream, from when its first bytes are written
+// until the returned function is called, once it's complete. The span
+// records when the response is first flushed to the client, along with the
+// number of bytes and flushes of the response.
+func StreamWriter(ctx context.Context, w http.ResponseWriter, attributes ...attribute.KeyValue) (http.ResponseWriter, func()) {
+	sw := &streamWriter{ResponseWriter: w, ctx: ctx, attributes: attributes}
+	return sw, sw.end
+}
+
+// streamWriter traces the streaming of a response.
+type streamWriter struct {
+	http.ResponseWriter
+	// ctx is the context of the span.
+	ctx context.Context
+	// attributes are the attributes of the span.
+	attributes []attribute.KeyValue
+	// span is the span, once the response starts.
+	span trace.Span
+	// bytes is the number of bytes written.
+	bytes int64
+	// flushes is the number of flushes.
+	flushes int64
+}
+
+// start starts the span unless it's started already.
+func (w *streamWriter) start() {
+	if w.span == nil {
+		_, w.span = Tracer().Start(w.ctx, "stream", trace.WithAttributes(w.attributes...))
+	}
+}
+
+func (w *streamWriter) WriteHeader(statusCode int) {
+	w.start()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	w.start()
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *streamWriter) Flush() {
+	w.start()
+	if w.flushes == 0 {
+		w.span.AddEvent("first flush")
+	}
+	w.flushes++
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *streamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// end ends the span, if the response started.
+func (w *streamWriter) end() {
+	if w.span == nil {
+		return
+	}
+	w.span.SetAttributes(
+		attribute.Int64("model_runner.stream.bytes", w.bytes),
+		attribute.Int64("model_runner.stream.flushes", w.flushes),
+	)
+	w.span.End()
+}
@@ -0,0 +1,98 @@
+// Package tracing traces requests with OpenTelemetry. Incoming requests
+// continue the trace of their client, if any, and the model runner's spans
+// cover where their latency goes: routing, queueing, waiting for their model
+// to load, generation by the backend, and streaming the response. The trace
+// context is propagated to backends, so that their own spans, if any, join
+// the trace.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the tracer of the model runner's spans.
+const tracerName = "github.com/docker/model-runner"
+
+// serviceName is the default name of the service in exported spans, which
+// OTEL_SERVICE_NAME overrides.
+const serviceName = "model-runner"
+
+const (
+	// ModelKey is the attribute of the model requested.
+	ModelKey = attribute.Key("gen_ai.request.model")
+	// BackendKey is the attribute of the backend serving a request.
+	BackendKey = attribute.Key("model_runner.backend")
+	// ModeKey is the attribute of the backend operation mode of a request.
+	ModeKey = attribute.Key("model_runner.mode")
+)
+
+// Setup installs the propagator of the W3C trace context and baggage, so
+// that the traces of incoming requests are continued and propagated to
+// backends. If export is true, it also installs a tracer provider that
+// exports spans via OTLP over HTTP, as configured by the standard OTEL_*
+// environment variables, and returns a function that flushes the spans and
+// stops exporting them. Otherwise, spans aren't recorded, and the returned
+// function does nothing.
+func Setup(ctx context.Context, export bool) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !export {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of the model runner's spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Handler wraps next so that every request it serves is traced by a server
+// span, which continues the trace of the request's client, if any.
+func Handler(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, serviceName, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method + " " + r.URL.Path
+	}))
+}
+
+// Inject sets the headers of the trace context of ctx, so that a request
+// sent with them continues its trace.
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// End ends a span, marking it as failed if err isn't nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
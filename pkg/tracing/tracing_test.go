@@ -0,0 +1,151 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// record installs a tracer provider that records spans for the duration of a
+// test.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	if _, err := Setup(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	return recorder
+}
+
+func TestHandlerContinuesTrace(t *testing.T) {
+	recorder := record(t)
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	var upstream http.Header
+	handler := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := Tracer().Start(r.Context(), "generate")
+		upstream = http.Header{}
+		Inject(ctx, upstream)
+		End(span, nil)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/engines/v1/chat/completions", nil)
+	req.Header.Set("traceparent", traceparent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	generate, server := spans[0], spans[1]
+	if server.Name() != "POST /engines/v1/chat/completions" {
+		t.Errorf("server span name = %q", server.Name())
+	}
+	if got := server.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("server span trace ID = %s, want the client's", got)
+	}
+	if got := server.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("server span parent = %s, want the client's span", got)
+	}
+	if generate.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("generate span isn't a child of the server span")
+	}
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + generate.SpanContext().SpanID().String() + "-01"
+	if got := upstream.Get("traceparent"); got != want {
+		t.Errorf("upstream traceparent = %q, want %q", got, want)
+	}
+}
+
+func TestInjectWithoutExport(t *testing.T) {
+	if _, err := Setup(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	// Spans aren't recorded, but the client's trace is still propagated.
+	var upstream http.Header
+	handler := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := Tracer().Start(r.Context(), "generate")
+		defer span.End()
+		upstream = http.Header{}
+		Inject(ctx, upstream)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/engines/v1/completions", nil)
+	req.Header.Set("traceparent", traceparent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := upstream.Get("traceparent"); got != traceparent {
+		t.Errorf("upstream traceparent = %q, want %q", got, traceparent)
+	}
+}
+
+func TestEndRecordsError(t *testing.T) {
+	recorder := record(t)
+	_, span := Tracer().Start(context.Background(), "load")
+	End(span, context.DeadlineExceeded)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if status := spans[0].Status(); status.Description != context.DeadlineExceeded.Error() {
+		t.Errorf("status = %+v", status)
+	}
+	if len(spans[0].Events()) != 1 || spans[0].Events()[0].Name != "exception" {
+		t.Errorf("events = %+v, want the error", spans[0].Events())
+	}
+}
+
+func TestStreamWriter(t *testing.T) {
+	recorder := record(t)
+	ctx, parent := Tracer().Start(context.Background(), "request")
+	defer parent.End()
+
+	// Nothing is traced until the response starts.
+	_, end := StreamWriter(ctx, httptest.NewRecorder())
+	end()
+	if len(recorder.Ended()) != 0 {
+		t.Fatalf("got %d spans before the response started, want 0", len(recorder.Ended()))
+	}
+
+	rec := httptest.NewRecorder()
+	w, end := StreamWriter(ctx, rec, ModelKey.String("ai/smollm2"))
+	for _, chunk := range []string{"data: {}\n\n", "data: [DONE]\n\n"} {
+		w.Write([]byte(chunk))
+		w.(http.Flusher).Flush()
+	}
+	end()
+	if !rec.Flushed {
+		t.Error("response wasn't flushed")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "stream" || span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span %q has parent %s", span.Name(), span.Parent().SpanID())
+	}
+	attributes := attribute.NewSet(span.Attributes()...)
+	for key, want := range map[attribute.Key]attribute.Value{
+		ModelKey:                      attribute.StringValue("ai/smollm2"),
+		"model_runner.stream.bytes":   attribute.Int64Value(int64(len("data: {}\n\ndata: [DONE]\n\n"))),
+		"model_runner.stream.flushes": attribute.Int64Value(2),
+	} {
+		if got, _ := attributes.Value(key); got != want {
+			t.Errorf("%s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+	if len(span.Events()) != 1 || span.Events()[0].Name != "first flush" {
+		t.Errorf("events = %+v, want the first flush", span.Events())
+	}
+}
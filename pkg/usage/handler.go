@@ -0,0 +1,187 @@
+package usage
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/docker/model-runner/pkg/logging"
+)
+
+// Prefix is the prefix of the usage query routes.
+const Prefix = "/usage"
+
+// defaultRange is the time range of queries that don't set their start.
+const defaultRange = 24 * time.Hour
+
+// Summary is the usage of a key or a model, within a time bucket if queries
+// are bucketed.
+type Summary struct {
+	Key              *string    `json:"key,omitempty"`
+	Model            string     `json:"model,omitempty"`
+	Start            *time.Time `json:"start,omitempty"`
+	Requests         int        `json:"requests"`
+	FailedRequests   int        `json:"failed_requests"`
+	PromptTokens     int64      `json:"prompt_tokens"`
+	CompletionTokens int64      `json:"completion_tokens"`
+	TotalTokens      int64      `json:"total_tokens"`
+	AverageLatencyMS float64    `json:"average_latency_ms"`
+}
+
+// QueryResponse is the response of a usage query.
+type QueryResponse struct {
+	Object string    `json:"object"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Data   []Summary `json:"data"`
+}
+
+// HTTPHandler serves usage queries.
+type HTTPHandler struct {
+	// log is the associated logger.
+	log logging.Logger
+	// router is the HTTP request router.
+	router *http.ServeMux
+	// store is the usage store.
+	store *Store
+}
+
+// NewHTTPHandler creates a new handler serving queries of the usage in store.
+func NewHTTPHandler(log logging.Logger, store *Store) *HTTPHandler {
+	h := &HTTPHandler{log: log, router: http.NewServeMux(), store: store}
+	h.router.HandleFunc("GET "+Prefix+"/keys", h.handleQuery(false))
+	h.router.HandleFunc("GET "+Prefix+"/models", h.handleQuery(true))
+	return h
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// handleQuery handles GET /usage/keys and GET /usage/models requests, which
+// summarize usage by key or by model. Requests are filtered by the start and
+// end of their range, RFC 3339 timestamps or Unix seconds, and by key and
+// model, and summaries are split into hour or day buckets if requested.
+func (h *HTTPHandler) handleQuery(byModel bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		end := time.Now().UTC()
+		if s := query.Get("end"); s != "" {
+			var err error
+			if end, err = parseTime(s); err != nil {
+				http.Error(w, fmt.Sprintf("invalid end: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		start := end.Add(-defaultRange)
+		if s := query.Get("start"); s != "" {
+			var err error
+			if start, err = parseTime(s); err != nil {
+				http.Error(w, fmt.Sprintf("invalid start: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		if !start.Before(end) {
+			http.Error(w, "start must be before end", http.StatusBadRequest)
+			return
+		}
+		var bucket time.Duration
+		switch s := query.Get("bucket"); s {
+		case "":
+		case "hour":
+			bucket = time.Hour
+		case "day":
+			bucket = 24 * time.Hour
+		default:
+			http.Error(w, fmt.Sprintf("invalid bucket %q (expected hour or day)", s), http.StatusBadRequest)
+			return
+		}
+
+		records, err := h.store.Query(start, end)
+		if err != nil {
+			h.log.Warnf("Failed to query usage: %v", err)
+			http.Error(w, "failed to query usage", http.StatusInternalServerError)
+			return
+		}
+		if query.Has("key") || query.Has("model") {
+			records = slices.DeleteFunc(records, func(record Record) bool {
+				return (query.Has("key") && record.Key != query.Get("key")) ||
+					(query.Has("model") && record.Model != query.Get("model"))
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := QueryResponse{Object: "list", Start: start, End: end, Data: summarize(records, byModel, bucket)}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			h.log.Warnf("Failed to encode response: %v", err)
+		}
+	}
+}
+
+// summarize summarizes records by key or by model, within time buckets if
+// bucket is set, ordered by bucket and then by key or model.
+func summarize(records []Record, byModel bool, bucket time.Duration) []Summary {
+	type group struct {
+		name  string
+		start time.Time
+	}
+	summaries := make(map[group]*Summary)
+	latencies := make(map[group]float64)
+	for _, record := range records {
+		g := group{name: record.Key}
+		if byModel {
+			g.name = record.Model
+		}
+		if bucket > 0 {
+			g.start = record.Time.UTC().Truncate(bucket)
+		}
+		summary, ok := summaries[g]
+		if !ok {
+			summary = &Summary{}
+			if byModel {
+				summary.Model = g.name
+			} else {
+				summary.Key = &g.name
+			}
+			if bucket > 0 {
+				summary.Start = &g.start
+			}
+			summaries[g] = summary
+		}
+		summary.Requests++
+		if record.Status >= http.StatusBadRequest {
+			summary.FailedRequests++
+		}
+		summary.PromptTokens += record.PromptTokens
+		summary.CompletionTokens += record.CompletionTokens
+		summary.TotalTokens += record.PromptTokens + record.CompletionTokens
+		latencies[g] += record.LatencyMS
+	}
+
+	groups := make([]group, 0, len(summaries))
+	for g := range summaries {
+		groups = append(groups, g)
+	}
+	slices.SortFunc(groups, func(a, b group) int {
+		return cmp.Or(a.start.Compare(b.start), cmp.Compare(a.name, b.name))
+	})
+	data := make([]Summary, len(groups))
+	for i, g := range groups {
+		data[i] = *summaries[g]
+		data[i].AverageLatencyMS = latencies[g] / float64(data[i].Requests)
+	}
+	return data
+}
+
+// parseTime parses an RFC 3339 timestamp or Unix seconds.
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second))).UTC(), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
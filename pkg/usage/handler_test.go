@@ -0,0 +1,141 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeInference serves inference responses reporting their token usage,
+// twice for moderations, which classify two inputs.
+func fakeInference(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/models" {
+		fmt.Fprint(w, `{"data":[]}`)
+		return
+	}
+	middleware.SetModel(r.Context(), r.URL.Query().Get("model"))
+	if r.URL.Query().Get("model") == "missing" {
+		http.Error(w, "model not found", http.StatusNotFound)
+		return
+	}
+	responses := 1
+	if r.URL.Path == "/v1/moderations" {
+		responses = 2
+	}
+	for range responses {
+		tw, finish := TokenWriter(r.Context(), w)
+		fmt.Fprint(tw, `data: {"choices":[{"delta":{"content":"hi"}}]}`+"\n\n")
+		fmt.Fprint(tw, `data: {"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`+"\n\n")
+		fmt.Fprint(tw, "data: [DONE]\n\n")
+		finish()
+	}
+}
+
+func TestMeter(t *testing.T) {
+	store, err := NewStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	handler := NewMeter(logrus.New(), store).Handler(http.HandlerFunc(fakeInference))
+
+	for _, request := range []struct {
+		path string
+		key  string
+	}{
+		{path: "/v1/chat/completions?model=m", key: "Bearer secret"},
+		{path: "/v1/moderations?model=guard", key: "Bearer secret"},
+		{path: "/v1/chat/completions?model=missing"},
+		{path: "/v1/models"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, request.path, nil)
+		if request.key != "" {
+			r.Header.Set("Authorization", request.key)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	records, err := store.Query(time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to query records: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records of inference requests, got %+v", records)
+	}
+	key := KeyID("Bearer secret")
+	if len(key) != 16 || records[0].Key != key || records[0].Model != "m" || records[0].Route != "/v1/chat/completions" || records[0].Status != http.StatusOK {
+		t.Errorf("Unexpected record %+v", records[0])
+	}
+	if records[0].PromptTokens != 3 || records[0].CompletionTokens != 2 {
+		t.Errorf("Expected 3 prompt and 2 completion tokens, got %+v", records[0])
+	}
+	if records[1].PromptTokens != 6 || records[1].CompletionTokens != 4 {
+		t.Errorf("Expected the tokens of both responses to add up, got %+v", records[1])
+	}
+	if records[2].Key != "" || records[2].Status != http.StatusNotFound || records[2].PromptTokens != 0 {
+		t.Errorf("Unexpected record of a failed request %+v", records[2])
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	store, err := NewStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	for _, record := range []Record{
+		{Time: start.Add(10 * time.Minute), Key: "a", Model: "m", Status: 200, PromptTokens: 10, CompletionTokens: 5, LatencyMS: 100},
+		{Time: start.Add(20 * time.Minute), Key: "b", Model: "m", Status: 200, PromptTokens: 20, CompletionTokens: 10, LatencyMS: 300},
+		{Time: start.Add(70 * time.Minute), Key: "a", Model: "n", Status: 500, LatencyMS: 50},
+		{Time: start.Add(-time.Hour), Key: "a", Model: "m", Status: 200, PromptTokens: 100},
+	} {
+		if err := store.Append(record); err != nil {
+			t.Fatalf("Failed to append record: %v", err)
+		}
+	}
+	h := NewHTTPHandler(logrus.New(), store)
+
+	query := func(path string) ([]Summary, int) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			return nil, w.Code
+		}
+		var response QueryResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response.Data, w.Code
+	}
+
+	summaries, _ := query("/usage/keys?start=2026-03-10T09:00:00Z&end=2026-03-10T11:00:00Z")
+	if len(summaries) != 2 || *summaries[0].Key != "a" || *summaries[1].Key != "b" {
+		t.Fatalf("Expected summaries of keys a and b, got %+v", summaries)
+	}
+	if a := summaries[0]; a.Requests != 2 || a.FailedRequests != 1 || a.PromptTokens != 10 || a.TotalTokens != 15 || a.AverageLatencyMS != 75 {
+		t.Errorf("Unexpected summary of key a %+v", a)
+	}
+
+	summaries, _ = query(fmt.Sprintf("/usage/models?start=%d&end=%d&bucket=hour&key=a", start.Unix(), start.Add(2*time.Hour).Unix()))
+	if len(summaries) != 2 || summaries[0].Model != "m" || !summaries[0].Start.Equal(start) || summaries[1].Model != "n" || !summaries[1].Start.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected hourly summaries of models m and n for key a, got %+v", summaries)
+	}
+
+	for _, path := range []string{
+		"/usage/keys?start=yesterday",
+		"/usage/keys?start=2026-03-10T11:00:00Z&end=2026-03-10T09:00:00Z",
+		"/usage/keys?bucket=week",
+	} {
+		if _, code := query(path); code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, path, code)
+		}
+	}
+}
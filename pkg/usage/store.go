@@ -0,0 +1,165 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dayLayout is the layout of the dates of the daily files of a store.
+const dayLayout = "2006-01-02"
+
+// Store persists usage records in a directory, as one JSON object per line
+// in a file per UTC day, so that queries only read the days in their range
+// and days beyond the retention period can be removed as a whole.
+type Store struct {
+	// dir is the directory of the store.
+	dir string
+	// retention is how long records are kept, or 0 to keep them forever.
+	retention time.Duration
+	// mu protects the fields below and serializes appends.
+	mu sync.Mutex
+	// file is the file of the current day, once it's opened.
+	file *os.File
+	// day is the date of the current day.
+	day string
+}
+
+// NewStore creates a store in a directory, creating it if needed, which keeps
+// records for a retention period, or forever if it's 0.
+func NewStore(dir string, retention time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", dir, err)
+	}
+	return &Store{dir: dir, retention: retention}, nil
+}
+
+// Append appends a record to the file of its day, opening it and removing
+// the files of the days beyond the retention period when the day changes.
+func (s *Store) Append(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if day := record.Time.UTC().Format(dayLayout); s.file == nil || day != s.day {
+		if s.file != nil {
+			s.file.Close()
+			s.file = nil
+		}
+		file, err := os.OpenFile(s.path(day), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		s.file, s.day = file, day
+		s.prune(record.Time)
+	}
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// prune removes the files of the days that ended beyond the retention period
+// before now.
+func (s *Store) prune(now time.Time) {
+	if s.retention <= 0 {
+		return
+	}
+	days, err := s.days()
+	if err != nil {
+		return
+	}
+	for _, day := range days {
+		date, _ := time.Parse(dayLayout, day)
+		if date.Add(24 * time.Hour).Before(now.Add(-s.retention)) {
+			os.Remove(s.path(day))
+		}
+	}
+}
+
+// Query returns the records of requests made from start until end, in the
+// order in which they were recorded.
+func (s *Store) Query(start, end time.Time) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	days, err := s.days()
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for _, day := range days {
+		date, _ := time.Parse(dayLayout, day)
+		if !date.Add(24*time.Hour).After(start) || !date.Before(end) {
+			continue
+		}
+		if records, err = s.read(day, start, end, records); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// read appends the records of a day made from start until end to records.
+// Lines that can't be decoded, such as one cut short by a crash, are skipped.
+func (s *Store) read(day string, start, end time.Time, records []Record) ([]Record, error) {
+	file, err := os.Open(s.path(day))
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if !record.Time.Before(start) && record.Time.Before(end) {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// days returns the dates of the daily files of the store, in order.
+func (s *Store) days() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var days []string
+	for _, path := range paths {
+		day := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+		if _, err := time.Parse(dayLayout, day); err == nil {
+			days = append(days, day)
+		}
+	}
+	slices.Sort(days)
+	return days, nil
+}
+
+// path returns the path of the file of a day.
+func (s *Store) path(day string) string {
+	return filepath.Join(s.dir, day+".jsonl")
+}
+
+// Close closes the file of the current day.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
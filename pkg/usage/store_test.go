@@ -0,0 +1,63 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, 48*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	for _, record := range []Record{
+		{Time: day.Add(23 * time.Hour), Key: "a", Model: "m", PromptTokens: 1},
+		{Time: day.Add(25 * time.Hour), Key: "b", Model: "m", PromptTokens: 2},
+		{Time: day.Add(26 * time.Hour), Key: "a", Model: "n", PromptTokens: 3},
+	} {
+		if err := store.Append(record); err != nil {
+			t.Fatalf("Failed to append record: %v", err)
+		}
+	}
+	for _, name := range []string{"2026-03-10.jsonl", "2026-03-11.jsonl"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected daily file %s: %v", name, err)
+		}
+	}
+
+	records, err := store.Query(day.Add(23*time.Hour), day.Add(26*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to query records: %v", err)
+	}
+	if len(records) != 2 || records[0].PromptTokens != 1 || records[1].PromptTokens != 2 {
+		t.Errorf("Expected the first two records, got %+v", records)
+	}
+
+	// A line cut short by a crash is skipped.
+	file, err := os.OpenFile(filepath.Join(dir, "2026-03-11.jsonl"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Failed to open daily file: %v", err)
+	}
+	file.WriteString(`{"time":"2026-03-11T03:00:00Z","mod`)
+	file.Close()
+	if records, err = store.Query(day, day.Add(48*time.Hour)); err != nil || len(records) != 3 {
+		t.Errorf("Expected 3 records, got %+v (%v)", records, err)
+	}
+
+	// Days beyond the retention period are removed when a new day starts.
+	if err := store.Append(Record{Time: day.Add(4 * 24 * time.Hour), Model: "m"}); err != nil {
+		t.Fatalf("Failed to append record: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2026-03-10.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("Expected the file of 2026-03-10 to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2026-03-11.jsonl")); err != nil {
+		t.Errorf("Expected the file of 2026-03-11 to be kept: %v", err)
+	}
+}
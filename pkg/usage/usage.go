@@ -0,0 +1,122 @@
+// Package usage meters the inference requests served by the model runner: the
+// model, token counts, latency, and API key of every request are recorded in
+// a store, which can be queried by key or by model over time ranges, for
+// example for chargeback.
+package usage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/model-runner/pkg/logging"
+	"github.com/docker/model-runner/pkg/middleware"
+)
+
+// Record is the usage of a request.
+type Record struct {
+	Time             time.Time `json:"time"`
+	Key              string    `json:"key,omitempty"`
+	Model            string    `json:"model"`
+	Route            string    `json:"route"`
+	Status           int       `json:"status"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	LatencyMS        float64   `json:"latency_ms"`
+}
+
+// KeyID identifies the API key of a request, from its Authorization header,
+// by the first 16 hexadecimal digits of its SHA-256 hash, so that keys aren't
+// retained. Requests without a key have an empty ID.
+func KeyID(authorization string) string {
+	key := strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// tokens holds the token usage of the inference responses served to a
+// request.
+type tokens struct {
+	mu               sync.Mutex
+	promptTokens     int64
+	completionTokens int64
+}
+
+type tokensKey struct{}
+
+// TokenWriter wraps the writer of an inference response to the request
+// associated with ctx, so that the token usage that it reports is added to
+// that of the request when the returned function is called, once the response
+// is complete. Requests that serve several inference responses, such as
+// moderations of several inputs, add up their usage. It returns w as-is if the
+// request isn't metered.
+func TokenWriter(ctx context.Context, w http.ResponseWriter) (http.ResponseWriter, func()) {
+	t, ok := ctx.Value(tokensKey{}).(*tokens)
+	if !ok {
+		return w, func() {}
+	}
+	rw := middleware.NewUsageRecorder(w)
+	return rw, func() {
+		prompt, completion := rw.Usage()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if prompt != nil {
+			t.promptTokens += *prompt
+		}
+		if completion != nil {
+			t.completionTokens += *completion
+		}
+	}
+}
+
+// Meter records the usage of requests in a store.
+type Meter struct {
+	// log is the associated logger.
+	log logging.Logger
+	// store is the usage store.
+	store *Store
+}
+
+// NewMeter creates a new meter recording usage in store.
+func NewMeter(log logging.Logger, store *Store) *Meter {
+	return &Meter{log: log, store: store}
+}
+
+// Handler wraps next so that the usage of every inference request it serves
+// is recorded. Requests that don't target a model aren't recorded.
+func (m *Meter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		t := &tokens{}
+		r = middleware.Annotate(r.WithContext(context.WithValue(r.Context(), tokensKey{}, t)))
+		rw := middleware.NewUsageRecorder(w)
+		next.ServeHTTP(rw, r)
+
+		model := middleware.Model(r.Context())
+		if model == "" {
+			return
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		record := Record{
+			Time:             start.UTC(),
+			Key:              KeyID(r.Header.Get("Authorization")),
+			Model:            model,
+			Route:            r.URL.Path,
+			Status:           rw.Status(),
+			PromptTokens:     t.promptTokens,
+			CompletionTokens: t.completionTokens,
+			LatencyMS:        float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err := m.store.Append(record); err != nil {
+			m.log.Warnf("Failed to record usage: %v", err)
+		}
+	})
+}
@@ -0,0 +1,300 @@
+package wschat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/internal/relay"
+)
+
+var (
+	// errUpdated cancels the request of a generation whose parameters were
+	// updated, to resume it with them.
+	errUpdated = errors.New("generation updated")
+	// errCancelled cancels the request of a generation cancelled by the
+	// client.
+	errCancelled = errors.New("generation cancelled")
+)
+
+// generation is a generation in progress. Updating its parameters cancels
+// its request and resumes it with a new request continuing the assistant
+// message generated so far, since backends can't change the parameters of a
+// request in progress.
+type generation struct {
+	c *connection
+	// id identifies the generation.
+	id string
+	// request is the chat completion request, with the updated parameters
+	// applied.
+	request map[string]json.RawMessage
+
+	// lock guards the fields below, which are shared with the connection.
+	lock sync.Mutex
+	// cancelRequest cancels the request in progress, if any.
+	cancelRequest context.CancelCauseFunc
+	// updates are the parameters of updates to apply to the next request.
+	updates map[string]json.RawMessage
+	// cancelled indicates whether the client cancelled the generation.
+	cancelled bool
+
+	// content is the content of the assistant message generated so far.
+	content strings.Builder
+	// finishReason is the finish reason of the generation, once it ended.
+	finishReason string
+	// usage is the token usage of the generation.
+	usage Usage
+}
+
+// update updates the parameters of the generation.
+func (g *generation) update(parameters map[string]json.RawMessage) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.updates == nil {
+		g.updates = make(map[string]json.RawMessage)
+	}
+	for key, value := range parameters {
+		g.updates[key] = value
+	}
+	if g.cancelRequest != nil {
+		g.cancelRequest(errUpdated)
+	}
+}
+
+// stop cancels the generation.
+func (g *generation) stop() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.cancelled = true
+	if g.cancelRequest != nil {
+		g.cancelRequest(errCancelled)
+	}
+}
+
+// run serves the generation until it ends, resuming it when its parameters
+// are updated.
+func (g *generation) run() {
+	for {
+		g.lock.Lock()
+		if g.cancelled {
+			g.lock.Unlock()
+			g.done(FinishReasonCancelled)
+			return
+		}
+		for key, value := range g.updates {
+			g.request[key] = value
+		}
+		g.updates = nil
+		ctx, cancel := context.WithCancelCause(g.c.ctx)
+		g.cancelRequest = cancel
+		g.lock.Unlock()
+
+		body, err := g.body()
+		if err != nil {
+			cancel(nil)
+			g.c.sendError(g.id, http.StatusBadRequest, err.Error())
+			return
+		}
+		w := &chunkWriter{header: make(http.Header), status: http.StatusOK, g: g}
+		g.c.h.next.ServeHTTP(w, g.c.chatRequest(ctx, body))
+		cause := context.Cause(ctx)
+		g.lock.Lock()
+		g.cancelRequest = nil
+		g.lock.Unlock()
+		cancel(nil)
+
+		switch {
+		case g.finishReason != "":
+			g.done(g.finishReason)
+		case errors.Is(cause, errUpdated):
+			continue
+		case errors.Is(cause, errCancelled):
+			g.done(FinishReasonCancelled)
+		case g.c.ctx.Err() != nil:
+			// The connection closed.
+		case w.status != http.StatusOK:
+			g.c.sendError(g.id, w.status, errorMessage(w.body.Bytes()))
+		case w.err != nil:
+			g.c.sendError(g.id, http.StatusInternalServerError, w.err.Error())
+		default:
+			g.done("")
+		}
+		return
+	}
+}
+
+// done ends the generation.
+func (g *generation) done(finishReason string) {
+	usage := g.usage
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	g.c.send(ServerMessage{Type: TypeDone, ID: g.id, FinishReason: finishReason, Usage: &usage})
+}
+
+// body returns the body of the next request of the generation: the chat
+// completion request streamed with its usage, continuing the assistant
+// message generated so far, if any.
+func (g *generation) body() ([]byte, error) {
+	request := make(map[string]json.RawMessage, len(g.request)+4)
+	for key, value := range g.request {
+		request[key] = value
+	}
+	request["stream"] = json.RawMessage("true")
+	request["stream_options"] = json.RawMessage(`{"include_usage":true}`)
+	if g.content.Len() > 0 {
+		var messages []json.RawMessage
+		if err := json.Unmarshal(request["messages"], &messages); err != nil {
+			return nil, errors.New("messages must be an array")
+		}
+		prefix, err := json.Marshal(map[string]string{"role": "assistant", "content": g.content.String()})
+		if err != nil {
+			return nil, err
+		}
+		if request["messages"], err = json.Marshal(append(messages, prefix)); err != nil {
+			return nil, err
+		}
+		request["continue_final_message"] = json.RawMessage("true")
+		request["add_generation_prompt"] = json.RawMessage("false")
+	}
+	return json.Marshal(request)
+}
+
+// chatRequest returns a chat completion request of a generation, with the
+// headers of the request that opened the connection.
+func (c *connection) chatRequest(ctx context.Context, body []byte) *http.Request {
+	r := c.upgrade.Clone(ctx)
+	r.Method = http.MethodPost
+	r.URL.Path = relay.ChatCompletionsPath(c.upgrade.URL.Path, "/chat/ws")
+	r.URL.RawPath = ""
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	for key := range r.Header {
+		if strings.HasPrefix(key, "Sec-Websocket-") {
+			r.Header.Del(key)
+		}
+	}
+	r.Header.Del("Upgrade")
+	r.Header.Del("Connection")
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(inference.RequestOriginHeader, inference.OriginWebSocketChat)
+	return r
+}
+
+// errorMessage returns the message of an error response of the inference
+// handler.
+func errorMessage(body []byte) string {
+	var response struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &response) == nil && response.Error.Message != "" {
+		return response.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// chunkWriter sends the chunks of the streamed response of a generation's
+// request to the client as they're written, keeping track of the content
+// generated and of the token usage. Error responses are recorded.
+type chunkWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	// g is the generation.
+	g *generation
+	// body is the incomplete line written last, or the body of error
+	// responses.
+	body bytes.Buffer
+	// err is the error event that ended the stream, if any.
+	err error
+}
+
+// Header implements net/http.ResponseWriter.Header.
+func (w *chunkWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements net/http.ResponseWriter.WriteHeader.
+func (w *chunkWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+}
+
+// Write implements net/http.ResponseWriter.Write.
+func (w *chunkWriter) Write(data []byte) (int, error) {
+	w.wroteHeader = true
+	w.body.Write(data)
+	if w.status != http.StatusOK {
+		return len(data), nil
+	}
+	for {
+		line, _, found := bytes.Cut(w.body.Bytes(), []byte("\n"))
+		if !found {
+			break
+		}
+		w.chunk(line)
+		w.body.Next(len(line) + 1)
+	}
+	return len(data), nil
+}
+
+// Flush implements http.Flusher.Flush. Chunks are sent as they're written.
+func (w *chunkWriter) Flush() {}
+
+// chunk handles a line of the stream. Chunks with choices are sent to the
+// client, and the usage of the chunk that ends the stream is recorded.
+func (w *chunkWriter) chunk(line []byte) {
+	data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data: "))
+	if !ok || bytes.Equal(data, []byte("[DONE]")) {
+		return
+	}
+	var chunk struct {
+		Choices []struct {
+			Index int `json:"index"`
+			Delta struct {
+				Content string `json:"content"`
+			} `json:"delta"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage *Usage `json:"usage"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return
+	}
+	if chunk.Error != nil {
+		w.err = errors.New(chunk.Error.Message)
+		return
+	}
+	g := w.g
+	if chunk.Usage != nil {
+		// Resumed requests are prompted with the content generated before.
+		if g.usage.PromptTokens == 0 {
+			g.usage.PromptTokens = chunk.Usage.PromptTokens
+		}
+		g.usage.CompletionTokens += chunk.Usage.CompletionTokens
+	}
+	if len(chunk.Choices) == 0 {
+		return
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		g.content.WriteString(choice.Delta.Content)
+		if choice.FinishReason != "" {
+			g.finishReason = choice.FinishReason
+		}
+	}
+	g.c.send(ServerMessage{Type: TypeChunk, ID: g.id, Chunk: json.RawMessage(data)})
+}
@@ -0,0 +1,234 @@
+// Package wschat serves chat completions over WebSocket connections, for
+// interactive clients behind proxies that break server-sent events. A
+// connection multiplexes concurrent generations, identified by the client,
+// which can be cancelled or have their parameters updated while they stream.
+// Generations are served as streamed chat completions by the inference
+// handler.
+package wschat
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/docker/model-runner/pkg/logging"
+	"github.com/docker/model-runner/pkg/middleware"
+	"golang.org/x/net/websocket"
+)
+
+// Path is the path of the WebSocket chat endpoint, under each prefix.
+const Path = "/v1/chat/ws"
+
+// maximumMessageSize is the maximum size of a message sent by clients,
+// matching the limit applied by the scheduler to request bodies.
+const maximumMessageSize = 10 * 1024 * 1024
+
+// maximumGenerations is the maximum number of concurrent generations of a
+// connection.
+const maximumGenerations = 16
+
+// HTTPHandler serves the WebSocket chat endpoint.
+type HTTPHandler struct {
+	// log is the associated logger.
+	log logging.Logger
+	// router is the HTTP request router.
+	router *http.ServeMux
+	// httpHandler is the router wrapped with CORS checks, since browsers
+	// don't enforce the same-origin policy on WebSocket connections.
+	httpHandler http.Handler
+	// next is the inference handler.
+	next http.Handler
+}
+
+// NewHTTPHandler creates a new WebSocket chat handler that forwards chat
+// completions requests to next, which should serve the inference API.
+func NewHTTPHandler(log logging.Logger, next http.Handler) *HTTPHandler {
+	h := &HTTPHandler{
+		log:    log,
+		router: http.NewServeMux(),
+		next:   next,
+	}
+	for _, prefix := range Prefixes() {
+		h.router.HandleFunc("GET "+prefix+Path, h.handleConnect)
+	}
+	h.httpHandler = middleware.CorsMiddleware(nil, h.router)
+	return h
+}
+
+// Prefixes returns the prefixes under which the endpoint is served: the
+// root, like the OpenAI endpoints, and the inference prefix, with or without
+// a backend.
+func Prefixes() []string {
+	return []string{"", inference.InferencePrefix, inference.InferencePrefix + "/{backend}"}
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.httpHandler.ServeHTTP(w, r)
+}
+
+// handleConnect handles GET /v1/chat/ws requests, which open connections.
+func (h *HTTPHandler) handleConnect(w http.ResponseWriter, r *http.Request) {
+	// Origins are checked by the CORS middleware.
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		h.serve(ws, r)
+	}}
+	server.ServeHTTP(hijacker{w}, r)
+}
+
+// hijacker makes the connection of responses hijackable through the
+// writers that wrap them, such as those of the access log.
+type hijacker struct {
+	http.ResponseWriter
+}
+
+// Hijack implements net/http.Hijacker.Hijack.
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(h.ResponseWriter).Hijack()
+}
+
+// connection is an open WebSocket connection.
+type connection struct {
+	h *HTTPHandler
+	// ws is the WebSocket connection.
+	ws *websocket.Conn
+	// upgrade is the request that opened the connection, whose headers,
+	// such as its authorization, are passed along with generations.
+	upgrade *http.Request
+	// ctx is canceled when the connection closes.
+	ctx context.Context
+	// sendLock serializes the messages sent to the client.
+	sendLock sync.Mutex
+	// lock guards generations.
+	lock sync.Mutex
+	// generations are the generations in progress, by ID.
+	generations map[string]*generation
+	// wg tracks the generations in progress.
+	wg sync.WaitGroup
+}
+
+// serve serves a connection until it's closed, cancelling its generations
+// in progress.
+func (h *HTTPHandler) serve(ws *websocket.Conn, r *http.Request) {
+	ws.MaxPayloadBytes = maximumMessageSize
+	ctx, cancel := context.WithCancel(r.Context())
+	c := &connection{h: h, ws: ws, upgrade: r, ctx: ctx, generations: make(map[string]*generation)}
+	defer c.wg.Wait()
+	defer cancel()
+
+	for {
+		var message ClientMessage
+		if err := websocket.JSON.Receive(ws, &message); err != nil {
+			var syntaxError *json.SyntaxError
+			var typeError *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &syntaxError) || errors.As(err, &typeError):
+				c.sendError("", http.StatusBadRequest, "invalid message")
+				continue
+			case errors.Is(err, websocket.ErrFrameTooLarge):
+				c.sendError("", http.StatusBadRequest, "message too large")
+				continue
+			}
+			return
+		}
+		switch message.Type {
+		case TypeGenerate:
+			c.generate(message)
+		case TypeUpdate:
+			c.update(message)
+		case TypeCancel:
+			c.cancel(message)
+		default:
+			c.sendError(message.ID, http.StatusBadRequest, "unknown message type: "+message.Type)
+		}
+	}
+}
+
+// send sends a message to the client. Failures mean that the connection
+// closed, which ends its generations.
+func (c *connection) send(message ServerMessage) {
+	c.sendLock.Lock()
+	defer c.sendLock.Unlock()
+	if err := websocket.JSON.Send(c.ws, message); err != nil && c.ctx.Err() == nil {
+		c.h.log.Debugf("Failed to send WebSocket message: %v", err)
+	}
+}
+
+// sendError sends an error to the client, about a generation if id is set.
+func (c *connection) sendError(id string, status int, message string) {
+	c.send(ServerMessage{Type: TypeError, ID: id, Error: &Error{Message: message, Status: status}})
+}
+
+// generate starts a generation.
+func (c *connection) generate(message ClientMessage) {
+	if message.ID == "" {
+		c.sendError("", http.StatusBadRequest, "generations must have an ID")
+		return
+	}
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(message.Request, &request); err != nil || request == nil {
+		c.sendError(message.ID, http.StatusBadRequest, "request must be a chat completion request")
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.generations[message.ID]; ok {
+		c.sendError(message.ID, http.StatusBadRequest, "a generation with this ID is in progress")
+		return
+	}
+	if len(c.generations) >= maximumGenerations {
+		c.sendError(message.ID, http.StatusTooManyRequests, "too many concurrent generations")
+		return
+	}
+	g := &generation{c: c, id: message.ID, request: request}
+	c.generations[message.ID] = g
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		g.run()
+		c.lock.Lock()
+		delete(c.generations, g.id)
+		c.lock.Unlock()
+	}()
+}
+
+// generation returns the generation in progress with the ID of a message,
+// or sends an error if there's none.
+func (c *connection) generation(message ClientMessage) *generation {
+	c.lock.Lock()
+	g, ok := c.generations[message.ID]
+	c.lock.Unlock()
+	if !ok {
+		c.sendError(message.ID, http.StatusNotFound, "no generation with this ID is in progress")
+	}
+	return g
+}
+
+// fixedFields are the fields of requests that updates can't change.
+var fixedFields = []string{"model", "messages", "stream", "stream_options", "n"}
+
+// update updates the parameters of a generation.
+func (c *connection) update(message ClientMessage) {
+	for _, field := range fixedFields {
+		if _, ok := message.Parameters[field]; ok {
+			c.sendError(message.ID, http.StatusBadRequest, field+" can't be updated")
+			return
+		}
+	}
+	if g := c.generation(message); g != nil {
+		g.update(message.Parameters)
+	}
+}
+
+// cancel cancels a generation.
+func (c *connection) cancel(message ClientMessage) {
+	if g := c.generation(message); g != nil {
+		g.stop()
+	}
+}
@@ -0,0 +1,198 @@
+package wschat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/model-runner/pkg/inference"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+)
+
+// fakeInference streams chat completions, recording the requests it
+// receives. Generations of the "slow" model stream a first chunk and wait to
+// be cancelled, unless they're resumed.
+type fakeInference struct {
+	requests chan map[string]any
+}
+
+func (f *fakeInference) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request map[string]any
+	json.NewDecoder(r.Body).Decode(&request)
+	request["path"] = r.URL.Path
+	request["authorization"] = r.Header.Get("Authorization")
+	f.requests <- request
+	if request["model"] == "missing" {
+		http.Error(w, `{"error":{"message":"model not found"}}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"content":"Hel"}}]}`+"\n\n")
+	if request["model"] == "slow" && request["continue_final_message"] != true {
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		return
+	}
+	fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}`+"\n\n")
+	fmt.Fprint(w, `data: {"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`+"\n\n")
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// allowedOrigin is the origin allowed to connect in tests.
+const allowedOrigin = "http://localhost:3000"
+
+// connect opens a connection to the endpoint, served with an inference
+// handler, from an origin.
+func connect(t *testing.T, next http.Handler, path, origin string) (*websocket.Conn, error) {
+	t.Helper()
+	t.Setenv("DMR_ORIGINS", allowedOrigin)
+	server := httptest.NewServer(NewHTTPHandler(logrus.New(), next))
+	t.Cleanup(server.Close)
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+path, origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Header.Set("Authorization", "Bearer secret")
+	return websocket.DialConfig(config)
+}
+
+// dial opens a connection to the endpoint, served with an inference handler.
+func dial(t *testing.T, next http.Handler, path string) *websocket.Conn {
+	t.Helper()
+	ws, err := connect(t, next, path, allowedOrigin)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	ws.SetDeadline(time.Now().Add(10 * time.Second))
+	return ws
+}
+
+// send sends a message.
+func send(t *testing.T, ws *websocket.Conn, message string) {
+	t.Helper()
+	if err := websocket.Message.Send(ws, message); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+}
+
+// receive receives messages until one of the given type.
+func receive(t *testing.T, ws *websocket.Conn, messageType string) (ServerMessage, []ServerMessage) {
+	t.Helper()
+	var received []ServerMessage
+	for {
+		var message ServerMessage
+		if err := websocket.JSON.Receive(ws, &message); err != nil {
+			t.Fatalf("Failed to receive message: %v", err)
+		}
+		if message.Type == messageType {
+			return message, received
+		}
+		received = append(received, message)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	fake := &fakeInference{requests: make(chan map[string]any, 2)}
+	ws := dial(t, fake, "/engines/llama.cpp/v1/chat/ws")
+
+	send(t, ws, `{"type":"generate","id":"a","request":{"model":"m","messages":[{"role":"user","content":"Hi"}]}}`)
+	send(t, ws, `{"type":"generate","id":"b","request":{"model":"m","messages":[{"role":"user","content":"Hi"}]}}`)
+	content := map[string]string{}
+	var done []ServerMessage
+	for len(done) < 2 {
+		message, chunks := receive(t, ws, TypeDone)
+		for _, chunk := range chunks {
+			var decoded struct {
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+				} `json:"choices"`
+			}
+			json.Unmarshal(chunk.Chunk, &decoded)
+			content[chunk.ID] += decoded.Choices[0].Delta.Content
+		}
+		done = append(done, message)
+	}
+	for _, message := range done {
+		if message.FinishReason != "stop" || message.Usage == nil || message.Usage.TotalTokens != 5 {
+			t.Errorf("Unexpected end of generation %+v", message)
+		}
+	}
+	if content["a"] != "Hello" || content["b"] != "Hello" {
+		t.Errorf("Expected both generations to be streamed, got %v", content)
+	}
+
+	request := <-fake.requests
+	if request["path"] != inference.InferencePrefix+"/llama.cpp/v1/chat/completions" || request["stream"] != true || request["authorization"] != "Bearer secret" {
+		t.Errorf("Unexpected request %v", request)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	fake := &fakeInference{requests: make(chan map[string]any, 1)}
+	ws := dial(t, fake, "/v1/chat/ws")
+
+	send(t, ws, `{"type":"generate","id":"a","request":{"model":"slow","messages":[{"role":"user","content":"Hi"}]}}`)
+	receive(t, ws, TypeChunk)
+	send(t, ws, `{"type":"cancel","id":"a"}`)
+	if message, _ := receive(t, ws, TypeDone); message.FinishReason != FinishReasonCancelled {
+		t.Errorf("Expected the generation to be cancelled, got %+v", message)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	fake := &fakeInference{requests: make(chan map[string]any, 2)}
+	ws := dial(t, fake, "/v1/chat/ws")
+
+	send(t, ws, `{"type":"generate","id":"a","request":{"model":"slow","temperature":1,"messages":[{"role":"user","content":"Hi"}]}}`)
+	receive(t, ws, TypeChunk)
+	send(t, ws, `{"type":"update","id":"a","parameters":{"temperature":0.2}}`)
+	if message, _ := receive(t, ws, TypeDone); message.FinishReason != "stop" || message.Usage.CompletionTokens != 2 {
+		t.Errorf("Expected the generation to be resumed, got %+v", message)
+	}
+
+	<-fake.requests
+	resumed := <-fake.requests
+	messages := resumed["messages"].([]any)
+	last := messages[len(messages)-1].(map[string]any)
+	if resumed["temperature"] != 0.2 || last["role"] != "assistant" || last["content"] != "Hel" || resumed["continue_final_message"] != true {
+		t.Errorf("Expected the generation to continue with the updated parameters, got %v", resumed)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	fake := &fakeInference{requests: make(chan map[string]any, 1)}
+	ws := dial(t, fake, "/v1/chat/ws")
+
+	for _, test := range []struct {
+		message string
+		status  int
+	}{
+		{message: `{"type":"generate","id":"a","request":{"model":"missing"}}`, status: http.StatusNotFound},
+		{message: `{"type":"cancel","id":"b"}`, status: http.StatusNotFound},
+		{message: `{"type":"update","id":"b","parameters":{"model":"n"}}`, status: http.StatusBadRequest},
+		{message: `{"type":"generate","request":{"model":"m"}}`, status: http.StatusBadRequest},
+		{message: `{"type":"resume","id":"b"}`, status: http.StatusBadRequest},
+		{message: `not json`, status: http.StatusBadRequest},
+	} {
+		send(t, ws, test.message)
+		message, _ := receive(t, ws, TypeError)
+		if message.Error == nil || message.Error.Status != test.status {
+			t.Errorf("Expected an error with status %d for %s, got %+v", test.status, test.message, message)
+		}
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	fake := &fakeInference{requests: make(chan map[string]any, 1)}
+	if _, err := connect(t, fake, "/v1/chat/ws", "http://example.com"); err == nil {
+		t.Error("Expected connections from other origins to be rejected")
+	}
+}
@@ -0,0 +1,71 @@
+package wschat
+
+import "encoding/json"
+
+// The types of the messages sent by clients.
+const (
+	// TypeGenerate starts a generation of a chat completion request.
+	TypeGenerate = "generate"
+	// TypeUpdate updates the parameters of a generation for the rest of it.
+	TypeUpdate = "update"
+	// TypeCancel cancels a generation.
+	TypeCancel = "cancel"
+)
+
+// The types of the messages sent to clients.
+const (
+	// TypeChunk carries a chunk of a generation.
+	TypeChunk = "chunk"
+	// TypeDone ends a generation.
+	TypeDone = "done"
+	// TypeError reports an error, which ends a generation if it's about
+	// one.
+	TypeError = "error"
+)
+
+// FinishReasonCancelled is the finish reason of cancelled generations.
+const FinishReasonCancelled = "cancelled"
+
+// ClientMessage is a message sent by a client.
+type ClientMessage struct {
+	// Type is the type of the message.
+	Type string `json:"type"`
+	// ID identifies the generation, chosen by the client when starting it.
+	ID string `json:"id"`
+	// Request is the chat completion request of a generation, which is
+	// streamed regardless of its stream field.
+	Request json.RawMessage `json:"request,omitempty"`
+	// Parameters are the fields of the request set by an update, such as
+	// temperature or max_tokens.
+	Parameters map[string]json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ServerMessage is a message sent to a client.
+type ServerMessage struct {
+	// Type is the type of the message.
+	Type string `json:"type"`
+	// ID identifies the generation.
+	ID string `json:"id,omitempty"`
+	// Chunk is a chat completion chunk.
+	Chunk json.RawMessage `json:"chunk,omitempty"`
+	// FinishReason is the finish reason of the generation that ended.
+	FinishReason string `json:"finish_reason,omitempty"`
+	// Usage is the token usage of the generation that ended.
+	Usage *Usage `json:"usage,omitempty"`
+	// Error is the error reported.
+	Error *Error `json:"error,omitempty"`
+}
+
+// Usage is the token usage of a generation.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Error is an error reported to a client, with the HTTP status the request
+// would have been answered with.
+type Error struct {
+	Message string `json:"message"`
+	Status  int    `json:"status,omitempty"`
+}